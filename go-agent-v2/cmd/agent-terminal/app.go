@@ -6,16 +6,19 @@
 //   - CallAPI(method, params): 通用 JSON-RPC 桥, 覆盖全部后端功能
 //   - GetLSPDiagnostics/GetLSPStatus: LSP 工具结果显示
 //   - handleBridgeNotification: Go 标准化事件 → Wails 事件 → 前端展示
+//
+// 分组从属窗口 (见 group_link.go) 没有内嵌 apiserver / agent 管理器,
+// 所有调用经 api (remoteAPI) 转发到分组宿主。
 package main
 
 import (
 	"context"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -27,13 +30,27 @@ import (
 	"github.com/wailsapp/wails/v3/pkg/application"
 )
 
+// apiInvoker JSON-RPC 调用入口 (内嵌 *apiserver.Server 或分组宿主的 remoteAPI)。
+type apiInvoker interface {
+	InvokeMethod(ctx context.Context, method string, params json.RawMessage) (any, error)
+}
+
 // App Wails 绑定 — 前端通过 window.go.main.App.XXX() 调用。
 type App struct {
-	srv      *apiserver.Server    // 后端 API (所有操作通过此调用)
-	mgr      *runner.AgentManager // Agent 进程管理 (事件监听 + 直接操作)
+	srv      *apiserver.Server    // 内嵌后端 (分组从属窗口为 nil)
+	mgr      *runner.AgentManager // Agent 进程管理 (分组从属窗口为 nil)
+	api      apiInvoker           // 后端 API (所有操作通过此调用)
 	group    string               // 分组名称
+	windowID string               // 分组内窗口 ID (group/join 分配)
 	autoN    int                  // 自动启动数量
+	tray     *agentTray           // 系统托盘 (仅分组宿主窗口)
 	wailsApp *application.App
+
+	mainWindow      application.Window   // 主窗口 (深度链接聚焦目标)
+	layout          *windowLayoutTracker // 主窗口布局持久化
+	deepLinkMu      sync.Mutex
+	runtimeReady    bool   // 前端运行时已就绪
+	pendingThreadID string // 就绪前收到的深度链接线程
 }
 
 const callAPISampleEvery int64 = 30
@@ -105,16 +122,42 @@ func shouldLogBridgeNotify(method string, seq int64) bool {
 
 // NewApp 创建 App 实例。
 func NewApp(group string, autoN int, srv *apiserver.Server, mgr *runner.AgentManager) *App {
-	return &App{
+	app := &App{
 		srv:   srv,
 		mgr:   mgr,
 		group: group,
 		autoN: autoN,
 	}
+	if srv != nil {
+		app.api = srv
+	}
+	return app
+}
+
+// newFollowerApp 创建分组从属窗口 (调用经宿主 apiserver)。
+func newFollowerApp(group string, autoN int, remote *remoteAPI) *App {
+	return &App{api: remote, group: group, autoN: autoN}
+}
+
+// invoke 调用后端 JSON-RPC 方法。
+func (a *App) invoke(ctx context.Context, method string, params json.RawMessage) (any, error) {
+	if a.api == nil {
+		return nil, apperrors.Newf("App.invoke", "server not ready")
+	}
+	return a.api.InvokeMethod(ctx, method, params)
+}
+
+func (a *App) invokeParams(ctx context.Context, method string, params any) (any, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil, apperrors.Wrapf(err, "App.invokeParams", "marshal %s params", method)
+	}
+	return a.invoke(ctx, method, data)
 }
 
 // ServiceStartup Wails v3 Service 生命周期: 应用启动时调用。
 func (a *App) ServiceStartup(_ context.Context, _ application.ServiceOptions) error {
+	a.joinGroup()
 	if a.autoN > 0 && a.wailsApp != nil {
 		a.wailsApp.Event.Emit("auto-launch", map[string]interface{}{
 			"count": a.autoN,
@@ -125,11 +168,12 @@ func (a *App) ServiceStartup(_ context.Context, _ application.ServiceOptions) er
 }
 
 func (a *App) shutdown() {
-	start := time.Now()
-	activeAgents := 0
-	if a.mgr != nil {
-		activeAgents = len(a.mgr.List())
+	a.leaveGroup()
+	if a.mgr == nil {
+		return
 	}
+	start := time.Now()
+	activeAgents := len(a.mgr.List())
 	logger.Warn("shutdown: begin", "active_agents", activeAgents)
 	done := make(chan struct{})
 	util.SafeGo(func() {
@@ -210,11 +254,13 @@ func (a *App) CallAPI(method string, params any) (result any, callErr error) {
 		result, callErr = a.handleUIBuildInfo()
 	case "ui/copyText":
 		result, callErr = a.handleUICopyText(normalizedParams)
+	case "ui/windowInfo":
+		result, callErr = a.handleUIWindowInfo()
 	case "approval/respond":
 		result, callErr = a.handleApprovalRespond(normalizedParams)
 	default:
 		// 通用 JSON-RPC 调用
-		if a.srv == nil {
+		if a.api == nil {
 			callErr = apperrors.Newf("App.CallAPI", "server not ready")
 			return nil, callErr
 		}
@@ -227,7 +273,10 @@ func (a *App) CallAPI(method string, params any) (result any, callErr error) {
 		ctx, cancel := context.WithTimeout(context.Background(), 8*time.Second)
 		defer cancel()
 
-		result, callErr = a.srv.InvokeMethod(ctx, method, json.RawMessage(paramsJSON))
+		result, callErr = a.invoke(ctx, method, json.RawMessage(paramsJSON))
+		if callErr == nil && method == "thread/start" {
+			a.claimThread(extractThreadID(util.ToMapAny(result)))
+		}
 	}
 
 	return result, callErr
@@ -282,7 +331,17 @@ func (a *App) handleApprovalRespond(params map[string]any) (any, error) {
 		return nil, apperrors.Newf("App.handleApprovalRespond", "requestId is required")
 	}
 
-	ok := a.srv.ResolvePendingRequest(int64(requestID), map[string]any{"approved": approved})
+	var ok bool
+	if a.srv != nil {
+		ok = a.srv.ResolvePendingRequest(int64(requestID), map[string]any{"approved": approved})
+	} else {
+		// 分组从属窗口: 审批 pending channel 在宿主进程中
+		result, err := a.invokeParams(context.Background(), "approval/respond", map[string]any{"requestId": int64(requestID), "approved": approved})
+		if err != nil {
+			return nil, apperrors.Wrap(err, "App.handleApprovalRespond", "forward to group leader")
+		}
+		ok, _ = util.ToMapAny(result)["ok"].(bool)
+	}
 	if !ok {
 		logger.Warn("ui: approval respond — no pending request",
 			logger.FieldSource, "ui", logger.FieldID, int64(requestID))
@@ -453,12 +512,13 @@ func (a *App) LaunchAgent(name, prompt, cwd string) (any, error) {
 	if err != nil {
 		return nil, apperrors.Wrap(err, "App.LaunchAgent", "marshal params")
 	}
-	result, err := a.srv.InvokeMethod(context.Background(), "thread/start", params)
+	result, err := a.invoke(context.Background(), "thread/start", params)
 	if err != nil {
 		return nil, apperrors.Wrap(err, "App.LaunchAgent", "invoke thread/start")
 	}
 
 	resultMap := util.ToMapAny(result)
+	a.claimThread(extractThreadID(resultMap))
 
 	// 发送初始 prompt (如果有)
 	if prompt != "" {
@@ -470,7 +530,7 @@ func (a *App) LaunchAgent(name, prompt, cwd string) (any, error) {
 			})
 			if marshalErr != nil {
 				logger.Warn("turn/start marshal failed", logger.FieldAgentID, threadID, logger.FieldError, marshalErr)
-			} else if _, err := a.srv.InvokeMethod(context.Background(), "turn/start", turnParams); err != nil {
+			} else if _, err := a.invoke(context.Background(), "turn/start", turnParams); err != nil {
 				logger.Warn("turn/start invoke failed", logger.FieldAgentID, threadID, logger.FieldError, err)
 			}
 		}
@@ -517,6 +577,9 @@ func (a *App) SubmitInput(agentID, prompt string) error {
 	logger.Info("ui: submit input", logger.FieldSource, "ui",
 		logger.FieldComponent, "chat", logger.FieldAgentID, agentID,
 		"prompt_len", len(prompt))
+	if a.mgr == nil {
+		return a.submitRemote(agentID, prompt, nil, nil)
+	}
 	return a.mgr.Submit(agentID, prompt, nil, nil)
 }
 
@@ -525,14 +588,33 @@ func (a *App) SubmitWithFiles(agentID, prompt string, images, files []string) er
 	logger.Info("ui: submit with files", logger.FieldSource, "ui",
 		logger.FieldComponent, "chat", logger.FieldAgentID, agentID,
 		"images", len(images), "files", len(files))
+	if a.mgr == nil {
+		return a.submitRemote(agentID, prompt, images, files)
+	}
 	return a.mgr.Submit(agentID, prompt, images, files)
 }
 
+// submitRemote 分组从属窗口经宿主 turn/start 提交输入。
+func (a *App) submitRemote(agentID, prompt string, images, files []string) error {
+	input := []map[string]string{{"type": "text", "text": prompt}}
+	for _, path := range images {
+		input = append(input, map[string]string{"type": "localImage", "path": path})
+	}
+	for _, path := range files {
+		input = append(input, map[string]string{"type": "mention", "path": path})
+	}
+	_, err := a.invokeParams(context.Background(), "turn/start", map[string]any{"threadId": agentID, "input": input})
+	return err
+}
+
 // SendCommand 向 Agent 发送斜杠命令。
 func (a *App) SendCommand(agentID, cmd, args string) error {
 	logger.Info("ui: send command", logger.FieldSource, "ui",
 		logger.FieldComponent, "command", logger.FieldAgentID, agentID,
 		"cmd", cmd)
+	if a.mgr == nil {
+		return apperrors.Newf("App.SendCommand", "slash commands must be sent from the group leader window")
+	}
 	return a.mgr.SendCommand(agentID, cmd, args)
 }
 
@@ -540,6 +622,9 @@ func (a *App) SendCommand(agentID, cmd, args string) error {
 func (a *App) StopAgent(id string) error {
 	logger.Info("ui: stop agent", logger.FieldSource, "ui",
 		logger.FieldComponent, "agent", logger.FieldAgentID, id)
+	if a.mgr == nil {
+		return apperrors.Newf("App.StopAgent", "agents can only be stopped from the group leader window")
+	}
 	done := make(chan error, 1)
 	util.SafeGo(func() { done <- a.mgr.Stop(id) })
 
@@ -551,9 +636,41 @@ func (a *App) StopAgent(id string) error {
 	}
 }
 
+// emergencyStopHotkey 全局快捷键触发紧急停止 (分组窗口经宿主 apiserver 执行)。
+// 解锁需显式调用 orchestrator/unlock, 快捷键不提供解锁。
+func (a *App) emergencyStopHotkey() {
+	logger.Warn("ui: emergency stop hotkey", logger.FieldSource, "ui", logger.FieldComponent, "hotkey")
+	util.SafeGo(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if _, err := a.invokeParams(ctx, "orchestrator/emergencyStop", map[string]any{
+			"reason": "hotkey " + emergencyStopHotkey,
+			"by":     "hotkey",
+		}); err != nil {
+			logger.Error("ui: emergency stop failed", logger.FieldError, err)
+		}
+	})
+}
+
 // ListAgents 返回所有 Agent 信息。
+// 分组窗口返回同组所有窗口的 agent (含未归属的 agent)。
 func (a *App) ListAgents() []runner.AgentInfo {
-	return a.mgr.List()
+	if a.group == "" && a.mgr != nil {
+		return a.mgr.List()
+	}
+	list, err := a.groupList()
+	if err != nil {
+		logger.Warn("ui: list group agents failed", logger.FieldName, a.group, logger.FieldError, err)
+		if a.mgr != nil {
+			return a.mgr.List()
+		}
+		return []runner.AgentInfo{}
+	}
+	agents := append([]runner.AgentInfo{}, list.Unassigned...)
+	for _, window := range list.Windows {
+		agents = append(agents, window.Agents...)
+	}
+	return agents
 }
 
 // GetGroup 返回当前窗口的分组名。
@@ -578,7 +695,7 @@ func (a *App) GetBuildInfo() any {
 func (a *App) GetLSPDiagnostics(filePath string) (string, error) {
 	params, _ := json.Marshal(map[string]string{"file_path": filePath})
 	// 使用 apiserver 内置的 lsp_diagnostics handler
-	result, err := a.srv.InvokeMethod(context.Background(), "lsp_diagnostics_query", params)
+	result, err := a.invoke(context.Background(), "lsp_diagnostics_query", params)
 	if err != nil {
 		// 直接查 diagCache — 如果没有专用 method, 走 JSON-RPC 不通时降级
 		return "{}", nil
@@ -594,41 +711,38 @@ func (a *App) GetLSPDiagnostics(filePath string) (string, error) {
 //	const status = await window.go.main.App.GetLSPStatus()
 //	// 返回: [{"Language":"go","Status":"running","Port":0}...]
 func (a *App) GetLSPStatus() (any, error) {
-	result, err := a.srv.InvokeMethod(context.Background(), "mcpServerStatus/list", json.RawMessage("{}"))
+	result, err := a.invoke(context.Background(), "mcpServerStatus/list", json.RawMessage("{}"))
 	if err != nil {
 		return []any{}, nil
 	}
 	return result, nil
 }
 
-// SaveClipboardImage 保存剪贴板图片(base64)到临时文件, 返回路径。
+// SaveClipboardImage 保存剪贴板图片 (base64 或 data URL) 到输入暂存目录, 返回路径。
 //
+// 经 input/stageImage 按内容哈希落盘并校验大小/格式 (分组从属窗口同样写入宿主暂存目录)。
 // 前端使用:
 //
 //	const path = await window.go.main.App.SaveClipboardImage(base64Data)
 func (a *App) SaveClipboardImage(base64Data string) (string, error) {
-	data, err := base64.StdEncoding.DecodeString(base64Data)
-	if err != nil {
-		// 尝试 RawStdEncoding (无 padding)
-		data, err = base64.RawStdEncoding.DecodeString(base64Data)
-		if err != nil {
-			return "", apperrors.Wrap(err, "App.SaveClipboardImage", "decode base64")
-		}
+	dataURL := strings.TrimSpace(base64Data)
+	if !strings.HasPrefix(strings.ToLower(dataURL), "data:") {
+		dataURL = "data:image/png;base64," + dataURL
 	}
-
-	tmpFile, err := os.CreateTemp("", "clipboard-*.png")
+	result, err := a.invokeParams(context.Background(), "input/stageImage", map[string]any{"dataUrl": dataURL})
 	if err != nil {
-		return "", apperrors.Wrap(err, "App.SaveClipboardImage", "create temp file")
+		return "", apperrors.Wrap(err, "App.SaveClipboardImage", "stage image")
 	}
-	defer func() { _ = tmpFile.Close() }()
-
-	if _, err := tmpFile.Write(data); err != nil {
-		return "", apperrors.Wrap(err, "App.SaveClipboardImage", "write temp file")
+	var staged struct {
+		Path string `json:"path"`
+		Size int64  `json:"size"`
+	}
+	if err := decodeAPIResult(result, &staged); err != nil {
+		return "", err
 	}
-
 	logger.Info("ui: saved clipboard image", logger.FieldSource, "ui",
-		logger.FieldComponent, "clipboard", "path", tmpFile.Name(), "size", len(data))
-	return tmpFile.Name(), nil
+		logger.FieldComponent, "clipboard", "path", staged.Path, "size", staged.Size)
+	return staged.Path, nil
 }
 
 // ========================================
@@ -693,6 +807,18 @@ func (a *App) handleBridgeNotification(method string, params any) {
 	}
 
 	payloadMap := util.ToMapAny(params)
+	if a.tray != nil {
+		a.tray.observe(method, payloadMap)
+	}
+	if method == apiserver.UILayoutResetMethod && a.layout != nil {
+		window, _ := payloadMap["window"].(string)
+		a.layout.handleReset(window)
+	}
+	if method == apiserver.UIThreadOpenMethod {
+		if threadID, _ := payloadMap["threadId"].(string); strings.TrimSpace(threadID) != "" {
+			a.openThreadWindow(strings.TrimSpace(threadID))
+		}
+	}
 
 	// 通用桥接事件: 前端可统一订阅 bridge-event 自行按 type 渲染。
 	a.wailsApp.Event.Emit("bridge-event", buildBridgeEventPayload(method, payloadMap))
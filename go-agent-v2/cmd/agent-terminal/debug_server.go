@@ -7,6 +7,7 @@
 //   - 从磁盘 frontend/ 目录提供静态文件 (修改即刷新)
 //   - 注入 wails-shim.js 替代 Wails runtime, 通过 HTTP 调用 apiserver
 //   - 独立于 Wails 窗口, 可在 Chrome DevTools 中调试
+//   - /debug/stats: 基于 debug/runtime 的实时指标页 (goroutine / 堆 / timeline / RPC 速率), 无需前端构建产物
 package main
 
 import (
@@ -263,28 +264,91 @@ func buildDebugShimScript(apiBaseURL string) string {
 	return strings.ReplaceAll(shimScriptTemplate, "__APP_SERVER_BASE_URL__", apiBaseURL)
 }
 
-// startDebugServer 启动调试 HTTP 服务器, 提供前端静态文件。
+// debugStatsPageTemplate /debug/stats 指标页 (轮询 debug/runtime 绘制曲线, 自动刷新)。
+//
+//go:embed shim/debug-stats.html
+var debugStatsPageTemplate string
+
+// handleDebugStatsPage 返回注入 apiserver 地址后的指标页。
+func handleDebugStatsPage(apiBaseURL string) http.HandlerFunc {
+	page := strings.ReplaceAll(debugStatsPageTemplate, "__APP_SERVER_BASE_URL__", apiBaseURL)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		_, _ = fmt.Fprint(w, page)
+	}
+}
+
+// startDebugServer 启动调试 HTTP 服务器, 提供前端静态文件与 /debug/stats 指标页。
+//
+// 找不到 frontend/dist 时仍启动服务 (仅提供指标页与构建信息), 便于生产环境快速排查。
 func startDebugServer(ctx context.Context, uiPort int, apiBaseURL string) {
-	// 查找 frontend 目录
+	mux := http.NewServeMux()
+	mux.HandleFunc("/build-info", handleDebugBuildInfo)
+	mux.HandleFunc("/debug/stats", handleDebugStatsPage(apiBaseURL))
+	if distDir := findFrontendDistDir(); distDir != "" {
+		registerDebugFrontend(mux, distDir, apiBaseURL)
+	} else {
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/" {
+				http.Redirect(w, r, "/debug/stats", http.StatusFound)
+				return
+			}
+			http.NotFound(w, r)
+		})
+	}
+
+	server := &http.Server{
+		Addr:              fmt.Sprintf("127.0.0.1:%d", uiPort),
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	util.SafeGo(func() {
+		logger.Info("debug: UI server started",
+			logger.FieldURL, fmt.Sprintf("http://localhost:%d", uiPort),
+			"stats_url", fmt.Sprintf("http://localhost:%d/debug/stats", uiPort),
+			"api_url", apiBaseURL)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Error("debug server failed", logger.FieldError, err)
+		}
+	})
+
+	util.SafeGo(func() {
+		<-ctx.Done()
+		if err := server.Close(); err != nil {
+			logger.Warn("debug: server close error", logger.FieldError, err)
+		}
+	})
+}
+
+// findFrontendDistDir 查找 frontend/dist (Vite 构建产物), 不存在时返回空串。
+func findFrontendDistDir() string {
 	frontendDir := findFrontendDir()
 	if frontendDir == "" {
-		logger.Error("debug: frontend directory not found")
-		return
+		logger.Error("debug: frontend directory not found, serving /debug/stats only")
+		return ""
 	}
-	// 使用 dist/ 子目录 (Vite 构建产物)
 	distDir := filepath.Join(frontendDir, "dist")
 	if info, err := os.Stat(distDir); err != nil || !info.IsDir() {
-		logger.Error("debug: frontend/dist not found — run 'npm run build:react' first",
+		logger.Error("debug: frontend/dist not found — run 'npm run build:react' first, serving /debug/stats only",
 			logger.FieldPath, distDir)
-		return
+		return ""
 	}
+	return distDir
+}
+
+// registerDebugFrontend 注册前端静态文件、桥接事件与文件选择接口。
+func registerDebugFrontend(mux *http.ServeMux, distDir, apiBaseURL string) {
 	logger.Info("debug: serving frontend from dist/", logger.FieldPath, distDir)
 	debugBridgeEnabled.Store(true)
 
-	mux := http.NewServeMux()
 	mux.HandleFunc("/select-project-dir", handleDebugSelectProjectDir)
 	mux.HandleFunc("/select-files", handleDebugSelectFiles)
-	mux.HandleFunc("/build-info", handleDebugBuildInfo)
 	mux.HandleFunc("/bridge/events", handleDebugBridgeEvents)
 	mux.HandleFunc("/wails/runtime.js", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
@@ -326,28 +390,6 @@ func startDebugServer(ctx context.Context, uiPort int, apiBaseURL string) {
 		// SPA fallback: 路径不匹配静态文件 → 返回 index.html (React Router 处理)
 		serveIndexWithShim(w, r)
 	})
-
-	server := &http.Server{
-		Addr:              fmt.Sprintf("127.0.0.1:%d", uiPort),
-		Handler:           mux,
-		ReadHeaderTimeout: 10 * time.Second,
-	}
-
-	util.SafeGo(func() {
-		logger.Info("debug: UI server started",
-			logger.FieldURL, fmt.Sprintf("http://localhost:%d", uiPort),
-			"api_url", apiBaseURL)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			logger.Error("debug server failed", logger.FieldError, err)
-		}
-	})
-
-	util.SafeGo(func() {
-		<-ctx.Done()
-		if err := server.Close(); err != nil {
-			logger.Warn("debug: server close error", logger.FieldError, err)
-		}
-	})
 }
 
 // handleDebugBridgeEvents 返回 Go 统一收集的桥接事件。
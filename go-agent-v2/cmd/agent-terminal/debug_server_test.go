@@ -1,7 +1,9 @@
 package main
 
 import (
+	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 )
@@ -235,3 +237,26 @@ func TestPublishDebugBridgeEvent_DroppableSkipMetric(t *testing.T) {
 		t.Fatalf("expected droppableSkipTotal=10, got %d", skipTotal)
 	}
 }
+
+func TestHandleDebugStatsPage_InjectsAPIBaseURL(t *testing.T) {
+	handler := handleDebugStatsPage("http://127.0.0.1:4599")
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/debug/stats", nil))
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("status=%d content-type=%q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(body, "'http://127.0.0.1:4599'") || strings.Contains(body, "__APP_SERVER_BASE_URL__") {
+		t.Fatal("api base url placeholder not replaced")
+	}
+	if !strings.Contains(body, "debug/runtime") {
+		t.Fatal("stats page should poll debug/runtime")
+	}
+
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodPost, "/debug/stats", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("POST status = %d, want 405", rec.Code)
+	}
+}
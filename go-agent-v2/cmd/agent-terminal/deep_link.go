@@ -0,0 +1,229 @@
+// deep_link.go — agentorch:// 深度链接: agentorch://thread/<id> 聚焦窗口并选中线程。
+//
+// 三种到达路径:
+//   - 首次启动: 系统以 `agent-terminal agentorch://thread/<id>` 拉起, Wails 派发
+//     ApplicationLaunchedWithUrl; 前端运行时就绪前先挂起, WindowRuntimeReady 后再切换。
+//   - 已有实例 (Linux / Windows): 新进程在初始化任何后端之前把链接经默认 apiserver 的
+//     ui/thread/open 转发给已运行实例后退出, 避免重复启动 agent 管理器 / 清理孤儿进程。
+//   - 已有实例 (macOS): 系统直接向运行中的应用投递 URL, 同样经 ApplicationLaunchedWithUrl 到达。
+//
+// URL scheme 注册: macOS 由 .app 的 Info.plist (CFBundleURLTypes) 声明;
+// Linux / Windows 执行一次 `agent-terminal -register-url-scheme` 写入当前用户的处理程序。
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/apiserver"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+	"github.com/wailsapp/wails/v3/pkg/application"
+)
+
+const (
+	deepLinkScheme         = "agentorch"
+	deepLinkSource         = "deep-link"
+	deepLinkForwardTimeout = 1500 * time.Millisecond
+	deepLinkDesktopFile    = "agentorch-agent-terminal.desktop"
+)
+
+var deepLinkThreadIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,256}$`)
+
+// isDeepLinkArg 判断命令行参数是否为 agentorch:// 链接。
+func isDeepLinkArg(arg string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(arg)), deepLinkScheme+"://")
+}
+
+// findDeepLinkArg 返回参数列表中首个 agentorch:// 链接 (无则空串)。
+func findDeepLinkArg(args []string) string {
+	for _, arg := range args {
+		if isDeepLinkArg(arg) {
+			return strings.TrimSpace(arg)
+		}
+	}
+	return ""
+}
+
+// parseThreadDeepLink 解析 agentorch://thread/<id> (兼容 agentorch:///thread/<id>), 返回线程 ID。
+func parseThreadDeepLink(raw string) (string, error) {
+	const op = "parseThreadDeepLink"
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", apperrors.Wrap(err, op, "parse url")
+	}
+	if !strings.EqualFold(u.Scheme, deepLinkScheme) {
+		return "", apperrors.Newf(op, "unsupported scheme %q", u.Scheme)
+	}
+	segments := make([]string, 0, 2)
+	if u.Host != "" {
+		segments = append(segments, u.Host)
+	}
+	for _, seg := range strings.Split(u.EscapedPath(), "/") {
+		if seg != "" {
+			segments = append(segments, seg)
+		}
+	}
+	if len(segments) != 2 || !strings.EqualFold(segments[0], "thread") {
+		return "", apperrors.Newf(op, "unsupported link %q (want %s://thread/<id>)", raw, deepLinkScheme)
+	}
+	threadID, err := url.PathUnescape(segments[1])
+	if err != nil {
+		return "", apperrors.Wrap(err, op, "unescape thread id")
+	}
+	if !deepLinkThreadIDPattern.MatchString(threadID) {
+		return "", apperrors.Newf(op, "invalid thread id %q", threadID)
+	}
+	return threadID, nil
+}
+
+// forwardDeepLink 把链接交给 baseURL 上已运行的实例; 返回 true 表示已转发, 当前进程可直接退出。
+func forwardDeepLink(baseURL, raw string) bool {
+	threadID, err := parseThreadDeepLink(raw)
+	if err != nil {
+		logger.Warn("deep-link: ignoring invalid link", logger.FieldURL, raw, logger.FieldError, err)
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), deepLinkForwardTimeout)
+	defer cancel()
+	params, _ := json.Marshal(map[string]string{"threadId": threadID, "source": deepLinkSource})
+	if _, err := newRemoteAPI(baseURL).InvokeMethod(ctx, apiserver.UIThreadOpenMethod, params); err != nil {
+		logger.Info("deep-link: no running instance, starting new one", "api_url", baseURL, logger.FieldError, err)
+		return false
+	}
+	logger.Info("deep-link: forwarded to running instance", logger.FieldThreadID, threadID, "api_url", baseURL)
+	return true
+}
+
+// openDeepLink 处理 Wails 投递的链接 (首次启动 / macOS 运行中)。
+func (a *App) openDeepLink(raw string) {
+	threadID, err := parseThreadDeepLink(raw)
+	if err != nil {
+		logger.Warn("deep-link: ignoring invalid link", logger.FieldURL, raw, logger.FieldError, err)
+		return
+	}
+	logger.Info("deep-link: open thread", logger.FieldThreadID, threadID)
+	a.openThreadWindow(threadID)
+}
+
+// openThreadWindow 显示主窗口并切换线程; 前端运行时未就绪时挂起到 markRuntimeReady。
+func (a *App) openThreadWindow(threadID string) {
+	a.deepLinkMu.Lock()
+	if !a.runtimeReady {
+		a.pendingThreadID = threadID
+		a.deepLinkMu.Unlock()
+		return
+	}
+	a.deepLinkMu.Unlock()
+	showThreadInWindow(a.wailsApp, a.mainWindow, threadID)
+}
+
+// markRuntimeReady 前端运行时就绪 (WindowRuntimeReady), 投递挂起的线程切换。
+func (a *App) markRuntimeReady() {
+	a.deepLinkMu.Lock()
+	a.runtimeReady = true
+	pending := a.pendingThreadID
+	a.pendingThreadID = ""
+	a.deepLinkMu.Unlock()
+	if pending != "" {
+		showThreadInWindow(a.wailsApp, a.mainWindow, pending)
+	}
+}
+
+// showThreadInWindow 显示 (必要时还原) 并聚焦窗口, 通知前端切换线程 (threadID 为空时仅显示窗口)。
+func showThreadInWindow(wailsApp *application.App, window application.Window, threadID string) {
+	if window != nil {
+		if window.IsMinimised() {
+			window.Restore()
+		}
+		window.Show()
+		window.Focus()
+	}
+	if threadID != "" && wailsApp != nil {
+		wailsApp.Event.Emit("open-thread", map[string]any{"threadId": threadID})
+	}
+}
+
+// registerURLScheme 为当前用户注册 agentorch:// 处理程序 (指向当前可执行文件)。
+func registerURLScheme() error {
+	const op = "registerURLScheme"
+	exe, err := os.Executable()
+	if err != nil {
+		return apperrors.Wrap(err, op, "resolve executable")
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	switch runtime.GOOS {
+	case "linux":
+		return registerURLSchemeLinux(exe)
+	case "windows":
+		return registerURLSchemeWindows(exe)
+	case "darwin":
+		return apperrors.New(op, "macOS registers URL schemes via the app bundle Info.plist (CFBundleURLTypes)")
+	default:
+		return apperrors.Newf(op, "unsupported platform %s", runtime.GOOS)
+	}
+}
+
+// registerURLSchemeLinux 写入 ~/.local/share/applications 桌面项并设为 x-scheme-handler 默认程序。
+func registerURLSchemeLinux(exe string) error {
+	const op = "registerURLSchemeLinux"
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return apperrors.Wrap(err, op, "user home dir")
+	}
+	dir := filepath.Join(home, ".local", "share", "applications")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return apperrors.Wrap(err, op, "create applications dir")
+	}
+	path := filepath.Join(dir, deepLinkDesktopFile)
+	if err := os.WriteFile(path, []byte(linuxDesktopEntry(exe)), 0o644); err != nil {
+		return apperrors.Wrap(err, op, "write desktop entry")
+	}
+	mime := "x-scheme-handler/" + deepLinkScheme
+	if out, err := exec.Command("xdg-mime", "default", deepLinkDesktopFile, mime).CombinedOutput(); err != nil {
+		return apperrors.Wrapf(err, op, "xdg-mime default: %s", strings.TrimSpace(string(out)))
+	}
+	logger.Info("deep-link: url scheme registered", logger.FieldPath, path, "mime", mime)
+	return nil
+}
+
+// linuxDesktopEntry 生成 agentorch:// 处理程序桌面项 (NoDisplay: 不出现在应用菜单)。
+func linuxDesktopEntry(exe string) string {
+	return fmt.Sprintf(`[Desktop Entry]
+Type=Application
+Name=Agent Orchestrator
+Exec="%s" %%u
+Terminal=false
+NoDisplay=true
+MimeType=x-scheme-handler/%s;
+`, exe, deepLinkScheme)
+}
+
+// registerURLSchemeWindows 写入 HKCU\Software\Classes\agentorch。
+func registerURLSchemeWindows(exe string) error {
+	const op = "registerURLSchemeWindows"
+	key := `HKCU\Software\Classes\` + deepLinkScheme
+	commands := [][]string{
+		{"reg", "add", key, "/ve", "/d", "URL:Agent Orchestrator", "/f"},
+		{"reg", "add", key, "/v", "URL Protocol", "/d", "", "/f"},
+		{"reg", "add", key + `\shell\open\command`, "/ve", "/d", fmt.Sprintf(`"%s" "%%1"`, exe), "/f"},
+	}
+	for _, args := range commands {
+		if out, err := exec.Command(args[0], args[1:]...).CombinedOutput(); err != nil {
+			return apperrors.Wrapf(err, op, "%s: %s", strings.Join(args, " "), strings.TrimSpace(string(out)))
+		}
+	}
+	logger.Info("deep-link: url scheme registered", "registry_key", key)
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/multi-agent/go-agent-v2/internal/apiserver"
+)
+
+func TestParseThreadDeepLink(t *testing.T) {
+	cases := []struct {
+		raw  string
+		want string
+		ok   bool
+	}{
+		{"agentorch://thread/019a-abc", "019a-abc", true},
+		{"AgentOrch://Thread/t1/", "t1", true},
+		{"agentorch:///thread/t2", "t2", true},
+		{"agentorch://thread/mock%3Aagent-1", "mock:agent-1", true},
+		{"agentorch://thread/", "", false},
+		{"agentorch://thread/a/b", "", false},
+		{"agentorch://agent/t1", "", false},
+		{"agentorch://thread/bad%20id", "", false},
+		{"https://thread/t1", "", false},
+	}
+	for _, tc := range cases {
+		got, err := parseThreadDeepLink(tc.raw)
+		if tc.ok && (err != nil || got != tc.want) {
+			t.Errorf("parseThreadDeepLink(%q) = %q, %v; want %q", tc.raw, got, err, tc.want)
+		}
+		if !tc.ok && err == nil {
+			t.Errorf("parseThreadDeepLink(%q) = %q, want error", tc.raw, got)
+		}
+	}
+}
+
+func TestFindDeepLinkArg(t *testing.T) {
+	if got := findDeepLinkArg([]string{"--debug", "agentorch://thread/t1"}); got != "agentorch://thread/t1" {
+		t.Fatalf("findDeepLinkArg = %q", got)
+	}
+	if got := findDeepLinkArg([]string{"https://example.com"}); got != "" {
+		t.Fatalf("findDeepLinkArg = %q, want empty", got)
+	}
+}
+
+func TestForwardDeepLink_InvokesThreadOpen(t *testing.T) {
+	var gotMethod, gotThread string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     any    `json:"id"`
+			Method string `json:"method"`
+			Params struct {
+				ThreadID string `json:"threadId"`
+			} `json:"params"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		gotMethod, gotThread = req.Method, req.Params.ThreadID
+		_ = json.NewEncoder(w).Encode(map[string]any{"id": req.ID, "result": map[string]any{"ok": true}})
+	}))
+	defer srv.Close()
+
+	if !forwardDeepLink(srv.URL, "agentorch://thread/t9") {
+		t.Fatal("forwardDeepLink = false, want true")
+	}
+	if gotMethod != apiserver.UIThreadOpenMethod || gotThread != "t9" {
+		t.Fatalf("forwarded method=%q thread=%q", gotMethod, gotThread)
+	}
+	if forwardDeepLink(srv.URL, "agentorch://agent/t9") {
+		t.Fatal("invalid link must not be forwarded")
+	}
+}
+
+func TestOpenThreadWindow_PendingUntilRuntimeReady(t *testing.T) {
+	app := &App{}
+	app.openDeepLink("agentorch://thread/t1")
+	app.openDeepLink("agentorch://thread/t2")
+	if app.pendingThreadID != "t2" {
+		t.Fatalf("pendingThreadID = %q, want t2", app.pendingThreadID)
+	}
+	app.markRuntimeReady()
+	if app.pendingThreadID != "" || !app.runtimeReady {
+		t.Fatalf("after ready: pending=%q ready=%v", app.pendingThreadID, app.runtimeReady)
+	}
+}
+
+func TestLinuxDesktopEntry(t *testing.T) {
+	entry := linuxDesktopEntry("/opt/agent-terminal")
+	for _, want := range []string{`Exec="/opt/agent-terminal" %u`, "MimeType=x-scheme-handler/agentorch;"} {
+		if !strings.Contains(entry, want) {
+			t.Fatalf("desktop entry missing %q:\n%s", want, entry)
+		}
+	}
+}
@@ -453,6 +453,21 @@ class ConversationManager {
         this.replyWithCommandExecutionApprovalDecision(conversationId, requestId, decision);
     }
 
+    /**
+     * 撤下已在别处答复的请求 (serverRequest/resolved); 未给出 conversationId 时在全部会话中查找
+     */
+    resolveRequest(conversationId, requestId) {
+        if (requestId == null) return;
+        const ids = conversationId ? [conversationId] : [...this.conversations.keys()];
+        for (const id of ids) {
+            const state = this.conversations.get(id);
+            if (!state?.requests?.some((r) => r.id === requestId)) continue;
+            this.updateConversationState(id, (s) => {
+                s.requests = s.requests.filter((r) => r.id !== requestId);
+            });
+        }
+    }
+
     // ======================== JSON-RPC 响应处理 ========================
 
     onResult(requestId, result) {
@@ -88,6 +88,12 @@ function setupMessageDispatcher(conversationManager, navigate, queryClient) {
                 break;
             }
 
+            // ---- 审批已在别处答复 (如移动端推送中继) → 撤下对话框 ----
+            case type === "serverRequest/resolved": {
+                cm.resolveRequest(payload?.threadId, payload?.requestId);
+                break;
+            }
+
             // ---- Skills 更新 → React Query 失效 ----
             case type === "codex/event/skills_update_available": {
                 queryClient.invalidateQueries({ queryKey: ["skills"] });
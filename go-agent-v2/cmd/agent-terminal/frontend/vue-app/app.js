@@ -72,6 +72,7 @@ export const AppRoot = {
     const dagsFields = Object.freeze([
       { key: 'dag_key', label: 'DAG' },
       { key: 'status', label: '状态' },
+      { key: 'progress', label: '进度' },
       { key: 'updated_at', label: '更新时间' },
     ]);
 
@@ -174,6 +175,11 @@ export const AppRoot = {
       });
       unsubscribeBridgeEvent = onBridgeEvent((evt) => {
         threadStore.handleBridgeEvent(evt);
+        if ((evt?.type || evt?.method) === 'skills/changed' && page.value === 'skills') {
+          refreshDashboardByPage('skills').catch((error) => {
+            console.warn('refresh skills page failed', error);
+          });
+        }
       });
       unsubscribeAppWillQuit = onAppWillQuit(() => {
         isExiting.value = true;
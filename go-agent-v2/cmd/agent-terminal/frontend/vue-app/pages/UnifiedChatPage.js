@@ -15,7 +15,7 @@ import { JsonRenderer } from '../components/JsonRenderer.js';
 import { normalizeStatus } from '../services/status.js';
 import { parseUnifiedDiff } from '../services/diff.js';
 import { hasJsonRenderSpec, extractSpecBlocks } from '../services/json-render-engine.js';
-import { callAPI, copyTextToClipboard, onFilesDropped, resolveThreadIdentity } from '../services/api.js';
+import { callAPI, copyTextToClipboard, onBridgeEvent, onFilesDropped, onOpenThread, resolveThreadIdentity } from '../services/api.js';
 import { logDebug, logInfo, logWarn } from '../services/log.js';
 import { useComposerStore } from '../stores/composer.js';
 
@@ -514,6 +514,8 @@ export const UnifiedChatPage = {
     let scrollTimer = 0;
     let copyStateTimer = 0;
     let offFilesDropped = () => { };
+    let offOpenThread = () => { };
+    let offSkillsChanged = () => { };
     let clearThreadRailResizeListeners = () => { };
     let clearActivityPanelResizeListeners = () => { };
     const editingThreadId = ref('');
@@ -2291,6 +2293,16 @@ export const UnifiedChatPage = {
       window.addEventListener('keydown', onGlobalEscape, true);
       document.addEventListener('keydown', onGlobalEscape, true);
       offFilesDropped = onFilesDropped(onNativeFilesDropped);
+      offOpenThread = onOpenThread((payload) => {
+        const threadId = (payload?.threadId || '').toString().trim();
+        if (threadId) selectThread(threadId);
+      });
+      // 技能目录热加载: 技能增删改后按当前输入重新匹配
+      offSkillsChanged = onBridgeEvent((evt) => {
+        if ((evt?.type || evt?.method || '') !== 'skills/changed') return;
+        composerSkillPreviewLastSignature = '';
+        scheduleComposerSkillPreview();
+      });
     });
 
     onBeforeUnmount(() => {
@@ -2298,6 +2310,10 @@ export const UnifiedChatPage = {
       document.removeEventListener('keydown', onGlobalEscape, true);
       offFilesDropped();
       offFilesDropped = () => { };
+      offOpenThread();
+      offOpenThread = () => { };
+      offSkillsChanged();
+      offSkillsChanged = () => { };
       dragging.value = false;
       threadRailDragging.value = false;
       activityPanelDragging.value = false;
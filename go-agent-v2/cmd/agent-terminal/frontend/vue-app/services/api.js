@@ -40,16 +40,27 @@ function parseRuntimeEventJSON(rawText) {
 }
 
 function normalizeRPCError(error) {
-  const rawMessage = (
+  const originalMessage = (
     (error && typeof error === 'object' && typeof error.message === 'string' ? error.message : '')
     || String(error || '')
-  ).toLowerCase();
+  );
+  const rawMessage = originalMessage.toLowerCase();
+  // 后端结构化错误码 (error.data.code) 以 "(code -32603, error THREAD_NOT_FOUND)" 形式附在消息末尾。
+  const errorCode = (originalMessage.match(/\(code -?\d+, error ([A-Z0-9_]+)\)/) || [])[1] || '';
+  if (errorCode && error && typeof error === 'object' && !error.errorCode) {
+    try {
+      error.errorCode = errorCode;
+    } catch {
+      // 冻结对象: 忽略
+    }
+  }
   const overloaded = rawMessage.includes('code -32001') || rawMessage.includes('server overloaded');
   if (!overloaded) {
     return error;
   }
   const normalized = new Error('Server overloaded; retry later.');
   normalized.code = -32001;
+  normalized.errorCode = errorCode;
   normalized.retryAfterMs = 500;
   normalized.cause = error;
   return normalized;
@@ -171,6 +182,30 @@ export async function callAPI(method, params = {}) {
   }
 }
 
+// callBatch 一次往返执行多个 JSON-RPC 调用 (后端 rpc/batch 按顺序分发, 单条失败互不影响)。
+// calls: [{ method, params }]; 返回与 calls 等长的 [{ result } | { error }]。
+export async function callBatch(calls = []) {
+  const requests = calls.map((call, index) => ({
+    id: index,
+    method: call.method,
+    params: call.params == null ? {} : call.params,
+  }));
+  const res = await callAPI('rpc/batch', { requests });
+  const byId = new Map((Array.isArray(res?.responses) ? res.responses : []).map((resp) => [resp?.id, resp]));
+  return requests.map((req) => {
+    const resp = byId.get(req.id);
+    if (!resp) {
+      return { error: new Error(`batch entry ${req.method} returned no response`) };
+    }
+    if (resp.error) {
+      const code = resp.error.data?.code || '';
+      const suffix = code ? `(code ${resp.error.code}, error ${code})` : `(code ${resp.error.code})`;
+      return { error: normalizeRPCError(new Error(`${resp.error.message} ${suffix}`)) };
+    }
+    return { result: resp.result };
+  });
+}
+
 export async function selectProjectDir() {
   // Project directory chooser must be handled by Go/Wails native dialog.
   logInfo('ui', 'selectProjectDir.start', {});
@@ -377,6 +412,39 @@ export function onFilesDropped(callback) {
   return () => off();
 }
 
+export function onOpenThread(callback) {
+  let off = () => { };
+  const wrapped = (evt) => {
+    const normalized = normalizeRuntimeEventEnvelope(evt);
+    try {
+      callback(normalized);
+    } catch (error) {
+      logError('event', 'openThread.callback.failed', { error });
+    }
+  };
+  waitRuntime().then((runtime) => {
+    if (!runtime?.Events?.On) {
+      logWarn('event', 'openThread.subscribe.unavailable', {});
+      return;
+    }
+    const unbind = runtime.Events.On('open-thread', wrapped);
+    logInfo('event', 'openThread.subscribe.ready', {});
+    if (typeof unbind === 'function') {
+      off = unbind;
+      return;
+    }
+    off = () => {
+      try {
+        runtime.Events.Off('open-thread');
+        logInfo('event', 'openThread.unsubscribe.done', {});
+      } catch {
+        // ignore
+      }
+    };
+  });
+  return () => off();
+}
+
 export function onAppWillQuit(callback) {
   let off = () => { };
   const wrapped = (evt) => {
@@ -1,5 +1,5 @@
 import { reactive } from '../../lib/vue.esm-browser.prod.js';
-import { callAPI } from '../services/api.js';
+import { callAPI, callBatch } from '../services/api.js';
 import { logDebug, logInfo, logWarn } from '../services/log.js';
 import {
   defaultLayoutForMode,
@@ -772,8 +772,17 @@ function handleBridgeEvent(evt) {
 async function refreshThreads() {
   const start = perfNow();
   try {
-    await callAPI('thread/list', {});
-    await syncRuntimeState();
+    // thread/list 与 ui/state/get 合并为一次往返 (后端按顺序执行, 快照包含刚刷新的线程列表)
+    const [listed, snapshot] = await callBatch([
+      { method: 'thread/list' },
+      { method: 'ui/state/get', params: { threadId: (state.activeThreadId || '').toString().trim() } },
+    ]);
+    if (listed.error) throw listed.error;
+    if (snapshot.error) {
+      await syncRuntimeState();
+    } else {
+      applyRuntimeSnapshot(snapshot.result || {});
+    }
     logDebug('thread', 'list.refreshed', {
       count: state.threads.length,
       active_chat: state.activeThreadId,
@@ -905,6 +914,13 @@ async function loadMessages(threadId, limit = 300) {
   }
 }
 
+function createIdempotencyKey(prefix) {
+  const random = globalThis.crypto?.randomUUID
+    ? globalThis.crypto.randomUUID()
+    : `${Date.now().toString(36)}-${Math.random().toString(36).slice(2, 10)}`;
+  return `${prefix}-${random}`;
+}
+
 async function sendMessage(threadId, prompt, attachments = [], options = {}) {
   const text = (prompt || '').trim();
   const hasAttachments = attachments.length > 0;
@@ -965,7 +981,8 @@ async function sendMessage(threadId, prompt, attachments = [], options = {}) {
       .filter(Boolean)
     : [];
   const manualSkillSelection = Boolean(options?.manualSkillSelection);
-  const requestPayload = { threadId, input };
+  // 重连后重发同一请求时后端按 idempotencyKey 去重, 避免重复提交 prompt。
+  const requestPayload = { threadId, input, idempotencyKey: createIdempotencyKey('turn') };
   if (selectedSkills.length > 0) {
     requestPayload.selectedSkills = selectedSkills;
   }
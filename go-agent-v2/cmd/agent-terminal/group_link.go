@@ -0,0 +1,342 @@
+// group_link.go — 多窗口分组: 同组窗口共享一个 apiserver 实例。
+//
+// 首个以 --group 启动的窗口成为宿主 (leader): 在空闲端口内嵌 apiserver, 并把地址写入
+// <UserConfigDir>/agent-terminal/groups/<group>.json。之后同组的窗口读取该文件并探测存活,
+// 成为从属窗口 (follower): 不再启动 apiserver / agent 管理器, JSON-RPC 经宿主 /rpc 调用,
+// 事件经宿主 /events (SSE) 订阅后转发到本窗口前端。
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/runner"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+const (
+	groupLeaderProbeTimeout = 1500 * time.Millisecond
+	groupSSERetryDelay      = 2 * time.Second
+)
+
+var groupFileNameSanitizer = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// groupLeaderInfo 分组宿主登记信息。
+type groupLeaderInfo struct {
+	Group     string    `json:"group"`
+	Addr      string    `json:"addr"` // host:port
+	PID       int       `json:"pid"`
+	StartedAt time.Time `json:"startedAt"`
+}
+
+func (g groupLeaderInfo) baseURL() string { return "http://" + g.Addr }
+
+// groupRegistryPath 返回分组登记文件路径。
+func groupRegistryPath(group string) (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", apperrors.Wrap(err, "groupRegistryPath", "user config dir")
+	}
+	name := groupFileNameSanitizer.ReplaceAllString(strings.TrimSpace(group), "_")
+	return filepath.Join(dir, "agent-terminal", "groups", name+".json"), nil
+}
+
+// findGroupLeader 读取登记文件并探测宿主是否存活。
+func findGroupLeader(ctx context.Context, group string) (*groupLeaderInfo, bool) {
+	path, err := groupRegistryPath(group)
+	if err != nil {
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var info groupLeaderInfo
+	if err := json.Unmarshal(data, &info); err != nil || info.Addr == "" || info.Group != group {
+		return nil, false
+	}
+	probeCtx, cancel := context.WithTimeout(ctx, groupLeaderProbeTimeout)
+	defer cancel()
+	remote := newRemoteAPI(info.baseURL())
+	params, _ := json.Marshal(map[string]string{"group": group})
+	if _, err := remote.InvokeMethod(probeCtx, "group/list", params); err != nil {
+		logger.Info("group: stale leader registration", logger.FieldName, group, "addr", info.Addr, logger.FieldError, err)
+		return nil, false
+	}
+	return &info, true
+}
+
+// registerGroupLeader 登记本进程为分组宿主, 返回注销函数 (仅删除自己写入的登记)。
+func registerGroupLeader(group, addr string) (func(), error) {
+	path, err := groupRegistryPath(group)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, apperrors.Wrap(err, "registerGroupLeader", "create registry dir")
+	}
+	info := groupLeaderInfo{Group: group, Addr: addr, PID: os.Getpid(), StartedAt: time.Now().UTC()}
+	data, err := json.Marshal(info)
+	if err != nil {
+		return nil, apperrors.Wrap(err, "registerGroupLeader", "marshal")
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return nil, apperrors.Wrap(err, "registerGroupLeader", "write registry")
+	}
+	return func() {
+		current, err := os.ReadFile(path)
+		if err != nil {
+			return
+		}
+		var registered groupLeaderInfo
+		if json.Unmarshal(current, &registered) == nil && registered.PID == info.PID {
+			_ = os.Remove(path)
+		}
+	}, nil
+}
+
+// allocateLoopbackAddr 申请一个空闲的本地回环端口。
+func allocateLoopbackAddr() (string, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", apperrors.Wrap(err, "allocateLoopbackAddr", "listen")
+	}
+	addr := ln.Addr().String()
+	_ = ln.Close()
+	return addr, nil
+}
+
+// ========================================
+// 远程 apiserver 客户端 (follower)
+// ========================================
+
+// remoteAPI 经 HTTP JSON-RPC + SSE 访问宿主 apiserver。
+type remoteAPI struct {
+	baseURL string
+	client  *http.Client
+	seq     atomic.Int64
+}
+
+func newRemoteAPI(baseURL string) *remoteAPI {
+	return &remoteAPI{baseURL: strings.TrimRight(baseURL, "/"), client: &http.Client{}}
+}
+
+// InvokeMethod 调用宿主 JSON-RPC 方法 (与 apiserver.Server.InvokeMethod 同签名)。
+func (r *remoteAPI) InvokeMethod(ctx context.Context, method string, params json.RawMessage) (any, error) {
+	body, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      r.seq.Add(1),
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return nil, apperrors.Wrap(err, "remoteAPI.InvokeMethod", "marshal request")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.baseURL+"/rpc", bytes.NewReader(body))
+	if err != nil {
+		return nil, apperrors.Wrap(err, "remoteAPI.InvokeMethod", "build request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, apperrors.Wrapf(err, "remoteAPI.InvokeMethod", "call %s", method)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var decoded struct {
+		Result any `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+			Data    struct {
+				Code string `json:"code"`
+			} `json:"data"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return nil, apperrors.Wrapf(err, "remoteAPI.InvokeMethod", "decode %s response (status %d)", method, resp.StatusCode)
+	}
+	if decoded.Error != nil {
+		if code := decoded.Error.Data.Code; code != "" {
+			return nil, apperrors.NewCodef("remoteAPI.InvokeMethod", code, "%s (code %d, error %s)", decoded.Error.Message, decoded.Error.Code, code)
+		}
+		return nil, apperrors.Newf("remoteAPI.InvokeMethod", "%s (code %d)", decoded.Error.Message, decoded.Error.Code)
+	}
+	return decoded.Result, nil
+}
+
+// subscribe 订阅宿主事件流, 断线后自动重连, 直到 ctx 结束。
+func (r *remoteAPI) subscribe(ctx context.Context, handler func(method string, params any)) {
+	for ctx.Err() == nil {
+		if err := r.streamEvents(ctx, handler); err != nil && ctx.Err() == nil {
+			logger.Warn("group: event stream interrupted, retrying", logger.FieldURL, r.baseURL, logger.FieldError, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(groupSSERetryDelay):
+		}
+	}
+}
+
+func (r *remoteAPI) streamEvents(ctx context.Context, handler func(method string, params any)) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.baseURL+"/events", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return apperrors.Newf("remoteAPI.streamEvents", "unexpected status %d", resp.StatusCode)
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var notif struct {
+			Method string `json:"method"`
+			Params any    `json:"params"`
+		}
+		if err := json.Unmarshal([]byte(data), &notif); err != nil || notif.Method == "" {
+			continue
+		}
+		handler(notif.Method, notif.Params)
+	}
+	return scanner.Err()
+}
+
+// ========================================
+// App 分组集成
+// ========================================
+
+// groupListView group/list 响应 (仅前端需要的字段)。
+type groupListView struct {
+	Group   string `json:"group"`
+	Windows []struct {
+		ID     string             `json:"id"`
+		Title  string             `json:"title"`
+		Agents []runner.AgentInfo `json:"agents"`
+	} `json:"windows"`
+	Unassigned []runner.AgentInfo `json:"unassigned"`
+}
+
+// joinGroup 向 apiserver 登记本窗口 (无分组时跳过)。
+func (a *App) joinGroup() {
+	if a.group == "" || a.api == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), groupLeaderProbeTimeout*2)
+	defer cancel()
+	result, err := a.invokeParams(ctx, "group/join", map[string]any{
+		"group": a.group,
+		"title": fmt.Sprintf("Agent Orchestrator — %s", a.group),
+		"pid":   os.Getpid(),
+	})
+	if err != nil {
+		logger.Warn("group: join failed", logger.FieldName, a.group, logger.FieldError, err)
+		return
+	}
+	var joined struct {
+		Window struct {
+			ID string `json:"id"`
+		} `json:"window"`
+	}
+	if err := decodeAPIResult(result, &joined); err != nil {
+		logger.Warn("group: decode join result failed", logger.FieldName, a.group, logger.FieldError, err)
+		return
+	}
+	a.windowID = joined.Window.ID
+	logger.Info("group: window joined", logger.FieldName, a.group, logger.FieldID, a.windowID, "leader", a.srv != nil)
+}
+
+func (a *App) leaveGroup() {
+	if a.windowID == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), groupLeaderProbeTimeout)
+	defer cancel()
+	if _, err := a.invokeParams(ctx, "group/leave", map[string]string{"windowId": a.windowID}); err != nil {
+		logger.Warn("group: leave failed", logger.FieldName, a.group, logger.FieldID, a.windowID, logger.FieldError, err)
+	}
+}
+
+// claimThread 将本窗口新建的线程登记到本窗口名下。
+func (a *App) claimThread(threadID string) {
+	if a.windowID == "" || threadID == "" {
+		return
+	}
+	if _, err := a.invokeParams(context.Background(), "group/transfer", map[string]string{"threadId": threadID, "toWindowId": a.windowID}); err != nil {
+		logger.Warn("group: claim thread failed", logger.FieldAgentID, threadID, logger.FieldError, err)
+	}
+}
+
+func (a *App) groupList() (groupListView, error) {
+	var view groupListView
+	result, err := a.invokeParams(context.Background(), "group/list", map[string]string{"group": a.group})
+	if err != nil {
+		return view, err
+	}
+	err = decodeAPIResult(result, &view)
+	return view, err
+}
+
+// decodeAPIResult 将 InvokeMethod 结果 (内嵌为 Go 结构体, 远程为 JSON 对象) 解码到 out。
+func decodeAPIResult(result any, out any) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return apperrors.Wrap(err, "decodeAPIResult", "marshal")
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return apperrors.Wrap(err, "decodeAPIResult", "unmarshal")
+	}
+	return nil
+}
+
+func (a *App) handleUIWindowInfo() (any, error) {
+	role := "standalone"
+	if a.group != "" {
+		role = "follower"
+		if a.srv != nil {
+			role = "leader"
+		}
+	}
+	return map[string]any{"group": a.group, "windowId": a.windowID, "role": role}, nil
+}
+
+// GetWindowID 返回本窗口在分组内的 ID (未分组时为空)。
+func (a *App) GetWindowID() string { return a.windowID }
+
+// TransferThread 将本窗口名下的线程移交给同组另一窗口。
+//
+// 前端使用:
+//
+//	await window.go.main.App.TransferThread(threadId, "window-2")
+func (a *App) TransferThread(threadID, toWindowID string) (any, error) {
+	if a.windowID == "" {
+		return nil, apperrors.Newf("App.TransferThread", "window is not in a group")
+	}
+	logger.Info("ui: transfer thread", logger.FieldSource, "ui",
+		logger.FieldAgentID, threadID, "from_window", a.windowID, "to_window", toWindowID)
+	return a.invokeParams(context.Background(), "group/transfer", map[string]string{
+		"threadId":     threadID,
+		"fromWindowId": a.windowID,
+		"toWindowId":   toWindowID,
+	})
+}
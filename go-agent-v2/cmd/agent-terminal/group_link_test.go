@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func newFakeGroupLeader(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rpc", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     any             `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		switch req.Method {
+		case "group/join":
+			_ = json.NewEncoder(w).Encode(map[string]any{"id": req.ID, "result": map[string]any{"window": map[string]any{"id": "window-2"}}})
+		case "group/list":
+			_ = json.NewEncoder(w).Encode(map[string]any{"id": req.ID, "result": map[string]any{
+				"group":      "alpha",
+				"windows":    []any{map[string]any{"id": "window-1", "agents": []any{map[string]any{"id": "t1", "name": "a"}}}},
+				"unassigned": []any{map[string]any{"id": "t2", "name": "b"}},
+			}})
+		default:
+			_ = json.NewEncoder(w).Encode(map[string]any{"id": req.ID, "error": map[string]any{"code": -32601, "message": "method not found: " + req.Method}})
+		}
+	})
+	mux.HandleFunc("/events", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprintf(w, "data: %s\n\n", `{"jsonrpc":"2.0","method":"group/threadTransferred","params":{"threadId":"t1"}}`)
+		w.(http.Flusher).Flush()
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRemoteAPIInvokeAndEvents(t *testing.T) {
+	leader := newFakeGroupLeader(t)
+	app := newFollowerApp("alpha", 0, newRemoteAPI(leader.URL))
+
+	app.joinGroup()
+	if app.GetWindowID() != "window-2" {
+		t.Fatalf("windowID = %q", app.GetWindowID())
+	}
+	if agents := app.ListAgents(); len(agents) != 2 {
+		t.Fatalf("ListAgents = %+v, want agents from all windows", agents)
+	}
+	if _, err := app.invoke(context.Background(), "unknown/method", json.RawMessage("{}")); err == nil {
+		t.Fatal("expected remote error to surface")
+	}
+	if err := app.StopAgent("t1"); err == nil {
+		t.Fatal("follower window should not stop agents directly")
+	}
+
+	events := make(chan string, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := newRemoteAPI(leader.URL).streamEvents(ctx, func(method string, _ any) { events <- method }); err != nil {
+		t.Fatalf("streamEvents: %v", err)
+	}
+	if got := <-events; got != "group/threadTransferred" {
+		t.Fatalf("event = %q", got)
+	}
+}
+
+func TestGroupLeaderRegistry(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	leader := newFakeGroupLeader(t)
+
+	if _, ok := findGroupLeader(context.Background(), "alpha"); ok {
+		t.Fatal("no leader should be registered yet")
+	}
+	unregister, err := registerGroupLeader("alpha", leader.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	info, ok := findGroupLeader(context.Background(), "alpha")
+	if !ok || info.PID != os.Getpid() {
+		t.Fatalf("findGroupLeader = (%+v, %v)", info, ok)
+	}
+	leader.Close()
+	if _, ok := findGroupLeader(context.Background(), "alpha"); ok {
+		t.Fatal("unreachable leader should be treated as stale")
+	}
+	unregister()
+	path, _ := groupRegistryPath("alpha")
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("registry should be removed, stat err = %v", err)
+	}
+}
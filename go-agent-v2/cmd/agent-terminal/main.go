@@ -3,6 +3,10 @@
 // 统一架构:
 //   - 内嵌 apiserver — 前端通过 Wails 绑定 App.CallAPI() 调用
 //   - Agent 事件通过 Wails Events 推送到前端
+//   - --group: 同组窗口共享首个窗口内嵌的 apiserver (见 group_link.go)
+//   - 系统托盘: agent 状态徽标 + 审批系统通知 (见 tray.go)
+//   - --mock: 演示模式, agent 使用进程内模拟 codex (codex.MockClient), 不需要 codex 二进制与 API Key
+//   - agentorch://thread/<id>: 深度链接打开线程 (见 deep_link.go; -register-url-scheme 注册处理程序)
 //
 // 构建:
 //
@@ -34,12 +38,17 @@ import (
 	"github.com/multi-agent/go-agent-v2/internal/database"
 	"github.com/multi-agent/go-agent-v2/internal/lsp"
 	"github.com/multi-agent/go-agent-v2/internal/runner"
+	"github.com/multi-agent/go-agent-v2/internal/store"
 	"github.com/multi-agent/go-agent-v2/pkg/logger"
 	"github.com/multi-agent/go-agent-v2/pkg/util"
 	"github.com/wailsapp/wails/v3/pkg/application"
 	"github.com/wailsapp/wails/v3/pkg/events"
+	"github.com/wailsapp/wails/v3/pkg/services/notifications"
 )
 
+// emergencyStopHotkey 紧急停止全局快捷键 (中断全部 turn 并冻结, 见 apiserver/emergency_stop.go)。
+const emergencyStopHotkey = "CmdOrCtrl+Shift+Escape"
+
 //go:embed frontend/dist/*
 var assets embed.FS
 
@@ -115,14 +124,32 @@ func main() {
 		logger.Warn("file logging unavailable", logger.FieldError, err)
 	}
 
-	group := flag.String("group", "", "窗口分组名称 (显示在标题栏)")
+	group := flag.String("group", "", "窗口分组名称 (同组窗口共享 apiserver, 可互相移交线程)")
 	n := flag.Int("n", 0, "自动启动的 Agent 数量")
 	debug := flag.Bool("debug", false, "调试模式: 在 :4501 启动 HTTP UI 服务, 浏览器访问")
+	mock := flag.Bool("mock", false, "演示模式: 使用进程内模拟 codex 后端 (不需要 codex 二进制与 API Key)")
+	registerScheme := flag.Bool("register-url-scheme", false, "为当前用户注册 agentorch:// 链接处理程序后退出")
+	debugPprof := flag.Bool("debug-pprof", false, "在回环地址开启 net/http/pprof 调试端口 (含 /debug/pprof/trace)")
+	debugPprofAddr := flag.String("debug-pprof-addr", apiserver.DefaultPprofAddr, "pprof 调试端口监听地址 (仅允许回环地址)")
 	flag.Parse()
 
+	if *registerScheme {
+		if err := registerURLScheme(); err != nil {
+			logger.Error("deep-link: register url scheme failed", logger.FieldError, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	apiAddr := "127.0.0.1:4500"
 	apiBaseURL := "http://127.0.0.1:4500"
 
+	// ─── 深度链接: 已有默认实例时转发后退出 (须在启动 agent 管理器之前) ───
+	deepLink := findDeepLinkArg(flag.Args())
+	if deepLink != "" && *group == "" && forwardDeepLink(apiBaseURL, deepLink) {
+		return
+	}
+
 	title := "Agent Orchestrator"
 	if *group != "" {
 		title = fmt.Sprintf("Agent Orchestrator — %s", *group)
@@ -133,14 +160,56 @@ func main() {
 	defer cancel()
 	defer signalCleanup()
 
-	// ─── 数据库 ───
-	cfg := config.Load()
-	// Wails 桌面 App 需要全部 JSON-RPC 方法 (config/read, model/list 等)
-	cfg.DisableOffline52Methods = false
-	pool := setupDatabase(ctx, cfg)
+	// ─── 多窗口分组: 已有宿主时作为从属窗口连接, 否则在空闲端口内嵌 apiserver ───
+	var leader *groupLeaderInfo
+	if *group != "" {
+		if found, ok := findGroupLeader(ctx, *group); ok {
+			leader = found
+			apiBaseURL = found.baseURL()
+		} else if addr, err := allocateLoopbackAddr(); err == nil {
+			apiAddr = addr
+			apiBaseURL = "http://" + addr
+		} else {
+			logger.Warn("group: allocate leader port failed, using default", logger.FieldName, *group, logger.FieldError, err)
+		}
+	}
 
-	// ─── 内嵌 apiserver ───
-	appSrv, mgr := setupAppServer(ctx, cfg, pool, apiAddr)
+	var (
+		appSvc *App
+		mgr    *runner.AgentManager
+		pool   *pgxpool.Pool
+	)
+	if leader != nil {
+		logger.Info("group: joining existing group leader", logger.FieldName, *group, "addr", leader.Addr, "leader_pid", leader.PID)
+		remote := newRemoteAPI(apiBaseURL)
+		appSvc = newFollowerApp(*group, *n, remote)
+		util.SafeGo(func() { remote.subscribe(ctx, appSvc.handleBridgeNotification) })
+	} else {
+		// ─── 数据库 ───
+		cfg := config.Load()
+		// Wails 桌面 App 需要全部 JSON-RPC 方法 (config/read, model/list 等)
+		cfg.DisableOffline52Methods = false
+		pool = setupDatabase(ctx, cfg)
+
+		// ─── 内嵌 apiserver ───
+		var appSrv *apiserver.Server
+		appSrv, mgr = setupAppServer(ctx, cfg, pool, apiAddr, *mock)
+		if *group != "" {
+			if unregister, err := registerGroupLeader(*group, apiAddr); err != nil {
+				logger.Warn("group: register leader failed", logger.FieldName, *group, logger.FieldError, err)
+			} else {
+				defer unregister()
+			}
+		}
+		appSvc = NewApp(*group, *n, appSrv, mgr)
+		appSrv.SetNotifyHook(appSvc.handleBridgeNotification)
+	}
+
+	if *debugPprof {
+		if err := apiserver.StartPprofServer(ctx, *debugPprofAddr); err != nil {
+			logger.Warn("debug-pprof: start failed", logger.FieldError, err)
+		}
+	}
 
 	// ─── 调试模式 ───
 	if *debug {
@@ -151,8 +220,6 @@ func main() {
 	}
 
 	// ─── Wails App ───
-	appSvc := NewApp(*group, *n, appSrv, mgr)
-	appSrv.SetNotifyHook(appSvc.handleBridgeNotification)
 	var quitOverlayShown atomic.Bool
 	var quitForceAllowed atomic.Bool
 	var coverageFlushed atomic.Bool
@@ -167,6 +234,7 @@ func main() {
 		flushCoverage("root_context_done")
 	})
 
+	notifier := notifications.New()
 	app := application.New(application.Options{
 		Name: "Agent Orchestrator",
 		Icon: appIcon,
@@ -175,10 +243,14 @@ func main() {
 		},
 		Services: []application.Service{
 			application.NewService(appSvc),
+			application.NewService(notifier),
 		},
 		Mac: application.MacOptions{
 			ApplicationShouldTerminateAfterLastWindowClosed: true,
 		},
+		KeyBindings: map[string]func(window application.Window){
+			emergencyStopHotkey: func(application.Window) { appSvc.emergencyStopHotkey() },
+		},
 		ShouldQuit: func() bool {
 			logger.Info("quit: request received",
 				"force_allowed", quitForceAllowed.Load(),
@@ -215,7 +287,11 @@ func main() {
 		OnShutdown: func() {
 			cancelWithReason("wails_on_shutdown")
 			reason, _ := shutdownReason.Load().(string)
-			logger.Warn("on-shutdown: begin", "reason", reason, "active_agents", len(mgr.List()))
+			activeAgents := 0
+			if mgr != nil {
+				activeAgents = len(mgr.List())
+			}
+			logger.Warn("on-shutdown: begin", "reason", reason, "active_agents", activeAgents)
 			appSvc.shutdown()
 			flushCoverage("wails_on_shutdown")
 			logger.ShutdownDBHandler()
@@ -229,12 +305,31 @@ func main() {
 
 	appSvc.wailsApp = app
 
+	app.Event.OnApplicationEvent(events.Common.ApplicationLaunchedWithUrl, func(event *application.ApplicationEvent) {
+		if event == nil {
+			return
+		}
+		appSvc.openDeepLink(event.Context().URL())
+	})
+	if deepLink != "" {
+		// 带 --group 等额外参数时 Wails 不派发 ApplicationLaunchedWithUrl; 重复投递同一线程只挂起一次。
+		appSvc.openDeepLink(deepLink)
+	}
+
+	// ─── 窗口布局: 按保存的尺寸创建, 应用启动后按显示器恢复位置 ───
+	layoutKey := windowLayoutKey(*group)
+	savedLayout, hasLayout := appSvc.loadWindowGeometry(ctx, layoutKey)
+	windowWidth, windowHeight := defaultWindowWidth, defaultWindowHeight
+	if hasLayout {
+		windowWidth, windowHeight = clampWindowSize(savedLayout.Width, savedLayout.Height)
+	}
+
 	mainWindow := app.Window.NewWithOptions(application.WebviewWindowOptions{
 		Title:           title,
-		Width:           1440,
-		Height:          900,
-		MinWidth:        800,
-		MinHeight:       600,
+		Width:           windowWidth,
+		Height:          windowHeight,
+		MinWidth:        minWindowWidth,
+		MinHeight:       minWindowHeight,
 		EnableFileDrop:  true,
 		InitialPosition: application.WindowCentered,
 		BackgroundColour: application.RGBA{
@@ -245,6 +340,20 @@ func main() {
 		},
 	})
 
+	appSvc.mainWindow = mainWindow
+	appSvc.layout = newWindowLayoutTracker(appSvc, mainWindow, layoutKey)
+	app.Event.OnApplicationEvent(events.Common.ApplicationStarted, func(*application.ApplicationEvent) {
+		appSvc.layout.restore(app.Screen.GetAll(), savedLayout, hasLayout)
+	})
+	mainWindow.OnWindowEvent(events.Common.WindowRuntimeReady, func(*application.WindowEvent) {
+		appSvc.markRuntimeReady()
+	})
+
+	if appSvc.srv != nil {
+		appSvc.tray = newAgentTray(appSvc, notifier)
+		appSvc.tray.start(ctx, app, mainWindow, appIcon)
+	}
+
 	mainWindow.OnWindowEvent(events.Common.WindowFilesDropped, func(event *application.WindowEvent) {
 		if event == nil {
 			return
@@ -367,10 +476,29 @@ func setupDatabase(ctx context.Context, cfg *config.Config) *pgxpool.Pool {
 	return pool
 }
 
-// setupAppServer 创建 apiserver + runner manager 并启动监听。
-func setupAppServer(ctx context.Context, cfg *config.Config, pool *pgxpool.Pool, addr string) (*apiserver.Server, *runner.AgentManager) {
+// setupAppServer 创建 apiserver + runner manager 并启动监听 (mock = 注入模拟 codex 后端)。
+func setupAppServer(ctx context.Context, cfg *config.Config, pool *pgxpool.Pool, addr string, mock bool) (*apiserver.Server, *runner.AgentManager) {
 	mgr := runner.NewAgentManager()
-	runner.CleanOrphanedProcesses()
+	portStateFile := runner.ResolvePortStateFile(cfg.AgentPortStateFile)
+	if mock {
+		mgr.SetClientFactory(codex.MockClientFactory(nil, codex.DefaultMockStepDelay))
+		logger.Warn("demo mode: agents use the in-process mock codex backend")
+		portStateFile = ""
+	} else {
+		// 先接管上次遗留且仍可恢复线程的 app-server, 其余清理。
+		allocs, err := runner.ReadPortAllocations(portStateFile)
+		if err != nil {
+			logger.Warn("read agent port allocations failed", logger.FieldPath, portStateFile, logger.FieldError, err)
+		}
+		var adopted map[int]bool
+		if cfg.AgentAdoptOrphans && pool != nil {
+			adopted = mgr.AdoptOrphans(ctx, allocs, store.NewAgentCodexBindingStore(pool).CodexThreadID)
+		}
+		runner.CleanOrphanedProcesses(allocs, adopted)
+	}
+	if err := mgr.ConfigurePorts(runner.PortOptions{Min: cfg.AgentPortMin, Max: cfg.AgentPortMax, StateFile: portStateFile}); err != nil {
+		logger.Warn("agent port range invalid, using defaults", logger.FieldError, err)
+	}
 	lspMgr := lsp.NewManager(nil)
 
 	deps := apiserver.Deps{
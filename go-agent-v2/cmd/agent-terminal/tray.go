@@ -0,0 +1,295 @@
+// tray.go — 系统托盘: agent 状态徽标 (运行中 / 待审批 / 出错) + 快捷操作 + 审批系统通知。
+//
+// 托盘标签 (macOS 菜单栏文字) 与提示显示各状态计数, 菜单提供 "中断全部" 与按线程打开。
+// 审批请求 (item/commandExecution|fileChange/requestApproval) 到达时发送系统通知,
+// 点击通知显示主窗口并切换到对应线程 (Wails 事件 open-thread)。
+// 仅分组宿主窗口创建托盘; 宿主的 ListAgents 已汇总分组内所有窗口的 agent。
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/runner"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+	"github.com/multi-agent/go-agent-v2/pkg/util"
+	"github.com/wailsapp/wails/v3/pkg/application"
+	"github.com/wailsapp/wails/v3/pkg/services/notifications"
+)
+
+const (
+	trayRefreshInterval    = 5 * time.Second
+	trayRefreshMinInterval = 500 * time.Millisecond // 通知密集时的最小刷新间隔
+	trayNotifyBodyMaxRunes = 160
+)
+
+// trayCounts 托盘徽标计数。
+type trayCounts struct {
+	Working int
+	Waiting int
+	Error   int
+	Idle    int
+}
+
+// summarizeTrayAgents 按 agent 状态汇总计数; 待审批优先于运行状态, 已停止的 agent 不计。
+func summarizeTrayAgents(agents []runner.AgentInfo, waiting map[string]bool) trayCounts {
+	var counts trayCounts
+	for _, agent := range agents {
+		if waiting[agent.ID] {
+			counts.Waiting++
+			continue
+		}
+		switch agent.State {
+		case runner.StateThinking, runner.StateRunning:
+			counts.Working++
+		case runner.StateError:
+			counts.Error++
+		case runner.StateStopped:
+		default:
+			counts.Idle++
+		}
+	}
+	return counts
+}
+
+// label 托盘标签 (省略为 0 的计数, 全为 0 时为空)。
+func (c trayCounts) label() string {
+	var parts []string
+	if c.Working > 0 {
+		parts = append(parts, fmt.Sprintf("▶%d", c.Working))
+	}
+	if c.Waiting > 0 {
+		parts = append(parts, fmt.Sprintf("⏸%d", c.Waiting))
+	}
+	if c.Error > 0 {
+		parts = append(parts, fmt.Sprintf("✖%d", c.Error))
+	}
+	return strings.Join(parts, " ")
+}
+
+// tooltip 托盘提示 / 菜单首行。
+func (c trayCounts) tooltip() string {
+	return fmt.Sprintf("运行中 %d · 待审批 %d · 出错 %d · 空闲 %d", c.Working, c.Waiting, c.Error, c.Idle)
+}
+
+// isApprovalRequestMethod 是否为需要用户回复的审批请求通知。
+func isApprovalRequestMethod(method string) bool {
+	return method == "item/commandExecution/requestApproval" || method == "item/fileChange/requestApproval"
+}
+
+// approvalNotification 由审批请求构造系统通知。
+func approvalNotification(method string, payload map[string]any) notifications.NotificationOptions {
+	threadID, _ := payload["threadId"].(string)
+	title := "Agent 请求执行命令"
+	if method == "item/fileChange/requestApproval" {
+		title = "Agent 请求修改文件"
+	}
+	body := ""
+	for _, key := range []string{"command", "reason", "path", "file"} {
+		switch v := payload[key].(type) {
+		case string:
+			body = v
+		case []any:
+			parts := make([]string, 0, len(v))
+			for _, item := range v {
+				parts = append(parts, fmt.Sprint(item))
+			}
+			body = strings.Join(parts, " ")
+		case []string:
+			body = strings.Join(v, " ")
+		}
+		if strings.TrimSpace(body) != "" {
+			break
+		}
+	}
+	if runes := []rune(body); len(runes) > trayNotifyBodyMaxRunes {
+		body = string(runes[:trayNotifyBodyMaxRunes]) + "…"
+	}
+	id := fmt.Sprintf("approval-%s-%v", threadID, payload["requestId"])
+	return notifications.NotificationOptions{
+		ID:       id,
+		Title:    title,
+		Subtitle: threadID,
+		Body:     strings.TrimSpace(body),
+		Data:     map[string]any{"threadId": threadID, "requestId": payload["requestId"]},
+	}
+}
+
+// agentTray 系统托盘状态。
+type agentTray struct {
+	app      *App
+	notifier *notifications.NotificationService
+	tray     *application.SystemTray
+	menu     *application.Menu
+	window   application.Window
+	poke     chan struct{}
+
+	mu      sync.Mutex
+	waiting map[string]bool // threadID → 有未回复的审批请求
+}
+
+func newAgentTray(app *App, notifier *notifications.NotificationService) *agentTray {
+	return &agentTray{
+		app:      app,
+		notifier: notifier,
+		poke:     make(chan struct{}, 1),
+		waiting:  make(map[string]bool),
+	}
+}
+
+// start 创建托盘图标并启动刷新循环。
+func (t *agentTray) start(ctx context.Context, wailsApp *application.App, window application.Window, icon []byte) {
+	t.window = window
+	t.menu = application.NewMenu()
+	t.tray = wailsApp.SystemTray.New()
+	t.tray.SetIcon(icon)
+	t.tray.SetTooltip("Agent Orchestrator")
+	t.tray.SetMenu(t.menu)
+
+	if t.notifier != nil {
+		t.notifier.OnNotificationResponse(func(result notifications.NotificationResult) {
+			if result.Error != nil {
+				logger.Warn("tray: notification response failed", logger.FieldError, result.Error)
+				return
+			}
+			threadID, _ := result.Response.UserInfo["threadId"].(string)
+			t.openThread(threadID)
+		})
+		util.SafeGo(func() {
+			if ok, err := t.notifier.RequestNotificationAuthorization(); err != nil || !ok {
+				logger.Warn("tray: notification authorization unavailable", "granted", ok, logger.FieldError, err)
+			}
+		})
+	}
+
+	util.SafeGo(func() {
+		ticker := time.NewTicker(trayRefreshInterval)
+		defer ticker.Stop()
+		for {
+			t.refresh()
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-t.poke:
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(trayRefreshMinInterval):
+				}
+			}
+		}
+	})
+}
+
+// observe 处理桥接通知: 维护待审批集合, 审批请求发送系统通知, 并触发刷新。
+func (t *agentTray) observe(method string, payload map[string]any) {
+	lower := strings.ToLower(method)
+	if strings.Contains(lower, "delta") || strings.Contains(lower, "output") {
+		return
+	}
+	threadID, _ := payload["threadId"].(string)
+	t.mu.Lock()
+	switch {
+	case isApprovalRequestMethod(method):
+		if threadID != "" {
+			t.waiting[threadID] = true
+		}
+	case method == "orchestrator/emergencyStopped":
+		t.waiting = make(map[string]bool)
+	case threadID != "" && (method == "turn/completed" || strings.HasPrefix(method, "item/")):
+		// 审批已回复 (后续事件到达) 或 turn 结束
+		delete(t.waiting, threadID)
+	}
+	t.mu.Unlock()
+
+	if isApprovalRequestMethod(method) && t.notifier != nil {
+		if err := t.notifier.SendNotification(approvalNotification(method, payload)); err != nil {
+			logger.Warn("tray: send approval notification failed", logger.FieldThreadID, threadID, logger.FieldError, err)
+		}
+	}
+	select {
+	case t.poke <- struct{}{}:
+	default:
+	}
+}
+
+func (t *agentTray) waitingSnapshot() map[string]bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make(map[string]bool, len(t.waiting))
+	for id := range t.waiting {
+		out[id] = true
+	}
+	return out
+}
+
+// refresh 重新汇总计数并重建菜单。
+func (t *agentTray) refresh() {
+	if t.tray == nil {
+		return
+	}
+	agents := t.app.ListAgents()
+	sort.Slice(agents, func(i, j int) bool { return agents[i].Name < agents[j].Name })
+	waiting := t.waitingSnapshot()
+	counts := summarizeTrayAgents(agents, waiting)
+	t.tray.SetLabel(counts.label())
+	t.tray.SetTooltip(counts.tooltip())
+
+	t.menu.Clear()
+	t.menu.Add(counts.tooltip()).SetEnabled(false)
+	t.menu.AddSeparator()
+	t.menu.Add("中断全部 agent").
+		SetEnabled(counts.Working+counts.Waiting > 0).
+		OnClick(func(*application.Context) { t.interruptAll(agents, waiting) })
+	threads := t.menu.AddSubmenu("打开线程")
+	if len(agents) == 0 {
+		threads.Add("(无 agent)").SetEnabled(false)
+	}
+	for _, agent := range agents {
+		threadID := agent.ID
+		threads.Add(trayAgentLabel(agent, waiting[threadID])).
+			OnClick(func(*application.Context) { t.openThread(threadID) })
+	}
+	t.menu.AddSeparator()
+	t.menu.Add("显示窗口").OnClick(func(*application.Context) { t.openThread("") })
+	t.menu.Update()
+}
+
+func trayAgentLabel(agent runner.AgentInfo, waiting bool) string {
+	name := strings.TrimSpace(agent.Name)
+	if name == "" {
+		name = agent.ID
+	}
+	state := string(agent.State)
+	if waiting {
+		state = "待审批"
+	}
+	return fmt.Sprintf("%s [%s]", name, state)
+}
+
+// interruptAll 中断所有运行中 / 待审批的 agent。
+func (t *agentTray) interruptAll(agents []runner.AgentInfo, waiting map[string]bool) {
+	util.SafeGo(func() {
+		for _, agent := range agents {
+			if !waiting[agent.ID] && agent.State != runner.StateThinking && agent.State != runner.StateRunning {
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			_, err := t.app.invokeParams(ctx, "turn/interrupt", map[string]any{"threadId": agent.ID})
+			cancel()
+			if err != nil {
+				logger.Warn("tray: interrupt failed", logger.FieldAgentID, agent.ID, logger.FieldError, err)
+			}
+		}
+	})
+}
+
+// openThread 显示主窗口并通知前端切换线程 (threadID 为空时仅显示窗口)。
+func (t *agentTray) openThread(threadID string) {
+	showThreadInWindow(t.app.wailsApp, t.window, threadID)
+}
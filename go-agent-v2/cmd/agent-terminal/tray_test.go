@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/multi-agent/go-agent-v2/internal/runner"
+)
+
+func TestSummarizeTrayAgents(t *testing.T) {
+	agents := []runner.AgentInfo{
+		{ID: "a", State: runner.StateRunning},
+		{ID: "b", State: runner.StateThinking},
+		{ID: "c", State: runner.StateRunning},
+		{ID: "d", State: runner.StateError},
+		{ID: "e", State: runner.StateIdle},
+		{ID: "f", State: runner.StateStopped},
+	}
+	counts := summarizeTrayAgents(agents, map[string]bool{"c": true})
+	want := trayCounts{Working: 2, Waiting: 1, Error: 1, Idle: 1}
+	if counts != want {
+		t.Fatalf("counts = %+v, want %+v", counts, want)
+	}
+	if got := counts.label(); got != "▶2 ⏸1 ✖1" {
+		t.Fatalf("label = %q", got)
+	}
+	if got := (trayCounts{Idle: 3}).label(); got != "" {
+		t.Fatalf("idle-only label = %q, want empty", got)
+	}
+}
+
+func TestApprovalNotification(t *testing.T) {
+	opts := approvalNotification("item/commandExecution/requestApproval", map[string]any{
+		"threadId":  "thread-1",
+		"requestId": 7,
+		"command":   []any{"rm", "-rf", "build"},
+	})
+	if opts.Title != "Agent 请求执行命令" || opts.Body != "rm -rf build" || opts.Subtitle != "thread-1" {
+		t.Fatalf("opts = %+v", opts)
+	}
+	if opts.Data["threadId"] != "thread-1" || opts.ID != "approval-thread-1-7" {
+		t.Fatalf("data = %+v id = %q", opts.Data, opts.ID)
+	}
+
+	file := approvalNotification("item/fileChange/requestApproval", map[string]any{"threadId": "t", "reason": "apply patch"})
+	if file.Title != "Agent 请求修改文件" || file.Body != "apply patch" {
+		t.Fatalf("file opts = %+v", file)
+	}
+}
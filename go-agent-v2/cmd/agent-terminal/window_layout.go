@@ -0,0 +1,221 @@
+// window_layout.go — 窗口布局持久化: 移动/缩放后保存几何状态, 启动时按显示器恢复。
+//
+// 几何状态经 ui/layout/save 写入 uistate 偏好 (windows.layout), 窗口键按分组区分
+// ("main" / "group:<name>")。恢复时若记录的显示器已不存在且位置落在所有屏幕之外则居中,
+// 收到 ui/layout/reset 通知时回到默认尺寸并居中。
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/uistate"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+	"github.com/wailsapp/wails/v3/pkg/application"
+	"github.com/wailsapp/wails/v3/pkg/events"
+)
+
+const (
+	defaultWindowWidth  = 1440
+	defaultWindowHeight = 900
+	minWindowWidth      = 800
+	minWindowHeight     = 600
+
+	windowLayoutSaveDelay  = 500 * time.Millisecond
+	windowLayoutRPCTimeout = 3 * time.Second
+	// windowTitleProbe 标题栏探测点相对窗口左上角的偏移: 该点可见才认为窗口可拖回。
+	windowTitleProbeX = 100
+	windowTitleProbeY = 16
+)
+
+// windowLayoutKey 返回窗口布局键 (跨重启稳定)。
+func windowLayoutKey(group string) string {
+	if group = strings.TrimSpace(group); group != "" {
+		return "group:" + group
+	}
+	return "main"
+}
+
+// clampWindowSize 将尺寸限制为不小于窗口最小尺寸。
+func clampWindowSize(width, height int) (int, int) {
+	return max(width, minWindowWidth), max(height, minWindowHeight)
+}
+
+// resolveWindowScreen 为保存的几何状态选择目标屏幕: 先按 ID、再按名称匹配,
+// 且标题栏探测点须落在该屏幕内; 都不满足时退回探测点所在的任意屏幕。返回 nil 表示应居中。
+func resolveWindowScreen(g uistate.WindowGeometry, screens []*application.Screen) *application.Screen {
+	probe := application.Point{X: g.X + min(windowTitleProbeX, g.Width/2), Y: g.Y + windowTitleProbeY}
+	var matched *application.Screen
+	for _, screen := range screens {
+		if screen != nil && g.Monitor != "" && screen.ID == g.Monitor {
+			matched = screen
+			break
+		}
+	}
+	if matched == nil && g.MonitorName != "" {
+		for _, screen := range screens {
+			if screen != nil && screen.Name == g.MonitorName {
+				matched = screen
+				break
+			}
+		}
+	}
+	if matched != nil && rectContains(matched.Bounds, probe) {
+		return matched
+	}
+	for _, screen := range screens {
+		if screen != nil && rectContains(screen.Bounds, probe) {
+			return screen
+		}
+	}
+	return nil
+}
+
+func rectContains(r application.Rect, p application.Point) bool {
+	return p.X >= r.X && p.X < r.X+r.Width && p.Y >= r.Y && p.Y < r.Y+r.Height
+}
+
+// loadWindowGeometry 读取窗口布局 (失败或无记录时返回 false)。
+func (a *App) loadWindowGeometry(ctx context.Context, key string) (uistate.WindowGeometry, bool) {
+	ctx, cancel := context.WithTimeout(ctx, windowLayoutRPCTimeout)
+	defer cancel()
+	result, err := a.invokeParams(ctx, "ui/layout/get", map[string]any{"window": key})
+	if err != nil {
+		logger.Warn("layout: load failed", logger.FieldName, key, logger.FieldError, err)
+		return uistate.WindowGeometry{}, false
+	}
+	var view struct {
+		Geometry *uistate.WindowGeometry `json:"geometry"`
+	}
+	if err := decodeAPIResult(result, &view); err != nil || view.Geometry == nil || !view.Geometry.Valid() {
+		return uistate.WindowGeometry{}, false
+	}
+	return *view.Geometry, true
+}
+
+// windowLayoutTracker 跟踪单个窗口的几何变化并去抖保存。
+type windowLayoutTracker struct {
+	app    *App
+	window application.Window
+	key    string
+
+	mu      sync.Mutex
+	timer   *time.Timer
+	normal  application.Rect // 最近一次非最大化边界
+	started bool
+}
+
+func newWindowLayoutTracker(app *App, window application.Window, key string) *windowLayoutTracker {
+	return &windowLayoutTracker{app: app, window: window, key: key}
+}
+
+// restore 按保存的几何状态摆放窗口 (屏幕不可用时居中), 随后开始跟踪变化。
+func (t *windowLayoutTracker) restore(screens []*application.Screen, g uistate.WindowGeometry, ok bool) {
+	if ok {
+		if screen := resolveWindowScreen(g, screens); screen != nil {
+			width, height := clampWindowSize(min(g.Width, screen.WorkArea.Width), min(g.Height, screen.WorkArea.Height))
+			bounds := application.Rect{X: g.X, Y: g.Y, Width: width, Height: height}
+			t.mu.Lock()
+			t.normal = bounds
+			t.mu.Unlock()
+			t.window.SetBounds(bounds)
+			if g.Maximized {
+				t.window.Maximise()
+			}
+			logger.Info("layout: window restored",
+				logger.FieldName, t.key,
+				"screen", screen.Name,
+				"x", g.X, "y", g.Y, "width", width, "height", height,
+				"maximized", g.Maximized)
+		} else {
+			t.window.Center()
+			logger.Info("layout: saved monitor unavailable, window centered",
+				logger.FieldName, t.key, "monitor", g.MonitorName, "screens", len(screens))
+		}
+	}
+	t.start()
+}
+
+// start 注册移动/缩放/最大化事件 (幂等)。
+func (t *windowLayoutTracker) start() {
+	t.mu.Lock()
+	if t.started {
+		t.mu.Unlock()
+		return
+	}
+	t.started = true
+	t.mu.Unlock()
+	for _, eventType := range []events.WindowEventType{
+		events.Common.WindowDidMove,
+		events.Common.WindowDidResize,
+		events.Common.WindowMaximise,
+		events.Common.WindowUnMaximise,
+	} {
+		t.window.OnWindowEvent(eventType, func(*application.WindowEvent) { t.schedule() })
+	}
+}
+
+// schedule 去抖: 拖动过程中的连续事件只在停止后保存一次。
+func (t *windowLayoutTracker) schedule() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+	t.timer = time.AfterFunc(windowLayoutSaveDelay, t.save)
+}
+
+// save 采集当前几何状态并写入偏好; 最小化时跳过, 最大化时保留之前的普通边界。
+func (t *windowLayoutTracker) save() {
+	if t.window.IsMinimised() || t.window.IsFullscreen() {
+		return
+	}
+	maximized := t.window.IsMaximised()
+	t.mu.Lock()
+	if !maximized {
+		t.normal = t.window.Bounds()
+	}
+	bounds := t.normal
+	t.mu.Unlock()
+	if bounds.Width <= 0 || bounds.Height <= 0 {
+		return
+	}
+	geometry := uistate.WindowGeometry{
+		X:         bounds.X,
+		Y:         bounds.Y,
+		Width:     bounds.Width,
+		Height:    bounds.Height,
+		Maximized: maximized,
+	}
+	if screen, err := t.window.GetScreen(); err == nil && screen != nil {
+		geometry.Monitor = screen.ID
+		geometry.MonitorName = screen.Name
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), windowLayoutRPCTimeout)
+	defer cancel()
+	if _, err := t.app.invokeParams(ctx, "ui/layout/save", map[string]any{"window": t.key, "geometry": geometry}); err != nil {
+		logger.Warn("layout: save failed", logger.FieldName, t.key, logger.FieldError, err)
+	}
+}
+
+// handleReset 响应 ui/layout/reset 通知: 目标为本窗口 (或全部) 时回到默认尺寸并居中。
+func (t *windowLayoutTracker) handleReset(window string) {
+	if window = strings.TrimSpace(window); window != "" && window != t.key {
+		return
+	}
+	t.mu.Lock()
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+	t.normal = application.Rect{}
+	t.mu.Unlock()
+	if t.window.IsMaximised() {
+		t.window.UnMaximise()
+	}
+	t.window.SetSize(defaultWindowWidth, defaultWindowHeight)
+	t.window.Center()
+	logger.Info("layout: window reset to default", logger.FieldName, t.key)
+}
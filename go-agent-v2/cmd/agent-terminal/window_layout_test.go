@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/multi-agent/go-agent-v2/internal/uistate"
+	"github.com/wailsapp/wails/v3/pkg/application"
+)
+
+func TestWindowLayoutKey(t *testing.T) {
+	if got := windowLayoutKey(""); got != "main" {
+		t.Fatalf("windowLayoutKey(\"\") = %q", got)
+	}
+	if got := windowLayoutKey(" alpha "); got != "group:alpha" {
+		t.Fatalf("windowLayoutKey(alpha) = %q", got)
+	}
+}
+
+func TestClampWindowSize(t *testing.T) {
+	if w, h := clampWindowSize(320, 200); w != minWindowWidth || h != minWindowHeight {
+		t.Fatalf("clampWindowSize small = %dx%d", w, h)
+	}
+	if w, h := clampWindowSize(1920, 1080); w != 1920 || h != 1080 {
+		t.Fatalf("clampWindowSize large = %dx%d", w, h)
+	}
+}
+
+func TestResolveWindowScreen(t *testing.T) {
+	primary := &application.Screen{ID: "1", Name: "eDP-1", Bounds: application.Rect{X: 0, Y: 0, Width: 1920, Height: 1080}}
+	external := &application.Screen{ID: "2", Name: "HDMI-1", Bounds: application.Rect{X: 1920, Y: 0, Width: 2560, Height: 1440}}
+	onExternal := uistate.WindowGeometry{X: 2000, Y: 50, Width: 1440, Height: 900, Monitor: "2", MonitorName: "HDMI-1"}
+
+	if got := resolveWindowScreen(onExternal, []*application.Screen{primary, external}); got != external {
+		t.Fatalf("both monitors: got %#v, want external", got)
+	}
+
+	// 外接屏 ID 变化但名称不变。
+	renumbered := &application.Screen{ID: "7", Name: "HDMI-1", Bounds: external.Bounds}
+	if got := resolveWindowScreen(onExternal, []*application.Screen{primary, renumbered}); got != renumbered {
+		t.Fatalf("renumbered monitor: got %#v", got)
+	}
+
+	// 外接屏已拔出: 位置落在所有屏幕之外 → 居中。
+	if got := resolveWindowScreen(onExternal, []*application.Screen{primary}); got != nil {
+		t.Fatalf("unplugged monitor: got %#v, want nil", got)
+	}
+
+	// 显示器标识未知但位置仍在某块屏幕内。
+	anon := uistate.WindowGeometry{X: 100, Y: 100, Width: 1200, Height: 800}
+	if got := resolveWindowScreen(anon, []*application.Screen{primary, external}); got != primary {
+		t.Fatalf("anonymous geometry: got %#v, want primary", got)
+	}
+
+	if got := resolveWindowScreen(onExternal, nil); got != nil {
+		t.Fatalf("no screens: got %#v, want nil", got)
+	}
+}
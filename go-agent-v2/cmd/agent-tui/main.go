@@ -0,0 +1,59 @@
+// cmd/agent-tui — app-server 终端 UI: 线程列表、时间线流式输出、发送 turn、处理审批。
+//
+// 用法:
+//
+//	agent-tui [-addr ws://127.0.0.1:4500] [-cwd .] [-model o3]
+//
+// 面向只能 SSH 登录的用户: 连接已运行的 app-server (WebSocket JSON-RPC),
+// 不依赖 Wails 桌面端。列表屏 n 新建线程 (-cwd / -model 作为 thread/start 参数),
+// enter 打开线程; 线程屏 enter 发送, esc 返回, ctrl+x 中断当前 turn。
+// 审批请求 (命令执行 / 文件修改) 在底栏提示, y 批准 / n 拒绝。
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/multi-agent/go-agent-v2/internal/rpcclient"
+)
+
+func main() {
+	addr := flag.String("addr", rpcclient.DefaultAddr, "app-server WebSocket address")
+	cwd := flag.String("cwd", ".", "working directory for new threads")
+	modelName := flag.String("model", "", "model for new threads (empty = server default)")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: agent-tui [flags]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	dir, err := filepath.Abs(*cwd)
+	if err != nil {
+		fail("Resolve -cwd failed: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	client, err := rpcclient.Dial(ctx, *addr)
+	cancel()
+	if err != nil {
+		fail("Connect to app-server failed: %v", err)
+	}
+	defer client.Close()
+
+	m := newModel(client, *addr, dir, *modelName)
+	if _, err := tea.NewProgram(m, tea.WithAltScreen()).Run(); err != nil {
+		fail("agent-tui: %v", err)
+	}
+	if m.fatal != nil {
+		fail("%v", m.fatal)
+	}
+}
+
+func fail(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
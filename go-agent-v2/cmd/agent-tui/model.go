@@ -0,0 +1,467 @@
+// model.go — Bubble Tea 模型: 线程列表 / 线程时间线两屏 + 审批提示。
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/multi-agent/go-agent-v2/internal/rpcclient"
+)
+
+// callTimeout 单次 RPC 超时 (turn/start 只等受理, 不等完成)。
+const callTimeout = 30 * time.Second
+
+type screen int
+
+const (
+	screenList screen = iota
+	screenThread
+)
+
+// threadItem thread/list 条目。
+type threadItem struct {
+	ID    string   `json:"id"`
+	Name  string   `json:"name"`
+	State string   `json:"state"`
+	Tags  []string `json:"tags,omitempty"`
+}
+
+// 异步命令结果。
+type (
+	threadsMsg struct {
+		threads []threadItem
+		err     error
+	}
+	historyMsg struct {
+		threadID string
+		messages []historyMessage
+		err      error
+	}
+	threadStartedMsg struct {
+		threadID string
+		err      error
+	}
+	notifyMsg       rpcclient.Notification
+	disconnectedMsg struct{ err error }
+	statusMsg       struct {
+		text string
+		err  error
+	}
+)
+
+type model struct {
+	client *rpcclient.Client
+	addr   string
+	cwd    string
+	model  string
+
+	screen    screen
+	threads   []threadItem
+	cursor    int
+	active    string
+	timelines map[string]*timeline
+	approvals []rpcclient.Notification
+
+	viewport viewport.Model
+	input    textinput.Model
+	width    int
+	height   int
+	status   string
+	err      error
+	fatal    error // 连接断开 (退出码非零)
+}
+
+func newModel(client *rpcclient.Client, addr, cwd, modelName string) *model {
+	input := textinput.New()
+	input.Placeholder = "Send a message (Enter to send, Esc to go back)"
+	input.CharLimit = 0
+	return &model{
+		client:    client,
+		addr:      addr,
+		cwd:       cwd,
+		model:     modelName,
+		timelines: map[string]*timeline{},
+		viewport:  viewport.New(80, 20),
+		input:     input,
+	}
+}
+
+func (m *model) Init() tea.Cmd {
+	return tea.Batch(m.loadThreads(), m.waitNotification())
+}
+
+// ========================================
+// 命令 (后台 RPC)
+// ========================================
+
+func (m *model) call(method string, params, out any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+	return m.client.CallInto(ctx, method, params, out)
+}
+
+func (m *model) waitNotification() tea.Cmd {
+	return func() tea.Msg {
+		n, ok := <-m.client.Notifications()
+		if !ok {
+			return disconnectedMsg{err: m.client.Err()}
+		}
+		return notifyMsg(n)
+	}
+}
+
+func (m *model) loadThreads() tea.Cmd {
+	return func() tea.Msg {
+		var res struct {
+			Threads []threadItem `json:"threads"`
+		}
+		err := m.call("thread/list", nil, &res)
+		return threadsMsg{threads: res.Threads, err: err}
+	}
+}
+
+func (m *model) loadHistory(threadID string) tea.Cmd {
+	return func() tea.Msg {
+		var res struct {
+			Messages []historyMessage `json:"messages"`
+		}
+		err := m.call("thread/messages", map[string]any{"threadId": threadID, "limit": 200}, &res)
+		return historyMsg{threadID: threadID, messages: res.Messages, err: err}
+	}
+}
+
+func (m *model) startThread() tea.Cmd {
+	return func() tea.Msg {
+		params := map[string]any{"cwd": m.cwd}
+		if m.model != "" {
+			params["model"] = m.model
+		}
+		var res struct {
+			Thread struct {
+				ID string `json:"id"`
+			} `json:"thread"`
+		}
+		err := m.call("thread/start", params, &res)
+		return threadStartedMsg{threadID: res.Thread.ID, err: err}
+	}
+}
+
+// sendTurn turn/start (未加载的线程由服务端自动恢复)。
+func (m *model) sendTurn(threadID, text string) tea.Cmd {
+	return func() tea.Msg {
+		err := m.call("turn/start", map[string]any{
+			"threadId": threadID,
+			"input":    []map[string]any{{"type": "text", "text": text}},
+		}, nil)
+		return statusMsg{text: "turn submitted", err: err}
+	}
+}
+
+func (m *model) interrupt(threadID string) tea.Cmd {
+	return func() tea.Msg {
+		err := m.call("turn/interrupt", map[string]any{"threadId": threadID}, nil)
+		return statusMsg{text: "interrupt sent", err: err}
+	}
+}
+
+func (m *model) respondApproval(req rpcclient.Notification, approved bool) tea.Cmd {
+	return func() tea.Msg {
+		err := m.client.Respond(req.ID, map[string]any{"approved": approved})
+		verdict := "denied"
+		if approved {
+			verdict = "approved"
+		}
+		return statusMsg{text: verdict, err: err}
+	}
+}
+
+// ========================================
+// Update
+// ========================================
+
+func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.input.Width = max(msg.Width-4, 10)
+		m.viewport.Width = msg.Width
+		m.viewport.Height = max(msg.Height-5, 3)
+		m.refreshViewport(true)
+		return m, nil
+	case tea.KeyMsg:
+		return m.handleKey(msg)
+	case threadsMsg:
+		m.setStatus("", msg.err)
+		if msg.err == nil {
+			m.threads = msg.threads
+			m.cursor = min(m.cursor, max(len(m.threads)-1, 0))
+		}
+		return m, nil
+	case historyMsg:
+		m.setStatus("", msg.err)
+		if msg.err == nil {
+			m.timelineFor(msg.threadID).prependHistory(msg.messages)
+			m.refreshViewport(true)
+		}
+		return m, nil
+	case threadStartedMsg:
+		if msg.err != nil {
+			m.setStatus("", msg.err)
+			return m, nil
+		}
+		m.timelineFor(msg.threadID).loaded = true
+		m.open(msg.threadID)
+		return m, m.loadThreads()
+	case statusMsg:
+		m.setStatus(msg.text, msg.err)
+		return m, nil
+	case notifyMsg:
+		m.handleNotification(rpcclient.Notification(msg))
+		return m, m.waitNotification()
+	case disconnectedMsg:
+		m.fatal = fmt.Errorf("disconnected from app-server: %v", msg.err)
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+func (m *model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if msg.String() == "ctrl+c" {
+		return m, tea.Quit
+	}
+	if len(m.approvals) > 0 {
+		switch msg.String() {
+		case "y", "n":
+			req := m.approvals[0]
+			m.approvals = m.approvals[1:]
+			return m, m.respondApproval(req, msg.String() == "y")
+		}
+		return m, nil
+	}
+	if m.screen == screenList {
+		switch msg.String() {
+		case "q":
+			return m, tea.Quit
+		case "up", "k":
+			m.cursor = max(m.cursor-1, 0)
+		case "down", "j":
+			m.cursor = min(m.cursor+1, max(len(m.threads)-1, 0))
+		case "r":
+			return m, m.loadThreads()
+		case "n":
+			m.status = "starting thread…"
+			return m, m.startThread()
+		case "enter":
+			if m.cursor < len(m.threads) {
+				id := m.threads[m.cursor].ID
+				m.open(id)
+				if !m.timelineFor(id).loaded {
+					return m, m.loadHistory(id)
+				}
+			}
+		}
+		return m, nil
+	}
+
+	switch msg.String() {
+	case "esc":
+		m.screen = screenList
+		m.input.Blur()
+		return m, m.loadThreads()
+	case "ctrl+x":
+		return m, m.interrupt(m.active)
+	case "enter":
+		text := strings.TrimSpace(m.input.Value())
+		if text == "" {
+			return m, nil
+		}
+		m.input.Reset()
+		tl := m.timelineFor(m.active)
+		tl.add(entryUser, text)
+		tl.running = true
+		m.refreshViewport(true)
+		return m, m.sendTurn(m.active, text)
+	case "pgup", "pgdown", "ctrl+u", "ctrl+d":
+		var cmd tea.Cmd
+		m.viewport, cmd = m.viewport.Update(msg)
+		return m, cmd
+	}
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+func (m *model) handleNotification(n rpcclient.Notification) {
+	if n.IsRequest() {
+		if strings.HasSuffix(n.Method, "/requestApproval") {
+			m.approvals = append(m.approvals, n)
+		}
+		return
+	}
+	var p notifyParams
+	if json.Unmarshal(n.Params, &p) != nil || p.ThreadID == "" {
+		return
+	}
+	switch n.Method {
+	case "turn/started":
+		m.setThreadState(p.ThreadID, "running")
+	case "turn/completed":
+		m.setThreadState(p.ThreadID, "idle")
+	}
+	if !m.timelineFor(p.ThreadID).apply(n.Method, p) || p.ThreadID != m.active {
+		return
+	}
+	m.refreshViewport(m.viewport.AtBottom())
+}
+
+// ========================================
+// 状态辅助
+// ========================================
+
+func (m *model) timelineFor(threadID string) *timeline {
+	tl := m.timelines[threadID]
+	if tl == nil {
+		tl = &timeline{}
+		m.timelines[threadID] = tl
+	}
+	return tl
+}
+
+func (m *model) open(threadID string) {
+	m.active = threadID
+	m.screen = screenThread
+	m.input.Focus()
+	m.refreshViewport(true)
+}
+
+func (m *model) setThreadState(threadID, state string) {
+	for i := range m.threads {
+		if m.threads[i].ID == threadID {
+			m.threads[i].State = state
+		}
+	}
+}
+
+func (m *model) setStatus(text string, err error) {
+	m.status, m.err = text, err
+}
+
+func (m *model) refreshViewport(follow bool) {
+	if m.active == "" {
+		return
+	}
+	m.viewport.SetContent(m.timelineFor(m.active).render(m.viewport.Width))
+	if follow {
+		m.viewport.GotoBottom()
+	}
+}
+
+// ========================================
+// View
+// ========================================
+
+var (
+	styleHeader   = lipgloss.NewStyle().Bold(true).Reverse(true).Padding(0, 1)
+	styleSelected = lipgloss.NewStyle().Foreground(lipgloss.Color("14")).Bold(true)
+	styleHelp     = lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+	styleApproval = lipgloss.NewStyle().Foreground(lipgloss.Color("0")).Background(lipgloss.Color("11")).Padding(0, 1)
+)
+
+func (m *model) View() string {
+	var b strings.Builder
+	if m.screen == screenList {
+		b.WriteString(styleHeader.Render("agent-tui · " + m.addr))
+		b.WriteString("\n\n")
+		b.WriteString(m.renderThreadList())
+	} else {
+		b.WriteString(styleHeader.Render(m.threadTitle(m.active)))
+		b.WriteString("\n")
+		b.WriteString(m.viewport.View())
+		b.WriteString("\n")
+		b.WriteString(m.input.View())
+	}
+	b.WriteString("\n")
+	b.WriteString(m.footer())
+	return b.String()
+}
+
+func (m *model) renderThreadList() string {
+	if len(m.threads) == 0 {
+		return styleHelp.Render("No threads. Press n to start one.") + "\n"
+	}
+	var b strings.Builder
+	rows := max(m.height-5, 1)
+	start := max(0, m.cursor-rows+1)
+	for i := start; i < len(m.threads) && i < start+rows; i++ {
+		t := m.threads[i]
+		line := fmt.Sprintf("%-10s %s", t.State, displayName(t))
+		if len(t.Tags) > 0 {
+			line += styleHelp.Render("  [" + strings.Join(t.Tags, " ") + "]")
+		}
+		if i == m.cursor {
+			line = styleSelected.Render("▸ " + line)
+		} else {
+			line = "  " + line
+		}
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
+
+func (m *model) threadTitle(threadID string) string {
+	for _, t := range m.threads {
+		if t.ID == threadID {
+			return displayName(t) + " · " + t.State
+		}
+	}
+	return threadID
+}
+
+func displayName(t threadItem) string {
+	if t.Name != "" && t.Name != t.ID {
+		return t.Name + " (" + t.ID + ")"
+	}
+	return t.ID
+}
+
+func (m *model) footer() string {
+	if len(m.approvals) > 0 {
+		return styleApproval.Render(approvalPrompt(m.approvals[0]) + "  [y] approve  [n] deny")
+	}
+	if m.err != nil {
+		return styleError.Render(m.err.Error())
+	}
+	help := "↑/↓ select · enter open · n new thread · r refresh · q quit"
+	if m.screen == screenThread {
+		help = "enter send · esc back · ctrl+x interrupt · pgup/pgdn scroll · ctrl+c quit"
+	}
+	if m.status != "" {
+		help = m.status + " · " + help
+	}
+	return styleHelp.Render(help)
+}
+
+// approvalPrompt 审批请求摘要 (命令或待修改文件)。
+func approvalPrompt(req rpcclient.Notification) string {
+	var p struct {
+		ThreadID string          `json:"threadId"`
+		Command  json.RawMessage `json:"command"`
+		Reason   string          `json:"reason"`
+	}
+	_ = json.Unmarshal(req.Params, &p)
+	subject := "file change"
+	if len(p.Command) > 0 {
+		subject = "run: " + commandText(p.Command)
+	}
+	if p.Reason != "" {
+		subject += " (" + p.Reason + ")"
+	}
+	return fmt.Sprintf("[%s] approve %s?", p.ThreadID, subject)
+}
@@ -0,0 +1,210 @@
+// timeline.go — 线程时间线: 历史消息 + 流式通知折叠为可渲染条目。
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// entryKind 时间线条目类型 (决定渲染样式)。
+type entryKind int
+
+const (
+	entryUser entryKind = iota
+	entryAgent
+	entryReasoning
+	entryCommand
+	entryOutput
+	entryInfo
+	entryError
+)
+
+// maxOutputLines 单条命令输出渲染保留的尾部行数。
+const maxOutputLines = 12
+
+type timelineEntry struct {
+	kind entryKind
+	text string
+}
+
+// timeline 单线程时间线。loaded 表示历史已拉取 (避免重复 thread/messages)。
+type timeline struct {
+	entries []timelineEntry
+	loaded  bool
+	running bool
+}
+
+// notifyParams 时间线关心的通知字段 (threadId 始终在顶层)。
+type notifyParams struct {
+	ThreadID string          `json:"threadId"`
+	Delta    string          `json:"delta"`
+	Message  string          `json:"message"`
+	Error    json.RawMessage `json:"error"`
+	Item     struct {
+		Type    string          `json:"type"`
+		Command json.RawMessage `json:"command"`
+		Changes []struct {
+			Path string `json:"path"`
+		} `json:"changes"`
+	} `json:"item"`
+	Turn struct {
+		Status string `json:"status"`
+	} `json:"turn"`
+}
+
+// historyMessage thread/messages 返回的单条历史。
+type historyMessage struct {
+	ID      int64  `json:"id"`
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+func (t *timeline) add(kind entryKind, text string) {
+	t.entries = append(t.entries, timelineEntry{kind: kind, text: text})
+}
+
+// addDelta 追加流式增量: 与末条同类型时拼接, 否则新开一条。
+func (t *timeline) addDelta(kind entryKind, delta string) {
+	if delta == "" {
+		return
+	}
+	if n := len(t.entries); n > 0 && t.entries[n-1].kind == kind {
+		t.entries[n-1].text += delta
+		return
+	}
+	t.add(kind, delta)
+}
+
+// prependHistory 将历史消息 (任意顺序) 按 id 升序放到现有条目之前。
+func (t *timeline) prependHistory(msgs []historyMessage) {
+	sort.Slice(msgs, func(i, j int) bool { return msgs[i].ID < msgs[j].ID })
+	history := make([]timelineEntry, 0, len(msgs))
+	for _, m := range msgs {
+		text := strings.TrimSpace(m.Content)
+		if text == "" {
+			continue
+		}
+		kind := entryAgent
+		if m.Role == "user" {
+			kind = entryUser
+		}
+		history = append(history, timelineEntry{kind: kind, text: text})
+	}
+	t.entries = append(history, t.entries...)
+	t.loaded = true
+}
+
+// apply 将一条通知折叠进时间线; 返回 false 表示与时间线无关。
+func (t *timeline) apply(method string, p notifyParams) bool {
+	switch method {
+	case "turn/started":
+		t.running = true
+	case "turn/completed":
+		t.running = false
+		if p.Turn.Status != "" && p.Turn.Status != "completed" {
+			t.add(entryInfo, "turn "+p.Turn.Status)
+		}
+	case "item/agentMessage/delta":
+		t.addDelta(entryAgent, p.Delta)
+	case "item/reasoning/summaryTextDelta":
+		t.addDelta(entryReasoning, p.Delta)
+	case "item/commandExecution/outputDelta":
+		t.addDelta(entryOutput, p.Delta)
+	case "item/started":
+		switch p.Item.Type {
+		case "commandExecution":
+			t.add(entryCommand, commandText(p.Item.Command))
+		case "fileChange":
+			paths := make([]string, 0, len(p.Item.Changes))
+			for _, c := range p.Item.Changes {
+				paths = append(paths, c.Path)
+			}
+			t.add(entryInfo, "edit "+strings.Join(paths, ", "))
+		default:
+			return false
+		}
+	case "error":
+		t.add(entryError, errorText(p))
+	default:
+		return false
+	}
+	return true
+}
+
+// commandText command 字段可能是字符串或 argv 数组。
+func commandText(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	var argv []string
+	if json.Unmarshal(raw, &argv) == nil {
+		return strings.Join(argv, " ")
+	}
+	return string(raw)
+}
+
+func errorText(p notifyParams) string {
+	var e struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(p.Error, &e) == nil && e.Message != "" {
+		return e.Message
+	}
+	var s string
+	if json.Unmarshal(p.Error, &s) == nil && s != "" {
+		return s
+	}
+	if p.Message != "" {
+		return p.Message
+	}
+	return "error"
+}
+
+var (
+	styleUser      = lipgloss.NewStyle().Foreground(lipgloss.Color("14")).Bold(true)
+	styleReasoning = lipgloss.NewStyle().Foreground(lipgloss.Color("8")).Italic(true)
+	styleCommand   = lipgloss.NewStyle().Foreground(lipgloss.Color("11"))
+	styleOutput    = lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+	styleInfo      = lipgloss.NewStyle().Foreground(lipgloss.Color("12"))
+	styleError     = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+)
+
+// render 渲染为按 width 折行的文本。
+func (t *timeline) render(width int) string {
+	wrap := lipgloss.NewStyle().Width(max(width, 20))
+	blocks := make([]string, 0, len(t.entries))
+	for _, e := range t.entries {
+		text := strings.TrimRight(e.text, "\n")
+		switch e.kind {
+		case entryUser:
+			text = styleUser.Render("› ") + text
+		case entryReasoning:
+			text = styleReasoning.Render(text)
+		case entryCommand:
+			text = styleCommand.Render("$ " + text)
+		case entryOutput:
+			text = styleOutput.Render(tailLines(text, maxOutputLines))
+		case entryInfo:
+			text = styleInfo.Render("• " + text)
+		case entryError:
+			text = styleError.Render("✗ " + text)
+		}
+		blocks = append(blocks, wrap.Render(text))
+	}
+	if t.running {
+		blocks = append(blocks, styleInfo.Render("… working"))
+	}
+	return strings.Join(blocks, "\n\n")
+}
+
+func tailLines(s string, n int) string {
+	lines := strings.Split(s, "\n")
+	if len(lines) <= n {
+		return s
+	}
+	return "…\n" + strings.Join(lines[len(lines)-n:], "\n")
+}
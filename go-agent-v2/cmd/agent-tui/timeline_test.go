@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestTimelineFoldsStreamAndHistory(t *testing.T) {
+	tl := &timeline{}
+	apply := func(method, params string) {
+		var p notifyParams
+		if err := json.Unmarshal([]byte(params), &p); err != nil {
+			t.Fatal(err)
+		}
+		tl.apply(method, p)
+	}
+	apply("turn/started", `{"threadId":"t"}`)
+	apply("item/agentMessage/delta", `{"threadId":"t","delta":"Hel"}`)
+	apply("item/agentMessage/delta", `{"threadId":"t","delta":"lo"}`)
+	apply("item/started", `{"threadId":"t","item":{"type":"commandExecution","command":["go","test"]}}`)
+	apply("item/commandExecution/outputDelta", `{"threadId":"t","delta":"ok\n"}`)
+	apply("error", `{"threadId":"t","error":{"message":"boom"}}`)
+	if !tl.running || len(tl.entries) != 4 || tl.entries[0].text != "Hello" || tl.entries[1].text != "go test" {
+		t.Fatalf("entries = %+v", tl.entries)
+	}
+	apply("turn/completed", `{"threadId":"t","turn":{"status":"interrupted"}}`)
+	if tl.running || tl.entries[len(tl.entries)-1].text != "turn interrupted" {
+		t.Fatalf("after completion = %+v", tl.entries)
+	}
+
+	tl.prependHistory([]historyMessage{{ID: 2, Role: "assistant", Content: "earlier reply"}, {ID: 1, Role: "user", Content: "earlier ask"}})
+	if !tl.loaded || tl.entries[0].kind != entryUser || tl.entries[1].text != "earlier reply" {
+		t.Fatalf("history = %+v", tl.entries[:2])
+	}
+	if out := tl.render(80); !strings.Contains(out, "$ go test") || !strings.Contains(out, "boom") {
+		t.Fatalf("render = %q", out)
+	}
+	if got := tailLines("a\nb\nc", 2); got != "…\nb\nc" {
+		t.Fatalf("tailLines = %q", got)
+	}
+}
@@ -0,0 +1,50 @@
+// cmd/agent — app-server 命令行客户端 (脚本 / CI 友好)。
+//
+// 用法:
+//
+//	agent run [-addr ws://127.0.0.1:4500] [-cwd .] [-model o3] [-approve] [-timeout 30m] [-no-diff] "prompt"
+//
+// run: 新建线程, 发送一次 turn, 将助手输出流式写到 stdout (命令 / 文件修改进度写 stderr),
+// 等待 turn 结束后打印本轮 diff 并退出。prompt 为 "-" 时从 stdin 读取。
+//
+// 退出码: 0 成功; 1 turn 失败 / 中断 / 超时; 2 用法错误; 3 无法连接或 RPC 失败; 130 被 Ctrl+C 中断。
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+const (
+	exitOK          = 0
+	exitTurnFailed  = 1
+	exitUsage       = 2
+	exitRPCError    = 3
+	exitInterrupted = 130
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(exitUsage)
+	}
+	switch os.Args[1] {
+	case "run":
+		os.Exit(runCommand(os.Args[2:]))
+	case "-h", "-help", "--help", "help":
+		usage()
+	default:
+		fmt.Fprintf(os.Stderr, "agent: unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(exitUsage)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: agent <command> [flags]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "commands:")
+	fmt.Fprintln(os.Stderr, "  run    run one prompt in a new thread, stream output, print the diff and exit")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "run 'agent run -h' for command flags")
+}
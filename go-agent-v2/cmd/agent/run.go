@@ -0,0 +1,329 @@
+// run.go — agent run: 一次性执行 prompt (新建线程 → turn → 流式输出 → diff → 退出码)。
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/rpcclient"
+)
+
+// interruptGrace 发出 turn/interrupt 后等待 turn/completed 的时间。
+const interruptGrace = 10 * time.Second
+
+const (
+	statusCompleted   = "completed"
+	statusInterrupted = "interrupted"
+	statusTimeout     = "timeout"
+)
+
+type runOptions struct {
+	addr    string
+	cwd     string
+	model   string
+	approve bool
+	timeout time.Duration
+	noDiff  bool
+}
+
+// runOutcome 一次 run 的结果。
+type runOutcome struct {
+	ThreadID string
+	Status   string // completed / failed / interrupted / timeout
+	Diff     string
+	Error    string // 不再重试的错误通知
+	Canceled bool   // 被信号中断
+}
+
+// runEvent run 关心的通知字段。
+type runEvent struct {
+	ThreadID  string          `json:"threadId"`
+	Delta     string          `json:"delta"`
+	Diff      string          `json:"diff"`
+	Message   string          `json:"message"`
+	Command   json.RawMessage `json:"command"` // 审批请求
+	WillRetry bool            `json:"willRetry"`
+	Error     json.RawMessage `json:"error"`
+	Item      struct {
+		Type    string          `json:"type"`
+		Command json.RawMessage `json:"command"`
+		Changes []struct {
+			Path string `json:"path"`
+		} `json:"changes"`
+	} `json:"item"`
+	Turn struct {
+		Status string `json:"status"`
+	} `json:"turn"`
+}
+
+func runCommand(args []string) int {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	opts := runOptions{}
+	fs.StringVar(&opts.addr, "addr", rpcclient.DefaultAddr, "app-server WebSocket address")
+	fs.StringVar(&opts.cwd, "cwd", ".", "working directory for the thread")
+	fs.StringVar(&opts.model, "model", "", "model (empty = server default)")
+	fs.BoolVar(&opts.approve, "approve", false, "approve command / file-change approval requests (default: deny)")
+	fs.DurationVar(&opts.timeout, "timeout", 30*time.Minute, "max turn duration before interrupting")
+	fs.BoolVar(&opts.noDiff, "no-diff", false, "do not print the turn diff")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, `usage: agent run [flags] "prompt" (use "-" to read the prompt from stdin)`)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	prompt, err := readPrompt(fs.Args(), os.Stdin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "agent run: %v\n", err)
+		return exitUsage
+	}
+	if prompt == "" {
+		fs.Usage()
+		return exitUsage
+	}
+	if opts.cwd, err = filepath.Abs(opts.cwd); err != nil {
+		fmt.Fprintf(os.Stderr, "agent run: resolve -cwd: %v\n", err)
+		return exitUsage
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	dialCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	client, err := rpcclient.Dial(dialCtx, opts.addr)
+	cancel()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "agent run: connect to app-server: %v\n", err)
+		return exitRPCError
+	}
+	defer client.Close()
+
+	outcome, err := runPrompt(ctx, client, opts, prompt, os.Stdout, os.Stderr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "agent run: %v\n", err)
+		return exitRPCError
+	}
+	return outcome.exitCode()
+}
+
+func (o runOutcome) exitCode() int {
+	switch {
+	case o.Canceled:
+		return exitInterrupted
+	case o.Status == statusCompleted && o.Error == "":
+		return exitOK
+	}
+	return exitTurnFailed
+}
+
+// readPrompt 拼接位置参数; 单个 "-" 时读取 stdin。
+func readPrompt(args []string, stdin io.Reader) (string, error) {
+	if len(args) == 1 && args[0] == "-" {
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return "", fmt.Errorf("read prompt from stdin: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	return strings.TrimSpace(strings.Join(args, " ")), nil
+}
+
+// runPrompt 新建线程并执行一个 turn, 直到 turn/completed (或超时 / 信号中断后的宽限期结束)。
+// 返回 error 仅表示 RPC / 连接失败。
+func runPrompt(ctx context.Context, client *rpcclient.Client, opts runOptions, prompt string, stdout, stderr io.Writer) (runOutcome, error) {
+	outcome := runOutcome{}
+	callCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	params := map[string]any{"cwd": opts.cwd}
+	if opts.model != "" {
+		params["model"] = opts.model
+	}
+	var started struct {
+		Thread struct {
+			ID string `json:"id"`
+		} `json:"thread"`
+		Model string `json:"model"`
+	}
+	if err := client.CallInto(callCtx, "thread/start", params, &started); err != nil {
+		return outcome, fmt.Errorf("thread/start: %w", err)
+	}
+	outcome.ThreadID = started.Thread.ID
+	fmt.Fprintf(stderr, "thread %s (model %s, cwd %s)\n", outcome.ThreadID, started.Model, opts.cwd)
+
+	if _, err := client.Call(callCtx, "turn/start", map[string]any{
+		"threadId": outcome.ThreadID,
+		"input":    []map[string]any{{"type": "text", "text": prompt}},
+	}); err != nil {
+		return outcome, fmt.Errorf("turn/start: %w", err)
+	}
+
+	w := &streamWriter{out: stdout}
+	defer w.finish()
+	timeout := time.NewTimer(opts.timeout)
+	defer timeout.Stop()
+	var grace <-chan time.Time
+	sigDone := ctx.Done()
+	interrupt := func(status string) {
+		outcome.Status = status
+		fmt.Fprintf(stderr, "\n%s: interrupting turn\n", status)
+		interruptCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if _, err := client.Call(interruptCtx, "turn/interrupt", map[string]any{"threadId": outcome.ThreadID}); err != nil {
+			fmt.Fprintf(stderr, "turn/interrupt: %v\n", err)
+		}
+		grace = time.After(interruptGrace)
+	}
+
+	for {
+		select {
+		case n, ok := <-client.Notifications():
+			if !ok {
+				return outcome, fmt.Errorf("connection closed: %w", client.Err())
+			}
+			if done := handleRunNotification(client, n, opts, &outcome, w, stderr); done {
+				if !opts.noDiff && outcome.Diff != "" {
+					w.finish()
+					fmt.Fprintf(stdout, "\n%s", ensureNewline(outcome.Diff))
+				}
+				return outcome, nil
+			}
+		case <-sigDone:
+			sigDone = nil
+			outcome.Canceled = true
+			interrupt(statusInterrupted)
+		case <-timeout.C:
+			interrupt(statusTimeout)
+		case <-grace:
+			return outcome, nil
+		}
+	}
+}
+
+// handleRunNotification 处理单条推送; 本线程 turn/completed 时返回 true。
+func handleRunNotification(client *rpcclient.Client, n rpcclient.Notification, opts runOptions, outcome *runOutcome, w *streamWriter, stderr io.Writer) bool {
+	var ev runEvent
+	_ = json.Unmarshal(n.Params, &ev)
+	if n.IsRequest() {
+		if ev.ThreadID != "" && ev.ThreadID != outcome.ThreadID {
+			return false
+		}
+		verdict := "denied"
+		if opts.approve {
+			verdict = "approved"
+		}
+		w.finish()
+		fmt.Fprintf(stderr, "approval %s: %s\n", verdict, approvalSubject(ev))
+		if err := client.Respond(n.ID, map[string]any{"approved": opts.approve}); err != nil {
+			fmt.Fprintf(stderr, "respond approval: %v\n", err)
+		}
+		return false
+	}
+	if ev.ThreadID != outcome.ThreadID {
+		return false
+	}
+	switch n.Method {
+	case "item/agentMessage/delta":
+		w.write(ev.Delta)
+	case "item/started":
+		switch ev.Item.Type {
+		case "commandExecution":
+			w.finish()
+			fmt.Fprintf(stderr, "$ %s\n", commandText(ev.Item.Command))
+		case "fileChange":
+			w.finish()
+			paths := make([]string, 0, len(ev.Item.Changes))
+			for _, c := range ev.Item.Changes {
+				paths = append(paths, c.Path)
+			}
+			fmt.Fprintf(stderr, "edit %s\n", strings.Join(paths, ", "))
+		}
+	case "turn/diff/updated":
+		outcome.Diff = ev.Diff
+	case "error":
+		if !ev.WillRetry {
+			outcome.Error = errorText(ev)
+			w.finish()
+			fmt.Fprintf(stderr, "error: %s\n", outcome.Error)
+		}
+	case "turn/completed":
+		// 超时 / 信号中断时保留 interrupt 设置的状态。
+		if outcome.Status == "" {
+			outcome.Status = ev.Turn.Status
+			if outcome.Status == "" {
+				outcome.Status = statusCompleted
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// streamWriter 助手输出写 stdout; finish 保证在插入其他输出前换行。
+type streamWriter struct {
+	out     io.Writer
+	pending bool // 已写内容未以换行结尾
+}
+
+func (w *streamWriter) write(s string) {
+	if s == "" {
+		return
+	}
+	_, _ = io.WriteString(w.out, s)
+	w.pending = !strings.HasSuffix(s, "\n")
+}
+
+func (w *streamWriter) finish() {
+	if w.pending {
+		_, _ = io.WriteString(w.out, "\n")
+		w.pending = false
+	}
+}
+
+func ensureNewline(s string) string {
+	if strings.HasSuffix(s, "\n") {
+		return s
+	}
+	return s + "\n"
+}
+
+// commandText command 字段可能是字符串或 argv 数组。
+func commandText(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	var argv []string
+	if json.Unmarshal(raw, &argv) == nil {
+		return strings.Join(argv, " ")
+	}
+	return string(raw)
+}
+
+func approvalSubject(ev runEvent) string {
+	if len(ev.Command) > 0 {
+		return "run " + commandText(ev.Command)
+	}
+	return "file change"
+}
+
+func errorText(ev runEvent) string {
+	var e struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(ev.Error, &e) == nil && e.Message != "" {
+		return e.Message
+	}
+	if ev.Message != "" {
+		return ev.Message
+	}
+	return "turn error"
+}
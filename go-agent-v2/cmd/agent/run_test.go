@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/apiserver"
+	"github.com/multi-agent/go-agent-v2/internal/codex"
+	"github.com/multi-agent/go-agent-v2/internal/rpcclient"
+	"github.com/multi-agent/go-agent-v2/internal/runner"
+)
+
+func TestReadPromptAndExitCode(t *testing.T) {
+	if got, _ := readPrompt([]string{"fix", "the", "bug"}, nil); got != "fix the bug" {
+		t.Fatalf("args prompt = %q", got)
+	}
+	if got, _ := readPrompt([]string{"-"}, strings.NewReader("  from stdin\n")); got != "from stdin" {
+		t.Fatalf("stdin prompt = %q", got)
+	}
+	cases := []struct {
+		outcome runOutcome
+		want    int
+	}{
+		{runOutcome{Status: statusCompleted}, exitOK},
+		{runOutcome{Status: statusCompleted, Error: "quota"}, exitTurnFailed},
+		{runOutcome{Status: "failed"}, exitTurnFailed},
+		{runOutcome{Status: statusTimeout}, exitTurnFailed},
+		{runOutcome{Status: statusInterrupted, Canceled: true}, exitInterrupted},
+	}
+	for _, tc := range cases {
+		if got := tc.outcome.exitCode(); got != tc.want {
+			t.Errorf("exitCode(%+v) = %d, want %d", tc.outcome, got, tc.want)
+		}
+	}
+}
+
+func TestRunPrompt_StreamsReplyAndDiff(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	script := func(turnID, prompt string) []codex.MockStep {
+		if strings.Contains(prompt, "break") {
+			return []codex.MockStep{
+				{Type: codex.EventTurnStarted, Data: map[string]any{"turn": map[string]any{"id": turnID}}},
+				{Type: codex.EventTurnComplete, Data: map[string]any{"turn": map[string]any{"id": turnID, "status": "failed", "items": []any{}}}},
+			}
+		}
+		return codex.DefaultMockScript(turnID, prompt)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := "ws://" + ln.Addr().String()
+	_ = ln.Close()
+	mgr := runner.NewAgentManager()
+	mgr.SetClientFactory(codex.MockClientFactory(script, 5*time.Millisecond))
+	srv := apiserver.New(apiserver.Deps{Manager: mgr})
+	mgr.SetOnEvent(func(agentID string, event codex.Event) {
+		srv.AgentEventHandler(agentID)(event)
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = srv.ListenAndServe(ctx, addr) }()
+
+	var client *rpcclient.Client
+	for deadline := time.Now().Add(5 * time.Second); client == nil; time.Sleep(20 * time.Millisecond) {
+		if client, err = rpcclient.Dial(ctx, addr); err != nil && time.Now().After(deadline) {
+			t.Fatalf("dial: %v", err)
+		}
+	}
+	defer client.Close()
+
+	opts := runOptions{cwd: t.TempDir(), timeout: 10 * time.Second}
+	var stdout, stderr bytes.Buffer
+	outcome, err := runPrompt(ctx, client, opts, "update the readme", &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("runPrompt: %v\nstderr: %s", err, stderr.String())
+	}
+	if outcome.exitCode() != exitOK || outcome.ThreadID == "" {
+		t.Fatalf("outcome = %+v\nstderr: %s", outcome, stderr.String())
+	}
+	out := stdout.String()
+	if !strings.Contains(out, `I received "update the readme`) || !strings.Contains(out, "+Updated by the mock codex backend.") {
+		t.Fatalf("stdout = %q\n%s", out, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "thread "+outcome.ThreadID) {
+		t.Fatalf("stderr = %q", stderr.String())
+	}
+
+	stdout.Reset()
+	outcome, err = runPrompt(ctx, client, runOptions{cwd: opts.cwd, timeout: 10 * time.Second, noDiff: true}, "break it", &stdout, &stderr)
+	if err != nil || outcome.Status != "failed" || outcome.exitCode() != exitTurnFailed {
+		t.Fatalf("failed turn outcome = %+v, %v", outcome, err)
+	}
+}
@@ -0,0 +1,30 @@
+// drain_signal.go — SIGUSR1 触发优雅排空。
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/multi-agent/go-agent-v2/internal/apiserver"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+	"github.com/multi-agent/go-agent-v2/pkg/util"
+)
+
+// watchDrainSignal 收到 SIGUSR1 时开始排空, 排空完成后服务自行退出。
+func watchDrainSignal(ctx context.Context, srv *apiserver.Server) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGUSR1)
+	util.SafeGo(func() {
+		defer signal.Stop(sig)
+		select {
+		case <-sig:
+			logger.Warn("app-server: SIGUSR1 received, draining")
+			if err := srv.Drain("SIGUSR1", 0, true); err != nil {
+				logger.Error("app-server: drain failed", logger.FieldError, err)
+			}
+		case <-ctx.Done():
+		}
+	})
+}
@@ -0,0 +1,64 @@
+// emergency.go — 紧急停止 CLI: 经 HTTP /rpc 调用运行中服务的 orchestrator/emergencyStop 与 orchestrator/unlock。
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// rpcURLFromListen 将 --listen 地址 (ws://host:port) 转换为 HTTP /rpc 地址。
+func rpcURLFromListen(listen string) string {
+	addr := strings.TrimSpace(listen)
+	switch {
+	case strings.HasPrefix(addr, "ws://"):
+		addr = "http://" + strings.TrimPrefix(addr, "ws://")
+	case strings.HasPrefix(addr, "wss://"):
+		addr = "https://" + strings.TrimPrefix(addr, "wss://")
+	case !strings.Contains(addr, "://"):
+		addr = "http://" + addr
+	}
+	return strings.TrimRight(addr, "/") + "/rpc"
+}
+
+// runEmergencyCommand 调用 JSON-RPC 方法并打印结果, 返回进程退出码。
+func runEmergencyCommand(listen, method string, params map[string]any) int {
+	body, err := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: marshal request: %v\n", method, err)
+		return 2
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(rpcURLFromListen(listen), "application/json", bytes.NewReader(body))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", method, err)
+		return 2
+	}
+	defer resp.Body.Close()
+	var out struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: decode response (HTTP %d): %v\n", method, resp.StatusCode, err)
+		return 2
+	}
+	if out.Error != nil {
+		fmt.Fprintf(os.Stderr, "%s failed: %s (code %d)\n", method, out.Error.Message, out.Error.Code)
+		return 1
+	}
+	var pretty bytes.Buffer
+	if err := json.Indent(&pretty, out.Result, "", "  "); err != nil {
+		fmt.Println(string(out.Result))
+		return 0
+	}
+	fmt.Println(pretty.String())
+	return 0
+}
@@ -3,62 +3,150 @@
 // 启动:
 //
 //	codex app-server --listen ws://127.0.0.1:4500
+//
+// 仅校验技能 (不连接数据库, 有错误时退出码为 1):
+//
+//	app-server --validate-skills
+//
+// 紧急停止 / 解锁正在运行的服务 (经 --listen 对应的 /rpc):
+//
+//	app-server --emergency-stop --reason "runaway edits"
+//	app-server --unlock
+//
+// 配置按层覆盖: 默认值 → 配置文件 → 环境变量 → --set (校验配置后退出: --validate-config):
+//
+//	app-server --config /etc/agent/config.yaml --set LOG_LEVEL=DEBUG --set STALL_THRESHOLD_SEC=600
+//
+// 演示模式 (进程内模拟 codex, 不需要 codex 二进制与 API Key; 未配置数据库时无持久化):
+//
+//	app-server --mock
 package main
 
 import (
 	"context"
 	"flag"
+	"fmt"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
 
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/multi-agent/go-agent-v2/internal/apiserver"
 	"github.com/multi-agent/go-agent-v2/internal/codex"
 	"github.com/multi-agent/go-agent-v2/internal/config"
 	"github.com/multi-agent/go-agent-v2/internal/database"
 	"github.com/multi-agent/go-agent-v2/internal/lsp"
 	"github.com/multi-agent/go-agent-v2/internal/runner"
+	"github.com/multi-agent/go-agent-v2/internal/store"
 	"github.com/multi-agent/go-agent-v2/pkg/logger"
 )
 
 func main() {
 	listen := flag.String("listen", "ws://127.0.0.1:4500", "WebSocket 监听地址")
+	validateSkills := flag.Bool("validate-skills", false, "校验技能目录中的 SKILL.md 后退出")
+	emergencyStop := flag.Bool("emergency-stop", false, "对运行中的服务触发紧急停止后退出")
+	emergencyUnlock := flag.Bool("unlock", false, "解除运行中服务的紧急停止后退出")
+	drain := flag.Bool("drain", false, "让运行中的服务优雅排空 (等待进行中 turn 结束后退出)")
+	reason := flag.String("reason", "", "紧急停止 / 排空原因 (配合 --emergency-stop / --drain)")
+	configFile := flag.String("config", "", "配置文件 (YAML / TOML; 空 = $CONFIG_FILE 或工作目录下 config.yaml / config.toml)")
+	validateConfig := flag.Bool("validate-config", false, "校验分层配置后退出")
+	mock := flag.Bool("mock", false, "演示模式: 使用进程内模拟 codex 后端")
+	debugPprof := flag.Bool("debug-pprof", false, "在回环地址开启 net/http/pprof 调试端口 (含 /debug/pprof/trace)")
+	debugPprofAddr := flag.String("debug-pprof-addr", apiserver.DefaultPprofAddr, "pprof 调试端口监听地址 (仅允许回环地址)")
+	overrides := map[string]string{}
+	flag.Func("set", "覆盖配置项 KEY=VALUE (可重复, 优先级最高)", func(v string) error {
+		key, value, ok := strings.Cut(v, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return fmt.Errorf("expected KEY=VALUE, got %q", v)
+		}
+		overrides[strings.ToUpper(strings.TrimSpace(key))] = value
+		return nil
+	})
 	flag.Parse()
 
+	if *validateSkills {
+		os.Exit(runSkillValidation())
+	}
+	if *validateConfig {
+		os.Exit(runConfigValidation(config.LoadOptions{File: *configFile, Overrides: overrides}))
+	}
+	if *emergencyStop {
+		os.Exit(runEmergencyCommand(*listen, "orchestrator/emergencyStop", map[string]any{"reason": *reason, "by": "cli"}))
+	}
+	if *emergencyUnlock {
+		os.Exit(runEmergencyCommand(*listen, "orchestrator/unlock", map[string]any{"by": "cli"}))
+	}
+	if *drain {
+		os.Exit(runEmergencyCommand(*listen, "server/drain", map[string]any{"reason": *reason, "by": "cli"}))
+	}
+
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
-	cfg := config.Load()
+	cfg, _, err := config.LoadWithOptions(config.LoadOptions{File: *configFile, Overrides: overrides})
+	if err != nil {
+		logger.Fatal("load config failed", logger.FieldError, err)
+	}
 	logger.Init(cfg.LogLevel)
 
+	if *debugPprof {
+		if err := apiserver.StartPprofServer(ctx, *debugPprofAddr); err != nil {
+			logger.Fatal("debug-pprof: start failed", logger.FieldError, err)
+		}
+	}
+
 	// Runner (Agent 进程管理)
 	mgr := runner.NewAgentManager()
+	portStateFile := runner.ResolvePortStateFile(cfg.AgentPortStateFile)
+	if *mock {
+		mgr.SetClientFactory(codex.MockClientFactory(nil, codex.DefaultMockStepDelay))
+		logger.Warn("demo mode: agents use the in-process mock codex backend")
+		portStateFile = ""
+	}
 
 	// LSP Manager (延迟启动)
 	lspMgr := lsp.NewManager(nil)
 
-	// PostgreSQL (消息持久化, 必需)
+	// PostgreSQL (消息持久化, 必需; 演示模式可省略)
+	var dbPool *pgxpool.Pool
 	if cfg.PostgresConnStr == "" {
-		logger.Fatal("POSTGRES_CONNECTION_STRING is required")
-	}
-	dbPool, err := database.NewPool(ctx, cfg)
-	if err != nil {
-		logger.Fatal("postgres connect failed", logger.FieldError, err)
-	}
-	defer dbPool.Close()
+		if !*mock {
+			logger.Fatal("POSTGRES_CONNECTION_STRING is required")
+		}
+		logger.Warn("demo mode: no POSTGRES_CONNECTION_STRING, running without persistence")
+	} else {
+		dbPool, err = database.NewPool(ctx, cfg)
+		if err != nil {
+			logger.Fatal("postgres connect failed", logger.FieldError, err)
+		}
+		defer dbPool.Close()
 
-	// 自动迁移
-	migrationsDir := filepath.Join(filepath.Dir(os.Args[0]), "..", "..", "migrations")
-	if _, err := os.Stat(migrationsDir); os.IsNotExist(err) {
-		migrationsDir = "migrations"
+		// 自动迁移
+		migrationsDir := filepath.Join(filepath.Dir(os.Args[0]), "..", "..", "migrations")
+		if _, err := os.Stat(migrationsDir); os.IsNotExist(err) {
+			migrationsDir = "migrations"
+		}
+		if err := database.Migrate(ctx, dbPool, migrationsDir); err != nil {
+			if cfg.MigrationNonFatal {
+				logger.Warn("migration failed (non-fatal by config)", logger.FieldError, err, logger.FieldPath, migrationsDir)
+			} else {
+				logger.Fatal("migration failed", logger.FieldError, err, logger.FieldPath, migrationsDir)
+			}
+		}
 	}
-	if err := database.Migrate(ctx, dbPool, migrationsDir); err != nil {
-		if cfg.MigrationNonFatal {
-			logger.Warn("migration failed (non-fatal by config)", logger.FieldError, err, logger.FieldPath, migrationsDir)
-		} else {
-			logger.Fatal("migration failed", logger.FieldError, err, logger.FieldPath, migrationsDir)
+
+	// 接管上次遗留的 app-server (需要绑定存储恢复线程), 之后写入新的端口预留记录
+	if portStateFile != "" && cfg.AgentAdoptOrphans && dbPool != nil {
+		allocs, err := runner.ReadPortAllocations(portStateFile)
+		if err != nil {
+			logger.Warn("read agent port allocations failed", logger.FieldPath, portStateFile, logger.FieldError, err)
 		}
+		mgr.AdoptOrphans(ctx, allocs, store.NewAgentCodexBindingStore(dbPool).CodexThreadID)
+	}
+	if err := mgr.ConfigurePorts(runner.PortOptions{Min: cfg.AgentPortMin, Max: cfg.AgentPortMax, StateFile: portStateFile}); err != nil {
+		logger.Warn("agent port range invalid, using defaults", logger.FieldError, err)
 	}
 
 	// JSON-RPC Server
@@ -79,9 +167,60 @@ func main() {
 	cwd, _ := os.Getwd()
 	srv.SetupLSP(cwd)
 
+	// SIGUSR1 → 优雅排空 (滚动重启)
+	watchDrainSignal(ctx, srv)
+
 	logger.Info("app-server starting", logger.FieldListen, *listen)
 
 	if err := srv.ListenAndServe(ctx, *listen); err != nil {
 		logger.Fatal("app-server failed", logger.FieldError, err)
 	}
 }
+
+// runConfigValidation 分层加载配置并输出来源与问题, 返回进程退出码。
+func runConfigValidation(opts config.LoadOptions) int {
+	_, report, err := config.LoadWithOptions(opts)
+	if report == nil || (err != nil && len(report.Issues) == 0) {
+		fmt.Fprintf(os.Stderr, "load config failed: %v\n", err)
+		return 2
+	}
+	file := report.File
+	if file == "" {
+		file = "(none)"
+	}
+	fmt.Printf("config file: %s\n", file)
+	for _, issue := range report.Issues {
+		fmt.Printf("    [%s] %s: %s\n", issue.Source, issue.Key, issue.Message)
+	}
+	fmt.Printf("%d issue(s)\n", len(report.Issues))
+	if len(report.Issues) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// runSkillValidation 校验默认技能目录并输出报告, 返回进程退出码。
+func runSkillValidation() int {
+	results, err := apiserver.ValidateSkillsDir("")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "validate skills failed: %v\n", err)
+		return 2
+	}
+	failed := 0
+	for _, result := range results {
+		status := "ok"
+		if !result.Valid {
+			status = "FAIL"
+			failed++
+		}
+		fmt.Printf("[%s] %s (%s)\n", status, result.Name, result.Path)
+		for _, issue := range result.Issues {
+			fmt.Printf("    %s %s: %s\n", issue.Severity, issue.Field, issue.Message)
+		}
+	}
+	fmt.Printf("%d skill(s) checked, %d invalid\n", len(results), failed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,407 @@
+// cmd/loadtest — app-server 压测: N 个模拟 WebSocket 客户端并发 thread/start + turn/start。
+//
+// 用法:
+//
+//	loadtest [-clients 20] [-turns 3] [-deltas 50] [-delta-interval 0] [-addr ws://host:port]
+//
+// 默认在进程内启动 apiserver (无数据库) 并注入模拟 codex 后端 (codex.MockClient, 不需要 codex 二进制):
+// 每个 turn 发出 turn_started → N 条 agent_message_delta → turn_complete。
+// 每个客户端等待自己线程的 turn/completed 后再发下一轮。
+// 输出各 RPC 的 p50/p95/max 延迟, 以及通知扇出吞吐 (后端事件数 × 连接数 → 实际收到的通知数),
+// 用于度量 Notify 广播的容量上限。指定 -addr 时压测外部服务 (真实 codex, 不统计后端事件)。
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/multi-agent/go-agent-v2/internal/apiserver"
+	"github.com/multi-agent/go-agent-v2/internal/codex"
+	"github.com/multi-agent/go-agent-v2/internal/runner"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+type options struct {
+	clients     int
+	turns       int
+	turnTimeout time.Duration
+}
+
+func main() {
+	addr := flag.String("addr", "", "target app-server (empty = in-process server with mock codex backend)")
+	clients := flag.Int("clients", 20, "number of concurrent WebSocket clients")
+	turns := flag.Int("turns", 3, "turns per client")
+	deltas := flag.Int("deltas", 50, "mock backend: agent_message_delta events per turn")
+	deltaInterval := flag.Duration("delta-interval", 0, "mock backend: delay between events")
+	turnTimeout := flag.Duration("turn-timeout", 60*time.Second, "max wait for turn/completed")
+	logLevel := flag.String("log-level", "WARN", "server log level (logs go to stderr)")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: loadtest [flags]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *clients <= 0 || *turns < 0 || *deltas < 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	logger.InitStderr(*logLevel)
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	var emitted atomic.Int64 // 后端派发给 apiserver 的事件数 (扇出基数)
+	target := *addr
+	if target == "" {
+		var err error
+		target, err = startInProcessServer(ctx, loadScript(*deltas), *deltaInterval, &emitted)
+		if err != nil {
+			fail("Start in-process server failed: %v", err)
+		}
+	}
+
+	st := newStats()
+	opts := options{clients: *clients, turns: *turns, turnTimeout: *turnTimeout}
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < opts.clients; i++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			if err := runClient(ctx, target, idx, opts, st); err != nil {
+				st.clientErrors.Add(1)
+				fmt.Fprintf(os.Stderr, "client %d: %v\n", idx, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	st.report(os.Stdout, opts, elapsed, emitted.Load())
+	if st.clientErrors.Load() > 0 {
+		os.Exit(1)
+	}
+}
+
+// loadScript 压测脚本: 只含 turn 生命周期与回复增量。
+func loadScript(deltas int) codex.MockScript {
+	return func(turnID, _ string) []codex.MockStep {
+		steps := []codex.MockStep{{Type: codex.EventTurnStarted, Data: map[string]any{"turn": map[string]any{"id": turnID}}}}
+		for i := 0; i < deltas; i++ {
+			steps = append(steps, codex.MockStep{Type: codex.EventAgentMessageDelta, Data: map[string]any{"delta": fmt.Sprintf("token-%d ", i)}})
+		}
+		return append(steps, codex.MockStep{Type: codex.EventTurnComplete, Data: map[string]any{
+			"turn": map[string]any{"id": turnID, "status": "completed", "items": []any{}},
+		}})
+	}
+}
+
+// startInProcessServer 在随机端口启动注入模拟后端的 apiserver, 返回 ws 地址。
+func startInProcessServer(ctx context.Context, script codex.MockScript, stepDelay time.Duration, emitted *atomic.Int64) (string, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	addr := "ws://" + ln.Addr().String()
+	_ = ln.Close()
+
+	mgr := runner.NewAgentManager()
+	mgr.SetClientFactory(codex.MockClientFactory(script, stepDelay))
+	srv := apiserver.New(apiserver.Deps{Manager: mgr})
+	mgr.SetOnEvent(func(agentID string, event codex.Event) {
+		emitted.Add(1)
+		srv.AgentEventHandler(agentID)(event)
+	})
+	go func() {
+		if err := srv.ListenAndServe(ctx, addr); err != nil {
+			fmt.Fprintf(os.Stderr, "server: %v\n", err)
+		}
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		conn, _, err := websocket.DefaultDialer.Dial(addr, nil)
+		if err == nil {
+			_ = conn.Close()
+			return addr, nil
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return "", fmt.Errorf("server not ready at %s", addr)
+}
+
+type rpcMessage struct {
+	ID     *int64          `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// wsClient 单个模拟客户端: 串行请求 + 后台读取通知。
+type wsClient struct {
+	conn    *websocket.Conn
+	stats   *stats
+	writeMu sync.Mutex
+	nextID  atomic.Int64
+
+	mu        sync.Mutex
+	pending   map[int64]chan rpcMessage
+	threadID  string
+	completed chan struct{}
+	closed    chan struct{} // 读循环退出 (服务端断开, 如通知背压)
+	readErr   error
+}
+
+// errDisconnected 服务端在压测途中断开连接。
+var errDisconnected = errors.New("disconnected by server")
+
+func runClient(ctx context.Context, addr string, idx int, opts options, st *stats) (err error) {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, addr, nil)
+	if err != nil {
+		return fmt.Errorf("dial: %w", err)
+	}
+	defer conn.Close()
+	c := &wsClient{conn: conn, stats: st, pending: map[int64]chan rpcMessage{}, completed: make(chan struct{}, 16), closed: make(chan struct{})}
+	go c.readLoop()
+	defer func() {
+		if errors.Is(err, errDisconnected) {
+			st.disconnects.Add(1)
+		}
+	}()
+
+	result, err := c.call(ctx, "thread/start", map[string]any{"cwd": "."})
+	if err != nil {
+		return err
+	}
+	var started struct {
+		Thread struct {
+			ID string `json:"id"`
+		} `json:"thread"`
+	}
+	if err := json.Unmarshal(result, &started); err != nil || started.Thread.ID == "" {
+		return fmt.Errorf("thread/start: unexpected result %s", result)
+	}
+	c.mu.Lock()
+	c.threadID = started.Thread.ID
+	c.mu.Unlock()
+
+	for turn := 0; turn < opts.turns; turn++ {
+		// 每轮提示词唯一, 避免触发跨线程去重。
+		prompt := fmt.Sprintf("loadtest client %d turn %d", idx, turn)
+		turnStart := time.Now()
+		if _, err := c.call(ctx, "turn/start", map[string]any{
+			"threadId": started.Thread.ID,
+			"input":    []map[string]any{{"type": "text", "text": prompt}},
+		}); err != nil {
+			return err
+		}
+		select {
+		case <-c.completed:
+			st.observe("turn (start → completed)", time.Since(turnStart), nil)
+		case <-c.closed:
+			return c.disconnectedError()
+		case <-time.After(opts.turnTimeout):
+			return fmt.Errorf("turn %d: no turn/completed within %s", turn, opts.turnTimeout)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	_, _ = c.call(ctx, "thread/archive", map[string]any{"threadId": started.Thread.ID})
+	c.writeMu.Lock()
+	_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "done"), time.Now().Add(time.Second))
+	c.writeMu.Unlock()
+	return nil
+}
+
+func (c *wsClient) call(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	id := c.nextID.Add(1)
+	ch := make(chan rpcMessage, 1)
+	c.mu.Lock()
+	c.pending[id] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	start := time.Now()
+	c.writeMu.Lock()
+	err := c.conn.WriteJSON(map[string]any{"jsonrpc": "2.0", "id": id, "method": method, "params": params})
+	c.writeMu.Unlock()
+	if err != nil {
+		c.stats.observe(method, time.Since(start), err)
+		return nil, fmt.Errorf("%s: write: %w", method, err)
+	}
+	select {
+	case msg := <-ch:
+		if msg.Error != nil {
+			err = fmt.Errorf("%s: rpc error %d: %s", method, msg.Error.Code, msg.Error.Message)
+		}
+		c.stats.observe(method, time.Since(start), err)
+		return msg.Result, err
+	case <-c.closed:
+		err = fmt.Errorf("%s: %w", method, c.disconnectedError())
+		c.stats.observe(method, time.Since(start), err)
+		return nil, err
+	case <-time.After(30 * time.Second):
+		err = fmt.Errorf("%s: response timeout", method)
+		c.stats.observe(method, time.Since(start), err)
+		return nil, err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *wsClient) disconnectedError() error {
+	return fmt.Errorf("%w: %v", errDisconnected, c.readErr)
+}
+
+func (c *wsClient) readLoop() {
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			c.readErr = err
+			close(c.closed)
+			return
+		}
+		var msg rpcMessage
+		if json.Unmarshal(data, &msg) != nil {
+			continue
+		}
+		if msg.Method == "" && msg.ID != nil {
+			c.mu.Lock()
+			ch := c.pending[*msg.ID]
+			c.mu.Unlock()
+			if ch != nil {
+				ch <- msg
+			}
+			continue
+		}
+		if msg.Method == "" {
+			continue
+		}
+		c.stats.notifications.Add(1)
+		if msg.Method != "turn/completed" {
+			continue
+		}
+		var p struct {
+			ThreadID string `json:"threadId"`
+		}
+		_ = json.Unmarshal(msg.Params, &p)
+		c.mu.Lock()
+		mine := p.ThreadID != "" && p.ThreadID == c.threadID
+		c.mu.Unlock()
+		if mine {
+			select {
+			case c.completed <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// stats 延迟样本 (按方法) 与通知计数。
+type stats struct {
+	mu            sync.Mutex
+	samples       map[string][]time.Duration
+	errors        map[string]int
+	notifications atomic.Int64
+	clientErrors  atomic.Int64
+	disconnects   atomic.Int64 // 被服务端断开的客户端 (Notify 背压)
+}
+
+func newStats() *stats {
+	return &stats{samples: map[string][]time.Duration{}, errors: map[string]int{}}
+}
+
+func (s *stats) observe(name string, d time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.errors[name]++
+		return
+	}
+	s.samples[name] = append(s.samples[name], d)
+}
+
+// percentile 最近秩法 (samples 已排序)。
+func percentile(samples []time.Duration, p float64) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+	rank := int(p/100*float64(len(samples))+0.5) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(samples) {
+		rank = len(samples) - 1
+	}
+	return samples[rank]
+}
+
+func (s *stats) report(out *os.File, opts options, elapsed time.Duration, emitted int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.samples)+len(s.errors))
+	seen := map[string]bool{}
+	for name := range s.samples {
+		names, seen[name] = append(names, name), true
+	}
+	for name := range s.errors {
+		if !seen[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(out, "clients=%d turns/client=%d elapsed=%s client_errors=%d disconnected=%d\n\n",
+		opts.clients, opts.turns, elapsed.Round(time.Millisecond), s.clientErrors.Load(), s.disconnects.Load())
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "RPC\tOK\tERR\tP50\tP95\tMAX")
+	for _, name := range names {
+		samples := s.samples[name]
+		sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+		var maxLatency time.Duration
+		if len(samples) > 0 {
+			maxLatency = samples[len(samples)-1]
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t%s\n", name, len(samples), s.errors[name],
+			percentile(samples, 50).Round(time.Microsecond),
+			percentile(samples, 95).Round(time.Microsecond),
+			maxLatency.Round(time.Microsecond))
+	}
+	_ = w.Flush()
+
+	received := s.notifications.Load()
+	seconds := elapsed.Seconds()
+	if seconds <= 0 {
+		seconds = 1
+	}
+	fmt.Fprintf(out, "\nnotifications received: %d (%.0f/s)\n", received, float64(received)/seconds)
+	if emitted > 0 {
+		fmt.Fprintf(out, "backend events: %d (%.0f/s), fan-out: %.1f notifications per event (%d clients)\n",
+			emitted, float64(emitted)/seconds, float64(received)/float64(emitted), opts.clients)
+	}
+}
+
+func fail(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
@@ -1,10 +1,17 @@
 // cmd/mcp-server — MCP 服务器入口。
+//
+// 传输: stdio (默认, stdout 专用于 MCP 协议, 日志写到 stderr) 或 http
+// (Streamable HTTP /mcp + 旧版 SSE /sse, 可用 -transport / -listen 覆盖配置)。
+// 未配置 POSTGRES_CONNECTION_STRING 时仅提供子代理编排工具 (MCP_APP_SERVER_URL)。
 package main
 
 import (
 	"context"
+	"flag"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/multi-agent/go-agent-v2/internal/config"
 	"github.com/multi-agent/go-agent-v2/internal/database"
@@ -18,28 +25,52 @@ func main() {
 	defer cancel()
 
 	cfg := config.Load()
-	logger.Init(cfg.LogLevel)
+	transport := flag.String("transport", cfg.MCPTransport, "stdio | http")
+	listen := flag.String("listen", cfg.MCPListen, "http 传输监听地址")
+	flag.Parse()
+	logger.InitStderr(cfg.LogLevel)
 
-	pool, err := database.NewPool(ctx, cfg)
-	if err != nil {
-		logger.Fatal("database init failed", logger.FieldError, err)
-	}
-	defer pool.Close()
-
-	stores := &mcp.Stores{
-		Interaction:      store.NewInteractionStore(pool),
-		TaskTrace:        store.NewTaskTraceStore(pool),
-		PromptTemplate:   store.NewPromptTemplateStore(pool),
-		CommandCard:      store.NewCommandCardStore(pool),
-		AuditLog:         store.NewAuditLogStore(pool),
-		SharedFile:       store.NewSharedFileStore(pool),
-		AgentStatus:      store.NewAgentStatusStore(pool),
-		TopologyApproval: store.NewTopologyApprovalStore(pool),
-		DBQuery:          store.NewDBQueryStore(pool),
+	var stores *mcp.Stores
+	if cfg.PostgresConnStr != "" {
+		pool, err := database.NewPool(ctx, cfg)
+		if err != nil {
+			logger.Fatal("database init failed", logger.FieldError, err)
+		}
+		defer pool.Close()
+		stores = &mcp.Stores{
+			Interaction:      store.NewInteractionStore(pool),
+			TaskTrace:        store.NewTaskTraceStore(pool),
+			PromptTemplate:   store.NewPromptTemplateStore(pool),
+			CommandCard:      store.NewCommandCardStore(pool),
+			AuditLog:         store.NewAuditLogStore(pool),
+			SharedFile:       store.NewSharedFileStore(pool),
+			AgentStatus:      store.NewAgentStatusStore(pool),
+			TopologyApproval: store.NewTopologyApprovalStore(pool),
+			DBQuery:          store.NewDBQueryStore(pool),
+		}
+	} else {
+		logger.Warn("MCP server: POSTGRES_CONNECTION_STRING not set, database tools disabled")
 	}
 
 	s := mcp.NewServer(stores)
-	if err := s.Start(ctx); err != nil {
+	if addr := strings.TrimSpace(cfg.MCPAppServerURL); addr != "" {
+		orch := mcp.NewHTTPOrchestrator(addr)
+		s.SetOrchestrator(orch)
+		logger.Info("MCP server: orchestration tools enabled", logger.FieldURL, orch.URL())
+	}
+	var err error
+	switch strings.ToLower(strings.TrimSpace(*transport)) {
+	case "", "stdio":
+		err = s.Start(ctx)
+	case "http":
+		err = s.ServeHTTP(ctx, *listen, mcp.HTTPOptions{
+			Token:      cfg.MCPAuthToken,
+			SessionTTL: time.Duration(cfg.MCPSessionTTLMin) * time.Minute,
+		})
+	default:
+		logger.Fatal("MCP server: unknown transport", "transport", *transport)
+	}
+	if err != nil {
 		logger.Fatal("MCP server failed", logger.FieldError, err)
 	}
 }
@@ -1,57 +1,143 @@
+// cmd/migrate — 数据库迁移命令行。
+//
+// 用法:
+//
+//	migrate [-dir migrations] [-dry-run] [-allow-modified] [up]
+//	migrate [-dir migrations] [-dry-run] down [-steps N]
+//	migrate [-dir migrations] status
+//	migrate [-dry-run] rotate-keys [-batch N]
+//
+// 版本记录与执行逻辑复用 internal/database (与服务启动时的自动迁移一致)。
+// rotate-keys 把加密列重写为 STORE_ENCRYPTION_KEYS 首个密钥的密文 (明文数据同时加密)。
 package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"os"
-	"path/filepath"
-	"sort"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
 
-	"github.com/jackc/pgx/v5"
+	"github.com/multi-agent/go-agent-v2/internal/config"
+	"github.com/multi-agent/go-agent-v2/internal/database"
+	"github.com/multi-agent/go-agent-v2/internal/store"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
 )
 
 func main() {
-	connStr := os.Getenv("POSTGRES_CONNECTION_STRING")
-	if connStr == "" {
-		fmt.Println("POSTGRES_CONNECTION_STRING not set")
-		os.Exit(1)
+	dir := flag.String("dir", "migrations", "migrations directory")
+	dryRun := flag.Bool("dry-run", false, "print the plan without executing")
+	allowModified := flag.Bool("allow-modified", false, "warn instead of failing when applied migrations were modified")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: migrate [flags] [up | down [-steps N] | status | rotate-keys [-batch N]]")
+		flag.PrintDefaults()
 	}
+	flag.Parse()
 
-	ctx := context.Background()
-	conn, err := pgx.Connect(ctx, connStr)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Unable to connect to database: %v\n", err)
-		os.Exit(1)
+	cmd := "up"
+	args := flag.Args()
+	if len(args) > 0 {
+		cmd, args = args[0], args[1:]
 	}
-	defer func() {
-		if err := conn.Close(ctx); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: conn close: %v\n", err)
-		}
-	}()
 
-	files, err := filepath.Glob("migrations/*.sql")
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	cfg := config.Load()
+	logger.Init(cfg.LogLevel)
+	pool, err := database.NewPool(ctx, cfg)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to list migrations: %v\n", err)
-		os.Exit(1)
+		fail("Unable to connect to database: %v", err)
 	}
+	defer pool.Close()
 
-	sort.Strings(files)
-
-	for _, file := range files {
-		fmt.Printf("Applying %s...\n", file)
-		content, err := os.ReadFile(file)
+	switch cmd {
+	case "up":
+		res, err := database.MigrateUp(ctx, pool, *dir, database.MigrateOptions{DryRun: *dryRun, Strict: !*allowModified})
+		for _, v := range res.Modified {
+			fmt.Printf("Modified after apply: %s\n", v)
+		}
+		printVersions(res.Applied, "Applied", *dryRun)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to read %s: %v\n", file, err)
-			os.Exit(1)
+			fail("Migration failed: %v", err)
 		}
-
-		_, err = conn.Exec(ctx, string(content))
+		fmt.Println("Migration complete.")
+	case "down":
+		fs := flag.NewFlagSet("down", flag.ExitOnError)
+		steps := fs.Int("steps", 1, "number of migrations to revert")
+		_ = fs.Parse(args)
+		res, err := database.MigrateDown(ctx, pool, *dir, database.MigrateOptions{DryRun: *dryRun, Steps: *steps})
+		printVersions(res.Reverted, "Reverted", *dryRun)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error applying %s: %v\n", file, err)
-			os.Exit(1)
-		} else {
-			fmt.Printf("Applied %s\n", file)
+			fail("Rollback failed: %v", err)
+		}
+	case "status":
+		statuses, err := database.MigrationStatuses(ctx, pool, *dir)
+		if err != nil {
+			fail("Status failed: %v", err)
+		}
+		printStatus(statuses)
+	case "rotate-keys":
+		fs := flag.NewFlagSet("rotate-keys", flag.ExitOnError)
+		batch := fs.Int("batch", 500, "rows per batch")
+		_ = fs.Parse(args)
+		results, err := store.RotateFieldEncryption(ctx, pool, store.FieldRotationOptions{BatchSize: *batch, DryRun: *dryRun})
+		printRotation(results, *dryRun)
+		if err != nil {
+			fail("Key rotation failed: %v", err)
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+func printVersions(versions []string, verb string, dryRun bool) {
+	if dryRun {
+		verb = "Would " + map[string]string{"Applied": "apply", "Reverted": "revert"}[verb]
+	}
+	if len(versions) == 0 {
+		fmt.Println("Nothing to do.")
+		return
+	}
+	for _, v := range versions {
+		fmt.Printf("%s %s\n", verb, v)
+	}
+}
+
+func printStatus(statuses []database.MigrationStatus) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tSTATE\tAPPLIED AT\tDOWN")
+	for _, st := range statuses {
+		appliedAt := "-"
+		if st.AppliedAt != nil {
+			appliedAt = st.AppliedAt.Local().Format("2006-01-02 15:04:05")
+		}
+		down := "no"
+		if st.Reversible {
+			down = "yes"
 		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", st.Version, st.State, appliedAt, down)
 	}
-	fmt.Println("Migration complete.")
+	_ = w.Flush()
+}
+
+func printRotation(results []store.FieldRotationResult, dryRun bool) {
+	verb := "REWRITTEN"
+	if dryRun {
+		verb = "WOULD REWRITE"
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "TABLE\tSCANNED\t%s\tSKIPPED\n", verb)
+	for _, res := range results {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\n", res.Table, res.Scanned, res.Rewritten, res.Skipped)
+	}
+	_ = w.Flush()
+}
+
+func fail(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
 }
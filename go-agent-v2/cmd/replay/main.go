@@ -0,0 +1,113 @@
+// cmd/replay — codex 会话录制回放, 用于回归调试。
+//
+// 用法:
+//
+//	replay [-agent thread-1] [-ui-state] [-raw] capture.jsonl
+//
+// 录制文件由 GO_AGENT_SESSION_CAPTURE_DIR 开启 (每个 codex 会话一个 .jsonl)。回放把入站事件
+// 送入 apiserver 的事件处理路径 (payload 归一化 → uistate → 通知), 输出 JSON:
+// 产生的通知序列与每个 Agent 的最终时间线。两次输出 diff 即可定位归一化 / 时间线回归。
+// 审批与动态工具调用只记录为通知, 不会执行。
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/multi-agent/go-agent-v2/internal/apiserver"
+	"github.com/multi-agent/go-agent-v2/internal/codex"
+	"github.com/multi-agent/go-agent-v2/internal/runner"
+	"github.com/multi-agent/go-agent-v2/internal/uistate"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+type notification struct {
+	Method string `json:"method"`
+	Params any    `json:"params"`
+}
+
+type replayOutput struct {
+	Records       int                               `json:"records"`
+	Events        int                               `json:"events"`
+	Notifications []notification                    `json:"notifications"`
+	Timelines     map[string][]uistate.TimelineItem `json:"timelines"`
+}
+
+func main() {
+	agentID := flag.String("agent", "", "only replay records of this agent id")
+	withUIState := flag.Bool("ui-state", false, "include throttled ui/state/changed notifications")
+	raw := flag.Bool("raw", false, "keep timeline ids and timestamps (default normalizes them for stable diffs)")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: replay [flags] capture.jsonl")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	logger.InitStderr("WARN")
+	f, err := os.Open(flag.Arg(0))
+	if err != nil {
+		fail("Open capture failed: %v", err)
+	}
+	records, err := codex.ReadCapture(f)
+	_ = f.Close()
+	if err != nil {
+		fail("Read capture failed: %v", err)
+	}
+
+	srv := apiserver.New(apiserver.Deps{Manager: runner.NewAgentManager()})
+	var mu sync.Mutex
+	out := replayOutput{Records: len(records), Notifications: []notification{}, Timelines: map[string][]uistate.TimelineItem{}}
+	srv.SetNotifyHook(func(method string, params any) {
+		if method == "ui/state/changed" && !*withUIState {
+			return
+		}
+		mu.Lock()
+		out.Notifications = append(out.Notifications, notification{Method: method, Params: params})
+		mu.Unlock()
+	})
+	out.Events = srv.ReplayCapture(records, *agentID)
+
+	agents := map[string]bool{}
+	for _, rec := range records {
+		if *agentID == "" || rec.AgentID == *agentID {
+			agents[rec.AgentID] = true
+		}
+	}
+	ids := make([]string, 0, len(agents))
+	for id := range agents {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		timeline := srv.ThreadTimeline(id)
+		if !*raw {
+			// 时间线 ID / 时间戳含墙钟时间, 归一化后两次回放输出可直接 diff。
+			for i := range timeline {
+				timeline[i].ID = fmt.Sprintf("%s-%d", timeline[i].Kind, i+1)
+				timeline[i].Ts = ""
+			}
+		}
+		out.Timelines[id] = timeline
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		fail("Encode output failed: %v", err)
+	}
+}
+
+func fail(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
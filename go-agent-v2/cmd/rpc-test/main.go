@@ -1,96 +1,82 @@
-//go:build e2e
-
-// rpc-test — 直接测试 app-server JSON-RPC 方法。
+// rpc-test — app-server JSON-RPC 脚本化测试驱动 (可用于 CI, 连接已运行的服务)。
+//
+// 用法:
+//
+//	rpc-test [-addr ws://127.0.0.1:4500] [-timeout 30s] [-var k=v]... [-v] script.yaml...
+//
+// 每个脚本独立连接, 步骤顺序执行: call 发送请求并断言响应, wait 等待匹配的通知,
+// capture 从响应 / 通知中捕获变量供后续步骤引用。脚本格式见 script.go。
+// 全部脚本通过时退出码 0, 任一步骤失败为 1, 用法 / 脚本解析错误为 2。
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"flag"
+	"fmt"
 	"os"
 	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
-	"github.com/gorilla/websocket"
-	"github.com/multi-agent/go-agent-v2/pkg/logger"
-	"github.com/multi-agent/go-agent-v2/pkg/util"
+	"github.com/multi-agent/go-agent-v2/internal/rpcclient"
 )
 
 func main() {
-	logger.Init("development")
-
-	addr := "ws://127.0.0.1:4500"
-	if len(os.Args) > 1 {
-		addr = os.Args[1]
-	}
-
-	logger.Info("connecting", logger.FieldAddr, addr)
-	conn, _, err := websocket.DefaultDialer.Dial(addr, nil)
-	if err != nil {
-		logger.Fatal("dial failed", logger.FieldError, err)
-	}
-	defer conn.Close()
-	logger.Info("connected")
-
-	// 后台读取所有消息 (包括通知)
-	util.SafeGo(func() {
-		for {
-			_, msg, err := conn.ReadMessage()
-			if err != nil {
-				logger.Error("read error", logger.FieldError, err)
-				return
-			}
-			// 美化 JSON
-			var pretty json.RawMessage
-			if json.Unmarshal(msg, &pretty) == nil {
-				out, _ := json.MarshalIndent(pretty, "", "  ")
-				logger.Info("recv", "data", string(out))
-			} else {
-				logger.Info("recv", "data", string(msg))
-			}
+	addr := flag.String("addr", rpcclient.DefaultAddr, "app-server WebSocket address")
+	timeout := flag.Duration("timeout", 0, "default per-step timeout (overrides script timeout; 0 = script/30s)")
+	verbose := flag.Bool("v", false, "print requests, responses, notifications and captures")
+	vars := map[string]any{}
+	flag.Func("var", "set script variable `name=value` (repeatable, overrides script vars)", func(s string) error {
+		name, value, ok := strings.Cut(s, "=")
+		if !ok || name == "" {
+			return fmt.Errorf("want name=value")
 		}
+		vars[name] = value
+		return nil
 	})
-
-	// 1. 发送 thread/start
-	req1 := map[string]any{
-		"jsonrpc": "2.0",
-		"id":      1,
-		"method":  "thread/start",
-		"params":  map[string]any{"cwd": "."},
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: rpc-test [flags] script.yaml...")
+		flag.PrintDefaults()
 	}
-	data1, err := json.Marshal(req1)
-	if err != nil {
-		logger.Fatal("marshal thread/start", logger.FieldError, err)
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
 	}
-	logger.Info(">>> SEND", "data", string(data1))
-	if err := conn.WriteMessage(websocket.TextMessage, data1); err != nil {
-		logger.Fatal("write thread/start failed", logger.FieldError, err)
-	}
-
-	// 等待 thread/start 响应 + 可能的通知
-	logger.Info("waiting 20s for thread/start response (codex spawn + health check)...")
-	time.Sleep(20 * time.Second)
 
-	// 2. 发送 turn/start (用 thread-* 的 ID)
-	// 先发一个 thread/list 看看有什么
-	req2 := map[string]any{
-		"jsonrpc": "2.0",
-		"id":      2,
-		"method":  "thread/list",
-		"params":  map[string]any{},
+	scripts := make([]*script, 0, flag.NArg())
+	for _, path := range flag.Args() {
+		sc, err := loadScript(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		if *timeout > 0 {
+			sc.overrideTimeout(*timeout)
+		}
+		scripts = append(scripts, sc)
 	}
-	data2, err := json.Marshal(req2)
-	if err != nil {
-		logger.Fatal("marshal thread/list", logger.FieldError, err)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+	opts := runOptions{addr: *addr, vars: vars, verbose: *verbose, out: os.Stdout}
+	failed := 0
+	for _, sc := range scripts {
+		res, err := runScript(ctx, sc, opts)
+		switch {
+		case err != nil:
+			failed++
+			fmt.Printf("FAIL %s: %v\n", sc.Name, err)
+		case res.Failed > 0:
+			failed++
+			fmt.Printf("FAIL %s: %d passed, %d failed, %d skipped (%s)\n", sc.Name, res.Passed, res.Failed, res.Skipped, res.Elapsed.Round(time.Millisecond))
+		default:
+			fmt.Printf("ok   %s: %d steps (%s)\n", sc.Name, res.Passed, res.Elapsed.Round(time.Millisecond))
+		}
 	}
-	logger.Info(">>> SEND", "data", string(data2))
-	if err := conn.WriteMessage(websocket.TextMessage, data2); err != nil {
-		logger.Error("write thread/list failed", logger.FieldError, err)
+	if failed > 0 {
+		fmt.Printf("FAIL %d/%d scripts\n", failed, len(scripts))
+		os.Exit(1)
 	}
-	time.Sleep(2 * time.Second)
-
-	// 等用户 Ctrl+C
-	logger.Info("listening for notifications... Ctrl+C to exit")
-	sig := make(chan os.Signal, 1)
-	signal.Notify(sig, os.Interrupt)
-	<-sig
-	logger.Info("bye")
 }
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/apiserver"
+	"github.com/multi-agent/go-agent-v2/internal/codex"
+	"github.com/multi-agent/go-agent-v2/internal/runner"
+)
+
+func TestParseScript_Validation(t *testing.T) {
+	cases := map[string]string{
+		"no steps":      "name: x\nsteps: []\n",
+		"two kinds":     "steps:\n  - {call: a, wait: b}\n",
+		"unknown field": "steps:\n  - {call: a, expcet: {x: 1}}\n",
+		"bad timeout":   "steps:\n  - {wait: a, timeout: soon}\n",
+		"error on wait": "steps:\n  - {wait: a, expectError: {code: 1}}\n",
+	}
+	for name, src := range cases {
+		if _, err := parseScript(name, []byte(src)); err == nil {
+			t.Errorf("%s: expected parse error", name)
+		}
+	}
+	sc, err := parseScript("ok.json", []byte(`{"timeout":"5s","steps":[{"call":"a"},{"wait":"b","timeout":"1s"},{"sleep":"10ms"}]}`))
+	if err != nil {
+		t.Fatalf("json script: %v", err)
+	}
+	sc.overrideTimeout(2 * time.Second)
+	if sc.Steps[0].timeout != 2*time.Second || sc.Steps[1].timeout != time.Second || sc.Steps[2].sleep != 10*time.Millisecond {
+		t.Fatalf("timeouts = %v %v %v", sc.Steps[0].timeout, sc.Steps[1].timeout, sc.Steps[2].sleep)
+	}
+}
+
+func TestExpandAndCheckExpect(t *testing.T) {
+	t.Setenv("RPC_TEST_TOKEN", "secret")
+	vars := map[string]any{"id": "t-1", "n": float64(2)}
+	got, err := expand(map[string]any{"threadId": "${id}", "limit": "${n}", "label": "${id}/${n}", "auth": "${env:RPC_TEST_TOKEN}"}, vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := got.(map[string]any)
+	if m["threadId"] != "t-1" || m["limit"] != float64(2) || m["label"] != "t-1/2" || m["auth"] != "secret" {
+		t.Fatalf("expanded = %v", m)
+	}
+	if _, err := expand("${missing}", vars); err == nil {
+		t.Fatal("undefined variable should fail")
+	}
+
+	root := normalize(map[string]any{"threads": []any{map[string]any{"id": "a", "tags": []any{"x"}}}, "total": 1})
+	if failures := checkExpect(root, map[string]any{
+		"threads.0.id":   "a",
+		"threads":        map[string]any{"len": 1},
+		"threads.0.tags": map[string]any{"contains": "x"},
+		"total":          uint64(1),
+		"missing":        map[string]any{"exists": false},
+		"threads.0":      map[string]any{"matches": `"id":"a"`},
+	}); len(failures) > 0 {
+		t.Fatalf("unexpected failures: %v", failures)
+	}
+	failures := checkExpect(root, map[string]any{"threads.0.id": map[string]any{"notEquals": "a"}, "threads.1.id": map[string]any{"exists": true}})
+	if len(failures) != 2 || !strings.Contains(failures[0], "threads.0.id") || !strings.Contains(failures[1], "missing") {
+		t.Fatalf("failures = %v", failures)
+	}
+}
+
+func TestRunScript_SmokeAgainstMockServer(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := "ws://" + ln.Addr().String()
+	_ = ln.Close()
+	mgr := runner.NewAgentManager()
+	mgr.SetClientFactory(codex.MockClientFactory(codex.DefaultMockScript, 5*time.Millisecond))
+	srv := apiserver.New(apiserver.Deps{Manager: mgr})
+	mgr.SetOnEvent(func(agentID string, event codex.Event) {
+		srv.AgentEventHandler(agentID)(event)
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = srv.ListenAndServe(ctx, addr) }()
+
+	sc, err := loadScript("testdata/smoke.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc.overrideTimeout(10 * time.Second)
+	var out bytes.Buffer
+	opts := runOptions{addr: addr, verbose: true, out: &out}
+	var res scriptResult
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(20 * time.Millisecond) {
+		if res, err = runScript(ctx, sc, opts); err == nil || time.Now().After(deadline) {
+			break
+		}
+	}
+	if err != nil || res.Failed != 0 || res.Passed != len(sc.Steps) {
+		t.Fatalf("smoke = %+v, %v\n%s", res, err, out.String())
+	}
+
+	failing, err := parseScript("failing.yaml", []byte("steps:\n  - {call: thread/list, expect: {threads: {len: 99}}}\n  - {sleep: 1ms}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	res, err = runScript(ctx, failing, opts)
+	if err != nil || res.Failed != 1 || res.Skipped != 1 || !strings.Contains(out.String(), "expected len 99") {
+		t.Fatalf("failing = %+v, %v\n%s", res, err, out.String())
+	}
+}
@@ -0,0 +1,234 @@
+// runner.go — 执行脚本: 每个脚本一条连接, 步骤顺序执行, 首个失败即停止 (后续步骤依赖捕获变量)。
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/rpcclient"
+)
+
+// maxInbox 未被 wait 消费的通知保留上限 (超出丢弃最旧)。
+const maxInbox = 10000
+
+type runOptions struct {
+	addr    string
+	vars    map[string]any
+	verbose bool
+	out     io.Writer
+}
+
+// scriptResult 单个脚本的执行结果。
+type scriptResult struct {
+	Passed  int
+	Failed  int
+	Skipped int
+	Elapsed time.Duration
+}
+
+// inbox 后台收集服务端推送, 供 wait 步骤按序匹配; 审批请求按脚本策略自动回复。
+type inbox struct {
+	mu     sync.Mutex
+	items  []rpcclient.Notification
+	closed bool
+	signal chan struct{}
+}
+
+func newInbox(client *rpcclient.Client, approve bool, log func(string, ...any)) *inbox {
+	in := &inbox{signal: make(chan struct{}, 1)}
+	go func() {
+		for n := range client.Notifications() {
+			if n.IsRequest() {
+				if err := client.Respond(n.ID, map[string]any{"approved": approve}); err != nil {
+					log("respond %s: %v", n.Method, err)
+				}
+			}
+			in.mu.Lock()
+			in.items = append(in.items, n)
+			if len(in.items) > maxInbox {
+				in.items = in.items[len(in.items)-maxInbox:]
+			}
+			in.mu.Unlock()
+			in.notify()
+		}
+		in.mu.Lock()
+		in.closed = true
+		in.mu.Unlock()
+		in.notify()
+	}()
+	return in
+}
+
+func (in *inbox) notify() {
+	select {
+	case in.signal <- struct{}{}:
+	default:
+	}
+}
+
+// take 取出 (并移除) 最早的匹配通知, 直到 ctx 结束。
+func (in *inbox) take(ctx context.Context, method string, match map[string]any) (any, error) {
+	for {
+		in.mu.Lock()
+		for i, n := range in.items {
+			if n.Method != method {
+				continue
+			}
+			var params any
+			_ = json.Unmarshal(n.Params, &params)
+			if len(checkExpect(params, match)) > 0 {
+				continue
+			}
+			in.items = append(in.items[:i:i], in.items[i+1:]...)
+			in.mu.Unlock()
+			return params, nil
+		}
+		closed := in.closed
+		in.mu.Unlock()
+		if closed {
+			return nil, errors.New("connection closed")
+		}
+		select {
+		case <-in.signal:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// runScript 执行一个脚本; 返回 error 仅表示无法开始 (连接失败等)。
+func runScript(ctx context.Context, sc *script, opts runOptions) (scriptResult, error) {
+	start := time.Now()
+	res := scriptResult{}
+	dialCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	client, err := rpcclient.Dial(dialCtx, opts.addr)
+	cancel()
+	if err != nil {
+		return res, err
+	}
+	defer client.Close()
+
+	logf := func(format string, args ...any) {
+		if opts.verbose {
+			fmt.Fprintf(opts.out, "      "+format+"\n", args...)
+		}
+	}
+	vars := make(map[string]any, len(sc.Vars)+len(opts.vars))
+	for k, v := range sc.Vars {
+		vars[k] = normalize(v)
+	}
+	for k, v := range opts.vars {
+		vars[k] = v
+	}
+	in := newInbox(client, sc.Approve, logf)
+
+	fmt.Fprintf(opts.out, "=== %s (%d steps)\n", sc.Name, len(sc.Steps))
+	for i, st := range sc.Steps {
+		stepStart := time.Now()
+		err := runStep(ctx, client, in, st, vars, logf)
+		elapsed := time.Since(stepStart).Round(time.Millisecond)
+		if err != nil {
+			res.Failed++
+			res.Skipped = len(sc.Steps) - i - 1
+			fmt.Fprintf(opts.out, "  FAIL %s (%s)\n", st.label(), elapsed)
+			for _, line := range strings.Split(err.Error(), "\n") {
+				fmt.Fprintf(opts.out, "       %s\n", line)
+			}
+			break
+		}
+		res.Passed++
+		fmt.Fprintf(opts.out, "  ok   %s (%s)\n", st.label(), elapsed)
+	}
+	res.Elapsed = time.Since(start)
+	return res, nil
+}
+
+func runStep(ctx context.Context, client *rpcclient.Client, in *inbox, st step, vars map[string]any, logf func(string, ...any)) error {
+	if st.Sleep != "" {
+		select {
+		case <-time.After(st.sleep):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, st.timeout)
+	defer cancel()
+
+	expect, err := expandMap(st.Expect, vars)
+	if err != nil {
+		return err
+	}
+	var root any
+	if st.Call != "" {
+		params, err := expand(st.Params, vars)
+		if err != nil {
+			return err
+		}
+		logf("→ %s %s", st.Call, compactJSON(params))
+		raw, callErr := client.Call(ctx, st.Call, params)
+		var rpcErr *rpcclient.Error
+		switch {
+		case errors.As(callErr, &rpcErr):
+			logf("← error %s", compactJSON(rpcErr))
+			if st.ExpectError == nil {
+				return fmt.Errorf("unexpected error: %v", rpcErr)
+			}
+			if expect, err = expandMap(st.ExpectError, vars); err != nil {
+				return err
+			}
+			root = normalize(rpcErr)
+		case callErr != nil:
+			return callErr
+		default:
+			logf("← %s", raw)
+			if st.ExpectError != nil {
+				return fmt.Errorf("expected error, got result %s", raw)
+			}
+			_ = json.Unmarshal(raw, &root)
+		}
+	} else {
+		match, err := expandMap(st.Match, vars)
+		if err != nil {
+			return err
+		}
+		root, err = in.take(ctx, st.Wait, match)
+		if errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("no %s matching %s within %s", st.Wait, compactJSON(match), st.timeout)
+		}
+		if err != nil {
+			return err
+		}
+		logf("← %s %s", st.Wait, compactJSON(root))
+	}
+
+	if failures := checkExpect(root, expect); len(failures) > 0 {
+		return errors.New(strings.Join(failures, "\n"))
+	}
+	for name, path := range st.Capture {
+		val, ok := lookup(root, path)
+		if !ok {
+			return fmt.Errorf("capture %s: path %s not found in %s", name, path, describe(root, true))
+		}
+		vars[name] = val
+		logf("%s = %s", name, compactJSON(val))
+	}
+	return nil
+}
+
+func expandMap(m map[string]any, vars map[string]any) (map[string]any, error) {
+	if m == nil {
+		return nil, nil
+	}
+	out, err := expand(m, vars)
+	if err != nil {
+		return nil, err
+	}
+	return out.(map[string]any), nil
+}
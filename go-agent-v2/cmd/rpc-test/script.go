@@ -0,0 +1,413 @@
+// script.go — 测试脚本格式: 加载、变量替换、路径取值与断言。
+//
+// 脚本为 YAML (JSON 是其子集), 顶层:
+//
+//	name: smoke
+//	vars: {cwd: "."}          # 初始变量; -var k=v 可覆盖
+//	approve: false            # Server→Client 审批请求的自动回复 (默认拒绝)
+//	timeout: 30s              # 单步默认超时
+//	steps:
+//	  - call: thread/start    # 发送请求
+//	    params: {cwd: "${cwd}"}
+//	    expect: {thread.id: {exists: true}}
+//	    capture: {threadId: thread.id}
+//	  - wait: turn/completed  # 等待通知 (match 选择, expect 断言)
+//	    match: {threadId: "${threadId}"}
+//	  - call: thread/stop/graceful
+//	    params: {threadId: missing}
+//	    expectError: {data.code: THREAD_NOT_FOUND}
+//	  - sleep: 1s
+//
+// 路径以 "." 分隔, 数组用下标 (threads.0.id); call 步骤相对 result (expectError 相对
+// error 对象), wait 步骤相对通知 params。断言值为字面量时按 JSON 相等比较, 也可写成
+// 操作符对象: equals / notEquals / exists / contains / matches / len。
+// 字符串中的 ${name} 引用变量, ${env:NAME} 引用环境变量; 整串为单个引用时保留原类型。
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+)
+
+// defaultStepTimeout 未指定 timeout 时单步超时。
+const defaultStepTimeout = 30 * time.Second
+
+type script struct {
+	Name    string         `yaml:"name"`
+	Vars    map[string]any `yaml:"vars"`
+	Approve bool           `yaml:"approve"`
+	Timeout string         `yaml:"timeout"`
+	Steps   []step         `yaml:"steps"`
+
+	path    string
+	timeout time.Duration
+}
+
+type step struct {
+	Name        string            `yaml:"name"`
+	Call        string            `yaml:"call"`
+	Params      any               `yaml:"params"`
+	Wait        string            `yaml:"wait"`
+	Match       map[string]any    `yaml:"match"`
+	Sleep       string            `yaml:"sleep"`
+	Expect      map[string]any    `yaml:"expect"`
+	ExpectError map[string]any    `yaml:"expectError"`
+	Capture     map[string]string `yaml:"capture"`
+	Timeout     string            `yaml:"timeout"`
+
+	timeout time.Duration
+	sleep   time.Duration
+}
+
+// label 输出用步骤名。
+func (s step) label() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	switch {
+	case s.Call != "":
+		return "call " + s.Call
+	case s.Wait != "":
+		return "wait " + s.Wait
+	}
+	return "sleep " + s.Sleep
+}
+
+// loadScript 读取并校验脚本 (未知字段报错, 避免拼写错误被静默忽略)。
+func loadScript(path string) (*script, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseScript(path, data)
+}
+
+func parseScript(path string, data []byte) (*script, error) {
+	sc := &script{path: path}
+	if err := yaml.UnmarshalWithOptions(data, sc, yaml.DisallowUnknownField()); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if sc.Name == "" {
+		sc.Name = path
+	}
+	if len(sc.Steps) == 0 {
+		return nil, fmt.Errorf("%s: no steps", path)
+	}
+	var err error
+	if sc.timeout, err = parseDuration(sc.Timeout, defaultStepTimeout); err != nil {
+		return nil, fmt.Errorf("%s: timeout: %w", path, err)
+	}
+	for i := range sc.Steps {
+		if err := sc.Steps[i].validate(sc.timeout); err != nil {
+			return nil, fmt.Errorf("%s: step %d (%s): %w", path, i+1, sc.Steps[i].label(), err)
+		}
+	}
+	return sc, nil
+}
+
+// overrideTimeout 命令行 -timeout 替换脚本默认超时 (步骤自身的 timeout 仍优先)。
+func (sc *script) overrideTimeout(d time.Duration) {
+	sc.timeout = d
+	for i := range sc.Steps {
+		if sc.Steps[i].Timeout == "" {
+			sc.Steps[i].timeout = d
+		}
+	}
+}
+
+func (s *step) validate(fallback time.Duration) error {
+	kinds := 0
+	for _, v := range []string{s.Call, s.Wait, s.Sleep} {
+		if v != "" {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return fmt.Errorf("exactly one of call / wait / sleep is required")
+	}
+	if s.Call == "" && s.ExpectError != nil {
+		return fmt.Errorf("expectError only applies to call steps")
+	}
+	if s.Call == "" && s.Params != nil {
+		return fmt.Errorf("params only applies to call steps")
+	}
+	if s.Wait == "" && s.Match != nil {
+		return fmt.Errorf("match only applies to wait steps")
+	}
+	if s.ExpectError != nil && s.Expect != nil {
+		return fmt.Errorf("expect and expectError are mutually exclusive")
+	}
+	var err error
+	if s.timeout, err = parseDuration(s.Timeout, fallback); err != nil {
+		return fmt.Errorf("timeout: %w", err)
+	}
+	if s.Sleep != "" {
+		if s.sleep, err = time.ParseDuration(s.Sleep); err != nil {
+			return fmt.Errorf("sleep: %w", err)
+		}
+	}
+	return nil
+}
+
+func parseDuration(s string, fallback time.Duration) (time.Duration, error) {
+	if s == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err == nil && d <= 0 {
+		err = fmt.Errorf("must be positive")
+	}
+	return d, err
+}
+
+// ========================================
+// 变量替换
+// ========================================
+
+var varRef = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// expand 递归替换 ${name} / ${env:NAME}。
+func expand(v any, vars map[string]any) (any, error) {
+	switch t := v.(type) {
+	case string:
+		return expandString(t, vars)
+	case map[string]any:
+		out := make(map[string]any, len(t))
+		for k, item := range t {
+			expanded, err := expand(item, vars)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = expanded
+		}
+		return out, nil
+	case []any:
+		out := make([]any, len(t))
+		for i, item := range t {
+			expanded, err := expand(item, vars)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = expanded
+		}
+		return out, nil
+	}
+	return v, nil
+}
+
+func expandString(s string, vars map[string]any) (any, error) {
+	if m := varRef.FindStringSubmatch(s); m != nil && m[0] == s {
+		return resolveVar(m[1], vars)
+	}
+	var firstErr error
+	out := varRef.ReplaceAllStringFunc(s, func(ref string) string {
+		val, err := resolveVar(ref[2:len(ref)-1], vars)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			return ref
+		}
+		if str, ok := val.(string); ok {
+			return str
+		}
+		return compactJSON(val)
+	})
+	return out, firstErr
+}
+
+func resolveVar(name string, vars map[string]any) (any, error) {
+	if env, ok := strings.CutPrefix(name, "env:"); ok {
+		if val, ok := os.LookupEnv(env); ok {
+			return val, nil
+		}
+		return nil, fmt.Errorf("environment variable %s is not set", env)
+	}
+	val, ok := vars[name]
+	if !ok {
+		return nil, fmt.Errorf("undefined variable %q", name)
+	}
+	return val, nil
+}
+
+// ========================================
+// 路径取值 & 断言
+// ========================================
+
+// lookup 按点分路径取值 (空路径返回根)。
+func lookup(root any, path string) (any, bool) {
+	if path == "" {
+		return root, true
+	}
+	cur := root
+	for _, key := range strings.Split(path, ".") {
+		switch t := cur.(type) {
+		case map[string]any:
+			next, ok := t[key]
+			if !ok {
+				return nil, false
+			}
+			cur = next
+		case []any:
+			idx, err := strconv.Atoi(key)
+			if err != nil || idx < 0 || idx >= len(t) {
+				return nil, false
+			}
+			cur = t[idx]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+var matcherOps = map[string]bool{"equals": true, "notEquals": true, "exists": true, "contains": true, "matches": true, "len": true}
+
+// asMatcher 仅含操作符键的对象视为匹配器, 否则按字面量相等比较。
+func asMatcher(want any) (map[string]any, bool) {
+	m, ok := want.(map[string]any)
+	if !ok || len(m) == 0 {
+		return nil, false
+	}
+	for k := range m {
+		if !matcherOps[k] {
+			return nil, false
+		}
+	}
+	return m, true
+}
+
+// checkExpect 按路径排序逐条断言, 返回失败描述。
+func checkExpect(root any, expect map[string]any) []string {
+	paths := make([]string, 0, len(expect))
+	for p := range expect {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	var failures []string
+	for _, path := range paths {
+		actual, found := lookup(root, path)
+		want := expect[path]
+		ops, isMatcher := asMatcher(want)
+		if !isMatcher {
+			ops = map[string]any{"equals": want}
+		}
+		names := make([]string, 0, len(ops))
+		for op := range ops {
+			names = append(names, op)
+		}
+		sort.Strings(names)
+		for _, op := range names {
+			if msg := checkOp(op, normalize(ops[op]), actual, found); msg != "" {
+				failures = append(failures, fmt.Sprintf("%s: %s (got %s)", displayPath(path), msg, describe(actual, found)))
+			}
+		}
+	}
+	return failures
+}
+
+func checkOp(op string, want, actual any, found bool) string {
+	switch op {
+	case "exists":
+		if wantBool, _ := want.(bool); wantBool != found {
+			return fmt.Sprintf("expected exists=%v", wantBool)
+		}
+	case "equals":
+		if !found || !reflect.DeepEqual(want, actual) {
+			return "expected " + compactJSON(want)
+		}
+	case "notEquals":
+		if found && reflect.DeepEqual(want, actual) {
+			return "expected not " + compactJSON(want)
+		}
+	case "contains":
+		switch t := actual.(type) {
+		case string:
+			if s, ok := want.(string); ok && strings.Contains(t, s) {
+				return ""
+			}
+		case []any:
+			for _, item := range t {
+				if reflect.DeepEqual(item, want) {
+					return ""
+				}
+			}
+		}
+		return "expected to contain " + compactJSON(want)
+	case "matches":
+		pattern, _ := want.(string)
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return "invalid pattern: " + err.Error()
+		}
+		s, ok := actual.(string)
+		if !ok {
+			s = compactJSON(actual)
+		}
+		if !found || !re.MatchString(s) {
+			return "expected to match " + pattern
+		}
+	case "len":
+		n := -1
+		switch t := actual.(type) {
+		case string:
+			n = len(t)
+		case []any:
+			n = len(t)
+		case map[string]any:
+			n = len(t)
+		}
+		if wantN, ok := want.(float64); !ok || float64(n) != wantN {
+			return "expected len " + compactJSON(want)
+		}
+	}
+	return ""
+}
+
+// normalize JSON 往返, 使 YAML 解码的数值与响应中的 float64 可比较。
+func normalize(v any) any {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out any
+	if json.Unmarshal(data, &out) != nil {
+		return v
+	}
+	return out
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "(root)"
+	}
+	return path
+}
+
+func describe(v any, found bool) string {
+	if !found {
+		return "missing"
+	}
+	s := compactJSON(v)
+	if len(s) > 200 {
+		s = s[:200] + "…"
+	}
+	return s
+}
+
+func compactJSON(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
@@ -0,0 +1,163 @@
+// agent_capabilities.go — 每个 agent 的 codex 版本与协议能力快照 (agent/capabilities)。
+//
+// initialize 只返回本服务的静态能力; 各线程背后的 codex 版本可能不同。agent 启动成功后
+// (runner.AgentManager.SetOnLaunched) 异步探测一次并缓存, 完成后推送 agent/capabilities/updated,
+// UI 据 features 按线程隐藏不支持的操作。缓存按 codex 客户端实例失效 (进程重启 / 传输回退后重新探测)。
+package apiserver
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/codex"
+	"github.com/multi-agent/go-agent-v2/internal/runner"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+	"github.com/multi-agent/go-agent-v2/pkg/util"
+)
+
+// agentCapabilityProbeTimeout 一次完整探测的超时。
+const agentCapabilityProbeTimeout = 60 * time.Second
+
+// agentCapabilitySnapshot 一个 agent 的能力快照。
+type agentCapabilitySnapshot struct {
+	ThreadID string `json:"threadId"`
+	codex.Capabilities
+	Probed   bool      `json:"probed"` // false = 客户端不支持探测 (REST 传输)
+	ProbedAt time.Time `json:"probedAt"`
+
+	client codex.CodexClient
+}
+
+// agentCapabilityCache threadID → 快照。
+type agentCapabilityCache struct {
+	mu      sync.Mutex
+	byAgent map[string]agentCapabilitySnapshot
+}
+
+func (c *agentCapabilityCache) get(threadID string, client codex.CodexClient) (agentCapabilitySnapshot, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	snap, ok := c.byAgent[threadID]
+	if !ok || snap.client != client {
+		return agentCapabilitySnapshot{}, false
+	}
+	return snap, true
+}
+
+func (c *agentCapabilityCache) put(snap agentCapabilitySnapshot) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.byAgent == nil {
+		c.byAgent = make(map[string]agentCapabilitySnapshot)
+	}
+	c.byAgent[snap.ThreadID] = snap
+}
+
+// prune 丢弃已不在运行的 agent。
+func (c *agentCapabilityCache) prune(alive map[string]bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id := range c.byAgent {
+		if !alive[id] {
+			delete(c.byAgent, id)
+		}
+	}
+}
+
+// probeAgentCapabilities 探测 agent 当前 codex 客户端的能力并写入缓存。
+func (s *Server) probeAgentCapabilities(ctx context.Context, threadID string, client codex.CodexClient) agentCapabilitySnapshot {
+	snap := agentCapabilitySnapshot{ThreadID: threadID, client: client}
+	if prober, ok := client.(codex.CapabilityProber); ok {
+		ctx, cancel := context.WithTimeout(ctx, agentCapabilityProbeTimeout)
+		snap.Capabilities = prober.ProbeCapabilities(ctx)
+		cancel()
+		snap.Probed = true
+	} else {
+		snap.Transport = "rest"
+	}
+	if snap.Features == nil {
+		snap.Features = map[string]bool{}
+	}
+	snap.ProbedAt = time.Now()
+	s.agentCaps.put(snap)
+	logger.Info("agent capabilities: probed",
+		logger.FieldThreadID, threadID,
+		"transport", snap.Transport,
+		"codex_version", snap.Version,
+		"unsupported", unsupportedCapabilities(snap.Features),
+		"unknown", snap.Unknown,
+	)
+	return snap
+}
+
+// onAgentLaunched 启动成功后异步探测能力 (runner.AgentManager.SetOnLaunched)。
+func (s *Server) onAgentLaunched(proc *runner.AgentProcess) {
+	if proc == nil || proc.Client == nil {
+		return
+	}
+	threadID, client := proc.ID, proc.Client
+	util.SafeGo(func() {
+		snap := s.probeAgentCapabilities(context.Background(), threadID, client)
+		s.Notify("agent/capabilities/updated", snap)
+	})
+}
+
+func unsupportedCapabilities(features map[string]bool) []string {
+	var out []string
+	for method, ok := range features {
+		if !ok {
+			out = append(out, method)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// agentCapabilitiesParams agent/capabilities 请求参数 (threadId 为空 = 全部运行中的 agent)。
+type agentCapabilitiesParams struct {
+	ThreadID string `json:"threadId,omitempty"`
+	Refresh  bool   `json:"refresh,omitempty"` // 忽略缓存重新探测
+}
+
+// agentCapabilitiesTyped 返回 agent 的 codex 版本与能力矩阵 (JSON-RPC: agent/capabilities)。
+func (s *Server) agentCapabilitiesTyped(ctx context.Context, p agentCapabilitiesParams) (any, error) {
+	const op = "Server.agentCapabilities"
+	if s.mgr == nil {
+		return nil, apperrors.New(op, "agent manager unavailable")
+	}
+	threadID := strings.TrimSpace(p.ThreadID)
+	if threadID != "" {
+		proc := s.mgr.Get(threadID)
+		if proc == nil {
+			return nil, apperrors.NewCodef(op, errcode.ThreadNotFound, "thread %s is not running", threadID)
+		}
+		if snap, ok := s.agentCaps.get(threadID, proc.Client); ok && !p.Refresh {
+			return snap, nil
+		}
+		return s.probeAgentCapabilities(ctx, threadID, proc.Client), nil
+	}
+
+	agents := s.mgr.List()
+	alive := make(map[string]bool, len(agents))
+	out := make([]agentCapabilitySnapshot, 0, len(agents))
+	for _, info := range agents {
+		alive[info.ID] = true
+		proc := s.mgr.Get(info.ID)
+		if proc == nil {
+			continue
+		}
+		snap, ok := s.agentCaps.get(info.ID, proc.Client)
+		if !ok || p.Refresh {
+			snap = s.probeAgentCapabilities(ctx, info.ID, proc.Client)
+		}
+		out = append(out, snap)
+	}
+	s.agentCaps.prune(alive)
+	sort.Slice(out, func(i, j int) bool { return out[i].ThreadID < out[j].ThreadID })
+	return map[string]any{"agents": out}, nil
+}
@@ -0,0 +1,80 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/codex"
+	"github.com/multi-agent/go-agent-v2/internal/runner"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+func TestAgentCapabilitiesProbedAtLaunchAndCached(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	mgr := runner.NewAgentManager()
+	mgr.SetClientFactory(codex.MockClientFactory(nil, 0))
+	srv := New(Deps{Manager: mgr})
+	var mu sync.Mutex
+	var updates []map[string]any
+	srv.SetNotifyHook(func(method string, params any) {
+		if method == "agent/capabilities/updated" {
+			mu.Lock()
+			updates = append(updates, params.(map[string]any))
+			mu.Unlock()
+		}
+	})
+	ctx := context.Background()
+
+	if err := mgr.Launch(ctx, "thread-caps", "caps", "", t.TempDir(), "", nil); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mgr.Stop("thread-caps") }()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		n := len(updates)
+		mu.Unlock()
+		if n > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no agent/capabilities/updated after launch")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := updates[0]; got["threadId"] != "thread-caps" || got["transport"] != "mock" || got["probed"] != true {
+		t.Fatalf("launch notification = %+v", got)
+	}
+
+	raw, err := srv.InvokeMethod(ctx, "agent/capabilities", json.RawMessage(`{"threadId":"thread-caps"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	snap := raw.(agentCapabilitySnapshot)
+	if !snap.Features["thread/fork"] || snap.Version == "" {
+		t.Fatalf("snapshot = %+v", snap)
+	}
+	raw, _ = srv.InvokeMethod(ctx, "agent/capabilities", json.RawMessage(`{"threadId":"thread-caps"}`))
+	if !raw.(agentCapabilitySnapshot).ProbedAt.Equal(snap.ProbedAt) {
+		t.Fatal("second call should be served from cache")
+	}
+	raw, err = srv.InvokeMethod(ctx, "agent/capabilities", json.RawMessage(`{"threadId":"thread-caps","refresh":true}`))
+	if err != nil || !raw.(agentCapabilitySnapshot).ProbedAt.After(snap.ProbedAt) {
+		t.Fatalf("refresh = %+v, %v", raw, err)
+	}
+
+	raw, err = srv.InvokeMethod(ctx, "agent/capabilities", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if agents := raw.(map[string]any)["agents"].([]agentCapabilitySnapshot); len(agents) != 1 || agents[0].ThreadID != "thread-caps" {
+		t.Fatalf("agents = %+v", agents)
+	}
+	if _, err := srv.InvokeMethod(ctx, "agent/capabilities", json.RawMessage(`{"threadId":"missing"}`)); apperrors.CodeOf(err) != errcode.ThreadNotFound {
+		t.Fatalf("missing thread err = %v", err)
+	}
+}
@@ -0,0 +1,447 @@
+// agent_health.go — agent 健康检查与自动重启策略。
+//
+// 后台循环逐个探测运行中的 codex 进程:
+//   - 进程存活 (Running)
+//   - RPC 往返 (Ping, 超时 / 传输失败计为一次失败)
+//   - 事件心跳 (turn 进行中且超过 staleAfter 无事件 → degraded, 由 stall 看门狗处理, 不重启)
+//
+// 连续失败达到阈值 (或进程已退出) 判定 unhealthy, 按重启策略处理:
+// 首次重启等待 backoff, 之后逐次翻倍 (上限 agentHealthMaxBackoff); 统计窗口内重启次数达到上限后放弃 (gaveUp),
+// 直到 agent 恢复健康或被手动重启。紧急停止锁定期间只上报不重启。
+// agent 在 thread/start 时可指定自己的重启策略 (runner.RestartPolicy) 覆盖全局上限与退避:
+// never 只上报; on-failure 进程正常退出 (exit 0) 记为 exited 不重启; always 无论退出码都重启。
+//
+// 状态变化推送 agent/health; 判定异常、重启、重启失败、放弃与恢复写入审计日志 (event_type=agent_health)。
+package apiserver
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/codex"
+	"github.com/multi-agent/go-agent-v2/internal/runner"
+	"github.com/multi-agent/go-agent-v2/internal/store"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+	"github.com/multi-agent/go-agent-v2/pkg/util"
+)
+
+const (
+	agentHealthHealthy    = "healthy"
+	agentHealthDegraded   = "degraded"
+	agentHealthUnhealthy  = "unhealthy"
+	agentHealthRestarting = "restarting"
+	agentHealthGaveUp     = "gaveUp"
+	agentHealthExited     = "exited"
+
+	agentHealthActionRestart = "restart"
+	agentHealthActionGiveUp  = "giveUp"
+
+	agentHealthMaxBackoff  = 5 * time.Minute
+	agentHealthAuditType   = "agent_health"
+	agentHealthListLimit   = 100
+	agentHealthRestartWait = 60 * time.Second
+	// agentHealthPolicyMaxRestarts agent 策略未指定上限且全局关闭重启 (AGENT_RESTART_MAX=0) 时的上限。
+	agentHealthPolicyMaxRestarts = 3
+)
+
+// agentHealthProber 可选能力: 支持 RPC 往返与心跳查询的 codex 客户端 (AppServerClient)。
+type agentHealthProber interface {
+	Ping(timeout time.Duration) error
+	LastActivity() time.Time
+}
+
+// agentHealthConfig 健康检查与重启策略。
+type agentHealthConfig struct {
+	Interval      time.Duration // ≤ 0 = 不启动后台检查
+	PingTimeout   time.Duration
+	FailThreshold int
+	StaleAfter    time.Duration // 0 = 不检查事件心跳
+	MaxRestarts   int           // 0 = 仅上报不重启
+	Backoff       time.Duration
+	RestartWindow time.Duration
+}
+
+// agentHealthProbe 单次探测结果。
+type agentHealthProbe struct {
+	Running    bool
+	PingErr    error
+	Latency    time.Duration
+	EventAge   time.Duration // < 0 = 未知
+	TurnActive bool
+	Exited     bool // 进程已退出且退出码可知 (codex.ExitReporter)
+	ExitCode   int
+	Policy     *runner.RestartPolicy // agent 显式配置的重启策略 (nil = 全局策略)
+}
+
+// agentHealthRecord 单个 agent 的健康状态。
+type agentHealthRecord struct {
+	AgentID             string `json:"agentId"`
+	Status              string `json:"status"`
+	LastCheckAt         string `json:"lastCheckAt,omitempty"`
+	LastHealthyAt       string `json:"lastHealthyAt,omitempty"`
+	PingLatencyMS       int64  `json:"pingLatencyMs"`
+	EventAgeMS          int64  `json:"eventAgeMs,omitempty"`
+	ConsecutiveFailures int    `json:"consecutiveFailures"`
+	Restarts            int    `json:"restarts"` // 统计窗口内的重启次数
+	NextRestartAt       string `json:"nextRestartAt,omitempty"`
+	LastError           string `json:"lastError,omitempty"`
+
+	restartTimes []time.Time
+	nextRestart  time.Time
+}
+
+// agentHealthMonitor 健康状态表。
+type agentHealthMonitor struct {
+	cfg     agentHealthConfig
+	mu      sync.Mutex
+	records map[string]*agentHealthRecord
+}
+
+func newAgentHealthMonitor(cfg agentHealthConfig) *agentHealthMonitor {
+	if cfg.PingTimeout <= 0 {
+		cfg.PingTimeout = 5 * time.Second
+	}
+	if cfg.FailThreshold <= 0 {
+		cfg.FailThreshold = 1
+	}
+	if cfg.RestartWindow <= 0 {
+		cfg.RestartWindow = 10 * time.Minute
+	}
+	return &agentHealthMonitor{cfg: cfg, records: make(map[string]*agentHealthRecord)}
+}
+
+// restartLimits 返回生效的重启上限与首次退避 (agent 策略优先于全局配置)。
+func (m *agentHealthMonitor) restartLimits(policy *runner.RestartPolicy) (int, time.Duration) {
+	maxRestarts, backoff := m.cfg.MaxRestarts, m.cfg.Backoff
+	if policy == nil {
+		return maxRestarts, backoff
+	}
+	if policy.Mode == runner.RestartNever {
+		return 0, backoff
+	}
+	switch {
+	case policy.MaxRestarts > 0:
+		maxRestarts = policy.MaxRestarts
+	case maxRestarts <= 0:
+		maxRestarts = agentHealthPolicyMaxRestarts
+	}
+	if policy.BackoffMs > 0 {
+		backoff = time.Duration(policy.BackoffMs) * time.Millisecond
+	}
+	return maxRestarts, backoff
+}
+
+// restartBackoff 第 n 次 (从 0 计) 重启前的等待时长 (首次等待 base)。
+func (m *agentHealthMonitor) restartBackoff(base time.Duration, n int) time.Duration {
+	delay := base
+	for i := 0; i < n && delay < agentHealthMaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, agentHealthMaxBackoff)
+}
+
+// observe 记录一次探测结果, 返回状态快照、上一次状态与需执行的动作 (restart / giveUp / 空)。
+func (m *agentHealthMonitor) observe(agentID string, probe agentHealthProbe, now time.Time) (agentHealthRecord, string, string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec := m.records[agentID]
+	if rec == nil {
+		rec = &agentHealthRecord{AgentID: agentID, Status: agentHealthHealthy}
+		m.records[agentID] = rec
+	}
+	prevStatus := rec.Status
+	rec.LastCheckAt = now.Format(time.RFC3339)
+	rec.PingLatencyMS = probe.Latency.Milliseconds()
+	rec.EventAgeMS = 0
+	if probe.EventAge >= 0 {
+		rec.EventAgeMS = probe.EventAge.Milliseconds()
+	}
+
+	// 统计窗口外的重启记录过期
+	kept := rec.restartTimes[:0]
+	for _, at := range rec.restartTimes {
+		if now.Sub(at) < m.cfg.RestartWindow {
+			kept = append(kept, at)
+		}
+	}
+	rec.restartTimes = kept
+	rec.Restarts = len(kept)
+
+	action := ""
+	switch {
+	case !probe.Running && probe.Exited && probe.ExitCode == 0 &&
+		probe.Policy != nil && probe.Policy.Mode == runner.RestartOnFailure:
+		rec.ConsecutiveFailures = 0
+		rec.LastError = "process exited normally (exit 0)"
+		rec.nextRestart = time.Time{}
+		rec.NextRestartAt = ""
+		rec.Status = agentHealthExited
+		return *rec, prevStatus, ""
+	case !probe.Running:
+		rec.ConsecutiveFailures = max(rec.ConsecutiveFailures+1, m.cfg.FailThreshold)
+		rec.LastError = "process not running"
+		if probe.Exited {
+			rec.LastError = fmt.Sprintf("process exited (code %d)", probe.ExitCode)
+		}
+	case probe.PingErr != nil:
+		rec.ConsecutiveFailures++
+		rec.LastError = probe.PingErr.Error()
+	default:
+		rec.ConsecutiveFailures = 0
+		rec.LastError = ""
+		rec.nextRestart = time.Time{}
+		rec.NextRestartAt = ""
+		rec.Status = agentHealthHealthy
+		if probe.TurnActive && m.cfg.StaleAfter > 0 && probe.EventAge > m.cfg.StaleAfter {
+			rec.Status = agentHealthDegraded
+			rec.LastError = fmt.Sprintf("no events for %s during active turn", probe.EventAge.Round(time.Second))
+		} else {
+			rec.LastHealthyAt = rec.LastCheckAt
+		}
+		return *rec, prevStatus, ""
+	}
+
+	if rec.ConsecutiveFailures < m.cfg.FailThreshold {
+		rec.Status = agentHealthDegraded
+		return *rec, prevStatus, ""
+	}
+	maxRestarts, backoff := m.restartLimits(probe.Policy)
+	switch {
+	case prevStatus == agentHealthGaveUp:
+		rec.Status = agentHealthGaveUp
+	case maxRestarts <= 0:
+		rec.Status = agentHealthUnhealthy
+	case len(rec.restartTimes) >= maxRestarts:
+		rec.Status = agentHealthGaveUp
+		rec.NextRestartAt = ""
+		action = agentHealthActionGiveUp
+	default:
+		if rec.nextRestart.IsZero() {
+			rec.nextRestart = now.Add(m.restartBackoff(backoff, len(rec.restartTimes)))
+			rec.NextRestartAt = rec.nextRestart.Format(time.RFC3339)
+		}
+		rec.Status = agentHealthUnhealthy
+		if !now.Before(rec.nextRestart) {
+			rec.restartTimes = append(rec.restartTimes, now)
+			rec.Restarts = len(rec.restartTimes)
+			rec.nextRestart = time.Time{}
+			rec.NextRestartAt = ""
+			rec.Status = agentHealthRestarting
+			action = agentHealthActionRestart
+		}
+	}
+	return *rec, prevStatus, action
+}
+
+// forget 移除已不在运行列表中的 agent。
+func (m *agentHealthMonitor) forget(alive map[string]bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id := range m.records {
+		if !alive[id] {
+			delete(m.records, id)
+		}
+	}
+}
+
+// snapshot 全部 agent 健康状态 (按 agentId 排序)。
+func (m *agentHealthMonitor) snapshot() []agentHealthRecord {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]agentHealthRecord, 0, len(m.records))
+	for _, rec := range m.records {
+		out = append(out, *rec)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].AgentID < out[j].AgentID })
+	return out
+}
+
+// ========================================
+// 后台循环
+// ========================================
+
+// startAgentHealthMonitor 启动健康检查循环 (interval ≤ 0 时不启动)。
+func (s *Server) startAgentHealthMonitor(ctx context.Context) {
+	if s.health == nil || s.health.cfg.Interval <= 0 || s.mgr == nil {
+		return
+	}
+	util.SafeGo(func() {
+		ticker := time.NewTicker(s.health.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			s.checkAgentHealth(ctx)
+		}
+	})
+}
+
+// probeAgent 探测单个 agent。
+func (s *Server) probeAgent(agentID string) agentHealthProbe {
+	probe := agentHealthProbe{EventAge: -1}
+	proc := s.mgr.Get(agentID)
+	if proc == nil || proc.Client == nil {
+		return probe
+	}
+	probe.Running = proc.Client.Running()
+	if policy, ok := s.mgr.RestartPolicyFor(agentID); ok {
+		probe.Policy = &policy
+	}
+	if reporter, ok := proc.Client.(codex.ExitReporter); ok {
+		probe.ExitCode, probe.Exited = reporter.ExitStatus()
+	}
+	if _, _, _, ok := s.peekTrackedTurnMeta(agentID); ok {
+		probe.TurnActive = true
+	}
+	prober, ok := proc.Client.(agentHealthProber)
+	if !probe.Running || !ok {
+		return probe
+	}
+	start := time.Now()
+	probe.PingErr = prober.Ping(s.health.cfg.PingTimeout)
+	probe.Latency = time.Since(start)
+	if last := prober.LastActivity(); !last.IsZero() {
+		probe.EventAge = time.Since(last)
+	}
+	return probe
+}
+
+// checkAgentHealth 执行一轮健康检查。
+func (s *Server) checkAgentHealth(ctx context.Context) {
+	infos := s.mgr.List()
+	alive := make(map[string]bool, len(infos))
+	for _, info := range infos {
+		alive[info.ID] = true
+		probe := s.probeAgent(info.ID)
+		rec, prevStatus, action := s.health.observe(info.ID, probe, time.Now())
+		if rec.Status != prevStatus {
+			s.Notify("agent/health", rec)
+			switch {
+			case rec.Status == agentHealthUnhealthy:
+				s.writeAgentHealthIncident(rec, "unhealthy", "WARN")
+				s.emitNotifyEvent(notifyEvent{
+					Type:    notifyEventAgentUnhealthy,
+					AgentID: rec.AgentID,
+					Title:   "agent unhealthy",
+					Detail:  rec.LastError,
+					Fields:  map[string]any{"consecutiveFailures": rec.ConsecutiveFailures, "restarts": rec.Restarts},
+				})
+			case rec.Status == agentHealthHealthy && prevStatus != agentHealthDegraded:
+				s.writeAgentHealthIncident(rec, "recovered", "INFO")
+			}
+		}
+		switch action {
+		case agentHealthActionRestart:
+			if _, locked := s.emergency.current(); locked {
+				logger.Warn("agent health: restart skipped during emergency stop", logger.FieldAgentID, info.ID)
+				continue
+			}
+			s.restartUnhealthyAgent(ctx, rec)
+		case agentHealthActionGiveUp:
+			logger.Error("agent health: restart limit reached, giving up",
+				logger.FieldAgentID, info.ID,
+				"restarts", rec.Restarts,
+				logger.FieldError, rec.LastError,
+			)
+			s.writeAgentHealthIncident(rec, "gave_up", "ERROR")
+		}
+	}
+	s.health.forget(alive)
+}
+
+// restartUnhealthyAgent 停止并以原工作目录重新拉起 agent (恢复历史会话)。
+func (s *Server) restartUnhealthyAgent(ctx context.Context, rec agentHealthRecord) {
+	agentID := rec.AgentID
+	cwd := s.getAgentWorkDir(agentID)
+	logger.Warn("agent health: restarting unhealthy agent",
+		logger.FieldAgentID, agentID,
+		logger.FieldCwd, cwd,
+		"attempt", rec.Restarts,
+		logger.FieldError, rec.LastError,
+	)
+	s.writeAgentHealthIncident(rec, "restart", "WARN")
+	s.mgr.NoteRestart(agentID)
+	_ = s.cancelCodeRuns(agentID)
+	_ = s.mgr.Stop(agentID)
+	restartCtx, cancel := context.WithTimeout(ctx, agentHealthRestartWait)
+	defer cancel()
+	if _, err := s.ensureThreadReadyForTurn(restartCtx, agentID, cwd); err != nil {
+		rec.LastError = err.Error()
+		logger.Error("agent health: restart failed", logger.FieldAgentID, agentID, logger.FieldError, err)
+		s.writeAgentHealthIncident(rec, "restart_failed", "ERROR")
+		s.Notify("agent/health", rec)
+		return
+	}
+	logger.Info("agent health: agent restarted", logger.FieldAgentID, agentID)
+}
+
+// writeAgentHealthIncident 持久化健康事件 (审计日志)。
+func (s *Server) writeAgentHealthIncident(rec agentHealthRecord, action, level string) {
+	if s.auditLogStore == nil {
+		return
+	}
+	event := &store.AuditEvent{
+		EventType: agentHealthAuditType,
+		Action:    action,
+		Result:    rec.Status,
+		Actor:     rec.AgentID,
+		Target:    rec.AgentID,
+		Detail:    rec.LastError,
+		Level:     level,
+		Extra: map[string]any{
+			"consecutive_failures": rec.ConsecutiveFailures,
+			"restarts":             rec.Restarts,
+			"ping_latency_ms":      rec.PingLatencyMS,
+			"event_age_ms":         rec.EventAgeMS,
+		},
+	}
+	if err := s.auditLogStore.Append(context.Background(), event); err != nil {
+		logger.Warn("agent health: incident write failed", logger.FieldAgentID, rec.AgentID, logger.FieldError, err)
+	}
+}
+
+// ========================================
+// agent/health/list, agent/health/incidents
+// ========================================
+
+type agentHealthListParams struct {
+	Refresh bool `json:"refresh,omitempty"` // 立即执行一轮检查
+}
+
+func (s *Server) agentHealthListTyped(ctx context.Context, p agentHealthListParams) (any, error) {
+	if s.health == nil {
+		return map[string]any{"enabled": false, "agents": []agentHealthRecord{}}, nil
+	}
+	if p.Refresh && s.mgr != nil {
+		s.checkAgentHealth(ctx)
+	}
+	return map[string]any{
+		"enabled":     s.health.cfg.Interval > 0,
+		"intervalSec": int(s.health.cfg.Interval / time.Second),
+		"agents":      s.health.snapshot(),
+	}, nil
+}
+
+type agentHealthIncidentsParams struct {
+	AgentID string `json:"agentId,omitempty"`
+	Limit   int    `json:"limit,omitempty"`
+}
+
+func (s *Server) agentHealthIncidentsTyped(ctx context.Context, p agentHealthIncidentsParams) (any, error) {
+	if s.auditLogStore == nil {
+		return nil, apperrors.New("Server.agentHealthIncidents", "audit log store not initialized")
+	}
+	incidents, err := s.auditLogStore.List(ctx, agentHealthAuditType, "", strings.TrimSpace(p.AgentID), "", clampLimit(p.Limit, agentHealthListLimit))
+	if err != nil {
+		return nil, apperrors.Wrap(err, "Server.agentHealthIncidents", "list incidents")
+	}
+	return map[string]any{"incidents": incidents}, nil
+}
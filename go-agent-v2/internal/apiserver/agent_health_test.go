@@ -0,0 +1,135 @@
+package apiserver
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/runner"
+)
+
+func TestAgentHealthMonitor_RestartPolicy(t *testing.T) {
+	m := newAgentHealthMonitor(agentHealthConfig{
+		FailThreshold: 2,
+		MaxRestarts:   2,
+		Backoff:       10 * time.Second,
+		RestartWindow: 10 * time.Minute,
+	})
+	now := time.Unix(1000, 0)
+	pingFail := agentHealthProbe{Running: true, PingErr: errors.New("turn/start timeout"), EventAge: -1}
+
+	rec, _, action := m.observe("a1", pingFail, now)
+	if rec.Status != agentHealthDegraded || action != "" {
+		t.Fatalf("first failure: status=%s action=%q, want degraded", rec.Status, action)
+	}
+	rec, prev, action := m.observe("a1", pingFail, now.Add(time.Second))
+	if rec.Status != agentHealthUnhealthy || prev != agentHealthDegraded || action != "" || rec.NextRestartAt == "" {
+		t.Fatalf("threshold reached: %+v action=%q, want unhealthy waiting for backoff", rec, action)
+	}
+	rec, _, action = m.observe("a1", pingFail, now.Add(11*time.Second))
+	if rec.Status != agentHealthRestarting || action != agentHealthActionRestart || rec.Restarts != 1 {
+		t.Fatalf("after backoff: %+v action=%q, want restart", rec, action)
+	}
+
+	// 进程退出直接判定 unhealthy, 第二次重启退避翻倍
+	dead := agentHealthProbe{EventAge: -1}
+	rec, _, action = m.observe("a1", dead, now.Add(12*time.Second))
+	if rec.Status != agentHealthUnhealthy || action != "" {
+		t.Fatalf("dead process: %+v action=%q", rec, action)
+	}
+	if _, _, action = m.observe("a1", dead, now.Add(25*time.Second)); action != "" {
+		t.Fatal("second restart should wait doubled backoff (20s)")
+	}
+	if _, _, action = m.observe("a1", dead, now.Add(33*time.Second)); action != agentHealthActionRestart {
+		t.Fatalf("second restart action = %q, want restart", action)
+	}
+
+	rec, _, action = m.observe("a1", dead, now.Add(40*time.Second))
+	if rec.Status != agentHealthGaveUp || action != agentHealthActionGiveUp {
+		t.Fatalf("restart limit: %+v action=%q, want giveUp", rec, action)
+	}
+	if _, _, action = m.observe("a1", dead, now.Add(50*time.Second)); action != "" {
+		t.Fatalf("give up should fire once, got %q", action)
+	}
+
+	rec, prev, _ = m.observe("a1", agentHealthProbe{Running: true, EventAge: time.Second}, now.Add(60*time.Second))
+	if rec.Status != agentHealthHealthy || prev != agentHealthGaveUp || rec.ConsecutiveFailures != 0 {
+		t.Fatalf("recovered: %+v prev=%s", rec, prev)
+	}
+}
+
+func TestAgentHealthMonitor_StaleEventsDuringTurn(t *testing.T) {
+	m := newAgentHealthMonitor(agentHealthConfig{FailThreshold: 1, StaleAfter: time.Minute})
+	now := time.Unix(1000, 0)
+	rec, _, action := m.observe("a1", agentHealthProbe{Running: true, TurnActive: true, EventAge: 2 * time.Minute}, now)
+	if rec.Status != agentHealthDegraded || action != "" {
+		t.Fatalf("stale turn: %+v action=%q, want degraded without restart", rec, action)
+	}
+	rec, _, _ = m.observe("a1", agentHealthProbe{Running: true, EventAge: 2 * time.Minute}, now)
+	if rec.Status != agentHealthHealthy {
+		t.Fatalf("idle agent with old events should be healthy, got %s", rec.Status)
+	}
+}
+
+func TestAgentHealthMonitor_ReportOnlyWhenRestartsDisabled(t *testing.T) {
+	m := newAgentHealthMonitor(agentHealthConfig{FailThreshold: 1, MaxRestarts: 0})
+	rec, _, action := m.observe("a1", agentHealthProbe{EventAge: -1}, time.Unix(1000, 0))
+	if rec.Status != agentHealthUnhealthy || action != "" {
+		t.Fatalf("report-only: %+v action=%q", rec, action)
+	}
+	m.forget(map[string]bool{})
+	if len(m.snapshot()) != 0 {
+		t.Fatal("forget should drop agents no longer running")
+	}
+}
+
+func TestAgentHealthMonitor_PerAgentRestartPolicy(t *testing.T) {
+	// 全局关闭重启, agent 策略仍可启用。
+	m := newAgentHealthMonitor(agentHealthConfig{FailThreshold: 1, MaxRestarts: 0, Backoff: time.Minute})
+	now := time.Unix(1000, 0)
+
+	always := &runner.RestartPolicy{Mode: runner.RestartAlways, MaxRestarts: 1, BackoffMs: 1000}
+	cleanExit := agentHealthProbe{EventAge: -1, Exited: true, ExitCode: 0, Policy: always}
+	rec, _, action := m.observe("always", cleanExit, now)
+	if rec.Status != agentHealthUnhealthy || action != "" || rec.NextRestartAt == "" {
+		t.Fatalf("always, clean exit: %+v action=%q, want unhealthy awaiting 1s backoff", rec, action)
+	}
+	if _, _, action = m.observe("always", cleanExit, now.Add(time.Second)); action != agentHealthActionRestart {
+		t.Fatalf("always: action=%q, want restart after policy backoff", action)
+	}
+	if _, _, action = m.observe("always", cleanExit, now.Add(2*time.Second)); action != agentHealthActionGiveUp {
+		t.Fatalf("always: action=%q, want giveUp after policy maxRestarts", action)
+	}
+
+	onFailure := &runner.RestartPolicy{Mode: runner.RestartOnFailure}
+	rec, _, action = m.observe("onfail", agentHealthProbe{EventAge: -1, Exited: true, ExitCode: 0, Policy: onFailure}, now)
+	if rec.Status != agentHealthExited || action != "" {
+		t.Fatalf("on-failure, clean exit: %+v action=%q, want exited", rec, action)
+	}
+	rec, _, action = m.observe("onfail", agentHealthProbe{EventAge: -1, Exited: true, ExitCode: 137, Policy: onFailure}, now.Add(2*time.Minute))
+	if rec.Status != agentHealthUnhealthy || rec.LastError != "process exited (code 137)" {
+		t.Fatalf("on-failure, crash: %+v", rec)
+	}
+	if _, _, action = m.observe("onfail", agentHealthProbe{EventAge: -1, Exited: true, ExitCode: 137, Policy: onFailure}, now.Add(4*time.Minute)); action != agentHealthActionRestart {
+		t.Fatalf("on-failure, crash: action=%q, want restart with global backoff", action)
+	}
+
+	never := &runner.RestartPolicy{Mode: runner.RestartNever}
+	global := newAgentHealthMonitor(agentHealthConfig{FailThreshold: 1, MaxRestarts: 3})
+	rec, _, action = global.observe("never", agentHealthProbe{EventAge: -1, Exited: true, ExitCode: 1, Policy: never}, now)
+	if rec.Status != agentHealthUnhealthy || action != "" {
+		t.Fatalf("never: %+v action=%q, want report only", rec, action)
+	}
+}
+
+func TestAgentHealthList_DisabledByDefault(t *testing.T) {
+	srv := &Server{health: newAgentHealthMonitor(agentHealthConfig{})}
+	out, err := srv.agentHealthListTyped(context.Background(), agentHealthListParams{})
+	if err != nil {
+		t.Fatalf("agentHealthList error: %v", err)
+	}
+	if out.(map[string]any)["enabled"] != false {
+		t.Fatalf("enabled = %v, want false", out.(map[string]any)["enabled"])
+	}
+}
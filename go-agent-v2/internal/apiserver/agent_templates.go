@@ -0,0 +1,382 @@
+// agent_templates.go — 角色化 agent 模板 (reviewer / tester / doc-writer 等专职 agent)。
+//
+// 模板打包 模型 + 审批策略 + 基础指令 + 技能 + 动态工具集:
+//   - thread/start 携带 templateId 时按模板启动 (模型 / 指令写入 codex thread/start, 工具集按白名单过滤);
+//   - agentTemplate/apply 将模板套用到已有线程 (技能、审批策略、turn 模型立即生效,
+//     基础指令随下一次 turn/start 注入一次, 工具白名单在 dynamic tool 调用时强制)。
+//
+// 自定义模板以 UI 偏好存储 (settings.agentTemplates, id → template), 内置模板可被同 id 覆盖。
+// 审批策略 never 表示不询问客户端直接拒绝 (与 codex 语义一致: 失败交还模型处理)。
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/multi-agent/go-agent-v2/internal/codex"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+const (
+	prefKeyAgentTemplates = "settings.agentTemplates"
+	agentTemplateMetaKey  = "agent.template"
+
+	maxAgentTemplates          = 64
+	maxAgentTemplateInstrRunes = 16000
+)
+
+var (
+	agentTemplateIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,63}$`)
+
+	agentTemplateApprovalPolicies = map[string]bool{
+		"":           true,
+		"untrusted":  true,
+		"on-failure": true,
+		"on-request": true,
+		"never":      true,
+	}
+)
+
+// agentTemplate 角色模板。
+type agentTemplate struct {
+	ID               string   `json:"id"`
+	Name             string   `json:"name,omitempty"`
+	Description      string   `json:"description,omitempty"`
+	Model            string   `json:"model,omitempty"`
+	ApprovalPolicy   string   `json:"approvalPolicy,omitempty"`
+	BaseInstructions string   `json:"baseInstructions,omitempty"`
+	Skills           []string `json:"skills,omitempty"`
+	DynamicTools     []string `json:"dynamicTools,omitempty"` // 工具名白名单, 支持 "lsp_*" 前缀; 空 = 全部
+	BuiltIn          bool     `json:"builtIn,omitempty"`
+}
+
+// builtinAgentTemplates 内置专职 agent 模板。
+func builtinAgentTemplates() map[string]agentTemplate {
+	return map[string]agentTemplate{
+		"reviewer": {
+			ID:             "reviewer",
+			Name:           "Code Reviewer",
+			Description:    "只读审查改动, 输出问题清单, 不修改文件",
+			ApprovalPolicy: "never",
+			BaseInstructions: "你是代码审查 agent。只阅读代码与 diff, 不修改任何文件。" +
+				"按严重程度列出正确性、并发、错误处理与可维护性问题, 每条附文件与行号及修改建议。",
+			DynamicTools: []string{"lsp_*", "kb_search", "shared_file_read", "orchestration_send_message", "orchestration_list_agents"},
+			BuiltIn:      true,
+		},
+		"tester": {
+			ID:             "tester",
+			Name:           "Test Engineer",
+			Description:    "补充与运行测试, 定位失败原因",
+			ApprovalPolicy: "on-failure",
+			BaseInstructions: "你是测试 agent。为改动补充单元测试与回归用例, 运行测试并报告结果;" +
+				"测试失败时先定位根因, 只修改测试代码, 业务代码问题通过消息反馈。",
+			DynamicTools: []string{"lsp_*", "code_run", "code_run_test", "shared_file_read", "shared_file_write", "orchestration_send_message"},
+			BuiltIn:      true,
+		},
+		"doc-writer": {
+			ID:             "doc-writer",
+			Name:           "Doc Writer",
+			Description:    "编写与更新文档 / 注释",
+			ApprovalPolicy: "on-request",
+			BaseInstructions: "你是文档 agent。根据代码行为编写或更新 README、设计文档与注释," +
+				"保持与现有文档风格一致, 不改动业务逻辑。",
+			DynamicTools: []string{"lsp_document_symbol", "lsp_hover", "lsp_workspace_symbol", "kb_search", "shared_file_read", "shared_file_write"},
+			BuiltIn:      true,
+		},
+	}
+}
+
+// normalize 校验并规整模板字段。
+func (t agentTemplate) normalize() (agentTemplate, error) {
+	t.ID = strings.ToLower(strings.TrimSpace(t.ID))
+	if !agentTemplateIDPattern.MatchString(t.ID) {
+		return t, apperrors.Newf("agentTemplate.normalize", "invalid template id %q (want [a-z0-9._-], ≤64)", t.ID)
+	}
+	t.Name = strings.TrimSpace(t.Name)
+	t.Description = strings.TrimSpace(t.Description)
+	t.Model = strings.TrimSpace(t.Model)
+	t.ApprovalPolicy = strings.ToLower(strings.TrimSpace(t.ApprovalPolicy))
+	if !agentTemplateApprovalPolicies[t.ApprovalPolicy] {
+		return t, apperrors.Newf("agentTemplate.normalize", "unknown approvalPolicy %q", t.ApprovalPolicy)
+	}
+	t.BaseInstructions = strings.TrimSpace(t.BaseInstructions)
+	if len([]rune(t.BaseInstructions)) > maxAgentTemplateInstrRunes {
+		return t, apperrors.Newf("agentTemplate.normalize", "baseInstructions exceeds %d runes", maxAgentTemplateInstrRunes)
+	}
+	skills, err := normalizeSkillNames(t.Skills)
+	if err != nil {
+		return t, apperrors.Wrap(err, "agentTemplate.normalize", "normalize skills")
+	}
+	t.Skills = skills
+	tools := make([]string, 0, len(t.DynamicTools))
+	seen := make(map[string]bool, len(t.DynamicTools))
+	for _, raw := range t.DynamicTools {
+		name := strings.TrimSpace(raw)
+		if name == "" || seen[name] {
+			continue
+		}
+		if strings.Contains(strings.TrimSuffix(name, "*"), "*") {
+			return t, apperrors.Newf("agentTemplate.normalize", "invalid tool pattern %q (only trailing * allowed)", name)
+		}
+		seen[name] = true
+		tools = append(tools, name)
+	}
+	t.DynamicTools = tools
+	t.BuiltIn = false
+	return t, nil
+}
+
+// allowsTool 工具名是否在模板白名单内 (白名单为空 = 全部允许)。
+func (t agentTemplate) allowsTool(name string) bool {
+	if len(t.DynamicTools) == 0 {
+		return true
+	}
+	for _, pattern := range t.DynamicTools {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if pattern == name {
+			return true
+		}
+	}
+	return false
+}
+
+// filterTools 按白名单过滤启动时注入的动态工具。
+func (t agentTemplate) filterTools(tools []codex.DynamicTool) []codex.DynamicTool {
+	if len(t.DynamicTools) == 0 {
+		return tools
+	}
+	out := make([]codex.DynamicTool, 0, len(tools))
+	for _, tool := range tools {
+		if t.allowsTool(tool.Name) {
+			out = append(out, tool)
+		}
+	}
+	return out
+}
+
+// threadTemplateBinding 线程已应用的模板。
+type threadTemplateBinding struct {
+	Template            agentTemplate
+	PendingInstructions bool // 基础指令待随下一次 turn/start 注入
+}
+
+// agentTemplateBindings 线程 → 已应用模板 (零值可用)。
+type agentTemplateBindings struct {
+	mu       sync.RWMutex
+	byThread map[string]threadTemplateBinding
+}
+
+func (b *agentTemplateBindings) set(threadID string, binding threadTemplateBinding) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.byThread == nil {
+		b.byThread = make(map[string]threadTemplateBinding)
+	}
+	b.byThread[threadID] = binding
+}
+
+func (b *agentTemplateBindings) get(threadID string) (agentTemplate, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	binding, ok := b.byThread[threadID]
+	return binding.Template, ok
+}
+
+// pendingInstructions 待注入的基础指令 (只读; turn 提交或排队成功后再 consumeInstructions)。
+func (b *agentTemplateBindings) pendingInstructions(threadID string) string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	binding, ok := b.byThread[threadID]
+	if !ok || !binding.PendingInstructions {
+		return ""
+	}
+	return binding.Template.BaseInstructions
+}
+
+// consumeInstructions 标记基础指令已注入 (每次 apply 仅注入一次); 期间重新应用了不同指令的模板时保持待注入。
+func (b *agentTemplateBindings) consumeInstructions(threadID, instructions string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	binding, ok := b.byThread[threadID]
+	if !ok || !binding.PendingInstructions || binding.Template.BaseInstructions != instructions {
+		return
+	}
+	binding.PendingInstructions = false
+	b.byThread[threadID] = binding
+}
+
+// ========================================
+// 模板存储
+// ========================================
+
+func (s *Server) loadCustomAgentTemplates(ctx context.Context) map[string]agentTemplate {
+	out := map[string]agentTemplate{}
+	if s.prefManager == nil {
+		return out
+	}
+	value, err := s.prefManager.Get(ctx, prefKeyAgentTemplates)
+	if err != nil {
+		logger.Warn("agent templates: load preference failed", logger.FieldError, err)
+		return out
+	}
+	if value == nil {
+		return out
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return out
+	}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		logger.Warn("agent templates: decode preference failed", logger.FieldError, err)
+		return map[string]agentTemplate{}
+	}
+	return out
+}
+
+// loadAgentTemplates 内置模板 + 自定义模板 (同 id 时自定义覆盖内置)。
+func (s *Server) loadAgentTemplates(ctx context.Context) map[string]agentTemplate {
+	all := builtinAgentTemplates()
+	for id, tmpl := range s.loadCustomAgentTemplates(ctx) {
+		tmpl.ID = id
+		all[id] = tmpl
+	}
+	return all
+}
+
+func (s *Server) resolveAgentTemplate(ctx context.Context, op, id string) (agentTemplate, error) {
+	key := strings.ToLower(strings.TrimSpace(id))
+	tmpl, ok := s.loadAgentTemplates(ctx)[key]
+	if !ok {
+		return agentTemplate{}, apperrors.Newf(op, "agent template %q not found", id)
+	}
+	return tmpl, nil
+}
+
+// ========================================
+// Server 集成
+// ========================================
+
+// applyTemplateToThread 登记线程模板: 技能、审批策略、turn 模型与工具白名单。
+func (s *Server) applyTemplateToThread(ctx context.Context, threadID string, tmpl agentTemplate, pendingInstructions bool) {
+	s.agentTemplates.set(threadID, threadTemplateBinding{Template: tmpl, PendingInstructions: pendingInstructions && tmpl.BaseInstructions != ""})
+	if len(tmpl.Skills) > 0 {
+		s.skillsMu.Lock()
+		s.agentSkills[threadID] = append([]string(nil), tmpl.Skills...)
+		s.skillsMu.Unlock()
+	}
+	id := tmpl.ID
+	if _, err := s.threadMetaSetTyped(ctx, threadMetaSetParams{ThreadID: threadID, Meta: map[string]*string{agentTemplateMetaKey: &id}}); err != nil {
+		logger.Warn("agent template: tag thread metadata failed", logger.FieldThreadID, threadID, logger.FieldError, err)
+	}
+}
+
+// templateTurnModel turn/start 未指定模型时取线程模板的模型。
+func (s *Server) templateTurnModel(threadID, model string) string {
+	if strings.TrimSpace(model) != "" {
+		return model
+	}
+	if tmpl, ok := s.agentTemplates.get(threadID); ok {
+		return tmpl.Model
+	}
+	return model
+}
+
+// templateDeniesApprovals 线程模板审批策略为 never 时不询问客户端直接拒绝。
+func (s *Server) templateDeniesApprovals(threadID string) bool {
+	tmpl, ok := s.agentTemplates.get(threadID)
+	return ok && tmpl.ApprovalPolicy == "never"
+}
+
+// templateToolError 工具不在线程模板白名单内时返回错误。
+func (s *Server) templateToolError(threadID, tool string) error {
+	tmpl, ok := s.agentTemplates.get(threadID)
+	if !ok || tmpl.allowsTool(tool) {
+		return nil
+	}
+	return apperrors.NewCodef("Server.handleDynamicToolCall", errcode.ToolNotAllowed, "tool %s is not enabled by agent template %s", tool, tmpl.ID)
+}
+
+// ========================================
+// JSON-RPC
+// ========================================
+
+type agentTemplateCreateParams struct {
+	agentTemplate
+	Overwrite bool `json:"overwrite,omitempty"`
+}
+
+func (s *Server) agentTemplateCreateTyped(ctx context.Context, p agentTemplateCreateParams) (any, error) {
+	if s.prefManager == nil {
+		return nil, apperrors.New("Server.agentTemplateCreate", "preference manager not initialized")
+	}
+	tmpl, err := p.agentTemplate.normalize()
+	if err != nil {
+		return nil, apperrors.Wrap(err, "Server.agentTemplateCreate", "validate template")
+	}
+
+	s.agentTemplatePrefMu.Lock()
+	defer s.agentTemplatePrefMu.Unlock()
+	custom := s.loadCustomAgentTemplates(ctx)
+	if _, exists := custom[tmpl.ID]; exists && !p.Overwrite {
+		return nil, apperrors.Newf("Server.agentTemplateCreate", "agent template %q already exists (set overwrite to replace)", tmpl.ID)
+	}
+	if _, exists := custom[tmpl.ID]; !exists && len(custom) >= maxAgentTemplates {
+		return nil, apperrors.Newf("Server.agentTemplateCreate", "too many agent templates (max %d)", maxAgentTemplates)
+	}
+	custom[tmpl.ID] = tmpl
+	if err := s.prefManager.Set(ctx, prefKeyAgentTemplates, custom); err != nil {
+		return nil, apperrors.Wrap(err, "Server.agentTemplateCreate", "save templates")
+	}
+	logger.Info("agentTemplate/create: saved",
+		logger.FieldID, tmpl.ID,
+		"model", tmpl.Model,
+		"approval_policy", tmpl.ApprovalPolicy,
+		"skills", len(tmpl.Skills),
+		"tools", len(tmpl.DynamicTools),
+	)
+	return map[string]any{"template": tmpl}, nil
+}
+
+func (s *Server) agentTemplateList(ctx context.Context, _ json.RawMessage) (any, error) {
+	all := s.loadAgentTemplates(ctx)
+	templates := make([]agentTemplate, 0, len(all))
+	for _, tmpl := range all {
+		templates = append(templates, tmpl)
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].ID < templates[j].ID })
+	return map[string]any{"templates": templates}, nil
+}
+
+type agentTemplateApplyParams struct {
+	TemplateID string `json:"templateId"`
+	ThreadID   string `json:"threadId"`
+}
+
+func (s *Server) agentTemplateApplyTyped(ctx context.Context, p agentTemplateApplyParams) (any, error) {
+	threadID := strings.TrimSpace(p.ThreadID)
+	if threadID == "" {
+		return nil, apperrors.New("Server.agentTemplateApply", "threadId is required")
+	}
+	if s.mgr == nil || s.mgr.Get(threadID) == nil {
+		return nil, apperrors.Newf("Server.agentTemplateApply", "thread %s not running", threadID)
+	}
+	tmpl, err := s.resolveAgentTemplate(ctx, "Server.agentTemplateApply", p.TemplateID)
+	if err != nil {
+		return nil, err
+	}
+	s.applyTemplateToThread(ctx, threadID, tmpl, true)
+	logger.Info("agentTemplate/apply: applied",
+		logger.FieldThreadID, threadID,
+		logger.FieldID, tmpl.ID,
+	)
+	return map[string]any{"threadId": threadID, "template": tmpl}, nil
+}
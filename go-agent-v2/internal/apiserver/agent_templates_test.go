@@ -0,0 +1,83 @@
+package apiserver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/multi-agent/go-agent-v2/internal/codex"
+	"github.com/multi-agent/go-agent-v2/internal/uistate"
+)
+
+func TestAgentTemplateCreateListAndOverride(t *testing.T) {
+	srv := &Server{prefManager: uistate.NewPreferenceManager(nil)}
+	ctx := context.Background()
+
+	if _, err := srv.agentTemplateCreateTyped(ctx, agentTemplateCreateParams{agentTemplate: agentTemplate{ID: "Bad Id"}}); err == nil {
+		t.Fatal("invalid id should be rejected")
+	}
+	if _, err := srv.agentTemplateCreateTyped(ctx, agentTemplateCreateParams{agentTemplate: agentTemplate{ID: "x", ApprovalPolicy: "always"}}); err == nil {
+		t.Fatal("unknown approval policy should be rejected")
+	}
+
+	custom := agentTemplate{ID: "Migrator", Model: "gpt-5", ApprovalPolicy: "On-Request", Skills: []string{"go"}, DynamicTools: []string{"lsp_*", " code_run "}}
+	if _, err := srv.agentTemplateCreateTyped(ctx, agentTemplateCreateParams{agentTemplate: custom}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := srv.agentTemplateCreateTyped(ctx, agentTemplateCreateParams{agentTemplate: custom}); err == nil {
+		t.Fatal("duplicate create without overwrite should fail")
+	}
+	override := agentTemplate{ID: "reviewer", Model: "o3", ApprovalPolicy: "never"}
+	if _, err := srv.agentTemplateCreateTyped(ctx, agentTemplateCreateParams{agentTemplate: override}); err != nil {
+		t.Fatalf("override builtin: %v", err)
+	}
+
+	resp, err := srv.agentTemplateList(ctx, nil)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	templates := resp.(map[string]any)["templates"].([]agentTemplate)
+	byID := map[string]agentTemplate{}
+	for _, tmpl := range templates {
+		byID[tmpl.ID] = tmpl
+	}
+	if len(templates) != 4 || !byID["tester"].BuiltIn || byID["reviewer"].BuiltIn || byID["reviewer"].Model != "o3" {
+		t.Fatalf("templates = %+v", templates)
+	}
+	migrator := byID["migrator"]
+	if migrator.ApprovalPolicy != "on-request" || len(migrator.DynamicTools) != 2 || migrator.DynamicTools[1] != "code_run" {
+		t.Fatalf("migrator = %+v", migrator)
+	}
+}
+
+func TestAgentTemplateThreadBinding(t *testing.T) {
+	tmpl := agentTemplate{ID: "reviewer", Model: "o3", ApprovalPolicy: "never", BaseInstructions: "review only", DynamicTools: []string{"lsp_*", "kb_search"}}
+	tools := tmpl.filterTools([]codex.DynamicTool{{Name: "lsp_hover"}, {Name: "code_run"}, {Name: "kb_search"}})
+	if len(tools) != 2 || tools[0].Name != "lsp_hover" || tools[1].Name != "kb_search" {
+		t.Fatalf("filtered tools = %+v", tools)
+	}
+
+	srv := &Server{agentSkills: map[string][]string{}}
+	srv.agentTemplates.set("t1", threadTemplateBinding{Template: tmpl, PendingInstructions: true})
+	if got := srv.templateTurnModel("t1", ""); got != "o3" {
+		t.Fatalf("turn model = %q", got)
+	}
+	if got := srv.templateTurnModel("t1", "gpt-5"); got != "gpt-5" {
+		t.Fatalf("explicit model overridden: %q", got)
+	}
+	if !srv.templateDeniesApprovals("t1") || srv.templateDeniesApprovals("t2") {
+		t.Fatal("approval policy never should only apply to bound thread")
+	}
+	if srv.templateToolError("t1", "code_run") == nil || srv.templateToolError("t1", "lsp_rename") != nil || srv.templateToolError("t2", "code_run") != nil {
+		t.Fatal("tool allowlist not enforced per thread")
+	}
+	if got := srv.agentTemplates.pendingInstructions("t1"); got != "review only" {
+		t.Fatalf("instructions = %q", got)
+	}
+	if got := srv.agentTemplates.pendingInstructions("t1"); got != "review only" {
+		t.Fatalf("peeking must not consume instructions, got %q", got)
+	}
+	srv.agentTemplates.consumeInstructions("t1", "review only")
+	if got := srv.agentTemplates.pendingInstructions("t1"); got != "" {
+		t.Fatalf("instructions should be injected once, got %q", got)
+	}
+}
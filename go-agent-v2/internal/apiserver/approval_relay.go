@@ -0,0 +1,364 @@
+// approval_relay.go — 审批推送中继: 审批未及时处理时推送到移动端 (ntfy / FCM), 经签名链接回复。
+//
+// 流程:
+//  1. 审批请求进入等待后, 超过 APPROVAL_RELAY_DELAY_SEC 仍未答复 (或当前无任何前端连接) 时,
+//     把命令文本、线程与风险分级推送到 APPROVAL_RELAY_URL;
+//  2. 推送内含 approve / deny 两个回复链接, 参数经 HMAC-SHA256 (APPROVAL_RELAY_SECRET) 签名并带过期时间;
+//  3. 移动端 POST /approval/respond?id=&decision=&exp=&sig= 校验通过后投递决定, 与前端审批竞争, 先到先得。
+package apiserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/executor"
+	"github.com/multi-agent/go-agent-v2/internal/uistate"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+	"github.com/multi-agent/go-agent-v2/pkg/util"
+)
+
+const (
+	approvalRelayKindNtfy = "ntfy"
+	approvalRelayKindFCM  = "fcm"
+
+	approvalDecisionApprove = "approve"
+	approvalDecisionDeny    = "deny"
+
+	approvalRelayMaxCommand = 1024
+)
+
+// relayedApproval 等待移动端回复的审批。
+type relayedApproval struct {
+	ID       string
+	AgentID  string
+	Method   string
+	Command  string
+	Files    []string
+	Risk     string
+	decision chan bool
+}
+
+// approvalRelayState 待回复审批表 (零值可用)。
+type approvalRelayState struct {
+	mu      sync.Mutex
+	pending map[string]*relayedApproval
+	client  *http.Client // nil = 默认超时客户端
+}
+
+func (st *approvalRelayState) add(req *relayedApproval) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.pending == nil {
+		st.pending = make(map[string]*relayedApproval)
+	}
+	st.pending[req.ID] = req
+}
+
+func (st *approvalRelayState) remove(id string) {
+	st.mu.Lock()
+	delete(st.pending, id)
+	st.mu.Unlock()
+}
+
+// resolve 投递决定; 审批已结束 (或已被回复) 时返回 false。
+func (st *approvalRelayState) resolve(id string, approved bool) bool {
+	st.mu.Lock()
+	req, ok := st.pending[id]
+	delete(st.pending, id)
+	st.mu.Unlock()
+	if !ok {
+		return false
+	}
+	select {
+	case req.decision <- approved:
+		return true
+	default:
+		return false
+	}
+}
+
+func (st *approvalRelayState) httpClient() *http.Client {
+	if st.client != nil {
+		return st.client
+	}
+	return &http.Client{Timeout: notifySendTimeout}
+}
+
+func (s *Server) approvalRelayEnabled() bool {
+	return s.cfg != nil &&
+		strings.TrimSpace(s.cfg.ApprovalRelayURL) != "" &&
+		s.cfg.ApprovalRelaySecret != "" &&
+		strings.TrimSpace(s.cfg.ApprovalRelayPublicURL) != ""
+}
+
+// hasApprovalFrontend 是否存在可交互审批的前端 (WebSocket 客户端或 Wails)。
+func (s *Server) hasApprovalFrontend() bool {
+	s.mu.RLock()
+	numConns := 0
+	for _, entry := range s.conns {
+		if entry.spectator == nil {
+			numConns++
+		}
+	}
+	s.mu.RUnlock()
+	if numConns > 0 {
+		return true
+	}
+	s.notifyHookMu.RLock()
+	defer s.notifyHookMu.RUnlock()
+	return s.notifyHook != nil
+}
+
+// classifyApprovalRisk 审批风险分级: 命中危险命令模式 high, 其余命令执行 medium, 文件变更 low。
+func classifyApprovalRisk(method, command string) string {
+	if command != "" && executor.DetectDangerous(command) != "" {
+		return "high"
+	}
+	if strings.Contains(method, "commandExecution") {
+		return "medium"
+	}
+	return "low"
+}
+
+// awaitApprovalWithRelay 在客户端审批之外并行启用推送中继, 返回先到的决定。
+// 中继未配置时等价于 waitClient(); 无前端连接时立即推送并只等待移动端回复。
+// 移动端先答复时取消 waitClient 的 ctx, 由其撤回 pending 请求并通知前端关闭审批对话框。
+func (s *Server) awaitApprovalWithRelay(agentID, method string, payload map[string]any, waitClient func(ctx context.Context) bool) bool {
+	if !s.approvalRelayEnabled() {
+		return waitClient(context.Background())
+	}
+	normalized := uistate.NormalizeEventFromPayload("", method, payload)
+	command := strings.TrimSpace(normalized.Command)
+	if command == "" {
+		command = extractFirstString(payload, "command", "cmd")
+	}
+	req := &relayedApproval{
+		ID:       rand.Text(),
+		AgentID:  agentID,
+		Method:   method,
+		Command:  command,
+		Files:    normalized.Files,
+		Risk:     classifyApprovalRisk(method, command),
+		decision: make(chan bool, 1),
+	}
+	s.approvalRelay.add(req)
+	defer s.approvalRelay.remove(req.ID)
+	timeout := time.Duration(s.cfg.ApprovalRelayTimeoutSec) * time.Second
+
+	if !s.hasApprovalFrontend() {
+		logger.Info("approval relay: no frontend connected, pushing immediately",
+			logger.FieldAgentID, agentID, logger.FieldMethod, method, "risk", req.Risk)
+		s.pushRelayedApproval(req, timeout)
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case approved := <-req.decision:
+			return approved
+		case <-timer.C:
+			logger.Warn("approval relay: no response before timeout, denying",
+				logger.FieldAgentID, agentID, logger.FieldMethod, method)
+			return false
+		}
+	}
+
+	clientCtx, cancelClient := context.WithCancel(context.Background())
+	defer cancelClient()
+	clientDone := make(chan bool, 1)
+	util.SafeGo(func() { clientDone <- waitClient(clientCtx) })
+	pushTimer := time.AfterFunc(time.Duration(s.cfg.ApprovalRelayDelaySec)*time.Second, func() {
+		s.pushRelayedApproval(req, timeout)
+	})
+	defer pushTimer.Stop()
+	select {
+	case approved := <-clientDone:
+		return approved
+	case approved := <-req.decision:
+		logger.Info("approval relay: answered from push",
+			logger.FieldAgentID, agentID, logger.FieldMethod, method, "approved", approved)
+		return approved
+	}
+}
+
+// signApprovalDecision 回复链接签名: HMAC-SHA256(secret, id \n decision \n exp)。
+func signApprovalDecision(secret, id, decision string, exp int64) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = fmt.Fprintf(mac, "%s\n%s\n%d", id, decision, exp)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *Server) approvalDecisionURL(id, decision string, exp int64) string {
+	q := url.Values{}
+	q.Set("id", id)
+	q.Set("decision", decision)
+	q.Set("exp", strconv.FormatInt(exp, 10))
+	q.Set("sig", signApprovalDecision(s.cfg.ApprovalRelaySecret, id, decision, exp))
+	return strings.TrimRight(strings.TrimSpace(s.cfg.ApprovalRelayPublicURL), "/") + "/approval/respond?" + q.Encode()
+}
+
+// pushRelayedApproval 推送审批到中继, 失败只记日志 (前端审批仍可用)。
+func (s *Server) pushRelayedApproval(req *relayedApproval, ttl time.Duration) {
+	exp := time.Now().Add(ttl).Unix()
+	approveURL := s.approvalDecisionURL(req.ID, approvalDecisionApprove, exp)
+	denyURL := s.approvalDecisionURL(req.ID, approvalDecisionDeny, exp)
+	ctx, cancel := context.WithTimeout(context.Background(), notifySendTimeout)
+	defer cancel()
+
+	var err error
+	switch strings.ToLower(strings.TrimSpace(s.cfg.ApprovalRelayKind)) {
+	case approvalRelayKindFCM:
+		err = s.pushApprovalFCM(ctx, req, approveURL, denyURL, exp)
+	default:
+		err = s.pushApprovalNtfy(ctx, req, approveURL, denyURL)
+	}
+	if err != nil {
+		logger.Warn("approval relay: push failed", logger.FieldAgentID, req.AgentID, logger.FieldError, err)
+		return
+	}
+	logger.Info("approval relay: pushed",
+		logger.FieldAgentID, req.AgentID, logger.FieldMethod, req.Method, "risk", req.Risk)
+}
+
+func (req *relayedApproval) title() string {
+	kind := "file change"
+	if strings.Contains(req.Method, "commandExecution") {
+		kind = "command"
+	}
+	return fmt.Sprintf("Approval needed (%s, %s risk)", kind, req.Risk)
+}
+
+func (req *relayedApproval) body() string {
+	var b strings.Builder
+	b.WriteString("thread: " + req.AgentID)
+	if req.Command != "" {
+		b.WriteString("\n$ " + truncateRunes(req.Command, approvalRelayMaxCommand))
+	}
+	if len(req.Files) > 0 {
+		b.WriteString("\nfiles: " + strings.Join(req.Files, ", "))
+	}
+	return b.String()
+}
+
+func (s *Server) pushApprovalNtfy(ctx context.Context, req *relayedApproval, approveURL, denyURL string) error {
+	const op = "Server.pushApprovalNtfy"
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSpace(s.cfg.ApprovalRelayURL), strings.NewReader(req.body()))
+	if err != nil {
+		return apperrors.Wrap(err, op, "build request")
+	}
+	priority, tags := "high", "lock"
+	if req.Risk == "high" {
+		priority, tags = "urgent", "warning"
+	}
+	httpReq.Header.Set("Title", req.title())
+	httpReq.Header.Set("Priority", priority)
+	httpReq.Header.Set("Tags", tags)
+	httpReq.Header.Set("Actions", fmt.Sprintf(
+		"http, Approve, %s, method=POST, clear=true; http, Deny, %s, method=POST, clear=true", approveURL, denyURL))
+	if token := strings.TrimSpace(s.cfg.ApprovalRelayToken); token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+token)
+	}
+	return s.doApprovalRelayRequest(op, httpReq)
+}
+
+func (s *Server) pushApprovalFCM(ctx context.Context, req *relayedApproval, approveURL, denyURL string, exp int64) error {
+	const op = "Server.pushApprovalFCM"
+	target := strings.TrimSpace(s.cfg.ApprovalRelayFCMTarget)
+	if target == "" {
+		return apperrors.New(op, "APPROVAL_RELAY_FCM_TARGET is required for fcm relay")
+	}
+	body, err := json.Marshal(map[string]any{"message": map[string]any{
+		"token":        target,
+		"notification": map[string]any{"title": req.title(), "body": req.body()},
+		"data": map[string]string{
+			"approvalId": req.ID,
+			"threadId":   req.AgentID,
+			"method":     req.Method,
+			"risk":       req.Risk,
+			"command":    truncateRunes(req.Command, approvalRelayMaxCommand),
+			"approveUrl": approveURL,
+			"denyUrl":    denyURL,
+			"expiresAt":  strconv.FormatInt(exp, 10),
+		},
+		"android": map[string]any{"priority": "high"},
+	}})
+	if err != nil {
+		return apperrors.Wrap(err, op, "marshal message")
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSpace(s.cfg.ApprovalRelayURL), bytes.NewReader(body))
+	if err != nil {
+		return apperrors.Wrap(err, op, "build request")
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if token := strings.TrimSpace(s.cfg.ApprovalRelayToken); token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+token)
+	}
+	return s.doApprovalRelayRequest(op, httpReq)
+}
+
+func (s *Server) doApprovalRelayRequest(op string, req *http.Request) error {
+	resp, err := s.approvalRelay.httpClient().Do(req)
+	if err != nil {
+		return apperrors.Wrap(err, op, "send")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return apperrors.Newf(op, "relay returned HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(snippet)))
+	}
+	return nil
+}
+
+// handleApprovalRelayRespond POST /approval/respond: 校验签名后投递移动端的审批决定。
+func (s *Server) handleApprovalRelayRespond(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.approvalRelayEnabled() {
+		http.Error(w, "approval relay disabled", http.StatusNotFound)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	id := r.Form.Get("id")
+	decision := r.Form.Get("decision")
+	exp, err := strconv.ParseInt(r.Form.Get("exp"), 10, 64)
+	if id == "" || err != nil || (decision != approvalDecisionApprove && decision != approvalDecisionDeny) {
+		http.Error(w, "id, decision and exp are required", http.StatusBadRequest)
+		return
+	}
+	want := signApprovalDecision(s.cfg.ApprovalRelaySecret, id, decision, exp)
+	if !hmac.Equal([]byte(want), []byte(r.Form.Get("sig"))) {
+		logger.Warn("approval relay: invalid signature", logger.FieldRemote, r.RemoteAddr)
+		http.Error(w, "invalid signature", http.StatusForbidden)
+		return
+	}
+	if time.Now().Unix() > exp {
+		http.Error(w, "link expired", http.StatusGone)
+		return
+	}
+	approved := decision == approvalDecisionApprove
+	if !s.approvalRelay.resolve(id, approved) {
+		http.Error(w, "approval already resolved", http.StatusGone)
+		return
+	}
+	logger.Info("approval relay: decision received", logger.FieldRemote, r.RemoteAddr, "approved", approved)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "approved": approved})
+}
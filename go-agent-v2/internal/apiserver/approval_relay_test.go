@@ -0,0 +1,211 @@
+package apiserver
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/config"
+)
+
+type relayPush struct {
+	header http.Header
+	body   string
+}
+
+func newApprovalRelayTestServer(t *testing.T) (*Server, <-chan relayPush) {
+	t.Helper()
+	pushes := make(chan relayPush, 4)
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		pushes <- relayPush{header: r.Header.Clone(), body: string(data)}
+	}))
+	t.Cleanup(relay.Close)
+	srv := &Server{cfg: &config.Config{
+		ApprovalRelayKind:       approvalRelayKindNtfy,
+		ApprovalRelayURL:        relay.URL,
+		ApprovalRelayToken:      "tok",
+		ApprovalRelaySecret:     "s3cret",
+		ApprovalRelayPublicURL:  "https://agents.example.com/",
+		ApprovalRelayDelaySec:   0,
+		ApprovalRelayTimeoutSec: 60,
+	}}
+	return srv, pushes
+}
+
+// actionURL 从 ntfy Actions 头中取出指定按钮的链接。
+func actionURL(t *testing.T, actions, label string) string {
+	t.Helper()
+	for _, action := range strings.Split(actions, ";") {
+		parts := strings.Split(action, ",")
+		if len(parts) >= 3 && strings.TrimSpace(parts[1]) == label {
+			return strings.TrimSpace(parts[2])
+		}
+	}
+	t.Fatalf("action %q not found in %q", label, actions)
+	return ""
+}
+
+func postDecision(srv *Server, link string) *httptest.ResponseRecorder {
+	u, _ := url.Parse(link)
+	rec := httptest.NewRecorder()
+	srv.handleApprovalRelayRespond(rec, httptest.NewRequest(http.MethodPost, "/approval/respond?"+u.RawQuery, nil))
+	return rec
+}
+
+func TestClassifyApprovalRisk(t *testing.T) {
+	method := "item/commandExecution/requestApproval"
+	if got := classifyApprovalRisk(method, "rm -rf /"); got != "high" {
+		t.Errorf("rm -rf / risk = %s", got)
+	}
+	if got := classifyApprovalRisk(method, "go test ./..."); got != "medium" {
+		t.Errorf("go test risk = %s", got)
+	}
+	if got := classifyApprovalRisk("item/fileChange/requestApproval", ""); got != "low" {
+		t.Errorf("file change risk = %s", got)
+	}
+}
+
+func TestApprovalRelayHeadlessPushAndRespond(t *testing.T) {
+	srv, pushes := newApprovalRelayTestServer(t)
+	result := make(chan bool, 1)
+	go func() {
+		result <- srv.awaitApprovalWithRelay("thread-1", "item/commandExecution/requestApproval",
+			map[string]any{"command": "rm -rf /tmp/build"}, func(context.Context) bool {
+				t.Error("client wait must not run without a frontend")
+				return false
+			})
+	}()
+
+	var push relayPush
+	select {
+	case push = <-pushes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for relay push")
+	}
+	if push.header.Get("Authorization") != "Bearer tok" || push.header.Get("Priority") != "urgent" {
+		t.Fatalf("push headers = %v", push.header)
+	}
+	if !strings.Contains(push.body, "thread-1") || !strings.Contains(push.body, "rm -rf /tmp/build") {
+		t.Fatalf("push body = %q", push.body)
+	}
+	actions := push.header.Get("Actions")
+	approve := actionURL(t, actions, "Approve")
+	if !strings.HasPrefix(approve, "https://agents.example.com/approval/respond?") {
+		t.Fatalf("approve url = %q", approve)
+	}
+
+	tampered := strings.Replace(approve, "decision=approve", "decision=deny", 1)
+	if rec := postDecision(srv, tampered); rec.Code != http.StatusForbidden {
+		t.Fatalf("tampered link status = %d", rec.Code)
+	}
+	if rec := postDecision(srv, approve); rec.Code != http.StatusOK {
+		t.Fatalf("approve status = %d body = %s", rec.Code, rec.Body.String())
+	}
+	select {
+	case approved := <-result:
+		if !approved {
+			t.Fatal("relay approval must approve")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for relayed decision")
+	}
+	if rec := postDecision(srv, actionURL(t, actions, "Deny")); rec.Code != http.StatusGone {
+		t.Fatalf("second decision status = %d", rec.Code)
+	}
+}
+
+func TestApprovalRelayRespondRejectsExpired(t *testing.T) {
+	srv, _ := newApprovalRelayTestServer(t)
+	srv.approvalRelay.add(&relayedApproval{ID: "req-1", decision: make(chan bool, 1)})
+	exp := time.Now().Add(-time.Minute).Unix()
+	if rec := postDecision(srv, srv.approvalDecisionURL("req-1", approvalDecisionApprove, exp)); rec.Code != http.StatusGone {
+		t.Fatalf("expired link status = %d", rec.Code)
+	}
+	rec := httptest.NewRecorder()
+	srv.handleApprovalRelayRespond(rec, httptest.NewRequest(http.MethodGet, "/approval/respond", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("GET status = %d", rec.Code)
+	}
+}
+
+func TestApprovalRelayDisabledFallsBackToClient(t *testing.T) {
+	srv := &Server{cfg: &config.Config{}}
+	called := false
+	approved := srv.awaitApprovalWithRelay("thread-1", "item/fileChange/requestApproval", map[string]any{}, func(context.Context) bool {
+		called = true
+		return true
+	})
+	if !called || !approved {
+		t.Fatalf("called = %v approved = %v", called, approved)
+	}
+}
+
+func TestApprovalRelayAnswerWithdrawsClientRequest(t *testing.T) {
+	srv, pushes := newApprovalRelayTestServer(t)
+	srv.pending = make(map[int64]pendingRequest)
+	asked := make(chan struct{}, 1)
+	resolved := make(chan map[string]any, 1)
+	srv.SetNotifyHook(func(method string, params any) {
+		switch method {
+		case "item/commandExecution/requestApproval":
+			asked <- struct{}{}
+		case "serverRequest/resolved":
+			resolved <- params.(map[string]any)
+		}
+	})
+
+	result := make(chan bool, 1)
+	go func() {
+		result <- srv.awaitApprovalWithRelay("thread-1", "item/commandExecution/requestApproval",
+			map[string]any{"command": "make deploy", "threadId": "thread-1"}, func(ctx context.Context) bool {
+				return srv.awaitClientApproval(ctx, "thread-1", "item/commandExecution/requestApproval",
+					map[string]any{"command": "make deploy", "threadId": "thread-1"})
+			})
+	}()
+	select {
+	case <-asked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the UI approval request")
+	}
+	var push relayPush
+	select {
+	case push = <-pushes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for relay push")
+	}
+	if rec := postDecision(srv, actionURL(t, push.header.Get("Actions"), "Deny")); rec.Code != http.StatusOK {
+		t.Fatalf("deny status = %d", rec.Code)
+	}
+	if approved := <-result; approved {
+		t.Fatal("relay denial must deny")
+	}
+
+	// 移动端先答复后, 前端对话框被撤下且 pending 请求被清理。
+	select {
+	case params := <-resolved:
+		if params["threadId"] != "thread-1" || params["requestId"] == nil {
+			t.Fatalf("resolved params = %#v", params)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for serverRequest/resolved")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		srv.pendingMu.Lock()
+		n := len(srv.pending)
+		srv.pendingMu.Unlock()
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("pending requests left: %d", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,389 @@
+// artifacts.go — 跨线程产物存储 (artifact/put, artifact/get, artifact/list)。
+//
+// 产物按内容寻址: ID 为 "sha256:<hex>", 以 JSON 信封 (元数据 + 内容) 存为共享文件
+// artifacts/<hex>; 相同内容重复写入时复用已有产物。读取时校验摘要, 防止经 shared_file_write 篡改。
+// turn/start、turn/steer 的 {"type": "artifact", "path": "sha256:<hex>"} 输入在提交前展开:
+// 文本产物内联为文件内容, 二进制产物落盘到 ~/.multi-agent/artifacts 后作为附件引用。
+package apiserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/multi-agent/go-agent-v2/internal/store"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+const (
+	artifactPathPrefix   = "artifacts/"
+	artifactIDPrefix     = "sha256:"
+	maxArtifactBytes     = 4 << 20
+	defaultArtifactLimit = 50
+	maxArtifactLimit     = 500
+	artifactEncodingUTF8 = "utf8"
+	artifactEncodingB64  = "base64"
+)
+
+// artifactFiles 产物持久化所需的共享文件操作 (由 *store.SharedFileStore 实现)。
+type artifactFiles interface {
+	Write(ctx context.Context, path, content, actor string) (*store.SharedFile, error)
+	Read(ctx context.Context, path string) (*store.SharedFile, error)
+	List(ctx context.Context, prefix string, limit int) ([]store.SharedFile, error)
+}
+
+// artifactMeta 产物元数据。
+type artifactMeta struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	MimeType  string    `json:"mimeType,omitempty"`
+	Size      int       `json:"size"`
+	ThreadID  string    `json:"threadId,omitempty"` // 产出线程
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// artifactEnvelope 共享文件中保存的内容。
+type artifactEnvelope struct {
+	artifactMeta
+	Encoding string `json:"encoding"` // utf8 / base64
+	Content  string `json:"content"`
+}
+
+func (e *artifactEnvelope) bytes() ([]byte, error) {
+	if e.Encoding == artifactEncodingB64 {
+		return base64.StdEncoding.DecodeString(e.Content)
+	}
+	return []byte(e.Content), nil
+}
+
+func artifactID(data []byte) string {
+	sum := sha256.Sum256(data)
+	return artifactIDPrefix + hex.EncodeToString(sum[:])
+}
+
+// artifactPath 校验 ID 并返回共享文件路径。
+func artifactPath(id string) (string, error) {
+	digest := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(id)), artifactIDPrefix)
+	if len(digest) != sha256.Size*2 {
+		return "", apperrors.NewCodef("Server.artifact", errcode.InvalidInput, "invalid artifact id %q", id)
+	}
+	if _, err := hex.DecodeString(digest); err != nil {
+		return "", apperrors.NewCodef("Server.artifact", errcode.InvalidInput, "invalid artifact id %q", id)
+	}
+	return artifactPathPrefix + digest, nil
+}
+
+func (s *Server) artifactFiles(op string) (artifactFiles, error) {
+	if s.artifactStore == nil {
+		return nil, apperrors.New(op, "artifact store requires database")
+	}
+	return s.artifactStore, nil
+}
+
+// putArtifact 写入产物 (内容相同则返回已有产物, created = false)。
+func (s *Server) putArtifact(ctx context.Context, name, mimeType, threadID string, data []byte) (artifactMeta, bool, error) {
+	const op = "Server.artifactPut"
+	files, err := s.artifactFiles(op)
+	if err != nil {
+		return artifactMeta{}, false, err
+	}
+	if len(data) > maxArtifactBytes {
+		return artifactMeta{}, false, apperrors.NewCodef(op, errcode.InvalidInput, "artifact exceeds %d bytes", maxArtifactBytes)
+	}
+	id := artifactID(data)
+	path, _ := artifactPath(id)
+	if existing, err := s.readArtifact(ctx, files, id); err == nil && existing != nil {
+		return existing.artifactMeta, false, nil
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = id
+	}
+	env := artifactEnvelope{
+		artifactMeta: artifactMeta{
+			ID:        id,
+			Name:      name,
+			MimeType:  strings.TrimSpace(mimeType),
+			Size:      len(data),
+			ThreadID:  strings.TrimSpace(threadID),
+			CreatedAt: time.Now().UTC(),
+		},
+		Encoding: artifactEncodingUTF8,
+		Content:  string(data),
+	}
+	if !utf8.Valid(data) {
+		env.Encoding = artifactEncodingB64
+		env.Content = base64.StdEncoding.EncodeToString(data)
+	}
+	raw, err := json.Marshal(env)
+	if err != nil {
+		return artifactMeta{}, false, apperrors.Wrap(err, op, "encode artifact")
+	}
+	actor := env.ThreadID
+	if actor == "" {
+		actor = "artifact"
+	}
+	if _, err := files.Write(ctx, path, string(raw), actor); err != nil {
+		return artifactMeta{}, false, apperrors.Wrap(err, op, "write artifact")
+	}
+	logger.Info("artifact: stored",
+		logger.FieldID, id,
+		logger.FieldName, name,
+		logger.FieldThreadID, env.ThreadID,
+		logger.FieldLen, len(data),
+	)
+	return env.artifactMeta, true, nil
+}
+
+// readArtifact 读取并校验产物; 不存在时返回 (nil, nil)。
+func (s *Server) readArtifact(ctx context.Context, files artifactFiles, id string) (*artifactEnvelope, error) {
+	const op = "Server.artifactGet"
+	path, err := artifactPath(id)
+	if err != nil {
+		return nil, err
+	}
+	file, err := files.Read(ctx, path)
+	if err != nil {
+		return nil, apperrors.Wrap(err, op, "read artifact")
+	}
+	if file == nil {
+		return nil, nil
+	}
+	var env artifactEnvelope
+	if err := json.Unmarshal([]byte(file.Content), &env); err != nil {
+		return nil, apperrors.Wrapf(err, op, "decode artifact %s", id)
+	}
+	data, err := env.bytes()
+	if err != nil {
+		return nil, apperrors.Wrapf(err, op, "decode artifact %s content", id)
+	}
+	if want := artifactIDPrefix + strings.TrimPrefix(path, artifactPathPrefix); artifactID(data) != want {
+		return nil, apperrors.Newf(op, "artifact %s failed digest check", id)
+	}
+	return &env, nil
+}
+
+// ========================================
+// 输入展开 (type=artifact)
+// ========================================
+
+// artifactCacheDir 二进制产物落盘目录。
+func artifactCacheDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", apperrors.Wrap(err, "Server.artifactCacheDir", "resolve user home")
+	}
+	dir := filepath.Join(homeDir, ".multi-agent", "artifacts")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", apperrors.Wrap(err, "Server.artifactCacheDir", "ensure cache dir")
+	}
+	return dir, nil
+}
+
+// resolveArtifactInputs 把 artifact 输入展开为 fileContent (文本) 或 mention (二进制落盘)。
+func (s *Server) resolveArtifactInputs(ctx context.Context, inputs []UserInput) ([]UserInput, error) {
+	const op = "Server.resolveArtifactInputs"
+	hasArtifact := false
+	for _, input := range inputs {
+		if strings.EqualFold(strings.TrimSpace(input.Type), "artifact") {
+			hasArtifact = true
+			break
+		}
+	}
+	if !hasArtifact {
+		return inputs, nil
+	}
+	files, err := s.artifactFiles(op)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]UserInput, 0, len(inputs))
+	for _, input := range inputs {
+		if !strings.EqualFold(strings.TrimSpace(input.Type), "artifact") {
+			out = append(out, input)
+			continue
+		}
+		id := strings.TrimSpace(input.Path)
+		env, err := s.readArtifact(ctx, files, id)
+		if err != nil {
+			return nil, err
+		}
+		if env == nil {
+			return nil, apperrors.NewCodef(op, errcode.NotFound, "artifact %s not found", id)
+		}
+		name := strings.TrimSpace(input.Name)
+		if name == "" {
+			name = env.Name
+		}
+		if env.Encoding != artifactEncodingB64 {
+			out = append(out, UserInput{Type: "fileContent", Name: name, Content: env.Content})
+			continue
+		}
+		dir, err := artifactCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		data, err := env.bytes()
+		if err != nil {
+			return nil, apperrors.Wrapf(err, op, "decode artifact %s", id)
+		}
+		path := filepath.Join(dir, strings.TrimPrefix(env.ID, artifactIDPrefix)+filepath.Ext(env.Name))
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return nil, apperrors.Wrapf(err, op, "materialize artifact %s", id)
+		}
+		out = append(out, UserInput{Type: "mention", Name: name, Path: path})
+	}
+	return out, nil
+}
+
+// ========================================
+// JSON-RPC
+// ========================================
+
+type artifactPutParams struct {
+	Name     string `json:"name"`
+	Content  string `json:"content"`
+	Encoding string `json:"encoding,omitempty"` // utf8 (默认) / base64
+	MimeType string `json:"mimeType,omitempty"`
+	ThreadID string `json:"threadId,omitempty"`
+}
+
+func decodeArtifactContent(op, content, encoding string) ([]byte, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", artifactEncodingUTF8:
+		return []byte(content), nil
+	case artifactEncodingB64:
+		data, err := base64.StdEncoding.DecodeString(content)
+		if err != nil {
+			return nil, apperrors.WrapCode(err, op, errcode.InvalidInput, "invalid base64 content")
+		}
+		return data, nil
+	default:
+		return nil, apperrors.NewCodef(op, errcode.InvalidInput, "unsupported encoding %q", encoding)
+	}
+}
+
+func (s *Server) artifactPutTyped(ctx context.Context, p artifactPutParams) (any, error) {
+	data, err := decodeArtifactContent("Server.artifactPut", p.Content, p.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	meta, created, err := s.putArtifact(ctx, p.Name, p.MimeType, p.ThreadID, data)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"artifact": meta, "created": created}, nil
+}
+
+type artifactGetParams struct {
+	ID       string `json:"id"`
+	MetaOnly bool   `json:"metaOnly,omitempty"`
+}
+
+func (s *Server) artifactGetTyped(ctx context.Context, p artifactGetParams) (any, error) {
+	const op = "Server.artifactGet"
+	files, err := s.artifactFiles(op)
+	if err != nil {
+		return nil, err
+	}
+	env, err := s.readArtifact(ctx, files, p.ID)
+	if err != nil {
+		return nil, err
+	}
+	if env == nil {
+		return nil, apperrors.NewCodef(op, errcode.NotFound, "artifact %s not found", p.ID)
+	}
+	result := map[string]any{"artifact": env.artifactMeta}
+	if !p.MetaOnly {
+		result["content"] = env.Content
+		result["encoding"] = env.Encoding
+	}
+	return result, nil
+}
+
+type artifactListParams struct {
+	ThreadID string `json:"threadId,omitempty"`
+	Limit    int    `json:"limit,omitempty"`
+}
+
+func (s *Server) artifactListTyped(ctx context.Context, p artifactListParams) (any, error) {
+	const op = "Server.artifactList"
+	files, err := s.artifactFiles(op)
+	if err != nil {
+		return nil, err
+	}
+	limit := p.Limit
+	if limit <= 0 {
+		limit = defaultArtifactLimit
+	}
+	limit = min(limit, maxArtifactLimit)
+	rows, err := files.List(ctx, artifactPathPrefix, maxArtifactLimit)
+	if err != nil {
+		return nil, apperrors.Wrap(err, op, "list artifacts")
+	}
+	threadID := strings.TrimSpace(p.ThreadID)
+	artifacts := make([]artifactMeta, 0, len(rows))
+	for _, row := range rows {
+		if !strings.HasPrefix(row.Path, artifactPathPrefix) {
+			continue
+		}
+		var env artifactEnvelope
+		if err := json.Unmarshal([]byte(row.Content), &env); err != nil {
+			continue
+		}
+		if threadID != "" && env.ThreadID != threadID {
+			continue
+		}
+		artifacts = append(artifacts, env.artifactMeta)
+	}
+	sort.SliceStable(artifacts, func(i, j int) bool { return artifacts[i].CreatedAt.After(artifacts[j].CreatedAt) })
+	if len(artifacts) > limit {
+		artifacts = artifacts[:limit]
+	}
+	return map[string]any{"artifacts": artifacts}, nil
+}
+
+// ========================================
+// 动态工具 (artifact_put / artifact_get)
+// ========================================
+
+// artifactPutFrom artifact_put 工具: 产出线程取调用方 agentID。
+func (s *Server) artifactPutFrom(agentID string, args json.RawMessage) string {
+	var p artifactPutParams
+	if err := json.Unmarshal(args, &p); err != nil {
+		return toolError(apperrors.Wrap(err, "ResourceTool.ArtifactPut", "invalid args"))
+	}
+	p.ThreadID = agentID
+	ctx, cancel := toolCtx()
+	defer cancel()
+	result, err := s.artifactPutTyped(ctx, p)
+	if err != nil {
+		return toolError(err)
+	}
+	return toolJSON(result)
+}
+
+func (s *Server) resourceArtifactGet(args json.RawMessage) string {
+	var p artifactGetParams
+	if err := json.Unmarshal(args, &p); err != nil {
+		return toolError(apperrors.Wrap(err, "ResourceTool.ArtifactGet", "invalid args"))
+	}
+	ctx, cancel := toolCtx()
+	defer cancel()
+	result, err := s.artifactGetTyped(ctx, p)
+	if err != nil {
+		return toolError(err)
+	}
+	return toolJSON(result)
+}
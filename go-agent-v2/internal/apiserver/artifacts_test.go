@@ -0,0 +1,147 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/multi-agent/go-agent-v2/internal/store"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+// memSharedFiles 内存版共享文件 (替代 SharedFileStore)。
+type memSharedFiles struct {
+	mu    sync.Mutex
+	files map[string]store.SharedFile
+}
+
+func (m *memSharedFiles) Write(_ context.Context, path, content, actor string) (*store.SharedFile, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.files == nil {
+		m.files = map[string]store.SharedFile{}
+	}
+	file := store.SharedFile{Path: path, Content: content, UpdatedBy: actor}
+	m.files[path] = file
+	return &file, nil
+}
+
+func (m *memSharedFiles) Read(_ context.Context, path string) (*store.SharedFile, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	file, ok := m.files[path]
+	if !ok {
+		return nil, nil
+	}
+	return &file, nil
+}
+
+func (m *memSharedFiles) List(_ context.Context, prefix string, _ int) ([]store.SharedFile, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []store.SharedFile
+	for path, file := range m.files {
+		if strings.Contains(path, prefix) {
+			out = append(out, file)
+		}
+	}
+	return out, nil
+}
+
+func TestArtifactPutGetListDedup(t *testing.T) {
+	files := &memSharedFiles{}
+	srv := &Server{artifactStore: files}
+	ctx := context.Background()
+
+	res, err := srv.artifactPutTyped(ctx, artifactPutParams{Name: "report.md", Content: "# ok", ThreadID: "thread-a"})
+	if err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	meta := res.(map[string]any)["artifact"].(artifactMeta)
+	if !strings.HasPrefix(meta.ID, artifactIDPrefix) || meta.Size != 4 || !res.(map[string]any)["created"].(bool) {
+		t.Fatalf("put result = %+v", res)
+	}
+	again, err := srv.artifactPutTyped(ctx, artifactPutParams{Name: "other.md", Content: "# ok", ThreadID: "thread-b"})
+	if err != nil || again.(map[string]any)["created"].(bool) || again.(map[string]any)["artifact"].(artifactMeta).Name != "report.md" {
+		t.Fatalf("dedup put = %+v, %v", again, err)
+	}
+	if _, err := srv.artifactPutTyped(ctx, artifactPutParams{Name: "blob.bin", Content: base64.StdEncoding.EncodeToString([]byte{0xff, 0x00}), Encoding: "base64"}); err != nil {
+		t.Fatalf("put binary: %v", err)
+	}
+
+	got, err := srv.artifactGetTyped(ctx, artifactGetParams{ID: meta.ID})
+	if err != nil || got.(map[string]any)["content"] != "# ok" {
+		t.Fatalf("get = %+v, %v", got, err)
+	}
+	if _, err := srv.artifactGetTyped(ctx, artifactGetParams{ID: "sha256:" + strings.Repeat("0", 64)}); apperrors.CodeOf(err) != errcode.NotFound {
+		t.Fatalf("missing err = %v", err)
+	}
+	if _, err := srv.artifactGetTyped(ctx, artifactGetParams{ID: "../etc/passwd"}); apperrors.CodeOf(err) != errcode.InvalidInput {
+		t.Fatalf("invalid id err = %v", err)
+	}
+
+	list, err := srv.artifactListTyped(ctx, artifactListParams{ThreadID: "thread-a"})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if artifacts := list.(map[string]any)["artifacts"].([]artifactMeta); len(artifacts) != 1 || artifacts[0].ID != meta.ID {
+		t.Fatalf("list = %+v", artifacts)
+	}
+
+	// 经 shared_file_write 篡改后摘要校验失败
+	path, _ := artifactPath(meta.ID)
+	tampered := strings.Replace(files.files[path].Content, "# ok", "# no", 1)
+	if _, err := files.Write(ctx, path, tampered, "agent"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.artifactGetTyped(ctx, artifactGetParams{ID: meta.ID}); err == nil || !strings.Contains(err.Error(), "digest") {
+		t.Fatalf("tampered get err = %v", err)
+	}
+}
+
+func TestResolveArtifactInputs(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	srv := &Server{artifactStore: &memSharedFiles{}}
+	ctx := context.Background()
+	text, _, err := srv.putArtifact(ctx, "build.log", "", "thread-a", []byte("PASS"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary, _, err := srv.putArtifact(ctx, "shot.png", "image/png", "thread-a", []byte{0x89, 'P', 'N', 'G', 0xff})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inputs, err := srv.resolveArtifactInputs(ctx, []UserInput{
+		{Type: "text", Text: "check this"},
+		{Type: "artifact", Path: text.ID},
+		{Type: "artifact", Path: binary.ID},
+	})
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if len(inputs) != 3 || inputs[1].Type != "fileContent" || inputs[1].Name != "build.log" || inputs[1].Content != "PASS" {
+		t.Fatalf("inputs = %+v", inputs)
+	}
+	if inputs[2].Type != "mention" || !strings.HasSuffix(inputs[2].Path, ".png") {
+		t.Fatalf("binary input = %+v", inputs[2])
+	}
+	if data, err := os.ReadFile(inputs[2].Path); err != nil || string(data) != "\x89PNG\xff" {
+		t.Fatalf("materialized = %q, %v", data, err)
+	}
+	prompt, _, files := extractInputs(inputs)
+	if !strings.Contains(prompt, "[file:build.log]\nPASS") || len(files) != 1 {
+		t.Fatalf("prompt = %q files = %v", prompt, files)
+	}
+
+	if _, err := srv.resolveArtifactInputs(ctx, []UserInput{{Type: "artifact", Path: "sha256:" + strings.Repeat("a", 64)}}); apperrors.CodeOf(err) != errcode.NotFound {
+		t.Fatalf("missing artifact err = %v", err)
+	}
+	if _, err := (&Server{}).resolveArtifactInputs(ctx, []UserInput{{Type: "text", Text: "x"}}); err != nil {
+		t.Fatalf("no artifacts without store: %v", err)
+	}
+}
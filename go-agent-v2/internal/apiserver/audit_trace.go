@@ -0,0 +1,265 @@
+// audit_trace.go — 请求审计链: 关联 ID 与 audit/trace/get。
+//
+// dispatchRequest 为每个 JSON-RPC 调用创建 correlation.Trail, 响应携带 correlationId;
+// 请求路径上的 codex 调用 (traceCodex) 与存储写入 (database 包的 pgx tracer) 经 context 记入。
+// turn/start 提交成功后线程绑定到该 Trail, 之后该线程的通知与 turn 结束一并记入, turn 结束即解绑。
+// 最近 auditTrailCap 条 Trail 保留在内存; 有副作用或出错的 Trail 结束时以 trace_id = 关联 ID
+// 写一条系统日志 (event_type = audit), 内存淘汰后 audit/trace/get 从 system_logs 还原。
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/store"
+	"github.com/multi-agent/go-agent-v2/pkg/correlation"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+const (
+	auditTrailCap       = 512
+	auditTraceEventType = "audit"
+)
+
+// auditTrailRegistry 内存中的 Trail 与线程 → 进行中 turn 所属 Trail 的绑定。
+type auditTrailRegistry struct {
+	mu     sync.Mutex
+	trails map[string]*correlation.Trail
+	order  []string
+	bound  map[string]*correlation.Trail
+}
+
+func (r *auditTrailRegistry) remember(t *correlation.Trail) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.trails == nil {
+		r.trails = make(map[string]*correlation.Trail)
+	}
+	r.trails[t.ID] = t
+	r.order = append(r.order, t.ID)
+	for len(r.order) > auditTrailCap {
+		delete(r.trails, r.order[0])
+		r.order = r.order[1:]
+	}
+}
+
+func (r *auditTrailRegistry) get(id string) *correlation.Trail {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.trails[id]
+}
+
+// bind 把线程后续的通知归入 t (同线程的新 turn 覆盖旧绑定)。
+func (r *auditTrailRegistry) bind(threadID string, t *correlation.Trail) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.bound == nil {
+		r.bound = make(map[string]*correlation.Trail)
+	}
+	r.bound[threadID] = t
+}
+
+func (r *auditTrailRegistry) boundTo(threadID string) *correlation.Trail {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.bound[threadID]
+}
+
+func (r *auditTrailRegistry) unbind(threadID string) *correlation.Trail {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t := r.bound[threadID]
+	delete(r.bound, threadID)
+	return t
+}
+
+// isBound Trail 是否仍有进行中的 turn (结束时再落盘)。
+func (r *auditTrailRegistry) isBound(t *correlation.Trail) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, bound := range r.bound {
+		if bound == t {
+			return true
+		}
+	}
+	return false
+}
+
+// beginAuditTrail 为方法调用创建 Trail 并绑定到 context。
+func (s *Server) beginAuditTrail(ctx context.Context, method string) (context.Context, *correlation.Trail) {
+	trail := correlation.New(method)
+	trail.Add(correlation.Event{Kind: correlation.KindRequest, Name: method})
+	s.auditTrails.remember(trail)
+	return correlation.WithTrail(ctx, trail), trail
+}
+
+// finishAuditTrail 记录响应; 无进行中 turn 时立即落盘。
+func (s *Server) finishAuditTrail(trail *correlation.Trail, err error) {
+	ev := correlation.Event{
+		Kind:       correlation.KindResponse,
+		Name:       trail.Method,
+		Status:     "ok",
+		DurationMS: time.Since(trail.StartedAt).Milliseconds(),
+	}
+	if err != nil {
+		ev.Status, ev.Error = "error", err.Error()
+	}
+	trail.Add(ev)
+	if !s.auditTrails.isBound(trail) {
+		persistAuditTrail(trail)
+	}
+}
+
+// bindAuditTurn turn 提交成功后把线程绑定到请求的 Trail。
+func (s *Server) bindAuditTurn(ctx context.Context, threadID, turnID string) {
+	trail := correlation.FromContext(ctx)
+	if trail == nil {
+		return
+	}
+	trail.Add(correlation.Event{Kind: correlation.KindTurn, Name: "turn/started", ThreadID: threadID, TurnID: turnID, Status: "inProgress"})
+	s.auditTrails.bind(threadID, trail)
+}
+
+// finishAuditTurn turn 结束: 记入绑定的 Trail, 解绑并落盘。
+func (s *Server) finishAuditTurn(threadID, turnID, status, reason string, duration time.Duration) {
+	trail := s.auditTrails.unbind(threadID)
+	if trail == nil {
+		return
+	}
+	trail.Add(correlation.Event{
+		Kind:       correlation.KindTurn,
+		Name:       "turn/completed",
+		ThreadID:   threadID,
+		TurnID:     turnID,
+		Status:     status,
+		Detail:     reason,
+		DurationMS: duration.Milliseconds(),
+	})
+	persistAuditTrail(trail)
+}
+
+// recordAuditNotification 把线程通知记入其进行中 turn 所属的 Trail。
+func (s *Server) recordAuditNotification(method string, payload map[string]any) {
+	threadID := notificationThreadID(payload)
+	if threadID == "" {
+		return
+	}
+	trail := s.auditTrails.boundTo(threadID)
+	if trail == nil {
+		return
+	}
+	turnID, _ := payload["turnId"].(string)
+	trail.Add(correlation.Event{Kind: correlation.KindNotification, Name: method, ThreadID: threadID, TurnID: turnID})
+}
+
+// traceCodex 执行一次 codex 调用并记入请求的 Trail。
+func traceCodex(ctx context.Context, threadID, name string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	ev := correlation.Event{
+		Kind:       correlation.KindCodex,
+		Name:       name,
+		ThreadID:   threadID,
+		Status:     "ok",
+		DurationMS: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		ev.Status, ev.Error = "failed", err.Error()
+	}
+	correlation.Record(ctx, ev)
+	return err
+}
+
+// persistAuditTrail 有副作用或出错的 Trail 写入系统日志 (trace_id = 关联 ID)。
+func persistAuditTrail(trail *correlation.Trail) {
+	events, dropped := trail.Events()
+	failed := false
+	for _, ev := range events {
+		if ev.Error != "" {
+			failed = true
+			break
+		}
+	}
+	if !failed && !trail.HasSideEffects() {
+		return
+	}
+	logger.Info("audit: request trace",
+		logger.FieldTraceID, trail.ID,
+		logger.FieldMethod, trail.Method,
+		logger.FieldEventType, auditTraceEventType,
+		logger.FieldCount, len(events),
+		"dropped", dropped,
+		"events", events,
+	)
+}
+
+type auditTraceGetParams struct {
+	CorrelationID string `json:"correlationId"`
+}
+
+type auditTraceResponse struct {
+	CorrelationID string              `json:"correlationId"`
+	Method        string              `json:"method"`
+	StartedAt     time.Time           `json:"startedAt"`
+	Source        string              `json:"source"` // memory | log
+	Events        []correlation.Event `json:"events"`
+	Dropped       int                 `json:"dropped,omitempty"`
+}
+
+// auditTraceGetTyped audit/trace/get: 按关联 ID 还原请求的因果链。
+func (s *Server) auditTraceGetTyped(ctx context.Context, p auditTraceGetParams) (any, error) {
+	const op = "Server.auditTraceGet"
+	id := strings.TrimSpace(p.CorrelationID)
+	if id == "" {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "correlationId is required")
+	}
+	if trail := s.auditTrails.get(id); trail != nil {
+		events, dropped := trail.Events()
+		return auditTraceResponse{
+			CorrelationID: trail.ID,
+			Method:        trail.Method,
+			StartedAt:     trail.StartedAt,
+			Source:        "memory",
+			Events:        events,
+			Dropped:       dropped,
+		}, nil
+	}
+	if s.sysLogStore == nil {
+		return nil, apperrors.NewCodef(op, errcode.NotFound, "trace %s not found", id)
+	}
+	rows, err := s.sysLogStore.ListV2(ctx, store.ListParams{TraceID: id, EventType: auditTraceEventType, Limit: 1})
+	if err != nil {
+		return nil, apperrors.Wrap(err, op, "query system logs")
+	}
+	if len(rows) == 0 {
+		return nil, apperrors.NewCodef(op, errcode.NotFound, "trace %s not found", id)
+	}
+	return auditTraceFromLog(rows[0])
+}
+
+// auditTraceFromLog 从落盘的系统日志还原 Trail。
+func auditTraceFromLog(row store.SystemLog) (auditTraceResponse, error) {
+	resp := auditTraceResponse{CorrelationID: row.TraceID, StartedAt: row.Ts, Source: "log"}
+	var extra struct {
+		Method  string              `json:"method"`
+		Dropped int                 `json:"dropped"`
+		Events  []correlation.Event `json:"events"`
+	}
+	raw, err := json.Marshal(row.Extra)
+	if err == nil {
+		err = json.Unmarshal(raw, &extra)
+	}
+	if err != nil {
+		return resp, apperrors.Wrap(err, "Server.auditTraceGet", "decode trace events")
+	}
+	resp.Method, resp.Dropped, resp.Events = extra.Method, extra.Dropped, extra.Events
+	if len(resp.Events) > 0 {
+		resp.StartedAt = resp.Events[0].Ts
+	}
+	return resp, nil
+}
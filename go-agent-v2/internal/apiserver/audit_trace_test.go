@@ -0,0 +1,84 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/codex"
+	"github.com/multi-agent/go-agent-v2/internal/runner"
+	"github.com/multi-agent/go-agent-v2/pkg/correlation"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+func TestAuditTrace_TurnStartCausalChain(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	mgr := runner.NewAgentManager()
+	mgr.SetClientFactory(codex.MockClientFactory(codex.DefaultMockScript, 0))
+	srv := New(Deps{Manager: mgr})
+	mgr.SetOnEvent(func(agentID string, event codex.Event) {
+		srv.AgentEventHandler(agentID)(event)
+	})
+
+	ctx := context.Background()
+	res, err := srv.InvokeMethod(ctx, "thread/start", json.RawMessage(`{"cwd":"."}`))
+	if err != nil {
+		t.Fatalf("thread/start: %v", err)
+	}
+	threadID := res.(threadStartResponse).Thread.ID
+	params, _ := json.Marshal(map[string]any{
+		"threadId": threadID,
+		"input":    []map[string]any{{"type": "text", "text": "hello"}},
+	})
+	resp := srv.dispatchRequest(ctx, 7, "turn/start", params)
+	if resp.Error != nil || resp.CorrelationID == "" {
+		t.Fatalf("turn/start = %#v", resp)
+	}
+
+	var trace auditTraceResponse
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got, err := srv.InvokeMethod(ctx, "audit/trace/get", json.RawMessage(`{"correlationId":"`+resp.CorrelationID+`"}`))
+		if err != nil {
+			t.Fatalf("audit/trace/get: %v", err)
+		}
+		trace = got.(auditTraceResponse)
+		if last := trace.Events[len(trace.Events)-1]; last.Kind == correlation.KindTurn && last.Name == "turn/completed" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("turn did not complete: %#v", trace.Events)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	kinds := map[string]int{}
+	for _, ev := range trace.Events {
+		kinds[ev.Kind]++
+		if ev.Kind == correlation.KindCodex && (ev.Name != "submit" || ev.ThreadID != threadID) {
+			t.Errorf("codex event = %#v", ev)
+		}
+	}
+	if trace.Method != "turn/start" || trace.Source != "memory" || trace.Events[0].Kind != correlation.KindRequest {
+		t.Fatalf("trace = %#v", trace)
+	}
+	if kinds[correlation.KindCodex] != 1 || kinds[correlation.KindResponse] != 1 || kinds[correlation.KindNotification] == 0 || kinds[correlation.KindTurn] != 2 {
+		t.Fatalf("event kinds = %v (%#v)", kinds, trace.Events)
+	}
+	if srv.auditTrails.boundTo(threadID) != nil {
+		t.Fatal("thread should be unbound after turn completion")
+	}
+}
+
+func TestAuditTrace_UnknownID(t *testing.T) {
+	srv := New(Deps{})
+	_, err := srv.auditTraceGetTyped(context.Background(), auditTraceGetParams{CorrelationID: "req-0-0"})
+	if apperrors.CodeOf(err) != errcode.NotFound {
+		t.Fatalf("err = %v", err)
+	}
+	if _, err := srv.auditTraceGetTyped(context.Background(), auditTraceGetParams{}); apperrors.CodeOf(err) != errcode.InvalidInput {
+		t.Fatalf("empty id err = %v", err)
+	}
+}
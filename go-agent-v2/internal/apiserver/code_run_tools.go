@@ -238,6 +238,7 @@ func (s *Server) setAgentWorkDir(agentID, cwd string) {
 	}
 	s.agentWorkDirs[id] = normalized
 	s.agentWorkDirMu.Unlock()
+	s.watchThreadWorkspace(id, normalized)
 }
 
 func (s *Server) getAgentWorkDir(agentID string) string {
@@ -259,6 +260,7 @@ func (s *Server) clearAgentWorkDir(agentID string) {
 	s.agentWorkDirMu.Lock()
 	delete(s.agentWorkDirs, id)
 	s.agentWorkDirMu.Unlock()
+	s.fileWatch.detach(id)
 }
 
 // resolveCodeRunner 按 agent 默认 cwd 选择 runner。
@@ -412,8 +414,10 @@ func (s *Server) awaitCodeRunApproval(agentID, callID, mode, command string, isD
 		"is_dangerous": isDangerous,
 	}
 
-	// 双通道等待
-	return s.waitForFrontendDecision(method, payload)
+	// 双通道等待 (配置推送中继时并行转发到移动端)
+	return s.awaitApprovalWithRelay(agentID, method, payload, func(ctx context.Context) bool {
+		return s.waitForFrontendDecision(ctx, method, payload)
+	})
 }
 
 // waitForFrontendDecision 抽取双通道等待逻辑 (WebSocket → Wails → fail-close)。
@@ -421,10 +425,10 @@ func (s *Server) awaitCodeRunApproval(agentID, callID, mode, command string, isD
 // 共享于 handleApprovalRequest 和 awaitCodeRunApproval:
 //   - 优先 WebSocket SendRequestToAll
 //   - 降级 AllocPendingRequest + broadcastNotification
-//   - 超时/无前端 → false (fail-close)
-func (s *Server) waitForFrontendDecision(method string, payload map[string]any) bool {
+//   - 超时/无前端/ctx 取消 → false (fail-close)
+func (s *Server) waitForFrontendDecision(ctx context.Context, method string, payload map[string]any) bool {
 	// 尝试 WebSocket
-	resp, wsErr := s.SendRequestToAll(method, payload)
+	resp, wsErr := s.SendRequestToAll(ctx, method, payload)
 	if wsErr == nil && resp != nil && resp.Result != nil {
 		if m, ok := resp.Result.(map[string]any); ok {
 			if approved, ok := m["approved"].(bool); ok {
@@ -442,6 +446,9 @@ func (s *Server) waitForFrontendDecision(method string, payload map[string]any)
 		logger.Warn("code-run: approval auto-denied — no frontend", "method", method)
 		return false
 	}
+	if ctx.Err() != nil {
+		return false
+	}
 
 	reqID, ch, cleanup := s.AllocPendingRequest()
 	defer cleanup()
@@ -467,6 +474,8 @@ func (s *Server) waitForFrontendDecision(method string, payload map[string]any)
 		}
 	case <-timer.C:
 		logger.Warn("code-run: approval timed out", "method", method)
+	case <-ctx.Done():
+		s.notifyServerRequestResolved(reqID, method, payload)
 	}
 	return false
 }
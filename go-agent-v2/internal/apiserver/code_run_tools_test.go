@@ -24,7 +24,7 @@ func TestCodeRunWithAgent_ProjectCmdSafeCommandNoApproval(t *testing.T) {
 	s := &Server{
 		codeRunner: r,
 		conns:      map[string]*connEntry{},
-		pending:    make(map[int64]chan *Response),
+		pending:    make(map[int64]pendingRequest),
 	}
 
 	args := json.RawMessage(`{"mode":"project_cmd","command":"echo hello"}`)
@@ -47,7 +47,7 @@ func TestCodeRunWithAgent_ProjectCmdDangerousDeniedWithoutFrontend(t *testing.T)
 	s := &Server{
 		codeRunner: r,
 		conns:      map[string]*connEntry{},
-		pending:    make(map[int64]chan *Response),
+		pending:    make(map[int64]pendingRequest),
 	}
 
 	args := json.RawMessage(`{"mode":"project_cmd","command":"rm -rf /tmp/unsafe"}`)
@@ -67,7 +67,7 @@ func TestCodeRunWithAgent_GoRun_DefaultAutoWrap(t *testing.T) {
 	s := &Server{
 		codeRunner: r,
 		conns:      map[string]*connEntry{},
-		pending:    make(map[int64]chan *Response),
+		pending:    make(map[int64]pendingRequest),
 	}
 
 	args := json.RawMessage(`{"mode":"run","language":"go","code":"fmt.Println(\"hello-from-code-run\")"}`)
@@ -90,7 +90,7 @@ func TestCodeRunTestWithAgent_EmptyTestFuncReturnsError(t *testing.T) {
 	s := &Server{
 		codeRunner: r,
 		conns:      map[string]*connEntry{},
-		pending:    make(map[int64]chan *Response),
+		pending:    make(map[int64]pendingRequest),
 	}
 
 	resp := s.codeRunTestWithAgent(context.Background(), "agent-1", "call-3", json.RawMessage(`{}`))
@@ -102,9 +102,9 @@ func TestCodeRunTestWithAgent_EmptyTestFuncReturnsError(t *testing.T) {
 func TestWaitForFrontendDecision_NoFrontendFailClose(t *testing.T) {
 	s := &Server{
 		conns:   map[string]*connEntry{},
-		pending: make(map[int64]chan *Response),
+		pending: make(map[int64]pendingRequest),
 	}
-	if ok := s.waitForFrontendDecision("item/commandExecution/requestApproval", map[string]any{"x": 1}); ok {
+	if ok := s.waitForFrontendDecision(context.Background(), "item/commandExecution/requestApproval", map[string]any{"x": 1}); ok {
 		t.Fatal("expected fail-close false when no websocket and no notifyHook")
 	}
 }
@@ -140,7 +140,7 @@ func TestCodeRunWithAgent_UsesAgentWorkDirByDefault(t *testing.T) {
 	s := &Server{
 		codeRunner:     r,
 		conns:          map[string]*connEntry{},
-		pending:        make(map[int64]chan *Response),
+		pending:        make(map[int64]pendingRequest),
 		activeCodeRuns: make(map[string]map[string]context.CancelFunc),
 		agentWorkDirs:  make(map[string]string),
 	}
@@ -0,0 +1,96 @@
+// codex_binary.go — 启动时 codex 可执行文件发现与版本校验 (codex/binary/status)。
+//
+// ListenAndServe 启动后台循环前解析一次 (见 codex.ResolveBinary), 结果经 codex.SetBinary 供 Spawn 使用。
+// 找不到或版本不满足 CODEX_MIN_VERSION / CODEX_MAX_VERSION 时记 Warn 并推送 codex/binary/status;
+// 启动时尚无客户端连接, 因此 initialize 响应同样携带状态, 晚连接的 UI 也能提示版本不匹配。
+package apiserver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/multi-agent/go-agent-v2/internal/codex"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+// codexBinaryState 最近一次解析结果。
+type codexBinaryState struct {
+	mu      sync.Mutex
+	status  codex.BinaryStatus
+	checked bool
+}
+
+func (st *codexBinaryState) get() (codex.BinaryStatus, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.status, st.checked
+}
+
+func (st *codexBinaryState) set(status codex.BinaryStatus) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.status, st.checked = status, true
+}
+
+func (s *Server) codexBinaryOptions() codex.BinaryOptions {
+	var opts codex.BinaryOptions
+	if s.cfg != nil {
+		opts = codex.BinaryOptions{
+			Path:        s.cfg.CodexBin,
+			MinVersion:  s.cfg.CodexMinVersion,
+			MaxVersion:  s.cfg.CodexMaxVersion,
+			DownloadURL: s.cfg.CodexDownloadURL,
+			DownloadDir: strings.TrimSpace(s.cfg.CodexDownloadDir),
+		}
+	}
+	if opts.DownloadDir == "" {
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			opts.DownloadDir = filepath.Join(homeDir, ".multi-agent", "bin")
+		}
+	}
+	return opts
+}
+
+// checkCodexBinary 解析 codex 可执行文件并校验版本; 有问题时推送 codex/binary/status。
+func (s *Server) checkCodexBinary(ctx context.Context) codex.BinaryStatus {
+	status, err := codex.ResolveBinary(ctx, s.codexBinaryOptions())
+	if err == nil {
+		codex.SetBinary(status.Path)
+	}
+	s.codexBinary.set(status)
+
+	if status.Compatible {
+		logger.Info("codex binary: resolved",
+			logger.FieldPath, status.Path,
+			logger.FieldSource, status.Source,
+			logger.FieldVersion, status.Version,
+		)
+		return status
+	}
+	logger.Warn("codex binary: unusable or unsupported version",
+		logger.FieldPath, status.Path,
+		logger.FieldSource, status.Source,
+		logger.FieldVersion, status.Version,
+		"min_version", status.MinVersion,
+		"max_version", status.MaxVersion,
+		logger.FieldError, status.Problem,
+	)
+	s.Notify("codex/binary/status", status)
+	return status
+}
+
+// codexBinaryStatusParams codex/binary/status 请求参数。
+type codexBinaryStatusParams struct {
+	Recheck bool `json:"recheck,omitempty"` // 重新发现与校验 (安装或升级 codex 后)
+}
+
+// codexBinaryStatusTyped 返回 codex 可执行文件与版本校验结果 (JSON-RPC: codex/binary/status)。
+func (s *Server) codexBinaryStatusTyped(ctx context.Context, p codexBinaryStatusParams) (any, error) {
+	if status, ok := s.codexBinary.get(); ok && !p.Recheck {
+		return status, nil
+	}
+	return s.checkCodexBinary(ctx), nil
+}
@@ -0,0 +1,63 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/multi-agent/go-agent-v2/internal/codex"
+	"github.com/multi-agent/go-agent-v2/internal/config"
+)
+
+func TestCheckCodexBinaryReportsVersionMismatch(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script fake")
+	}
+	t.Setenv("HOME", t.TempDir())
+	defer codex.SetBinary("")
+	bin := filepath.Join(t.TempDir(), "codex")
+	if err := os.WriteFile(bin, []byte("#!/bin/sh\necho 'codex-cli 0.30.0'\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	srv := New(Deps{Config: &config.Config{CodexBin: bin, CodexMinVersion: "0.40.0"}})
+	var notified []map[string]any
+	srv.SetNotifyHook(func(method string, params any) {
+		if method == "codex/binary/status" {
+			notified = append(notified, params.(map[string]any))
+		}
+	})
+
+	status := srv.checkCodexBinary(context.Background())
+	if status.Compatible || status.Version != "0.30.0" || codex.Binary() != bin {
+		t.Fatalf("status = %+v, binary = %q", status, codex.Binary())
+	}
+	if len(notified) != 1 || notified[0]["compatible"] != false || notified[0]["minVersion"] != "0.40.0" {
+		t.Fatalf("notifications = %+v", notified)
+	}
+
+	raw, err := srv.InvokeMethod(context.Background(), "initialize", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := raw.(map[string]any)["codexBinary"].(codex.BinaryStatus); !ok || got.Problem == "" {
+		t.Fatalf("initialize codexBinary = %+v", raw.(map[string]any)["codexBinary"])
+	}
+
+	if err := os.WriteFile(bin, []byte("#!/bin/sh\necho 'codex-cli 0.41.0'\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	raw, err = srv.InvokeMethod(context.Background(), "codex/binary/status", nil)
+	if err != nil || raw.(codex.BinaryStatus).Version != "0.30.0" {
+		t.Fatalf("cached status = %+v, %v", raw, err)
+	}
+	raw, err = srv.InvokeMethod(context.Background(), "codex/binary/status", json.RawMessage(`{"recheck":true}`))
+	if err != nil || !raw.(codex.BinaryStatus).Compatible {
+		t.Fatalf("recheck status = %+v, %v", raw, err)
+	}
+	if len(notified) != 1 {
+		t.Fatalf("compatible recheck should not notify, got %d", len(notified))
+	}
+}
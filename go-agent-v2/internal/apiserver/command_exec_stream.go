@@ -0,0 +1,259 @@
+// command_exec_stream.go — command/exec/stream 流式执行: stdout/stderr 分块推送 + command/exec/cancel 取消。
+//
+// command/exec 缓冲输出并在结束时一次性返回; 长时间构建 / 测试改用流式变体:
+// 请求立即返回 execId, 输出以 command/exec/output 通知分块推送 (按 execId + seq 排序),
+// 进程结束时推送 command/exec/exited。沙箱校验与 command/exec 相同。
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+const (
+	maxExecStreams           = 16
+	maxExecStreamTimeoutSec  = 3600
+	maxExecStreamChunkBytes  = 16 << 10
+	maxExecStreamOutputBytes = 64 << 20 // 单次执行推送上限, 超出后丢弃并标记 truncated
+	execStreamStopGrace      = 5 * time.Second
+)
+
+// execStream 运行中的流式命令。
+type execStream struct {
+	ID        string    `json:"execId"`
+	ThreadID  string    `json:"threadId,omitempty"`
+	Command   string    `json:"command"`
+	Sandbox   string    `json:"sandbox"`
+	StartedAt time.Time `json:"startedAt"`
+
+	cancel   context.CancelFunc
+	canceled atomic.Bool
+	seq      atomic.Int64
+	sent     atomic.Int64
+	dropped  atomic.Bool
+}
+
+// execStreamHub 流式命令登记 (零值可用)。
+type execStreamHub struct {
+	mu      sync.Mutex
+	seq     int64
+	streams map[string]*execStream // execId →
+}
+
+func (h *execStreamHub) register(stream *execStream) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.streams == nil {
+		h.streams = make(map[string]*execStream)
+	}
+	if len(h.streams) >= maxExecStreams {
+		return apperrors.Newf("Server.commandExecStream", "too many running streams (max %d)", maxExecStreams)
+	}
+	h.seq++
+	stream.ID = fmt.Sprintf("exec-%d-%d", stream.StartedAt.UnixMilli(), h.seq)
+	h.streams[stream.ID] = stream
+	return nil
+}
+
+func (h *execStreamHub) remove(id string) {
+	h.mu.Lock()
+	delete(h.streams, id)
+	h.mu.Unlock()
+}
+
+func (h *execStreamHub) get(id string) *execStream {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.streams[id]
+}
+
+func (h *execStreamHub) list() []*execStream {
+	h.mu.Lock()
+	out := make([]*execStream, 0, len(h.streams))
+	for _, stream := range h.streams {
+		out = append(out, stream)
+	}
+	h.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].StartedAt.Before(out[j].StartedAt) })
+	return out
+}
+
+// execStreamWriter 将 stdout / stderr 写入转为 command/exec/output 通知。
+type execStreamWriter struct {
+	stream *execStream
+	name   string // stdout / stderr
+	emit   func(method string, params any)
+}
+
+func (w *execStreamWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		size := min(len(p), maxExecStreamChunkBytes)
+		if w.stream.sent.Add(int64(size)) > maxExecStreamOutputBytes {
+			w.stream.dropped.Store(true)
+			return n, nil // 超出上限: 丢弃但不中断进程
+		}
+		payload := map[string]any{
+			"execId": w.stream.ID,
+			"stream": w.name,
+			"seq":    w.stream.seq.Add(1),
+			"data":   strings.ToValidUTF8(string(p[:size]), "�"),
+		}
+		if w.stream.ThreadID != "" {
+			payload["threadId"] = w.stream.ThreadID
+		}
+		w.emit("command/exec/output", payload)
+		p = p[size:]
+	}
+	return n, nil
+}
+
+// ========================================
+// command/exec/stream, command/exec/cancel, command/exec/list
+// ========================================
+
+type commandExecStreamParams struct {
+	commandExecParams
+	TimeoutSec int `json:"timeoutSec,omitempty"` // 0 = 沙箱配置的超时
+}
+
+func (s *Server) commandExecStreamTyped(ctx context.Context, p commandExecStreamParams) (any, error) {
+	profile, err := s.resolveSandboxProfile(ctx, p.ThreadID)
+	if err != nil {
+		return nil, err
+	}
+	if p.TimeoutSec < 0 || p.TimeoutSec > maxExecStreamTimeoutSec {
+		return nil, apperrors.Newf("Server.commandExecStream", "timeoutSec must be within [0, %d]", maxExecStreamTimeoutSec)
+	}
+	timeout := profile.timeout()
+	if p.TimeoutSec > 0 {
+		timeout = time.Duration(p.TimeoutSec) * time.Second
+	}
+
+	// 进程生命周期独立于本次请求, 由超时或 command/exec/cancel 结束。
+	execCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	cmd, err := s.buildSandboxedCommand(execCtx, profile, p.commandExecParams)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	stream := &execStream{
+		ThreadID:  p.ThreadID,
+		Command:   filepath.Base(p.Argv[0]),
+		Sandbox:   profile.Name,
+		StartedAt: time.Now(),
+		cancel:    cancel,
+	}
+	if err := s.execStreams.register(stream); err != nil {
+		cancel()
+		return nil, err
+	}
+	cmd.Stdout = &execStreamWriter{stream: stream, name: "stdout", emit: s.Notify}
+	cmd.Stderr = &execStreamWriter{stream: stream, name: "stderr", emit: s.Notify}
+	// 取消时先发中断信号, 宽限期后强制结束。
+	cmd.Cancel = func() error {
+		if err := cmd.Process.Signal(os.Interrupt); err != nil {
+			return cmd.Process.Kill()
+		}
+		return nil
+	}
+	cmd.WaitDelay = execStreamStopGrace
+
+	if err := cmd.Start(); err != nil {
+		s.execStreams.remove(stream.ID)
+		cancel()
+		return nil, apperrors.Wrap(err, "Server.commandExecStream", "start command")
+	}
+	logger.Info("command/exec/stream: started",
+		logger.FieldCommand, stream.Command,
+		logger.FieldCwd, cmd.Dir,
+		logger.FieldThreadID, stream.ThreadID,
+		"exec_id", stream.ID,
+		"sandbox", profile.Name,
+		"timeout_sec", int(timeout.Seconds()),
+	)
+
+	go s.waitExecStream(execCtx, cmd, stream)
+
+	return map[string]any{
+		"execId":    stream.ID,
+		"sandbox":   stream.Sandbox,
+		"startedAt": stream.StartedAt,
+	}, nil
+}
+
+// waitExecStream 等待进程结束并推送 command/exec/exited。
+func (s *Server) waitExecStream(execCtx context.Context, cmd *exec.Cmd, stream *execStream) {
+	defer stream.cancel()
+
+	err := cmd.Wait()
+	s.execStreams.remove(stream.ID) // exited 通知前注销, 此后 cancel 返回 not found
+	elapsed := time.Since(stream.StartedAt)
+	exitCode := 0
+	payload := map[string]any{
+		"execId":     stream.ID,
+		"durationMs": elapsed.Milliseconds(),
+		"canceled":   stream.canceled.Load(),
+		"timedOut":   !stream.canceled.Load() && errors.Is(execCtx.Err(), context.DeadlineExceeded),
+		"truncated":  stream.dropped.Load(),
+		"chunks":     stream.seq.Load(),
+	}
+	if stream.ThreadID != "" {
+		payload["threadId"] = stream.ThreadID
+	}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			exitCode = exitErr.ExitCode()
+		} else {
+			exitCode = -1
+			payload["error"] = err.Error()
+		}
+	}
+	payload["exitCode"] = exitCode
+
+	logger.Info("command/exec/stream: exited",
+		logger.FieldCommand, stream.Command,
+		logger.FieldExitCode, exitCode,
+		logger.FieldDurationMS, elapsed.Milliseconds(),
+		"exec_id", stream.ID,
+		"canceled", stream.canceled.Load(),
+	)
+	s.Notify("command/exec/exited", payload)
+}
+
+type commandExecCancelParams struct {
+	ExecID string `json:"execId"`
+}
+
+func (s *Server) commandExecCancelTyped(_ context.Context, p commandExecCancelParams) (any, error) {
+	id := strings.TrimSpace(p.ExecID)
+	if id == "" {
+		return nil, apperrors.New("Server.commandExecCancel", "execId is required")
+	}
+	stream := s.execStreams.get(id)
+	if stream == nil {
+		return nil, apperrors.Wrapf(apperrors.ErrNotFound, "Server.commandExecCancel", "exec stream %s (already exited?)", id)
+	}
+	stream.canceled.Store(true)
+	stream.cancel()
+	logger.Info("command/exec/cancel: requested", "exec_id", id, logger.FieldCommand, stream.Command)
+	return map[string]any{"execId": id, "canceled": true}, nil
+}
+
+func (s *Server) commandExecList(_ context.Context, _ json.RawMessage) (any, error) {
+	return map[string]any{"streams": s.execStreams.list()}, nil
+}
@@ -0,0 +1,124 @@
+package apiserver
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// collectExecStreamNotifications 收集流式命令通知, exited 到达时关闭返回的通道。
+func collectExecStreamNotifications(srv *Server) (func() string, <-chan map[string]any) {
+	var mu sync.Mutex
+	var output strings.Builder
+	exited := make(chan map[string]any, 1)
+	srv.SetNotifyHook(func(method string, params any) {
+		payload, _ := params.(map[string]any)
+		switch method {
+		case "command/exec/output":
+			mu.Lock()
+			output.WriteString(payload["data"].(string))
+			mu.Unlock()
+		case "command/exec/exited":
+			exited <- payload
+		}
+	})
+	return func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return output.String()
+	}, exited
+}
+
+func TestCommandExecStreamEmitsOutputAndExit(t *testing.T) {
+	srv := &Server{}
+	output, exited := collectExecStreamNotifications(srv)
+
+	resp, err := srv.commandExecStreamTyped(context.Background(), commandExecStreamParams{
+		commandExecParams: commandExecParams{Argv: []string{"echo", "hello", "stream"}},
+	})
+	if err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	execID := resp.(map[string]any)["execId"].(string)
+
+	select {
+	case payload := <-exited:
+		if payload["execId"] != execID || payload["exitCode"] != 0 || payload["canceled"] != false {
+			t.Fatalf("exited payload = %+v", payload)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for command/exec/exited")
+	}
+	if got := output(); got != "hello stream\n" {
+		t.Fatalf("output = %q", got)
+	}
+	if streams := srv.execStreams.list(); len(streams) != 0 {
+		t.Fatalf("streams after exit = %d", len(streams))
+	}
+}
+
+func TestCommandExecStreamCancel(t *testing.T) {
+	srv := &Server{}
+	_, exited := collectExecStreamNotifications(srv)
+	ctx := context.Background()
+
+	resp, err := srv.commandExecStreamTyped(ctx, commandExecStreamParams{
+		commandExecParams: commandExecParams{Argv: []string{"sleep", "30"}},
+	})
+	if err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	execID := resp.(map[string]any)["execId"].(string)
+	if _, err := srv.commandExecCancelTyped(ctx, commandExecCancelParams{ExecID: execID}); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+
+	select {
+	case payload := <-exited:
+		if payload["canceled"] != true || payload["exitCode"] == 0 {
+			t.Fatalf("exited payload = %+v", payload)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for canceled stream to exit")
+	}
+	if _, err := srv.commandExecCancelTyped(ctx, commandExecCancelParams{ExecID: execID}); err == nil {
+		t.Fatal("cancel after exit should fail")
+	}
+}
+
+func TestCommandExecStreamRejectsBlockedCommand(t *testing.T) {
+	srv := &Server{}
+	if _, err := srv.commandExecStreamTyped(context.Background(), commandExecStreamParams{
+		commandExecParams: commandExecParams{Argv: []string{"rm", "-rf", "/tmp/x"}},
+	}); err == nil {
+		t.Fatal("blocked command should be rejected")
+	}
+	if streams := srv.execStreams.list(); len(streams) != 0 {
+		t.Fatalf("rejected command registered %d streams", len(streams))
+	}
+}
+
+func TestExecStreamWriterChunksAndCaps(t *testing.T) {
+	stream := &execStream{ID: "exec-1"}
+	var chunks []string
+	w := &execStreamWriter{stream: stream, name: "stdout", emit: func(_ string, params any) {
+		chunks = append(chunks, params.(map[string]any)["data"].(string))
+	}}
+	data := strings.Repeat("x", maxExecStreamChunkBytes*2+10)
+	if n, err := w.Write([]byte(data)); err != nil || n != len(data) {
+		t.Fatalf("Write = %d, %v", n, err)
+	}
+	if len(chunks) != 3 || len(chunks[2]) != 10 || stream.seq.Load() != 3 {
+		t.Fatalf("chunks = %d, seq = %d", len(chunks), stream.seq.Load())
+	}
+
+	stream.sent.Store(maxExecStreamOutputBytes)
+	if _, err := w.Write([]byte("dropped")); err != nil {
+		t.Fatalf("Write over cap: %v", err)
+	}
+	if len(chunks) != 3 || !stream.dropped.Load() {
+		t.Fatalf("output over cap should be dropped: chunks = %d", len(chunks))
+	}
+}
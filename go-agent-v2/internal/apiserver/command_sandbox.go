@@ -0,0 +1,454 @@
+// command_sandbox.go — command/exec 沙箱配置 (按线程选择 strict / standard / dev 预设并可覆盖)。
+//
+// 每个预设约束:
+//   - 命令黑名单 (基础命令名, 去掉路径)
+//   - 网络: 关闭时宿主机执行仅拦截常见网络工具 (尽力而为); 容器执行以 --network none 强制隔离
+//   - 写入路径白名单: restrictWrites 时工作目录必须位于白名单内 (默认仅线程工作目录);
+//     容器执行只以读写方式挂载白名单路径
+//   - 环境变量: minimal (仅 PATH/HOME/LANG 等基础变量) / scrubbed (继承但剔除疑似凭据) / inherit
+//   - 可选容器镜像: 设置后通过 docker/podman 在用户提供的镜像内执行
+//
+// 线程配置以 UI 偏好存储 (settings.sandboxProfiles), 按线程 ID 索引; 未配置的线程使用
+// COMMAND_SANDBOX_DEFAULT_PROFILE (默认 standard, 与原静态黑名单行为一致)。
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+const (
+	prefKeySandboxProfiles = "settings.sandboxProfiles"
+
+	sandboxProfileStrict   = "strict"
+	sandboxProfileStandard = "standard"
+	sandboxProfileDev      = "dev"
+
+	sandboxEnvMinimal  = "minimal"
+	sandboxEnvScrubbed = "scrubbed"
+	sandboxEnvInherit  = "inherit"
+
+	defaultSandboxTimeoutSec   = 30
+	maxSandboxTimeoutSec       = 600
+	defaultSandboxContainerCLI = "docker"
+)
+
+// sandboxSystemBlocklist 任何预设均禁止的系统级命令。
+var sandboxSystemBlocklist = []string{
+	"sudo", "su", "mkfs", "dd", "shutdown", "reboot", "passwd", "useradd", "userdel",
+	"mount", "umount", "fdisk", "iptables",
+}
+
+// sandboxDestructiveBlocklist strict / standard 额外禁止的破坏性 / 进程控制命令。
+var sandboxDestructiveBlocklist = []string{
+	"rm", "rmdir", "chmod", "chown", "kill", "killall", "pkill",
+}
+
+// sandboxNetworkTools 网络关闭时在宿主机上拦截的网络工具。
+var sandboxNetworkTools = []string{
+	"curl", "wget", "ssh", "scp", "sftp", "nc", "ncat", "netcat", "telnet", "ftp", "rsync",
+}
+
+// sandboxMinimalEnvKeys minimal 模式保留的基础环境变量。
+var sandboxMinimalEnvKeys = []string{"PATH", "HOME", "USER", "LANG", "LC_ALL", "TERM", "TMPDIR", "TZ", "SYSTEMROOT", "TEMP", "TMP"}
+
+// sandboxSecretMarkers scrubbed 模式剔除的变量名特征 (大写匹配)。
+var sandboxSecretMarkers = []string{"TOKEN", "SECRET", "PASSWORD", "PASSWD", "API_KEY", "APIKEY", "PRIVATE_KEY", "CREDENTIAL", "ACCESS_KEY", "DATABASE_URL", "_DSN"}
+
+// sandboxProfile 沙箱配置。
+type sandboxProfile struct {
+	Name             string   `json:"name"`
+	Blocklist        []string `json:"blocklist,omitempty"` // 追加到预设黑名单
+	AllowShellMeta   bool     `json:"allowShellMeta"`      // 允许参数含 | ; & $ ` (argv 不经 shell, dev 放开)
+	Network          bool     `json:"network"`
+	RestrictWrites   bool     `json:"restrictWrites"`
+	WritePaths       []string `json:"writePaths,omitempty"` // 空 = 线程工作目录
+	EnvMode          string   `json:"envMode"`
+	ContainerImage   string   `json:"containerImage,omitempty"`
+	ContainerRuntime string   `json:"containerRuntime,omitempty"` // docker / podman, 空 = COMMAND_SANDBOX_CONTAINER_RUNTIME
+	TimeoutSec       int      `json:"timeoutSec,omitempty"`
+}
+
+// builtinSandboxProfiles 内置预设。
+func builtinSandboxProfiles() map[string]sandboxProfile {
+	return map[string]sandboxProfile{
+		sandboxProfileStrict: {
+			Name:           sandboxProfileStrict,
+			RestrictWrites: true,
+			EnvMode:        sandboxEnvMinimal,
+			TimeoutSec:     defaultSandboxTimeoutSec,
+		},
+		sandboxProfileStandard: {
+			Name:       sandboxProfileStandard,
+			EnvMode:    sandboxEnvScrubbed,
+			TimeoutSec: defaultSandboxTimeoutSec,
+		},
+		sandboxProfileDev: {
+			Name:           sandboxProfileDev,
+			AllowShellMeta: true,
+			Network:        true,
+			EnvMode:        sandboxEnvInherit,
+			TimeoutSec:     120,
+		},
+	}
+}
+
+// blocked 合并后的命令黑名单。
+func (p sandboxProfile) blocked(baseName string) bool {
+	name := strings.ToLower(strings.TrimSuffix(baseName, ".exe"))
+	if slices.Contains(sandboxSystemBlocklist, name) || slices.Contains(p.Blocklist, name) {
+		return true
+	}
+	if p.Name != sandboxProfileDev && slices.Contains(sandboxDestructiveBlocklist, name) {
+		return true
+	}
+	// 容器内由 --network none 隔离, 无需按名拦截
+	return !p.Network && p.ContainerImage == "" && slices.Contains(sandboxNetworkTools, name)
+}
+
+func (p sandboxProfile) timeout() time.Duration {
+	sec := p.TimeoutSec
+	if sec <= 0 {
+		sec = defaultSandboxTimeoutSec
+	}
+	return time.Duration(min(sec, maxSandboxTimeoutSec)) * time.Second
+}
+
+// sandboxThreadPolicy 线程沙箱配置 (预设 + 覆盖项)。
+type sandboxThreadPolicy struct {
+	Profile        string   `json:"profile"`
+	Network        *bool    `json:"network,omitempty"`
+	RestrictWrites *bool    `json:"restrictWrites,omitempty"`
+	WritePaths     []string `json:"writePaths,omitempty"`
+	EnvMode        string   `json:"envMode,omitempty"`
+	ContainerImage string   `json:"containerImage,omitempty"`
+	Blocklist      []string `json:"blocklist,omitempty"`
+	TimeoutSec     int      `json:"timeoutSec,omitempty"`
+}
+
+// resolve 以预设为基础应用覆盖项。
+func (tp sandboxThreadPolicy) resolve() (sandboxProfile, error) {
+	profile, ok := builtinSandboxProfiles()[strings.ToLower(strings.TrimSpace(tp.Profile))]
+	if !ok {
+		return sandboxProfile{}, apperrors.Newf("sandboxThreadPolicy.resolve", "unknown sandbox profile %q (want strict, standard or dev)", tp.Profile)
+	}
+	if tp.Network != nil {
+		profile.Network = *tp.Network
+	}
+	if tp.RestrictWrites != nil {
+		profile.RestrictWrites = *tp.RestrictWrites
+	}
+	if len(tp.WritePaths) > 0 {
+		profile.WritePaths = nil
+		for _, path := range tp.WritePaths {
+			if !filepath.IsAbs(path) {
+				return sandboxProfile{}, apperrors.Newf("sandboxThreadPolicy.resolve", "writePaths must be absolute: %q", path)
+			}
+			profile.WritePaths = append(profile.WritePaths, filepath.Clean(path))
+		}
+	}
+	switch tp.EnvMode {
+	case "":
+	case sandboxEnvMinimal, sandboxEnvScrubbed, sandboxEnvInherit:
+		profile.EnvMode = tp.EnvMode
+	default:
+		return sandboxProfile{}, apperrors.Newf("sandboxThreadPolicy.resolve", "unknown envMode %q", tp.EnvMode)
+	}
+	profile.ContainerImage = strings.TrimSpace(tp.ContainerImage)
+	for _, name := range tp.Blocklist {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			profile.Blocklist = append(profile.Blocklist, name)
+		}
+	}
+	if tp.TimeoutSec < 0 || tp.TimeoutSec > maxSandboxTimeoutSec {
+		return sandboxProfile{}, apperrors.Newf("sandboxThreadPolicy.resolve", "timeoutSec must be within [0, %d]", maxSandboxTimeoutSec)
+	}
+	if tp.TimeoutSec > 0 {
+		profile.TimeoutSec = tp.TimeoutSec
+	}
+	return profile, nil
+}
+
+// ========================================
+// 命令构造
+// ========================================
+
+// sandboxCommandSpec command/exec 的执行请求。
+type sandboxCommandSpec struct {
+	Argv      []string
+	Cwd       string
+	Env       map[string]string
+	ThreadCwd string // 线程工作目录 (写入白名单默认值)
+}
+
+// pathWithin 判断 path 是否位于 root 内 (含 root 本身)。
+func pathWithin(path, root string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// sandboxEnv 按环境变量模式生成基础环境 (KEY=VALUE 列表)。
+func sandboxEnv(mode string, environ []string) []string {
+	if mode == sandboxEnvInherit {
+		return append([]string(nil), environ...)
+	}
+	out := make([]string, 0, len(environ))
+	for _, kv := range environ {
+		key, _, ok := strings.Cut(kv, "=")
+		if !ok {
+			continue
+		}
+		upper := strings.ToUpper(key)
+		if mode == sandboxEnvMinimal {
+			if slices.Contains(sandboxMinimalEnvKeys, upper) {
+				out = append(out, kv)
+			}
+			continue
+		}
+		if !slices.ContainsFunc(sandboxSecretMarkers, func(marker string) bool { return strings.Contains(upper, marker) }) {
+			out = append(out, kv)
+		}
+	}
+	return out
+}
+
+// build 校验请求并构造待执行命令 (宿主机或容器)。
+func (p sandboxProfile) build(ctx context.Context, spec sandboxCommandSpec, containerRuntime string) (*exec.Cmd, error) {
+	if len(spec.Argv) == 0 {
+		return nil, apperrors.New("Server.commandExec", "argv is required")
+	}
+	baseName := filepath.Base(spec.Argv[0])
+	if p.blocked(baseName) {
+		return nil, apperrors.NewCodef("Server.commandExec", errcode.BlockedCommand, "command %q is blocked by sandbox profile %s", baseName, p.Name)
+	}
+	if !p.AllowShellMeta {
+		for _, arg := range spec.Argv {
+			if strings.ContainsAny(arg, "|;&$`") {
+				return nil, apperrors.NewCode("Server.commandExec", errcode.BlockedCommand, "shell metacharacters not allowed in arguments")
+			}
+		}
+	}
+
+	cwd := strings.TrimSpace(spec.Cwd)
+	if cwd == "" {
+		cwd = spec.ThreadCwd
+	}
+	if cwd != "" {
+		if abs, err := filepath.Abs(cwd); err == nil {
+			cwd = abs
+		}
+	}
+	writePaths := p.WritePaths
+	if len(writePaths) == 0 && spec.ThreadCwd != "" {
+		if abs, err := filepath.Abs(spec.ThreadCwd); err == nil {
+			writePaths = []string{abs}
+		}
+	}
+	if p.RestrictWrites {
+		if cwd == "" || !slices.ContainsFunc(writePaths, func(root string) bool { return pathWithin(cwd, root) }) {
+			return nil, apperrors.NewCodef("Server.commandExec", errcode.SandboxViolation,
+				"cwd %q is outside sandbox write paths %v (profile %s)", cwd, writePaths, p.Name)
+		}
+	}
+
+	var userEnv []string
+	keys := make([]string, 0, len(spec.Env))
+	for key := range spec.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !isAllowedEnvKey(key) {
+			continue // 跳过不允许的环境变量
+		}
+		userEnv = append(userEnv, key+"="+spec.Env[key])
+	}
+
+	if p.ContainerImage == "" {
+		cmd := exec.CommandContext(ctx, spec.Argv[0], spec.Argv[1:]...)
+		cmd.Dir = cwd
+		cmd.Env = append(sandboxEnv(p.EnvMode, os.Environ()), userEnv...)
+		return cmd, nil
+	}
+
+	// 容器执行: 工作目录与写入白名单以读写挂载, 其余宿主路径不可见。
+	cli := strings.TrimSpace(p.ContainerRuntime)
+	if cli == "" {
+		cli = containerRuntime
+	}
+	if cli == "" {
+		cli = defaultSandboxContainerCLI
+	}
+	args := []string{"run", "--rm", "--init"}
+	if !p.Network {
+		args = append(args, "--network", "none")
+	}
+	if runtime.GOOS == "linux" {
+		args = append(args, "--user", strconv.Itoa(os.Getuid())+":"+strconv.Itoa(os.Getgid()))
+	}
+	mounted := map[string]bool{}
+	for _, root := range writePaths {
+		if !mounted[root] {
+			args = append(args, "-v", root+":"+root)
+			mounted[root] = true
+		}
+	}
+	if cwd != "" {
+		if !slices.ContainsFunc(writePaths, func(root string) bool { return pathWithin(cwd, root) }) {
+			args = append(args, "-v", cwd+":"+cwd+":ro")
+		}
+		args = append(args, "-w", cwd)
+	}
+	for _, kv := range userEnv {
+		args = append(args, "-e", kv)
+	}
+	args = append(args, p.ContainerImage)
+	args = append(args, spec.Argv...)
+	cmd := exec.CommandContext(ctx, cli, args...)
+	cmd.Env = sandboxEnv(sandboxEnvMinimal, os.Environ())
+	return cmd, nil
+}
+
+// ========================================
+// 线程配置存取
+// ========================================
+
+func decodeSandboxPolicies(value any) map[string]sandboxThreadPolicy {
+	out := map[string]sandboxThreadPolicy{}
+	if value == nil {
+		return out
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return out
+	}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return map[string]sandboxThreadPolicy{}
+	}
+	return out
+}
+
+func (s *Server) loadSandboxPolicies(ctx context.Context) map[string]sandboxThreadPolicy {
+	if s.prefManager == nil {
+		return map[string]sandboxThreadPolicy{}
+	}
+	value, err := s.prefManager.Get(ctx, prefKeySandboxProfiles)
+	if err != nil {
+		logger.Warn("sandbox: load preference failed", logger.FieldError, err)
+		return map[string]sandboxThreadPolicy{}
+	}
+	return decodeSandboxPolicies(value)
+}
+
+// defaultSandboxProfileName 未配置线程使用的预设。
+func (s *Server) defaultSandboxProfileName() string {
+	if s.cfg != nil && strings.TrimSpace(s.cfg.CommandSandboxDefaultProfile) != "" {
+		return strings.TrimSpace(s.cfg.CommandSandboxDefaultProfile)
+	}
+	return sandboxProfileStandard
+}
+
+// resolveSandboxProfile 解析线程生效的沙箱配置。
+func (s *Server) resolveSandboxProfile(ctx context.Context, threadID string) (sandboxProfile, error) {
+	policy, ok := s.loadSandboxPolicies(ctx)[strings.TrimSpace(threadID)]
+	if !ok || threadID == "" {
+		policy = sandboxThreadPolicy{Profile: s.defaultSandboxProfileName()}
+	}
+	return policy.resolve()
+}
+
+// ========================================
+// sandbox/profile/list, sandbox/profile/get, sandbox/profile/set
+// ========================================
+
+func (s *Server) sandboxProfileList(_ context.Context, _ json.RawMessage) (any, error) {
+	profiles := builtinSandboxProfiles()
+	out := make([]sandboxProfile, 0, len(profiles))
+	for _, name := range []string{sandboxProfileStrict, sandboxProfileStandard, sandboxProfileDev} {
+		out = append(out, profiles[name])
+	}
+	return map[string]any{
+		"profiles":       out,
+		"defaultProfile": s.defaultSandboxProfileName(),
+		"prefKey":        prefKeySandboxProfiles,
+	}, nil
+}
+
+type sandboxProfileGetParams struct {
+	ThreadID string `json:"threadId"`
+}
+
+func (s *Server) sandboxProfileGetTyped(ctx context.Context, p sandboxProfileGetParams) (any, error) {
+	policy, configured := s.loadSandboxPolicies(ctx)[strings.TrimSpace(p.ThreadID)]
+	profile, err := s.resolveSandboxProfile(ctx, p.ThreadID)
+	if err != nil {
+		return nil, err
+	}
+	resp := map[string]any{"threadId": p.ThreadID, "configured": configured, "effective": profile}
+	if configured {
+		resp["policy"] = policy
+	}
+	return resp, nil
+}
+
+type sandboxProfileSetParams struct {
+	ThreadID string `json:"threadId"`
+	sandboxThreadPolicy
+	Reset bool `json:"reset,omitempty"` // 移除线程配置, 回到默认预设
+}
+
+func (s *Server) sandboxProfileSetTyped(ctx context.Context, p sandboxProfileSetParams) (any, error) {
+	if s.prefManager == nil {
+		return nil, apperrors.New("Server.sandboxProfileSet", "preference manager not initialized")
+	}
+	threadID := strings.TrimSpace(p.ThreadID)
+	if threadID == "" {
+		return nil, apperrors.New("Server.sandboxProfileSet", "threadId is required")
+	}
+	if err := s.frozenError("Server.sandboxProfileSet"); err != nil {
+		return nil, err
+	}
+	var effective sandboxProfile
+	if !p.Reset {
+		resolved, err := p.sandboxThreadPolicy.resolve()
+		if err != nil {
+			return nil, apperrors.WrapCode(err, "Server.sandboxProfileSet", errcode.InvalidInput, "invalid sandbox policy")
+		}
+		effective = resolved
+	}
+
+	s.sandboxPrefMu.Lock()
+	defer s.sandboxPrefMu.Unlock()
+	policies := s.loadSandboxPolicies(ctx)
+	if p.Reset {
+		delete(policies, threadID)
+	} else {
+		policies[threadID] = p.sandboxThreadPolicy
+	}
+	if err := s.prefManager.Set(ctx, prefKeySandboxProfiles, policies); err != nil {
+		return nil, err
+	}
+	logger.Info("sandbox/profile/set: saved",
+		logger.FieldThreadID, threadID,
+		"profile", p.Profile,
+		"reset", p.Reset,
+		"container", effective.ContainerImage != "",
+	)
+	if p.Reset {
+		return s.sandboxProfileGetTyped(ctx, sandboxProfileGetParams{ThreadID: threadID})
+	}
+	return map[string]any{"threadId": threadID, "configured": true, "policy": p.sandboxThreadPolicy, "effective": effective}, nil
+}
@@ -0,0 +1,139 @@
+package apiserver
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/multi-agent/go-agent-v2/internal/uistate"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+func TestSandboxProfileBlocklist(t *testing.T) {
+	profiles := builtinSandboxProfiles()
+	cases := []struct {
+		profile string
+		command string
+		blocked bool
+	}{
+		{sandboxProfileStandard, "rm", true},
+		{sandboxProfileStandard, "curl", true},
+		{sandboxProfileStandard, "ls", false},
+		{sandboxProfileStrict, "wget", true},
+		{sandboxProfileDev, "rm", false},
+		{sandboxProfileDev, "curl", false},
+		{sandboxProfileDev, "sudo", true},
+	}
+	for _, tc := range cases {
+		if got := profiles[tc.profile].blocked(tc.command); got != tc.blocked {
+			t.Errorf("%s.blocked(%q) = %v, want %v", tc.profile, tc.command, got, tc.blocked)
+		}
+	}
+}
+
+func TestSandboxThreadPolicyResolve(t *testing.T) {
+	network := true
+	profile, err := sandboxThreadPolicy{Profile: "Strict", Network: &network, WritePaths: []string{"/tmp/work/"}, Blocklist: []string{" Make "}}.resolve()
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if profile.Name != sandboxProfileStrict || !profile.Network || !profile.RestrictWrites {
+		t.Fatalf("profile = %+v", profile)
+	}
+	if !slices.Equal(profile.WritePaths, []string{"/tmp/work"}) || !profile.blocked("make") {
+		t.Fatalf("overrides not applied: %+v", profile)
+	}
+
+	for _, bad := range []sandboxThreadPolicy{
+		{Profile: "paranoid"},
+		{Profile: sandboxProfileStandard, WritePaths: []string{"relative"}},
+		{Profile: sandboxProfileStandard, EnvMode: "everything"},
+		{Profile: sandboxProfileStandard, TimeoutSec: maxSandboxTimeoutSec + 1},
+	} {
+		if _, err := bad.resolve(); err == nil {
+			t.Errorf("resolve(%+v) should fail", bad)
+		}
+	}
+}
+
+func TestSandboxEnvModes(t *testing.T) {
+	environ := []string{"PATH=/bin", "HOME=/root", "GITHUB_TOKEN=x", "OPENAI_API_KEY=y", "EDITOR=vim"}
+	if got := sandboxEnv(sandboxEnvMinimal, environ); !slices.Equal(got, []string{"PATH=/bin", "HOME=/root"}) {
+		t.Fatalf("minimal = %v", got)
+	}
+	if got := sandboxEnv(sandboxEnvScrubbed, environ); !slices.Equal(got, []string{"PATH=/bin", "HOME=/root", "EDITOR=vim"}) {
+		t.Fatalf("scrubbed = %v", got)
+	}
+	if got := sandboxEnv(sandboxEnvInherit, environ); len(got) != len(environ) {
+		t.Fatalf("inherit = %v", got)
+	}
+}
+
+func TestSandboxBuildRestrictsWrites(t *testing.T) {
+	profile := builtinSandboxProfiles()[sandboxProfileStrict]
+	spec := sandboxCommandSpec{Argv: []string{"ls"}, Cwd: "/etc", ThreadCwd: "/tmp/thread"}
+	_, err := profile.build(context.Background(), spec, "")
+	if apperrors.CodeOf(err) != errcode.SandboxViolation {
+		t.Fatalf("cwd outside write paths: err = %v", err)
+	}
+
+	spec.Cwd = "/tmp/thread/sub"
+	cmd, err := profile.build(context.Background(), spec, "")
+	if err != nil {
+		t.Fatalf("cwd inside write paths: %v", err)
+	}
+	if cmd.Dir != "/tmp/thread/sub" {
+		t.Fatalf("cmd.Dir = %q", cmd.Dir)
+	}
+
+	if _, err := profile.build(context.Background(), sandboxCommandSpec{Argv: []string{"echo", "a;b"}, ThreadCwd: "/tmp/thread"}, ""); apperrors.CodeOf(err) != errcode.BlockedCommand {
+		t.Fatalf("shell metachar: err = %v", err)
+	}
+}
+
+func TestSandboxBuildContainer(t *testing.T) {
+	profile := builtinSandboxProfiles()[sandboxProfileStandard]
+	profile.ContainerImage = "alpine:3"
+	cmd, err := profile.build(context.Background(), sandboxCommandSpec{
+		Argv:      []string{"curl", "-V"},
+		Cwd:       "/src",
+		Env:       map[string]string{"LANG": "C"},
+		ThreadCwd: "/work",
+	}, "podman")
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	args := strings.Join(cmd.Args, " ")
+	for _, want := range []string{"podman run --rm", "--network none", "-v /work:/work", "-v /src:/src:ro", "-w /src", "alpine:3 curl -V"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("args %q missing %q", args, want)
+		}
+	}
+}
+
+func TestSandboxProfileSetAndResolve(t *testing.T) {
+	srv := &Server{prefManager: uistate.NewPreferenceManager(nil)}
+	ctx := context.Background()
+
+	profile, err := srv.resolveSandboxProfile(ctx, "thread-1")
+	if err != nil || profile.Name != sandboxProfileStandard {
+		t.Fatalf("default profile = %+v, %v", profile, err)
+	}
+	if _, err := srv.sandboxProfileSetTyped(ctx, sandboxProfileSetParams{ThreadID: "thread-1", sandboxThreadPolicy: sandboxThreadPolicy{Profile: "dev"}}); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if profile, _ := srv.resolveSandboxProfile(ctx, "thread-1"); profile.Name != sandboxProfileDev {
+		t.Fatalf("profile after set = %+v", profile)
+	}
+	if _, err := srv.sandboxProfileSetTyped(ctx, sandboxProfileSetParams{ThreadID: "thread-1", sandboxThreadPolicy: sandboxThreadPolicy{Profile: "nope"}}); apperrors.CodeOf(err) != errcode.InvalidInput {
+		t.Fatalf("invalid profile: err = %v", err)
+	}
+	if _, err := srv.sandboxProfileSetTyped(ctx, sandboxProfileSetParams{ThreadID: "thread-1", Reset: true}); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	if profile, _ := srv.resolveSandboxProfile(ctx, "thread-1"); profile.Name != sandboxProfileStandard {
+		t.Fatalf("profile after reset = %+v", profile)
+	}
+}
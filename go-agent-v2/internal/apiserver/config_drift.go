@@ -0,0 +1,188 @@
+// config_drift.go — 运行配置与声明配置的漂移检测 (config/drift)。
+//
+// 比较运行中的有效配置 (环境变量 + 命令行 + 运行时写入) 与配置文件声明, 逐键报告差异与来源;
+// config/value/write、config/batchWrite、config/reload 的每次写入都记录下来 (有数据库时写审计日志
+// event_type = config_write), 漂移项附带最近一次写入者, 便于排查 "我这里是好的" 类环境差异。
+package apiserver
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/config"
+	"github.com/multi-agent/go-agent-v2/internal/store"
+	"github.com/multi-agent/go-agent-v2/pkg/correlation"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+const (
+	configWriteAuditType = "config_write"
+	// configWriteAuditScan 归因时最多回溯的审计记录条数。
+	configWriteAuditScan = 500
+)
+
+// configWriteRecord 一次配置写入。
+type configWriteRecord struct {
+	Key           string    `json:"key"`
+	Method        string    `json:"method"`
+	Old           string    `json:"old"`
+	New           string    `json:"new"`
+	Actor         string    `json:"actor"`
+	CorrelationID string    `json:"correlationId,omitempty"`
+	At            time.Time `json:"at"`
+}
+
+// configWriteLog 进程内最近写入 (key → 记录)。
+type configWriteLog struct {
+	mu     sync.Mutex
+	latest map[string]configWriteRecord
+}
+
+func (l *configWriteLog) put(rec configWriteRecord) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.latest == nil {
+		l.latest = make(map[string]configWriteRecord)
+	}
+	l.latest[rec.Key] = rec
+}
+
+func (l *configWriteLog) get(key string) (configWriteRecord, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	rec, ok := l.latest[key]
+	return rec, ok
+}
+
+// recordConfigWrite 记录一次配置写入 (敏感键取值脱敏)。
+func (s *Server) recordConfigWrite(ctx context.Context, method, key, oldValue, newValue string) {
+	if isSecretConfigKey(key) {
+		oldValue, newValue = "***", "***"
+	}
+	rec := configWriteRecord{
+		Key:           key,
+		Method:        method,
+		Old:           oldValue,
+		New:           newValue,
+		Actor:         "rpc",
+		CorrelationID: correlation.ID(ctx),
+		At:            time.Now(),
+	}
+	if rec.CorrelationID != "" {
+		rec.Actor = "rpc:" + rec.CorrelationID
+	}
+	s.configWrites.put(rec)
+	logger.Info("config: value written", logger.FieldKey, key, logger.FieldMethod, method, "actor", rec.Actor)
+	if s.auditLogStore == nil {
+		return
+	}
+	event := &store.AuditEvent{
+		EventType: configWriteAuditType,
+		Action:    method,
+		Result:    "ok",
+		Actor:     rec.Actor,
+		Target:    key,
+		Level:     "INFO",
+		Extra: map[string]any{
+			"old":            rec.Old,
+			"new":            rec.New,
+			"correlation_id": rec.CorrelationID,
+		},
+	}
+	if err := s.auditLogStore.Append(context.Background(), event); err != nil {
+		logger.Warn("config: write audit failed", logger.FieldKey, key, logger.FieldError, err)
+	}
+}
+
+type configDriftParams struct {
+	Path              string `json:"path,omitempty"`              // 对比的配置文件, 默认启动时使用的文件
+	IncludeUndeclared bool   `json:"includeUndeclared,omitempty"` // 同时报告文件未声明但偏离默认值的键
+}
+
+// configDriftItem 单键漂移 (附最近一次写入)。
+type configDriftItem struct {
+	config.Drift
+	LastWrite *configWriteRecord `json:"lastWrite,omitempty"`
+}
+
+// configDriftTyped 报告运行配置相对配置文件的漂移。
+func (s *Server) configDriftTyped(ctx context.Context, p configDriftParams) (any, error) {
+	const op = "Server.configDrift"
+	if s.cfg == nil {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "server started without config")
+	}
+	path := strings.TrimSpace(p.Path)
+	if path == "" {
+		path = s.cfg.ConfigFile
+	}
+	values := map[string]any{}
+	if path != "" {
+		var err error
+		if values, err = config.ReadConfigFile(path); err != nil {
+			return nil, apperrors.WrapCode(err, op, errcode.InvalidInput, "read config file")
+		}
+	}
+
+	s.configReloadMu.Lock()
+	drifts := config.CompareWithFile(s.cfg, values, p.IncludeUndeclared || path == "")
+	drifts = append(drifts, config.PendingEnv(s.cfg)...)
+	s.configReloadMu.Unlock()
+
+	audited := s.configWriteAudit(ctx)
+	items := make([]configDriftItem, 0, len(drifts))
+	for _, d := range drifts {
+		if isSecretConfigKey(d.Key) {
+			d.Declared, d.Running = redactDriftValue(d.Declared), redactDriftValue(d.Running)
+			d.Default = redactDriftValue(d.Default)
+		}
+		item := configDriftItem{Drift: d}
+		if rec, ok := s.configWrites.get(d.Key); ok {
+			item.LastWrite = &rec
+		} else if rec, ok := audited[d.Key]; ok {
+			item.LastWrite = &rec
+		}
+		items = append(items, item)
+	}
+	return map[string]any{
+		"file":    path,
+		"drifted": len(items) > 0,
+		"drifts":  items,
+	}, nil
+}
+
+// configWriteAudit 从审计日志取各键最近一次写入 (覆盖进程重启前的写入; 无数据库时为空)。
+func (s *Server) configWriteAudit(ctx context.Context) map[string]configWriteRecord {
+	out := map[string]configWriteRecord{}
+	if s.auditLogStore == nil {
+		return out
+	}
+	events, err := s.auditLogStore.List(ctx, configWriteAuditType, "", "", "", configWriteAuditScan)
+	if err != nil {
+		logger.Warn("config/drift: audit query failed", logger.FieldError, err)
+		return out
+	}
+	for _, ev := range events {
+		if _, seen := out[ev.Target]; seen {
+			continue // 按时间倒序, 首条即最近一次
+		}
+		rec := configWriteRecord{Key: ev.Target, Method: ev.Action, Actor: ev.Actor, At: ev.Ts}
+		if extra, ok := ev.Extra.(map[string]any); ok {
+			rec.Old, _ = extra["old"].(string)
+			rec.New, _ = extra["new"].(string)
+			rec.CorrelationID, _ = extra["correlation_id"].(string)
+		}
+		out[ev.Target] = rec
+	}
+	return out
+}
+
+func redactDriftValue(v any) any {
+	if v == nil || v == "" {
+		return v
+	}
+	return "***"
+}
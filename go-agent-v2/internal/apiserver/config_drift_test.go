@@ -0,0 +1,63 @@
+package apiserver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/multi-agent/go-agent-v2/internal/config"
+	"github.com/multi-agent/go-agent-v2/pkg/correlation"
+)
+
+func TestConfigDrift_AttributesRuntimeWrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("OPENAI_BASE_URL: http://declared\nLLM_MODEL: o3\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	t.Setenv("OPENAI_BASE_URL", "")
+	t.Setenv("OPENAI_API_KEY", "")
+	t.Setenv("LLM_MODEL", "gpt-4o")
+	cfg, _, err := config.LoadWithOptions(config.LoadOptions{File: path})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	srv := &Server{cfg: cfg}
+
+	trail := correlation.New("config/batchWrite")
+	ctx := correlation.WithTrail(context.Background(), trail)
+	if _, err := srv.configBatchWriteTyped(ctx, configBatchWriteParams{Entries: []configBatchWriteEntry{
+		{Key: "OPENAI_BASE_URL", Value: "http://local"},
+		{Key: "OPENAI_API_KEY", Value: "sk-secret"},
+	}}); err != nil {
+		t.Fatalf("batchWrite: %v", err)
+	}
+
+	res, err := srv.configDriftTyped(context.Background(), configDriftParams{})
+	if err != nil {
+		t.Fatalf("config/drift: %v", err)
+	}
+	result := res.(map[string]any)
+	if result["file"] != path || result["drifted"] != true {
+		t.Fatalf("result = %+v", result)
+	}
+	got := map[string]configDriftItem{}
+	for _, item := range result["drifts"].([]configDriftItem) {
+		got[item.Key+"/"+item.Kind] = item
+	}
+	if d := got["LLM_MODEL/"+config.DriftOverridden]; d.Source != config.SourceEnv || d.Declared != "o3" || d.Running != "gpt-4o" || d.LastWrite != nil {
+		t.Fatalf("LLM_MODEL drift = %+v", d)
+	}
+	base := got["OPENAI_BASE_URL/"+config.DriftPending]
+	if base.Declared != "http://local" || base.LastWrite == nil || base.LastWrite.Method != "config/batchWrite" ||
+		base.LastWrite.Actor != "rpc:"+trail.ID || base.LastWrite.New != "http://local" {
+		t.Fatalf("OPENAI_BASE_URL pending drift = %+v (lastWrite %+v)", base, base.LastWrite)
+	}
+	if _, ok := got["OPENAI_BASE_URL/"+config.DriftOverridden]; ok {
+		t.Fatalf("running value still matches the file until reload: %+v", got)
+	}
+	key := got["OPENAI_API_KEY/"+config.DriftPending]
+	if key.Declared != "***" || key.LastWrite == nil || key.LastWrite.New != "***" {
+		t.Fatalf("secret leaked: %+v (lastWrite %+v)", key, key.LastWrite)
+	}
+}
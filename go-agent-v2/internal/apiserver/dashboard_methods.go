@@ -10,6 +10,8 @@ import (
 	"reflect"
 	"time"
 
+	"github.com/multi-agent/go-agent-v2/internal/store"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
 	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
 	"github.com/multi-agent/go-agent-v2/pkg/logger"
 )
@@ -204,6 +206,10 @@ func (s *Server) registerDashboardMethods() {
 	// — DAG Detail (非列表, 不走 dashList) —
 
 	s.methods["dashboard/dagDetail"] = s.dashDAGDetail
+
+	// — 统计 (多结果集, 不走 dashList) —
+
+	s.methods["dashboard/turnStats"] = typedHandler(s.dashTurnStatsTyped)
 }
 
 // ========================================
@@ -232,3 +238,92 @@ func (s *Server) dashDAGDetail(_ context.Context, params json.RawMessage) (any,
 	}
 	return map[string]any{"dag": dag, "nodes": nodes}, nil
 }
+
+// ========================================
+// Dashboard 统计方法
+// ========================================
+
+const (
+	defaultTurnStatsDays = 7
+	maxTurnStatsDays     = 90
+)
+
+type dashTurnStatsParams struct {
+	AgentID  string `json:"agentId"`
+	Days     int    `json:"days"`
+	TimeZone string `json:"timezone"`
+}
+
+// turnStatsView dashboard/turnStats 响应: 全局汇总 / agent 汇总 / 每日明细 / 中断原因。
+type turnStatsView struct {
+	Since    time.Time             `json:"since"`
+	Days     int                   `json:"days"`
+	TimeZone string                `json:"timezone"`
+	Totals   store.TurnStatsRow    `json:"totals"`
+	Agents   []store.TurnStatsRow  `json:"agents"`
+	Daily    []store.TurnStatsRow  `json:"daily"`
+	Reasons  []store.TurnReasonRow `json:"reasons"`
+}
+
+// splitTurnStatsRows 按 GROUPING SETS 汇总层级拆分统计行。
+func splitTurnStatsRows(view *turnStatsView, rows []store.TurnStatsRow) {
+	for _, row := range rows {
+		switch {
+		case row.AgentID == nil:
+			view.Totals = row
+		case row.Day == nil:
+			view.Agents = append(view.Agents, row)
+		default:
+			view.Daily = append(view.Daily, row)
+		}
+	}
+}
+
+// dashTurnStatsTyped 按 agent / 天聚合 turn 成功 / 中断 / 失败分布、耗时分位与中断原因。
+func (s *Server) dashTurnStatsTyped(_ context.Context, p dashTurnStatsParams) (any, error) {
+	days := p.Days
+	if days <= 0 {
+		days = defaultTurnStatsDays
+	}
+	if days > maxTurnStatsDays {
+		days = maxTurnStatsDays
+	}
+	tz := p.TimeZone
+	if tz == "" {
+		tz = "UTC"
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, apperrors.WrapCodef(err, "Server.dashTurnStats", errcode.InvalidInput, "invalid timezone %q", tz)
+	}
+	now := time.Now().In(loc)
+	since := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc).AddDate(0, 0, -(days - 1))
+	view := &turnStatsView{
+		Since:    since,
+		Days:     days,
+		TimeZone: tz,
+		Agents:   []store.TurnStatsRow{},
+		Daily:    []store.TurnStatsRow{},
+		Reasons:  []store.TurnReasonRow{},
+	}
+	if s.taskTraceStore == nil {
+		return view, nil
+	}
+
+	ctx, cancel := dashCtx()
+	defer cancel()
+	q := store.TurnStatsQuery{AgentID: p.AgentID, Since: since, TimeZone: tz}
+	rows, err := s.taskTraceStore.TurnStats(ctx, q)
+	if err != nil {
+		return nil, apperrors.Wrap(err, "Server.dashTurnStats", "query turn stats")
+	}
+	splitTurnStatsRows(view, rows)
+	reasons, err := s.taskTraceStore.TurnReasons(ctx, q, 0)
+	if err != nil {
+		return nil, apperrors.Wrap(err, "Server.dashTurnStats", "query turn reasons")
+	}
+	if reasons != nil {
+		view.Reasons = reasons
+	}
+	return view, nil
+}
@@ -0,0 +1,48 @@
+package apiserver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/multi-agent/go-agent-v2/internal/store"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+func TestSplitTurnStatsRows(t *testing.T) {
+	agent, day := "thread-1", "2026-10-17"
+	view := &turnStatsView{}
+	splitTurnStatsRows(view, []store.TurnStatsRow{
+		{Total: 5, Completed: 3, Interrupted: 1, Failed: 1},
+		{AgentID: &agent, Total: 5},
+		{AgentID: &agent, Day: &day, Total: 5, P90DurationMS: 1200},
+	})
+	if view.Totals.Total != 5 || view.Totals.Completed != 3 {
+		t.Fatalf("totals = %+v", view.Totals)
+	}
+	if len(view.Agents) != 1 || *view.Agents[0].AgentID != agent {
+		t.Fatalf("agents = %+v", view.Agents)
+	}
+	if len(view.Daily) != 1 || *view.Daily[0].Day != day || view.Daily[0].P90DurationMS != 1200 {
+		t.Fatalf("daily = %+v", view.Daily)
+	}
+}
+
+func TestDashTurnStatsWithoutStore(t *testing.T) {
+	srv := &Server{}
+	resp, err := srv.dashTurnStatsTyped(context.Background(), dashTurnStatsParams{Days: 365, TimeZone: "Asia/Shanghai"})
+	if err != nil {
+		t.Fatalf("turnStats: %v", err)
+	}
+	view := resp.(*turnStatsView)
+	if view.Days != maxTurnStatsDays || view.Since.Location().String() != "Asia/Shanghai" {
+		t.Fatalf("view = %+v", view)
+	}
+	if view.Agents == nil || view.Daily == nil || view.Reasons == nil {
+		t.Fatal("empty result lists must be non-nil")
+	}
+
+	if _, err := srv.dashTurnStatsTyped(context.Background(), dashTurnStatsParams{TimeZone: "Mars/Olympus"}); apperrors.CodeOf(err) != errcode.InvalidInput {
+		t.Fatalf("invalid timezone err = %v", err)
+	}
+}
@@ -0,0 +1,292 @@
+// data_export.go — data/export: 按 agent 导出其全部数据 (数据主体访问请求)。
+//
+// data/export 只登记一次性下载令牌, 数据在 GET /data/export/download?token=... 时边查询边写入 zip,
+// 大量审计 / 追踪记录不在内存中整体驻留。压缩包内容 (JSONL 每行一条记录):
+//   - interactions.jsonl  agent 作为线程 / 发送方 / 接收方的交互记录
+//   - task_traces.jsonl   component = agent 的任务追踪 span
+//   - audit_events.jsonl  agent 作为操作者 / 目标的审计事件
+//   - bindings.jsonl      agent ↔ codex 线程绑定与 agent 状态
+//   - timeline.jsonl      UI 时间线
+//   - manifest.json       导出时间与各文件记录数 (最后写入)
+//
+// 令牌下载一次即失效, 过期未下载同样失效; 下载完成写入审计日志 (event_type=data_export)。
+package apiserver
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/store"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+const (
+	dataExportDownloadPath = "/data/export/download"
+	dataExportFormat       = "agent-data-export"
+	dataExportVersion      = 1
+	dataExportAuditType    = "data_export"
+	dataExportDefaultTTL   = 10 * time.Minute
+	dataExportMaxTTL       = time.Hour
+
+	dataExportManifest     = "manifest.json"
+	dataExportInteractions = "interactions.jsonl"
+	dataExportTaskTraces   = "task_traces.jsonl"
+	dataExportAuditEvents  = "audit_events.jsonl"
+	dataExportBindings     = "bindings.jsonl"
+	dataExportTimeline     = "timeline.jsonl"
+)
+
+// dataExportTicket 待下载的导出。
+type dataExportTicket struct {
+	AgentID   string
+	ExpiresAt time.Time
+}
+
+// dataExportTable 一次性下载令牌表。
+type dataExportTable struct {
+	mu      sync.Mutex
+	tickets map[string]dataExportTicket
+}
+
+// issue 登记导出并返回令牌 (顺带清理过期令牌)。
+func (t *dataExportTable) issue(agentID string, ttl time.Duration, now time.Time) (string, dataExportTicket, error) {
+	var b [24]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", dataExportTicket{}, err
+	}
+	token := hex.EncodeToString(b[:])
+	ticket := dataExportTicket{AgentID: agentID, ExpiresAt: now.Add(ttl)}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.tickets == nil {
+		t.tickets = make(map[string]dataExportTicket)
+	}
+	for key, existing := range t.tickets {
+		if now.After(existing.ExpiresAt) {
+			delete(t.tickets, key)
+		}
+	}
+	t.tickets[token] = ticket
+	return token, ticket, nil
+}
+
+// consume 取出令牌 (一次性); 不存在或已过期返回 false。
+func (t *dataExportTable) consume(token string, now time.Time) (dataExportTicket, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ticket, ok := t.tickets[token]
+	if !ok {
+		return dataExportTicket{}, false
+	}
+	delete(t.tickets, token)
+	if now.After(ticket.ExpiresAt) {
+		return dataExportTicket{}, false
+	}
+	return ticket, true
+}
+
+// ========================================
+// data/export
+// ========================================
+
+// dataExportParams data/export 请求参数。
+type dataExportParams struct {
+	AgentID string `json:"agentId"`
+	TTLSec  int    `json:"ttlSec,omitempty"` // 下载链接有效期, 0 = 10 分钟, 上限 1 小时
+}
+
+func (s *Server) dataExportTyped(_ context.Context, p dataExportParams) (any, error) {
+	agentID := strings.TrimSpace(p.AgentID)
+	if agentID == "" {
+		return nil, apperrors.NewCode("Server.dataExport", errcode.InvalidInput, "agentId is required")
+	}
+	ttl := dataExportDefaultTTL
+	if p.TTLSec > 0 {
+		ttl = min(time.Duration(p.TTLSec)*time.Second, dataExportMaxTTL)
+	}
+	token, ticket, err := s.dataExports.issue(agentID, ttl, time.Now())
+	if err != nil {
+		return nil, apperrors.Wrap(err, "Server.dataExport", "generate download token")
+	}
+	logger.Info("data/export: download issued", logger.FieldAgentID, agentID, "expires_at", ticket.ExpiresAt)
+	return map[string]any{
+		"agentId":   agentID,
+		"url":       dataExportDownloadPath + "?token=" + token,
+		"token":     token,
+		"expiresAt": ticket.ExpiresAt.UTC().Format(time.RFC3339),
+	}, nil
+}
+
+// dataExportManifestData 导出清单 (manifest.json)。
+type dataExportManifestData struct {
+	Format     string         `json:"format"`
+	Version    int            `json:"version"`
+	AgentID    string         `json:"agentId"`
+	ExportedAt string         `json:"exportedAt"`
+	Counts     map[string]int `json:"counts"`
+	Skipped    []string       `json:"skipped,omitempty"` // 无数据库时未导出的文件
+}
+
+// handleDataExportDownload 流式输出 agent 数据压缩包 (GET /data/export/download?token=...)。
+func (s *Server) handleDataExportDownload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ticket, ok := s.dataExports.consume(r.URL.Query().Get("token"), time.Now())
+	if !ok {
+		http.Error(w, "export link expired or already used", http.StatusGone)
+		return
+	}
+	filename := fmt.Sprintf("%s-export-%s.zip", sanitizeArchiveName(ticket.AgentID), time.Now().UTC().Format("20060102-150405"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Header().Set("Cache-Control", "no-store")
+
+	manifest, err := s.writeAgentDataExport(r.Context(), zip.NewWriter(w), ticket.AgentID)
+	if err != nil {
+		// 响应头已发出, 只能中断传输 (客户端得到不完整的 zip)。
+		logger.Warn("data/export: stream failed", logger.FieldAgentID, ticket.AgentID, logger.FieldRemote, r.RemoteAddr, logger.FieldError, err)
+		return
+	}
+	logger.Info("data/export: downloaded", logger.FieldAgentID, ticket.AgentID, logger.FieldRemote, r.RemoteAddr, "counts", manifest.Counts)
+	if s.auditLogStore != nil {
+		extra := make(map[string]any, len(manifest.Counts))
+		for name, n := range manifest.Counts {
+			extra[name] = n
+		}
+		event := &store.AuditEvent{
+			EventType: dataExportAuditType,
+			Action:    "download",
+			Result:    "ok",
+			Actor:     r.RemoteAddr,
+			Target:    ticket.AgentID,
+			Level:     "INFO",
+			Extra:     extra,
+		}
+		if err := s.auditLogStore.Append(context.Background(), event); err != nil {
+			logger.Warn("data/export: audit write failed", logger.FieldAgentID, ticket.AgentID, logger.FieldError, err)
+		}
+	}
+}
+
+// writeAgentDataExport 依次写入各 JSONL 文件与清单并关闭 zw。
+func (s *Server) writeAgentDataExport(ctx context.Context, zw *zip.Writer, agentID string) (dataExportManifestData, error) {
+	manifest := dataExportManifestData{
+		Format:     dataExportFormat,
+		Version:    dataExportVersion,
+		AgentID:    agentID,
+		ExportedAt: time.Now().UTC().Format(time.RFC3339),
+		Counts:     make(map[string]int),
+	}
+	sections := []struct {
+		name  string
+		needs bool // 依赖数据库
+		fill  func(emit func(any) error) error
+	}{
+		{dataExportInteractions, true, func(emit func(any) error) error {
+			return s.agentExportStore.EachInteraction(ctx, agentID, func(row store.Interaction) error { return emit(row) })
+		}},
+		{dataExportTaskTraces, true, func(emit func(any) error) error {
+			return s.agentExportStore.EachTaskTrace(ctx, agentID, func(row store.TaskTrace) error { return emit(row) })
+		}},
+		{dataExportAuditEvents, true, func(emit func(any) error) error {
+			return s.agentExportStore.EachAuditEvent(ctx, agentID, func(row store.AuditEvent) error { return emit(row) })
+		}},
+		{dataExportBindings, false, func(emit func(any) error) error {
+			return s.emitAgentBindings(ctx, agentID, emit)
+		}},
+		{dataExportTimeline, false, func(emit func(any) error) error {
+			if s.uiRuntime == nil {
+				return nil
+			}
+			for _, item := range s.uiRuntime.ThreadTimeline(agentID) {
+				if err := emit(item); err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+	}
+	for _, section := range sections {
+		if section.needs && s.agentExportStore == nil {
+			manifest.Skipped = append(manifest.Skipped, section.name)
+			continue
+		}
+		n, err := writeJSONLEntry(zw, section.name, section.fill)
+		if err != nil {
+			return manifest, apperrors.Wrapf(err, "Server.writeAgentDataExport", "export %s", section.name)
+		}
+		manifest.Counts[section.name] = n
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return manifest, apperrors.Wrap(err, "Server.writeAgentDataExport", "encode manifest")
+	}
+	w, err := zw.Create(dataExportManifest)
+	if err != nil {
+		return manifest, apperrors.Wrap(err, "Server.writeAgentDataExport", "create manifest")
+	}
+	if _, err := w.Write(data); err != nil {
+		return manifest, apperrors.Wrap(err, "Server.writeAgentDataExport", "write manifest")
+	}
+	if err := zw.Close(); err != nil {
+		return manifest, apperrors.Wrap(err, "Server.writeAgentDataExport", "finalize archive")
+	}
+	return manifest, nil
+}
+
+// emitAgentBindings 输出 codex 线程绑定与 agent 状态记录。
+func (s *Server) emitAgentBindings(ctx context.Context, agentID string, emit func(any) error) error {
+	if s.bindingStore != nil {
+		binding, err := s.bindingStore.FindByAgentID(ctx, agentID)
+		if err != nil {
+			return err
+		}
+		if binding != nil {
+			if err := emit(map[string]any{"kind": "codex_thread", "binding": binding}); err != nil {
+				return err
+			}
+		}
+	}
+	if s.agentStatusStore != nil {
+		status, err := s.agentStatusStore.Get(ctx, agentID)
+		if err != nil {
+			return err
+		}
+		if status != nil {
+			if err := emit(map[string]any{"kind": "agent_status", "status": status}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeJSONLEntry 创建 zip 条目并逐行写入 fill 产出的记录, 返回行数。
+func writeJSONLEntry(zw *zip.Writer, name string, fill func(emit func(any) error) error) (int, error) {
+	w, err := zw.Create(name)
+	if err != nil {
+		return 0, err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	count := 0
+	err = fill(func(row any) error {
+		count++
+		return enc.Encode(row)
+	})
+	return count, err
+}
@@ -0,0 +1,90 @@
+package apiserver
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/uistate"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+func TestDataExport_DownloadOnceWithoutDatabase(t *testing.T) {
+	srv := &Server{uiRuntime: uistate.NewRuntimeManager()}
+	srv.uiRuntime.AppendUserMessage("agent-7", "export me", nil)
+
+	if _, err := srv.dataExportTyped(context.Background(), dataExportParams{AgentID: " "}); apperrors.CodeOf(err) != errcode.InvalidInput {
+		t.Fatalf("blank agentId err = %v, want InvalidInput", err)
+	}
+	resp, err := srv.dataExportTyped(context.Background(), dataExportParams{AgentID: "agent-7"})
+	if err != nil {
+		t.Fatalf("data/export: %v", err)
+	}
+	url := resp.(map[string]any)["url"].(string)
+	if !strings.HasPrefix(url, dataExportDownloadPath+"?token=") {
+		t.Fatalf("url = %q", url)
+	}
+
+	rec := httptest.NewRecorder()
+	srv.handleDataExportDownload(rec, httptest.NewRequest(http.MethodGet, url, nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/zip" {
+		t.Fatalf("download status=%d content-type=%q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	body := rec.Body.Bytes()
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("open zip: %v", err)
+	}
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+		data, _ := io.ReadAll(rc)
+		_ = rc.Close()
+		files[f.Name] = string(data)
+	}
+	if !strings.Contains(files[dataExportTimeline], "export me") {
+		t.Fatalf("timeline.jsonl = %q", files[dataExportTimeline])
+	}
+	var manifest dataExportManifestData
+	if err := json.Unmarshal([]byte(files[dataExportManifest]), &manifest); err != nil {
+		t.Fatalf("manifest: %v", err)
+	}
+	if manifest.AgentID != "agent-7" || manifest.Counts[dataExportTimeline] != 1 || len(manifest.Skipped) != 3 {
+		t.Fatalf("manifest = %+v", manifest)
+	}
+	if _, ok := files[dataExportInteractions]; ok {
+		t.Fatal("database sections should be skipped without a store")
+	}
+
+	again := httptest.NewRecorder()
+	srv.handleDataExportDownload(again, httptest.NewRequest(http.MethodGet, url, nil))
+	if again.Code != http.StatusGone {
+		t.Fatalf("second download status = %d, want 410", again.Code)
+	}
+}
+
+func TestDataExportTable_ExpiredTokenRejected(t *testing.T) {
+	var table dataExportTable
+	now := time.Unix(1000, 0)
+	token, _, err := table.issue("agent-1", time.Minute, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := table.consume(token, now.Add(2*time.Minute)); ok {
+		t.Fatal("expired token should be rejected")
+	}
+	if _, ok := table.consume("unknown", now); ok {
+		t.Fatal("unknown token should be rejected")
+	}
+}
@@ -0,0 +1,190 @@
+// debug_trace.go — 延迟尖刺诊断: runtime/trace 采集 (debug/trace/start · debug/trace/stop) 与 pprof 调试端口。
+//
+//   - debug/trace/start 把执行追踪写入 logs/trace-<时间>-<序号>.out, 到时自动停止 (默认 30s, 最长 10 分钟);
+//     同一时刻只允许一次采集 (runtime/trace 全局唯一, 与 /debug/pprof/trace 互斥)。
+//   - StartPprofServer 由 --debug-pprof 开启, 仅允许监听回环地址, 暴露 net/http/pprof 全部端点。
+//
+// 分析: go tool trace logs/trace-*.out · go tool pprof http://127.0.0.1:6060/debug/pprof/profile
+package apiserver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"path/filepath"
+	"runtime/trace"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+	"github.com/multi-agent/go-agent-v2/pkg/util"
+)
+
+// DefaultPprofAddr --debug-pprof 默认监听地址。
+const DefaultPprofAddr = "127.0.0.1:6060"
+
+const (
+	defaultTraceDurationSec = 30
+	maxTraceDurationSec     = 600
+)
+
+// runtimeTraceState 进行中的 runtime/trace 采集 (零值可用)。
+type runtimeTraceState struct {
+	mu        sync.Mutex
+	file      *os.File
+	path      string
+	startedAt time.Time
+	timer     *time.Timer
+	gen       uint64 // 每次 start 递增; 自动停止只作用于同一代采集
+}
+
+// runtimeTraceResult 一次采集的结果。
+type runtimeTraceResult struct {
+	Path       string    `json:"path"`
+	StartedAt  time.Time `json:"startedAt"`
+	StopAt     time.Time `json:"stopAt,omitzero"` // start 响应: 计划自动停止时间
+	DurationMS int64     `json:"durationMs,omitempty"`
+	Bytes      int64     `json:"bytes,omitempty"`
+}
+
+type debugTraceStartParams struct {
+	DurationSec int `json:"durationSec,omitempty"` // 0 = 30
+}
+
+// debugTraceStartTyped debug/trace/start: 开始采集执行追踪, 到时自动停止。
+func (s *Server) debugTraceStartTyped(_ context.Context, p debugTraceStartParams) (any, error) {
+	const op = "Server.debugTraceStart"
+	duration := p.DurationSec
+	if duration == 0 {
+		duration = defaultTraceDurationSec
+	}
+	if duration < 0 || duration > maxTraceDurationSec {
+		return nil, apperrors.NewCodef(op, errcode.InvalidInput, "durationSec must be within [1, %d]", maxTraceDurationSec)
+	}
+
+	st := &s.runtimeTrace
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.file != nil {
+		return nil, apperrors.NewCodef(op, errcode.InvalidInput, "trace already running (%s)", st.path)
+	}
+	dir := absOrSelf(storageLogDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, apperrors.Wrap(err, op, "create log dir")
+	}
+	now := time.Now()
+	gen := st.gen + 1
+	path := filepath.Join(dir, fmt.Sprintf("trace-%s-%d.out", now.Format("20060102-150405.000"), gen))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, apperrors.Wrap(err, op, "create trace file")
+	}
+	if err := trace.Start(f); err != nil {
+		_ = f.Close()
+		_ = os.Remove(path)
+		return nil, apperrors.Wrap(err, op, "start runtime trace")
+	}
+	st.gen = gen
+	st.file, st.path, st.startedAt = f, path, now
+	st.timer = time.AfterFunc(time.Duration(duration)*time.Second, func() { s.autoStopRuntimeTrace(gen) })
+	logger.Info("debug/trace: started", logger.FieldPath, path, "duration_sec", duration)
+	return runtimeTraceResult{Path: path, StartedAt: now, StopAt: now.Add(time.Duration(duration) * time.Second)}, nil
+}
+
+// debugTraceStopTyped debug/trace/stop: 提前停止进行中的采集。
+func (s *Server) debugTraceStopTyped(_ context.Context, _ struct{}) (any, error) {
+	res, err := s.stopRuntimeTrace()
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("debug/trace: stopped", logger.FieldPath, res.Path, logger.FieldBytes, res.Bytes, logger.FieldDurationMS, res.DurationMS)
+	return res, nil
+}
+
+// autoStopRuntimeTrace 到时回调: 仅当进行中的仍是第 gen 次采集时停止
+// (手动 stop 后又重新 start 的新采集不受旧定时器影响)。
+func (s *Server) autoStopRuntimeTrace(gen uint64) {
+	st := &s.runtimeTrace
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.file == nil || st.gen != gen {
+		return
+	}
+	if res, err := s.stopRuntimeTraceLocked(); err == nil {
+		logger.Info("debug/trace: auto stopped", logger.FieldPath, res.Path, logger.FieldBytes, res.Bytes)
+	}
+}
+
+// stopRuntimeTrace 停止采集并关闭文件; 无进行中采集时返回错误。
+func (s *Server) stopRuntimeTrace() (runtimeTraceResult, error) {
+	st := &s.runtimeTrace
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return s.stopRuntimeTraceLocked()
+}
+
+// stopRuntimeTraceLocked 同 stopRuntimeTrace, 调用方需持有 runtimeTrace.mu。
+func (s *Server) stopRuntimeTraceLocked() (runtimeTraceResult, error) {
+	const op = "Server.stopRuntimeTrace"
+	st := &s.runtimeTrace
+	if st.file == nil {
+		return runtimeTraceResult{}, apperrors.NewCode(op, errcode.InvalidInput, "no trace is running")
+	}
+	st.timer.Stop()
+	trace.Stop()
+	res := runtimeTraceResult{Path: st.path, StartedAt: st.startedAt, DurationMS: time.Since(st.startedAt).Milliseconds()}
+	if info, err := st.file.Stat(); err == nil {
+		res.Bytes = info.Size()
+	}
+	closeErr := st.file.Close()
+	st.file, st.path, st.timer = nil, "", nil
+	if closeErr != nil {
+		return res, apperrors.Wrap(closeErr, op, "close trace file")
+	}
+	return res, nil
+}
+
+// StartPprofServer 在回环地址启动 pprof 调试端口 (--debug-pprof), ctx 结束时关闭。
+func StartPprofServer(ctx context.Context, addr string) error {
+	const op = "apiserver.StartPprofServer"
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return apperrors.Wrapf(err, op, "invalid address %q", addr)
+	}
+	if ip := net.ParseIP(host); !strings.EqualFold(host, "localhost") && (ip == nil || !ip.IsLoopback()) {
+		return apperrors.Newf(op, "pprof address %q must be a loopback address", addr)
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return apperrors.Wrapf(err, op, "listen %s", addr)
+	}
+	server := &http.Server{Handler: newPprofMux(), ReadHeaderTimeout: 10 * time.Second}
+	util.SafeGo(func() {
+		if err := server.Serve(ln); err != nil && err != http.ErrServerClosed {
+			logger.Error("debug-pprof: server failed", logger.FieldError, err)
+		}
+	})
+	util.SafeGo(func() {
+		<-ctx.Done()
+		_ = server.Close()
+	})
+	logger.Warn("debug-pprof: profiling endpoints enabled", logger.FieldURL, "http://"+ln.Addr().String()+"/debug/pprof/")
+	return nil
+}
+
+// newPprofMux 注册 net/http/pprof 端点 (不使用 DefaultServeMux, 避免被其他服务意外暴露)。
+func newPprofMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
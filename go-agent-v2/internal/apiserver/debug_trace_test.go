@@ -0,0 +1,110 @@
+package apiserver
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+func TestDebugTrace_StartStopWritesFile(t *testing.T) {
+	t.Chdir(t.TempDir())
+	srv := New(Deps{})
+	ctx := context.Background()
+
+	if _, err := srv.debugTraceStopTyped(ctx, struct{}{}); apperrors.CodeOf(err) != errcode.InvalidInput {
+		t.Fatalf("stop without trace err = %v", err)
+	}
+	if _, err := srv.debugTraceStartTyped(ctx, debugTraceStartParams{DurationSec: maxTraceDurationSec + 1}); apperrors.CodeOf(err) != errcode.InvalidInput {
+		t.Fatalf("too long duration err = %v", err)
+	}
+	started, err := srv.debugTraceStartTyped(ctx, debugTraceStartParams{DurationSec: 60})
+	if err != nil {
+		t.Fatalf("debug/trace/start: %v", err)
+	}
+	path := started.(runtimeTraceResult).Path
+	if filepath.Base(filepath.Dir(path)) != storageLogDir || !strings.HasPrefix(filepath.Base(path), "trace-") {
+		t.Fatalf("trace path = %s", path)
+	}
+	if _, err := srv.debugTraceStartTyped(ctx, debugTraceStartParams{}); err == nil {
+		t.Fatal("second concurrent trace should fail")
+	}
+
+	stopped, err := srv.debugTraceStopTyped(ctx, struct{}{})
+	if err != nil {
+		t.Fatalf("debug/trace/stop: %v", err)
+	}
+	res := stopped.(runtimeTraceResult)
+	info, err := os.Stat(path)
+	if err != nil || res.Path != path || info.Size() == 0 || res.Bytes != info.Size() {
+		t.Fatalf("stop result = %+v, stat = %v, %v", res, info, err)
+	}
+}
+
+func TestDebugTrace_StaleTimerDoesNotStopNewerTrace(t *testing.T) {
+	t.Chdir(t.TempDir())
+	srv := New(Deps{})
+	ctx := context.Background()
+
+	if _, err := srv.debugTraceStartTyped(ctx, debugTraceStartParams{DurationSec: 60}); err != nil {
+		t.Fatalf("first start: %v", err)
+	}
+	staleGen := srv.runtimeTrace.gen
+	if _, err := srv.debugTraceStopTyped(ctx, struct{}{}); err != nil {
+		t.Fatalf("manual stop: %v", err)
+	}
+	second, err := srv.debugTraceStartTyped(ctx, debugTraceStartParams{DurationSec: 60})
+	if err != nil {
+		t.Fatalf("second start: %v", err)
+	}
+	t.Cleanup(func() { _, _ = srv.stopRuntimeTrace() })
+
+	// 模拟第一次采集的定时器在手动 stop 之后才触发。
+	srv.autoStopRuntimeTrace(staleGen)
+	srv.runtimeTrace.mu.Lock()
+	path := srv.runtimeTrace.path
+	srv.runtimeTrace.mu.Unlock()
+	if path != second.(runtimeTraceResult).Path {
+		t.Fatalf("stale timer stopped the newer trace (running = %q)", path)
+	}
+
+	srv.autoStopRuntimeTrace(srv.runtimeTrace.gen)
+	if _, err := srv.debugTraceStopTyped(ctx, struct{}{}); apperrors.CodeOf(err) != errcode.InvalidInput {
+		t.Fatalf("current timer should stop the trace, stop err = %v", err)
+	}
+}
+
+func TestStartPprofServer_LoopbackOnly(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := StartPprofServer(ctx, "0.0.0.0:0"); err == nil {
+		t.Fatal("non-loopback address should be rejected")
+	}
+
+	// 先占用一个回环端口再释放, 取得可用地址。
+	ln, err := (&net.ListenConfig{}).Listen(ctx, "tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	_ = ln.Close()
+	if err := StartPprofServer(ctx, addr); err != nil {
+		t.Fatalf("StartPprofServer: %v", err)
+	}
+	resp, err := http.Get("http://" + addr + "/debug/pprof/cmdline")
+	if err != nil {
+		t.Fatalf("GET cmdline: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || len(body) == 0 {
+		t.Fatalf("cmdline status = %d body = %q", resp.StatusCode, body)
+	}
+}
@@ -0,0 +1,169 @@
+// dynamic_tool_cache.go — 确定性动态工具结果缓存。
+//
+// 只读工具 (lsp_hover / lsp_definition / lsp_references / shared_file_read 等)
+// 在长 agent 循环中常被重复调用。结果按 (工具名 + 规范化参数 + 工作区修订号) 缓存:
+//   - 文件变更事件 / 写类工具 / code_run 会递增修订号, 旧结果自动失效
+//   - 失败结果不缓存
+//   - TTL + 容量上限兜底
+package apiserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultToolCacheTTL        = 5 * time.Minute
+	defaultToolCacheMaxEntries = 512
+)
+
+// cacheableDynamicTools 结果仅依赖输入与工作区内容的只读工具。
+var cacheableDynamicTools = map[string]bool{
+	"lsp_hover":            true,
+	"lsp_definition":       true,
+	"lsp_references":       true,
+	"lsp_document_symbol":  true,
+	"lsp_workspace_symbol": true,
+	"lsp_implementation":   true,
+	"lsp_call_hierarchy":   true,
+	"lsp_type_hierarchy":   true,
+	"lsp_semantic_tokens":  true,
+	"lsp_folding_range":    true,
+	"lsp_signature_help":   true,
+	"shared_file_read":     true,
+}
+
+// workspaceMutatingTools 调用后需使缓存失效的写类工具。
+var workspaceMutatingTools = map[string]bool{
+	"lsp_did_change":      true,
+	"lsp_rename":          true,
+	"lsp_format":          true,
+	"lsp_code_action":     true,
+	"shared_file_write":   true,
+	"workspace_merge_run": true,
+	"code_run":            true,
+	"code_run_test":       true,
+}
+
+type toolCacheEntry struct {
+	result    string
+	expiresAt time.Time
+}
+
+// toolResultCache 动态工具结果缓存 (nil 接收者安全, 等价于禁用)。
+type toolResultCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	revision   uint64
+	entries    map[string]toolCacheEntry
+}
+
+func newToolResultCache(ttl time.Duration, maxEntries int) *toolResultCache {
+	if ttl <= 0 {
+		return nil
+	}
+	if maxEntries <= 0 {
+		maxEntries = defaultToolCacheMaxEntries
+	}
+	return &toolResultCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]toolCacheEntry),
+	}
+}
+
+// toolCacheKey 工具名 + 规范化参数 (JSON 重新编码, 消除字段顺序/空白差异)。
+func toolCacheKey(tool string, args json.RawMessage, revision uint64) string {
+	canonical := bytes.TrimSpace(args)
+	var decoded any
+	if len(canonical) > 0 && json.Unmarshal(canonical, &decoded) == nil {
+		if normalized, err := json.Marshal(decoded); err == nil {
+			canonical = normalized
+		}
+	}
+	return tool + "\x00" + strconv.FormatUint(revision, 10) + "\x00" + string(canonical)
+}
+
+// get 查询缓存; 返回当前修订号供 put 校验 (不可缓存的工具直接返回 miss)。
+func (c *toolResultCache) get(tool string, args json.RawMessage, now time.Time) (string, uint64, bool) {
+	if c == nil || !cacheableDynamicTools[tool] {
+		return "", 0, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := toolCacheKey(tool, args, c.revision)
+	entry, ok := c.entries[key]
+	if !ok || !now.Before(entry.expiresAt) {
+		delete(c.entries, key)
+		return "", c.revision, false
+	}
+	return entry.result, c.revision, true
+}
+
+// put 写入成功结果; 执行期间修订号已变化 (工作区被修改) 时丢弃。
+func (c *toolResultCache) put(tool string, args json.RawMessage, result string, revision uint64, now time.Time) {
+	if c == nil || !cacheableDynamicTools[tool] || !toolResultSuccess(result) {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if revision != c.revision {
+		return
+	}
+	if len(c.entries) >= c.maxEntries {
+		c.evictLocked(now)
+	}
+	c.entries[toolCacheKey(tool, args, revision)] = toolCacheEntry{result: result, expiresAt: now.Add(c.ttl)}
+}
+
+// invalidate 递增工作区修订号并清空旧结果。
+func (c *toolResultCache) invalidate() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.revision++
+	clear(c.entries)
+}
+
+func (c *toolResultCache) evictLocked(now time.Time) {
+	for key, entry := range c.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+	// 仍然超限: 任意淘汰一半 (缓存只是加速, 不追求精确 LRU)。
+	if len(c.entries) >= c.maxEntries {
+		drop := len(c.entries) / 2
+		for key := range c.entries {
+			if drop <= 0 {
+				break
+			}
+			delete(c.entries, key)
+			drop--
+		}
+	}
+}
+
+// invokeCachedDynamicTool 命中缓存时直接返回, 否则调用 handler 并写入缓存。
+//
+// 写类工具执行后使缓存失效。
+func (s *Server) invokeCachedDynamicTool(tool string, args json.RawMessage, handler func(json.RawMessage) string) (string, bool) {
+	now := time.Now()
+	result, revision, ok := s.toolCache.get(tool, args, now)
+	if ok {
+		return result, true
+	}
+	result = handler(args)
+	if workspaceMutatingTools[tool] {
+		s.toolCache.invalidate()
+	} else {
+		s.toolCache.put(tool, args, result, revision, now)
+	}
+	return result, false
+}
@@ -0,0 +1,66 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestToolResultCacheHitsAndInvalidates(t *testing.T) {
+	srv := &Server{toolCache: newToolResultCache(time.Minute, 8)}
+	calls := 0
+	hover := func(json.RawMessage) string {
+		calls++
+		return "func Foo()"
+	}
+
+	if _, cached := srv.invokeCachedDynamicTool("lsp_hover", json.RawMessage(`{"file_path":"a.go","line":1}`), hover); cached {
+		t.Fatal("first call should miss")
+	}
+	result, cached := srv.invokeCachedDynamicTool("lsp_hover", json.RawMessage(`{ "line":1, "file_path":"a.go" }`), hover)
+	if !cached || result != "func Foo()" || calls != 1 {
+		t.Fatalf("reordered args should hit cache: cached=%v calls=%d", cached, calls)
+	}
+
+	srv.invokeCachedDynamicTool("lsp_did_change", json.RawMessage(`{"file_path":"a.go"}`), func(json.RawMessage) string { return "ok" })
+	if _, cached := srv.invokeCachedDynamicTool("lsp_hover", json.RawMessage(`{"file_path":"a.go","line":1}`), hover); cached || calls != 2 {
+		t.Fatalf("mutating tool should invalidate cache: cached=%v calls=%d", cached, calls)
+	}
+}
+
+func TestToolResultCacheSkipsFailuresAndUncacheableTools(t *testing.T) {
+	cache := newToolResultCache(time.Minute, 8)
+	now := time.Now()
+	args := json.RawMessage(`{"file_path":"a.go"}`)
+
+	_, rev, _ := cache.get("lsp_hover", args, now)
+	cache.put("lsp_hover", args, "error: no server", rev, now)
+	if _, _, ok := cache.get("lsp_hover", args, now); ok {
+		t.Fatal("failed result should not be cached")
+	}
+
+	cache.put("lsp_completion", args, "items", rev, now)
+	if _, _, ok := cache.get("lsp_completion", args, now); ok {
+		t.Fatal("non-deterministic tool should not be cached")
+	}
+
+	cache.invalidate()
+	cache.put("lsp_hover", args, "stale", rev, now)
+	if _, _, ok := cache.get("lsp_hover", args, now); ok {
+		t.Fatal("result computed before invalidation should be dropped")
+	}
+
+	_, rev, _ = cache.get("lsp_hover", args, now)
+	cache.put("lsp_hover", args, "fresh", rev, now)
+	if _, _, ok := cache.get("lsp_hover", args, now.Add(2*time.Minute)); ok {
+		t.Fatal("expired entry should miss")
+	}
+
+	var disabled *toolResultCache
+	if _, _, ok := disabled.get("lsp_hover", args, now); ok {
+		t.Fatal("nil cache should always miss")
+	}
+	if newToolResultCache(0, 8) != nil {
+		t.Fatal("zero ttl should disable cache")
+	}
+}
@@ -0,0 +1,436 @@
+// dynamic_tool_registry.go — 可插拔动态工具 (tools/list, tools/setEnabled, tools/reload)。
+//
+// 内置工具 (LSP / 编排 / 资源 / 代码执行 / 知识库) 之外, 注册表汇集三类外部工具:
+// 构建标签模块、DYNAMIC_TOOLS_FILE 声明的外部命令、gRPC 插件与 WASM 沙箱插件 (见 internal/toolreg);
+// 另按线程工作目录加载项目 .agent/tools.yaml 声明的 HTTP 工具 (按文件修改时间缓存)。
+// 与内置工具重名的外部工具被忽略, 优先级: 内置 > 注册表 > 项目 HTTP 工具。线程级禁用列表持久化在 UI 偏好
+// settings.threadTools ({threadId: [tool]}): 恢复线程时不再注入, 调用时拒绝。
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/codex"
+	"github.com/multi-agent/go-agent-v2/internal/toolreg"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+const (
+	prefKeyThreadTools = "settings.threadTools"
+
+	toolRegistryLoadTimeout = 30 * time.Second
+	registryToolCallTimeout = 10 * time.Minute
+)
+
+// dynamicToolsFile 配置文件路径 (配置优先, 默认 ~/.multi-agent/dynamic-tools.json)。
+func (s *Server) dynamicToolsFile() (string, error) {
+	if s.cfg != nil {
+		if path := strings.TrimSpace(s.cfg.DynamicToolsFile); path != "" {
+			return path, nil
+		}
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", apperrors.Wrap(err, "Server.dynamicToolsFile", "resolve user home")
+	}
+	return filepath.Join(homeDir, ".multi-agent", "dynamic-tools.json"), nil
+}
+
+// reloadDynamicToolRegistry 重新加载模块 / 命令 / 插件工具; 单个插件失败只记录日志。
+func (s *Server) reloadDynamicToolRegistry(ctx context.Context) error {
+	const op = "Server.reloadDynamicToolRegistry"
+	reg := &s.dynToolRegistry
+
+	for name, tools := range toolreg.ModuleTools() {
+		reg.SetGroup(toolreg.SourceModule+":"+name, tools)
+	}
+
+	path, err := s.dynamicToolsFile()
+	if err != nil {
+		return err
+	}
+	cfg, err := toolreg.LoadConfig(path)
+	if err != nil {
+		return apperrors.Wrap(err, op, "load dynamic tools file")
+	}
+	if cfg == nil {
+		cfg = &toolreg.Config{}
+	}
+	commandTools, err := cfg.CommandTools(path)
+	if err != nil {
+		return apperrors.Wrap(err, op, "build command tools")
+	}
+
+	// 先清除旧的命令 / 插件分组 (插件可能已从配置移除)
+	for _, group := range reg.Groups() {
+		if !strings.HasPrefix(group, toolreg.SourceModule+":") {
+			reg.SetGroup(group, nil)
+		}
+	}
+	reg.SetGroup(toolreg.SourceCommand+":"+path, commandTools)
+
+	loadCtx, cancel := context.WithTimeout(ctx, toolRegistryLoadTimeout)
+	defer cancel()
+	for _, plugin := range cfg.Plugins {
+		tools, err := toolreg.LoadPlugin(loadCtx, plugin)
+		if err != nil {
+			logger.Warn("dynamic tools: load plugin failed", logger.FieldName, plugin.Name, logger.FieldError, err)
+			continue
+		}
+		reg.SetGroup(toolreg.SourceGRPC+":"+plugin.Name, tools)
+	}
+	var wasmPlugins []*toolreg.WASMPlugin
+	for _, spec := range cfg.WASM {
+		if spec.Path != "" && !filepath.IsAbs(spec.Path) {
+			spec.Path = filepath.Join(filepath.Dir(path), spec.Path)
+		}
+		plugin, err := toolreg.LoadWASM(loadCtx, spec)
+		if err != nil {
+			logger.Warn("dynamic tools: load wasm plugin failed", logger.FieldName, spec.Name, logger.FieldError, err)
+			continue
+		}
+		wasmPlugins = append(wasmPlugins, plugin)
+		reg.SetGroup(toolreg.SourceWASM+":"+spec.Name, plugin.Tools())
+	}
+	s.wasmPlugins.replace(wasmPlugins)
+	logger.Info("dynamic tools: registry loaded",
+		"groups", len(reg.Groups()),
+		"tools", len(reg.Tools()),
+		logger.FieldPath, path,
+	)
+	return nil
+}
+
+// wasmPluginSet 当前加载的 WASM 插件运行时 (重载时关闭旧实例)。
+type wasmPluginSet struct {
+	mu      sync.Mutex
+	plugins []*toolreg.WASMPlugin
+}
+
+func (w *wasmPluginSet) replace(plugins []*toolreg.WASMPlugin) {
+	w.mu.Lock()
+	old := w.plugins
+	w.plugins = plugins
+	w.mu.Unlock()
+	for _, plugin := range old {
+		plugin.Close(context.Background())
+	}
+}
+
+// builtinToolNames 内置工具名集合 (外部工具不得覆盖)。
+func (s *Server) builtinToolNames() map[string]bool {
+	names := map[string]bool{}
+	for _, tool := range s.buildBuiltinDynamicTools() {
+		names[tool.Name] = true
+	}
+	for name := range s.dynTools {
+		names[name] = true
+	}
+	return names
+}
+
+// registryTools 注册表工具 (剔除与内置工具重名者)。
+func (s *Server) registryTools() []toolreg.Tool {
+	builtin := s.builtinToolNames()
+	var out []toolreg.Tool
+	for _, tool := range s.dynToolRegistry.Tools() {
+		if builtin[tool.Name] {
+			continue
+		}
+		out = append(out, tool)
+	}
+	return out
+}
+
+// lookupRegistryTool 查找可调用的注册表工具。
+func (s *Server) lookupRegistryTool(name string) (toolreg.Tool, bool) {
+	if s.builtinToolNames()[name] {
+		return toolreg.Tool{}, false
+	}
+	return s.dynToolRegistry.Lookup(name)
+}
+
+func registryDynamicTools(tools []toolreg.Tool) []codex.DynamicTool {
+	out := make([]codex.DynamicTool, 0, len(tools))
+	for _, tool := range tools {
+		out = append(out, codex.DynamicTool{Name: tool.Name, Description: tool.Description, InputSchema: tool.InputSchema})
+	}
+	return out
+}
+
+// buildDynamicToolsForThread 线程启动时注入的动态工具: 全部工具 + 项目 HTTP 工具, 剔除线程禁用项。
+func (s *Server) buildDynamicToolsForThread(threadID, cwd string) []codex.DynamicTool {
+	tools := append(s.buildAllDynamicTools(), registryDynamicTools(s.projectTools(cwd))...)
+	ctx, cancel := context.WithTimeout(context.Background(), threadEnvLoadTimeout)
+	defer cancel()
+	disabled := s.loadThreadDisabledTools(ctx)[strings.TrimSpace(threadID)]
+	if len(disabled) == 0 {
+		return tools
+	}
+	skip := make(map[string]bool, len(disabled))
+	for _, name := range disabled {
+		skip[name] = true
+	}
+	out := make([]codex.DynamicTool, 0, len(tools))
+	for _, tool := range tools {
+		if !skip[tool.Name] {
+			out = append(out, tool)
+		}
+	}
+	return out
+}
+
+// callRegistryTool 执行注册表工具, 错误转为工具错误结果。
+func (s *Server) callRegistryTool(tool toolreg.Tool, agentID, callID string, args json.RawMessage) string {
+	ctx, cancel := context.WithTimeout(context.Background(), registryToolCallTimeout)
+	defer cancel()
+	result, err := tool.Handler(ctx, toolreg.Call{AgentID: agentID, CallID: callID, Arguments: args})
+	if err != nil {
+		return toolError(err)
+	}
+	return result
+}
+
+// ========================================
+// 项目 HTTP 工具 (.agent/tools.yaml)
+// ========================================
+
+type projectToolEntry struct {
+	modTime time.Time
+	tools   []toolreg.Tool
+}
+
+// projectToolCache 按项目目录缓存 .agent/tools.yaml 解析结果 (文件修改时间变化即重新加载)。
+type projectToolCache struct {
+	mu      sync.Mutex
+	entries map[string]projectToolEntry
+}
+
+func (c *projectToolCache) load(rootDir string) []toolreg.Tool {
+	path := filepath.Join(rootDir, toolreg.ProjectToolsFile)
+	info, err := os.Stat(path)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		delete(c.entries, rootDir)
+		return nil
+	}
+	if entry, ok := c.entries[rootDir]; ok && entry.modTime.Equal(info.ModTime()) {
+		return entry.tools
+	}
+	tools, err := toolreg.LoadProjectTools(rootDir)
+	if err != nil {
+		logger.Warn("dynamic tools: project tools ignored", logger.FieldPath, path, logger.FieldError, err)
+		tools = nil
+	}
+	if c.entries == nil {
+		c.entries = make(map[string]projectToolEntry)
+	}
+	c.entries[rootDir] = projectToolEntry{modTime: info.ModTime(), tools: tools}
+	return tools
+}
+
+// projectTools 工作目录下的项目 HTTP 工具 (剔除与内置 / 注册表工具重名者)。
+func (s *Server) projectTools(cwd string) []toolreg.Tool {
+	cwd = strings.TrimSpace(cwd)
+	if cwd == "" {
+		return nil
+	}
+	tools := s.projectToolCache.load(cwd)
+	if len(tools) == 0 {
+		return nil
+	}
+	taken := s.builtinToolNames()
+	for _, tool := range s.dynToolRegistry.Tools() {
+		taken[tool.Name] = true
+	}
+	var out []toolreg.Tool
+	for _, tool := range tools {
+		if taken[tool.Name] {
+			continue
+		}
+		taken[tool.Name] = true
+		out = append(out, tool)
+	}
+	return out
+}
+
+// lookupProjectTool 按线程工作目录查找项目 HTTP 工具。
+func (s *Server) lookupProjectTool(threadID, name string) (toolreg.Tool, bool) {
+	for _, tool := range s.projectTools(s.getAgentWorkDir(threadID)) {
+		if tool.Name == name {
+			return tool, true
+		}
+	}
+	return toolreg.Tool{}, false
+}
+
+// ========================================
+// 线程级禁用
+// ========================================
+
+func (s *Server) loadThreadDisabledTools(ctx context.Context) map[string][]string {
+	out := map[string][]string{}
+	if s.prefManager == nil {
+		return out
+	}
+	value, err := s.prefManager.Get(ctx, prefKeyThreadTools)
+	if err != nil {
+		logger.Warn("dynamic tools: load preference failed", logger.FieldError, err)
+		return out
+	}
+	if value == nil {
+		return out
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return out
+	}
+	if err := json.Unmarshal(raw, &out); err != nil || out == nil {
+		return map[string][]string{}
+	}
+	return out
+}
+
+// threadToolDisabledError 工具被线程禁用时返回错误。
+func (s *Server) threadToolDisabledError(ctx context.Context, threadID, tool string) error {
+	for _, name := range s.loadThreadDisabledTools(ctx)[threadID] {
+		if name == tool {
+			return apperrors.NewCodef("Server.handleDynamicToolCall", errcode.ToolNotAllowed, "tool %s is disabled for thread %s", tool, threadID)
+		}
+	}
+	return nil
+}
+
+// ========================================
+// JSON-RPC
+// ========================================
+
+type toolView struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Source      string         `json:"source"`
+	Origin      string         `json:"origin,omitempty"`
+	InputSchema map[string]any `json:"inputSchema"`
+	Enabled     bool           `json:"enabled"`
+}
+
+type toolsListParams struct {
+	ThreadID string `json:"threadId,omitempty"`
+	Cwd      string `json:"cwd,omitempty"` // 项目目录 (默认取线程工作目录)
+}
+
+func (s *Server) toolsListTyped(ctx context.Context, p toolsListParams) (any, error) {
+	threadID := strings.TrimSpace(p.ThreadID)
+	disabled := map[string]bool{}
+	if threadID != "" {
+		for _, name := range s.loadThreadDisabledTools(ctx)[threadID] {
+			disabled[name] = true
+		}
+	}
+	cwd := strings.TrimSpace(p.Cwd)
+	if cwd == "" && threadID != "" {
+		cwd = s.getAgentWorkDir(threadID)
+	}
+	builtin := s.buildBuiltinDynamicTools()
+	registry := append(s.registryTools(), s.projectTools(cwd)...)
+	views := make([]toolView, 0, len(builtin)+len(registry))
+	for _, tool := range builtin {
+		views = append(views, toolView{
+			Name:        tool.Name,
+			Description: tool.Description,
+			Source:      toolreg.SourceBuiltin,
+			InputSchema: tool.InputSchema,
+			Enabled:     !disabled[tool.Name],
+		})
+	}
+	for _, tool := range registry {
+		views = append(views, toolView{
+			Name:        tool.Name,
+			Description: tool.Description,
+			Source:      tool.Source,
+			Origin:      tool.Origin,
+			InputSchema: tool.InputSchema,
+			Enabled:     !disabled[tool.Name],
+		})
+	}
+	return map[string]any{"threadId": threadID, "tools": views}, nil
+}
+
+type toolsSetEnabledParams struct {
+	ThreadID string   `json:"threadId"`
+	Tools    []string `json:"tools"`
+	Enabled  bool     `json:"enabled"`
+}
+
+func (s *Server) toolsSetEnabledTyped(ctx context.Context, p toolsSetEnabledParams) (any, error) {
+	const op = "Server.toolsSetEnabled"
+	if s.prefManager == nil {
+		return nil, apperrors.New(op, "preference manager not initialized")
+	}
+	threadID := strings.TrimSpace(p.ThreadID)
+	if threadID == "" {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "threadId is required")
+	}
+	if len(p.Tools) == 0 {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "tools is required")
+	}
+
+	s.threadEnvMu.Lock()
+	defer s.threadEnvMu.Unlock()
+	all := s.loadThreadDisabledTools(ctx)
+	set := map[string]bool{}
+	for _, name := range all[threadID] {
+		set[name] = true
+	}
+	for _, name := range p.Tools {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if p.Enabled {
+			delete(set, name)
+		} else {
+			set[name] = true
+		}
+	}
+	disabled := make([]string, 0, len(set))
+	for name := range set {
+		disabled = append(disabled, name)
+	}
+	sort.Strings(disabled)
+	if len(disabled) == 0 {
+		delete(all, threadID)
+	} else {
+		all[threadID] = disabled
+	}
+	if err := s.prefManager.Set(ctx, prefKeyThreadTools, all); err != nil {
+		return nil, err
+	}
+	logger.Info("tools/setEnabled: saved",
+		logger.FieldThreadID, threadID,
+		"tools", p.Tools,
+		"enabled", p.Enabled,
+		"disabled_count", len(disabled),
+	)
+	return map[string]any{"threadId": threadID, "disabled": disabled}, nil
+}
+
+func (s *Server) toolsReload(ctx context.Context, _ json.RawMessage) (any, error) {
+	if err := s.reloadDynamicToolRegistry(ctx); err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"groups": s.dynToolRegistry.Groups(),
+		"tools":  len(s.registryTools()),
+	}, nil
+}
@@ -0,0 +1,128 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/multi-agent/go-agent-v2/internal/config"
+	"github.com/multi-agent/go-agent-v2/internal/toolreg"
+	"github.com/multi-agent/go-agent-v2/internal/uistate"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+func TestDynamicToolRegistryLoadsCommandTools(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dynamic-tools.json")
+	spec := `{"tools": [
+		{"name": "shout", "description": "upper-case text", "command": ["sh", "-c", "tr a-z A-Z"]},
+		{"name": "orchestration_list_agents", "description": "collides with builtin", "command": ["true"]}
+	]}`
+	if err := os.WriteFile(path, []byte(spec), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	srv := &Server{cfg: &config.Config{DynamicToolsFile: path}, dynTools: map[string]func(json.RawMessage) string{}}
+	srv.registerDynamicTools()
+	if err := srv.reloadDynamicToolRegistry(context.Background()); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+
+	names := map[string]int{}
+	for _, tool := range srv.buildAllDynamicTools() {
+		names[tool.Name]++
+	}
+	if names["shout"] != 1 || names["orchestration_list_agents"] != 1 {
+		t.Fatalf("tools = %v, want shout once and builtin once", names)
+	}
+
+	tool, ok := srv.lookupRegistryTool("shout")
+	if !ok || tool.Source != toolreg.SourceCommand || tool.Origin != path {
+		t.Fatalf("lookup shout = %+v, %v", tool, ok)
+	}
+	if got := srv.callRegistryTool(tool, "thread-1", "call-1", []byte(`{"text":"hi"}`)); got != `{"TEXT":"HI"}` {
+		t.Fatalf("result = %q", got)
+	}
+	if _, ok := srv.lookupRegistryTool("orchestration_list_agents"); ok {
+		t.Fatal("builtin name must not resolve to registry tool")
+	}
+}
+
+func TestToolsSetEnabledFiltersThreadTools(t *testing.T) {
+	srv := &Server{prefManager: uistate.NewPreferenceManager(nil)}
+	ctx := context.Background()
+
+	res, err := srv.toolsSetEnabledTyped(ctx, toolsSetEnabledParams{ThreadID: "thread-1", Tools: []string{"orchestration_list_agents", "kb_search"}})
+	if err != nil {
+		t.Fatalf("disable: %v", err)
+	}
+	if disabled := res.(map[string]any)["disabled"].([]string); strings.Join(disabled, ",") != "kb_search,orchestration_list_agents" {
+		t.Fatalf("disabled = %v", disabled)
+	}
+	if _, err := srv.toolsSetEnabledTyped(ctx, toolsSetEnabledParams{ThreadID: "thread-1", Tools: []string{"kb_search"}, Enabled: true}); err != nil {
+		t.Fatalf("enable: %v", err)
+	}
+
+	list, err := srv.toolsListTyped(ctx, toolsListParams{ThreadID: "thread-1"})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	found := false
+	for _, view := range list.(map[string]any)["tools"].([]toolView) {
+		if view.Name == "orchestration_list_agents" {
+			found = true
+			if view.Enabled || view.Source != toolreg.SourceBuiltin {
+				t.Fatalf("orchestration_list_agents view = %+v", view)
+			}
+		} else if !view.Enabled {
+			t.Fatalf("%s unexpectedly disabled", view.Name)
+		}
+	}
+	if !found {
+		t.Fatal("orchestration_list_agents missing from tools/list")
+	}
+
+	for _, tool := range srv.buildDynamicToolsForThread("thread-1", "") {
+		if tool.Name == "orchestration_list_agents" {
+			t.Fatal("disabled tool injected into thread")
+		}
+	}
+	if err := srv.threadToolDisabledError(ctx, "thread-1", "orchestration_list_agents"); apperrors.CodeOf(err) != errcode.ToolNotAllowed {
+		t.Fatalf("call err = %v, want TOOL_NOT_ALLOWED", err)
+	}
+	if err := srv.threadToolDisabledError(ctx, "thread-2", "orchestration_list_agents"); err != nil {
+		t.Fatalf("other thread err = %v", err)
+	}
+}
+
+func TestProjectHTTPToolsFollowThreadCwd(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, ".agent"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	doc := "tools:\n  - name: ticket_create\n    url: http://127.0.0.1:1/tickets\n  - name: orchestration_list_agents\n    url: http://127.0.0.1:1/shadow\n"
+	if err := os.WriteFile(filepath.Join(root, toolreg.ProjectToolsFile), []byte(doc), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	srv := &Server{agentWorkDirs: map[string]string{}}
+	srv.setAgentWorkDir("thread-1", root)
+
+	names := map[string]int{}
+	for _, tool := range srv.buildDynamicToolsForThread("thread-1", root) {
+		names[tool.Name]++
+	}
+	if names["ticket_create"] != 1 || names["orchestration_list_agents"] != 1 {
+		t.Fatalf("tools = %v", names)
+	}
+	if tool, ok := srv.lookupProjectTool("thread-1", "ticket_create"); !ok || tool.Source != toolreg.SourceHTTP {
+		t.Fatalf("lookup = %+v, %v", tool, ok)
+	}
+	if _, ok := srv.lookupProjectTool("thread-2", "ticket_create"); ok {
+		t.Fatal("thread without project cwd resolved project tool")
+	}
+	if _, ok := srv.lookupProjectTool("thread-1", "orchestration_list_agents"); ok {
+		t.Fatal("builtin name resolved to project tool")
+	}
+}
@@ -0,0 +1,376 @@
+// emergency_stop.go — 紧急停止开关 (orchestrator/emergencyStop, orchestrator/unlock, orchestrator/status)。
+//
+// 触发后立即中断所有进行中的 turn 并取消 code_run, 冻结文件写入类操作
+// (fileChange / commandExecution 审批自动拒绝, workspace/run/merge 与 dynamic tool 调用被拒),
+// 拒绝新的 turn/start 与 turn/steer, 调度器与静默时段放行的 turn 暂存到解锁后再派发。
+// 运行时状态 (agent、进行中 turn、调度队列、暂存 turn、UI 快照) 写入磁盘;
+// 锁文件跨进程重启保留, 必须显式 orchestrator/unlock 才能恢复。
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/runner"
+	"github.com/multi-agent/go-agent-v2/internal/uistate"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+	"github.com/multi-agent/go-agent-v2/pkg/util"
+)
+
+// emergencyLockFile 锁文件名 (位于快照目录, 存在即视为锁定)。
+const emergencyLockFile = "emergency-lock.json"
+
+// emergencyLock 锁定状态 (同时作为锁文件内容)。
+type emergencyLock struct {
+	Reason       string    `json:"reason"`
+	By           string    `json:"by,omitempty"`
+	StoppedAt    time.Time `json:"stoppedAt"`
+	SnapshotPath string    `json:"snapshotPath,omitempty"`
+}
+
+// deferredTurn 锁定期间被拦下的排队 turn, 解锁后重新派发。
+type deferredTurn struct {
+	ID   string
+	turn preparedTurn
+}
+
+// emergencyState 紧急停止状态。
+type emergencyState struct {
+	mu       sync.Mutex
+	lock     *emergencyLock
+	deferred []deferredTurn
+}
+
+// engage 进入锁定; 已锁定时返回 false 与现有锁。
+func (e *emergencyState) engage(lock emergencyLock) (emergencyLock, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.lock != nil {
+		return *e.lock, false
+	}
+	e.lock = &lock
+	return lock, true
+}
+
+func (e *emergencyState) setSnapshotPath(path string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.lock != nil {
+		e.lock.SnapshotPath = path
+	}
+}
+
+// current 返回当前锁 (未锁定时 ok=false)。
+func (e *emergencyState) current() (emergencyLock, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.lock == nil {
+		return emergencyLock{}, false
+	}
+	return *e.lock, true
+}
+
+// deferTurn 锁定期间暂存排队 turn; 未锁定时返回 false (调用方应直接派发)。
+func (e *emergencyState) deferTurn(id string, turn preparedTurn) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.lock == nil {
+		return false
+	}
+	e.deferred = append(e.deferred, deferredTurn{ID: id, turn: turn})
+	return true
+}
+
+// release 解除锁定, 返回此前的锁与暂存 turn。
+func (e *emergencyState) release() (emergencyLock, []deferredTurn, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.lock == nil {
+		return emergencyLock{}, nil, false
+	}
+	lock := *e.lock
+	deferred := e.deferred
+	e.lock = nil
+	e.deferred = nil
+	return lock, deferred, true
+}
+
+// frozenError 锁定时返回统一错误, 未锁定返回 nil。
+func (s *Server) frozenError(op string) error {
+	lock, locked := s.emergency.current()
+	if !locked {
+		return nil
+	}
+	return apperrors.NewCodef(op, errcode.EmergencyLocked, "emergency stop active since %s (%s); call orchestrator/unlock to resume",
+		lock.StoppedAt.Format(time.RFC3339), lock.Reason)
+}
+
+// ========================================
+// 状态快照
+// ========================================
+
+// emergencyTurnView 快照中的进行中 turn。
+type emergencyTurnView struct {
+	ThreadID  string    `json:"threadId"`
+	TurnID    string    `json:"turnId"`
+	StartedAt time.Time `json:"startedAt"`
+}
+
+// emergencySnapshot 紧急停止时写入磁盘的运行时状态。
+type emergencySnapshot struct {
+	emergencyLock
+	Agents      []runner.AgentInfo       `json:"agents"`
+	ActiveTurns []emergencyTurnView      `json:"activeTurns"`
+	Interrupted []string                 `json:"interrupted"`
+	Running     []scheduledTurn          `json:"schedulerRunning"`
+	Queued      []queuedTurn             `json:"schedulerQueued"`
+	Held        []heldTurn               `json:"quietHoursHeld"`
+	UI          *uistate.RuntimeSnapshot `json:"ui,omitempty"`
+}
+
+// emergencySnapshotDir 快照与锁文件目录 (配置优先, 默认 ~/.multi-agent/emergency)。
+func (s *Server) emergencySnapshotDir() (string, error) {
+	dir := ""
+	if s.cfg != nil {
+		dir = strings.TrimSpace(s.cfg.EmergencySnapshotDir)
+	}
+	if dir == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", apperrors.Wrap(err, "Server.emergencySnapshotDir", "resolve user home")
+		}
+		dir = filepath.Join(homeDir, ".multi-agent", "emergency")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", apperrors.Wrap(err, "Server.emergencySnapshotDir", "ensure snapshot dir")
+	}
+	return dir, nil
+}
+
+func (s *Server) activeTrackedTurns() []emergencyTurnView {
+	s.turnMu.Lock()
+	defer s.turnMu.Unlock()
+	out := make([]emergencyTurnView, 0, len(s.activeTurns))
+	for threadID, turn := range s.activeTurns {
+		if turn == nil {
+			continue
+		}
+		out = append(out, emergencyTurnView{ThreadID: threadID, TurnID: turn.ID, StartedAt: turn.StartedAt})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ThreadID < out[j].ThreadID })
+	return out
+}
+
+func (s *Server) captureEmergencySnapshot(lock emergencyLock, turns []emergencyTurnView, interrupted []string) emergencySnapshot {
+	snap := emergencySnapshot{
+		emergencyLock: lock,
+		Agents:        []runner.AgentInfo{},
+		ActiveTurns:   turns,
+		Interrupted:   interrupted,
+		Running:       []scheduledTurn{},
+		Queued:        []queuedTurn{},
+		Held:          s.quietHours.snapshot(),
+	}
+	if s.mgr != nil {
+		snap.Agents = s.mgr.List()
+	}
+	if s.turnScheduler != nil {
+		snap.Running, snap.Queued = s.turnScheduler.snapshot()
+	}
+	if s.uiRuntime != nil {
+		ui := s.uiRuntime.SnapshotLight()
+		snap.UI = &ui
+	}
+	return snap
+}
+
+// writeEmergencySnapshot 写入快照文件与锁文件, 返回快照路径。
+func (s *Server) writeEmergencySnapshot(snap emergencySnapshot) (string, error) {
+	dir, err := s.emergencySnapshotDir()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("snapshot-%s.json", snap.StoppedAt.UTC().Format("20060102T150405.000Z")))
+	snap.SnapshotPath = path
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return "", apperrors.Wrap(err, "Server.writeEmergencySnapshot", "marshal snapshot")
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", apperrors.Wrap(err, "Server.writeEmergencySnapshot", "write snapshot")
+	}
+	lockData, err := json.MarshalIndent(snap.emergencyLock, "", "  ")
+	if err != nil {
+		return "", apperrors.Wrap(err, "Server.writeEmergencySnapshot", "marshal lock")
+	}
+	if err := os.WriteFile(filepath.Join(dir, emergencyLockFile), lockData, 0o644); err != nil {
+		return "", apperrors.Wrap(err, "Server.writeEmergencySnapshot", "write lock file")
+	}
+	return path, nil
+}
+
+// restoreEmergencyLock 启动时恢复上次未解锁的紧急停止。
+func (s *Server) restoreEmergencyLock() {
+	dir, err := s.emergencySnapshotDir()
+	if err != nil {
+		logger.Warn("emergency stop: resolve snapshot dir failed", logger.FieldError, err)
+		return
+	}
+	data, err := os.ReadFile(filepath.Join(dir, emergencyLockFile))
+	if err != nil {
+		return
+	}
+	var lock emergencyLock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		logger.Warn("emergency stop: invalid lock file, staying locked", logger.FieldError, err)
+		lock = emergencyLock{Reason: "unreadable lock file", StoppedAt: time.Now()}
+	}
+	if _, engaged := s.emergency.engage(lock); engaged {
+		logger.Warn("emergency stop: restored lock from previous run",
+			"reason", lock.Reason,
+			"stopped_at", lock.StoppedAt.Format(time.RFC3339),
+			logger.FieldPath, lock.SnapshotPath,
+		)
+	}
+}
+
+// ========================================
+// 中断
+// ========================================
+
+// interruptAllTurns 中断所有进行中的 turn (含无 tracker 但处于活跃状态的 agent), 返回已中断线程。
+func (s *Server) interruptAllTurns(turns []emergencyTurnView) []string {
+	targets := map[string]string{}
+	for _, turn := range turns {
+		targets[turn.ThreadID] = turn.TurnID
+	}
+	if s.mgr != nil {
+		for _, info := range s.mgr.List() {
+			if _, ok := targets[info.ID]; !ok && isInterruptActiveState(s.readThreadRuntimeState(info.ID)) {
+				targets[info.ID] = ""
+			}
+		}
+	}
+	interrupted := make([]string, 0, len(targets))
+	for threadID, turnID := range targets {
+		s.markTrackedTurnInterruptRequested(threadID)
+		if cancelled := s.cancelCodeRuns(threadID); cancelled > 0 {
+			logger.Info("emergency stop: cancelled running code_run executions",
+				logger.FieldThreadID, threadID, "cancelled_runs", cancelled)
+		}
+		sent := false
+		if s.mgr != nil {
+			if proc := s.mgr.Get(threadID); proc != nil {
+				if err := proc.Client.SendCommand("/interrupt", ""); err != nil && !isInterruptNoActiveTurnError(err) {
+					logger.Warn("emergency stop: interrupt failed", logger.FieldThreadID, threadID, logger.FieldError, err)
+				} else {
+					sent = true
+				}
+			}
+		}
+		// 与 stall 自动中断一致: 进程不可达时强制结束 tracker, 避免 turn 悬挂。
+		if !sent && turnID != "" {
+			if completion, ok := s.completeTrackedTurnByID(threadID, turnID, "interrupted", "emergency_stop"); ok {
+				s.Notify("turn/completed", completion)
+			}
+		}
+		interrupted = append(interrupted, threadID)
+	}
+	sort.Strings(interrupted)
+	return interrupted
+}
+
+// ========================================
+// JSON-RPC
+// ========================================
+
+type emergencyStopParams struct {
+	Reason string `json:"reason,omitempty"`
+	By     string `json:"by,omitempty"` // 触发来源, 如 cli / hotkey / rpc
+}
+
+type emergencyUnlockParams struct {
+	By string `json:"by,omitempty"`
+}
+
+func (s *Server) emergencyStopTyped(_ context.Context, p emergencyStopParams) (any, error) {
+	reason := strings.TrimSpace(p.Reason)
+	if reason == "" {
+		reason = "manual emergency stop"
+	}
+	lock, engaged := s.emergency.engage(emergencyLock{Reason: reason, By: strings.TrimSpace(p.By), StoppedAt: time.Now()})
+	if !engaged {
+		return map[string]any{"locked": true, "alreadyLocked": true, "lock": lock}, nil
+	}
+	logger.Warn("emergency stop: engaged", "reason", lock.Reason, "by", lock.By)
+
+	turns := s.activeTrackedTurns()
+	interrupted := s.interruptAllTurns(turns)
+	snap := s.captureEmergencySnapshot(lock, turns, interrupted)
+	path, err := s.writeEmergencySnapshot(snap)
+	if err != nil {
+		// 锁定不回滚: 快照失败时仍保持冻结, 由调用方决定是否解锁。
+		logger.Error("emergency stop: snapshot failed", logger.FieldError, err)
+	} else {
+		s.emergency.setSnapshotPath(path)
+		lock.SnapshotPath = path
+	}
+	resp := map[string]any{
+		"locked":      true,
+		"lock":        lock,
+		"interrupted": interrupted,
+	}
+	if err != nil {
+		resp["snapshotError"] = err.Error()
+	}
+	s.Notify("orchestrator/emergencyStopped", resp)
+	return resp, nil
+}
+
+func (s *Server) emergencyUnlockTyped(_ context.Context, p emergencyUnlockParams) (any, error) {
+	lock, deferred, ok := s.emergency.release()
+	if !ok {
+		return map[string]any{"unlocked": false}, nil
+	}
+	if dir, err := s.emergencySnapshotDir(); err == nil {
+		if err := os.Remove(filepath.Join(dir, emergencyLockFile)); err != nil && !os.IsNotExist(err) {
+			logger.Warn("emergency stop: remove lock file failed", logger.FieldError, err)
+		}
+	}
+	logger.Warn("emergency stop: unlocked",
+		"by", strings.TrimSpace(p.By),
+		"locked_ms", time.Since(lock.StoppedAt).Milliseconds(),
+		"deferred_turns", len(deferred),
+	)
+	for _, item := range deferred {
+		util.SafeGo(func() { s.dispatchQueuedTurn(item.ID, item.turn) })
+	}
+	resp := map[string]any{
+		"unlocked":      true,
+		"previous":      lock,
+		"resumedQueued": len(deferred),
+	}
+	s.Notify("orchestrator/unlocked", resp)
+	return resp, nil
+}
+
+func (s *Server) emergencyStatus(_ context.Context, _ json.RawMessage) (any, error) {
+	lock, locked := s.emergency.current()
+	resp := map[string]any{"locked": locked}
+	if locked {
+		resp["lock"] = lock
+		s.emergency.mu.Lock()
+		resp["deferredTurns"] = len(s.emergency.deferred)
+		s.emergency.mu.Unlock()
+	}
+	return resp, nil
+}
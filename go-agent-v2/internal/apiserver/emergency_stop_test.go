@@ -0,0 +1,79 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/config"
+)
+
+func TestEmergencyStopInterruptsSnapshotsAndFreezes(t *testing.T) {
+	dir := t.TempDir()
+	srv := &Server{
+		cfg:                 &config.Config{EmergencySnapshotDir: dir},
+		activeTurns:         make(map[string]*trackedTurn),
+		turnWatchdogTimeout: time.Minute,
+	}
+	ctx := context.Background()
+	_ = srv.beginTrackedTurn("thread-1", "turn-1")
+
+	resp, err := srv.emergencyStopTyped(ctx, emergencyStopParams{Reason: "runaway edits", By: "test"})
+	if err != nil {
+		t.Fatalf("emergencyStop: %v", err)
+	}
+	result := resp.(map[string]any)
+	if interrupted := result["interrupted"].([]string); len(interrupted) != 1 || interrupted[0] != "thread-1" {
+		t.Fatalf("interrupted = %v", interrupted)
+	}
+	if srv.hasActiveTrackedTurn("thread-1") {
+		t.Fatal("unreachable agent's tracked turn should be force-completed")
+	}
+	lock := result["lock"].(emergencyLock)
+	data, err := os.ReadFile(lock.SnapshotPath)
+	if err != nil {
+		t.Fatalf("read snapshot: %v", err)
+	}
+	var snap emergencySnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		t.Fatalf("decode snapshot: %v", err)
+	}
+	if snap.Reason != "runaway edits" || len(snap.ActiveTurns) != 1 || snap.ActiveTurns[0].TurnID != "turn-1" {
+		t.Fatalf("snapshot = %+v", snap)
+	}
+	if again, _ := srv.emergencyStopTyped(ctx, emergencyStopParams{}); again.(map[string]any)["alreadyLocked"] != true {
+		t.Fatal("second stop should report existing lock")
+	}
+
+	if _, err := srv.turnStartTyped(ctx, turnStartParams{ThreadID: "thread-1"}); err == nil {
+		t.Fatal("turn/start should be rejected while locked")
+	}
+	if _, err := srv.workspaceRunMerge(ctx, json.RawMessage(`{"runKey":"r1"}`)); err == nil {
+		t.Fatal("workspace/run/merge should be rejected while locked")
+	}
+	srv.dispatchQueuedTurn("q-1", preparedTurn{})
+	if status, _ := srv.emergencyStatus(ctx, nil); status.(map[string]any)["deferredTurns"] != 1 {
+		t.Fatalf("status = %v, want one deferred turn", status)
+	}
+
+	// 锁文件跨重启保留。
+	restarted := &Server{cfg: &config.Config{EmergencySnapshotDir: dir}}
+	restarted.restoreEmergencyLock()
+	if restored, locked := restarted.emergency.current(); !locked || restored.Reason != "runaway edits" {
+		t.Fatalf("restored = (%+v, %v)", restored, locked)
+	}
+
+	unlocked, err := srv.emergencyUnlockTyped(ctx, emergencyUnlockParams{By: "test"})
+	if err != nil || unlocked.(map[string]any)["resumedQueued"] != 1 {
+		t.Fatalf("unlock = (%v, %v)", unlocked, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, emergencyLockFile)); !os.IsNotExist(err) {
+		t.Fatalf("lock file should be removed, stat err = %v", err)
+	}
+	if err := srv.frozenError("test"); err != nil {
+		t.Fatalf("still frozen after unlock: %v", err)
+	}
+}
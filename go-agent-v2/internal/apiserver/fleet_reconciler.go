@@ -0,0 +1,322 @@
+// fleet_reconciler.go — 编队对账循环: 周期比对 fleet/apply 清单与运行态, 检测漂移并可选自愈。
+//
+// 漂移类型:
+//   - agent_missing / agent_crashed: 成员未创建或进程已退出 (可自愈: 重新创建)
+//   - skills_drifted: agent 绑定的技能与清单不一致 (可自愈: 恢复技能绑定)
+//   - cwd_drifted: agent 工作目录被改动 (可自愈: 恢复工作目录)
+//   - cwd_missing: 清单中的工作目录已不存在 (仅上报)
+//   - skill_unavailable: 清单引用的技能已从技能库删除 (仅上报)
+//
+// 漂移集合变化时推送 fleet/drift; fleet/status 返回最近一次对账结果。
+// 清单中任一项目处于静默时段 / 封版窗口时自动自愈推迟 (见 quiet_hours.go), 紧急停止锁定期间同样不自愈。
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/service"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+	"github.com/multi-agent/go-agent-v2/pkg/util"
+)
+
+const (
+	fleetDriftAgentMissing     = "agent_missing"
+	fleetDriftAgentCrashed     = "agent_crashed"
+	fleetDriftSkillsDrifted    = "skills_drifted"
+	fleetDriftCwdDrifted       = "cwd_drifted"
+	fleetDriftCwdMissing       = "cwd_missing"
+	fleetDriftSkillUnavailable = "skill_unavailable"
+)
+
+// fleetDrift 单项漂移。
+type fleetDrift struct {
+	Kind     string `json:"kind"`
+	Detail   string `json:"detail,omitempty"`
+	Healable bool   `json:"healable"`
+}
+
+// fleetAgentStatus 单个清单 agent 的对账结果。
+type fleetAgentStatus struct {
+	Name    string       `json:"name"`
+	AgentID string       `json:"agentId,omitempty"`
+	Running bool         `json:"running"`
+	Drift   []fleetDrift `json:"drift,omitempty"`
+}
+
+// fleetStatusParams fleet/status 请求参数。
+type fleetStatusParams struct {
+	Refresh bool `json:"refresh,omitempty"` // 立即重新对账
+	Heal    bool `json:"heal,omitempty"`    // 立即自愈一次 (不受 autoHeal 开关限制)
+}
+
+// fleetStatusResponse fleet/status 响应。
+type fleetStatusResponse struct {
+	Managed       bool                `json:"managed"` // 是否已应用过清单
+	AutoHeal      bool                `json:"autoHeal"`
+	IntervalSec   int                 `json:"intervalSec"`
+	LastCheckedAt string              `json:"lastCheckedAt,omitempty"`
+	LastHealedAt  string              `json:"lastHealedAt,omitempty"`
+	Drifted       int                 `json:"drifted"`
+	Agents        []fleetAgentStatus  `json:"agents"`
+	Heal          *fleetApplyResponse `json:"heal,omitempty"`
+}
+
+func (s *Server) fleetStatusTyped(ctx context.Context, p fleetStatusParams) (any, error) {
+	var healed *fleetApplyResponse
+	if p.Refresh || p.Heal {
+		_, healed = s.reconcileFleet(ctx, p.Heal)
+	}
+	resp := s.fleetStatusSnapshot()
+	resp.Heal = healed
+	return resp, nil
+}
+
+func (s *Server) fleetStatusSnapshot() fleetStatusResponse {
+	s.fleet.mu.Lock()
+	defer s.fleet.mu.Unlock()
+	resp := fleetStatusResponse{
+		Managed:     s.fleet.manifest != nil,
+		AutoHeal:    s.fleet.autoHeal,
+		IntervalSec: int(s.fleet.interval / time.Second),
+		Agents:      append([]fleetAgentStatus{}, s.fleet.status...),
+	}
+	if !s.fleet.lastCheckedAt.IsZero() {
+		resp.LastCheckedAt = s.fleet.lastCheckedAt.Format(time.RFC3339)
+	}
+	if !s.fleet.lastHealedAt.IsZero() {
+		resp.LastHealedAt = s.fleet.lastHealedAt.Format(time.RFC3339)
+	}
+	for _, agent := range resp.Agents {
+		if len(agent.Drift) > 0 {
+			resp.Drifted++
+		}
+	}
+	return resp
+}
+
+// startFleetReconciler 启动后台对账循环 (interval ≤ 0 时不启动); 启动时恢复已持久化的清单。
+func (s *Server) startFleetReconciler(ctx context.Context) {
+	if s.fleet == nil || s.fleet.interval <= 0 {
+		return
+	}
+	s.restoreFleetManifest(ctx)
+	util.SafeGo(func() {
+		ticker := time.NewTicker(s.fleet.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			s.fleet.mu.Lock()
+			autoHeal := s.fleet.autoHeal
+			s.fleet.mu.Unlock()
+			if autoHeal {
+				if state, quiet := s.fleetQuietHours(ctx, time.Now()); quiet {
+					logger.Info("fleet: auto-heal deferred by quiet hours", logger.FieldPath, state.Project, "reason", state.Reason)
+					autoHeal = false
+				}
+			}
+			if _, locked := s.emergency.current(); locked {
+				autoHeal = false
+			}
+			s.reconcileFleet(ctx, autoHeal)
+		}
+	})
+}
+
+// restoreFleetManifest 从偏好恢复最近一次应用的清单 (仅在内存中尚无清单时)。
+func (s *Server) restoreFleetManifest(ctx context.Context) {
+	if s.prefManager == nil {
+		return
+	}
+	value, err := s.prefManager.Get(ctx, prefFleetManifest)
+	if err != nil || value == nil {
+		return
+	}
+	var manifest service.FleetManifest
+	switch typed := value.(type) {
+	case *service.FleetManifest:
+		manifest = *typed
+	case string:
+		err = json.Unmarshal([]byte(typed), &manifest)
+	default:
+		var data []byte
+		if data, err = json.Marshal(typed); err == nil {
+			err = json.Unmarshal(data, &manifest)
+		}
+	}
+	if err != nil {
+		logger.Warn("fleet: restore manifest failed", logger.FieldError, err)
+		return
+	}
+	s.fleet.mu.Lock()
+	if s.fleet.manifest == nil {
+		s.fleet.manifest = &manifest
+	}
+	s.fleet.mu.Unlock()
+}
+
+// reconcileFleet 执行一次漂移检测; heal=true 时修复可自愈的漂移并返回重建结果。
+func (s *Server) reconcileFleet(ctx context.Context, heal bool) ([]fleetAgentStatus, *fleetApplyResponse) {
+	s.fleet.mu.Lock()
+	manifest := s.fleet.manifest
+	s.fleet.mu.Unlock()
+	if manifest == nil {
+		return nil, nil
+	}
+
+	status := s.detectFleetDrift(manifest)
+	var healed *fleetApplyResponse
+	if heal && fleetHasHealableDrift(status) {
+		healed = s.healFleetDrift(ctx, manifest, status)
+		status = s.detectFleetDrift(manifest)
+	}
+
+	s.fleet.mu.Lock()
+	changed := !fleetDriftEqual(s.fleet.status, status)
+	s.fleet.status = status
+	s.fleet.lastCheckedAt = time.Now()
+	if healed != nil {
+		s.fleet.lastHealedAt = s.fleet.lastCheckedAt
+	}
+	s.fleet.mu.Unlock()
+
+	if changed {
+		snapshot := s.fleetStatusSnapshot()
+		logger.Info("fleet: drift changed", "drifted", snapshot.Drifted, logger.FieldCount, len(snapshot.Agents))
+		s.Notify("fleet/drift", snapshot)
+	}
+	return status, healed
+}
+
+// detectFleetDrift 逐个比对清单 agent 与运行态。
+func (s *Server) detectFleetDrift(manifest *service.FleetManifest) []fleetAgentStatus {
+	members := s.fleetMembersSnapshot()
+	available := s.availableSkillSet()
+	out := make([]fleetAgentStatus, 0, len(manifest.Agents))
+	for _, spec := range manifest.Agents {
+		member, exists := members[spec.Name]
+		status := fleetAgentStatus{Name: spec.Name, AgentID: member.AgentID}
+		if info, err := os.Stat(spec.Cwd); err != nil || !info.IsDir() {
+			status.Drift = append(status.Drift, fleetDrift{Kind: fleetDriftCwdMissing, Detail: spec.Cwd})
+		}
+		if available != nil {
+			for _, skill := range spec.Skills {
+				if _, ok := available[strings.ToLower(skill)]; !ok {
+					status.Drift = append(status.Drift, fleetDrift{Kind: fleetDriftSkillUnavailable, Detail: skill})
+				}
+			}
+		}
+		switch {
+		case !exists:
+			status.Drift = append(status.Drift, fleetDrift{Kind: fleetDriftAgentMissing, Healable: true})
+		case !s.fleetAgentAlive(member.AgentID):
+			status.Drift = append(status.Drift, fleetDrift{Kind: fleetDriftAgentCrashed, Detail: member.AgentID, Healable: true})
+		default:
+			status.Running = true
+			if current := s.GetAgentSkills(member.AgentID); !slices.Equal(normalizeSkillList(current), spec.Skills) {
+				status.Drift = append(status.Drift, fleetDrift{Kind: fleetDriftSkillsDrifted, Detail: strings.Join(current, ","), Healable: true})
+			}
+			if cwd := s.getAgentWorkDir(member.AgentID); cwd != "" && cwd != normalizeAgentWorkDir(spec.Cwd) {
+				status.Drift = append(status.Drift, fleetDrift{Kind: fleetDriftCwdDrifted, Detail: cwd, Healable: true})
+			}
+		}
+		out = append(out, status)
+	}
+	return out
+}
+
+// healFleetDrift 修复可自愈漂移: 缺失/崩溃的 agent 走 apply (不 prune), 其余原地恢复。
+func (s *Server) healFleetDrift(ctx context.Context, manifest *service.FleetManifest, status []fleetAgentStatus) *fleetApplyResponse {
+	specs := make(map[string]service.FleetAgentSpec, len(manifest.Agents))
+	for _, spec := range manifest.Agents {
+		specs[spec.Name] = spec
+	}
+	relaunch := false
+	for _, agent := range status {
+		spec := specs[agent.Name]
+		for _, drift := range agent.Drift {
+			switch drift.Kind {
+			case fleetDriftAgentMissing, fleetDriftAgentCrashed:
+				relaunch = true
+			case fleetDriftSkillsDrifted:
+				s.commitFleetMember(agent.AgentID, spec, manifest.ProfileFor(spec))
+			case fleetDriftCwdDrifted:
+				s.setAgentWorkDir(agent.AgentID, spec.Cwd)
+			}
+		}
+	}
+	resp := &fleetApplyResponse{Actions: []fleetAction{}}
+	if relaunch {
+		applied := s.applyFleetManifest(ctx, manifest, false, false)
+		resp = &applied
+	}
+	logger.Info("fleet: drift healed", "relaunch", relaunch, "applied", resp.Applied, "failed", resp.Failed)
+	s.Notify("fleet/healed", resp)
+	return resp
+}
+
+// fleetQuietHours 清单中任一 agent 的项目处于静默窗口时返回该窗口 (自动自愈推迟)。
+func (s *Server) fleetQuietHours(ctx context.Context, now time.Time) (quietHoursState, bool) {
+	s.fleet.mu.Lock()
+	manifest := s.fleet.manifest
+	s.fleet.mu.Unlock()
+	if manifest == nil {
+		return quietHoursState{}, false
+	}
+	policies := s.loadQuietHoursPolicies(ctx)
+	for _, spec := range manifest.Agents {
+		if state, quiet := resolveQuietHours(policies, spec.Cwd, now); quiet {
+			return state, true
+		}
+	}
+	return quietHoursState{}, false
+}
+
+func (s *Server) availableSkillSet() map[string]struct{} {
+	if s.skillSvc == nil {
+		return nil
+	}
+	list, err := s.skillSvc.ListSkills()
+	if err != nil {
+		return nil
+	}
+	out := make(map[string]struct{}, len(list))
+	for _, skill := range list {
+		out[strings.ToLower(skill.Name)] = struct{}{}
+	}
+	return out
+}
+
+func normalizeSkillList(skills []string) []string {
+	if len(skills) == 0 {
+		return nil
+	}
+	out := slices.Clone(skills)
+	slices.Sort(out)
+	return slices.Compact(out)
+}
+
+func fleetHasHealableDrift(status []fleetAgentStatus) bool {
+	for _, agent := range status {
+		for _, drift := range agent.Drift {
+			if drift.Healable {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func fleetDriftEqual(a, b []fleetAgentStatus) bool {
+	return slices.EqualFunc(a, b, func(x, y fleetAgentStatus) bool {
+		return x.Name == y.Name && x.AgentID == y.AgentID && x.Running == y.Running && slices.Equal(x.Drift, y.Drift)
+	})
+}
@@ -0,0 +1,63 @@
+package apiserver
+
+import (
+	"testing"
+
+	"github.com/multi-agent/go-agent-v2/internal/service"
+)
+
+func TestReconcileFleetReportsDrift(t *testing.T) {
+	dir := t.TempDir()
+	manifest, err := service.ParseFleetManifest([]byte(`
+agents:
+  - {name: alpha, cwd: .}
+  - {name: beta, cwd: ./gone}
+`), dir)
+	if err != nil {
+		t.Fatalf("ParseFleetManifest: %v", err)
+	}
+	srv := &Server{fleet: newFleetState(0, false)}
+	srv.fleet.manifest = manifest
+	srv.fleet.members["alpha"] = &fleetMember{Name: "alpha", AgentID: "agent-exited"}
+
+	if _, healed := srv.reconcileFleet(t.Context(), false); healed != nil {
+		t.Fatal("reconcile without heal should not heal")
+	}
+	status := srv.fleetStatusSnapshot()
+	if !status.Managed || status.Drifted != 2 || status.LastCheckedAt == "" {
+		t.Fatalf("status = %+v", status)
+	}
+	kinds := map[string][]string{}
+	for _, agent := range status.Agents {
+		for _, drift := range agent.Drift {
+			kinds[agent.Name] = append(kinds[agent.Name], drift.Kind)
+		}
+	}
+	if len(kinds["alpha"]) != 1 || kinds["alpha"][0] != fleetDriftAgentCrashed {
+		t.Fatalf("alpha drift = %v", kinds["alpha"])
+	}
+	if len(kinds["beta"]) != 2 || kinds["beta"][0] != fleetDriftCwdMissing || kinds["beta"][1] != fleetDriftAgentMissing {
+		t.Fatalf("beta drift = %v", kinds["beta"])
+	}
+
+	// 无 agent manager 时自愈应逐项失败而不是 panic
+	resp, err := srv.fleetStatusTyped(t.Context(), fleetStatusParams{Heal: true})
+	if err != nil {
+		t.Fatalf("fleet/status heal: %v", err)
+	}
+	heal := resp.(fleetStatusResponse).Heal
+	if heal == nil || heal.Failed != 2 || heal.Applied != 0 {
+		t.Fatalf("heal = %+v", heal)
+	}
+}
+
+func TestFleetStatusWithoutManifest(t *testing.T) {
+	srv := &Server{fleet: newFleetState(0, true)}
+	resp, err := srv.fleetStatusTyped(t.Context(), fleetStatusParams{Refresh: true})
+	if err != nil {
+		t.Fatalf("fleet/status: %v", err)
+	}
+	if got := resp.(fleetStatusResponse); got.Managed || !got.AutoHeal || len(got.Agents) != 0 {
+		t.Fatalf("status = %+v", got)
+	}
+}
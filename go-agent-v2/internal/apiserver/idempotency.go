@@ -0,0 +1,181 @@
+// idempotency.go — 变更类 JSON-RPC 请求的幂等键去重。
+//
+// 前端在 WebSocket 重连后会重发未收到响应的请求; 携带相同 idempotencyKey 的重复请求
+// 不再执行 handler, 而是直接返回首次执行的结果 (首次仍在执行时等待其完成)。
+// 仅成功结果在 TTL 内缓存, 失败后允许以同一个键重试; 同一个键携带不同参数视为冲突。
+package apiserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+const (
+	idempotencyTTL        = 10 * time.Minute
+	idempotencyMaxEntries = 1024
+	idempotencyMaxKeyLen  = 256
+)
+
+// idempotentMethods 支持 idempotencyKey 的变更类方法。
+var idempotentMethods = map[string]bool{
+	"turn/start":             true,
+	"workspace/run/create":   true,
+	"skills/local/importDir": true,
+	"skills/local/delete":    true,
+	"skills/remote/write":    true,
+	"skills/config/write":    true,
+	"skills/summary/write":   true,
+}
+
+// idempotencyEntry 单个幂等键的执行记录; done 关闭后 result / err 可读。
+type idempotencyEntry struct {
+	fingerprint string
+	createdAt   time.Time
+	expiresAt   time.Time
+	done        chan struct{}
+	result      any
+	err         error
+}
+
+// idempotencyCache 幂等键表 (method + key → entry)。
+type idempotencyCache struct {
+	mu      sync.Mutex
+	entries map[string]*idempotencyEntry
+}
+
+// extractIdempotencyKey 读取 params.idempotencyKey (缺省返回空串)。
+func extractIdempotencyKey(params json.RawMessage) string {
+	if len(params) == 0 {
+		return ""
+	}
+	var p struct {
+		IdempotencyKey string `json:"idempotencyKey"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return ""
+	}
+	return strings.TrimSpace(p.IdempotencyKey)
+}
+
+// idempotencyFingerprint 计算参数指纹 (重新编码以消除字段顺序 / 空白差异)。
+func idempotencyFingerprint(params json.RawMessage) string {
+	data := []byte(params)
+	var decoded any
+	if err := json.Unmarshal(params, &decoded); err == nil {
+		if canonical, err := json.Marshal(decoded); err == nil {
+			data = canonical
+		}
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// begin 查找未过期记录; 未命中时登记并返回 owner=true, 调用方执行完毕后须调用 finish。
+func (c *idempotencyCache) begin(scope, fingerprint string, now time.Time) (*idempotencyEntry, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]*idempotencyEntry)
+	}
+	c.pruneLocked(now)
+	if entry, ok := c.entries[scope]; ok {
+		if entry.fingerprint != fingerprint {
+			return nil, false, apperrors.NewCode("Server.idempotency", errcode.IdempotencyConflict, "idempotencyKey reused with different params")
+		}
+		return entry, false, nil
+	}
+	entry := &idempotencyEntry{
+		fingerprint: fingerprint,
+		createdAt:   now,
+		expiresAt:   now.Add(idempotencyTTL),
+		done:        make(chan struct{}),
+	}
+	c.entries[scope] = entry
+	return entry, true, nil
+}
+
+// finish 记录执行结果; 失败时移除记录以允许重试。
+func (c *idempotencyCache) finish(scope string, entry *idempotencyEntry, result any, err error) {
+	c.mu.Lock()
+	entry.result, entry.err = result, err
+	if err != nil && c.entries[scope] == entry {
+		delete(c.entries, scope)
+	}
+	c.mu.Unlock()
+	close(entry.done)
+}
+
+func (c *idempotencyCache) pruneLocked(now time.Time) {
+	for key, entry := range c.entries {
+		select {
+		case <-entry.done:
+		default:
+			continue // 执行中的记录不淘汰
+		}
+		if !now.Before(entry.expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+	if len(c.entries) <= idempotencyMaxEntries {
+		return
+	}
+	type kv struct {
+		key       string
+		createdAt time.Time
+	}
+	ordered := make([]kv, 0, len(c.entries))
+	for key, entry := range c.entries {
+		ordered = append(ordered, kv{key: key, createdAt: entry.createdAt})
+	}
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].createdAt.Before(ordered[j].createdAt) })
+	for i := 0; i < len(ordered)-idempotencyMaxEntries; i++ {
+		delete(c.entries, ordered[i].key)
+	}
+}
+
+// invokeIdempotent 按 idempotencyKey 去重执行 handler; 方法不支持或未携带键时直接执行。
+func (s *Server) invokeIdempotent(ctx context.Context, method string, handler Handler, params json.RawMessage) (any, error) {
+	if !idempotentMethods[method] {
+		return handler(ctx, params)
+	}
+	key := extractIdempotencyKey(params)
+	if key == "" {
+		return handler(ctx, params)
+	}
+	if len(key) > idempotencyMaxKeyLen {
+		return nil, apperrors.Newf("Server.idempotency", "idempotencyKey exceeds %d bytes", idempotencyMaxKeyLen)
+	}
+	scope := method + "\x00" + key
+	entry, owner, err := s.idempotency.begin(scope, idempotencyFingerprint(params), time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !owner {
+		select {
+		case <-entry.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		logger.Info("app-server: idempotent request replayed",
+			logger.FieldMethod, method,
+			"idempotency_key", key,
+			"age_ms", time.Since(entry.createdAt).Milliseconds(),
+		)
+		return entry.result, entry.err
+	}
+	// handler panic 时同样释放记录, 避免等待方永久阻塞。
+	result, err := any(nil), error(apperrors.New("Server.idempotency", "handler aborted"))
+	defer func() { s.idempotency.finish(scope, entry, result, err) }()
+	result, err = handler(ctx, params)
+	return result, err
+}
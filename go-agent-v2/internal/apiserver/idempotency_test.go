@@ -0,0 +1,116 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDispatchRequest_IdempotencyKeyReplaysResult(t *testing.T) {
+	var calls atomic.Int32
+	srv := &Server{methods: map[string]Handler{
+		"turn/start": func(_ context.Context, _ json.RawMessage) (any, error) {
+			n := calls.Add(1)
+			return map[string]any{"call": n}, nil
+		},
+	}}
+	ctx := context.Background()
+	first := srv.dispatchRequest(ctx, 1, "turn/start", json.RawMessage(`{"threadId":"t1","idempotencyKey":"k1"}`))
+	// 字段顺序 / 空白不同视为同一请求
+	second := srv.dispatchRequest(ctx, 2, "turn/start", json.RawMessage(`{ "idempotencyKey":"k1", "threadId":"t1" }`))
+	if first.Error != nil || second.Error != nil {
+		t.Fatalf("unexpected errors: %+v %+v", first.Error, second.Error)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("handler calls = %d, want 1", calls.Load())
+	}
+	if got := second.Result.(map[string]any)["call"]; got != int32(1) {
+		t.Fatalf("replayed result = %v, want first result", got)
+	}
+
+	conflict := srv.dispatchRequest(ctx, 3, "turn/start", json.RawMessage(`{"threadId":"t2","idempotencyKey":"k1"}`))
+	if conflict.Error == nil || !strings.Contains(conflict.Error.Message, "different params") {
+		t.Fatalf("conflict = %+v, want different params error", conflict.Error)
+	}
+
+	srv.dispatchRequest(ctx, 4, "turn/start", json.RawMessage(`{"threadId":"t1"}`))
+	if calls.Load() != 2 {
+		t.Fatalf("request without key should execute, calls = %d", calls.Load())
+	}
+}
+
+func TestDispatchRequest_IdempotencyFailureAllowsRetry(t *testing.T) {
+	var calls atomic.Int32
+	srv := &Server{methods: map[string]Handler{
+		"skills/config/write": func(_ context.Context, _ json.RawMessage) (any, error) {
+			if calls.Add(1) == 1 {
+				return nil, errors.New("disk full")
+			}
+			return map[string]any{"ok": true}, nil
+		},
+	}}
+	params := json.RawMessage(`{"idempotencyKey":"k2"}`)
+	if resp := srv.dispatchRequest(context.Background(), 1, "skills/config/write", params); resp.Error == nil {
+		t.Fatal("first call should fail")
+	}
+	if resp := srv.dispatchRequest(context.Background(), 2, "skills/config/write", params); resp.Error != nil {
+		t.Fatalf("retry error = %+v", resp.Error)
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("calls = %d, want 2", calls.Load())
+	}
+}
+
+func TestDispatchRequest_IdempotencyConcurrentDuplicateWaits(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	srv := &Server{methods: map[string]Handler{
+		"workspace/run/create": func(_ context.Context, _ json.RawMessage) (any, error) {
+			calls.Add(1)
+			<-release
+			return "run-1", nil
+		},
+	}}
+	params := json.RawMessage(`{"idempotencyKey":"k3"}`)
+	var wg sync.WaitGroup
+	results := make([]*Response, 2)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = srv.dispatchRequest(context.Background(), i, "workspace/run/create", params)
+		}(i)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if calls.Load() != 1 {
+		t.Fatalf("calls = %d, want 1", calls.Load())
+	}
+	for i, resp := range results {
+		if resp.Error != nil || resp.Result != "run-1" {
+			t.Fatalf("result[%d] = %+v", i, resp)
+		}
+	}
+}
+
+func TestInvokeIdempotent_IgnoresNonMutatingMethods(t *testing.T) {
+	var calls atomic.Int32
+	srv := &Server{}
+	handler := func(_ context.Context, _ json.RawMessage) (any, error) {
+		calls.Add(1)
+		return nil, nil
+	}
+	params := json.RawMessage(`{"idempotencyKey":"k4"}`)
+	for i := 0; i < 2; i++ {
+		_, _ = srv.invokeIdempotent(context.Background(), "thread/list", handler, params)
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("calls = %d, want 2", calls.Load())
+	}
+}
@@ -0,0 +1,149 @@
+// input_files.go — input/prepareFiles, input/stageImage: 把拖放的本地路径 / 粘贴的图片转换为
+// 可直接用于 turn/start 的 UserInput。
+//
+// 前端只需传入 files-dropped 事件中的路径; 分类与暂存由 service.FileIngestor 完成:
+// 图片 → localImage, 小文本 → fileContent (内联), 大文本/二进制 → mention (暂存副本), 目录 → mention。
+// 粘贴的截图以 base64 data URL 传入 input/stageImage, 按内容哈希落到同一暂存目录后作为 localImage 引用。
+package apiserver
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/multi-agent/go-agent-v2/internal/service"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+const (
+	defaultInputInlineMaxBytes = 64 << 10
+	defaultInputMaxFileBytes   = 100 << 20
+	defaultInputImageMaxBytes  = 20 << 20
+	maxPrepareFilesPerCall     = 200
+)
+
+// inputStagingState 输入暂存器 (首次使用时按 INPUT_* 配置创建)。
+type inputStagingState struct {
+	mu       sync.Mutex
+	ingestor *service.FileIngestor
+}
+
+// fileIngestor 返回 (必要时创建) 输入暂存器。
+func (s *Server) fileIngestor() (*service.FileIngestor, error) {
+	s.inputStaging.mu.Lock()
+	defer s.inputStaging.mu.Unlock()
+	if s.inputStaging.ingestor != nil {
+		return s.inputStaging.ingestor, nil
+	}
+	dir := ""
+	inlineMax, maxBytes := int64(defaultInputInlineMaxBytes), int64(defaultInputMaxFileBytes)
+	if s.cfg != nil {
+		dir = s.cfg.InputStagingDir
+		inlineMax = int64(s.cfg.InputInlineMaxBytes)
+		if s.cfg.InputMaxFileBytes > 0 {
+			maxBytes = int64(s.cfg.InputMaxFileBytes)
+		}
+	}
+	ingestor, err := service.NewFileIngestor(dir, inlineMax, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("input: staging dir ready", logger.FieldPath, ingestor.StagingDir())
+	s.inputStaging.ingestor = ingestor
+	return ingestor, nil
+}
+
+// ingestedFileInput 把预处理结果映射为 UserInput (失败项返回 nil)。
+func ingestedFileInput(file service.IngestedFile) *UserInput {
+	if file.Error != "" {
+		return nil
+	}
+	switch file.Kind {
+	case service.IngestKindImage:
+		return &UserInput{Type: "localImage", Path: file.StagedPath}
+	case service.IngestKindDirectory:
+		return &UserInput{Type: "mention", Name: file.Name, Path: file.Path}
+	case service.IngestKindText:
+		if file.Inline {
+			return &UserInput{Type: "fileContent", Name: file.Name, Content: file.Content}
+		}
+	}
+	return &UserInput{Type: "mention", Name: file.Name, Path: file.StagedPath}
+}
+
+type inputPrepareFilesParams struct {
+	Paths []string `json:"paths"`
+	Cwd   string   `json:"cwd,omitempty"` // 相对路径的基准目录
+}
+
+// preparedFile 单个路径的处理结果 (Input 为 nil 表示失败, 见 Error)。
+type preparedFile struct {
+	service.IngestedFile
+	Input *UserInput `json:"input,omitempty"`
+}
+
+func (s *Server) inputPrepareFilesTyped(ctx context.Context, p inputPrepareFilesParams) (any, error) {
+	const op = "Server.inputPrepareFiles"
+	if len(p.Paths) == 0 {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "paths is required")
+	}
+	if len(p.Paths) > maxPrepareFilesPerCall {
+		return nil, apperrors.NewCodef(op, errcode.InvalidInput, "too many paths (%d > %d)", len(p.Paths), maxPrepareFilesPerCall)
+	}
+	ingestor, err := s.fileIngestor()
+	if err != nil {
+		return nil, err
+	}
+	ingested := ingestor.Prepare(ctx, strings.TrimSpace(p.Cwd), p.Paths)
+	files := make([]preparedFile, 0, len(ingested))
+	inputs := make([]UserInput, 0, len(ingested))
+	failed := 0
+	for _, file := range ingested {
+		input := ingestedFileInput(file)
+		if input == nil {
+			failed++
+		} else {
+			inputs = append(inputs, *input)
+		}
+		files = append(files, preparedFile{IngestedFile: file, Input: input})
+	}
+	logger.Info("input/prepareFiles: done", logger.FieldCount, len(files), "failed", failed)
+	return map[string]any{
+		"files":  files,
+		"inputs": inputs,
+		"failed": failed,
+	}, nil
+}
+
+type inputStageImageParams struct {
+	DataURL string `json:"dataUrl"`
+}
+
+func (s *Server) inputStageImageTyped(_ context.Context, p inputStageImageParams) (any, error) {
+	const op = "Server.inputStageImage"
+	if strings.TrimSpace(p.DataURL) == "" {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "dataUrl is required")
+	}
+	ingestor, err := s.fileIngestor()
+	if err != nil {
+		return nil, err
+	}
+	maxBytes := int64(defaultInputImageMaxBytes)
+	if s.cfg != nil && s.cfg.InputImageMaxBytes > 0 {
+		maxBytes = int64(s.cfg.InputImageMaxBytes)
+	}
+	staged, err := ingestor.StageImageDataURL(p.DataURL, maxBytes)
+	if err != nil {
+		return nil, apperrors.WrapCode(err, op, errcode.InvalidInput, "stage image")
+	}
+	logger.Info("input/stageImage: staged", logger.FieldPath, staged.Path, "mime", staged.MimeType, "size", staged.Size)
+	return map[string]any{
+		"path":     staged.Path,
+		"mimeType": staged.MimeType,
+		"size":     staged.Size,
+		"sha256":   staged.SHA256,
+		"input":    UserInput{Type: "localImage", Path: staged.Path},
+	}, nil
+}
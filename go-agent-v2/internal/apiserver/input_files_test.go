@@ -0,0 +1,75 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/multi-agent/go-agent-v2/internal/config"
+)
+
+func TestInputPrepareFiles_MapsToUserInputs(t *testing.T) {
+	staging := t.TempDir()
+	srv := New(Deps{Config: &config.Config{InputStagingDir: staging, InputInlineMaxBytes: 1024}})
+	src := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "data.bin"), []byte{0, 1, 2, 3}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	params, _ := json.Marshal(map[string]any{"cwd": src, "paths": []string{"main.go", "data.bin", "gone.txt"}})
+	res, err := srv.InvokeMethod(context.Background(), "input/prepareFiles", params)
+	if err != nil {
+		t.Fatalf("input/prepareFiles: %v", err)
+	}
+	out := res.(map[string]any)
+	inputs := out["inputs"].([]UserInput)
+	if len(inputs) != 2 || out["failed"] != 1 {
+		t.Fatalf("inputs=%#v failed=%v", inputs, out["failed"])
+	}
+	if inputs[0] != (UserInput{Type: "fileContent", Name: "main.go", Content: "package main\n"}) {
+		t.Fatalf("text input = %#v", inputs[0])
+	}
+	if inputs[1].Type != "mention" || inputs[1].Name != "data.bin" || !strings.HasPrefix(inputs[1].Path, staging) {
+		t.Fatalf("binary input = %#v", inputs[1])
+	}
+	files := out["files"].([]preparedFile)
+	if files[2].Input != nil || files[2].Error == "" {
+		t.Fatalf("missing file = %#v", files[2])
+	}
+}
+
+func TestInputPrepareFiles_RequiresPaths(t *testing.T) {
+	srv := New(Deps{})
+	if _, err := srv.InvokeMethod(context.Background(), "input/prepareFiles", json.RawMessage(`{"paths":[]}`)); err == nil {
+		t.Fatal("expected error for empty paths")
+	}
+}
+
+func TestInputStageImage_ReturnsLocalImageInput(t *testing.T) {
+	staging := t.TempDir()
+	srv := New(Deps{Config: &config.Config{InputStagingDir: staging, InputImageMaxBytes: 4096}})
+	gif := "data:image/gif;base64,R0lGODlhAQABAIAAAAAAAP///yH5BAEAAAAALAAAAAABAAEAAAIBRAA7"
+	params, _ := json.Marshal(map[string]string{"dataUrl": gif})
+	res, err := srv.InvokeMethod(context.Background(), "input/stageImage", params)
+	if err != nil {
+		t.Fatalf("input/stageImage: %v", err)
+	}
+	out := res.(map[string]any)
+	path, _ := out["path"].(string)
+	if !strings.HasPrefix(path, staging) || filepath.Ext(path) != ".gif" || out["mimeType"] != "image/gif" {
+		t.Fatalf("result = %#v", out)
+	}
+	if input := out["input"].(UserInput); input.Type != "localImage" || input.Path != path {
+		t.Fatalf("input = %#v", input)
+	}
+
+	if _, err := srv.InvokeMethod(context.Background(), "input/stageImage", json.RawMessage(`{"dataUrl":"data:text/plain;base64,aGk="}`)); err == nil {
+		t.Fatal("expected error for non-image data URL")
+	}
+}
@@ -0,0 +1,393 @@
+// json_schema.go — turn/start outputSchema 使用的 JSON Schema 子集校验。
+//
+// 支持: type (含数组形式)、enum、const、properties / required / additionalProperties、
+// items / minItems / maxItems / uniqueItems、minLength / maxLength / pattern、
+// minimum / maximum / exclusiveMinimum / exclusiveMaximum、allOf / anyOf / oneOf / not,
+// 以及布尔 schema。title / description / $schema 等注解忽略; $ref 等不支持的结构性关键字
+// 在编译时报错, 避免约束被静默跳过。
+package apiserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxSchemaErrors 单次校验最多报告的违例数。
+const maxSchemaErrors = 20
+
+// unsupportedSchemaKeywords 无法按子集语义校验的关键字。
+var unsupportedSchemaKeywords = []string{"$ref", "$dynamicRef", "patternProperties", "dependentSchemas", "if", "prefixItems", "contains"}
+
+// jsonSchema 编译后的 schema 节点。
+type jsonSchema struct {
+	reject bool // false schema: 任何值都不合法
+
+	types                []string
+	enum                 []any
+	constValue           any
+	hasConst             bool
+	properties           map[string]*jsonSchema
+	required             []string
+	additionalProperties *jsonSchema // nil = 不限制
+	items                *jsonSchema
+	minItems, maxItems   int // -1 = 不限制
+	uniqueItems          bool
+	minLength, maxLength int // -1 = 不限制
+	pattern              *regexp.Regexp
+	minimum, maximum     *float64
+	exclusiveMin         *float64
+	exclusiveMax         *float64
+	allOf, anyOf, oneOf  []*jsonSchema
+	not                  *jsonSchema
+}
+
+// compileJSONSchema 解析 schema 文本。
+func compileJSONSchema(raw json.RawMessage) (*jsonSchema, error) {
+	var doc any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	return compileSchemaNode(doc, "$")
+}
+
+func compileSchemaNode(node any, path string) (*jsonSchema, error) {
+	switch t := node.(type) {
+	case bool:
+		return &jsonSchema{reject: !t, minItems: -1, maxItems: -1, minLength: -1, maxLength: -1}, nil
+	case map[string]any:
+		return compileSchemaObject(t, path)
+	}
+	return nil, fmt.Errorf("%s: schema must be an object or boolean", path)
+}
+
+func compileSchemaObject(m map[string]any, path string) (*jsonSchema, error) {
+	for _, kw := range unsupportedSchemaKeywords {
+		if _, ok := m[kw]; ok {
+			return nil, fmt.Errorf("%s: keyword %q is not supported", path, kw)
+		}
+	}
+	s := &jsonSchema{minItems: -1, maxItems: -1, minLength: -1, maxLength: -1}
+	var err error
+
+	switch t := m["type"].(type) {
+	case nil:
+	case string:
+		s.types = []string{t}
+	case []any:
+		for _, item := range t {
+			name, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s.type: entries must be strings", path)
+			}
+			s.types = append(s.types, name)
+		}
+	default:
+		return nil, fmt.Errorf("%s.type: must be a string or array", path)
+	}
+	for _, name := range s.types {
+		switch name {
+		case "object", "array", "string", "number", "integer", "boolean", "null":
+		default:
+			return nil, fmt.Errorf("%s.type: unknown type %q", path, name)
+		}
+	}
+
+	if v, ok := m["enum"]; ok {
+		if s.enum, ok = v.([]any); !ok {
+			return nil, fmt.Errorf("%s.enum: must be an array", path)
+		}
+	}
+	s.constValue, s.hasConst = m["const"]
+
+	if v, ok := m["properties"]; ok {
+		props, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%s.properties: must be an object", path)
+		}
+		s.properties = make(map[string]*jsonSchema, len(props))
+		for name, sub := range props {
+			if s.properties[name], err = compileSchemaNode(sub, path+"."+name); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if v, ok := m["required"]; ok {
+		list, ok := v.([]any)
+		if !ok {
+			return nil, fmt.Errorf("%s.required: must be an array", path)
+		}
+		for _, item := range list {
+			name, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s.required: entries must be strings", path)
+			}
+			s.required = append(s.required, name)
+		}
+	}
+	if v, ok := m["additionalProperties"]; ok {
+		if s.additionalProperties, err = compileSchemaNode(v, path+".additionalProperties"); err != nil {
+			return nil, err
+		}
+	}
+	if v, ok := m["items"]; ok {
+		if s.items, err = compileSchemaNode(v, path+".items"); err != nil {
+			return nil, err
+		}
+	}
+	if v, ok := m["not"]; ok {
+		if s.not, err = compileSchemaNode(v, path+".not"); err != nil {
+			return nil, err
+		}
+	}
+	for _, kw := range []struct {
+		name string
+		dst  *[]*jsonSchema
+	}{{"allOf", &s.allOf}, {"anyOf", &s.anyOf}, {"oneOf", &s.oneOf}} {
+		v, ok := m[kw.name]
+		if !ok {
+			continue
+		}
+		list, ok := v.([]any)
+		if !ok || len(list) == 0 {
+			return nil, fmt.Errorf("%s.%s: must be a non-empty array", path, kw.name)
+		}
+		for i, sub := range list {
+			compiled, err := compileSchemaNode(sub, fmt.Sprintf("%s.%s[%d]", path, kw.name, i))
+			if err != nil {
+				return nil, err
+			}
+			*kw.dst = append(*kw.dst, compiled)
+		}
+	}
+
+	for _, kw := range []struct {
+		name string
+		dst  *int
+	}{{"minItems", &s.minItems}, {"maxItems", &s.maxItems}, {"minLength", &s.minLength}, {"maxLength", &s.maxLength}} {
+		v, ok := m[kw.name]
+		if !ok {
+			continue
+		}
+		n, ok := v.(float64)
+		if !ok || n < 0 || n != math.Trunc(n) {
+			return nil, fmt.Errorf("%s.%s: must be a non-negative integer", path, kw.name)
+		}
+		*kw.dst = int(n)
+	}
+	for _, kw := range []struct {
+		name string
+		dst  **float64
+	}{{"minimum", &s.minimum}, {"maximum", &s.maximum}, {"exclusiveMinimum", &s.exclusiveMin}, {"exclusiveMaximum", &s.exclusiveMax}} {
+		v, ok := m[kw.name]
+		if !ok {
+			continue
+		}
+		n, ok := v.(float64)
+		if !ok {
+			return nil, fmt.Errorf("%s.%s: must be a number", path, kw.name)
+		}
+		*kw.dst = &n
+	}
+	if v, ok := m["uniqueItems"]; ok {
+		if s.uniqueItems, ok = v.(bool); !ok {
+			return nil, fmt.Errorf("%s.uniqueItems: must be a boolean", path)
+		}
+	}
+	if v, ok := m["pattern"]; ok {
+		expr, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s.pattern: must be a string", path)
+		}
+		if s.pattern, err = regexp.Compile(expr); err != nil {
+			return nil, fmt.Errorf("%s.pattern: %w", path, err)
+		}
+	}
+	return s, nil
+}
+
+// validate 返回违例描述 (形如 "$.items[0].name: ..."), 空切片表示通过。
+func (s *jsonSchema) validate(value any) []string {
+	var errs []string
+	s.check(value, "$", &errs)
+	if len(errs) > maxSchemaErrors {
+		errs = append(errs[:maxSchemaErrors], fmt.Sprintf("... %d more", len(errs)-maxSchemaErrors))
+	}
+	return errs
+}
+
+func (s *jsonSchema) check(value any, path string, errs *[]string) {
+	fail := func(format string, args ...any) {
+		*errs = append(*errs, path+": "+fmt.Sprintf(format, args...))
+	}
+	if s.reject {
+		fail("no value is allowed here")
+		return
+	}
+	if len(s.types) > 0 && !schemaTypeMatches(s.types, value) {
+		fail("expected %s, got %s", strings.Join(s.types, " or "), jsonTypeName(value))
+		return
+	}
+	if s.enum != nil && !containsJSONValue(s.enum, value) {
+		fail("must be one of %s", compactSchemaJSON(s.enum))
+	}
+	if s.hasConst && !reflect.DeepEqual(s.constValue, value) {
+		fail("must equal %s", compactSchemaJSON(s.constValue))
+	}
+
+	switch t := value.(type) {
+	case map[string]any:
+		s.checkObject(t, path, errs)
+	case []any:
+		if s.minItems >= 0 && len(t) < s.minItems {
+			fail("must have at least %d items", s.minItems)
+		}
+		if s.maxItems >= 0 && len(t) > s.maxItems {
+			fail("must have at most %d items", s.maxItems)
+		}
+		if s.uniqueItems {
+			for i := 1; i < len(t); i++ {
+				if containsJSONValue(t[:i], t[i]) {
+					fail("items must be unique (item %d repeats an earlier one)", i)
+					break
+				}
+			}
+		}
+		if s.items != nil {
+			for i, item := range t {
+				s.items.check(item, path+"["+strconv.Itoa(i)+"]", errs)
+			}
+		}
+	case string:
+		n := utf8.RuneCountInString(t)
+		if s.minLength >= 0 && n < s.minLength {
+			fail("must be at least %d characters", s.minLength)
+		}
+		if s.maxLength >= 0 && n > s.maxLength {
+			fail("must be at most %d characters", s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(t) {
+			fail("must match pattern %q", s.pattern.String())
+		}
+	case float64:
+		if s.minimum != nil && t < *s.minimum {
+			fail("must be >= %v", *s.minimum)
+		}
+		if s.maximum != nil && t > *s.maximum {
+			fail("must be <= %v", *s.maximum)
+		}
+		if s.exclusiveMin != nil && t <= *s.exclusiveMin {
+			fail("must be > %v", *s.exclusiveMin)
+		}
+		if s.exclusiveMax != nil && t >= *s.exclusiveMax {
+			fail("must be < %v", *s.exclusiveMax)
+		}
+	}
+
+	for _, sub := range s.allOf {
+		sub.check(value, path, errs)
+	}
+	if len(s.anyOf) > 0 && s.countMatches(s.anyOf, value) == 0 {
+		fail("must match at least one anyOf schema")
+	}
+	if len(s.oneOf) > 0 {
+		if n := s.countMatches(s.oneOf, value); n != 1 {
+			fail("must match exactly one oneOf schema (matched %d)", n)
+		}
+	}
+	if s.not != nil && len(s.not.validate(value)) == 0 {
+		fail("must not match the \"not\" schema")
+	}
+}
+
+func (s *jsonSchema) checkObject(obj map[string]any, path string, errs *[]string) {
+	for _, name := range s.required {
+		if _, ok := obj[name]; !ok {
+			*errs = append(*errs, fmt.Sprintf("%s: missing required property %q", path, name))
+		}
+	}
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if sub, ok := s.properties[name]; ok {
+			sub.check(obj[name], path+"."+name, errs)
+			continue
+		}
+		if s.additionalProperties == nil {
+			continue
+		}
+		if s.additionalProperties.reject {
+			*errs = append(*errs, fmt.Sprintf("%s: unexpected property %q", path, name))
+			continue
+		}
+		s.additionalProperties.check(obj[name], path+"."+name, errs)
+	}
+}
+
+func (s *jsonSchema) countMatches(schemas []*jsonSchema, value any) int {
+	n := 0
+	for _, sub := range schemas {
+		if len(sub.validate(value)) == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func schemaTypeMatches(types []string, value any) bool {
+	actual := jsonTypeName(value)
+	for _, want := range types {
+		if want == actual || (want == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonTypeName 按 JSON Schema 类型名描述 encoding/json 解码后的值。
+func jsonTypeName(value any) string {
+	switch t := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if t == math.Trunc(t) && !math.IsInf(t, 0) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func containsJSONValue(list []any, value any) bool {
+	for _, item := range list {
+		if reflect.DeepEqual(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func compactSchemaJSON(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
@@ -0,0 +1,69 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestCompileJSONSchema_RejectsUnsupported(t *testing.T) {
+	for name, src := range map[string]string{
+		"not json":     `{`,
+		"scalar":       `"object"`,
+		"unknown type": `{"type":"int"}`,
+		"ref":          `{"properties":{"a":{"$ref":"#/defs/a"}}}`,
+		"bad pattern":  `{"pattern":"("}`,
+		"bad minItems": `{"minItems":-1}`,
+		"empty anyOf":  `{"anyOf":[]}`,
+	} {
+		if _, err := compileJSONSchema(json.RawMessage(src)); err == nil {
+			t.Errorf("%s: expected compile error", name)
+		}
+	}
+}
+
+func TestJSONSchemaValidate(t *testing.T) {
+	schema, err := compileJSONSchema(json.RawMessage(`{
+	  "type": "object",
+	  "properties": {
+	    "title": {"type": "string", "minLength": 3, "pattern": "^[A-Z]"},
+	    "count": {"type": "integer", "minimum": 1},
+	    "level": {"enum": ["low", "high"]},
+	    "tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2, "uniqueItems": true},
+	    "ref": {"anyOf": [{"type": "string"}, {"type": "null"}]}
+	  },
+	  "required": ["title", "count"],
+	  "additionalProperties": false
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := map[string]any{"title": "Fix", "count": float64(2), "level": "low", "tags": []any{"a"}, "ref": nil}
+	if errs := schema.validate(valid); len(errs) != 0 {
+		t.Fatalf("valid value rejected: %v", errs)
+	}
+
+	var bad any
+	_ = json.Unmarshal([]byte(`{"title":"no","count":1.5,"level":"mid","tags":["a","a","b"],"ref":3,"extra":true}`), &bad)
+	got := strings.Join(schema.validate(bad), "\n")
+	for _, want := range []string{
+		`$: unexpected property "extra"`,
+		"$.count: expected integer, got number",
+		"$.level: must be one of",
+		"$.ref: must match at least one anyOf schema",
+		"$.tags: must have at most 2 items",
+		"$.tags: items must be unique",
+		"$.title: must be at least 3 characters",
+		"$.title: must match pattern",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+	if errs := schema.validate(map[string]any{}); len(errs) != 2 || !strings.Contains(errs[0], `"title"`) {
+		t.Fatalf("required errors = %v", errs)
+	}
+	if errs := schema.validate([]any{}); len(errs) != 1 || errs[0] != "$: expected object, got array" {
+		t.Fatalf("type errors = %v", errs)
+	}
+}
@@ -0,0 +1,103 @@
+// kb_tools.go — 知识库动态工具 kb_search 与 JSON-RPC kb/sync, kb/status。
+//
+// kb_search 返回带来源引用 (source/title/url/path) 的文档分块, agent 无需手动提及文件即可回答内部文档问题。
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/codex"
+	"github.com/multi-agent/go-agent-v2/internal/config"
+	"github.com/multi-agent/go-agent-v2/internal/service"
+	pkgerr "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+// kbSearchTimeout kb_search 可能触发读穿同步, 超时比普通资源工具更长。
+const kbSearchTimeout = 2 * time.Minute
+
+// newKnowledgeBase 按 KB_CONNECTORS 创建知识库 (未配置或配置无效时返回 nil)。
+func newKnowledgeBase(cfg *config.Config, memory *service.AgentMemoryService, repo service.KBRepository) *service.KnowledgeBase {
+	if cfg == nil {
+		return nil
+	}
+	connectors, err := service.ParseKBConnectors(cfg.KBConnectors)
+	if err != nil {
+		logger.Warn("app-server: invalid KB_CONNECTORS, knowledge base disabled", logger.FieldError, err)
+		return nil
+	}
+	if len(connectors) == 0 {
+		return nil
+	}
+	kb := service.NewKnowledgeBase(memory, repo, connectors, time.Duration(cfg.KBRefreshMin)*time.Minute)
+	logger.Info("app-server: knowledge base enabled", "sources", strings.Join(kb.Sources(), ","))
+	return kb
+}
+
+// buildKBTools 返回知识库工具定义 (未配置连接器时不暴露)。
+func (s *Server) buildKBTools() []codex.DynamicTool {
+	if s.kb == nil {
+		return nil
+	}
+	return []codex.DynamicTool{{
+		Name: "kb_search",
+		Description: "Search the internal knowledge base (" + strings.Join(s.kb.Sources(), ", ") + ") by meaning. " +
+			"Returns document chunks with citations; cite the title and url/path of the sources you rely on.",
+		InputSchema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"query":  map[string]any{"type": "string", "description": "Natural language question or keywords"},
+				"source": map[string]any{"type": "string", "description": "Optional knowledge base source name to restrict the search"},
+				"k":      map[string]any{"type": "integer", "description": "Number of results (default 5, max 20)"},
+			},
+			"required": []string{"query"},
+		},
+	}}
+}
+
+func (s *Server) kbSearchTool(args json.RawMessage) string {
+	if s.kb == nil {
+		return toolError(pkgerr.New("KBTool.Search", "knowledge base not configured"))
+	}
+	var p struct {
+		Query  string `json:"query"`
+		Source string `json:"source"`
+		K      int    `json:"k"`
+	}
+	if err := json.Unmarshal(args, &p); err != nil {
+		return toolError(pkgerr.Wrap(err, "KBTool.Search", "invalid args"))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), kbSearchTimeout)
+	defer cancel()
+	results, err := s.kb.Search(ctx, p.Query, strings.TrimSpace(p.Source), min(p.K, 20))
+	if err != nil {
+		return toolError(pkgerr.Wrap(err, "KBTool.Search", "search"))
+	}
+	return toolJSON(map[string]any{"query": p.Query, "results": results, "count": len(results)})
+}
+
+// kbSyncParams kb/sync 请求参数。
+type kbSyncParams struct {
+	Source string `json:"source,omitempty"` // 空 = 全部来源
+}
+
+func (s *Server) kbSyncTyped(ctx context.Context, p kbSyncParams) (any, error) {
+	if s.kb == nil {
+		return nil, pkgerr.New("Server.kbSync", "knowledge base not configured")
+	}
+	results, err := s.kb.Sync(ctx, strings.TrimSpace(p.Source), true)
+	if err != nil {
+		return nil, pkgerr.Wrap(err, "Server.kbSync", "sync")
+	}
+	return map[string]any{"results": results}, nil
+}
+
+func (s *Server) kbStatus(_ context.Context, _ json.RawMessage) (any, error) {
+	if s.kb == nil {
+		return map[string]any{"configured": false, "sources": []any{}}, nil
+	}
+	return map[string]any{"configured": true, "sources": s.kb.Status()}, nil
+}
@@ -84,7 +84,7 @@ func TestHandleApprovalRequest_DenyFuncError_LogsWarn(t *testing.T) {
 	s := &Server{
 		mgr:     nil, // mgr==nil → 走 deny 路径
 		conns:   map[string]*connEntry{},
-		pending: make(map[int64]chan *Response), // Wails 模式需要
+		pending: make(map[int64]pendingRequest), // Wails 模式需要
 	}
 
 	event := codex.Event{
@@ -0,0 +1,402 @@
+// log_retention.go — system_logs 批量写入与保留策略 (log/ingest, log/retention/get|set)。
+//
+// 保留策略以 UI 偏好存储 (settings.logRetention: {"days": 30, "archive": true}),
+// 未设置的字段取 LOG_RETENTION_* 配置。后台按 LOG_RETENTION_INTERVAL_MIN 周期清理:
+// 超过天数的日志按 id 分批读出, 写入归档目录下的 gzip JSONL 文件并 fsync 后再删除,
+// 归档写盘失败时停止本轮清理 (不删除未归档的数据); archive=false 时直接分批删除。
+package apiserver
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/store"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+	"github.com/multi-agent/go-agent-v2/pkg/util"
+)
+
+const (
+	prefKeyLogRetention = "settings.logRetention"
+
+	logRetentionBatchSize    = 5000
+	logRetentionInitialDelay = time.Minute
+	logRetentionRunTimeout   = 30 * time.Minute
+	maxLogRetentionDays      = 3650
+	maxLogIngestEntries      = 5000
+)
+
+// logRetentionPolicy 保留策略 (Days = 0 表示永久保留)。
+type logRetentionPolicy struct {
+	Days    int  `json:"days"`
+	Archive bool `json:"archive"`
+}
+
+// logRetentionResult 单轮清理结果。
+type logRetentionResult struct {
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+	Cutoff     time.Time `json:"cutoff"`
+	Archived   int64     `json:"archived"`
+	Deleted    int64     `json:"deleted"`
+	File       string    `json:"file,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// logRetentionState 清理串行化与最近一次结果。
+type logRetentionState struct {
+	runMu sync.Mutex
+
+	mu   sync.Mutex
+	last *logRetentionResult
+}
+
+func (st *logRetentionState) lastResult() *logRetentionResult {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.last == nil {
+		return nil
+	}
+	cp := *st.last
+	return &cp
+}
+
+func (st *logRetentionState) record(result *logRetentionResult) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	cp := *result
+	st.last = &cp
+}
+
+func (s *Server) defaultLogRetentionPolicy() logRetentionPolicy {
+	if s.cfg == nil {
+		return logRetentionPolicy{Days: 30, Archive: true}
+	}
+	return logRetentionPolicy{Days: s.cfg.LogRetentionDays, Archive: s.cfg.LogRetentionArchive}
+}
+
+// loadLogRetentionPolicy 配置默认值叠加偏好覆盖。
+func (s *Server) loadLogRetentionPolicy(ctx context.Context) logRetentionPolicy {
+	policy := s.defaultLogRetentionPolicy()
+	if s.prefManager == nil {
+		return policy
+	}
+	value, err := s.prefManager.Get(ctx, prefKeyLogRetention)
+	if err != nil {
+		logger.Warn("log retention: load preference failed", logger.FieldError, err)
+		return policy
+	}
+	if value == nil {
+		return policy
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return policy
+	}
+	override := policy
+	if err := json.Unmarshal(raw, &override); err != nil || override.Days < 0 {
+		logger.Warn("log retention: invalid preference ignored", logger.FieldError, err)
+		return policy
+	}
+	return override
+}
+
+func (s *Server) logArchiveDir() (string, error) {
+	if s.cfg != nil {
+		if dir := strings.TrimSpace(s.cfg.LogArchiveDir); dir != "" {
+			return dir, nil
+		}
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", apperrors.Wrap(err, "Server.logArchiveDir", "resolve user home")
+	}
+	return filepath.Join(homeDir, ".multi-agent", "log-archive"), nil
+}
+
+// startLogRetentionLoop 周期执行保留策略 (LOG_RETENTION_INTERVAL_MIN = 0 时关闭)。
+func (s *Server) startLogRetentionLoop(ctx context.Context) {
+	if s.sysLogStore == nil || s.cfg == nil || s.cfg.LogRetentionIntervalMin <= 0 {
+		return
+	}
+	interval := time.Duration(s.cfg.LogRetentionIntervalMin) * time.Minute
+	util.SafeGo(func() {
+		timer := time.NewTimer(logRetentionInitialDelay)
+		defer timer.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+			}
+			if _, err := s.runLogRetention(ctx, time.Now()); err != nil {
+				logger.Warn("log retention: run failed", logger.FieldError, err)
+			}
+			timer.Reset(interval)
+		}
+	})
+}
+
+// runLogRetention 执行一轮清理; 已有清理在进行时直接返回错误。
+func (s *Server) runLogRetention(ctx context.Context, now time.Time) (*logRetentionResult, error) {
+	const op = "Server.runLogRetention"
+	if s.sysLogStore == nil {
+		return nil, apperrors.New(op, "log store not initialized")
+	}
+	if !s.logRetention.runMu.TryLock() {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "log retention already running")
+	}
+	defer s.logRetention.runMu.Unlock()
+
+	policy := s.loadLogRetentionPolicy(ctx)
+	if policy.Days <= 0 {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, logRetentionRunTimeout)
+	defer cancel()
+
+	result := &logRetentionResult{StartedAt: now, Cutoff: now.Add(-time.Duration(policy.Days) * 24 * time.Hour)}
+	var err error
+	if policy.Archive {
+		err = s.archiveLogsBefore(ctx, result)
+	} else {
+		err = s.pruneLogsBefore(ctx, result)
+	}
+	result.FinishedAt = time.Now()
+	if err != nil {
+		result.Error = err.Error()
+	}
+	s.logRetention.record(result)
+	logger.Info("log retention: run finished",
+		"cutoff", result.Cutoff,
+		"archived", result.Archived,
+		"deleted", result.Deleted,
+		logger.FieldPath, result.File,
+		logger.FieldDurationMS, result.FinishedAt.Sub(result.StartedAt).Milliseconds(),
+	)
+	return result, err
+}
+
+func (s *Server) pruneLogsBefore(ctx context.Context, result *logRetentionResult) error {
+	for {
+		n, err := s.sysLogStore.PruneBefore(ctx, result.Cutoff, logRetentionBatchSize)
+		if err != nil {
+			return apperrors.Wrap(err, "Server.pruneLogsBefore", "delete expired logs")
+		}
+		result.Deleted += n
+		if n < logRetentionBatchSize {
+			return nil
+		}
+	}
+}
+
+func (s *Server) archiveLogsBefore(ctx context.Context, result *logRetentionResult) error {
+	const op = "Server.archiveLogsBefore"
+	var (
+		archive *logArchiveWriter
+		afterID int
+	)
+	defer func() {
+		if archive != nil {
+			if err := archive.close(); err != nil {
+				logger.Warn("log retention: close archive failed", logger.FieldError, err, logger.FieldPath, archive.path)
+			}
+		}
+	}()
+	for {
+		rows, err := s.sysLogStore.ListBefore(ctx, result.Cutoff, afterID, logRetentionBatchSize)
+		if err != nil {
+			return apperrors.Wrap(err, op, "list expired logs")
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		if archive == nil {
+			dir, err := s.logArchiveDir()
+			if err != nil {
+				return err
+			}
+			name := "system_logs_before_" + result.Cutoff.UTC().Format("20060102") + "_" + result.StartedAt.UTC().Format("20060102T150405") + ".jsonl.gz"
+			if archive, err = createLogArchive(filepath.Join(dir, name)); err != nil {
+				return err
+			}
+			result.File = archive.path
+		}
+		if err := archive.write(rows); err != nil {
+			return err
+		}
+		ids := make([]int, len(rows))
+		for i := range rows {
+			ids[i] = rows[i].ID
+		}
+		result.Archived += int64(len(rows))
+		deleted, err := s.sysLogStore.DeleteIDs(ctx, ids)
+		if err != nil {
+			return apperrors.Wrap(err, op, "delete archived logs")
+		}
+		result.Deleted += deleted
+		afterID = ids[len(ids)-1]
+		if len(rows) < logRetentionBatchSize {
+			return nil
+		}
+	}
+}
+
+// logArchiveWriter gzip JSONL 归档文件 (每批写入后 flush + fsync, 保证删除前已落盘)。
+type logArchiveWriter struct {
+	path string
+	file *os.File
+	gz   *gzip.Writer
+	enc  *json.Encoder
+}
+
+func createLogArchive(path string) (*logArchiveWriter, error) {
+	const op = "createLogArchive"
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, apperrors.Wrap(err, op, "create archive dir")
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, apperrors.Wrap(err, op, "create archive file")
+	}
+	gz := gzip.NewWriter(f)
+	return &logArchiveWriter{path: path, file: f, gz: gz, enc: json.NewEncoder(gz)}, nil
+}
+
+func (w *logArchiveWriter) write(rows []store.SystemLog) error {
+	const op = "logArchiveWriter.write"
+	for i := range rows {
+		if err := w.enc.Encode(&rows[i]); err != nil {
+			return apperrors.Wrap(err, op, "encode log row")
+		}
+	}
+	if err := w.gz.Flush(); err != nil {
+		return apperrors.Wrap(err, op, "flush archive")
+	}
+	if err := w.file.Sync(); err != nil {
+		return apperrors.Wrap(err, op, "sync archive")
+	}
+	return nil
+}
+
+func (w *logArchiveWriter) close() error {
+	gzErr := w.gz.Close()
+	fileErr := w.file.Close()
+	if gzErr != nil {
+		return gzErr
+	}
+	return fileErr
+}
+
+// ========================================
+// JSON-RPC
+// ========================================
+
+// logRetentionGet 返回生效策略与最近一次清理结果 (JSON-RPC: log/retention/get)。
+func (s *Server) logRetentionGet(ctx context.Context, _ json.RawMessage) (any, error) {
+	result := map[string]any{
+		"policy":   s.loadLogRetentionPolicy(ctx),
+		"defaults": s.defaultLogRetentionPolicy(),
+		"lastRun":  s.logRetention.lastResult(),
+	}
+	if s.cfg != nil {
+		result["intervalMin"] = s.cfg.LogRetentionIntervalMin
+	}
+	if dir, err := s.logArchiveDir(); err == nil {
+		result["archiveDir"] = dir
+	}
+	return result, nil
+}
+
+type logRetentionSetParams struct {
+	Days    *int  `json:"days,omitempty"`
+	Archive *bool `json:"archive,omitempty"`
+	RunNow  bool  `json:"runNow,omitempty"` // 保存后立即执行一轮清理
+}
+
+// logRetentionSetTyped 更新保留策略 (JSON-RPC: log/retention/set)。
+func (s *Server) logRetentionSetTyped(ctx context.Context, p logRetentionSetParams) (any, error) {
+	const op = "Server.logRetentionSet"
+	if s.prefManager == nil {
+		return nil, apperrors.New(op, "preference manager not initialized")
+	}
+	policy := s.loadLogRetentionPolicy(ctx)
+	if p.Days != nil {
+		if *p.Days < 0 || *p.Days > maxLogRetentionDays {
+			return nil, apperrors.NewCodef(op, errcode.InvalidInput, "days must be between 0 and %d", maxLogRetentionDays)
+		}
+		policy.Days = *p.Days
+	}
+	if p.Archive != nil {
+		policy.Archive = *p.Archive
+	}
+	if err := s.prefManager.Set(ctx, prefKeyLogRetention, policy); err != nil {
+		return nil, err
+	}
+	logger.Info("log/retention/set: saved", "days", policy.Days, "archive", policy.Archive)
+
+	resp := map[string]any{"policy": policy}
+	if p.RunNow {
+		result, err := s.runLogRetention(ctx, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		resp["result"] = result
+	}
+	return resp, nil
+}
+
+type logIngestParams struct {
+	Entries []store.SystemLog `json:"entries"`
+}
+
+// logIngestTyped 批量写入外部日志 (JSON-RPC: log/ingest, COPY 单次往返)。
+func (s *Server) logIngestTyped(ctx context.Context, p logIngestParams) (any, error) {
+	const op = "Server.logIngest"
+	if s.sysLogStore == nil {
+		return nil, apperrors.New(op, "log store not initialized")
+	}
+	if len(p.Entries) == 0 {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "entries is required")
+	}
+	if len(p.Entries) > maxLogIngestEntries {
+		return nil, apperrors.NewCodef(op, errcode.InvalidInput, "at most %d entries per call", maxLogIngestEntries)
+	}
+	for i := range p.Entries {
+		if err := normalizeIngestEntry(&p.Entries[i]); err != nil {
+			return nil, apperrors.WrapCodef(err, op, errcode.InvalidInput, "entries[%d]", i)
+		}
+	}
+	n, err := s.sysLogStore.AppendBatch(ctx, p.Entries)
+	if err != nil {
+		return nil, apperrors.Wrap(err, op, "copy logs")
+	}
+	return map[string]any{"inserted": n}, nil
+}
+
+// normalizeIngestEntry 补默认值 (level=INFO, source=ingest) 并校验必填字段。
+func normalizeIngestEntry(e *store.SystemLog) error {
+	e.ID = 0
+	e.Message = strings.TrimSpace(e.Message)
+	if e.Message == "" {
+		return apperrors.New("normalizeIngestEntry", "message is required")
+	}
+	e.Level = strings.ToUpper(strings.TrimSpace(e.Level))
+	if e.Level == "" {
+		e.Level = "INFO"
+	}
+	if strings.TrimSpace(e.Source) == "" {
+		e.Source = "ingest"
+	}
+	if e.Raw == "" {
+		e.Raw = e.Message
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package apiserver
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/config"
+	"github.com/multi-agent/go-agent-v2/internal/store"
+	"github.com/multi-agent/go-agent-v2/internal/uistate"
+)
+
+func TestLogArchiveWriterRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "logs.jsonl.gz")
+	w, err := createLogArchive(path)
+	if err != nil {
+		t.Fatalf("createLogArchive: %v", err)
+	}
+	ts := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := w.write([]store.SystemLog{{ID: 1, Ts: ts, Level: "INFO", Message: "a"}}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := w.write([]store.SystemLog{{ID: 2, Ts: ts, Level: "WARN", Message: "b"}}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := w.close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if _, err := createLogArchive(path); err == nil {
+		t.Fatal("existing archive must not be overwritten")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	var got []store.SystemLog
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		var row store.SystemLog
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		got = append(got, row)
+	}
+	if len(got) != 2 || got[0].ID != 1 || got[1].Message != "b" || !got[0].Ts.Equal(ts) {
+		t.Fatalf("rows = %+v", got)
+	}
+}
+
+func TestLogRetentionPolicyPreferenceOverride(t *testing.T) {
+	srv := &Server{
+		cfg:         &config.Config{LogRetentionDays: 14, LogRetentionArchive: true},
+		prefManager: uistate.NewPreferenceManager(nil),
+	}
+	ctx := context.Background()
+	if got := srv.loadLogRetentionPolicy(ctx); got.Days != 14 || !got.Archive {
+		t.Fatalf("default policy = %+v", got)
+	}
+
+	archive := false
+	if _, err := srv.logRetentionSetTyped(ctx, logRetentionSetParams{Archive: &archive}); err != nil {
+		t.Fatalf("set archive: %v", err)
+	}
+	if got := srv.loadLogRetentionPolicy(ctx); got.Days != 14 || got.Archive {
+		t.Fatalf("partial update policy = %+v", got)
+	}
+
+	bad := -1
+	if _, err := srv.logRetentionSetTyped(ctx, logRetentionSetParams{Days: &bad}); err == nil {
+		t.Fatal("negative days must be rejected")
+	}
+	if _, err := srv.runLogRetention(ctx, time.Now()); err == nil {
+		t.Fatal("run without log store must fail")
+	}
+}
+
+func TestNormalizeIngestEntry(t *testing.T) {
+	e := store.SystemLog{ID: 42, Level: " warn ", Message: " disk full "}
+	if err := normalizeIngestEntry(&e); err != nil {
+		t.Fatalf("normalize: %v", err)
+	}
+	if e.ID != 0 || e.Level != "WARN" || e.Source != "ingest" || e.Message != "disk full" || e.Raw != "disk full" {
+		t.Fatalf("entry = %+v", e)
+	}
+	if err := normalizeIngestEntry(&store.SystemLog{Message: "  "}); err == nil {
+		t.Fatal("empty message must be rejected")
+	}
+}
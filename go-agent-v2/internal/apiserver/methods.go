@@ -95,24 +95,87 @@ func (s *Server) registerMethods() {
 	s.methods["thread/start"] = typedHandler(s.threadStartTyped)
 	s.methods["thread/resume"] = typedHandler(s.threadResumeTyped)
 	s.methods["thread/fork"] = typedHandler(s.threadForkTyped)
+	s.methods["thread/lineage"] = typedHandler(s.threadLineageTyped)
+	s.methods["thread/cwd/set"] = typedHandler(s.threadCwdSetTyped)
 	s.methods["thread/archive"] = typedHandler(s.threadArchiveTyped)
 	s.methods["thread/unarchive"] = typedHandler(s.threadUnarchiveTyped)
 	s.methods["thread/name/set"] = typedHandler(s.threadNameSetTyped)
+	s.methods["thread/meta/set"] = typedHandler(s.threadMetaSetTyped)
+	s.methods["thread/tags/set"] = typedHandler(s.threadTagsSetTyped)
+	s.methods["thread/tags/list"] = s.threadTagsList
+	s.methods["thread/filters/list"] = s.threadFiltersList
+	s.methods["thread/filters/save"] = typedHandler(s.threadFilterSaveTyped)
+	s.methods["thread/filters/delete"] = typedHandler(s.threadFilterDeleteTyped)
+	s.methods["thread/env/get"] = typedHandler(s.threadEnvGetTyped)
+	s.methods["thread/env/set"] = typedHandler(s.threadEnvSetTyped)
+	s.methods["fleet/apply"] = typedHandler(s.fleetApplyTyped)
+	s.methods["fleet/status"] = typedHandler(s.fleetStatusTyped)
+	s.methods["agent/health/list"] = typedHandler(s.agentHealthListTyped)
+	s.methods["agent/health/incidents"] = typedHandler(s.agentHealthIncidentsTyped)
+	s.methods["agent/capabilities"] = typedHandler(s.agentCapabilitiesTyped)
+	s.methods["codex/binary/status"] = typedHandler(s.codexBinaryStatusTyped)
+	s.methods["memory/search"] = typedHandler(s.memorySearchTyped)
+	s.methods["memory/inject"] = typedHandler(s.memoryInjectTyped)
+	s.methods["kb/sync"] = typedHandler(s.kbSyncTyped)
+	s.methods["kb/status"] = s.kbStatus
+	s.methods["group/join"] = typedHandler(s.groupJoinTyped)
+	s.methods["group/leave"] = typedHandler(s.groupLeaveTyped)
+	s.methods["group/list"] = typedHandler(s.groupListTyped)
+	s.methods["group/transfer"] = typedHandler(s.groupTransferTyped)
+	s.methods["approval/respond"] = typedHandler(s.approvalRespondTyped)
 	s.methods["thread/compact/start"] = s.threadCompact
 	s.methods["thread/rollback"] = typedHandler(s.threadRollbackTyped)
+	s.methods["thread/undo/preview"] = typedHandler(s.threadUndoPreviewTyped)
+	s.methods["thread/undo"] = typedHandler(s.threadUndoTyped)
+	s.methods["thread/context/pin"] = typedHandler(s.threadContextPinTyped)
+	s.methods["thread/context/unpin"] = typedHandler(s.threadContextUnpinTyped)
+	s.methods["thread/context/list"] = typedHandler(s.threadContextListTyped)
 	s.methods["thread/list"] = s.threadList
 	s.methods["thread/loaded/list"] = s.threadLoadedList
 	s.methods["thread/read"] = typedHandler(s.threadReadTyped)
 	s.methods["thread/resolve"] = typedHandler(s.threadResolveTyped)
 	s.methods["thread/messages"] = typedHandler(s.threadMessagesTyped)
+	s.methods["thread/search"] = typedHandler(s.threadSearchTyped)
+	s.methods["thread/stateAt"] = typedHandler(s.threadStateAtTyped)
+	s.methods["thread/diff/get"] = typedHandler(s.threadDiffGetTyped)
+	s.methods["thread/diff/export"] = typedHandler(s.threadDiffExportTyped)
+	s.methods["thread/export"] = typedHandler(s.threadExportTyped)
+	s.methods["thread/import"] = typedHandler(s.threadImportTyped)
+	s.methods["thread/transcript/export"] = typedHandler(s.threadTranscriptExportTyped)
+	s.methods["data/export"] = typedHandler(s.dataExportTyped)
 	s.methods["thread/backgroundTerminals/clean"] = s.threadBgTerminalsClean
+	s.methods["terminal/attach"] = typedHandler(s.terminalAttachTyped)
+	s.methods["terminal/stdin"] = typedHandler(s.terminalStdinTyped)
+	s.methods["terminal/detach"] = typedHandler(s.terminalDetachTyped)
+	s.methods["terminal/list"] = s.terminalList
 
 	// § 3. 对话控制 (4 methods)
 	s.methods["turn/start"] = typedHandler(s.turnStartTyped)
+	s.methods["turn/startFromTemplate"] = typedHandler(s.turnStartFromTemplateTyped)
 	s.methods["turn/steer"] = typedHandler(s.turnSteerTyped)
 	s.methods["turn/interrupt"] = s.turnInterrupt
+	s.methods["turn/speculate"] = typedHandler(s.turnSpeculateTyped)
+	s.methods["turn/speculate/result"] = typedHandler(s.turnSpeculateResultTyped)
+	s.methods["question/answer"] = typedHandler(s.questionAnswerTyped)
+	s.methods["question/list"] = typedHandler(s.questionListTyped)
+	s.methods["thread/stop/graceful"] = typedHandler(s.threadStopGracefulTyped)
+	s.methods["thread/handoff/list"] = typedHandler(s.threadHandoffListTyped)
 	s.methods["turn/forceComplete"] = s.turnForceComplete
+	s.methods["turn/await"] = typedHandler(s.turnAwaitTyped)
+	s.methods["turn/result/get"] = typedHandler(s.turnResultGetTyped)
 	s.methods["review/start"] = typedHandler(s.reviewStartTyped)
+	s.methods["review/findings/list"] = typedHandler(s.reviewFindingsListTyped)
+	s.methods["review/findings/accept"] = typedHandler(s.reviewFindingAcceptTyped)
+	s.methods["review/findings/dismiss"] = typedHandler(s.reviewFindingDismissTyped)
+	s.methods["promptTemplate/render"] = typedHandler(s.promptTemplateRenderTyped)
+	s.methods["artifact/put"] = typedHandler(s.artifactPutTyped)
+	s.methods["artifact/get"] = typedHandler(s.artifactGetTyped)
+	s.methods["artifact/list"] = typedHandler(s.artifactListTyped)
+	s.methods["input/prepareFiles"] = typedHandler(s.inputPrepareFilesTyped)
+	s.methods["input/stageImage"] = typedHandler(s.inputStageImageTyped)
+	s.methods["tools/list"] = typedHandler(s.toolsListTyped)
+	s.methods["tools/setEnabled"] = typedHandler(s.toolsSetEnabledTyped)
+	s.methods["tools/reload"] = s.toolsReload
 
 	// § 4. 文件搜索 (4 methods)
 	s.methods["fuzzyFileSearch"] = typedHandler(s.fuzzyFileSearchTyped)
@@ -131,6 +194,8 @@ func (s *Server) registerMethods() {
 	s.methods["skills/config/write"] = typedHandler(s.skillsConfigWriteTyped)
 	s.methods["skills/summary/write"] = typedHandler(s.skillsSummaryWriteTyped)
 	s.methods["skills/match/preview"] = typedHandler(s.skillsMatchPreviewTyped)
+	s.methods["skills/registry/sync"] = typedHandler(s.skillsRegistrySyncTyped)
+	s.methods["skills/validate"] = typedHandler(s.skillsValidateTyped)
 	s.methods["app/list"] = s.appList
 
 	// § 6. 模型 / 配置 (7 methods)
@@ -142,6 +207,38 @@ func (s *Server) registerMethods() {
 	s.methods["config/batchWrite"] = typedHandler(s.configBatchWriteTyped)
 	s.methods["config/lspPromptHint/read"] = s.configLSPPromptHintRead
 	s.methods["config/lspPromptHint/write"] = typedHandler(s.configLSPPromptHintWriteTyped)
+	s.methods["config/turnDedup/read"] = s.configTurnDedupRead
+	s.methods["config/turnDedup/write"] = typedHandler(s.configTurnDedupWriteTyped)
+	s.methods["config/quietHours/read"] = s.configQuietHoursRead
+	s.methods["config/quietHours/write"] = typedHandler(s.configQuietHoursWriteTyped)
+	s.methods["config/modelRouting/read"] = s.configModelRoutingRead
+	s.methods["config/modelRouting/write"] = typedHandler(s.configModelRoutingWriteTyped)
+	s.methods["config/validate"] = typedHandler(s.configValidateTyped)
+	s.methods["config/reload"] = s.configReload
+	s.methods["config/drift"] = typedHandler(s.configDriftTyped)
+	s.methods["cache/stats"] = s.cacheStats
+	s.methods["quietHours/status"] = s.quietHoursStatus
+	s.methods["agentTemplate/create"] = typedHandler(s.agentTemplateCreateTyped)
+	s.methods["agentTemplate/list"] = s.agentTemplateList
+	s.methods["agentTemplate/apply"] = typedHandler(s.agentTemplateApplyTyped)
+	s.methods["personality/list"] = s.personalityList
+	s.methods["personality/create"] = typedHandler(s.personalityCreateTyped)
+	s.methods["personality/apply"] = typedHandler(s.personalityApplyTyped)
+	s.methods["orchestrator/emergencyStop"] = typedHandler(s.emergencyStopTyped)
+	s.methods["orchestrator/unlock"] = typedHandler(s.emergencyUnlockTyped)
+	s.methods["orchestrator/status"] = s.emergencyStatus
+	s.methods["orchestrate/fanout"] = typedHandler(s.orchestrateFanoutTyped)
+	s.methods["orchestrate/fanout/result"] = typedHandler(s.orchestrateFanoutResultTyped)
+	s.methods["orchestrate/plan"] = typedHandler(s.orchestratePlanTyped)
+	s.methods["orchestrate/plan/status"] = typedHandler(s.orchestratePlanStatusTyped)
+	s.methods["server/drain"] = typedHandler(s.serverDrainTyped)
+	s.methods["server/drain/status"] = s.serverDrainStatus
+	s.methods["persist/status"] = s.persistStatus
+	s.methods["persist/replay"] = s.persistReplay
+	s.methods["scheduler/queue"] = s.schedulerQueue
+	s.methods["scheduler/cancel"] = typedHandler(s.schedulerCancelTyped)
+	s.methods["lsp/catalog"] = s.lspCatalog
+	s.methods["lsp/install"] = typedHandler(s.lspInstallTyped)
 	s.methods["configRequirements/read"] = s.configRequirementsRead
 
 	// § 7. 账号 (5 methods)
@@ -159,12 +256,23 @@ func (s *Server) registerMethods() {
 
 	// § 9. 命令执行 / 其他 (2 methods)
 	s.methods["command/exec"] = typedHandler(s.commandExecTyped)
+	s.methods["command/exec/stream"] = typedHandler(s.commandExecStreamTyped)
+	s.methods["command/exec/cancel"] = typedHandler(s.commandExecCancelTyped)
+	s.methods["command/exec/list"] = s.commandExecList
+	s.methods["sandbox/profile/list"] = s.sandboxProfileList
+	s.methods["sandbox/profile/get"] = typedHandler(s.sandboxProfileGetTyped)
+	s.methods["sandbox/profile/set"] = typedHandler(s.sandboxProfileSetTyped)
+	s.methods["notify/channel/list"] = s.notifyChannelList
+	s.methods["notify/channel/create"] = typedHandler(s.notifyChannelCreateTyped)
+	s.methods["notify/channel/update"] = typedHandler(s.notifyChannelUpdateTyped)
+	s.methods["notify/channel/delete"] = typedHandler(s.notifyChannelDeleteTyped)
+	s.methods["notify/channel/test"] = typedHandler(s.notifyChannelTestTyped)
 	s.methods["feedback/upload"] = noop
 
 	// § 10. 斜杠命令 (SOCKS 独有, JSON-RPC 化)
 	s.methods["thread/undo"] = s.threadUndo
 	s.methods["thread/model/set"] = s.threadModelSet
-	s.methods["thread/personality/set"] = s.threadPersonality
+	s.methods["thread/personality/set"] = typedHandler(s.threadPersonalityTyped)
 	s.methods["thread/approvals/set"] = s.threadApprovals
 	s.methods["thread/mcp/list"] = s.threadMCPList
 	s.methods["thread/skills/list"] = s.threadSkillsList
@@ -173,16 +281,29 @@ func (s *Server) registerMethods() {
 	// § 11. 系统日志查询 (2 methods)
 	s.methods["log/list"] = typedHandler(s.logListTyped)
 	s.methods["log/filters"] = s.logFilters
+	s.methods["log/query"] = typedHandler(s.logQueryTyped)
+	s.methods["log/queries/list"] = s.logQueriesList
+	s.methods["log/queries/save"] = typedHandler(s.logQuerySaveTyped)
+	s.methods["log/queries/delete"] = typedHandler(s.logQueryDeleteTyped)
+	s.methods["log/ingest"] = typedHandler(s.logIngestTyped)
+	s.methods["log/retention/get"] = s.logRetentionGet
+	s.methods["log/retention/set"] = typedHandler(s.logRetentionSetTyped)
+	s.methods["storage/usage"] = s.storageUsage
+	s.methods["storage/cleanup"] = typedHandler(s.storageCleanupTyped)
 
 	// § 12. Dashboard 数据查询 (12 methods, 替代 Wails Dashboard 绑定)
 	s.registerDashboardMethods()
 
 	// § 13. Workspace Run (双通道编排: 虚拟目录 + PG 状态)
+	s.methods["workspace/root/add"] = typedHandler(s.workspaceRootAddTyped)
+	s.methods["workspace/root/remove"] = typedHandler(s.workspaceRootRemoveTyped)
+	s.methods["workspace/root/list"] = s.workspaceRootList
 	s.methods["workspace/run/create"] = s.workspaceRunCreate
 	s.methods["workspace/run/get"] = s.workspaceRunGet
 	s.methods["workspace/run/list"] = s.workspaceRunList
 	s.methods["workspace/run/merge"] = s.workspaceRunMerge
 	s.methods["workspace/run/abort"] = s.workspaceRunAbort
+	s.methods["workspace/run/pullRequest"] = s.workspaceRunPullRequest
 
 	// § 14. UI State (UI 偏好持久化)
 	s.methods["ui/preferences/get"] = typedHandler(s.uiPreferencesGet)
@@ -195,10 +316,23 @@ func (s *Server) registerMethods() {
 	s.methods["ui/code/open"] = typedHandler(s.uiCodeOpenTyped)
 	s.methods["ui/dashboard/get"] = typedHandler(s.uiDashboardGet)
 	s.methods["ui/state/get"] = s.uiStateGet
+	s.methods[UIThreadOpenMethod] = typedHandler(s.uiThreadOpenTyped)
+	s.methods["ui/layout/get"] = typedHandler(s.uiLayoutGet)
+	s.methods["ui/layout/save"] = typedHandler(s.uiLayoutSave)
+	s.methods[UILayoutResetMethod] = typedHandler(s.uiLayoutReset)
 
 	// § 15. Debug (运行时诊断)
 	s.methods["debug/runtime"] = s.debugRuntime
 	s.methods["debug/gc"] = s.debugForceGC
+	s.methods["debug/events/unknown"] = typedHandler(s.debugEventsUnknown)
+	s.methods["debug/trace/start"] = typedHandler(s.debugTraceStartTyped)
+	s.methods["debug/trace/stop"] = typedHandler(s.debugTraceStopTyped)
+	s.methods["errors/codes"] = s.errorsCodes
+	s.methods["audit/trace/get"] = typedHandler(s.auditTraceGetTyped)
+	s.methods[subscribeMethod] = s.connectionMethodUnavailable
+	s.methods[unsubscribeMethod] = s.connectionMethodUnavailable
+	s.methods[streamCoalesceMethod] = s.connectionMethodUnavailable
+	s.methods["rpc/batch"] = typedHandler(s.rpcBatchTyped)
 
 	// § 16. 前端兼容 Stub (返回空数据, 防止前端 "unregistered method" 报错)
 	//
@@ -246,18 +380,23 @@ func (s *Server) initialize(_ context.Context, params json.RawMessage) (any, err
 			logger.Debug("initialize: unmarshal params", logger.FieldError, err)
 		}
 	}
-	return map[string]any{
+	result := map[string]any{
 		"protocolVersion": "2.0",
 		"serverInfo": map[string]string{
 			"name":    "codex-go-app-server",
 			"version": "0.1.0",
 		},
 		"capabilities": map[string]bool{
-			"threads":    true,
-			"turns":      true,
-			"fileSearch": true,
-			"skills":     true,
-			"exec":       true,
+			"threads":           true,
+			"turns":             true,
+			"fileSearch":        true,
+			"skills":            true,
+			"exec":              true,
+			"agentCapabilities": true,
 		},
-	}, nil
+	}
+	if status, ok := s.codexBinary.get(); ok {
+		result["codexBinary"] = status
+	}
+	return result, nil
 }
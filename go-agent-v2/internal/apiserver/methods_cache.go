@@ -0,0 +1,50 @@
+// methods_cache.go — Store 读缓存配置与统计 (cache/stats 调试 RPC)。
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/store"
+)
+
+// applyStoreCacheTTL 按 STORE_CACHE_TTL_MS 设置热路径 store 的读缓存。
+func (s *Server) applyStoreCacheTTL() {
+	if s.cfg == nil {
+		return
+	}
+	ttl := time.Duration(s.cfg.StoreCacheTTLMS) * time.Millisecond
+	if s.agentStatusStore != nil {
+		s.agentStatusStore.SetCacheTTL(ttl)
+	}
+	if s.bindingStore != nil {
+		s.bindingStore.SetCacheTTL(ttl)
+	}
+}
+
+// cacheStats 返回各 store 读缓存的命中统计。
+func (s *Server) cacheStats(_ context.Context, _ json.RawMessage) (any, error) {
+	caches := []store.CacheStats{}
+	if s.agentStatusStore != nil {
+		caches = append(caches, s.agentStatusStore.CacheStats()...)
+	}
+	if s.bindingStore != nil {
+		caches = append(caches, s.bindingStore.CacheStats()...)
+	}
+	var hits, misses uint64
+	for _, c := range caches {
+		hits += c.Hits
+		misses += c.Misses
+	}
+	hitRate := 0.0
+	if hits+misses > 0 {
+		hitRate = float64(hits) / float64(hits+misses)
+	}
+	return map[string]any{
+		"caches":  caches,
+		"hits":    hits,
+		"misses":  misses,
+		"hitRate": hitRate,
+	}, nil
+}
@@ -3,7 +3,6 @@ package apiserver
 
 import (
 	"context"
-	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
@@ -15,35 +14,10 @@ import (
 )
 
 type commandExecParams struct {
-	Argv []string          `json:"argv"`
-	Cwd  string            `json:"cwd,omitempty"`
-	Env  map[string]string `json:"env,omitempty"`
-}
-
-// commandBlocklist 禁止通过 command/exec 执行的危险命令。
-var commandBlocklist = map[string]bool{
-	"rm":       true,
-	"rmdir":    true,
-	"sudo":     true,
-	"su":       true,
-	"chmod":    true,
-	"chown":    true,
-	"mkfs":     true,
-	"dd":       true,
-	"kill":     true,
-	"killall":  true,
-	"pkill":    true,
-	"shutdown": true,
-	"reboot":   true,
-	"passwd":   true,
-	"useradd":  true,
-	"userdel":  true,
-	"mount":    true,
-	"umount":   true,
-	"fdisk":    true,
-	"iptables": true,
-	"curl":     true, // 防止外部请求
-	"wget":     true,
+	Argv     []string          `json:"argv"`
+	Cwd      string            `json:"cwd,omitempty"`
+	Env      map[string]string `json:"env,omitempty"`
+	ThreadID string            `json:"threadId,omitempty"` // 按线程沙箱配置执行; 空 = 默认预设
 }
 
 const maxOutputSize = 1 << 20 // 1MB 输出限制
@@ -55,47 +29,44 @@ type commandExecResponse struct {
 	Stderr   string `json:"stderr"`
 }
 
-func (s *Server) commandExecTyped(ctx context.Context, p commandExecParams) (any, error) {
-	if len(p.Argv) == 0 {
-		return nil, apperrors.New("Server.commandExec", "argv is required")
+// buildSandboxedCommand 按沙箱配置校验并构造命令 (黑名单 / shell 元字符 / 写入路径)。
+func (s *Server) buildSandboxedCommand(ctx context.Context, profile sandboxProfile, p commandExecParams) (*exec.Cmd, error) {
+	containerRuntime := ""
+	if s.cfg != nil {
+		containerRuntime = s.cfg.CommandSandboxContainerRuntime
 	}
+	return profile.build(ctx, sandboxCommandSpec{
+		Argv:      p.Argv,
+		Cwd:       p.Cwd,
+		Env:       p.Env,
+		ThreadCwd: s.getAgentWorkDir(p.ThreadID),
+	}, containerRuntime)
+}
 
-	// 安全检查: 提取基础命令名 (去掉路径)
-	baseName := filepath.Base(p.Argv[0])
-	if commandBlocklist[baseName] {
-		return nil, apperrors.Newf("Server.commandExec", "command %q is blocked for security", baseName)
+func (s *Server) commandExecTyped(ctx context.Context, p commandExecParams) (any, error) {
+	profile, err := s.resolveSandboxProfile(ctx, p.ThreadID)
+	if err != nil {
+		return nil, err
 	}
 
-	// 禁止管道/shell 注入: 检查参数中是否有 shell 元字符
-	for _, arg := range p.Argv {
-		if strings.ContainsAny(arg, "|;&$`") {
-			return nil, apperrors.New("Server.commandExec", "shell metacharacters not allowed in arguments")
-		}
+	execCtx, cancel := context.WithTimeout(ctx, profile.timeout())
+	defer cancel()
+
+	cmd, err := s.buildSandboxedCommand(execCtx, profile, p)
+	if err != nil {
+		return nil, err
 	}
+	baseName := filepath.Base(p.Argv[0])
 
 	logger.Info("command/exec: starting",
 		logger.FieldCommand, baseName,
-		logger.FieldCwd, p.Cwd,
+		logger.FieldCwd, cmd.Dir,
+		logger.FieldThreadID, p.ThreadID,
+		"sandbox", profile.Name,
+		"container", profile.ContainerImage,
 		"argc", len(p.Argv),
 	)
 
-	execCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
-	defer cancel()
-
-	cmd := exec.CommandContext(execCtx, p.Argv[0], p.Argv[1:]...)
-	if p.Cwd != "" {
-		cmd.Dir = p.Cwd
-	}
-	if len(p.Env) > 0 {
-		cmd.Env = os.Environ()
-		for k, v := range p.Env {
-			if !isAllowedEnvKey(k) {
-				continue // 跳过不允许的环境变量
-			}
-			cmd.Env = append(cmd.Env, k+"="+v)
-		}
-	}
-
 	// 限制输出大小, 防止内存耗尽
 	var stdout, stderr strings.Builder
 	stdout.Grow(4096)
@@ -104,7 +75,7 @@ func (s *Server) commandExecTyped(ctx context.Context, p commandExecParams) (any
 	cmd.Stderr = util.NewLimitedWriter(&stderr, maxOutputSize)
 
 	start := time.Now()
-	err := cmd.Run()
+	err = cmd.Run()
 	elapsed := time.Since(start)
 	exitCode := 0
 	if err != nil {
@@ -91,6 +91,10 @@ var configEnvAllowPrefixes = []string{
 	"AGENT_",
 	"MCP_",
 	"APP_",
+	"HTTP_PROXY", // 线程级代理 (thread/env/set)
+	"HTTPS_PROXY",
+	"ALL_PROXY",
+	"NO_PROXY",
 	"STRESS_TEST_", // 测试用
 	"TEST_E2E_",    // 测试用
 }
@@ -111,13 +115,15 @@ type configValueWriteParams struct {
 	Value string `json:"value"`
 }
 
-func (s *Server) configValueWriteTyped(_ context.Context, p configValueWriteParams) (any, error) {
+func (s *Server) configValueWriteTyped(ctx context.Context, p configValueWriteParams) (any, error) {
 	if !isAllowedEnvKey(p.Key) {
 		return nil, apperrors.Newf("Server.configValueWrite", "key %q not in allowlist", p.Key)
 	}
+	old := os.Getenv(p.Key)
 	if err := os.Setenv(p.Key, p.Value); err != nil {
 		return nil, err
 	}
+	s.recordConfigWrite(ctx, "config/value/write", p.Key, old, p.Value)
 	return map[string]any{}, nil
 }
 
@@ -131,16 +137,19 @@ type configBatchWriteEntry struct {
 	Value string `json:"value"`
 }
 
-func (s *Server) configBatchWriteTyped(_ context.Context, p configBatchWriteParams) (any, error) {
+func (s *Server) configBatchWriteTyped(ctx context.Context, p configBatchWriteParams) (any, error) {
 	var rejected []string
 	for _, e := range p.Entries {
 		if !isAllowedEnvKey(e.Key) {
 			rejected = append(rejected, e.Key)
 			continue
 		}
+		old := os.Getenv(e.Key)
 		if err := os.Setenv(e.Key, e.Value); err != nil {
 			logger.Warn("config/batchWrite: setenv failed", logger.FieldKey, e.Key, logger.FieldError, err)
+			continue
 		}
+		s.recordConfigWrite(ctx, "config/batchWrite", e.Key, old, e.Value)
 	}
 	result := map[string]any{}
 	if len(rejected) > 0 {
@@ -208,6 +217,7 @@ func (s *Server) mcpServerReload(_ context.Context, _ json.RawMessage) (any, err
 
 type lspDiagnosticsQueryParams struct {
 	FilePath string `json:"file_path"`
+	Root     string `json:"root,omitempty"` // 仅返回该工作区根下的诊断 (workspace/root/list)
 }
 
 func (s *Server) lspDiagnosticsQueryTyped(_ context.Context, p lspDiagnosticsQueryParams) (any, error) {
@@ -239,18 +249,19 @@ func (s *Server) lspDiagnosticsQueryTyped(_ context.Context, p lspDiagnosticsQue
 				uri = "file://" + abs
 			}
 		}
-		if diags, ok := s.diagCache[uri]; ok && len(diags) > 0 {
+		if diags := s.diagCache.get(uri); len(diags) > 0 {
 			result[uri] = formatDiagnostics(diags)
 		}
 		return result, nil
 	}
 
-	for uri, diags := range s.diagCache {
-		if len(diags) == 0 {
-			continue
+	root := normalizeAgentWorkDir(p.Root)
+	s.diagCache.each(func(diagRoot, uri string, diags []lsp.Diagnostic) {
+		if len(diags) == 0 || (root != "" && diagRoot != root) {
+			return
 		}
 		result[uri] = formatDiagnostics(diags)
-	}
+	})
 	return result, nil
 }
 
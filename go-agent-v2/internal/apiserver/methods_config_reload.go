@@ -0,0 +1,153 @@
+// methods_config_reload.go — 分层配置校验与运行时热更新 (config/validate, config/reload)。
+//
+// config/reload 按启动时的来源 (配置文件 + 环境变量 + 命令行覆盖) 重新加载,
+// 仅应用 reload:"true" 的安全键 (日志级别、stall 阈值), 其余变更在响应中标记为需重启。
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/config"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+type configValidateParams struct {
+	Path    string `json:"path,omitempty"`    // 校验指定文件
+	Content string `json:"content,omitempty"` // 校验文本 (优先于 path)
+	Format  string `json:"format,omitempty"`  // content 的格式: yaml (默认) / toml
+}
+
+// configValidateTyped 校验配置; 未指定 path/content 时按当前来源完整加载一次 (不应用)。
+func (s *Server) configValidateTyped(_ context.Context, p configValidateParams) (any, error) {
+	const op = "Server.configValidate"
+	var (
+		issues []config.Issue
+		err    error
+	)
+	switch {
+	case p.Content != "":
+		format := strings.ToLower(strings.TrimSpace(p.Format))
+		if format == "" {
+			format = "yaml"
+		}
+		issues, err = config.ValidateContent([]byte(p.Content), format)
+	case strings.TrimSpace(p.Path) != "":
+		issues, err = config.ValidateFile(strings.TrimSpace(p.Path))
+	default:
+		_, report, loadErr := config.LoadWithOptions(s.configLoadOptions())
+		if report == nil || (loadErr != nil && len(report.Issues) == 0) {
+			return nil, apperrors.WrapCode(loadErr, op, errcode.InvalidInput, "load config")
+		}
+		return map[string]any{
+			"valid":   len(report.Issues) == 0,
+			"file":    report.File,
+			"issues":  report.Issues,
+			"sources": report.Sources,
+		}, nil
+	}
+	if err != nil {
+		return nil, apperrors.WrapCode(err, op, errcode.InvalidInput, "parse config")
+	}
+	return map[string]any{"valid": len(issues) == 0, "file": p.Path, "issues": issues}, nil
+}
+
+// configReload 重新加载配置并应用可热更新的键。
+func (s *Server) configReload(ctx context.Context, _ json.RawMessage) (any, error) {
+	const op = "Server.configReload"
+	if s.cfg == nil {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "server started without config")
+	}
+	s.configReloadMu.Lock()
+	defer s.configReloadMu.Unlock()
+
+	next, report, err := config.LoadWithOptions(s.configLoadOptions())
+	if err != nil {
+		// 任一键校验失败即整体拒绝, 避免部分应用。
+		return nil, apperrors.WrapCode(err, op, errcode.InvalidInput, "reload config")
+	}
+	changes := config.Diff(s.cfg, next)
+	applied := config.ApplyReloadable(s.cfg, next)
+	s.cfg.ConfigFile = next.ConfigFile
+	s.applyRuntimeConfig(applied)
+
+	restartRequired := []string{}
+	for _, c := range changes {
+		s.recordConfigWrite(ctx, "config/reload", c.Key, fmt.Sprint(c.Old), fmt.Sprint(c.New))
+	}
+	for i := range changes {
+		if !changes[i].Reloadable {
+			restartRequired = append(restartRequired, changes[i].Key)
+		}
+		if isSecretConfigKey(changes[i].Key) {
+			changes[i].Old, changes[i].New = "***", "***"
+		}
+	}
+	if applied == nil {
+		applied = []string{}
+	}
+	if changes == nil {
+		changes = []config.Change{}
+	}
+	logger.Info("config: reloaded",
+		logger.FieldPath, report.File,
+		"applied", applied,
+		"restart_required", restartRequired,
+	)
+	result := map[string]any{
+		"file":            report.File,
+		"applied":         applied,
+		"restartRequired": restartRequired,
+		"changes":         changes,
+		"reloadableKeys":  config.ReloadableKeys(),
+	}
+	s.Notify("config/reloaded", result)
+	return result, nil
+}
+
+func (s *Server) configLoadOptions() config.LoadOptions {
+	if s.cfg == nil {
+		return config.LoadOptions{}
+	}
+	return config.LoadOptions{File: s.cfg.ConfigFile, Overrides: s.cfg.ConfigOverrides}
+}
+
+// applyRuntimeConfig 将已写入 s.cfg 的热更新键同步到运行时状态。
+func (s *Server) applyRuntimeConfig(keys []string) {
+	for _, key := range keys {
+		switch key {
+		case "LOG_LEVEL":
+			if !logger.SetLevel(s.cfg.LogLevel) {
+				logger.Warn("config: unknown log level ignored", "level", s.cfg.LogLevel)
+			}
+		case "STALL_THRESHOLD_SEC":
+			s.turnMu.Lock()
+			s.stallThreshold = time.Duration(s.cfg.StallThresholdSec) * time.Second
+			s.turnMu.Unlock()
+		case "STALL_HEARTBEAT_SEC":
+			s.turnMu.Lock()
+			s.stallHeartbeat = time.Duration(s.cfg.StallHeartbeatSec) * time.Second
+			s.turnMu.Unlock()
+		case "STALL_REMEDIATION", "STALL_REMEDIATION_GRACE_SEC":
+			s.turnMu.Lock()
+			s.applyStallRemediationConfig()
+			s.turnMu.Unlock()
+		case "STORE_CACHE_TTL_MS":
+			s.applyStoreCacheTTL()
+		}
+	}
+}
+
+func isSecretConfigKey(key string) bool {
+	for _, marker := range []string{"KEY", "TOKEN", "SECRET", "PASSWORD", "CONNECTION_STRING"} {
+		if strings.Contains(key, marker) {
+			return true
+		}
+	}
+	return false
+}
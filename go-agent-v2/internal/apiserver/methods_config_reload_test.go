@@ -0,0 +1,78 @@
+package apiserver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/config"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+func TestConfigReloadAppliesSafeKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeYAML := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+	}
+	writeYAML("STALL_THRESHOLD_SEC: 480\nLLM_MODEL: gpt-4o\n")
+	cfg, _, err := config.LoadWithOptions(config.LoadOptions{File: path})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	srv := &Server{cfg: cfg, stallThreshold: 480 * time.Second}
+	defer logger.SetLevel("INFO")
+
+	writeYAML("STALL_THRESHOLD_SEC: 900\nLOG_LEVEL: DEBUG\nLLM_MODEL: o3\nOPENAI_API_KEY: sk-secret\n")
+	res, err := srv.configReload(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	result := res.(map[string]any)
+	if srv.stallThreshold != 900*time.Second || logger.Level().String() != "DEBUG" {
+		t.Fatalf("runtime not updated: stall=%s level=%s", srv.stallThreshold, logger.Level())
+	}
+	if srv.cfg.LLMModel != "gpt-4o" {
+		t.Fatalf("LLM_MODEL must require restart, got %q", srv.cfg.LLMModel)
+	}
+	restart := result["restartRequired"].([]string)
+	if len(restart) != 2 || restart[0] != "LLM_MODEL" || restart[1] != "OPENAI_API_KEY" {
+		t.Fatalf("restartRequired = %v", restart)
+	}
+	for _, change := range result["changes"].([]config.Change) {
+		if change.Key == "OPENAI_API_KEY" && change.New != "***" {
+			t.Fatalf("secret leaked in changes: %+v", change)
+		}
+	}
+
+	// 校验失败时整体拒绝。
+	writeYAML("STALL_THRESHOLD_SEC: 1\nLOG_LEVEL: ERROR\n")
+	if _, err := srv.configReload(context.Background(), nil); apperrors.CodeOf(err) != errcode.InvalidInput {
+		t.Fatalf("invalid reload err = %v, want INVALID_INPUT", err)
+	}
+	if srv.cfg.LogLevel != "DEBUG" {
+		t.Fatalf("rejected reload must not apply LOG_LEVEL, got %q", srv.cfg.LogLevel)
+	}
+}
+
+func TestConfigValidateContent(t *testing.T) {
+	srv := &Server{}
+	res, err := srv.configValidateTyped(context.Background(), configValidateParams{
+		Content: "[stall]\nthreshold_sec = 10\n",
+		Format:  "toml",
+	})
+	if err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	result := res.(map[string]any)
+	issues := result["issues"].([]config.Issue)
+	if result["valid"] != false || len(issues) != 1 || issues[0].Key != "STALL_THRESHOLD_SEC" {
+		t.Fatalf("result = %+v", result)
+	}
+}
@@ -0,0 +1,418 @@
+// methods_fleet.go — fleet/apply: 按声明式清单收敛 agent 编队 (类似 kubectl apply)。
+//
+// 收敛规则 (按 agent name 对齐):
+//   - 清单中存在、运行时缺失 (未创建或进程已退出) → create
+//   - cwd / profile 指令变化 → recreate (停止旧 agent 后重新启动)
+//   - skills / schedule / prompt / model 变化 → update (原地更新)
+//   - 运行时存在、清单已删除 → stop (prune=false 时保留)
+//
+// 最近一次成功应用的清单持久化在偏好 fleet.manifest, 供后续对账使用。
+package apiserver
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/service"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+	"github.com/multi-agent/go-agent-v2/pkg/util"
+)
+
+const (
+	prefFleetManifest = "fleet.manifest"
+
+	fleetActionCreate    = "create"
+	fleetActionRecreate  = "recreate"
+	fleetActionUpdate    = "update"
+	fleetActionUnchanged = "unchanged"
+	fleetActionStop      = "stop"
+
+	fleetMetaKey         = "fleet.agent"
+	fleetLaunchTimeout   = 30 * time.Second
+	maxFleetManifestSize = 1 << 20
+)
+
+// fleetMember 编队中已应用的 agent。
+type fleetMember struct {
+	Name       string
+	AgentID    string
+	Spec       service.FleetAgentSpec
+	Profile    service.FleetProfile
+	LaunchHash string
+	SpecHash   string
+	AppliedAt  time.Time
+	cancel     context.CancelFunc // 定时任务取消函数 (无 schedule 时为 nil)
+}
+
+// fleetState 编队运行态 (applyMu 串行化 apply / 自愈, mu 保护其余字段)。
+type fleetState struct {
+	applyMu  sync.Mutex
+	mu       sync.Mutex
+	members  map[string]*fleetMember
+	manifest *service.FleetManifest
+
+	// 对账循环 (fleet_reconciler.go)
+	autoHeal      bool
+	interval      time.Duration
+	status        []fleetAgentStatus
+	lastCheckedAt time.Time
+	lastHealedAt  time.Time
+}
+
+func newFleetState(interval time.Duration, autoHeal bool) *fleetState {
+	return &fleetState{members: make(map[string]*fleetMember), interval: interval, autoHeal: autoHeal}
+}
+
+// stopSchedules 取消全部定时任务 (nil-safe, 服务关闭时调用)。
+func (f *fleetState) stopSchedules() {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, member := range f.members {
+		if member.cancel != nil {
+			member.cancel()
+			member.cancel = nil
+		}
+	}
+}
+
+// fleetAction 单个 agent 的收敛动作。
+type fleetAction struct {
+	Name    string `json:"name"`
+	Action  string `json:"action"`
+	AgentID string `json:"agentId,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// fleetApplyParams fleet/apply 请求参数 (manifest 与 path 二选一)。
+type fleetApplyParams struct {
+	Manifest string `json:"manifest,omitempty"` // YAML 文本
+	Path     string `json:"path,omitempty"`     // 清单文件路径
+	DryRun   bool   `json:"dryRun,omitempty"`
+	Prune    *bool  `json:"prune,omitempty"` // 默认 true: 停止清单中已删除的 agent
+}
+
+// fleetApplyResponse fleet/apply 响应。
+type fleetApplyResponse struct {
+	DryRun  bool          `json:"dryRun"`
+	Actions []fleetAction `json:"actions"`
+	Applied int           `json:"applied"`
+	Failed  int           `json:"failed"`
+}
+
+func (s *Server) fleetApplyTyped(ctx context.Context, p fleetApplyParams) (any, error) {
+	manifest, err := loadFleetManifest(p)
+	if err != nil {
+		return nil, err
+	}
+	prune := p.Prune == nil || *p.Prune
+	resp := s.applyFleetManifest(ctx, manifest, prune, p.DryRun)
+	if !p.DryRun {
+		s.Notify("fleet/applied", resp)
+	}
+	return resp, nil
+}
+
+// applyFleetManifest 计划并执行收敛动作; 非 dry-run 时记录并持久化清单。
+func (s *Server) applyFleetManifest(ctx context.Context, manifest *service.FleetManifest, prune, dryRun bool) fleetApplyResponse {
+	s.fleet.applyMu.Lock()
+	defer s.fleet.applyMu.Unlock()
+
+	actions := planFleetApply(manifest, s.fleetMembersSnapshot(), s.fleetAgentAlive, prune)
+	resp := fleetApplyResponse{DryRun: dryRun, Actions: actions}
+	if dryRun {
+		return resp
+	}
+
+	specs := make(map[string]service.FleetAgentSpec, len(manifest.Agents))
+	for _, spec := range manifest.Agents {
+		specs[spec.Name] = spec
+	}
+	for i := range resp.Actions {
+		action := &resp.Actions[i]
+		if action.Action == fleetActionUnchanged {
+			continue
+		}
+		spec := specs[action.Name]
+		if err := s.executeFleetAction(ctx, action, spec, manifest.ProfileFor(spec)); err != nil {
+			action.Error = err.Error()
+			resp.Failed++
+			logger.Warn("fleet/apply: action failed", logger.FieldName, action.Name, logger.FieldAction, action.Action, logger.FieldError, err)
+			continue
+		}
+		resp.Applied++
+	}
+
+	s.fleet.mu.Lock()
+	s.fleet.manifest = manifest
+	s.fleet.mu.Unlock()
+	if s.prefManager != nil {
+		if err := s.prefManager.Set(ctx, prefFleetManifest, manifest); err != nil {
+			logger.Warn("fleet/apply: persist manifest failed", logger.FieldError, err)
+		}
+	}
+	logger.Info("fleet/apply: applied", logger.FieldCount, len(resp.Actions), "applied", resp.Applied, "failed", resp.Failed)
+	return resp
+}
+
+func loadFleetManifest(p fleetApplyParams) (*service.FleetManifest, error) {
+	content := p.Manifest
+	baseDir := ""
+	if path := strings.TrimSpace(p.Path); path != "" {
+		if strings.TrimSpace(content) != "" {
+			return nil, apperrors.New("Server.fleetApply", "manifest and path are mutually exclusive")
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, apperrors.Wrap(err, "Server.fleetApply", "resolve manifest path")
+		}
+		info, err := os.Stat(abs)
+		if err != nil {
+			return nil, apperrors.Wrap(err, "Server.fleetApply", "stat manifest")
+		}
+		if info.Size() > maxFleetManifestSize {
+			return nil, apperrors.Newf("Server.fleetApply", "manifest too large: %d bytes", info.Size())
+		}
+		data, err := os.ReadFile(abs)
+		if err != nil {
+			return nil, apperrors.Wrap(err, "Server.fleetApply", "read manifest")
+		}
+		content = string(data)
+		baseDir = filepath.Dir(abs)
+	}
+	if strings.TrimSpace(content) == "" {
+		return nil, apperrors.New("Server.fleetApply", "manifest or path is required")
+	}
+	if len(content) > maxFleetManifestSize {
+		return nil, apperrors.Newf("Server.fleetApply", "manifest too large: %d bytes", len(content))
+	}
+	return service.ParseFleetManifest([]byte(content), baseDir)
+}
+
+// planFleetApply 比对期望状态与已应用成员, 生成收敛动作 (纯函数, 按 name 排序)。
+func planFleetApply(manifest *service.FleetManifest, members map[string]fleetMember, alive func(agentID string) bool, prune bool) []fleetAction {
+	actions := make([]fleetAction, 0, len(manifest.Agents)+len(members))
+	desired := make(map[string]struct{}, len(manifest.Agents))
+	for _, spec := range manifest.Agents {
+		desired[spec.Name] = struct{}{}
+		profile := manifest.ProfileFor(spec)
+		member, exists := members[spec.Name]
+		action := fleetAction{Name: spec.Name, AgentID: member.AgentID}
+		switch {
+		case !exists:
+			action.Action, action.Reason = fleetActionCreate, "missing"
+		case !alive(member.AgentID):
+			action.Action, action.Reason = fleetActionCreate, "agent not running"
+		case member.LaunchHash != service.FleetLaunchHash(spec, profile):
+			action.Action, action.Reason = fleetActionRecreate, "cwd or instructions changed"
+		case member.SpecHash != service.FleetSpecHash(spec, profile):
+			action.Action, action.Reason = fleetActionUpdate, "skills, schedule or model changed"
+		default:
+			action.Action = fleetActionUnchanged
+		}
+		actions = append(actions, action)
+	}
+	if prune {
+		for name, member := range members {
+			if _, ok := desired[name]; !ok {
+				actions = append(actions, fleetAction{Name: name, Action: fleetActionStop, AgentID: member.AgentID, Reason: "removed from manifest"})
+			}
+		}
+	}
+	sort.SliceStable(actions, func(i, j int) bool { return actions[i].Name < actions[j].Name })
+	return actions
+}
+
+func (s *Server) executeFleetAction(ctx context.Context, action *fleetAction, spec service.FleetAgentSpec, profile service.FleetProfile) error {
+	switch action.Action {
+	case fleetActionStop:
+		return s.stopFleetMember(action.Name)
+	case fleetActionRecreate:
+		if err := s.stopFleetMember(action.Name); err != nil {
+			return err
+		}
+		fallthrough
+	case fleetActionCreate:
+		s.removeFleetMember(action.Name)
+		agentID, err := s.launchFleetAgent(ctx, spec, profile)
+		if err != nil {
+			return err
+		}
+		action.AgentID = agentID
+		return nil
+	case fleetActionUpdate:
+		s.fleet.mu.Lock()
+		member := s.fleet.members[action.Name]
+		s.fleet.mu.Unlock()
+		if member == nil {
+			return apperrors.Newf("Server.fleetApply", "fleet member %s not found", action.Name)
+		}
+		s.commitFleetMember(member.AgentID, spec, profile)
+		return nil
+	default:
+		return nil
+	}
+}
+
+// launchFleetAgent 启动清单中的 agent 并登记为编队成员。
+func (s *Server) launchFleetAgent(ctx context.Context, spec service.FleetAgentSpec, profile service.FleetProfile) (string, error) {
+	if s.mgr == nil {
+		return "", apperrors.New("Server.fleetApply", "agent manager unavailable")
+	}
+	if len(s.mgr.List()) >= maxAgents {
+		return "", apperrors.Newf("Server.fleetApply", "max agents (%d) reached", maxAgents)
+	}
+	if info, err := os.Stat(spec.Cwd); err != nil || !info.IsDir() {
+		return "", apperrors.Newf("Server.fleetApply", "cwd %s is not a directory", spec.Cwd)
+	}
+	id := fmt.Sprintf("agent-%d-%d", time.Now().UnixMilli(), s.threadSeq.Add(1))
+	launchCtx, cancel := context.WithTimeout(ctx, fleetLaunchTimeout)
+	defer cancel()
+	if err := s.mgr.Launch(launchCtx, id, spec.Name, "", spec.Cwd, profile.Instructions, s.buildDynamicToolsForThread(id, spec.Cwd)); err != nil {
+		return "", apperrors.Wrapf(err, "Server.fleetApply", "launch agent %s", spec.Name)
+	}
+	s.setAgentWorkDir(id, spec.Cwd)
+	if proc := s.mgr.Get(id); proc != nil {
+		s.registerBinding(ctx, id, proc)
+	}
+	if s.uiRuntime != nil {
+		s.uiRuntime.ReplaceThreads(buildThreadSnapshots(s.mgr.List()))
+	}
+	name := spec.Name
+	if _, err := s.threadMetaSetTyped(ctx, threadMetaSetParams{ThreadID: id, Meta: map[string]*string{fleetMetaKey: &name}}); err != nil {
+		logger.Warn("fleet/apply: tag thread metadata failed", logger.FieldAgentID, id, logger.FieldError, err)
+	}
+	s.commitFleetMember(id, spec, profile)
+	logger.Info("fleet/apply: agent launched", logger.FieldAgentID, id, logger.FieldName, spec.Name, logger.FieldCwd, spec.Cwd)
+	return id, nil
+}
+
+// commitFleetMember 登记 (或原地更新) 成员配置并重启定时任务。
+func (s *Server) commitFleetMember(agentID string, spec service.FleetAgentSpec, profile service.FleetProfile) {
+	member := &fleetMember{
+		Name:       spec.Name,
+		AgentID:    agentID,
+		Spec:       spec,
+		Profile:    profile,
+		LaunchHash: service.FleetLaunchHash(spec, profile),
+		SpecHash:   service.FleetSpecHash(spec, profile),
+		AppliedAt:  time.Now(),
+	}
+	s.skillsMu.Lock()
+	if len(spec.Skills) > 0 {
+		s.agentSkills[agentID] = append([]string(nil), spec.Skills...)
+	} else {
+		delete(s.agentSkills, agentID)
+	}
+	s.skillsMu.Unlock()
+
+	s.fleet.mu.Lock()
+	if prev := s.fleet.members[spec.Name]; prev != nil && prev.cancel != nil {
+		prev.cancel()
+	}
+	s.fleet.members[spec.Name] = member
+	s.fleet.mu.Unlock()
+	s.startFleetSchedule(member)
+}
+
+func (s *Server) stopFleetMember(name string) error {
+	s.fleet.mu.Lock()
+	member := s.fleet.members[name]
+	s.fleet.mu.Unlock()
+	if member == nil {
+		return nil
+	}
+	if s.mgr != nil && s.mgr.Get(member.AgentID) != nil {
+		s.cancelCodeRuns(member.AgentID)
+		if err := s.mgr.Stop(member.AgentID); err != nil {
+			return apperrors.Wrapf(err, "Server.fleetApply", "stop agent %s", member.AgentID)
+		}
+	}
+	s.clearAgentWorkDir(member.AgentID)
+	s.turnUndo.drop(member.AgentID)
+	s.removeFleetMember(name)
+	logger.Info("fleet/apply: agent stopped", logger.FieldAgentID, member.AgentID, logger.FieldName, name)
+	return nil
+}
+
+func (s *Server) removeFleetMember(name string) {
+	s.fleet.mu.Lock()
+	member := s.fleet.members[name]
+	delete(s.fleet.members, name)
+	s.fleet.mu.Unlock()
+	if member == nil {
+		return
+	}
+	if member.cancel != nil {
+		member.cancel()
+	}
+	s.skillsMu.Lock()
+	delete(s.agentSkills, member.AgentID)
+	s.skillsMu.Unlock()
+}
+
+func (s *Server) fleetMembersSnapshot() map[string]fleetMember {
+	s.fleet.mu.Lock()
+	defer s.fleet.mu.Unlock()
+	out := make(map[string]fleetMember, len(s.fleet.members))
+	for name, member := range s.fleet.members {
+		out[name] = *member
+	}
+	return out
+}
+
+func (s *Server) fleetAgentAlive(agentID string) bool {
+	return s.mgr != nil && agentID != "" && s.mgr.Get(agentID) != nil
+}
+
+// startFleetSchedule 为带 schedule 的成员按间隔提交后台 turn (上一轮未结束时跳过)。
+func (s *Server) startFleetSchedule(member *fleetMember) {
+	if member.Spec.Schedule == "" {
+		return
+	}
+	every, err := service.ParseFleetSchedule(member.Spec.Schedule)
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.fleet.mu.Lock()
+	member.cancel = cancel
+	s.fleet.mu.Unlock()
+
+	agentID, name := member.AgentID, member.Name
+	params := turnStartParams{
+		ThreadID:       agentID,
+		Input:          []UserInput{{Type: "text", Text: member.Spec.Prompt}},
+		SelectedSkills: member.Spec.Skills,
+		Model:          member.Profile.Model,
+		Priority:       turnPriorityBackground,
+	}
+	util.SafeGo(func() {
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if s.hasActiveTrackedTurn(agentID) {
+				logger.Info("fleet: scheduled turn skipped, previous turn still running", logger.FieldAgentID, agentID, logger.FieldName, name)
+				continue
+			}
+			if _, err := s.turnStartTyped(ctx, params); err != nil {
+				logger.Warn("fleet: scheduled turn failed", logger.FieldAgentID, agentID, logger.FieldName, name, logger.FieldError, err)
+			}
+		}
+	})
+}
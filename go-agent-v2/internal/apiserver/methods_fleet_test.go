@@ -0,0 +1,88 @@
+package apiserver
+
+import (
+	"testing"
+
+	"github.com/multi-agent/go-agent-v2/internal/service"
+)
+
+func TestPlanFleetApply(t *testing.T) {
+	manifest, err := service.ParseFleetManifest([]byte(`
+profiles:
+  dev: {instructions: build things}
+agents:
+  - {name: alpha, cwd: /repo/a, profile: dev}
+  - {name: beta, cwd: /repo/b, skills: [lint]}
+  - {name: gamma, cwd: /repo/c}
+  - {name: delta, cwd: /repo/d}
+  - {name: epsilon, cwd: /repo/e}
+`), "")
+	if err != nil {
+		t.Fatalf("ParseFleetManifest: %v", err)
+	}
+	member := func(name, agentID string, mutate func(*service.FleetAgentSpec, *service.FleetProfile)) fleetMember {
+		for _, spec := range manifest.Agents {
+			if spec.Name != name {
+				continue
+			}
+			profile := manifest.ProfileFor(spec)
+			if mutate != nil {
+				mutate(&spec, &profile)
+			}
+			return fleetMember{Name: name, AgentID: agentID, LaunchHash: service.FleetLaunchHash(spec, profile), SpecHash: service.FleetSpecHash(spec, profile)}
+		}
+		return fleetMember{Name: name, AgentID: agentID}
+	}
+	members := map[string]fleetMember{
+		"alpha":   member("alpha", "agent-a", func(_ *service.FleetAgentSpec, p *service.FleetProfile) { p.Instructions = "old" }),
+		"beta":    member("beta", "agent-b", func(s *service.FleetAgentSpec, _ *service.FleetProfile) { s.Skills = nil }),
+		"gamma":   member("gamma", "agent-c", nil),
+		"delta":   member("delta", "agent-dead", nil),
+		"removed": member("removed", "agent-r", nil),
+	}
+	alive := func(agentID string) bool { return agentID != "agent-dead" }
+
+	want := map[string]string{
+		"alpha":   fleetActionRecreate,
+		"beta":    fleetActionUpdate,
+		"gamma":   fleetActionUnchanged,
+		"delta":   fleetActionCreate,
+		"epsilon": fleetActionCreate,
+		"removed": fleetActionStop,
+	}
+	actions := planFleetApply(manifest, members, alive, true)
+	if len(actions) != len(want) {
+		t.Fatalf("actions = %+v", actions)
+	}
+	for i, action := range actions {
+		if i > 0 && actions[i-1].Name > action.Name {
+			t.Fatalf("actions not sorted: %+v", actions)
+		}
+		if want[action.Name] != action.Action {
+			t.Errorf("%s: action = %s, want %s", action.Name, action.Action, want[action.Name])
+		}
+	}
+
+	for _, action := range planFleetApply(manifest, members, alive, false) {
+		if action.Action == fleetActionStop {
+			t.Fatalf("prune=false should keep removed agents, got %+v", action)
+		}
+	}
+}
+
+func TestFleetApplyRejectsBadParams(t *testing.T) {
+	srv := &Server{fleet: newFleetState(0, false)}
+	if _, err := srv.fleetApplyTyped(t.Context(), fleetApplyParams{}); err == nil {
+		t.Fatal("expected error without manifest or path")
+	}
+	if _, err := srv.fleetApplyTyped(t.Context(), fleetApplyParams{Manifest: "agents: []", Path: "x.yaml"}); err == nil {
+		t.Fatal("expected error for both manifest and path")
+	}
+	resp, err := srv.fleetApplyTyped(t.Context(), fleetApplyParams{Manifest: "agents: [{name: a, cwd: /tmp}]", DryRun: true})
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if got := resp.(fleetApplyResponse); !got.DryRun || len(got.Actions) != 1 || got.Actions[0].Action != fleetActionCreate {
+		t.Fatalf("dry run response = %+v", got)
+	}
+}
@@ -0,0 +1,276 @@
+// methods_group.go — agent-terminal 多窗口分组 (group/join, group/leave, group/list, group/transfer)。
+//
+// 同一分组的窗口共享同一个 apiserver 实例 (首个窗口内嵌, 其余窗口经 /rpc + /events 连接)。
+// 每个线程归属一个窗口; 分组内的窗口互相可见对方的 agent, 并可通过 group/transfer
+// 将线程移交给另一窗口。归属变化推送 group/changed 与 group/threadTransferred。
+package apiserver
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/runner"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+// groupWindow 已加入分组的窗口。
+type groupWindow struct {
+	ID       string    `json:"id"`
+	Group    string    `json:"group"`
+	Title    string    `json:"title,omitempty"`
+	PID      int       `json:"pid,omitempty"`
+	JoinedAt time.Time `json:"joinedAt"`
+}
+
+// windowGroupRegistry 窗口与线程归属 (threadID → windowID)。
+type windowGroupRegistry struct {
+	mu      sync.Mutex
+	windows map[string]*groupWindow
+	owners  map[string]string
+	seq     uint64
+}
+
+func (r *windowGroupRegistry) join(group, title string, pid int, now time.Time) groupWindow {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.windows == nil {
+		r.windows = make(map[string]*groupWindow)
+		r.owners = make(map[string]string)
+	}
+	r.seq++
+	window := &groupWindow{
+		ID:       fmt.Sprintf("window-%d", r.seq),
+		Group:    group,
+		Title:    title,
+		PID:      pid,
+		JoinedAt: now,
+	}
+	r.windows[window.ID] = window
+	return *window
+}
+
+// leave 移除窗口, 其名下线程变为未归属; 返回窗口所属分组。
+func (r *windowGroupRegistry) leave(windowID string) (string, []string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	window, ok := r.windows[windowID]
+	if !ok {
+		return "", nil, false
+	}
+	delete(r.windows, windowID)
+	var released []string
+	for threadID, owner := range r.owners {
+		if owner == windowID {
+			delete(r.owners, threadID)
+			released = append(released, threadID)
+		}
+	}
+	sort.Strings(released)
+	return window.Group, released, true
+}
+
+// transfer 将线程移交给目标窗口; from 非空时要求线程当前属于 from (乐观校验)。
+func (r *windowGroupRegistry) transfer(threadID, from, to string) (string, string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	target, ok := r.windows[to]
+	if !ok {
+		return "", "", fmt.Errorf("window %s not found", to)
+	}
+	current := r.owners[threadID]
+	if from != "" && current != from {
+		return "", "", fmt.Errorf("thread %s is owned by %q, not %s", threadID, current, from)
+	}
+	if current != "" && from == "" && current != to {
+		return "", "", fmt.Errorf("thread %s is owned by %s, fromWindowId required", threadID, current)
+	}
+	if owner, ok := r.windows[current]; ok && owner.Group != target.Group {
+		return "", "", fmt.Errorf("window %s is not in group %q", to, owner.Group)
+	}
+	if r.owners == nil {
+		r.owners = make(map[string]string)
+	}
+	r.owners[threadID] = to
+	return target.Group, current, nil
+}
+
+func (r *windowGroupRegistry) forget(threadID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.owners, threadID)
+}
+
+// snapshot 返回分组内窗口 (按加入顺序) 及各窗口名下的线程。
+func (r *windowGroupRegistry) snapshot(group string) ([]groupWindow, map[string][]string, map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	windows := make([]groupWindow, 0, len(r.windows))
+	for _, window := range r.windows {
+		if window.Group == group {
+			windows = append(windows, *window)
+		}
+	}
+	sort.Slice(windows, func(i, j int) bool { return windows[i].JoinedAt.Before(windows[j].JoinedAt) })
+	threads := make(map[string][]string)
+	owners := make(map[string]string, len(r.owners))
+	for threadID, owner := range r.owners {
+		owners[threadID] = owner
+		if window, ok := r.windows[owner]; ok && window.Group == group {
+			threads[owner] = append(threads[owner], threadID)
+		}
+	}
+	for _, ids := range threads {
+		sort.Strings(ids)
+	}
+	return windows, threads, owners
+}
+
+// ========================================
+// JSON-RPC
+// ========================================
+
+type groupJoinParams struct {
+	Group string `json:"group"`
+	Title string `json:"title,omitempty"`
+	PID   int    `json:"pid,omitempty"`
+}
+
+type groupLeaveParams struct {
+	WindowID string `json:"windowId"`
+}
+
+type groupListParams struct {
+	Group string `json:"group"`
+}
+
+type groupTransferParams struct {
+	ThreadID     string `json:"threadId"`
+	ToWindowID   string `json:"toWindowId"`
+	FromWindowID string `json:"fromWindowId,omitempty"` // 空 = 认领未归属线程
+}
+
+// groupWindowView group/list 中的窗口及其 agent。
+type groupWindowView struct {
+	groupWindow
+	Agents []runner.AgentInfo `json:"agents"`
+}
+
+// groupListResponse group/list 响应。
+type groupListResponse struct {
+	Group      string             `json:"group"`
+	Windows    []groupWindowView  `json:"windows"`
+	Unassigned []runner.AgentInfo `json:"unassigned"` // 未归属任何窗口的 agent
+}
+
+func (s *Server) groupJoinTyped(_ context.Context, p groupJoinParams) (any, error) {
+	group := strings.TrimSpace(p.Group)
+	if group == "" {
+		return nil, apperrors.New("Server.groupJoin", "group is required")
+	}
+	window := s.windowGroups.join(group, strings.TrimSpace(p.Title), p.PID, time.Now())
+	logger.Info("group/join: window joined", logger.FieldName, group, logger.FieldID, window.ID, "pid", p.PID)
+	list := s.groupSnapshot(group)
+	s.Notify("group/changed", list)
+	return map[string]any{"window": window, "group": list}, nil
+}
+
+func (s *Server) groupLeaveTyped(_ context.Context, p groupLeaveParams) (any, error) {
+	group, released, ok := s.windowGroups.leave(strings.TrimSpace(p.WindowID))
+	if !ok {
+		return map[string]any{"left": false}, nil
+	}
+	logger.Info("group/leave: window left", logger.FieldName, group, logger.FieldID, p.WindowID, "released_threads", len(released))
+	s.Notify("group/changed", s.groupSnapshot(group))
+	return map[string]any{"left": true, "released": released}, nil
+}
+
+func (s *Server) groupListTyped(_ context.Context, p groupListParams) (any, error) {
+	group := strings.TrimSpace(p.Group)
+	if group == "" {
+		return nil, apperrors.New("Server.groupList", "group is required")
+	}
+	return s.groupSnapshot(group), nil
+}
+
+func (s *Server) groupTransferTyped(_ context.Context, p groupTransferParams) (any, error) {
+	threadID := strings.TrimSpace(p.ThreadID)
+	to := strings.TrimSpace(p.ToWindowID)
+	if threadID == "" || to == "" {
+		return nil, apperrors.New("Server.groupTransfer", "threadId and toWindowId are required")
+	}
+	if s.mgr != nil && s.mgr.Get(threadID) == nil {
+		s.windowGroups.forget(threadID)
+		return nil, apperrors.NewCodef("Server.groupTransfer", errcode.ThreadNotFound, "thread %s not found", threadID)
+	}
+	group, from, err := s.windowGroups.transfer(threadID, strings.TrimSpace(p.FromWindowID), to)
+	if err != nil {
+		return nil, apperrors.Wrap(err, "Server.groupTransfer", "transfer thread")
+	}
+	logger.Info("group/transfer: thread transferred",
+		logger.FieldThreadID, threadID,
+		logger.FieldName, group,
+		"from_window", from,
+		"to_window", to,
+	)
+	event := map[string]any{
+		"group":        group,
+		"threadId":     threadID,
+		"fromWindowId": from,
+		"toWindowId":   to,
+	}
+	s.Notify("group/threadTransferred", event)
+	s.Notify("group/changed", s.groupSnapshot(group))
+	return event, nil
+}
+
+// groupSnapshot 汇总分组内窗口与 agent (已退出的 agent 从归属表清除)。
+func (s *Server) groupSnapshot(group string) groupListResponse {
+	windows, threads, owners := s.windowGroups.snapshot(group)
+	resp := groupListResponse{Group: group, Windows: make([]groupWindowView, 0, len(windows)), Unassigned: []runner.AgentInfo{}}
+	agents := map[string]runner.AgentInfo{}
+	if s.mgr != nil {
+		for _, info := range s.mgr.List() {
+			agents[info.ID] = info
+			if _, owned := owners[info.ID]; !owned {
+				resp.Unassigned = append(resp.Unassigned, info)
+			}
+		}
+	}
+	sort.Slice(resp.Unassigned, func(i, j int) bool { return resp.Unassigned[i].ID < resp.Unassigned[j].ID })
+	for _, window := range windows {
+		view := groupWindowView{groupWindow: window, Agents: []runner.AgentInfo{}}
+		for _, threadID := range threads[window.ID] {
+			info, ok := agents[threadID]
+			if s.mgr != nil && !ok {
+				s.windowGroups.forget(threadID)
+				continue
+			}
+			if !ok {
+				info = runner.AgentInfo{ID: threadID}
+			}
+			view.Agents = append(view.Agents, info)
+		}
+		resp.Windows = append(resp.Windows, view)
+	}
+	return resp
+}
+
+// approvalRespondParams approval/respond 请求参数 (分组内非宿主窗口经 /rpc 回复审批)。
+type approvalRespondParams struct {
+	RequestID int64 `json:"requestId"`
+	Approved  bool  `json:"approved"`
+}
+
+func (s *Server) approvalRespondTyped(_ context.Context, p approvalRespondParams) (any, error) {
+	if p.RequestID == 0 {
+		return nil, apperrors.New("Server.approvalRespond", "requestId is required")
+	}
+	ok := s.ResolvePendingRequest(p.RequestID, map[string]any{"approved": p.Approved})
+	return map[string]any{"ok": ok}, nil
+}
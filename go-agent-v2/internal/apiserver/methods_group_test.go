@@ -0,0 +1,65 @@
+package apiserver
+
+import (
+	"context"
+	"testing"
+)
+
+func TestGroupJoinTransferAndLeave(t *testing.T) {
+	srv := &Server{}
+	ctx := context.Background()
+
+	join := func(group string) string {
+		t.Helper()
+		result, err := srv.groupJoinTyped(ctx, groupJoinParams{Group: group, Title: group})
+		if err != nil {
+			t.Fatalf("join %s: %v", group, err)
+		}
+		return result.(map[string]any)["window"].(groupWindow).ID
+	}
+	w1, w2, other := join("alpha"), join("alpha"), join("beta")
+	if _, err := srv.groupJoinTyped(ctx, groupJoinParams{}); err == nil {
+		t.Fatal("expected missing group error")
+	}
+
+	// 未归属线程可直接认领; 已归属线程移交需给出当前归属窗口。
+	if _, err := srv.groupTransferTyped(ctx, groupTransferParams{ThreadID: "t1", ToWindowID: w1}); err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	if _, err := srv.groupTransferTyped(ctx, groupTransferParams{ThreadID: "t1", ToWindowID: w2}); err == nil {
+		t.Fatal("transfer of owned thread without fromWindowId should fail")
+	}
+	if _, err := srv.groupTransferTyped(ctx, groupTransferParams{ThreadID: "t1", FromWindowID: w1, ToWindowID: other}); err == nil {
+		t.Fatal("transfer across groups should fail")
+	}
+	result, err := srv.groupTransferTyped(ctx, groupTransferParams{ThreadID: "t1", FromWindowID: w1, ToWindowID: w2})
+	if err != nil {
+		t.Fatalf("transfer: %v", err)
+	}
+	if event := result.(map[string]any); event["fromWindowId"] != w1 || event["toWindowId"] != w2 || event["group"] != "alpha" {
+		t.Fatalf("transfer event = %+v", event)
+	}
+
+	list, err := srv.groupListTyped(ctx, groupListParams{Group: "alpha"})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	snapshot := list.(groupListResponse)
+	if len(snapshot.Windows) != 2 || len(snapshot.Windows[0].Agents) != 0 || len(snapshot.Windows[1].Agents) != 1 || snapshot.Windows[1].Agents[0].ID != "t1" {
+		t.Fatalf("snapshot = %+v", snapshot)
+	}
+
+	left, err := srv.groupLeaveTyped(ctx, groupLeaveParams{WindowID: w2})
+	if err != nil || left.(map[string]any)["left"] != true {
+		t.Fatalf("leave = (%v, %v)", left, err)
+	}
+	if released := left.(map[string]any)["released"].([]string); len(released) != 1 || released[0] != "t1" {
+		t.Fatalf("released = %v", released)
+	}
+	if _, err := srv.groupTransferTyped(ctx, groupTransferParams{ThreadID: "t1", ToWindowID: w1}); err != nil {
+		t.Fatalf("released thread should be claimable: %v", err)
+	}
+	if _, err := srv.groupTransferTyped(ctx, groupTransferParams{ThreadID: "t1", FromWindowID: w1, ToWindowID: w2}); err == nil {
+		t.Fatal("transfer to departed window should fail")
+	}
+}
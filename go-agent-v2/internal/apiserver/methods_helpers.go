@@ -14,6 +14,7 @@ import (
 	"github.com/multi-agent/go-agent-v2/internal/codex"
 	"github.com/multi-agent/go-agent-v2/internal/runner"
 	"github.com/multi-agent/go-agent-v2/internal/uistate"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
 	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
 	"github.com/multi-agent/go-agent-v2/pkg/logger"
 )
@@ -26,7 +27,7 @@ import (
 func (s *Server) withThread(threadID string, fn func(*runner.AgentProcess) (any, error)) (any, error) {
 	proc := s.mgr.Get(threadID)
 	if proc == nil {
-		return nil, apperrors.Newf("Server.withThread", "thread %s not found", threadID)
+		return nil, apperrors.NewCodef("Server.withThread", errcode.ThreadNotFound, "thread %s not found", threadID)
 	}
 	return fn(proc)
 }
@@ -329,13 +330,16 @@ func (s *Server) ensureThreadReadyForTurn(ctx context.Context, threadID, cwd str
 			logger.FieldPort, proc.Client.GetPort(),
 			"codex_thread_id", strings.TrimSpace(proc.Client.GetThreadID()),
 		)
-		s.setAgentWorkDir(id, launchCwd)
+		// 未显式指定 cwd 时沿用线程当前工作目录 (可能已由 thread/cwd/set 切换)
+		if strings.TrimSpace(cwd) != "" || s.getAgentWorkDir(id) == "" {
+			s.setAgentWorkDir(id, launchCwd)
+		}
 		s.registerBinding(ctx, id, proc)
 		return proc, nil
 	}
 	hasHistory := s.threadExistsInHistory(ctx, id)
 	if !hasHistory {
-		return nil, apperrors.Newf("Server.ensureThreadReady", "thread %s not found", id)
+		return nil, apperrors.NewCodef("Server.ensureThreadReady", errcode.ThreadNotFound, "thread %s not found", id)
 	}
 	resumeCandidates := make([]string, 0, 4)
 
@@ -367,7 +371,7 @@ func (s *Server) ensureThreadReadyForTurn(ctx context.Context, threadID, cwd str
 		"candidates", previewResumeCandidates(resumeCandidates, 4),
 	)
 
-	dynamicTools := s.buildAllDynamicTools()
+	dynamicTools := s.buildDynamicToolsForThread(id, launchCwd)
 
 	if err := s.mgr.Launch(ctx, id, id, "", launchCwd, "", dynamicTools); err != nil {
 		// 并发补加载时可能已被其他请求拉起，二次确认后再报错。
@@ -397,9 +401,11 @@ func (s *Server) ensureThreadReadyForTurn(ctx context.Context, threadID, cwd str
 	}
 	var lastResumeErr error
 	for _, resumeThreadID := range resumeCandidates {
-		err := proc.Client.ResumeThread(codex.ResumeThreadRequest{
-			ThreadID: resumeThreadID,
-			Cwd:      launchCwd,
+		err := traceCodex(ctx, id, "resume", func() error {
+			return proc.Client.ResumeThread(codex.ResumeThreadRequest{
+				ThreadID: resumeThreadID,
+				Cwd:      launchCwd,
+			})
 		})
 		if err == nil {
 			logger.Info("turn/start: historical thread auto-loaded",
@@ -424,7 +430,7 @@ func (s *Server) ensureThreadReadyForTurn(ctx context.Context, threadID, cwd str
 			_ = s.cancelCodeRuns(id)
 			_ = s.mgr.Stop(id)
 			s.broadcastNotification(buildSessionLostNotification(id, err))
-			return nil, apperrors.Wrapf(err, "Server.ensureThreadReady",
+			return nil, apperrors.WrapCodef(err, "Server.ensureThreadReady", errcode.CodexCrashed,
 				"codex crashed while resuming thread %s (rollout=%s)", id, resumeThreadID)
 		}
 
@@ -444,7 +450,7 @@ func (s *Server) ensureThreadReadyForTurn(ctx context.Context, threadID, cwd str
 			"resume_thread_id", resumeThreadID,
 			logger.FieldError, err,
 		)
-		return nil, apperrors.Wrapf(err, "Server.ensureThreadReady",
+		return nil, apperrors.WrapCodef(err, "Server.ensureThreadReady", errcode.ResumeExhausted,
 			"resume failed for thread %s (rollout=%s)", id, resumeThreadID)
 	}
 
@@ -461,11 +467,11 @@ func (s *Server) ensureThreadReadyForTurn(ctx context.Context, threadID, cwd str
 			_ = s.cancelCodeRuns(id)
 			_ = s.mgr.Stop(id)
 			if launchErr := s.mgr.Launch(ctx, id, id, "", launchCwd, "", dynamicTools); launchErr != nil {
-				return nil, apperrors.Wrapf(launchErr, "Server.ensureThreadReady", "final re-spawn thread %s", id)
+				return nil, apperrors.WrapCodef(launchErr, "Server.ensureThreadReady", errcode.ResumeExhausted, "final re-spawn thread %s", id)
 			}
 			proc = s.mgr.Get(id)
 			if proc == nil {
-				return nil, apperrors.Newf("Server.ensureThreadReady", "thread %s final re-spawn failed", id)
+				return nil, apperrors.NewCodef("Server.ensureThreadReady", errcode.ResumeExhausted, "thread %s final re-spawn failed", id)
 			}
 		}
 		proc.MarkSessionLost()
@@ -497,7 +503,7 @@ func (s *Server) registerBinding(ctx context.Context, agentID string, proc *runn
 	if codexThreadID == "" {
 		return
 	}
-	if err := s.bindingStore.Bind(ctx, agentID, codexThreadID, ""); err != nil {
+	if err := s.persistDurable(ctx, walOp{Kind: walKindBinding, Binding: &walBinding{AgentID: agentID, CodexThreadID: codexThreadID}}); err != nil {
 		logger.Warn("turn/start: failed to register binding",
 			logger.FieldAgentID, agentID,
 			"codex_thread_id", codexThreadID,
@@ -527,14 +533,14 @@ func resolveSlashCommandThread(
 		}
 	}
 	if ensureReady == nil {
-		return nil, apperrors.Newf("Server.sendSlashCommand", "thread %s not found", id)
+		return nil, apperrors.NewCodef("Server.sendSlashCommand", errcode.ThreadNotFound, "thread %s not found", id)
 	}
 	proc, err := ensureReady(ctx, id, "")
 	if err != nil {
 		return nil, err
 	}
 	if proc == nil {
-		return nil, apperrors.Newf("Server.sendSlashCommand", "thread %s not found", id)
+		return nil, apperrors.NewCodef("Server.sendSlashCommand", errcode.ThreadNotFound, "thread %s not found", id)
 	}
 	return proc, nil
 }
@@ -584,7 +590,9 @@ func (s *Server) sendSlashCommand(ctx context.Context, params json.RawMessage, c
 		)
 		return nil, err
 	}
-	if err := proc.Client.SendCommand(command, ""); err != nil {
+	if err := traceCodex(ctx, p.ThreadID, command, func() error {
+		return proc.Client.SendCommand(command, "")
+	}); err != nil {
 		logger.Warn("slash/command: send failed",
 			logger.FieldAgentID, p.ThreadID,
 			logger.FieldThreadID, p.ThreadID,
@@ -861,11 +869,6 @@ func (s *Server) threadModelSet(_ context.Context, params json.RawMessage) (any,
 	return s.sendSlashCommandWithArgs(params, "/model", "model")
 }
 
-// threadPersonality 设置人格 (/personality <type>)。
-func (s *Server) threadPersonality(_ context.Context, params json.RawMessage) (any, error) {
-	return s.sendSlashCommandWithArgs(params, "/personality", "personality")
-}
-
 // threadApprovals 设置审批策略 (/approvals <policy>)。
 func (s *Server) threadApprovals(_ context.Context, params json.RawMessage) (any, error) {
 	return s.sendSlashCommandWithArgs(params, "/approvals", "policy")
@@ -932,6 +935,7 @@ func (s *Server) debugRuntime(_ context.Context, _ json.RawMessage) (any, error)
 	if s.uiRuntime != nil {
 		result["timeline"] = s.uiRuntime.TimelineStats()
 	}
+	result["rpc"] = s.rpcStats.snapshot(rpcStatsTopMethods)
 
 	return result, nil
 }
@@ -961,3 +965,13 @@ func (s *Server) debugForceGC(_ context.Context, _ json.RawMessage) (any, error)
 		"gcCycles":     after.NumGC,
 	}, nil
 }
+
+// debugEventsUnknownParams debug/events/unknown 请求参数。
+type debugEventsUnknownParams struct {
+	Reset bool `json:"reset,omitempty"` // 读取后清零计数
+}
+
+// debugEventsUnknown 返回当前事件 schema 未声明的 codex 事件计数, 用于发现协议漂移 (见 uistate/event_schema.go)。
+func (s *Server) debugEventsUnknown(_ context.Context, p debugEventsUnknownParams) (any, error) {
+	return uistate.UnknownEvents(p.Reset), nil
+}
@@ -0,0 +1,212 @@
+// methods_log_query.go — 日志查询语言 (log/query) 与已保存查询 (log/queries/*)。
+//
+// 语法见 store/log_query.go, 例: level>=warn AND (source=codex OR tool=shell) ts>=-1h message~"timeout"。
+// 已保存查询存于偏好 logs.savedQueries; log/query 传 name 时与 query 以 AND 组合。
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/multi-agent/go-agent-v2/internal/store"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+const (
+	prefLogSavedQueries = "logs.savedQueries"
+
+	maxLogSavedQueries     = 64
+	maxLogQueryNameLen     = 64
+	maxLogQueryDescLen     = 500
+	defaultLogQueryLimit   = 100
+	maxLogQueryResultLimit = 2000
+)
+
+// logSavedQuery 已保存的日志查询。
+type logSavedQuery struct {
+	Query       string    `json:"query"`
+	Description string    `json:"description,omitempty"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+type logQueryParams struct {
+	Query   string `json:"query"`
+	Name    string `json:"name,omitempty"`    // 已保存查询名, 与 query 以 AND 组合
+	Limit   int    `json:"limit,omitempty"`   // 0 = 100, 超过 2000 截断为 2000
+	Explain bool   `json:"explain,omitempty"` // 只编译, 返回 WHERE 子句与参数, 不执行
+}
+
+// logQueryTyped 按查询语言检索系统日志 (JSON-RPC: log/query)。
+func (s *Server) logQueryTyped(ctx context.Context, p logQueryParams) (any, error) {
+	const op = "Server.logQuery"
+	query := strings.TrimSpace(p.Query)
+	if name := strings.TrimSpace(p.Name); name != "" {
+		saved, ok := s.loadLogSavedQueries(ctx)[name]
+		if !ok {
+			return nil, apperrors.NewCodef(op, errcode.NotFound, "saved query %q not found", name)
+		}
+		query = combineLogQueries(saved.Query, query)
+	}
+	compiled, err := store.CompileLogQuery(query, time.Now())
+	if err != nil {
+		return nil, apperrors.WrapCode(err, op, errcode.InvalidInput, "parse query")
+	}
+	limit := p.Limit
+	if limit <= 0 {
+		limit = defaultLogQueryLimit
+	}
+	limit = min(limit, maxLogQueryResultLimit)
+	result := map[string]any{"query": query, "where": compiled.Where, "args": compiled.Args, "limit": limit}
+	if p.Explain {
+		return result, nil
+	}
+	if s.sysLogStore == nil {
+		return nil, apperrors.New(op, "log store not initialized")
+	}
+	logs, err := s.sysLogStore.Query(ctx, compiled, limit)
+	if err != nil {
+		return nil, apperrors.Wrap(err, op, "query logs")
+	}
+	if logs == nil {
+		logs = []store.SystemLog{}
+	}
+	result["logs"] = logs
+	result["count"] = len(logs)
+	return result, nil
+}
+
+// combineLogQueries 以 AND 组合两段查询 (各自加括号, 避免 OR 优先级串联)。
+func combineLogQueries(a, b string) string {
+	a, b = strings.TrimSpace(a), strings.TrimSpace(b)
+	switch {
+	case a == "":
+		return b
+	case b == "":
+		return a
+	}
+	return "(" + a + ") AND (" + b + ")"
+}
+
+// ========================================
+// 已保存的查询
+// ========================================
+
+func (s *Server) loadLogSavedQueries(ctx context.Context) map[string]logSavedQuery {
+	out := map[string]logSavedQuery{}
+	if s.prefManager == nil {
+		return out
+	}
+	value, err := s.prefManager.Get(ctx, prefLogSavedQueries)
+	if err != nil {
+		logger.Warn("log queries: load preference failed", logger.FieldError, err)
+		return out
+	}
+	if value == nil {
+		return out
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return out
+	}
+	if err := json.Unmarshal(raw, &out); err != nil || out == nil {
+		return map[string]logSavedQuery{}
+	}
+	return out
+}
+
+type logSavedQueryView struct {
+	Name string `json:"name"`
+	logSavedQuery
+}
+
+func logSavedQueryViews(queries map[string]logSavedQuery) []logSavedQueryView {
+	out := make([]logSavedQueryView, 0, len(queries))
+	for name, q := range queries {
+		out = append(out, logSavedQueryView{Name: name, logSavedQuery: q})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// logQueriesList 已保存的日志查询 (JSON-RPC: log/queries/list)。
+func (s *Server) logQueriesList(ctx context.Context, _ json.RawMessage) (any, error) {
+	return map[string]any{"queries": logSavedQueryViews(s.loadLogSavedQueries(ctx))}, nil
+}
+
+type logQuerySaveParams struct {
+	Name        string `json:"name"`
+	Query       string `json:"query"`
+	Description string `json:"description,omitempty"`
+}
+
+// logQuerySaveTyped 保存 (或覆盖) 命名查询, 保存前先编译校验 (JSON-RPC: log/queries/save)。
+func (s *Server) logQuerySaveTyped(ctx context.Context, p logQuerySaveParams) (any, error) {
+	const op = "Server.logQuerySave"
+	if s.prefManager == nil {
+		return nil, apperrors.New(op, "preference manager not initialized")
+	}
+	name := strings.TrimSpace(p.Name)
+	if name == "" || utf8.RuneCountInString(name) > maxLogQueryNameLen {
+		return nil, apperrors.NewCodef(op, errcode.InvalidInput, "name is required (max %d chars)", maxLogQueryNameLen)
+	}
+	query := strings.TrimSpace(p.Query)
+	if query == "" {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "query is required")
+	}
+	if _, err := store.CompileLogQuery(query, time.Now()); err != nil {
+		return nil, apperrors.WrapCode(err, op, errcode.InvalidInput, "parse query")
+	}
+	desc := strings.TrimSpace(p.Description)
+	if utf8.RuneCountInString(desc) > maxLogQueryDescLen {
+		return nil, apperrors.NewCodef(op, errcode.InvalidInput, "description exceeds %d chars", maxLogQueryDescLen)
+	}
+
+	s.logQueryMu.Lock()
+	defer s.logQueryMu.Unlock()
+	queries := s.loadLogSavedQueries(ctx)
+	if _, exists := queries[name]; !exists && len(queries) >= maxLogSavedQueries {
+		return nil, apperrors.NewCodef(op, errcode.InvalidInput, "too many saved queries (limit %d)", maxLogSavedQueries)
+	}
+	queries[name] = logSavedQuery{Query: query, Description: desc, UpdatedAt: time.Now().UTC()}
+	if err := s.prefManager.Set(ctx, prefLogSavedQueries, queries); err != nil {
+		return nil, apperrors.Wrap(err, op, "persist saved queries")
+	}
+	logger.Info("log/queries/save: saved", logger.FieldName, name)
+	views := logSavedQueryViews(queries)
+	s.Notify("log/queries/updated", map[string]any{"queries": views})
+	return map[string]any{"name": name, "queries": views}, nil
+}
+
+type logQueryDeleteParams struct {
+	Name string `json:"name"`
+}
+
+// logQueryDeleteTyped 删除命名查询 (JSON-RPC: log/queries/delete)。
+func (s *Server) logQueryDeleteTyped(ctx context.Context, p logQueryDeleteParams) (any, error) {
+	const op = "Server.logQueryDelete"
+	if s.prefManager == nil {
+		return nil, apperrors.New(op, "preference manager not initialized")
+	}
+	name := strings.TrimSpace(p.Name)
+
+	s.logQueryMu.Lock()
+	defer s.logQueryMu.Unlock()
+	queries := s.loadLogSavedQueries(ctx)
+	if _, ok := queries[name]; !ok {
+		return nil, apperrors.NewCodef(op, errcode.NotFound, "saved query %q not found", name)
+	}
+	delete(queries, name)
+	if err := s.prefManager.Set(ctx, prefLogSavedQueries, queries); err != nil {
+		return nil, apperrors.Wrap(err, op, "persist saved queries")
+	}
+	logger.Info("log/queries/delete: deleted", logger.FieldName, name)
+	views := logSavedQueryViews(queries)
+	s.Notify("log/queries/updated", map[string]any{"queries": views})
+	return map[string]any{"name": name, "queries": views}, nil
+}
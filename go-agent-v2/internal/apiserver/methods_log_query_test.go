@@ -0,0 +1,64 @@
+package apiserver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/multi-agent/go-agent-v2/internal/uistate"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+func TestLogQuery_SavedQueries(t *testing.T) {
+	srv := &Server{prefManager: uistate.NewPreferenceManager(nil)}
+	ctx := context.Background()
+
+	if _, err := srv.logQuerySaveTyped(ctx, logQuerySaveParams{Name: "bad", Query: "level>=loud"}); apperrors.CodeOf(err) != errcode.InvalidInput {
+		t.Fatalf("invalid query err = %v", err)
+	}
+	if _, err := srv.logQuerySaveTyped(ctx, logQuerySaveParams{Name: "errors", Query: "level>=error OR event=crash"}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	list, _ := srv.logQueriesList(ctx, nil)
+	if views := list.(map[string]any)["queries"].([]logSavedQueryView); len(views) != 1 || views[0].Name != "errors" {
+		t.Fatalf("saved queries = %+v", views)
+	}
+
+	// 已保存查询与临时查询以 AND 组合, OR 不会越过括号。
+	res, err := srv.logQueryTyped(ctx, logQueryParams{Name: "errors", Query: "source=codex", Explain: true})
+	if err != nil {
+		t.Fatalf("explain: %v", err)
+	}
+	out := res.(map[string]any)
+	if out["query"] != "(level>=error OR event=crash) AND (source=codex)" {
+		t.Fatalf("combined query = %v", out["query"])
+	}
+	if want := "((UPPER(level) = ANY($1) OR event_type = $2) AND source = $3)"; out["where"] != want {
+		t.Fatalf("where = %v, want %s", out["where"], want)
+	}
+	if _, ok := out["logs"]; ok {
+		t.Fatal("explain must not execute the query")
+	}
+	if out["limit"] != defaultLogQueryLimit {
+		t.Fatalf("default limit = %v", out["limit"])
+	}
+	for limit, want := range map[int]int{1: 1, maxLogQueryResultLimit: maxLogQueryResultLimit, maxLogQueryResultLimit + 1: maxLogQueryResultLimit, 100000: maxLogQueryResultLimit} {
+		res, err := srv.logQueryTyped(ctx, logQueryParams{Query: "level=error", Limit: limit, Explain: true})
+		if err != nil {
+			t.Fatalf("explain limit %d: %v", limit, err)
+		}
+		if got := res.(map[string]any)["limit"]; got != want {
+			t.Fatalf("limit %d resolved to %v, want %d", limit, got, want)
+		}
+	}
+
+	if _, err := srv.logQueryTyped(ctx, logQueryParams{Query: "level=error"}); err == nil {
+		t.Fatal("query without log store should fail")
+	}
+	if _, err := srv.logQueryDeleteTyped(ctx, logQueryDeleteParams{Name: "errors"}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := srv.logQueryTyped(ctx, logQueryParams{Name: "errors", Explain: true}); apperrors.CodeOf(err) != errcode.NotFound {
+		t.Fatalf("deleted query err = %v", err)
+	}
+}
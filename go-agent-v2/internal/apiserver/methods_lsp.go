@@ -0,0 +1,112 @@
+// methods_lsp.go — lsp/catalog, lsp/install: 语言服务器目录查询与自动安装。
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/multi-agent/go-agent-v2/internal/lsp"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+// applyProjectLSPCatalog 加载项目 .agent/lsp.json 覆盖并应用到 LSP 管理器。
+func (s *Server) applyProjectLSPCatalog(rootDir string) {
+	if s.lsp == nil {
+		return
+	}
+	configs, overridden, err := lsp.LoadProjectCatalog(rootDir)
+	if err != nil {
+		logger.Warn("lsp: project catalog ignored",
+			logger.FieldPath, rootDir,
+			logger.FieldError, err,
+		)
+		return
+	}
+	if !overridden {
+		return
+	}
+	s.lsp.ApplyConfigs(configs)
+	logger.Info("lsp: project catalog applied",
+		logger.FieldPath, rootDir,
+		"languages", len(configs),
+	)
+}
+
+// lspCatalogEntry lsp/catalog 单项。
+type lspCatalogEntry struct {
+	Language              string         `json:"language"`
+	Command               string         `json:"command"`
+	Args                  []string       `json:"args,omitempty"`
+	Extensions            []string       `json:"extensions"`
+	Install               []string       `json:"install,omitempty"`
+	InitializationOptions map[string]any `json:"initializationOptions,omitempty"`
+	Available             bool           `json:"available"`
+	Running               bool           `json:"running"`
+}
+
+func (s *Server) lspCatalog(_ context.Context, _ json.RawMessage) (any, error) {
+	if s.lsp == nil {
+		return map[string]any{"servers": []lspCatalogEntry{}, "projectFile": lsp.ProjectCatalogFile}, nil
+	}
+	statuses := make(map[string]lsp.ServerStatus)
+	for _, status := range s.lsp.Statuses() {
+		statuses[status.Language] = status
+	}
+	configs := s.lsp.Configs()
+	servers := make([]lspCatalogEntry, 0, len(configs))
+	for _, cfg := range configs {
+		status := statuses[cfg.Language]
+		servers = append(servers, lspCatalogEntry{
+			Language:              cfg.Language,
+			Command:               cfg.Command,
+			Args:                  cfg.Args,
+			Extensions:            cfg.Extensions,
+			Install:               cfg.InstallCommand,
+			InitializationOptions: cfg.InitializationOptions,
+			Available:             status.Available,
+			Running:               status.Running,
+		})
+	}
+	return map[string]any{"servers": servers, "projectFile": lsp.ProjectCatalogFile}, nil
+}
+
+// lspInstallParams lsp/install 请求参数 (languages 为空时安装所有缺失且可安装的服务器)。
+type lspInstallParams struct {
+	Language  string   `json:"language,omitempty"`
+	Languages []string `json:"languages,omitempty"`
+	Force     bool     `json:"force,omitempty"`
+}
+
+func (s *Server) lspInstallTyped(ctx context.Context, p lspInstallParams) (any, error) {
+	if s.lsp == nil {
+		return nil, apperrors.New("Server.lspInstall", "lsp manager not initialized")
+	}
+	languages := append([]string(nil), p.Languages...)
+	if language := strings.TrimSpace(p.Language); language != "" {
+		languages = append(languages, language)
+	}
+	if len(languages) == 0 {
+		for _, cfg := range s.lsp.Configs() {
+			if len(cfg.InstallCommand) > 0 {
+				languages = append(languages, cfg.Language)
+			}
+		}
+	}
+
+	results := make([]lsp.InstallResult, 0, len(languages))
+	failed := 0
+	for _, language := range languages {
+		result := s.lsp.Install(ctx, language, p.Force)
+		if result.Status == "failed" {
+			failed++
+		}
+		results = append(results, result)
+	}
+	logger.Info("lsp/install: completed",
+		"requested", len(languages),
+		"failed", failed,
+	)
+	return map[string]any{"results": results, "ok": failed == 0}, nil
+}
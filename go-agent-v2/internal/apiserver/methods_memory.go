@@ -0,0 +1,221 @@
+// methods_memory.go — agent 长期记忆 JSON-RPC (memory/search, memory/inject) 与自动采集/检索。
+//
+// 自动采集: turn/completed 摘要、成功的动态工具输出 (异步写入, 不阻塞事件处理)。
+// 自动检索: turn/start 提交前按 prompt 检索 top-k 记忆, 前置到提交给 codex 的 prompt。
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/config"
+	"github.com/multi-agent/go-agent-v2/internal/service"
+	"github.com/multi-agent/go-agent-v2/internal/store"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+	"github.com/multi-agent/go-agent-v2/pkg/util"
+)
+
+const (
+	defaultMemoryAutoTopK        = 3
+	defaultMemoryMinScore        = 0.25
+	defaultMemoryToolOutputChars = 200
+	memoryRetrieveTimeout        = 3 * time.Second
+	memoryWriteTimeout           = 15 * time.Second
+	maxMemoryToolArgsChars       = 300
+)
+
+// newMemoryEmbedder 按配置选择 embedder (未配置 embedding 模型时使用本地特征哈希)。
+func newMemoryEmbedder(cfg *config.Config) service.Embedder {
+	if cfg == nil || strings.TrimSpace(cfg.MemoryEmbeddingModel) == "" {
+		return service.HashEmbedder{}
+	}
+	baseURL := strings.TrimSpace(cfg.MemoryEmbeddingBaseURL)
+	if baseURL == "" {
+		baseURL = strings.TrimSpace(cfg.OpenAIBaseURL)
+	}
+	apiKey := strings.TrimSpace(cfg.MemoryEmbeddingAPIKey)
+	if apiKey == "" {
+		apiKey = strings.TrimSpace(cfg.OpenAIAPIKey)
+	}
+	return &service.OpenAIEmbedder{BaseURL: baseURL, APIKey: apiKey, Model: strings.TrimSpace(cfg.MemoryEmbeddingModel)}
+}
+
+func (s *Server) memoryAutoTopK() int {
+	if s.cfg == nil {
+		return defaultMemoryAutoTopK
+	}
+	return s.cfg.MemoryAutoRetrieveTopK
+}
+
+func (s *Server) memoryMinScore() float64 {
+	if s.cfg == nil {
+		return defaultMemoryMinScore
+	}
+	return s.cfg.MemoryMinScore
+}
+
+func (s *Server) memoryToolOutputMinChars() int {
+	if s.cfg == nil {
+		return defaultMemoryToolOutputChars
+	}
+	return s.cfg.MemoryToolOutputMinChars
+}
+
+// memorySearchParams memory/search 请求参数。
+type memorySearchParams struct {
+	AgentID  string   `json:"agentId"`
+	ThreadID string   `json:"threadId,omitempty"` // agentId 为空时使用
+	Query    string   `json:"query"`
+	K        int      `json:"k,omitempty"`
+	Kinds    []string `json:"kinds,omitempty"`
+	MinScore *float64 `json:"minScore,omitempty"`
+}
+
+func (s *Server) memorySearchTyped(ctx context.Context, p memorySearchParams) (any, error) {
+	if s.memory == nil || !s.memory.Enabled(ctx) {
+		return nil, apperrors.New("Server.memorySearch", "memory store unavailable")
+	}
+	agentID := memoryAgentID(p.AgentID, p.ThreadID)
+	if agentID == "" {
+		return nil, apperrors.New("Server.memorySearch", "agentId is required")
+	}
+	minScore := 0.0
+	if p.MinScore != nil {
+		minScore = *p.MinScore
+	}
+	hits, err := s.memory.Search(ctx, service.MemoryQuery{
+		AgentID:  agentID,
+		Query:    p.Query,
+		TopK:     p.K,
+		Kinds:    p.Kinds,
+		MinScore: minScore,
+	})
+	if err != nil {
+		return nil, apperrors.Wrap(err, "Server.memorySearch", "search")
+	}
+	if hits == nil {
+		hits = []store.AgentMemoryHit{}
+	}
+	return map[string]any{"agentId": agentID, "memories": hits}, nil
+}
+
+// memoryInjectParams memory/inject 请求参数: 手动写入一条记忆。
+type memoryInjectParams struct {
+	AgentID  string         `json:"agentId"`
+	ThreadID string         `json:"threadId,omitempty"`
+	Content  string         `json:"content"`
+	Kind     string         `json:"kind,omitempty"` // 默认 note
+	Metadata map[string]any `json:"metadata,omitempty"`
+}
+
+func (s *Server) memoryInjectTyped(ctx context.Context, p memoryInjectParams) (any, error) {
+	if s.memory == nil || !s.memory.Enabled(ctx) {
+		return nil, apperrors.New("Server.memoryInject", "memory store unavailable")
+	}
+	agentID := memoryAgentID(p.AgentID, p.ThreadID)
+	if agentID == "" || strings.TrimSpace(p.Content) == "" {
+		return nil, apperrors.New("Server.memoryInject", "agentId and content are required")
+	}
+	id, err := s.memory.Remember(ctx, service.MemoryRecord{
+		AgentID:  agentID,
+		ThreadID: strings.TrimSpace(p.ThreadID),
+		Kind:     p.Kind,
+		Content:  p.Content,
+		Metadata: p.Metadata,
+	})
+	if err != nil {
+		return nil, apperrors.Wrap(err, "Server.memoryInject", "remember")
+	}
+	logger.Info("memory/inject: stored", logger.FieldAgentID, agentID, logger.FieldID, id)
+	return map[string]any{"id": id, "agentId": agentID}, nil
+}
+
+// memoryAgentID 记忆按 agent 隔离 (agent 与线程 1:1, 未给 agentId 时使用 threadId)。
+func memoryAgentID(agentID, threadID string) string {
+	if id := strings.TrimSpace(agentID); id != "" {
+		return id
+	}
+	return strings.TrimSpace(threadID)
+}
+
+// buildMemoryContextPrompt 检索与 prompt 相关的记忆并渲染为前置上下文 (失败时静默跳过)。
+func (s *Server) buildMemoryContextPrompt(ctx context.Context, agentID, prompt string) (string, int) {
+	topK := s.memoryAutoTopK()
+	if s.memory == nil || topK <= 0 || strings.TrimSpace(prompt) == "" {
+		return "", 0
+	}
+	searchCtx, cancel := context.WithTimeout(ctx, memoryRetrieveTimeout)
+	defer cancel()
+	if !s.memory.Enabled(searchCtx) {
+		return "", 0
+	}
+	hits, err := s.memory.Search(searchCtx, service.MemoryQuery{
+		AgentID:  agentID,
+		Query:    prompt,
+		TopK:     topK,
+		MinScore: s.memoryMinScore(),
+	})
+	if err != nil {
+		logger.Warn("memory: auto retrieve failed", logger.FieldAgentID, agentID, logger.FieldError, err)
+		return "", 0
+	}
+	return service.RenderMemoryContext(hits), len(hits)
+}
+
+// rememberAsync 异步写入记忆 (记忆不可用时直接跳过)。
+func (s *Server) rememberAsync(rec service.MemoryRecord) {
+	if s.memory == nil || strings.TrimSpace(rec.Content) == "" {
+		return
+	}
+	util.SafeGo(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), memoryWriteTimeout)
+		defer cancel()
+		if !s.memory.Enabled(ctx) {
+			return
+		}
+		if _, err := s.memory.Remember(ctx, rec); err != nil {
+			logger.Warn("memory: remember failed", logger.FieldAgentID, rec.AgentID, "kind", rec.Kind, logger.FieldError, err)
+		}
+	})
+}
+
+// rememberTurnSummary 记录已完成 turn 的摘要。
+func (s *Server) rememberTurnSummary(threadID, turnID, summary string) {
+	s.rememberAsync(service.MemoryRecord{
+		AgentID:  threadID,
+		ThreadID: threadID,
+		TurnID:   turnID,
+		Kind:     service.MemoryKindTurnSummary,
+		Content:  summary,
+	})
+}
+
+// rememberToolOutput 记录成功的动态工具输出 (过短的输出不入库)。
+func (s *Server) rememberToolOutput(agentID, tool string, args json.RawMessage, result string) {
+	if len(strings.TrimSpace(result)) < s.memoryToolOutputMinChars() {
+		return
+	}
+	argText := strings.TrimSpace(string(args))
+	if len(argText) > maxMemoryToolArgsChars {
+		argText = argText[:maxMemoryToolArgsChars] + "..."
+	}
+	s.rememberAsync(service.MemoryRecord{
+		AgentID:  agentID,
+		ThreadID: agentID,
+		TurnID:   s.activeTrackedTurnID(agentID),
+		Kind:     service.MemoryKindToolOutput,
+		Content:  fmt.Sprintf("tool %s(%s):\n%s", tool, argText, result),
+		Metadata: map[string]any{"tool": tool},
+	})
+}
+
+func (s *Server) activeTrackedTurnID(threadID string) string {
+	if turnID, _, _, ok := s.peekTrackedTurnMeta(threadID); ok {
+		return turnID
+	}
+	return ""
+}
@@ -0,0 +1,69 @@
+package apiserver
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/multi-agent/go-agent-v2/internal/config"
+	"github.com/multi-agent/go-agent-v2/internal/service"
+	"github.com/multi-agent/go-agent-v2/internal/store"
+)
+
+type stubMemoryRepo struct{ rows []store.AgentMemory }
+
+func (r *stubMemoryRepo) Available(context.Context) (bool, error) { return true, nil }
+
+func (r *stubMemoryRepo) Insert(_ context.Context, m *store.AgentMemory) error {
+	m.ID = int64(len(r.rows) + 1)
+	r.rows = append(r.rows, *m)
+	return nil
+}
+
+func (r *stubMemoryRepo) Search(_ context.Context, q store.AgentMemoryQuery) ([]store.AgentMemoryHit, error) {
+	var hits []store.AgentMemoryHit
+	for _, row := range r.rows {
+		var score float64
+		for i := range row.Embedding {
+			score += float64(row.Embedding[i]) * float64(q.Embedding[i])
+		}
+		if row.AgentID == q.AgentID {
+			hits = append(hits, store.AgentMemoryHit{ID: row.ID, AgentID: row.AgentID, Kind: row.Kind, Content: row.Content, Score: score})
+		}
+	}
+	return hits, nil
+}
+
+func TestBuildMemoryContextPrompt(t *testing.T) {
+	repo := &stubMemoryRepo{}
+	srv := &Server{memory: service.NewAgentMemoryService(repo, nil)}
+	if _, err := srv.memoryInjectTyped(context.Background(), memoryInjectParams{ThreadID: "thread-1", Content: "deploy script lives in ops/deploy.sh"}); err != nil {
+		t.Fatalf("memory/inject: %v", err)
+	}
+
+	prompt, count := srv.buildMemoryContextPrompt(context.Background(), "thread-1", "run the deploy script")
+	if count != 1 || !strings.Contains(prompt, "ops/deploy.sh") {
+		t.Fatalf("memory prompt = %q (%d)", prompt, count)
+	}
+	if merged := mergePromptText(prompt, "run the deploy script"); !strings.HasSuffix(merged, "\nrun the deploy script") {
+		t.Fatalf("memory context should be prepended, got %q", merged)
+	}
+	if _, count := srv.buildMemoryContextPrompt(context.Background(), "thread-2", "run the deploy script"); count != 0 {
+		t.Fatal("memories must be isolated per agent")
+	}
+
+	srv.cfg = &config.Config{MemoryAutoRetrieveTopK: 0}
+	if _, count := srv.buildMemoryContextPrompt(context.Background(), "thread-1", "run the deploy script"); count != 0 {
+		t.Fatal("topK=0 should disable auto retrieval")
+	}
+}
+
+func TestMemoryRPCWithoutStore(t *testing.T) {
+	srv := &Server{}
+	if _, err := srv.memorySearchTyped(context.Background(), memorySearchParams{AgentID: "a", Query: "x"}); err == nil {
+		t.Fatal("expected unavailable error")
+	}
+	if prompt, count := srv.buildMemoryContextPrompt(context.Background(), "a", "x"); prompt != "" || count != 0 {
+		t.Fatal("no memory store should inject nothing")
+	}
+}
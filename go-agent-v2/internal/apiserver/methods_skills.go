@@ -30,6 +30,11 @@ func (s *Server) skillsList(_ context.Context, _ json.RawMessage) (any, error) {
 	if err != nil {
 		return nil, apperrors.Wrap(err, "Server.skillsList", "list skills")
 	}
+	return map[string]any{"skills": skillInfoPayload(list)}, nil
+}
+
+// skillInfoPayload 技能列表的前端视图 (skills/list 与 skills/changed 共用)。
+func skillInfoPayload(list []service.SkillInfo) []map[string]any {
 	skills := make([]map[string]any, 0, len(list))
 	for _, item := range list {
 		skills = append(skills, map[string]any{
@@ -41,7 +46,7 @@ func (s *Server) skillsList(_ context.Context, _ json.RawMessage) (any, error) {
 			"force_words":   item.ForceWords,
 		})
 	}
-	return map[string]any{"skills": skills}, nil
+	return skills
 }
 
 func (s *Server) appList(_ context.Context, _ json.RawMessage) (any, error) {
@@ -568,3 +573,146 @@ func (s *Server) skillsRemoteWriteTyped(_ context.Context, p skillsRemoteWritePa
 	}
 	return map[string]any{"ok": true, "path": path}, nil
 }
+
+// ========================================
+// skills/registry/sync
+// ========================================
+
+// skillsRegistrySyncParams skills/registry/sync 请求参数。
+type skillsRegistrySyncParams struct {
+	Source  string   `json:"source,omitempty"`  // 为空时使用 SKILL_REGISTRY_URL
+	Install []string `json:"install,omitempty"` // 需要安装的技能名 (为空仅列出)
+}
+
+// skillsRegistrySyncTyped 拉取远程技能注册表, 列出可用技能并按需安装 (带签名校验)。
+func (s *Server) skillsRegistrySyncTyped(ctx context.Context, p skillsRegistrySyncParams) (any, error) {
+	source := strings.TrimSpace(p.Source)
+	if source == "" && s.cfg != nil {
+		source = strings.TrimSpace(s.cfg.SkillRegistryURL)
+	}
+	if source == "" {
+		return nil, apperrors.New("Server.skillsRegistrySync", "source is required (or set SKILL_REGISTRY_URL)")
+	}
+	registry, err := service.OpenSkillRegistry(ctx, source)
+	if err != nil {
+		return nil, apperrors.Wrap(err, "Server.skillsRegistrySync", "open registry")
+	}
+	defer registry.Close()
+
+	index := registry.Index()
+	installed := make([]service.SkillRegistryInstallResult, 0, len(p.Install))
+	failures := make([]skillImportFailure, 0)
+	if len(p.Install) > 0 {
+		var publicKey []byte
+		if s.cfg != nil {
+			key, keyErr := service.ParseSkillRegistryPublicKey(s.cfg.SkillRegistryPublicKey)
+			if keyErr != nil {
+				return nil, apperrors.Wrap(keyErr, "Server.skillsRegistrySync", "parse registry public key")
+			}
+			publicKey = key
+		}
+		for _, raw := range p.Install {
+			name := strings.TrimSpace(raw)
+			if name == "" {
+				continue
+			}
+			result, installErr := registry.Install(ctx, s.skillSvc, name, publicKey)
+			if installErr != nil {
+				logger.Warn("skills/registry/sync: install failed",
+					logger.FieldSkill, name,
+					logger.FieldSource, source,
+					logger.FieldError, installErr,
+				)
+				failures = append(failures, skillImportFailure{Source: name, Error: installErr.Error()})
+				continue
+			}
+			logger.Info("skills/registry/sync: installed",
+				logger.FieldSkill, result.Name,
+				logger.FieldSource, source,
+				logger.FieldPath, result.Path,
+			)
+			installed = append(installed, result)
+		}
+	}
+
+	return map[string]any{
+		"ok":        len(failures) == 0,
+		"source":    index.Source,
+		"skills":    index.Skills,
+		"installed": installed,
+		"failures":  failures,
+	}, nil
+}
+
+// ========================================
+// skills/validate
+// ========================================
+
+// skillsValidateParams skills/validate 请求参数。
+//
+// 提供 content 时校验该内容 (保存前预检), 否则校验已安装技能 (names 为空 = 全部)。
+type skillsValidateParams struct {
+	Name    string   `json:"name,omitempty"`
+	Names   []string `json:"names,omitempty"`
+	Content *string  `json:"content,omitempty"`
+}
+
+func (s *Server) skillsValidateTyped(_ context.Context, p skillsValidateParams) (any, error) {
+	var results []service.SkillValidationResult
+	if p.Content != nil {
+		results = []service.SkillValidationResult{service.ValidateSkillContent(p.Name, *p.Content)}
+	} else {
+		if s.skillSvc == nil {
+			return nil, apperrors.New("Server.skillsValidate", "skills service not initialized")
+		}
+		names := append([]string(nil), p.Names...)
+		if name := strings.TrimSpace(p.Name); name != "" {
+			names = append(names, name)
+		}
+		var err error
+		results, err = s.skillSvc.ValidateSkills(names)
+		if err != nil {
+			return nil, apperrors.Wrap(err, "Server.skillsValidate", "validate skills")
+		}
+	}
+	if results == nil {
+		results = []service.SkillValidationResult{}
+	}
+
+	errorCount, warningCount := countSkillValidationIssues(results)
+	logger.Info("skills/validate: completed",
+		"skills", len(results),
+		"errors", errorCount,
+		"warnings", warningCount,
+	)
+	return map[string]any{
+		"valid":        errorCount == 0,
+		"results":      results,
+		"errorCount":   errorCount,
+		"warningCount": warningCount,
+	}, nil
+}
+
+// countSkillValidationIssues 统计校验结果中的错误/警告数量。
+func countSkillValidationIssues(results []service.SkillValidationResult) (errorCount, warningCount int) {
+	for _, result := range results {
+		for _, issue := range result.Issues {
+			if issue.Severity == service.SkillIssueSeverityErr {
+				errorCount++
+			} else {
+				warningCount++
+			}
+		}
+	}
+	return errorCount, warningCount
+}
+
+// ValidateSkillsDir 离线校验技能目录 (供 app-server --validate-skills 使用)。
+//
+// dir 为空时使用默认技能缓存目录。
+func ValidateSkillsDir(dir string) ([]service.SkillValidationResult, error) {
+	if strings.TrimSpace(dir) == "" {
+		dir = defaultSkillsCacheDir()
+	}
+	return service.NewSkillService(dir).ValidateSkills(nil)
+}
@@ -20,18 +20,23 @@ import (
 	"github.com/multi-agent/go-agent-v2/internal/runner"
 	"github.com/multi-agent/go-agent-v2/internal/store"
 	"github.com/multi-agent/go-agent-v2/internal/uistate"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
 	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
 	"github.com/multi-agent/go-agent-v2/pkg/logger"
 	"github.com/multi-agent/go-agent-v2/pkg/util"
 )
 
 type threadStartParams struct {
-	Model                 string `json:"model,omitempty"`
-	ModelProvider         string `json:"modelProvider,omitempty"`
-	Cwd                   string `json:"cwd,omitempty"`
-	ApprovalPolicy        string `json:"approvalPolicy,omitempty"`
-	BaseInstructions      string `json:"baseInstructions,omitempty"`
-	DeveloperInstructions string `json:"developerInstructions,omitempty"`
+	Model                 string            `json:"model,omitempty"`
+	ModelProvider         string            `json:"modelProvider,omitempty"`
+	Cwd                   string            `json:"cwd,omitempty"`
+	ApprovalPolicy        string            `json:"approvalPolicy,omitempty"`
+	BaseInstructions      string            `json:"baseInstructions,omitempty"`
+	DeveloperInstructions string            `json:"developerInstructions,omitempty"`
+	TemplateID            string            `json:"templateId,omitempty"` // 角色模板 (agentTemplate/list)
+	Env                   map[string]string `json:"env,omitempty"`        // 线程级环境变量覆盖 (同 thread/env/set, 持久化后随恢复复用)
+	// RestartPolicy 进程崩溃 / 退出时的自动重启策略 (nil = 全局 AGENT_RESTART_* 配置)。
+	RestartPolicy *runner.RestartPolicy `json:"restartPolicy,omitempty"`
 }
 
 // threadInfo 通用线程信息。
@@ -48,28 +53,91 @@ type threadStartResponse struct {
 	ModelProvider  string     `json:"modelProvider"`
 	Cwd            string     `json:"cwd"`
 	ApprovalPolicy string     `json:"approvalPolicy"`
+
+	RestartPolicy *runner.RestartPolicy `json:"restartPolicy,omitempty"`
 }
 
 func (s *Server) threadStartTyped(ctx context.Context, p threadStartParams) (any, error) {
+	if err := s.drainingError("Server.threadStart"); err != nil {
+		return nil, err
+	}
 	if p.Cwd == "" {
 		p.Cwd = "."
 	}
 
+	var (
+		tmpl        agentTemplate
+		hasTemplate bool
+	)
+	if strings.TrimSpace(p.TemplateID) != "" {
+		resolved, err := s.resolveAgentTemplate(ctx, "Server.threadStart", p.TemplateID)
+		if err != nil {
+			return nil, err
+		}
+		tmpl, hasTemplate = resolved, true
+		if p.Model == "" {
+			p.Model = tmpl.Model
+		}
+		if p.ApprovalPolicy == "" {
+			p.ApprovalPolicy = tmpl.ApprovalPolicy
+		}
+	}
+
+	if p.RestartPolicy != nil {
+		policy, err := p.RestartPolicy.Normalize()
+		if err != nil {
+			return nil, err
+		}
+		p.RestartPolicy = &policy
+	}
+
+	envPatch := make(map[string]*string, len(p.Env))
+	for key, value := range p.Env {
+		if err := validateThreadEnvEntry(key, &value); err != nil {
+			return nil, apperrors.WrapCode(err, "Server.threadStart", errcode.InvalidInput, "invalid env override")
+		}
+		envPatch[key] = &value
+	}
+
 	id := fmt.Sprintf("thread-%d-%d", time.Now().UnixMilli(), s.threadSeq.Add(1))
+	if len(envPatch) > 0 {
+		// 先持久化, 启动时由启动环境解析器注入。
+		if s.prefManager == nil {
+			return nil, apperrors.New("Server.threadStart", "preference manager not initialized")
+		}
+		if _, err := s.saveThreadEnv(ctx, id, envPatch, true); err != nil {
+			return nil, err
+		}
+	}
 
-	// 构建全部动态工具注入 agent (LSP + 编排 + 资源)
-	dynamicTools := s.buildAllDynamicTools()
+	// 构建全部动态工具注入 agent (内置 + 注册表 + 项目 HTTP 工具), 模板启动时按白名单过滤
+	dynamicTools := s.buildDynamicToolsForThread(id, p.Cwd)
+	if hasTemplate {
+		dynamicTools = tmpl.filterTools(dynamicTools)
+	}
 
-	// 提示词注入统一走 turn/start 与 turn/steer，thread 启动不再附加独立注入。
-	if err := s.mgr.Launch(ctx, id, id, "", p.Cwd, "", dynamicTools); err != nil {
+	// 提示词注入统一走 turn/start 与 turn/steer，thread 启动仅携带模板基础指令。
+	if err := s.mgr.LaunchWithOptions(ctx, runner.LaunchOptions{
+		ID:            id,
+		Name:          id,
+		Cwd:           p.Cwd,
+		Model:         p.Model,
+		Instructions:  tmpl.BaseInstructions,
+		DynamicTools:  dynamicTools,
+		RestartPolicy: p.RestartPolicy,
+	}); err != nil {
 		return nil, apperrors.Wrap(err, "Server.threadStart", "launch thread")
 	}
+	if hasTemplate {
+		s.applyTemplateToThread(ctx, id, tmpl, false)
+	}
 	if proc := s.mgr.Get(id); proc != nil {
 		s.registerBinding(ctx, id, proc)
 	}
 	if s.uiRuntime != nil {
 		s.uiRuntime.ReplaceThreads(buildThreadSnapshots(s.mgr.List()))
 	}
+	s.watchThreadWorkspace(id, p.Cwd)
 
 	return threadStartResponse{
 		Thread: threadInfo{
@@ -80,6 +148,7 @@ func (s *Server) threadStartTyped(ctx context.Context, p threadStartParams) (any
 		ModelProvider:  p.ModelProvider,
 		Cwd:            p.Cwd,
 		ApprovalPolicy: p.ApprovalPolicy,
+		RestartPolicy:  p.RestartPolicy,
 	}, nil
 }
 
@@ -93,8 +162,10 @@ type threadResumeParams struct {
 
 // threadResumeResponse thread/resume 响应。
 type threadResumeResponse struct {
-	Thread threadInfo `json:"thread"`
-	Model  string     `json:"model"`
+	Thread      threadInfo           `json:"thread"`
+	Model       string               `json:"model"`
+	Personality string               `json:"personality,omitempty"` // 重新套用的线程人格
+	Handoff     *store.ThreadHandoff `json:"handoff,omitempty"`     // 下一次 turn 将注入的交接摘要
 }
 
 func (s *Server) threadResumeTyped(ctx context.Context, p threadResumeParams) (any, error) {
@@ -107,10 +178,12 @@ func (s *Server) threadResumeTyped(ctx context.Context, p threadResumeParams) (a
 			"cwd", strings.TrimSpace(p.Cwd),
 		)
 		resumedID, err := tryResumeCandidates(candidates, p.ThreadID, func(id string) error {
-			return proc.Client.ResumeThread(codex.ResumeThreadRequest{
-				ThreadID: id,
-				Path:     p.Path,
-				Cwd:      p.Cwd,
+			return traceCodex(ctx, p.ThreadID, "resume", func() error {
+				return proc.Client.ResumeThread(codex.ResumeThreadRequest{
+					ThreadID: id,
+					Path:     p.Path,
+					Cwd:      p.Cwd,
+				})
 			})
 		})
 		if err != nil {
@@ -118,8 +191,10 @@ func (s *Server) threadResumeTyped(ctx context.Context, p threadResumeParams) (a
 		}
 		_ = resumedID // logged inside tryResumeCandidates
 		return threadResumeResponse{
-			Thread: threadInfo{ID: p.ThreadID, Status: "resumed"},
-			Model:  p.Model,
+			Thread:      threadInfo{ID: p.ThreadID, Status: "resumed"},
+			Model:       p.Model,
+			Personality: s.reapplyThreadPersonality(ctx, proc, p.ThreadID),
+			Handoff:     s.pendingThreadHandoff(ctx, p.ThreadID),
 		}, nil
 	})
 }
@@ -131,18 +206,28 @@ type threadIDParams struct {
 // threadForkParams thread/fork 请求参数。
 type threadForkParams struct {
 	ThreadID  string `json:"threadId"`
-	TurnIndex *int   `json:"turnIndex,omitempty"`
+	TurnIndex *int   `json:"turnIndex,omitempty"` // 保留到第几个 turn (从 0 开始), 见 thread_lineage.go
 }
 
 // threadForkResponse thread/fork 响应。
 type threadForkResponse struct {
-	Thread threadInfo `json:"thread"`
+	Thread        threadInfo `json:"thread"`
+	TurnIndex     *int       `json:"turnIndex,omitempty"`
+	CodexThreadID string     `json:"codexThreadId,omitempty"`
+	RolloutPath   string     `json:"rolloutPath,omitempty"`
 }
 
-func (s *Server) threadForkTyped(_ context.Context, p threadForkParams) (any, error) {
+func (s *Server) threadForkTyped(ctx context.Context, p threadForkParams) (any, error) {
+	if p.TurnIndex != nil {
+		return s.forkThreadAtTurn(ctx, strings.TrimSpace(p.ThreadID), *p.TurnIndex)
+	}
 	return s.withThread(p.ThreadID, func(proc *runner.AgentProcess) (any, error) {
-		resp, err := proc.Client.ForkThread(codex.ForkThreadRequest{
-			SourceThreadID: p.ThreadID,
+		var resp *codex.ForkThreadResponse
+		err := traceCodex(ctx, p.ThreadID, "fork", func() (err error) {
+			resp, err = proc.Client.ForkThread(codex.ForkThreadRequest{
+				SourceThreadID: p.ThreadID,
+			})
+			return err
 		})
 		if err != nil {
 			return nil, apperrors.Wrap(err, "Server.threadFork", "fork thread")
@@ -151,6 +236,7 @@ func (s *Server) threadForkTyped(_ context.Context, p threadForkParams) (any, er
 		if newID == "" {
 			newID = fmt.Sprintf("thread-%d", time.Now().UnixMilli())
 		}
+		s.recordThreadLineage(ctx, store.ThreadLineage{ChildThreadID: newID, ParentThreadID: p.ThreadID, CodexThreadID: resp.ThreadID})
 		return threadForkResponse{
 			Thread: threadInfo{ID: newID, ForkedFrom: p.ThreadID},
 		}, nil
@@ -163,7 +249,7 @@ func (s *Server) threadArchiveTyped(ctx context.Context, p threadIDParams) (any,
 		return nil, apperrors.New("Server.threadArchive", "threadId is required")
 	}
 	if !s.threadExistsForArchive(ctx, threadID) {
-		return nil, apperrors.Newf("Server.threadArchive", "thread %s not found", threadID)
+		return nil, apperrors.NewCodef("Server.threadArchive", errcode.ThreadNotFound, "thread %s not found", threadID)
 	}
 
 	manifest, err := s.archiveThreadArtifacts(ctx, threadID)
@@ -273,11 +359,13 @@ func (s *Server) threadNameSetTyped(ctx context.Context, p threadNameSetParams)
 		existsInRuntime = hasThread(s.uiRuntime.SnapshotLight().Threads, threadID)
 	}
 	if proc == nil && !existsInRuntime && !s.threadExistsInHistory(ctx, threadID) {
-		return nil, apperrors.Newf("Server.threadNameSet", "thread %s not found", threadID)
+		return nil, apperrors.NewCodef("Server.threadNameSet", errcode.ThreadNotFound, "thread %s not found", threadID)
 	}
 
 	if proc != nil && renameTarget != "" {
-		if err := proc.Client.SendCommand("/rename", renameTarget); err != nil {
+		if err := traceCodex(ctx, threadID, "/rename", func() error {
+			return proc.Client.SendCommand("/rename", renameTarget)
+		}); err != nil {
 			return nil, apperrors.Wrap(err, "Server.threadNameSet", "send rename command")
 		}
 	}
@@ -285,7 +373,7 @@ func (s *Server) threadNameSetTyped(ctx context.Context, p threadNameSetParams)
 	if s.uiRuntime != nil {
 		s.uiRuntime.SetThreadName(threadID, persistedAlias)
 	}
-	if err := s.persistThreadAlias(ctx, threadID, persistedAlias); err != nil {
+	if err := s.persistDurable(ctx, walOp{Kind: walKindAlias, Alias: &walAlias{ThreadID: threadID, Alias: persistedAlias}}); err != nil {
 		logger.Warn("thread/name/set: persist alias failed",
 			logger.FieldThreadID, threadID,
 			logger.FieldError, err,
@@ -305,9 +393,11 @@ type threadRollbackParams struct {
 	TurnIndex int    `json:"turnIndex"`
 }
 
-func (s *Server) threadRollbackTyped(_ context.Context, p threadRollbackParams) (any, error) {
+func (s *Server) threadRollbackTyped(ctx context.Context, p threadRollbackParams) (any, error) {
 	return s.withThread(p.ThreadID, func(proc *runner.AgentProcess) (any, error) {
-		if err := proc.Client.SendCommand("/undo", fmt.Sprintf("%d", p.TurnIndex)); err != nil {
+		if err := traceCodex(ctx, p.ThreadID, "/undo", func() error {
+			return proc.Client.SendCommand("/undo", fmt.Sprintf("%d", p.TurnIndex))
+		}); err != nil {
 			return nil, apperrors.Wrap(err, "Server.threadRollback", "send undo command")
 		}
 		return map[string]any{}, nil
@@ -316,9 +406,11 @@ func (s *Server) threadRollbackTyped(_ context.Context, p threadRollbackParams)
 
 // threadListItem thread/list 响应项。
 type threadListItem struct {
-	ID    string `json:"id"`
-	Name  string `json:"name"`
-	State string `json:"state"`
+	ID    string            `json:"id"`
+	Name  string            `json:"name"`
+	State string            `json:"state"`
+	Meta  map[string]string `json:"meta,omitempty"` // thread/meta/set 写入的外部引用
+	Tags  []string          `json:"tags,omitempty"` // thread/tags/set 写入的标签
 }
 
 // threadListResponse thread/list 响应。
@@ -483,7 +575,18 @@ func (s *Server) appendThreadHistoryFromStores(ctx context.Context, threads []th
 	return threads
 }
 
-func (s *Server) threadList(ctx context.Context, _ json.RawMessage) (any, error) {
+func (s *Server) threadList(ctx context.Context, params json.RawMessage) (any, error) {
+	var p threadListParams
+	if len(params) > 0 && string(params) != "null" {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, apperrors.WrapCode(err, "Server.threadList", errcode.InvalidParams, "decode params")
+		}
+	}
+	filter, err := s.resolveThreadListFilter(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
 	agents := []runner.AgentInfo{}
 	if s.mgr != nil {
 		agents = s.mgr.List()
@@ -506,11 +609,13 @@ func (s *Server) threadList(ctx context.Context, _ json.RawMessage) (any, error)
 
 	threads = s.appendThreadHistoryFromStores(ctx, threads, seen, "thread/list")
 	applyThreadAliases(threads, s.loadThreadAliases(ctx))
+	applyThreadMetadata(threads, s.loadThreadMetadata(ctx))
+	applyThreadTags(threads, s.loadThreadTags(ctx))
 	if s.uiRuntime != nil {
 		s.uiRuntime.ReplaceThreads(buildThreadSnapshotsFromListItems(threads))
 	}
 
-	return threadListResponse{Threads: threads}, nil
+	return threadListResponse{Threads: filterThreadList(threads, filter)}, nil
 }
 
 // threadLoadedListResponse thread/loaded/list 响应。
@@ -541,13 +646,19 @@ func (s *Server) threadLoadedList(ctx context.Context, _ json.RawMessage) (any,
 
 	threads = s.appendThreadHistoryFromStores(ctx, threads, seen, "thread/loaded/list")
 	applyThreadAliases(threads, s.loadThreadAliases(ctx))
+	applyThreadMetadata(threads, s.loadThreadMetadata(ctx))
+	applyThreadTags(threads, s.loadThreadTags(ctx))
 
 	return threadLoadedListResponse{Threads: threads}, nil
 }
 
 func (s *Server) threadReadTyped(ctx context.Context, p threadIDParams) (any, error) {
 	return s.withThread(p.ThreadID, func(proc *runner.AgentProcess) (any, error) {
-		threads, err := proc.Client.ListThreads()
+		var threads []codex.ThreadInfo
+		err := traceCodex(ctx, p.ThreadID, "listThreads", func() (err error) {
+			threads, err = proc.Client.ListThreads()
+			return err
+		})
 		if err != nil {
 			return nil, err
 		}
@@ -668,7 +779,7 @@ func (s *Server) threadMessagesTyped(ctx context.Context, p threadMessagesParams
 	timelineLen := 0
 	if s.uiRuntime != nil {
 		diffLen = len(s.uiRuntime.ThreadDiff(p.ThreadID))
-		timelineLen = len(s.uiRuntime.ThreadTimeline(p.ThreadID))
+		timelineLen = s.uiRuntime.ThreadTimelineLen(p.ThreadID)
 	}
 	logger.Info("thread/messages: response prepared",
 		logger.FieldAgentID, p.ThreadID, logger.FieldThreadID, p.ThreadID,
@@ -727,7 +838,7 @@ func (s *Server) streamRemainingHistory(threadID string, all []threadHistoryMess
 	records := msgsToRecords(remaining)
 	s.uiRuntime.AppendHistory(threadID, records)
 	diffLen := len(s.uiRuntime.ThreadDiff(threadID))
-	timelineLen := len(s.uiRuntime.ThreadTimeline(threadID))
+	timelineLen := s.uiRuntime.ThreadTimelineLen(threadID)
 
 	// 通知前端 timeline 已更新
 	s.Notify("thread/messages/page", map[string]any{
@@ -873,25 +984,34 @@ func paginateRolloutMessages(all []threadHistoryMessage, limit int, before int64
 	return page
 }
 
-func (s *Server) loadAllThreadMessagesFromCodexRollout(ctx context.Context, threadID string) ([]threadHistoryMessage, error) {
+// resolveRolloutFilePath 解析线程对应的 rollout 文件路径 (不存在时返回空字符串)。
+func (s *Server) resolveRolloutFilePath(ctx context.Context, threadID string) string {
 	codexThreadID, rolloutPath := s.resolveRolloutHistorySource(ctx, threadID)
 	codexThreadID = normalizeCodexThreadID(codexThreadID)
 	if codexThreadID == "" {
-		return []threadHistoryMessage{}, nil
+		return ""
 	}
 
 	path := strings.TrimSpace(rolloutPath)
 	if path == "" {
 		resolvedPath, err := codex.FindRolloutPath(codexThreadID)
 		if err != nil {
-			return []threadHistoryMessage{}, nil
+			return ""
 		}
 		path = resolvedPath
 	}
 	if path == "" {
-		return []threadHistoryMessage{}, nil
+		return ""
 	}
 	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+func (s *Server) loadAllThreadMessagesFromCodexRollout(ctx context.Context, threadID string) ([]threadHistoryMessage, error) {
+	path := s.resolveRolloutFilePath(ctx, threadID)
+	if path == "" {
 		return []threadHistoryMessage{}, nil
 	}
 
@@ -1144,9 +1264,11 @@ func (s *Server) archiveThreadArtifacts(ctx context.Context, threadID string) (t
 		return manifest, apperrors.Wrap(err, "Server.archiveThreadArtifacts", "write manifest")
 	}
 	if s.bindingStore != nil && manifest.CodexThreadID != "" && strings.TrimSpace(manifest.RolloutPath) != "" {
-		dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
-		err := s.bindingStore.Bind(dbCtx, id, manifest.CodexThreadID, manifest.RolloutPath)
-		cancel()
+		err := s.persistDurable(ctx, walOp{Kind: walKindBinding, Binding: &walBinding{
+			AgentID:       id,
+			CodexThreadID: manifest.CodexThreadID,
+			RolloutPath:   manifest.RolloutPath,
+		}})
 		if err != nil {
 			logger.Warn("thread/archive: persist rollout path failed",
 				logger.FieldThreadID, id,
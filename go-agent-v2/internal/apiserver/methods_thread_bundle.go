@@ -0,0 +1,416 @@
+// methods_thread_bundle.go — thread/export · thread/import: 可移植会话包 (.maost), 用于同事间交接线程。
+//
+// 会话包是 zip 文件:
+//   - manifest.json  格式版本、源线程 ID / 名称 / 工作目录、技能配置、线程元数据
+//   - rollout.jsonl  codex rollout 原文 (导入后可 thread/resume 继续对话)
+//   - messages.json  rollout 解析出的消息 (无 rollout 时用于重建 timeline)
+//   - timeline.json  UI timeline 快照
+//   - diff.patch     线程累计 diff
+//
+// 导入时分配新的线程 ID 与 codex 线程 ID (rollout 中的旧 ID 整体替换), rollout 写入本机
+// ~/.codex/sessions 并登记绑定, 因此同一会话包可在同一台机器上重复导入而互不冲突。
+package apiserver
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/uistate"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+const (
+	threadBundleFormat     = "maost"
+	threadBundleVersion    = 1
+	threadBundleExt        = ".maost"
+	maxThreadBundleEntry   = 256 << 20 // 单个条目解压上限
+	threadBundleImportMeta = "importedFrom"
+
+	threadBundleManifest = "manifest.json"
+	threadBundleRollout  = "rollout.jsonl"
+	threadBundleMessages = "messages.json"
+	threadBundleTimeline = "timeline.json"
+	threadBundleDiff     = "diff.patch"
+)
+
+// threadBundleManifestData 会话包清单 (manifest.json)。
+type threadBundleManifestData struct {
+	Format        string            `json:"format"`
+	Version       int               `json:"version"`
+	ExportedAt    string            `json:"exportedAt"`
+	ThreadID      string            `json:"threadId"`
+	Name          string            `json:"name,omitempty"`
+	CodexThreadID string            `json:"codexThreadId,omitempty"`
+	Cwd           string            `json:"cwd,omitempty"`
+	Skills        []string          `json:"skills,omitempty"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+	MessageCount  int               `json:"messageCount"`
+	TimelineCount int               `json:"timelineCount"`
+	HasRollout    bool              `json:"hasRollout"`
+	HasDiff       bool              `json:"hasDiff"`
+}
+
+// threadDisplayName 线程在 UI 中的名称 (别名), 未命名返回空串。
+func (s *Server) threadDisplayName(threadID string) string {
+	if s.uiRuntime == nil {
+		return ""
+	}
+	for _, item := range s.uiRuntime.SnapshotLight().Threads {
+		if item.ID == threadID && item.Name != threadID {
+			return strings.TrimSpace(item.Name)
+		}
+	}
+	return ""
+}
+
+// ========================================
+// thread/export
+// ========================================
+
+// threadExportParams thread/export 请求参数。
+type threadExportParams struct {
+	ThreadID string `json:"threadId"`
+	Path     string `json:"path,omitempty"` // 目标 .maost 路径, 空 = 临时目录
+}
+
+func (s *Server) threadExportTyped(ctx context.Context, p threadExportParams) (any, error) {
+	threadID := strings.TrimSpace(p.ThreadID)
+	if threadID == "" {
+		return nil, apperrors.New("Server.threadExport", "threadId is required")
+	}
+	if !s.threadExistsForArchive(ctx, threadID) {
+		return nil, apperrors.NewCodef("Server.threadExport", errcode.ThreadNotFound, "thread %s not found", threadID)
+	}
+
+	manifest := threadBundleManifestData{
+		Format:     threadBundleFormat,
+		Version:    threadBundleVersion,
+		ExportedAt: time.Now().UTC().Format(time.RFC3339),
+		ThreadID:   threadID,
+		Name:       s.threadDisplayName(threadID),
+		Cwd:        s.getAgentWorkDir(threadID),
+		Skills:     s.GetAgentSkills(threadID),
+		Metadata:   s.loadThreadMetadata(ctx)[threadID],
+	}
+	codexThreadID, _ := s.resolveRolloutHistorySource(ctx, threadID)
+	manifest.CodexThreadID = normalizeCodexThreadID(codexThreadID)
+
+	var rollout []byte
+	if path := s.resolveRolloutFilePath(ctx, threadID); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, apperrors.Wrap(err, "Server.threadExport", "read rollout")
+		}
+		rollout = data
+	}
+	messages, err := s.loadAllThreadMessagesFromCodexRollout(ctx, threadID)
+	if err != nil {
+		return nil, apperrors.Wrap(err, "Server.threadExport", "load rollout messages")
+	}
+	var (
+		timeline []uistate.TimelineItem
+		diff     string
+	)
+	if s.uiRuntime != nil {
+		timeline = s.uiRuntime.ThreadTimeline(threadID)
+		diff = s.uiRuntime.ThreadDiff(threadID)
+	}
+	manifest.MessageCount = len(messages)
+	manifest.TimelineCount = len(timeline)
+	manifest.HasRollout = len(rollout) > 0
+	manifest.HasDiff = diff != ""
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	entries := []struct {
+		name  string
+		value any
+		raw   []byte
+	}{
+		{name: threadBundleManifest, value: manifest},
+		{name: threadBundleRollout, raw: rollout},
+		{name: threadBundleMessages, value: messages},
+		{name: threadBundleTimeline, value: timeline},
+		{name: threadBundleDiff, raw: []byte(diff)},
+	}
+	for _, entry := range entries {
+		data := entry.raw
+		if entry.value != nil {
+			if data, err = json.MarshalIndent(entry.value, "", "  "); err != nil {
+				return nil, apperrors.Wrapf(err, "Server.threadExport", "encode %s", entry.name)
+			}
+		}
+		if len(data) == 0 {
+			continue
+		}
+		w, err := zw.Create(entry.name)
+		if err != nil {
+			return nil, apperrors.Wrapf(err, "Server.threadExport", "create %s", entry.name)
+		}
+		if _, err := w.Write(data); err != nil {
+			return nil, apperrors.Wrapf(err, "Server.threadExport", "write %s", entry.name)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, apperrors.Wrap(err, "Server.threadExport", "finalize bundle")
+	}
+
+	target := strings.TrimSpace(p.Path)
+	if target == "" {
+		target = filepath.Join(os.TempDir(), "multi-agent-bundles", fmt.Sprintf("%s-%d%s", sanitizeArchiveName(threadID), time.Now().Unix(), threadBundleExt))
+	}
+	target, err = filepath.Abs(target)
+	if err != nil {
+		return nil, apperrors.Wrap(err, "Server.threadExport", "resolve path")
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return nil, apperrors.Wrap(err, "Server.threadExport", "create bundle dir")
+	}
+	if err := os.WriteFile(target, buf.Bytes(), 0o600); err != nil {
+		return nil, apperrors.Wrap(err, "Server.threadExport", "write bundle")
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	logger.Info("thread/export: bundle written",
+		logger.FieldThreadID, threadID,
+		logger.FieldPath, target,
+		logger.FieldBytes, buf.Len(),
+		"messages", manifest.MessageCount,
+		"has_rollout", manifest.HasRollout,
+	)
+	return map[string]any{
+		"threadId": threadID,
+		"path":     target,
+		"bytes":    buf.Len(),
+		"sha256":   hex.EncodeToString(sum[:]),
+		"manifest": manifest,
+	}, nil
+}
+
+// ========================================
+// thread/import
+// ========================================
+
+// threadImportParams thread/import 请求参数。
+type threadImportParams struct {
+	Path string `json:"path"`
+	Name string `json:"name,omitempty"` // 覆盖会话包中的线程名称
+	Cwd  string `json:"cwd,omitempty"`  // 本机工作目录 (源机器路径通常不可用)
+}
+
+// threadBundle 解包后的会话包。
+type threadBundle struct {
+	Manifest threadBundleManifestData
+	Rollout  []byte
+	Messages []threadHistoryMessage
+	Timeline []uistate.TimelineItem
+	Diff     string
+}
+
+// readThreadBundle 读取并校验会话包。
+func readThreadBundle(path string) (threadBundle, error) {
+	var bundle threadBundle
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return bundle, apperrors.WrapCode(err, "readThreadBundle", errcode.InvalidInput, "open bundle")
+	}
+	defer zr.Close()
+
+	files := make(map[string][]byte, len(zr.File))
+	for _, file := range zr.File {
+		switch file.Name {
+		case threadBundleManifest, threadBundleRollout, threadBundleMessages, threadBundleTimeline, threadBundleDiff:
+		default:
+			continue // 未知条目 (新版本扩展) 忽略
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return bundle, apperrors.Wrapf(err, "readThreadBundle", "open %s", file.Name)
+		}
+		data, err := io.ReadAll(io.LimitReader(rc, maxThreadBundleEntry+1))
+		rc.Close()
+		if err != nil {
+			return bundle, apperrors.Wrapf(err, "readThreadBundle", "read %s", file.Name)
+		}
+		if len(data) > maxThreadBundleEntry {
+			return bundle, apperrors.NewCodef("readThreadBundle", errcode.InvalidInput, "%s exceeds %d bytes", file.Name, maxThreadBundleEntry)
+		}
+		files[file.Name] = data
+	}
+
+	raw, ok := files[threadBundleManifest]
+	if !ok {
+		return bundle, apperrors.NewCode("readThreadBundle", errcode.InvalidInput, "bundle has no manifest.json")
+	}
+	if err := json.Unmarshal(raw, &bundle.Manifest); err != nil {
+		return bundle, apperrors.WrapCode(err, "readThreadBundle", errcode.InvalidInput, "decode manifest")
+	}
+	if bundle.Manifest.Format != threadBundleFormat {
+		return bundle, apperrors.NewCodef("readThreadBundle", errcode.InvalidInput, "unsupported bundle format %q", bundle.Manifest.Format)
+	}
+	if bundle.Manifest.Version < 1 || bundle.Manifest.Version > threadBundleVersion {
+		return bundle, apperrors.NewCodef("readThreadBundle", errcode.InvalidInput, "unsupported bundle version %d (max %d)", bundle.Manifest.Version, threadBundleVersion)
+	}
+	if raw := files[threadBundleMessages]; len(raw) > 0 {
+		if err := json.Unmarshal(raw, &bundle.Messages); err != nil {
+			return bundle, apperrors.WrapCode(err, "readThreadBundle", errcode.InvalidInput, "decode messages")
+		}
+	}
+	if raw := files[threadBundleTimeline]; len(raw) > 0 {
+		if err := json.Unmarshal(raw, &bundle.Timeline); err != nil {
+			return bundle, apperrors.WrapCode(err, "readThreadBundle", errcode.InvalidInput, "decode timeline")
+		}
+	}
+	bundle.Rollout = files[threadBundleRollout]
+	bundle.Diff = string(files[threadBundleDiff])
+	return bundle, nil
+}
+
+// newCodexThreadID 生成随机 UUIDv4 作为导入线程的 codex 线程 ID。
+func newCodexThreadID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	h := hex.EncodeToString(b[:])
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32], nil
+}
+
+// installImportedRollout 将 rollout 以新 codex 线程 ID 写入本机 codex sessions 目录。
+func installImportedRollout(rollout []byte, oldCodexID, newCodexID string, now time.Time) (string, error) {
+	codexRoot, err := resolveCodexRootDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(codexRoot, "sessions", now.Format("2006"), now.Format("01"), now.Format("02"))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", apperrors.Wrap(err, "installImportedRollout", "create sessions dir")
+	}
+	if oldCodexID != "" {
+		rollout = bytes.ReplaceAll(rollout, []byte(oldCodexID), []byte(newCodexID))
+	}
+	path := filepath.Join(dir, fmt.Sprintf("rollout-%s-%s.jsonl", now.Format("2006-01-02T15-04-05"), newCodexID))
+	if err := os.WriteFile(path, rollout, 0o600); err != nil {
+		return "", apperrors.Wrap(err, "installImportedRollout", "write rollout")
+	}
+	return path, nil
+}
+
+func (s *Server) threadImportTyped(ctx context.Context, p threadImportParams) (any, error) {
+	path := strings.TrimSpace(p.Path)
+	if path == "" {
+		return nil, apperrors.New("Server.threadImport", "path is required")
+	}
+	if err := s.frozenError("Server.threadImport"); err != nil {
+		return nil, err
+	}
+	bundle, err := readThreadBundle(path)
+	if err != nil {
+		return nil, err
+	}
+	manifest := bundle.Manifest
+
+	now := time.Now()
+	threadID := fmt.Sprintf("thread-%d-%d", now.UnixMilli(), s.threadSeq.Add(1))
+	name := strings.TrimSpace(p.Name)
+	if name == "" {
+		name = manifest.Name
+	}
+
+	// 1. rollout → 本机 codex sessions + 绑定, 之后可 thread/resume
+	var codexThreadID, rolloutPath string
+	if len(bundle.Rollout) > 0 {
+		if codexThreadID, err = newCodexThreadID(); err != nil {
+			return nil, apperrors.Wrap(err, "Server.threadImport", "generate codex thread id")
+		}
+		if rolloutPath, err = installImportedRollout(bundle.Rollout, normalizeCodexThreadID(manifest.CodexThreadID), codexThreadID, now); err != nil {
+			return nil, apperrors.Wrap(err, "Server.threadImport", "install rollout")
+		}
+		if err := s.persistDurable(ctx, walOp{Kind: walKindBinding, Binding: &walBinding{AgentID: threadID, CodexThreadID: codexThreadID, RolloutPath: rolloutPath}}); err != nil {
+			logger.Warn("thread/import: register binding failed",
+				logger.FieldThreadID, threadID,
+				"codex_thread_id", codexThreadID,
+				logger.FieldError, err,
+			)
+		}
+	}
+
+	// 2. timeline / diff: 优先使用快照, 缺失时由消息重建
+	timeline := bundle.Timeline
+	if s.uiRuntime != nil {
+		if len(timeline) == 0 && len(bundle.Messages) > 0 {
+			s.uiRuntime.HydrateHistory(threadID, msgsToRecords(bundle.Messages))
+			timeline = s.uiRuntime.ThreadTimeline(threadID)
+		}
+		s.uiRuntime.RestoreThread(threadID, name, timeline, bundle.Diff)
+		if name != "" {
+			s.uiRuntime.SetThreadName(threadID, name)
+		}
+	}
+	if name != "" {
+		if err := s.persistDurable(ctx, walOp{Kind: walKindAlias, Alias: &walAlias{ThreadID: threadID, Alias: name}}); err != nil {
+			logger.Warn("thread/import: persist alias failed", logger.FieldThreadID, threadID, logger.FieldError, err)
+		}
+	}
+
+	// 3. 技能 / 工作目录 / 元数据
+	if len(manifest.Skills) > 0 {
+		s.skillsMu.Lock()
+		s.agentSkills[threadID] = append([]string(nil), manifest.Skills...)
+		s.skillsMu.Unlock()
+	}
+	cwd := strings.TrimSpace(p.Cwd)
+	if cwd == "" {
+		if info, err := os.Stat(manifest.Cwd); err == nil && info.IsDir() {
+			cwd = manifest.Cwd
+		}
+	}
+	if cwd != "" {
+		s.setAgentWorkDir(threadID, cwd)
+	}
+	meta := map[string]*string{}
+	for key, value := range manifest.Metadata {
+		if validateThreadMetaEntry(key, &value) == nil {
+			meta[key] = &value
+		}
+	}
+	source := manifest.ThreadID
+	meta[threadBundleImportMeta] = &source
+	if _, err := s.threadMetaSetTyped(ctx, threadMetaSetParams{ThreadID: threadID, Meta: meta}); err != nil {
+		logger.Warn("thread/import: persist metadata failed", logger.FieldThreadID, threadID, logger.FieldError, err)
+	}
+
+	logger.Info("thread/import: bundle restored",
+		logger.FieldThreadID, threadID,
+		logger.FieldPath, path,
+		"source_thread_id", manifest.ThreadID,
+		"codex_thread_id", codexThreadID,
+		"messages", len(bundle.Messages),
+		"timeline", len(bundle.Timeline),
+	)
+	return map[string]any{
+		"thread":         threadInfo{ID: threadID, Status: "idle"},
+		"name":           name,
+		"cwd":            cwd,
+		"sourceThreadId": manifest.ThreadID,
+		"codexThreadId":  codexThreadID,
+		"rolloutPath":    rolloutPath,
+		"resumable":      rolloutPath != "",
+		"messageCount":   len(bundle.Messages),
+		"timelineCount":  len(timeline),
+	}, nil
+}
@@ -0,0 +1,132 @@
+package apiserver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/uistate"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+func writeTestRollout(t *testing.T, home, codexThreadID string) string {
+	t.Helper()
+	now := time.Now()
+	path := filepath.Join(home, ".codex", "sessions", now.Format("2006"), now.Format("01"), now.Format("02"),
+		"rollout-"+now.Format("2006-01-02T15-04-05")+"-"+codexThreadID+".jsonl")
+	lines := []string{
+		`{"timestamp":"2026-03-01T10:00:00Z","type":"session_meta","payload":{"id":"` + codexThreadID + `","cwd":"/src/project"}}`,
+		`{"timestamp":"2026-03-01T10:00:01Z","type":"response_item","payload":{"type":"message","role":"user","content":[{"type":"input_text","text":"fix the build"}]}}`,
+		`{"timestamp":"2026-03-01T10:00:05Z","type":"response_item","payload":{"type":"message","role":"assistant","content":[{"type":"output_text","text":"build fixed"}]}}`,
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir rollout dir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatalf("write rollout: %v", err)
+	}
+	return path
+}
+
+func TestThreadExportImportRoundTrip(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	codexThreadID := "22222222-2222-4222-8222-222222222222"
+	writeTestRollout(t, home, codexThreadID)
+
+	srv := &Server{
+		uiRuntime:   uistate.NewRuntimeManager(),
+		prefManager: uistate.NewPreferenceManager(nil),
+		agentSkills: map[string][]string{codexThreadID: {"go-review"}},
+	}
+	ctx := context.Background()
+	srv.uiRuntime.RestoreThread(codexThreadID, "build fixer", nil, "diff --git a/x b/x\n")
+	if _, err := srv.threadMetaSetTyped(ctx, threadMetaSetParams{ThreadID: codexThreadID, Meta: map[string]*string{"ticket": ptrString("OPS-42")}}); err != nil {
+		t.Fatalf("seed metadata: %v", err)
+	}
+
+	bundlePath := filepath.Join(t.TempDir(), "handoff.maost")
+	exported, err := srv.threadExportTyped(ctx, threadExportParams{ThreadID: codexThreadID, Path: bundlePath})
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	manifest := exported.(map[string]any)["manifest"].(threadBundleManifestData)
+	if !manifest.HasRollout || manifest.MessageCount != 2 || manifest.Name != "build fixer" || manifest.CodexThreadID != codexThreadID {
+		t.Fatalf("manifest = %+v", manifest)
+	}
+
+	imported, err := srv.threadImportTyped(ctx, threadImportParams{Path: bundlePath, Cwd: home})
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	resp := imported.(map[string]any)
+	newID := resp["thread"].(threadInfo).ID
+	newCodexID := resp["codexThreadId"].(string)
+	if newID == codexThreadID || newCodexID == codexThreadID || normalizeCodexThreadID(newCodexID) == "" {
+		t.Fatalf("import must allocate new ids: thread=%s codex=%s", newID, newCodexID)
+	}
+
+	rollout, err := os.ReadFile(resp["rolloutPath"].(string))
+	if err != nil {
+		t.Fatalf("read imported rollout: %v", err)
+	}
+	if strings.Contains(string(rollout), codexThreadID) || !strings.Contains(string(rollout), newCodexID) {
+		t.Fatalf("imported rollout ids not rewritten: %s", rollout)
+	}
+	if got := srv.uiRuntime.ThreadDiff(newID); got != "diff --git a/x b/x\n" {
+		t.Fatalf("diff = %q", got)
+	}
+	if got := srv.threadDisplayName(newID); got != "build fixer" {
+		t.Fatalf("name = %q", got)
+	}
+	if got := srv.GetAgentSkills(newID); len(got) != 1 || got[0] != "go-review" {
+		t.Fatalf("skills = %v", got)
+	}
+	if got := srv.getAgentWorkDir(newID); got != home {
+		t.Fatalf("cwd = %q", got)
+	}
+	meta := srv.loadThreadMetadata(ctx)[newID]
+	if meta["ticket"] != "OPS-42" || meta[threadBundleImportMeta] != codexThreadID {
+		t.Fatalf("metadata = %v", meta)
+	}
+}
+
+func TestThreadImportHydratesTimelineFromMessages(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	codexThreadID := "33333333-3333-4333-8333-333333333333"
+	writeTestRollout(t, home, codexThreadID)
+
+	// 导出端无 UI runtime: 会话包只含消息, 导入端由消息重建 timeline。
+	exporter := &Server{agentSkills: map[string][]string{}}
+	bundlePath := filepath.Join(t.TempDir(), "bare.maost")
+	if _, err := exporter.threadExportTyped(context.Background(), threadExportParams{ThreadID: codexThreadID, Path: bundlePath}); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	importer := &Server{uiRuntime: uistate.NewRuntimeManager(), agentSkills: map[string][]string{}}
+	imported, err := importer.threadImportTyped(context.Background(), threadImportParams{Path: bundlePath})
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	newID := imported.(map[string]any)["thread"].(threadInfo).ID
+	if n := len(importer.uiRuntime.ThreadTimeline(newID)); n == 0 {
+		t.Fatal("timeline should be hydrated from bundled messages")
+	}
+}
+
+func TestReadThreadBundleRejectsInvalidFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "not-a-bundle.maost")
+	if err := os.WriteFile(path, []byte("plain text"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := readThreadBundle(path); apperrors.CodeOf(err) != errcode.InvalidInput {
+		t.Fatalf("readThreadBundle err = %v, want INVALID_INPUT", err)
+	}
+}
+
+func ptrString(v string) *string { return &v }
@@ -0,0 +1,556 @@
+// methods_thread_diff.go — thread/diff/get 与 thread/diff/export: 线程 diff 结构化与导出。
+//
+// 数据来源为 UI 运行时的 DiffTextByThread (codex turn/diff/updated 聚合的统一 diff 文本):
+//   - get    → 按文件拆分 hunk, 统计增删行数, 附渲染提示 (语言 / 二进制 / 重命名), 可选并排 (sideBySide) 行对齐
+//   - export → 规整为带 diff --git 头的 .patch 文件, 可直接 git apply
+package apiserver
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+const (
+	diffFormatUnified    = "unified"
+	diffFormatSideBySide = "sideBySide"
+
+	diffLineContext = "context"
+	diffLineAdd     = "add"
+	diffLineDelete  = "delete"
+
+	diffFileAdded    = "added"
+	diffFileDeleted  = "deleted"
+	diffFileModified = "modified"
+	diffFileRenamed  = "renamed"
+
+	diffLargeFileLines = 2000 // 超过该行数的文件提示前端默认折叠
+)
+
+var diffHunkHeaderPattern = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@ ?(.*)$`)
+
+// diffLine hunk 内单行。
+type diffLine struct {
+	Type      string `json:"type"` // context / add / delete
+	Text      string `json:"text"`
+	OldLine   int    `json:"oldLine,omitempty"`
+	NewLine   int    `json:"newLine,omitempty"`
+	NoNewline bool   `json:"noNewline,omitempty"` // 后随 "\ No newline at end of file"
+}
+
+// diffSideCell 并排视图的一侧。
+type diffSideCell struct {
+	Line int    `json:"line"`
+	Text string `json:"text"`
+	Type string `json:"type"`
+}
+
+// diffSideRow 并排视图的一行 (一侧可为空: 纯新增 / 纯删除)。
+type diffSideRow struct {
+	Left  *diffSideCell `json:"left,omitempty"`
+	Right *diffSideCell `json:"right,omitempty"`
+}
+
+// diffHunk 单个 hunk。
+type diffHunk struct {
+	OldStart int           `json:"oldStart"`
+	OldLines int           `json:"oldLines"`
+	NewStart int           `json:"newStart"`
+	NewLines int           `json:"newLines"`
+	Section  string        `json:"section,omitempty"` // @@ 之后的函数 / 段落上下文
+	Lines    []diffLine    `json:"lines,omitempty"`
+	Rows     []diffSideRow `json:"rows,omitempty"` // format=sideBySide 时填充
+}
+
+// diffStats 增删行统计。
+type diffStats struct {
+	Files   int `json:"files,omitempty"`
+	Added   int `json:"added"`
+	Removed int `json:"removed"`
+}
+
+// diffRenderHints 前端渲染提示。
+type diffRenderHints struct {
+	Language  string `json:"language,omitempty"`
+	Binary    bool   `json:"binary,omitempty"`
+	Collapsed bool   `json:"collapsed,omitempty"` // 大文件 / 纯删除文件默认折叠
+}
+
+// diffFile 单个文件的变更。
+type diffFile struct {
+	Path    string          `json:"path"`
+	OldPath string          `json:"oldPath,omitempty"`
+	NewPath string          `json:"newPath,omitempty"`
+	Status  string          `json:"status"`
+	OldMode string          `json:"oldMode,omitempty"`
+	NewMode string          `json:"newMode,omitempty"`
+	Binary  bool            `json:"binary,omitempty"`
+	Hunks   []diffHunk      `json:"hunks"`
+	Stats   diffStats       `json:"stats"`
+	Hints   diffRenderHints `json:"hints"`
+}
+
+// stripDiffPathPrefix 去掉 a/ b/ 前缀与时间戳后缀; /dev/null 返回空。
+func stripDiffPathPrefix(raw string) string {
+	path := strings.TrimSpace(raw)
+	if tab := strings.IndexByte(path, '\t'); tab >= 0 {
+		path = path[:tab]
+	}
+	if path == "/dev/null" {
+		return ""
+	}
+	if unquoted, err := strconv.Unquote(path); err == nil {
+		path = unquoted
+	}
+	if strings.HasPrefix(path, "a/") || strings.HasPrefix(path, "b/") {
+		return path[2:]
+	}
+	return path
+}
+
+// parseUnifiedDiff 解析统一 diff 文本 (兼容有无 diff --git 头)。
+func parseUnifiedDiff(text string) []diffFile {
+	var (
+		files   []diffFile
+		file    *diffFile
+		hunk    *diffHunk
+		oldLeft int
+		newLeft int
+		oldNo   int
+		newNo   int
+	)
+	flushHunk := func() {
+		if file != nil && hunk != nil {
+			file.Hunks = append(file.Hunks, *hunk)
+		}
+		hunk = nil
+		oldLeft, newLeft = 0, 0
+	}
+	flushFile := func() {
+		flushHunk()
+		if file != nil {
+			files = append(files, *file)
+		}
+		file = nil
+	}
+	startFile := func() {
+		flushFile()
+		file = &diffFile{Hunks: []diffHunk{}}
+	}
+
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		inHunk := hunk != nil && (oldLeft > 0 || newLeft > 0)
+		if inHunk {
+			switch {
+			case strings.HasPrefix(line, "+"):
+				newNo++
+				newLeft--
+				hunk.Lines = append(hunk.Lines, diffLine{Type: diffLineAdd, Text: line[1:], NewLine: newNo})
+				continue
+			case strings.HasPrefix(line, "-"):
+				oldNo++
+				oldLeft--
+				hunk.Lines = append(hunk.Lines, diffLine{Type: diffLineDelete, Text: line[1:], OldLine: oldNo})
+				continue
+			case strings.HasPrefix(line, " ") || (line == "" && i < len(lines)-1):
+				oldNo++
+				newNo++
+				oldLeft--
+				newLeft--
+				hunk.Lines = append(hunk.Lines, diffLine{Type: diffLineContext, Text: strings.TrimPrefix(line, " "), OldLine: oldNo, NewLine: newNo})
+				continue
+			}
+		}
+		switch {
+		case strings.HasPrefix(line, `\ `):
+			if hunk != nil && len(hunk.Lines) > 0 {
+				hunk.Lines[len(hunk.Lines)-1].NoNewline = true
+			}
+		case strings.HasPrefix(line, "diff --git "):
+			startFile()
+			if parts := strings.SplitN(strings.TrimPrefix(line, "diff --git "), " b/", 2); len(parts) == 2 {
+				file.OldPath = stripDiffPathPrefix(parts[0])
+				file.NewPath = parts[1]
+			}
+		case strings.HasPrefix(line, "--- "):
+			if file == nil || hunk != nil || file.Binary {
+				startFile()
+			}
+			file.OldPath = stripDiffPathPrefix(line[4:])
+			if file.OldPath == "" {
+				file.Status = diffFileAdded
+			}
+		case strings.HasPrefix(line, "+++ ") && file != nil:
+			file.NewPath = stripDiffPathPrefix(line[4:])
+			if file.NewPath == "" {
+				file.Status = diffFileDeleted
+			}
+		case strings.HasPrefix(line, "@@ "):
+			match := diffHunkHeaderPattern.FindStringSubmatch(line)
+			if match == nil || file == nil {
+				continue
+			}
+			flushHunk()
+			atoi := func(value, fallback string) int {
+				if value == "" {
+					value = fallback
+				}
+				n, _ := strconv.Atoi(value)
+				return n
+			}
+			hunk = &diffHunk{
+				OldStart: atoi(match[1], "0"),
+				OldLines: atoi(match[2], "1"),
+				NewStart: atoi(match[3], "0"),
+				NewLines: atoi(match[4], "1"),
+				Section:  strings.TrimSpace(match[5]),
+			}
+			oldLeft, newLeft = hunk.OldLines, hunk.NewLines
+			oldNo, newNo = hunk.OldStart-1, hunk.NewStart-1
+			if hunk.OldLines == 0 {
+				oldNo = hunk.OldStart
+			}
+			if hunk.NewLines == 0 {
+				newNo = hunk.NewStart
+			}
+		case file == nil:
+		case strings.HasPrefix(line, "new file mode "):
+			file.Status = diffFileAdded
+			file.NewMode = strings.TrimPrefix(line, "new file mode ")
+		case strings.HasPrefix(line, "deleted file mode "):
+			file.Status = diffFileDeleted
+			file.OldMode = strings.TrimPrefix(line, "deleted file mode ")
+		case strings.HasPrefix(line, "old mode "):
+			file.OldMode = strings.TrimPrefix(line, "old mode ")
+		case strings.HasPrefix(line, "new mode "):
+			file.NewMode = strings.TrimPrefix(line, "new mode ")
+		case strings.HasPrefix(line, "rename from "):
+			file.Status = diffFileRenamed
+			file.OldPath = strings.TrimPrefix(line, "rename from ")
+		case strings.HasPrefix(line, "rename to "):
+			file.Status = diffFileRenamed
+			file.NewPath = strings.TrimPrefix(line, "rename to ")
+		case strings.HasPrefix(line, "Binary files ") || line == "GIT binary patch":
+			file.Binary = true
+		}
+	}
+	flushFile()
+
+	for i := range files {
+		finalizeDiffFile(&files[i])
+	}
+	return files
+}
+
+// finalizeDiffFile 补全路径 / 状态 / 统计 / 渲染提示。
+func finalizeDiffFile(file *diffFile) {
+	switch file.Status {
+	case diffFileAdded:
+		file.OldPath = ""
+		file.Path = file.NewPath
+	case diffFileDeleted:
+		file.NewPath = ""
+		file.Path = file.OldPath
+	default:
+		if file.Status == "" {
+			file.Status = diffFileModified
+			if file.OldPath != "" && file.NewPath != "" && file.OldPath != file.NewPath {
+				file.Status = diffFileRenamed
+			}
+		}
+		file.Path = file.NewPath
+		if file.Path == "" {
+			file.Path = file.OldPath
+		}
+	}
+	total := 0
+	for _, hunk := range file.Hunks {
+		for _, line := range hunk.Lines {
+			switch line.Type {
+			case diffLineAdd:
+				file.Stats.Added++
+			case diffLineDelete:
+				file.Stats.Removed++
+			}
+		}
+		total += len(hunk.Lines)
+	}
+	file.Hints = diffRenderHints{
+		Language:  diffLanguageForPath(file.Path),
+		Binary:    file.Binary,
+		Collapsed: file.Binary || file.Status == diffFileDeleted || total > diffLargeFileLines,
+	}
+}
+
+// diffLanguageForPath 按扩展名推断语法高亮语言 (未知返回空)。
+func diffLanguageForPath(path string) string {
+	base := strings.ToLower(filepath.Base(path))
+	switch base {
+	case "dockerfile":
+		return "dockerfile"
+	case "makefile":
+		return "makefile"
+	case "go.mod", "go.sum":
+		return "go-module"
+	}
+	languages := map[string]string{
+		".go": "go", ".js": "javascript", ".mjs": "javascript", ".ts": "typescript", ".tsx": "tsx", ".jsx": "jsx",
+		".vue": "vue", ".py": "python", ".rs": "rust", ".java": "java", ".kt": "kotlin", ".swift": "swift",
+		".c": "c", ".h": "c", ".cc": "cpp", ".cpp": "cpp", ".hpp": "cpp", ".cs": "csharp", ".rb": "ruby",
+		".php": "php", ".sh": "shell", ".bash": "shell", ".sql": "sql", ".json": "json", ".yaml": "yaml",
+		".yml": "yaml", ".toml": "toml", ".md": "markdown", ".html": "html", ".css": "css", ".scss": "scss",
+		".xml": "xml", ".proto": "protobuf",
+	}
+	return languages[filepath.Ext(base)]
+}
+
+// buildSideBySideRows 将 hunk 行对齐为并排视图: 连续的删除块与新增块逐行配对。
+func buildSideBySideRows(lines []diffLine) []diffSideRow {
+	rows := make([]diffSideRow, 0, len(lines))
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		if line.Type == diffLineContext {
+			rows = append(rows, diffSideRow{
+				Left:  &diffSideCell{Line: line.OldLine, Text: line.Text, Type: diffLineContext},
+				Right: &diffSideCell{Line: line.NewLine, Text: line.Text, Type: diffLineContext},
+			})
+			i++
+			continue
+		}
+		var deleted, added []diffLine
+		for i < len(lines) && lines[i].Type == diffLineDelete {
+			deleted = append(deleted, lines[i])
+			i++
+		}
+		for i < len(lines) && lines[i].Type == diffLineAdd {
+			added = append(added, lines[i])
+			i++
+		}
+		for j := 0; j < len(deleted) || j < len(added); j++ {
+			var row diffSideRow
+			if j < len(deleted) {
+				row.Left = &diffSideCell{Line: deleted[j].OldLine, Text: deleted[j].Text, Type: diffLineDelete}
+			}
+			if j < len(added) {
+				row.Right = &diffSideCell{Line: added[j].NewLine, Text: added[j].Text, Type: diffLineAdd}
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// renderGitPatch 将结构化 diff 渲染为 git apply 可用的 patch 文本。
+// 二进制文件没有可还原的内容, 仅保留 Binary files 提示行 (git apply 会拒绝该文件)。
+func renderGitPatch(files []diffFile) string {
+	var b strings.Builder
+	for _, file := range files {
+		oldPath, newPath := file.OldPath, file.NewPath
+		if oldPath == "" {
+			oldPath = newPath
+		}
+		if newPath == "" {
+			newPath = oldPath
+		}
+		fmt.Fprintf(&b, "diff --git a/%s b/%s\n", oldPath, newPath)
+		switch file.Status {
+		case diffFileAdded:
+			fmt.Fprintf(&b, "new file mode %s\n", firstNonEmpty(file.NewMode, "100644"))
+		case diffFileDeleted:
+			fmt.Fprintf(&b, "deleted file mode %s\n", firstNonEmpty(file.OldMode, "100644"))
+		default:
+			if file.OldMode != "" && file.NewMode != "" && file.OldMode != file.NewMode {
+				fmt.Fprintf(&b, "old mode %s\nnew mode %s\n", file.OldMode, file.NewMode)
+			}
+			if file.Status == diffFileRenamed {
+				fmt.Fprintf(&b, "rename from %s\nrename to %s\n", oldPath, newPath)
+			}
+		}
+		if file.Binary {
+			fmt.Fprintf(&b, "Binary files a/%s and b/%s differ\n", oldPath, newPath)
+			continue
+		}
+		if len(file.Hunks) == 0 {
+			continue
+		}
+		if file.Status == diffFileAdded {
+			b.WriteString("--- /dev/null\n")
+		} else {
+			fmt.Fprintf(&b, "--- a/%s\n", oldPath)
+		}
+		if file.Status == diffFileDeleted {
+			b.WriteString("+++ /dev/null\n")
+		} else {
+			fmt.Fprintf(&b, "+++ b/%s\n", newPath)
+		}
+		for _, hunk := range file.Hunks {
+			fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@", hunk.OldStart, hunk.OldLines, hunk.NewStart, hunk.NewLines)
+			if hunk.Section != "" {
+				b.WriteString(" " + hunk.Section)
+			}
+			b.WriteByte('\n')
+			for _, line := range hunk.Lines {
+				switch line.Type {
+				case diffLineAdd:
+					b.WriteByte('+')
+				case diffLineDelete:
+					b.WriteByte('-')
+				default:
+					b.WriteByte(' ')
+				}
+				b.WriteString(line.Text)
+				b.WriteByte('\n')
+				if line.NoNewline {
+					b.WriteString("\\ No newline at end of file\n")
+				}
+			}
+		}
+	}
+	return b.String()
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if strings.TrimSpace(value) != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// ========================================
+// JSON-RPC
+// ========================================
+
+// threadDiffGetParams thread/diff/get 请求参数。
+type threadDiffGetParams struct {
+	ThreadID string `json:"threadId"`
+	Format   string `json:"format,omitempty"` // unified(默认) / sideBySide
+	Path     string `json:"path,omitempty"`   // 仅返回指定文件
+}
+
+// threadDiffGetResponse thread/diff/get 响应。
+type threadDiffGetResponse struct {
+	ThreadID string     `json:"threadId"`
+	Format   string     `json:"format"`
+	Files    []diffFile `json:"files"`
+	Stats    diffStats  `json:"stats"`
+}
+
+func (s *Server) loadThreadDiffFiles(op, threadID string) ([]diffFile, error) {
+	id := strings.TrimSpace(threadID)
+	if id == "" {
+		return nil, apperrors.New(op, "threadId is required")
+	}
+	if s.uiRuntime == nil {
+		return nil, apperrors.New(op, "ui runtime not initialized")
+	}
+	return parseUnifiedDiff(s.uiRuntime.ThreadDiff(id)), nil
+}
+
+func (s *Server) threadDiffGetTyped(_ context.Context, p threadDiffGetParams) (any, error) {
+	format := strings.TrimSpace(p.Format)
+	switch format {
+	case "":
+		format = diffFormatUnified
+	case diffFormatUnified, diffFormatSideBySide:
+	default:
+		return nil, apperrors.Newf("Server.threadDiffGet", "unknown format %q (want unified / sideBySide)", p.Format)
+	}
+	files, err := s.loadThreadDiffFiles("Server.threadDiffGet", p.ThreadID)
+	if err != nil {
+		return nil, err
+	}
+	if path := strings.TrimSpace(p.Path); path != "" {
+		filtered := files[:0]
+		for _, file := range files {
+			if file.Path == path || file.OldPath == path {
+				filtered = append(filtered, file)
+			}
+		}
+		files = filtered
+	}
+	resp := threadDiffGetResponse{ThreadID: strings.TrimSpace(p.ThreadID), Format: format, Files: files}
+	for i := range resp.Files {
+		file := &resp.Files[i]
+		resp.Stats.Added += file.Stats.Added
+		resp.Stats.Removed += file.Stats.Removed
+		if format != diffFormatSideBySide {
+			continue
+		}
+		for j := range file.Hunks {
+			file.Hunks[j].Rows = buildSideBySideRows(file.Hunks[j].Lines)
+			file.Hunks[j].Lines = nil
+		}
+	}
+	resp.Stats.Files = len(resp.Files)
+	if resp.Files == nil {
+		resp.Files = []diffFile{}
+	}
+	return resp, nil
+}
+
+// threadDiffExportParams thread/diff/export 请求参数。
+type threadDiffExportParams struct {
+	ThreadID string `json:"threadId"`
+	Path     string `json:"path,omitempty"` // 目标 .patch 路径, 空 = 临时目录
+}
+
+func (s *Server) threadDiffExportTyped(_ context.Context, p threadDiffExportParams) (any, error) {
+	files, err := s.loadThreadDiffFiles("Server.threadDiffExport", p.ThreadID)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, apperrors.Newf("Server.threadDiffExport", "thread %s has no diff", p.ThreadID)
+	}
+	threadID := strings.TrimSpace(p.ThreadID)
+	target := strings.TrimSpace(p.Path)
+	if target == "" {
+		target = filepath.Join(os.TempDir(), "multi-agent-patches", fmt.Sprintf("%s-%d.patch", threadID, time.Now().Unix()))
+	}
+	target, err = filepath.Abs(target)
+	if err != nil {
+		return nil, apperrors.Wrap(err, "Server.threadDiffExport", "resolve path")
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return nil, apperrors.Wrap(err, "Server.threadDiffExport", "create patch dir")
+	}
+	patch := renderGitPatch(files)
+	if err := os.WriteFile(target, []byte(patch), 0o644); err != nil {
+		return nil, apperrors.Wrap(err, "Server.threadDiffExport", "write patch")
+	}
+	var stats diffStats
+	binary := 0
+	for _, file := range files {
+		stats.Added += file.Stats.Added
+		stats.Removed += file.Stats.Removed
+		if file.Binary {
+			binary++
+		}
+	}
+	stats.Files = len(files)
+	logger.Info("thread/diff/export: patch written",
+		logger.FieldThreadID, threadID,
+		logger.FieldPath, target,
+		logger.FieldBytes, len(patch),
+		"files", stats.Files,
+	)
+	return map[string]any{
+		"threadId":      threadID,
+		"path":          target,
+		"bytes":         len(patch),
+		"stats":         stats,
+		"binarySkipped": binary,
+	}, nil
+}
@@ -0,0 +1,93 @@
+package apiserver
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/multi-agent/go-agent-v2/internal/uistate"
+)
+
+const sampleThreadDiff = `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -1,4 +1,4 @@ package main
+ package main
+ 
+-func old() {}
++func renamed() {}
+ // end
+\ No newline at end of file
+--- /dev/null
++++ b/docs/NEW.md
+@@ -0,0 +1,2 @@
++# New
++-- not a header
+`
+
+func TestParseUnifiedDiff(t *testing.T) {
+	files := parseUnifiedDiff(sampleThreadDiff)
+	if len(files) != 2 {
+		t.Fatalf("files = %+v", files)
+	}
+	main := files[0]
+	if main.Path != "main.go" || main.Status != diffFileModified || main.Hints.Language != "go" {
+		t.Fatalf("main = %+v", main)
+	}
+	if main.Stats.Added != 1 || main.Stats.Removed != 1 || len(main.Hunks) != 1 || main.Hunks[0].Section != "package main" {
+		t.Fatalf("main stats/hunks = %+v %+v", main.Stats, main.Hunks)
+	}
+	lines := main.Hunks[0].Lines
+	if len(lines) != 5 || lines[1].Text != "" || lines[3].NewLine != 3 || !lines[4].NoNewline {
+		t.Fatalf("lines = %+v", lines)
+	}
+	added := files[1]
+	if added.Path != "docs/NEW.md" || added.Status != diffFileAdded || added.Stats.Added != 2 || added.Hunks[0].Lines[1].Text != "-- not a header" {
+		t.Fatalf("added = %+v", added)
+	}
+
+	rows := buildSideBySideRows(lines)
+	if len(rows) != 4 || rows[2].Left.Text != "func old() {}" || rows[2].Right.Text != "func renamed() {}" {
+		t.Fatalf("rows = %+v", rows)
+	}
+}
+
+func TestThreadDiffGetAndExport(t *testing.T) {
+	rt := uistate.NewRuntimeManager()
+	rt.ApplyAgentEvent("thread-1", uistate.NormalizedEvent{UIType: uistate.UITypeDiffUpdate}, map[string]any{"diff": sampleThreadDiff})
+	srv := &Server{uiRuntime: rt}
+	ctx := context.Background()
+
+	resp, err := srv.threadDiffGetTyped(ctx, threadDiffGetParams{ThreadID: "thread-1", Format: diffFormatSideBySide, Path: "main.go"})
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	got := resp.(threadDiffGetResponse)
+	if got.Stats != (diffStats{Files: 1, Added: 1, Removed: 1}) || got.Files[0].Hunks[0].Lines != nil || len(got.Files[0].Hunks[0].Rows) != 4 {
+		t.Fatalf("get = %+v", got)
+	}
+	if _, err := srv.threadDiffGetTyped(ctx, threadDiffGetParams{ThreadID: "thread-1", Format: "split"}); err == nil {
+		t.Fatal("unknown format should fail")
+	}
+
+	repo := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repo, "main.go"), []byte("package main\n\nfunc old() {}\n// end"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(t.TempDir(), "out", "thread-1.patch")
+	if _, err := srv.threadDiffExportTyped(ctx, threadDiffExportParams{ThreadID: "thread-1", Path: target}); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	cmd := exec.Command("git", "apply", "--check", target)
+	cmd.Dir = repo
+	if out, err := cmd.CombinedOutput(); err != nil {
+		patch, _ := os.ReadFile(target)
+		t.Fatalf("git apply --check: %v\n%s\n%s", err, out, patch)
+	}
+}
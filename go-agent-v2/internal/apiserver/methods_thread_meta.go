@@ -0,0 +1,166 @@
+// methods_thread_meta.go — 线程级自定义元数据 (工单 URL / 客户 ID / git 分支等外部引用)。
+//
+// 元数据持久化在偏好 threads.metadata (threadId → key → value), 由 thread/list 与
+// thread/loaded/list 一并返回, 外部系统据此将编排线程与自身实体关联。
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+const (
+	prefThreadMetadata = "threads.metadata"
+
+	maxThreadMetaKeys       = 64
+	maxThreadMetaKeyRunes   = 128
+	maxThreadMetaValueRunes = 2048
+)
+
+var threadMetaKeyPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._:/-]*$`)
+
+// threadMetaSetParams thread/meta/set 请求参数。
+//
+// meta 中值为 null 或空串表示删除该键; replace=true 时以 meta 整体替换原有元数据。
+type threadMetaSetParams struct {
+	ThreadID string             `json:"threadId"`
+	Meta     map[string]*string `json:"meta"`
+	Replace  bool               `json:"replace,omitempty"`
+}
+
+func (s *Server) threadMetaSetTyped(ctx context.Context, p threadMetaSetParams) (any, error) {
+	threadID := strings.TrimSpace(p.ThreadID)
+	if threadID == "" {
+		return nil, apperrors.New("Server.threadMetaSet", "threadId is required")
+	}
+	if len(p.Meta) == 0 && !p.Replace {
+		return nil, apperrors.New("Server.threadMetaSet", "meta is required")
+	}
+	for key, value := range p.Meta {
+		if err := validateThreadMetaEntry(key, value); err != nil {
+			return nil, err
+		}
+	}
+	if !s.threadExistsForArchive(ctx, threadID) {
+		return nil, apperrors.NewCodef("Server.threadMetaSet", errcode.ThreadNotFound, "thread %s not found", threadID)
+	}
+
+	s.threadMetaMu.Lock()
+	all := s.loadThreadMetadata(ctx)
+	current := all[threadID]
+	if p.Replace || current == nil {
+		current = map[string]string{}
+	}
+	for key, value := range p.Meta {
+		if value == nil || strings.TrimSpace(*value) == "" {
+			delete(current, key)
+			continue
+		}
+		current[key] = strings.TrimSpace(*value)
+	}
+	if len(current) > maxThreadMetaKeys {
+		s.threadMetaMu.Unlock()
+		return nil, apperrors.Newf("Server.threadMetaSet", "too many metadata keys: %d (limit %d)", len(current), maxThreadMetaKeys)
+	}
+	if len(current) == 0 {
+		delete(all, threadID)
+	} else {
+		all[threadID] = current
+	}
+	var err error
+	if s.prefManager != nil {
+		err = s.prefManager.Set(ctx, prefThreadMetadata, all)
+	}
+	s.threadMetaMu.Unlock()
+	if err != nil {
+		logger.Warn("thread/meta/set: persist metadata failed", logger.FieldThreadID, threadID, logger.FieldError, err)
+		return nil, apperrors.Wrap(err, "Server.threadMetaSet", "persist thread metadata")
+	}
+
+	result := map[string]any{"threadId": threadID, "meta": current}
+	s.Notify("thread/meta/updated", result)
+	return result, nil
+}
+
+func validateThreadMetaEntry(key string, value *string) error {
+	if !threadMetaKeyPattern.MatchString(key) || utf8.RuneCountInString(key) > maxThreadMetaKeyRunes {
+		return apperrors.Newf("Server.threadMetaSet", "invalid metadata key %q", key)
+	}
+	if value != nil && utf8.RuneCountInString(*value) > maxThreadMetaValueRunes {
+		return apperrors.Newf("Server.threadMetaSet", "metadata value for %q too long (limit %d)", key, maxThreadMetaValueRunes)
+	}
+	return nil
+}
+
+func (s *Server) loadThreadMetadata(ctx context.Context) map[string]map[string]string {
+	if s.prefManager == nil {
+		return map[string]map[string]string{}
+	}
+	value, err := s.prefManager.Get(ctx, prefThreadMetadata)
+	if err != nil {
+		logger.Warn("thread metadata: load preference failed", logger.FieldError, err)
+		return map[string]map[string]string{}
+	}
+	return normalizeThreadMetadata(value)
+}
+
+// normalizeThreadMetadata 兼容偏好存储返回的 map / JSON 字符串形态。
+func normalizeThreadMetadata(value any) map[string]map[string]string {
+	out := map[string]map[string]string{}
+	var decoded map[string]any
+	switch typed := value.(type) {
+	case map[string]map[string]string:
+		for threadID, meta := range typed {
+			if len(meta) == 0 {
+				continue
+			}
+			copied := make(map[string]string, len(meta))
+			for key, value := range meta {
+				copied[key] = value
+			}
+			out[threadID] = copied
+		}
+		return out
+	case map[string]any:
+		decoded = typed
+	case string:
+		_ = json.Unmarshal([]byte(strings.TrimSpace(typed)), &decoded)
+	case json.RawMessage:
+		_ = json.Unmarshal(typed, &decoded)
+	}
+	for rawID, rawMeta := range decoded {
+		threadID := strings.TrimSpace(rawID)
+		entries, ok := rawMeta.(map[string]any)
+		if threadID == "" || !ok {
+			continue
+		}
+		meta := make(map[string]string, len(entries))
+		for key, rawValue := range entries {
+			if text := strings.TrimSpace(asString(rawValue)); text != "" {
+				meta[key] = text
+			}
+		}
+		if len(meta) > 0 {
+			out[threadID] = meta
+		}
+	}
+	return out
+}
+
+func applyThreadMetadata(threads []threadListItem, metadata map[string]map[string]string) {
+	if len(threads) == 0 || len(metadata) == 0 {
+		return
+	}
+	for i := range threads {
+		if meta := metadata[strings.TrimSpace(threads[i].ID)]; len(meta) > 0 {
+			threads[i].Meta = meta
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package apiserver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/multi-agent/go-agent-v2/internal/uistate"
+)
+
+func TestThreadMetaSetMergesAndAppearsInList(t *testing.T) {
+	srv := &Server{prefManager: uistate.NewPreferenceManager(nil)}
+	ctx := context.Background()
+	threadID := "019c3b4e-6d7a-7f10-9a2b-3c4d5e6f7a8b"
+	str := func(v string) *string { return &v }
+
+	if _, err := srv.threadMetaSetTyped(ctx, threadMetaSetParams{ThreadID: "missing", Meta: map[string]*string{"ticket": str("x")}}); err == nil {
+		t.Fatal("expected unknown thread error")
+	}
+	if _, err := srv.threadMetaSetTyped(ctx, threadMetaSetParams{ThreadID: threadID, Meta: map[string]*string{"bad key": str("x")}}); err == nil {
+		t.Fatal("expected invalid key error")
+	}
+	if _, err := srv.threadMetaSetTyped(ctx, threadMetaSetParams{ThreadID: threadID, Meta: map[string]*string{
+		"ticketUrl":  str("https://tracker/ISSUE-1"),
+		"customerId": str("c-42"),
+	}}); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if _, err := srv.threadMetaSetTyped(ctx, threadMetaSetParams{ThreadID: threadID, Meta: map[string]*string{
+		"customerId": nil,
+		"git.branch": str("feature/x"),
+	}}); err != nil {
+		t.Fatalf("merge: %v", err)
+	}
+
+	threads := []threadListItem{{ID: threadID, Name: "worker"}, {ID: "other"}}
+	applyThreadMetadata(threads, srv.loadThreadMetadata(ctx))
+	meta := threads[0].Meta
+	if len(meta) != 2 || meta["ticketUrl"] != "https://tracker/ISSUE-1" || meta["git.branch"] != "feature/x" {
+		t.Fatalf("meta = %+v, want ticketUrl + git.branch", meta)
+	}
+	if threads[1].Meta != nil {
+		t.Fatalf("other thread meta = %+v, want nil", threads[1].Meta)
+	}
+
+	if _, err := srv.threadMetaSetTyped(ctx, threadMetaSetParams{ThreadID: threadID, Replace: true}); err != nil {
+		t.Fatalf("replace with empty: %v", err)
+	}
+	if len(srv.loadThreadMetadata(ctx)) != 0 {
+		t.Fatal("replace with empty meta should clear thread metadata")
+	}
+}
@@ -0,0 +1,132 @@
+// methods_thread_state_at.go — thread/stateAt: 按时间点重建线程 timeline/diff/状态 (事后复盘)。
+//
+// 数据来源为 codex rollout 文件:
+//   - 消息 → 独立 RuntimeManager 重放, 得到该时刻的 timeline
+//   - apply_patch 调用 → 按时间累积, 得到该时刻的 diff
+//
+// 重建在隔离的 RuntimeManager 中进行, 不影响实时 UI 状态。
+package apiserver
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/codex"
+	"github.com/multi-agent/go-agent-v2/internal/uistate"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+// threadStateAtParams thread/stateAt 请求参数。
+type threadStateAtParams struct {
+	ThreadID  string `json:"threadId"`
+	Timestamp string `json:"timestamp"` // RFC3339
+}
+
+// threadStateAtResponse thread/stateAt 响应。
+type threadStateAtResponse struct {
+	ThreadID     string                 `json:"threadId"`
+	Timestamp    string                 `json:"timestamp"`
+	Status       string                 `json:"status"` // empty / running / idle
+	Timeline     []uistate.TimelineItem `json:"timeline"`
+	Diff         string                 `json:"diff"`
+	Patches      []codex.RolloutPatch   `json:"patches"`
+	MessageCount int                    `json:"messageCount"`
+	TotalCount   int                    `json:"totalCount"`
+	LastEventAt  string                 `json:"lastEventAt,omitempty"`
+}
+
+func (s *Server) threadStateAtTyped(ctx context.Context, p threadStateAtParams) (any, error) {
+	threadID := strings.TrimSpace(p.ThreadID)
+	if threadID == "" {
+		return nil, apperrors.New("Server.threadStateAt", "threadId is required")
+	}
+	at := parseRolloutTimestamp(p.Timestamp)
+	if at.IsZero() {
+		return nil, apperrors.Newf("Server.threadStateAt", "invalid timestamp %q (want RFC3339)", p.Timestamp)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	allMsgs, err := s.loadAllThreadMessagesFromCodexRollout(ctx, threadID)
+	if err != nil {
+		return nil, apperrors.Wrap(err, "Server.threadStateAt", "load codex rollout messages")
+	}
+	var patches []codex.RolloutPatch
+	if path := s.resolveRolloutFilePath(ctx, threadID); path != "" {
+		patches, err = codex.ReadRolloutPatches(path)
+		if err != nil {
+			return nil, apperrors.Wrap(err, "Server.threadStateAt", "load codex rollout patches")
+		}
+	}
+
+	resp := reconstructThreadStateAt(threadID, at, allMsgs, patches)
+	logger.Info("thread/stateAt: reconstructed",
+		logger.FieldAgentID, threadID, logger.FieldThreadID, threadID,
+		"at", resp.Timestamp,
+		"message_count", resp.MessageCount,
+		"total_count", resp.TotalCount,
+		"patch_count", len(resp.Patches),
+		logger.FieldStatus, resp.Status,
+	)
+	return resp, nil
+}
+
+// reconstructThreadStateAt 截取 at 之前 (含) 的消息与补丁, 在隔离 RuntimeManager 中重放。
+//
+// 时间戳缺失的记录无法定位, 一律排除。
+func reconstructThreadStateAt(threadID string, at time.Time, msgs []threadHistoryMessage, patches []codex.RolloutPatch) threadStateAtResponse {
+	resp := threadStateAtResponse{
+		ThreadID:   threadID,
+		Timestamp:  at.UTC().Format(time.RFC3339Nano),
+		Status:     "empty",
+		Timeline:   []uistate.TimelineItem{},
+		Patches:    []codex.RolloutPatch{},
+		TotalCount: len(msgs),
+	}
+
+	visible := make([]threadHistoryMessage, 0, len(msgs))
+	var lastAt time.Time
+	for _, msg := range msgs {
+		if msg.CreatedAt.IsZero() || msg.CreatedAt.After(at) {
+			continue
+		}
+		visible = append(visible, msg)
+		if msg.CreatedAt.After(lastAt) {
+			lastAt = msg.CreatedAt
+		}
+	}
+	resp.MessageCount = len(visible)
+	if len(visible) > 0 {
+		if strings.EqualFold(visible[len(visible)-1].Role, "user") {
+			resp.Status = "running"
+		} else {
+			resp.Status = "idle"
+		}
+		runtime := uistate.NewRuntimeManager()
+		runtime.HydrateHistory(threadID, msgsToRecords(visible))
+		if timeline := runtime.ThreadTimeline(threadID); timeline != nil {
+			resp.Timeline = timeline
+		}
+	}
+
+	diffs := make([]string, 0, len(patches))
+	for _, patch := range patches {
+		ts := parseRolloutTimestamp(patch.Timestamp)
+		if ts.IsZero() || ts.After(at) {
+			continue
+		}
+		resp.Patches = append(resp.Patches, patch)
+		diffs = append(diffs, strings.TrimRight(patch.Patch, "\n"))
+		if ts.After(lastAt) {
+			lastAt = ts
+		}
+	}
+	resp.Diff = strings.Join(diffs, "\n")
+	if !lastAt.IsZero() {
+		resp.LastEventAt = lastAt.UTC().Format(time.RFC3339Nano)
+	}
+	return resp
+}
@@ -0,0 +1,55 @@
+package apiserver
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/codex"
+)
+
+func TestReconstructThreadStateAtCutsOffLaterEvents(t *testing.T) {
+	base := time.Date(2026, 2, 20, 1, 0, 0, 0, time.UTC)
+	msgs := []threadHistoryMessage{
+		{ID: 1, Role: "user", Content: "fix bug", CreatedAt: base},
+		{ID: 2, Role: "assistant", EventType: codex.EventAgentMessage, Content: "done", CreatedAt: base.Add(2 * time.Second)},
+		{ID: 3, Role: "user", Content: "later", CreatedAt: base.Add(10 * time.Second)},
+		{ID: 4, Role: "assistant", Content: "no time"},
+	}
+	patches := []codex.RolloutPatch{
+		{CallID: "c1", Patch: "*** Begin Patch\n*** Add File: a.go\n*** End Patch\n", Timestamp: base.Add(time.Second).Format(time.RFC3339)},
+		{CallID: "c2", Patch: "*** Begin Patch\n*** Delete File: b.go\n*** End Patch", Timestamp: base.Add(20 * time.Second).Format(time.RFC3339)},
+	}
+
+	got := reconstructThreadStateAt("t1", base.Add(time.Second), msgs, patches)
+	if got.Status != "running" || got.MessageCount != 1 || got.TotalCount != 4 {
+		t.Fatalf("state at +1s = %+v, want running with 1/4 messages", got)
+	}
+	if len(got.Patches) != 1 || !strings.Contains(got.Diff, "a.go") || strings.Contains(got.Diff, "b.go") {
+		t.Fatalf("diff at +1s = %q", got.Diff)
+	}
+
+	got = reconstructThreadStateAt("t1", base.Add(5*time.Second), msgs, patches)
+	if got.Status != "idle" || got.MessageCount != 2 {
+		t.Fatalf("state at +5s = %+v, want idle with 2 messages", got)
+	}
+	if len(got.Timeline) == 0 {
+		t.Fatal("timeline at +5s is empty")
+	}
+
+	got = reconstructThreadStateAt("t1", base.Add(-time.Second), msgs, patches)
+	if got.Status != "empty" || len(got.Timeline) != 0 || got.Diff != "" {
+		t.Fatalf("state before first event = %+v, want empty", got)
+	}
+}
+
+func TestThreadStateAtRejectsInvalidTimestamp(t *testing.T) {
+	srv := &Server{}
+	if _, err := srv.threadStateAtTyped(context.Background(), threadStateAtParams{ThreadID: "t1", Timestamp: "yesterday"}); err == nil {
+		t.Fatal("expected invalid timestamp error")
+	}
+	if _, err := srv.threadStateAtTyped(context.Background(), threadStateAtParams{Timestamp: "2026-02-20T01:00:00Z"}); err == nil {
+		t.Fatal("expected missing threadId error")
+	}
+}
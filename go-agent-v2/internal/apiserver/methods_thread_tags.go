@@ -0,0 +1,380 @@
+// methods_thread_tags.go — 线程标签与保存的筛选条件 (侧边栏按项目 / 优先级 / 客户组织历史线程)。
+//
+// 标签持久化在偏好 threads.tags (threadId → [tag]), 由 thread/list 与 thread/loaded/list 一并返回;
+// thread/list 可按标签 (全部匹配 / 任一匹配)、状态、名称筛选, 也可引用偏好 threads.savedFilters
+// 中保存的筛选条件 (name → filter)。标签约定 "维度:值" (如 project:web, priority:high), 比较不区分大小写。
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+const (
+	prefThreadTags         = "threads.tags"
+	prefThreadSavedFilters = "threads.savedFilters"
+
+	maxThreadTags          = 32
+	maxThreadTagRunes      = 64
+	maxThreadSavedFilters  = 64
+	maxThreadFilterNameLen = 64
+)
+
+// ========================================
+// 标签
+// ========================================
+
+// normalizeThreadTag 去除首尾空白; 空串或含控制字符 / 逗号时返回错误。
+func normalizeThreadTag(tag string) (string, error) {
+	tag = strings.TrimSpace(tag)
+	if tag == "" {
+		return "", apperrors.NewCode("Server.threadTagsSet", errcode.InvalidInput, "tag must not be empty")
+	}
+	if utf8.RuneCountInString(tag) > maxThreadTagRunes {
+		return "", apperrors.NewCodef("Server.threadTagsSet", errcode.InvalidInput, "tag %q too long (limit %d)", tag, maxThreadTagRunes)
+	}
+	if strings.ContainsFunc(tag, func(r rune) bool { return r == ',' || unicode.IsControl(r) }) {
+		return "", apperrors.NewCodef("Server.threadTagsSet", errcode.InvalidInput, "invalid tag %q", tag)
+	}
+	return tag, nil
+}
+
+// hasThreadTag 不区分大小写判断标签是否存在。
+func hasThreadTag(tags []string, tag string) bool {
+	return slices.ContainsFunc(tags, func(t string) bool { return strings.EqualFold(t, tag) })
+}
+
+// threadTagsSetParams thread/tags/set 请求参数。
+//
+// tags 非 null 时整体替换; add / remove 在此基础上增删 (remove 优先)。
+type threadTagsSetParams struct {
+	ThreadID string    `json:"threadId"`
+	Tags     *[]string `json:"tags,omitempty"`
+	Add      []string  `json:"add,omitempty"`
+	Remove   []string  `json:"remove,omitempty"`
+}
+
+func (s *Server) threadTagsSetTyped(ctx context.Context, p threadTagsSetParams) (any, error) {
+	const op = "Server.threadTagsSet"
+	threadID := strings.TrimSpace(p.ThreadID)
+	if threadID == "" {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "threadId is required")
+	}
+	if p.Tags == nil && len(p.Add) == 0 && len(p.Remove) == 0 {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "tags, add or remove is required")
+	}
+	if !s.threadExistsForArchive(ctx, threadID) {
+		return nil, apperrors.NewCodef(op, errcode.ThreadNotFound, "thread %s not found", threadID)
+	}
+
+	remove := make([]string, 0, len(p.Remove))
+	for _, tag := range p.Remove {
+		remove = append(remove, strings.TrimSpace(tag))
+	}
+
+	s.threadMetaMu.Lock()
+	all := s.loadThreadTags(ctx)
+	current := all[threadID]
+	if p.Tags != nil {
+		current = nil
+	}
+	var additions []string
+	if p.Tags != nil {
+		additions = append(additions, *p.Tags...)
+	}
+	additions = append(additions, p.Add...)
+	next := make([]string, 0, len(current)+len(additions))
+	for _, tag := range append(slices.Clone(current), additions...) {
+		normalized, err := normalizeThreadTag(tag)
+		if err != nil {
+			s.threadMetaMu.Unlock()
+			return nil, err
+		}
+		if !hasThreadTag(next, normalized) && !hasThreadTag(remove, normalized) {
+			next = append(next, normalized)
+		}
+	}
+	if len(next) > maxThreadTags {
+		s.threadMetaMu.Unlock()
+		return nil, apperrors.NewCodef(op, errcode.InvalidInput, "too many tags: %d (limit %d)", len(next), maxThreadTags)
+	}
+	sort.Strings(next)
+	if len(next) == 0 {
+		delete(all, threadID)
+	} else {
+		all[threadID] = next
+	}
+	var err error
+	if s.prefManager != nil {
+		err = s.prefManager.Set(ctx, prefThreadTags, all)
+	}
+	s.threadMetaMu.Unlock()
+	if err != nil {
+		logger.Warn("thread/tags/set: persist tags failed", logger.FieldThreadID, threadID, logger.FieldError, err)
+		return nil, apperrors.Wrap(err, op, "persist thread tags")
+	}
+
+	result := map[string]any{"threadId": threadID, "tags": next}
+	s.Notify("thread/tags/updated", result)
+	return result, nil
+}
+
+func (s *Server) loadThreadTags(ctx context.Context) map[string][]string {
+	out := map[string][]string{}
+	if s.prefManager == nil {
+		return out
+	}
+	value, err := s.prefManager.Get(ctx, prefThreadTags)
+	if err != nil {
+		logger.Warn("thread tags: load preference failed", logger.FieldError, err)
+		return out
+	}
+	if value == nil {
+		return out
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return out
+	}
+	if err := json.Unmarshal(raw, &out); err != nil || out == nil {
+		return map[string][]string{}
+	}
+	return out
+}
+
+func applyThreadTags(threads []threadListItem, tags map[string][]string) {
+	if len(threads) == 0 || len(tags) == 0 {
+		return
+	}
+	for i := range threads {
+		if list := tags[strings.TrimSpace(threads[i].ID)]; len(list) > 0 {
+			threads[i].Tags = list
+		}
+	}
+}
+
+// threadTagsList 全部标签及使用次数 (JSON-RPC: thread/tags/list)。
+func (s *Server) threadTagsList(ctx context.Context, _ json.RawMessage) (any, error) {
+	counts := map[string]int{}
+	display := map[string]string{}
+	for _, tags := range s.loadThreadTags(ctx) {
+		for _, tag := range tags {
+			key := strings.ToLower(tag)
+			if _, ok := display[key]; !ok {
+				display[key] = tag
+			}
+			counts[key]++
+		}
+	}
+	type tagCount struct {
+		Tag   string `json:"tag"`
+		Count int    `json:"count"`
+	}
+	out := make([]tagCount, 0, len(counts))
+	for key, n := range counts {
+		out = append(out, tagCount{Tag: display[key], Count: n})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Tag < out[j].Tag })
+	return map[string]any{"tags": out}, nil
+}
+
+// ========================================
+// 筛选
+// ========================================
+
+// threadListFilter thread/list 筛选条件 (各条件取交集, 空条件不过滤)。
+type threadListFilter struct {
+	Tags     []string `json:"tags,omitempty"`     // 须包含全部标签
+	AnyTags  []string `json:"anyTags,omitempty"`  // 至少包含其一
+	States   []string `json:"states,omitempty"`   // 线程状态 (running / idle / ...)
+	Query    string   `json:"query,omitempty"`    // 名称 / ID 子串 (不区分大小写)
+	Untagged bool     `json:"untagged,omitempty"` // 只看未打标签的线程
+}
+
+func (f threadListFilter) empty() bool {
+	return len(f.Tags) == 0 && len(f.AnyTags) == 0 && len(f.States) == 0 && strings.TrimSpace(f.Query) == "" && !f.Untagged
+}
+
+func (f threadListFilter) match(item threadListItem) bool {
+	if f.Untagged && len(item.Tags) > 0 {
+		return false
+	}
+	for _, tag := range f.Tags {
+		if !hasThreadTag(item.Tags, strings.TrimSpace(tag)) {
+			return false
+		}
+	}
+	if len(f.AnyTags) > 0 && !slices.ContainsFunc(f.AnyTags, func(tag string) bool { return hasThreadTag(item.Tags, strings.TrimSpace(tag)) }) {
+		return false
+	}
+	if len(f.States) > 0 && !slices.ContainsFunc(f.States, func(state string) bool { return strings.EqualFold(strings.TrimSpace(state), item.State) }) {
+		return false
+	}
+	if query := strings.ToLower(strings.TrimSpace(f.Query)); query != "" {
+		if !strings.Contains(strings.ToLower(item.Name), query) && !strings.Contains(strings.ToLower(item.ID), query) {
+			return false
+		}
+	}
+	return true
+}
+
+func filterThreadList(threads []threadListItem, f threadListFilter) []threadListItem {
+	if f.empty() {
+		return threads
+	}
+	out := make([]threadListItem, 0, len(threads))
+	for _, item := range threads {
+		if f.match(item) {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// threadListParams thread/list 请求参数 (filter 为已保存筛选条件名, 与内联条件合并)。
+type threadListParams struct {
+	threadListFilter
+	Filter string `json:"filter,omitempty"`
+}
+
+// resolveThreadListFilter 合并已保存筛选条件与内联条件。
+func (s *Server) resolveThreadListFilter(ctx context.Context, p threadListParams) (threadListFilter, error) {
+	name := strings.TrimSpace(p.Filter)
+	if name == "" {
+		return p.threadListFilter, nil
+	}
+	saved, ok := s.loadThreadSavedFilters(ctx)[name]
+	if !ok {
+		return threadListFilter{}, apperrors.NewCodef("Server.threadList", errcode.NotFound, "saved filter %q not found", name)
+	}
+	merged := saved
+	merged.Tags = append(slices.Clone(saved.Tags), p.Tags...)
+	merged.AnyTags = append(slices.Clone(saved.AnyTags), p.AnyTags...)
+	merged.States = append(slices.Clone(saved.States), p.States...)
+	if strings.TrimSpace(p.Query) != "" {
+		merged.Query = p.Query
+	}
+	merged.Untagged = saved.Untagged || p.Untagged
+	return merged, nil
+}
+
+// ========================================
+// 保存的筛选条件
+// ========================================
+
+func (s *Server) loadThreadSavedFilters(ctx context.Context) map[string]threadListFilter {
+	out := map[string]threadListFilter{}
+	if s.prefManager == nil {
+		return out
+	}
+	value, err := s.prefManager.Get(ctx, prefThreadSavedFilters)
+	if err != nil {
+		logger.Warn("thread filters: load preference failed", logger.FieldError, err)
+		return out
+	}
+	if value == nil {
+		return out
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return out
+	}
+	if err := json.Unmarshal(raw, &out); err != nil || out == nil {
+		return map[string]threadListFilter{}
+	}
+	return out
+}
+
+type threadSavedFilterView struct {
+	Name string `json:"name"`
+	threadListFilter
+}
+
+func savedFilterViews(filters map[string]threadListFilter) []threadSavedFilterView {
+	out := make([]threadSavedFilterView, 0, len(filters))
+	for name, f := range filters {
+		out = append(out, threadSavedFilterView{Name: name, threadListFilter: f})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// threadFiltersList 已保存的筛选条件 (JSON-RPC: thread/filters/list)。
+func (s *Server) threadFiltersList(ctx context.Context, _ json.RawMessage) (any, error) {
+	return map[string]any{"filters": savedFilterViews(s.loadThreadSavedFilters(ctx))}, nil
+}
+
+type threadFilterSaveParams struct {
+	Name   string           `json:"name"`
+	Filter threadListFilter `json:"filter"`
+}
+
+func (s *Server) threadFilterSaveTyped(ctx context.Context, p threadFilterSaveParams) (any, error) {
+	const op = "Server.threadFilterSave"
+	if s.prefManager == nil {
+		return nil, apperrors.New(op, "preference manager not initialized")
+	}
+	name := strings.TrimSpace(p.Name)
+	if name == "" || utf8.RuneCountInString(name) > maxThreadFilterNameLen {
+		return nil, apperrors.NewCodef(op, errcode.InvalidInput, "name is required (max %d chars)", maxThreadFilterNameLen)
+	}
+	if p.Filter.empty() {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "filter must have at least one condition")
+	}
+	for _, tag := range append(slices.Clone(p.Filter.Tags), p.Filter.AnyTags...) {
+		if _, err := normalizeThreadTag(tag); err != nil {
+			return nil, err
+		}
+	}
+
+	s.threadMetaMu.Lock()
+	defer s.threadMetaMu.Unlock()
+	filters := s.loadThreadSavedFilters(ctx)
+	if _, exists := filters[name]; !exists && len(filters) >= maxThreadSavedFilters {
+		return nil, apperrors.NewCodef(op, errcode.InvalidInput, "too many saved filters (limit %d)", maxThreadSavedFilters)
+	}
+	filters[name] = p.Filter
+	if err := s.prefManager.Set(ctx, prefThreadSavedFilters, filters); err != nil {
+		return nil, apperrors.Wrap(err, op, "persist saved filters")
+	}
+	logger.Info("thread/filters/save: saved", logger.FieldName, name)
+	views := savedFilterViews(filters)
+	s.Notify("thread/filters/updated", map[string]any{"filters": views})
+	return map[string]any{"name": name, "filters": views}, nil
+}
+
+type threadFilterDeleteParams struct {
+	Name string `json:"name"`
+}
+
+func (s *Server) threadFilterDeleteTyped(ctx context.Context, p threadFilterDeleteParams) (any, error) {
+	const op = "Server.threadFilterDelete"
+	if s.prefManager == nil {
+		return nil, apperrors.New(op, "preference manager not initialized")
+	}
+	name := strings.TrimSpace(p.Name)
+
+	s.threadMetaMu.Lock()
+	defer s.threadMetaMu.Unlock()
+	filters := s.loadThreadSavedFilters(ctx)
+	if _, ok := filters[name]; !ok {
+		return nil, apperrors.NewCodef(op, errcode.NotFound, "saved filter %q not found", name)
+	}
+	delete(filters, name)
+	if err := s.prefManager.Set(ctx, prefThreadSavedFilters, filters); err != nil {
+		return nil, apperrors.Wrap(err, op, "persist saved filters")
+	}
+	logger.Info("thread/filters/delete: deleted", logger.FieldName, name)
+	views := savedFilterViews(filters)
+	s.Notify("thread/filters/updated", map[string]any{"filters": views})
+	return map[string]any{"name": name, "filters": views}, nil
+}
@@ -0,0 +1,123 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/multi-agent/go-agent-v2/internal/uistate"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+func TestThreadTagsSetMergesAndFilters(t *testing.T) {
+	srv := &Server{prefManager: uistate.NewPreferenceManager(nil)}
+	ctx := context.Background()
+	web := "019c3b4e-6d7a-7f10-9a2b-3c4d5e6f7a8b"
+	api := "019c3b4e-6d7a-7f10-9a2b-3c4d5e6f7a8c"
+	tags := func(v ...string) *[]string { return &v }
+
+	if _, err := srv.threadTagsSetTyped(ctx, threadTagsSetParams{ThreadID: "missing", Add: []string{"x"}}); apperrors.CodeOf(err) != errcode.ThreadNotFound {
+		t.Fatalf("unknown thread err = %v", err)
+	}
+	if _, err := srv.threadTagsSetTyped(ctx, threadTagsSetParams{ThreadID: web, Add: []string{"a,b"}}); apperrors.CodeOf(err) != errcode.InvalidInput {
+		t.Fatalf("comma tag err = %v", err)
+	}
+	if _, err := srv.threadTagsSetTyped(ctx, threadTagsSetParams{ThreadID: web, Tags: tags("project:web", "priority:high", " Project:Web ")}); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	res, err := srv.threadTagsSetTyped(ctx, threadTagsSetParams{ThreadID: web, Add: []string{"customer:acme"}, Remove: []string{"PRIORITY:HIGH"}})
+	if err != nil {
+		t.Fatalf("merge: %v", err)
+	}
+	if got := res.(map[string]any)["tags"].([]string); len(got) != 2 || got[0] != "customer:acme" || got[1] != "project:web" {
+		t.Fatalf("tags = %v", got)
+	}
+	if _, err := srv.threadTagsSetTyped(ctx, threadTagsSetParams{ThreadID: api, Add: []string{"project:api", "customer:acme"}}); err != nil {
+		t.Fatalf("set api: %v", err)
+	}
+
+	threads := []threadListItem{{ID: web, Name: "web-worker", State: "idle"}, {ID: api, Name: "api-worker", State: "running"}, {ID: "other", Name: "scratch"}}
+	applyThreadTags(threads, srv.loadThreadTags(ctx))
+	if threads[2].Tags != nil {
+		t.Fatalf("untagged thread tags = %v", threads[2].Tags)
+	}
+	cases := []struct {
+		filter threadListFilter
+		want   []string
+	}{
+		{threadListFilter{Tags: []string{"Customer:ACME"}}, []string{web, api}},
+		{threadListFilter{Tags: []string{"customer:acme", "project:web"}}, []string{web}},
+		{threadListFilter{AnyTags: []string{"project:api", "project:none"}}, []string{api}},
+		{threadListFilter{States: []string{"running"}}, []string{api}},
+		{threadListFilter{Untagged: true}, []string{"other"}},
+		{threadListFilter{Query: "WEB"}, []string{web}},
+		{threadListFilter{}, []string{web, api, "other"}},
+	}
+	for _, tc := range cases {
+		got := filterThreadList(threads, tc.filter)
+		ids := make([]string, 0, len(got))
+		for _, item := range got {
+			ids = append(ids, item.ID)
+		}
+		if len(ids) != len(tc.want) {
+			t.Fatalf("filter %+v = %v, want %v", tc.filter, ids, tc.want)
+		}
+		for i := range ids {
+			if ids[i] != tc.want[i] {
+				t.Fatalf("filter %+v = %v, want %v", tc.filter, ids, tc.want)
+			}
+		}
+	}
+
+	if _, err := srv.threadTagsSetTyped(ctx, threadTagsSetParams{ThreadID: api, Tags: tags()}); err != nil {
+		t.Fatalf("clear: %v", err)
+	}
+	if _, ok := srv.loadThreadTags(ctx)[api]; ok {
+		t.Fatal("empty replace should clear thread tags")
+	}
+}
+
+func TestThreadSavedFiltersAppliedByThreadList(t *testing.T) {
+	srv := &Server{prefManager: uistate.NewPreferenceManager(nil)}
+	ctx := context.Background()
+	threadID := "019c3b4e-6d7a-7f10-9a2b-3c4d5e6f7a8b"
+
+	if _, err := srv.threadFilterSaveTyped(ctx, threadFilterSaveParams{Name: "empty"}); apperrors.CodeOf(err) != errcode.InvalidInput {
+		t.Fatalf("empty filter err = %v", err)
+	}
+	if _, err := srv.threadFilterSaveTyped(ctx, threadFilterSaveParams{Name: "acme", Filter: threadListFilter{Tags: []string{"customer:acme"}}}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if _, err := srv.threadTagsSetTyped(ctx, threadTagsSetParams{ThreadID: threadID, Add: []string{"customer:acme"}}); err != nil {
+		t.Fatalf("tag: %v", err)
+	}
+	if err := srv.prefManager.Set(ctx, prefThreadAliases, map[string]string{threadID: "acme-worker"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.prefManager.Set(ctx, prefThreadArchivesChat, map[string]any{threadID: 1, "019c3b4e-6d7a-7f10-9a2b-3c4d5e6f7a8c": 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := srv.threadList(ctx, json.RawMessage(`{"filter":"acme"}`))
+	if err != nil {
+		t.Fatalf("thread/list filter: %v", err)
+	}
+	if threads := res.(threadListResponse).Threads; len(threads) != 1 || threads[0].ID != threadID || len(threads[0].Tags) != 1 {
+		t.Fatalf("filtered threads = %+v", threads)
+	}
+	res, err = srv.threadList(ctx, nil)
+	if err != nil || len(res.(threadListResponse).Threads) != 2 {
+		t.Fatalf("unfiltered = %+v, %v", res, err)
+	}
+	if _, err := srv.threadList(ctx, json.RawMessage(`{"filter":"nope"}`)); apperrors.CodeOf(err) != errcode.NotFound {
+		t.Fatalf("unknown filter err = %v", err)
+	}
+
+	if _, err := srv.threadFilterDeleteTyped(ctx, threadFilterDeleteParams{Name: "acme"}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := srv.threadFilterDeleteTyped(ctx, threadFilterDeleteParams{Name: "acme"}); apperrors.CodeOf(err) != errcode.NotFound {
+		t.Fatalf("repeat delete err = %v", err)
+	}
+}
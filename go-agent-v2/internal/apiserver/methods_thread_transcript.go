@@ -0,0 +1,387 @@
+// methods_thread_transcript.go — thread/transcript/export: 把线程 timeline 导出为 Markdown 或独立 HTML 文档。
+//
+// 面向人阅读 (附到工单 / PR 描述), 与 thread/export 的可导入会话包互补:
+//   - 用户消息与 agent 回复原样保留, 推理过程折叠 (<details>);
+//   - 命令连同退出码与输出 (输出折叠, 超长截断);
+//   - 末尾按文件列出线程累计 diff。
+package apiserver
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/multi-agent/go-agent-v2/internal/uistate"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+const (
+	transcriptFormatMarkdown = "markdown"
+	transcriptFormatHTML     = "html"
+
+	defaultTranscriptOutputChars = 4000
+	maxTranscriptOutputChars     = 200000
+)
+
+// threadTranscriptParams thread/transcript/export 请求参数。
+type threadTranscriptParams struct {
+	ThreadID         string `json:"threadId"`
+	Format           string `json:"format,omitempty"`           // markdown (默认) / html
+	Path             string `json:"path,omitempty"`             // 写入文件路径, 空 = 在响应中返回内容
+	IncludeReasoning *bool  `json:"includeReasoning,omitempty"` // 默认 true (折叠显示)
+	IncludeDiff      *bool  `json:"includeDiff,omitempty"`      // 默认 true
+	MaxOutputChars   int    `json:"maxOutputChars,omitempty"`   // 单条命令输出上限, 0 = 4000
+}
+
+// transcriptMeta 文档头信息。
+type transcriptMeta struct {
+	ThreadID   string
+	Name       string
+	Cwd        string
+	ExportedAt time.Time
+}
+
+// transcriptOptions 渲染选项。
+type transcriptOptions struct {
+	IncludeReasoning bool
+	MaxOutputChars   int
+}
+
+// transcriptSection 渲染无关的文档片段: 标题 + 正文 + 代码块 (可折叠)。
+type transcriptSection struct {
+	Heading     string
+	Body        string // 正文 (Markdown 原样输出, HTML 按预格式文本输出)
+	Code        string
+	CodeLang    string
+	Details     string // 折叠块内容 (空 = 无)
+	Summary     string // 折叠块标题
+	DetailsLang string // 折叠块代码语言, 空 = 按正文输出
+}
+
+func (s *Server) threadTranscriptExportTyped(ctx context.Context, p threadTranscriptParams) (any, error) {
+	const op = "Server.threadTranscriptExport"
+	threadID := strings.TrimSpace(p.ThreadID)
+	if threadID == "" {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "threadId is required")
+	}
+	format := strings.ToLower(strings.TrimSpace(p.Format))
+	switch format {
+	case "", "md", transcriptFormatMarkdown:
+		format = transcriptFormatMarkdown
+	case "htm", transcriptFormatHTML:
+		format = transcriptFormatHTML
+	default:
+		return nil, apperrors.NewCodef(op, errcode.InvalidInput, "unsupported format %q (markdown / html)", p.Format)
+	}
+	if p.MaxOutputChars < 0 || p.MaxOutputChars > maxTranscriptOutputChars {
+		return nil, apperrors.NewCodef(op, errcode.InvalidInput, "maxOutputChars must be within [0, %d]", maxTranscriptOutputChars)
+	}
+	if s.uiRuntime == nil || !s.threadExistsForArchive(ctx, threadID) {
+		return nil, apperrors.NewCodef(op, errcode.ThreadNotFound, "thread %s not found", threadID)
+	}
+
+	opts := transcriptOptions{IncludeReasoning: p.IncludeReasoning == nil || *p.IncludeReasoning, MaxOutputChars: p.MaxOutputChars}
+	if opts.MaxOutputChars == 0 {
+		opts.MaxOutputChars = defaultTranscriptOutputChars
+	}
+	timeline := s.uiRuntime.ThreadTimeline(threadID)
+	diff := ""
+	if p.IncludeDiff == nil || *p.IncludeDiff {
+		diff = s.uiRuntime.ThreadDiff(threadID)
+	}
+	meta := transcriptMeta{
+		ThreadID:   threadID,
+		Name:       s.threadDisplayName(threadID),
+		Cwd:        s.getAgentWorkDir(threadID),
+		ExportedAt: time.Now().UTC(),
+	}
+	content := renderThreadTranscript(meta, buildTranscriptSections(timeline, diff, opts), format)
+
+	resp := map[string]any{
+		"threadId": threadID,
+		"format":   format,
+		"items":    len(timeline),
+		"bytes":    len(content),
+	}
+	target := strings.TrimSpace(p.Path)
+	if target == "" {
+		resp["content"] = content
+		return resp, nil
+	}
+	target, err := filepath.Abs(target)
+	if err != nil {
+		return nil, apperrors.Wrap(err, op, "resolve path")
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return nil, apperrors.Wrap(err, op, "create transcript dir")
+	}
+	if err := os.WriteFile(target, []byte(content), 0o600); err != nil {
+		return nil, apperrors.Wrap(err, op, "write transcript")
+	}
+	logger.Info("thread/transcript/export: transcript written",
+		logger.FieldThreadID, threadID,
+		logger.FieldPath, target,
+		logger.FieldBytes, len(content),
+		"format", format,
+	)
+	resp["path"] = target
+	return resp, nil
+}
+
+// buildTranscriptSections 把 timeline 与累计 diff 转为文档片段。
+func buildTranscriptSections(timeline []uistate.TimelineItem, diff string, opts transcriptOptions) []transcriptSection {
+	sections := make([]transcriptSection, 0, len(timeline)+1)
+	for _, item := range timeline {
+		text := strings.TrimSpace(item.Text)
+		switch item.Kind {
+		case "user":
+			body := text
+			for _, att := range item.Attachments {
+				body += fmt.Sprintf("\n- 📎 %s `%s`", att.Kind, firstNonEmpty(att.Path, att.Name))
+			}
+			sections = append(sections, transcriptSection{Heading: "User", Body: strings.TrimSpace(body)})
+		case "assistant":
+			if text != "" {
+				sections = append(sections, transcriptSection{Heading: "Assistant", Body: text})
+			}
+		case "thinking":
+			if opts.IncludeReasoning && text != "" {
+				sections = append(sections, transcriptSection{Summary: "Reasoning", Details: text})
+			}
+		case "command":
+			sec := transcriptSection{Heading: "Command", Code: "$ " + item.Command, CodeLang: "sh"}
+			if output := strings.TrimRight(item.Output, "\n"); output != "" {
+				sec.Summary = "Output" + transcriptStatusSuffix(item)
+				sec.Details = truncateTranscriptText(output, opts.MaxOutputChars)
+				sec.DetailsLang = "text"
+			} else if suffix := transcriptStatusSuffix(item); suffix != "" {
+				sec.Body = "Result" + suffix
+			}
+			sections = append(sections, sec)
+		case "file":
+			sections = append(sections, transcriptSection{Body: fmt.Sprintf("✏️ `%s` (%s)", item.File, firstNonEmpty(item.Status, "edited"))})
+		case "tool":
+			line := fmt.Sprintf("🔧 `%s`", item.Tool)
+			if item.File != "" {
+				line += fmt.Sprintf(" on `%s`", item.File)
+			}
+			if item.Status != "" {
+				line += " — " + item.Status
+			}
+			if item.ElapsedMS != nil {
+				line += fmt.Sprintf(" (%d ms)", *item.ElapsedMS)
+			}
+			sections = append(sections, transcriptSection{Body: line})
+		case "approval":
+			sections = append(sections, transcriptSection{Heading: "Approval requested", Code: item.Command, CodeLang: "sh"})
+		case "plan":
+			sections = append(sections, transcriptSection{Heading: "Plan", Body: text})
+		case "error":
+			sections = append(sections, transcriptSection{Heading: "Error", Body: text})
+		default:
+			if text != "" {
+				sections = append(sections, transcriptSection{Heading: transcriptKindTitle(item.Kind), Body: text})
+			}
+		}
+	}
+	if files := splitTranscriptDiff(diff); len(files) > 0 {
+		sections = append(sections, transcriptSection{Heading: "File changes", Body: fmt.Sprintf("%d file(s) changed.", len(files))})
+		for _, f := range files {
+			sections = append(sections, transcriptSection{Summary: f.path, Details: f.patch, DetailsLang: "diff"})
+		}
+	}
+	return sections
+}
+
+func transcriptStatusSuffix(item uistate.TimelineItem) string {
+	switch {
+	case item.ExitCode != nil:
+		return fmt.Sprintf(" (exit %d)", *item.ExitCode)
+	case item.Status != "":
+		return " (" + item.Status + ")"
+	default:
+		return ""
+	}
+}
+
+func transcriptKindTitle(kind string) string {
+	if kind == "" {
+		return "Note"
+	}
+	r, size := utf8.DecodeRuneInString(kind)
+	return strings.ToUpper(string(r)) + kind[size:]
+}
+
+// truncateTranscriptText 按字符截断, 保留开头并注明省略量。
+func truncateTranscriptText(text string, limit int) string {
+	if limit <= 0 || utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:limit]) + fmt.Sprintf("\n… (%d more characters truncated)", len(runes)-limit)
+}
+
+type transcriptFileDiff struct {
+	path  string
+	patch string
+}
+
+// splitTranscriptDiff 按 "diff --git" 把累计 diff 拆成单文件补丁。
+func splitTranscriptDiff(diff string) []transcriptFileDiff {
+	diff = strings.TrimSpace(diff)
+	if diff == "" {
+		return nil
+	}
+	var files []transcriptFileDiff
+	for _, chunk := range strings.SplitAfter(diff, "\n") {
+		if strings.HasPrefix(chunk, "diff --git ") || len(files) == 0 {
+			path := strings.TrimSpace(strings.TrimPrefix(chunk, "diff --git "))
+			if fields := strings.Fields(path); len(fields) == 2 {
+				path = strings.TrimPrefix(fields[1], "b/")
+			}
+			files = append(files, transcriptFileDiff{path: path})
+		}
+		files[len(files)-1].patch += chunk
+	}
+	for i := range files {
+		files[i].patch = strings.TrimRight(files[i].patch, "\n")
+	}
+	return files
+}
+
+// renderThreadTranscript 按格式输出完整文档。
+func renderThreadTranscript(meta transcriptMeta, sections []transcriptSection, format string) string {
+	if format == transcriptFormatHTML {
+		return renderTranscriptHTML(meta, sections)
+	}
+	return renderTranscriptMarkdown(meta, sections)
+}
+
+func transcriptTitle(meta transcriptMeta) string {
+	return "Transcript: " + firstNonEmpty(meta.Name, meta.ThreadID)
+}
+
+func renderTranscriptMarkdown(meta transcriptMeta, sections []transcriptSection) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", transcriptTitle(meta))
+	fmt.Fprintf(&b, "- Thread: `%s`\n", meta.ThreadID)
+	if meta.Cwd != "" {
+		fmt.Fprintf(&b, "- Workspace: `%s`\n", meta.Cwd)
+	}
+	fmt.Fprintf(&b, "- Exported: %s\n", meta.ExportedAt.Format(time.RFC3339))
+	for _, sec := range sections {
+		b.WriteString("\n")
+		if sec.Heading != "" {
+			fmt.Fprintf(&b, "### %s\n\n", sec.Heading)
+		}
+		if sec.Body != "" {
+			b.WriteString(sec.Body + "\n\n")
+		}
+		if sec.Code != "" {
+			writeMarkdownFence(&b, sec.CodeLang, sec.Code)
+			b.WriteString("\n")
+		}
+		if sec.Details != "" {
+			// <summary> 后需空行, GitHub 才会把折叠内容按 Markdown 渲染。
+			fmt.Fprintf(&b, "<details>\n<summary>%s</summary>\n\n", html.EscapeString(sec.Summary))
+			if sec.DetailsLang != "" {
+				writeMarkdownFence(&b, sec.DetailsLang, sec.Details)
+			} else {
+				b.WriteString(sec.Details + "\n")
+			}
+			b.WriteString("\n</details>\n\n")
+		}
+	}
+	return strings.TrimRight(b.String(), "\n") + "\n"
+}
+
+// writeMarkdownFence 写围栏代码块, 围栏长度大于内容中最长的连续反引号。
+func writeMarkdownFence(b *strings.Builder, lang, code string) {
+	longest, run := 0, 0
+	for _, r := range code {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	fence := strings.Repeat("`", max(3, longest+1))
+	fmt.Fprintf(b, "%s%s\n%s\n%s\n", fence, lang, strings.TrimRight(code, "\n"), fence)
+}
+
+const transcriptHTMLStyle = `body{font-family:-apple-system,BlinkMacSystemFont,"Segoe UI",Helvetica,Arial,sans-serif;max-width:960px;margin:2rem auto;padding:0 1rem;color:#1f2328;line-height:1.5}
+h1{font-size:1.6rem;border-bottom:1px solid #d0d7de;padding-bottom:.3rem}
+h3{font-size:1.05rem;margin:1.4rem 0 .4rem}
+.meta{color:#59636e;font-size:.9rem;padding-left:1.2rem}
+.text{white-space:pre-wrap;word-wrap:break-word}
+pre{background:#f6f8fa;border-radius:6px;padding:.75rem;overflow:auto;font-size:.85rem}
+details{margin:.5rem 0}
+summary{cursor:pointer;color:#59636e}
+.add{color:#1a7f37}.del{color:#cf222e}.hunk{color:#8250df}`
+
+func renderTranscriptHTML(meta transcriptMeta, sections []transcriptSection) string {
+	var b strings.Builder
+	title := html.EscapeString(transcriptTitle(meta))
+	fmt.Fprintf(&b, "<!DOCTYPE html>\n<html lang=\"en\">\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n<style>\n%s\n</style>\n</head>\n<body>\n", title, transcriptHTMLStyle)
+	fmt.Fprintf(&b, "<h1>%s</h1>\n<ul class=\"meta\">\n<li>Thread: <code>%s</code></li>\n", title, html.EscapeString(meta.ThreadID))
+	if meta.Cwd != "" {
+		fmt.Fprintf(&b, "<li>Workspace: <code>%s</code></li>\n", html.EscapeString(meta.Cwd))
+	}
+	fmt.Fprintf(&b, "<li>Exported: %s</li>\n</ul>\n", meta.ExportedAt.Format(time.RFC3339))
+	for _, sec := range sections {
+		b.WriteString("<section>\n")
+		if sec.Heading != "" {
+			fmt.Fprintf(&b, "<h3>%s</h3>\n", html.EscapeString(sec.Heading))
+		}
+		if sec.Body != "" {
+			fmt.Fprintf(&b, "<div class=\"text\">%s</div>\n", html.EscapeString(sec.Body))
+		}
+		if sec.Code != "" {
+			fmt.Fprintf(&b, "<pre><code>%s</code></pre>\n", html.EscapeString(sec.Code))
+		}
+		if sec.Details != "" {
+			fmt.Fprintf(&b, "<details>\n<summary>%s</summary>\n", html.EscapeString(sec.Summary))
+			switch sec.DetailsLang {
+			case "diff":
+				fmt.Fprintf(&b, "<pre><code>%s</code></pre>\n", highlightTranscriptDiff(sec.Details))
+			case "":
+				fmt.Fprintf(&b, "<div class=\"text\">%s</div>\n", html.EscapeString(sec.Details))
+			default:
+				fmt.Fprintf(&b, "<pre><code>%s</code></pre>\n", html.EscapeString(sec.Details))
+			}
+			b.WriteString("</details>\n")
+		}
+		b.WriteString("</section>\n")
+	}
+	b.WriteString("</body>\n</html>\n")
+	return b.String()
+}
+
+// highlightTranscriptDiff 转义 diff 并按行着色 (+ / - / @@)。
+func highlightTranscriptDiff(patch string) string {
+	lines := strings.Split(patch, "\n")
+	for i, line := range lines {
+		escaped := html.EscapeString(line)
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			lines[i] = escaped
+		case strings.HasPrefix(line, "+"):
+			lines[i] = `<span class="add">` + escaped + `</span>`
+		case strings.HasPrefix(line, "-"):
+			lines[i] = `<span class="del">` + escaped + `</span>`
+		case strings.HasPrefix(line, "@@"):
+			lines[i] = `<span class="hunk">` + escaped + `</span>`
+		default:
+			lines[i] = escaped
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,107 @@
+package apiserver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/uistate"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+func sampleTranscriptSections() []transcriptSection {
+	exit := 1
+	timeline := []uistate.TimelineItem{
+		{Kind: "user", Text: "Fix the failing test"},
+		{Kind: "thinking", Text: "Look at the test output first"},
+		{Kind: "command", Command: "go test ./...", Output: "--- FAIL: TestX\n```\n<oops>\n", ExitCode: &exit},
+		{Kind: "file", File: "x.go", Status: "saved"},
+		{Kind: "assistant", Text: "Fixed **TestX**."},
+	}
+	diff := "diff --git a/x.go b/x.go\n--- a/x.go\n+++ b/x.go\n@@ -1 +1 @@\n-old\n+new\n" +
+		"diff --git a/y.go b/y.go\n--- a/y.go\n+++ b/y.go\n@@ -1 +1 @@\n-a\n+b\n"
+	return buildTranscriptSections(timeline, diff, transcriptOptions{IncludeReasoning: true, MaxOutputChars: 14})
+}
+
+func TestRenderTranscriptMarkdown(t *testing.T) {
+	meta := transcriptMeta{ThreadID: "thread-1", Cwd: "/repo", ExportedAt: time.Unix(0, 0).UTC()}
+	md := renderThreadTranscript(meta, sampleTranscriptSections(), transcriptFormatMarkdown)
+	for _, want := range []string{
+		"# Transcript: thread-1",
+		"### User\n\nFix the failing test",
+		"<details>\n<summary>Reasoning</summary>\n\nLook at the test output first",
+		"```sh\n$ go test ./...\n```",
+		"<summary>Output (exit 1)</summary>",
+		"… (",
+		"### Assistant\n\nFixed **TestX**.",
+		"2 file(s) changed.",
+		"<summary>y.go</summary>\n\n```diff\ndiff --git a/y.go b/y.go",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("markdown missing %q:\n%s", want, md)
+		}
+	}
+
+	// 输出含 ``` 时围栏需加长, 否则会提前闭合。
+	var b strings.Builder
+	writeMarkdownFence(&b, "text", "a\n```\nb")
+	if !strings.HasPrefix(b.String(), "````text\n") {
+		t.Fatalf("fence = %q", b.String())
+	}
+}
+
+func TestRenderTranscriptHTML(t *testing.T) {
+	meta := transcriptMeta{ThreadID: "thread-1", Name: "<Bug>", ExportedAt: time.Unix(0, 0).UTC()}
+	doc := renderThreadTranscript(meta, sampleTranscriptSections(), transcriptFormatHTML)
+	for _, want := range []string{
+		"<!DOCTYPE html>",
+		"<title>Transcript: &lt;Bug&gt;</title>",
+		"<details>\n<summary>Reasoning</summary>",
+		`<span class="add">+new</span>`,
+		`<span class="del">-old</span>`,
+		"</html>\n",
+	} {
+		if !strings.Contains(doc, want) {
+			t.Errorf("html missing %q", want)
+		}
+	}
+	if strings.Contains(doc, "<oops>") {
+		t.Fatal("command output must be escaped")
+	}
+}
+
+func TestThreadTranscriptExport_WritesFile(t *testing.T) {
+	srv := &Server{uiRuntime: uistate.NewRuntimeManager()}
+	srv.uiRuntime.ReplaceThreads([]uistate.ThreadSnapshot{{ID: "thread-t", Name: "thread-t"}})
+	srv.uiRuntime.AppendUserMessage("thread-t", "hello transcript", nil)
+	ctx := context.Background()
+
+	if _, err := srv.threadTranscriptExportTyped(ctx, threadTranscriptParams{ThreadID: "thread-t", Format: "pdf"}); apperrors.CodeOf(err) != errcode.InvalidInput {
+		t.Fatalf("bad format err = %v", err)
+	}
+	if _, err := srv.threadTranscriptExportTyped(ctx, threadTranscriptParams{ThreadID: "missing"}); apperrors.CodeOf(err) != errcode.ThreadNotFound {
+		t.Fatalf("missing thread err = %v", err)
+	}
+
+	inline, err := srv.threadTranscriptExportTyped(ctx, threadTranscriptParams{ThreadID: "thread-t"})
+	if err != nil || !strings.Contains(inline.(map[string]any)["content"].(string), "hello transcript") {
+		t.Fatalf("inline export = %+v, %v", inline, err)
+	}
+
+	target := filepath.Join(t.TempDir(), "out", "transcript.html")
+	resp, err := srv.threadTranscriptExportTyped(ctx, threadTranscriptParams{ThreadID: "thread-t", Format: "html", Path: target})
+	if err != nil {
+		t.Fatalf("export to file: %v", err)
+	}
+	if _, ok := resp.(map[string]any)["content"]; ok {
+		t.Fatal("content should be omitted when written to a file")
+	}
+	data, err := os.ReadFile(target)
+	if err != nil || !strings.Contains(string(data), "hello transcript") {
+		t.Fatalf("written transcript = %q, %v", data, err)
+	}
+}
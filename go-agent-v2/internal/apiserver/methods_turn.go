@@ -12,29 +12,42 @@ import (
 
 	"github.com/multi-agent/go-agent-v2/internal/codex"
 	"github.com/multi-agent/go-agent-v2/internal/runner"
+	"github.com/multi-agent/go-agent-v2/internal/service"
+	"github.com/multi-agent/go-agent-v2/internal/store"
 	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
 	"github.com/multi-agent/go-agent-v2/pkg/logger"
 )
 
 // UserInput 用户输入 (支持多种类型)。
 type UserInput struct {
-	Type    string `json:"type"`              // text, image, localImage, skill, mention, fileContent
+	Type    string `json:"type"`              // text, image, localImage, skill, mention, fileContent, artifact
 	Text    string `json:"text,omitempty"`    // type=text
 	URL     string `json:"url,omitempty"`     // type=image
-	Path    string `json:"path,omitempty"`    // type=localImage/mention/fileContent
+	Path    string `json:"path,omitempty"`    // type=localImage/mention/fileContent; type=artifact 为产物 ID
 	Name    string `json:"name,omitempty"`    // type=skill/mention
 	Content string `json:"content,omitempty"` // type=skill/fileContent
 }
 
 type turnStartParams struct {
-	ThreadID             string          `json:"threadId"`
-	Input                []UserInput     `json:"input"`
-	SelectedSkills       []string        `json:"selectedSkills,omitempty"`
-	ManualSkillSelection bool            `json:"manualSkillSelection,omitempty"`
-	Cwd                  string          `json:"cwd,omitempty"`
-	ApprovalPolicy       string          `json:"approvalPolicy,omitempty"`
-	Model                string          `json:"model,omitempty"`
-	OutputSchema         json.RawMessage `json:"outputSchema,omitempty"`
+	ThreadID             string              `json:"threadId"`
+	Input                []UserInput         `json:"input"`
+	SelectedSkills       []string            `json:"selectedSkills,omitempty"`
+	ManualSkillSelection bool                `json:"manualSkillSelection,omitempty"`
+	Cwd                  string              `json:"cwd,omitempty"`
+	ApprovalPolicy       string              `json:"approvalPolicy,omitempty"`
+	Model                string              `json:"model,omitempty"`
+	OutputSchema         json.RawMessage     `json:"outputSchema,omitempty"`
+	BypassDedup          bool                `json:"bypassDedup,omitempty"`         // 跳过跨线程去重, 强制提交
+	Priority             string              `json:"priority,omitempty"`            // interactive(默认) / normal / background
+	QualityGate          *bool               `json:"qualityGate,omitempty"`         // 诊断门禁, 缺省取 TURN_QUALITY_GATE_ENABLED
+	IdempotencyKey       string              `json:"idempotencyKey,omitempty"`      // 重发去重, 见 idempotency.go
+	Retry                *turnRetryPolicy    `json:"retry,omitempty"`               // 失败重试 / 备用模型, 见 turn_retry.go
+	OutputSchemaRetries  *int                `json:"outputSchemaRetries,omitempty"` // 结构化输出不合法时的重新提示次数, 见 turn_result.go
+	CostBudget           string              `json:"costBudget,omitempty"`          // 预算档位 low / standard(默认) / high, 参与模型路由
+	Routing              *turnRoutingOptions `json:"routing,omitempty"`             // 单次覆盖模型路由, 见 model_routing.go
+
+	retryAttempt         int  // 重试提交时的尝试序号 (>1 时不重复写入用户消息)
+	skipOutputValidation bool // 调用方自行解析结构化输出 (规划 / 审查), 不做 schema 校验与重新提示
 }
 
 // turnInfo 通用 turn 信息。
@@ -45,7 +58,11 @@ type turnInfo struct {
 
 // turnStartResponse turn/start 响应。
 type turnStartResponse struct {
-	Turn turnInfo `json:"turn"`
+	Turn    turnInfo            `json:"turn"`
+	DedupOf *turnDedupRef       `json:"dedupOf,omitempty"` // 命中去重时指向原始 turn
+	Queue   *turnQueueInfo      `json:"queue,omitempty"`   // 调度器排队时的队列信息
+	Held    *heldTurn           `json:"held,omitempty"`    // 静默窗口内暂存时的信息
+	Route   *modelRouteDecision `json:"route,omitempty"`   // 模型路由决策
 }
 
 type activeTurnIDReader interface {
@@ -232,7 +249,12 @@ func lowerMatchedTerms(text string, candidates []string) []string {
 		if _, ok := seen[lowerCandidate]; ok {
 			continue
 		}
-		if !strings.Contains(text, lowerCandidate) {
+		if re, isPattern, err := service.ParseSkillTriggerPattern(candidate); isPattern {
+			// /pattern/ 形式按正则匹配; 非法正则由 skills/validate 报告, 此处忽略。
+			if err != nil || !re.MatchString(text) {
+				continue
+			}
+		} else if !strings.Contains(text, lowerCandidate) {
 			continue
 		}
 		seen[lowerCandidate] = struct{}{}
@@ -450,12 +472,39 @@ func (s *Server) renderAutoMatchedSkillPrompt(agentID string, matches []autoMatc
 }
 
 func (s *Server) turnStartTyped(ctx context.Context, p turnStartParams) (any, error) {
+	// 先登记再提交: turn 可能在 startTurn 返回前就已结束。
+	gen, err := s.beginTurnOutputValidation(p)
+	if err != nil {
+		return nil, err
+	}
+	var res any
+	if p.Retry != nil {
+		res, err = s.startTurnWithRetry(ctx, p)
+	} else {
+		// 线程上的新 turn 取消未完成的重试。
+		s.turnRetries.drop(p.ThreadID, 0)
+		res, err = s.startTurn(ctx, p)
+	}
+	if gen != 0 {
+		s.turnResults.bind(p.ThreadID, gen, res, err)
+	}
+	return res, err
+}
+
+// startTurn 执行 turn/start (重试提交也经由此处)。
+func (s *Server) startTurn(ctx context.Context, p turnStartParams) (any, error) {
 	logger.Info("turn/start: request received",
 		logger.FieldAgentID, p.ThreadID, logger.FieldThreadID, p.ThreadID,
 		logger.FieldCwd, strings.TrimSpace(p.Cwd),
 		"input_count", len(p.Input),
 		"selected_skills_count", len(p.SelectedSkills),
 	)
+	if err := s.frozenError("Server.turnStart"); err != nil {
+		return nil, err
+	}
+	if err := s.drainingError("Server.turnStart"); err != nil {
+		return nil, err
+	}
 	proc, err := s.ensureThreadReadyForTurn(ctx, p.ThreadID, p.Cwd)
 	if err != nil {
 		return nil, err
@@ -471,10 +520,37 @@ func (s *Server) turnStartTyped(ctx context.Context, p turnStartParams) (any, er
 		return nil, apperrors.Wrap(err, "Server.turnStart", "normalize selected skills")
 	}
 
+	costBudget, err := normalizeCostBudget(p.CostBudget)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.Input, err = s.resolveArtifactInputs(ctx, p.Input); err != nil {
+		return nil, err
+	}
 	prompt, images, files := extractInputs(p.Input)
+	routeSkills := append([]string(nil), selectedSkills...)
+	for name := range collectInputSkillNames(p.Input) {
+		routeSkills = append(routeSkills, name)
+	}
+	route, err := routeTurnModel(s.loadModelRouting(ctx), modelRouteInput{
+		Prompt:     prompt,
+		Skills:     routeSkills,
+		CostBudget: costBudget,
+	}, p.Model, s.templateTurnModel(p.ThreadID, ""), p.Routing)
+	if err != nil {
+		return nil, err
+	}
+	p.Model = route.Model
 	skillPrompt, selectedSkillCount, autoMatchedSkillCount := s.buildTurnSkillPrompt(p.ThreadID, prompt, p.Input, selectedSkills, p.ManualSkillSelection)
 	submitPrompt := mergePromptText(prompt, skillPrompt)
 	submitPrompt = s.appendUnifiedToolingHint(ctx, submitPrompt)
+	memoryPrompt, memoryCount := s.buildMemoryContextPrompt(ctx, p.ThreadID, prompt)
+	submitPrompt = mergePromptText(memoryPrompt, submitPrompt)
+	submitPrompt = mergePromptText(s.buildThreadContextPrompt(ctx, p.ThreadID), submitPrompt)
+	oneShot, releaseOneShot := s.claimTurnOneShotPrompts(ctx, p.ThreadID)
+	defer releaseOneShot()
+	submitPrompt = oneShot.wrap(submitPrompt)
 	logger.Info("turn/start: input prepared",
 		logger.FieldAgentID, p.ThreadID, logger.FieldThreadID, p.ThreadID,
 		"text_len", len(prompt),
@@ -484,28 +560,227 @@ func (s *Server) turnStartTyped(ctx context.Context, p turnStartParams) (any, er
 		"selected_skills_injected", selectedSkillCount,
 		"manual_skill_selection", p.ManualSkillSelection,
 		"auto_matched_skills", autoMatchedSkillCount,
+		"memories_injected", memoryCount,
 	)
-	if err := proc.Client.Submit(submitPrompt, images, files, p.OutputSchema); err != nil {
+	dedupKey := ""
+	if !p.BypassDedup {
+		project := s.resolveTurnProject(p.ThreadID, p.Cwd)
+		if _, policy, enabled := resolveTurnDedupPolicy(s.loadTurnDedupPolicies(ctx), project); enabled {
+			now := time.Now()
+			fingerprint := turnDedupFingerprint(project, p)
+			if original, reserved := s.turnDedup.reserve(fingerprint, p.ThreadID, policy.window(), now); !reserved {
+				ref := s.lookupTurnDedupRef(original, now)
+				logger.Info("turn/start: duplicate input linked to original turn",
+					logger.FieldAgentID, p.ThreadID, logger.FieldThreadID, p.ThreadID,
+					logger.FieldPath, project,
+					"original_thread_id", ref.ThreadID,
+					"original_turn_id", ref.TurnID,
+					logger.FieldStatus, ref.Status,
+				)
+				return turnStartResponse{
+					Turn:    turnInfo{ID: ref.TurnID, Status: ref.Status},
+					DedupOf: &ref,
+				}, nil
+			}
+			dedupKey = fingerprint
+		}
+	}
+	turn := preparedTurn{
+		ThreadID:     p.ThreadID,
+		Cwd:          p.Cwd,
+		Input:        p.Input,
+		Prompt:       prompt,
+		SubmitPrompt: submitPrompt,
+		Images:       images,
+		Files:        files,
+		Model:        p.Model,
+		OutputSchema: p.OutputSchema,
+		DedupKey:     dedupKey,
+		QualityGate:  s.qualityGate.enabledFor(p.QualityGate),
+		Attempt:      p.retryAttempt,
+		Route:        &route,
+	}
+	if isAutonomousTurnPriority(p.Priority) {
+		project := s.resolveTurnProject(p.ThreadID, p.Cwd)
+		now := time.Now()
+		if state, quiet := resolveQuietHours(s.loadQuietHoursPolicies(ctx), project, now); quiet {
+			priority, _ := normalizeTurnPriority(p.Priority)
+			held, merged, err := s.quietHours.hold(project, priority, state, turn, now)
+			if (err != nil || merged) && dedupKey != "" {
+				s.turnDedup.release(dedupKey)
+			}
+			if err != nil {
+				return nil, apperrors.Wrap(err, "Server.turnStart", "hold for quiet hours")
+			}
+			if !merged {
+				s.consumeTurnOneShotPrompts(ctx, p.ThreadID, oneShot)
+			}
+			logger.Info("turn/start: held by quiet hours",
+				logger.FieldAgentID, p.ThreadID, logger.FieldThreadID, p.ThreadID,
+				logger.FieldPath, project,
+				"held_id", held.ID,
+				"reason", state.Reason,
+				"until", state.Until.Format(time.RFC3339),
+				"merged", merged,
+			)
+			return turnStartResponse{
+				Turn: turnInfo{ID: held.ID, Status: "held"},
+				Held: held,
+			}, nil
+		}
+	}
+	if s.turnScheduler != nil {
+		priority, err := normalizeTurnPriority(p.Priority)
+		if err != nil {
+			if dedupKey != "" {
+				s.turnDedup.release(dedupKey)
+			}
+			return nil, apperrors.Wrap(err, "Server.turnStart", "normalize priority")
+		}
+		project := s.resolveTurnProject(p.ThreadID, p.Cwd)
+		if queued, position, admitted := s.turnScheduler.admit(project, priority, turn, time.Now()); !admitted {
+			s.consumeTurnOneShotPrompts(ctx, p.ThreadID, oneShot)
+			logger.Info("turn/start: queued by scheduler",
+				logger.FieldAgentID, p.ThreadID, logger.FieldThreadID, p.ThreadID,
+				logger.FieldPath, project,
+				"queue_id", queued.ID,
+				"priority", priority,
+				"position", position,
+			)
+			return turnStartResponse{
+				Turn:  turnInfo{ID: queued.ID, Status: "queued"},
+				Queue: &turnQueueInfo{ID: queued.ID, Position: position, Priority: priority},
+			}, nil
+		}
+	}
+	turnID, err := s.submitPreparedTurn(ctx, proc, turn)
+	if err != nil {
+		if dedupKey != "" {
+			s.turnDedup.release(dedupKey)
+		}
+		s.releaseScheduledTurn(p.ThreadID)
 		return nil, apperrors.Wrap(err, "Server.turnStart", "submit prompt")
 	}
-	if s.uiRuntime != nil {
-		attachments := buildUserTimelineAttachmentsFromInputs(p.Input)
+	s.consumeTurnOneShotPrompts(ctx, p.ThreadID, oneShot)
+	s.bindAuditTurn(ctx, p.ThreadID, turnID)
+	return turnStartResponse{
+		Turn:  turnInfo{ID: turnID, Status: "inProgress"},
+		Route: turn.Route,
+	}, nil
+}
+
+// turnOneShotPrompts 只随一次 turn 注入的上下文: 模板基础指令、人格附加指令、交接摘要。
+// turn/start 先只读取, 提交或排队成功后才消费; 去重命中或提交失败时留给下一次 turn。
+type turnOneShotPrompts struct {
+	Template    string
+	Personality string
+	Handoff     *store.ThreadHandoff
+}
+
+func (o turnOneShotPrompts) empty() bool {
+	return o.Template == "" && o.Personality == "" && o.Handoff == nil
+}
+
+// claimTurnOneShotPrompts 占用线程的一次性提示词并读取; 同一线程已有 turn/start 占用时返回空,
+// 避免并发 turn 读到同一份提示词后重复注入。release 须在消费 (或放弃) 之后调用。
+func (s *Server) claimTurnOneShotPrompts(ctx context.Context, threadID string) (turnOneShotPrompts, func()) {
+	s.turnOneShotMu.Lock()
+	if _, busy := s.turnOneShotClaims[threadID]; busy {
+		s.turnOneShotMu.Unlock()
+		return turnOneShotPrompts{}, func() {}
+	}
+	if s.turnOneShotClaims == nil {
+		s.turnOneShotClaims = make(map[string]struct{})
+	}
+	s.turnOneShotClaims[threadID] = struct{}{}
+	s.turnOneShotMu.Unlock()
+
+	release := func() {
+		s.turnOneShotMu.Lock()
+		delete(s.turnOneShotClaims, threadID)
+		s.turnOneShotMu.Unlock()
+	}
+	o := turnOneShotPrompts{
+		Template:    s.agentTemplates.pendingInstructions(threadID),
+		Personality: s.personalities.pendingInstructions(threadID),
+		Handoff:     s.pendingThreadHandoff(ctx, threadID),
+	}
+	if o.empty() {
+		release()
+		return o, func() {}
+	}
+	return o, release
+}
+
+// wrap 按 交接摘要 → 人格 → 模板 → 原提示词 的顺序拼接。
+func (o turnOneShotPrompts) wrap(prompt string) string {
+	prompt = mergePromptText(o.Template, prompt)
+	prompt = mergePromptText(o.Personality, prompt)
+	if o.Handoff != nil {
+		prompt = mergePromptText(buildHandoffResumePrompt(o.Handoff), prompt)
+	}
+	return prompt
+}
+
+func (s *Server) consumeTurnOneShotPrompts(ctx context.Context, threadID string, o turnOneShotPrompts) {
+	if o.empty() {
+		return
+	}
+	if o.Template != "" {
+		s.agentTemplates.consumeInstructions(threadID, o.Template)
+	}
+	if o.Personality != "" {
+		s.personalities.consumeInstructions(threadID, o.Personality)
+	}
+	s.consumeThreadHandoff(ctx, threadID, o.Handoff)
+}
+
+// preparedTurn 已完成技能/提示词组装、待提交给 codex 的 turn。
+type preparedTurn struct {
+	ThreadID     string
+	Cwd          string
+	Input        []UserInput
+	Prompt       string
+	SubmitPrompt string
+	Images       []string
+	Files        []string
+	Model        string
+	OutputSchema json.RawMessage
+	DedupKey     string
+	QualityGate  bool
+	Attempt      int                 // 重试序号, >1 时时间线已有该用户消息
+	Route        *modelRouteDecision // 模型路由决策, 提交前据此切换模型并记入 turn span
+}
+
+// submitPreparedTurn 提交 turn, 写入 UI 时间线并开始 turn 跟踪, 返回 turn ID。
+func (s *Server) submitPreparedTurn(ctx context.Context, proc *runner.AgentProcess, turn preparedTurn) (string, error) {
+	s.beginTurnQualityGate(turn.ThreadID, turn.QualityGate)
+	s.applyTurnModelRoute(proc, &turn)
+	if err := traceCodex(ctx, turn.ThreadID, "submit", func() error {
+		return proc.Client.Submit(turn.SubmitPrompt, turn.Images, turn.Files, turn.OutputSchema)
+	}); err != nil {
+		return "", err
+	}
+	if s.uiRuntime != nil && turn.Attempt <= 1 {
+		attachments := buildUserTimelineAttachmentsFromInputs(turn.Input)
 		if len(attachments) == 0 {
-			attachments = buildUserTimelineAttachments(images, files)
+			attachments = buildUserTimelineAttachments(turn.Images, turn.Files)
 		}
-		s.uiRuntime.AppendUserMessage(p.ThreadID, prompt, attachments)
+		s.uiRuntime.AppendUserMessage(turn.ThreadID, turn.Prompt, attachments)
 	}
 
 	resolvedTurnID := resolveClientActiveTurnID(proc.Client)
 	if resolvedTurnID == "" {
 		logger.Warn("turn/start: active turn id unavailable after submit; tracker will use synthetic id",
-			logger.FieldAgentID, p.ThreadID, logger.FieldThreadID, p.ThreadID,
+			logger.FieldAgentID, turn.ThreadID, logger.FieldThreadID, turn.ThreadID,
 		)
 	}
-	turnID := s.beginTrackedTurn(p.ThreadID, resolvedTurnID)
-	return turnStartResponse{
-		Turn: turnInfo{ID: turnID, Status: "inProgress"},
-	}, nil
+	turnID := s.beginTrackedTurn(turn.ThreadID, resolvedTurnID)
+	s.setTrackedTurnRoute(turn.ThreadID, turnID, turn.Route)
+	if turn.DedupKey != "" {
+		s.turnDedup.commit(turn.DedupKey, turnID)
+	}
+	return turnID, nil
 }
 
 type turnSteerParams struct {
@@ -516,23 +791,32 @@ type turnSteerParams struct {
 }
 
 func (s *Server) turnSteerTyped(ctx context.Context, p turnSteerParams) (any, error) {
+	if err := s.frozenError("Server.turnSteer"); err != nil {
+		return nil, err
+	}
 	return s.withThread(p.ThreadID, func(proc *runner.AgentProcess) (any, error) {
 		selectedSkills, err := normalizeSkillNames(p.SelectedSkills)
 		if err != nil {
 			return nil, apperrors.Wrap(err, "Server.turnSteer", "normalize selected skills")
 		}
-		prompt, images, files := extractInputs(p.Input)
-		skillPrompt, _, _ := s.buildTurnSkillPrompt(p.ThreadID, prompt, p.Input, selectedSkills, p.ManualSkillSelection)
+		inputs, err := s.resolveArtifactInputs(ctx, p.Input)
+		if err != nil {
+			return nil, err
+		}
+		prompt, images, files := extractInputs(inputs)
+		skillPrompt, _, _ := s.buildTurnSkillPrompt(p.ThreadID, prompt, inputs, selectedSkills, p.ManualSkillSelection)
 		submitPrompt := mergePromptText(prompt, skillPrompt)
 		submitPrompt = s.appendUnifiedToolingHint(ctx, submitPrompt)
-		if err := proc.Client.Submit(submitPrompt, images, files, nil); err != nil {
+		if err := traceCodex(ctx, p.ThreadID, "steer", func() error {
+			return proc.Client.Submit(submitPrompt, images, files, nil)
+		}); err != nil {
 			return nil, err
 		}
 		return map[string]any{}, nil
 	})
 }
 
-func (s *Server) turnInterrupt(_ context.Context, params json.RawMessage) (any, error) {
+func (s *Server) turnInterrupt(ctx context.Context, params json.RawMessage) (any, error) {
 	start := time.Now()
 	var p threadIDParams
 	if err := json.Unmarshal(params, &p); err != nil {
@@ -554,8 +838,16 @@ func (s *Server) turnInterrupt(_ context.Context, params json.RawMessage) (any,
 			"cancelled_runs", cancelled,
 		)
 	}
+	if cancelled := s.cancelThreadQuestions(p.ThreadID); cancelled > 0 {
+		logger.Info("turn/interrupt: cancelled pending ask_user questions",
+			logger.FieldAgentID, p.ThreadID, logger.FieldThreadID, p.ThreadID,
+			"cancelled_questions", cancelled,
+		)
+	}
 	return s.withThread(p.ThreadID, func(proc *runner.AgentProcess) (any, error) {
-		if err := proc.Client.SendCommand("/interrupt", ""); err != nil {
+		if err := traceCodex(ctx, p.ThreadID, "/interrupt", func() error {
+			return proc.Client.SendCommand("/interrupt", "")
+		}); err != nil {
 			if isInterruptNoActiveTurnError(err) {
 				if activeBefore || activeTrackedBefore {
 					if completion, ok := s.completeTrackedTurn(p.ThreadID, "completed", "interrupt_no_active_turn"); ok {
@@ -640,6 +932,12 @@ func (s *Server) turnForceComplete(_ context.Context, params json.RawMessage) (a
 			"cancelled_runs", cancelled,
 		)
 	}
+	if cancelled := s.cancelThreadQuestions(p.ThreadID); cancelled > 0 {
+		logger.Info("turn/forceComplete: cancelled pending ask_user questions",
+			logger.FieldAgentID, p.ThreadID, logger.FieldThreadID, p.ThreadID,
+			"cancelled_questions", cancelled,
+		)
+	}
 	return s.withThread(p.ThreadID, func(proc *runner.AgentProcess) (any, error) {
 		// 尝试发送中断; 忽略 "no active turn" 错误, 但记录其他错误。
 		if err := proc.Client.SendCommand("/interrupt", ""); err != nil {
@@ -778,35 +1076,24 @@ func interruptSettleMode(confirmed bool, afterState string) string {
 	}
 }
 
-// reviewStartParams review/start 请求参数。
-type reviewStartParams struct {
-	ThreadID string `json:"threadId"`
-	Delivery string `json:"delivery,omitempty"`
-}
-
-func (s *Server) reviewStartTyped(_ context.Context, p reviewStartParams) (any, error) {
-	return s.withThread(p.ThreadID, func(proc *runner.AgentProcess) (any, error) {
-		if err := proc.Client.SendCommand("/review", p.Delivery); err != nil {
-			return nil, apperrors.Wrap(err, "Server.reviewStart", "send review command")
-		}
-		return map[string]any{}, nil
-	})
-}
-
 // ========================================
 // fuzzyFileSearch
 // ========================================
 
 type fuzzySearchParams struct {
 	Query string   `json:"query"`
-	Roots []string `json:"roots"`
+	Roots []string `json:"roots"` // 空 = 全部已登记工作区根 (workspace/root/list)
 }
 
 func (s *Server) fuzzyFileSearchTyped(_ context.Context, p fuzzySearchParams) (any, error) {
 	query := strings.ToLower(p.Query)
 	results := make([]map[string]any, 0)
 
-	for _, root := range p.Roots {
+	roots := p.Roots
+	if len(roots) == 0 {
+		roots = s.workspaceRoots.topLevel()
+	}
+	for _, root := range roots {
 		_ = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
 			if err != nil {
 				return nil
@@ -165,7 +165,7 @@ func (s *Server) gatherCodeDiagnostics(filePath string, startLine, endLine int)
 	}
 	uri := codePathToURI(filePath)
 	s.diagMu.RLock()
-	diags := s.diagCache[uri]
+	diags := s.diagCache.get(uri)
 	s.diagMu.RUnlock()
 	if len(diags) == 0 {
 		return []map[string]any{}
@@ -382,7 +382,7 @@ func (s *Server) uiCodeOpenTyped(_ context.Context, p uiCodeOpenParams) (any, er
 		)
 		return nil, apperrors.Newf("Server.uiCodeOpen", "path is directory: %s", resolvedPath)
 	}
-	lspSupported := supportsLSPFileType(resolvedPath)
+	lspSupported := s.supportsLSPFileType(resolvedPath)
 	if info.Size() > maxCodeOpenFileBytes {
 		logger.Warn("ui/code/open: file too large",
 			"resolved_path", resolvedPath,
@@ -541,11 +541,14 @@ func (s *Server) uiCodeOpenTyped(_ context.Context, p uiCodeOpenParams) (any, er
 	return result, nil
 }
 
-func supportsLSPFileType(path string) bool {
+func (s *Server) supportsLSPFileType(path string) bool {
 	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
 	if ext == "" {
 		return false
 	}
+	if s.lsp != nil {
+		return s.lsp.SupportsExtension(ext)
+	}
 	for _, item := range lsp.DefaultServers {
 		for _, supportedExt := range item.Extensions {
 			if supportedExt == ext {
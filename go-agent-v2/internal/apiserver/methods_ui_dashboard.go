@@ -121,6 +121,7 @@ func (s *Server) uiDashboardGet(ctx context.Context, p uiDashboardGetParams) (an
 	case "dags":
 		out, _ := s.callDash(ctx, "dags")
 		copyListField(result, "dags", out, "dags")
+		result["dags"] = mergePlanDashboardRows(s.planDashboardRows(), result["dags"])
 	case "tasks":
 		acks, _ := s.callDash(ctx, "taskAcks")
 		traces, _ := s.callDash(ctx, "taskTraces")
@@ -0,0 +1,76 @@
+// methods_ui_layout.go — ui/layout/*: 桌面窗口几何布局 (位置/尺寸/最大化/显示器) 持久化。
+//
+// 桌面端在窗口移动/缩放后调用 ui/layout/save, 启动时经 ui/layout/get 恢复;
+// 显示器变化导致窗口落在屏幕外时用 ui/layout/reset 清除, 并广播 ui/layout/reset 让窗口回到默认布局。
+package apiserver
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/uistate"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+// UILayoutResetMethod 布局重置方法名 (同时作为广播通知名)。
+const UILayoutResetMethod = "ui/layout/reset"
+
+type uiLayoutGetParams struct {
+	Window string `json:"window,omitempty"` // 为空返回全部窗口
+}
+
+func (s *Server) uiLayoutGet(ctx context.Context, p uiLayoutGetParams) (any, error) {
+	layouts, err := s.prefManager.WindowLayouts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	window := strings.TrimSpace(p.Window)
+	if window == "" {
+		return map[string]any{"layouts": layouts}, nil
+	}
+	geometry, ok := layouts[window]
+	if !ok {
+		return map[string]any{"window": window, "geometry": nil}, nil
+	}
+	return map[string]any{"window": window, "geometry": geometry}, nil
+}
+
+type uiLayoutSaveParams struct {
+	Window   string                 `json:"window"`
+	Geometry uistate.WindowGeometry `json:"geometry"`
+}
+
+func (s *Server) uiLayoutSave(ctx context.Context, p uiLayoutSaveParams) (any, error) {
+	const op = "Server.uiLayoutSave"
+	window := strings.TrimSpace(p.Window)
+	if window == "" {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "window is required")
+	}
+	if !p.Geometry.Valid() {
+		return nil, apperrors.NewCodef(op, errcode.InvalidInput, "invalid window size %dx%d", p.Geometry.Width, p.Geometry.Height)
+	}
+	geometry := p.Geometry
+	geometry.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	if err := s.prefManager.SaveWindowGeometry(ctx, window, geometry); err != nil {
+		return nil, err
+	}
+	return map[string]any{"ok": true, "window": window}, nil
+}
+
+type uiLayoutResetParams struct {
+	Window string `json:"window,omitempty"` // 为空重置全部窗口
+}
+
+func (s *Server) uiLayoutReset(ctx context.Context, p uiLayoutResetParams) (any, error) {
+	window := strings.TrimSpace(p.Window)
+	removed, err := s.prefManager.ResetWindowLayout(ctx, window)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("ui/layout/reset: cleared window layout", logger.FieldName, window, logger.FieldCount, removed)
+	s.Notify(UILayoutResetMethod, map[string]any{"window": window, "removed": removed})
+	return map[string]any{"ok": true, "window": window, "removed": removed}, nil
+}
@@ -0,0 +1,56 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/multi-agent/go-agent-v2/internal/uistate"
+)
+
+func TestUILayout_SaveGetReset(t *testing.T) {
+	srv := New(Deps{})
+	ctx := context.Background()
+	var notified []map[string]any
+	srv.SetNotifyHook(func(method string, params any) {
+		if method == UILayoutResetMethod {
+			p, _ := params.(map[string]any)
+			notified = append(notified, p)
+		}
+	})
+
+	save := `{"window":"main","geometry":{"x":2560,"y":24,"width":1440,"height":900,"maximized":true,"monitor":"2","monitorName":"HDMI-1"}}`
+	if _, err := srv.InvokeMethod(ctx, "ui/layout/save", json.RawMessage(save)); err != nil {
+		t.Fatalf("ui/layout/save: %v", err)
+	}
+	if _, err := srv.InvokeMethod(ctx, "ui/layout/save", json.RawMessage(`{"window":"main","geometry":{"width":0,"height":900}}`)); err == nil {
+		t.Fatal("expected error for zero width")
+	}
+
+	res, err := srv.InvokeMethod(ctx, "ui/layout/get", json.RawMessage(`{"window":"main"}`))
+	if err != nil {
+		t.Fatalf("ui/layout/get: %v", err)
+	}
+	geometry, ok := res.(map[string]any)["geometry"].(uistate.WindowGeometry)
+	if !ok {
+		t.Fatalf("geometry type = %T", res.(map[string]any)["geometry"])
+	}
+	if geometry.X != 2560 || !geometry.Maximized || geometry.Monitor != "2" || geometry.UpdatedAt == "" {
+		t.Fatalf("geometry = %#v", geometry)
+	}
+
+	res, err = srv.InvokeMethod(ctx, UILayoutResetMethod, json.RawMessage(`{}`))
+	if err != nil {
+		t.Fatalf("ui/layout/reset: %v", err)
+	}
+	if removed := res.(map[string]any)["removed"]; removed != 1 {
+		t.Fatalf("removed = %v, want 1", removed)
+	}
+	if len(notified) != 1 || notified[0]["window"] != "" {
+		t.Fatalf("reset notifications = %#v", notified)
+	}
+	res, _ = srv.InvokeMethod(ctx, "ui/layout/get", json.RawMessage(`{"window":"main"}`))
+	if res.(map[string]any)["geometry"] != nil {
+		t.Fatalf("geometry after reset = %#v", res)
+	}
+}
@@ -0,0 +1,37 @@
+// methods_ui_thread_open.go — ui/thread/open: 请求桌面窗口聚焦并切换到指定线程。
+//
+// 用于 agentorch://thread/<id> 深度链接转发: 由系统再次拉起的 agent-terminal 进程把链接经 /rpc
+// 交给已运行实例, 本方法广播 ui/thread/open 通知, 桌面端桥接收到后显示窗口并选中线程。
+package apiserver
+
+import (
+	"context"
+	"strings"
+
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+// UIThreadOpenMethod 打开线程请求的方法名 (同时作为广播通知名)。
+const UIThreadOpenMethod = "ui/thread/open"
+
+type uiThreadOpenParams struct {
+	ThreadID string `json:"threadId"`
+	Source   string `json:"source,omitempty"` // 来源标识, 如 "deep-link"
+}
+
+func (s *Server) uiThreadOpenTyped(_ context.Context, p uiThreadOpenParams) (any, error) {
+	const op = "Server.uiThreadOpen"
+	threadID := strings.TrimSpace(p.ThreadID)
+	if threadID == "" {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "threadId is required")
+	}
+	source := strings.TrimSpace(p.Source)
+	logger.Info("ui/thread/open: broadcast", logger.FieldThreadID, threadID, logger.FieldSource, source)
+	s.Notify(UIThreadOpenMethod, map[string]any{
+		"threadId": threadID,
+		"source":   source,
+	})
+	return map[string]any{"ok": true, "threadId": threadID}, nil
+}
@@ -0,0 +1,38 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestUIThreadOpen_BroadcastsNotification(t *testing.T) {
+	srv := New(Deps{})
+	var gotMethod string
+	var gotPayload map[string]any
+	srv.SetNotifyHook(func(method string, params any) {
+		gotMethod = method
+		gotPayload, _ = params.(map[string]any)
+	})
+
+	res, err := srv.InvokeMethod(context.Background(), UIThreadOpenMethod, json.RawMessage(`{"threadId":" thread-42 ","source":"deep-link"}`))
+	if err != nil {
+		t.Fatalf("ui/thread/open: %v", err)
+	}
+	if m, _ := res.(map[string]any); m["threadId"] != "thread-42" {
+		t.Fatalf("result = %#v, want threadId thread-42", res)
+	}
+	if gotMethod != UIThreadOpenMethod {
+		t.Fatalf("notify method = %q, want %q", gotMethod, UIThreadOpenMethod)
+	}
+	if gotPayload["threadId"] != "thread-42" || gotPayload["source"] != "deep-link" {
+		t.Fatalf("notify payload = %#v", gotPayload)
+	}
+}
+
+func TestUIThreadOpen_RequiresThreadID(t *testing.T) {
+	srv := New(Deps{})
+	if _, err := srv.InvokeMethod(context.Background(), UIThreadOpenMethod, json.RawMessage(`{"threadId":"  "}`)); err == nil {
+		t.Fatal("expected error for empty threadId")
+	}
+}
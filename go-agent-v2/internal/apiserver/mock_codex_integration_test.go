@@ -0,0 +1,59 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/codex"
+	"github.com/multi-agent/go-agent-v2/internal/runner"
+)
+
+func TestMockCodexBackendDrivesTurnEndToEnd(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	mgr := runner.NewAgentManager()
+	mgr.SetClientFactory(codex.MockClientFactory(nil, 0))
+	srv := New(Deps{Manager: mgr})
+	mgr.SetOnEvent(func(agentID string, event codex.Event) {
+		srv.AgentEventHandler(agentID)(event)
+	})
+	completed := make(chan string, 1)
+	srv.SetNotifyHook(func(method string, params any) {
+		if method == "turn/completed" {
+			if p, ok := params.(map[string]any); ok {
+				tid, _ := p["threadId"].(string)
+				completed <- tid
+			}
+		}
+	})
+
+	ctx := context.Background()
+	res, err := srv.InvokeMethod(ctx, "thread/start", json.RawMessage(`{"cwd":"."}`))
+	if err != nil {
+		t.Fatalf("thread/start: %v", err)
+	}
+	threadID := res.(threadStartResponse).Thread.ID
+	params, _ := json.Marshal(map[string]any{"threadId": threadID, "input": []map[string]any{{"type": "text", "text": "demo"}}})
+	if _, err := srv.InvokeMethod(ctx, "turn/start", params); err != nil {
+		t.Fatalf("turn/start: %v", err)
+	}
+	select {
+	case tid := <-completed:
+		if tid != threadID {
+			t.Fatalf("completed thread = %q, want %q", tid, threadID)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("turn/completed not received")
+	}
+
+	kinds := map[string]bool{}
+	for _, item := range srv.ThreadTimeline(threadID) {
+		kinds[item.Kind] = true
+	}
+	for _, want := range []string{"command", "file", "assistant"} {
+		if !kinds[want] {
+			t.Fatalf("timeline kinds = %v, missing %s", kinds, want)
+		}
+	}
+}
@@ -0,0 +1,336 @@
+// model_routing.go — 按任务特征为每个 turn 选择模型 (模型路由规则)。
+//
+// 规则以 UI 偏好存储 (settings.modelRouting), 按顺序匹配, 第一条命中的规则决定模型:
+//
+//	{"rules": [
+//	  {"name": "long-context", "model": "gpt-4.1", "minPromptChars": 20000},
+//	  {"name": "code", "model": "o3", "hasCodeBlock": true, "budgets": ["standard", "high"]},
+//	  {"name": "review-skill", "model": "o4-mini", "skills": ["review"]}
+//	]}
+//
+// 条件之间为 "且", 未设置的条件不参与匹配。预算档位 (costBudget) 由 turn/start 传入, 缺省 standard。
+//
+// 优先级: turn/start 显式 model > routing.rule 指定规则 > 规则匹配 > 线程模板模型;
+// routing.disabled 跳过规则匹配。命中规则且模型与线程当前模型不同时先发送 /model 切换,
+// 此后未命中规则的 turn 切回模板 / 启动模型。决策随响应返回, 并写入 turn span 的 metadata.modelRoute。
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/multi-agent/go-agent-v2/internal/codex"
+	"github.com/multi-agent/go-agent-v2/internal/runner"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+const (
+	prefKeyModelRouting  = "settings.modelRouting"
+	maxModelRoutingRules = 64
+
+	// 预算档位。
+	costBudgetLow      = "low"
+	costBudgetStandard = "standard"
+	costBudgetHigh     = "high"
+)
+
+// 路由决策来源。
+const (
+	modelRouteSourceRequest  = "request"  // turn/start 显式 model
+	modelRouteSourceRule     = "rule"     // 规则匹配 (含 routing.rule 指定)
+	modelRouteSourceTemplate = "template" // 线程模板 / 启动模型
+	modelRouteSourceDefault  = "default"  // 未指定, codex 当前模型
+)
+
+var costBudgetTiers = map[string]bool{costBudgetLow: true, costBudgetStandard: true, costBudgetHigh: true}
+
+// modelRoutingRule 一条路由规则。
+type modelRoutingRule struct {
+	Name           string   `json:"name"`
+	Model          string   `json:"model"`
+	MinPromptChars int      `json:"minPromptChars,omitempty"` // 用户输入字符数下限 (含)
+	MaxPromptChars int      `json:"maxPromptChars,omitempty"` // 用户输入字符数上限 (含), 0 = 不限
+	HasCodeBlock   *bool    `json:"hasCodeBlock,omitempty"`   // 输入是否包含 ``` 代码块
+	Skills         []string `json:"skills,omitempty"`         // 选中任一技能
+	Budgets        []string `json:"budgets,omitempty"`        // 预算档位 low / standard / high, 任一
+	Disabled       bool     `json:"disabled,omitempty"`
+}
+
+// modelRoutingConfig 路由配置。
+type modelRoutingConfig struct {
+	Rules []modelRoutingRule `json:"rules"`
+}
+
+// turnRoutingOptions turn/start 的单次路由覆盖。
+type turnRoutingOptions struct {
+	Disabled bool   `json:"disabled,omitempty"` // 跳过规则匹配
+	Rule     string `json:"rule,omitempty"`     // 直接使用指定规则的模型 (忽略条件)
+}
+
+// modelRouteDecision 单个 turn 的模型选择结果。
+type modelRouteDecision struct {
+	Model      string `json:"model,omitempty"` // 空 = codex 当前模型
+	Source     string `json:"source"`
+	Rule       string `json:"rule,omitempty"`
+	CostBudget string `json:"costBudget"`
+	Switched   bool   `json:"switched,omitempty"` // 提交前发送了 /model 切换
+}
+
+// modelRouteInput 规则匹配所需的 turn 特征。
+type modelRouteInput struct {
+	Prompt     string
+	Skills     []string
+	CostBudget string
+}
+
+func (r modelRoutingRule) validate() error {
+	if strings.TrimSpace(r.Name) == "" {
+		return fmt.Errorf("rule name is required")
+	}
+	if strings.TrimSpace(r.Model) == "" {
+		return fmt.Errorf("rule %q: model is required", r.Name)
+	}
+	if r.MinPromptChars < 0 || r.MaxPromptChars < 0 {
+		return fmt.Errorf("rule %q: prompt length bounds must be >= 0", r.Name)
+	}
+	if r.MaxPromptChars > 0 && r.MaxPromptChars < r.MinPromptChars {
+		return fmt.Errorf("rule %q: maxPromptChars < minPromptChars", r.Name)
+	}
+	for _, budget := range r.Budgets {
+		if !costBudgetTiers[strings.ToLower(strings.TrimSpace(budget))] {
+			return fmt.Errorf("rule %q: unknown budget %q (want low / standard / high)", r.Name, budget)
+		}
+	}
+	return nil
+}
+
+func (c modelRoutingConfig) validate() error {
+	if len(c.Rules) > maxModelRoutingRules {
+		return fmt.Errorf("too many rules: %d (limit %d)", len(c.Rules), maxModelRoutingRules)
+	}
+	seen := make(map[string]bool, len(c.Rules))
+	for _, rule := range c.Rules {
+		if err := rule.validate(); err != nil {
+			return err
+		}
+		name := strings.ToLower(strings.TrimSpace(rule.Name))
+		if seen[name] {
+			return fmt.Errorf("duplicate rule name %q", rule.Name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// matches 判断规则条件是否全部满足。
+func (r modelRoutingRule) matches(in modelRouteInput) bool {
+	if r.Disabled {
+		return false
+	}
+	chars := len([]rune(in.Prompt))
+	if chars < r.MinPromptChars || (r.MaxPromptChars > 0 && chars > r.MaxPromptChars) {
+		return false
+	}
+	if r.HasCodeBlock != nil && *r.HasCodeBlock != strings.Contains(in.Prompt, "```") {
+		return false
+	}
+	if len(r.Skills) > 0 && !containsAnyFold(r.Skills, in.Skills) {
+		return false
+	}
+	if len(r.Budgets) > 0 && !containsAnyFold(r.Budgets, []string{in.CostBudget}) {
+		return false
+	}
+	return true
+}
+
+func containsAnyFold(want, have []string) bool {
+	for _, w := range want {
+		for _, h := range have {
+			if strings.EqualFold(strings.TrimSpace(w), strings.TrimSpace(h)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// normalizeCostBudget 规范化预算档位 (空 = standard)。
+func normalizeCostBudget(value string) (string, error) {
+	budget := strings.ToLower(strings.TrimSpace(value))
+	if budget == "" {
+		return costBudgetStandard, nil
+	}
+	if !costBudgetTiers[budget] {
+		return "", apperrors.Newf("Server.turnStart", "unknown costBudget %q (want low / standard / high)", value)
+	}
+	return budget, nil
+}
+
+// routeTurnModel 按规则选择模型; fallback 为模板 / 启动模型 (可为空)。
+func routeTurnModel(cfg modelRoutingConfig, in modelRouteInput, requested, fallback string, opts *turnRoutingOptions) (modelRouteDecision, error) {
+	decision := modelRouteDecision{CostBudget: in.CostBudget}
+	if model := strings.TrimSpace(requested); model != "" {
+		decision.Model, decision.Source = model, modelRouteSourceRequest
+		return decision, nil
+	}
+	if opts != nil && strings.TrimSpace(opts.Rule) != "" {
+		for _, rule := range cfg.Rules {
+			if strings.EqualFold(strings.TrimSpace(rule.Name), strings.TrimSpace(opts.Rule)) {
+				decision.Model, decision.Source, decision.Rule = strings.TrimSpace(rule.Model), modelRouteSourceRule, rule.Name
+				return decision, nil
+			}
+		}
+		return decision, apperrors.Newf("Server.turnStart", "routing rule %q not found", opts.Rule)
+	}
+	if opts == nil || !opts.Disabled {
+		for _, rule := range cfg.Rules {
+			if rule.matches(in) {
+				decision.Model, decision.Source, decision.Rule = strings.TrimSpace(rule.Model), modelRouteSourceRule, rule.Name
+				return decision, nil
+			}
+		}
+	}
+	decision.Model, decision.Source = strings.TrimSpace(fallback), modelRouteSourceTemplate
+	if decision.Model == "" {
+		decision.Source = modelRouteSourceDefault
+	}
+	return decision, nil
+}
+
+// ========================================
+// 线程当前模型 (路由切换后需要切回)
+// ========================================
+
+// modelRouteTable 记录被路由切换过模型的线程 (threadID → 最近一次 /model 设置的模型)。
+type modelRouteTable struct {
+	mu      sync.Mutex
+	current map[string]string
+}
+
+func (t *modelRouteTable) get(threadID string) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.current[threadID]
+}
+
+func (t *modelRouteTable) set(threadID, model string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.current == nil {
+		t.current = make(map[string]string)
+	}
+	t.current[threadID] = model
+}
+
+// applyTurnModelRoute 在提交前按决策切换线程模型。
+//
+// 仅在命中规则, 或线程此前被路由切换过 (需要切回) 时发送 /model; 重试提交由 turn_retry 自行切换。
+func (s *Server) applyTurnModelRoute(proc *runner.AgentProcess, turn *preparedTurn) {
+	route := turn.Route
+	if route == nil || proc == nil || proc.Client == nil {
+		return
+	}
+	current := s.modelRoutes.get(turn.ThreadID)
+	if turn.Attempt > 1 {
+		if current != "" && route.Model != "" {
+			s.modelRoutes.set(turn.ThreadID, route.Model)
+		}
+		return
+	}
+	if route.Model == "" {
+		// 路由切换过但没有可切回的模型名, 沿用当前模型。
+		route.Model = current
+		return
+	}
+	if strings.EqualFold(route.Model, current) || (route.Source != modelRouteSourceRule && current == "") {
+		return
+	}
+	if err := proc.Client.SendCommand(codex.CmdModel, route.Model); err != nil {
+		logger.Warn("turn/start: routed model switch failed",
+			logger.FieldThreadID, turn.ThreadID, "model", route.Model, "rule", route.Rule, logger.FieldError, err)
+		return
+	}
+	s.modelRoutes.set(turn.ThreadID, route.Model)
+	route.Switched = true
+	logger.Info("turn/start: model routed",
+		logger.FieldThreadID, turn.ThreadID,
+		"model", route.Model,
+		logger.FieldSource, route.Source,
+		"rule", route.Rule,
+		"cost_budget", route.CostBudget,
+	)
+}
+
+// ========================================
+// config/modelRouting/read, config/modelRouting/write
+// ========================================
+
+func decodeModelRoutingConfig(value any) modelRoutingConfig {
+	if value == nil {
+		return modelRoutingConfig{Rules: []modelRoutingRule{}}
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return modelRoutingConfig{Rules: []modelRoutingRule{}}
+	}
+	var cfg modelRoutingConfig
+	if err := json.Unmarshal(raw, &cfg); err != nil || cfg.validate() != nil {
+		logger.Warn("model routing: invalid stored rules ignored", logger.FieldError, err)
+		return modelRoutingConfig{Rules: []modelRoutingRule{}}
+	}
+	if cfg.Rules == nil {
+		cfg.Rules = []modelRoutingRule{}
+	}
+	return cfg
+}
+
+func (s *Server) loadModelRouting(ctx context.Context) modelRoutingConfig {
+	if s.prefManager == nil {
+		return modelRoutingConfig{Rules: []modelRoutingRule{}}
+	}
+	value, err := s.prefManager.Get(ctx, prefKeyModelRouting)
+	if err != nil {
+		logger.Warn("model routing: load preference failed", logger.FieldError, err)
+		return modelRoutingConfig{Rules: []modelRoutingRule{}}
+	}
+	return decodeModelRoutingConfig(value)
+}
+
+func (s *Server) configModelRoutingRead(ctx context.Context, _ json.RawMessage) (any, error) {
+	return map[string]any{
+		"rules":   s.loadModelRouting(ctx).Rules,
+		"budgets": []string{costBudgetLow, costBudgetStandard, costBudgetHigh},
+		"prefKey": prefKeyModelRouting,
+	}, nil
+}
+
+// configModelRoutingWriteParams 整体替换规则列表 (空列表 = 关闭路由)。
+type configModelRoutingWriteParams struct {
+	Rules []modelRoutingRule `json:"rules"`
+}
+
+func (s *Server) configModelRoutingWriteTyped(ctx context.Context, p configModelRoutingWriteParams) (any, error) {
+	if s.prefManager == nil {
+		return nil, apperrors.New("Server.configModelRoutingWrite", "preference manager not initialized")
+	}
+	cfg := modelRoutingConfig{Rules: make([]modelRoutingRule, 0, len(p.Rules))}
+	for _, rule := range p.Rules {
+		rule.Name, rule.Model = strings.TrimSpace(rule.Name), strings.TrimSpace(rule.Model)
+		for i := range rule.Budgets {
+			rule.Budgets[i] = strings.ToLower(strings.TrimSpace(rule.Budgets[i]))
+		}
+		cfg.Rules = append(cfg.Rules, rule)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, apperrors.Wrap(err, "Server.configModelRoutingWrite", "validate rules")
+	}
+	if err := s.prefManager.Set(ctx, prefKeyModelRouting, cfg); err != nil {
+		return nil, err
+	}
+	logger.Info("config/modelRouting/write: saved", "rules", len(cfg.Rules))
+	return map[string]any{"ok": true, "rules": cfg.Rules}, nil
+}
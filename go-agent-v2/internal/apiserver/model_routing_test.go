@@ -0,0 +1,133 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/codex"
+	"github.com/multi-agent/go-agent-v2/internal/runner"
+)
+
+func TestRouteTurnModel_RuleOrderAndOverrides(t *testing.T) {
+	yes := true
+	cfg := modelRoutingConfig{Rules: []modelRoutingRule{
+		{Name: "long", Model: "big-context", MinPromptChars: 50},
+		{Name: "code", Model: "coder", HasCodeBlock: &yes, Budgets: []string{"standard", "high"}},
+		{Name: "review", Model: "reviewer", Skills: []string{"Review"}},
+		{Name: "off", Model: "never", Disabled: true},
+	}}
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	code := "fix\n```go\nx := 1\n```"
+	cases := []struct {
+		name     string
+		in       modelRouteInput
+		request  string
+		opts     *turnRoutingOptions
+		wantRule string
+		wantSrc  string
+		want     string
+	}{
+		{"code block", modelRouteInput{Prompt: code, CostBudget: "standard"}, "", nil, "code", modelRouteSourceRule, "coder"},
+		{"budget excludes", modelRouteInput{Prompt: code, CostBudget: "low"}, "", nil, "", modelRouteSourceTemplate, "tmpl"},
+		{"skill", modelRouteInput{Prompt: "hi", Skills: []string{"review"}, CostBudget: "low"}, "", nil, "review", modelRouteSourceRule, "reviewer"},
+		{"first match wins", modelRouteInput{Prompt: code + string(make([]byte, 60)), CostBudget: "high"}, "", nil, "long", modelRouteSourceRule, "big-context"},
+		{"explicit model", modelRouteInput{Prompt: code, CostBudget: "standard"}, "manual", nil, "", modelRouteSourceRequest, "manual"},
+		{"routing disabled", modelRouteInput{Prompt: code, CostBudget: "standard"}, "", &turnRoutingOptions{Disabled: true}, "", modelRouteSourceTemplate, "tmpl"},
+		{"forced rule", modelRouteInput{Prompt: "hi", CostBudget: "standard"}, "", &turnRoutingOptions{Rule: "REVIEW"}, "review", modelRouteSourceRule, "reviewer"},
+	}
+	for _, tc := range cases {
+		got, err := routeTurnModel(cfg, tc.in, tc.request, "tmpl", tc.opts)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got.Rule != tc.wantRule || got.Source != tc.wantSrc || got.Model != tc.want {
+			t.Errorf("%s: decision = %+v", tc.name, got)
+		}
+	}
+	if _, err := routeTurnModel(cfg, modelRouteInput{}, "", "", &turnRoutingOptions{Rule: "missing"}); err == nil {
+		t.Fatal("unknown forced rule should fail")
+	}
+	if got, _ := routeTurnModel(cfg, modelRouteInput{Prompt: "hi"}, "", "", nil); got.Source != modelRouteSourceDefault {
+		t.Fatalf("no template decision = %+v", got)
+	}
+
+	for _, bad := range []modelRoutingConfig{
+		{Rules: []modelRoutingRule{{Name: "a"}}},
+		{Rules: []modelRoutingRule{{Name: "a", Model: "m", Budgets: []string{"cheap"}}}},
+		{Rules: []modelRoutingRule{{Name: "a", Model: "m", MinPromptChars: 10, MaxPromptChars: 5}}},
+		{Rules: []modelRoutingRule{{Name: "a", Model: "m"}, {Name: "A", Model: "n"}}},
+	} {
+		if err := bad.validate(); err == nil {
+			t.Errorf("%+v: expected validation error", bad)
+		}
+	}
+}
+
+func TestTurnStart_RecordsModelRoute(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	mgr := runner.NewAgentManager()
+	mgr.SetClientFactory(codex.MockClientFactory(nil, 0))
+	srv := New(Deps{Manager: mgr})
+	mgr.SetOnEvent(func(agentID string, event codex.Event) {
+		srv.AgentEventHandler(agentID)(event)
+	})
+	ctx := context.Background()
+
+	if _, err := srv.InvokeMethod(ctx, "config/modelRouting/write", json.RawMessage(
+		`{"rules":[{"name":"code","model":"coder","hasCodeBlock":true,"budgets":["High"]}]}`)); err != nil {
+		t.Fatalf("config/modelRouting/write: %v", err)
+	}
+	res, err := srv.InvokeMethod(ctx, "thread/start", json.RawMessage(`{"cwd":"."}`))
+	if err != nil {
+		t.Fatalf("thread/start: %v", err)
+	}
+	threadID := res.(threadStartResponse).Thread.ID
+
+	start := func(text, budget string) *modelRouteDecision {
+		t.Helper()
+		params, _ := json.Marshal(map[string]any{
+			"threadId":   threadID,
+			"input":      []map[string]any{{"type": "text", "text": text}},
+			"costBudget": budget,
+		})
+		res, err := srv.InvokeMethod(ctx, "turn/start", params)
+		if err != nil {
+			t.Fatalf("turn/start: %v", err)
+		}
+		resp := res.(turnStartResponse)
+		if resp.Route == nil {
+			t.Fatal("turn/start response missing route")
+		}
+		srv.turnMu.Lock()
+		if turn := srv.activeTurns[threadID]; turn != nil && turn.ID == resp.Turn.ID && turn.route != resp.Route {
+			t.Errorf("tracked turn route = %+v, want %+v", turn.route, resp.Route)
+		}
+		srv.turnMu.Unlock()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			if outcome, ok := srv.lastTurnOutcome(threadID); ok && outcome.TurnID == resp.Turn.ID {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("turn did not complete")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return resp.Route
+	}
+
+	if route := start("```sh\nls\n```", "high"); route.Rule != "code" || route.Model != "coder" || !route.Switched {
+		t.Fatalf("routed decision = %+v", route)
+	}
+	// 未命中规则且没有可切回的模型: 沿用路由设置的模型。
+	if route := start("plain", ""); route.Source != modelRouteSourceDefault || route.Model != "coder" || route.CostBudget != costBudgetStandard || route.Switched {
+		t.Fatalf("unrouted decision = %+v", route)
+	}
+	if _, err := srv.InvokeMethod(ctx, "turn/start", json.RawMessage(`{"threadId":"`+threadID+`","input":[{"type":"text","text":"x"}],"costBudget":"huge"}`)); err == nil {
+		t.Fatal("unknown costBudget should be rejected")
+	}
+}
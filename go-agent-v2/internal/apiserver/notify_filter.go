@@ -0,0 +1,193 @@
+// notify_filter.go — 按 WebSocket 连接订阅通知 (subscribe / unsubscribe)。
+//
+// 连接默认接收全部通知。subscribe 追加方法模式与线程 ID 后, 该连接只接收:
+//   - 方法匹配任一模式 (未设模式 = 任意方法; "item/*" 为前缀匹配, "*" = 全部);
+//   - 且线程在所选集合内 (未选线程 = 任意线程; 无 threadId 的全局通知不受线程限制)。
+//
+// unsubscribe 移除指定模式 / 线程, 两者皆空时清空订阅 (恢复接收全部)。
+// 订阅属于连接本身, 在 readLoop 中就地处理; 经 HTTP / InvokeMethod 调用时返回 INVALID_INPUT
+// (stream/coalesce 同理, 见 connectionMethodUnavailable)。
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+const (
+	subscribeMethod        = "subscribe"
+	unsubscribeMethod      = "unsubscribe"
+	maxSubscriptionEntries = 128
+)
+
+// subscriptionParams subscribe / unsubscribe 请求参数。
+type subscriptionParams struct {
+	Methods   []string `json:"methods,omitempty"`
+	ThreadIDs []string `json:"threadIds,omitempty"`
+}
+
+// subscriptionState subscribe / unsubscribe 响应: 连接当前的订阅。
+type subscriptionState struct {
+	Methods   []string `json:"methods"`
+	ThreadIDs []string `json:"threadIds"`
+}
+
+// notifySubscription 单个连接的通知订阅 (零值 = 接收全部)。
+type notifySubscription struct {
+	mu      sync.RWMutex
+	methods map[string]bool
+	threads map[string]bool
+}
+
+// validMethodPattern 模式只允许末尾一个 "*"。
+func validMethodPattern(pattern string) bool {
+	if pattern == "" {
+		return false
+	}
+	i := strings.IndexByte(pattern, '*')
+	return i < 0 || i == len(pattern)-1
+}
+
+func methodMatches(pattern, method string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(method, prefix)
+	}
+	return pattern == method
+}
+
+func normalizeSubscriptionParams(p subscriptionParams) (subscriptionParams, error) {
+	const op = "Server.subscribe"
+	var out subscriptionParams
+	for _, pattern := range p.Methods {
+		pattern = strings.TrimSpace(pattern)
+		if !validMethodPattern(pattern) {
+			return out, apperrors.NewCodef(op, errcode.InvalidInput, "invalid method pattern %q (only a trailing * is allowed)", pattern)
+		}
+		out.Methods = append(out.Methods, pattern)
+	}
+	for _, id := range p.ThreadIDs {
+		if id = strings.TrimSpace(id); id != "" {
+			out.ThreadIDs = append(out.ThreadIDs, id)
+		}
+	}
+	return out, nil
+}
+
+// add 追加订阅, 超出上限时返回错误且不做修改。
+func (f *notifySubscription) add(p subscriptionParams) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.methods == nil {
+		f.methods = make(map[string]bool)
+		f.threads = make(map[string]bool)
+	}
+	if countNew(f.methods, p.Methods) > maxSubscriptionEntries || countNew(f.threads, p.ThreadIDs) > maxSubscriptionEntries {
+		return apperrors.NewCodef("Server.subscribe", errcode.InvalidInput, "subscription exceeds %d methods or threads", maxSubscriptionEntries)
+	}
+	for _, pattern := range p.Methods {
+		f.methods[pattern] = true
+	}
+	for _, id := range p.ThreadIDs {
+		f.threads[id] = true
+	}
+	return nil
+}
+
+func countNew(set map[string]bool, items []string) int {
+	n := len(set)
+	for _, item := range items {
+		if !set[item] {
+			n++
+		}
+	}
+	return n
+}
+
+// remove 移除订阅; 两者皆空时清空。
+func (f *notifySubscription) remove(p subscriptionParams) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(p.Methods) == 0 && len(p.ThreadIDs) == 0 {
+		f.methods, f.threads = nil, nil
+		return
+	}
+	for _, pattern := range p.Methods {
+		delete(f.methods, pattern)
+	}
+	for _, id := range p.ThreadIDs {
+		delete(f.threads, id)
+	}
+}
+
+// allows 通知是否推送给该连接。
+func (f *notifySubscription) allows(method, threadID string) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if len(f.threads) > 0 && threadID != "" && !f.threads[threadID] {
+		return false
+	}
+	if len(f.methods) == 0 {
+		return true
+	}
+	for pattern := range f.methods {
+		if methodMatches(pattern, method) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *notifySubscription) state() subscriptionState {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return subscriptionState{Methods: sortedKeys(f.methods), ThreadIDs: sortedKeys(f.threads)}
+}
+
+func sortedKeys(set map[string]bool) []string {
+	out := make([]string, 0, len(set))
+	for key := range set {
+		out = append(out, key)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// handleSubscriptionMessage 处理连接上的 subscribe / unsubscribe (其他方法返回 false)。
+func handleSubscriptionMessage(sub *notifySubscription, env rpcEnvelope) (*Response, bool) {
+	if env.Method != subscribeMethod && env.Method != unsubscribeMethod {
+		return nil, false
+	}
+	id := rawIDtoAny(env.ID)
+	var p subscriptionParams
+	if len(env.Params) > 0 {
+		if err := json.Unmarshal(env.Params, &p); err != nil {
+			return newHandlerError(id, apperrors.WrapCode(err, "Server.subscribe", errcode.InvalidParams, "unmarshal params")), true
+		}
+	}
+	p, err := normalizeSubscriptionParams(p)
+	if err == nil {
+		if env.Method == subscribeMethod {
+			err = sub.add(p)
+		} else {
+			sub.remove(p)
+		}
+	}
+	if id == nil {
+		return nil, true
+	}
+	if err != nil {
+		return newHandlerError(id, err), true
+	}
+	return newResult(id, sub.state()), true
+}
+
+// connectionMethodUnavailable 连接级方法 (subscribe / unsubscribe / stream/coalesce) 经 HTTP / InvokeMethod 调用时没有所属连接。
+func (s *Server) connectionMethodUnavailable(_ context.Context, _ json.RawMessage) (any, error) {
+	return nil, apperrors.NewCode("Server.connectionMethod", errcode.InvalidInput, "method is only available on WebSocket connections")
+}
@@ -0,0 +1,98 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+)
+
+func TestNotifySubscription_Allows(t *testing.T) {
+	var sub notifySubscription
+	if !sub.allows("item/agentMessage/delta", "t1") {
+		t.Fatal("empty subscription should allow everything")
+	}
+	_ = sub.add(subscriptionParams{Methods: []string{"item/*", "turn/completed"}, ThreadIDs: []string{"t1"}})
+	cases := []struct {
+		method, thread string
+		want           bool
+	}{
+		{"item/agentMessage/delta", "t1", true},
+		{"item/agentMessage/delta", "t2", false},
+		{"turn/completed", "t1", true},
+		{"turn/started", "t1", false},
+		{"item/started", "", true},
+		{"config/changed", "", false},
+	}
+	for _, tc := range cases {
+		if got := sub.allows(tc.method, tc.thread); got != tc.want {
+			t.Errorf("allows(%q, %q) = %v", tc.method, tc.thread, got)
+		}
+	}
+	sub.remove(subscriptionParams{})
+	if !sub.allows("config/changed", "t9") {
+		t.Fatal("cleared subscription should allow everything")
+	}
+	if _, err := normalizeSubscriptionParams(subscriptionParams{Methods: []string{"item/*/delta"}}); err == nil {
+		t.Fatal("expected invalid pattern error")
+	}
+}
+
+func TestSubscribe_FiltersConnectionNotifications(t *testing.T) {
+	srv := New(Deps{})
+	ts := httptest.NewServer(http.HandlerFunc(srv.handleUpgrade))
+	t.Cleanup(ts.Close)
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = ws.Close() })
+	read := func() map[string]any {
+		t.Helper()
+		_ = ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+		var msg map[string]any
+		if err := ws.ReadJSON(&msg); err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		return msg
+	}
+	call := func(id int, method string, params any) map[string]any {
+		t.Helper()
+		if err := ws.WriteJSON(map[string]any{"jsonrpc": "2.0", "id": id, "method": method, "params": params}); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		return read()
+	}
+
+	msg := call(1, "subscribe", map[string]any{"methods": []string{"item/*"}, "threadIds": []string{"thread-a"}})
+	if got, _ := json.Marshal(msg["result"]); string(got) != `{"methods":["item/*"],"threadIds":["thread-a"]}` {
+		t.Fatalf("subscribe = %#v", msg)
+	}
+	srv.Notify("item/agentMessage/delta", map[string]any{"threadId": "thread-b", "delta": "other thread"})
+	srv.Notify("turn/started", map[string]any{"threadId": "thread-a"})
+	srv.Notify("item/agentMessage/delta", map[string]any{"threadId": "thread-a", "delta": "wanted"})
+	if msg := read(); msg["params"].(map[string]any)["delta"] != "wanted" {
+		t.Fatalf("notification = %#v", msg)
+	}
+
+	call(2, "unsubscribe", nil)
+	srv.Notify("turn/started", map[string]any{"threadId": "thread-b"})
+	for {
+		msg := read()
+		if msg["method"] == "turn/started" {
+			break
+		}
+		if msg["method"] != "ui/state/changed" {
+			t.Fatalf("notification after unsubscribe = %#v", msg)
+		}
+	}
+
+	if _, err := srv.InvokeMethod(t.Context(), "subscribe", nil); err == nil || !strings.Contains(err.Error(), errcode.InvalidInput) {
+		t.Fatalf("InvokeMethod subscribe err = %v", err)
+	}
+}
@@ -0,0 +1,558 @@
+// notify_sinks.go — 外部通知渠道 (Slack webhook / SMTP 邮件 / 通用 webhook)。
+//
+// 渠道配置存于数据库 (notify_channels), 每个渠道按事件类型与 agent 过滤:
+//   - agent.stuck       turn 长时间无事件 (stall 看门狗进入宽限期)
+//   - agent.unhealthy   健康检查判定 unhealthy
+//   - approval.pending  审批请求超过阈值 (默认 5 分钟) 仍未处理
+//   - turn.failed       turn 以 failed 结束
+//
+// 同一渠道 + 事件 + agent 在冷却期内只投递一次, 避免抖动刷屏; 投递失败只记日志, 不影响主流程。
+// notify/channel/test 绕过过滤与冷却, 同步返回投递结果。
+package apiserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/smtp"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/store"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+	"github.com/multi-agent/go-agent-v2/pkg/util"
+)
+
+const (
+	notifyEventAgentStuck      = "agent.stuck"
+	notifyEventAgentUnhealthy  = "agent.unhealthy"
+	notifyEventApprovalPending = "approval.pending"
+	notifyEventTurnFailed      = "turn.failed"
+	notifyEventTest            = "test"
+
+	notifyKindSlack   = "slack"
+	notifyKindEmail   = "email"
+	notifyKindWebhook = "webhook"
+
+	notifyChannelCacheTTL      = 30 * time.Second
+	notifyCooldown             = 5 * time.Minute
+	notifySendTimeout          = 10 * time.Second
+	defaultApprovalNotifyAfter = 5 * time.Minute
+)
+
+var notifyEventTypes = []string{notifyEventAgentStuck, notifyEventAgentUnhealthy, notifyEventApprovalPending, notifyEventTurnFailed}
+
+// notifyEvent 投递给外部渠道的事件。
+type notifyEvent struct {
+	Type      string         `json:"type"`
+	AgentID   string         `json:"agentId,omitempty"`
+	AgentName string         `json:"agentName,omitempty"`
+	Title     string         `json:"title"`
+	Detail    string         `json:"detail,omitempty"`
+	At        time.Time      `json:"at"`
+	Fields    map[string]any `json:"fields,omitempty"`
+}
+
+// text 渲染为纯文本消息 (Slack text / 邮件正文)。
+func (e notifyEvent) text() string {
+	var b strings.Builder
+	b.WriteString("[multi-agent] ")
+	b.WriteString(e.Title)
+	if e.AgentID != "" {
+		b.WriteString("\nagent: ")
+		if e.AgentName != "" {
+			b.WriteString(e.AgentName + " (" + e.AgentID + ")")
+		} else {
+			b.WriteString(e.AgentID)
+		}
+	}
+	if e.Detail != "" {
+		b.WriteString("\n")
+		b.WriteString(e.Detail)
+	}
+	b.WriteString("\nat: ")
+	b.WriteString(e.At.Format(time.RFC3339))
+	return b.String()
+}
+
+// notifySinkHub 渠道缓存 + 投递冷却 (零值可用)。
+type notifySinkHub struct {
+	mu       sync.Mutex
+	channels []store.NotifyChannel
+	loadedAt time.Time
+	lastSent map[string]time.Time
+
+	approvalAfter time.Duration // 审批等待多久后通知, 0 = defaultApprovalNotifyAfter
+	client        *http.Client  // nil = 默认超时客户端
+	sendMail      func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error
+}
+
+func (h *notifySinkHub) invalidate() {
+	h.mu.Lock()
+	h.loadedAt = time.Time{}
+	h.mu.Unlock()
+}
+
+// claim 检查并记录冷却, 冷却期内返回 false。
+func (h *notifySinkHub) claim(channelID int64, ev notifyEvent, now time.Time) bool {
+	key := strconv.FormatInt(channelID, 10) + "\x00" + ev.Type + "\x00" + ev.AgentID
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.lastSent == nil {
+		h.lastSent = make(map[string]time.Time)
+	}
+	if last, ok := h.lastSent[key]; ok && now.Sub(last) < notifyCooldown {
+		return false
+	}
+	h.lastSent[key] = now
+	for k, t := range h.lastSent {
+		if now.Sub(t) >= notifyCooldown {
+			delete(h.lastSent, k)
+		}
+	}
+	return true
+}
+
+func (h *notifySinkHub) approvalDelay() time.Duration {
+	if h.approvalAfter > 0 {
+		return h.approvalAfter
+	}
+	return defaultApprovalNotifyAfter
+}
+
+func (h *notifySinkHub) httpClient() *http.Client {
+	if h.client != nil {
+		return h.client
+	}
+	return &http.Client{Timeout: notifySendTimeout}
+}
+
+// notifyChannels 返回渠道列表 (带缓存; 无 store 时仅使用内存缓存)。
+func (s *Server) notifyChannels(ctx context.Context) []store.NotifyChannel {
+	h := &s.notifySinks
+	h.mu.Lock()
+	fresh := s.notifyChannelStore == nil || (!h.loadedAt.IsZero() && time.Since(h.loadedAt) < notifyChannelCacheTTL)
+	if fresh {
+		channels := h.channels
+		h.mu.Unlock()
+		return channels
+	}
+	h.mu.Unlock()
+
+	channels, err := s.notifyChannelStore.List(ctx)
+	if err != nil {
+		logger.Warn("notify: load channels failed", logger.FieldError, err)
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		return h.channels
+	}
+	h.mu.Lock()
+	h.channels = channels
+	h.loadedAt = time.Now()
+	h.mu.Unlock()
+	return channels
+}
+
+// notifyChannelMatches 判断渠道是否订阅该事件。
+func notifyChannelMatches(c store.NotifyChannel, ev notifyEvent) bool {
+	if !c.Enabled {
+		return false
+	}
+	if len(c.Events) > 0 && !slices.Contains(c.Events, ev.Type) {
+		return false
+	}
+	return len(c.AgentIDs) == 0 || slices.Contains(c.AgentIDs, ev.AgentID)
+}
+
+// emitNotifyEvent 异步投递事件到所有匹配渠道。
+func (s *Server) emitNotifyEvent(ev notifyEvent) {
+	if ev.At.IsZero() {
+		ev.At = time.Now()
+	}
+	if ev.AgentID != "" && ev.AgentName == "" {
+		ev.AgentName = s.threadDisplayName(ev.AgentID)
+	}
+	util.SafeGo(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), notifySendTimeout*3)
+		defer cancel()
+		for _, c := range s.notifyChannels(ctx) {
+			if !notifyChannelMatches(c, ev) || !s.notifySinks.claim(c.ID, ev, ev.At) {
+				continue
+			}
+			if err := s.sendNotify(ctx, c, ev); err != nil {
+				logger.Warn("notify: deliver failed",
+					"channel", c.Name,
+					"kind", c.Kind,
+					"event", ev.Type,
+					logger.FieldAgentID, ev.AgentID,
+					logger.FieldError, err,
+				)
+			}
+		}
+	})
+}
+
+// sendNotify 按渠道类型投递单个事件。
+func (s *Server) sendNotify(ctx context.Context, c store.NotifyChannel, ev notifyEvent) error {
+	switch c.Kind {
+	case notifyKindSlack:
+		return s.postNotifyJSON(ctx, notifyConfigString(c.Config, "webhookUrl"), nil, map[string]any{"text": ev.text()})
+	case notifyKindWebhook:
+		body := map[string]any{"event": ev, "text": ev.text()}
+		return s.postNotifyJSON(ctx, notifyConfigString(c.Config, "url"), notifyConfigHeaders(c.Config), body)
+	case notifyKindEmail:
+		return s.sendNotifyMail(c.Config, ev)
+	default:
+		return apperrors.Newf("Server.sendNotify", "unsupported channel kind %q", c.Kind)
+	}
+}
+
+func (s *Server) postNotifyJSON(ctx context.Context, url string, headers map[string]string, body any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return apperrors.Wrap(err, "Server.postNotifyJSON", "encode body")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return apperrors.Wrap(err, "Server.postNotifyJSON", "build request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := s.notifySinks.httpClient().Do(req)
+	if err != nil {
+		return apperrors.Wrap(err, "Server.postNotifyJSON", "post")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return apperrors.Newf("Server.postNotifyJSON", "http %d: %s", resp.StatusCode, strings.TrimSpace(string(snippet)))
+	}
+	return nil
+}
+
+func (s *Server) sendNotifyMail(cfg map[string]any, ev notifyEvent) error {
+	host := notifyConfigString(cfg, "host")
+	port := notifyConfigString(cfg, "port")
+	if port == "" {
+		port = "587"
+	}
+	from := notifyConfigString(cfg, "from")
+	to := notifyConfigStrings(cfg, "to")
+
+	var auth smtp.Auth
+	if user := notifyConfigString(cfg, "username"); user != "" {
+		password := ""
+		if env := notifyConfigString(cfg, "passwordEnv"); env != "" {
+			password = os.Getenv(env)
+		}
+		auth = smtp.PlainAuth("", user, password, host)
+	}
+
+	subject := "[multi-agent] " + ev.Title
+	msg := "From: " + from + "\r\n" +
+		"To: " + strings.Join(to, ", ") + "\r\n" +
+		"Subject: " + strings.ReplaceAll(subject, "\n", " ") + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n\r\n" +
+		strings.ReplaceAll(ev.text(), "\n", "\r\n") + "\r\n"
+
+	send := s.notifySinks.sendMail
+	if send == nil {
+		send = smtp.SendMail
+	}
+	if err := send(host+":"+port, auth, from, to, []byte(msg)); err != nil {
+		return apperrors.Wrap(err, "Server.sendNotifyMail", "smtp send")
+	}
+	return nil
+}
+
+func notifyConfigString(cfg map[string]any, key string) string {
+	switch v := cfg[key].(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return ""
+	}
+}
+
+func notifyConfigStrings(cfg map[string]any, key string) []string {
+	var out []string
+	switch v := cfg[key].(type) {
+	case string:
+		for _, part := range strings.Split(v, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				out = append(out, part)
+			}
+		}
+	case []any:
+		for _, item := range v {
+			if str, ok := item.(string); ok && strings.TrimSpace(str) != "" {
+				out = append(out, strings.TrimSpace(str))
+			}
+		}
+	case []string:
+		out = append(out, v...)
+	}
+	return out
+}
+
+func notifyConfigHeaders(cfg map[string]any) map[string]string {
+	raw, _ := cfg["headers"].(map[string]any)
+	headers := make(map[string]string, len(raw))
+	for k, v := range raw {
+		if str, ok := v.(string); ok {
+			headers[k] = str
+		}
+	}
+	return headers
+}
+
+// validateNotifyChannel 校验渠道名称、类型、事件与类型专属配置。
+func validateNotifyChannel(c *store.NotifyChannel) error {
+	const op = "Server.validateNotifyChannel"
+	c.Name = strings.TrimSpace(c.Name)
+	c.Kind = strings.ToLower(strings.TrimSpace(c.Kind))
+	if c.Name == "" {
+		return apperrors.NewCode(op, errcode.InvalidInput, "name is required")
+	}
+	for _, ev := range c.Events {
+		if !slices.Contains(notifyEventTypes, ev) {
+			return apperrors.NewCodef(op, errcode.InvalidInput, "unknown event %q (supported: %s)", ev, strings.Join(notifyEventTypes, ", "))
+		}
+	}
+	isURL := func(v string) bool { return strings.HasPrefix(v, "https://") || strings.HasPrefix(v, "http://") }
+	switch c.Kind {
+	case notifyKindSlack:
+		if !isURL(notifyConfigString(c.Config, "webhookUrl")) {
+			return apperrors.NewCode(op, errcode.InvalidInput, "slack channel requires config.webhookUrl")
+		}
+	case notifyKindWebhook:
+		if !isURL(notifyConfigString(c.Config, "url")) {
+			return apperrors.NewCode(op, errcode.InvalidInput, "webhook channel requires config.url")
+		}
+	case notifyKindEmail:
+		if notifyConfigString(c.Config, "host") == "" || notifyConfigString(c.Config, "from") == "" || len(notifyConfigStrings(c.Config, "to")) == 0 {
+			return apperrors.NewCode(op, errcode.InvalidInput, "email channel requires config.host, config.from and config.to")
+		}
+		if _, ok := c.Config["password"]; ok {
+			return apperrors.NewCode(op, errcode.InvalidInput, "email password must be provided via config.passwordEnv")
+		}
+	default:
+		return apperrors.NewCodef(op, errcode.InvalidInput, "unsupported kind %q (slack / email / webhook)", c.Kind)
+	}
+	return nil
+}
+
+// ========================================
+// 事件触发点
+// ========================================
+
+// notifyTurnFailed turn 以 failed 结束。
+func (s *Server) notifyTurnFailed(threadID, turnID, reason string, duration time.Duration) {
+	s.emitNotifyEvent(notifyEvent{
+		Type:    notifyEventTurnFailed,
+		AgentID: threadID,
+		Title:   "turn failed",
+		Detail:  reason,
+		Fields:  map[string]any{"turnId": turnID, "durationMs": duration.Milliseconds()},
+	})
+}
+
+// notifyAgentStuck turn 长时间无事件 (stall 宽限期开始)。
+func (s *Server) notifyAgentStuck(threadID, turnID string, silent time.Duration) {
+	s.emitNotifyEvent(notifyEvent{
+		Type:    notifyEventAgentStuck,
+		AgentID: threadID,
+		Title:   "agent stuck",
+		Detail:  fmt.Sprintf("no events for %ds", int(silent.Seconds())),
+		Fields:  map[string]any{"turnId": turnID, "silentMs": silent.Milliseconds()},
+	})
+}
+
+// watchApprovalPending 审批超过阈值仍未处理时发出通知; 返回的 stop 在审批结束时调用。
+func (s *Server) watchApprovalPending(agentID, method string) (stop func() bool) {
+	delay := s.notifySinks.approvalDelay()
+	timer := time.AfterFunc(delay, func() {
+		s.emitNotifyEvent(notifyEvent{
+			Type:    notifyEventApprovalPending,
+			AgentID: agentID,
+			Title:   "approval pending",
+			Detail:  fmt.Sprintf("%s waiting for more than %ds", method, int(delay.Seconds())),
+			Fields:  map[string]any{"method": method},
+		})
+	})
+	return timer.Stop
+}
+
+// ========================================
+// notify/channel/*
+// ========================================
+
+type notifyChannelCreateParams struct {
+	Name     string         `json:"name"`
+	Kind     string         `json:"kind"`
+	Config   map[string]any `json:"config"`
+	Events   []string       `json:"events"`
+	AgentIDs []string       `json:"agentIds"`
+	Enabled  *bool          `json:"enabled,omitempty"`
+}
+
+type notifyChannelUpdateParams struct {
+	ID       int64          `json:"id"`
+	Name     *string        `json:"name,omitempty"`
+	Config   map[string]any `json:"config,omitempty"`
+	Events   *[]string      `json:"events,omitempty"`
+	AgentIDs *[]string      `json:"agentIds,omitempty"`
+	Enabled  *bool          `json:"enabled,omitempty"`
+}
+
+type notifyChannelIDParams struct {
+	ID int64 `json:"id"`
+}
+
+func (s *Server) requireNotifyChannelStore(op string) error {
+	if s.notifyChannelStore == nil {
+		return apperrors.New(op, "notify channel store unavailable")
+	}
+	return nil
+}
+
+func (s *Server) notifyChannelList(ctx context.Context, _ json.RawMessage) (any, error) {
+	if err := s.requireNotifyChannelStore("Server.notifyChannelList"); err != nil {
+		return nil, err
+	}
+	channels, err := s.notifyChannelStore.List(ctx)
+	if err != nil {
+		return nil, apperrors.Wrap(err, "Server.notifyChannelList", "list channels")
+	}
+	if channels == nil {
+		channels = []store.NotifyChannel{}
+	}
+	return map[string]any{"channels": channels, "events": notifyEventTypes}, nil
+}
+
+func (s *Server) notifyChannelCreateTyped(ctx context.Context, p notifyChannelCreateParams) (any, error) {
+	if err := s.requireNotifyChannelStore("Server.notifyChannelCreate"); err != nil {
+		return nil, err
+	}
+	c := &store.NotifyChannel{
+		Name: p.Name, Kind: p.Kind, Config: p.Config, Events: p.Events, AgentIDs: p.AgentIDs,
+		Enabled: p.Enabled == nil || *p.Enabled,
+	}
+	if err := validateNotifyChannel(c); err != nil {
+		return nil, err
+	}
+	created, err := s.notifyChannelStore.Create(ctx, c)
+	if err != nil {
+		return nil, apperrors.Wrap(err, "Server.notifyChannelCreate", "create channel")
+	}
+	s.notifySinks.invalidate()
+	logger.Info("notify: channel created", "channel", created.Name, "kind", created.Kind, "events", created.Events)
+	return map[string]any{"channel": created}, nil
+}
+
+func (s *Server) notifyChannelUpdateTyped(ctx context.Context, p notifyChannelUpdateParams) (any, error) {
+	if err := s.requireNotifyChannelStore("Server.notifyChannelUpdate"); err != nil {
+		return nil, err
+	}
+	c, err := s.loadNotifyChannel(ctx, "Server.notifyChannelUpdate", p.ID)
+	if err != nil {
+		return nil, err
+	}
+	if p.Name != nil {
+		c.Name = *p.Name
+	}
+	if p.Config != nil {
+		c.Config = p.Config
+	}
+	if p.Events != nil {
+		c.Events = *p.Events
+	}
+	if p.AgentIDs != nil {
+		c.AgentIDs = *p.AgentIDs
+	}
+	if p.Enabled != nil {
+		c.Enabled = *p.Enabled
+	}
+	if err := validateNotifyChannel(c); err != nil {
+		return nil, err
+	}
+	updated, err := s.notifyChannelStore.Update(ctx, c)
+	if err != nil {
+		return nil, apperrors.Wrap(err, "Server.notifyChannelUpdate", "update channel")
+	}
+	s.notifySinks.invalidate()
+	return map[string]any{"channel": updated}, nil
+}
+
+func (s *Server) notifyChannelDeleteTyped(ctx context.Context, p notifyChannelIDParams) (any, error) {
+	if err := s.requireNotifyChannelStore("Server.notifyChannelDelete"); err != nil {
+		return nil, err
+	}
+	deleted, err := s.notifyChannelStore.Delete(ctx, p.ID)
+	if err != nil {
+		return nil, apperrors.Wrap(err, "Server.notifyChannelDelete", "delete channel")
+	}
+	if !deleted {
+		return nil, apperrors.Wrapf(apperrors.ErrNotFound, "Server.notifyChannelDelete", "channel %d", p.ID)
+	}
+	s.notifySinks.invalidate()
+	return map[string]any{"ok": true}, nil
+}
+
+// notifyChannelTestTyped 向指定渠道同步发送测试消息 (忽略过滤条件、启用状态与冷却)。
+func (s *Server) notifyChannelTestTyped(ctx context.Context, p notifyChannelIDParams) (any, error) {
+	if err := s.requireNotifyChannelStore("Server.notifyChannelTest"); err != nil {
+		return nil, err
+	}
+	c, err := s.loadNotifyChannel(ctx, "Server.notifyChannelTest", p.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.sendNotifyTest(ctx, *c); err != nil {
+		return nil, err
+	}
+	return map[string]any{"ok": true, "channel": c.Name}, nil
+}
+
+func (s *Server) sendNotifyTest(ctx context.Context, c store.NotifyChannel) error {
+	sendCtx, cancel := context.WithTimeout(ctx, notifySendTimeout)
+	defer cancel()
+	ev := notifyEvent{
+		Type:   notifyEventTest,
+		Title:  "test notification",
+		Detail: fmt.Sprintf("channel %q (%s) is configured correctly", c.Name, c.Kind),
+		At:     time.Now(),
+	}
+	if err := s.sendNotify(sendCtx, c, ev); err != nil {
+		return apperrors.Wrapf(err, "Server.notifyChannelTest", "send to %s", c.Name)
+	}
+	return nil
+}
+
+func (s *Server) loadNotifyChannel(ctx context.Context, op string, id int64) (*store.NotifyChannel, error) {
+	if id <= 0 {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "id is required")
+	}
+	c, err := s.notifyChannelStore.Get(ctx, id)
+	if err != nil {
+		return nil, apperrors.Wrap(err, op, "get channel")
+	}
+	if c == nil {
+		return nil, apperrors.Wrapf(apperrors.ErrNotFound, op, "channel %d", id)
+	}
+	return c, nil
+}
@@ -0,0 +1,144 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/store"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+// newNotifySinkTestServer 返回预置渠道缓存的 Server 与 webhook 请求体通道。
+func newNotifySinkTestServer(t *testing.T, channels func(url string) []store.NotifyChannel) (*Server, <-chan map[string]any) {
+	t.Helper()
+	bodies := make(chan map[string]any, 8)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var body map[string]any
+		_ = json.Unmarshal(data, &body)
+		body["_auth"] = r.Header.Get("Authorization")
+		bodies <- body
+	}))
+	t.Cleanup(hook.Close)
+	srv := &Server{}
+	srv.notifySinks.channels = channels(hook.URL)
+	return srv, bodies
+}
+
+func waitNotifyBody(t *testing.T, bodies <-chan map[string]any) map[string]any {
+	t.Helper()
+	select {
+	case body := <-bodies:
+		return body
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for notification delivery")
+		return nil
+	}
+}
+
+func TestEmitNotifyEventFiltersAndCooldown(t *testing.T) {
+	srv, bodies := newNotifySinkTestServer(t, func(url string) []store.NotifyChannel {
+		return []store.NotifyChannel{
+			{ID: 1, Name: "ops-slack", Kind: notifyKindSlack, Enabled: true, Events: []string{notifyEventTurnFailed}, Config: map[string]any{"webhookUrl": url}},
+			{ID: 2, Name: "disabled", Kind: notifyKindSlack, Enabled: false, Config: map[string]any{"webhookUrl": url}},
+			{ID: 3, Name: "other-agent", Kind: notifyKindSlack, Enabled: true, AgentIDs: []string{"thread-9"}, Config: map[string]any{"webhookUrl": url}},
+		}
+	})
+
+	srv.notifyTurnFailed("thread-1", "turn-1", "model error", 3*time.Second)
+	body := waitNotifyBody(t, bodies)
+	if text, _ := body["text"].(string); !strings.Contains(text, "turn failed") || !strings.Contains(text, "model error") {
+		t.Fatalf("slack text = %q", text)
+	}
+
+	// 冷却期内同一渠道 + 事件 + agent 不重复投递; 未订阅的事件不投递
+	srv.notifyTurnFailed("thread-1", "turn-2", "again", time.Second)
+	srv.notifyAgentStuck("thread-1", "turn-2", time.Minute)
+	select {
+	case extra := <-bodies:
+		t.Fatalf("unexpected delivery: %+v", extra)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestWatchApprovalPendingPostsWebhook(t *testing.T) {
+	srv, bodies := newNotifySinkTestServer(t, func(url string) []store.NotifyChannel {
+		return []store.NotifyChannel{{
+			ID: 1, Name: "pager", Kind: notifyKindWebhook, Enabled: true,
+			Config: map[string]any{"url": url, "headers": map[string]any{"Authorization": "Bearer t0k"}},
+		}}
+	})
+	srv.notifySinks.approvalAfter = 10 * time.Millisecond
+
+	stop := srv.watchApprovalPending("thread-1", "item/commandExecution/requestApproval")
+	defer stop()
+	body := waitNotifyBody(t, bodies)
+	event, _ := body["event"].(map[string]any)
+	if event["type"] != notifyEventApprovalPending || event["agentId"] != "thread-1" || body["_auth"] != "Bearer t0k" {
+		t.Fatalf("webhook body = %+v", body)
+	}
+
+	// 审批及时处理: stop 后不再通知
+	stopped := srv.watchApprovalPending("thread-2", "item/fileChange/requestApproval")
+	if !stopped() {
+		t.Fatal("stop should cancel the pending timer")
+	}
+}
+
+func TestSendNotifyMail(t *testing.T) {
+	t.Setenv("NOTIFY_TEST_SMTP_PASSWORD", "secret")
+	srv := &Server{}
+	var gotAddr string
+	var gotTo []string
+	var gotMsg string
+	srv.notifySinks.sendMail = func(addr string, auth smtp.Auth, _ string, to []string, msg []byte) error {
+		if auth == nil {
+			t.Fatal("auth should be configured when username is set")
+		}
+		gotAddr, gotTo, gotMsg = addr, to, string(msg)
+		return nil
+	}
+	err := srv.sendNotifyTest(context.Background(), store.NotifyChannel{
+		Name: "oncall-mail", Kind: notifyKindEmail,
+		Config: map[string]any{
+			"host": "smtp.example.com", "port": float64(2525), "from": "bot@example.com",
+			"to": []any{"a@example.com", "b@example.com"}, "username": "bot", "passwordEnv": "NOTIFY_TEST_SMTP_PASSWORD",
+		},
+	})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if gotAddr != "smtp.example.com:2525" || len(gotTo) != 2 {
+		t.Fatalf("addr = %s, to = %v", gotAddr, gotTo)
+	}
+	if !strings.Contains(gotMsg, "Subject: [multi-agent] test notification\r\n") {
+		t.Fatalf("message = %q", gotMsg)
+	}
+}
+
+func TestValidateNotifyChannel(t *testing.T) {
+	valid := &store.NotifyChannel{Name: " ops ", Kind: "Slack", Config: map[string]any{"webhookUrl": "https://hooks.slack.com/x"}}
+	if err := validateNotifyChannel(valid); err != nil || valid.Kind != notifyKindSlack || valid.Name != "ops" {
+		t.Fatalf("valid channel: %v (%+v)", err, valid)
+	}
+	for _, c := range []*store.NotifyChannel{
+		{Name: "", Kind: notifyKindSlack},
+		{Name: "x", Kind: "sms"},
+		{Name: "x", Kind: notifyKindWebhook, Config: map[string]any{"url": "ftp://host"}},
+		{Name: "x", Kind: notifyKindEmail, Config: map[string]any{"host": "h", "from": "f"}},
+		{Name: "x", Kind: notifyKindEmail, Config: map[string]any{"host": "h", "from": "f", "to": "t", "password": "p"}},
+		{Name: "x", Kind: notifyKindSlack, Events: []string{"turn.exploded"}, Config: map[string]any{"webhookUrl": "https://h"}},
+	} {
+		if err := validateNotifyChannel(c); apperrors.CodeOf(err) != errcode.InvalidInput {
+			t.Fatalf("validate(%+v) = %v, want INVALID_INPUT", c, err)
+		}
+	}
+}
@@ -0,0 +1,299 @@
+// orchestrate_fanout.go — orchestrate/fanout: 同一输入并发发送到多个线程, 汇总各 agent 的回答。
+//
+// orchestrate/fanout 并发对每个目标线程执行 turn/start (跳过跨线程去重), 立即返回 fanoutId;
+// orchestrate/fanout/result 按 turn/await 的方式轮询各目标 turn, 全部结束、达到 quorum
+// 或超时即返回。超时返回的是部分结果 (partial=true), 调用方可再次调用继续等待,
+// 用于多 agent 共识 / 对比工作流。
+package apiserver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+	"github.com/multi-agent/go-agent-v2/pkg/util"
+)
+
+const (
+	maxFanoutTargets = 32
+	maxFanoutJobs    = 64 // 超出后淘汰最早的 fan-out 记录
+)
+
+// fanoutTarget 单个目标线程的派发状态。
+type fanoutTarget struct {
+	ThreadID string `json:"threadId"`
+	TurnID   string `json:"turnId,omitempty"`
+	Dispatch string `json:"dispatch"` // started / queued / held / failed
+	Error    string `json:"error,omitempty"`
+}
+
+// fanoutJob 一次 fan-out 的全部目标。
+type fanoutJob struct {
+	ID        string         `json:"fanoutId"`
+	CreatedAt time.Time      `json:"createdAt"`
+	Targets   []fanoutTarget `json:"targets"`
+}
+
+// fanoutHub fan-out 登记 (零值可用)。
+type fanoutHub struct {
+	mu   sync.Mutex
+	seq  int64
+	jobs map[string]*fanoutJob // fanoutId →
+}
+
+func (h *fanoutHub) add(job *fanoutJob) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.jobs == nil {
+		h.jobs = make(map[string]*fanoutJob)
+	}
+	if len(h.jobs) >= maxFanoutJobs {
+		oldestID := ""
+		for id, existing := range h.jobs {
+			if oldestID == "" || existing.CreatedAt.Before(h.jobs[oldestID].CreatedAt) {
+				oldestID = id
+			}
+		}
+		delete(h.jobs, oldestID)
+	}
+	h.seq++
+	job.ID = fmt.Sprintf("fanout-%d-%d", job.CreatedAt.UnixMilli(), h.seq)
+	h.jobs[job.ID] = job
+}
+
+// snapshot 返回目标列表副本 (结果轮询期间会回填排队 turn 的 ID)。
+func (h *fanoutHub) snapshot(id string) (fanoutJob, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	job, ok := h.jobs[id]
+	if !ok {
+		return fanoutJob{}, false
+	}
+	out := *job
+	out.Targets = append([]fanoutTarget(nil), job.Targets...)
+	return out, true
+}
+
+func (h *fanoutHub) setTurnID(id, threadID, turnID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if job, ok := h.jobs[id]; ok {
+		for i := range job.Targets {
+			if job.Targets[i].ThreadID == threadID && job.Targets[i].TurnID == "" {
+				job.Targets[i].TurnID = turnID
+			}
+		}
+	}
+}
+
+type orchestrateFanoutParams struct {
+	ThreadIDs []string    `json:"threadIds"`
+	Input     []UserInput `json:"input,omitempty"`
+	Prompt    string      `json:"prompt,omitempty"` // input 为空时的纯文本简写
+	Model     string      `json:"model,omitempty"`
+	Priority  string      `json:"priority,omitempty"`
+}
+
+func (s *Server) orchestrateFanoutTyped(ctx context.Context, p orchestrateFanoutParams) (any, error) {
+	const op = "Server.orchestrateFanout"
+	threadIDs := make([]string, 0, len(p.ThreadIDs))
+	seen := make(map[string]bool, len(p.ThreadIDs))
+	for _, id := range p.ThreadIDs {
+		if id = strings.TrimSpace(id); id != "" && !seen[id] {
+			seen[id] = true
+			threadIDs = append(threadIDs, id)
+		}
+	}
+	if len(threadIDs) == 0 {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "threadIds is required")
+	}
+	if len(threadIDs) > maxFanoutTargets {
+		return nil, apperrors.NewCodef(op, errcode.InvalidInput, "too many threads (%d > %d)", len(threadIDs), maxFanoutTargets)
+	}
+	input := p.Input
+	if len(input) == 0 && strings.TrimSpace(p.Prompt) != "" {
+		input = []UserInput{{Type: "text", Text: p.Prompt}}
+	}
+	if len(input) == 0 {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "input or prompt is required")
+	}
+	if err := s.frozenError(op); err != nil {
+		return nil, err
+	}
+	if err := s.drainingError(op); err != nil {
+		return nil, err
+	}
+
+	job := &fanoutJob{CreatedAt: time.Now(), Targets: make([]fanoutTarget, len(threadIDs))}
+	done := make(chan struct{}, len(threadIDs))
+	for i, threadID := range threadIDs {
+		i, threadID := i, threadID
+		util.SafeGo(func() {
+			defer func() { done <- struct{}{} }()
+			job.Targets[i] = s.dispatchFanoutTurn(ctx, threadID, p, input)
+		})
+	}
+	for range threadIDs {
+		<-done
+	}
+	s.fanouts.add(job)
+
+	failed := 0
+	for _, target := range job.Targets {
+		if target.Dispatch == "failed" {
+			failed++
+		}
+	}
+	logger.Info("orchestrate/fanout: dispatched",
+		"fanout_id", job.ID, logger.FieldCount, len(job.Targets), "failed", failed)
+	return map[string]any{
+		"fanoutId": job.ID,
+		"targets":  job.Targets,
+		"failed":   failed,
+	}, nil
+}
+
+// dispatchFanoutTurn 对单个线程执行 turn/start, 派发失败记入 Error 而非中止整个 fan-out。
+func (s *Server) dispatchFanoutTurn(ctx context.Context, threadID string, p orchestrateFanoutParams, input []UserInput) fanoutTarget {
+	target := fanoutTarget{ThreadID: threadID}
+	res, err := s.turnStartTyped(ctx, turnStartParams{
+		ThreadID:    threadID,
+		Input:       append([]UserInput(nil), input...),
+		Model:       p.Model,
+		Priority:    p.Priority,
+		BypassDedup: true, // 同一输入发往多个线程正是 fan-out 的目的
+	})
+	if err != nil {
+		target.Dispatch = "failed"
+		target.Error = err.Error()
+		return target
+	}
+	resp, _ := res.(turnStartResponse)
+	target.TurnID = resp.Turn.ID
+	switch {
+	case resp.Queue != nil:
+		target.Dispatch = "queued"
+	case resp.Held != nil:
+		target.Dispatch = "held"
+	default:
+		target.Dispatch = "started"
+	}
+	return target
+}
+
+type orchestrateFanoutResultParams struct {
+	FanoutID   string `json:"fanoutId"`
+	TimeoutSec *int   `json:"timeoutSec,omitempty"` // 0 = 仅查询不等待
+	Quorum     int    `json:"quorum,omitempty"`     // 已结束数量达到即返回, 0 = 等待全部
+}
+
+// fanoutResult 单个目标的结果。
+type fanoutResult struct {
+	ThreadID    string `json:"threadId"`
+	TurnID      string `json:"turnId,omitempty"`
+	Status      string `json:"status"` // running / queued / held / completed / failed / interrupted / idle
+	Summary     string `json:"summary,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+	Error       string `json:"error,omitempty"`
+	CompletedAt int64  `json:"completedAt,omitempty"`
+	Done        bool   `json:"done"`
+}
+
+func (s *Server) orchestrateFanoutResultTyped(ctx context.Context, p orchestrateFanoutResultParams) (any, error) {
+	const op = "Server.orchestrateFanoutResult"
+	id := strings.TrimSpace(p.FanoutID)
+	if id == "" {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "fanoutId is required")
+	}
+	if _, ok := s.fanouts.snapshot(id); !ok {
+		return nil, apperrors.NewCodef(op, errcode.InvalidInput, "fanout %s not found", id)
+	}
+	timeoutSec := defaultTurnAwaitTimeoutSec
+	if p.TimeoutSec != nil {
+		timeoutSec = *p.TimeoutSec
+	}
+	if timeoutSec < 0 || timeoutSec > maxTurnAwaitTimeoutSec {
+		return nil, apperrors.NewCodef(op, errcode.InvalidInput, "timeoutSec must be between 0 and %d", maxTurnAwaitTimeoutSec)
+	}
+
+	deadline := time.Now().Add(time.Duration(timeoutSec) * time.Second)
+	ticker := time.NewTicker(turnAwaitPollInterval)
+	defer ticker.Stop()
+	for {
+		results, finished := s.collectFanoutResults(id)
+		total := len(results)
+		complete := finished == total
+		if complete || (p.Quorum > 0 && finished >= p.Quorum) || !time.Now().Before(deadline) {
+			return map[string]any{
+				"fanoutId": id,
+				"total":    total,
+				"finished": finished,
+				"complete": complete,
+				"partial":  !complete,
+				"results":  results,
+			}, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, apperrors.Wrap(ctx.Err(), op, "await cancelled")
+		case <-ticker.C:
+		}
+	}
+}
+
+// collectFanoutResults 汇总各目标当前结果, 返回结果列表与已结束数量。
+func (s *Server) collectFanoutResults(id string) ([]fanoutResult, int) {
+	job, _ := s.fanouts.snapshot(id)
+	results := make([]fanoutResult, 0, len(job.Targets))
+	finished := 0
+	for _, target := range job.Targets {
+		result := s.fanoutTargetResult(job, target)
+		if result.Done {
+			finished++
+		}
+		results = append(results, result)
+	}
+	return results, finished
+}
+
+func (s *Server) fanoutTargetResult(job fanoutJob, target fanoutTarget) fanoutResult {
+	result := fanoutResult{ThreadID: target.ThreadID, TurnID: target.TurnID}
+	if target.Dispatch == "failed" {
+		result.Status, result.Error, result.Done = "failed", target.Error, true
+		return result
+	}
+	if result.TurnID == "" {
+		// 排队 / 暂存的 turn 在派发时还没有 ID: 取 fan-out 之后开始的 turn。
+		result.TurnID = s.fanoutAdoptTurnID(job, target.ThreadID)
+		if result.TurnID == "" {
+			result.Status = target.Dispatch
+			return result
+		}
+	}
+	snapshot, done := s.turnAwaitResult(target.ThreadID, result.TurnID)
+	result.Done = done
+	result.Status, _ = snapshot["status"].(string)
+	result.Summary, _ = snapshot["summary"].(string)
+	result.Reason, _ = snapshot["reason"].(string)
+	result.CompletedAt, _ = snapshot["completedAt"].(int64)
+	return result
+}
+
+// fanoutAdoptTurnID 在目标线程上查找 fan-out 创建之后开始 (或结束) 的 turn 并回填。
+func (s *Server) fanoutAdoptTurnID(job fanoutJob, threadID string) string {
+	turnID := ""
+	if activeID, startedAt, _, ok := s.peekTrackedTurnMeta(threadID); ok && !startedAt.Before(job.CreatedAt) {
+		turnID = activeID
+	} else if outcome, ok := s.lastTurnOutcome(threadID); ok && outcome.CompletedAt.After(job.CreatedAt) {
+		turnID = outcome.TurnID
+	}
+	if turnID != "" {
+		s.fanouts.setTurnID(job.ID, threadID, turnID)
+	}
+	return turnID
+}
@@ -0,0 +1,75 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/multi-agent/go-agent-v2/internal/codex"
+	"github.com/multi-agent/go-agent-v2/internal/runner"
+)
+
+func TestOrchestrateFanout_CollectsPerAgentResults(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	mgr := runner.NewAgentManager()
+	mgr.SetClientFactory(codex.MockClientFactory(nil, 0))
+	srv := New(Deps{Manager: mgr})
+	mgr.SetOnEvent(func(agentID string, event codex.Event) {
+		srv.AgentEventHandler(agentID)(event)
+	})
+
+	ctx := context.Background()
+	var threadIDs []string
+	for i := 0; i < 2; i++ {
+		res, err := srv.InvokeMethod(ctx, "thread/start", json.RawMessage(`{"cwd":"."}`))
+		if err != nil {
+			t.Fatalf("thread/start: %v", err)
+		}
+		threadIDs = append(threadIDs, res.(threadStartResponse).Thread.ID)
+	}
+
+	params, _ := json.Marshal(map[string]any{"threadIds": append(threadIDs, "missing-thread"), "prompt": "compare"})
+	res, err := srv.InvokeMethod(ctx, "orchestrate/fanout", params)
+	if err != nil {
+		t.Fatalf("orchestrate/fanout: %v", err)
+	}
+	out := res.(map[string]any)
+	if out["failed"] != 1 {
+		t.Fatalf("fanout = %#v", out)
+	}
+
+	params, _ = json.Marshal(map[string]any{"fanoutId": out["fanoutId"], "timeoutSec": 10})
+	res, err = srv.InvokeMethod(ctx, "orchestrate/fanout/result", params)
+	if err != nil {
+		t.Fatalf("orchestrate/fanout/result: %v", err)
+	}
+	result := res.(map[string]any)
+	if result["complete"] != true || result["finished"] != 3 {
+		t.Fatalf("result = %#v", result)
+	}
+	results := result["results"].([]fanoutResult)
+	for i, threadID := range threadIDs {
+		if r := results[i]; r.ThreadID != threadID || r.TurnID == "" || r.Status != "completed" {
+			t.Fatalf("results[%d] = %#v", i, r)
+		}
+	}
+	if r := results[2]; r.Status != "failed" || r.Error == "" {
+		t.Fatalf("missing thread result = %#v", r)
+	}
+}
+
+func TestOrchestrateFanout_Validation(t *testing.T) {
+	srv := New(Deps{})
+	ctx := context.Background()
+	for name, params := range map[string]string{
+		"no threads": `{"prompt":"hi"}`,
+		"no input":   `{"threadIds":["a"]}`,
+	} {
+		if _, err := srv.InvokeMethod(ctx, "orchestrate/fanout", json.RawMessage(params)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	if _, err := srv.InvokeMethod(ctx, "orchestrate/fanout/result", json.RawMessage(`{"fanoutId":"fanout-unknown"}`)); err == nil {
+		t.Error("unknown fanout: expected error")
+	}
+}
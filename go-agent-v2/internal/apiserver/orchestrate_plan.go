@@ -0,0 +1,846 @@
+// orchestrate_plan.go — orchestrate/plan: map-reduce 式任务分解与 DAG 调度。
+//
+// 流程: 规划线程以带 outputSchema 的 turn 把大任务拆成带依赖的子任务 (也可由调用方直接传入 subtasks),
+// 校验为无环图后写入 task_dags / task_dag_nodes (无数据库时仅保存在进程内),
+// 随后把依赖已满足的节点派发给空闲 worker 线程。每个节点 turn 结束时 (turn tracker 完成路径调用
+// finishPlanTurn) 记录结果摘要, 依赖它的节点带上前置结果继续派发; 失败节点的下游标记为 skipped。
+//
+// 进度以 orchestrate/plan/progress 推送, 全部节点结束推送 orchestrate/plan/completed;
+// orchestrate/plan/status 查询快照, DAG 管理页 (ui/dashboard/get page=dags) 显示进行中的计划。
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/store"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+	"github.com/multi-agent/go-agent-v2/pkg/util"
+)
+
+const (
+	maxPlanNodes          = 50
+	maxPlanRuns           = 32 // 超出后淘汰最早结束的计划
+	maxPlanTaskRunes      = 20000
+	maxPlanDepSummaryRune = 4000
+
+	planStatusPlanning  = "planning"
+	planStatusRunning   = "running"
+	planStatusCompleted = "completed"
+	planStatusFailed    = "failed"
+
+	planNodePending   = "pending"
+	planNodeRunning   = "running"
+	planNodeCompleted = "completed"
+	planNodeFailed    = "failed"
+	planNodeSkipped   = "skipped"
+)
+
+// planOutputSchema 规划 turn 的结构化输出约束。
+var planOutputSchema = json.RawMessage(`{
+  "type": "object",
+  "properties": {
+    "subtasks": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "properties": {
+          "key": {"type": "string"},
+          "title": {"type": "string"},
+          "prompt": {"type": "string"},
+          "dependsOn": {"type": "array", "items": {"type": "string"}}
+        },
+        "required": ["key", "title", "prompt", "dependsOn"],
+        "additionalProperties": false
+      }
+    }
+  },
+  "required": ["subtasks"],
+  "additionalProperties": false
+}`)
+
+var planNodeKeyPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// planSubtask 规划输出 / 调用方传入的子任务。
+type planSubtask struct {
+	Key       string   `json:"key"`
+	Title     string   `json:"title"`
+	Prompt    string   `json:"prompt"`
+	DependsOn []string `json:"dependsOn"`
+}
+
+// planNode DAG 节点的运行状态。
+type planNode struct {
+	Key        string     `json:"key"`
+	Title      string     `json:"title"`
+	Prompt     string     `json:"prompt"`
+	DependsOn  []string   `json:"dependsOn"`
+	Status     string     `json:"status"`
+	ThreadID   string     `json:"threadId,omitempty"`
+	TurnID     string     `json:"turnId,omitempty"`
+	Summary    string     `json:"summary,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+// planRun 一次 orchestrate/plan。
+type planRun struct {
+	DagKey          string      `json:"dagKey"`
+	Title           string      `json:"title"`
+	Task            string      `json:"task"`
+	PlannerThreadID string      `json:"plannerThreadId,omitempty"`
+	PlannerTurnID   string      `json:"plannerTurnId,omitempty"`
+	Workers         []string    `json:"workerThreadIds"`
+	Status          string      `json:"status"`
+	Error           string      `json:"error,omitempty"`
+	Nodes           []*planNode `json:"nodes"`
+	CreatedAt       time.Time   `json:"createdAt"`
+	FinishedAt      *time.Time  `json:"finishedAt,omitempty"`
+}
+
+// planEngine 计划登记与 worker 占用 (零值可用)。
+type planEngine struct {
+	mu   sync.Mutex
+	seq  int64
+	runs map[string]*planRun // dagKey →
+	busy map[string]string   // worker threadId → dagKey
+}
+
+func (e *planEngine) add(run *planRun) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.runs == nil {
+		e.runs = make(map[string]*planRun)
+	}
+	if len(e.runs) >= maxPlanRuns {
+		oldest := ""
+		for key, existing := range e.runs {
+			if existing.FinishedAt != nil && (oldest == "" || existing.CreatedAt.Before(e.runs[oldest].CreatedAt)) {
+				oldest = key
+			}
+		}
+		delete(e.runs, oldest)
+	}
+	e.seq++
+	if run.DagKey == "" {
+		run.DagKey = fmt.Sprintf("plan-%d-%d", run.CreatedAt.UnixMilli(), e.seq)
+	}
+	e.runs[run.DagKey] = run
+}
+
+// snapshot 返回计划深拷贝 (供 JSON 输出)。
+func (e *planEngine) snapshot(dagKey string) (planRun, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	run, ok := e.runs[dagKey]
+	if !ok {
+		return planRun{}, false
+	}
+	return copyPlanRun(run), true
+}
+
+func (e *planEngine) list() []planRun {
+	e.mu.Lock()
+	out := make([]planRun, 0, len(e.runs))
+	for _, run := range e.runs {
+		out = append(out, copyPlanRun(run))
+	}
+	e.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out
+}
+
+func copyPlanRun(run *planRun) planRun {
+	out := *run
+	out.Workers = append([]string(nil), run.Workers...)
+	out.Nodes = make([]*planNode, len(run.Nodes))
+	for i, node := range run.Nodes {
+		cp := *node
+		cp.DependsOn = append([]string(nil), node.DependsOn...)
+		out.Nodes[i] = &cp
+	}
+	return out
+}
+
+// planCounts 各状态节点数。
+func planCounts(nodes []*planNode) map[string]int {
+	counts := map[string]int{
+		planNodePending: 0, planNodeRunning: 0, planNodeCompleted: 0, planNodeFailed: 0, planNodeSkipped: 0,
+	}
+	for _, node := range nodes {
+		counts[node.Status]++
+	}
+	return counts
+}
+
+// ========================================
+// 规划输出解析与 DAG 校验
+// ========================================
+
+// parsePlanOutput 从规划 agent 回复中解析子任务 (整体 JSON → ```json 代码块 → 首尾花括号区间)。
+func parsePlanOutput(text string) ([]planSubtask, bool) {
+	trimmed := strings.TrimSpace(text)
+	candidates := []string{trimmed}
+	fences := reviewJSONFence.FindAllStringSubmatch(trimmed, -1)
+	for i := len(fences) - 1; i >= 0; i-- {
+		candidates = append(candidates, fences[i][1])
+	}
+	if start, end := strings.Index(trimmed, "{"), strings.LastIndex(trimmed, "}"); start >= 0 && end > start {
+		candidates = append(candidates, trimmed[start:end+1])
+	}
+	for _, candidate := range candidates {
+		var out struct {
+			Subtasks []planSubtask `json:"subtasks"`
+		}
+		if candidate = strings.TrimSpace(candidate); strings.HasPrefix(candidate, "{") &&
+			json.Unmarshal([]byte(candidate), &out) == nil && len(out.Subtasks) > 0 {
+			return out.Subtasks, true
+		}
+	}
+	return nil, false
+}
+
+// buildPlanNodes 校验子任务 (键唯一、依赖存在、无环) 并按拓扑序返回节点。
+func buildPlanNodes(subtasks []planSubtask) ([]*planNode, error) {
+	const op = "Server.orchestratePlan"
+	if len(subtasks) == 0 {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "plan has no subtasks")
+	}
+	if len(subtasks) > maxPlanNodes {
+		return nil, apperrors.NewCodef(op, errcode.InvalidInput, "too many subtasks (%d > %d)", len(subtasks), maxPlanNodes)
+	}
+	byKey := make(map[string]*planNode, len(subtasks))
+	order := make([]string, 0, len(subtasks))
+	for _, st := range subtasks {
+		key := strings.TrimSpace(st.Key)
+		if !planNodeKeyPattern.MatchString(key) {
+			return nil, apperrors.NewCodef(op, errcode.InvalidInput, "invalid subtask key %q", st.Key)
+		}
+		if byKey[key] != nil {
+			return nil, apperrors.NewCodef(op, errcode.InvalidInput, "duplicate subtask key %q", key)
+		}
+		prompt := strings.TrimSpace(st.Prompt)
+		if prompt == "" {
+			return nil, apperrors.NewCodef(op, errcode.InvalidInput, "subtask %q has no prompt", key)
+		}
+		title := strings.TrimSpace(st.Title)
+		if title == "" {
+			title = key
+		}
+		byKey[key] = &planNode{Key: key, Title: title, Prompt: prompt, Status: planNodePending}
+		order = append(order, key)
+		for _, dep := range st.DependsOn {
+			if dep = strings.TrimSpace(dep); dep != "" && !containsString(byKey[key].DependsOn, dep) {
+				byKey[key].DependsOn = append(byKey[key].DependsOn, dep)
+			}
+		}
+	}
+	for _, key := range order {
+		for _, dep := range byKey[key].DependsOn {
+			if byKey[dep] == nil {
+				return nil, apperrors.NewCodef(op, errcode.InvalidInput, "subtask %q depends on unknown %q", key, dep)
+			}
+		}
+	}
+
+	// Kahn 拓扑排序: 同层保持原顺序, 剩余节点即成环。
+	indegree := make(map[string]int, len(order))
+	for _, key := range order {
+		indegree[key] = len(byKey[key].DependsOn)
+	}
+	nodes := make([]*planNode, 0, len(order))
+	placed := make(map[string]bool, len(order))
+	for len(nodes) < len(order) {
+		progressed := false
+		for _, key := range order {
+			if placed[key] || indegree[key] > 0 {
+				continue
+			}
+			placed[key] = true
+			progressed = true
+			nodes = append(nodes, byKey[key])
+			for _, other := range order {
+				if containsString(byKey[other].DependsOn, key) {
+					indegree[other]--
+				}
+			}
+		}
+		if !progressed {
+			return nil, apperrors.NewCode(op, errcode.InvalidInput, "subtask dependencies contain a cycle")
+		}
+	}
+	return nodes, nil
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func buildPlannerPrompt(task string, workers int) string {
+	var b strings.Builder
+	b.WriteString("Split the following task into independent subtasks that can be handed to other agents.\n")
+	b.WriteString(fmt.Sprintf("Up to %d agents work in parallel; use at most %d subtasks. ", workers, maxPlanNodes))
+	b.WriteString("Each subtask needs a short unique key (letters, digits, '.', '_' or '-'), a title, a self-contained prompt for the agent ")
+	b.WriteString("and the keys of the subtasks whose results it needs (dependsOn). Add a final subtask that combines the results when useful. ")
+	b.WriteString("Do not start working on the task yourself. ")
+	b.WriteString("Reply with JSON only: {\"subtasks\": [{\"key\", \"title\", \"prompt\", \"dependsOn\"}]}.\n\nTask:\n")
+	b.WriteString(task)
+	return b.String()
+}
+
+// buildPlanNodePrompt 节点 turn 的提示词 (附带前置节点结果)。
+func buildPlanNodePrompt(run *planRun, node *planNode, deps []*planNode) string {
+	var b strings.Builder
+	b.WriteString("You are working on one subtask of a larger task.\n\nOverall task:\n")
+	b.WriteString(run.Task)
+	b.WriteString("\n\nYour subtask (" + node.Key + "): " + node.Title + "\n" + node.Prompt + "\n")
+	if len(deps) > 0 {
+		b.WriteString("\nResults of the subtasks this one depends on:\n")
+		for _, dep := range deps {
+			b.WriteString("\n### " + dep.Key + ": " + dep.Title + "\n" + truncateRunes(dep.Summary, maxPlanDepSummaryRune) + "\n")
+		}
+	}
+	b.WriteString("\nFinish with a concise summary of your result.\n")
+	return b.String()
+}
+
+// ========================================
+// orchestrate/plan, orchestrate/plan/status
+// ========================================
+
+type orchestratePlanParams struct {
+	Task            string        `json:"task"`
+	Title           string        `json:"title,omitempty"`
+	PlannerThreadID string        `json:"plannerThreadId,omitempty"`
+	WorkerThreadIDs []string      `json:"workerThreadIds"`
+	Subtasks        []planSubtask `json:"subtasks,omitempty"` // 直接给定分解时跳过规划 turn
+}
+
+func (s *Server) orchestratePlanTyped(ctx context.Context, p orchestratePlanParams) (any, error) {
+	const op = "Server.orchestratePlan"
+	task := strings.TrimSpace(p.Task)
+	if task == "" {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "task is required")
+	}
+	if len([]rune(task)) > maxPlanTaskRunes {
+		return nil, apperrors.NewCodef(op, errcode.InvalidInput, "task too long (max %d characters)", maxPlanTaskRunes)
+	}
+	var workers []string
+	for _, id := range p.WorkerThreadIDs {
+		if id = strings.TrimSpace(id); id != "" && !containsString(workers, id) {
+			workers = append(workers, id)
+		}
+	}
+	if len(workers) == 0 {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "workerThreadIds is required")
+	}
+	planner := strings.TrimSpace(p.PlannerThreadID)
+	if planner == "" && len(p.Subtasks) == 0 {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "plannerThreadId or subtasks is required")
+	}
+	if err := s.frozenError(op); err != nil {
+		return nil, err
+	}
+	if err := s.drainingError(op); err != nil {
+		return nil, err
+	}
+
+	run := &planRun{
+		Title:     strings.TrimSpace(p.Title),
+		Task:      task,
+		Workers:   workers,
+		Status:    planStatusPlanning,
+		CreatedAt: time.Now(),
+	}
+	if run.Title == "" {
+		run.Title = truncateRunes(strings.SplitN(task, "\n", 2)[0], 120)
+	}
+	if len(p.Subtasks) > 0 {
+		nodes, err := buildPlanNodes(p.Subtasks)
+		if err != nil {
+			return nil, err
+		}
+		run.Nodes = nodes
+		run.Status = planStatusRunning
+		s.plans.add(run)
+		dagKey := run.DagKey
+		s.persistPlan(dagKey)
+		util.SafeGo(func() { s.dispatchReadyPlanNodes(context.Background(), dagKey) })
+	} else {
+		run.PlannerThreadID = planner
+		s.plans.add(run)
+		resp, err := s.turnStartTyped(ctx, turnStartParams{
+			ThreadID:             planner,
+			Input:                []UserInput{{Type: "text", Text: buildPlannerPrompt(task, len(workers))}},
+			OutputSchema:         planOutputSchema,
+			BypassDedup:          true,
+			skipOutputValidation: true,
+		})
+		if err != nil {
+			s.plans.mu.Lock()
+			delete(s.plans.runs, run.DagKey)
+			s.plans.mu.Unlock()
+			return nil, err
+		}
+		if started, ok := resp.(turnStartResponse); ok && started.Turn.Status == "inProgress" {
+			s.plans.mu.Lock()
+			if run.PlannerTurnID == "" {
+				run.PlannerTurnID = started.Turn.ID
+			}
+			s.plans.mu.Unlock()
+		}
+		s.persistPlan(run.DagKey)
+	}
+	snapshot, _ := s.plans.snapshot(run.DagKey)
+	logger.Info("orchestrate/plan: created",
+		logger.FieldDAG, snapshot.DagKey, logger.FieldStatus, snapshot.Status,
+		"planner", planner, "workers", len(workers), "nodes", len(snapshot.Nodes))
+	return snapshot, nil
+}
+
+type orchestratePlanStatusParams struct {
+	DagKey string `json:"dagKey"`
+}
+
+func (s *Server) orchestratePlanStatusTyped(ctx context.Context, p orchestratePlanStatusParams) (any, error) {
+	const op = "Server.orchestratePlanStatus"
+	key := strings.TrimSpace(p.DagKey)
+	if key == "" {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "dagKey is required")
+	}
+	if run, ok := s.plans.snapshot(key); ok {
+		return map[string]any{"plan": run, "counts": planCounts(run.Nodes)}, nil
+	}
+	if s.dagStore != nil {
+		dag, nodes, err := s.dagStore.GetDAGDetail(ctx, key)
+		if err != nil {
+			return nil, apperrors.Wrap(err, op, "get dag")
+		}
+		if dag != nil {
+			return map[string]any{"dag": dag, "nodes": nodes}, nil
+		}
+	}
+	return nil, apperrors.NewCodef(op, errcode.InvalidInput, "plan %s not found", key)
+}
+
+// ========================================
+// 调度
+// ========================================
+
+// finishPlanTurn turn 结束时推进匹配的规划或节点 (turn tracker 完成路径调用)。
+func (s *Server) finishPlanTurn(threadID, turnID, status string) {
+	s.plans.mu.Lock()
+	defer s.plans.mu.Unlock()
+	matches := func(want string) bool { return want == "" || turnID == "" || strings.EqualFold(want, turnID) }
+	for _, run := range s.plans.runs {
+		if run.Status == planStatusPlanning && run.PlannerThreadID == threadID && matches(run.PlannerTurnID) {
+			run.PlannerTurnID = turnID
+			dagKey := run.DagKey
+			util.SafeGo(func() { s.collectPlan(dagKey, threadID, turnID, status) })
+			return
+		}
+		if run.Status != planStatusRunning {
+			continue
+		}
+		for _, node := range run.Nodes {
+			if node.Status == planNodeRunning && node.ThreadID == threadID && matches(node.TurnID) {
+				node.TurnID = turnID
+				dagKey, nodeKey := run.DagKey, node.Key
+				util.SafeGo(func() { s.completePlanNode(dagKey, nodeKey, threadID, turnID, status) })
+				return
+			}
+		}
+	}
+}
+
+// turnResultText 优先取时间线中的最后一条 assistant 消息, 其次为 turn 摘要缓存。
+func (s *Server) turnResultText(threadID, turnID string) string {
+	if text := s.lastAssistantText(threadID); text != "" {
+		return text
+	}
+	return s.lookupTrackedTurnSummary(threadID, turnID)
+}
+
+// collectPlan 解析规划 turn 的输出, 建立 DAG 并开始派发。
+func (s *Server) collectPlan(dagKey, threadID, turnID, status string) {
+	const op = "Server.collectPlan"
+	var nodes []*planNode
+	err := apperrors.Newf(op, "planner turn %s", status)
+	if status == "completed" {
+		if subtasks, ok := parsePlanOutput(s.turnResultText(threadID, turnID)); !ok {
+			err = apperrors.New(op, "planner output not parseable")
+		} else {
+			nodes, err = buildPlanNodes(subtasks)
+		}
+	}
+
+	s.plans.mu.Lock()
+	run := s.plans.runs[dagKey]
+	if run == nil {
+		s.plans.mu.Unlock()
+		return
+	}
+	if err != nil {
+		now := time.Now()
+		run.Status, run.Error, run.FinishedAt = planStatusFailed, err.Error(), &now
+	} else {
+		run.Nodes, run.Status = nodes, planStatusRunning
+	}
+	s.plans.mu.Unlock()
+
+	s.persistPlan(dagKey)
+	if err != nil {
+		logger.Warn("orchestrate/plan: planning failed", logger.FieldDAG, dagKey, logger.FieldError, err)
+		s.notifyPlanCompleted(dagKey)
+		return
+	}
+	logger.Info("orchestrate/plan: planned", logger.FieldDAG, dagKey, "nodes", len(nodes))
+	s.notifyPlanProgress(dagKey, nil)
+	s.dispatchReadyPlanNodes(context.Background(), dagKey)
+}
+
+// completePlanNode 记录节点结果并派发后续节点。
+func (s *Server) completePlanNode(dagKey, nodeKey, threadID, turnID, status string) {
+	summary := ""
+	if status == "completed" {
+		summary = strings.TrimSpace(s.turnResultText(threadID, turnID))
+	}
+	s.plans.mu.Lock()
+	run := s.plans.runs[dagKey]
+	var node *planNode
+	if run != nil {
+		for _, n := range run.Nodes {
+			if n.Key == nodeKey {
+				node = n
+			}
+		}
+	}
+	if node == nil {
+		s.plans.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	node.FinishedAt = &now
+	if status == "completed" {
+		node.Status, node.Summary = planNodeCompleted, summary
+	} else {
+		node.Status, node.Error = planNodeFailed, "turn "+status
+	}
+	if s.plans.busy[threadID] == dagKey {
+		delete(s.plans.busy, threadID)
+	}
+	snapshot := *node
+	s.plans.mu.Unlock()
+
+	logger.Info("orchestrate/plan: node finished",
+		logger.FieldDAG, dagKey, logger.FieldNode, nodeKey, logger.FieldThreadID, threadID, logger.FieldStatus, snapshot.Status)
+	s.persistPlanNode(dagKey, &snapshot)
+	s.notifyPlanProgress(dagKey, &snapshot)
+	s.dispatchReadyPlanNodes(context.Background(), dagKey)
+}
+
+// planAssignment 一次派发 (节点 → worker)。
+type planAssignment struct {
+	node   planNode
+	worker string
+	prompt string
+}
+
+// dispatchReadyPlanNodes 把依赖已满足的节点派发给空闲 worker; 所有节点结束时收尾。
+func (s *Server) dispatchReadyPlanNodes(ctx context.Context, dagKey string) {
+	var assignments []planAssignment
+	var skipped []planNode
+	finished := false
+
+	s.plans.mu.Lock()
+	run := s.plans.runs[dagKey]
+	if run == nil || run.Status != planStatusRunning {
+		s.plans.mu.Unlock()
+		return
+	}
+	if s.plans.busy == nil {
+		s.plans.busy = make(map[string]string)
+	}
+	byKey := make(map[string]*planNode, len(run.Nodes))
+	for _, node := range run.Nodes {
+		byKey[node.Key] = node
+	}
+	// 拓扑序遍历: 上游失败 / 跳过的节点在同一轮内向下传递。
+	for _, node := range run.Nodes {
+		if node.Status != planNodePending {
+			continue
+		}
+		ready, blocked := true, ""
+		deps := make([]*planNode, 0, len(node.DependsOn))
+		for _, key := range node.DependsOn {
+			dep := byKey[key]
+			switch dep.Status {
+			case planNodeCompleted:
+				deps = append(deps, dep)
+			case planNodeFailed, planNodeSkipped:
+				blocked = key
+			default:
+				ready = false
+			}
+		}
+		if blocked != "" {
+			now := time.Now()
+			node.Status, node.Error, node.FinishedAt = planNodeSkipped, "dependency "+blocked+" did not complete", &now
+			skipped = append(skipped, *node)
+			continue
+		}
+		if !ready {
+			continue
+		}
+		worker := ""
+		for _, candidate := range run.Workers {
+			if _, busy := s.plans.busy[candidate]; !busy {
+				worker = candidate
+				break
+			}
+		}
+		if worker == "" {
+			continue // 无空闲 worker, 继续扫描以传递上游失败
+		}
+		now := time.Now()
+		node.Status, node.ThreadID, node.StartedAt = planNodeRunning, worker, &now
+		s.plans.busy[worker] = dagKey
+		assignments = append(assignments, planAssignment{node: *node, worker: worker, prompt: buildPlanNodePrompt(run, node, deps)})
+	}
+	if len(assignments) == 0 {
+		finished = true
+		for _, node := range run.Nodes {
+			if node.Status == planNodePending || node.Status == planNodeRunning {
+				finished = false
+				break
+			}
+		}
+		if finished {
+			now := time.Now()
+			run.FinishedAt = &now
+			run.Status = planStatusCompleted
+			if counts := planCounts(run.Nodes); counts[planNodeFailed]+counts[planNodeSkipped] > 0 {
+				run.Status = planStatusFailed
+			}
+		}
+	}
+	s.plans.mu.Unlock()
+
+	for i := range skipped {
+		s.persistPlanNode(dagKey, &skipped[i])
+		s.notifyPlanProgress(dagKey, &skipped[i])
+	}
+	for _, a := range assignments {
+		s.startPlanNode(ctx, dagKey, a)
+	}
+	if finished {
+		s.persistPlan(dagKey)
+		s.notifyPlanCompleted(dagKey)
+	}
+}
+
+// startPlanNode 在 worker 上提交节点 turn; 提交失败时节点记为 failed 并继续调度。
+func (s *Server) startPlanNode(ctx context.Context, dagKey string, a planAssignment) {
+	resp, err := s.turnStartTyped(ctx, turnStartParams{
+		ThreadID:    a.worker,
+		Input:       []UserInput{{Type: "text", Text: a.prompt}},
+		BypassDedup: true,
+		Priority:    "normal",
+	})
+	node, found := a.node, false
+	s.plans.mu.Lock()
+	if run := s.plans.runs[dagKey]; run != nil {
+		for _, n := range run.Nodes {
+			if n.Key != node.Key || n.Status != planNodeRunning {
+				continue
+			}
+			found = true
+			if err != nil {
+				now := time.Now()
+				n.Status, n.Error, n.FinishedAt = planNodeFailed, err.Error(), &now
+				delete(s.plans.busy, a.worker)
+			} else if started, ok := resp.(turnStartResponse); ok && started.Turn.Status == "inProgress" && n.TurnID == "" {
+				n.TurnID = started.Turn.ID
+			}
+			node = *n
+		}
+	}
+	s.plans.mu.Unlock()
+	if !found {
+		return // turn 已在提交返回前结束, 由 completePlanNode 处理
+	}
+
+	if err != nil {
+		logger.Warn("orchestrate/plan: dispatch failed",
+			logger.FieldDAG, dagKey, logger.FieldNode, node.Key, logger.FieldThreadID, a.worker, logger.FieldError, err)
+		s.persistPlanNode(dagKey, &node)
+		s.notifyPlanProgress(dagKey, &node)
+		s.dispatchReadyPlanNodes(ctx, dagKey)
+		return
+	}
+	logger.Info("orchestrate/plan: node dispatched",
+		logger.FieldDAG, dagKey, logger.FieldNode, node.Key, logger.FieldThreadID, a.worker, logger.FieldTurnID, node.TurnID)
+	s.persistPlanNode(dagKey, &node)
+	s.notifyPlanProgress(dagKey, &node)
+}
+
+// ========================================
+// 持久化与通知
+// ========================================
+
+// persistPlan 写入 DAG 主表与全部节点 (无数据库时跳过)。
+func (s *Server) persistPlan(dagKey string) {
+	if s.dagStore == nil {
+		return
+	}
+	run, ok := s.plans.snapshot(dagKey)
+	if !ok {
+		return
+	}
+	ctx := context.Background()
+	if _, err := s.dagStore.SaveDAG(ctx, &store.TaskDAG{
+		DagKey:      run.DagKey,
+		Title:       run.Title,
+		Description: run.Task,
+		Status:      run.Status,
+		CreatedBy:   "orchestrate/plan",
+		Metadata: map[string]any{
+			"plannerThreadId": run.PlannerThreadID,
+			"workerThreadIds": run.Workers,
+			"error":           run.Error,
+		},
+	}); err != nil {
+		logger.Warn("orchestrate/plan: save dag failed", logger.FieldDAG, dagKey, logger.FieldError, err)
+		return
+	}
+	for _, node := range run.Nodes {
+		if _, err := s.dagStore.SaveNode(ctx, &store.TaskDAGNode{
+			DagKey:     dagKey,
+			NodeKey:    node.Key,
+			Title:      node.Title,
+			AssignedTo: node.ThreadID,
+			DependsOn:  node.DependsOn,
+			Config:     map[string]any{"prompt": node.Prompt},
+		}); err != nil {
+			logger.Warn("orchestrate/plan: save node failed", logger.FieldDAG, dagKey, logger.FieldNode, node.Key, logger.FieldError, err)
+			continue
+		}
+		if node.Status != planNodePending {
+			s.persistPlanNode(dagKey, node)
+		}
+	}
+}
+
+// persistPlanNode 更新节点状态与结果 (无数据库时跳过)。
+func (s *Server) persistPlanNode(dagKey string, node *planNode) {
+	if s.dagStore == nil {
+		return
+	}
+	ctx := context.Background()
+	if node.Status == planNodeRunning {
+		// 派发时才确定 worker, 同步 assigned_to。
+		if _, err := s.dagStore.SaveNode(ctx, &store.TaskDAGNode{
+			DagKey: dagKey, NodeKey: node.Key, Title: node.Title, AssignedTo: node.ThreadID,
+			DependsOn: node.DependsOn, Config: map[string]any{"prompt": node.Prompt},
+		}); err != nil {
+			logger.Warn("orchestrate/plan: save node failed", logger.FieldDAG, dagKey, logger.FieldNode, node.Key, logger.FieldError, err)
+		}
+	}
+	result := map[string]any{"threadId": node.ThreadID, "turnId": node.TurnID}
+	if node.Summary != "" {
+		result["summary"] = node.Summary
+	}
+	if node.Error != "" {
+		result["error"] = node.Error
+	}
+	if _, err := s.dagStore.UpdateNodeStatus(ctx, dagKey, node.Key, node.Status, result); err != nil {
+		logger.Warn("orchestrate/plan: update node failed", logger.FieldDAG, dagKey, logger.FieldNode, node.Key, logger.FieldError, err)
+	}
+}
+
+func (s *Server) notifyPlanProgress(dagKey string, node *planNode) {
+	run, ok := s.plans.snapshot(dagKey)
+	if !ok {
+		return
+	}
+	payload := map[string]any{
+		"dagKey": dagKey,
+		"status": run.Status,
+		"counts": planCounts(run.Nodes),
+	}
+	if node != nil {
+		payload["node"] = node
+	}
+	s.Notify("orchestrate/plan/progress", payload)
+}
+
+func (s *Server) notifyPlanCompleted(dagKey string) {
+	run, ok := s.plans.snapshot(dagKey)
+	if !ok {
+		return
+	}
+	logger.Info("orchestrate/plan: finished", logger.FieldDAG, dagKey, logger.FieldStatus, run.Status)
+	s.Notify("orchestrate/plan/completed", map[string]any{
+		"dagKey": dagKey,
+		"status": run.Status,
+		"error":  run.Error,
+		"counts": planCounts(run.Nodes),
+	})
+}
+
+// planDashboardRows 进行中 / 最近的计划, 以 DAG 管理页的行格式输出。
+func (s *Server) planDashboardRows() []any {
+	runs := s.plans.list()
+	rows := make([]any, 0, len(runs))
+	for _, run := range runs {
+		counts := planCounts(run.Nodes)
+		updated := run.CreatedAt
+		if run.FinishedAt != nil {
+			updated = *run.FinishedAt
+		}
+		rows = append(rows, map[string]any{
+			"dag_key":    run.DagKey,
+			"title":      run.Title,
+			"status":     run.Status,
+			"progress":   fmt.Sprintf("%d/%d", counts[planNodeCompleted], len(run.Nodes)),
+			"updated_at": updated,
+		})
+	}
+	return rows
+}
+
+// mergePlanDashboardRows 合并进程内计划与数据库 DAG 列表 (同一 dag_key 以进程内状态为准)。
+func mergePlanDashboardRows(live []any, stored any) []any {
+	dags, _ := stored.([]store.TaskDAG)
+	if len(live) == 0 && len(dags) == 0 {
+		if rows, ok := stored.([]any); ok {
+			return rows
+		}
+		return []any{}
+	}
+	seen := make(map[string]bool, len(live))
+	for _, row := range live {
+		seen[row.(map[string]any)["dag_key"].(string)] = true
+	}
+	out := append([]any(nil), live...)
+	for _, dag := range dags {
+		if !seen[dag.DagKey] {
+			out = append(out, dag)
+		}
+	}
+	return out
+}
@@ -0,0 +1,169 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/codex"
+	"github.com/multi-agent/go-agent-v2/internal/runner"
+)
+
+func TestBuildPlanNodes_ValidatesAndSorts(t *testing.T) {
+	nodes, err := buildPlanNodes([]planSubtask{
+		{Key: "merge", Prompt: "combine", DependsOn: []string{"left", "right"}},
+		{Key: "left", Prompt: "do left"},
+		{Key: "right", Prompt: "do right", DependsOn: []string{"left", "left"}},
+	})
+	if err != nil {
+		t.Fatalf("buildPlanNodes: %v", err)
+	}
+	var keys []string
+	for _, n := range nodes {
+		keys = append(keys, n.Key)
+	}
+	if strings.Join(keys, ",") != "left,right,merge" || len(nodes[1].DependsOn) != 1 || nodes[2].Title != "merge" {
+		t.Fatalf("nodes = %v (%#v)", keys, nodes[1])
+	}
+
+	for name, bad := range map[string][]planSubtask{
+		"cycle":     {{Key: "a", Prompt: "x", DependsOn: []string{"b"}}, {Key: "b", Prompt: "y", DependsOn: []string{"a"}}},
+		"unknown":   {{Key: "a", Prompt: "x", DependsOn: []string{"zzz"}}},
+		"duplicate": {{Key: "a", Prompt: "x"}, {Key: "a", Prompt: "y"}},
+		"bad key":   {{Key: "a b", Prompt: "x"}},
+		"no prompt": {{Key: "a"}},
+	} {
+		if _, err := buildPlanNodes(bad); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestParsePlanOutput(t *testing.T) {
+	text := "Here is the plan:\n```json\n{\"subtasks\":[{\"key\":\"a\",\"title\":\"A\",\"prompt\":\"do a\",\"dependsOn\":[]}]}\n```"
+	subtasks, ok := parsePlanOutput(text)
+	if !ok || len(subtasks) != 1 || subtasks[0].Key != "a" {
+		t.Fatalf("parsePlanOutput = %#v, %v", subtasks, ok)
+	}
+	if _, ok := parsePlanOutput("no plan here"); ok {
+		t.Fatal("expected parse failure")
+	}
+}
+
+func newPlanTestServer(t *testing.T) (*Server, chan map[string]any) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	mgr := runner.NewAgentManager()
+	mgr.SetClientFactory(codex.MockClientFactory(nil, 0))
+	srv := New(Deps{Manager: mgr})
+	mgr.SetOnEvent(func(agentID string, event codex.Event) {
+		srv.AgentEventHandler(agentID)(event)
+	})
+	completed := make(chan map[string]any, 1)
+	srv.SetNotifyHook(func(method string, params any) {
+		if method == "orchestrate/plan/completed" {
+			completed <- params.(map[string]any)
+		}
+	})
+	return srv, completed
+}
+
+func waitPlanCompleted(t *testing.T, completed chan map[string]any) map[string]any {
+	t.Helper()
+	select {
+	case payload := <-completed:
+		return payload
+	case <-time.After(15 * time.Second):
+		t.Fatal("orchestrate/plan/completed not received")
+		return nil
+	}
+}
+
+func TestOrchestratePlan_DispatchesDAGToWorkers(t *testing.T) {
+	srv, completed := newPlanTestServer(t)
+	ctx := context.Background()
+	var workers []string
+	for i := 0; i < 2; i++ {
+		res, err := srv.InvokeMethod(ctx, "thread/start", json.RawMessage(`{"cwd":"."}`))
+		if err != nil {
+			t.Fatalf("thread/start: %v", err)
+		}
+		workers = append(workers, res.(threadStartResponse).Thread.ID)
+	}
+
+	params, _ := json.Marshal(map[string]any{
+		"task":            "summarize the repo",
+		"workerThreadIds": workers,
+		"subtasks": []map[string]any{
+			{"key": "scan", "title": "Scan", "prompt": "list packages"},
+			{"key": "api", "title": "API", "prompt": "describe api", "dependsOn": []string{"scan"}},
+			{"key": "store", "title": "Store", "prompt": "describe store", "dependsOn": []string{"scan"}},
+			{"key": "report", "title": "Report", "prompt": "combine", "dependsOn": []string{"api", "store"}},
+		},
+	})
+	res, err := srv.InvokeMethod(ctx, "orchestrate/plan", params)
+	if err != nil {
+		t.Fatalf("orchestrate/plan: %v", err)
+	}
+	dagKey := res.(planRun).DagKey
+
+	payload := waitPlanCompleted(t, completed)
+	if payload["dagKey"] != dagKey || payload["status"] != planStatusCompleted {
+		t.Fatalf("completed = %#v", payload)
+	}
+	status, err := srv.InvokeMethod(ctx, "orchestrate/plan/status", json.RawMessage(`{"dagKey":"`+dagKey+`"}`))
+	if err != nil {
+		t.Fatalf("orchestrate/plan/status: %v", err)
+	}
+	plan := status.(map[string]any)["plan"].(planRun)
+	for _, node := range plan.Nodes {
+		if node.Status != planNodeCompleted || node.TurnID == "" || node.FinishedAt == nil {
+			t.Fatalf("node %s = %#v", node.Key, node)
+		}
+	}
+	rows := srv.planDashboardRows()
+	if len(rows) != 1 || rows[0].(map[string]any)["progress"] != "4/4" {
+		t.Fatalf("dashboard rows = %#v", rows)
+	}
+}
+
+func TestOrchestratePlan_SkipsDependentsOfFailedNode(t *testing.T) {
+	srv, completed := newPlanTestServer(t)
+	params, _ := json.Marshal(map[string]any{
+		"task":            "broken",
+		"workerThreadIds": []string{"missing-thread"},
+		"subtasks": []map[string]any{
+			{"key": "first", "prompt": "one"},
+			{"key": "second", "prompt": "two", "dependsOn": []string{"first"}},
+		},
+	})
+	res, err := srv.InvokeMethod(context.Background(), "orchestrate/plan", params)
+	if err != nil {
+		t.Fatalf("orchestrate/plan: %v", err)
+	}
+	payload := waitPlanCompleted(t, completed)
+	counts := payload["counts"].(map[string]int)
+	if payload["status"] != planStatusFailed || counts[planNodeFailed] != 1 || counts[planNodeSkipped] != 1 {
+		t.Fatalf("completed = %#v", payload)
+	}
+	plan, _ := srv.plans.snapshot(res.(planRun).DagKey)
+	if !strings.Contains(plan.Nodes[1].Error, "first") {
+		t.Fatalf("skipped node = %#v", plan.Nodes[1])
+	}
+}
+
+func TestOrchestratePlan_Validation(t *testing.T) {
+	srv := New(Deps{})
+	for name, params := range map[string]string{
+		"no task":    `{"workerThreadIds":["w"],"plannerThreadId":"p"}`,
+		"no workers": `{"task":"t","plannerThreadId":"p"}`,
+		"no planner": `{"task":"t","workerThreadIds":["w"]}`,
+		"cycle":      `{"task":"t","workerThreadIds":["w"],"subtasks":[{"key":"a","prompt":"x","dependsOn":["a"]}]}`,
+	} {
+		if _, err := srv.InvokeMethod(context.Background(), "orchestrate/plan", json.RawMessage(params)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
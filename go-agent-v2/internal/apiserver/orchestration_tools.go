@@ -154,8 +154,8 @@ func (s *Server) orchestrationLaunchAgent(args json.RawMessage) string {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	// 构建完整工具列表 (LSP + 编排 + 资源)
-	tools := s.buildAllDynamicTools()
+	// 构建完整工具列表 (内置 + 注册表 + 项目 HTTP 工具)
+	tools := s.buildDynamicToolsForThread(id, p.Cwd)
 
 	if err := s.mgr.Launch(ctx, id, p.Name, p.Prompt, p.Cwd, "", tools); err != nil {
 		return toolError(apperrors.Wrap(err, "orchestrationLaunchAgent", "launch agent"))
@@ -194,17 +194,25 @@ func (s *Server) orchestrationStopAgent(args json.RawMessage) string {
 		return toolError(apperrors.Wrap(err, "orchestrationStopAgent", "stop agent"))
 	}
 	s.clearAgentWorkDir(p.AgentID)
+	s.turnUndo.drop(p.AgentID)
 
 	logger.Info("orchestration: agent stopped", logger.FieldID, p.AgentID)
 	return toolJSON(map[string]any{"success": true, "agent_id": p.AgentID})
 }
 
-// buildAllDynamicTools 构建全部动态工具列表 (LSP + 编排 + 资源 + 代码执行)。
+// buildAllDynamicTools 构建全部动态工具列表 (内置 + 注册表外部工具)。
 func (s *Server) buildAllDynamicTools() []codex.DynamicTool {
+	return append(s.buildBuiltinDynamicTools(), registryDynamicTools(s.registryTools())...)
+}
+
+// buildBuiltinDynamicTools 内置动态工具 (LSP + 编排 + 资源 + 代码执行 + 知识库 + 提问)。
+func (s *Server) buildBuiltinDynamicTools() []codex.DynamicTool {
 	var tools []codex.DynamicTool
 	tools = append(tools, s.buildLSPDynamicTools()...)
 	tools = append(tools, s.buildOrchestrationTools()...)
 	tools = append(tools, s.buildResourceTools()...)
 	tools = append(tools, s.buildCodeRunTools()...)
+	tools = append(tools, s.buildKBTools()...)
+	tools = append(tools, s.buildQuestionTools()...)
 	return tools
 }
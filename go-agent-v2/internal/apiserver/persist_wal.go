@@ -0,0 +1,412 @@
+// persist_wal.go — 离线持久化: Postgres / 网络不可用时将写入暂存到本地 WAL, 恢复后按序重放。
+//
+// 覆盖的写入: agent ↔ codex 线程绑定、线程别名、turn 任务追踪。
+// 写入失败且判定为连接类错误时追加到 WAL (JSONL, 每行一个操作) 而非丢弃;
+// WAL 非空期间的新写入也直接排队, 保证重放顺序与写入顺序一致。
+// 后台循环按 PERSIST_WAL_REPLAY_SEC 间隔重放: 遇到连接错误即停止等待下一轮,
+// 非连接错误 (如绑定冲突) 记录后丢弃该操作。persist/status 查看积压, persist/replay 立即重放。
+package apiserver
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/multi-agent/go-agent-v2/internal/store"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+	"github.com/multi-agent/go-agent-v2/pkg/util"
+)
+
+const (
+	walKindBinding   = "binding"
+	walKindAlias     = "alias"
+	walKindTaskTrace = "taskTrace"
+
+	defaultPersistWALReplayInterval = 15 * time.Second
+	persistWALApplyTimeout          = 5 * time.Second
+)
+
+// walBinding 绑定写入。
+type walBinding struct {
+	AgentID       string `json:"agentId"`
+	CodexThreadID string `json:"codexThreadId"`
+	RolloutPath   string `json:"rolloutPath,omitempty"`
+}
+
+// walAlias 线程别名写入。
+type walAlias struct {
+	ThreadID string `json:"threadId"`
+	Alias    string `json:"alias"`
+}
+
+// walOp WAL 中的一条待持久化写入。
+type walOp struct {
+	Seq     uint64           `json:"seq"`
+	Kind    string           `json:"kind"`
+	At      time.Time        `json:"at"`
+	Binding *walBinding      `json:"binding,omitempty"`
+	Alias   *walAlias        `json:"alias,omitempty"`
+	Trace   *store.TaskTrace `json:"trace,omitempty"`
+}
+
+// persistWAL 本地 WAL 文件 (内存中保留全部待重放操作)。
+type persistWAL struct {
+	mu        sync.Mutex
+	path      string
+	ops       []walOp
+	seq       uint64
+	lastError string
+	replaying sync.Mutex
+}
+
+// openPersistWAL 打开 (或创建) WAL 并加载未重放的操作; 损坏的行跳过。
+func openPersistWAL(path string) (*persistWAL, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, apperrors.Wrap(err, "openPersistWAL", "ensure wal dir")
+	}
+	w := &persistWAL{path: path}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return w, nil
+	}
+	if err != nil {
+		return nil, apperrors.Wrap(err, "openPersistWAL", "open wal")
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 8*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var op walOp
+		if err := json.Unmarshal([]byte(line), &op); err != nil {
+			logger.Warn("persist wal: skip corrupt entry", logger.FieldPath, path, logger.FieldError, err)
+			continue
+		}
+		w.ops = append(w.ops, op)
+		if op.Seq > w.seq {
+			w.seq = op.Seq
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, apperrors.Wrap(err, "openPersistWAL", "read wal")
+	}
+	return w, nil
+}
+
+func (w *persistWAL) pending() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.ops)
+}
+
+func (w *persistWAL) snapshot() []walOp {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]walOp(nil), w.ops...)
+}
+
+// appendLocked 追加操作并同步落盘。
+func (w *persistWAL) appendLocked(op walOp) error {
+	w.seq++
+	op.Seq = w.seq
+	if op.At.IsZero() {
+		op.At = time.Now()
+	}
+	data, err := json.Marshal(op)
+	if err != nil {
+		return apperrors.Wrap(err, "persistWAL.append", "marshal op")
+	}
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return apperrors.Wrap(err, "persistWAL.append", "open wal")
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return apperrors.Wrap(err, "persistWAL.append", "write wal")
+	}
+	if err := f.Sync(); err != nil {
+		return apperrors.Wrap(err, "persistWAL.append", "sync wal")
+	}
+	w.ops = append(w.ops, op)
+	return nil
+}
+
+// submit 写入一条操作: WAL 为空时直接 apply, 连接类失败转入 WAL; WAL 非空时直接排队。
+// 返回 queued=true 表示已暂存待重放。
+func (w *persistWAL) submit(ctx context.Context, op walOp, apply func(context.Context, walOp) error) (bool, error) {
+	w.mu.Lock()
+	backlog := len(w.ops) > 0
+	w.mu.Unlock()
+	if !backlog {
+		err := apply(ctx, op)
+		if err == nil {
+			return false, nil
+		}
+		if !isOfflinePersistError(err) {
+			return false, err
+		}
+		w.mu.Lock()
+		w.lastError = err.Error()
+		w.mu.Unlock()
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.appendLocked(op); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// replay 按序重放; 遇到连接错误停止, 其余错误丢弃该操作。返回重放成功数与丢弃数。
+func (w *persistWAL) replay(ctx context.Context, apply func(context.Context, walOp) error) (int, int, error) {
+	w.replaying.Lock()
+	defer w.replaying.Unlock()
+
+	ops := w.snapshot()
+	replayed, dropped := 0, 0
+	var lastSeq uint64
+	var offlineErr error
+	for _, op := range ops {
+		if err := apply(ctx, op); err != nil {
+			if isOfflinePersistError(err) {
+				offlineErr = err
+				break
+			}
+			logger.Warn("persist wal: dropping op after non-retryable error",
+				"kind", op.Kind, "seq", op.Seq, logger.FieldError, err)
+			dropped++
+		} else {
+			replayed++
+		}
+		lastSeq = op.Seq
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if offlineErr != nil {
+		w.lastError = offlineErr.Error()
+	} else {
+		w.lastError = ""
+	}
+	if lastSeq == 0 {
+		return 0, 0, offlineErr
+	}
+	remaining := w.ops[:0:0]
+	for _, op := range w.ops {
+		if op.Seq > lastSeq {
+			remaining = append(remaining, op)
+		}
+	}
+	if err := w.rewriteLocked(remaining); err != nil {
+		return replayed, dropped, err
+	}
+	w.ops = remaining
+	return replayed, dropped, offlineErr
+}
+
+// rewriteLocked 原子重写 WAL 文件 (临时文件 + rename)。
+func (w *persistWAL) rewriteLocked(ops []walOp) error {
+	if len(ops) == 0 {
+		if err := os.Remove(w.path); err != nil && !os.IsNotExist(err) {
+			return apperrors.Wrap(err, "persistWAL.rewrite", "remove wal")
+		}
+		return nil
+	}
+	tmp := w.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return apperrors.Wrap(err, "persistWAL.rewrite", "create temp wal")
+	}
+	enc := json.NewEncoder(f)
+	for _, op := range ops {
+		if err := enc.Encode(op); err != nil {
+			f.Close()
+			return apperrors.Wrap(err, "persistWAL.rewrite", "encode op")
+		}
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return apperrors.Wrap(err, "persistWAL.rewrite", "sync temp wal")
+	}
+	if err := f.Close(); err != nil {
+		return apperrors.Wrap(err, "persistWAL.rewrite", "close temp wal")
+	}
+	if err := os.Rename(tmp, w.path); err != nil {
+		return apperrors.Wrap(err, "persistWAL.rewrite", "rename wal")
+	}
+	return nil
+}
+
+// isOfflinePersistError 判断是否为连接 / 网络类错误 (可重放), 而非数据或约束错误。
+func isOfflinePersistError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var connectErr *pgconn.ConnectError
+	if errors.As(err, &connectErr) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// 08xxx connection exception, 57P0x 管理员关闭 / 崩溃恢复中
+		return strings.HasPrefix(pgErr.Code, "08") || strings.HasPrefix(pgErr.Code, "57P")
+	}
+	if pgconn.SafeToRetry(err) {
+		return true
+	}
+	message := strings.ToLower(err.Error())
+	for _, marker := range []string{"connection refused", "connection reset", "broken pipe", "failed to connect", "conn closed", "closed pool", "no such host", "i/o timeout"} {
+		if strings.Contains(message, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// ========================================
+// Server 集成
+// ========================================
+
+// resolvePersistWALPath WAL 路径 (配置优先, 默认 ~/.multi-agent/persist-wal.jsonl)。
+func resolvePersistWALPath(path string) (string, error) {
+	if path = strings.TrimSpace(path); path != "" {
+		return path, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", apperrors.Wrap(err, "resolvePersistWALPath", "resolve user home")
+	}
+	return filepath.Join(homeDir, ".multi-agent", "persist-wal.jsonl"), nil
+}
+
+// applyPersistOp 执行一条持久化写入。
+func (s *Server) applyPersistOp(ctx context.Context, op walOp) error {
+	ctx, cancel := context.WithTimeout(ctx, persistWALApplyTimeout)
+	defer cancel()
+	switch op.Kind {
+	case walKindBinding:
+		if s.bindingStore == nil || op.Binding == nil {
+			return nil
+		}
+		return s.bindingStore.Bind(ctx, op.Binding.AgentID, op.Binding.CodexThreadID, op.Binding.RolloutPath)
+	case walKindAlias:
+		if op.Alias == nil {
+			return nil
+		}
+		return s.persistThreadAlias(ctx, op.Alias.ThreadID, op.Alias.Alias)
+	case walKindTaskTrace:
+		if s.taskTraceStore == nil || op.Trace == nil {
+			return nil
+		}
+		_, err := s.taskTraceStore.Create(ctx, op.Trace)
+		return err
+	default:
+		return apperrors.Newf("Server.applyPersistOp", "unknown wal op kind %q", op.Kind)
+	}
+}
+
+// persistDurable 持久化写入; WAL 启用时连接失败转入本地 WAL 而不是丢弃。
+func (s *Server) persistDurable(ctx context.Context, op walOp) error {
+	if s.persistWAL == nil {
+		return s.applyPersistOp(ctx, op)
+	}
+	queued, err := s.persistWAL.submit(ctx, op, s.applyPersistOp)
+	if queued {
+		logger.Info("persist wal: write queued for replay", "kind", op.Kind, "pending", s.persistWAL.pending())
+	}
+	return err
+}
+
+// startPersistReplayLoop 周期重放 WAL (仅在有积压时访问数据库)。
+func (s *Server) startPersistReplayLoop(ctx context.Context) {
+	if s.persistWAL == nil {
+		return
+	}
+	interval := defaultPersistWALReplayInterval
+	if s.cfg != nil && s.cfg.PersistWALReplaySec > 0 {
+		interval = time.Duration(s.cfg.PersistWALReplaySec) * time.Second
+	}
+	util.SafeGo(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if s.persistWAL.pending() > 0 {
+				s.replayPersistWAL(ctx)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+}
+
+func (s *Server) replayPersistWAL(ctx context.Context) (int, int, error) {
+	replayed, dropped, err := s.persistWAL.replay(ctx, s.applyPersistOp)
+	if replayed > 0 || dropped > 0 {
+		pending := s.persistWAL.pending()
+		logger.Info("persist wal: replayed", "replayed", replayed, "dropped", dropped, "pending", pending)
+		s.Notify("persist/replayed", map[string]any{"replayed": replayed, "dropped": dropped, "pending": pending})
+	}
+	return replayed, dropped, err
+}
+
+// persistStatus persist/status: WAL 积压情况。
+func (s *Server) persistStatus(_ context.Context, _ json.RawMessage) (any, error) {
+	if s.persistWAL == nil {
+		return map[string]any{"enabled": false, "pending": 0}, nil
+	}
+	ops := s.persistWAL.snapshot()
+	kinds := map[string]int{}
+	for _, op := range ops {
+		kinds[op.Kind]++
+	}
+	resp := map[string]any{
+		"enabled": true,
+		"path":    s.persistWAL.path,
+		"pending": len(ops),
+		"byKind":  kinds,
+	}
+	if len(ops) > 0 {
+		resp["oldest"] = ops[0].At
+	}
+	s.persistWAL.mu.Lock()
+	if s.persistWAL.lastError != "" {
+		resp["lastError"] = s.persistWAL.lastError
+	}
+	s.persistWAL.mu.Unlock()
+	return resp, nil
+}
+
+// persistReplay persist/replay: 立即重放。
+func (s *Server) persistReplay(ctx context.Context, _ json.RawMessage) (any, error) {
+	if s.persistWAL == nil {
+		return nil, apperrors.New("Server.persistReplay", "persist wal not enabled")
+	}
+	replayed, dropped, err := s.replayPersistWAL(ctx)
+	resp := map[string]any{"replayed": replayed, "dropped": dropped, "pending": s.persistWAL.pending()}
+	if err != nil {
+		resp["error"] = err.Error()
+	}
+	return resp, nil
+}
@@ -0,0 +1,94 @@
+package apiserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestIsOfflinePersistError(t *testing.T) {
+	offline := []error{
+		context.DeadlineExceeded,
+		fmt.Errorf("bind: %w", &pgconn.PgError{Code: "08006"}),
+		errors.New("failed to connect to `host=localhost`: dial error: connection refused"),
+		errors.New("closed pool"),
+	}
+	for _, err := range offline {
+		if !isOfflinePersistError(err) {
+			t.Errorf("%v should be offline", err)
+		}
+	}
+	online := []error{
+		nil,
+		&pgconn.PgError{Code: "23505"},
+		errors.New(`immutable binding violation: agent "a" already bound`),
+	}
+	for _, err := range online {
+		if isOfflinePersistError(err) {
+			t.Errorf("%v should not be offline", err)
+		}
+	}
+}
+
+func TestPersistWALQueuesWhileOfflineAndReplaysInOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal.jsonl")
+	wal, err := openPersistWAL(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	ctx := context.Background()
+	online := false
+	var applied []string
+	apply := func(_ context.Context, op walOp) error {
+		if !online {
+			return errors.New("dial tcp 127.0.0.1:5432: connect: connection refused")
+		}
+		if op.Alias != nil && op.Alias.Alias == "conflict" {
+			return errors.New("constraint violation")
+		}
+		applied = append(applied, op.Kind+":"+op.Alias.ThreadID)
+		return nil
+	}
+	alias := func(threadID, name string) walOp {
+		return walOp{Kind: walKindAlias, Alias: &walAlias{ThreadID: threadID, Alias: name}}
+	}
+
+	if queued, err := wal.submit(ctx, alias("t1", "one"), apply); err != nil || !queued {
+		t.Fatalf("offline submit = (%v, %v), want queued", queued, err)
+	}
+	// 积压期间即使在线也排队, 保证顺序。
+	online = true
+	if queued, _ := wal.submit(ctx, alias("t2", "conflict"), apply); !queued {
+		t.Fatal("writes behind a backlog must queue")
+	}
+	online = false
+	_, _ = wal.submit(ctx, alias("t3", "three"), apply)
+
+	reopened, err := openPersistWAL(path)
+	if err != nil || reopened.pending() != 3 {
+		t.Fatalf("reopened pending = %d, err = %v", reopened.pending(), err)
+	}
+	if replayed, _, err := reopened.replay(ctx, apply); replayed != 0 || !isOfflinePersistError(err) {
+		t.Fatalf("offline replay = (%d, %v)", replayed, err)
+	}
+
+	online = true
+	replayed, dropped, err := reopened.replay(ctx, apply)
+	if err != nil || replayed != 2 || dropped != 1 || reopened.pending() != 0 {
+		t.Fatalf("replay = (%d, %d, %v), pending %d", replayed, dropped, err, reopened.pending())
+	}
+	if len(applied) != 2 || applied[0] != "alias:t1" || applied[1] != "alias:t3" {
+		t.Fatalf("applied = %v", applied)
+	}
+	if empty, _ := openPersistWAL(path); empty.pending() != 0 {
+		t.Fatal("wal file should be empty after full replay")
+	}
+
+	if queued, err := reopened.submit(ctx, alias("t4", "conflict"), apply); queued || err == nil {
+		t.Fatalf("non-offline error should surface, got (%v, %v)", queued, err)
+	}
+}
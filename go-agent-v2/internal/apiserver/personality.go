@@ -0,0 +1,379 @@
+// personality.go — agent 人格目录 (命名系统提示词预设) 与线程人格。
+//
+// 人格 = codex 内置人格 (base: none / friendly / pragmatic) + 附加指令:
+//   - personality/apply 发送 /personality <base>, 附加指令随下一次 turn/start 注入一次;
+//   - 线程当前人格持久化 (表 thread_personalities, 无数据库时保存在进程内),
+//     thread/resume 成功后重新套用, 使恢复的会话保持原人格;
+//   - thread/personality/set 按目录名解析 (内置预设覆盖 codex 三种 base), 不再透传任意值。
+//
+// 自定义预设存表 personalities (无数据库时保存在进程内), 同名覆盖内置预设。
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/multi-agent/go-agent-v2/internal/runner"
+	"github.com/multi-agent/go-agent-v2/internal/store"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+const (
+	maxPersonalities         = 64
+	maxPersonalityInstrRunes = 16000
+	maxPersonalityDescRunes  = 500
+	personalitySlashCommand  = "/personality"
+)
+
+// personalityBases codex 内置人格 (/personality 参数)。
+var personalityBases = map[string]bool{
+	"":          true,
+	"none":      true,
+	"friendly":  true,
+	"pragmatic": true,
+}
+
+// builtinPersonalities 内置人格预设 (每种 codex base 一个同名预设, 另加常用组合)。
+func builtinPersonalities() map[string]store.Personality {
+	return map[string]store.Personality{
+		"none": {
+			Name:        "none",
+			Description: "codex 默认人格, 无附加指令",
+			Base:        "none",
+		},
+		"friendly": {
+			Name:        "friendly",
+			Description: "友好、耐心的协作风格",
+			Base:        "friendly",
+		},
+		"pragmatic": {
+			Name:        "pragmatic",
+			Description: "务实、直接, 聚焦可执行结果",
+			Base:        "pragmatic",
+		},
+		"mentor": {
+			Name:        "mentor",
+			Description: "讲解思路与取舍, 适合学习陌生代码",
+			Base:        "friendly",
+			Instructions: "在完成任务的同时简要解释关键决策与取舍, " +
+				"指出相关的代码位置与概念, 必要时给出进一步阅读的建议。",
+		},
+		"terse": {
+			Name:        "terse",
+			Description: "极简输出, 只给结论与改动",
+			Base:        "pragmatic",
+			Instructions: "回复保持最简: 只给结论、改动摘要与必要的命令, " +
+				"不复述问题, 不做客套说明。",
+		},
+	}
+}
+
+// normalizePersonality 校验并规整预设字段。
+func normalizePersonality(op string, p store.Personality) (store.Personality, error) {
+	p.Name = strings.ToLower(strings.TrimSpace(p.Name))
+	if !agentTemplateIDPattern.MatchString(p.Name) {
+		return p, apperrors.NewCodef(op, errcode.InvalidInput, "invalid personality name %q (want [a-z0-9._-], ≤64)", p.Name)
+	}
+	p.Base = strings.ToLower(strings.TrimSpace(p.Base))
+	if !personalityBases[p.Base] {
+		return p, apperrors.NewCodef(op, errcode.InvalidInput, "unknown base personality %q (want none / friendly / pragmatic)", p.Base)
+	}
+	p.Description = truncateRunes(strings.TrimSpace(p.Description), maxPersonalityDescRunes)
+	p.Instructions = strings.TrimSpace(p.Instructions)
+	if len([]rune(p.Instructions)) > maxPersonalityInstrRunes {
+		return p, apperrors.NewCodef(op, errcode.InvalidInput, "instructions exceeds %d runes", maxPersonalityInstrRunes)
+	}
+	if p.Base == "" && p.Instructions == "" {
+		return p, apperrors.NewCode(op, errcode.InvalidInput, "base or instructions is required")
+	}
+	return p, nil
+}
+
+// threadPersonalityBinding 线程当前人格。
+type threadPersonalityBinding struct {
+	Personality         store.Personality
+	PendingInstructions bool // 附加指令待随下一次 turn/start 注入
+}
+
+// personalityCatalog 自定义预设 (无数据库时) 与线程人格 (零值可用)。
+type personalityCatalog struct {
+	mu       sync.Mutex
+	custom   map[string]store.Personality
+	byThread map[string]threadPersonalityBinding
+	threads  map[string]string // 线程 → 人格名 (无数据库时的持久化替代)
+}
+
+func (c *personalityCatalog) listCustom() []store.Personality {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]store.Personality, 0, len(c.custom))
+	for _, p := range c.custom {
+		out = append(out, p)
+	}
+	return out
+}
+
+func (c *personalityCatalog) saveCustom(p store.Personality) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.custom == nil {
+		c.custom = make(map[string]store.Personality)
+	}
+	if _, exists := c.custom[p.Name]; !exists && len(c.custom) >= maxPersonalities {
+		return apperrors.NewCodef("personalityCatalog.saveCustom", errcode.InvalidInput, "too many personalities (max %d)", maxPersonalities)
+	}
+	c.custom[p.Name] = p
+	return nil
+}
+
+func (c *personalityCatalog) bind(threadID string, p store.Personality) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.byThread == nil {
+		c.byThread = make(map[string]threadPersonalityBinding)
+		c.threads = make(map[string]string)
+	}
+	c.byThread[threadID] = threadPersonalityBinding{Personality: p, PendingInstructions: p.Instructions != ""}
+	c.threads[threadID] = p.Name
+}
+
+func (c *personalityCatalog) boundName(threadID string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.threads[threadID]
+}
+
+// pendingInstructions 待注入的附加指令 (只读; turn 提交或排队成功后再 consumeInstructions)。
+func (c *personalityCatalog) pendingInstructions(threadID string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	binding, ok := c.byThread[threadID]
+	if !ok || !binding.PendingInstructions {
+		return ""
+	}
+	return binding.Personality.Instructions
+}
+
+// consumeInstructions 标记附加指令已注入 (每次 apply 仅注入一次); 期间切换为指令不同的人格时保持待注入。
+func (c *personalityCatalog) consumeInstructions(threadID, instructions string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	binding, ok := c.byThread[threadID]
+	if !ok || !binding.PendingInstructions || binding.Personality.Instructions != instructions {
+		return
+	}
+	binding.PendingInstructions = false
+	c.byThread[threadID] = binding
+}
+
+// ========================================
+// 目录读写
+// ========================================
+
+// loadPersonalities 内置预设 + 自定义预设 (同名时自定义覆盖内置)。
+func (s *Server) loadPersonalities(ctx context.Context) (map[string]store.Personality, error) {
+	all := builtinPersonalities()
+	custom := s.personalities.listCustom()
+	if s.personalityStore != nil {
+		saved, err := s.personalityStore.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		custom = saved
+	}
+	for _, p := range custom {
+		all[p.Name] = p
+	}
+	return all, nil
+}
+
+func (s *Server) resolvePersonality(ctx context.Context, op, name string) (store.Personality, error) {
+	all, err := s.loadPersonalities(ctx)
+	if err != nil {
+		return store.Personality{}, apperrors.Wrap(err, op, "load personalities")
+	}
+	key := strings.ToLower(strings.TrimSpace(name))
+	p, ok := all[key]
+	if !ok {
+		return store.Personality{}, apperrors.NewCodef(op, errcode.NotFound, "personality %q not found", name)
+	}
+	return p, nil
+}
+
+// threadPersonalityName 线程已持久化的人格名 (未设置返回空串)。
+func (s *Server) threadPersonalityName(ctx context.Context, threadID string) string {
+	if s.personalityStore != nil {
+		name, err := s.personalityStore.GetThread(ctx, threadID)
+		if err != nil {
+			logger.Warn("personality: load thread binding failed", logger.FieldThreadID, threadID, logger.FieldError, err)
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return s.personalities.boundName(threadID)
+}
+
+// applyPersonalityToProc 切换 codex 人格并登记附加指令。
+func (s *Server) applyPersonalityToProc(proc *runner.AgentProcess, threadID string, p store.Personality) error {
+	if p.Base != "" {
+		if err := proc.Client.SendCommand(personalitySlashCommand, p.Base); err != nil {
+			return apperrors.Wrap(err, "Server.applyPersonality", "send /personality")
+		}
+	}
+	s.personalities.bind(threadID, p)
+	return nil
+}
+
+// applyPersonality 套用人格到运行中的线程并持久化绑定。
+func (s *Server) applyPersonality(ctx context.Context, op, threadID, name string) (any, error) {
+	threadID = strings.TrimSpace(threadID)
+	if threadID == "" {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "threadId is required")
+	}
+	p, err := s.resolvePersonality(ctx, op, name)
+	if err != nil {
+		return nil, err
+	}
+	return s.withThread(threadID, func(proc *runner.AgentProcess) (any, error) {
+		if err := s.applyPersonalityToProc(proc, threadID, p); err != nil {
+			return nil, err
+		}
+		if s.personalityStore != nil {
+			if err := s.personalityStore.SetThread(ctx, threadID, p.Name); err != nil {
+				logger.Warn("personality: persist thread binding failed", logger.FieldThreadID, threadID, logger.FieldError, err)
+			}
+		}
+		logger.Info("personality/apply: applied",
+			logger.FieldThreadID, threadID,
+			logger.FieldName, p.Name,
+			"base", p.Base,
+		)
+		return map[string]any{"threadId": threadID, "personality": p}, nil
+	})
+}
+
+// reapplyThreadPersonality thread/resume 成功后重新套用线程已持久化的人格。
+func (s *Server) reapplyThreadPersonality(ctx context.Context, proc *runner.AgentProcess, threadID string) string {
+	name := s.threadPersonalityName(ctx, threadID)
+	if name == "" {
+		return ""
+	}
+	p, err := s.resolvePersonality(ctx, "Server.reapplyThreadPersonality", name)
+	if err == nil {
+		err = s.applyPersonalityToProc(proc, threadID, p)
+	}
+	if err != nil {
+		logger.Warn("personality: reapply on resume failed",
+			logger.FieldThreadID, threadID,
+			logger.FieldName, name,
+			logger.FieldError, err,
+		)
+		return ""
+	}
+	logger.Info("personality: reapplied on resume", logger.FieldThreadID, threadID, logger.FieldName, p.Name)
+	return p.Name
+}
+
+// ========================================
+// JSON-RPC
+// ========================================
+
+func (s *Server) personalityList(ctx context.Context, _ json.RawMessage) (any, error) {
+	all, err := s.loadPersonalities(ctx)
+	if err != nil {
+		return nil, apperrors.Wrap(err, "Server.personalityList", "load personalities")
+	}
+	builtin := builtinPersonalities()
+	type listItem struct {
+		store.Personality
+		BuiltIn bool `json:"builtIn,omitempty"`
+	}
+	items := make([]listItem, 0, len(all))
+	for name, p := range all {
+		b, ok := builtin[name]
+		items = append(items, listItem{Personality: p, BuiltIn: ok && b == p})
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+	return map[string]any{"personalities": items}, nil
+}
+
+type personalityCreateParams struct {
+	Name         string `json:"name"`
+	Description  string `json:"description,omitempty"`
+	Base         string `json:"base,omitempty"`
+	Instructions string `json:"instructions,omitempty"`
+	Overwrite    bool   `json:"overwrite,omitempty"`
+}
+
+func (s *Server) personalityCreateTyped(ctx context.Context, p personalityCreateParams) (any, error) {
+	const op = "Server.personalityCreate"
+	personality, err := normalizePersonality(op, store.Personality{
+		Name:         p.Name,
+		Description:  p.Description,
+		Base:         p.Base,
+		Instructions: p.Instructions,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.personalityMu.Lock()
+	defer s.personalityMu.Unlock()
+	if !p.Overwrite {
+		all, err := s.loadPersonalities(ctx)
+		if err != nil {
+			return nil, apperrors.Wrap(err, op, "load personalities")
+		}
+		if _, exists := all[personality.Name]; exists {
+			return nil, apperrors.NewCodef(op, errcode.InvalidInput, "personality %q already exists (set overwrite to replace)", personality.Name)
+		}
+	}
+	if s.personalityStore != nil {
+		n, err := s.personalityStore.Count(ctx)
+		if err != nil {
+			return nil, apperrors.Wrap(err, op, "count personalities")
+		}
+		if n >= maxPersonalities && !p.Overwrite {
+			return nil, apperrors.NewCodef(op, errcode.InvalidInput, "too many personalities (max %d)", maxPersonalities)
+		}
+		saved, err := s.personalityStore.Save(ctx, personality)
+		if err != nil {
+			return nil, apperrors.Wrap(err, op, "save personality")
+		}
+		personality = *saved
+	} else if err := s.personalities.saveCustom(personality); err != nil {
+		return nil, err
+	}
+	logger.Info("personality/create: saved",
+		logger.FieldName, personality.Name,
+		"base", personality.Base,
+		"instructions_len", len(personality.Instructions),
+	)
+	return map[string]any{"personality": personality}, nil
+}
+
+type personalityApplyParams struct {
+	ThreadID string `json:"threadId"`
+	Name     string `json:"name"`
+}
+
+func (s *Server) personalityApplyTyped(ctx context.Context, p personalityApplyParams) (any, error) {
+	return s.applyPersonality(ctx, "Server.personalityApply", p.ThreadID, p.Name)
+}
+
+// threadPersonalitySetParams thread/personality/set 请求参数 (personality 为目录中的预设名)。
+type threadPersonalitySetParams struct {
+	ThreadID    string `json:"threadId"`
+	Personality string `json:"personality"`
+}
+
+// threadPersonalityTyped 设置人格 (按目录预设套用, 见 personality/apply)。
+func (s *Server) threadPersonalityTyped(ctx context.Context, p threadPersonalitySetParams) (any, error) {
+	return s.applyPersonality(ctx, "Server.threadPersonality", p.ThreadID, p.Personality)
+}
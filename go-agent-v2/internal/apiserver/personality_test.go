@@ -0,0 +1,109 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/multi-agent/go-agent-v2/internal/codex"
+	"github.com/multi-agent/go-agent-v2/internal/runner"
+	"github.com/multi-agent/go-agent-v2/internal/store"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+func TestPersonalityCreateAndList(t *testing.T) {
+	srv := &Server{}
+	ctx := context.Background()
+
+	if _, err := srv.personalityCreateTyped(ctx, personalityCreateParams{Name: "Bad Name", Base: "friendly"}); apperrors.CodeOf(err) != errcode.InvalidInput {
+		t.Fatalf("invalid name err = %v", err)
+	}
+	if _, err := srv.personalityCreateTyped(ctx, personalityCreateParams{Name: "x", Base: "grumpy"}); apperrors.CodeOf(err) != errcode.InvalidInput {
+		t.Fatalf("unknown base err = %v", err)
+	}
+	if _, err := srv.personalityCreateTyped(ctx, personalityCreateParams{Name: "x"}); apperrors.CodeOf(err) != errcode.InvalidInput {
+		t.Fatalf("empty personality err = %v", err)
+	}
+	if _, err := srv.personalityCreateTyped(ctx, personalityCreateParams{Name: "Reviewer", Base: "Pragmatic", Instructions: " be strict "}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := srv.personalityCreateTyped(ctx, personalityCreateParams{Name: "reviewer", Base: "none"}); apperrors.CodeOf(err) != errcode.InvalidInput {
+		t.Fatalf("duplicate without overwrite err = %v", err)
+	}
+	if _, err := srv.personalityCreateTyped(ctx, personalityCreateParams{Name: "terse", Instructions: "one line", Overwrite: true}); err != nil {
+		t.Fatalf("override builtin: %v", err)
+	}
+
+	resp, err := srv.personalityList(ctx, nil)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	raw, _ := json.Marshal(resp)
+	var got struct {
+		Personalities []struct {
+			store.Personality
+			BuiltIn bool `json:"builtIn"`
+		} `json:"personalities"`
+	}
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("decode list: %v", err)
+	}
+	byName := map[string]int{}
+	for i, p := range got.Personalities {
+		byName[p.Name] = i
+	}
+	if len(got.Personalities) != len(builtinPersonalities())+1 {
+		t.Fatalf("personalities = %+v", got.Personalities)
+	}
+	reviewer := got.Personalities[byName["reviewer"]]
+	if reviewer.Base != "pragmatic" || reviewer.Instructions != "be strict" || reviewer.BuiltIn {
+		t.Fatalf("reviewer = %+v", reviewer)
+	}
+	if terse := got.Personalities[byName["terse"]]; terse.BuiltIn || terse.Instructions != "one line" {
+		t.Fatalf("terse = %+v", terse)
+	}
+	if !got.Personalities[byName["friendly"]].BuiltIn {
+		t.Fatal("friendly should be builtin")
+	}
+}
+
+func TestPersonalityApplyPersistsAcrossResume(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	mgr := runner.NewAgentManager()
+	mgr.SetClientFactory(codex.MockClientFactory(codex.DefaultMockScript, 0))
+	srv := New(Deps{Manager: mgr})
+	ctx := context.Background()
+
+	res, err := srv.InvokeMethod(ctx, "thread/start", json.RawMessage(`{"cwd":"."}`))
+	if err != nil {
+		t.Fatalf("thread/start: %v", err)
+	}
+	threadID := res.(threadStartResponse).Thread.ID
+
+	if _, err := srv.InvokeMethod(ctx, "personality/apply", json.RawMessage(`{"threadId":"`+threadID+`","name":"missing"}`)); apperrors.CodeOf(err) != errcode.NotFound {
+		t.Fatalf("unknown personality err = %v", err)
+	}
+	if _, err := srv.InvokeMethod(ctx, "thread/personality/set", json.RawMessage(`{"threadId":"`+threadID+`","personality":"mentor"}`)); err != nil {
+		t.Fatalf("thread/personality/set: %v", err)
+	}
+	want := builtinPersonalities()["mentor"].Instructions
+	if got := srv.personalities.pendingInstructions(threadID); got != want {
+		t.Fatalf("instructions = %q, want %q", got, want)
+	}
+	srv.personalities.consumeInstructions(threadID, want)
+	if got := srv.personalities.pendingInstructions(threadID); got != "" {
+		t.Fatalf("instructions injected twice: %q", got)
+	}
+
+	resumed, err := srv.InvokeMethod(ctx, "thread/resume", json.RawMessage(`{"threadId":"`+threadID+`"}`))
+	if err != nil {
+		t.Fatalf("thread/resume: %v", err)
+	}
+	if got := resumed.(threadResumeResponse).Personality; got != "mentor" {
+		t.Fatalf("resume personality = %q", got)
+	}
+	if got := srv.personalities.pendingInstructions(threadID); got != want {
+		t.Fatalf("instructions after resume = %q", got)
+	}
+}
@@ -0,0 +1,228 @@
+// prompt_render.go — 提示词模板渲染 (promptTemplate/render, turn/startFromTemplate)。
+//
+// 模板文本中的 {{name}} (允许两侧空白) 以变量值替换。变量声明取自 prompt_templates.variables,
+// 支持三种形态:
+//
+//	["repo", "branch"]                                         全部必填
+//	[{"name": "branch", "required": false, "default": "main"}] 逐项声明
+//	{"branch": {"default": "main"}, "repo": {}}                以名称为键
+//
+// 文本中出现但未声明的变量视为必填; 缺少必填变量时返回 INVALID_INPUT 并列出缺失项。
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+var promptVariablePattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_.-]*)\s*\}\}`)
+
+// promptVariable 模板变量声明。
+type promptVariable struct {
+	Name        string `json:"name"`
+	Required    bool   `json:"required"`
+	Default     any    `json:"default,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// parsePromptVariables 解析 variables 列 (数组 / 对象两种形态)。
+func parsePromptVariables(raw any) []promptVariable {
+	var out []promptVariable
+	add := func(name string, spec map[string]any) {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return
+		}
+		v := promptVariable{Name: name, Required: true}
+		if spec != nil {
+			if required, ok := spec["required"].(bool); ok {
+				v.Required = required
+			}
+			if def, ok := spec["default"]; ok && def != nil {
+				v.Default = def
+				v.Required = false
+			}
+			v.Description, _ = spec["description"].(string)
+		}
+		out = append(out, v)
+	}
+	switch vars := raw.(type) {
+	case []any:
+		for _, item := range vars {
+			switch entry := item.(type) {
+			case string:
+				add(entry, nil)
+			case map[string]any:
+				name, _ := entry["name"].(string)
+				add(name, entry)
+			}
+		}
+	case map[string]any:
+		names := make([]string, 0, len(vars))
+		for name := range vars {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			switch spec := vars[name].(type) {
+			case map[string]any:
+				add(name, spec)
+			case nil:
+				add(name, nil)
+			default:
+				add(name, map[string]any{"default": spec})
+			}
+		}
+	}
+	return out
+}
+
+func promptValueString(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	case float64, bool, int, int64:
+		return fmt.Sprint(v)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	}
+}
+
+// promptRenderResult 渲染结果。
+type promptRenderResult struct {
+	Text      string           `json:"text"`
+	Variables []promptVariable `json:"variables"`        // 声明 + 文本中引用的全部变量
+	Unused    []string         `json:"unused,omitempty"` // 传入但模板未引用的变量
+}
+
+// renderPromptTemplate 替换 {{name}}; 缺少必填变量时报错。
+func renderPromptTemplate(text string, declared []promptVariable, values map[string]any) (promptRenderResult, error) {
+	const op = "Server.promptTemplateRender"
+	vars := make(map[string]promptVariable, len(declared))
+	order := make([]string, 0, len(declared))
+	for _, v := range declared {
+		if _, ok := vars[v.Name]; !ok {
+			order = append(order, v.Name)
+		}
+		vars[v.Name] = v
+	}
+	referenced := map[string]bool{}
+	for _, match := range promptVariablePattern.FindAllStringSubmatch(text, -1) {
+		name := match[1]
+		referenced[name] = true
+		if _, ok := vars[name]; !ok {
+			vars[name] = promptVariable{Name: name, Required: true}
+			order = append(order, name)
+		}
+	}
+
+	var missing []string
+	resolved := make(map[string]string, len(vars))
+	for _, name := range order {
+		v := vars[name]
+		if value, ok := values[name]; ok && value != nil {
+			resolved[name] = promptValueString(value)
+			continue
+		}
+		if v.Default != nil {
+			resolved[name] = promptValueString(v.Default)
+			continue
+		}
+		if v.Required {
+			missing = append(missing, name)
+			continue
+		}
+		resolved[name] = ""
+	}
+	if len(missing) > 0 {
+		return promptRenderResult{}, apperrors.NewCodef(op, errcode.InvalidInput, "missing required variables: %s", strings.Join(missing, ", "))
+	}
+
+	rendered := promptVariablePattern.ReplaceAllStringFunc(text, func(match string) string {
+		name := promptVariablePattern.FindStringSubmatch(match)[1]
+		return resolved[name]
+	})
+	result := promptRenderResult{Text: rendered, Variables: make([]promptVariable, 0, len(order))}
+	for _, name := range order {
+		result.Variables = append(result.Variables, vars[name])
+	}
+	for name := range values {
+		if !referenced[name] {
+			result.Unused = append(result.Unused, name)
+		}
+	}
+	sort.Strings(result.Unused)
+	return result, nil
+}
+
+type promptTemplateRenderParams struct {
+	PromptKey string         `json:"promptKey,omitempty"`
+	Template  string         `json:"template,omitempty"` // 内联模板 (无 promptKey 时)
+	Variables map[string]any `json:"variables,omitempty"`
+}
+
+// renderPromptParams 加载模板 (promptKey 或内联) 并渲染。
+func (s *Server) renderPromptParams(ctx context.Context, p promptTemplateRenderParams) (promptRenderResult, error) {
+	const op = "Server.promptTemplateRender"
+	key := strings.TrimSpace(p.PromptKey)
+	if key == "" {
+		if strings.TrimSpace(p.Template) == "" {
+			return promptRenderResult{}, apperrors.NewCode(op, errcode.InvalidInput, "promptKey or template is required")
+		}
+		return renderPromptTemplate(p.Template, nil, p.Variables)
+	}
+	if s.promptStore == nil {
+		return promptRenderResult{}, apperrors.New(op, "prompt template store requires database")
+	}
+	tmpl, err := s.promptStore.Get(ctx, key)
+	if err != nil {
+		return promptRenderResult{}, apperrors.Wrapf(err, op, "load template %s", key)
+	}
+	if tmpl == nil {
+		return promptRenderResult{}, apperrors.NewCodef(op, errcode.NotFound, "prompt template %s not found", key)
+	}
+	if !tmpl.Enabled {
+		return promptRenderResult{}, apperrors.NewCodef(op, errcode.InvalidInput, "prompt template %s is disabled", key)
+	}
+	return renderPromptTemplate(tmpl.PromptText, parsePromptVariables(tmpl.Variables), p.Variables)
+}
+
+func (s *Server) promptTemplateRenderTyped(ctx context.Context, p promptTemplateRenderParams) (any, error) {
+	return s.renderPromptParams(ctx, p)
+}
+
+// turnStartFromTemplateParams turn/start 参数 + 模板; input 作为附加输入排在渲染文本之后。
+type turnStartFromTemplateParams struct {
+	turnStartParams
+	promptTemplateRenderParams
+}
+
+func (s *Server) turnStartFromTemplateTyped(ctx context.Context, p turnStartFromTemplateParams) (any, error) {
+	rendered, err := s.renderPromptParams(ctx, p.promptTemplateRenderParams)
+	if err != nil {
+		return nil, err
+	}
+	start := p.turnStartParams
+	start.Input = append([]UserInput{{Type: "text", Text: rendered.Text}}, start.Input...)
+	logger.Info("turn/startFromTemplate: template rendered",
+		logger.FieldThreadID, start.ThreadID,
+		"prompt_key", strings.TrimSpace(p.PromptKey),
+		"variables", len(rendered.Variables),
+		logger.FieldLen, len(rendered.Text),
+	)
+	return s.turnStartTyped(ctx, start)
+}
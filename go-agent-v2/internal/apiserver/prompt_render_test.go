@@ -0,0 +1,75 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+func TestParsePromptVariablesShapes(t *testing.T) {
+	var list, objects, keyed any
+	_ = json.Unmarshal([]byte(`["repo"]`), &list)
+	_ = json.Unmarshal([]byte(`[{"name":"branch","default":"main"},{"name":"note","required":false}]`), &objects)
+	_ = json.Unmarshal([]byte(`{"repo":{},"branch":"main"}`), &keyed)
+
+	if got := parsePromptVariables(list); len(got) != 1 || !got[0].Required {
+		t.Fatalf("list = %+v", got)
+	}
+	if got := parsePromptVariables(objects); len(got) != 2 || got[0].Required || got[0].Default != "main" || got[1].Required {
+		t.Fatalf("objects = %+v", got)
+	}
+	if got := parsePromptVariables(keyed); len(got) != 2 || got[0].Name != "branch" || got[0].Default != "main" || !got[1].Required {
+		t.Fatalf("keyed = %+v", got)
+	}
+}
+
+func TestRenderPromptTemplate(t *testing.T) {
+	declared := []promptVariable{{Name: "branch", Default: "main"}, {Name: "note"}}
+	text := "Review {{ repo }} on {{branch}}.{{note}} Files: {{files}}"
+
+	got, err := renderPromptTemplate(text, declared, map[string]any{
+		"repo":  "api",
+		"files": []any{"a.go", "b.go"},
+		"extra": 1,
+	})
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if got.Text != `Review api on main. Files: ["a.go","b.go"]` {
+		t.Fatalf("text = %q", got.Text)
+	}
+	if len(got.Variables) != 4 || strings.Join(got.Unused, ",") != "extra" {
+		t.Fatalf("result = %+v", got)
+	}
+
+	_, err = renderPromptTemplate(text, declared, map[string]any{"repo": "api"})
+	if apperrors.CodeOf(err) != errcode.InvalidInput || !strings.Contains(err.Error(), "files") {
+		t.Fatalf("missing err = %v", err)
+	}
+}
+
+func TestPromptTemplateRenderRequiresSource(t *testing.T) {
+	srv := &Server{}
+	if _, err := srv.promptTemplateRenderTyped(context.Background(), promptTemplateRenderParams{}); apperrors.CodeOf(err) != errcode.InvalidInput {
+		t.Fatalf("err = %v", err)
+	}
+	res, err := srv.promptTemplateRenderTyped(context.Background(), promptTemplateRenderParams{Template: "hi {{who}}", Variables: map[string]any{"who": "bob"}})
+	if err != nil || res.(promptRenderResult).Text != "hi bob" {
+		t.Fatalf("inline = %+v, %v", res, err)
+	}
+	if _, err := srv.promptTemplateRenderTyped(context.Background(), promptTemplateRenderParams{PromptKey: "k"}); err == nil {
+		t.Fatal("expected error without prompt store")
+	}
+
+	var p turnStartFromTemplateParams
+	if err := json.Unmarshal([]byte(`{"threadId":"t1","template":"x","variables":{"a":1},"input":[{"type":"text","text":"more"}]}`), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.ThreadID != "t1" || p.Template != "x" || p.Variables["a"] != float64(1) || len(p.Input) != 1 {
+		t.Fatalf("params = %+v", p)
+	}
+}
@@ -4,7 +4,7 @@
 //
 //	Request:      {"jsonrpc":"2.0", "id":1, "method":"...", "params":{...}}
 //	Response:     {"jsonrpc":"2.0", "id":1, "result":{...}}
-//	Error:        {"jsonrpc":"2.0", "id":1, "error":{"code":..., "message":"..."}}
+//	Error:        {"jsonrpc":"2.0", "id":1, "error":{"code":..., "message":"...", "data":{"code":"THREAD_NOT_FOUND","retryable":false}}}
 //	Notification: {"jsonrpc":"2.0", "method":"...", "params":{...}}
 package apiserver
 
@@ -12,6 +12,7 @@ import (
 	"context"
 	"encoding/json"
 
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
 	pkgerr "github.com/multi-agent/go-agent-v2/pkg/errors"
 )
 
@@ -31,6 +32,8 @@ type Response struct {
 	ID      any       `json:"id"`
 	Result  any       `json:"result,omitempty"`
 	Error   *RPCError `json:"error,omitempty"`
+	// CorrelationID 服务端为本次调用分配的关联 ID (audit/trace/get 查询因果链)
+	CorrelationID string `json:"correlationId,omitempty"`
 }
 
 // Notification JSON-RPC 2.0 通知 (无 id, 服务端主动推送)。
@@ -94,7 +97,7 @@ func typedHandler[P any](fn func(ctx context.Context, p P) (any, error)) Handler
 		var p P
 		if raw != nil {
 			if err := json.Unmarshal(raw, &p); err != nil {
-				return nil, pkgerr.Wrap(err, "TypedHandler", "invalid params")
+				return nil, pkgerr.WrapCode(err, "TypedHandler", errcode.InvalidParams, "invalid params")
 			}
 		}
 		return fn(ctx, p)
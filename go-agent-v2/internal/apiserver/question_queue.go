@@ -0,0 +1,286 @@
+// question_queue.go — 人在回路提问队列: 动态工具 ask_user 与 JSON-RPC question/answer, question/list。
+//
+// 流程:
+//  1. agent 调用 ask_user(question, options) — 工具调用阻塞, turn 随之暂停 (外层心跳防止 stall 误判);
+//  2. 登记待回答问题并广播 question/asked 到所有客户端;
+//  3. 任一客户端 question/answer 作答 (先到先得), 答案作为工具结果回传, turn 继续;
+//  4. 超时或 turn/interrupt 时问题作废, 工具返回错误由模型自行处理; 结束时广播 question/resolved。
+package apiserver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/codex"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+const (
+	questionStatusPending   = "pending"
+	questionStatusAnswered  = "answered"
+	questionStatusCancelled = "cancelled"
+	questionStatusExpired   = "expired"
+
+	// askUserTimeout 无人作答时问题作废的等待上限。
+	askUserTimeout = 30 * time.Minute
+
+	maxQuestionOptions = 10
+	maxQuestionChars   = 4000
+)
+
+// pendingQuestion 一条 ask_user 提问记录。
+type pendingQuestion struct {
+	ID          string    `json:"id"`
+	ThreadID    string    `json:"threadId"`
+	TurnID      string    `json:"turnId,omitempty"`
+	CallID      string    `json:"callId,omitempty"`
+	Question    string    `json:"question"`
+	Options     []string  `json:"options,omitempty"` // 空 = 自由作答
+	Status      string    `json:"status"`            // pending / answered / cancelled / expired
+	Answer      string    `json:"answer,omitempty"`
+	OptionIndex *int      `json:"optionIndex,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	ResolvedAt  time.Time `json:"resolvedAt,omitzero"`
+}
+
+// questionQueue 待回答问题表 (零值可用)。
+type questionQueue struct {
+	mu      sync.Mutex
+	pending map[string]*pendingQuestion
+	waiters map[string]chan pendingQuestion
+}
+
+// add 登记问题 (队列持有副本), 返回在问题结束时收到最终记录的 channel。
+func (q *questionQueue) add(item pendingQuestion) <-chan pendingQuestion {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.pending == nil {
+		q.pending = make(map[string]*pendingQuestion)
+		q.waiters = make(map[string]chan pendingQuestion)
+	}
+	ch := make(chan pendingQuestion, 1)
+	q.pending[item.ID] = &item
+	q.waiters[item.ID] = ch
+	return ch
+}
+
+func (q *questionQueue) get(id string) (pendingQuestion, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	item, ok := q.pending[id]
+	if !ok {
+		return pendingQuestion{}, false
+	}
+	return *item, true
+}
+
+// resolve 结束问题并唤醒等待方; 问题已结束时返回 false (先到先得)。
+func (q *questionQueue) resolve(id, status, answer string, optionIndex *int) (pendingQuestion, bool) {
+	q.mu.Lock()
+	item, ok := q.pending[id]
+	ch := q.waiters[id]
+	delete(q.pending, id)
+	delete(q.waiters, id)
+	q.mu.Unlock()
+	if !ok {
+		return pendingQuestion{}, false
+	}
+	final := *item
+	final.Status, final.Answer, final.OptionIndex, final.ResolvedAt = status, answer, optionIndex, time.Now()
+	ch <- final
+	return final, true
+}
+
+// list 按创建时间返回待回答问题 (threadID 为空 = 全部)。
+func (q *questionQueue) list(threadID string) []pendingQuestion {
+	q.mu.Lock()
+	out := make([]pendingQuestion, 0, len(q.pending))
+	for _, item := range q.pending {
+		if threadID == "" || item.ThreadID == threadID {
+			out = append(out, *item)
+		}
+	}
+	q.mu.Unlock()
+	slices.SortFunc(out, func(a, b pendingQuestion) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return out
+}
+
+// buildQuestionTools 返回 ask_user 工具定义。
+func (s *Server) buildQuestionTools() []codex.DynamicTool {
+	return []codex.DynamicTool{{
+		Name: "ask_user",
+		Description: "Ask the user a clarifying question and wait for the answer before continuing. " +
+			"Use it only when the task is ambiguous and a wrong guess would be costly. " +
+			"Provide options when the answer is one of a few choices; the result contains the chosen answer.",
+		InputSchema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"question": map[string]any{"type": "string", "description": "The question to show to the user"},
+				"options": map[string]any{
+					"type":        "array",
+					"items":       map[string]any{"type": "string"},
+					"description": "Optional answer choices (max 10); omit for a free-form answer",
+				},
+			},
+			"required": []string{"question"},
+		},
+	}}
+}
+
+// askUserFrom 执行 ask_user: 登记问题、广播并阻塞直到作答 / 作废。
+func (s *Server) askUserFrom(agentID, callID string, args json.RawMessage) string {
+	const op = "QuestionTool.AskUser"
+	var p struct {
+		Question string   `json:"question"`
+		Options  []string `json:"options"`
+	}
+	if err := json.Unmarshal(args, &p); err != nil {
+		return toolError(apperrors.Wrap(err, op, "invalid args"))
+	}
+	question := strings.TrimSpace(p.Question)
+	if question == "" {
+		return toolError(apperrors.New(op, "question is required"))
+	}
+	if len([]rune(question)) > maxQuestionChars {
+		return toolError(apperrors.Newf(op, "question exceeds %d characters", maxQuestionChars))
+	}
+	options := make([]string, 0, len(p.Options))
+	for _, raw := range p.Options {
+		if opt := strings.TrimSpace(raw); opt != "" && !slices.Contains(options, opt) {
+			options = append(options, opt)
+		}
+	}
+	if len(options) > maxQuestionOptions {
+		return toolError(apperrors.Newf(op, "at most %d options are allowed", maxQuestionOptions))
+	}
+
+	item := pendingQuestion{
+		ID:        "question-" + rand.Text(),
+		ThreadID:  agentID,
+		TurnID:    s.activeTrackedTurnID(agentID),
+		CallID:    strings.TrimSpace(callID),
+		Question:  question,
+		Options:   options,
+		Status:    questionStatusPending,
+		CreatedAt: time.Now(),
+	}
+	done := s.questions.add(item)
+	logger.Info("ask_user: question pending",
+		logger.FieldAgentID, agentID, logger.FieldThreadID, agentID,
+		"question_id", item.ID,
+		"options", len(options),
+	)
+	s.Notify("question/asked", item)
+
+	timer := time.NewTimer(askUserTimeout)
+	defer timer.Stop()
+	var final pendingQuestion
+	select {
+	case final = <-done:
+	case <-timer.C:
+		if expired, ok := s.questions.resolve(item.ID, questionStatusExpired, "", nil); ok {
+			s.Notify("question/resolved", expired)
+		}
+		final = <-done
+	}
+
+	logger.Info("ask_user: question resolved",
+		logger.FieldAgentID, agentID, logger.FieldThreadID, agentID,
+		"question_id", final.ID,
+		logger.FieldStatus, final.Status,
+		logger.FieldDurationMS, final.ResolvedAt.Sub(final.CreatedAt).Milliseconds(),
+	)
+	if final.Status != questionStatusAnswered {
+		return toolError(apperrors.Newf(op, "question %s was %s without an answer; continue with your best judgement or stop", final.ID, final.Status))
+	}
+	result := map[string]any{"questionId": final.ID, "answer": final.Answer}
+	if final.OptionIndex != nil {
+		result["optionIndex"] = *final.OptionIndex
+	}
+	return toolJSON(result)
+}
+
+// cancelThreadQuestions 作废线程的全部待回答问题 (turn/interrupt), 返回作废数量。
+func (s *Server) cancelThreadQuestions(threadID string) int {
+	cancelled := 0
+	for _, item := range s.questions.list(threadID) {
+		if resolved, ok := s.questions.resolve(item.ID, questionStatusCancelled, "", nil); ok {
+			s.Notify("question/resolved", resolved)
+			cancelled++
+		}
+	}
+	return cancelled
+}
+
+// ========================================
+// JSON-RPC
+// ========================================
+
+type questionAnswerParams struct {
+	QuestionID  string `json:"questionId"`
+	Answer      string `json:"answer,omitempty"`
+	OptionIndex *int   `json:"optionIndex,omitempty"` // 与 answer 二选一, 按下标选择选项
+}
+
+// questionAnswerTyped question/answer: 回答待处理问题, 答案回传给等待中的 agent。
+func (s *Server) questionAnswerTyped(_ context.Context, p questionAnswerParams) (any, error) {
+	const op = "Server.questionAnswer"
+	id := strings.TrimSpace(p.QuestionID)
+	item, ok := s.questions.get(id)
+	if !ok {
+		return nil, apperrors.NewCodef(op, errcode.NotFound, "question %q is not pending", p.QuestionID)
+	}
+	answer, index, err := matchQuestionAnswer(item.Options, p.Answer, p.OptionIndex)
+	if err != nil {
+		return nil, apperrors.WrapCode(err, op, errcode.InvalidInput, "invalid answer")
+	}
+	resolved, ok := s.questions.resolve(id, questionStatusAnswered, answer, index)
+	if !ok {
+		return nil, apperrors.NewCodef(op, errcode.NotFound, "question %q is not pending", p.QuestionID)
+	}
+	s.Notify("question/resolved", resolved)
+	return resolved, nil
+}
+
+// matchQuestionAnswer 校验答案: 有选项时必须命中其一 (按文本或下标), 否则为非空自由文本。
+func matchQuestionAnswer(options []string, answer string, optionIndex *int) (string, *int, error) {
+	answer = strings.TrimSpace(answer)
+	if len(options) == 0 {
+		if optionIndex != nil {
+			return "", nil, apperrors.New("matchQuestionAnswer", "question has no options")
+		}
+		if answer == "" {
+			return "", nil, apperrors.New("matchQuestionAnswer", "answer is required")
+		}
+		return answer, nil, nil
+	}
+	if optionIndex != nil {
+		i := *optionIndex
+		if i < 0 || i >= len(options) {
+			return "", nil, apperrors.Newf("matchQuestionAnswer", "optionIndex must be within [0, %d]", len(options)-1)
+		}
+		return options[i], &i, nil
+	}
+	for i, opt := range options {
+		if strings.EqualFold(opt, answer) {
+			return opt, &i, nil
+		}
+	}
+	return "", nil, apperrors.Newf("matchQuestionAnswer", "answer must be one of %q", options)
+}
+
+type questionListParams struct {
+	ThreadID string `json:"threadId,omitempty"`
+}
+
+// questionListTyped question/list: 待回答问题 (新连接的客户端据此补齐提示)。
+func (s *Server) questionListTyped(_ context.Context, p questionListParams) (any, error) {
+	return map[string]any{"questions": s.questions.list(strings.TrimSpace(p.ThreadID))}, nil
+}
@@ -0,0 +1,113 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitPendingQuestion 等待线程出现待回答问题。
+func waitPendingQuestion(t *testing.T, srv *Server, threadID string) pendingQuestion {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if items := srv.questions.list(threadID); len(items) > 0 {
+			return items[0]
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("no pending question for %s", threadID)
+	return pendingQuestion{}
+}
+
+func TestAskUser_AnswerResumesToolCall(t *testing.T) {
+	srv := New(Deps{})
+	notified := make(chan string, 4)
+	srv.SetNotifyHook(func(method string, _ any) {
+		if strings.HasPrefix(method, "question/") {
+			notified <- method
+		}
+	})
+
+	result := make(chan string, 1)
+	go func() {
+		result <- srv.askUserFrom("thread-1", "call-1", json.RawMessage(`{"question":"Which database?","options":["postgres"," sqlite ","postgres"]}`))
+	}()
+	item := waitPendingQuestion(t, srv, "thread-1")
+	if item.Question != "Which database?" || len(item.Options) != 2 || item.CallID != "call-1" {
+		t.Fatalf("pending question = %+v", item)
+	}
+	if got := <-notified; got != "question/asked" {
+		t.Fatalf("first notification = %s", got)
+	}
+
+	ctx := context.Background()
+	if _, err := srv.questionAnswerTyped(ctx, questionAnswerParams{QuestionID: item.ID, Answer: "mysql"}); err == nil {
+		t.Fatal("answer outside options should fail")
+	}
+	resolved, err := srv.questionAnswerTyped(ctx, questionAnswerParams{QuestionID: item.ID, Answer: "SQLite"})
+	if err != nil {
+		t.Fatalf("question/answer: %v", err)
+	}
+	if q := resolved.(pendingQuestion); q.Status != questionStatusAnswered || q.Answer != "sqlite" || *q.OptionIndex != 1 {
+		t.Fatalf("resolved = %+v", q)
+	}
+
+	select {
+	case out := <-result:
+		var got map[string]any
+		if err := json.Unmarshal([]byte(out), &got); err != nil || got["answer"] != "sqlite" || got["optionIndex"] != float64(1) {
+			t.Fatalf("tool result = %s", out)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ask_user did not resume after answer")
+	}
+	if got := <-notified; got != "question/resolved" {
+		t.Fatalf("second notification = %s", got)
+	}
+	if _, err := srv.questionAnswerTyped(ctx, questionAnswerParams{QuestionID: item.ID, Answer: "postgres"}); err == nil {
+		t.Fatal("answering a resolved question should fail")
+	}
+}
+
+func TestAskUser_CancelledOnInterrupt(t *testing.T) {
+	srv := New(Deps{})
+	result := make(chan string, 1)
+	go func() {
+		result <- srv.askUserFrom("thread-2", "", json.RawMessage(`{"question":"Proceed?"}`))
+	}()
+	waitPendingQuestion(t, srv, "thread-2")
+	if got := srv.questions.list("other"); len(got) != 0 {
+		t.Fatalf("list filtered by thread = %+v", got)
+	}
+
+	if n := srv.cancelThreadQuestions("thread-2"); n != 1 {
+		t.Fatalf("cancelled = %d, want 1", n)
+	}
+	select {
+	case out := <-result:
+		if toolResultSuccess(out) || !strings.Contains(out, questionStatusCancelled) {
+			t.Fatalf("tool result = %s", out)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ask_user did not return after cancel")
+	}
+	if out := srv.askUserFrom("thread-2", "", json.RawMessage(`{"question":"  "}`)); toolResultSuccess(out) {
+		t.Fatalf("empty question should fail: %s", out)
+	}
+}
+
+func TestMatchQuestionAnswer(t *testing.T) {
+	idx := 2
+	if _, _, err := matchQuestionAnswer([]string{"a", "b"}, "", &idx); err == nil {
+		t.Fatal("out of range optionIndex should fail")
+	}
+	if answer, index, err := matchQuestionAnswer(nil, " free text ", nil); err != nil || answer != "free text" || index != nil {
+		t.Fatalf("free answer = %q %v %v", answer, index, err)
+	}
+	if _, _, err := matchQuestionAnswer(nil, "", nil); err == nil {
+		t.Fatal("empty free answer should fail")
+	}
+}
@@ -0,0 +1,456 @@
+// quiet_hours.go — 按项目配置的静默时段 / 封版窗口 (自主运行期间不启动新 turn)。
+//
+// 非 interactive 优先级的 turn (定时任务 / webhook / 编队 schedule 等自主来源) 在项目
+// 处于静默时段或封版窗口时不提交给 codex, 而是暂存 (status=held), 窗口结束后自动放行;
+// 人工发起的 interactive turn 不受影响。编队自动自愈在静默期间同样推迟。
+//
+// 配置以 UI 偏好存储 (settings.quietHours), 按项目路径索引:
+//
+//	{"/path/to/project": {
+//	  "timezone": "Asia/Shanghai",
+//	  "windows": [{"days": ["mon","tue","wed","thu","fri"], "start": "22:00", "end": "08:00"}],
+//	  "blackouts": [{"start": "2026-03-01T00:00:00Z", "end": "2026-03-03T00:00:00Z", "reason": "release freeze"}]
+//	}}
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+	"github.com/multi-agent/go-agent-v2/pkg/util"
+)
+
+const (
+	prefKeyQuietHours       = "settings.quietHours"
+	quietHoursCheckInterval = 30 * time.Second
+	maxQuietHoursHeld       = 256
+)
+
+var quietHoursWeekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// quietWindow 每周重复的静默时段 (start > end 表示跨午夜, days 按开始日计算, 为空表示每天)。
+type quietWindow struct {
+	Days  []string `json:"days,omitempty"`
+	Start string   `json:"start"` // HH:MM
+	End   string   `json:"end"`   // HH:MM
+}
+
+// blackoutWindow 一次性封版窗口。
+type blackoutWindow struct {
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// quietHoursPolicy 单个项目的静默策略。
+type quietHoursPolicy struct {
+	Timezone  string           `json:"timezone,omitempty"` // IANA 时区, 空 = 服务器本地时区
+	Windows   []quietWindow    `json:"windows,omitempty"`
+	Blackouts []blackoutWindow `json:"blackouts,omitempty"`
+}
+
+// quietHoursState 命中的静默窗口。
+type quietHoursState struct {
+	Project string    `json:"project"`
+	Reason  string    `json:"reason"`
+	Until   time.Time `json:"until"`
+}
+
+func parseQuietClock(value string) (int, error) {
+	var hour, minute int
+	if _, err := fmt.Sscanf(strings.TrimSpace(value), "%d:%d", &hour, &minute); err != nil || hour < 0 || hour > 23 || minute < 0 || minute > 59 {
+		return 0, fmt.Errorf("invalid time %q (want HH:MM)", value)
+	}
+	return hour*60 + minute, nil
+}
+
+func (p quietHoursPolicy) location() *time.Location {
+	if tz := strings.TrimSpace(p.Timezone); tz != "" {
+		if loc, err := time.LoadLocation(tz); err == nil {
+			return loc
+		}
+	}
+	return time.Local
+}
+
+func (p quietHoursPolicy) validate() error {
+	if tz := strings.TrimSpace(p.Timezone); tz != "" {
+		if _, err := time.LoadLocation(tz); err != nil {
+			return fmt.Errorf("unknown timezone %q", tz)
+		}
+	}
+	for _, window := range p.Windows {
+		start, err := parseQuietClock(window.Start)
+		if err != nil {
+			return err
+		}
+		end, err := parseQuietClock(window.End)
+		if err != nil {
+			return err
+		}
+		if start == end {
+			return fmt.Errorf("window %s-%s is empty", window.Start, window.End)
+		}
+		for _, day := range window.Days {
+			if _, ok := quietHoursWeekdays[strings.ToLower(strings.TrimSpace(day))]; !ok {
+				return fmt.Errorf("unknown day %q (want mon..sun)", day)
+			}
+		}
+	}
+	for _, blackout := range p.Blackouts {
+		if blackout.Start.IsZero() || !blackout.End.After(blackout.Start) {
+			return fmt.Errorf("blackout %q must have start < end", blackout.Reason)
+		}
+	}
+	return nil
+}
+
+func (w quietWindow) onDay(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, value := range w.Days {
+		if quietHoursWeekdays[strings.ToLower(strings.TrimSpace(value))] == day {
+			return true
+		}
+	}
+	return false
+}
+
+// active 判断 now 是否处于静默时段或封版窗口, 返回原因与 (当前窗口的) 结束时间。
+func (p quietHoursPolicy) active(now time.Time) (string, time.Time, bool) {
+	for _, blackout := range p.Blackouts {
+		if !now.Before(blackout.Start) && now.Before(blackout.End) {
+			reason := "blackout"
+			if blackout.Reason != "" {
+				reason += ": " + blackout.Reason
+			}
+			return reason, blackout.End, true
+		}
+	}
+	local := now.In(p.location())
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, local.Location())
+	minute := local.Hour()*60 + local.Minute()
+	for _, window := range p.Windows {
+		start, err := parseQuietClock(window.Start)
+		if err != nil {
+			continue
+		}
+		end, err := parseQuietClock(window.End)
+		if err != nil || start == end {
+			continue
+		}
+		reason := fmt.Sprintf("quiet hours %s-%s", window.Start, window.End)
+		endAt := func(day time.Time) time.Time { return day.Add(time.Duration(end) * time.Minute) }
+		if start < end {
+			if window.onDay(local.Weekday()) && minute >= start && minute < end {
+				return reason, endAt(midnight), true
+			}
+			continue
+		}
+		// 跨午夜: 今天开始的窗口延续到明天, 或昨天开始的窗口延续到今天。
+		if window.onDay(local.Weekday()) && minute >= start {
+			return reason, endAt(midnight.AddDate(0, 0, 1)), true
+		}
+		if window.onDay(local.AddDate(0, 0, -1).Weekday()) && minute < end {
+			return reason, endAt(midnight), true
+		}
+	}
+	return "", time.Time{}, false
+}
+
+// isAutonomousTurnPriority 非 interactive 优先级视为自主来源。
+func isAutonomousTurnPriority(priority string) bool {
+	value, err := normalizeTurnPriority(priority)
+	return err == nil && value != turnPriorityInteractive
+}
+
+// ========================================
+// 暂存队列
+// ========================================
+
+// heldTurn 因静默窗口暂存的 turn。
+type heldTurn struct {
+	ID       string    `json:"id"`
+	ThreadID string    `json:"threadId"`
+	Project  string    `json:"project,omitempty"`
+	Priority string    `json:"priority"`
+	Reason   string    `json:"reason"`
+	Until    time.Time `json:"until"`
+	HeldAt   time.Time `json:"heldAt"`
+	turn     preparedTurn
+}
+
+// quietHoursGate 暂存队列 (同一线程相同输入的重复暂存合并为一条)。
+type quietHoursGate struct {
+	mu   sync.Mutex
+	held []*heldTurn
+	seq  uint64
+}
+
+// hold 暂存 turn; 返回暂存项与是否被合并到已有项 (合并时调用方应释放自身的去重预留)。
+func (g *quietHoursGate) hold(project, priority string, state quietHoursState, turn preparedTurn, now time.Time) (*heldTurn, bool, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, item := range g.held {
+		if item.ThreadID == turn.ThreadID && item.turn.Prompt == turn.Prompt {
+			item.Until = state.Until
+			copied := *item
+			return &copied, true, nil
+		}
+	}
+	if len(g.held) >= maxQuietHoursHeld {
+		return nil, false, fmt.Errorf("quiet hours hold queue full (%d)", maxQuietHoursHeld)
+	}
+	g.seq++
+	item := &heldTurn{
+		ID:       fmt.Sprintf("held-%d", g.seq),
+		ThreadID: turn.ThreadID,
+		Project:  project,
+		Priority: priority,
+		Reason:   state.Reason,
+		Until:    state.Until,
+		HeldAt:   now,
+		turn:     turn,
+	}
+	g.held = append(g.held, item)
+	copied := *item
+	return &copied, false, nil
+}
+
+// releaseReady 取出所属项目已不在静默窗口内的暂存项。
+func (g *quietHoursGate) releaseReady(stillQuiet func(project string) bool) []*heldTurn {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var ready []*heldTurn
+	kept := g.held[:0]
+	for _, item := range g.held {
+		if stillQuiet(item.Project) {
+			kept = append(kept, item)
+			continue
+		}
+		ready = append(ready, item)
+	}
+	g.held = kept
+	return ready
+}
+
+func (g *quietHoursGate) cancel(id string) (*heldTurn, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for idx, item := range g.held {
+		if item.ID == id {
+			g.held = append(g.held[:idx], g.held[idx+1:]...)
+			return item, true
+		}
+	}
+	return nil, false
+}
+
+func (g *quietHoursGate) snapshot() []heldTurn {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	out := make([]heldTurn, 0, len(g.held))
+	for _, item := range g.held {
+		out = append(out, *item)
+	}
+	return out
+}
+
+func (g *quietHoursGate) pending() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.held)
+}
+
+// ========================================
+// 策略解析
+// ========================================
+
+func decodeQuietHoursPolicies(value any) map[string]quietHoursPolicy {
+	out := map[string]quietHoursPolicy{}
+	if value == nil {
+		return out
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return out
+	}
+	var decoded map[string]quietHoursPolicy
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return out
+	}
+	for project, policy := range decoded {
+		key := normalizeProjectPath(project)
+		if key == "" {
+			continue
+		}
+		out[key] = policy
+	}
+	return out
+}
+
+func (s *Server) loadQuietHoursPolicies(ctx context.Context) map[string]quietHoursPolicy {
+	if s.prefManager == nil {
+		return map[string]quietHoursPolicy{}
+	}
+	value, err := s.prefManager.Get(ctx, prefKeyQuietHours)
+	if err != nil {
+		logger.Warn("quiet hours: load preference failed", logger.FieldError, err)
+		return map[string]quietHoursPolicy{}
+	}
+	return decodeQuietHoursPolicies(value)
+}
+
+// resolveQuietHours 按项目路径 (最长前缀匹配) 判断当前是否处于静默窗口。
+func resolveQuietHours(policies map[string]quietHoursPolicy, project string, now time.Time) (quietHoursState, bool) {
+	target := normalizeProjectPath(project)
+	if target == "" || len(policies) == 0 {
+		return quietHoursState{}, false
+	}
+	bestKey := ""
+	for key := range policies {
+		if target != key && !strings.HasPrefix(target, key+"/") && !strings.HasPrefix(target, key+"\\") {
+			continue
+		}
+		if len(key) > len(bestKey) {
+			bestKey = key
+		}
+	}
+	if bestKey == "" {
+		return quietHoursState{}, false
+	}
+	reason, until, active := policies[bestKey].active(now)
+	if !active {
+		return quietHoursState{}, false
+	}
+	return quietHoursState{Project: bestKey, Reason: reason, Until: until}, true
+}
+
+// ========================================
+// Server 集成
+// ========================================
+
+// startQuietHoursLoop 周期检查暂存 turn, 窗口结束后放行。
+func (s *Server) startQuietHoursLoop(ctx context.Context) {
+	util.SafeGo(func() {
+		ticker := time.NewTicker(quietHoursCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			s.releaseHeldTurns(ctx, time.Now())
+		}
+	})
+}
+
+// releaseHeldTurns 放行已离开静默窗口的暂存 turn (经调度器时可能继续排队)。
+func (s *Server) releaseHeldTurns(ctx context.Context, now time.Time) int {
+	if s.quietHours.pending() == 0 {
+		return 0
+	}
+	if _, locked := s.emergency.current(); locked {
+		return 0
+	}
+	policies := s.loadQuietHoursPolicies(ctx)
+	ready := s.quietHours.releaseReady(func(project string) bool {
+		_, quiet := resolveQuietHours(policies, project, now)
+		return quiet
+	})
+	for _, item := range ready {
+		logger.Info("quiet hours: releasing held turn",
+			logger.FieldThreadID, item.ThreadID,
+			logger.FieldPath, item.Project,
+			"held_id", item.ID,
+			"held_ms", now.Sub(item.HeldAt).Milliseconds(),
+		)
+		s.Notify("quietHours/released", map[string]any{"id": item.ID, "threadId": item.ThreadID, "project": item.Project})
+		if s.turnScheduler != nil {
+			if queued, position, admitted := s.turnScheduler.admit(item.Project, item.Priority, item.turn, now); !admitted {
+				logger.Info("quiet hours: released turn queued by scheduler",
+					logger.FieldThreadID, item.ThreadID, "queue_id", queued.ID, "position", position)
+				continue
+			}
+		}
+		util.SafeGo(func() { s.dispatchQueuedTurn(item.ID, item.turn) })
+	}
+	return len(ready)
+}
+
+// quietHoursStatus quietHours/status: 当前处于静默窗口的项目与暂存 turn。
+func (s *Server) quietHoursStatus(ctx context.Context, _ json.RawMessage) (any, error) {
+	now := time.Now()
+	policies := s.loadQuietHoursPolicies(ctx)
+	active := []quietHoursState{}
+	for project := range policies {
+		if state, quiet := resolveQuietHours(policies, project, now); quiet && state.Project == project {
+			active = append(active, state)
+		}
+	}
+	return map[string]any{
+		"active": active,
+		"held":   s.quietHours.snapshot(),
+	}, nil
+}
+
+// ========================================
+// config/quietHours/read, config/quietHours/write
+// ========================================
+
+type configQuietHoursWriteParams struct {
+	Project   string           `json:"project"`
+	Timezone  string           `json:"timezone,omitempty"`
+	Windows   []quietWindow    `json:"windows,omitempty"`
+	Blackouts []blackoutWindow `json:"blackouts,omitempty"`
+}
+
+func (s *Server) configQuietHoursRead(ctx context.Context, _ json.RawMessage) (any, error) {
+	return map[string]any{
+		"projects": s.loadQuietHoursPolicies(ctx),
+		"prefKey":  prefKeyQuietHours,
+	}, nil
+}
+
+func (s *Server) configQuietHoursWriteTyped(ctx context.Context, p configQuietHoursWriteParams) (any, error) {
+	if s.prefManager == nil {
+		return nil, apperrors.New("Server.configQuietHoursWrite", "preference manager not initialized")
+	}
+	project := normalizeProjectPath(p.Project)
+	if project == "" {
+		return nil, apperrors.New("Server.configQuietHoursWrite", "project is required")
+	}
+	policy := quietHoursPolicy{Timezone: strings.TrimSpace(p.Timezone), Windows: p.Windows, Blackouts: p.Blackouts}
+	if err := policy.validate(); err != nil {
+		return nil, apperrors.Wrap(err, "Server.configQuietHoursWrite", "validate policy")
+	}
+
+	s.quietHoursPrefMu.Lock()
+	defer s.quietHoursPrefMu.Unlock()
+	policies := s.loadQuietHoursPolicies(ctx)
+	if len(policy.Windows) == 0 && len(policy.Blackouts) == 0 {
+		delete(policies, project)
+	} else {
+		policies[project] = policy
+	}
+	if err := s.prefManager.Set(ctx, prefKeyQuietHours, policies); err != nil {
+		return nil, err
+	}
+	logger.Info("config/quietHours/write: saved",
+		logger.FieldPath, project,
+		"windows", len(policy.Windows),
+		"blackouts", len(policy.Blackouts),
+	)
+	return map[string]any{"ok": true, "projects": policies}, nil
+}
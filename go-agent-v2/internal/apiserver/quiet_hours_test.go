@@ -0,0 +1,103 @@
+package apiserver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/uistate"
+)
+
+func TestQuietHoursPolicyWindows(t *testing.T) {
+	policy := quietHoursPolicy{
+		Timezone: "UTC",
+		Windows:  []quietWindow{{Days: []string{"fri"}, Start: "22:00", End: "07:00"}},
+		Blackouts: []blackoutWindow{{
+			Start:  time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC),
+			End:    time.Date(2026, 3, 12, 0, 0, 0, 0, time.UTC),
+			Reason: "release freeze",
+		}},
+	}
+	if err := policy.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	friday := time.Date(2026, 3, 6, 23, 0, 0, 0, time.UTC)
+	if _, until, ok := policy.active(friday); !ok || !until.Equal(time.Date(2026, 3, 7, 7, 0, 0, 0, time.UTC)) {
+		t.Fatalf("friday night should be quiet until saturday 07:00, got %v %v", until, ok)
+	}
+	// 跨午夜窗口按开始日计算: 周六凌晨属于周五的窗口, 周六晚上不静默。
+	if _, _, ok := policy.active(time.Date(2026, 3, 7, 6, 59, 0, 0, time.UTC)); !ok {
+		t.Fatal("saturday early morning should still be quiet")
+	}
+	if _, _, ok := policy.active(time.Date(2026, 3, 7, 23, 0, 0, 0, time.UTC)); ok {
+		t.Fatal("saturday night is not configured as quiet")
+	}
+	if reason, _, ok := policy.active(time.Date(2026, 3, 11, 12, 0, 0, 0, time.UTC)); !ok || reason != "blackout: release freeze" {
+		t.Fatalf("blackout = (%q, %v)", reason, ok)
+	}
+
+	for _, bad := range []quietHoursPolicy{
+		{Windows: []quietWindow{{Start: "25:00", End: "07:00"}}},
+		{Windows: []quietWindow{{Start: "07:00", End: "07:00"}}},
+		{Windows: []quietWindow{{Days: []string{"someday"}, Start: "01:00", End: "02:00"}}},
+		{Timezone: "Mars/Olympus", Windows: []quietWindow{{Start: "01:00", End: "02:00"}}},
+		{Blackouts: []blackoutWindow{{Start: friday, End: friday}}},
+	} {
+		if err := bad.validate(); err == nil {
+			t.Errorf("validate(%+v): expected error", bad)
+		}
+	}
+}
+
+func TestQuietHoursHoldsAutonomousTurnsUntilWindowEnds(t *testing.T) {
+	srv := &Server{prefManager: uistate.NewPreferenceManager(nil)}
+	ctx := context.Background()
+	if _, err := srv.configQuietHoursWriteTyped(ctx, configQuietHoursWriteParams{
+		Project: "/repo",
+		Windows: []quietWindow{{Start: "00:00", End: "23:59"}},
+	}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	policies := srv.loadQuietHoursPolicies(ctx)
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.Local)
+	state, quiet := resolveQuietHours(policies, "/repo/service", now)
+	if !quiet || state.Project != "/repo" {
+		t.Fatalf("resolve = (%+v, %v), want /repo quiet", state, quiet)
+	}
+	if _, quiet := resolveQuietHours(policies, "/repository", now); quiet {
+		t.Fatal("sibling path with shared prefix should not match")
+	}
+	if isAutonomousTurnPriority("") || !isAutonomousTurnPriority(turnPriorityBackground) {
+		t.Fatal("only non-interactive priorities are autonomous")
+	}
+
+	first, merged, err := srv.quietHours.hold("/repo", turnPriorityBackground, state, preparedTurn{ThreadID: "t1", Prompt: "nightly"}, now)
+	if err != nil || merged {
+		t.Fatalf("hold = (%+v, %v, %v)", first, merged, err)
+	}
+	if again, merged, _ := srv.quietHours.hold("/repo", turnPriorityBackground, state, preparedTurn{ThreadID: "t1", Prompt: "nightly"}, now); !merged || again.ID != first.ID {
+		t.Fatal("repeated scheduled input on same thread should merge")
+	}
+	other, _, _ := srv.quietHours.hold("/repo", turnPriorityNormal, state, preparedTurn{ThreadID: "t2", Prompt: "webhook"}, now)
+
+	if released := srv.releaseHeldTurns(ctx, now); released != 0 {
+		t.Fatalf("released %d turns inside window", released)
+	}
+	if resp, err := srv.schedulerCancelTyped(ctx, schedulerCancelParams{ID: other.ID}); err != nil || resp.(map[string]any)["cancelled"] != true {
+		t.Fatalf("cancel held = (%v, %v)", resp, err)
+	}
+	if held := srv.quietHours.snapshot(); len(held) != 1 || held[0].ThreadID != "t1" {
+		t.Fatalf("held = %+v", held)
+	}
+
+	if _, err := srv.configQuietHoursWriteTyped(ctx, configQuietHoursWriteParams{Project: "/repo"}); err != nil {
+		t.Fatalf("clear: %v", err)
+	}
+	ready := srv.quietHours.releaseReady(func(project string) bool {
+		_, quiet := resolveQuietHours(srv.loadQuietHoursPolicies(ctx), project, now)
+		return quiet
+	})
+	if len(ready) != 1 || ready[0].ID != first.ID || srv.quietHours.pending() != 0 {
+		t.Fatalf("ready = %+v", ready)
+	}
+}
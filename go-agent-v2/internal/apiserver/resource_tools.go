@@ -1,4 +1,4 @@
-// resource_tools.go — 资源类动态工具 (task DAG, 命令卡, 提示词模板, 共享文件)。
+// resource_tools.go — 资源类动态工具 (task DAG, 命令卡, 提示词模板, 共享文件, 产物)。
 //
 // 通过 Dynamic Tool 注入机制暴露给 codex agent,
 // 使 agent 能操作编排基础数据。
@@ -149,6 +149,34 @@ func (s *Server) buildResourceTools() []codex.DynamicTool {
 			},
 		},
 
+		// ── 产物 (内容寻址, 可在其他线程以 artifact 输入引用) ──
+		{
+			Name:        "artifact_put",
+			Description: "Publish an artifact (build output, report, ...) so other agents can reference it. Returns the content-addressed id (sha256:...).",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"name":     map[string]any{"type": "string", "description": "Artifact name, e.g. 'coverage.txt'"},
+					"content":  map[string]any{"type": "string", "description": "Artifact content"},
+					"encoding": map[string]any{"type": "string", "enum": []string{"utf8", "base64"}, "description": "Content encoding (default utf8)"},
+					"mimeType": map[string]any{"type": "string", "description": "Optional MIME type"},
+				},
+				"required": []string{"name", "content"},
+			},
+		},
+		{
+			Name:        "artifact_get",
+			Description: "Fetch an artifact by id (sha256:...).",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"id":       map[string]any{"type": "string", "description": "Artifact id"},
+					"metaOnly": map[string]any{"type": "boolean", "description": "Only return metadata"},
+				},
+				"required": []string{"id"},
+			},
+		},
+
 		// ── Workspace Run (双通道: 虚拟目录 + PG 状态) ──
 		{
 			Name:        "workspace_create_run",
@@ -0,0 +1,559 @@
+// review_pipeline.go — 代码审查流水线: diff 范围审查 → 结构化发现 → 持久化 / 时间线。
+//
+// review/start 计算线程工作目录的 git diff (base / staged / paths), 以带 outputSchema 的 turn 提交审查;
+// turn 结束后从 agent 最终回复解析发现 (file / line / severity / title / suggestion),
+// 写入 review_findings (无数据库时保存在进程内), 追加到线程时间线 (kind=review) 并推送 review/completed。
+// review/findings/list 查询, review/findings/accept / dismiss 更新处理状态 (推送 review/findings/updated)。
+package apiserver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/store"
+	"github.com/multi-agent/go-agent-v2/internal/uistate"
+	"github.com/multi-agent/go-agent-v2/internal/vcs"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+	"github.com/multi-agent/go-agent-v2/pkg/util"
+)
+
+const (
+	maxReviewDiffRunes      = 150000
+	maxReviewMemoryFindings = 2000
+	defaultReviewListLimit  = 200
+
+	reviewSeverityCritical = "critical"
+	reviewSeverityMajor    = "major"
+	reviewSeverityMinor    = "minor"
+	reviewSeverityInfo     = "info"
+)
+
+// reviewOutputSchema 审查 turn 的结构化输出约束。
+var reviewOutputSchema = json.RawMessage(`{
+  "type": "object",
+  "properties": {
+    "summary": {"type": "string"},
+    "findings": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "properties": {
+          "file": {"type": "string"},
+          "line": {"type": "integer"},
+          "severity": {"type": "string", "enum": ["critical", "major", "minor", "info"]},
+          "title": {"type": "string"},
+          "suggestion": {"type": "string"}
+        },
+        "required": ["file", "line", "severity", "title", "suggestion"],
+        "additionalProperties": false
+      }
+    }
+  },
+  "required": ["summary", "findings"],
+  "additionalProperties": false
+}`)
+
+var reviewJSONFence = regexp.MustCompile("(?s)```(?:json)?\\s*\\n(.*?)```")
+
+// activeReview 进行中的审查 (每线程至多一个)。
+type activeReview struct {
+	ID        string
+	ThreadID  string
+	TurnID    string // 空 = turn 排队/暂存中, 匹配线程下一次完成的 turn
+	Scope     string
+	StartedAt time.Time
+}
+
+// reviewPipeline 审查状态 (零值可用)。
+type reviewPipeline struct {
+	mu     sync.Mutex
+	active map[string]*activeReview
+	memory []store.ReviewFinding // 无数据库时的发现 (按写入顺序)
+	nextID int64
+}
+
+func (rp *reviewPipeline) begin(review *activeReview) bool {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	if rp.active == nil {
+		rp.active = make(map[string]*activeReview)
+	}
+	if _, busy := rp.active[review.ThreadID]; busy {
+		return false
+	}
+	rp.active[review.ThreadID] = review
+	return true
+}
+
+func (rp *reviewPipeline) setTurn(threadID, turnID string) {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	if review := rp.active[threadID]; review != nil {
+		review.TurnID = turnID
+	}
+}
+
+func (rp *reviewPipeline) abort(threadID string) {
+	rp.mu.Lock()
+	delete(rp.active, threadID)
+	rp.mu.Unlock()
+}
+
+// take 取出与完成的 turn 匹配的审查。
+func (rp *reviewPipeline) take(threadID, turnID string) *activeReview {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	review := rp.active[threadID]
+	if review == nil {
+		return nil
+	}
+	if review.TurnID != "" && turnID != "" && !strings.EqualFold(review.TurnID, turnID) {
+		return nil
+	}
+	delete(rp.active, threadID)
+	return review
+}
+
+func (rp *reviewPipeline) storeMemory(findings []store.ReviewFinding) []store.ReviewFinding {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	now := time.Now()
+	for i := range findings {
+		rp.nextID++
+		findings[i].ID = rp.nextID
+		findings[i].CreatedAt = now
+		findings[i].UpdatedAt = now
+	}
+	rp.memory = append(rp.memory, findings...)
+	if over := len(rp.memory) - maxReviewMemoryFindings; over > 0 {
+		rp.memory = append([]store.ReviewFinding(nil), rp.memory[over:]...)
+	}
+	return findings
+}
+
+func (rp *reviewPipeline) listMemory(q store.ReviewFindingQuery) []store.ReviewFinding {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	out := make([]store.ReviewFinding, 0)
+	for i := len(rp.memory) - 1; i >= 0 && len(out) < q.Limit; i-- {
+		f := rp.memory[i]
+		if (q.ThreadID != "" && f.ThreadID != q.ThreadID) ||
+			(q.ReviewID != "" && f.ReviewID != q.ReviewID) ||
+			(q.Status != "" && f.Status != q.Status) {
+			continue
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+func (rp *reviewPipeline) setMemoryStatus(id int64, status, note string) *store.ReviewFinding {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	for i := range rp.memory {
+		if rp.memory[i].ID == id {
+			rp.memory[i].Status = status
+			rp.memory[i].Note = note
+			rp.memory[i].UpdatedAt = time.Now()
+			f := rp.memory[i]
+			return &f
+		}
+	}
+	return nil
+}
+
+// ========================================
+// review/start
+// ========================================
+
+// reviewStartParams review/start 请求参数。
+type reviewStartParams struct {
+	ThreadID     string   `json:"threadId"`
+	Delivery     string   `json:"delivery,omitempty"`     // 兼容旧参数: 自定义审查指令
+	Instructions string   `json:"instructions,omitempty"` // 自定义审查指令
+	Base         string   `json:"base,omitempty"`         // 比较基准 (分支 / 提交, 默认 HEAD)
+	Staged       bool     `json:"staged,omitempty"`       // 只审查暂存区
+	Paths        []string `json:"paths,omitempty"`        // 限定路径 (相对工作目录)
+}
+
+func (p reviewStartParams) scope() string {
+	base := strings.TrimSpace(p.Base)
+	if base == "" {
+		base = "HEAD"
+	}
+	parts := []string{"base=" + base}
+	if p.Staged {
+		parts = append(parts, "staged")
+	}
+	if len(p.Paths) > 0 {
+		parts = append(parts, "paths="+strings.Join(p.Paths, ","))
+	}
+	return strings.Join(parts, " ")
+}
+
+func (s *Server) reviewStartTyped(ctx context.Context, p reviewStartParams) (any, error) {
+	const op = "Server.reviewStart"
+	threadID := strings.TrimSpace(p.ThreadID)
+	if threadID == "" {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "threadId is required")
+	}
+	base := strings.TrimSpace(p.Base)
+	if strings.HasPrefix(base, "-") {
+		return nil, apperrors.NewCodef(op, errcode.InvalidInput, "invalid base %q", base)
+	}
+	cwd := s.getAgentWorkDir(threadID)
+	if cwd == "" {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "thread has no working directory")
+	}
+	diff, err := vcs.Repo{Dir: cwd}.Diff(ctx, base, p.Staged, p.Paths)
+	if err != nil {
+		return nil, apperrors.Wrap(err, op, "compute diff")
+	}
+	scope := p.scope()
+	if strings.TrimSpace(diff) == "" {
+		return map[string]any{"status": "no_changes", "scope": scope}, nil
+	}
+	truncated := len([]rune(diff)) > maxReviewDiffRunes
+	if truncated {
+		diff = truncateRunes(diff, maxReviewDiffRunes)
+	}
+
+	review := &activeReview{
+		ID:        "review-" + rand.Text(),
+		ThreadID:  threadID,
+		Scope:     scope,
+		StartedAt: time.Now(),
+	}
+	if !s.reviews.begin(review) {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "a review is already running on this thread")
+	}
+	instructions := strings.TrimSpace(p.Instructions)
+	if instructions == "" {
+		instructions = strings.TrimSpace(p.Delivery)
+	}
+	resp, err := s.turnStartTyped(ctx, turnStartParams{
+		ThreadID:             threadID,
+		Input:                []UserInput{{Type: "text", Text: buildReviewPrompt(scope, instructions, diff, truncated)}},
+		OutputSchema:         reviewOutputSchema,
+		BypassDedup:          true,
+		skipOutputValidation: true,
+	})
+	if err != nil {
+		s.reviews.abort(threadID)
+		return nil, err
+	}
+	turn := turnInfo{}
+	if started, ok := resp.(turnStartResponse); ok {
+		turn = started.Turn
+		if turn.Status == "inProgress" {
+			s.reviews.setTurn(threadID, turn.ID)
+		}
+	}
+	logger.Info("review: started",
+		logger.FieldThreadID, threadID, logger.FieldTurnID, turn.ID,
+		"review_id", review.ID, "scope", scope, "diff_len", len(diff), "truncated", truncated)
+	return map[string]any{
+		"reviewId":  review.ID,
+		"status":    "running",
+		"scope":     scope,
+		"turn":      turn,
+		"truncated": truncated,
+	}, nil
+}
+
+func buildReviewPrompt(scope, instructions, diff string, truncated bool) string {
+	var b strings.Builder
+	b.WriteString("Review the following code changes (" + scope + ").\n")
+	b.WriteString("Report concrete problems only: bugs, security issues, missing error handling, races, and clear maintainability issues. ")
+	b.WriteString("For each finding give the file path as shown in the diff, the line number in the new file (0 if not line-specific), ")
+	b.WriteString("a severity (critical / major / minor / info), a one-line title and an actionable suggestion. ")
+	b.WriteString("Do not modify any files. Reply with JSON only: {\"summary\": string, \"findings\": [{\"file\", \"line\", \"severity\", \"title\", \"suggestion\"}]}.\n")
+	if instructions != "" {
+		b.WriteString("\nAdditional instructions:\n" + instructions + "\n")
+	}
+	if truncated {
+		b.WriteString("\nThe diff was truncated; review only what is shown.\n")
+	}
+	b.WriteString("\n```diff\n" + diff + "\n```\n")
+	return b.String()
+}
+
+// ========================================
+// 结果解析与入库
+// ========================================
+
+// reviewFindingInput agent 输出的单条发现 (message 兼容为 title)。
+type reviewFindingInput struct {
+	File       string          `json:"file"`
+	Line       json.RawMessage `json:"line"`
+	Severity   string          `json:"severity"`
+	Title      string          `json:"title"`
+	Message    string          `json:"message"`
+	Suggestion string          `json:"suggestion"`
+}
+
+type reviewOutput struct {
+	Summary  string               `json:"summary"`
+	Findings []reviewFindingInput `json:"findings"`
+}
+
+// parseReviewOutput 从 agent 回复中解析审查结果: 整体 JSON → ```json 代码块 (后者优先) → 首尾花括号区间。
+func parseReviewOutput(text string) (reviewOutput, bool) {
+	trimmed := strings.TrimSpace(text)
+	candidates := []string{trimmed}
+	fences := reviewJSONFence.FindAllStringSubmatch(trimmed, -1)
+	for i := len(fences) - 1; i >= 0; i-- {
+		candidates = append(candidates, fences[i][1])
+	}
+	if start, end := strings.Index(trimmed, "{"), strings.LastIndex(trimmed, "}"); start >= 0 && end > start {
+		candidates = append(candidates, trimmed[start:end+1])
+	}
+	for _, candidate := range candidates {
+		candidate = strings.TrimSpace(candidate)
+		var out reviewOutput
+		if strings.HasPrefix(candidate, "{") && json.Unmarshal([]byte(candidate), &out) == nil && out.Findings != nil {
+			return out, true
+		}
+		var list []reviewFindingInput
+		if strings.HasPrefix(candidate, "[") && json.Unmarshal([]byte(candidate), &list) == nil {
+			return reviewOutput{Findings: list}, true
+		}
+	}
+	return reviewOutput{}, false
+}
+
+// normalizeReviewSeverity 归一严重级别 (未知级别记为 info)。
+func normalizeReviewSeverity(raw string) string {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "critical", "blocker", "security":
+		return reviewSeverityCritical
+	case "major", "high", "error", "bug":
+		return reviewSeverityMajor
+	case "minor", "medium", "low", "warning", "warn":
+		return reviewSeverityMinor
+	default:
+		return reviewSeverityInfo
+	}
+}
+
+func parseReviewLine(raw json.RawMessage) int {
+	text := strings.Trim(strings.TrimSpace(string(raw)), `"`)
+	if head, _, ok := strings.Cut(text, "-"); ok {
+		text = head
+	}
+	line, err := strconv.Atoi(strings.TrimSpace(text))
+	if err != nil || line < 0 {
+		return 0
+	}
+	return line
+}
+
+// reviewFindingsFromOutput 将解析结果转为待入库记录 (丢弃无标题条目)。
+func reviewFindingsFromOutput(review *activeReview, turnID string, out reviewOutput) []store.ReviewFinding {
+	findings := make([]store.ReviewFinding, 0, len(out.Findings))
+	for _, in := range out.Findings {
+		title := strings.TrimSpace(in.Title)
+		if title == "" {
+			title = strings.TrimSpace(in.Message)
+		}
+		if title == "" {
+			continue
+		}
+		file := strings.TrimSpace(in.File)
+		file = strings.TrimPrefix(strings.TrimPrefix(file, "a/"), "b/")
+		if file != "" {
+			file = filepath.ToSlash(filepath.Clean(file))
+		}
+		findings = append(findings, store.ReviewFinding{
+			ReviewID:   review.ID,
+			ThreadID:   review.ThreadID,
+			TurnID:     turnID,
+			Scope:      review.Scope,
+			File:       file,
+			Line:       parseReviewLine(in.Line),
+			Severity:   normalizeReviewSeverity(in.Severity),
+			Title:      truncateRunes(title, 500),
+			Suggestion: truncateRunes(strings.TrimSpace(in.Suggestion), 4000),
+			Status:     store.ReviewFindingOpen,
+		})
+	}
+	return findings
+}
+
+// finishReview turn 结束时收集匹配审查的发现 (turn tracker 完成路径调用)。
+func (s *Server) finishReview(threadID, turnID, status string) {
+	review := s.reviews.take(threadID, turnID)
+	if review == nil {
+		return
+	}
+	util.SafeGo(func() { s.collectReviewFindings(review, turnID, status) })
+}
+
+func (s *Server) collectReviewFindings(review *activeReview, turnID, status string) {
+	result := map[string]any{
+		"reviewId": review.ID,
+		"threadId": review.ThreadID,
+		"turnId":   turnID,
+		"scope":    review.Scope,
+		"status":   status,
+	}
+	if status != "completed" {
+		logger.Warn("review: turn did not complete", logger.FieldThreadID, review.ThreadID, logger.FieldTurnID, turnID, logger.FieldStatus, status)
+		s.Notify("review/completed", result)
+		return
+	}
+	text := s.lastAssistantText(review.ThreadID)
+	if text == "" {
+		text = s.lookupTrackedTurnSummary(review.ThreadID, turnID)
+	}
+	out, ok := parseReviewOutput(text)
+	if !ok {
+		logger.Warn("review: findings not parseable", logger.FieldThreadID, review.ThreadID, "review_id", review.ID)
+		result["status"] = "unparsed"
+		result["findings"] = 0
+		s.Notify("review/completed", result)
+		return
+	}
+	findings := s.saveReviewFindings(context.Background(), reviewFindingsFromOutput(review, turnID, out))
+	if s.uiRuntime != nil {
+		for _, f := range findings {
+			s.uiRuntime.AppendReviewFinding(f.ThreadID, uistate.TimelineItem{
+				Text:     f.Title,
+				Preview:  f.Suggestion,
+				File:     f.File,
+				Line:     f.Line,
+				Severity: f.Severity,
+				Status:   f.Status,
+				Ref:      strconv.FormatInt(f.ID, 10),
+			})
+		}
+	}
+	counts := map[string]int{}
+	for _, f := range findings {
+		counts[f.Severity]++
+	}
+	result["summary"] = strings.TrimSpace(out.Summary)
+	result["findings"] = len(findings)
+	result["bySeverity"] = counts
+	logger.Info("review: completed",
+		logger.FieldThreadID, review.ThreadID, "review_id", review.ID, "findings", len(findings),
+		"duration_ms", time.Since(review.StartedAt).Milliseconds())
+	s.Notify("review/completed", result)
+}
+
+// lastAssistantText 线程时间线中最后一条 assistant 消息。
+func (s *Server) lastAssistantText(threadID string) string {
+	if s.uiRuntime == nil {
+		return ""
+	}
+	items := s.uiRuntime.ThreadTimeline(threadID)
+	for i := len(items) - 1; i >= 0; i-- {
+		if items[i].Kind == "assistant" && strings.TrimSpace(items[i].Text) != "" {
+			return items[i].Text
+		}
+	}
+	return ""
+}
+
+// saveReviewFindings 写入数据库 (失败或无数据库时保存在进程内), 返回带 id 的记录。
+func (s *Server) saveReviewFindings(ctx context.Context, findings []store.ReviewFinding) []store.ReviewFinding {
+	if len(findings) == 0 {
+		return findings
+	}
+	if s.reviewFindingStore != nil {
+		saved, err := s.reviewFindingStore.InsertBatch(ctx, findings)
+		if err == nil {
+			return saved
+		}
+		logger.Warn("review: persist findings failed, keeping in memory", logger.FieldError, err)
+	}
+	return s.reviews.storeMemory(findings)
+}
+
+// ========================================
+// review/findings/*
+// ========================================
+
+type reviewFindingsListParams struct {
+	ThreadID string `json:"threadId,omitempty"`
+	ReviewID string `json:"reviewId,omitempty"`
+	Status   string `json:"status,omitempty"`
+	Limit    int    `json:"limit,omitempty"`
+}
+
+func (s *Server) reviewFindingsListTyped(ctx context.Context, p reviewFindingsListParams) (any, error) {
+	const op = "Server.reviewFindingsList"
+	status := strings.TrimSpace(p.Status)
+	switch status {
+	case "", store.ReviewFindingOpen, store.ReviewFindingAccepted, store.ReviewFindingDismissed:
+	default:
+		return nil, apperrors.NewCodef(op, errcode.InvalidInput, "unknown status %q (want open / accepted / dismissed)", status)
+	}
+	q := store.ReviewFindingQuery{
+		ThreadID: strings.TrimSpace(p.ThreadID),
+		ReviewID: strings.TrimSpace(p.ReviewID),
+		Status:   status,
+		Limit:    util.ClampInt(p.Limit, 1, 2000),
+	}
+	if p.Limit <= 0 {
+		q.Limit = defaultReviewListLimit
+	}
+	if s.reviewFindingStore != nil {
+		findings, err := s.reviewFindingStore.List(ctx, q)
+		if err != nil {
+			return nil, apperrors.Wrap(err, op, "list findings")
+		}
+		if findings == nil {
+			findings = []store.ReviewFinding{}
+		}
+		return map[string]any{"findings": findings}, nil
+	}
+	return map[string]any{"findings": s.reviews.listMemory(q)}, nil
+}
+
+type reviewFindingDecisionParams struct {
+	ID   int64  `json:"id"`
+	Note string `json:"note,omitempty"`
+}
+
+func (s *Server) reviewFindingAcceptTyped(ctx context.Context, p reviewFindingDecisionParams) (any, error) {
+	return s.setReviewFindingStatus(ctx, "Server.reviewFindingAccept", p, store.ReviewFindingAccepted)
+}
+
+func (s *Server) reviewFindingDismissTyped(ctx context.Context, p reviewFindingDecisionParams) (any, error) {
+	return s.setReviewFindingStatus(ctx, "Server.reviewFindingDismiss", p, store.ReviewFindingDismissed)
+}
+
+func (s *Server) setReviewFindingStatus(ctx context.Context, op string, p reviewFindingDecisionParams, status string) (any, error) {
+	if p.ID <= 0 {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "id is required")
+	}
+	note := truncateRunes(strings.TrimSpace(p.Note), 2000)
+	var finding *store.ReviewFinding
+	if s.reviewFindingStore != nil {
+		updated, err := s.reviewFindingStore.SetStatus(ctx, p.ID, status, note)
+		if err != nil {
+			return nil, apperrors.Wrap(err, op, "update finding")
+		}
+		finding = updated
+	} else {
+		finding = s.reviews.setMemoryStatus(p.ID, status, note)
+	}
+	if finding == nil {
+		return nil, apperrors.NewCodef(op, errcode.NotFound, "review finding %d not found", p.ID)
+	}
+	if s.uiRuntime != nil {
+		s.uiRuntime.SetReviewFindingStatus(finding.ThreadID, strconv.FormatInt(finding.ID, 10), status)
+	}
+	s.Notify("review/findings/updated", finding)
+	return map[string]any{"finding": finding}, nil
+}
@@ -0,0 +1,94 @@
+package apiserver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/multi-agent/go-agent-v2/internal/store"
+	"github.com/multi-agent/go-agent-v2/internal/uistate"
+)
+
+func TestParseReviewOutputFormats(t *testing.T) {
+	cases := map[string]string{
+		"plain":  `{"summary":"ok","findings":[{"file":"a.go","line":3,"severity":"major","title":"nil deref","suggestion":"check err"}]}`,
+		"fenced": "Here is the review:\n```json\n{\"findings\":[{\"file\":\"a.go\",\"line\":\"3\",\"severity\":\"high\",\"message\":\"nil deref\"}]}\n```\nThanks.",
+		"array":  `[{"file":"b/a.go","line":"3-5","severity":"error","title":"nil deref"}]`,
+	}
+	review := &activeReview{ID: "review-1", ThreadID: "thread-1", Scope: "base=HEAD"}
+	for name, text := range cases {
+		out, ok := parseReviewOutput(text)
+		if !ok {
+			t.Fatalf("%s: not parsed", name)
+		}
+		findings := reviewFindingsFromOutput(review, "turn-1", out)
+		if len(findings) != 1 {
+			t.Fatalf("%s: findings = %+v", name, findings)
+		}
+		f := findings[0]
+		if f.File != "a.go" || f.Line != 3 || f.Severity != reviewSeverityMajor || f.Title != "nil deref" || f.Status != store.ReviewFindingOpen {
+			t.Fatalf("%s: finding = %+v", name, f)
+		}
+	}
+	if _, ok := parseReviewOutput("looks good to me"); ok {
+		t.Fatal("free text must not parse")
+	}
+}
+
+func TestReviewFindingsCollectAndDecide(t *testing.T) {
+	srv := &Server{uiRuntime: uistate.NewRuntimeManager()}
+	var notified []string
+	srv.SetNotifyHook(func(method string, _ any) { notified = append(notified, method) })
+	srv.uiRuntime.RestoreThread("thread-1", "reviewer", []uistate.TimelineItem{{
+		Kind: "assistant",
+		Text: `{"summary":"two issues","findings":[` +
+			`{"file":"main.go","line":12,"severity":"critical","title":"SQL injection","suggestion":"use placeholders"},` +
+			`{"file":"util.go","line":0,"severity":"info","title":"typo","suggestion":""}]}`,
+	}}, "")
+
+	if !srv.reviews.begin(&activeReview{ID: "review-1", ThreadID: "thread-1", TurnID: "turn-1"}) {
+		t.Fatal("begin review")
+	}
+	if srv.reviews.begin(&activeReview{ID: "review-2", ThreadID: "thread-1"}) {
+		t.Fatal("second review on the same thread must be rejected")
+	}
+	if review := srv.reviews.take("thread-1", "turn-other"); review != nil {
+		t.Fatal("unrelated turn must not finish the review")
+	}
+	review := srv.reviews.take("thread-1", "turn-1")
+	srv.collectReviewFindings(review, "turn-1", "completed")
+
+	ctx := context.Background()
+	resp, err := srv.reviewFindingsListTyped(ctx, reviewFindingsListParams{ThreadID: "thread-1"})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	findings := resp.(map[string]any)["findings"].([]store.ReviewFinding)
+	if len(findings) != 2 || findings[1].Title != "SQL injection" || findings[1].Severity != reviewSeverityCritical {
+		t.Fatalf("findings = %+v", findings)
+	}
+
+	critical := findings[1]
+	if _, err := srv.reviewFindingAcceptTyped(ctx, reviewFindingDecisionParams{ID: critical.ID, Note: "fixing"}); err != nil {
+		t.Fatalf("accept: %v", err)
+	}
+	if _, err := srv.reviewFindingDismissTyped(ctx, reviewFindingDecisionParams{ID: 999}); err == nil {
+		t.Fatal("dismiss of unknown finding must fail")
+	}
+	resp, _ = srv.reviewFindingsListTyped(ctx, reviewFindingsListParams{ThreadID: "thread-1", Status: store.ReviewFindingAccepted})
+	if accepted := resp.(map[string]any)["findings"].([]store.ReviewFinding); len(accepted) != 1 || accepted[0].Note != "fixing" {
+		t.Fatalf("accepted = %+v", accepted)
+	}
+
+	var reviewItems []uistate.TimelineItem
+	for _, item := range srv.uiRuntime.ThreadTimeline("thread-1") {
+		if item.Kind == "review" {
+			reviewItems = append(reviewItems, item)
+		}
+	}
+	if len(reviewItems) != 2 || reviewItems[0].Status != store.ReviewFindingAccepted || reviewItems[0].Line != 12 {
+		t.Fatalf("timeline review items = %+v", reviewItems)
+	}
+	if len(notified) < 2 || notified[0] != "review/completed" || notified[len(notified)-1] != "review/findings/updated" {
+		t.Fatalf("notifications = %v", notified)
+	}
+}
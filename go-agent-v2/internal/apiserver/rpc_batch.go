@@ -0,0 +1,157 @@
+// rpc_batch.go — JSON-RPC 2.0 批量请求 (WebSocket / HTTP 数组消息 + rpc/batch 方法)。
+//
+// 批量消息是请求对象数组, 按数组顺序依次分发 (后一条可依赖前一条的副作用, 如 thread/list → ui/state/get),
+// 响应数组仅包含带 id 的请求, 顺序与请求一致; 单条失败 (含 panic) 只影响该条目。
+// 空数组 / 非法 JSON 按规范以单个错误对象响应; 全部为通知时不响应。
+//
+// Wails 桥接只能传递单个方法调用, 前端经 rpc/batch 方法 ({requests:[...]}) 复用同一套分发,
+// 省略 id 的条目按下标补齐, 保证每条都有响应。
+package apiserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+const (
+	maxBatchEntries = 64
+	rpcBatchMethod  = "rpc/batch"
+)
+
+// isBatchMessage 判断原始消息是否为 JSON 数组 (批量请求)。
+func isBatchMessage(data []byte) bool {
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	return len(trimmed) > 0 && trimmed[0] == '['
+}
+
+// dispatchBatch 解析并分发批量消息。
+//
+// 整体无效 (非法 JSON / 空数组 / 超出条目上限) 时 single 为单个错误响应;
+// 否则 responses 为各请求的响应 (可能为空: 全部是通知)。connID 为来源 WebSocket 连接 ("" = HTTP)。
+func (s *Server) dispatchBatch(ctx context.Context, connID string, data []byte) (responses []*Response, single *Response) {
+	var entries []json.RawMessage
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, newError(nil, CodeParseError, "parse error: "+err.Error())
+	}
+	if len(entries) == 0 {
+		return nil, newError(nil, CodeInvalidRequest, "invalid request: empty batch")
+	}
+	if len(entries) > maxBatchEntries {
+		return nil, newError(nil, CodeInvalidRequest, fmt.Sprintf("invalid request: batch exceeds %d entries", maxBatchEntries))
+	}
+	return s.dispatchBatchEntries(ctx, connID, entries), nil
+}
+
+// dispatchBatchEntries 按顺序分发批量条目, 逐条隔离错误。
+func (s *Server) dispatchBatchEntries(ctx context.Context, connID string, entries []json.RawMessage) []*Response {
+	start := time.Now()
+	responses := make([]*Response, 0, len(entries))
+	failed := 0
+	for _, raw := range entries {
+		var env rpcEnvelope
+		if err := json.Unmarshal(raw, &env); err != nil {
+			responses = append(responses, newError(nil, CodeInvalidRequest, "invalid request: "+err.Error()))
+			failed++
+			continue
+		}
+		// 数组中夹带的客户端响应 (对服务端请求的回复) 直接交给 pending map。
+		if s.handleClientResponse(connID, env) {
+			continue
+		}
+		resp := s.dispatchBatchEntry(ctx, env)
+		if resp == nil {
+			continue
+		}
+		if resp.Error != nil {
+			failed++
+		}
+		responses = append(responses, resp)
+	}
+	logger.Info("app-server: batch dispatched",
+		"entries", len(entries),
+		"responses", len(responses),
+		"failed", failed,
+		logger.FieldDurationMS, time.Since(start).Milliseconds(),
+	)
+	return responses
+}
+
+// dispatchBatchEntry 分发单个条目; handler panic 仅转为该条目的错误响应。
+func (s *Server) dispatchBatchEntry(ctx context.Context, env rpcEnvelope) (resp *Response) {
+	id := rawIDtoAny(env.ID)
+	if env.Method == rpcBatchMethod {
+		return newError(id, CodeInvalidRequest, "invalid request: nested "+rpcBatchMethod)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error("app-server: batch entry panicked",
+				logger.FieldMethod, env.Method,
+				logger.FieldID, id,
+				logger.FieldError, r,
+			)
+			if id == nil {
+				resp = nil
+				return
+			}
+			resp = newError(id, CodeInternalError, fmt.Sprintf("internal error: %v", r))
+		}
+	}()
+	return s.dispatchRequest(ctx, id, env.Method, env.Params)
+}
+
+// encodeBatchResult 编码批量响应; 无需响应时返回 ok=false。
+func encodeBatchResult(responses []*Response, single *Response) ([]byte, bool, error) {
+	if single != nil {
+		data, err := json.Marshal(single)
+		return data, true, err
+	}
+	if len(responses) == 0 {
+		return nil, false, nil
+	}
+	data, err := json.Marshal(responses)
+	return data, true, err
+}
+
+// ========================================
+// rpc/batch (Wails 桥接等进程内客户端)
+// ========================================
+
+type rpcBatchParams struct {
+	Requests []json.RawMessage `json:"requests"`
+}
+
+func (s *Server) rpcBatchTyped(ctx context.Context, p rpcBatchParams) (any, error) {
+	if len(p.Requests) == 0 {
+		return nil, apperrors.NewCode("Server.rpcBatch", errcode.InvalidInput, "requests is required")
+	}
+	if len(p.Requests) > maxBatchEntries {
+		return nil, apperrors.NewCodef("Server.rpcBatch", errcode.InvalidInput, "batch exceeds %d entries", maxBatchEntries)
+	}
+	entries := make([]json.RawMessage, 0, len(p.Requests))
+	for i, raw := range p.Requests {
+		var req map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &req); err != nil {
+			entries = append(entries, raw) // 交由分发层返回 invalid request
+			continue
+		}
+		if id, ok := req["id"]; !ok || string(id) == "null" {
+			req["id"] = json.RawMessage(fmt.Sprint(i))
+		}
+		if _, ok := req["jsonrpc"]; !ok {
+			req["jsonrpc"] = json.RawMessage(`"` + jsonrpcVersion + `"`)
+		}
+		filled, err := json.Marshal(req)
+		if err != nil {
+			return nil, apperrors.Wrap(err, "Server.rpcBatch", "encode request")
+		}
+		entries = append(entries, filled)
+	}
+	return map[string]any{"responses": s.dispatchBatchEntries(ctx, "", entries)}, nil
+}
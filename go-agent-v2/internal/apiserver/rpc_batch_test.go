@@ -0,0 +1,124 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newBatchTestServer() (*Server, *[]string) {
+	var calls []string
+	srv := &Server{methods: map[string]Handler{}}
+	srv.methods["echo"] = func(_ context.Context, params json.RawMessage) (any, error) {
+		calls = append(calls, "echo")
+		return json.RawMessage(params), nil
+	}
+	srv.methods["fail"] = func(context.Context, json.RawMessage) (any, error) {
+		calls = append(calls, "fail")
+		return nil, errors.New("boom")
+	}
+	srv.methods["panic"] = func(context.Context, json.RawMessage) (any, error) {
+		panic("handler exploded")
+	}
+	srv.methods["rpc/batch"] = typedHandler(srv.rpcBatchTyped)
+	return srv, &calls
+}
+
+func TestDispatchBatchIsolatesEntries(t *testing.T) {
+	srv, calls := newBatchTestServer()
+	responses, single := srv.dispatchBatch(context.Background(), "", []byte(`[
+		{"jsonrpc":"2.0","id":1,"method":"echo","params":{"a":1}},
+		{"jsonrpc":"2.0","id":2,"method":"fail"},
+		{"jsonrpc":"2.0","method":"echo"},
+		{"jsonrpc":"2.0","id":"p","method":"panic"},
+		42,
+		{"jsonrpc":"2.0","id":3,"method":"missing"}
+	]`))
+	if single != nil {
+		t.Fatalf("unexpected single response: %+v", single)
+	}
+	if got := strings.Join(*calls, ","); got != "echo,fail,echo" {
+		t.Fatalf("calls = %s, want sequential echo,fail,echo", got)
+	}
+	if len(responses) != 5 {
+		t.Fatalf("responses = %d, want 5 (notification omitted)", len(responses))
+	}
+	if responses[0].Error != nil || responses[0].ID != int64(1) {
+		t.Fatalf("echo response = %+v", responses[0])
+	}
+	if responses[1].Error == nil || responses[1].ID != int64(2) {
+		t.Fatalf("fail response = %+v", responses[1])
+	}
+	if responses[2].Error == nil || responses[2].Error.Code != CodeInternalError || responses[2].ID != "p" {
+		t.Fatalf("panic response = %+v", responses[2])
+	}
+	if responses[3].Error == nil || responses[3].Error.Code != CodeInvalidRequest {
+		t.Fatalf("invalid entry response = %+v", responses[3])
+	}
+	if responses[4].Error == nil || responses[4].Error.Code != CodeMethodNotFound {
+		t.Fatalf("missing method response = %+v", responses[4])
+	}
+}
+
+func TestDispatchBatchInvalid(t *testing.T) {
+	srv, _ := newBatchTestServer()
+	for _, raw := range []string{`[]`, `[{"id":1,`, `[` + strings.Repeat(`{"method":"echo"},`, maxBatchEntries) + `{"method":"echo"}]`} {
+		responses, single := srv.dispatchBatch(context.Background(), "", []byte(raw))
+		if single == nil || single.Error == nil || responses != nil {
+			t.Fatalf("dispatchBatch(%.20s) = %v, %+v; want single error", raw, responses, single)
+		}
+	}
+
+	// 全部为通知: 不响应
+	data, ok, err := encodeBatchResult(srv.dispatchBatch(context.Background(), "", []byte(`[{"jsonrpc":"2.0","method":"echo"}]`)))
+	if err != nil || ok || data != nil {
+		t.Fatalf("notification-only batch = %s, %v, %v", data, ok, err)
+	}
+}
+
+func TestHTTPRPCBatch(t *testing.T) {
+	srv, _ := newBatchTestServer()
+	req := httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(` [{"jsonrpc":"2.0","id":1,"method":"echo","params":{"x":"y"}},{"jsonrpc":"2.0","id":2,"method":"fail"}]`))
+	rec := httptest.NewRecorder()
+	srv.handleHTTPRPC(rec, req)
+
+	var responses []map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &responses); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+	if len(responses) != 2 || responses[0]["result"] == nil || responses[1]["error"] == nil {
+		t.Fatalf("responses = %+v", responses)
+	}
+}
+
+func TestRPCBatchMethodFillsIDs(t *testing.T) {
+	srv, _ := newBatchTestServer()
+	result, err := srv.InvokeMethod(context.Background(), "rpc/batch", json.RawMessage(`{"requests":[
+		{"method":"echo","params":{"n":1}},
+		{"method":"fail"},
+		{"method":"rpc/batch","params":{"requests":[]}}
+	]}`))
+	if err != nil {
+		t.Fatalf("rpc/batch: %v", err)
+	}
+	responses := result.(map[string]any)["responses"].([]*Response)
+	if len(responses) != 3 {
+		t.Fatalf("responses = %d, want 3", len(responses))
+	}
+	for i, resp := range responses {
+		if resp.ID != int64(i) {
+			t.Fatalf("responses[%d].ID = %v", i, resp.ID)
+		}
+	}
+	if responses[0].Error != nil || responses[1].Error == nil || responses[2].Error == nil {
+		t.Fatalf("responses = %+v, %+v, %+v", responses[0], responses[1], responses[2])
+	}
+
+	if _, err := srv.InvokeMethod(context.Background(), "rpc/batch", json.RawMessage(`{"requests":[]}`)); err == nil {
+		t.Fatal("empty rpc/batch should fail")
+	}
+}
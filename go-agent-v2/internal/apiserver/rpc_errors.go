@@ -0,0 +1,70 @@
+// rpc_errors.go — handler 错误 → JSON-RPC 错误响应 (error.data.code 结构化错误码)。
+//
+// 错误码取自错误链上的 AppError.Code (见 pkg/errcode 注册表); 未附加错误码时按哨兵错误 /
+// context 错误 / codex 崩溃特征推断, 其余为 INTERNAL。error.code 取注册表中的 JSON-RPC 码。
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+// rpcErrorData JSON-RPC error.data。
+type rpcErrorData struct {
+	Code      string `json:"code"`
+	Retryable bool   `json:"retryable"`
+}
+
+// resolveErrorCode 解析错误码。
+func resolveErrorCode(err error) string {
+	if code := apperrors.CodeOf(err); code != "" {
+		return code
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, apperrors.ErrTimeout):
+		return errcode.Timeout
+	case errors.Is(err, context.Canceled):
+		return errcode.Canceled
+	case errors.Is(err, apperrors.ErrNotFound), errors.Is(err, apperrors.ErrRowMissing):
+		return errcode.NotFound
+	case errors.Is(err, apperrors.ErrInvalidInput):
+		return errcode.InvalidInput
+	case isCodexProcessCrashError(err):
+		return errcode.CodexCrashed
+	}
+	return errcode.Internal
+}
+
+// newHandlerError 由 handler 错误构造带错误码的响应。
+func newHandlerError(id any, err error) *Response {
+	code := resolveErrorCode(err)
+	desc, ok := errcode.Lookup(code)
+	if !ok {
+		desc = errcode.Descriptor{Code: code, RPCCode: CodeInternalError}
+	}
+	return newErrorData(id, desc.RPCCode, err.Error(), rpcErrorData{Code: code, Retryable: desc.Retryable})
+}
+
+// errorCodeOfResponse 读取响应中的结构化错误码 (InvokeMethod 还原错误用)。
+func errorCodeOfResponse(resp *Response) string {
+	if resp == nil || resp.Error == nil {
+		return ""
+	}
+	switch data := resp.Error.Data.(type) {
+	case rpcErrorData:
+		return data.Code
+	case map[string]any:
+		code, _ := data["code"].(string)
+		return code
+	}
+	return ""
+}
+
+// errorsCodes errors/codes: 列出全部错误码。
+func (s *Server) errorsCodes(_ context.Context, _ json.RawMessage) (any, error) {
+	return map[string]any{"codes": errcode.All()}, nil
+}
@@ -0,0 +1,55 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+func TestDispatchRequest_ErrorDataCarriesCode(t *testing.T) {
+	srv := &Server{methods: map[string]Handler{
+		"thread/name/set": typedHandler(func(_ context.Context, _ struct{ ThreadID string }) (any, error) {
+			return nil, apperrors.NewCodef("Server.threadNameSet", errcode.ThreadNotFound, "thread %s not found", "t1")
+		}),
+	}}
+	resp := srv.dispatchRequest(context.Background(), 1, "thread/name/set", json.RawMessage(`{"threadId":"t1"}`))
+	if resp.Error == nil || resp.Error.Code != CodeInternalError {
+		t.Fatalf("error = %+v, want internal error", resp.Error)
+	}
+	data, ok := resp.Error.Data.(rpcErrorData)
+	if !ok || data.Code != errcode.ThreadNotFound || data.Retryable {
+		t.Fatalf("data = %#v, want THREAD_NOT_FOUND", resp.Error.Data)
+	}
+
+	resp = srv.dispatchRequest(context.Background(), 2, "thread/name/set", json.RawMessage(`[1]`))
+	if resp.Error == nil || resp.Error.Code != CodeInvalidParams || errorCodeOfResponse(resp) != errcode.InvalidParams {
+		t.Fatalf("invalid params error = %+v", resp.Error)
+	}
+
+	_, err := srv.InvokeMethod(context.Background(), "thread/name/set", json.RawMessage(`{"threadId":"t1"}`))
+	if apperrors.CodeOf(err) != errcode.ThreadNotFound || !strings.Contains(err.Error(), "error THREAD_NOT_FOUND") {
+		t.Fatalf("InvokeMethod err = %v, want coded THREAD_NOT_FOUND", err)
+	}
+}
+
+func TestResolveErrorCode_Fallbacks(t *testing.T) {
+	cases := []struct {
+		err  error
+		want string
+	}{
+		{context.DeadlineExceeded, errcode.Timeout},
+		{apperrors.Wrap(apperrors.ErrNotFound, "Store.Get", "load"), errcode.NotFound},
+		{errors.New("read: websocket: close 1006 (abnormal closure)"), errcode.CodexCrashed},
+		{errors.New("boom"), errcode.Internal},
+	}
+	for _, tc := range cases {
+		if got := resolveErrorCode(tc.err); got != tc.want {
+			t.Fatalf("resolveErrorCode(%v) = %q, want %q", tc.err, got, tc.want)
+		}
+	}
+}
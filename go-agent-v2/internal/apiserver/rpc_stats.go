@@ -0,0 +1,64 @@
+// rpc_stats.go — JSON-RPC 调用计数, 作为 debug/runtime 的 rpc 段 (/debug/stats 按两次采样差值计算速率)。
+package apiserver
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// rpcStatsTopMethods debug/runtime 返回的高频方法数。
+const rpcStatsTopMethods = 10
+
+// rpcStats 累计调用计数 (零值可用)。
+type rpcStats struct {
+	requests atomic.Int64
+	errors   atomic.Int64
+
+	mu       sync.Mutex
+	byMethod map[string]int64
+}
+
+// record 记录一次已注册方法的调用。
+func (st *rpcStats) record(method string, failed bool) {
+	st.requests.Add(1)
+	if failed {
+		st.errors.Add(1)
+	}
+	st.mu.Lock()
+	if st.byMethod == nil {
+		st.byMethod = make(map[string]int64)
+	}
+	st.byMethod[method]++
+	st.mu.Unlock()
+}
+
+// snapshot 返回累计总数与调用最多的 top 个方法。
+func (st *rpcStats) snapshot(top int) map[string]any {
+	type methodCount struct {
+		Method string `json:"method"`
+		Count  int64  `json:"count"`
+	}
+	st.mu.Lock()
+	methods := make([]methodCount, 0, len(st.byMethod))
+	for method, count := range st.byMethod {
+		methods = append(methods, methodCount{Method: method, Count: count})
+	}
+	st.mu.Unlock()
+	slices.SortFunc(methods, func(a, b methodCount) int {
+		if c := cmp.Compare(b.Count, a.Count); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Method, b.Method)
+	})
+	if len(methods) > top {
+		methods = methods[:top]
+	}
+	return map[string]any{
+		"requests":   st.requests.Load(),
+		"errors":     st.errors.Load(),
+		"topMethods": methods,
+	}
+}
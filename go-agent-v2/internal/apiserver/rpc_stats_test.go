@@ -0,0 +1,32 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestRPCStats_CountsDispatchedRequests(t *testing.T) {
+	srv := New(Deps{})
+	ctx := context.Background()
+	for range 2 {
+		if _, err := srv.InvokeMethod(ctx, "debug/runtime", json.RawMessage(`{}`)); err != nil {
+			t.Fatalf("debug/runtime: %v", err)
+		}
+	}
+	_, _ = srv.InvokeMethod(ctx, "thread/transcript/export", json.RawMessage(`{}`))
+	_, _ = srv.InvokeMethod(ctx, "no/such/method", json.RawMessage(`{}`))
+
+	res, err := srv.debugRuntime(ctx, nil)
+	if err != nil {
+		t.Fatalf("debugRuntime: %v", err)
+	}
+	rpc := res.(map[string]any)["rpc"].(map[string]any)
+	if rpc["requests"] != int64(3) || rpc["errors"] != int64(1) {
+		t.Fatalf("rpc stats = %+v", rpc)
+	}
+	data, _ := json.Marshal(rpc["topMethods"])
+	if string(data) != `[{"method":"debug/runtime","count":2},{"method":"thread/transcript/export","count":1}]` {
+		t.Fatalf("topMethods = %s", data)
+	}
+}
@@ -32,6 +32,7 @@ import (
 	"github.com/multi-agent/go-agent-v2/internal/runner"
 	"github.com/multi-agent/go-agent-v2/internal/service"
 	"github.com/multi-agent/go-agent-v2/internal/store"
+	"github.com/multi-agent/go-agent-v2/internal/toolreg"
 	"github.com/multi-agent/go-agent-v2/internal/uistate"
 	pkgerr "github.com/multi-agent/go-agent-v2/pkg/errors"
 	"github.com/multi-agent/go-agent-v2/pkg/logger"
@@ -81,6 +82,7 @@ type Server struct {
 	cmdStore          *store.CommandCardStore
 	promptStore       *store.PromptTemplateStore
 	fileStore         *store.SharedFileStore
+	artifactStore     artifactFiles // 产物存储 (基于 fileStore, 无数据库时为 nil)
 	workspaceRunStore *store.WorkspaceRunStore
 	sysLogStore       *store.SystemLogStore
 
@@ -96,10 +98,24 @@ type Server struct {
 	workspaceMgr     *service.WorkspaceManager
 	prefManager      *uistate.PreferenceManager
 	uiRuntime        *uistate.RuntimeManager
+	uiPersist        *uiRuntimePersister // UI 运行时状态跨重启持久化 (nil = 关闭, 见 ui_runtime_persist.go)
 	threadAliasMu    sync.Mutex
+	threadMetaMu     sync.Mutex
 
 	// Agent ↔ Codex Thread 1:1 共生绑定 (根基约束, 不允许绕过)。
 	bindingStore *store.AgentCodexBindingStore
+	// 线程时间线全文检索 (thread/search; nil = 使用进程内索引)
+	threadSearchStore *store.ThreadSearchStore
+	// 代码审查发现 (nil = 无数据库, 保存在进程内)
+	reviewFindingStore *store.ReviewFindingStore
+	// 人格目录与线程人格 (nil = 无数据库, 保存在进程内)
+	personalityStore *store.PersonalityStore
+	// 优雅停止交接摘要 (nil = 无数据库, 保存在进程内)
+	handoffStore *store.ThreadHandoffStore
+	// 线程分叉关系 (nil = 无数据库, 保存在进程内)
+	lineageStore *store.ThreadLineageStore
+	// data/export 按 agent 导出查询 (nil = 无数据库, 仅导出进程内数据)
+	agentExportStore *store.AgentExportStore
 
 	// 连接管理 (支持多 IDE 同时连接)
 	mu     sync.RWMutex
@@ -108,18 +124,22 @@ type Server struct {
 
 	// § 二 Server → Client 请求: 服务端发起请求, 等待客户端响应
 	pendingMu sync.Mutex
-	pending   map[int64]chan *Response // requestID → response channel
+	pending   map[int64]pendingRequest // requestID → 等待中的请求 (含目标连接)
 	nextReqID atomic.Int64
 
 	threadSeq atomic.Int64 // thread/start 唯一序号
 
 	// LSP 诊断缓存 (uri → diagnostics)
 	diagMu    sync.RWMutex
-	diagCache map[string][]lsp.Diagnostic
+	diagCache lspDiagnosticCache // 工作区根 → 文件 URI → 诊断
+
+	// 多根工作区 (主根 + workspace/root/add 追加的根)
+	workspaceRoots workspaceRootRegistry
 
 	// 动态工具调用计数 (可观测性)
 	toolCallMu    sync.Mutex
 	toolCallCount map[string]int64 // toolName → count
+	toolCache     *toolResultCache // 确定性动态工具结果缓存 (nil = 禁用)
 
 	// code_run 执行上下文管理 (agentID -> runKey -> cancel)。
 	codeRunMu      sync.Mutex
@@ -139,9 +159,140 @@ type Server struct {
 	activeTurns         map[string]*trackedTurn
 	turnWatchdogTimeout time.Duration
 	turnSummaryCache    map[string]trackedTurnSummaryCacheEntry
+	turnOutcomes        map[string]trackedTurnOutcome // threadId → 最近一次完成的 turn (turn/await)
 	turnSummaryTTL      time.Duration
 	stallThreshold      time.Duration // 无事件多久(秒)触发 stall 自动中断
 	stallHeartbeat      time.Duration // dynamic tool call / 审批等待时的保活心跳间隔
+	stallPlan           []string      // stall 处置链 (见 stuck_turn.go), 空 = escalate,interrupt
+	stallGrace          time.Duration // 处置步骤之间的等待时间
+
+	// 跨线程 turn 输入去重 (fingerprint → 原始 turn), 策略写入由 turnDedupPrefMu 串行化
+	turnDedup       turnDedupTable
+	turnDedupPrefMu sync.Mutex
+
+	// 静默时段 / 封版窗口暂存的自主 turn, 策略写入由 quietHoursPrefMu 串行化
+	quietHours       quietHoursGate
+	quietHoursPrefMu sync.Mutex
+	// 模型路由切换过模型的线程 (threadID → 最近一次 /model 设置的模型)
+	modelRoutes modelRouteTable
+
+	// turn 优先级调度 (nil = 未启用)
+	turnScheduler *turnScheduler
+
+	// 诊断门禁 (turn 变更文件新增 LSP 错误时拒绝 completed)
+	qualityGate *turnQualityGate
+	// turn/speculate 投机双模型执行 (实验性, TURN_SPECULATION_ENABLED)
+	speculationEnabled bool
+	speculations       speculationTable
+
+	// 线程工作目录文件监听 (nil = 禁用)
+	fileWatch *fileWatchHub
+
+	// agent-terminal 多窗口分组 (窗口注册 + 线程归属)
+	windowGroups windowGroupRegistry
+
+	// 角色模板: 线程已应用的模板, 自定义模板写入由 agentTemplatePrefMu 串行化
+	agentTemplates      agentTemplateBindings
+	agentTemplatePrefMu sync.Mutex
+	// 人格目录: 线程当前人格与待注入指令, 预设写入由 personalityMu 串行化
+	personalities personalityCatalog
+	personalityMu sync.Mutex
+	// thread/stop/graceful 交接摘要 (无数据库时)
+	handoffs threadHandoffState
+	// turn/start 一次性提示词 (模板/人格/交接) 的线程占用, 防止并发 turn 重复注入
+	turnOneShotMu     sync.Mutex
+	turnOneShotClaims map[string]struct{}
+	// thread/fork 分叉关系 (无数据库时)
+	lineages threadLineageState
+	// thread/context/pin 固定上下文文档 (写入串行化与文件读取缓存)
+	contextPins threadContextPins
+	// agent/capabilities 各 agent 的 codex 版本与能力快照
+	agentCaps agentCapabilityCache
+	// codex 可执行文件解析结果 (codex/binary/status)
+	codexBinary codexBinaryState
+
+	// command/exec 线程沙箱配置写入串行化
+	sandboxPrefMu sync.Mutex
+	// command/exec/stream 运行中的流式命令
+	execStreams execStreamHub
+
+	// 外部通知渠道 (Slack / 邮件 / webhook; 渠道配置存 DB, 投递带冷却)
+	notifyChannelStore *store.NotifyChannelStore
+	notifySinks        notifySinkHub
+
+	// agent 健康检查与自动重启 (interval ≤ 0 = 不启动后台检查)
+	health *agentHealthMonitor
+
+	// 后台终端交互 (terminal/attach 附着与 stdin 转发)
+	terminals terminalHub
+
+	// 变更类请求幂等键去重 (idempotencyKey → 首次执行结果)
+	idempotency idempotencyCache
+
+	// 紧急停止开关 (锁定期间冻结 turn 与文件写入)
+	emergency emergencyState
+
+	// 优雅排空 (server/drain, SIGUSR1: 拒绝新工作, 等待进行中 turn 后退出)
+	drain drainState
+
+	// config/reload 串行化 (避免并发重载交错写入 cfg)
+	configReloadMu sync.Mutex
+	// thread/env/set / tools/setEnabled 写入串行化 (线程环境变量覆盖与工具禁用列表)
+	threadEnvMu sync.Mutex
+	// system_logs 保留策略清理 (串行执行, 记录最近一次结果)
+	logRetention logRetentionState
+	// log/queries/save · delete 写入串行化 (已保存的日志查询)
+	logQueryMu sync.Mutex
+	// 磁盘配额清理 (串行执行, 记录最近一次结果)
+	storageJanitor storageJanitorState
+	// thread/search 增量索引状态 (无数据库时兼作进程内索引)
+	threadSearch threadSearchIndex
+	// 审批推送中继: 等待移动端回复的审批 (APPROVAL_RELAY_*)
+	approvalRelay approvalRelayState
+	// review/start 审查流水线 (进行中的审查, 无数据库时兼存发现)
+	reviews reviewPipeline
+	// 外部动态工具注册表 (模块 / 命令 / gRPC / WASM 插件, tools/reload 重载)
+	dynToolRegistry toolreg.Registry
+	// WASM 插件运行时 (wazero, 重载时关闭旧实例)
+	wasmPlugins wasmPluginSet
+	// 项目 .agent/tools.yaml HTTP 工具缓存 (按工作目录)
+	projectToolCache projectToolCache
+	// 会话回放模式 (cmd/replay): 录制的服务端请求不执行, 仅按通知输出
+	replayMode atomic.Bool
+	// 拖放文件 / 粘贴图片输入暂存 (首次使用时按配置创建)
+	inputStaging inputStagingState
+	// orchestrate/fanout 登记 (fanoutId → 各目标 turn)
+	fanouts fanoutHub
+	// orchestrate/plan 计划与 worker 占用 (dagKey → 运行状态)
+	plans planEngine
+	// turn/start retry 策略的进行中重试 (threadID → 尝试状态)
+	turnRetries turnRetryTable
+	// turn/start outputSchema 的校验与结果 (threadID → 进行中校验 / 最近结果)
+	turnResults turnResultTable
+	// 文件级撤销栈 (threadID → 各 turn 改动文件的快照)
+	turnUndo turnUndoTable
+	// 请求审计链 (correlationId → Trail, 线程 → 进行中 turn 所属 Trail)
+	auditTrails auditTrailRegistry
+	// data/export 一次性下载令牌 (token → 待导出 agent)
+	dataExports dataExportTable
+	// 配置写入记录 (key → 最近一次写入, config/drift 归因; 有数据库时另写审计日志)
+	configWrites configWriteLog
+	// ask_user 待回答问题 (questionId → 问题, question/answer 作答)
+	questions questionQueue
+	// JSON-RPC 调用计数 (debug/runtime 的 rpc 段)
+	rpcStats rpcStats
+	// debug/trace/start 进行中的 runtime/trace 采集
+	runtimeTrace runtimeTraceState
+
+	// 离线持久化 WAL (nil = 未启用, 写入失败直接返回错误)
+	persistWAL *persistWAL
+
+	// 声明式 agent 编队 (fleet/apply)
+	fleet *fleetState
+
+	// agent 长期记忆 (nil = 无数据库或已禁用)
+	memory *service.AgentMemoryService
+	kb     *service.KnowledgeBase // 外部知识库 (nil = 未配置连接器)
 
 	// 委托消息自动回报跟踪 (workerAgentID -> requesterAgentID -> createdAt)
 	orchestrationReportMu       sync.Mutex
@@ -189,8 +340,8 @@ func New(deps Deps) *Server {
 		methods:                     make(map[string]Handler),
 		dynTools:                    make(map[string]func(json.RawMessage) string),
 		conns:                       make(map[string]*connEntry),
-		pending:                     make(map[int64]chan *Response),
-		diagCache:                   make(map[string][]lsp.Diagnostic),
+		pending:                     make(map[int64]pendingRequest),
+		diagCache:                   lspDiagnosticCache{},
 		toolCallCount:               make(map[string]int64),
 		activeCodeRuns:              make(map[string]map[string]context.CancelFunc),
 		agentWorkDirs:               make(map[string]string),
@@ -204,12 +355,15 @@ func New(deps Deps) *Server {
 		orchestrationPendingReports: make(map[string]map[string]time.Time),
 		orchestrationReportTTL:      defaultOrchestrationReportTTL,
 		agentSkills:                 make(map[string][]string),
+		fleet:                       newFleetState(0, false),
+		health:                      newAgentHealthMonitor(agentHealthConfig{}),
 		sseClients:                  make(map[chan []byte]struct{}),
 		prefManager:                 uistate.NewPreferenceManager(nil),
 		uiRuntime:                   uistate.NewRuntimeManager(),
 		uiThrottleEntries:           make(map[string]*uiStateThrottleEntry),
 		upgrader: websocket.Upgrader{
-			CheckOrigin: checkLocalOrigin,
+			CheckOrigin:       checkLocalOrigin,
+			EnableCompression: deps.Config == nil || deps.Config.WSCompression,
 		},
 	}
 	if s.mgr != nil {
@@ -221,6 +375,7 @@ func New(deps Deps) *Server {
 		s.cmdStore = store.NewCommandCardStore(deps.DB)
 		s.promptStore = store.NewPromptTemplateStore(deps.DB)
 		s.fileStore = store.NewSharedFileStore(deps.DB)
+		s.artifactStore = s.fileStore
 		s.workspaceRunStore = store.NewWorkspaceRunStore(deps.DB)
 		s.sysLogStore = store.NewSystemLogStore(deps.DB)
 		// Dashboard stores
@@ -230,7 +385,33 @@ func New(deps Deps) *Server {
 		s.busLogStore = store.NewBusLogStore(deps.DB)
 		s.taskAckStore = store.NewTaskAckStore(deps.DB)
 		s.taskTraceStore = store.NewTaskTraceStore(deps.DB)
+		s.notifyChannelStore = store.NewNotifyChannelStore(deps.DB)
 		s.bindingStore = store.NewAgentCodexBindingStore(deps.DB)
+		s.threadSearchStore = store.NewThreadSearchStore(deps.DB)
+		s.reviewFindingStore = store.NewReviewFindingStore(deps.DB)
+		s.personalityStore = store.NewPersonalityStore(deps.DB)
+		s.handoffStore = store.NewThreadHandoffStore(deps.DB)
+		s.lineageStore = store.NewThreadLineageStore(deps.DB)
+		s.agentExportStore = store.NewAgentExportStore(deps.DB)
+		walPath := ""
+		if s.cfg != nil {
+			walPath = s.cfg.PersistWALPath
+		}
+		if path, err := resolvePersistWALPath(walPath); err != nil {
+			logger.Warn("app-server: persist wal unavailable", logger.FieldError, err)
+		} else if wal, err := openPersistWAL(path); err != nil {
+			logger.Warn("app-server: persist wal unavailable", logger.FieldError, err, logger.FieldPath, path)
+		} else {
+			s.persistWAL = wal
+			if pending := wal.pending(); pending > 0 {
+				logger.Info("app-server: persist wal has pending writes", logger.FieldPath, path, "pending", pending)
+			}
+		}
+		if s.cfg == nil || s.cfg.MemoryEnabled {
+			memoryStore := store.NewAgentMemoryStore(deps.DB)
+			s.memory = service.NewAgentMemoryService(memoryStore, newMemoryEmbedder(s.cfg))
+			s.kb = newKnowledgeBase(s.cfg, s.memory, memoryStore)
+		}
 
 		if s.cfg != nil {
 			maxFileBytes := int64(s.cfg.OrchestrationWorkspaceMaxFileBytes)
@@ -262,11 +443,16 @@ func New(deps Deps) *Server {
 		)
 		skillsDir = defaultSkillsCacheDir()
 	}
+	var uiStateStore *store.UIRuntimeStateStore
+	if deps.DB != nil {
+		uiStateStore = store.NewUIRuntimeStateStore(deps.DB)
+	}
+	s.initUIRuntimePersist(uiStateStore)
 	s.skillsDir = skillsDir
 	s.skillSvc = service.NewSkillService(skillsDir)
 	s.registerMethods()
 
-	// 从 Config 加载 stall 参数
+	// 从 Config 加载 stall / 工具结果缓存 / turn 调度 / 诊断门禁 / 文件监听 / 编队对账参数
 	if deps.Config != nil {
 		if deps.Config.StallThresholdSec > 0 {
 			s.stallThreshold = time.Duration(deps.Config.StallThresholdSec) * time.Second
@@ -274,6 +460,46 @@ func New(deps Deps) *Server {
 		if deps.Config.StallHeartbeatSec > 0 {
 			s.stallHeartbeat = time.Duration(deps.Config.StallHeartbeatSec) * time.Second
 		}
+		s.applyStallRemediationConfig()
+		if err := uistate.UseEventSchema(deps.Config.EventSchemaVersion, uistate.EventParseMode(deps.Config.EventSchemaMode)); err != nil {
+			logger.Warn("app-server: invalid event schema config, keeping current schema", logger.FieldError, err)
+		}
+		s.toolCache = newToolResultCache(
+			time.Duration(deps.Config.ToolResultCacheTTLSec)*time.Second,
+			deps.Config.ToolResultCacheMaxEntries,
+		)
+		s.applyStoreCacheTTL()
+		modelLimits, err := parseTurnModelLimits(deps.Config.TurnSchedulerModelLimits)
+		if err != nil {
+			logger.Warn("app-server: invalid TURN_SCHEDULER_MODEL_LIMITS, per-model limits disabled", logger.FieldError, err)
+		}
+		s.turnScheduler = newTurnScheduler(deps.Config.TurnSchedulerMaxConcurrent, deps.Config.TurnSchedulerProjectQuota, modelLimits)
+		s.qualityGate = newTurnQualityGate(
+			deps.Config.TurnQualityGateEnabled,
+			deps.Config.TurnQualityGateMaxRetries,
+			time.Duration(deps.Config.TurnQualityGateSettleMS)*time.Millisecond,
+		)
+		s.speculationEnabled = deps.Config.TurnSpeculationEnabled
+		s.fleet = newFleetState(time.Duration(deps.Config.FleetReconcileIntervalSec)*time.Second, deps.Config.FleetAutoHeal)
+		s.notifySinks.approvalAfter = time.Duration(deps.Config.NotifyApprovalPendingSec) * time.Second
+		s.health = newAgentHealthMonitor(agentHealthConfig{
+			Interval:      time.Duration(deps.Config.AgentHealthIntervalSec) * time.Second,
+			PingTimeout:   time.Duration(deps.Config.AgentHealthPingTimeoutSec) * time.Second,
+			FailThreshold: deps.Config.AgentHealthFailThreshold,
+			StaleAfter:    time.Duration(deps.Config.AgentHealthStaleEventSec) * time.Second,
+			MaxRestarts:   deps.Config.AgentRestartMax,
+			Backoff:       time.Duration(deps.Config.AgentRestartBackoffSec) * time.Second,
+			RestartWindow: time.Duration(deps.Config.AgentRestartWindowSec) * time.Second,
+		})
+		if deps.Config.WorkspaceWatchEnabled {
+			s.fileWatch = newFileWatchHub(s.notifyFileChanged,
+				time.Duration(deps.Config.WorkspaceWatchDebounceMS)*time.Millisecond,
+				deps.Config.WorkspaceWatchMaxDirs,
+			)
+		}
+	} else {
+		s.toolCache = newToolResultCache(defaultToolCacheTTL, defaultToolCacheMaxEntries)
+		s.qualityGate = newTurnQualityGate(false, defaultQualityGateMaxRetries, defaultQualityGateSettle)
 	}
 
 	// 代码执行引擎 (无外部依赖, 仅需 workDir)
@@ -284,6 +510,11 @@ func New(deps Deps) *Server {
 		s.codeRunner = cr
 	}
 
+	if s.mgr != nil {
+		s.mgr.SetLaunchEnvResolver(s.threadLaunchEnv)
+		s.mgr.SetOnLaunched(s.onAgentLaunched)
+	}
+
 	s.registerDynamicTools()
 	return s
 }
@@ -294,6 +525,11 @@ func New(deps Deps) *Server {
 func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
 	defer s.cleanupRuntimeResources()
 
+	// 排空完成后经 exit 触发关闭
+	ctx, exit := context.WithCancel(ctx)
+	defer exit()
+	s.drain.setExit(exit)
+
 	// 解析地址: 去掉 ws:// 前缀
 	host := strings.TrimPrefix(addr, "ws://")
 	host = strings.TrimPrefix(host, "wss://")
@@ -302,6 +538,12 @@ func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
 	mux.HandleFunc("/", s.handleUpgrade)    // WebSocket
 	mux.HandleFunc("/rpc", s.handleHTTPRPC) // HTTP JSON-RPC (调试模式)
 	mux.HandleFunc("/events", s.handleSSE)  // SSE 事件流 (调试模式)
+	// 审批推送中继回复 (签名链接, 未配置 APPROVAL_RELAY_* 时 404)
+	mux.HandleFunc("/approval/respond", s.handleApprovalRelayRespond)
+	// 只读观战 WebSocket (观战密钥认证, 未配置 SPECTATOR_KEYS 时 404)
+	mux.HandleFunc("/spectate", s.handleSpectate)
+	// data/export 下载 (一次性令牌, 过期或已下载返回 410)
+	mux.HandleFunc(dataExportDownloadPath, s.handleDataExportDownload)
 
 	srv := &http.Server{
 		Addr:              host,
@@ -310,11 +552,30 @@ func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
 		ReadHeaderTimeout: 10 * time.Second,
 	}
 
+	s.checkCodexBinary(ctx)
+	s.startFleetReconciler(ctx)
+	s.startAgentHealthMonitor(ctx)
+	s.startQuietHoursLoop(ctx)
+	s.restoreEmergencyLock()
+	s.startPersistReplayLoop(ctx)
+	s.startSkillsWatcher(ctx)
+	s.startLogRetentionLoop(ctx)
+	s.startStorageJanitorLoop(ctx)
+	s.startTimelineEvictionLoop(ctx)
+	s.startUIRuntimePersistLoop(ctx)
+	s.startThreadSearchIndexer(ctx)
+	if err := s.reloadDynamicToolRegistry(ctx); err != nil {
+		logger.Warn("dynamic tools: registry load failed", logger.FieldError, err)
+	}
+
 	// 优雅关闭: 给活跃连接 5 秒完成处理
 	util.SafeGo(func() {
 		<-ctx.Done()
 		logger.Info("app-server: shutdown trigger", "ctx_err", ctx.Err())
 		logger.Info("app-server: shutting down")
+		s.fileWatch.closeAll()
+		s.fleet.stopSchedules()
+		s.wasmPlugins.replace(nil)
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 		if err := srv.Shutdown(shutdownCtx); err != nil {
@@ -340,5 +601,6 @@ func (s *Server) cleanupRuntimeResources() {
 		s.agentWorkDirMu.Lock()
 		clear(s.agentWorkDirs)
 		s.agentWorkDirMu.Unlock()
+		s.saveUIRuntimeState()
 	})
 }
@@ -2,6 +2,7 @@
 package apiserver
 
 import (
+	"context"
 	"strings"
 	"time"
 
@@ -63,6 +64,26 @@ func (s *Server) handleApprovalRequest(agentID, method string, payload map[strin
 	}
 	defer s.approvalInFlight.Delete(inflightKey)
 
+	// 紧急停止: 冻结期间不询问客户端, 直接拒绝文件变更 / 命令执行
+	if _, locked := s.emergency.current(); locked {
+		logger.Warn("app-server: approval auto-denied — emergency stop active",
+			logger.FieldAgentID, agentID, logger.FieldMethod, method)
+		s.denyApproval(agentID, event)
+		return
+	}
+
+	// 角色模板审批策略 never: 不询问客户端, 拒绝后由模型自行处理
+	if s.templateDeniesApprovals(agentID) {
+		logger.Info("app-server: approval auto-denied — agent template policy never",
+			logger.FieldAgentID, agentID, logger.FieldMethod, method)
+		s.denyApproval(agentID, event)
+		return
+	}
+
+	// 审批长时间未处理 → 外部通知渠道 (approval.pending)
+	stopPendingNotify := s.watchApprovalPending(agentID, method)
+	defer stopPendingNotify()
+
 	// 心跳: 防止 stall 检测在等待审批期间误杀
 	heartbeatDone := make(chan struct{})
 	defer close(heartbeatDone)
@@ -86,10 +107,65 @@ func (s *Server) handleApprovalRequest(agentID, method string, payload map[strin
 		}
 	})
 
+	approved := s.awaitApprovalWithRelay(agentID, method, payload, func(ctx context.Context) bool {
+		return s.awaitClientApproval(ctx, agentID, method, payload)
+	})
+
+	// 回传给 codex agent
+	if s.mgr == nil {
+		logger.Error("app-server: approval auto-denied — mgr is nil",
+			logger.FieldAgentID, agentID, logger.FieldMethod, method)
+		if event.DenyFunc != nil {
+			if denyErr := event.DenyFunc(); denyErr != nil {
+				logger.Warn("app-server: deny callback failed", logger.FieldAgentID, agentID, logger.FieldError, denyErr)
+			}
+		}
+		return
+	}
+	proc := s.mgr.Get(agentID)
+	if proc == nil {
+		logger.Error("app-server: approval auto-denied — agent gone",
+			logger.FieldAgentID, agentID, logger.FieldMethod, method)
+		if event.DenyFunc != nil {
+			if denyErr := event.DenyFunc(); denyErr != nil {
+				logger.Warn("app-server: deny callback failed", logger.FieldAgentID, agentID, logger.FieldError, denyErr)
+			}
+		}
+		return
+	}
+	decision := "no"
+	if approved {
+		decision = "yes"
+	}
+	if err := proc.Client.Submit(decision, nil, nil, nil); err != nil {
+		logger.Warn("app-server: relay approval to codex failed", logger.FieldAgentID, agentID, logger.FieldError, err)
+	}
+}
+
+// denyApproval 回传拒绝决定 (优先 DenyFunc, 否则向 codex 提交 "no")。
+func (s *Server) denyApproval(agentID string, event codex.Event) {
+	if event.DenyFunc != nil {
+		if denyErr := event.DenyFunc(); denyErr != nil {
+			logger.Warn("app-server: deny callback failed", logger.FieldAgentID, agentID, logger.FieldError, denyErr)
+		}
+		return
+	}
+	if s.mgr == nil {
+		return
+	}
+	if proc := s.mgr.Get(agentID); proc != nil {
+		if err := proc.Client.Submit("no", nil, nil, nil); err != nil {
+			logger.Warn("app-server: relay approval to codex failed", logger.FieldAgentID, agentID, logger.FieldError, err)
+		}
+	}
+}
+
+// awaitClientApproval 双通道等待客户端审批: WebSocket 优先, 否则 Wails 前端 (5 分钟超时, ctx 取消即撤回)。
+func (s *Server) awaitClientApproval(ctx context.Context, agentID, method string, payload map[string]any) bool {
 	approved := false
 
 	// 尝试 WebSocket 通道 (IDE 客户端)
-	resp, wsErr := s.SendRequestToAll(method, payload)
+	resp, wsErr := s.SendRequestToAll(ctx, method, payload)
 	if wsErr == nil && resp != nil && resp.Result != nil {
 		// WebSocket 客户端已回复
 		if m, ok := resp.Result.(map[string]any); ok {
@@ -97,7 +173,7 @@ func (s *Server) handleApprovalRequest(agentID, method string, payload map[strin
 				approved, _ = v.(bool)
 			}
 		}
-	} else {
+	} else if ctx.Err() == nil {
 		// 降级: Wails 模式 — 通过 broadcastNotification + pending channel
 		// 仅在有 notifyHook (Wails 前端) 时才等待, 否则直接跳过 (approved=false → deny)
 		s.notifyHookMu.RLock()
@@ -135,6 +211,8 @@ func (s *Server) handleApprovalRequest(agentID, method string, payload map[strin
 			case <-timer.C:
 				logger.Warn("app-server: approval timed out (Wails mode)",
 					logger.FieldAgentID, agentID, logger.FieldMethod, method)
+			case <-ctx.Done():
+				s.notifyServerRequestResolved(reqID, method, payload)
 			}
 		} else {
 			// 无前端连接: 无法交互, 自动拒绝
@@ -142,34 +220,5 @@ func (s *Server) handleApprovalRequest(agentID, method string, payload map[strin
 				logger.FieldAgentID, agentID, logger.FieldMethod, method)
 		}
 	}
-
-	// 回传给 codex agent
-	if s.mgr == nil {
-		logger.Error("app-server: approval auto-denied — mgr is nil",
-			logger.FieldAgentID, agentID, logger.FieldMethod, method)
-		if event.DenyFunc != nil {
-			if denyErr := event.DenyFunc(); denyErr != nil {
-				logger.Warn("app-server: deny callback failed", logger.FieldAgentID, agentID, logger.FieldError, denyErr)
-			}
-		}
-		return
-	}
-	proc := s.mgr.Get(agentID)
-	if proc == nil {
-		logger.Error("app-server: approval auto-denied — agent gone",
-			logger.FieldAgentID, agentID, logger.FieldMethod, method)
-		if event.DenyFunc != nil {
-			if denyErr := event.DenyFunc(); denyErr != nil {
-				logger.Warn("app-server: deny callback failed", logger.FieldAgentID, agentID, logger.FieldError, denyErr)
-			}
-		}
-		return
-	}
-	decision := "no"
-	if approved {
-		decision = "yes"
-	}
-	if err := proc.Client.Submit(decision, nil, nil, nil); err != nil {
-		logger.Warn("app-server: relay approval to codex failed", logger.FieldAgentID, agentID, logger.FieldError, err)
-	}
+	return approved
 }
@@ -19,7 +19,7 @@ func TestHandleApprovalRequest_DeduplicatesConcurrent(t *testing.T) {
 	s := &Server{
 		mgr:     nil, // mgr==nil → 快速走 deny 路径
 		conns:   map[string]*connEntry{},
-		pending: make(map[int64]chan *Response),
+		pending: make(map[int64]pendingRequest),
 	}
 
 	var execCount atomic.Int64
@@ -63,7 +63,7 @@ func TestHandleApprovalRequest_DifferentMethodsNotDeduplicated(t *testing.T) {
 	s := &Server{
 		mgr:     nil,
 		conns:   map[string]*connEntry{},
-		pending: make(map[int64]chan *Response),
+		pending: make(map[int64]pendingRequest),
 	}
 
 	var execCount atomic.Int64
@@ -23,11 +23,14 @@ type wsOutbound struct {
 
 // connEntry WebSocket 连接 + 写锁 (gorilla/websocket 不安全并发写)。
 type connEntry struct {
-	ws        *websocket.Conn
-	wrMu      sync.Mutex // 序列化所有写操作
-	outbox    chan wsOutbound
-	closeCh   chan struct{}
-	closeOnce sync.Once
+	ws           *websocket.Conn
+	wrMu         sync.Mutex // 序列化所有写操作
+	outbox       chan wsOutbound
+	closeCh      chan struct{}
+	closeOnce    sync.Once
+	spectator    *spectatorScope    // 非 nil = 只读观战连接 (见 spectator.go)
+	subscription notifySubscription // subscribe / unsubscribe 通知订阅 (见 notify_filter.go)
+	coalescer    deltaCoalescer     // stream/coalesce 流式 delta 合并 (见 ws_coalesce.go)
 }
 
 func newConnEntry(ws *websocket.Conn) *connEntry {
@@ -119,6 +122,9 @@ func (s *Server) InvokeMethod(ctx context.Context, method string, params json.Ra
 		return nil, nil
 	}
 	if resp.Error != nil {
+		if code := errorCodeOfResponse(resp); code != "" {
+			return nil, pkgerr.NewCodef("Server.InvokeMethod", code, "%s (code %d, error %s)", resp.Error.Message, resp.Error.Code, code)
+		}
 		return nil, pkgerr.Newf("Server.InvokeMethod", "%s (code %d)", resp.Error.Message, resp.Error.Code)
 	}
 	return resp.Result, nil
@@ -161,7 +167,18 @@ func (s *Server) broadcastNotification(method string, params any) {
 		snapshot[id] = entry
 	}
 	s.mu.RUnlock()
+	threadID := notificationThreadID(params)
 	for id, entry := range snapshot {
+		if entry.spectator != nil && !entry.spectator.watches(threadID) {
+			continue
+		}
+		if !entry.subscription.allows(method, threadID) {
+			continue
+		}
+		if entry.coalescer.active() {
+			entry.coalescer.push(method, params, data)
+			continue
+		}
 		s.enqueueConnMessage(id, entry, websocket.TextMessage, data, "notify_backpressure")
 	}
 }
@@ -202,8 +219,8 @@ func (s *Server) disconnectConn(connID string) {
 // SendRequest 向指定连接发送 Server→Client 请求并等待响应 (§ 二)。
 //
 // 用于 approval 流程: requestApproval → client 审批 → 返回结果。
-// 超时 5 分钟 (用户审批需要时间)。
-func (s *Server) SendRequest(connID, method string, params any) (*Response, error) {
+// 超时 5 分钟 (用户审批需要时间); ctx 取消 (如审批已由推送中继答复) 时撤回请求并通知 UI 关闭对话框。
+func (s *Server) SendRequest(ctx context.Context, connID, method string, params any) (*Response, error) {
 	reqID := s.nextReqID.Add(1)
 
 	req := Request{
@@ -224,10 +241,10 @@ func (s *Server) SendRequest(connID, method string, params any) (*Response, erro
 		return nil, pkgerr.Wrap(err, "Server.SendRequest", "marshal request")
 	}
 
-	// 创建 response channel
+	// 创建 response channel (只接受目标连接的响应)
 	ch := make(chan *Response, 1)
 	s.pendingMu.Lock()
-	s.pending[reqID] = ch
+	s.pending[reqID] = pendingRequest{ch: ch, connID: connID}
 	s.pendingMu.Unlock()
 
 	defer func() {
@@ -258,25 +275,39 @@ func (s *Server) SendRequest(connID, method string, params any) (*Response, erro
 		return resp, nil
 	case <-timer.C:
 		return nil, pkgerr.Newf("Server.SendRequest", "request %d timed out waiting for client response", reqID)
+	case <-ctx.Done():
+		s.notifyServerRequestResolved(reqID, method, params)
+		return nil, pkgerr.Wrapf(ctx.Err(), "Server.SendRequest", "request %d cancelled", reqID)
+	}
+}
+
+// notifyServerRequestResolved 通知前端撤下已在别处得到答复的 Server→Client 请求 (serverRequest/resolved)。
+func (s *Server) notifyServerRequestResolved(reqID int64, method string, params any) {
+	payload := map[string]any{"requestId": reqID, "method": method}
+	if threadID, _ := util.ToMapAny(params)["threadId"].(string); threadID != "" {
+		payload["threadId"] = threadID
 	}
+	s.Notify("serverRequest/resolved", payload)
 }
 
 // SendRequestToAll 向所有连接广播 Server→Client 请求, 返回第一个响应。
 //
 // 适用于只有一个 IDE 连接的场景。
-func (s *Server) SendRequestToAll(method string, params any) (*Response, error) {
+func (s *Server) SendRequestToAll(ctx context.Context, method string, params any) (*Response, error) {
 	s.mu.RLock()
 	var firstConn string
-	for id := range s.conns {
-		firstConn = id
-		break
+	for id, entry := range s.conns {
+		if entry.spectator == nil {
+			firstConn = id
+			break
+		}
 	}
 	s.mu.RUnlock()
 
 	if firstConn == "" {
 		return nil, pkgerr.New("Server.SendRequestToAll", "no connected clients")
 	}
-	return s.SendRequest(firstConn, method, params)
+	return s.SendRequest(ctx, firstConn, method, params)
 }
 
 // ResolvePendingRequest 由 Wails 前端调用, 将审批结果注入 pending channel。
@@ -291,8 +322,9 @@ func (s *Server) SendRequestToAll(method string, params any) (*Response, error)
 // 返回 true 表示找到对应 pending 请求并已投递。
 func (s *Server) ResolvePendingRequest(reqID int64, result map[string]any) bool {
 	s.pendingMu.Lock()
-	ch, ok := s.pending[reqID]
+	pr, ok := s.pending[reqID]
 	s.pendingMu.Unlock()
+	ch := pr.ch
 	if !ok {
 		logger.Warn("app-server: ResolvePendingRequest — no pending request",
 			logger.FieldID, reqID)
@@ -328,7 +360,7 @@ func (s *Server) AllocPendingRequest() (reqID int64, ch <-chan *Response, cleanu
 	id := s.nextReqID.Add(1)
 	respCh := make(chan *Response, 1)
 	s.pendingMu.Lock()
-	s.pending[id] = respCh
+	s.pending[id] = pendingRequest{ch: respCh}
 	s.pendingMu.Unlock()
 	cleanupFn := func() {
 		s.pendingMu.Lock()
@@ -339,6 +371,11 @@ func (s *Server) AllocPendingRequest() (reqID int64, ch <-chan *Response, cleanu
 }
 
 func (s *Server) handleUpgrade(w http.ResponseWriter, r *http.Request) {
+	s.serveConn(w, r, &s.upgrader, nil)
+}
+
+// serveConn 升级 WebSocket 并运行读写循环; spectator 非 nil 时为只读观战连接。
+func (s *Server) serveConn(w http.ResponseWriter, r *http.Request, upgrader *websocket.Upgrader, spectator *spectatorScope) {
 	// 连接数限制
 	s.mu.RLock()
 	numConns := len(s.conns)
@@ -349,7 +386,7 @@ func (s *Server) handleUpgrade(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ws, err := s.upgrader.Upgrade(w, r, nil)
+	ws, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		logger.Error("app-server: upgrade failed", logger.FieldError, err)
 		return
@@ -360,6 +397,11 @@ func (s *Server) handleUpgrade(w http.ResponseWriter, r *http.Request) {
 
 	connID := fmt.Sprintf("conn-%d", s.nextID.Add(1))
 	entry := newConnEntry(ws)
+	entry.spectator = spectator
+	entry.coalescer.send = func(data []byte) bool {
+		return s.enqueueConnMessage(connID, entry, websocket.TextMessage, data, "notify_backpressure")
+	}
+	entry.coalescer.configure(s.defaultStreamCoalesce())
 	s.mu.Lock()
 	s.conns[connID] = entry
 	s.mu.Unlock()
@@ -370,7 +412,7 @@ func (s *Server) handleUpgrade(w http.ResponseWriter, r *http.Request) {
 		}
 	})
 
-	logger.Info("app-server: client connected", logger.FieldConn, connID, logger.FieldRemote, r.RemoteAddr)
+	logger.Info("app-server: client connected", logger.FieldConn, connID, logger.FieldRemote, r.RemoteAddr, "spectator", spectator != nil)
 
 	defer func() {
 		s.mu.Lock()
@@ -470,6 +512,34 @@ func (s *Server) readLoop(ctx context.Context, entry *connEntry, connID string)
 			return
 		}
 
+		// 批量请求: 按顺序分发, 响应数组一次写回
+		if isBatchMessage(message) {
+			if entry.spectator != nil {
+				if !s.sendResponseViaOutbox(connID, entry, spectatorBatchRejected(), "spectator_batch_rejected") {
+					return
+				}
+				continue
+			}
+			if entry.outboxDepth() >= connBacklogCut {
+				overloaded := newErrorData(nil, CodeOverloaded, "Server overloaded; retry later.", map[string]any{
+					"retry_after_ms": 500,
+				})
+				if !s.sendResponseViaOutbox(connID, entry, overloaded, "batch_overloaded") {
+					return
+				}
+				continue
+			}
+			data, ok, err := encodeBatchResult(s.dispatchBatch(ctx, connID, message))
+			if err != nil {
+				logger.Error("app-server: marshal batch response failed", logger.FieldConn, connID, logger.FieldError, err)
+				return
+			}
+			if ok && !s.enqueueConnMessage(connID, entry, websocket.TextMessage, data, "batch_response") {
+				return
+			}
+			continue
+		}
+
 		// 单次 Unmarshal: 路由 + 延迟解析
 		var env rpcEnvelope
 		if err := json.Unmarshal(message, &env); err != nil {
@@ -479,8 +549,15 @@ func (s *Server) readLoop(ctx context.Context, entry *connEntry, connID string)
 
 		// 快速路径: 客户端响应 (有 id + 无 method + 有 result/error)
 		// 直接从 raw bytes 解析 int64 ID → pending map 查找, 零 alloc
-		if s.handleClientResponse(env) {
-			continue
+		if isClientResponse(env) {
+			if entry.spectator != nil {
+				// 只读观战连接不得应答 Server→Client 请求 (审批等), 直接丢弃。
+				logger.Warn("app-server: spectator client response dropped", logger.FieldConn, connID)
+				continue
+			}
+			if s.handleClientResponse(connID, env) {
+				continue
+			}
 		}
 		if env.Method != "" && len(env.ID) > 0 && string(env.ID) != "null" && entry.outboxDepth() >= connBacklogCut {
 			overloaded := newErrorData(rawIDtoAny(env.ID), CodeOverloaded, "Server overloaded; retry later.", map[string]any{
@@ -493,7 +570,16 @@ func (s *Server) readLoop(ctx context.Context, entry *connEntry, connID string)
 		}
 
 		// 正常请求/通知: 复用已解析的字段
-		resp := s.handleParsedMessage(ctx, env)
+		var resp *Response
+		if subResp, handled := handleSubscriptionMessage(&entry.subscription, env); handled {
+			resp = subResp
+		} else if coalesceResp, handled := handleStreamCoalesceMessage(&entry.coalescer, env); handled {
+			resp = coalesceResp
+		} else if entry.spectator != nil {
+			resp = s.dispatchSpectatorMessage(ctx, entry.spectator, env)
+		} else {
+			resp = s.handleParsedMessage(ctx, env)
+		}
 		if resp == nil {
 			continue
 		}
@@ -516,11 +602,23 @@ func (s *Server) sendResponseViaOutbox(connID string, entry *connEntry, resp *Re
 	return s.enqueueConnMessage(connID, entry, websocket.TextMessage, data, reason)
 }
 
-func (s *Server) handleClientResponse(env rpcEnvelope) bool {
+// pendingRequest 等待客户端响应的 Server→Client 请求。
+type pendingRequest struct {
+	ch     chan *Response
+	connID string // 请求发往的连接; "" = 进程内分配 (AllocPendingRequest), 仅 ResolvePendingRequest 可应答
+}
+
+// isClientResponse 报告消息是否为客户端响应 (有 id + 无 method + 有 result/error)。
+func isClientResponse(env rpcEnvelope) bool {
 	if len(env.ID) == 0 || string(env.ID) == "null" || env.Method != "" {
 		return false
 	}
-	if len(env.Result) == 0 && len(env.Error) == 0 {
+	return len(env.Result) > 0 || len(env.Error) > 0
+}
+
+// handleClientResponse 将 connID 发来的响应投递给等待中的请求; 只有请求的目标连接可以应答。
+func (s *Server) handleClientResponse(connID string, env rpcEnvelope) bool {
+	if !isClientResponse(env) {
 		return false
 	}
 	reqID, ok := parseIntID(env.ID)
@@ -528,11 +626,17 @@ func (s *Server) handleClientResponse(env rpcEnvelope) bool {
 		return false
 	}
 	s.pendingMu.Lock()
-	ch, found := s.pending[reqID]
+	pr, found := s.pending[reqID]
 	s.pendingMu.Unlock()
 	if !found {
 		return false
 	}
+	if pr.connID == "" || pr.connID != connID {
+		logger.Warn("app-server: client response from non-target connection dropped",
+			logger.FieldConn, connID, logger.FieldID, reqID)
+		return true
+	}
+	ch := pr.ch
 	resp := &Response{
 		JSONRPC: jsonrpcVersion,
 		ID:      reqID,
@@ -593,7 +697,10 @@ func (s *Server) dispatchRequest(ctx context.Context, id any, method string, par
 		return newError(id, CodeMethodNotFound, "method not found: "+method)
 	}
 
-	result, err := handler(ctx, params)
+	ctx, trail := s.beginAuditTrail(ctx, method)
+	result, err := s.invokeIdempotent(ctx, method, handler, params)
+	s.finishAuditTrail(trail, err)
+	s.rpcStats.record(method, err != nil)
 	if err != nil {
 		if id == nil {
 			logger.Warn("app-server: notification handler error (no response sent)",
@@ -607,7 +714,9 @@ func (s *Server) dispatchRequest(ctx context.Context, id any, method string, par
 			logger.FieldID, id,
 			logger.FieldError, err,
 		)
-		return newError(id, CodeInternalError, err.Error())
+		resp := newHandlerError(id, err)
+		resp.CorrelationID = trail.ID
+		return resp
 	}
 
 	// JSON-RPC 2.0: 通知 (id == nil) 不返回响应
@@ -615,5 +724,7 @@ func (s *Server) dispatchRequest(ctx context.Context, id any, method string, par
 		return nil
 	}
 
-	return newResult(id, result)
+	resp := newResult(id, result)
+	resp.CorrelationID = trail.ID
+	return resp
 }
@@ -0,0 +1,301 @@
+// server_drain.go — 优雅排空 (server/drain, SIGUSR1), 用于滚动重启不丢失进行中的工作。
+//
+// 排空流程:
+//  1. 拒绝新的 thread/start 与 turn/start (SERVER_DRAINING, 客户端可重试到新实例);
+//     调度器中排队的 turn 不再派发, 记入检查点。
+//  2. 等待进行中的 turn 结束 (超时后不再等待, 未完成 turn 记入检查点)。
+//  3. 检查点: 重放离线 WAL, 写入运行时快照 (agent、剩余 turn、未派发 turn、静默暂存、UI 快照)。
+//  4. exit=true 时停止 agent 进程并触发 ListenAndServe 退出。
+//
+// 状态推送 server/draining (开始) 与 server/drained (完成)。
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/runner"
+	"github.com/multi-agent/go-agent-v2/internal/uistate"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+	"github.com/multi-agent/go-agent-v2/pkg/util"
+)
+
+const (
+	drainPhaseDraining      = "draining"
+	drainPhaseCheckpointing = "checkpointing"
+	drainPhaseDrained       = "drained"
+
+	defaultDrainTimeout = 5 * time.Minute
+	maxDrainTimeout     = time.Hour
+	drainPollInterval   = 500 * time.Millisecond
+	drainWALReplayWait  = 10 * time.Second
+)
+
+// drainParkedTurn 排空期间未派发的排队 turn。
+type drainParkedTurn struct {
+	QueueID  string `json:"queueId"`
+	ThreadID string `json:"threadId"`
+	Cwd      string `json:"cwd,omitempty"`
+	Prompt   string `json:"prompt,omitempty"`
+	Model    string `json:"model,omitempty"`
+}
+
+// drainStatus server/drain 状态视图。
+type drainStatus struct {
+	Phase          string              `json:"phase,omitempty"` // 空 = 未排空
+	Reason         string              `json:"reason,omitempty"`
+	StartedAt      time.Time           `json:"startedAt"`
+	Deadline       time.Time           `json:"deadline"`
+	FinishedAt     time.Time           `json:"finishedAt"`
+	TimedOut       bool                `json:"timedOut,omitempty"`
+	Exit           bool                `json:"exit"`
+	ActiveTurns    []emergencyTurnView `json:"activeTurns"`
+	Parked         []drainParkedTurn   `json:"parked"`
+	CheckpointPath string              `json:"checkpointPath,omitempty"`
+}
+
+// drainCheckpoint 排空完成时写入磁盘的运行时状态。
+type drainCheckpoint struct {
+	drainStatus
+	Agents     []runner.AgentInfo       `json:"agents"`
+	Held       []heldTurn               `json:"quietHoursHeld"`
+	WALPending int                      `json:"walPending"`
+	UI         *uistate.RuntimeSnapshot `json:"ui,omitempty"`
+}
+
+// drainState 排空状态 (零值 = 未排空)。
+type drainState struct {
+	mu     sync.Mutex
+	status drainStatus
+	exit   context.CancelFunc // ListenAndServe 注入, 排空完成后退出
+}
+
+func (d *drainState) setExit(exit context.CancelFunc) {
+	d.mu.Lock()
+	d.exit = exit
+	d.mu.Unlock()
+}
+
+// begin 进入排空; 已在排空时返回 false 与当前状态。
+func (d *drainState) begin(reason string, timeout time.Duration, exit bool, now time.Time) (drainStatus, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.status.Phase != "" {
+		return d.snapshotLocked(), false
+	}
+	d.status = drainStatus{
+		Phase:     drainPhaseDraining,
+		Reason:    reason,
+		StartedAt: now,
+		Deadline:  now.Add(timeout),
+		Exit:      exit,
+	}
+	return d.snapshotLocked(), true
+}
+
+// park 排空期间暂存排队 turn; 未排空时返回 false (调用方应直接派发)。
+func (d *drainState) park(queueID string, turn preparedTurn) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.status.Phase == "" {
+		return false
+	}
+	d.status.Parked = append(d.status.Parked, drainParkedTurn{
+		QueueID: queueID, ThreadID: turn.ThreadID, Cwd: turn.Cwd, Prompt: turn.Prompt, Model: turn.Model,
+	})
+	return true
+}
+
+func (d *drainState) update(fn func(st *drainStatus)) drainStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	fn(&d.status)
+	return d.snapshotLocked()
+}
+
+func (d *drainState) snapshot() drainStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.snapshotLocked()
+}
+
+func (d *drainState) snapshotLocked() drainStatus {
+	st := d.status
+	st.ActiveTurns = append([]emergencyTurnView{}, d.status.ActiveTurns...)
+	st.Parked = append([]drainParkedTurn{}, d.status.Parked...)
+	return st
+}
+
+// drainingError 排空期间拒绝新工作。
+func (s *Server) drainingError(op string) error {
+	st := s.drain.snapshot()
+	if st.Phase == "" {
+		return nil
+	}
+	return apperrors.NewCodef(op, errcode.ServerDraining, "server draining since %s (%s); retry after restart",
+		st.StartedAt.Format(time.RFC3339), st.Reason)
+}
+
+// Drain 开始优雅排空 (SIGUSR1 等进程内触发); timeout ≤ 0 使用配置默认值。
+func (s *Server) Drain(reason string, timeout time.Duration, exit bool) error {
+	_, err := s.startDrain(reason, timeout, exit)
+	return err
+}
+
+func (s *Server) startDrain(reason string, timeout time.Duration, exit bool) (drainStatus, error) {
+	if timeout <= 0 {
+		timeout = defaultDrainTimeout
+		if s.cfg != nil && s.cfg.DrainTimeoutSec > 0 {
+			timeout = time.Duration(s.cfg.DrainTimeoutSec) * time.Second
+		}
+	}
+	if timeout > maxDrainTimeout {
+		timeout = maxDrainTimeout
+	}
+	if reason == "" {
+		reason = "manual"
+	}
+	st, started := s.drain.begin(reason, timeout, exit, time.Now())
+	if !started {
+		return st, nil
+	}
+	st = s.drain.update(func(d *drainStatus) { d.ActiveTurns = s.activeTrackedTurns() })
+	logger.Warn("app-server: draining",
+		"reason", reason,
+		"timeout_ms", timeout.Milliseconds(),
+		"active_turns", len(st.ActiveTurns),
+		"exit", exit,
+	)
+	s.Notify("server/draining", st)
+	util.SafeGo(s.runDrain)
+	return st, nil
+}
+
+// runDrain 等待进行中 turn 结束, 写检查点, 按需退出。
+func (s *Server) runDrain() {
+	deadline := s.drain.snapshot().Deadline
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for {
+		turns := s.activeTrackedTurns()
+		s.drain.update(func(d *drainStatus) { d.ActiveTurns = turns })
+		if len(turns) == 0 {
+			break
+		}
+		if !time.Now().Before(deadline) {
+			logger.Warn("app-server: drain timed out with active turns", "active_turns", len(turns))
+			s.drain.update(func(d *drainStatus) { d.TimedOut = true })
+			break
+		}
+		<-ticker.C
+	}
+
+	s.drain.update(func(d *drainStatus) { d.Phase = drainPhaseCheckpointing })
+	path, err := s.writeDrainCheckpoint()
+	if err != nil {
+		logger.Error("app-server: drain checkpoint failed", logger.FieldError, err)
+	}
+	st := s.drain.update(func(d *drainStatus) {
+		d.Phase = drainPhaseDrained
+		d.FinishedAt = time.Now()
+		d.CheckpointPath = path
+	})
+	logger.Warn("app-server: drained",
+		"timed_out", st.TimedOut,
+		"parked", len(st.Parked),
+		logger.FieldPath, path,
+		logger.FieldDurationMS, st.FinishedAt.Sub(st.StartedAt).Milliseconds(),
+	)
+	s.Notify("server/drained", st)
+
+	if !st.Exit {
+		return
+	}
+	if s.mgr != nil {
+		s.mgr.StopAll()
+	}
+	s.drain.mu.Lock()
+	exit := s.drain.exit
+	s.drain.mu.Unlock()
+	if exit != nil {
+		exit()
+	}
+}
+
+// writeDrainCheckpoint 重放 WAL 并写入检查点文件 (与紧急停止快照同目录)。
+func (s *Server) writeDrainCheckpoint() (string, error) {
+	if s.persistWAL != nil && s.persistWAL.pending() > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), drainWALReplayWait)
+		if _, _, err := s.replayPersistWAL(ctx); err != nil {
+			logger.Warn("app-server: drain wal replay failed", logger.FieldError, err)
+		}
+		cancel()
+	}
+	cp := drainCheckpoint{
+		drainStatus: s.drain.snapshot(),
+		Agents:      []runner.AgentInfo{},
+		Held:        s.quietHours.snapshot(),
+	}
+	if s.mgr != nil {
+		cp.Agents = s.mgr.List()
+	}
+	if s.persistWAL != nil {
+		cp.WALPending = s.persistWAL.pending()
+	}
+	if s.uiRuntime != nil {
+		ui := s.uiRuntime.SnapshotLight()
+		cp.UI = &ui
+	}
+
+	dir, err := s.emergencySnapshotDir()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("drain-%s.json", cp.StartedAt.UTC().Format("20060102T150405.000Z")))
+	cp.CheckpointPath = path
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return "", apperrors.Wrap(err, "Server.writeDrainCheckpoint", "marshal checkpoint")
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", apperrors.Wrap(err, "Server.writeDrainCheckpoint", "write checkpoint")
+	}
+	return path, nil
+}
+
+// ========================================
+// server/drain, server/drain/status
+// ========================================
+
+type serverDrainParams struct {
+	Reason     string `json:"reason,omitempty"`
+	By         string `json:"by,omitempty"`
+	TimeoutSec int    `json:"timeoutSec,omitempty"`
+	Exit       *bool  `json:"exit,omitempty"` // 默认 true
+}
+
+func (s *Server) serverDrainTyped(_ context.Context, p serverDrainParams) (any, error) {
+	if p.TimeoutSec < 0 {
+		return nil, apperrors.NewCode("Server.serverDrain", errcode.InvalidInput, "timeoutSec must be >= 0")
+	}
+	reason := p.Reason
+	if reason == "" {
+		reason = "manual"
+	}
+	if p.By != "" {
+		reason = fmt.Sprintf("%s (by %s)", reason, p.By)
+	}
+	exit := p.Exit == nil || *p.Exit
+	return s.startDrain(reason, time.Duration(p.TimeoutSec)*time.Second, exit)
+}
+
+func (s *Server) serverDrainStatus(_ context.Context, _ json.RawMessage) (any, error) {
+	return s.drain.snapshot(), nil
+}
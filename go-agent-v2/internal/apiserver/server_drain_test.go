@@ -0,0 +1,93 @@
+package apiserver
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/config"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+func TestDrainWaitsForActiveTurnsThenExits(t *testing.T) {
+	srv := &Server{
+		cfg:         &config.Config{EmergencySnapshotDir: t.TempDir()},
+		activeTurns: map[string]*trackedTurn{"thread-1": {ID: "turn-1", ThreadID: "thread-1", StartedAt: time.Now()}},
+	}
+	exited := make(chan struct{})
+	srv.drain.setExit(func() { close(exited) })
+
+	st, err := srv.serverDrainTyped(context.Background(), serverDrainParams{Reason: "rolling restart", TimeoutSec: 30})
+	if err != nil {
+		t.Fatalf("drain: %v", err)
+	}
+	if status := st.(drainStatus); status.Phase != drainPhaseDraining || len(status.ActiveTurns) != 1 {
+		t.Fatalf("status = %+v", status)
+	}
+
+	// 排空期间拒绝新线程 / 新 turn, 排队 turn 不再派发
+	if _, err := srv.threadStartTyped(context.Background(), threadStartParams{}); apperrors.CodeOf(err) != errcode.ServerDraining {
+		t.Fatalf("thread/start err = %v, want SERVER_DRAINING", err)
+	}
+	if _, err := srv.turnStartTyped(context.Background(), turnStartParams{ThreadID: "thread-1"}); apperrors.CodeOf(err) != errcode.ServerDraining {
+		t.Fatalf("turn/start err = %v, want SERVER_DRAINING", err)
+	}
+	srv.dispatchQueuedTurn("queue-7", preparedTurn{ThreadID: "thread-2", Prompt: "run tests"})
+
+	select {
+	case <-exited:
+		t.Fatal("drain must wait for the active turn")
+	case <-time.After(2 * drainPollInterval):
+	}
+	srv.turnMu.Lock()
+	delete(srv.activeTurns, "thread-1")
+	srv.turnMu.Unlock()
+
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("drain did not exit after the active turn finished")
+	}
+	final := srv.drain.snapshot()
+	if final.Phase != drainPhaseDrained || final.TimedOut || len(final.Parked) != 1 {
+		t.Fatalf("final status = %+v", final)
+	}
+	data, err := os.ReadFile(final.CheckpointPath)
+	if err != nil {
+		t.Fatalf("read checkpoint: %v", err)
+	}
+	if !strings.Contains(string(data), `"queue-7"`) || !strings.Contains(string(data), `"rolling restart"`) {
+		t.Fatalf("checkpoint = %s", data)
+	}
+}
+
+func TestDrainTimeoutWithoutExit(t *testing.T) {
+	srv := &Server{
+		cfg:         &config.Config{EmergencySnapshotDir: t.TempDir()},
+		activeTurns: map[string]*trackedTurn{"thread-1": {ID: "turn-1", ThreadID: "thread-1", StartedAt: time.Now()}},
+	}
+	srv.drain.setExit(func() { t.Error("exit=false must not stop the server") })
+
+	if _, err := srv.startDrain("check", time.Millisecond, false); err != nil {
+		t.Fatalf("drain: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for srv.drain.snapshot().Phase != drainPhaseDrained {
+		if time.Now().After(deadline) {
+			t.Fatalf("drain stuck: %+v", srv.drain.snapshot())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if st := srv.drain.snapshot(); !st.TimedOut || len(st.ActiveTurns) != 1 {
+		t.Fatalf("status = %+v", st)
+	}
+
+	// 重复触发返回当前状态, 不重新开始
+	again, _ := srv.startDrain("again", time.Minute, true)
+	if again.Reason != "check" || again.Phase != drainPhaseDrained {
+		t.Fatalf("second drain = %+v", again)
+	}
+}
@@ -104,29 +104,33 @@ func (s *Server) registerDynamicTools() {
 	s.dynTools["prompt_get"] = s.resourcePromptGet
 	s.dynTools["shared_file_read"] = s.resourceSharedFileRead
 	s.dynTools["shared_file_write"] = s.resourceSharedFileWrite
+	s.dynTools["artifact_get"] = s.resourceArtifactGet
 	s.dynTools["workspace_create_run"] = s.resourceWorkspaceCreateRun
 	s.dynTools["workspace_get_run"] = s.resourceWorkspaceGetRun
 	s.dynTools["workspace_list_runs"] = s.resourceWorkspaceListRuns
 	s.dynTools["workspace_merge_run"] = s.resourceWorkspaceMergeRun
 	s.dynTools["workspace_abort_run"] = s.resourceWorkspaceAbortRun
+
+	// 知识库工具
+	s.dynTools["kb_search"] = s.kbSearchTool
 }
 
 // SetupLSP 初始化 LSP 事件转发: 诊断缓存 + 广播。
 func (s *Server) SetupLSP(rootDir string) {
+	if root := normalizeAgentWorkDir(rootDir); root != "" {
+		if _, err := s.workspaceRoots.add(root); err != nil {
+			logger.Warn("lsp: register primary workspace root failed", logger.FieldRoot, root, logger.FieldError, err)
+		}
+	}
 	if s.lsp == nil {
 		return
 	}
 	if rootDir != "" {
+		s.applyProjectLSPCatalog(rootDir)
 		s.lsp.SetRootURI("file://" + rootDir)
 	}
 	s.lsp.SetDiagnosticHandler(func(uri string, diagnostics []lsp.Diagnostic) {
-		s.diagMu.Lock()
-		if len(diagnostics) == 0 {
-			delete(s.diagCache, uri)
-		} else {
-			s.diagCache[uri] = diagnostics
-		}
-		s.diagMu.Unlock()
+		s.setDiagnostics(uri, diagnostics)
 
 		// 广播诊断通知给前端
 		items := make([]map[string]any, 0, len(diagnostics))
@@ -343,6 +347,42 @@ func (s *Server) handleDynamicToolCall(agentID string, event codex.Event) {
 		return
 	}
 
+	// 紧急停止: 冻结期间拒绝所有工具调用 (含写文件 / code_run)
+	if err := s.frozenError("Server.handleDynamicToolCall"); err != nil {
+		logger.Warn("dynamic-tool: rejected — emergency stop active",
+			logger.FieldAgentID, agentID, logger.FieldToolName, call.Tool)
+		if event.RequestID != nil {
+			if respErr := proc.Client.RespondError(*event.RequestID, -32000, err.Error()); respErr != nil {
+				logger.Warn("app-server: respond error failed", logger.FieldAgentID, agentID, logger.FieldError, respErr)
+			}
+		}
+		return
+	}
+
+	// 角色模板工具白名单
+	if err := s.templateToolError(agentID, call.Tool); err != nil {
+		logger.Warn("dynamic-tool: rejected — not in agent template",
+			logger.FieldAgentID, agentID, logger.FieldToolName, call.Tool)
+		if event.RequestID != nil {
+			if respErr := proc.Client.RespondError(*event.RequestID, -32000, err.Error()); respErr != nil {
+				logger.Warn("app-server: respond error failed", logger.FieldAgentID, agentID, logger.FieldError, respErr)
+			}
+		}
+		return
+	}
+
+	// 线程级禁用 (tools/setEnabled)
+	if err := s.threadToolDisabledError(context.Background(), agentID, call.Tool); err != nil {
+		logger.Warn("dynamic-tool: rejected — disabled for thread",
+			logger.FieldAgentID, agentID, logger.FieldToolName, call.Tool)
+		if event.RequestID != nil {
+			if respErr := proc.Client.RespondError(*event.RequestID, -32000, err.Error()); respErr != nil {
+				logger.Warn("app-server: respond error failed", logger.FieldAgentID, agentID, logger.FieldError, respErr)
+			}
+		}
+		return
+	}
+
 	// ── 可观测性: 计数 + 日志 ──
 	start := time.Now()
 	s.toolCallMu.Lock()
@@ -358,9 +398,16 @@ func (s *Server) handleDynamicToolCall(agentID string, event codex.Event) {
 	)
 
 	var result string
+	cached := false
 
 	if call.Tool == "orchestration_send_message" {
 		result = s.orchestrationSendMessageFrom(agentID, call.Arguments)
+	} else if call.Tool == "artifact_put" {
+		// 需要 agentID 记录产出线程
+		result = s.artifactPutFrom(agentID, call.Arguments)
+	} else if call.Tool == "ask_user" {
+		// 需要 agentID + callID 登记问题, 阻塞直到作答
+		result = s.askUserFrom(agentID, call.CallID, call.Arguments)
 	} else if call.Tool == "code_run" {
 		// code_run / code_run_test: 需要 agentID + callID, 在此硬编码分支。
 		resolvedCallID := resolveCodeRunCallID(call.CallID, event.RequestID)
@@ -373,6 +420,7 @@ func (s *Server) handleDynamicToolCall(agentID string, event codex.Event) {
 			}()
 			return s.codeRunWithAgent(execCtx, agentID, resolvedCallID, call.Arguments)
 		}()
+		s.toolCache.invalidate()
 	} else if call.Tool == "code_run_test" {
 		resolvedCallID := resolveCodeRunCallID(call.CallID, event.RequestID)
 		result = func() string {
@@ -384,8 +432,13 @@ func (s *Server) handleDynamicToolCall(agentID string, event codex.Event) {
 			}()
 			return s.codeRunTestWithAgent(execCtx, agentID, resolvedCallID, call.Arguments)
 		}()
+		s.toolCache.invalidate()
 	} else if handler, ok := s.dynTools[call.Tool]; ok {
-		result = handler(call.Arguments)
+		result, cached = s.invokeCachedDynamicTool(call.Tool, call.Arguments, handler)
+	} else if tool, ok := s.lookupRegistryTool(call.Tool); ok {
+		result = s.callRegistryTool(tool, agentID, call.CallID, call.Arguments)
+	} else if tool, ok := s.lookupProjectTool(agentID, call.Tool); ok {
+		result = s.callRegistryTool(tool, agentID, call.CallID, call.Arguments)
 	} else {
 		result = fmt.Sprintf("unknown tool: %s", call.Tool)
 	}
@@ -410,6 +463,7 @@ func (s *Server) handleDynamicToolCall(agentID string, event codex.Event) {
 		logger.FieldEventType, "dynamic_tool_call",
 		"result_len", len(result),
 		"success", success,
+		"cached", cached,
 	)
 
 	// 递增活动统计 (lsp_ 前缀工具会自动累加到 lspCalls)
@@ -419,7 +473,13 @@ func (s *Server) handleDynamicToolCall(agentID string, event codex.Event) {
 
 	// 广播到前端 — 让 UI 可以显示 LSP 调用
 	notifyPayload := buildToolNotifyPayload(agentID, call, argMap, filePath, success, count, elapsed, result)
+	if cached {
+		notifyPayload["cached"] = true
+	}
 	s.Notify("dynamic-tool/called", notifyPayload)
+	if success && !cached {
+		s.rememberToolOutput(agentID, call.Tool, call.Arguments, result)
+	}
 
 	// 回传结果: 使用 event.RequestID 发送 JSON-RPC response (codex 发的是 server request)
 	if err := proc.Client.SendDynamicToolResult(call.CallID, result, event.RequestID); err != nil {
@@ -566,8 +626,8 @@ func (s *Server) lspDiagnostics(args json.RawMessage) string {
 			abs, _ := filepath.Abs(uri)
 			uri = "file://" + abs
 		}
-		diags, ok := s.diagCache[uri]
-		if !ok || len(diags) == 0 {
+		diags := s.diagCache.get(uri)
+		if len(diags) == 0 {
 			return "no diagnostics"
 		}
 		var sb strings.Builder
@@ -582,11 +642,11 @@ func (s *Server) lspDiagnostics(args json.RawMessage) string {
 		return "no diagnostics"
 	}
 	var sb strings.Builder
-	for uri, diags := range s.diagCache {
+	s.diagCache.each(func(_, uri string, diags []lsp.Diagnostic) {
 		for _, d := range diags {
 			fmt.Fprintf(&sb, "%s:%d:%d %s\n", uri, d.Range.Start.Line+1, d.Range.Start.Character, d.Message)
 		}
-	}
+	})
 	return sb.String()
 }
 
@@ -14,8 +14,9 @@ import (
 func TestHandleClientResponse(t *testing.T) {
 	ch := make(chan *Response, 1)
 	s := &Server{
-		pending: map[int64]chan *Response{
-			42: ch,
+		pending: map[int64]pendingRequest{
+			42: {ch: ch, connID: "conn-1"},
+			43: {ch: make(chan *Response, 1)}, // 进程内分配, 仅 ResolvePendingRequest 可应答
 		},
 	}
 	env := rpcEnvelope{
@@ -23,7 +24,17 @@ func TestHandleClientResponse(t *testing.T) {
 		Result: json.RawMessage(`{"ok":true}`),
 	}
 
-	if !s.handleClientResponse(env) {
+	// 非目标连接的响应被丢弃, 不得投递。
+	if !s.handleClientResponse("conn-2", env) {
+		t.Fatal("response for a pending id should be consumed")
+	}
+	select {
+	case <-ch:
+		t.Fatal("response from another connection must not be delivered")
+	default:
+	}
+
+	if !s.handleClientResponse("conn-1", env) {
 		t.Fatal("expected client response to be handled")
 	}
 	select {
@@ -34,6 +45,12 @@ func TestHandleClientResponse(t *testing.T) {
 	default:
 		t.Fatal("expected response sent to pending channel")
 	}
+
+	env.ID = json.RawMessage("43")
+	s.handleClientResponse("", env)
+	if len(s.pending[43].ch) != 0 {
+		t.Fatal("in-process pending request must not be answered by a client response")
+	}
 }
 
 func TestExtractToolFilePath(t *testing.T) {
@@ -229,7 +246,7 @@ func TestHandleApprovalRequest_ProcNil_AutoDenies(t *testing.T) {
 	s := &Server{
 		mgr:     nil, // mgr==nil → proc 查不到
 		conns:   map[string]*connEntry{},
-		pending: make(map[int64]chan *Response), // Wails 模式需要
+		pending: make(map[int64]pendingRequest), // Wails 模式需要
 	}
 
 	denied := false
@@ -498,3 +515,17 @@ func TestAgentEventHandler_StreamErrorRetryLifecycle(t *testing.T) {
 		t.Fatal("non-retryable stream error should clear tracked turn")
 	}
 }
+
+func TestMergePayloadFieldsAliasesUnifiedDiff(t *testing.T) {
+	payload := map[string]any{"threadId": "agent-4"}
+	mergePayloadFields(payload, json.RawMessage(`{"unified_diff":"--- a/x\n+++ b/x\n"}`))
+	if got, _ := payload["diff"].(string); got != "--- a/x\n+++ b/x\n" {
+		t.Fatalf("payload diff = %q", got)
+	}
+
+	payload = map[string]any{"threadId": "agent-4"}
+	mergePayloadFields(payload, json.RawMessage(`{"diff":"v2","unified_diff":"v1"}`))
+	if got, _ := payload["diff"].(string); got != "v2" {
+		t.Fatalf("v2 diff should win, got %q", got)
+	}
+}
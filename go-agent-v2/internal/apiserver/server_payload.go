@@ -4,6 +4,7 @@ package apiserver
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
 	"time"
@@ -34,6 +35,7 @@ func (s *Server) SetNotifyHook(h func(method string, params any)) {
 func (s *Server) Notify(method string, params any) {
 	s.syncUIRuntimeFromNotify(method, params)
 	payload := util.ToMapAny(params)
+	s.recordAuditNotification(method, payload)
 	s.broadcastNotification(method, payload)
 
 	if shouldEmitUIStateChanged(method, payload) {
@@ -49,7 +51,7 @@ func (s *Server) Notify(method string, params any) {
 }
 
 func shouldEmitUIStateChanged(method string, payload map[string]any) bool {
-	if method == "" || method == "ui/state/changed" {
+	if method == "" || method == "ui/state/changed" || method == UIThreadOpenMethod {
 		return false
 	}
 	if strings.HasPrefix(method, "workspace/run/") {
@@ -259,6 +261,12 @@ func mergePayloadFromMap(payload map[string]any, data map[string]any) {
 			payload["file"] = v
 		}
 	}
+	// codex v1 turn_diff 使用 unified_diff; 统一为 turn/diff/updated 的 diff。
+	if v, ok := data["unified_diff"]; ok {
+		if _, exists := payload["diff"]; !exists {
+			payload["diff"] = v
+		}
+	}
 	if errObj, ok := data["error"].(map[string]any); ok && errObj != nil {
 		if _, exists := payload["message"]; !exists {
 			if msg, ok := errObj["message"]; ok {
@@ -471,6 +479,15 @@ func (s *Server) enrichFileChangePayload(threadID, eventType, method string, pay
 		files = parseFilesFromPatchDelta(delta)
 	}
 
+	if len(files) > 0 {
+		s.toolCache.invalidate()
+		s.touchTurnQualityGate(threadID, files)
+		// 补丁开始 / 审批请求均早于写盘, 此时记录撤销快照。
+		if method == "item/started" || method == "item/fileChange/requestApproval" {
+			s.captureTurnUndoSnapshots(threadID, files)
+		}
+	}
+
 	switch method {
 	case "item/fileChange/outputDelta", "item/started":
 		if len(files) > 0 {
@@ -491,6 +508,27 @@ func (s *Server) enrichFileChangePayload(threadID, eventType, method string, pay
 	}
 }
 
+// applyAgentEventToUIRuntime 归一化事件供 UI 使用并写入 uiRuntime。
+func (s *Server) applyAgentEventToUIRuntime(agentID, eventType, method string, payload map[string]any) {
+	normalized := uistate.NormalizeEventFromPayload(eventType, method, payload)
+	payload["uiType"] = string(normalized.UIType)
+	if normalized.Text != "" {
+		payload["uiText"] = normalized.Text
+	}
+	if normalized.Command != "" {
+		payload["uiCommand"] = normalized.Command
+	}
+	if len(normalized.Files) > 0 {
+		payload["uiFiles"] = normalized.Files
+	}
+	if normalized.ExitCode != nil {
+		payload["uiExitCode"] = *normalized.ExitCode
+	}
+	if s.uiRuntime != nil {
+		s.uiRuntime.ApplyAgentEvent(agentID, normalized, payload)
+	}
+}
+
 // AgentEventHandler 返回一个 codex.EventHandler，将 Agent 事件转为 JSON-RPC 通知/请求。
 //
 // 普通事件: 广播为通知 (无需客户端回复)。
@@ -528,6 +566,7 @@ func (s *Server) AgentEventHandler(agentID string) codex.EventHandler {
 		payload["threadId"] = agentID
 		s.enrichFileChangePayload(agentID, event.Type, method, payload)
 		s.captureAndInjectTurnSummary(agentID, event.Type, method, payload)
+		s.observeTerminalEvent(agentID, event.Type, method, payload)
 		if method == "error" {
 			willRetry, hasWillRetry := extractBoolFromPayload(payload, "willRetry", "will_retry", "recoverable")
 			if !hasWillRetry {
@@ -543,28 +582,26 @@ func (s *Server) AgentEventHandler(agentID string) codex.EventHandler {
 			}
 		}
 
-		// Normalize event for UI
-		normalized := uistate.NormalizeEventFromPayload(event.Type, method, payload)
-		payload["uiType"] = string(normalized.UIType)
-		if normalized.Text != "" {
-			payload["uiText"] = normalized.Text
-		}
-		if normalized.Command != "" {
-			payload["uiCommand"] = normalized.Command
-		}
-		if len(normalized.Files) > 0 {
-			payload["uiFiles"] = normalized.Files
-		}
-		if normalized.ExitCode != nil {
-			payload["uiExitCode"] = *normalized.ExitCode
-		}
-		if s.uiRuntime != nil {
-			s.uiRuntime.ApplyAgentEvent(agentID, normalized, payload)
+		// 诊断门禁: completed 终态事件挂起, 评估后由 finishQualityGatedEvent 重新派发。
+		gateHeld, gateSuppress := s.holdTurnForQualityGate(agentID, event.Type, method, payload)
+		if gateSuppress {
+			s.touchTrackedTurnLastEvent(agentID)
+			return
 		}
 
+		s.applyAgentEventToUIRuntime(agentID, event.Type, method, payload)
+
 		s.touchTrackedTurnLastEvent(agentID)
-		s.maybeFinalizeTrackedTurn(agentID, event.Type, method, payload)
-		s.maybeAutoReportOrchestrationCompletion(agentID, event.Type, method, payload)
+		if !gateHeld {
+			s.maybeFinalizeTrackedTurn(agentID, event.Type, method, payload)
+			s.maybeAutoReportOrchestrationCompletion(agentID, event.Type, method, payload)
+		}
+
+		// 回放模式: 审批 / 动态工具调用等服务端请求不执行, 按通知输出。
+		if event.RequestID != nil && s.replayMode.Load() {
+			s.Notify(method, payload)
+			return
+		}
 
 		// § 二 审批事件: 需要客户端回复 (双向请求)
 		switch event.Type {
@@ -588,9 +625,12 @@ func (s *Server) AgentEventHandler(agentID string) codex.EventHandler {
 // HTTP JSON-RPC (调试模式)
 // ========================================
 
+// maxHTTPRPCBodyBytes HTTP JSON-RPC 请求体上限。
+const maxHTTPRPCBodyBytes = 8 << 20
+
 // handleHTTPRPC 处理 HTTP POST /rpc 请求 (调试模式用)。
 //
-// 接收标准 JSON-RPC 2.0 请求, 调用 InvokeMethod, 返回 JSON-RPC 响应。
+// 接收标准 JSON-RPC 2.0 请求 (或批量数组), 调用 InvokeMethod, 返回 JSON-RPC 响应。
 func (s *Server) handleHTTPRPC(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodOptions {
 		w.WriteHeader(http.StatusOK)
@@ -601,6 +641,30 @@ func (s *Server) handleHTTPRPC(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxHTTPRPCBodyBytes))
+	if err != nil {
+		writeJSONRPCError(w, nil, -32700, "Parse error: "+err.Error())
+		return
+	}
+
+	// 批量请求: 返回响应数组 (全部为通知时 204)
+	if isBatchMessage(body) {
+		data, ok, err := encodeBatchResult(s.dispatchBatch(r.Context(), "", body))
+		if err != nil {
+			writeJSONRPCError(w, nil, -32603, "encode batch response: "+err.Error())
+			return
+		}
+		if !ok {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write(data); err != nil {
+			logger.Warn("http-rpc: write batch response failed", logger.FieldError, err)
+		}
+		return
+	}
+
 	var req struct {
 		JSONRPC string          `json:"jsonrpc"`
 		ID      any             `json:"id"`
@@ -608,7 +672,7 @@ func (s *Server) handleHTTPRPC(w http.ResponseWriter, r *http.Request) {
 		Params  json.RawMessage `json:"params"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := json.Unmarshal(body, &req); err != nil {
 		writeJSONRPCError(w, nil, -32700, "Parse error: "+err.Error())
 		return
 	}
@@ -0,0 +1,48 @@
+// session_replay.go — codex 会话录制回放 (cmd/replay)。
+//
+// 录制文件由 codex 包写入 (GO_AGENT_SESSION_CAPTURE_DIR)。回放把入站消息还原为 codex.Event,
+// 经 AgentEventHandler 同一路径写入 uiRuntime 并产生通知; 服务端请求 (审批 / 动态工具调用)
+// 在回放模式下只输出通知, 不执行工具也不等待客户端回复。
+package apiserver
+
+import (
+	"strings"
+
+	"github.com/multi-agent/go-agent-v2/internal/codex"
+	"github.com/multi-agent/go-agent-v2/internal/uistate"
+)
+
+// ReplayCapture 以回放模式按序派发录制记录, 返回实际派发的事件数。
+//
+// agentID 非空时只回放该 Agent 的记录。调用后服务器保持回放模式, 不应再用于真实会话。
+func (s *Server) ReplayCapture(records []codex.CaptureRecord, agentID string) int {
+	s.replayMode.Store(true)
+	agentID = strings.TrimSpace(agentID)
+	handlers := map[string]codex.EventHandler{}
+	replayed := 0
+	for _, rec := range records {
+		if agentID != "" && rec.AgentID != agentID {
+			continue
+		}
+		event, ok := codex.CaptureEvent(rec)
+		if !ok {
+			continue
+		}
+		handler, ok := handlers[rec.AgentID]
+		if !ok {
+			handler = s.AgentEventHandler(rec.AgentID)
+			handlers[rec.AgentID] = handler
+		}
+		handler(event)
+		replayed++
+	}
+	return replayed
+}
+
+// ThreadTimeline 返回线程当前 UI 时间线 (回放结果比对)。
+func (s *Server) ThreadTimeline(threadID string) []uistate.TimelineItem {
+	if s.uiRuntime == nil {
+		return nil
+	}
+	return s.uiRuntime.ThreadTimeline(threadID)
+}
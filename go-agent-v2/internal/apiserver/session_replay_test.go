@@ -0,0 +1,53 @@
+package apiserver
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/multi-agent/go-agent-v2/internal/codex"
+	"github.com/multi-agent/go-agent-v2/internal/runner"
+	"github.com/multi-agent/go-agent-v2/internal/uistate"
+)
+
+func TestReplayCaptureFeedsTimelineWithoutSideEffects(t *testing.T) {
+	capture := strings.Join([]string{
+		`{"dir":"out","agentId":"thread-r","msg":{"jsonrpc":"2.0","id":1,"method":"turn/start","params":{}}}`,
+		`{"dir":"in","agentId":"thread-r","msg":{"jsonrpc":"2.0","id":1,"result":{}}}`,
+		`{"dir":"in","agentId":"thread-r","msg":{"jsonrpc":"2.0","method":"turn/started","params":{"turn":{"id":"turn-1"}}}}`,
+		`{"dir":"in","agentId":"thread-r","msg":{"jsonrpc":"2.0","method":"item/agentMessage/delta","params":{"delta":"hello"}}}`,
+		`{"dir":"in","agentId":"thread-r","msg":{"jsonrpc":"2.0","id":9,"method":"item/tool/call","params":{"tool":"code_run","arguments":{"code":"rm -rf /"}}}}`,
+		`{"dir":"in","agentId":"thread-other","msg":{"jsonrpc":"2.0","method":"turn/started","params":{}}}`,
+	}, "\n")
+	records, err := codex.ReadCapture(strings.NewReader(capture))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &Server{mgr: runner.NewAgentManager(), uiRuntime: uistate.NewRuntimeManager()}
+	var methods []string
+	srv.SetNotifyHook(func(method string, _ any) {
+		if method != "ui/state/changed" {
+			methods = append(methods, method)
+		}
+	})
+	if n := srv.ReplayCapture(records, "thread-r"); n != 3 {
+		t.Fatalf("replayed = %d, want 3", n)
+	}
+	want := []string{"turn/started", "item/agentMessage/delta", "item/tool/call"}
+	if strings.Join(methods, ",") != strings.Join(want, ",") {
+		t.Fatalf("notifications = %v, want %v", methods, want)
+	}
+	timeline := srv.ThreadTimeline("thread-r")
+	found := false
+	for _, item := range timeline {
+		if strings.Contains(item.Text, "hello") {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("timeline missing replayed delta: %+v", timeline)
+	}
+	if len(srv.ThreadTimeline("thread-other")) != 0 {
+		t.Fatal("filtered agent should not be replayed")
+	}
+}
@@ -0,0 +1,195 @@
+// skills_watcher.go — 技能目录热加载: fsnotify 监听技能目录, 变更后失效技能缓存并推送 skills/changed。
+//
+// 外部编辑 SKILL.md / 拷贝技能目录 / git pull 等操作无需重启服务即可生效,
+// 前端据 skills/changed 刷新输入框技能匹配与技能页列表。事件按去抖窗口合并为一次通知。
+package apiserver
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+	"github.com/multi-agent/go-agent-v2/pkg/util"
+)
+
+const defaultSkillsWatchDebounce = 300 * time.Millisecond
+
+// skillsWatcher 递归监听技能目录 (新建子目录自动追加监听)。
+type skillsWatcher struct {
+	root     string
+	watcher  *fsnotify.Watcher
+	debounce time.Duration
+	onChange func(paths []string)
+
+	mu      sync.Mutex
+	pending map[string]struct{}
+	timer   *time.Timer
+	closed  bool
+	done    chan struct{}
+}
+
+func startSkillsWatcher(root string, debounce time.Duration, onChange func(paths []string)) (*skillsWatcher, error) {
+	if debounce <= 0 {
+		debounce = defaultSkillsWatchDebounce
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, apperrors.Wrap(err, "Server.skillsWatchStart", "ensure skills dir")
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, apperrors.Wrap(err, "Server.skillsWatchStart", "create watcher")
+	}
+	w := &skillsWatcher{
+		root:     root,
+		watcher:  watcher,
+		debounce: debounce,
+		onChange: onChange,
+		pending:  make(map[string]struct{}),
+		done:     make(chan struct{}),
+	}
+	w.addTree(root)
+	util.SafeGo(w.loop)
+	logger.Info("skills watcher: started", logger.FieldPath, root)
+	return w, nil
+}
+
+func (w *skillsWatcher) addTree(dir string) {
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if path != w.root && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if addErr := w.watcher.Add(path); addErr != nil {
+			logger.Debug("skills watcher: add dir failed", logger.FieldPath, path, logger.FieldError, addErr)
+		}
+		return nil
+	})
+}
+
+func (w *skillsWatcher) loop() {
+	for {
+		select {
+		case <-w.done:
+			return
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			w.handleEvent(event)
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			logger.Warn("skills watcher: error", logger.FieldPath, w.root, logger.FieldError, err)
+		}
+	}
+}
+
+func (w *skillsWatcher) handleEvent(event fsnotify.Event) {
+	if event.Op == fsnotify.Chmod {
+		return
+	}
+	base := filepath.Base(event.Name)
+	if strings.HasSuffix(base, "~") || strings.HasSuffix(base, ".swp") || strings.HasPrefix(base, ".#") {
+		return
+	}
+	if event.Has(fsnotify.Create) {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			w.addTree(event.Name)
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+	w.pending[event.Name] = struct{}{}
+	if w.timer == nil {
+		w.timer = time.AfterFunc(w.debounce, w.flush)
+	} else {
+		w.timer.Reset(w.debounce)
+	}
+}
+
+func (w *skillsWatcher) flush() {
+	w.mu.Lock()
+	if w.closed || len(w.pending) == 0 {
+		w.mu.Unlock()
+		return
+	}
+	paths := make([]string, 0, len(w.pending))
+	for path := range w.pending {
+		rel, err := filepath.Rel(w.root, path)
+		if err != nil {
+			rel = path
+		}
+		paths = append(paths, filepath.ToSlash(rel))
+	}
+	w.pending = make(map[string]struct{})
+	w.mu.Unlock()
+	sort.Strings(paths)
+	w.onChange(paths)
+}
+
+func (w *skillsWatcher) close() {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return
+	}
+	w.closed = true
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.mu.Unlock()
+	close(w.done)
+	_ = w.watcher.Close()
+	logger.Info("skills watcher: stopped", logger.FieldPath, w.root)
+}
+
+// startSkillsWatcher 监听技能目录直到 ctx 结束 (配置关闭或无技能服务时不启动)。
+func (s *Server) startSkillsWatcher(ctx context.Context) {
+	if s.skillSvc == nil || s.skillsDir == "" || (s.cfg != nil && !s.cfg.SkillsWatchEnabled) {
+		return
+	}
+	debounce := defaultSkillsWatchDebounce
+	if s.cfg != nil && s.cfg.SkillsWatchDebounceMS > 0 {
+		debounce = time.Duration(s.cfg.SkillsWatchDebounceMS) * time.Millisecond
+	}
+	w, err := startSkillsWatcher(s.skillsDir, debounce, s.handleSkillsChanged)
+	if err != nil {
+		logger.Warn("skills watcher: start failed", logger.FieldPath, s.skillsDir, logger.FieldError, err)
+		return
+	}
+	util.SafeGo(func() {
+		<-ctx.Done()
+		w.close()
+	})
+}
+
+// handleSkillsChanged 失效技能缓存并推送最新技能列表。
+func (s *Server) handleSkillsChanged(paths []string) {
+	s.skillSvc.Invalidate()
+	list, err := s.skillSvc.ListSkills()
+	if err != nil {
+		logger.Warn("skills watcher: reload failed", logger.FieldError, err)
+		return
+	}
+	logger.Info("skills watcher: skills changed", "paths", len(paths), "skills", len(list))
+	s.Notify("skills/changed", map[string]any{
+		"paths":  paths,
+		"skills": skillInfoPayload(list),
+	})
+}
@@ -0,0 +1,52 @@
+package apiserver
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/service"
+)
+
+func TestSkillsWatcherNotifiesOnExternalChange(t *testing.T) {
+	dir := t.TempDir()
+	srv := &Server{skillsDir: dir, skillSvc: service.NewSkillService(dir)}
+	if _, err := srv.skillSvc.ListSkills(); err != nil { // 预热缓存
+		t.Fatalf("ListSkills: %v", err)
+	}
+
+	changed := make(chan map[string]any, 4)
+	srv.SetNotifyHook(func(method string, params any) {
+		if method == "skills/changed" {
+			changed <- params.(map[string]any)
+		}
+	})
+	w, err := startSkillsWatcher(dir, 20*time.Millisecond, srv.handleSkillsChanged)
+	if err != nil {
+		t.Fatalf("startSkillsWatcher: %v", err)
+	}
+	defer w.close()
+
+	skillDir := filepath.Join(dir, "by-id", "external")
+	if err := os.MkdirAll(skillDir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond) // 等待新目录加入监听
+	if err := os.WriteFile(filepath.Join(skillDir, "SKILL.md"), []byte("---\nname: external\n---\n# external"), 0o644); err != nil {
+		t.Fatalf("write SKILL.md: %v", err)
+	}
+
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case payload := <-changed:
+			skills := payload["skills"].([]map[string]any)
+			if len(skills) == 1 && skills[0]["name"] == "external" {
+				return
+			}
+		case <-deadline:
+			t.Fatal("skills/changed with external skill not received")
+		}
+	}
+}
@@ -0,0 +1,211 @@
+// spectator.go — 只读观战连接 (GET /spectate, WebSocket)。
+//
+// 客户端以 SPECTATOR_KEYS 中的观战密钥认证 (Authorization: Bearer <key> 或 ?key=),
+// 经 ?threads=a,b 选择观看的线程 ("*" = 全部), 连接后可用 spectate/threads 调整。
+// 观战连接只收到所选线程的通知 (无 threadId 的全局通知不推送), 请求仅放行
+// spectatorMethods 中的只读方法 (及 subscribe / unsubscribe / stream/coalesce) 且线程必须在所选范围内, 其余一律以 READ_ONLY 拒绝;
+// 观战连接不参与审批 (SendRequestToAll / 审批中继不把它当作前端)。
+// 未配置 SPECTATOR_KEYS 时 /spectate 返回 404。观战密钥本身即授权, 因此不限制 Origin。
+package apiserver
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+const (
+	spectateThreadsMethod = "spectate/threads"
+	maxSpectatorThreads   = 64
+)
+
+// spectatorMethods 观战连接可调用的只读方法 (true = 按 params.threadId 校验所选线程)。
+var spectatorMethods = map[string]bool{
+	"initialize":           false,
+	"initialized":          false,
+	"errors/codes":         false,
+	"thread/messages":      true,
+	"thread/stateAt":       true,
+	"thread/lineage":       true,
+	"thread/diff/get":      true,
+	"thread/undo/preview":  true,
+	"thread/context/list":  true,
+	"agent/capabilities":   true,
+	"turn/await":           true,
+	"turn/result/get":      true,
+	"review/findings/list": true,
+}
+
+// spectatorScope 观战连接所选的线程。
+type spectatorScope struct {
+	mu      sync.RWMutex
+	all     bool
+	threads map[string]bool
+}
+
+func newSpectatorScope(threadIDs []string) *spectatorScope {
+	scope := &spectatorScope{}
+	scope.set(threadIDs)
+	return scope
+}
+
+// set 替换所选线程 ("*" = 全部)。
+func (v *spectatorScope) set(threadIDs []string) {
+	threads := make(map[string]bool, len(threadIDs))
+	all := false
+	for _, id := range threadIDs {
+		id = strings.TrimSpace(id)
+		switch {
+		case id == "*":
+			all = true
+		case id != "" && len(threads) < maxSpectatorThreads:
+			threads[id] = true
+		}
+	}
+	v.mu.Lock()
+	v.all, v.threads = all, threads
+	v.mu.Unlock()
+}
+
+func (v *spectatorScope) watches(threadID string) bool {
+	if threadID == "" {
+		return false
+	}
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.all || v.threads[threadID]
+}
+
+func (v *spectatorScope) list() []string {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if v.all {
+		return []string{"*"}
+	}
+	out := make([]string, 0, len(v.threads))
+	for id := range v.threads {
+		out = append(out, id)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// spectatorKeys 解析 SPECTATOR_KEYS (逗号分隔)。
+func (s *Server) spectatorKeys() []string {
+	if s.cfg == nil {
+		return nil
+	}
+	var keys []string
+	for _, key := range strings.Split(s.cfg.SpectatorKeys, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// spectatorKeyFromRequest 读取观战密钥 (Authorization: Bearer 优先, 浏览器 WebSocket 无法设置 header 时用 ?key=)。
+func spectatorKeyFromRequest(r *http.Request) string {
+	if auth := strings.TrimSpace(r.Header.Get("Authorization")); len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return strings.TrimSpace(r.URL.Query().Get("key"))
+}
+
+func validSpectatorKey(keys []string, got string) bool {
+	if got == "" {
+		return false
+	}
+	valid := false
+	for _, key := range keys {
+		if subtle.ConstantTimeCompare([]byte(got), []byte(key)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
+
+// handleSpectate GET /spectate: 校验观战密钥后升级为只读 WebSocket 连接。
+func (s *Server) handleSpectate(w http.ResponseWriter, r *http.Request) {
+	keys := s.spectatorKeys()
+	if len(keys) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	if !validSpectatorKey(keys, spectatorKeyFromRequest(r)) {
+		logger.Warn("app-server: spectator rejected (invalid key)", logger.FieldRemote, r.RemoteAddr)
+		http.Error(w, "invalid spectator key", http.StatusUnauthorized)
+		return
+	}
+	scope := newSpectatorScope(strings.Split(r.URL.Query().Get("threads"), ","))
+	upgrader := s.upgrader
+	upgrader.CheckOrigin = func(*http.Request) bool { return true }
+	s.serveConn(w, r, &upgrader, scope)
+}
+
+// notificationThreadID 取通知所属线程 (threadId, 其次 agent_id)。
+func notificationThreadID(params any) string {
+	payload, ok := params.(map[string]any)
+	if !ok {
+		return ""
+	}
+	if id, _ := payload["threadId"].(string); strings.TrimSpace(id) != "" {
+		return strings.TrimSpace(id)
+	}
+	id, _ := payload["agent_id"].(string)
+	return strings.TrimSpace(id)
+}
+
+// dispatchSpectatorMessage 观战连接的请求分发: 仅放行只读方法。
+func (s *Server) dispatchSpectatorMessage(ctx context.Context, scope *spectatorScope, env rpcEnvelope) *Response {
+	const op = "Server.spectate"
+	id := rawIDtoAny(env.ID)
+	if env.Method == spectateThreadsMethod {
+		var p struct {
+			ThreadIDs []string `json:"threadIds"`
+		}
+		if err := json.Unmarshal(env.Params, &p); err != nil {
+			return newHandlerError(id, apperrors.WrapCode(err, op, errcode.InvalidParams, "unmarshal params"))
+		}
+		scope.set(p.ThreadIDs)
+		if id == nil {
+			return nil
+		}
+		return newResult(id, map[string]any{"threadIds": scope.list()})
+	}
+	threadScoped, allowed := spectatorMethods[env.Method]
+	if !allowed {
+		logger.Warn("app-server: spectator method rejected", logger.FieldMethod, env.Method)
+		if id == nil {
+			return nil
+		}
+		return newHandlerError(id, apperrors.NewCodef(op, errcode.ReadOnly, "method %s not allowed on read-only spectator connection", env.Method))
+	}
+	if threadScoped {
+		var p struct {
+			ThreadID string `json:"threadId"`
+		}
+		_ = json.Unmarshal(env.Params, &p)
+		if !scope.watches(strings.TrimSpace(p.ThreadID)) {
+			if id == nil {
+				return nil
+			}
+			return newHandlerError(id, apperrors.NewCodef(op, errcode.ReadOnly, "thread %q is not selected for spectating", p.ThreadID))
+		}
+	}
+	return s.dispatchRequest(ctx, id, env.Method, env.Params)
+}
+
+// spectatorBatchRejected 观战连接不接受批量请求。
+func spectatorBatchRejected() *Response {
+	return newErrorData(nil, CodeInvalidRequest, "batch requests are not allowed on read-only spectator connection",
+		rpcErrorData{Code: errcode.ReadOnly})
+}
@@ -0,0 +1,117 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/multi-agent/go-agent-v2/internal/config"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+)
+
+func TestSpectate_ReadOnlyScopedConnection(t *testing.T) {
+	srv := New(Deps{})
+	srv.cfg = &config.Config{SpectatorKeys: "view-1, view-2"}
+	ts := httptest.NewServer(http.HandlerFunc(srv.handleSpectate))
+	t.Cleanup(ts.Close)
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http")
+
+	for _, query := range []string{"", "?key=wrong"} {
+		_, resp, err := websocket.DefaultDialer.Dial(wsURL+query, nil)
+		if err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("dial %q: err = %v resp = %v", query, err, resp)
+		}
+	}
+
+	ws, _, err := websocket.DefaultDialer.Dial(wsURL+"?key=view-2&threads=thread-a", nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = ws.Close() })
+	read := func() map[string]any {
+		t.Helper()
+		_ = ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+		var msg map[string]any
+		if err := ws.ReadJSON(&msg); err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		return msg
+	}
+	call := func(id int, method string, params any) map[string]any {
+		t.Helper()
+		if err := ws.WriteJSON(map[string]any{"jsonrpc": "2.0", "id": id, "method": method, "params": params}); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		return read()
+	}
+	errorCode := func(msg map[string]any) string {
+		rpcErr, _ := msg["error"].(map[string]any)
+		data, _ := rpcErr["data"].(map[string]any)
+		code, _ := data["code"].(string)
+		return code
+	}
+
+	if msg := call(1, "turn/start", map[string]any{"threadId": "thread-a"}); errorCode(msg) != errcode.ReadOnly {
+		t.Fatalf("turn/start = %#v", msg)
+	}
+	if msg := call(2, "thread/messages", map[string]any{"threadId": "thread-b"}); errorCode(msg) != errcode.ReadOnly {
+		t.Fatalf("unselected thread = %#v", msg)
+	}
+	if msg := call(3, "errors/codes", nil); msg["error"] != nil {
+		t.Fatalf("errors/codes = %#v", msg)
+	}
+
+	// 观战连接即使是请求的目标也不能应答 Server→Client 请求 (审批等)。
+	srv.mu.RLock()
+	var spectatorConn string
+	for id, entry := range srv.conns {
+		if entry.spectator != nil {
+			spectatorConn = id
+		}
+	}
+	srv.mu.RUnlock()
+	approval := make(chan *Response, 1)
+	srv.pendingMu.Lock()
+	srv.pending[900] = pendingRequest{ch: approval, connID: spectatorConn}
+	srv.pendingMu.Unlock()
+	if err := ws.WriteJSON(map[string]any{"jsonrpc": "2.0", "id": 900, "result": map[string]any{"approved": true}}); err != nil {
+		t.Fatalf("write response: %v", err)
+	}
+	if msg := call(5, "errors/codes", nil); msg["error"] != nil {
+		t.Fatalf("errors/codes after response = %#v", msg)
+	}
+	if len(approval) != 0 {
+		t.Fatal("spectator response must not resolve a pending request")
+	}
+
+	srv.Notify("item/agentMessage/delta", map[string]any{"threadId": "thread-b", "delta": "hidden"})
+	srv.Notify("config/changed", map[string]any{"key": "LOG_LEVEL"})
+	srv.Notify("item/agentMessage/delta", map[string]any{"threadId": "thread-a", "delta": "visible"})
+	if msg := read(); msg["method"] != "item/agentMessage/delta" || msg["params"].(map[string]any)["delta"] != "visible" {
+		t.Fatalf("notification = %#v", msg)
+	}
+
+	msg := call(4, "spectate/threads", map[string]any{"threadIds": []string{"thread-b"}})
+	if got, _ := json.Marshal(msg["result"]); string(got) != `{"threadIds":["thread-b"]}` {
+		t.Fatalf("spectate/threads = %s", got)
+	}
+	srv.Notify("item/agentMessage/delta", map[string]any{"threadId": "thread-a", "delta": "hidden"})
+	srv.Notify("item/agentMessage/delta", map[string]any{"threadId": "thread-b", "delta": "now visible"})
+	if msg := read(); msg["params"].(map[string]any)["delta"] != "now visible" {
+		t.Fatalf("notification after reselect = %#v", msg)
+	}
+}
+
+func TestSpectate_DisabledWithoutKeys(t *testing.T) {
+	srv := New(Deps{})
+	srv.cfg = &config.Config{}
+	rec := httptest.NewRecorder()
+	srv.handleSpectate(rec, httptest.NewRequest(http.MethodGet, "/spectate?key=any", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d", rec.Code)
+	}
+}
@@ -0,0 +1,390 @@
+// storage_janitor.go — 磁盘配额清理 (storage/usage, storage/cleanup)。
+//
+// 三类目录各有配额 (STORAGE_QUOTA_*_MB, 0 = 不限):
+//   - logs: logs/ (日志文件) + 日志归档目录;
+//   - rollouts: ~/.codex/sessions + ~/.codex/archived_sessions;
+//   - temp: 输入暂存目录 + 系统临时目录下残留的 skill-registry-* 导入目录。
+//
+// 后台按 STORAGE_JANITOR_INTERVAL_MIN 周期统计用量: 达到 STORAGE_WARN_PERCENT 时推送
+// storage/quotaWarning; 超过配额时先推送预警 (含待删文件数/字节) 再按修改时间从旧到新删除,
+// 直到回落到配额以内。最近 STORAGE_MIN_AGE_MIN 分钟内修改的文件 (正在写入的日志 / rollout) 不删除。
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/service"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+	"github.com/multi-agent/go-agent-v2/pkg/util"
+)
+
+const (
+	storageCategoryLogs     = "logs"
+	storageCategoryRollouts = "rollouts"
+	storageCategoryTemp     = "temp"
+
+	// storageLogDir 日志文件目录 (与 cmd/agent-terminal 的 logger.InitWithFile 一致)。
+	storageLogDir = "logs"
+
+	storageJanitorInitialDelay = 2 * time.Minute
+	storageJanitorRunTimeout   = 10 * time.Minute
+	storageWarningSampleSize   = 20
+	storageMaxRecordedErrors   = 20
+	bytesPerMB                 = 1 << 20
+)
+
+// storageRoot 被统计的目录; Ephemeral 目录清空后连同自身删除 (skill-registry-* 残留)。
+type storageRoot struct {
+	Path      string
+	Ephemeral bool
+}
+
+// storageCategory 一类受配额管理的目录。
+type storageCategory struct {
+	Name       string
+	QuotaBytes int64
+	Roots      []storageRoot
+}
+
+// storageFile 扫描到的普通文件。
+type storageFile struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+}
+
+// storageUsage 单类目录用量。
+type storageUsage struct {
+	Category   string   `json:"category"`
+	Roots      []string `json:"roots"`
+	UsedBytes  int64    `json:"usedBytes"`
+	QuotaBytes int64    `json:"quotaBytes"` // 0 = 不限
+	Files      int      `json:"files"`
+	Percent    float64  `json:"percent,omitempty"`
+}
+
+// storageCategoryResult 单类目录本轮清理结果。
+type storageCategoryResult struct {
+	storageUsage
+	Warned      bool     `json:"warned,omitempty"`
+	Planned     int      `json:"planned"` // 超配额时计划删除的文件数 (dryRun 时不删除)
+	Pruned      int      `json:"pruned"`
+	PrunedBytes int64    `json:"prunedBytes"`
+	Errors      []string `json:"errors,omitempty"`
+}
+
+// storageJanitorResult 单轮清理结果。
+type storageJanitorResult struct {
+	StartedAt  time.Time               `json:"startedAt"`
+	FinishedAt time.Time               `json:"finishedAt"`
+	DryRun     bool                    `json:"dryRun,omitempty"`
+	Categories []storageCategoryResult `json:"categories"`
+}
+
+// storageJanitorState 清理串行化与最近一次结果。
+type storageJanitorState struct {
+	runMu sync.Mutex
+
+	mu   sync.Mutex
+	last *storageJanitorResult
+}
+
+func (st *storageJanitorState) lastResult() *storageJanitorResult {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.last == nil {
+		return nil
+	}
+	cp := *st.last
+	return &cp
+}
+
+func (st *storageJanitorState) record(result *storageJanitorResult) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	cp := *result
+	st.last = &cp
+}
+
+// storageJanitorSettings 预警百分比与最小保留时长。
+func (s *Server) storageJanitorSettings() (warnPercent int, minAge time.Duration) {
+	if s.cfg == nil {
+		return 90, time.Hour
+	}
+	return s.cfg.StorageWarnPercent, time.Duration(s.cfg.StorageMinAgeMin) * time.Minute
+}
+
+// storageCategories 当前受管目录与配额。
+func (s *Server) storageCategories() []storageCategory {
+	logsMB, rolloutsMB, tempMB := 1024, 4096, 1024
+	stagingDir := ""
+	if s.cfg != nil {
+		logsMB, rolloutsMB, tempMB = s.cfg.StorageQuotaLogsMB, s.cfg.StorageQuotaRolloutsMB, s.cfg.StorageQuotaTempMB
+		stagingDir = strings.TrimSpace(s.cfg.InputStagingDir)
+	}
+
+	logRoots := []storageRoot{{Path: absOrSelf(storageLogDir)}}
+	if dir, err := s.logArchiveDir(); err == nil {
+		logRoots = append(logRoots, storageRoot{Path: absOrSelf(dir)})
+	}
+
+	var rolloutRoots []storageRoot
+	if homeDir, err := os.UserHomeDir(); err == nil {
+		rolloutRoots = []storageRoot{
+			{Path: filepath.Join(homeDir, ".codex", "sessions")},
+			{Path: filepath.Join(homeDir, ".codex", "archived_sessions")},
+		}
+	}
+
+	if stagingDir == "" {
+		stagingDir = service.DefaultInputStagingDir()
+	}
+	tempRoots := []storageRoot{{Path: absOrSelf(stagingDir)}}
+	if matches, err := filepath.Glob(filepath.Join(os.TempDir(), "skill-registry-*")); err == nil {
+		for _, dir := range matches {
+			tempRoots = append(tempRoots, storageRoot{Path: dir, Ephemeral: true})
+		}
+	}
+
+	return []storageCategory{
+		{Name: storageCategoryLogs, QuotaBytes: int64(logsMB) * bytesPerMB, Roots: logRoots},
+		{Name: storageCategoryRollouts, QuotaBytes: int64(rolloutsMB) * bytesPerMB, Roots: rolloutRoots},
+		{Name: storageCategoryTemp, QuotaBytes: int64(tempMB) * bytesPerMB, Roots: tempRoots},
+	}
+}
+
+func absOrSelf(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// scanStorageCategory 统计用量 (不跟随符号链接; 目录不存在视为空)。
+func scanStorageCategory(cat storageCategory) (storageUsage, []storageFile) {
+	usage := storageUsage{Category: cat.Name, QuotaBytes: cat.QuotaBytes, Roots: make([]string, 0, len(cat.Roots))}
+	var files []storageFile
+	for _, root := range cat.Roots {
+		usage.Roots = append(usage.Roots, root.Path)
+		_ = filepath.WalkDir(root.Path, func(path string, d fs.DirEntry, walkErr error) error {
+			if walkErr != nil || d == nil || !d.Type().IsRegular() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			files = append(files, storageFile{Path: path, Size: info.Size(), ModTime: info.ModTime()})
+			usage.UsedBytes += info.Size()
+			return nil
+		})
+	}
+	usage.Files = len(files)
+	if cat.QuotaBytes > 0 {
+		usage.Percent = float64(usage.UsedBytes) * 100 / float64(cat.QuotaBytes)
+	}
+	return usage, files
+}
+
+// planStoragePrune 按修改时间从旧到新挑选待删文件, 直到用量回落到配额以内; 晚于 cutoff 的文件跳过。
+func planStoragePrune(files []storageFile, used, quota int64, cutoff time.Time) []storageFile {
+	if quota <= 0 || used <= quota {
+		return nil
+	}
+	sorted := append([]storageFile(nil), files...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ModTime.Before(sorted[j].ModTime) })
+	var plan []storageFile
+	for _, f := range sorted {
+		if used <= quota {
+			break
+		}
+		if f.ModTime.After(cutoff) {
+			continue
+		}
+		plan = append(plan, f)
+		used -= f.Size
+	}
+	return plan
+}
+
+// startStorageJanitorLoop 周期执行配额清理 (STORAGE_JANITOR_INTERVAL_MIN = 0 时关闭)。
+func (s *Server) startStorageJanitorLoop(ctx context.Context) {
+	if s.cfg == nil || s.cfg.StorageJanitorIntervalMin <= 0 {
+		return
+	}
+	interval := time.Duration(s.cfg.StorageJanitorIntervalMin) * time.Minute
+	util.SafeGo(func() {
+		timer := time.NewTimer(storageJanitorInitialDelay)
+		defer timer.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+			}
+			if _, err := s.runStorageJanitor(ctx, time.Now(), false); err != nil {
+				logger.Warn("storage janitor: run failed", logger.FieldError, err)
+			}
+			timer.Reset(interval)
+		}
+	})
+}
+
+// runStorageJanitor 执行一轮统计与清理 (dryRun 只预警不删除); 已有清理在进行时返回错误。
+func (s *Server) runStorageJanitor(ctx context.Context, now time.Time, dryRun bool) (*storageJanitorResult, error) {
+	const op = "Server.runStorageJanitor"
+	if !s.storageJanitor.runMu.TryLock() {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "storage cleanup already running")
+	}
+	defer s.storageJanitor.runMu.Unlock()
+	ctx, cancel := context.WithTimeout(ctx, storageJanitorRunTimeout)
+	defer cancel()
+
+	warnPercent, minAge := s.storageJanitorSettings()
+	result := &storageJanitorResult{StartedAt: now, DryRun: dryRun}
+	for _, cat := range s.storageCategories() {
+		if err := ctx.Err(); err != nil {
+			return nil, apperrors.Wrap(err, op, "storage cleanup interrupted")
+		}
+		usage, files := scanStorageCategory(cat)
+		plan := planStoragePrune(files, usage.UsedBytes, cat.QuotaBytes, now.Add(-minAge))
+		catResult := storageCategoryResult{storageUsage: usage, Planned: len(plan)}
+		if cat.QuotaBytes > 0 && (len(plan) > 0 || usage.Percent >= float64(warnPercent)) {
+			s.warnStorageQuota(usage, plan, dryRun)
+			catResult.Warned = true
+		}
+		if !dryRun && len(plan) > 0 {
+			s.pruneStorageFiles(ctx, cat, plan, &catResult)
+		}
+		result.Categories = append(result.Categories, catResult)
+	}
+	result.FinishedAt = time.Now()
+	s.storageJanitor.record(result)
+	return result, nil
+}
+
+// warnStorageQuota 删除前预警: 日志 + storage/quotaWarning 通知。
+func (s *Server) warnStorageQuota(usage storageUsage, plan []storageFile, dryRun bool) {
+	var pruneBytes int64
+	sample := make([]string, 0, min(len(plan), storageWarningSampleSize))
+	for i, f := range plan {
+		pruneBytes += f.Size
+		if i < storageWarningSampleSize {
+			sample = append(sample, f.Path)
+		}
+	}
+	logger.Warn("storage janitor: quota threshold reached",
+		logger.FieldName, usage.Category,
+		logger.FieldBytes, usage.UsedBytes,
+		logger.FieldMax, usage.QuotaBytes,
+		logger.FieldCount, len(plan),
+		"prune_bytes", pruneBytes,
+		"dry_run", dryRun,
+	)
+	s.Notify("storage/quotaWarning", map[string]any{
+		"category":   usage.Category,
+		"usedBytes":  usage.UsedBytes,
+		"quotaBytes": usage.QuotaBytes,
+		"percent":    usage.Percent,
+		"pruneFiles": len(plan),
+		"pruneBytes": pruneBytes,
+		"sample":     sample,
+		"dryRun":     dryRun,
+	})
+}
+
+// pruneStorageFiles 删除计划中的文件并清理空目录 (受管根目录本身保留, Ephemeral 根除外)。
+func (s *Server) pruneStorageFiles(ctx context.Context, cat storageCategory, plan []storageFile, out *storageCategoryResult) {
+	dirs := make(map[string]struct{})
+	for _, f := range plan {
+		if ctx.Err() != nil {
+			break
+		}
+		if err := os.Remove(f.Path); err != nil && !os.IsNotExist(err) {
+			if len(out.Errors) < storageMaxRecordedErrors {
+				out.Errors = append(out.Errors, err.Error())
+			}
+			continue
+		}
+		out.Pruned++
+		out.PrunedBytes += f.Size
+		dirs[filepath.Dir(f.Path)] = struct{}{}
+	}
+	out.UsedBytes -= out.PrunedBytes
+	out.Files -= out.Pruned
+	if out.QuotaBytes > 0 {
+		out.Percent = float64(out.UsedBytes) * 100 / float64(out.QuotaBytes)
+	}
+	for dir := range dirs {
+		removeEmptyStorageDirs(cat.Roots, dir)
+	}
+	logger.Info("storage janitor: pruned",
+		logger.FieldName, cat.Name,
+		logger.FieldCount, out.Pruned,
+		logger.FieldBytes, out.PrunedBytes,
+	)
+}
+
+// removeEmptyStorageDirs 自 dir 向上删除空目录, 止于所属受管根。
+func removeEmptyStorageDirs(roots []storageRoot, dir string) {
+	for _, root := range roots {
+		within, err := pathWithinRoot(root.Path, dir)
+		if err != nil || !within {
+			continue
+		}
+		for dir != root.Path {
+			if os.Remove(dir) != nil {
+				return
+			}
+			dir = filepath.Dir(dir)
+		}
+		if root.Ephemeral {
+			_ = os.Remove(root.Path)
+		}
+		return
+	}
+}
+
+// ========================================
+// JSON-RPC
+// ========================================
+
+// storageUsage 各类目录当前用量 (JSON-RPC: storage/usage)。
+func (s *Server) storageUsage(_ context.Context, _ json.RawMessage) (any, error) {
+	cats := s.storageCategories()
+	usages := make([]storageUsage, 0, len(cats))
+	for _, cat := range cats {
+		usage, _ := scanStorageCategory(cat)
+		usages = append(usages, usage)
+	}
+	warnPercent, minAge := s.storageJanitorSettings()
+	result := map[string]any{
+		"categories":  usages,
+		"warnPercent": warnPercent,
+		"minAgeMin":   int(minAge / time.Minute),
+		"lastRun":     s.storageJanitor.lastResult(),
+	}
+	if s.cfg != nil {
+		result["intervalMin"] = s.cfg.StorageJanitorIntervalMin
+	}
+	return result, nil
+}
+
+type storageCleanupParams struct {
+	DryRun bool `json:"dryRun,omitempty"` // 只预警并返回待删统计, 不删除
+}
+
+// storageCleanupTyped 立即执行一轮配额清理 (JSON-RPC: storage/cleanup)。
+func (s *Server) storageCleanupTyped(ctx context.Context, p storageCleanupParams) (any, error) {
+	return s.runStorageJanitor(ctx, time.Now(), p.DryRun)
+}
@@ -0,0 +1,108 @@
+package apiserver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/config"
+)
+
+func TestPlanStoragePrune_OldestFirstSkipsRecent(t *testing.T) {
+	now := time.Now()
+	files := []storageFile{
+		{Path: "new", Size: 40, ModTime: now},
+		{Path: "old", Size: 30, ModTime: now.Add(-3 * time.Hour)},
+		{Path: "mid", Size: 30, ModTime: now.Add(-2 * time.Hour)},
+	}
+	plan := planStoragePrune(files, 100, 50, now.Add(-time.Hour))
+	if len(plan) != 2 || plan[0].Path != "old" || plan[1].Path != "mid" {
+		t.Fatalf("plan = %+v", plan)
+	}
+	if plan := planStoragePrune(files, 100, 0, now); plan != nil {
+		t.Fatalf("unlimited quota plan = %+v", plan)
+	}
+}
+
+func TestRunStorageJanitor_PrunesOverQuota(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "2026", "01")
+	if err := os.MkdirAll(nested, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	write := func(path string, size int, mtime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(path, make([]byte, size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(nested, "rollout-a.jsonl"), 600, old)
+	write(filepath.Join(root, "rollout-b.jsonl"), 600, old.Add(time.Hour))
+	write(filepath.Join(root, "rollout-live.jsonl"), 600, time.Now())
+
+	cat := storageCategory{Name: storageCategoryRollouts, QuotaBytes: 1000, Roots: []storageRoot{{Path: root}}}
+	usage, files := scanStorageCategory(cat)
+	if usage.UsedBytes != 1800 || usage.Files != 3 {
+		t.Fatalf("usage = %+v", usage)
+	}
+	plan := planStoragePrune(files, usage.UsedBytes, cat.QuotaBytes, time.Now().Add(-time.Hour))
+	if len(plan) != 2 {
+		t.Fatalf("plan = %+v", plan)
+	}
+
+	srv := &Server{}
+	result := storageCategoryResult{storageUsage: usage}
+	srv.pruneStorageFiles(context.Background(), cat, plan, &result)
+	if result.Pruned != 2 || result.UsedBytes != 600 {
+		t.Fatalf("result = %+v", result)
+	}
+	if _, err := os.Stat(filepath.Join(root, "2026")); !os.IsNotExist(err) {
+		t.Fatalf("empty dirs should be removed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "rollout-live.jsonl")); err != nil {
+		t.Fatalf("recent file must survive: %v", err)
+	}
+	if _, err := os.Stat(root); err != nil {
+		t.Fatalf("managed root must survive: %v", err)
+	}
+}
+
+func TestRunStorageJanitor_DryRunKeepsFiles(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	sessions := filepath.Join(home, ".codex", "sessions")
+	if err := os.MkdirAll(sessions, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(sessions, "rollout.jsonl")
+	if err := os.WriteFile(file, make([]byte, 2<<20), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(file, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &Server{cfg: &config.Config{StorageQuotaRolloutsMB: 1, StorageWarnPercent: 90, StorageMinAgeMin: 60, InputStagingDir: t.TempDir()}}
+	result, err := srv.runStorageJanitor(context.Background(), time.Now(), true)
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	for _, cat := range result.Categories {
+		if cat.Category == storageCategoryRollouts && (!cat.Warned || cat.Planned != 1 || cat.Pruned != 0) {
+			t.Fatalf("rollouts = %+v", cat)
+		}
+	}
+	if _, err := os.Stat(file); err != nil {
+		t.Fatalf("dry run must not delete: %v", err)
+	}
+	if srv.storageJanitor.lastResult() == nil {
+		t.Fatal("last run not recorded")
+	}
+}
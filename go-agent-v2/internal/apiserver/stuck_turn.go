@@ -0,0 +1,297 @@
+// stuck_turn.go — 卡住 turn 的自动处置链 (stall remediation)。
+//
+// turn 进行中超过 stallThreshold 无事件时, 按 STALL_REMEDIATION 配置的顺序逐步执行处置动作,
+// 每一步之后等待 STALL_REMEDIATION_GRACE_SEC, 期间仍无事件才执行下一步:
+//   - probe:     向 codex 发送 /status 探测 (有响应会刷新事件心跳)
+//   - escalate:  告警 (agent_stuck 通知 + UI stall_warning)
+//   - interrupt: 发送 /interrupt, 失败或进程不存在时强制结束 turn
+//   - restart:   结束 turn 并停止/重新拉起 codex 进程 (恢复历史会话)
+//
+// 处置链按 turn 单调推进, 事件恢复后再次卡住从下一步继续; 链执行完毕不再处置。
+// 每一步的决策 (动作/静默时长/结果) 以 stall_remediation span 写入 task_traces (trace_id = turnId),
+// turn 结束时 turn span 的 metadata.stallActions 记录完整处置链。
+package apiserver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/runner"
+	"github.com/multi-agent/go-agent-v2/internal/store"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+	"github.com/multi-agent/go-agent-v2/pkg/util"
+)
+
+const (
+	stallActionProbe     = "probe"
+	stallActionEscalate  = "escalate"
+	stallActionInterrupt = "interrupt"
+	stallActionRestart   = "restart"
+
+	stallRemediationSpanName     = "stall_remediation"
+	defaultStallRemediationGrace = 30 * time.Second
+)
+
+// stallActionLabels UI 告警中使用的动作描述。
+var stallActionLabels = map[string]string{
+	stallActionProbe:     "发送 /status 探测",
+	stallActionEscalate:  "再次告警",
+	stallActionInterrupt: "自动中断",
+	stallActionRestart:   "重启进程",
+}
+
+// parseStallRemediation 解析逗号分隔的处置链 (忽略未知动作, 为空时使用默认 escalate,interrupt)。
+func parseStallRemediation(spec string) []string {
+	var plan []string
+	for _, part := range strings.Split(spec, ",") {
+		action := strings.ToLower(strings.TrimSpace(part))
+		if action == "" {
+			continue
+		}
+		if _, ok := stallActionLabels[action]; !ok {
+			logger.Warn("turn tracker: unknown stall remediation action ignored", "action", action)
+			continue
+		}
+		plan = append(plan, action)
+	}
+	if len(plan) == 0 {
+		return []string{stallActionEscalate, stallActionInterrupt}
+	}
+	return plan
+}
+
+// applyStallRemediationConfig 从 Config 同步处置链与步间等待时间。
+// Must be called with s.turnMu held (或在 Server 初始化期间)。
+func (s *Server) applyStallRemediationConfig() {
+	if s.cfg == nil {
+		return
+	}
+	s.stallPlan = parseStallRemediation(s.cfg.StallRemediation)
+	s.stallGrace = time.Duration(s.cfg.StallRemediationGraceSec) * time.Second
+}
+
+// stallDecision 一次处置决策 (写入 stall_remediation span)。
+type stallDecision struct {
+	ThreadID  string
+	TurnID    string
+	Step      int // 从 1 开始
+	Action    string
+	Next      string // 下一步动作, 链结束时为空
+	Silent    time.Duration
+	Threshold time.Duration
+	Result    string // ok / failed / no_process / skipped
+	Detail    string
+	StartedAt time.Time
+}
+
+// advanceStallRemediation 执行处置链的下一步。
+// Must be called with s.turnMu held; releases it.
+func (s *Server) advanceStallRemediation(turn *trackedTurn, threadID, turnID string, silent, threshold time.Duration) {
+	plan := s.stallPlan
+	if len(plan) == 0 {
+		plan = parseStallRemediation("")
+	}
+	if turn.stallStep >= len(plan) {
+		s.turnMu.Unlock()
+		return
+	}
+	grace := s.stallGrace
+	if grace <= 0 {
+		grace = defaultStallRemediationGrace
+	}
+
+	decision := stallDecision{
+		ThreadID:  threadID,
+		TurnID:    turnID,
+		Step:      turn.stallStep + 1,
+		Action:    plan[turn.stallStep],
+		Silent:    silent,
+		Threshold: threshold,
+		StartedAt: time.Now(),
+	}
+	turn.stallStep++
+	turn.stallGraceStarted = true
+	turn.stallActions = append(turn.stallActions, decision.Action)
+	if decision.Action == stallActionInterrupt || decision.Action == stallActionRestart {
+		turn.stallAutoInterrupted = true
+	}
+	if turn.stallStep < len(plan) {
+		decision.Next = plan[turn.stallStep]
+		turn.stallTimer = time.AfterFunc(grace, func() {
+			s.checkTurnStall(threadID, turnID)
+		})
+	}
+	s.turnMu.Unlock()
+
+	logger.Warn("turn tracker: thinking stall detected — running remediation",
+		logger.FieldThreadID, threadID,
+		logger.FieldTurnID, turnID,
+		"action", decision.Action,
+		"step", decision.Step,
+		"next", decision.Next,
+		"silent_ms", silent.Milliseconds(),
+		"threshold_ms", threshold.Milliseconds(),
+		"grace_period_ms", grace.Milliseconds(),
+	)
+	util.SafeGo(func() {
+		decision.Result, decision.Detail = s.runStallAction(decision, grace)
+		s.recordStallDecision(decision)
+	})
+}
+
+// runStallAction 执行单个处置动作, 返回结果与说明。
+func (s *Server) runStallAction(d stallDecision, grace time.Duration) (string, string) {
+	switch d.Action {
+	case stallActionProbe:
+		proc := s.stallProcess(d.ThreadID)
+		if proc == nil {
+			return "no_process", ""
+		}
+		if err := proc.Client.SendCommand("/status", ""); err != nil {
+			return "failed", err.Error()
+		}
+		return "ok", ""
+	case stallActionEscalate:
+		s.notifyAgentStuck(d.ThreadID, d.TurnID, d.Silent)
+		if s.uiRuntime != nil {
+			msg := fmt.Sprintf("思考已 %ds 未响应", int(d.Silent.Seconds()))
+			if d.Next != "" {
+				msg += fmt.Sprintf("，将在 %ds 后%s", int(grace.Seconds()), stallActionLabels[d.Next])
+			}
+			s.uiRuntime.PushAlert(d.ThreadID, "stall_warning", msg)
+		}
+		return "ok", ""
+	case stallActionInterrupt:
+		return s.stallInterrupt(d)
+	case stallActionRestart:
+		return s.stallRestart(d)
+	}
+	return "skipped", "unknown action"
+}
+
+// stallInterrupt 发送 /interrupt (与 turn/interrupt 相同); 失败或进程不存在时强制结束 tracker。
+func (s *Server) stallInterrupt(d stallDecision) (string, string) {
+	if s.uiRuntime != nil {
+		s.uiRuntime.PushAlert(d.ThreadID, "stall",
+			fmt.Sprintf("思考超时 %ds 未响应，自动中断", int(d.Silent.Seconds())))
+	}
+	s.markTrackedTurnInterruptRequested(d.ThreadID)
+	if cancelled := s.cancelCodeRuns(d.ThreadID); cancelled > 0 {
+		logger.Info("turn tracker: cancelled running code_run executions",
+			logger.FieldThreadID, d.ThreadID,
+			logger.FieldTurnID, d.TurnID,
+			"cancelled_runs", cancelled,
+		)
+	}
+	result, detail := "no_process", ""
+	if proc := s.stallProcess(d.ThreadID); proc != nil {
+		if err := proc.Client.SendCommand("/interrupt", ""); err != nil {
+			logger.Warn("turn tracker: stall auto-interrupt failed",
+				logger.FieldThreadID, d.ThreadID,
+				logger.FieldTurnID, d.TurnID,
+				logger.FieldError, err,
+			)
+			result, detail = "failed", err.Error()
+		} else {
+			return "ok", ""
+		}
+	}
+	// Fallback: if /interrupt failed or process is gone, force-complete the tracker.
+	if completion, ok := s.completeTrackedTurnByID(d.ThreadID, d.TurnID, "failed", "thinking_stall_timeout"); ok {
+		s.Notify("turn/completed", completion)
+		detail = strings.TrimSpace(detail + " (turn force-completed)")
+	}
+	return result, detail
+}
+
+// stallRestart 结束卡住的 turn, 停止并以原工作目录重新拉起 codex 进程。紧急停止期间跳过。
+func (s *Server) stallRestart(d stallDecision) (string, string) {
+	if _, locked := s.emergency.current(); locked {
+		return "skipped", "emergency stop active"
+	}
+	if s.uiRuntime != nil {
+		s.uiRuntime.PushAlert(d.ThreadID, "stall",
+			fmt.Sprintf("思考超时 %ds 未响应，重启进程", int(d.Silent.Seconds())))
+	}
+	s.markTrackedTurnInterruptRequested(d.ThreadID)
+	_ = s.cancelCodeRuns(d.ThreadID)
+	if completion, ok := s.completeTrackedTurnByID(d.ThreadID, d.TurnID, "failed", "thinking_stall_restart"); ok {
+		s.Notify("turn/completed", completion)
+	}
+	if s.mgr == nil {
+		return "no_process", ""
+	}
+	_ = s.mgr.Stop(d.ThreadID)
+	ctx, cancel := context.WithTimeout(context.Background(), agentHealthRestartWait)
+	defer cancel()
+	if _, err := s.ensureThreadReadyForTurn(ctx, d.ThreadID, s.getAgentWorkDir(d.ThreadID)); err != nil {
+		logger.Error("turn tracker: stall restart failed",
+			logger.FieldThreadID, d.ThreadID, logger.FieldTurnID, d.TurnID, logger.FieldError, err)
+		return "failed", err.Error()
+	}
+	return "ok", ""
+}
+
+// stallProcess 返回线程对应的 codex 进程 (不存在时为 nil)。
+func (s *Server) stallProcess(threadID string) *runner.AgentProcess {
+	if s.mgr == nil {
+		return nil
+	}
+	return s.mgr.Get(threadID)
+}
+
+// stallDecisionTrace 把处置决策转换为 task_traces span (挂在 turn span 之下)。
+func stallDecisionTrace(d stallDecision, finishedAt time.Time) *store.TaskTrace {
+	parent := d.TurnID
+	status := "completed"
+	if d.Result != "ok" {
+		status = d.Result
+	}
+	metadata := map[string]any{
+		"action":      d.Action,
+		"step":        d.Step,
+		"next":        d.Next,
+		"result":      d.Result,
+		"silentMs":    d.Silent.Milliseconds(),
+		"thresholdMs": d.Threshold.Milliseconds(),
+	}
+	trace := &store.TaskTrace{
+		TraceID:      d.TurnID,
+		SpanID:       fmt.Sprintf("%s:stall:%d", d.TurnID, d.Step),
+		ParentSpanID: &parent,
+		SpanName:     stallRemediationSpanName,
+		Component:    d.ThreadID,
+		Status:       status,
+		Metadata:     metadata,
+		StartedAt:    d.StartedAt,
+		FinishedAt:   &finishedAt,
+		DurationMS:   int(finishedAt.Sub(d.StartedAt).Milliseconds()),
+	}
+	if d.Result == "failed" {
+		trace.ErrorText = d.Detail
+	} else if d.Detail != "" {
+		metadata["detail"] = d.Detail
+	}
+	return trace
+}
+
+// recordStallDecision 写入处置决策 span (经离线 WAL)。
+func (s *Server) recordStallDecision(d stallDecision) {
+	logger.Info("turn tracker: stall remediation step finished",
+		logger.FieldThreadID, d.ThreadID,
+		logger.FieldTurnID, d.TurnID,
+		"action", d.Action,
+		"step", d.Step,
+		"result", d.Result,
+	)
+	if s.taskTraceStore == nil || strings.TrimSpace(d.TurnID) == "" {
+		return
+	}
+	trace := stallDecisionTrace(d, time.Now())
+	if err := s.persistDurable(context.Background(), walOp{Kind: walKindTaskTrace, Trace: trace}); err != nil {
+		logger.Warn("turn tracker: persist stall remediation trace failed",
+			logger.FieldThreadID, d.ThreadID, logger.FieldTurnID, d.TurnID, logger.FieldError, err)
+	}
+}
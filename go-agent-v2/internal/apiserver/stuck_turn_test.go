@@ -0,0 +1,74 @@
+package apiserver
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseStallRemediation(t *testing.T) {
+	if got := parseStallRemediation(" Probe, bogus ,restart,,escalate"); !reflect.DeepEqual(got, []string{"probe", "restart", "escalate"}) {
+		t.Fatalf("parse = %v", got)
+	}
+	if got := parseStallRemediation("bogus"); !reflect.DeepEqual(got, []string{"escalate", "interrupt"}) {
+		t.Fatalf("fallback = %v", got)
+	}
+}
+
+func TestCheckTurnStall_RunsRemediationChainInOrder(t *testing.T) {
+	srv := &Server{
+		activeTurns:    make(map[string]*trackedTurn),
+		stallThreshold: 10 * time.Millisecond,
+		stallPlan:      []string{stallActionProbe, stallActionEscalate, stallActionInterrupt},
+		stallGrace:     20 * time.Millisecond,
+	}
+	srv.activeTurns["thread-chain"] = &trackedTurn{
+		ID:          "turn-chain",
+		ThreadID:    "thread-chain",
+		StartedAt:   time.Now().Add(-time.Minute),
+		LastEventAt: time.Now().Add(-time.Minute),
+		done:        make(chan string, 1),
+	}
+
+	srv.checkTurnStall("thread-chain", "turn-chain")
+	srv.turnMu.Lock()
+	turn := srv.activeTurns["thread-chain"]
+	if turn == nil || !reflect.DeepEqual(turn.stallActions, []string{"probe"}) || turn.stallAutoInterrupted {
+		srv.turnMu.Unlock()
+		t.Fatalf("after first check: %#v", turn)
+	}
+	srv.turnMu.Unlock()
+
+	// 无进程时 interrupt 回退为强制结束 turn。
+	select {
+	case status := <-turn.done:
+		if status != "failed" {
+			t.Fatalf("final status = %q", status)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("remediation chain did not finish the turn")
+	}
+	srv.turnMu.Lock()
+	defer srv.turnMu.Unlock()
+	if !reflect.DeepEqual(turn.stallActions, []string{"probe", "escalate", "interrupt"}) {
+		t.Fatalf("actions = %v", turn.stallActions)
+	}
+	if _, ok := srv.activeTurns["thread-chain"]; ok {
+		t.Fatal("turn should be removed after interrupt fallback")
+	}
+}
+
+func TestStallDecisionTrace(t *testing.T) {
+	started := time.Now().Add(-time.Second)
+	trace := stallDecisionTrace(stallDecision{
+		ThreadID: "thread-1", TurnID: "turn-1", Step: 2, Action: stallActionInterrupt,
+		Silent: 9 * time.Minute, Threshold: 8 * time.Minute, Result: "failed", Detail: "broken pipe", StartedAt: started,
+	}, started.Add(time.Second))
+	if trace.TraceID != "turn-1" || trace.SpanID != "turn-1:stall:2" || *trace.ParentSpanID != "turn-1" || trace.SpanName != stallRemediationSpanName {
+		t.Fatalf("trace ids = %#v", trace)
+	}
+	meta := trace.Metadata.(map[string]any)
+	if trace.Status != "failed" || trace.ErrorText != "broken pipe" || meta["action"] != "interrupt" || meta["silentMs"] != int64(540000) {
+		t.Fatalf("trace = %#v meta = %#v", trace, meta)
+	}
+}
@@ -0,0 +1,358 @@
+// terminal_attach.go — 后台终端交互: terminal/attach · terminal/stdin · terminal/detach · terminal/list。
+//
+// codex 后台终端等待输入时发出 item/commandExecution/terminalInteraction (含 processId / itemId),
+// 这里按 processId 登记终端并缓存最近输出 (item/commandExecution/outputDelta 按 itemId 归属);
+// 客户端 attach 后收到缓存输出与后续 terminal/output 通知, 通过 terminal/stdin 回答交互提示
+// (如 ssh 口令), stdin 经 codex command/exec/write 写入对应进程。
+package apiserver
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+const (
+	terminalOutputBufferBytes = 64 << 10
+	maxTrackedTerminals       = 128
+	maxTerminalStdinBytes     = 64 << 10
+)
+
+// terminalStdinWriter 支持写后台终端 stdin 的 codex 客户端 (app-server 传输)。
+type terminalStdinWriter interface {
+	WriteTerminalStdin(processID string, data []byte, closeStdin bool) error
+}
+
+// backgroundTerminal 已知的后台终端。
+type backgroundTerminal struct {
+	ProcessID   string    `json:"processId"`
+	ThreadID    string    `json:"threadId"`
+	ItemID      string    `json:"itemId,omitempty"`
+	Command     string    `json:"command,omitempty"`
+	Waiting     bool      `json:"waiting"` // 正在等待输入
+	LastStdin   string    `json:"lastStdin,omitempty"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+	Attachments int       `json:"attachments"`
+
+	output []byte
+}
+
+// terminalAttachment 客户端附着。
+type terminalAttachment struct {
+	ID        string    `json:"attachmentId"`
+	ProcessID string    `json:"processId"`
+	ThreadID  string    `json:"threadId"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// terminalHub 后台终端登记 + 附着 (零值可用)。
+type terminalHub struct {
+	mu          sync.Mutex
+	seq         int64
+	terminals   map[string]*backgroundTerminal // processId →
+	byItem      map[string]string              // itemId → processId
+	attachments map[string]*terminalAttachment // attachmentId →
+}
+
+func (h *terminalHub) ensureLocked() {
+	if h.terminals == nil {
+		h.terminals = make(map[string]*backgroundTerminal)
+		h.byItem = make(map[string]string)
+		h.attachments = make(map[string]*terminalAttachment)
+	}
+}
+
+// evictLocked 超出上限时淘汰最久未更新且无人附着的终端。
+func (h *terminalHub) evictLocked() {
+	if len(h.terminals) <= maxTrackedTerminals {
+		return
+	}
+	ids := make([]string, 0, len(h.terminals))
+	for id, term := range h.terminals {
+		if term.Attachments == 0 {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return h.terminals[ids[i]].UpdatedAt.Before(h.terminals[ids[j]].UpdatedAt) })
+	for _, id := range ids {
+		if len(h.terminals) <= maxTrackedTerminals {
+			return
+		}
+		if itemID := h.terminals[id].ItemID; itemID != "" {
+			delete(h.byItem, itemID)
+		}
+		delete(h.terminals, id)
+	}
+}
+
+// observeInteraction 登记终端交互事件 (waiting = 进程正在等待输入)。
+func (h *terminalHub) observeInteraction(threadID, processID, itemID, command, stdin string, waiting bool, now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.ensureLocked()
+	term := h.terminals[processID]
+	if term == nil {
+		term = &backgroundTerminal{ProcessID: processID}
+		h.terminals[processID] = term
+	}
+	term.ThreadID = threadID
+	if itemID != "" {
+		term.ItemID = itemID
+		h.byItem[itemID] = processID
+	}
+	if command != "" {
+		term.Command = command
+	}
+	if stdin != "" {
+		term.LastStdin = stdin
+	}
+	term.Waiting = waiting
+	term.UpdatedAt = now
+	h.evictLocked()
+}
+
+// appendOutput 追加输出, 返回终端 processId 与是否有客户端附着。
+func (h *terminalHub) appendOutput(processID, itemID, chunk string, now time.Time) (string, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.terminals == nil || chunk == "" {
+		return "", false
+	}
+	if processID == "" {
+		processID = h.byItem[itemID]
+	}
+	term := h.terminals[processID]
+	if term == nil {
+		return "", false
+	}
+	term.output = append(term.output, chunk...)
+	if over := len(term.output) - terminalOutputBufferBytes; over > 0 {
+		term.output = append([]byte(nil), term.output[over:]...)
+	}
+	term.UpdatedAt = now
+	return processID, term.Attachments > 0
+}
+
+func (h *terminalHub) attach(threadID, processID string, now time.Time) (*terminalAttachment, backgroundTerminal, string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.ensureLocked()
+	term := h.terminals[processID]
+	if term == nil || (threadID != "" && term.ThreadID != threadID) {
+		return nil, backgroundTerminal{}, "", apperrors.Newf("Server.terminalAttach", "background terminal %s not found", processID)
+	}
+	h.seq++
+	att := &terminalAttachment{
+		ID:        fmt.Sprintf("term-%d-%d", now.UnixMilli(), h.seq),
+		ProcessID: processID,
+		ThreadID:  term.ThreadID,
+		CreatedAt: now,
+	}
+	h.attachments[att.ID] = att
+	term.Attachments++
+	return att, *term, string(term.output), nil
+}
+
+func (h *terminalHub) detach(attachmentID string) (*terminalAttachment, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	att := h.attachments[attachmentID]
+	if att == nil {
+		return nil, false
+	}
+	delete(h.attachments, attachmentID)
+	if term := h.terminals[att.ProcessID]; term != nil && term.Attachments > 0 {
+		term.Attachments--
+	}
+	return att, true
+}
+
+func (h *terminalHub) attachment(attachmentID string) (terminalAttachment, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	att := h.attachments[attachmentID]
+	if att == nil {
+		return terminalAttachment{}, false
+	}
+	return *att, true
+}
+
+func (h *terminalHub) markWritten(processID string, now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if term := h.terminals[processID]; term != nil {
+		term.Waiting = false
+		term.UpdatedAt = now
+	}
+}
+
+func (h *terminalHub) list(threadID string) []backgroundTerminal {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make([]backgroundTerminal, 0, len(h.terminals))
+	for _, term := range h.terminals {
+		if threadID == "" || term.ThreadID == threadID {
+			out = append(out, *term)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].UpdatedAt.After(out[j].UpdatedAt) })
+	return out
+}
+
+// ========================================
+// 事件接入
+// ========================================
+
+// decodeTerminalChunk 输出增量: v2 为文本 delta, 旧版 exec_command_output_delta 为 base64 chunk。
+func decodeTerminalChunk(payload map[string]any) string {
+	if delta := extractFirstString(payload, "delta", "output"); delta != "" {
+		return delta
+	}
+	chunk := extractFirstString(payload, "chunk")
+	if decoded, err := base64.StdEncoding.DecodeString(chunk); err == nil {
+		return string(decoded)
+	}
+	return chunk
+}
+
+// observeTerminalEvent 跟踪终端交互与输出, 有客户端附着时推送 terminal/output。
+func (s *Server) observeTerminalEvent(threadID, eventType, method string, payload map[string]any) {
+	now := time.Now()
+	processID := extractFirstString(payload, "processId", "process_id")
+	itemID := extractFirstString(payload, "itemId", "item_id", "callId", "call_id")
+	switch {
+	case method == "item/commandExecution/terminalInteraction":
+		if processID == "" {
+			return
+		}
+		stdin := extractFirstString(payload, "stdin")
+		command := extractFirstString(payload, "command")
+		s.terminals.observeInteraction(threadID, processID, itemID, command, stdin, isTerminalAwaitingInput(payload), now)
+	case method == "item/commandExecution/outputDelta" || eventType == "exec_command_output_delta":
+		chunk := decodeTerminalChunk(payload)
+		if id, attached := s.terminals.appendOutput(processID, itemID, chunk, now); attached {
+			s.Notify("terminal/output", map[string]any{"threadId": threadID, "processId": id, "data": chunk})
+		}
+	}
+}
+
+// isTerminalAwaitingInput 交互事件 stdin 为空表示进程在等待输入 (与 UI 等待浮层判定一致)。
+func isTerminalAwaitingInput(payload map[string]any) bool {
+	switch v := payload["stdin"].(type) {
+	case nil:
+		return true
+	case string:
+		return strings.TrimSpace(v) == ""
+	default:
+		return false
+	}
+}
+
+// ========================================
+// JSON-RPC
+// ========================================
+
+type terminalAttachParams struct {
+	ThreadID  string `json:"threadId,omitempty"`
+	ProcessID string `json:"processId"`
+}
+
+func (s *Server) terminalAttachTyped(_ context.Context, p terminalAttachParams) (any, error) {
+	processID := strings.TrimSpace(p.ProcessID)
+	if processID == "" {
+		return nil, apperrors.New("Server.terminalAttach", "processId is required")
+	}
+	att, term, output, err := s.terminals.attach(strings.TrimSpace(p.ThreadID), processID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("terminal/attach: attached",
+		logger.FieldThreadID, att.ThreadID,
+		"process_id", processID,
+		"attachment_id", att.ID,
+	)
+	return map[string]any{"attachment": att, "terminal": term, "output": output}, nil
+}
+
+type terminalStdinParams struct {
+	AttachmentID string `json:"attachmentId"`
+	Data         string `json:"data"`
+	Newline      bool   `json:"newline,omitempty"`    // 追加换行 (回答提示时常用)
+	CloseStdin   bool   `json:"closeStdin,omitempty"` // 写入后关闭 stdin
+}
+
+func (s *Server) terminalStdinTyped(_ context.Context, p terminalStdinParams) (any, error) {
+	if err := s.frozenError("Server.terminalStdin"); err != nil {
+		return nil, err
+	}
+	att, ok := s.terminals.attachment(strings.TrimSpace(p.AttachmentID))
+	if !ok {
+		return nil, apperrors.Newf("Server.terminalStdin", "attachment %s not found", p.AttachmentID)
+	}
+	data := p.Data
+	if p.Newline {
+		data += "\n"
+	}
+	if len(data) > maxTerminalStdinBytes {
+		return nil, apperrors.Newf("Server.terminalStdin", "stdin exceeds %d bytes", maxTerminalStdinBytes)
+	}
+	if data == "" && !p.CloseStdin {
+		return nil, apperrors.New("Server.terminalStdin", "data is required")
+	}
+	if s.mgr == nil {
+		return nil, apperrors.New("Server.terminalStdin", "agent manager unavailable")
+	}
+	proc := s.mgr.Get(att.ThreadID)
+	if proc == nil {
+		return nil, apperrors.Newf("Server.terminalStdin", "thread %s not running", att.ThreadID)
+	}
+	writer, ok := proc.Client.(terminalStdinWriter)
+	if !ok {
+		return nil, apperrors.Newf("Server.terminalStdin", "thread %s transport does not support terminal stdin", att.ThreadID)
+	}
+	if err := writer.WriteTerminalStdin(att.ProcessID, []byte(data), p.CloseStdin); err != nil {
+		return nil, apperrors.Wrap(err, "Server.terminalStdin", "write stdin")
+	}
+	s.terminals.markWritten(att.ProcessID, time.Now())
+	s.touchTrackedTurnLastEvent(att.ThreadID)
+	// 不记录 stdin 内容 (可能是口令)
+	logger.Info("terminal/stdin: written",
+		logger.FieldThreadID, att.ThreadID,
+		"process_id", att.ProcessID,
+		logger.FieldBytes, len(data),
+		"close_stdin", p.CloseStdin,
+	)
+	return map[string]any{"ok": true, "bytes": len(data)}, nil
+}
+
+type terminalDetachParams struct {
+	AttachmentID string `json:"attachmentId"`
+}
+
+func (s *Server) terminalDetachTyped(_ context.Context, p terminalDetachParams) (any, error) {
+	att, ok := s.terminals.detach(strings.TrimSpace(p.AttachmentID))
+	if !ok {
+		return nil, apperrors.Newf("Server.terminalDetach", "attachment %s not found", p.AttachmentID)
+	}
+	return map[string]any{"ok": true, "processId": att.ProcessID}, nil
+}
+
+func (s *Server) terminalList(_ context.Context, params json.RawMessage) (any, error) {
+	var p struct {
+		ThreadID string `json:"threadId"`
+	}
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, apperrors.Wrap(err, "Server.terminalList", "invalid params")
+		}
+	}
+	return map[string]any{"terminals": s.terminals.list(strings.TrimSpace(p.ThreadID))}, nil
+}
@@ -0,0 +1,63 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+)
+
+func TestTerminalAttachStreamsOutputAndDetaches(t *testing.T) {
+	srv := &Server{}
+	var outputs []string
+	srv.SetNotifyHook(func(method string, params any) {
+		if method == "terminal/output" {
+			outputs = append(outputs, params.(map[string]any)["data"].(string))
+		}
+	})
+	ctx := context.Background()
+
+	// 未登记的终端无法附着; 登记前的输出不缓存。
+	srv.observeTerminalEvent("thread-1", "exec_command_output_delta", "item/commandExecution/outputDelta", map[string]any{"itemId": "item-1", "delta": "lost"})
+	if _, err := srv.terminalAttachTyped(ctx, terminalAttachParams{ProcessID: "p-1"}); err == nil {
+		t.Fatal("attach to unknown terminal should fail")
+	}
+
+	srv.observeTerminalEvent("thread-1", "exec_terminal_interaction", "item/commandExecution/terminalInteraction",
+		map[string]any{"processId": "p-1", "itemId": "item-1", "stdin": ""})
+	srv.observeTerminalEvent("thread-1", "exec_command_output_delta", "item/commandExecution/outputDelta", map[string]any{"itemId": "item-1", "delta": "Enter passphrase: "})
+	if len(outputs) != 0 {
+		t.Fatalf("output should not be pushed before attach: %v", outputs)
+	}
+	if _, err := srv.terminalAttachTyped(ctx, terminalAttachParams{ThreadID: "thread-2", ProcessID: "p-1"}); err == nil {
+		t.Fatal("attach with mismatched thread should fail")
+	}
+
+	resp, err := srv.terminalAttachTyped(ctx, terminalAttachParams{ThreadID: "thread-1", ProcessID: "p-1"})
+	if err != nil {
+		t.Fatalf("attach: %v", err)
+	}
+	result := resp.(map[string]any)
+	att := result["attachment"].(*terminalAttachment)
+	if result["output"] != "Enter passphrase: " || !result["terminal"].(backgroundTerminal).Waiting {
+		t.Fatalf("attach result = %+v", result)
+	}
+
+	legacy := base64.StdEncoding.EncodeToString([]byte("ok\n"))
+	srv.observeTerminalEvent("thread-1", "exec_command_output_delta", "item/commandExecution/outputDelta", map[string]any{"call_id": "item-1", "chunk": legacy})
+	if len(outputs) != 1 || outputs[0] != "ok\n" {
+		t.Fatalf("outputs = %v", outputs)
+	}
+	if _, err := srv.terminalStdinTyped(ctx, terminalStdinParams{AttachmentID: att.ID, Data: "secret", Newline: true}); err == nil {
+		t.Fatal("stdin without agent manager should fail")
+	}
+
+	if _, err := srv.terminalDetachTyped(ctx, terminalDetachParams{AttachmentID: att.ID}); err != nil {
+		t.Fatalf("detach: %v", err)
+	}
+	if _, err := srv.terminalStdinTyped(ctx, terminalStdinParams{AttachmentID: att.ID, Data: "x"}); err == nil {
+		t.Fatal("stdin after detach should fail")
+	}
+	if list := srv.terminals.list("thread-1"); len(list) != 1 || list[0].Attachments != 0 {
+		t.Fatalf("list = %+v", list)
+	}
+}
@@ -0,0 +1,410 @@
+// thread_context.go — 线程固定上下文文档 (thread/context/pin | list | unpin)。
+//
+// 固定的文档 (文件路径或内联内容) 在该线程每个 turn 提交前注入 prompt。文件类文档在每次
+// turn 前检查 mtime / 大小, 变化时重新读取, 内容与上次注入不同时在标题中标注已更新。
+// 固定列表持久化在偏好 threads.contextPins (threadId → [pin]); 无偏好管理器时保存在进程内。
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+const (
+	prefThreadContextPins = "threads.contextPins"
+
+	maxThreadContextPins    = 16
+	maxContextPinBytes      = 64 << 10  // 单个文档注入上限, 超出截断
+	maxContextPromptBytes   = 256 << 10 // 单个 turn 注入总量上限, 超出的文档只列出路径
+	maxContextPinTitleRunes = 128
+)
+
+// 文档在 thread/context/list 中的新鲜度。
+const (
+	contextPinInline  = "inline"  // 内联内容
+	contextPinFresh   = "fresh"   // 与上次注入一致
+	contextPinChanged = "changed" // 自上次注入后已修改, 下个 turn 注入新内容
+	contextPinMissing = "missing" // 文件不存在或不可读
+)
+
+// threadContextPin 一个固定的上下文文档。
+type threadContextPin struct {
+	ID         string    `json:"id"`
+	Title      string    `json:"title,omitempty"`
+	Path       string    `json:"path,omitempty"`    // 绝对路径 (文件类)
+	Content    string    `json:"content,omitempty"` // 内联内容
+	PinnedAt   time.Time `json:"pinnedAt"`
+	Hash       string    `json:"hash,omitempty"` // 上次注入时的内容哈希
+	IncludedAt time.Time `json:"includedAt,omitempty"`
+}
+
+func (p threadContextPin) label() string {
+	switch {
+	case p.Title != "":
+		return p.Title
+	case p.Path != "":
+		return p.Path
+	}
+	return p.ID
+}
+
+// contextDocument 文件内容缓存 (mtime + 大小不变时复用)。
+type contextDocument struct {
+	ModTime time.Time
+	Size    int64
+	Content string
+	Hash    string
+}
+
+// threadContextPins 固定列表写入串行化、进程内列表与文件读取缓存。
+type threadContextPins struct {
+	mu       sync.Mutex
+	byThread map[string][]threadContextPin // prefManager 为 nil 时使用
+	docs     map[string]contextDocument
+	seq      int64
+}
+
+// readLocked 按 mtime / 大小判断是否需要重新读取文件; 调用方持有 mu。
+func (c *threadContextPins) readLocked(path string) (contextDocument, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		delete(c.docs, path)
+		return contextDocument{}, err
+	}
+	if info.IsDir() {
+		return contextDocument{}, fmt.Errorf("%s is a directory", path)
+	}
+	if doc, ok := c.docs[path]; ok && doc.ModTime.Equal(info.ModTime()) && doc.Size == info.Size() {
+		return doc, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return contextDocument{}, err
+	}
+	doc := contextDocument{ModTime: info.ModTime(), Size: info.Size(), Content: string(data), Hash: contentDigest(data)}
+	if c.docs == nil {
+		c.docs = make(map[string]contextDocument)
+	}
+	c.docs[path] = doc
+	return doc, nil
+}
+
+// loadContextPinsLocked 读取全部线程的固定列表; 调用方持有 contextPins.mu。
+func (s *Server) loadContextPinsLocked(ctx context.Context) map[string][]threadContextPin {
+	out := map[string][]threadContextPin{}
+	if s.prefManager == nil {
+		for id, pins := range s.contextPins.byThread {
+			out[id] = append([]threadContextPin(nil), pins...)
+		}
+		return out
+	}
+	value, err := s.prefManager.Get(ctx, prefThreadContextPins)
+	if err != nil {
+		logger.Warn("thread context: load preference failed", logger.FieldError, err)
+		return out
+	}
+	if value == nil {
+		return out
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return out
+	}
+	if err := json.Unmarshal(raw, &out); err != nil || out == nil {
+		return map[string][]threadContextPin{}
+	}
+	return out
+}
+
+// saveThreadContextPinsLocked 写回单个线程的固定列表; 调用方持有 contextPins.mu。
+func (s *Server) saveThreadContextPinsLocked(ctx context.Context, all map[string][]threadContextPin, threadID string, pins []threadContextPin) error {
+	if len(pins) == 0 {
+		delete(all, threadID)
+	} else {
+		all[threadID] = pins
+	}
+	if s.prefManager == nil {
+		s.contextPins.byThread = all
+		return nil
+	}
+	return s.prefManager.Set(ctx, prefThreadContextPins, all)
+}
+
+// threadContextPinView thread/context/* 响应中的文档。
+type threadContextPinView struct {
+	threadContextPin
+	Kind       string    `json:"kind"` // file / inline
+	Status     string    `json:"status"`
+	Size       int       `json:"size"`
+	ModifiedAt time.Time `json:"modifiedAt,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// viewContextPinsLocked 检查各文档新鲜度; 调用方持有 contextPins.mu。
+func (s *Server) viewContextPinsLocked(pins []threadContextPin) []threadContextPinView {
+	out := make([]threadContextPinView, 0, len(pins))
+	for _, pin := range pins {
+		view := threadContextPinView{threadContextPin: pin}
+		if pin.Path == "" {
+			view.Kind = "inline"
+			view.Status = contextPinInline
+			view.Size = len(pin.Content)
+			out = append(out, view)
+			continue
+		}
+		view.Kind = "file"
+		doc, err := s.contextPins.readLocked(pin.Path)
+		switch {
+		case err != nil:
+			view.Status = contextPinMissing
+			view.Error = err.Error()
+		case doc.Hash == pin.Hash:
+			view.Status = contextPinFresh
+		default:
+			view.Status = contextPinChanged
+		}
+		view.Size = int(doc.Size)
+		view.ModifiedAt = doc.ModTime
+		out = append(out, view)
+	}
+	return out
+}
+
+// buildThreadContextPrompt 渲染线程固定的上下文文档 (每个 turn 调用, 文件变化时重新读取)。
+func (s *Server) buildThreadContextPrompt(ctx context.Context, threadID string) string {
+	s.contextPins.mu.Lock()
+	defer s.contextPins.mu.Unlock()
+	all := s.loadContextPinsLocked(ctx)
+	pins := all[threadID]
+	if len(pins) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("[Pinned context] The following documents are pinned to this thread and refreshed before every turn:\n")
+	now := time.Now()
+	budget := maxContextPromptBytes
+	updated := 0
+	for i := range pins {
+		pin := &pins[i]
+		content, hash := pin.Content, ""
+		if pin.Path != "" {
+			doc, err := s.contextPins.readLocked(pin.Path)
+			if err != nil {
+				fmt.Fprintf(&b, "\n=== %s (unavailable: %v) ===\n", pin.label(), err)
+				continue
+			}
+			content, hash = doc.Content, doc.Hash
+		} else {
+			hash = contentDigest([]byte(content))
+		}
+		header := pin.label()
+		if pin.Path != "" && pin.Title != "" {
+			header += " (" + pin.Path + ")"
+		}
+		if pin.Hash != "" && pin.Hash != hash {
+			header += " [updated since the previous turn]"
+		}
+		if budget <= 0 {
+			fmt.Fprintf(&b, "\n=== %s (omitted: pinned context limit reached) ===\n", header)
+			continue
+		}
+		if len(content) > maxContextPinBytes {
+			content = truncateContextBytes(content, maxContextPinBytes) + "\n[... truncated]"
+		}
+		if len(content) > budget {
+			content = truncateContextBytes(content, budget) + "\n[... truncated]"
+		}
+		budget -= len(content)
+		fmt.Fprintf(&b, "\n=== BEGIN %s ===\n%s\n=== END %s ===\n", header, strings.TrimRight(content, "\n"), pin.label())
+		if pin.Hash != hash {
+			updated++
+		}
+		pin.Hash = hash
+		pin.IncludedAt = now
+	}
+	if err := s.saveThreadContextPinsLocked(ctx, all, threadID, pins); err != nil {
+		logger.Warn("thread context: persist inclusion failed", logger.FieldThreadID, threadID, logger.FieldError, err)
+	}
+	logger.Info("thread context: pinned documents injected",
+		logger.FieldThreadID, threadID,
+		logger.FieldCount, len(pins),
+		"updated", updated,
+	)
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// truncateContextBytes 按字节截断且不拆分 UTF-8 字符。
+func truncateContextBytes(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	for limit > 0 && !utf8.RuneStart(s[limit]) {
+		limit--
+	}
+	return s[:limit]
+}
+
+// ========================================
+// JSON-RPC
+// ========================================
+
+// threadContextPinParams thread/context/pin 请求参数 (path 与 content 二选一)。
+type threadContextPinParams struct {
+	ThreadID string `json:"threadId"`
+	Path     string `json:"path,omitempty"` // 相对路径按线程工作目录解析
+	Content  string `json:"content,omitempty"`
+	Title    string `json:"title,omitempty"`
+}
+
+// threadContextResponse thread/context/* 响应。
+type threadContextResponse struct {
+	ThreadID string                 `json:"threadId"`
+	Pin      *threadContextPinView  `json:"pin,omitempty"`
+	Removed  int                    `json:"removed,omitempty"`
+	Pins     []threadContextPinView `json:"pins"`
+}
+
+func (s *Server) threadContextPinTyped(ctx context.Context, p threadContextPinParams) (any, error) {
+	const op = "Server.threadContextPin"
+	threadID := strings.TrimSpace(p.ThreadID)
+	if threadID == "" {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "threadId is required")
+	}
+	path := strings.TrimSpace(p.Path)
+	if (path == "") == (strings.TrimSpace(p.Content) == "") {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "exactly one of path or content is required")
+	}
+	if len(p.Content) > maxContextPinBytes {
+		return nil, apperrors.NewCodef(op, errcode.InvalidInput, "content too large: %d bytes (limit %d)", len(p.Content), maxContextPinBytes)
+	}
+	title := strings.TrimSpace(p.Title)
+	if utf8.RuneCountInString(title) > maxContextPinTitleRunes {
+		return nil, apperrors.NewCodef(op, errcode.InvalidInput, "title too long (limit %d)", maxContextPinTitleRunes)
+	}
+	if !s.threadExistsForArchive(ctx, threadID) {
+		return nil, apperrors.NewCodef(op, errcode.ThreadNotFound, "thread %s not found", threadID)
+	}
+	if path != "" {
+		path = s.resolveThreadFilePaths(threadID, []string{path})[0]
+		if !filepath.IsAbs(path) {
+			return nil, apperrors.NewCodef(op, errcode.InvalidInput, "path %s must be absolute (thread has no working directory)", p.Path)
+		}
+	}
+
+	s.contextPins.mu.Lock()
+	defer s.contextPins.mu.Unlock()
+	if path != "" {
+		if _, err := s.contextPins.readLocked(path); err != nil {
+			return nil, apperrors.NewCodef(op, errcode.InvalidInput, "cannot read %s: %v", path, err)
+		}
+	}
+	all := s.loadContextPinsLocked(ctx)
+	pins := append([]threadContextPin(nil), all[threadID]...)
+	idx := -1
+	for i, pin := range pins {
+		if path != "" && pin.Path == path {
+			idx = i
+			break
+		}
+	}
+	if idx >= 0 {
+		if title != "" {
+			pins[idx].Title = title
+		}
+	} else {
+		if len(pins) >= maxThreadContextPins {
+			return nil, apperrors.NewCodef(op, errcode.InvalidInput, "too many pinned documents (limit %d)", maxThreadContextPins)
+		}
+		s.contextPins.seq++
+		pins = append(pins, threadContextPin{
+			ID:       fmt.Sprintf("ctx-%d-%d", time.Now().UnixMilli(), s.contextPins.seq),
+			Title:    title,
+			Path:     path,
+			Content:  p.Content,
+			PinnedAt: time.Now(),
+		})
+		idx = len(pins) - 1
+	}
+	if err := s.saveThreadContextPinsLocked(ctx, all, threadID, pins); err != nil {
+		return nil, apperrors.Wrap(err, op, "persist pinned context")
+	}
+	views := s.viewContextPinsLocked(pins)
+	resp := threadContextResponse{ThreadID: threadID, Pin: &views[idx], Pins: views}
+	logger.Info("thread/context/pin: document pinned",
+		logger.FieldThreadID, threadID,
+		logger.FieldID, pins[idx].ID,
+		logger.FieldPath, path,
+	)
+	s.Notify("thread/context/updated", map[string]any{"threadId": threadID, "pins": views})
+	return resp, nil
+}
+
+// threadContextUnpinParams thread/context/unpin 请求参数 (id 或 path; all=true 清空)。
+type threadContextUnpinParams struct {
+	ThreadID string `json:"threadId"`
+	ID       string `json:"id,omitempty"`
+	Path     string `json:"path,omitempty"`
+	All      bool   `json:"all,omitempty"`
+}
+
+func (s *Server) threadContextUnpinTyped(ctx context.Context, p threadContextUnpinParams) (any, error) {
+	const op = "Server.threadContextUnpin"
+	threadID := strings.TrimSpace(p.ThreadID)
+	if threadID == "" {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "threadId is required")
+	}
+	id, path := strings.TrimSpace(p.ID), strings.TrimSpace(p.Path)
+	if id == "" && path == "" && !p.All {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "id, path or all is required")
+	}
+	if path != "" {
+		path = s.resolveThreadFilePaths(threadID, []string{path})[0]
+	}
+
+	s.contextPins.mu.Lock()
+	defer s.contextPins.mu.Unlock()
+	all := s.loadContextPinsLocked(ctx)
+	current := all[threadID]
+	pins := make([]threadContextPin, 0, len(current))
+	for _, pin := range current {
+		if p.All || (id != "" && pin.ID == id) || (path != "" && pin.Path == path) {
+			delete(s.contextPins.docs, pin.Path)
+			continue
+		}
+		pins = append(pins, pin)
+	}
+	removed := len(current) - len(pins)
+	if removed == 0 {
+		return nil, apperrors.NewCodef(op, errcode.NotFound, "no matching pinned document on thread %s", threadID)
+	}
+	if err := s.saveThreadContextPinsLocked(ctx, all, threadID, pins); err != nil {
+		return nil, apperrors.Wrap(err, op, "persist pinned context")
+	}
+	views := s.viewContextPinsLocked(pins)
+	logger.Info("thread/context/unpin: documents removed", logger.FieldThreadID, threadID, logger.FieldCount, removed)
+	s.Notify("thread/context/updated", map[string]any{"threadId": threadID, "pins": views})
+	return threadContextResponse{ThreadID: threadID, Removed: removed, Pins: views}, nil
+}
+
+func (s *Server) threadContextListTyped(ctx context.Context, p threadIDParams) (any, error) {
+	threadID := strings.TrimSpace(p.ThreadID)
+	if threadID == "" {
+		return nil, apperrors.NewCode("Server.threadContextList", errcode.InvalidInput, "threadId is required")
+	}
+	s.contextPins.mu.Lock()
+	defer s.contextPins.mu.Unlock()
+	pins := s.loadContextPinsLocked(ctx)[threadID]
+	return threadContextResponse{ThreadID: threadID, Pins: s.viewContextPinsLocked(pins)}, nil
+}
@@ -0,0 +1,88 @@
+package apiserver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/uistate"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+func TestThreadContextPinInjectsAndRefreshesDocuments(t *testing.T) {
+	dir := t.TempDir()
+	threadID := "019c3b4e-6d7a-7f10-9a2b-3c4d5e6f7a8b"
+	srv := &Server{prefManager: uistate.NewPreferenceManager(nil)}
+	srv.setAgentWorkDir(threadID, dir)
+	ctx := context.Background()
+	spec := filepath.Join(dir, "SPEC.md")
+	if err := os.WriteFile(spec, []byte("API v1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := srv.threadContextPinTyped(ctx, threadContextPinParams{ThreadID: threadID, Path: "SPEC.md", Content: "x"}); apperrors.CodeOf(err) != errcode.InvalidInput {
+		t.Fatalf("path+content err = %v", err)
+	}
+	if _, err := srv.threadContextPinTyped(ctx, threadContextPinParams{ThreadID: threadID, Path: "missing.md"}); apperrors.CodeOf(err) != errcode.InvalidInput {
+		t.Fatalf("missing file err = %v", err)
+	}
+	if _, err := srv.threadContextPinTyped(ctx, threadContextPinParams{ThreadID: threadID, Path: "SPEC.md", Title: "Spec"}); err != nil {
+		t.Fatal(err)
+	}
+	res, err := srv.threadContextPinTyped(ctx, threadContextPinParams{ThreadID: threadID, Content: "Always answer in English."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inlineID := res.(threadContextResponse).Pin.ID
+	if pins := res.(threadContextResponse).Pins; len(pins) != 2 || pins[0].Path != spec || pins[0].Status != contextPinChanged {
+		t.Fatalf("pins = %+v", pins)
+	}
+	// 重复固定同一文件不新增。
+	if res, _ := srv.threadContextPinTyped(ctx, threadContextPinParams{ThreadID: threadID, Path: spec}); len(res.(threadContextResponse).Pins) != 2 {
+		t.Fatalf("duplicate pin added: %+v", res)
+	}
+
+	prompt := srv.buildThreadContextPrompt(ctx, threadID)
+	if !strings.Contains(prompt, "=== BEGIN Spec ("+spec+") ===\nAPI v1\n") || !strings.Contains(prompt, "Always answer in English.") {
+		t.Fatalf("prompt = %q", prompt)
+	}
+	if strings.Contains(prompt, "updated since") {
+		t.Fatalf("first injection should not be marked updated: %q", prompt)
+	}
+	res, _ = srv.threadContextListTyped(ctx, threadIDParams{ThreadID: threadID})
+	if pins := res.(threadContextResponse).Pins; pins[0].Status != contextPinFresh || pins[0].IncludedAt.IsZero() {
+		t.Fatalf("after injection pins = %+v", pins)
+	}
+
+	if err := os.WriteFile(spec, []byte("API v2 with more detail"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Minute)
+	_ = os.Chtimes(spec, future, future)
+	res, _ = srv.threadContextListTyped(ctx, threadIDParams{ThreadID: threadID})
+	if pins := res.(threadContextResponse).Pins; pins[0].Status != contextPinChanged {
+		t.Fatalf("edited file status = %s", pins[0].Status)
+	}
+	prompt = srv.buildThreadContextPrompt(ctx, threadID)
+	if !strings.Contains(prompt, "API v2 with more detail") || !strings.Contains(prompt, "Spec ("+spec+") [updated since the previous turn]") {
+		t.Fatalf("refreshed prompt = %q", prompt)
+	}
+
+	if _, err := srv.threadContextUnpinTyped(ctx, threadContextUnpinParams{ThreadID: threadID, ID: "nope"}); apperrors.CodeOf(err) != errcode.NotFound {
+		t.Fatalf("unpin unknown err = %v", err)
+	}
+	res, err = srv.threadContextUnpinTyped(ctx, threadContextUnpinParams{ThreadID: threadID, ID: inlineID})
+	if err != nil || res.(threadContextResponse).Removed != 1 || len(res.(threadContextResponse).Pins) != 1 {
+		t.Fatalf("unpin inline = %+v, %v", res, err)
+	}
+	if _, err := srv.threadContextUnpinTyped(ctx, threadContextUnpinParams{ThreadID: threadID, Path: "SPEC.md"}); err != nil {
+		t.Fatal(err)
+	}
+	if prompt := srv.buildThreadContextPrompt(ctx, threadID); prompt != "" {
+		t.Fatalf("prompt after unpin = %q", prompt)
+	}
+}
@@ -0,0 +1,81 @@
+// thread_cwd.go — thread/cwd/set: 切换运行中线程的工作目录。
+//
+// 让已有 agent 改去处理另一个仓库而无需新建线程:
+//   - 校验目标为存在的目录, 线程有进行中的 turn 时拒绝 (避免 turn 中途换目录);
+//   - 更新 agent 默认工作目录 (code_run / turn 项目归属 / 文件监听随之切换);
+//   - 通知 codex: 之后的 turn/start 携带新 cwd (客户端实现 codex.TurnCwdConfigurable 时);
+//   - LSP 根目录切换到新目录并加载其 .agent/lsp.json (LSP 管理器单根, 语言服务器随之重启)。
+package apiserver
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/multi-agent/go-agent-v2/internal/codex"
+	"github.com/multi-agent/go-agent-v2/internal/runner"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+type threadCwdSetParams struct {
+	ThreadID string `json:"threadId"`
+	Cwd      string `json:"cwd"`
+}
+
+func (s *Server) threadCwdSetTyped(_ context.Context, p threadCwdSetParams) (any, error) {
+	const op = "Server.threadCwdSet"
+	threadID := strings.TrimSpace(p.ThreadID)
+	if threadID == "" {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "threadId is required")
+	}
+	cwd := normalizeAgentWorkDir(p.Cwd)
+	if cwd == "" {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "cwd is required")
+	}
+	info, err := os.Stat(cwd)
+	if err != nil {
+		return nil, apperrors.WrapCode(err, op, errcode.InvalidInput, "stat cwd")
+	}
+	if !info.IsDir() {
+		return nil, apperrors.NewCodef(op, errcode.InvalidInput, "cwd %s is not a directory", cwd)
+	}
+
+	return s.withThread(threadID, func(proc *runner.AgentProcess) (any, error) {
+		if turnID, _, _, active := s.peekTrackedTurnMeta(threadID); active {
+			return nil, apperrors.NewCodef(op, errcode.InvalidInput, "thread %s has a running turn %s (interrupt or wait before switching cwd)", threadID, turnID)
+		}
+		previous := s.getAgentWorkDir(threadID)
+		if previous == cwd {
+			return map[string]any{"threadId": threadID, "cwd": cwd, "previousCwd": previous, "changed": false}, nil
+		}
+
+		codexNotified := false
+		if configurable, ok := proc.Client.(codex.TurnCwdConfigurable); ok {
+			configurable.SetTurnCwd(cwd)
+			codexNotified = true
+		}
+		s.setAgentWorkDir(threadID, cwd)
+		if s.lsp != nil {
+			s.applyProjectLSPCatalog(cwd)
+			s.lsp.SetRootURI("file://" + cwd)
+		}
+
+		logger.Info("thread/cwd/set: switched",
+			logger.FieldThreadID, threadID,
+			logger.FieldCwd, cwd,
+			"previous_cwd", previous,
+			"codex_notified", codexNotified,
+		)
+		payload := map[string]any{
+			"threadId":      threadID,
+			"cwd":           cwd,
+			"previousCwd":   previous,
+			"changed":       true,
+			"codexNotified": codexNotified,
+		}
+		s.Notify("thread/cwd/changed", payload)
+		return payload, nil
+	})
+}
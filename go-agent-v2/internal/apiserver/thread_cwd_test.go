@@ -0,0 +1,76 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/multi-agent/go-agent-v2/internal/codex"
+	"github.com/multi-agent/go-agent-v2/internal/runner"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+func TestThreadCwdSet_SwitchesLiveThread(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	mgr := runner.NewAgentManager()
+	mgr.SetClientFactory(codex.MockClientFactory(codex.DefaultMockScript, 0))
+	srv := New(Deps{Manager: mgr})
+	ctx := context.Background()
+
+	res, err := srv.InvokeMethod(ctx, "thread/start", json.RawMessage(`{"cwd":"."}`))
+	if err != nil {
+		t.Fatalf("thread/start: %v", err)
+	}
+	threadID := res.(threadStartResponse).Thread.ID
+
+	target := t.TempDir()
+	file := filepath.Join(target, "note.txt")
+	if err := os.WriteFile(file, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	invoke := func(cwd string) (any, error) {
+		params, _ := json.Marshal(threadCwdSetParams{ThreadID: threadID, Cwd: cwd})
+		return srv.InvokeMethod(ctx, "thread/cwd/set", params)
+	}
+	if _, err := invoke(filepath.Join(target, "missing")); apperrors.CodeOf(err) != errcode.InvalidInput {
+		t.Fatalf("missing dir err = %v", err)
+	}
+	if _, err := invoke(file); apperrors.CodeOf(err) != errcode.InvalidInput {
+		t.Fatalf("file cwd err = %v", err)
+	}
+	params, _ := json.Marshal(threadCwdSetParams{ThreadID: "no-such-thread", Cwd: target})
+	if _, err := srv.InvokeMethod(ctx, "thread/cwd/set", params); apperrors.CodeOf(err) != errcode.ThreadNotFound {
+		t.Fatalf("unknown thread err = %v", err)
+	}
+
+	got, err := invoke(target)
+	if err != nil {
+		t.Fatalf("thread/cwd/set: %v", err)
+	}
+	payload := got.(map[string]any)
+	if payload["changed"] != true || payload["codexNotified"] != true || payload["cwd"] != target {
+		t.Fatalf("payload = %#v", payload)
+	}
+	if cwd := srv.getAgentWorkDir(threadID); cwd != target {
+		t.Fatalf("agent work dir = %q, want %q", cwd, target)
+	}
+	if cwd := mgr.Get(threadID).Client.(*codex.MockClient).TurnCwd(); cwd != target {
+		t.Fatalf("codex turn cwd = %q, want %q", cwd, target)
+	}
+
+	// turn/start 未指定 cwd 时沿用切换后的目录
+	if _, err := srv.ensureThreadReadyForTurn(ctx, threadID, ""); err != nil {
+		t.Fatalf("ensure ready: %v", err)
+	}
+	if cwd := srv.getAgentWorkDir(threadID); cwd != target {
+		t.Fatalf("work dir after turn without cwd = %q, want %q", cwd, target)
+	}
+
+	again, err := invoke(target)
+	if err != nil || again.(map[string]any)["changed"] != false {
+		t.Fatalf("repeat set = %#v, %v", again, err)
+	}
+}
@@ -0,0 +1,189 @@
+// thread_env.go — 线程级环境变量覆盖 (thread/env/get, thread/env/set)。
+//
+// 覆盖项持久化在 UI 偏好 settings.threadEnv ({threadId: {KEY: VALUE}}), 每次启动 codex 进程
+// (thread/start、自动恢复、重启) 时由 AgentManager 的启动环境解析器注入, 键名沿用
+// config/value/write 的允许列表。对运行中的线程修改后需重启进程才生效。
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+const (
+	prefKeyThreadEnv = "settings.threadEnv"
+
+	maxThreadEnvEntries    = 64
+	maxThreadEnvValueBytes = 8 << 10
+	threadEnvLoadTimeout   = 5 * time.Second
+)
+
+var envKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func decodeThreadEnv(value any) map[string]map[string]string {
+	out := map[string]map[string]string{}
+	if value == nil {
+		return out
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return out
+	}
+	if err := json.Unmarshal(raw, &out); err != nil || out == nil {
+		return map[string]map[string]string{}
+	}
+	return out
+}
+
+func (s *Server) loadThreadEnvs(ctx context.Context) map[string]map[string]string {
+	if s.prefManager == nil {
+		return map[string]map[string]string{}
+	}
+	value, err := s.prefManager.Get(ctx, prefKeyThreadEnv)
+	if err != nil {
+		logger.Warn("thread env: load preference failed", logger.FieldError, err)
+		return map[string]map[string]string{}
+	}
+	return decodeThreadEnv(value)
+}
+
+// threadLaunchEnv 启动环境解析器: 返回线程的 KEY=VALUE 覆盖 (按键名排序)。
+func (s *Server) threadLaunchEnv(agentID string) []string {
+	ctx, cancel := context.WithTimeout(context.Background(), threadEnvLoadTimeout)
+	defer cancel()
+	env := s.loadThreadEnvs(ctx)[strings.TrimSpace(agentID)]
+	if len(env) == 0 {
+		return nil
+	}
+	out := make([]string, 0, len(env))
+	for key, value := range env {
+		if !isAllowedEnvKey(key) { // 允许列表收紧后不再注入旧条目
+			continue
+		}
+		out = append(out, key+"="+value)
+	}
+	sort.Strings(out)
+	logger.Info("thread env: applying overrides", logger.FieldAgentID, agentID, "count", len(out))
+	return out
+}
+
+// validateThreadEnvEntry 校验单个覆盖项 (与 config/value/write 相同的允许列表)。
+func validateThreadEnvEntry(key string, value *string) error {
+	if !envKeyPattern.MatchString(key) {
+		return apperrors.Newf("Server.threadEnvSet", "invalid env key %q", key)
+	}
+	if !isAllowedEnvKey(key) {
+		return apperrors.Newf("Server.threadEnvSet", "key %q not in allowlist", key)
+	}
+	if value == nil {
+		return nil
+	}
+	if strings.ContainsRune(*value, 0) {
+		return apperrors.Newf("Server.threadEnvSet", "value of %q contains NUL", key)
+	}
+	if len(*value) > maxThreadEnvValueBytes {
+		return apperrors.Newf("Server.threadEnvSet", "value of %q exceeds %d bytes", key, maxThreadEnvValueBytes)
+	}
+	return nil
+}
+
+// maskThreadEnv 响应中隐藏密钥类取值。
+func maskThreadEnv(env map[string]string) map[string]string {
+	out := make(map[string]string, len(env))
+	for key, value := range env {
+		if isSecretConfigKey(strings.ToUpper(key)) && value != "" {
+			value = "***"
+		}
+		out[key] = value
+	}
+	return out
+}
+
+type threadEnvGetParams struct {
+	ThreadID string `json:"threadId"`
+}
+
+func (s *Server) threadEnvGetTyped(ctx context.Context, p threadEnvGetParams) (any, error) {
+	threadID := strings.TrimSpace(p.ThreadID)
+	if threadID == "" {
+		return nil, apperrors.NewCode("Server.threadEnvGet", errcode.InvalidInput, "threadId is required")
+	}
+	env := s.loadThreadEnvs(ctx)[threadID]
+	return map[string]any{"threadId": threadID, "env": maskThreadEnv(env)}, nil
+}
+
+type threadEnvSetParams struct {
+	ThreadID string             `json:"threadId"`
+	Env      map[string]*string `json:"env"`               // null = 删除该键
+	Replace  bool               `json:"replace,omitempty"` // true = 以 env 整体替换 (而非合并)
+}
+
+func (s *Server) threadEnvSetTyped(ctx context.Context, p threadEnvSetParams) (any, error) {
+	const op = "Server.threadEnvSet"
+	if s.prefManager == nil {
+		return nil, apperrors.New(op, "preference manager not initialized")
+	}
+	threadID := strings.TrimSpace(p.ThreadID)
+	if threadID == "" {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "threadId is required")
+	}
+	for key, value := range p.Env {
+		if err := validateThreadEnvEntry(key, value); err != nil {
+			return nil, apperrors.WrapCode(err, op, errcode.InvalidInput, "invalid env override")
+		}
+	}
+	env, err := s.saveThreadEnv(ctx, threadID, p.Env, p.Replace)
+	if err != nil {
+		return nil, err
+	}
+	running := s.mgr != nil && s.mgr.Get(threadID) != nil
+	logger.Info("thread/env/set: saved",
+		logger.FieldThreadID, threadID,
+		"count", len(env),
+		"replace", p.Replace,
+		"running", running,
+	)
+	return map[string]any{
+		"threadId":        threadID,
+		"env":             maskThreadEnv(env),
+		"restartRequired": running, // 已运行的进程需重启后生效
+	}, nil
+}
+
+// saveThreadEnv 合并 (或替换) 并持久化线程覆盖项, 返回保存后的取值。
+func (s *Server) saveThreadEnv(ctx context.Context, threadID string, patch map[string]*string, replace bool) (map[string]string, error) {
+	s.threadEnvMu.Lock()
+	defer s.threadEnvMu.Unlock()
+	all := s.loadThreadEnvs(ctx)
+	env := all[threadID]
+	if env == nil || replace {
+		env = map[string]string{}
+	}
+	for key, value := range patch {
+		if value == nil {
+			delete(env, key)
+			continue
+		}
+		env[key] = *value
+	}
+	if len(env) > maxThreadEnvEntries {
+		return nil, apperrors.NewCodef("Server.threadEnvSet", errcode.InvalidInput, "at most %d env overrides per thread", maxThreadEnvEntries)
+	}
+	if len(env) == 0 {
+		delete(all, threadID)
+	} else {
+		all[threadID] = env
+	}
+	if err := s.prefManager.Set(ctx, prefKeyThreadEnv, all); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
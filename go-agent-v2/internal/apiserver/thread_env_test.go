@@ -0,0 +1,64 @@
+package apiserver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/multi-agent/go-agent-v2/internal/uistate"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+func TestThreadEnvSetPersistsForLaunch(t *testing.T) {
+	srv := &Server{prefManager: uistate.NewPreferenceManager(nil)}
+	ctx := context.Background()
+	key, proxy := "sk-thread", "http://proxy:3128"
+
+	res, err := srv.threadEnvSetTyped(ctx, threadEnvSetParams{
+		ThreadID: "thread-1",
+		Env:      map[string]*string{"OPENAI_API_KEY": &key, "HTTPS_PROXY": &proxy},
+	})
+	if err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	env := res.(map[string]any)["env"].(map[string]string)
+	if env["OPENAI_API_KEY"] != "***" || env["HTTPS_PROXY"] != proxy {
+		t.Fatalf("masked env = %v", env)
+	}
+
+	got := srv.threadLaunchEnv("thread-1")
+	if len(got) != 2 || got[0] != "HTTPS_PROXY="+proxy || got[1] != "OPENAI_API_KEY="+key {
+		t.Fatalf("launch env = %v", got)
+	}
+	if other := srv.threadLaunchEnv("thread-2"); other != nil {
+		t.Fatalf("other thread env = %v, want nil", other)
+	}
+
+	// null 删除单个键, 其余保留。
+	if _, err := srv.threadEnvSetTyped(ctx, threadEnvSetParams{
+		ThreadID: "thread-1",
+		Env:      map[string]*string{"OPENAI_API_KEY": nil},
+	}); err != nil {
+		t.Fatalf("delete key: %v", err)
+	}
+	if got := srv.threadLaunchEnv("thread-1"); len(got) != 1 || got[0] != "HTTPS_PROXY="+proxy {
+		t.Fatalf("launch env after delete = %v", got)
+	}
+}
+
+func TestThreadEnvSetRejectsDisallowedKeys(t *testing.T) {
+	srv := &Server{prefManager: uistate.NewPreferenceManager(nil)}
+	value := "/tmp/evil"
+	for _, key := range []string{"PATH", "LD_PRELOAD", "OPENAI_KEY=X"} {
+		_, err := srv.threadEnvSetTyped(context.Background(), threadEnvSetParams{
+			ThreadID: "thread-1",
+			Env:      map[string]*string{key: &value},
+		})
+		if apperrors.CodeOf(err) != errcode.InvalidInput {
+			t.Fatalf("key %q err = %v, want INVALID_INPUT", key, err)
+		}
+	}
+	if got := srv.threadLaunchEnv("thread-1"); got != nil {
+		t.Fatalf("rejected keys must not persist: %v", got)
+	}
+}
@@ -0,0 +1,352 @@
+// thread_handoff.go — thread/stop/graceful: 带交接摘要的优雅停止。
+//
+// 直接停止 turn 中的 agent 会丢失上下文。优雅停止:
+//   - 中断进行中的 turn (含 code_run);
+//   - 以带 outputSchema 的 turn 让 agent 输出机器可读的交接摘要 (总结 / 待办步骤 / 已改文件),
+//     超时未回复时再次中断并记录 timeout;
+//   - 摘要持久化到 thread_handoffs (无数据库时保存在进程内), 默认随后停止 agent 进程;
+//   - 该线程下一次 turn/start 时把最新未消费的摘要注入提示词, thread/resume 响应附带待注入摘要。
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/runner"
+	"github.com/multi-agent/go-agent-v2/internal/store"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+const (
+	handoffStatusCaptured     = "captured"
+	handoffStatusUnstructured = "unstructured"
+	handoffStatusTimeout      = "timeout"
+
+	defaultHandoffTimeout = 90 * time.Second
+	maxHandoffTimeout     = 10 * time.Minute
+	maxHandoffListItems   = 20
+	maxHandoffRawRunes    = 8000
+)
+
+// handoffOutputSchema 交接 turn 的结构化输出约束。
+var handoffOutputSchema = json.RawMessage(`{
+  "type": "object",
+  "properties": {
+    "summary": {"type": "string"},
+    "outstandingSteps": {"type": "array", "items": {"type": "string"}},
+    "modifiedFiles": {"type": "array", "items": {"type": "string"}}
+  },
+  "required": ["summary", "outstandingSteps", "modifiedFiles"],
+  "additionalProperties": false
+}`)
+
+// handoffOutput 交接 turn 的输出。
+type handoffOutput struct {
+	Summary          string   `json:"summary"`
+	OutstandingSteps []string `json:"outstandingSteps"`
+	ModifiedFiles    []string `json:"modifiedFiles"`
+}
+
+func buildHandoffPrompt(reason string) string {
+	var b strings.Builder
+	b.WriteString("You are being stopped now. Do not continue the task and do not run any tools. ")
+	b.WriteString("Write a short handoff so that a later session can pick up exactly where you left off: ")
+	b.WriteString("what was done so far (summary), the remaining steps in order (outstandingSteps) ")
+	b.WriteString("and every file you created or modified (modifiedFiles, paths relative to the working directory). ")
+	b.WriteString("Reply with JSON only: {\"summary\", \"outstandingSteps\", \"modifiedFiles\"}.")
+	if reason = strings.TrimSpace(reason); reason != "" {
+		b.WriteString("\n\nReason for stopping: ")
+		b.WriteString(reason)
+	}
+	return b.String()
+}
+
+// parseHandoffOutput 从回复中解析交接 JSON (整段 / 代码块 / 首尾花括号)。
+func parseHandoffOutput(text string) (handoffOutput, bool) {
+	trimmed := strings.TrimSpace(text)
+	candidates := []string{trimmed}
+	fences := reviewJSONFence.FindAllStringSubmatch(trimmed, -1)
+	for i := len(fences) - 1; i >= 0; i-- {
+		candidates = append(candidates, fences[i][1])
+	}
+	if start, end := strings.Index(trimmed, "{"), strings.LastIndex(trimmed, "}"); start >= 0 && end > start {
+		candidates = append(candidates, trimmed[start:end+1])
+	}
+	for _, candidate := range candidates {
+		var out handoffOutput
+		if candidate = strings.TrimSpace(candidate); strings.HasPrefix(candidate, "{") &&
+			json.Unmarshal([]byte(candidate), &out) == nil && strings.TrimSpace(out.Summary) != "" {
+			out.OutstandingSteps = compactStrings(out.OutstandingSteps)
+			out.ModifiedFiles = compactStrings(out.ModifiedFiles)
+			return out, true
+		}
+	}
+	return handoffOutput{}, false
+}
+
+func compactStrings(in []string) []string {
+	out := make([]string, 0, len(in))
+	for _, item := range in {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// buildHandoffResumePrompt 注入下一次 turn 的交接上下文。
+func buildHandoffResumePrompt(h *store.ThreadHandoff) string {
+	if h == nil {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("[Handoff] This session was stopped on " + h.CreatedAt.Format(time.RFC3339))
+	if h.Reason != "" {
+		b.WriteString(" (" + h.Reason + ")")
+	}
+	b.WriteString(". Handoff left by the previous session:\n")
+	switch {
+	case h.Summary != "":
+		b.WriteString("Summary: " + h.Summary + "\n")
+	case h.Raw != "":
+		b.WriteString("Notes: " + h.Raw + "\n")
+	default:
+		b.WriteString("No handoff was written before the stop; check the working tree before continuing.\n")
+	}
+	if len(h.OutstandingSteps) > 0 {
+		b.WriteString("Outstanding steps:\n")
+		for _, step := range h.OutstandingSteps {
+			b.WriteString("- " + step + "\n")
+		}
+	}
+	if len(h.ModifiedFiles) > 0 {
+		b.WriteString("Modified files:\n")
+		for _, file := range h.ModifiedFiles {
+			b.WriteString("- " + file + "\n")
+		}
+	}
+	b.WriteString("Continue from this state.")
+	return b.String()
+}
+
+// ========================================
+// 持久化
+// ========================================
+
+// threadHandoffState 无数据库时的交接摘要 (零值可用)。
+type threadHandoffState struct {
+	mu       sync.Mutex
+	seq      int64
+	byThread map[string][]store.ThreadHandoff // 新的在前
+}
+
+func (st *threadHandoffState) save(h store.ThreadHandoff) *store.ThreadHandoff {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.byThread == nil {
+		st.byThread = make(map[string][]store.ThreadHandoff)
+	}
+	st.seq++
+	h.ID = st.seq
+	h.CreatedAt = time.Now()
+	list := append([]store.ThreadHandoff{h}, st.byThread[h.ThreadID]...)
+	if len(list) > maxHandoffListItems {
+		list = list[:maxHandoffListItems]
+	}
+	st.byThread[h.ThreadID] = list
+	return &h
+}
+
+func (st *threadHandoffState) latestPending(threadID string) *store.ThreadHandoff {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for _, h := range st.byThread[threadID] {
+		if h.ConsumedAt == nil {
+			cp := h
+			return &cp
+		}
+	}
+	return nil
+}
+
+func (st *threadHandoffState) markConsumed(threadID string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	now := time.Now()
+	for i := range st.byThread[threadID] {
+		if st.byThread[threadID][i].ConsumedAt == nil {
+			st.byThread[threadID][i].ConsumedAt = &now
+		}
+	}
+}
+
+func (st *threadHandoffState) list(threadID string) []store.ThreadHandoff {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return append([]store.ThreadHandoff(nil), st.byThread[threadID]...)
+}
+
+func (s *Server) saveThreadHandoff(ctx context.Context, h store.ThreadHandoff) (*store.ThreadHandoff, error) {
+	if s.handoffStore == nil {
+		return s.handoffs.save(h), nil
+	}
+	return s.handoffStore.Save(ctx, h)
+}
+
+// pendingThreadHandoff 线程最新未消费的交接摘要 (查询失败只记录日志)。
+func (s *Server) pendingThreadHandoff(ctx context.Context, threadID string) *store.ThreadHandoff {
+	if s.handoffStore == nil {
+		return s.handoffs.latestPending(threadID)
+	}
+	h, err := s.handoffStore.LatestPending(ctx, threadID)
+	if err != nil {
+		logger.Warn("thread handoff: load pending failed", logger.FieldThreadID, threadID, logger.FieldError, err)
+		return nil
+	}
+	return h
+}
+
+// consumeThreadHandoff 标记交接摘要已注入 (turn/start 提交或排队成功后调用, 去重/失败时保留待注入)。
+func (s *Server) consumeThreadHandoff(ctx context.Context, threadID string, h *store.ThreadHandoff) {
+	if h == nil {
+		return
+	}
+	if s.handoffStore == nil {
+		s.handoffs.markConsumed(threadID)
+	} else if err := s.handoffStore.MarkConsumed(ctx, threadID); err != nil {
+		logger.Warn("thread handoff: mark consumed failed", logger.FieldThreadID, threadID, logger.FieldError, err)
+	}
+	logger.Info("thread handoff: injected into turn", logger.FieldThreadID, threadID, logger.FieldID, h.ID, logger.FieldStatus, h.Status)
+}
+
+// ========================================
+// JSON-RPC
+// ========================================
+
+type threadStopGracefulParams struct {
+	ThreadID    string `json:"threadId"`
+	Reason      string `json:"reason,omitempty"`
+	TimeoutSec  int    `json:"timeoutSec,omitempty"`  // 等待交接回复的时长, 默认 90 秒
+	KeepRunning bool   `json:"keepRunning,omitempty"` // 只生成交接摘要, 不停止 agent 进程
+}
+
+func (s *Server) threadStopGracefulTyped(ctx context.Context, p threadStopGracefulParams) (any, error) {
+	const op = "Server.threadStopGraceful"
+	threadID := strings.TrimSpace(p.ThreadID)
+	if threadID == "" {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "threadId is required")
+	}
+	timeout := defaultHandoffTimeout
+	if p.TimeoutSec > 0 {
+		timeout = min(time.Duration(p.TimeoutSec)*time.Second, maxHandoffTimeout)
+	}
+	if s.mgr == nil || s.mgr.Get(threadID) == nil {
+		return nil, apperrors.NewCodef(op, errcode.ThreadNotFound, "thread %s not found", threadID)
+	}
+
+	interrupted := false
+	if _, _, _, active := s.peekTrackedTurnMeta(threadID); active {
+		params, _ := json.Marshal(threadIDParams{ThreadID: threadID})
+		if _, err := s.turnInterrupt(ctx, params); err != nil {
+			return nil, apperrors.Wrap(err, op, "interrupt running turn")
+		}
+		interrupted = true
+	}
+
+	reason := truncateRunes(strings.TrimSpace(p.Reason), 500)
+	handoff := store.ThreadHandoff{ThreadID: threadID, Reason: reason, Status: handoffStatusTimeout}
+	_, err := s.withThread(threadID, func(proc *runner.AgentProcess) (any, error) {
+		prompt := buildHandoffPrompt(reason)
+		turnID, err := s.submitPreparedTurn(ctx, proc, preparedTurn{
+			ThreadID:     threadID,
+			Prompt:       prompt,
+			SubmitPrompt: prompt,
+			OutputSchema: handoffOutputSchema,
+		})
+		if err != nil {
+			return nil, err
+		}
+		handoff.TurnID = turnID
+		status, finished := s.waitTrackedTurnTerminal(threadID, timeout)
+		if !finished || status != "completed" {
+			if !finished {
+				_ = proc.Client.SendCommand("/interrupt", "")
+			}
+			logger.Warn("thread/stop/graceful: handoff turn did not complete",
+				logger.FieldThreadID, threadID, logger.FieldTurnID, turnID, logger.FieldStatus, status)
+			return nil, nil
+		}
+		text := s.awaitTurnReplyText(threadID, turnID)
+		if out, ok := parseHandoffOutput(text); ok {
+			handoff.Status = handoffStatusCaptured
+			handoff.Summary = out.Summary
+			handoff.OutstandingSteps = out.OutstandingSteps
+			handoff.ModifiedFiles = out.ModifiedFiles
+		} else if text != "" {
+			handoff.Status = handoffStatusUnstructured
+			handoff.Raw = truncateRunes(text, maxHandoffRawRunes)
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return nil, apperrors.Wrap(err, op, "request handoff")
+	}
+
+	saved, err := s.saveThreadHandoff(ctx, handoff)
+	if err != nil {
+		return nil, apperrors.Wrap(err, op, "persist handoff")
+	}
+	stopped := false
+	if !p.KeepRunning {
+		_ = s.cancelCodeRuns(threadID)
+		if err := s.mgr.Stop(threadID); err != nil {
+			logger.Warn("thread/stop/graceful: stop agent failed", logger.FieldThreadID, threadID, logger.FieldError, err)
+		} else {
+			stopped = true
+		}
+	}
+
+	logger.Info("thread/stop/graceful: done",
+		logger.FieldThreadID, threadID,
+		logger.FieldStatus, saved.Status,
+		"interrupted", interrupted,
+		"stopped", stopped,
+		"outstanding_steps", len(saved.OutstandingSteps),
+		"modified_files", len(saved.ModifiedFiles),
+	)
+	result := map[string]any{
+		"threadId":    threadID,
+		"handoff":     saved,
+		"interrupted": interrupted,
+		"stopped":     stopped,
+	}
+	s.Notify("thread/handoff/saved", result)
+	return result, nil
+}
+
+// threadHandoffListTyped 线程的交接摘要 (JSON-RPC: thread/handoff/list)。
+func (s *Server) threadHandoffListTyped(ctx context.Context, p threadIDParams) (any, error) {
+	const op = "Server.threadHandoffList"
+	threadID := strings.TrimSpace(p.ThreadID)
+	if threadID == "" {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "threadId is required")
+	}
+	var handoffs []store.ThreadHandoff
+	if s.handoffStore == nil {
+		handoffs = s.handoffs.list(threadID)
+	} else {
+		var err error
+		if handoffs, err = s.handoffStore.ListByThread(ctx, threadID, maxHandoffListItems); err != nil {
+			return nil, err
+		}
+	}
+	if handoffs == nil {
+		handoffs = []store.ThreadHandoff{}
+	}
+	return map[string]any{"threadId": threadID, "handoffs": handoffs}, nil
+}
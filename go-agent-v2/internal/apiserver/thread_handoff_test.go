@@ -0,0 +1,90 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/codex"
+	"github.com/multi-agent/go-agent-v2/internal/runner"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+func TestParseHandoffOutput(t *testing.T) {
+	out, ok := parseHandoffOutput("Here you go:\n```json\n{\"summary\":\"half done\",\"outstandingSteps\":[\"wire RPC\",\" \"],\"modifiedFiles\":[\"a.go\"]}\n```")
+	if !ok || out.Summary != "half done" || len(out.OutstandingSteps) != 1 || out.ModifiedFiles[0] != "a.go" {
+		t.Fatalf("fenced = %+v, %v", out, ok)
+	}
+	if _, ok := parseHandoffOutput("I stopped."); ok {
+		t.Fatal("plain text should not parse")
+	}
+}
+
+func TestThreadStopGraceful_PersistsHandoffForNextTurn(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	reply := `{"summary":"Added the store","outstandingSteps":["register RPC","write tests"],"modifiedFiles":["internal/store/x.go"]}`
+	script := func(turnID, prompt string) []codex.MockStep {
+		if !strings.Contains(prompt, "You are being stopped") {
+			return codex.DefaultMockScript(turnID, prompt)
+		}
+		return []codex.MockStep{
+			{Type: codex.EventTurnStarted, Data: map[string]any{"turn": map[string]any{"id": turnID}}},
+			{Type: codex.EventAgentMessageDelta, Data: map[string]any{"delta": reply}},
+			{Type: codex.EventTurnComplete, Data: map[string]any{
+				"turn":               map[string]any{"id": turnID, "status": "completed", "items": []any{}},
+				"last_agent_message": reply,
+			}},
+		}
+	}
+	mgr := runner.NewAgentManager()
+	mgr.SetClientFactory(codex.MockClientFactory(script, 10*time.Millisecond))
+	srv := New(Deps{Manager: mgr})
+	mgr.SetOnEvent(func(agentID string, event codex.Event) {
+		srv.AgentEventHandler(agentID)(event)
+	})
+	ctx := context.Background()
+
+	res, err := srv.InvokeMethod(ctx, "thread/start", json.RawMessage(`{"cwd":"."}`))
+	if err != nil {
+		t.Fatalf("thread/start: %v", err)
+	}
+	threadID := res.(threadStartResponse).Thread.ID
+
+	if _, err := srv.InvokeMethod(ctx, "thread/stop/graceful", json.RawMessage(`{"threadId":"missing"}`)); apperrors.CodeOf(err) != errcode.ThreadNotFound {
+		t.Fatalf("unknown thread err = %v", err)
+	}
+	params, _ := json.Marshal(threadStopGracefulParams{ThreadID: threadID, Reason: "end of day", TimeoutSec: 5})
+	got, err := srv.InvokeMethod(ctx, "thread/stop/graceful", params)
+	if err != nil {
+		t.Fatalf("thread/stop/graceful: %v", err)
+	}
+	payload := got.(map[string]any)
+	if payload["stopped"] != true || mgr.Get(threadID) != nil {
+		t.Fatalf("agent not stopped: %#v", payload)
+	}
+	pending := srv.pendingThreadHandoff(ctx, threadID)
+	if pending == nil || pending.Status != handoffStatusCaptured || len(pending.OutstandingSteps) != 2 || pending.ModifiedFiles[0] != "internal/store/x.go" {
+		t.Fatalf("pending handoff = %+v", pending)
+	}
+
+	prompt := buildHandoffResumePrompt(pending)
+	for _, want := range []string{"end of day", "Added the store", "- write tests", "- internal/store/x.go"} {
+		if !strings.Contains(prompt, want) {
+			t.Fatalf("resume prompt missing %q:\n%s", want, prompt)
+		}
+	}
+	srv.consumeThreadHandoff(ctx, threadID, pending)
+	if again := srv.pendingThreadHandoff(ctx, threadID); again != nil {
+		t.Fatalf("handoff injected twice: %+v", again)
+	}
+	listed, err := srv.InvokeMethod(ctx, "thread/handoff/list", json.RawMessage(`{"threadId":"`+threadID+`"}`))
+	if err != nil {
+		t.Fatalf("thread/handoff/list: %v", err)
+	}
+	if handoffs := listed.(map[string]any)["handoffs"]; handoffs == nil {
+		t.Fatalf("list = %#v", listed)
+	}
+}
@@ -0,0 +1,258 @@
+// thread_lineage.go — thread/fork 分叉到指定 turn 与线程分叉关系 (thread/lineage)。
+//
+// thread/fork 带 turnIndex 时不经 codex fork, 而是:
+//  1. 读取父线程 rollout, 保留前 turnIndex+1 个用户 turn (codex.TruncateRolloutTurns);
+//  2. 以新 codex 线程 ID 写入 ~/.codex/sessions 并登记绑定 (同 thread/import);
+//  3. 启动新的 codex 进程并从截断后的 rollout 恢复, 由 rollout 重建时间线;
+//  4. 复制父线程的别名、技能与工作目录。
+//
+// 两种分叉都把父子关系写入 thread_lineage (无数据库时保存在进程内),
+// thread/lineage 返回线程的祖先链 (由近及远) 与直接子线程。
+package apiserver
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/codex"
+	"github.com/multi-agent/go-agent-v2/internal/store"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+// maxThreadLineageDepth 祖先链最大回溯深度 (防止异常数据成环)。
+const maxThreadLineageDepth = 32
+
+// threadLineageState 无数据库时的分叉关系 (childThreadID →)。
+type threadLineageState struct {
+	mu      sync.Mutex
+	byChild map[string]store.ThreadLineage
+}
+
+func (st *threadLineageState) save(l store.ThreadLineage) *store.ThreadLineage {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.byChild == nil {
+		st.byChild = make(map[string]store.ThreadLineage)
+	}
+	l.CreatedAt = time.Now()
+	st.byChild[l.ChildThreadID] = l
+	return &l
+}
+
+func (st *threadLineageState) parent(threadID string) *store.ThreadLineage {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if l, ok := st.byChild[threadID]; ok {
+		return &l
+	}
+	return nil
+}
+
+func (st *threadLineageState) children(threadID string) []store.ThreadLineage {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	var out []store.ThreadLineage
+	for _, l := range st.byChild {
+		if l.ParentThreadID == threadID {
+			out = append(out, l)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.Before(out[j].CreatedAt)
+		}
+		return out[i].ChildThreadID < out[j].ChildThreadID
+	})
+	return out
+}
+
+// recordThreadLineage 记录分叉关系 (失败只记录日志, 不影响已完成的分叉)。
+func (s *Server) recordThreadLineage(ctx context.Context, l store.ThreadLineage) {
+	if s.lineageStore == nil {
+		s.lineages.save(l)
+		return
+	}
+	if _, err := s.lineageStore.Save(ctx, l); err != nil {
+		logger.Warn("thread/fork: persist lineage failed",
+			logger.FieldThreadID, l.ChildThreadID,
+			"parent_thread_id", l.ParentThreadID,
+			logger.FieldError, err,
+		)
+	}
+}
+
+func (s *Server) threadParent(ctx context.Context, threadID string) (*store.ThreadLineage, error) {
+	if s.lineageStore == nil {
+		return s.lineages.parent(threadID), nil
+	}
+	return s.lineageStore.Parent(ctx, threadID)
+}
+
+func (s *Server) threadChildren(ctx context.Context, threadID string) ([]store.ThreadLineage, error) {
+	if s.lineageStore == nil {
+		return s.lineages.children(threadID), nil
+	}
+	return s.lineageStore.Children(ctx, threadID)
+}
+
+// forkThreadAtTurn 以父线程前 turnIndex+1 个 turn 创建并启动新线程。
+func (s *Server) forkThreadAtTurn(ctx context.Context, parentID string, turnIndex int) (any, error) {
+	const op = "Server.threadFork"
+	if err := s.drainingError(op); err != nil {
+		return nil, err
+	}
+	if turnIndex < 0 {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "turnIndex must be >= 0")
+	}
+	if !s.threadExistsForArchive(ctx, parentID) {
+		return nil, apperrors.NewCodef(op, errcode.ThreadNotFound, "thread %s not found", parentID)
+	}
+	path := s.resolveRolloutFilePath(ctx, parentID)
+	if path == "" {
+		return nil, apperrors.NewCodef(op, errcode.NotFound, "thread %s has no rollout to fork from", parentID)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, apperrors.Wrap(err, op, "read rollout")
+	}
+	truncated, turns := codex.TruncateRolloutTurns(data, turnIndex+1)
+	if turnIndex >= turns {
+		return nil, apperrors.NewCodef(op, errcode.InvalidInput, "turnIndex %d out of range (thread has %d turns)", turnIndex, turns)
+	}
+
+	now := time.Now()
+	threadID := fmt.Sprintf("thread-%d-%d", now.UnixMilli(), s.threadSeq.Add(1))
+	codexThreadID, err := newCodexThreadID()
+	if err != nil {
+		return nil, apperrors.Wrap(err, op, "generate codex thread id")
+	}
+	parentCodexID, _ := s.resolveRolloutHistorySource(ctx, parentID)
+	rolloutPath, err := installImportedRollout(truncated, normalizeCodexThreadID(parentCodexID), codexThreadID, now)
+	if err != nil {
+		return nil, apperrors.Wrap(err, op, "install rollout")
+	}
+	if err := s.persistDurable(ctx, walOp{Kind: walKindBinding, Binding: &walBinding{AgentID: threadID, CodexThreadID: codexThreadID, RolloutPath: rolloutPath}}); err != nil {
+		logger.Warn("thread/fork: register binding failed",
+			logger.FieldThreadID, threadID,
+			"codex_thread_id", codexThreadID,
+			logger.FieldError, err,
+		)
+	}
+
+	cwd := s.getAgentWorkDir(parentID)
+	if cwd == "" {
+		cwd = "."
+	}
+	if err := s.mgr.Launch(ctx, threadID, threadID, "", cwd, "", s.buildDynamicToolsForThread(threadID, cwd)); err != nil {
+		_ = os.Remove(rolloutPath)
+		return nil, apperrors.Wrap(err, op, "launch forked thread")
+	}
+	proc := s.mgr.Get(threadID)
+	if proc == nil {
+		_ = os.Remove(rolloutPath)
+		return nil, apperrors.Newf(op, "thread %s launched but not found", threadID)
+	}
+	if err := traceCodex(ctx, threadID, "resume", func() error {
+		return proc.Client.ResumeThread(codex.ResumeThreadRequest{ThreadID: codexThreadID, Path: rolloutPath, Cwd: cwd})
+	}); err != nil {
+		_ = s.mgr.Stop(threadID)
+		_ = os.Remove(rolloutPath)
+		return nil, apperrors.Wrap(err, op, "resume forked rollout")
+	}
+	s.setAgentWorkDir(threadID, cwd)
+
+	if skills := s.GetAgentSkills(parentID); len(skills) > 0 {
+		s.skillsMu.Lock()
+		s.agentSkills[threadID] = skills
+		s.skillsMu.Unlock()
+	}
+	name := s.threadDisplayName(parentID)
+	if s.uiRuntime != nil {
+		s.uiRuntime.ReplaceThreads(buildThreadSnapshots(s.mgr.List()))
+		if msgs, err := s.loadAllThreadMessagesFromCodexRollout(ctx, threadID); err != nil {
+			logger.Warn("thread/fork: rebuild timeline failed", logger.FieldThreadID, threadID, logger.FieldError, err)
+		} else if len(msgs) > 0 {
+			s.uiRuntime.HydrateHistory(threadID, msgsToRecords(msgs))
+		}
+		if name != "" {
+			s.uiRuntime.SetThreadName(threadID, name)
+		}
+	}
+	if name != "" {
+		if err := s.persistDurable(ctx, walOp{Kind: walKindAlias, Alias: &walAlias{ThreadID: threadID, Alias: name}}); err != nil {
+			logger.Warn("thread/fork: persist alias failed", logger.FieldThreadID, threadID, logger.FieldError, err)
+		}
+	}
+	s.watchThreadWorkspace(threadID, cwd)
+	s.recordThreadLineage(ctx, store.ThreadLineage{
+		ChildThreadID:  threadID,
+		ParentThreadID: parentID,
+		TurnIndex:      &turnIndex,
+		CodexThreadID:  codexThreadID,
+	})
+
+	logger.Info("thread/fork: forked at turn",
+		logger.FieldThreadID, threadID,
+		"parent_thread_id", parentID,
+		"turn_index", turnIndex,
+		"parent_turns", turns,
+		"codex_thread_id", codexThreadID,
+		logger.FieldPath, rolloutPath,
+	)
+	return threadForkResponse{
+		Thread:        threadInfo{ID: threadID, Status: "running", ForkedFrom: parentID},
+		TurnIndex:     &turnIndex,
+		CodexThreadID: codexThreadID,
+		RolloutPath:   rolloutPath,
+	}, nil
+}
+
+// threadLineageResponse thread/lineage 响应。
+type threadLineageResponse struct {
+	ThreadID  string                `json:"threadId"`
+	Parent    *store.ThreadLineage  `json:"parent,omitempty"`
+	Ancestors []store.ThreadLineage `json:"ancestors"` // 由近及远, 首项即 parent
+	Children  []store.ThreadLineage `json:"children"`
+}
+
+// threadLineageTyped 线程的分叉来源与子线程 (JSON-RPC: thread/lineage)。
+func (s *Server) threadLineageTyped(ctx context.Context, p threadIDParams) (any, error) {
+	const op = "Server.threadLineage"
+	threadID := strings.TrimSpace(p.ThreadID)
+	if threadID == "" {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "threadId is required")
+	}
+	resp := threadLineageResponse{ThreadID: threadID, Ancestors: []store.ThreadLineage{}}
+	seen := map[string]bool{threadID: true}
+	for cur := threadID; len(resp.Ancestors) < maxThreadLineageDepth; {
+		parent, err := s.threadParent(ctx, cur)
+		if err != nil {
+			return nil, apperrors.Wrap(err, op, "load parent")
+		}
+		if parent == nil || seen[parent.ParentThreadID] {
+			break
+		}
+		resp.Ancestors = append(resp.Ancestors, *parent)
+		seen[parent.ParentThreadID] = true
+		cur = parent.ParentThreadID
+	}
+	if len(resp.Ancestors) > 0 {
+		resp.Parent = &resp.Ancestors[0]
+	}
+	children, err := s.threadChildren(ctx, threadID)
+	if err != nil {
+		return nil, apperrors.Wrap(err, op, "load children")
+	}
+	if children == nil {
+		children = []store.ThreadLineage{}
+	}
+	resp.Children = children
+	return resp, nil
+}
@@ -0,0 +1,106 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/codex"
+	"github.com/multi-agent/go-agent-v2/internal/runner"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+func TestThreadFork_AtTurnIndexRecordsLineage(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	mgr := runner.NewAgentManager()
+	mgr.SetClientFactory(codex.MockClientFactory(codex.DefaultMockScript, 0))
+	srv := New(Deps{Manager: mgr})
+	ctx := context.Background()
+
+	res, err := srv.InvokeMethod(ctx, "thread/start", json.RawMessage(`{"cwd":"."}`))
+	if err != nil {
+		t.Fatalf("thread/start: %v", err)
+	}
+	parentID := res.(threadStartResponse).Thread.ID
+	const parentCodexID = "11111111-2222-4333-8444-555555555555"
+	if err := mgr.Get(parentID).Client.ResumeThread(codex.ResumeThreadRequest{ThreadID: parentCodexID}); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	dir := filepath.Join(home, ".codex", "sessions", now.Format("2006"), now.Format("01"), now.Format("02"))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	rollout := `{"type":"session_meta","payload":{"id":"` + parentCodexID + `"}}
+{"type":"turn_context","payload":{}}
+{"type":"response_item","payload":{"type":"message","role":"user","content":[{"type":"input_text","text":"first question"}]}}
+{"type":"response_item","payload":{"type":"message","role":"assistant","content":[{"type":"output_text","text":"first answer"}]}}
+{"type":"turn_context","payload":{}}
+{"type":"response_item","payload":{"type":"message","role":"user","content":[{"type":"input_text","text":"second question"}]}}
+{"type":"response_item","payload":{"type":"message","role":"assistant","content":[{"type":"output_text","text":"second answer"}]}}
+`
+	if err := os.WriteFile(filepath.Join(dir, "rollout-2026-01-01T00-00-00-"+parentCodexID+".jsonl"), []byte(rollout), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.InvokeMethod(ctx, "thread/name/set", json.RawMessage(`{"threadId":"`+parentID+`","name":"design"}`)); err != nil {
+		t.Fatalf("thread/name/set: %v", err)
+	}
+	srv.skillsMu.Lock()
+	srv.agentSkills[parentID] = []string{"go-review"}
+	srv.skillsMu.Unlock()
+
+	if _, err := srv.InvokeMethod(ctx, "thread/fork", json.RawMessage(`{"threadId":"`+parentID+`","turnIndex":2}`)); apperrors.CodeOf(err) != errcode.InvalidInput {
+		t.Fatalf("out of range err = %v", err)
+	}
+	res, err = srv.InvokeMethod(ctx, "thread/fork", json.RawMessage(`{"threadId":"`+parentID+`","turnIndex":0}`))
+	if err != nil {
+		t.Fatalf("thread/fork: %v", err)
+	}
+	fork := res.(threadForkResponse)
+	childID := fork.Thread.ID
+	if fork.Thread.ForkedFrom != parentID || childID == parentID || mgr.Get(childID) == nil {
+		t.Fatalf("fork = %+v", fork)
+	}
+	if got := mgr.Get(childID).Client.GetThreadID(); got != fork.CodexThreadID {
+		t.Fatalf("child codex thread = %q, want %q", got, fork.CodexThreadID)
+	}
+	data, err := os.ReadFile(fork.RolloutPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text := string(data); !strings.Contains(text, "first answer") || strings.Contains(text, "second") || strings.Contains(text, parentCodexID) {
+		t.Fatalf("forked rollout = %s", text)
+	}
+	if got := srv.threadDisplayName(childID); got != "design" {
+		t.Fatalf("child name = %q", got)
+	}
+	if got := srv.GetAgentSkills(childID); len(got) != 1 || got[0] != "go-review" {
+		t.Fatalf("child skills = %v", got)
+	}
+	timeline := srv.uiRuntime.ThreadTimeline(childID)
+	if len(timeline) != 2 || timeline[1].Text != "first answer" {
+		t.Fatalf("child timeline = %+v", timeline)
+	}
+
+	res, err = srv.InvokeMethod(ctx, "thread/lineage", json.RawMessage(`{"threadId":"`+childID+`"}`))
+	if err != nil {
+		t.Fatalf("thread/lineage: %v", err)
+	}
+	lineage := res.(threadLineageResponse)
+	if lineage.Parent == nil || lineage.Parent.ParentThreadID != parentID || lineage.Parent.TurnIndex == nil || *lineage.Parent.TurnIndex != 0 {
+		t.Fatalf("child lineage = %+v", lineage)
+	}
+	res, err = srv.InvokeMethod(ctx, "thread/lineage", json.RawMessage(`{"threadId":"`+parentID+`"}`))
+	if err != nil {
+		t.Fatalf("thread/lineage: %v", err)
+	}
+	if lineage = res.(threadLineageResponse); lineage.Parent != nil || len(lineage.Children) != 1 || lineage.Children[0].ChildThreadID != childID {
+		t.Fatalf("parent lineage = %+v", lineage)
+	}
+}
@@ -0,0 +1,398 @@
+// thread_search.go — 线程时间线全文检索 (thread/search)。
+//
+// 索引内容: assistant 消息、命令行、文件名 (文件编辑条目与带文件的工具调用)。
+// 后台按 threadSearchIndexInterval 增量扫描 UI 时间线 (含 thread/messages 加载的历史),
+// 有数据库时写入 thread_search_docs (tsvector + GIN), 无数据库时写入进程内索引。
+// 运行中线程的最后一条 assistant 消息仍在流式追加, 待 turn 结束后再索引。
+// 结果返回 threadId + itemId + 摘要 (highlights 为摘要内按 rune 计的命中区间), 供 UI 深链定位。
+package apiserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/multi-agent/go-agent-v2/internal/store"
+	"github.com/multi-agent/go-agent-v2/internal/uistate"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+	"github.com/multi-agent/go-agent-v2/pkg/util"
+)
+
+const (
+	threadSearchIndexInterval = 15 * time.Second
+	threadSearchSyncTimeout   = 10 * time.Second
+	maxThreadSearchDocChars   = 16 << 10
+	maxThreadSearchMemoryDocs = 50000
+	threadSearchSnippetRadius = 60
+	defaultThreadSearchLimit  = 50
+	maxThreadSearchLimit      = 200
+
+	searchKindAssistant = "assistant"
+	searchKindCommand   = "command"
+	searchKindFile      = "file"
+)
+
+var threadSearchKinds = map[string]bool{searchKindAssistant: true, searchKindCommand: true, searchKindFile: true}
+
+// threadSearchIndex 增量索引状态与无数据库时的进程内文档。
+type threadSearchIndex struct {
+	syncMu sync.Mutex
+
+	mu      sync.Mutex
+	indexed map[string]map[string]struct{} // threadID → 已索引 itemID
+	docs    map[string]store.ThreadSearchDoc
+	order   []string // 进程内文档写入顺序 (超出上限时淘汰最旧)
+}
+
+func (idx *threadSearchIndex) isIndexed(threadID, itemID string) bool {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	_, ok := idx.indexed[threadID][itemID]
+	return ok
+}
+
+func (idx *threadSearchIndex) markIndexed(docs []store.ThreadSearchDoc) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.indexed == nil {
+		idx.indexed = map[string]map[string]struct{}{}
+	}
+	for _, d := range docs {
+		items := idx.indexed[d.ThreadID]
+		if items == nil {
+			items = map[string]struct{}{}
+			idx.indexed[d.ThreadID] = items
+		}
+		items[d.ItemID] = struct{}{}
+	}
+}
+
+// forgetMissing 清理已不在 UI 时间线中的线程/条目记录 (时间线清空后重新加载可再次索引)。
+func (idx *threadSearchIndex) forgetMissing(timelines map[string][]uistate.TimelineItem) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	for threadID, items := range idx.indexed {
+		timeline, ok := timelines[threadID]
+		if !ok {
+			delete(idx.indexed, threadID)
+			continue
+		}
+		live := make(map[string]struct{}, len(timeline))
+		for _, item := range timeline {
+			live[item.ID] = struct{}{}
+		}
+		for itemID := range items {
+			if _, ok := live[itemID]; !ok {
+				delete(items, itemID)
+			}
+		}
+	}
+}
+
+func threadSearchDocKey(d store.ThreadSearchDoc) string {
+	return d.ThreadID + "\x00" + d.Kind + "\x00" + d.DocHash
+}
+
+// putMemory 写入进程内文档 (同键覆盖 itemId)。
+func (idx *threadSearchIndex) putMemory(docs []store.ThreadSearchDoc) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.docs == nil {
+		idx.docs = map[string]store.ThreadSearchDoc{}
+	}
+	for _, d := range docs {
+		key := threadSearchDocKey(d)
+		if _, ok := idx.docs[key]; !ok {
+			idx.order = append(idx.order, key)
+		}
+		idx.docs[key] = d
+	}
+	for len(idx.order) > maxThreadSearchMemoryDocs {
+		delete(idx.docs, idx.order[0])
+		idx.order = idx.order[1:]
+	}
+}
+
+// searchMemory 子串匹配 (查询词全部命中), 按命中次数与时间倒序。
+func (idx *threadSearchIndex) searchMemory(q store.ThreadSearchQuery) []store.ThreadSearchHit {
+	terms := searchTerms(q.Query)
+	if len(terms) == 0 {
+		return []store.ThreadSearchHit{}
+	}
+	kinds := map[string]bool{}
+	for _, kind := range q.Kinds {
+		kinds[kind] = true
+	}
+	idx.mu.Lock()
+	hits := make([]store.ThreadSearchHit, 0)
+	for _, d := range idx.docs {
+		if q.ThreadID != "" && d.ThreadID != q.ThreadID {
+			continue
+		}
+		if len(kinds) > 0 && !kinds[d.Kind] {
+			continue
+		}
+		lower := strings.ToLower(d.Content)
+		score := 0
+		for _, term := range terms {
+			n := strings.Count(lower, term)
+			if n == 0 {
+				score = 0
+				break
+			}
+			score += n
+		}
+		if score > 0 {
+			hits = append(hits, store.ThreadSearchHit{ThreadSearchDoc: d, Score: float64(score)})
+		}
+	}
+	idx.mu.Unlock()
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Ts.After(hits[j].Ts)
+	})
+	if q.Limit > 0 && len(hits) > q.Limit {
+		hits = hits[:q.Limit]
+	}
+	return hits
+}
+
+func searchTerms(query string) []string {
+	return strings.Fields(strings.ToLower(query))
+}
+
+// searchDocsFromTimeline 提取可检索条目; active=true 时跳过仍在流式追加的末尾 assistant 消息。
+func searchDocsFromTimeline(threadID string, items []uistate.TimelineItem, active bool, skip func(itemID string) bool) []store.ThreadSearchDoc {
+	var docs []store.ThreadSearchDoc
+	for i, item := range items {
+		if item.ID == "" || (skip != nil && skip(item.ID)) {
+			continue
+		}
+		var kind, content string
+		switch item.Kind {
+		case "assistant":
+			if active && i == len(items)-1 {
+				continue
+			}
+			kind, content = searchKindAssistant, item.Text
+		case "command":
+			kind, content = searchKindCommand, item.Command
+		case "file", "tool":
+			kind, content = searchKindFile, item.File
+		}
+		content = strings.TrimSpace(content)
+		if kind == "" || content == "" {
+			continue
+		}
+		content = truncateRunes(content, maxThreadSearchDocChars)
+		ts, err := time.Parse(time.RFC3339, item.Ts)
+		if err != nil {
+			ts = time.Now()
+		}
+		sum := sha256.Sum256([]byte(kind + "\x00" + item.Ts + "\x00" + content))
+		docs = append(docs, store.ThreadSearchDoc{
+			ThreadID: threadID,
+			Kind:     kind,
+			DocHash:  hex.EncodeToString(sum[:16]),
+			ItemID:   item.ID,
+			Content:  content,
+			Ts:       ts,
+		})
+	}
+	return docs
+}
+
+func truncateRunes(s string, limit int) string {
+	if utf8.RuneCountInString(s) <= limit {
+		return s
+	}
+	return string([]rune(s)[:limit])
+}
+
+// startThreadSearchIndexer 周期增量索引 UI 时间线。
+func (s *Server) startThreadSearchIndexer(ctx context.Context) {
+	if s.uiRuntime == nil {
+		return
+	}
+	util.SafeGo(func() {
+		ticker := time.NewTicker(threadSearchIndexInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if err := s.syncThreadSearchIndex(ctx); err != nil {
+				logger.Warn("thread search: index sync failed", logger.FieldError, err)
+			}
+		}
+	})
+}
+
+// syncThreadSearchIndex 将尚未索引的时间线条目写入索引 (串行执行)。
+func (s *Server) syncThreadSearchIndex(ctx context.Context) error {
+	if s.uiRuntime == nil {
+		return nil
+	}
+	s.threadSearch.syncMu.Lock()
+	defer s.threadSearch.syncMu.Unlock()
+
+	timelines, _ := s.uiRuntime.AllTimelinesAndDiffs()
+	s.threadSearch.forgetMissing(timelines)
+	active := s.uiRuntime.SnapshotLight().InterruptibleByThread
+
+	var docs []store.ThreadSearchDoc
+	for threadID, items := range timelines {
+		docs = append(docs, searchDocsFromTimeline(threadID, items, active[threadID], func(itemID string) bool {
+			return s.threadSearch.isIndexed(threadID, itemID)
+		})...)
+	}
+	if len(docs) == 0 {
+		return nil
+	}
+	if s.threadSearchStore != nil {
+		ctx, cancel := context.WithTimeout(ctx, threadSearchSyncTimeout)
+		defer cancel()
+		if err := s.threadSearchStore.UpsertDocs(ctx, docs); err != nil {
+			return apperrors.Wrap(err, "Server.syncThreadSearchIndex", "upsert search docs")
+		}
+	} else {
+		s.threadSearch.putMemory(docs)
+	}
+	s.threadSearch.markIndexed(docs)
+	logger.Debug("thread search: indexed", logger.FieldCount, len(docs))
+	return nil
+}
+
+type threadSearchParams struct {
+	Query    string   `json:"query"`
+	ThreadID string   `json:"threadId,omitempty"`
+	Kinds    []string `json:"kinds,omitempty"` // assistant / command / file
+	Limit    int      `json:"limit,omitempty"`
+}
+
+// threadSearchHit thread/search 单条结果。
+type threadSearchHit struct {
+	ThreadID   string   `json:"threadId"`
+	ItemID     string   `json:"itemId"`
+	Kind       string   `json:"kind"`
+	Ts         string   `json:"ts"`
+	Snippet    string   `json:"snippet"`
+	Highlights [][2]int `json:"highlights"`
+	Score      float64  `json:"score"`
+}
+
+// threadSearchTyped 跨线程全文检索 (JSON-RPC: thread/search)。
+func (s *Server) threadSearchTyped(ctx context.Context, p threadSearchParams) (any, error) {
+	const op = "Server.threadSearch"
+	query := strings.TrimSpace(p.Query)
+	if query == "" {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "query is required")
+	}
+	for _, kind := range p.Kinds {
+		if !threadSearchKinds[kind] {
+			return nil, apperrors.NewCodef(op, errcode.InvalidInput, "unknown kind %q (want assistant / command / file)", kind)
+		}
+	}
+	limit := p.Limit
+	if limit <= 0 {
+		limit = defaultThreadSearchLimit
+	}
+	limit = min(limit, maxThreadSearchLimit)
+
+	// 先补齐最近的时间线变更, 保证刚完成的消息可被检索。
+	if err := s.syncThreadSearchIndex(ctx); err != nil {
+		logger.Warn("thread/search: index sync failed", logger.FieldError, err)
+	}
+	q := store.ThreadSearchQuery{Query: query, ThreadID: strings.TrimSpace(p.ThreadID), Kinds: p.Kinds, Limit: limit}
+	var (
+		raw    []store.ThreadSearchHit
+		source = "memory"
+	)
+	if s.threadSearchStore != nil {
+		source = "postgres"
+		var err error
+		if raw, err = s.threadSearchStore.Search(ctx, q); err != nil {
+			return nil, apperrors.Wrap(err, op, "search docs")
+		}
+	} else {
+		raw = s.threadSearch.searchMemory(q)
+	}
+
+	hits := make([]threadSearchHit, 0, len(raw))
+	for _, h := range raw {
+		snippet, highlights := buildSearchSnippet(h.Content, query, threadSearchSnippetRadius)
+		hits = append(hits, threadSearchHit{
+			ThreadID:   h.ThreadID,
+			ItemID:     h.ItemID,
+			Kind:       h.Kind,
+			Ts:         h.Ts.UTC().Format(time.RFC3339),
+			Snippet:    snippet,
+			Highlights: highlights,
+			Score:      h.Score,
+		})
+	}
+	return map[string]any{"query": query, "hits": hits, "source": source}, nil
+}
+
+// buildSearchSnippet 以首个命中为中心截取摘要, 返回摘要内的命中区间 ([start, end) rune 偏移)。
+func buildSearchSnippet(content, query string, radius int) (string, [][2]int) {
+	runes := []rune(content)
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+	var matches [][2]int
+	for _, term := range searchTerms(query) {
+		t := []rune(term)
+		for i := 0; i+len(t) <= len(lower); i++ {
+			if string(lower[i:i+len(t)]) == term {
+				matches = append(matches, [2]int{i, i + len(t)})
+				i += len(t) - 1
+			}
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i][0] < matches[j][0] })
+
+	start, end := 0, min(len(runes), 2*radius)
+	if len(matches) > 0 {
+		start = max(0, matches[0][0]-radius)
+		end = min(len(runes), matches[0][1]+radius)
+	}
+	prefix, suffix := "", ""
+	if start > 0 {
+		prefix = "…"
+	}
+	if end < len(runes) {
+		suffix = "…"
+	}
+	offset := utf8.RuneCountInString(prefix)
+	highlights := [][2]int{}
+	last := -1
+	for _, m := range matches {
+		if m[0] < start || m[1] > end || m[0] < last {
+			continue
+		}
+		highlights = append(highlights, [2]int{m[0] - start + offset, m[1] - start + offset})
+		last = m[1]
+	}
+	// 换行等空白替换为空格 (逐 rune 替换, 不改变偏移)。
+	snippet := strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return ' '
+		}
+		return r
+	}, string(runes[start:end]))
+	return prefix + snippet + suffix, highlights
+}
@@ -0,0 +1,96 @@
+package apiserver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/codex"
+	"github.com/multi-agent/go-agent-v2/internal/uistate"
+)
+
+func TestSearchDocsFromTimeline(t *testing.T) {
+	items := []uistate.TimelineItem{
+		{ID: "u1", Kind: "user", Text: "please fix the build"},
+		{ID: "c1", Kind: "command", Command: "go test ./...", Ts: "2026-01-01T00:00:00Z"},
+		{ID: "f1", Kind: "file", File: "internal/store/cache.go"},
+		{ID: "t1", Kind: "tool", Tool: "lsp_hover"},
+		{ID: "a1", Kind: "assistant", Text: "Fixed the flaky test."},
+		{ID: "a2", Kind: "assistant", Text: "Still stream"},
+	}
+	docs := searchDocsFromTimeline("th-1", items, true, nil)
+	kinds := map[string]string{}
+	for _, d := range docs {
+		kinds[d.ItemID] = d.Kind
+	}
+	want := map[string]string{"c1": searchKindCommand, "f1": searchKindFile, "a1": searchKindAssistant}
+	if len(kinds) != len(want) {
+		t.Fatalf("docs = %+v", docs)
+	}
+	for id, kind := range want {
+		if kinds[id] != kind {
+			t.Errorf("kind[%s] = %q, want %q", id, kinds[id], kind)
+		}
+	}
+	if got := searchDocsFromTimeline("th-1", items, false, func(id string) bool { return id != "a2" }); len(got) != 1 || got[0].ItemID != "a2" {
+		t.Fatalf("idle thread must index trailing assistant message, got %+v", got)
+	}
+
+	again := searchDocsFromTimeline("th-1", []uistate.TimelineItem{{ID: "c9", Kind: "command", Command: "go test ./...", Ts: "2026-01-01T00:00:00Z"}}, false, nil)
+	if again[0].DocHash != docs[0].DocHash {
+		t.Fatal("re-hydrated item with same content and ts must map to the same doc hash")
+	}
+}
+
+func TestBuildSearchSnippet(t *testing.T) {
+	content := "很长的前缀内容用于截断摘要开头 the Quick brown fox\njumps over the quick dog"
+	snippet, highlights := buildSearchSnippet(content, "quick", 10)
+	runes := []rune(snippet)
+	if len(highlights) == 0 {
+		t.Fatalf("no highlights in %q", snippet)
+	}
+	for _, h := range highlights {
+		if got := string(runes[h[0]:h[1]]); got != "Quick" && got != "quick" {
+			t.Fatalf("highlight %v = %q in %q", h, got, snippet)
+		}
+	}
+	if runes[0] != '…' {
+		t.Fatalf("snippet %q should be truncated at start", snippet)
+	}
+
+	if s, h := buildSearchSnippet("no match here", "zzz", 5); s != "no match h…" || len(h) != 0 {
+		t.Fatalf("no-match snippet = %q, %v", s, h)
+	}
+}
+
+func TestThreadSearchMemoryIndex(t *testing.T) {
+	rt := uistate.NewRuntimeManager()
+	rt.HydrateHistory("th-a", []uistate.HistoryRecord{
+		{ID: 1, Role: "user", Content: "how do I rotate the logs"},
+		{ID: 2, Role: "assistant", EventType: codex.EventAgentMessage, Content: "Use the log retention worker to rotate logs.", CreatedAt: time.Now()},
+	})
+	rt.HydrateHistory("th-b", []uistate.HistoryRecord{
+		{ID: 1, Role: "assistant", EventType: codex.EventAgentMessage, Content: "Nothing about that topic.", CreatedAt: time.Now()},
+	})
+	srv := &Server{uiRuntime: rt}
+
+	res, err := srv.threadSearchTyped(context.Background(), threadSearchParams{Query: "Retention worker"})
+	if err != nil {
+		t.Fatalf("thread/search: %v", err)
+	}
+	body := res.(map[string]any)
+	hits := body["hits"].([]threadSearchHit)
+	if body["source"] != "memory" || len(hits) != 1 || hits[0].ThreadID != "th-a" || hits[0].Kind != searchKindAssistant || hits[0].ItemID == "" {
+		t.Fatalf("result = %+v", body)
+	}
+	if len(hits[0].Highlights) != 2 {
+		t.Fatalf("highlights = %v", hits[0].Highlights)
+	}
+
+	if _, err := srv.threadSearchTyped(context.Background(), threadSearchParams{Query: "x", Kinds: []string{"bogus"}}); err == nil {
+		t.Fatal("unknown kind must be rejected")
+	}
+	if _, err := srv.threadSearchTyped(context.Background(), threadSearchParams{Query: "  "}); err == nil {
+		t.Fatal("empty query must be rejected")
+	}
+}
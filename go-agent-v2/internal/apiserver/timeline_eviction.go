@@ -0,0 +1,57 @@
+// timeline_eviction.go — UI 时间线内存上限: 周期逐出冷线程时间线, 访问时从 rollout 历史重建。
+//
+// UI_TIMELINE_MAX_MB > 0 时, 估算的时间线内存超过上限即按 LRU 逐出空闲线程的时间线,
+// 直至降到上限的 3/4; 被逐出的线程下次 ThreadTimeline 访问时经 codex rollout 透明重建。
+// 水位与逐出计数见 debug/runtime 的 timeline.memory。
+package apiserver
+
+import (
+	"context"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/uistate"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+	"github.com/multi-agent/go-agent-v2/pkg/util"
+)
+
+const (
+	timelineEvictionInterval     = 30 * time.Second
+	timelineRehydrateLoadTimeout = 10 * time.Second
+)
+
+// startTimelineEvictionLoop 开启时间线逐出 (UI_TIMELINE_MAX_MB = 0 时关闭)。
+func (s *Server) startTimelineEvictionLoop(ctx context.Context) {
+	if s.uiRuntime == nil || s.cfg == nil || s.cfg.UITimelineMaxMB <= 0 {
+		return
+	}
+	s.uiRuntime.SetTimelineEviction(int64(s.cfg.UITimelineMaxMB)<<20, s.loadTimelineHistory)
+	util.SafeGo(func() {
+		ticker := time.NewTicker(timelineEvictionInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				if n := s.uiRuntime.EvictColdTimelines(now); n > 0 {
+					logger.Info("ui timeline: evicted cold thread timelines", logger.FieldCount, n)
+				}
+			}
+		}
+	})
+}
+
+// loadTimelineHistory 读取线程 rollout 历史 (最多 threadMessageHydrationMaxRecords 条) 供重建时间线。
+func (s *Server) loadTimelineHistory(threadID string) ([]uistate.HistoryRecord, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timelineRehydrateLoadTimeout)
+	defer cancel()
+	msgs, err := s.loadAllThreadMessagesFromCodexRollout(ctx, threadID)
+	if err != nil {
+		logger.Warn("ui timeline: rehydrate load failed", logger.FieldThreadID, threadID, logger.FieldError, err)
+		return nil, err
+	}
+	if len(msgs) > threadMessageHydrationMaxRecords {
+		msgs = msgs[len(msgs)-threadMessageHydrationMaxRecords:]
+	}
+	return msgsToRecords(msgs), nil
+}
@@ -0,0 +1,126 @@
+// turn_await.go — turn/await: 阻塞等待线程当前 turn 结束并返回结果摘要。
+//
+// 供 MCP 子代理编排 (await_result) 等外部调用方轮询使用。不消费 turn tracker 的
+// done 通道 (由 turn/start 同步等待路径独占), 而是按固定间隔查询跟踪状态; turn 完成后
+// 其最终状态保留在 turnOutcomes 中, 供完成之后才到达的 await 读取。
+package apiserver
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+const (
+	defaultTurnAwaitTimeoutSec = 60
+	maxTurnAwaitTimeoutSec     = 600
+	turnAwaitPollInterval      = 250 * time.Millisecond
+	maxTurnOutcomes            = 1024
+)
+
+// trackedTurnOutcome 最近一次完成的 turn。
+type trackedTurnOutcome struct {
+	TurnID      string
+	Status      string
+	Reason      string
+	CompletedAt time.Time
+}
+
+// recordTurnOutcomeLocked 记录线程最近一次 turn 的最终状态 (调用方持有 turnMu)。
+func (s *Server) recordTurnOutcomeLocked(threadID, turnID, status, reason string) {
+	if s.turnOutcomes == nil {
+		s.turnOutcomes = make(map[string]trackedTurnOutcome)
+	}
+	if _, ok := s.turnOutcomes[threadID]; !ok && len(s.turnOutcomes) >= maxTurnOutcomes {
+		oldestID := ""
+		var oldest time.Time
+		for id, outcome := range s.turnOutcomes {
+			if oldestID == "" || outcome.CompletedAt.Before(oldest) {
+				oldestID, oldest = id, outcome.CompletedAt
+			}
+		}
+		delete(s.turnOutcomes, oldestID)
+	}
+	s.turnOutcomes[threadID] = trackedTurnOutcome{
+		TurnID:      strings.TrimSpace(turnID),
+		Status:      status,
+		Reason:      reason,
+		CompletedAt: time.Now(),
+	}
+}
+
+func (s *Server) lastTurnOutcome(threadID string) (trackedTurnOutcome, bool) {
+	s.turnMu.Lock()
+	defer s.turnMu.Unlock()
+	outcome, ok := s.turnOutcomes[threadID]
+	return outcome, ok
+}
+
+type turnAwaitParams struct {
+	ThreadID   string `json:"threadId"`
+	TurnID     string `json:"turnId,omitempty"`     // 为空 = 当前/最近一次 turn
+	TimeoutSec *int   `json:"timeoutSec,omitempty"` // 0 = 仅查询不等待
+}
+
+// turnAwaitTyped 等待 turn 结束; 超时返回 status=running 而非错误, 调用方可继续等待。
+func (s *Server) turnAwaitTyped(ctx context.Context, p turnAwaitParams) (any, error) {
+	const op = "Server.turnAwait"
+	threadID := strings.TrimSpace(p.ThreadID)
+	if threadID == "" {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "threadId is required")
+	}
+	wantTurnID := strings.TrimSpace(p.TurnID)
+	timeoutSec := defaultTurnAwaitTimeoutSec
+	if p.TimeoutSec != nil {
+		timeoutSec = *p.TimeoutSec
+	}
+	if timeoutSec < 0 || timeoutSec > maxTurnAwaitTimeoutSec {
+		return nil, apperrors.NewCodef(op, errcode.InvalidInput, "timeoutSec must be between 0 and %d", maxTurnAwaitTimeoutSec)
+	}
+
+	deadline := time.Now().Add(time.Duration(timeoutSec) * time.Second)
+	ticker := time.NewTicker(turnAwaitPollInterval)
+	defer ticker.Stop()
+	for {
+		if result, done := s.turnAwaitResult(threadID, wantTurnID); done || !time.Now().Before(deadline) {
+			return result, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, apperrors.Wrap(ctx.Err(), op, "await cancelled")
+		case <-ticker.C:
+		}
+	}
+}
+
+// turnAwaitResult 返回当前结果快照; done=false 表示目标 turn 仍在运行。
+func (s *Server) turnAwaitResult(threadID, wantTurnID string) (map[string]any, bool) {
+	result := map[string]any{"threadId": threadID}
+	if activeID, startedAt, _, ok := s.peekTrackedTurnMeta(threadID); ok &&
+		(wantTurnID == "" || strings.EqualFold(strings.TrimSpace(activeID), wantTurnID)) {
+		result["turnId"] = activeID
+		result["status"] = "running"
+		result["elapsedMs"] = time.Since(startedAt).Milliseconds()
+		return result, false
+	}
+	outcome, ok := s.lastTurnOutcome(threadID)
+	if !ok || (wantTurnID != "" && !strings.EqualFold(outcome.TurnID, wantTurnID)) {
+		// 未跟踪到 (进程重启或 turn 过旧): 按空闲处理, 由调用方读取时间线。
+		result["turnId"] = wantTurnID
+		result["status"] = "idle"
+		return result, true
+	}
+	result["turnId"] = outcome.TurnID
+	result["status"] = outcome.Status
+	result["completedAt"] = outcome.CompletedAt.UnixMilli()
+	if outcome.Reason != "" {
+		result["reason"] = outcome.Reason
+	}
+	if summary := s.lookupTrackedTurnSummary(threadID, outcome.TurnID); summary != "" {
+		result["summary"] = summary
+	}
+	return result, true
+}
@@ -0,0 +1,54 @@
+package apiserver
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func intPtr(v int) *int { return &v }
+
+func TestTurnAwaitReturnsOutcomeAfterCompletion(t *testing.T) {
+	srv := &Server{
+		activeTurns:         make(map[string]*trackedTurn),
+		turnWatchdogTimeout: time.Minute,
+	}
+	_ = srv.beginTrackedTurn("thread-1", "turn-1")
+
+	out, err := srv.turnAwaitTyped(context.Background(), turnAwaitParams{ThreadID: "thread-1", TimeoutSec: intPtr(0)})
+	if err != nil {
+		t.Fatalf("turnAwait: %v", err)
+	}
+	if got := out.(map[string]any)["status"]; got != "running" {
+		t.Fatalf("peek status = %v, want running", got)
+	}
+
+	time.AfterFunc(100*time.Millisecond, func() {
+		srv.rememberTrackedTurnSummary("thread-1", "turn-1", "all tests pass")
+		srv.completeTrackedTurn("thread-1", "completed", "turn_complete")
+	})
+	out, err = srv.turnAwaitTyped(context.Background(), turnAwaitParams{ThreadID: "thread-1", TurnID: "turn-1", TimeoutSec: intPtr(5)})
+	if err != nil {
+		t.Fatalf("turnAwait: %v", err)
+	}
+	result := out.(map[string]any)
+	if result["status"] != "completed" || result["turnId"] != "turn-1" || result["summary"] != "all tests pass" {
+		t.Fatalf("result = %v", result)
+	}
+
+	// 对较早 turn 的等待不能误用最近结果。
+	out, _ = srv.turnAwaitTyped(context.Background(), turnAwaitParams{ThreadID: "thread-1", TurnID: "turn-0", TimeoutSec: intPtr(0)})
+	if got := out.(map[string]any)["status"]; got != "idle" {
+		t.Fatalf("unknown turn status = %v, want idle", got)
+	}
+}
+
+func TestTurnAwaitValidation(t *testing.T) {
+	srv := &Server{}
+	if _, err := srv.turnAwaitTyped(context.Background(), turnAwaitParams{}); err == nil {
+		t.Fatal("missing threadId must fail")
+	}
+	if _, err := srv.turnAwaitTyped(context.Background(), turnAwaitParams{ThreadID: "t", TimeoutSec: intPtr(maxTurnAwaitTimeoutSec + 1)}); err == nil {
+		t.Fatal("timeout above max must fail")
+	}
+}
@@ -0,0 +1,294 @@
+// turn_dedup.go — 跨线程相同 turn 输入去重 (按项目配置, 可显式绕过)。
+//
+// fan-out / 重试可能在短时间内向多个线程提交完全相同的输入。
+// 开启去重的项目中, 窗口期内的重复 turn/start 不再提交给 codex,
+// 而是直接关联到首个 turn 的结果 (线程 ID / turn ID / 最终回复摘要)。
+//
+// 配置以 UI 偏好存储 (settings.turnDedup), 按项目路径索引:
+//
+//	{"/path/to/project": {"enabled": true, "windowSec": 120}}
+package apiserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+const (
+	prefKeyTurnDedup             = "settings.turnDedup"
+	defaultTurnDedupWindowSec    = 120
+	maxTurnDedupWindowSec        = 24 * 60 * 60
+	turnDedupMaxEntries          = 1024
+	turnDedupStatusInProgress    = "inProgress"
+	turnDedupStatusCompleted     = "completed"
+	turnDedupStatusPendingSubmit = "pending"
+)
+
+// turnDedupPolicy 单个项目的去重策略。
+type turnDedupPolicy struct {
+	Enabled   bool `json:"enabled"`
+	WindowSec int  `json:"windowSec,omitempty"`
+}
+
+func (p turnDedupPolicy) window() time.Duration {
+	sec := p.WindowSec
+	if sec <= 0 {
+		sec = defaultTurnDedupWindowSec
+	}
+	if sec > maxTurnDedupWindowSec {
+		sec = maxTurnDedupWindowSec
+	}
+	return time.Duration(sec) * time.Second
+}
+
+// turnDedupEntry 已提交 turn 的指纹记录。
+type turnDedupEntry struct {
+	ThreadID  string
+	TurnID    string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// turnDedupRef 重复请求关联到的原始 turn。
+type turnDedupRef struct {
+	ThreadID         string `json:"threadId"`
+	TurnID           string `json:"turnId,omitempty"`
+	Status           string `json:"status"`
+	LastAgentMessage string `json:"lastAgentMessage,omitempty"`
+	AgeMS            int64  `json:"ageMs"`
+}
+
+// turnDedupTable 指纹表 (fingerprint → entry)。
+type turnDedupTable struct {
+	mu      sync.Mutex
+	entries map[string]*turnDedupEntry
+}
+
+// turnDedupFingerprint 计算 turn 输入指纹 (不含 threadId, 以支持跨线程去重)。
+func turnDedupFingerprint(project string, p turnStartParams) string {
+	prompt, images, files := extractInputs(p.Input)
+	skills := append([]string(nil), p.SelectedSkills...)
+	sort.Strings(skills)
+
+	h := sha256.New()
+	write := func(parts ...string) {
+		for _, part := range parts {
+			h.Write([]byte(part))
+			h.Write([]byte{0})
+		}
+	}
+	write(normalizeProjectPath(project), strings.TrimSpace(prompt), strings.TrimSpace(p.Model))
+	write(images...)
+	write("|")
+	write(files...)
+	write("|")
+	write(skills...)
+	write("|", strings.TrimSpace(string(p.OutputSchema)))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// reserve 查找窗口期内的原始 turn; 未命中时预留指纹并返回 (nil, true)。
+func (t *turnDedupTable) reserve(fingerprint, threadID string, window time.Duration, now time.Time) (*turnDedupEntry, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.entries == nil {
+		t.entries = make(map[string]*turnDedupEntry)
+	}
+	t.pruneLocked(now)
+	if entry, ok := t.entries[fingerprint]; ok && now.Before(entry.ExpiresAt) {
+		copied := *entry
+		return &copied, false
+	}
+	t.entries[fingerprint] = &turnDedupEntry{
+		ThreadID:  threadID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(window),
+	}
+	return nil, true
+}
+
+// commit 记录预留指纹对应的 turn ID。
+func (t *turnDedupTable) commit(fingerprint, turnID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if entry, ok := t.entries[fingerprint]; ok {
+		entry.TurnID = turnID
+	}
+}
+
+// release 提交失败时撤销预留。
+func (t *turnDedupTable) release(fingerprint string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.entries, fingerprint)
+}
+
+func (t *turnDedupTable) pruneLocked(now time.Time) {
+	for key, entry := range t.entries {
+		if !now.Before(entry.ExpiresAt) {
+			delete(t.entries, key)
+		}
+	}
+	if len(t.entries) <= turnDedupMaxEntries {
+		return
+	}
+	type kv struct {
+		key       string
+		createdAt time.Time
+	}
+	ordered := make([]kv, 0, len(t.entries))
+	for key, entry := range t.entries {
+		ordered = append(ordered, kv{key: key, createdAt: entry.CreatedAt})
+	}
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].createdAt.Before(ordered[j].createdAt) })
+	for i := 0; i < len(ordered)-turnDedupMaxEntries; i++ {
+		delete(t.entries, ordered[i].key)
+	}
+}
+
+// ========================================
+// 策略解析
+// ========================================
+
+func decodeTurnDedupPolicies(value any) map[string]turnDedupPolicy {
+	out := map[string]turnDedupPolicy{}
+	if value == nil {
+		return out
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return out
+	}
+	var decoded map[string]turnDedupPolicy
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return out
+	}
+	for project, policy := range decoded {
+		key := normalizeProjectPath(project)
+		if key == "" {
+			continue
+		}
+		out[key] = policy
+	}
+	return out
+}
+
+func (s *Server) loadTurnDedupPolicies(ctx context.Context) map[string]turnDedupPolicy {
+	if s.prefManager == nil {
+		return map[string]turnDedupPolicy{}
+	}
+	value, err := s.prefManager.Get(ctx, prefKeyTurnDedup)
+	if err != nil {
+		logger.Warn("turn dedup: load preference failed", logger.FieldError, err)
+		return map[string]turnDedupPolicy{}
+	}
+	return decodeTurnDedupPolicies(value)
+}
+
+// resolveTurnDedupPolicy 按项目路径查找策略 (最长前缀匹配, 子目录继承父项目配置)。
+func resolveTurnDedupPolicy(policies map[string]turnDedupPolicy, project string) (string, turnDedupPolicy, bool) {
+	target := normalizeProjectPath(project)
+	if target == "" || len(policies) == 0 {
+		return "", turnDedupPolicy{}, false
+	}
+	bestKey := ""
+	for key := range policies {
+		if target != key && !strings.HasPrefix(target, key+"/") && !strings.HasPrefix(target, key+"\\") {
+			continue
+		}
+		if len(key) > len(bestKey) {
+			bestKey = key
+		}
+	}
+	if bestKey == "" {
+		return "", turnDedupPolicy{}, false
+	}
+	policy := policies[bestKey]
+	return bestKey, policy, policy.Enabled
+}
+
+// resolveTurnProject 解析 turn 所属项目 (显式 cwd 优先, 其次 agent 默认工作目录)。
+func (s *Server) resolveTurnProject(threadID, cwd string) string {
+	if project := normalizeProjectPath(cwd); project != "" && project != "." {
+		return project
+	}
+	return normalizeProjectPath(s.getAgentWorkDir(threadID))
+}
+
+// lookupTurnDedupRef 生成指向原始 turn 的引用 (含运行状态与最终回复摘要)。
+func (s *Server) lookupTurnDedupRef(entry *turnDedupEntry, now time.Time) turnDedupRef {
+	ref := turnDedupRef{
+		ThreadID: entry.ThreadID,
+		TurnID:   entry.TurnID,
+		Status:   turnDedupStatusCompleted,
+		AgeMS:    now.Sub(entry.CreatedAt).Milliseconds(),
+	}
+	if entry.TurnID == "" {
+		ref.Status = turnDedupStatusPendingSubmit
+		return ref
+	}
+	if activeID, _, _, ok := s.peekTrackedTurnMeta(entry.ThreadID); ok && strings.EqualFold(activeID, entry.TurnID) {
+		ref.Status = turnDedupStatusInProgress
+		return ref
+	}
+	ref.LastAgentMessage = s.lookupTrackedTurnSummary(entry.ThreadID, entry.TurnID)
+	return ref
+}
+
+// ========================================
+// config/turnDedup/read, config/turnDedup/write
+// ========================================
+
+type configTurnDedupWriteParams struct {
+	Project   string `json:"project"`
+	Enabled   bool   `json:"enabled"`
+	WindowSec int    `json:"windowSec,omitempty"`
+}
+
+func (s *Server) configTurnDedupRead(ctx context.Context, _ json.RawMessage) (any, error) {
+	return map[string]any{
+		"projects":         s.loadTurnDedupPolicies(ctx),
+		"defaultWindowSec": defaultTurnDedupWindowSec,
+		"prefKey":          prefKeyTurnDedup,
+	}, nil
+}
+
+func (s *Server) configTurnDedupWriteTyped(ctx context.Context, p configTurnDedupWriteParams) (any, error) {
+	if s.prefManager == nil {
+		return nil, apperrors.New("Server.configTurnDedupWrite", "preference manager not initialized")
+	}
+	project := normalizeProjectPath(p.Project)
+	if project == "" {
+		return nil, apperrors.New("Server.configTurnDedupWrite", "project is required")
+	}
+	if p.WindowSec < 0 || p.WindowSec > maxTurnDedupWindowSec {
+		return nil, apperrors.Newf("Server.configTurnDedupWrite", "windowSec must be within [0, %d]", maxTurnDedupWindowSec)
+	}
+
+	s.turnDedupPrefMu.Lock()
+	defer s.turnDedupPrefMu.Unlock()
+	policies := s.loadTurnDedupPolicies(ctx)
+	if !p.Enabled && p.WindowSec == 0 {
+		delete(policies, project)
+	} else {
+		policies[project] = turnDedupPolicy{Enabled: p.Enabled, WindowSec: p.WindowSec}
+	}
+	if err := s.prefManager.Set(ctx, prefKeyTurnDedup, policies); err != nil {
+		return nil, err
+	}
+	logger.Info("config/turnDedup/write: saved",
+		logger.FieldPath, project,
+		"enabled", p.Enabled,
+		"window_sec", p.WindowSec,
+	)
+	return map[string]any{"ok": true, "projects": policies}, nil
+}
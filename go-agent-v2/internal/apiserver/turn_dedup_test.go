@@ -0,0 +1,72 @@
+package apiserver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/uistate"
+)
+
+func TestTurnDedupTableLinksDuplicateWithinWindow(t *testing.T) {
+	var table turnDedupTable
+	now := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	p := turnStartParams{Input: []UserInput{{Type: "text", Text: "run tests"}}, SelectedSkills: []string{"b", "a"}}
+	fp := turnDedupFingerprint("/repo", p)
+
+	if _, reserved := table.reserve(fp, "thread-1", time.Minute, now); !reserved {
+		t.Fatal("first reserve should succeed")
+	}
+	table.commit(fp, "turn-1")
+
+	swapped := p
+	swapped.ThreadID = "thread-2"
+	swapped.SelectedSkills = []string{"a", "b"}
+	if got := turnDedupFingerprint("/repo", swapped); got != fp {
+		t.Fatal("fingerprint should ignore threadId and skill order")
+	}
+	original, reserved := table.reserve(fp, "thread-2", time.Minute, now.Add(10*time.Second))
+	if reserved || original == nil || original.ThreadID != "thread-1" || original.TurnID != "turn-1" {
+		t.Fatalf("duplicate reserve = (%+v, %v), want link to thread-1/turn-1", original, reserved)
+	}
+
+	if _, reserved := table.reserve(fp, "thread-3", time.Minute, now.Add(2*time.Minute)); !reserved {
+		t.Fatal("reserve after window should succeed")
+	}
+	table.release(fp)
+	if _, reserved := table.reserve(fp, "thread-4", time.Minute, now.Add(2*time.Minute)); !reserved {
+		t.Fatal("reserve after release should succeed")
+	}
+
+	if turnDedupFingerprint("/other", p) == fp {
+		t.Fatal("fingerprint should differ across projects")
+	}
+}
+
+func TestConfigTurnDedupWriteAndResolve(t *testing.T) {
+	srv := &Server{prefManager: uistate.NewPreferenceManager(nil)}
+	ctx := context.Background()
+
+	if _, err := srv.configTurnDedupWriteTyped(ctx, configTurnDedupWriteParams{Enabled: true}); err == nil {
+		t.Fatal("expected missing project error")
+	}
+	if _, err := srv.configTurnDedupWriteTyped(ctx, configTurnDedupWriteParams{Project: "/repo", Enabled: true, WindowSec: 30}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	policies := srv.loadTurnDedupPolicies(ctx)
+	key, policy, enabled := resolveTurnDedupPolicy(policies, "/repo/sub/dir")
+	if !enabled || key != "/repo" || policy.window() != 30*time.Second {
+		t.Fatalf("resolve = (%q, %+v, %v), want /repo enabled 30s", key, policy, enabled)
+	}
+	if _, _, enabled := resolveTurnDedupPolicy(policies, "/repository"); enabled {
+		t.Fatal("sibling path with shared prefix should not match")
+	}
+
+	if _, err := srv.configTurnDedupWriteTyped(ctx, configTurnDedupWriteParams{Project: "/repo"}); err != nil {
+		t.Fatalf("disable: %v", err)
+	}
+	if len(srv.loadTurnDedupPolicies(ctx)) != 0 {
+		t.Fatal("disabling without window should remove project policy")
+	}
+}
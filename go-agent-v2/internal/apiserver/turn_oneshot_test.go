@@ -0,0 +1,99 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/multi-agent/go-agent-v2/internal/codex"
+	"github.com/multi-agent/go-agent-v2/internal/runner"
+	"github.com/multi-agent/go-agent-v2/internal/store"
+)
+
+// 去重命中时 turn 未提交, 模板/人格指令与交接摘要须留给下一次 turn。
+func TestTurnStart_OneShotPromptsSurviveDedup(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	mgr := runner.NewAgentManager()
+	mgr.SetClientFactory(codex.MockClientFactory(nil, 0))
+	srv := New(Deps{Manager: mgr})
+	ctx := context.Background()
+
+	res, err := srv.InvokeMethod(ctx, "thread/start", json.RawMessage(`{"cwd":"."}`))
+	if err != nil {
+		t.Fatalf("thread/start: %v", err)
+	}
+	threadID := res.(threadStartResponse).Thread.ID
+	project := t.TempDir()
+	if _, err := srv.configTurnDedupWriteTyped(ctx, configTurnDedupWriteParams{Project: project, Enabled: true, WindowSec: 60}); err != nil {
+		t.Fatalf("enable dedup: %v", err)
+	}
+	arm := func() {
+		srv.agentTemplates.set(threadID, threadTemplateBinding{Template: agentTemplate{BaseInstructions: "review only"}, PendingInstructions: true})
+		srv.personalities.bind(threadID, store.Personality{Name: "terse", Instructions: "be terse"})
+		srv.handoffs.save(store.ThreadHandoff{ThreadID: threadID, Status: handoffStatusCaptured, Summary: "half done"})
+	}
+	start := func() turnStartResponse {
+		t.Helper()
+		params, _ := json.Marshal(map[string]any{"threadId": threadID, "cwd": project, "input": []map[string]any{{"type": "text", "text": "same prompt"}}})
+		out, err := srv.InvokeMethod(ctx, "turn/start", params)
+		if err != nil {
+			t.Fatalf("turn/start: %v", err)
+		}
+		return out.(turnStartResponse)
+	}
+
+	arm()
+	if first := start(); first.DedupOf != nil {
+		t.Fatalf("first turn deduped: %+v", first)
+	}
+	if srv.agentTemplates.pendingInstructions(threadID) != "" || srv.personalities.pendingInstructions(threadID) != "" || srv.pendingThreadHandoff(ctx, threadID) != nil {
+		t.Fatal("one-shot prompts must be consumed by a submitted turn")
+	}
+
+	arm()
+	if second := start(); second.DedupOf == nil {
+		t.Fatalf("second turn should be deduped: %+v", second)
+	}
+	if srv.agentTemplates.pendingInstructions(threadID) != "review only" {
+		t.Fatal("template instructions lost on dedup")
+	}
+	if srv.personalities.pendingInstructions(threadID) != "be terse" {
+		t.Fatal("personality instructions lost on dedup")
+	}
+	if srv.pendingThreadHandoff(ctx, threadID) == nil {
+		t.Fatal("handoff summary lost on dedup")
+	}
+}
+
+// 同一线程并发 turn/start 时一次性提示词只由占用者注入; 释放后未消费的提示词仍可被下一次 turn 取得。
+func TestClaimTurnOneShotPrompts_SingleOwner(t *testing.T) {
+	srv := &Server{}
+	ctx := context.Background()
+	srv.personalities.bind("t1", store.Personality{Name: "terse", Instructions: "be terse"})
+
+	first, releaseFirst := srv.claimTurnOneShotPrompts(ctx, "t1")
+	if first.Personality != "be terse" {
+		t.Fatalf("first claim = %+v", first)
+	}
+	if second, releaseSecond := srv.claimTurnOneShotPrompts(ctx, "t1"); !second.empty() {
+		t.Fatalf("concurrent claim must not see the prompt: %+v", second)
+	} else {
+		releaseSecond()
+	}
+	if other, releaseOther := srv.claimTurnOneShotPrompts(ctx, "t2"); !other.empty() {
+		t.Fatalf("other thread claim = %+v", other)
+	} else {
+		releaseOther()
+	}
+
+	releaseFirst() // 未消费 (如去重命中) 即释放
+	retry, releaseRetry := srv.claimTurnOneShotPrompts(ctx, "t1")
+	if retry.Personality != "be terse" {
+		t.Fatalf("released prompt should be claimable again: %+v", retry)
+	}
+	srv.consumeTurnOneShotPrompts(ctx, "t1", retry)
+	releaseRetry()
+	if again, _ := srv.claimTurnOneShotPrompts(ctx, "t1"); !again.empty() {
+		t.Fatalf("consumed prompt claimed again: %+v", again)
+	}
+}
@@ -0,0 +1,385 @@
+// turn_quality_gate.go — 诊断门禁: turn 修改的文件存在新增 LSP 错误时拒绝标记 completed。
+//
+// 启用后 (TURN_QUALITY_GATE_ENABLED 或 turn/start 传 qualityGate=true):
+//   - turn 提交前记录各文件 error 级诊断基线
+//   - codex 报告 turn 完成时, 重新同步本轮变更文件并等待诊断稳定
+//   - 存在新增错误: 推送 turn/qualityGate (status=retrying) 并要求 agent 修复, 最多重试 N 次
+//   - 重试耗尽: turn 以 failed (reason=quality_gate_failed) 结束
+package apiserver
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/lsp"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+	"github.com/multi-agent/go-agent-v2/pkg/util"
+)
+
+const (
+	defaultQualityGateMaxRetries = 2
+	defaultQualityGateSettle     = 1500 * time.Millisecond
+	maxQualityGateFailures       = 50
+
+	qualityGateStatusPassed   = "passed"
+	qualityGateStatusRetrying = "retrying"
+	qualityGateStatusFailed   = "failed"
+)
+
+// qualityGateFailure 单条新增错误。
+type qualityGateFailure struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Source  string `json:"source,omitempty"`
+	Message string `json:"message"`
+}
+
+// qualityGateThread 单线程当前 turn 的门禁状态。
+type qualityGateThread struct {
+	baseline   map[string]map[string]int // abs path → error message → count
+	touched    map[string]struct{}       // abs path
+	attempts   int
+	evaluating bool
+}
+
+// turnQualityGate 诊断门禁状态表 (nil 接收者安全)。
+type turnQualityGate struct {
+	mu               sync.Mutex
+	enabledByDefault bool
+	maxRetries       int
+	settle           time.Duration
+	threads          map[string]*qualityGateThread
+}
+
+func newTurnQualityGate(enabledByDefault bool, maxRetries int, settle time.Duration) *turnQualityGate {
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+	if settle <= 0 {
+		settle = defaultQualityGateSettle
+	}
+	return &turnQualityGate{
+		enabledByDefault: enabledByDefault,
+		maxRetries:       maxRetries,
+		settle:           settle,
+		threads:          make(map[string]*qualityGateThread),
+	}
+}
+
+// enabledFor 解析单次 turn 是否启用门禁 (请求参数优先于全局配置)。
+func (g *turnQualityGate) enabledFor(override *bool) bool {
+	if g == nil {
+		return false
+	}
+	if override != nil {
+		return *override
+	}
+	return g.enabledByDefault
+}
+
+// begin 以当前诊断为基线开始跟踪 (覆盖同线程旧状态)。
+func (g *turnQualityGate) begin(threadID string, baseline map[string]map[string]int) {
+	if g == nil || threadID == "" {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.threads[threadID] = &qualityGateThread{baseline: baseline, touched: make(map[string]struct{})}
+}
+
+// touch 记录本轮变更文件 (线程未启用门禁时忽略)。
+func (g *turnQualityGate) touch(threadID string, files []string) {
+	if g == nil || len(files) == 0 {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	state, ok := g.threads[threadID]
+	if !ok {
+		return
+	}
+	for _, file := range files {
+		state.touched[file] = struct{}{}
+	}
+}
+
+// finish 清除线程门禁状态。
+func (g *turnQualityGate) finish(threadID string) {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.threads, threadID)
+}
+
+// startEvaluation 标记进入评估阶段; 返回变更文件 (排序) 与是否为新一轮评估。
+//
+// 已在评估中时返回 (nil, false, true): 调用方应继续挂起完成事件。
+func (g *turnQualityGate) startEvaluation(threadID string) (files []string, start bool, held bool) {
+	if g == nil {
+		return nil, false, false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	state, ok := g.threads[threadID]
+	if !ok {
+		return nil, false, false
+	}
+	if state.evaluating {
+		return nil, false, true
+	}
+	if len(state.touched) == 0 {
+		return nil, false, false
+	}
+	state.evaluating = true
+	files = make([]string, 0, len(state.touched))
+	for file := range state.touched {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	return files, true, true
+}
+
+// recordFailure 累计失败次数; 返回当前尝试序号与是否还可重试。
+func (g *turnQualityGate) recordFailure(threadID string) (int, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	state, ok := g.threads[threadID]
+	if !ok {
+		return 0, false
+	}
+	state.attempts++
+	state.evaluating = false
+	return state.attempts, state.attempts <= g.maxRetries
+}
+
+func (g *turnQualityGate) baselineFor(threadID string) map[string]map[string]int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if state, ok := g.threads[threadID]; ok {
+		return state.baseline
+	}
+	return nil
+}
+
+// diagnosticURIPath 将诊断 URI (file://...) 还原为本地路径。
+func diagnosticURIPath(uri string) string {
+	if !strings.HasPrefix(uri, "file://") {
+		return filepath.Clean(uri)
+	}
+	if parsed, err := url.Parse(uri); err == nil && parsed.Path != "" {
+		return filepath.Clean(filepath.FromSlash(parsed.Path))
+	}
+	return filepath.Clean(strings.TrimPrefix(uri, "file://"))
+}
+
+// snapshotErrorDiagnostics 按文件统计当前 error 级诊断 (消息 → 次数, 行号会随编辑漂移故不参与比较)。
+func (s *Server) snapshotErrorDiagnostics() map[string]map[string]int {
+	s.diagMu.RLock()
+	defer s.diagMu.RUnlock()
+	snapshot := make(map[string]map[string]int)
+	s.diagCache.each(func(_, uri string, diags []lsp.Diagnostic) {
+		counts := map[string]int{}
+		for _, d := range diags {
+			if d.Severity == lsp.SeverityError {
+				counts[d.Message]++
+			}
+		}
+		if len(counts) > 0 {
+			snapshot[diagnosticURIPath(uri)] = counts
+		}
+	})
+	return snapshot
+}
+
+// newQualityGateFailures 返回 files 中相对基线新增的 error 级诊断。
+func (s *Server) newQualityGateFailures(files []string, baseline map[string]map[string]int) []qualityGateFailure {
+	wanted := make(map[string]struct{}, len(files))
+	for _, file := range files {
+		wanted[file] = struct{}{}
+	}
+	current := make(map[string][]lsp.Diagnostic, len(files))
+	s.diagMu.RLock()
+	s.diagCache.each(func(_, uri string, diags []lsp.Diagnostic) {
+		path := diagnosticURIPath(uri)
+		if _, ok := wanted[path]; ok {
+			current[path] = diags
+		}
+	})
+	s.diagMu.RUnlock()
+
+	var failures []qualityGateFailure
+	for _, file := range files {
+		remaining := make(map[string]int, len(baseline[file]))
+		for message, count := range baseline[file] {
+			remaining[message] = count
+		}
+		for _, d := range current[file] {
+			if d.Severity != lsp.SeverityError {
+				continue
+			}
+			if remaining[d.Message] > 0 {
+				remaining[d.Message]--
+				continue
+			}
+			failures = append(failures, qualityGateFailure{
+				File:    file,
+				Line:    d.Range.Start.Line + 1,
+				Column:  d.Range.Start.Character + 1,
+				Source:  d.Source,
+				Message: d.Message,
+			})
+			if len(failures) >= maxQualityGateFailures {
+				return failures
+			}
+		}
+	}
+	return failures
+}
+
+// beginTurnQualityGate turn 提交前调用: 启用时记录诊断基线。
+func (s *Server) beginTurnQualityGate(threadID string, enabled bool) {
+	if !enabled {
+		s.qualityGate.finish(threadID)
+		return
+	}
+	s.qualityGate.begin(threadID, s.snapshotErrorDiagnostics())
+}
+
+// touchTurnQualityGate 记录 turn 内变更文件 (相对路径按 agent 工作目录解析)。
+func (s *Server) touchTurnQualityGate(threadID string, files []string) {
+	if s.qualityGate == nil || len(files) == 0 {
+		return
+	}
+	s.qualityGate.touch(threadID, s.resolveThreadFilePaths(threadID, files))
+}
+
+// resolveThreadFilePaths 将事件中的相对路径按线程工作目录解析为绝对路径。
+func (s *Server) resolveThreadFilePaths(threadID string, files []string) []string {
+	baseDir := s.getAgentWorkDir(threadID)
+	resolved := make([]string, 0, len(files))
+	for _, file := range files {
+		path := strings.TrimSpace(file)
+		if path == "" {
+			continue
+		}
+		if !filepath.IsAbs(path) && baseDir != "" {
+			path = filepath.Join(baseDir, path)
+		}
+		resolved = append(resolved, normalizeAgentWorkDir(path))
+	}
+	return resolved
+}
+
+// holdTurnForQualityGate 拦截 completed 终态事件。
+//
+// held=true: 跳过本事件的 turn 终结 (门禁评估中); suppress=true: 事件本身也暂不广播,
+// 评估结束后由 finishQualityGatedEvent 重新派发。
+func (s *Server) holdTurnForQualityGate(threadID, eventType, method string, payload map[string]any) (held bool, suppress bool) {
+	if s.qualityGate == nil {
+		return false, false
+	}
+	_, status, _, terminal, synthetic := trackedTurnTerminalFromEvent(eventType, method, payload)
+	if !terminal || normalizeTrackedTurnStatus(status) != "completed" {
+		return false, false
+	}
+	turnID, _, interruptRequested, ok := s.peekTrackedTurnMeta(threadID)
+	if !ok || interruptRequested {
+		return false, false
+	}
+	files, start, held := s.qualityGate.startEvaluation(threadID)
+	if !held {
+		return false, false
+	}
+	if !start {
+		// 评估期间的重复完成事件 (如 thread/status idle): 仅跳过终结。
+		return true, !synthetic
+	}
+	util.SafeGo(func() { s.runTurnQualityGate(threadID, turnID, eventType, method, payload, files) })
+	return true, !synthetic
+}
+
+// runTurnQualityGate 重新同步变更文件、等待诊断稳定后判定门禁结果。
+func (s *Server) runTurnQualityGate(threadID, turnID, eventType, method string, payload map[string]any, files []string) {
+	if s.lsp != nil {
+		for _, file := range files {
+			if !s.supportsLSPFileType(file) {
+				continue
+			}
+			if err := s.lsp.BootstrapDocument(file); err != nil {
+				logger.Debug("quality gate: refresh document failed", logger.FieldThreadID, threadID, logger.FieldPath, file, logger.FieldError, err)
+			}
+		}
+	}
+	time.Sleep(s.qualityGate.settle)
+
+	failures := s.newQualityGateFailures(files, s.qualityGate.baselineFor(threadID))
+	notice := map[string]any{
+		"threadId":   threadID,
+		"turnId":     turnID,
+		"files":      files,
+		"failures":   failures,
+		"maxRetries": s.qualityGate.maxRetries,
+	}
+	if len(failures) == 0 {
+		s.qualityGate.finish(threadID)
+		notice["status"] = qualityGateStatusPassed
+		s.Notify("turn/qualityGate", notice)
+		s.finishQualityGatedEvent(threadID, eventType, method, payload)
+		return
+	}
+
+	attempt, retry := s.qualityGate.recordFailure(threadID)
+	notice["attempt"] = attempt
+	if retry {
+		if proc := s.mgr.Get(threadID); proc != nil {
+			s.touchTrackedTurnLastEvent(threadID)
+			err := proc.Client.Submit(buildQualityGateRetryPrompt(failures, attempt, s.qualityGate.maxRetries), nil, nil, nil)
+			if err == nil {
+				notice["status"] = qualityGateStatusRetrying
+				s.Notify("turn/qualityGate", notice)
+				logger.Info("quality gate: new diagnostics, asking agent to fix",
+					logger.FieldThreadID, threadID, logger.FieldTurnID, turnID,
+					"attempt", attempt, "failures", len(failures))
+				return
+			}
+			logger.Warn("quality gate: submit retry failed", logger.FieldThreadID, threadID, logger.FieldError, err)
+		}
+	}
+
+	s.qualityGate.finish(threadID)
+	notice["status"] = qualityGateStatusFailed
+	s.Notify("turn/qualityGate", notice)
+	logger.Warn("quality gate: turn failed with new diagnostics",
+		logger.FieldThreadID, threadID, logger.FieldTurnID, turnID,
+		"attempt", attempt, "failures", len(failures))
+	if completion, ok := s.completeTrackedTurnByID(threadID, turnID, "failed", "quality_gate_failed"); ok {
+		mergeTrackedTurnCompletionPayload(payload, completion)
+	}
+	s.finishQualityGatedEvent(threadID, eventType, method, payload)
+}
+
+// finishQualityGatedEvent 重新派发被挂起的完成事件 (UI 状态 → turn 终结 → 广播)。
+func (s *Server) finishQualityGatedEvent(threadID, eventType, method string, payload map[string]any) {
+	s.applyAgentEventToUIRuntime(threadID, eventType, method, payload)
+	s.maybeFinalizeTrackedTurn(threadID, eventType, method, payload)
+	s.maybeAutoReportOrchestrationCompletion(threadID, eventType, method, payload)
+	s.Notify(method, payload)
+}
+
+func buildQualityGateRetryPrompt(failures []qualityGateFailure, attempt, maxRetries int) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "[Quality gate] Files changed in this turn have new errors (retry %d/%d). Fix them before finishing:\n", attempt, maxRetries)
+	for _, f := range failures {
+		fmt.Fprintf(&sb, "- %s:%d:%d %s\n", f.File, f.Line, f.Column, f.Message)
+	}
+	sb.WriteString("Use lsp_diagnostics to confirm the errors are resolved.")
+	return sb.String()
+}
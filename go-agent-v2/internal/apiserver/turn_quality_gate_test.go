@@ -0,0 +1,78 @@
+package apiserver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/lsp"
+)
+
+func TestQualityGateReportsOnlyNewErrorsInTouchedFiles(t *testing.T) {
+	errAt := func(line int, msg string) lsp.Diagnostic {
+		return lsp.Diagnostic{Severity: lsp.SeverityError, Message: msg, Range: lsp.Range{Start: lsp.Position{Line: line}}}
+	}
+	srv := &Server{diagCache: lspDiagnosticCache{"": {
+		"file:///repo/a.go": {errAt(3, "undefined: foo")},
+		"file:///repo/b.go": {errAt(1, "old error")},
+	}}}
+	baseline := srv.snapshotErrorDiagnostics()
+	if baseline["/repo/a.go"]["undefined: foo"] != 1 {
+		t.Fatalf("baseline = %+v", baseline)
+	}
+
+	srv.diagCache.set("", "file:///repo/a.go", []lsp.Diagnostic{
+		errAt(9, "undefined: foo"), // 行号漂移的既有错误不算新增
+		errAt(10, "missing return"),
+		{Severity: lsp.SeverityWarning, Message: "unused"},
+	})
+	srv.diagCache.set("", "file:///repo/c.go", []lsp.Diagnostic{errAt(0, "untouched file")})
+
+	failures := srv.newQualityGateFailures([]string{"/repo/a.go", "/repo/b.go"}, baseline)
+	if len(failures) != 1 || failures[0].File != "/repo/a.go" || failures[0].Message != "missing return" || failures[0].Line != 11 {
+		t.Fatalf("failures = %+v, want only missing return in a.go", failures)
+	}
+}
+
+func TestQualityGateHoldsCompletionAndLimitsRetries(t *testing.T) {
+	gate := newTurnQualityGate(true, 1, time.Millisecond)
+	srv := &Server{
+		activeTurns:         make(map[string]*trackedTurn),
+		turnWatchdogTimeout: time.Second,
+		diagCache:           lspDiagnosticCache{},
+		qualityGate:         gate,
+	}
+	completed := map[string]any{"turn": map[string]any{"id": "turn-1", "status": "completed"}}
+
+	srv.beginTurnQualityGate("thread-1", gate.enabledFor(nil))
+	_ = srv.beginTrackedTurn("thread-1", "turn-1")
+	if held, _ := srv.holdTurnForQualityGate("thread-1", "turn_complete", "turn/completed", completed); held {
+		t.Fatal("turn without file changes should not be held")
+	}
+
+	gate.touch("thread-1", []string{"/repo/a.go"})
+	files, start, held := gate.startEvaluation("thread-1")
+	if !start || !held || len(files) != 1 {
+		t.Fatalf("startEvaluation = (%v, %v, %v)", files, start, held)
+	}
+	if _, start, held := gate.startEvaluation("thread-1"); start || !held {
+		t.Fatal("duplicate completion during evaluation should stay held without restarting")
+	}
+	if attempt, retry := gate.recordFailure("thread-1"); attempt != 1 || !retry {
+		t.Fatalf("first failure = (%d, %v), want retry", attempt, retry)
+	}
+	if attempt, retry := gate.recordFailure("thread-1"); attempt != 2 || retry {
+		t.Fatalf("second failure = (%d, %v), want exhausted", attempt, retry)
+	}
+
+	if _, ok := srv.completeTrackedTurn("thread-1", "failed", "quality_gate_failed"); !ok {
+		t.Fatal("expected tracked turn completion")
+	}
+	if _, _, held := gate.startEvaluation("thread-1"); held {
+		t.Fatal("completing the tracked turn should clear gate state")
+	}
+
+	disabled := false
+	if gate.enabledFor(&disabled) {
+		t.Fatal("per-turn override should disable gate")
+	}
+}
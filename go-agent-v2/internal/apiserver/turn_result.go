@@ -0,0 +1,445 @@
+// turn_result.go — turn/start outputSchema 的服务端校验与 turn/result/get。
+//
+// 带 outputSchema 的 turn 完成后, 从最终助手回复中提取 JSON (整段 / ```json 代码块 /
+// 首尾括号区间) 并按 schema 校验 (见 json_schema.go)。不合法时在同一线程上重新提示,
+// 附上违例列表要求只回复修正后的 JSON, 最多 outputSchemaRetries 次 (缺省 2);
+// 重新提示的 turn 不写入时间线用户消息。结果 (valid / invalid / failed) 经
+// turn/result/ready 通知, 并可用 turn/result/get 按线程或 turn ID 查询解析后的对象。
+// 同一线程上的新 turn/start 会放弃未完成的重新提示。
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+	"github.com/multi-agent/go-agent-v2/pkg/util"
+)
+
+const (
+	turnResultPending = "pending"
+	turnResultValid   = "valid"
+	turnResultInvalid = "invalid"
+	turnResultFailed  = "failed" // turn 未正常完成 / 被新 turn 取代
+
+	defaultOutputSchemaRetries = 2
+	maxOutputSchemaRetries     = 5
+	maxTurnResultsPerThread    = 20
+	maxTurnResultThreads       = 256
+	maxTurnResultRawRunes      = 8000
+)
+
+// turnResultRecord 一次带 outputSchema 的 turn/start 的校验结果。
+type turnResultRecord struct {
+	ThreadID    string          `json:"threadId"`
+	TurnID      string          `json:"turnId"`                // turn/start 返回的 ID (排队时为队列 ID)
+	TurnIDs     []string        `json:"turnIds"`               // 各次尝试的 turn ID
+	FinalTurnID string          `json:"finalTurnId,omitempty"` // 给出最终结果的 turn
+	Status      string          `json:"status"`                // pending / valid / invalid / failed
+	Result      json.RawMessage `json:"result,omitempty"`      // 通过校验的结构化对象
+	Errors      []string        `json:"errors,omitempty"`      // 最近一次校验的违例
+	Raw         string          `json:"raw,omitempty"`         // 未通过校验时的最后一次回复
+	Reason      string          `json:"reason,omitempty"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"maxAttempts"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	UpdatedAt   time.Time       `json:"updatedAt"`
+}
+
+// matches 按 turn/start 返回的 ID 或任一尝试的 turn ID 匹配。
+func (r *turnResultRecord) matches(turnID string) bool {
+	return strings.EqualFold(r.TurnID, turnID) || containsFold(r.TurnIDs, turnID)
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// turnOutputState 单个线程上进行中的结构化输出校验。
+type turnOutputState struct {
+	gen    int64
+	schema *jsonSchema
+	params turnStartParams // 原始 turn/start 参数 (重新提示沿用 cwd / 模型 / schema)
+	turnID string          // 当前尝试的 turn ID, 空 = 匹配线程上下一个结束的 turn
+	record *turnResultRecord
+}
+
+// turnResultTable 进行中的校验与最近的结果 (零值可用)。
+type turnResultTable struct {
+	mu      sync.Mutex
+	gen     int64
+	active  map[string]*turnOutputState    // threadID →
+	results map[string][]*turnResultRecord // threadID → 最近的结果, 新的在后
+}
+
+// begin 登记新的校验 (替换线程上已有的), 返回其 generation。
+func (t *turnResultTable) begin(p turnStartParams, schema *jsonSchema, maxAttempts int) int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.active == nil {
+		t.active = make(map[string]*turnOutputState)
+		t.results = make(map[string][]*turnResultRecord)
+	}
+	t.supersedeLocked(p.ThreadID)
+	t.gen++
+	now := time.Now()
+	record := &turnResultRecord{
+		ThreadID:    p.ThreadID,
+		TurnIDs:     []string{},
+		Status:      turnResultPending,
+		MaxAttempts: maxAttempts,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	p.Retry = nil
+	p.OutputSchemaRetries = nil
+	t.active[p.ThreadID] = &turnOutputState{gen: t.gen, schema: schema, params: p, record: record}
+	t.storeLocked(record)
+	return t.gen
+}
+
+// bind 回填 turn/start 的结果: 失败或命中去重时撤销登记。
+func (t *turnResultTable) bind(threadID string, gen int64, res any, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	state := t.active[threadID]
+	if state == nil || state.gen != gen {
+		return
+	}
+	resp, _ := res.(turnStartResponse)
+	if err != nil || resp.DedupOf != nil {
+		delete(t.active, threadID)
+		t.removeLocked(state.record)
+		return
+	}
+	state.record.TurnID = resp.Turn.ID
+	if resp.Queue == nil && resp.Held == nil && state.turnID == "" && len(state.record.TurnIDs) == 0 {
+		state.turnID = resp.Turn.ID
+	}
+}
+
+// drop 放弃线程上未完成的校验 (新的 turn/start 不带 outputSchema 时)。
+func (t *turnResultTable) drop(threadID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.supersedeLocked(threadID)
+}
+
+func (t *turnResultTable) supersedeLocked(threadID string) {
+	state := t.active[threadID]
+	if state == nil {
+		return
+	}
+	delete(t.active, threadID)
+	state.record.Status = turnResultFailed
+	state.record.Reason = "superseded by a new turn"
+	state.record.UpdatedAt = time.Now()
+}
+
+func (t *turnResultTable) storeLocked(record *turnResultRecord) {
+	list := append(t.results[record.ThreadID], record)
+	if len(list) > maxTurnResultsPerThread {
+		list = list[len(list)-maxTurnResultsPerThread:]
+	}
+	t.results[record.ThreadID] = list
+	if len(t.results) <= maxTurnResultThreads {
+		return
+	}
+	oldestID := ""
+	var oldest time.Time
+	for id, records := range t.results {
+		if _, running := t.active[id]; running {
+			continue
+		}
+		if updated := records[len(records)-1].UpdatedAt; oldestID == "" || updated.Before(oldest) {
+			oldestID, oldest = id, updated
+		}
+	}
+	delete(t.results, oldestID)
+}
+
+func (t *turnResultTable) removeLocked(record *turnResultRecord) {
+	list := t.results[record.ThreadID]
+	for i, r := range list {
+		if r == record {
+			list = append(list[:i:i], list[i+1:]...)
+			break
+		}
+	}
+	if len(list) == 0 {
+		delete(t.results, record.ThreadID)
+		return
+	}
+	t.results[record.ThreadID] = list
+}
+
+// lookup 返回结果快照; turnID 为空时取线程上最近一次。
+func (t *turnResultTable) lookup(threadID, turnID string) (turnResultRecord, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	list := t.results[threadID]
+	for i := len(list) - 1; i >= 0; i-- {
+		if turnID == "" || list[i].matches(turnID) {
+			return snapshotTurnResult(list[i]), true
+		}
+	}
+	return turnResultRecord{}, false
+}
+
+func snapshotTurnResult(r *turnResultRecord) turnResultRecord {
+	out := *r
+	out.TurnIDs = append([]string(nil), r.TurnIDs...)
+	out.Errors = append([]string(nil), r.Errors...)
+	return out
+}
+
+// normalizeOutputSchemaRetries 校验 outputSchemaRetries, 返回最大尝试次数 (含首次)。
+func normalizeOutputSchemaRetries(retries *int) (int, error) {
+	n := defaultOutputSchemaRetries
+	if retries != nil {
+		n = *retries
+	}
+	if n < 0 || n > maxOutputSchemaRetries {
+		return 0, apperrors.NewCodef("Server.turnStart", errcode.InvalidInput, "outputSchemaRetries must be between 0 and %d", maxOutputSchemaRetries)
+	}
+	return n + 1, nil
+}
+
+// beginTurnOutputValidation 编译 outputSchema 并登记校验, 返回 generation (0 = 无 schema)。
+func (s *Server) beginTurnOutputValidation(p turnStartParams) (int64, error) {
+	const op = "Server.turnStart"
+	if p.skipOutputValidation {
+		return 0, nil
+	}
+	if len(p.OutputSchema) == 0 || string(p.OutputSchema) == "null" {
+		if p.OutputSchemaRetries != nil {
+			return 0, apperrors.NewCode(op, errcode.InvalidInput, "outputSchemaRetries requires outputSchema")
+		}
+		s.turnResults.drop(p.ThreadID)
+		return 0, nil
+	}
+	schema, err := compileJSONSchema(p.OutputSchema)
+	if err != nil {
+		return 0, apperrors.NewCodef(op, errcode.InvalidInput, "outputSchema: %v", err)
+	}
+	maxAttempts, err := normalizeOutputSchemaRetries(p.OutputSchemaRetries)
+	if err != nil {
+		return 0, err
+	}
+	return s.turnResults.begin(p, schema, maxAttempts), nil
+}
+
+// finishTurnOutput 在 turn 结束时开始校验; retrying 表示 turn 重试策略已安排重新提交。
+func (s *Server) finishTurnOutput(threadID, turnID, status string, retrying bool) {
+	t := &s.turnResults
+	t.mu.Lock()
+	state := t.active[threadID]
+	if state == nil || (state.turnID != "" && turnID != "" && !strings.EqualFold(state.turnID, turnID)) {
+		t.mu.Unlock()
+		return
+	}
+	if retrying {
+		// 重试提交的 turn 沿用本次校验。
+		state.turnID = ""
+		t.mu.Unlock()
+		return
+	}
+	record := state.record
+	if turnID != "" && !containsFold(record.TurnIDs, turnID) {
+		record.TurnIDs = append(record.TurnIDs, turnID)
+	}
+	record.UpdatedAt = time.Now()
+	if status != "completed" {
+		delete(t.active, threadID)
+		record.Status = turnResultFailed
+		record.FinalTurnID = turnID
+		record.Reason = "turn " + status
+		snapshot := snapshotTurnResult(record)
+		t.mu.Unlock()
+		s.notifyTurnResult(snapshot)
+		return
+	}
+	record.Attempts++
+	gen := state.gen
+	t.mu.Unlock()
+	util.SafeGo(func() { s.validateTurnOutput(threadID, turnID, gen) })
+}
+
+// validateTurnOutput 校验回复, 不合法且仍有余量时重新提示。
+func (s *Server) validateTurnOutput(threadID, turnID string, gen int64) {
+	text := s.awaitTurnReplyText(threadID, turnID)
+
+	t := &s.turnResults
+	t.mu.Lock()
+	state := t.active[threadID]
+	if state == nil || state.gen != gen {
+		t.mu.Unlock()
+		return
+	}
+	value, errs := extractStructuredOutput(text, state.schema)
+	record := state.record
+	record.FinalTurnID = turnID
+	record.Errors = errs
+	record.UpdatedAt = time.Now()
+	if len(errs) == 0 {
+		delete(t.active, threadID)
+		record.Status = turnResultValid
+		record.Result = value
+		record.Raw = ""
+		snapshot := snapshotTurnResult(record)
+		t.mu.Unlock()
+		logger.Info("turn/result: structured output valid",
+			logger.FieldThreadID, threadID, logger.FieldTurnID, turnID, "attempts", snapshot.Attempts)
+		s.notifyTurnResult(snapshot)
+		return
+	}
+	record.Raw = truncateRunes(text, maxTurnResultRawRunes)
+	if record.Attempts >= record.MaxAttempts {
+		delete(t.active, threadID)
+		record.Status = turnResultInvalid
+		snapshot := snapshotTurnResult(record)
+		t.mu.Unlock()
+		logger.Warn("turn/result: structured output invalid, retries exhausted",
+			logger.FieldThreadID, threadID, logger.FieldTurnID, turnID,
+			"attempts", snapshot.Attempts, "errors", len(errs))
+		s.notifyTurnResult(snapshot)
+		return
+	}
+	state.turnID = ""
+	p := state.params
+	p.Input = []UserInput{{Type: "text", Text: buildOutputRepairPrompt(errs)}}
+	p.SelectedSkills = nil
+	p.BypassDedup = true
+	p.IdempotencyKey = ""
+	p.retryAttempt = record.Attempts + 1
+	attempt, maxAttempts := p.retryAttempt, record.MaxAttempts
+	t.mu.Unlock()
+
+	logger.Warn("turn/result: structured output invalid, re-prompting",
+		logger.FieldThreadID, threadID, logger.FieldTurnID, turnID,
+		"attempt", attempt, "max_attempts", maxAttempts, "errors", len(errs))
+	s.Notify("turn/result/retry", map[string]any{
+		"threadId":    threadID,
+		"turnId":      turnID,
+		"attempt":     attempt,
+		"maxAttempts": maxAttempts,
+		"errors":      errs,
+	})
+	s.resubmitTurnOutput(threadID, gen, p)
+}
+
+// resubmitTurnOutput 提交重新提示 turn (期间线程上有新 turn/start 时放弃)。
+func (s *Server) resubmitTurnOutput(threadID string, gen int64, p turnStartParams) {
+	res, err := s.startTurn(context.Background(), p)
+	t := &s.turnResults
+	t.mu.Lock()
+	state := t.active[threadID]
+	if state == nil || state.gen != gen {
+		t.mu.Unlock()
+		return
+	}
+	if err != nil {
+		delete(t.active, threadID)
+		state.record.Status = turnResultInvalid
+		state.record.Reason = "re-prompt failed: " + err.Error()
+		state.record.UpdatedAt = time.Now()
+		snapshot := snapshotTurnResult(state.record)
+		t.mu.Unlock()
+		logger.Error("turn/result: re-prompt submit failed",
+			logger.FieldThreadID, threadID, "attempt", p.retryAttempt, logger.FieldError, err)
+		s.notifyTurnResult(snapshot)
+		return
+	}
+	if resp, _ := res.(turnStartResponse); resp.Queue == nil && resp.Held == nil && state.turnID == "" && state.record.Attempts < p.retryAttempt {
+		state.turnID = resp.Turn.ID
+	}
+	t.mu.Unlock()
+}
+
+func (s *Server) notifyTurnResult(record turnResultRecord) {
+	s.Notify("turn/result/ready", record)
+}
+
+// buildOutputRepairPrompt 要求模型按违例修正回复。
+func buildOutputRepairPrompt(errs []string) string {
+	var b strings.Builder
+	b.WriteString("Your previous reply did not satisfy the required output JSON schema:\n")
+	for _, e := range errs {
+		b.WriteString("- ")
+		b.WriteString(e)
+		b.WriteString("\n")
+	}
+	b.WriteString("Reply again with only the corrected JSON value that conforms to the schema. ")
+	b.WriteString("Do not run any tools and do not add commentary.")
+	return b.String()
+}
+
+// extractStructuredOutput 从回复中提取首个通过校验的 JSON 值; 都不通过时返回首个可解析候选的违例。
+func extractStructuredOutput(text string, schema *jsonSchema) (json.RawMessage, []string) {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return nil, []string{"$: reply is empty"}
+	}
+	candidates := []string{trimmed}
+	fences := reviewJSONFence.FindAllStringSubmatch(trimmed, -1)
+	for i := len(fences) - 1; i >= 0; i-- {
+		candidates = append(candidates, fences[i][1])
+	}
+	for _, pair := range [][2]string{{"{", "}"}, {"[", "]"}} {
+		if start, end := strings.Index(trimmed, pair[0]), strings.LastIndex(trimmed, pair[1]); start >= 0 && end > start {
+			candidates = append(candidates, trimmed[start:end+1])
+		}
+	}
+	var firstErrs []string
+	for _, candidate := range candidates {
+		candidate = strings.TrimSpace(candidate)
+		var value any
+		if json.Unmarshal([]byte(candidate), &value) != nil {
+			continue
+		}
+		errs := schema.validate(value)
+		if len(errs) == 0 {
+			return json.RawMessage(candidate), nil
+		}
+		if firstErrs == nil {
+			firstErrs = errs
+		}
+	}
+	if firstErrs == nil {
+		firstErrs = []string{"$: reply does not contain a JSON value"}
+	}
+	return nil, firstErrs
+}
+
+type turnResultGetParams struct {
+	ThreadID string `json:"threadId"`
+	TurnID   string `json:"turnId,omitempty"` // 缺省取线程上最近一次带 outputSchema 的 turn
+}
+
+// turnResultGetTyped 结构化输出的校验结果 (JSON-RPC: turn/result/get)。
+func (s *Server) turnResultGetTyped(_ context.Context, p turnResultGetParams) (any, error) {
+	const op = "Server.turnResultGet"
+	threadID := strings.TrimSpace(p.ThreadID)
+	if threadID == "" {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "threadId is required")
+	}
+	turnID := strings.TrimSpace(p.TurnID)
+	record, ok := s.turnResults.lookup(threadID, turnID)
+	if !ok {
+		if turnID == "" {
+			return nil, apperrors.NewCodef(op, errcode.NotFound, "no structured output result for thread %s", threadID)
+		}
+		return nil, apperrors.NewCodef(op, errcode.NotFound, "no structured output result for turn %s", turnID)
+	}
+	return record, nil
+}
@@ -0,0 +1,136 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/codex"
+	"github.com/multi-agent/go-agent-v2/internal/runner"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+func TestExtractStructuredOutput(t *testing.T) {
+	schema, err := compileJSONSchema(json.RawMessage(`{"type":"object","required":["ok"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, errs := extractStructuredOutput("Here you go:\n```json\n{\"draft\":1}\n```\nfinal:\n```json\n{\"ok\":true}\n```", schema)
+	if len(errs) != 0 || string(value) != `{"ok":true}` {
+		t.Fatalf("value = %s errs = %v", value, errs)
+	}
+	if _, errs := extractStructuredOutput(`result: {"nope":1}`, schema); len(errs) != 1 || !strings.Contains(errs[0], `"ok"`) {
+		t.Fatalf("errs = %v", errs)
+	}
+	if _, errs := extractStructuredOutput("no json here", schema); len(errs) != 1 || !strings.Contains(errs[0], "does not contain") {
+		t.Fatalf("errs = %v", errs)
+	}
+}
+
+func TestTurnStart_OutputSchemaRepromptsUntilValid(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	var mu sync.Mutex
+	var prompts []string
+	script := func(turnID, prompt string) []codex.MockStep {
+		mu.Lock()
+		prompts = append(prompts, prompt)
+		mu.Unlock()
+		reply := `{"summary": 42}`
+		if strings.Contains(prompt, "did not satisfy the required output JSON schema") {
+			reply = "```json\n{\"summary\": \"done\", \"files\": [\"a.go\"]}\n```"
+		}
+		return []codex.MockStep{
+			{Type: codex.EventTurnStarted, Data: map[string]any{"turn": map[string]any{"id": turnID}}},
+			{Type: codex.EventAgentMessageDelta, Data: map[string]any{"delta": reply}},
+			{Type: codex.EventTurnComplete, Data: map[string]any{
+				"turn":               map[string]any{"id": turnID, "status": "completed", "items": []any{}},
+				"last_agent_message": reply,
+			}},
+		}
+	}
+	mgr := runner.NewAgentManager()
+	mgr.SetClientFactory(codex.MockClientFactory(script, 0))
+	srv := New(Deps{Manager: mgr})
+	mgr.SetOnEvent(func(agentID string, event codex.Event) {
+		srv.AgentEventHandler(agentID)(event)
+	})
+	var retries []map[string]any
+	srv.SetNotifyHook(func(method string, params any) {
+		if method == "turn/result/retry" {
+			mu.Lock()
+			retries = append(retries, params.(map[string]any))
+			mu.Unlock()
+		}
+	})
+
+	ctx := context.Background()
+	res, err := srv.InvokeMethod(ctx, "thread/start", json.RawMessage(`{"cwd":"."}`))
+	if err != nil {
+		t.Fatalf("thread/start: %v", err)
+	}
+	threadID := res.(threadStartResponse).Thread.ID
+	schema := json.RawMessage(`{"type":"object","properties":{"summary":{"type":"string"},"files":{"type":"array","items":{"type":"string"}}},"required":["summary","files"]}`)
+	start := func(extra map[string]any) (any, error) {
+		p := map[string]any{"threadId": threadID, "input": []map[string]any{{"type": "text", "text": "summarize"}}}
+		for k, v := range extra {
+			p[k] = v
+		}
+		raw, _ := json.Marshal(p)
+		return srv.InvokeMethod(ctx, "turn/start", raw)
+	}
+	if _, err := start(map[string]any{"outputSchema": json.RawMessage(`{"type":"int"}`)}); apperrors.CodeOf(err) != errcode.InvalidInput {
+		t.Fatalf("bad schema err = %v", err)
+	}
+	res, err = start(map[string]any{"outputSchema": schema})
+	if err != nil {
+		t.Fatalf("turn/start: %v", err)
+	}
+	firstTurnID := res.(turnStartResponse).Turn.ID
+
+	var record turnResultRecord
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(20 * time.Millisecond) {
+		got, err := srv.InvokeMethod(ctx, "turn/result/get", json.RawMessage(`{"threadId":"`+threadID+`"}`))
+		if err != nil {
+			t.Fatalf("turn/result/get: %v", err)
+		}
+		if record = got.(turnResultRecord); record.Status != turnResultPending {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("result still pending: %+v", record)
+		}
+	}
+	if record.Status != turnResultValid || record.Attempts != 2 || record.TurnID != firstTurnID || len(record.TurnIDs) != 2 ||
+		record.FinalTurnID != record.TurnIDs[1] || string(record.Result) != `{"summary": "done", "files": ["a.go"]}` {
+		t.Fatalf("record = %+v result = %s", record, record.Result)
+	}
+	byTurn, err := srv.InvokeMethod(ctx, "turn/result/get", json.RawMessage(`{"threadId":"`+threadID+`","turnId":"`+record.FinalTurnID+`"}`))
+	if err != nil || byTurn.(turnResultRecord).TurnID != firstTurnID {
+		t.Fatalf("lookup by final turn = %+v, %v", byTurn, err)
+	}
+	if _, err := srv.InvokeMethod(ctx, "turn/result/get", json.RawMessage(`{"threadId":"`+threadID+`","turnId":"missing"}`)); apperrors.CodeOf(err) != errcode.NotFound {
+		t.Fatalf("missing turn err = %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(retries) != 1 || retries[0]["attempt"] != 2 {
+		t.Fatalf("turn/result/retry notifications = %#v", retries)
+	}
+	if len(prompts) != 2 || !strings.Contains(prompts[1], "$.summary: expected string, got integer") {
+		t.Fatalf("prompts = %q", prompts)
+	}
+	users := 0
+	for _, item := range srv.uiRuntime.Snapshot().TimelinesByThread[threadID] {
+		if item.Kind == "user" {
+			users++
+		}
+	}
+	if users != 1 {
+		t.Fatalf("timeline user messages = %d", users)
+	}
+}
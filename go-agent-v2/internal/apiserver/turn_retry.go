@@ -0,0 +1,335 @@
+// turn_retry.go — turn/start 的重试策略 (retry: maxAttempts / backoffMs / fallbackModels)。
+//
+// turn 因流错误或诊断门禁 (quality_gate_failed) 失败时, 按指数退避以下一个备用模型重新提交
+// 同一输入 (提交前以 /model 切换 codex 线程模型, 切换后线程保持该模型);
+// 用户中断、卡住处置与 watchdog 超时不重试。同一线程上的新 turn/start 会取消未完成的重试。
+// 每次重试发送 turn/retry 通知, 经历过重试的 turn 结束时在时间线追加 attempt 条目,
+// 标明最终结果来自第几次尝试及所用模型。
+package apiserver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/codex"
+	"github.com/multi-agent/go-agent-v2/internal/uistate"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+const (
+	maxTurnRetryAttempts    = 5
+	defaultTurnRetryBackoff = 2 * time.Second
+	maxTurnRetryBackoff     = time.Minute
+)
+
+// turnRetryNonRetryableReasons 失败但不重试的结束原因 (turn 可能仍在 codex 中运行)。
+var turnRetryNonRetryableReasons = map[string]bool{
+	"watchdog_timeout":       true,
+	"thinking_stall_timeout": true,
+	"thinking_stall_restart": true,
+	"emergency_stop":         true,
+}
+
+// turnRetryPolicy turn/start 的 retry 参数。
+type turnRetryPolicy struct {
+	MaxAttempts    int      `json:"maxAttempts,omitempty"`    // 含首次, 缺省为 1 + len(fallbackModels)
+	BackoffMs      int      `json:"backoffMs,omitempty"`      // 首次重试前等待, 之后每次翻倍
+	FallbackModels []string `json:"fallbackModels,omitempty"` // 第 2..n 次尝试依次使用, 用尽后沿用最后一个
+}
+
+// normalizeTurnRetryPolicy 校验并补全缺省值。
+func normalizeTurnRetryPolicy(p turnRetryPolicy) (turnRetryPolicy, error) {
+	const op = "Server.turnStart"
+	models := make([]string, 0, len(p.FallbackModels))
+	for _, model := range p.FallbackModels {
+		if model = strings.TrimSpace(model); model != "" {
+			models = append(models, model)
+		}
+	}
+	p.FallbackModels = models
+	if p.MaxAttempts == 0 {
+		p.MaxAttempts = 1 + len(models)
+		if p.MaxAttempts < 2 {
+			p.MaxAttempts = 2
+		}
+	}
+	if p.MaxAttempts < 1 || p.MaxAttempts > maxTurnRetryAttempts {
+		return p, apperrors.NewCodef(op, errcode.InvalidInput, "retry.maxAttempts must be between 1 and %d", maxTurnRetryAttempts)
+	}
+	if p.BackoffMs < 0 || time.Duration(p.BackoffMs)*time.Millisecond > maxTurnRetryBackoff {
+		return p, apperrors.NewCodef(op, errcode.InvalidInput, "retry.backoffMs must be between 0 and %d", maxTurnRetryBackoff.Milliseconds())
+	}
+	return p, nil
+}
+
+// modelFor 返回第 attempt 次尝试 (从 1 开始) 使用的模型, 空串表示沿用线程默认模型。
+func (p turnRetryPolicy) modelFor(attempt int, primary string) string {
+	if attempt <= 1 || len(p.FallbackModels) == 0 {
+		return primary
+	}
+	idx := attempt - 2
+	if idx >= len(p.FallbackModels) {
+		idx = len(p.FallbackModels) - 1
+	}
+	return p.FallbackModels[idx]
+}
+
+// backoffFor 返回第 attempt 次尝试 (>= 2) 之前的等待时间。
+func (p turnRetryPolicy) backoffFor(attempt int) time.Duration {
+	base := time.Duration(p.BackoffMs) * time.Millisecond
+	if p.BackoffMs == 0 {
+		base = defaultTurnRetryBackoff
+	}
+	delay := base
+	for i := 2; i < attempt && delay < maxTurnRetryBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxTurnRetryBackoff {
+		delay = maxTurnRetryBackoff
+	}
+	return delay
+}
+
+// turnAttempt 一次尝试的结果。
+type turnAttempt struct {
+	Attempt int    `json:"attempt"`
+	Model   string `json:"model,omitempty"`
+	TurnID  string `json:"turnId,omitempty"`
+	Status  string `json:"status"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// turnRetryState 单个线程上进行中的重试。
+type turnRetryState struct {
+	gen     int64
+	params  turnStartParams // 原始 turn/start 参数 (重新提交时替换模型)
+	policy  turnRetryPolicy
+	attempt int    // 当前尝试序号, 从 1 开始
+	model   string // 当前尝试使用的模型
+	turnID  string // 当前尝试的 turn ID, 空 = 匹配线程上下一个结束的 turn
+	history []turnAttempt
+}
+
+// turnRetryTable 各线程的重试状态 (零值可用)。
+type turnRetryTable struct {
+	mu      sync.Mutex
+	gen     int64
+	threads map[string]*turnRetryState // threadID →
+}
+
+// begin 登记新的重试状态 (替换线程上已有的), 返回其 generation。
+func (t *turnRetryTable) begin(p turnStartParams, policy turnRetryPolicy) int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.threads == nil {
+		t.threads = make(map[string]*turnRetryState)
+	}
+	t.gen++
+	p.Retry = nil
+	t.threads[p.ThreadID] = &turnRetryState{
+		gen:     t.gen,
+		params:  p,
+		policy:  policy,
+		attempt: 1,
+		model:   p.Model,
+	}
+	return t.gen
+}
+
+// setTurnID 回填首次尝试的 turn ID (turn 已结束或已进入重试时忽略)。
+func (t *turnRetryTable) setTurnID(threadID string, gen int64, turnID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if state := t.threads[threadID]; state != nil && state.gen == gen && state.attempt == 1 && state.turnID == "" && len(state.history) == 0 {
+		state.turnID = turnID
+	}
+}
+
+// drop 移除指定 generation 的状态; gen = 0 时无条件移除。
+func (t *turnRetryTable) drop(threadID string, gen int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if state := t.threads[threadID]; state != nil && (gen == 0 || state.gen == gen) {
+		delete(t.threads, threadID)
+	}
+}
+
+// startTurnWithRetry 登记重试策略后执行 turn/start。
+func (s *Server) startTurnWithRetry(ctx context.Context, p turnStartParams) (any, error) {
+	policy, err := normalizeTurnRetryPolicy(*p.Retry)
+	if err != nil {
+		return nil, err
+	}
+	// 先登记再提交: turn 可能在 startTurn 返回前就已失败。
+	gen := s.turnRetries.begin(p, policy)
+	res, err := s.startTurn(ctx, p)
+	if err != nil {
+		s.turnRetries.drop(p.ThreadID, gen)
+		return nil, err
+	}
+	resp, _ := res.(turnStartResponse)
+	switch {
+	case resp.DedupOf != nil:
+		s.turnRetries.drop(p.ThreadID, gen)
+	case resp.Queue == nil && resp.Held == nil:
+		s.turnRetries.setTurnID(p.ThreadID, gen, resp.Turn.ID)
+	}
+	return res, nil
+}
+
+// maybeRetryTurn 在 turn 结束时判断是否重试, 返回是否已安排重试。
+func (s *Server) maybeRetryTurn(threadID, turnID, status, reason string, interruptRequested bool) bool {
+	t := &s.turnRetries
+	t.mu.Lock()
+	state := t.threads[threadID]
+	if state == nil || (state.turnID != "" && turnID != "" && !strings.EqualFold(state.turnID, turnID)) {
+		t.mu.Unlock()
+		return false
+	}
+	state.history = append(state.history, turnAttempt{
+		Attempt: state.attempt,
+		Model:   state.model,
+		TurnID:  turnID,
+		Status:  status,
+		Reason:  reason,
+	})
+	retryable := status == "failed" && !interruptRequested && !turnRetryNonRetryableReasons[reason]
+	if !retryable || state.attempt >= state.policy.MaxAttempts {
+		delete(t.threads, threadID)
+		history := state.history
+		t.mu.Unlock()
+		s.annotateTurnAttempts(threadID, history)
+		return false
+	}
+	state.attempt++
+	state.model = state.policy.modelFor(state.attempt, state.params.Model)
+	state.turnID = ""
+	gen, attempt, model := state.gen, state.attempt, state.model
+	maxAttempts := state.policy.MaxAttempts
+	backoff := state.policy.backoffFor(attempt)
+	t.mu.Unlock()
+
+	logger.Warn("turn/start: turn failed, scheduling retry",
+		logger.FieldThreadID, threadID,
+		logger.FieldTurnID, turnID,
+		"reason", reason,
+		"attempt", attempt,
+		"max_attempts", maxAttempts,
+		"model", model,
+		"backoff_ms", backoff.Milliseconds(),
+	)
+	s.Notify("turn/retry", map[string]any{
+		"threadId":    threadID,
+		"turnId":      turnID,
+		"reason":      reason,
+		"attempt":     attempt,
+		"maxAttempts": maxAttempts,
+		"model":       model,
+		"backoffMs":   backoff.Milliseconds(),
+	})
+	time.AfterFunc(backoff, func() { s.resubmitTurn(threadID, gen) })
+	return true
+}
+
+// resubmitTurn 以当前尝试的模型重新提交原始输入 (期间线程上有新 turn/start 时放弃)。
+func (s *Server) resubmitTurn(threadID string, gen int64) {
+	t := &s.turnRetries
+	t.mu.Lock()
+	state := t.threads[threadID]
+	if state == nil || state.gen != gen {
+		t.mu.Unlock()
+		return
+	}
+	p := state.params
+	p.Model = state.model
+	p.BypassDedup = true
+	p.IdempotencyKey = ""
+	p.retryAttempt = state.attempt
+	switchModel := state.model != "" && !strings.EqualFold(state.model, state.history[len(state.history)-1].Model)
+	t.mu.Unlock()
+
+	if proc := s.stallProcess(threadID); proc != nil && switchModel {
+		if err := proc.Client.SendCommand(codex.CmdModel, p.Model); err != nil {
+			logger.Warn("turn/start: retry model switch failed",
+				logger.FieldThreadID, threadID, "model", p.Model, logger.FieldError, err)
+		}
+	}
+	res, err := s.startTurn(context.Background(), p)
+	if err != nil {
+		logger.Error("turn/start: retry submit failed",
+			logger.FieldThreadID, threadID, "attempt", p.retryAttempt, logger.FieldError, err)
+		t.mu.Lock()
+		state = t.threads[threadID]
+		if state == nil || state.gen != gen {
+			t.mu.Unlock()
+			return
+		}
+		delete(t.threads, threadID)
+		state.history = append(state.history, turnAttempt{
+			Attempt: state.attempt,
+			Model:   state.model,
+			Status:  "failed",
+			Reason:  err.Error(),
+		})
+		history := state.history
+		t.mu.Unlock()
+		s.annotateTurnAttempts(threadID, history)
+		return
+	}
+	resp, _ := res.(turnStartResponse)
+	if resp.Queue != nil || resp.Held != nil {
+		return
+	}
+	t.mu.Lock()
+	if state = t.threads[threadID]; state != nil && state.gen == gen && state.attempt == p.retryAttempt && len(state.history) < state.attempt {
+		state.turnID = resp.Turn.ID
+	}
+	t.mu.Unlock()
+}
+
+// annotateTurnAttempts 在时间线标注最终结果来自第几次尝试 (仅在发生过重试时)。
+func (s *Server) annotateTurnAttempts(threadID string, history []turnAttempt) {
+	if len(history) < 2 {
+		return
+	}
+	final := history[len(history)-1]
+	model := final.Model
+	if model == "" {
+		model = "默认模型"
+	}
+	var text string
+	if final.Status == "completed" {
+		text = fmt.Sprintf("第 %d 次尝试 (%s) 给出最终结果", final.Attempt, model)
+	} else {
+		text = fmt.Sprintf("重试 %d 次后仍失败 (%s): %s", final.Attempt-1, model, final.Reason)
+	}
+	logger.Info("turn/start: retry chain finished",
+		logger.FieldThreadID, threadID,
+		logger.FieldTurnID, final.TurnID,
+		logger.FieldStatus, final.Status,
+		"attempts", len(history),
+		"model", final.Model,
+	)
+	if s.uiRuntime == nil {
+		return
+	}
+	previews := make([]string, 0, len(history))
+	for _, a := range history {
+		entry := fmt.Sprintf("#%d %s %s", a.Attempt, a.Model, a.Status)
+		if a.Reason != "" {
+			entry += " (" + a.Reason + ")"
+		}
+		previews = append(previews, strings.Join(strings.Fields(entry), " "))
+	}
+	s.uiRuntime.AppendTurnAttempt(threadID, uistate.TimelineItem{
+		Text:    text,
+		Status:  final.Status,
+		Ref:     final.TurnID,
+		Preview: strings.Join(previews, "\n"),
+	})
+}
@@ -0,0 +1,108 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/codex"
+	"github.com/multi-agent/go-agent-v2/internal/runner"
+)
+
+func TestNormalizeTurnRetryPolicy(t *testing.T) {
+	p, err := normalizeTurnRetryPolicy(turnRetryPolicy{FallbackModels: []string{" model-b ", "", "model-c"}})
+	if err != nil || p.MaxAttempts != 3 || len(p.FallbackModels) != 2 {
+		t.Fatalf("policy = %#v err = %v", p, err)
+	}
+	if got := p.modelFor(1, "model-a") + "," + p.modelFor(2, "model-a") + "," + p.modelFor(5, "model-a"); got != "model-a,model-b,model-c" {
+		t.Fatalf("models = %s", got)
+	}
+	p.BackoffMs = 100
+	if p.backoffFor(2) != 100*time.Millisecond || p.backoffFor(4) != 400*time.Millisecond {
+		t.Fatalf("backoff = %v %v", p.backoffFor(2), p.backoffFor(4))
+	}
+	for _, bad := range []turnRetryPolicy{{MaxAttempts: 9}, {MaxAttempts: -1}, {BackoffMs: -1}} {
+		if _, err := normalizeTurnRetryPolicy(bad); err == nil {
+			t.Errorf("%#v: expected error", bad)
+		}
+	}
+}
+
+func TestTurnStart_RetriesFailedTurnWithFallbackModel(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	script := func(turnID, prompt string) []codex.MockStep {
+		if turnID == "mock-turn-1" {
+			return []codex.MockStep{{Type: codex.EventTurnComplete, Data: map[string]any{
+				"turn": map[string]any{"id": turnID, "status": "failed"},
+			}}}
+		}
+		return codex.DefaultMockScript(turnID, prompt)
+	}
+	mgr := runner.NewAgentManager()
+	mgr.SetClientFactory(codex.MockClientFactory(script, 0))
+	srv := New(Deps{Manager: mgr})
+	mgr.SetOnEvent(func(agentID string, event codex.Event) {
+		srv.AgentEventHandler(agentID)(event)
+	})
+	var mu sync.Mutex
+	var retries []map[string]any
+	srv.SetNotifyHook(func(method string, params any) {
+		if method == "turn/retry" {
+			mu.Lock()
+			retries = append(retries, params.(map[string]any))
+			mu.Unlock()
+		}
+	})
+
+	ctx := context.Background()
+	res, err := srv.InvokeMethod(ctx, "thread/start", json.RawMessage(`{"cwd":"."}`))
+	if err != nil {
+		t.Fatalf("thread/start: %v", err)
+	}
+	threadID := res.(threadStartResponse).Thread.ID
+	params, _ := json.Marshal(map[string]any{
+		"threadId": threadID,
+		"input":    []map[string]any{{"type": "text", "text": "hello"}},
+		"retry":    map[string]any{"backoffMs": 10, "fallbackModels": []string{"model-b"}},
+	})
+	if _, err := srv.InvokeMethod(ctx, "turn/start", params); err != nil {
+		t.Fatalf("turn/start: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if outcome, ok := srv.lastTurnOutcome(threadID); ok && outcome.TurnID == "mock-turn-2" {
+			if outcome.Status != "completed" {
+				t.Fatalf("retry outcome = %#v", outcome)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("retry turn did not complete")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	mu.Lock()
+	if len(retries) != 1 || retries[0]["attempt"] != 2 || retries[0]["model"] != "model-b" {
+		t.Fatalf("turn/retry notifications = %#v", retries)
+	}
+	mu.Unlock()
+	users, attempts := 0, 0
+	for _, item := range srv.uiRuntime.Snapshot().TimelinesByThread[threadID] {
+		switch item.Kind {
+		case "user":
+			users++
+		case "attempt":
+			attempts++
+			if item.Ref != "mock-turn-2" || item.Status != "completed" {
+				t.Fatalf("attempt item = %#v", item)
+			}
+		}
+	}
+	if users != 1 || attempts != 1 {
+		t.Fatalf("timeline users = %d attempts = %d", users, attempts)
+	}
+}
@@ -0,0 +1,449 @@
+// turn_scheduler.go — turn 优先级调度 (优先级分级 + 按项目配额的公平性)。
+//
+// 工作流 / 定时任务 / webhook 可能同时排入大量 turn。启用调度后
+// (TURN_SCHEDULER_MAX_CONCURRENT > 0 或配置了 TURN_SCHEDULER_MODEL_LIMITS):
+//   - 全局 / 单项目 / 单模型并发受限, 超出时 turn/start 返回 status=queued
+//   - 单模型上限用于遵守 provider 配额, 避免大量 agent 同时调用同一模型触发 429
+//   - 出队顺序: 优先级 (interactive > normal > background) → 运行中 turn 较少的项目 → FIFO
+//   - 人工发起的 interactive turn 总是排在后台维护 turn 之前
+//   - 同一线程同时只运行一个 turn
+//
+// turn 结束 (turn tracker 完成) 时释放槽位并派发下一个。队列状态见 scheduler/queue。
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+	"github.com/multi-agent/go-agent-v2/pkg/util"
+)
+
+const (
+	turnPriorityInteractive = "interactive"
+	turnPriorityNormal      = "normal"
+	turnPriorityBackground  = "background"
+
+	queuedTurnDispatchTimeout = 2 * time.Minute
+
+	// defaultTurnModelKey 未显式指定模型的 turn 归入该桶 (使用 codex 默认模型)。
+	defaultTurnModelKey = "default"
+)
+
+// turnPriorityRank 数值越小优先级越高。
+var turnPriorityRank = map[string]int{
+	turnPriorityInteractive: 0,
+	turnPriorityNormal:      1,
+	turnPriorityBackground:  2,
+}
+
+// normalizeTurnPriority 归一化优先级; 未指定视为 interactive (UI 人工发起)。
+func normalizeTurnPriority(priority string) (string, error) {
+	value := strings.ToLower(strings.TrimSpace(priority))
+	if value == "" {
+		return turnPriorityInteractive, nil
+	}
+	if _, ok := turnPriorityRank[value]; !ok {
+		return "", fmt.Errorf("unknown priority %q (want interactive/normal/background)", priority)
+	}
+	return value, nil
+}
+
+// queuedTurn 等待派发的 turn。
+type queuedTurn struct {
+	ID         string    `json:"id"`
+	ThreadID   string    `json:"threadId"`
+	Project    string    `json:"project,omitempty"`
+	Priority   string    `json:"priority"`
+	Model      string    `json:"model"`
+	EnqueuedAt time.Time `json:"enqueuedAt"`
+	seq        uint64
+	turn       preparedTurn
+}
+
+// scheduledTurn 已占用槽位的 turn。
+type scheduledTurn struct {
+	ThreadID  string    `json:"threadId"`
+	Project   string    `json:"project,omitempty"`
+	Priority  string    `json:"priority"`
+	Model     string    `json:"model"`
+	StartedAt time.Time `json:"startedAt"`
+}
+
+// turnScheduler 优先级调度器 (nil 表示未启用, 所有 turn 直接提交)。
+type turnScheduler struct {
+	mu            sync.Mutex
+	maxConcurrent int            // 0 = 不限
+	projectQuota  int            // 0 = 不限
+	modelLimits   map[string]int // 模型 → 并发上限 (键支持 "prefix*" 通配)
+	running       map[string]scheduledTurn
+	queue         []*queuedTurn
+	seq           uint64
+}
+
+func newTurnScheduler(maxConcurrent, projectQuota int, modelLimits map[string]int) *turnScheduler {
+	if maxConcurrent <= 0 && len(modelLimits) == 0 {
+		return nil
+	}
+	if maxConcurrent < 0 {
+		maxConcurrent = 0
+	}
+	if projectQuota < 0 {
+		projectQuota = 0
+	}
+	return &turnScheduler{
+		maxConcurrent: maxConcurrent,
+		projectQuota:  projectQuota,
+		modelLimits:   modelLimits,
+		running:       make(map[string]scheduledTurn),
+	}
+}
+
+// parseTurnModelLimits 解析 "model=N,prefix*=N" 形式的单模型并发上限。
+func parseTurnModelLimits(raw string) (map[string]int, error) {
+	limits := map[string]int{}
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(part, "=")
+		name = normalizeTurnModel(name)
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid model limit %q (want model=N)", part)
+		}
+		limit, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("invalid model limit %q (want positive integer)", part)
+		}
+		limits[name] = limit
+	}
+	if len(limits) == 0 {
+		return nil, nil
+	}
+	return limits, nil
+}
+
+// normalizeTurnModel 归一化模型名 (空 → default)。
+func normalizeTurnModel(model string) string {
+	value := strings.ToLower(strings.TrimSpace(model))
+	if value == "" {
+		return defaultTurnModelKey
+	}
+	return value
+}
+
+// modelLimitLocked 返回模型对应的并发上限与限额键 (精确匹配优先, 其次最长前缀通配)。
+func (q *turnScheduler) modelLimitLocked(model string) (string, int) {
+	if limit, ok := q.modelLimits[model]; ok {
+		return model, limit
+	}
+	bestKey, bestLimit := "", 0
+	for key, limit := range q.modelLimits {
+		prefix, wildcard := strings.CutSuffix(key, "*")
+		if !wildcard || !strings.HasPrefix(model, prefix) {
+			continue
+		}
+		if len(key) > len(bestKey) {
+			bestKey, bestLimit = key, limit
+		}
+	}
+	return bestKey, bestLimit
+}
+
+func (q *turnScheduler) modelRunningLocked(limitKey string) int {
+	count := 0
+	for _, item := range q.running {
+		if key, _ := q.modelLimitLocked(item.Model); key == limitKey {
+			count++
+		}
+	}
+	return count
+}
+
+func (q *turnScheduler) projectRunningLocked(project string) int {
+	count := 0
+	for _, item := range q.running {
+		if item.Project == project {
+			count++
+		}
+	}
+	return count
+}
+
+func (q *turnScheduler) eligibleLocked(threadID, project, model string) bool {
+	if q.maxConcurrent > 0 && len(q.running) >= q.maxConcurrent {
+		return false
+	}
+	if _, busy := q.running[threadID]; busy {
+		return false
+	}
+	if q.projectQuota > 0 && q.projectRunningLocked(project) >= q.projectQuota {
+		return false
+	}
+	if key, limit := q.modelLimitLocked(model); limit > 0 && q.modelRunningLocked(key) >= limit {
+		return false
+	}
+	return true
+}
+
+// admit 尝试立即占用槽位; 否则入队并返回排队信息。
+//
+// 队列中存在可运行且优先级不低于本 turn 的项时, 本 turn 也必须排队 (避免插队)。
+func (q *turnScheduler) admit(project, priority string, turn preparedTurn, now time.Time) (*queuedTurn, int, bool) {
+	threadID := turn.ThreadID
+	model := normalizeTurnModel(turn.Model)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	rank := turnPriorityRank[priority]
+	if q.eligibleLocked(threadID, project, model) {
+		blocked := false
+		for _, item := range q.queue {
+			if turnPriorityRank[item.Priority] <= rank && q.eligibleLocked(item.ThreadID, item.Project, item.Model) {
+				blocked = true
+				break
+			}
+		}
+		if !blocked {
+			q.running[threadID] = scheduledTurn{ThreadID: threadID, Project: project, Priority: priority, Model: model, StartedAt: now}
+			return nil, 0, true
+		}
+	}
+	q.seq++
+	item := &queuedTurn{
+		ID:         fmt.Sprintf("queued-%d", q.seq),
+		ThreadID:   threadID,
+		Project:    project,
+		Priority:   priority,
+		Model:      model,
+		EnqueuedAt: now,
+		seq:        q.seq,
+		turn:       turn,
+	}
+	q.queue = append(q.queue, item)
+	q.sortQueueLocked()
+	return item, q.positionLocked(item.ID), false
+}
+
+// release 释放线程槽位并取出可派发的 turn (调用方在锁外执行 dispatch)。
+func (q *turnScheduler) release(threadID string, now time.Time) []*queuedTurn {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.running[threadID]; !ok {
+		return nil
+	}
+	delete(q.running, threadID)
+	return q.drainLocked(now)
+}
+
+func (q *turnScheduler) drainLocked(now time.Time) []*queuedTurn {
+	var ready []*queuedTurn
+	for {
+		idx := q.pickLocked()
+		if idx < 0 {
+			break
+		}
+		item := q.queue[idx]
+		q.queue = append(q.queue[:idx], q.queue[idx+1:]...)
+		q.running[item.ThreadID] = scheduledTurn{ThreadID: item.ThreadID, Project: item.Project, Priority: item.Priority, Model: item.Model, StartedAt: now}
+		ready = append(ready, item)
+	}
+	return ready
+}
+
+// pickLocked 选择下一个可运行项: 优先级 → 项目运行数 (公平) → FIFO。
+func (q *turnScheduler) pickLocked() int {
+	best := -1
+	bestRank, bestLoad := 0, 0
+	for idx, item := range q.queue {
+		if !q.eligibleLocked(item.ThreadID, item.Project, item.Model) {
+			continue
+		}
+		rank := turnPriorityRank[item.Priority]
+		load := q.projectRunningLocked(item.Project)
+		if best < 0 || rank < bestRank || (rank == bestRank && load < bestLoad) {
+			best, bestRank, bestLoad = idx, rank, load
+		}
+	}
+	return best
+}
+
+func (q *turnScheduler) sortQueueLocked() {
+	sort.SliceStable(q.queue, func(i, j int) bool {
+		left, right := turnPriorityRank[q.queue[i].Priority], turnPriorityRank[q.queue[j].Priority]
+		if left != right {
+			return left < right
+		}
+		return q.queue[i].seq < q.queue[j].seq
+	})
+}
+
+func (q *turnScheduler) positionLocked(id string) int {
+	for idx, item := range q.queue {
+		if item.ID == id {
+			return idx + 1
+		}
+	}
+	return 0
+}
+
+// cancel 从队列移除指定排队项。
+func (q *turnScheduler) cancel(id string) (*queuedTurn, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for idx, item := range q.queue {
+		if item.ID == id {
+			q.queue = append(q.queue[:idx], q.queue[idx+1:]...)
+			return item, true
+		}
+	}
+	return nil, false
+}
+
+func (q *turnScheduler) snapshot() (running []scheduledTurn, queued []queuedTurn) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	running = make([]scheduledTurn, 0, len(q.running))
+	for _, item := range q.running {
+		running = append(running, item)
+	}
+	sort.Slice(running, func(i, j int) bool { return running[i].StartedAt.Before(running[j].StartedAt) })
+	queued = make([]queuedTurn, 0, len(q.queue))
+	for _, item := range q.queue {
+		queued = append(queued, *item)
+	}
+	return running, queued
+}
+
+// ========================================
+// Server 集成
+// ========================================
+
+// turnQueueInfo turn/start 排队时返回的队列信息。
+type turnQueueInfo struct {
+	ID       string `json:"id"`
+	Position int    `json:"position"`
+	Priority string `json:"priority"`
+}
+
+// releaseScheduledTurn turn 结束后释放调度槽位并派发排队 turn。
+func (s *Server) releaseScheduledTurn(threadID string) {
+	if s.turnScheduler == nil {
+		return
+	}
+	for _, item := range s.turnScheduler.release(threadID, time.Now()) {
+		logger.Info("scheduler: dispatching queued turn",
+			logger.FieldThreadID, item.ThreadID,
+			"queue_id", item.ID,
+			"priority", item.Priority,
+			"wait_ms", time.Since(item.EnqueuedAt).Milliseconds(),
+		)
+		util.SafeGo(func() { s.dispatchQueuedTurn(item.ID, item.turn) })
+	}
+}
+
+// dispatchQueuedTurn 派发排队 turn (重新确认线程就绪后提交)。
+func (s *Server) dispatchQueuedTurn(queueID string, turn preparedTurn) {
+	if s.emergency.deferTurn(queueID, turn) {
+		logger.Info("scheduler: queued turn deferred by emergency stop",
+			logger.FieldThreadID, turn.ThreadID, "queue_id", queueID)
+		return
+	}
+	if s.drain.park(queueID, turn) {
+		logger.Info("scheduler: queued turn parked by drain",
+			logger.FieldThreadID, turn.ThreadID, "queue_id", queueID)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), queuedTurnDispatchTimeout)
+	defer cancel()
+
+	fail := func(err error) {
+		if turn.DedupKey != "" {
+			s.turnDedup.release(turn.DedupKey)
+		}
+		logger.Warn("scheduler: queued turn dispatch failed",
+			logger.FieldThreadID, turn.ThreadID,
+			"queue_id", queueID,
+			logger.FieldError, err,
+		)
+		s.Notify("scheduler/turnFailed", map[string]any{
+			"queueId":  queueID,
+			"threadId": turn.ThreadID,
+			"error":    err.Error(),
+		})
+		s.releaseScheduledTurn(turn.ThreadID)
+	}
+
+	proc, err := s.ensureThreadReadyForTurn(ctx, turn.ThreadID, turn.Cwd)
+	if err != nil {
+		fail(err)
+		return
+	}
+	turnID, err := s.submitPreparedTurn(ctx, proc, turn)
+	if err != nil {
+		fail(err)
+		return
+	}
+	s.Notify("scheduler/turnDispatched", map[string]any{
+		"queueId":  queueID,
+		"threadId": turn.ThreadID,
+		"turn":     turnInfo{ID: turnID, Status: "inProgress"},
+	})
+}
+
+// ========================================
+// scheduler/queue, scheduler/cancel
+// ========================================
+
+func (s *Server) schedulerQueue(_ context.Context, _ json.RawMessage) (any, error) {
+	if s.turnScheduler == nil {
+		return map[string]any{
+			"enabled": false,
+			"running": []scheduledTurn{},
+			"queued":  []queuedTurn{},
+		}, nil
+	}
+	running, queued := s.turnScheduler.snapshot()
+	return map[string]any{
+		"enabled":       true,
+		"maxConcurrent": s.turnScheduler.maxConcurrent,
+		"projectQuota":  s.turnScheduler.projectQuota,
+		"modelLimits":   s.turnScheduler.modelLimits,
+		"running":       running,
+		"queued":        queued,
+	}, nil
+}
+
+type schedulerCancelParams struct {
+	ID string `json:"id"`
+}
+
+func (s *Server) schedulerCancelTyped(_ context.Context, p schedulerCancelParams) (any, error) {
+	if item, cancelled := s.quietHours.cancel(strings.TrimSpace(p.ID)); cancelled {
+		if item.turn.DedupKey != "" {
+			s.turnDedup.release(item.turn.DedupKey)
+		}
+		logger.Info("scheduler: held turn cancelled", logger.FieldThreadID, item.ThreadID, "held_id", item.ID)
+		return map[string]any{"cancelled": true}, nil
+	}
+	if s.turnScheduler == nil {
+		return map[string]any{"cancelled": false}, nil
+	}
+	item, cancelled := s.turnScheduler.cancel(strings.TrimSpace(p.ID))
+	if cancelled {
+		if item.turn.DedupKey != "" {
+			s.turnDedup.release(item.turn.DedupKey)
+		}
+		logger.Info("scheduler: queued turn cancelled",
+			logger.FieldThreadID, item.ThreadID,
+			"queue_id", item.ID,
+		)
+	}
+	return map[string]any{"cancelled": cancelled}, nil
+}
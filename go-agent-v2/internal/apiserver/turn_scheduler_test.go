@@ -0,0 +1,110 @@
+package apiserver
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTurnSchedulerPriorityAndProjectQuota(t *testing.T) {
+	q := newTurnScheduler(2, 1, nil)
+	now := time.Now()
+	turn := func(threadID string) preparedTurn { return preparedTurn{ThreadID: threadID} }
+
+	if _, _, ok := q.admit("/a", turnPriorityBackground, turn("bg-a1"), now); !ok {
+		t.Fatal("first turn should be admitted")
+	}
+	// 项目 /a 配额已满, 即便全局仍有空位也要排队。
+	if _, pos, ok := q.admit("/a", turnPriorityBackground, turn("bg-a2"), now); ok || pos != 1 {
+		t.Fatalf("project quota should queue bg-a2 (pos=%d ok=%v)", pos, ok)
+	}
+	if _, _, ok := q.admit("/b", turnPriorityBackground, turn("bg-b1"), now); !ok {
+		t.Fatal("other project should be admitted")
+	}
+	// 全局已满: interactive 排到 background 之前。
+	if _, pos, ok := q.admit("/c", turnPriorityBackground, turn("bg-c1"), now); ok || pos != 2 {
+		t.Fatalf("bg-c1 should queue at 2, got pos=%d ok=%v", pos, ok)
+	}
+	if _, pos, ok := q.admit("/c", turnPriorityInteractive, turn("ui-c1"), now); ok || pos != 1 {
+		t.Fatalf("interactive should jump to head, got pos=%d ok=%v", pos, ok)
+	}
+
+	ready := q.release("bg-b1", now)
+	if len(ready) != 1 || ready[0].ThreadID != "ui-c1" {
+		t.Fatalf("release should dispatch interactive first, got %+v", ready)
+	}
+	ready = q.release("bg-a1", now)
+	if len(ready) != 1 || ready[0].ThreadID != "bg-a2" {
+		t.Fatalf("release on /a should dispatch bg-a2 (bg-c1 blocked by /c quota), got %+v", ready)
+	}
+	if ready := q.release("unknown", now); ready != nil {
+		t.Fatalf("release of untracked thread should be noop, got %+v", ready)
+	}
+
+	running, queued := q.snapshot()
+	if len(running) != 2 || len(queued) != 1 || queued[0].ThreadID != "bg-c1" {
+		t.Fatalf("snapshot running=%+v queued=%+v", running, queued)
+	}
+	if item, ok := q.cancel(queued[0].ID); !ok || item.ThreadID != "bg-c1" {
+		t.Fatalf("cancel failed: %+v %v", item, ok)
+	}
+}
+
+func TestTurnSchedulerSerializesSameThread(t *testing.T) {
+	q := newTurnScheduler(4, 0, nil)
+	now := time.Now()
+	if _, _, ok := q.admit("", turnPriorityInteractive, preparedTurn{ThreadID: "t1"}, now); !ok {
+		t.Fatal("first turn should be admitted")
+	}
+	if _, _, ok := q.admit("", turnPriorityInteractive, preparedTurn{ThreadID: "t1"}, now); ok {
+		t.Fatal("second turn on same thread should queue")
+	}
+	if ready := q.release("t1", now); len(ready) != 1 || ready[0].ThreadID != "t1" {
+		t.Fatalf("queued turn on same thread should dispatch after release, got %+v", ready)
+	}
+	if newTurnScheduler(0, 1, nil) != nil {
+		t.Fatal("zero max concurrency should disable scheduler")
+	}
+	if _, err := normalizeTurnPriority("urgent"); err == nil {
+		t.Fatal("unknown priority should be rejected")
+	}
+}
+
+func TestTurnSchedulerPerModelLimits(t *testing.T) {
+	limits, err := parseTurnModelLimits("GPT-5=1, o3*=2")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if _, err := parseTurnModelLimits("gpt-5=0"); err == nil {
+		t.Fatal("non-positive limit should be rejected")
+	}
+	q := newTurnScheduler(0, 0, limits)
+	if q == nil {
+		t.Fatal("model limits alone should enable scheduler")
+	}
+	now := time.Now()
+	turn := func(threadID, model string) preparedTurn { return preparedTurn{ThreadID: threadID, Model: model} }
+
+	if _, _, ok := q.admit("", turnPriorityNormal, turn("t1", "gpt-5"), now); !ok {
+		t.Fatal("first gpt-5 turn should be admitted")
+	}
+	if _, _, ok := q.admit("", turnPriorityNormal, turn("t2", "gpt-5"), now); ok {
+		t.Fatal("second gpt-5 turn should queue")
+	}
+	if _, _, ok := q.admit("", turnPriorityNormal, turn("t3", "o3-mini"), now); !ok {
+		t.Fatal("o3 family should have its own quota")
+	}
+	if _, _, ok := q.admit("", turnPriorityNormal, turn("t4", "o3"), now); !ok {
+		t.Fatal("o3 family allows two concurrent turns")
+	}
+	if _, _, ok := q.admit("", turnPriorityNormal, turn("t5", "o3-pro"), now); ok {
+		t.Fatal("third o3 family turn should queue")
+	}
+	if _, _, ok := q.admit("", turnPriorityNormal, turn("t6", ""), now); !ok {
+		t.Fatal("unlimited default model should be admitted")
+	}
+
+	ready := q.release("t1", now)
+	if len(ready) != 1 || ready[0].ThreadID != "t2" || ready[0].Model != "gpt-5" {
+		t.Fatalf("release of gpt-5 should dispatch t2, got %+v", ready)
+	}
+}
@@ -0,0 +1,449 @@
+// turn_speculation.go — 投机双模型执行 (实验性, turn/speculate)。
+//
+// 同一输入并行发给两个模型: 廉价模型在原线程上运行 (提交前 /model 切换, 之后的 turn 由模型路由切回),
+// 强模型在同工作目录临时启动的影子线程上运行。先结束且通过门禁 (outputSchema 校验 / 诊断门禁) 的一方胜出,
+// 另一方立即中断; 两方都不合格时结果为 rejected。两个候选的耗时与 token 用量写入 task_traces
+// (span_name = speculation, component = 原线程) 以便比较成本, 结束后推送 turn/speculation/completed,
+// 影子线程随即停止。两个候选共享工作目录, 适用于问答 / 分析类 turn, 不宜用于会改写文件的任务。
+package apiserver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/codex"
+	"github.com/multi-agent/go-agent-v2/internal/runner"
+	"github.com/multi-agent/go-agent-v2/internal/store"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+	"github.com/multi-agent/go-agent-v2/pkg/util"
+)
+
+const (
+	speculationSpanName           = "speculation"
+	defaultSpeculationTimeoutSec  = 600
+	maxSpeculationTimeoutSec      = 3600
+	maxSpeculationJobs            = 64
+	speculationLoserWait          = 30 * time.Second // 胜负已分后等待败方中断完成 (记录其成本) 的上限
+	speculationRoleCheap          = "cheap"
+	speculationRoleStrong         = "strong"
+	speculationStatusRunning      = "running"
+	speculationStatusDecided      = "decided"
+	speculationStatusRejected     = "rejected" // 两方都未通过门禁
+	speculationStatusTimeout      = "timeout"
+	speculationCandidateRunning   = "running"
+	speculationCandidateAccepted  = "accepted"
+	speculationCandidateRejected  = "rejected"
+	speculationCandidateLost      = "lost" // 通过门禁但晚于胜出方结束
+	speculationCandidateCancelled = "cancelled"
+)
+
+// speculationCandidate 单个模型候选。
+type speculationCandidate struct {
+	Role       string    `json:"role"` // cheap / strong
+	Model      string    `json:"model"`
+	ThreadID   string    `json:"threadId"`
+	TurnID     string    `json:"turnId,omitempty"`
+	Status     string    `json:"status"`               // running / accepted / lost / rejected / cancelled
+	TurnStatus string    `json:"turnStatus,omitempty"` // turn 最终状态 (completed / failed / interrupted)
+	Reason     string    `json:"reason,omitempty"`
+	DurationMS int64     `json:"durationMs,omitempty"`
+	Tokens     int       `json:"tokens,omitempty"` // 结束时最近一次请求的 token 用量 (codex tokenUsage)
+	StartedAt  time.Time `json:"startedAt"`
+
+	queued      bool // 经调度器排队, TurnID 为队列 ID
+	finished    bool
+	completedAt time.Time
+}
+
+// speculationJob 一次投机执行。
+type speculationJob struct {
+	ID           string                 `json:"speculationId"`
+	ThreadID     string                 `json:"threadId"`
+	Status       string                 `json:"status"` // running / decided / rejected / timeout
+	Winner       string                 `json:"winner,omitempty"`
+	Answer       string                 `json:"answer,omitempty"`
+	Result       json.RawMessage        `json:"result,omitempty"` // 胜出方通过 schema 校验的结构化输出
+	Candidates   []speculationCandidate `json:"candidates"`
+	CreatedAt    time.Time              `json:"createdAt"`
+	DecidedAt    *time.Time             `json:"decidedAt,omitempty"`
+	shadowThread string
+	withSchema   bool
+	deadline     time.Time
+}
+
+// speculationTable 投机执行登记 (零值可用)。
+type speculationTable struct {
+	mu   sync.Mutex
+	jobs map[string]*speculationJob
+}
+
+func (t *speculationTable) add(job *speculationJob) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.jobs == nil {
+		t.jobs = make(map[string]*speculationJob)
+	}
+	if len(t.jobs) >= maxSpeculationJobs {
+		oldestID := ""
+		for id, existing := range t.jobs {
+			if existing.Status != speculationStatusRunning && (oldestID == "" || existing.CreatedAt.Before(t.jobs[oldestID].CreatedAt)) {
+				oldestID = id
+			}
+		}
+		delete(t.jobs, oldestID)
+	}
+	t.jobs[job.ID] = job
+}
+
+func (t *speculationTable) snapshot(id string) (speculationJob, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	job, ok := t.jobs[id]
+	if !ok {
+		return speculationJob{}, false
+	}
+	out := *job
+	out.Candidates = append([]speculationCandidate(nil), job.Candidates...)
+	return out, true
+}
+
+// update 在锁内修改任务。
+func (t *speculationTable) update(id string, fn func(job *speculationJob)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if job, ok := t.jobs[id]; ok {
+		fn(job)
+	}
+}
+
+// decideSpeculation 按候选状态判定: 最先结束的 accepted 胜出; 全部结束且无人通过为 rejected。
+func decideSpeculation(candidates []speculationCandidate) (winner int, status string) {
+	winner, allDone := -1, true
+	for i, c := range candidates {
+		switch c.Status {
+		case speculationCandidateAccepted:
+			if winner < 0 || c.completedAt.Before(candidates[winner].completedAt) {
+				winner = i
+			}
+		case speculationCandidateRunning:
+			allDone = false
+		}
+	}
+	switch {
+	case winner >= 0:
+		return winner, speculationStatusDecided
+	case allDone:
+		return -1, speculationStatusRejected
+	default:
+		return -1, speculationStatusRunning
+	}
+}
+
+// ========================================
+// turn/speculate, turn/speculate/result
+// ========================================
+
+type turnSpeculateParams struct {
+	ThreadID     string          `json:"threadId"`
+	Input        []UserInput     `json:"input,omitempty"`
+	Prompt       string          `json:"prompt,omitempty"` // input 为空时的纯文本简写
+	CheapModel   string          `json:"cheapModel"`
+	StrongModel  string          `json:"strongModel"`
+	OutputSchema json.RawMessage `json:"outputSchema,omitempty"`
+	QualityGate  *bool           `json:"qualityGate,omitempty"`
+	TimeoutSec   *int            `json:"timeoutSec,omitempty"` // 等待合格回答的上限, 超时两方都中断
+}
+
+func (s *Server) turnSpeculateTyped(ctx context.Context, p turnSpeculateParams) (any, error) {
+	const op = "Server.turnSpeculate"
+	if !s.speculationEnabled {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "speculative execution is disabled (TURN_SPECULATION_ENABLED)")
+	}
+	threadID := strings.TrimSpace(p.ThreadID)
+	cheap, strong := strings.TrimSpace(p.CheapModel), strings.TrimSpace(p.StrongModel)
+	if threadID == "" {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "threadId is required")
+	}
+	if cheap == "" || strong == "" || strings.EqualFold(cheap, strong) {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "cheapModel and strongModel are required and must differ")
+	}
+	input := p.Input
+	if len(input) == 0 && strings.TrimSpace(p.Prompt) != "" {
+		input = []UserInput{{Type: "text", Text: p.Prompt}}
+	}
+	if len(input) == 0 {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "input or prompt is required")
+	}
+	timeoutSec := defaultSpeculationTimeoutSec
+	if p.TimeoutSec != nil {
+		timeoutSec = *p.TimeoutSec
+	}
+	if timeoutSec <= 0 || timeoutSec > maxSpeculationTimeoutSec {
+		return nil, apperrors.NewCodef(op, errcode.InvalidInput, "timeoutSec must be within [1, %d]", maxSpeculationTimeoutSec)
+	}
+	if err := s.frozenError(op); err != nil {
+		return nil, err
+	}
+	if err := s.drainingError(op); err != nil {
+		return nil, err
+	}
+	proc, err := s.ensureThreadReadyForTurn(ctx, threadID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	job := &speculationJob{
+		ID:           "spec-" + rand.Text(),
+		ThreadID:     threadID,
+		Status:       speculationStatusRunning,
+		CreatedAt:    now,
+		shadowThread: threadID + "-spec-" + rand.Text()[:8],
+		withSchema:   len(p.OutputSchema) > 0,
+		deadline:     now.Add(time.Duration(timeoutSec) * time.Second),
+	}
+	if err := s.mgr.LaunchWithOptions(ctx, runner.LaunchOptions{
+		ID:    job.shadowThread,
+		Name:  job.shadowThread,
+		Cwd:   s.getAgentWorkDir(threadID),
+		Model: strong,
+	}); err != nil {
+		return nil, apperrors.Wrap(err, op, "launch shadow thread")
+	}
+	if err := proc.Client.SendCommand(codex.CmdModel, cheap); err != nil {
+		s.stopSpeculationShadow(job.shadowThread)
+		return nil, apperrors.Wrap(err, op, "switch model")
+	}
+	s.modelRoutes.set(threadID, cheap)
+
+	var repairs *int
+	if job.withSchema {
+		repairs = new(int) // 竞速不做修复重试, 不合格即判负
+	}
+	candidates := []speculationCandidate{
+		{Role: speculationRoleCheap, Model: cheap, ThreadID: threadID},
+		{Role: speculationRoleStrong, Model: strong, ThreadID: job.shadowThread},
+	}
+	for i := range candidates {
+		c := &candidates[i]
+		c.StartedAt = time.Now()
+		res, err := s.turnStartTyped(ctx, turnStartParams{
+			ThreadID:            c.ThreadID,
+			Input:               append([]UserInput(nil), input...),
+			Model:               c.Model,
+			OutputSchema:        p.OutputSchema,
+			QualityGate:         p.QualityGate,
+			OutputSchemaRetries: repairs,
+			BypassDedup:         true,
+		})
+		if err != nil {
+			if i == 1 {
+				s.interruptSpeculationCandidate(candidates[0].ThreadID)
+			}
+			s.stopSpeculationShadow(job.shadowThread)
+			return nil, apperrors.Wrapf(err, op, "start %s candidate", c.Role)
+		}
+		resp, _ := res.(turnStartResponse)
+		c.TurnID, c.Status, c.queued = resp.Turn.ID, speculationCandidateRunning, resp.Queue != nil || resp.Held != nil
+	}
+	job.Candidates = candidates
+	s.speculations.add(job)
+	logger.Info("turn/speculate: started",
+		logger.FieldThreadID, threadID,
+		"speculation_id", job.ID,
+		"shadow_thread", job.shadowThread,
+		"cheap_model", cheap,
+		"strong_model", strong,
+	)
+	util.SafeGo(func() { s.runSpeculation(job.ID) })
+	snapshot, _ := s.speculations.snapshot(job.ID)
+	return snapshot, nil
+}
+
+type turnSpeculateResultParams struct {
+	SpeculationID string `json:"speculationId"`
+}
+
+func (s *Server) turnSpeculateResultTyped(_ context.Context, p turnSpeculateResultParams) (any, error) {
+	job, ok := s.speculations.snapshot(strings.TrimSpace(p.SpeculationID))
+	if !ok {
+		return nil, apperrors.NewCodef("Server.turnSpeculateResult", errcode.InvalidInput, "speculation %q not found", p.SpeculationID)
+	}
+	return job, nil
+}
+
+// ========================================
+// 判定与收尾
+// ========================================
+
+// runSpeculation 轮询候选直到分出胜负 / 全部不合格 / 超时, 然后中断败方、记录成本并停止影子线程。
+func (s *Server) runSpeculation(id string) {
+	job, ok := s.speculations.snapshot(id)
+	if !ok {
+		return
+	}
+	for {
+		for i := range job.Candidates {
+			s.pollSpeculationCandidate(&job.Candidates[i], job.withSchema)
+		}
+		s.speculations.update(id, func(stored *speculationJob) {
+			stored.Candidates = append([]speculationCandidate(nil), job.Candidates...)
+		})
+		winner, status := decideSpeculation(job.Candidates)
+		if status == speculationStatusRunning && time.Now().After(job.deadline) {
+			status = speculationStatusTimeout
+		}
+		if status != speculationStatusRunning {
+			s.finishSpeculation(&job, winner, status)
+			return
+		}
+		time.Sleep(turnAwaitPollInterval)
+	}
+}
+
+// pollSpeculationCandidate 刷新候选状态 (turn 已结束且门禁结果可用时定案)。
+func (s *Server) pollSpeculationCandidate(c *speculationCandidate, withSchema bool) {
+	if c.finished {
+		return
+	}
+	outcome, ok := s.lastTurnOutcome(c.ThreadID)
+	if !ok || (outcome.TurnID != c.TurnID && !(c.queued && outcome.CompletedAt.After(c.StartedAt))) {
+		return
+	}
+	if c.queued {
+		c.TurnID, c.queued = outcome.TurnID, false
+	}
+	accepted := outcome.Status == "completed"
+	reason := outcome.Reason
+	if accepted && withSchema {
+		record, ok := s.turnResults.lookup(c.ThreadID, c.TurnID)
+		if !ok || record.Status == turnResultPending {
+			return
+		}
+		if record.Status != turnResultValid {
+			accepted, reason = false, "output "+record.Status
+			if len(record.Errors) > 0 {
+				reason += ": " + strings.Join(record.Errors, "; ")
+			}
+		}
+	}
+	c.finished, c.completedAt = true, outcome.CompletedAt
+	c.TurnStatus, c.Reason = outcome.Status, reason
+	c.DurationMS = outcome.CompletedAt.Sub(c.StartedAt).Milliseconds()
+	if s.uiRuntime != nil {
+		c.Tokens = s.uiRuntime.ThreadTokenUsage(c.ThreadID).UsedTokens
+	}
+	switch {
+	case c.Status == speculationCandidateCancelled:
+	case accepted:
+		c.Status = speculationCandidateAccepted
+	default:
+		c.Status = speculationCandidateRejected
+	}
+}
+
+func (s *Server) finishSpeculation(job *speculationJob, winner int, status string) {
+	var answer string
+	var result json.RawMessage
+	if winner >= 0 {
+		w := job.Candidates[winner]
+		if record, ok := s.turnResults.lookup(w.ThreadID, w.TurnID); ok && record.Status == turnResultValid {
+			result = record.Result
+		}
+		answer = s.awaitTurnReplyText(w.ThreadID, w.TurnID)
+	}
+	// 中断仍在运行的候选 (败方 / 超时), 等其结束以记录成本。
+	pending := false
+	for i := range job.Candidates {
+		c := &job.Candidates[i]
+		if i != winner && c.Status == speculationCandidateAccepted {
+			c.Status = speculationCandidateLost
+		}
+		if !c.finished {
+			c.Status = speculationCandidateCancelled
+			s.interruptSpeculationCandidate(c.ThreadID)
+			pending = true
+		}
+	}
+	for deadline := time.Now().Add(speculationLoserWait); pending && time.Now().Before(deadline); {
+		time.Sleep(turnAwaitPollInterval)
+		pending = false
+		for i := range job.Candidates {
+			s.pollSpeculationCandidate(&job.Candidates[i], job.withSchema)
+			pending = pending || !job.Candidates[i].finished
+		}
+	}
+	s.stopSpeculationShadow(job.shadowThread)
+
+	decidedAt := time.Now()
+	s.speculations.update(job.ID, func(stored *speculationJob) {
+		stored.Status, stored.Candidates, stored.DecidedAt = status, job.Candidates, &decidedAt
+		stored.Answer, stored.Result = answer, result
+		if winner >= 0 {
+			stored.Winner = job.Candidates[winner].Role
+		}
+	})
+	final, _ := s.speculations.snapshot(job.ID)
+	logger.Info("turn/speculate: finished",
+		logger.FieldThreadID, job.ThreadID,
+		"speculation_id", job.ID,
+		logger.FieldStatus, status,
+		"winner", final.Winner,
+	)
+	s.recordSpeculationTrace(final)
+	s.Notify("turn/speculation/completed", final)
+}
+
+// interruptSpeculationCandidate 中断候选线程上进行中的 turn。
+func (s *Server) interruptSpeculationCandidate(threadID string) {
+	proc := s.mgr.Get(threadID)
+	if proc == nil {
+		return
+	}
+	s.markTrackedTurnInterruptRequested(threadID)
+	if err := proc.Client.SendCommand(codex.CmdInterrupt, ""); err != nil && !isInterruptNoActiveTurnError(err) {
+		logger.Warn("turn/speculate: interrupt failed", logger.FieldThreadID, threadID, logger.FieldError, err)
+	}
+}
+
+func (s *Server) stopSpeculationShadow(threadID string) {
+	if s.mgr.Get(threadID) == nil {
+		return
+	}
+	if err := s.mgr.Stop(threadID); err != nil {
+		logger.Warn("turn/speculate: stop shadow thread failed", logger.FieldThreadID, threadID, logger.FieldError, err)
+	}
+}
+
+// recordSpeculationTrace 将两个候选的耗时与 token 用量写入 task_traces (经离线 WAL)。
+func (s *Server) recordSpeculationTrace(job speculationJob) {
+	if s.taskTraceStore == nil {
+		return
+	}
+	finishedAt := time.Now()
+	if job.DecidedAt != nil {
+		finishedAt = *job.DecidedAt
+	}
+	trace := &store.TaskTrace{
+		TraceID:    job.ID,
+		SpanID:     job.ID,
+		SpanName:   speculationSpanName,
+		Component:  job.ThreadID,
+		Status:     job.Status,
+		Metadata:   map[string]any{"winner": job.Winner, "candidates": job.Candidates},
+		StartedAt:  job.CreatedAt,
+		FinishedAt: &finishedAt,
+		DurationMS: int(finishedAt.Sub(job.CreatedAt).Milliseconds()),
+	}
+	util.SafeGo(func() {
+		if err := s.persistDurable(context.Background(), walOp{Kind: walKindTaskTrace, Trace: trace}); err != nil {
+			logger.Warn("turn/speculate: persist trace failed", logger.FieldThreadID, job.ThreadID, logger.FieldError, err)
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/codex"
+	"github.com/multi-agent/go-agent-v2/internal/runner"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+func TestDecideSpeculation(t *testing.T) {
+	running := speculationCandidate{Status: speculationCandidateRunning}
+	accepted := speculationCandidate{Status: speculationCandidateAccepted, completedAt: time.Unix(20, 0)}
+	earlier := speculationCandidate{Status: speculationCandidateAccepted, completedAt: time.Unix(10, 0)}
+	rejected := speculationCandidate{Status: speculationCandidateRejected}
+	cases := []struct {
+		in         []speculationCandidate
+		wantWinner int
+		wantStatus string
+	}{
+		{[]speculationCandidate{running, running}, -1, speculationStatusRunning},
+		{[]speculationCandidate{rejected, running}, -1, speculationStatusRunning},
+		{[]speculationCandidate{rejected, accepted}, 1, speculationStatusDecided},
+		{[]speculationCandidate{accepted, running}, 0, speculationStatusDecided},
+		{[]speculationCandidate{rejected, rejected}, -1, speculationStatusRejected},
+		{[]speculationCandidate{accepted, earlier}, 1, speculationStatusDecided},
+	}
+	for _, tc := range cases {
+		if winner, status := decideSpeculation(tc.in); winner != tc.wantWinner || status != tc.wantStatus {
+			t.Errorf("decide(%v) = %d %s, want %d %s", tc.in, winner, status, tc.wantWinner, tc.wantStatus)
+		}
+	}
+}
+
+func TestTurnSpeculate_FirstAcceptableWinsAndShadowStops(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	mgr := runner.NewAgentManager()
+	mgr.SetClientFactory(codex.MockClientFactory(nil, 0))
+	srv := New(Deps{Manager: mgr})
+	mgr.SetOnEvent(func(agentID string, event codex.Event) {
+		srv.AgentEventHandler(agentID)(event)
+	})
+	completed := make(chan struct{}, 1)
+	srv.SetNotifyHook(func(method string, _ any) {
+		if method == "turn/speculation/completed" {
+			completed <- struct{}{}
+		}
+	})
+	ctx := context.Background()
+	res, err := srv.InvokeMethod(ctx, "thread/start", json.RawMessage(`{"cwd":"."}`))
+	if err != nil {
+		t.Fatalf("thread/start: %v", err)
+	}
+	threadID := res.(threadStartResponse).Thread.ID
+
+	params := turnSpeculateParams{ThreadID: threadID, Prompt: "hello", CheapModel: "mini", StrongModel: "large"}
+	if _, err := srv.turnSpeculateTyped(ctx, params); apperrors.CodeOf(err) != errcode.InvalidInput {
+		t.Fatalf("disabled speculation err = %v, want InvalidInput", err)
+	}
+	srv.speculationEnabled = true
+	if _, err := srv.turnSpeculateTyped(ctx, turnSpeculateParams{ThreadID: threadID, Prompt: "x", CheapModel: "m", StrongModel: "M"}); err == nil {
+		t.Fatal("identical models should be rejected")
+	}
+
+	started, err := srv.turnSpeculateTyped(ctx, params)
+	if err != nil {
+		t.Fatalf("turn/speculate: %v", err)
+	}
+	job := started.(speculationJob)
+	if len(job.Candidates) != 2 || job.Candidates[0].ThreadID != threadID || job.Candidates[1].ThreadID == threadID {
+		t.Fatalf("candidates = %+v", job.Candidates)
+	}
+	shadow := job.Candidates[1].ThreadID
+
+	select {
+	case <-completed:
+	case <-time.After(10 * time.Second):
+		t.Fatal("speculation did not finish")
+	}
+	final, _ := srv.speculations.snapshot(job.ID)
+	if final.Status != speculationStatusDecided || final.Winner == "" || final.DecidedAt == nil {
+		t.Fatalf("final = %+v", final)
+	}
+	for _, c := range final.Candidates {
+		if c.Role == final.Winner && (c.Status != speculationCandidateAccepted || c.TurnStatus != "completed") {
+			t.Fatalf("winner candidate = %+v", c)
+		}
+		if c.Role != final.Winner && c.Status != speculationCandidateLost && c.Status != speculationCandidateCancelled {
+			t.Fatalf("loser candidate = %+v", c)
+		}
+	}
+	if mgr.Get(shadow) != nil {
+		t.Fatal("shadow thread should be stopped")
+	}
+	if srv.modelRoutes.get(threadID) != "mini" {
+		t.Fatalf("primary thread model = %q, want mini", srv.modelRoutes.get(threadID))
+	}
+	if _, err := srv.turnSpeculateResultTyped(ctx, turnSpeculateResultParams{SpeculationID: "missing"}); err == nil {
+		t.Fatal("unknown speculation should fail")
+	}
+}
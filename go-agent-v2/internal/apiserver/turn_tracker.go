@@ -1,11 +1,13 @@
 package apiserver
 
 import (
+	"context"
 	"fmt"
 	"sort"
 	"strings"
 	"time"
 
+	"github.com/multi-agent/go-agent-v2/internal/store"
 	"github.com/multi-agent/go-agent-v2/pkg/logger"
 	"github.com/multi-agent/go-agent-v2/pkg/util"
 )
@@ -13,6 +15,7 @@ import (
 const defaultTurnWatchdogTimeout = 10 * time.Minute
 const defaultTrackedTurnSummaryTTL = 30 * time.Minute
 const trackedTurnSummaryCacheMaxEntries = 512
+const trackedTurnReplyWait = 2 * time.Second
 const defaultStallThreshold = 480 * time.Second
 const defaultStallHeartbeat = 300 * time.Second
 
@@ -26,6 +29,9 @@ type trackedTurn struct {
 	stallHintLogged      bool
 	stallGraceStarted    bool
 	stallAutoInterrupted bool
+	stallStep            int                 // 已执行的 stall 处置步数 (见 stuck_turn.go)
+	stallActions         []string            // 已执行的处置动作, 随 turn span 一并写入 task_traces
+	route                *modelRouteDecision // 模型路由决策, 随 turn span 一并写入 task_traces
 	done                 chan string
 	timer                *time.Timer
 	stallTimer           *time.Timer
@@ -267,6 +273,7 @@ func (s *Server) completeTrackedTurnByID(threadID, turnID, status, reason string
 	case turn.done <- finalStatus:
 	default:
 	}
+	s.recordTurnOutcomeLocked(id, turn.ID, finalStatus, strings.TrimSpace(reason))
 	s.turnMu.Unlock()
 
 	payload := map[string]any{
@@ -286,9 +293,56 @@ func (s *Server) completeTrackedTurnByID(threadID, turnID, status, reason string
 		"duration_ms", time.Since(turn.StartedAt).Milliseconds(),
 		"interrupt_requested", turn.InterruptRequested,
 	)
+	s.releaseScheduledTurn(id)
+	s.qualityGate.finish(id)
+	s.finishReview(id, turn.ID, finalStatus)
+	s.finishPlanTurn(id, turn.ID, finalStatus)
+	retrying := s.maybeRetryTurn(id, turn.ID, finalStatus, strings.TrimSpace(reason), turn.InterruptRequested)
+	s.finishTurnOutput(id, turn.ID, finalStatus, retrying)
+	s.turnUndo.seal(id, turn.ID, finalStatus)
+	s.recordTurnTrace(id, turn.ID, turn.StartedAt, finalStatus, strings.TrimSpace(reason), turn.stallActions, turn.route)
+	s.finishAuditTurn(id, turn.ID, finalStatus, strings.TrimSpace(reason), time.Since(turn.StartedAt))
+	if finalStatus == "failed" {
+		s.notifyTurnFailed(id, turn.ID, strings.TrimSpace(reason), time.Since(turn.StartedAt))
+	}
 	return payload, true
 }
 
+// recordTurnTrace 将结束的 turn 写入 task_traces (component = threadID, 经离线 WAL)。
+func (s *Server) recordTurnTrace(threadID, turnID string, startedAt time.Time, status, reason string, stallActions []string, route *modelRouteDecision) {
+	if s.taskTraceStore == nil || strings.TrimSpace(turnID) == "" {
+		return
+	}
+	finishedAt := time.Now()
+	metadata := map[string]any{"reason": reason}
+	if len(stallActions) > 0 {
+		metadata["stallActions"] = stallActions
+	}
+	if route != nil {
+		metadata["modelRoute"] = route
+	}
+	trace := &store.TaskTrace{
+		TraceID:    turnID,
+		SpanID:     turnID,
+		SpanName:   store.TurnSpanName,
+		Component:  threadID,
+		Status:     status,
+		Metadata:   metadata,
+		StartedAt:  startedAt,
+		FinishedAt: &finishedAt,
+		DurationMS: int(finishedAt.Sub(startedAt).Milliseconds()),
+	}
+	if status == "failed" {
+		trace.ErrorText = reason
+	}
+	util.SafeGo(func() {
+		if err := s.persistDurable(context.Background(), walOp{Kind: walKindTaskTrace, Trace: trace}); err != nil {
+			logger.Warn("turn tracker: persist task trace failed",
+				logger.FieldThreadID, threadID, logger.FieldTurnID, turnID, logger.FieldError, err)
+		}
+	})
+}
+
 func trackedTurnSummaryFromPayload(payload map[string]any) string {
 	if payload == nil {
 		return ""
@@ -402,6 +456,20 @@ func (s *Server) lookupTrackedTurnSummary(threadID, turnID string) string {
 	return ""
 }
 
+// awaitTurnReplyText turn 结束后读取回复 (摘要缓存在完成事件之后写入, 短暂等待)。
+func (s *Server) awaitTurnReplyText(threadID, turnID string) string {
+	deadline := time.Now().Add(trackedTurnReplyWait)
+	for {
+		if text := strings.TrimSpace(s.lookupTrackedTurnSummary(threadID, turnID)); text != "" {
+			return text
+		}
+		if time.Now().After(deadline) {
+			return strings.TrimSpace(s.lastAssistantText(threadID))
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
 func injectTrackedTurnSummary(payload map[string]any, summary string) {
 	if payload == nil {
 		return
@@ -458,6 +526,7 @@ func (s *Server) captureAndInjectTurnSummary(threadID, eventType, method string,
 	}
 	injectTrackedTurnSummary(payload, summary)
 	s.rememberTrackedTurnSummary(id, resolvedTurnID, summary)
+	s.rememberTurnSummary(id, resolvedTurnID, summary)
 }
 
 func mergeTrackedTurnCompletionPayload(payload, completion map[string]any) {
@@ -665,8 +734,8 @@ func shouldLogTrackedTurnStallHint(eventType, method string, startedAt time.Time
 }
 
 // checkTurnStall is called periodically by the stall timer.
-// If no events have been received for the configured stall threshold, it pushes
-// an alert and auto-interrupts the turn.
+// If no events have been received for the configured stall threshold, it runs the
+// next step of the configured remediation chain (see stuck_turn.go).
 func (s *Server) checkTurnStall(threadID, turnID string) {
 	s.turnMu.Lock()
 	if s.activeTurns == nil {
@@ -692,24 +761,7 @@ func (s *Server) checkTurnStall(threadID, turnID string) {
 		return
 	}
 
-	// Already auto-interrupted — nothing to do.
-	if turn.stallAutoInterrupted {
-		s.turnMu.Unlock()
-		return
-	}
-
-	// Grace period: first detection → warn + reschedule 30s.
-	if !turn.stallGraceStarted {
-		turn.stallGraceStarted = true
-		s.turnMu.Unlock()
-		s.handleStallGracePeriod(threadID, turnID, silent, threshold)
-		return
-	}
-
-	// Second detection (after grace period) → actually interrupt.
-	turn.stallAutoInterrupted = true
-	s.turnMu.Unlock()
-	s.executeStallAutoInterrupt(threadID, turnID, silent, threshold)
+	s.advanceStallRemediation(turn, threadID, turnID, silent, threshold)
 }
 
 // rescheduleStallCheck schedules the next stall check timer.
@@ -728,83 +780,6 @@ func (s *Server) rescheduleStallCheck(turn *trackedTurn, threadID, turnID string
 	})
 }
 
-// handleStallGracePeriod begins the grace period on first stall detection:
-// logs a warning, pushes a UI alert, and schedules a final check after the grace period.
-// Must be called with s.turnMu released.
-func (s *Server) handleStallGracePeriod(threadID, turnID string, silent, threshold time.Duration) {
-	const stallGracePeriod = 30 * time.Second
-
-	logger.Warn("turn tracker: thinking stall detected — grace period started",
-		logger.FieldThreadID, threadID,
-		logger.FieldTurnID, turnID,
-		"silent_ms", silent.Milliseconds(),
-		"threshold_ms", threshold.Milliseconds(),
-		"grace_period_ms", stallGracePeriod.Milliseconds(),
-	)
-
-	if s.uiRuntime != nil {
-		s.uiRuntime.PushAlert(threadID, "stall_warning",
-			fmt.Sprintf("思考已 %ds 未响应，将在 %ds 后自动中断",
-				int(silent.Seconds()), int(stallGracePeriod.Seconds())))
-	}
-
-	// Re-lock to schedule grace period timer.
-	s.turnMu.Lock()
-	turn, ok := s.activeTurns[threadID]
-	if ok && turn != nil && turn.ID == turnID {
-		turn.stallTimer = time.AfterFunc(stallGracePeriod, func() {
-			s.checkTurnStall(threadID, turnID)
-		})
-	}
-	s.turnMu.Unlock()
-}
-
-// executeStallAutoInterrupt performs the actual auto-interrupt after the grace period expires.
-// Must be called with s.turnMu released and turn.stallAutoInterrupted already set.
-func (s *Server) executeStallAutoInterrupt(threadID, turnID string, silent, threshold time.Duration) {
-	logger.Warn("turn tracker: thinking stall detected — auto interrupting",
-		logger.FieldThreadID, threadID,
-		logger.FieldTurnID, turnID,
-		"silent_ms", silent.Milliseconds(),
-		"threshold_ms", threshold.Milliseconds(),
-	)
-
-	if s.uiRuntime != nil {
-		s.uiRuntime.PushAlert(threadID, "stall",
-			fmt.Sprintf("思考超时 %ds 未响应，自动中断", int(silent.Seconds())))
-	}
-
-	// Auto-interrupt: send /interrupt to codex process (same as turnInterrupt).
-	util.SafeGo(func() {
-		s.markTrackedTurnInterruptRequested(threadID)
-		if cancelled := s.cancelCodeRuns(threadID); cancelled > 0 {
-			logger.Info("turn tracker: cancelled running code_run executions",
-				logger.FieldThreadID, threadID,
-				logger.FieldTurnID, turnID,
-				"cancelled_runs", cancelled,
-			)
-		}
-		interrupted := false
-		if proc := s.mgr.Get(threadID); proc != nil {
-			if err := proc.Client.SendCommand("/interrupt", ""); err != nil {
-				logger.Warn("turn tracker: stall auto-interrupt failed",
-					logger.FieldThreadID, threadID,
-					logger.FieldTurnID, turnID,
-					logger.FieldError, err,
-				)
-			} else {
-				interrupted = true
-			}
-		}
-		// Fallback: if /interrupt failed or process is gone, force-complete the tracker.
-		if !interrupted {
-			if completion, ok := s.completeTrackedTurnByID(threadID, turnID, "failed", "thinking_stall_timeout"); ok {
-				s.Notify("turn/completed", completion)
-			}
-		}
-	})
-}
-
 // touchTrackedTurnLastEvent updates the LastEventAt heartbeat for the turn.
 // Call this whenever any event arrives for a tracked turn.
 func (s *Server) touchTrackedTurnLastEvent(threadID string) {
@@ -967,3 +942,15 @@ func extractTrackedString(payload map[string]any, keys ...string) string {
 	}
 	return ""
 }
+
+// setTrackedTurnRoute 记录 turn 的模型路由决策 (turn 已结束或已被替换时忽略)。
+func (s *Server) setTrackedTurnRoute(threadID, turnID string, route *modelRouteDecision) {
+	if route == nil {
+		return
+	}
+	s.turnMu.Lock()
+	defer s.turnMu.Unlock()
+	if turn, ok := s.activeTurns[strings.TrimSpace(threadID)]; ok && turn != nil && turn.ID == turnID {
+		turn.route = route
+	}
+}
@@ -0,0 +1,446 @@
+// turn_undo.go — 文件级撤销栈: 按 turn 记录文件快照, thread/undo/preview 预览, thread/undo 部分撤销。
+//
+// thread/rollback 直接向 codex 发送 /undo, 无法得知会还原哪些文件。这里在服务端维护撤销栈:
+//  1. turn 内首次出现某文件的改动 (item/started / 审批请求, 均早于写盘) 时保存其原始内容与哈希;
+//  2. turn 结束时记录各文件的 after 哈希并封存该条目 (每线程最多 maxUndoTurnsPerThread 条);
+//  3. 撤销时当前哈希 ≠ after 哈希视为冲突 (之后又被修改), 默认跳过, force=true 时仍然还原。
+//
+// 可通过 files 只撤销部分文件; 已还原的文件从条目中移除, 条目清空后出栈。
+package apiserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+const (
+	// maxUndoTurnsPerThread 每线程保留的可撤销 turn 数。
+	maxUndoTurnsPerThread = 20
+	// maxUndoSnapshotBytes 单文件快照上限, 超出时只记录哈希 (不可撤销)。
+	maxUndoSnapshotBytes = 4 << 20
+)
+
+// 撤销计划中单个文件的动作。
+const (
+	undoActionRestore     = "restore"     // 写回 turn 前内容
+	undoActionDelete      = "delete"      // turn 新建的文件, 删除
+	undoActionUnchanged   = "unchanged"   // 当前内容已与 turn 前一致
+	undoActionConflict    = "conflict"    // turn 结束后又被修改
+	undoActionUnavailable = "unavailable" // 快照超出大小上限
+)
+
+// undoFileSnapshot 单个文件在 turn 前后的状态。
+type undoFileSnapshot struct {
+	Path       string // 绝对路径
+	Existed    bool
+	Before     []byte
+	Mode       fs.FileMode
+	BeforeHash string // 文件不存在时为空
+	AfterHash  string
+	TooLarge   bool
+}
+
+// undoTurnEntry 一个 turn 改动过的文件。
+type undoTurnEntry struct {
+	TurnID      string
+	Status      string
+	CompletedAt time.Time
+	Files       []*undoFileSnapshot // 按首次改动顺序
+	sealed      bool
+}
+
+// turnUndoTable 各线程的撤销栈 (threadID → 由旧到新)。
+type turnUndoTable struct {
+	mu     sync.Mutex
+	stacks map[string][]*undoTurnEntry
+}
+
+// capture 记录 turn 内首次改动的文件原始内容。
+func (t *turnUndoTable) capture(threadID, turnID string, paths []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stacks == nil {
+		t.stacks = make(map[string][]*undoTurnEntry)
+	}
+	stack := t.stacks[threadID]
+	var entry *undoTurnEntry
+	if n := len(stack); n > 0 && !stack[n-1].sealed && stack[n-1].TurnID == turnID {
+		entry = stack[n-1]
+	} else {
+		entry = &undoTurnEntry{TurnID: turnID}
+		t.stacks[threadID] = append(stack, entry)
+	}
+	for _, path := range paths {
+		if entry.file(path) != nil {
+			continue
+		}
+		entry.Files = append(entry.Files, readUndoSnapshot(path))
+	}
+}
+
+// seal turn 结束: 记录 after 哈希并封存, 超出上限时丢弃最旧条目。
+func (t *turnUndoTable) seal(threadID, turnID, status string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stack := t.stacks[threadID]
+	for _, entry := range stack {
+		if entry.sealed || entry.TurnID != turnID {
+			continue
+		}
+		for _, f := range entry.Files {
+			f.AfterHash = hashUndoFile(f.Path)
+		}
+		entry.Status = status
+		entry.CompletedAt = time.Now()
+		entry.sealed = true
+	}
+	if len(stack) > maxUndoTurnsPerThread {
+		t.stacks[threadID] = append([]*undoTurnEntry(nil), stack[len(stack)-maxUndoTurnsPerThread:]...)
+	}
+}
+
+// drop 清除线程的撤销栈。
+func (t *turnUndoTable) drop(threadID string) {
+	t.mu.Lock()
+	delete(t.stacks, threadID)
+	t.mu.Unlock()
+}
+
+// targetLocked 返回指定 turn (缺省最近一个) 的已封存条目; 调用方持有 mu。
+func (t *turnUndoTable) targetLocked(threadID, turnID string) *undoTurnEntry {
+	stack := t.stacks[threadID]
+	for i := len(stack) - 1; i >= 0; i-- {
+		entry := stack[i]
+		if !entry.sealed {
+			continue
+		}
+		if turnID == "" || entry.TurnID == turnID {
+			return entry
+		}
+	}
+	return nil
+}
+
+// removeFilesLocked 从条目移除已撤销的文件, 条目清空后出栈。
+func (t *turnUndoTable) removeFilesLocked(threadID string, entry *undoTurnEntry, paths map[string]bool) int {
+	kept := entry.Files[:0]
+	for _, f := range entry.Files {
+		if !paths[f.Path] {
+			kept = append(kept, f)
+		}
+	}
+	entry.Files = kept
+	if len(kept) > 0 {
+		return len(kept)
+	}
+	stack := t.stacks[threadID]
+	for i, e := range stack {
+		if e == entry {
+			t.stacks[threadID] = append(stack[:i:i], stack[i+1:]...)
+			break
+		}
+	}
+	return 0
+}
+
+func (e *undoTurnEntry) file(path string) *undoFileSnapshot {
+	for _, f := range e.Files {
+		if f.Path == path {
+			return f
+		}
+	}
+	return nil
+}
+
+func readUndoSnapshot(path string) *undoFileSnapshot {
+	snap := &undoFileSnapshot{Path: path, Mode: 0o644}
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return snap
+	}
+	snap.Existed = true
+	snap.Mode = info.Mode().Perm()
+	if info.Size() > maxUndoSnapshotBytes {
+		snap.TooLarge = true
+		snap.BeforeHash = hashUndoFile(path)
+		return snap
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		snap.TooLarge = true
+		return snap
+	}
+	snap.Before = data
+	snap.BeforeHash = contentDigest(data)
+	return snap
+}
+
+// hashUndoFile 文件内容哈希, 不存在 (或不可读) 时为空。
+func hashUndoFile(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return contentDigest(data)
+}
+
+func contentDigest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// captureTurnUndoSnapshots 在文件改动写盘前记录快照 (无进行中的 turn 时忽略)。
+func (s *Server) captureTurnUndoSnapshots(threadID string, files []string) {
+	turnID, _, _, ok := s.peekTrackedTurnMeta(threadID)
+	if !ok || len(files) == 0 {
+		return
+	}
+	s.turnUndo.capture(threadID, turnID, s.resolveThreadFilePaths(threadID, files))
+}
+
+// undoFilePlan 撤销计划中的单个文件。
+type undoFilePlan struct {
+	Path          string `json:"path"` // 工作目录内为相对路径
+	Action        string `json:"action"`
+	ExistedBefore bool   `json:"existedBefore"`
+	BeforeHash    string `json:"beforeHash,omitempty"`
+	AfterHash     string `json:"afterHash,omitempty"`
+	CurrentHash   string `json:"currentHash,omitempty"`
+	Reason        string `json:"reason,omitempty"`
+
+	snap *undoFileSnapshot
+}
+
+// undoTurnSummary 撤销栈中的一个 turn。
+type undoTurnSummary struct {
+	TurnID      string    `json:"turnId"`
+	Status      string    `json:"status,omitempty"`
+	CompletedAt time.Time `json:"completedAt"`
+	Files       []string  `json:"files"`
+}
+
+// threadUndoParams thread/undo/preview 与 thread/undo 请求参数。
+type threadUndoParams struct {
+	ThreadID string   `json:"threadId"`
+	TurnID   string   `json:"turnId,omitempty"` // 缺省 = 最近一个可撤销的 turn
+	Files    []string `json:"files,omitempty"`  // 缺省 = 该 turn 改动的全部文件
+	Force    bool     `json:"force,omitempty"`  // 冲突文件也还原 (仅 thread/undo)
+}
+
+// threadUndoPreviewResponse thread/undo/preview 响应。
+type threadUndoPreviewResponse struct {
+	ThreadID string            `json:"threadId"`
+	TurnID   string            `json:"turnId,omitempty"`
+	Files    []undoFilePlan    `json:"files"`
+	Turns    []undoTurnSummary `json:"turns"` // 撤销栈, 由新到旧
+}
+
+// threadUndoResponse thread/undo 响应。
+type threadUndoResponse struct {
+	ThreadID       string         `json:"threadId"`
+	TurnID         string         `json:"turnId"`
+	Reverted       []undoFilePlan `json:"reverted"`
+	Skipped        []undoFilePlan `json:"skipped"`
+	RemainingFiles int            `json:"remainingFiles"` // 该 turn 仍可撤销的文件数
+}
+
+// planUndoLocked 计算条目中所选文件的撤销动作; 调用方持有 turnUndo.mu。
+func (s *Server) planUndoLocked(threadID string, entry *undoTurnEntry, files []string) ([]undoFilePlan, error) {
+	selected := entry.Files
+	if len(files) > 0 {
+		selected = nil
+		var unknown []string
+		for i, path := range s.resolveThreadFilePaths(threadID, files) {
+			snap := entry.file(path)
+			if snap == nil {
+				unknown = append(unknown, files[i])
+				continue
+			}
+			selected = append(selected, snap)
+		}
+		if len(unknown) > 0 {
+			return nil, apperrors.NewCodef("Server.threadUndo", errcode.InvalidInput,
+				"turn %s did not change: %s", entry.TurnID, strings.Join(unknown, ", "))
+		}
+	}
+	baseDir := s.getAgentWorkDir(threadID)
+	plans := make([]undoFilePlan, 0, len(selected))
+	for _, snap := range selected {
+		plan := undoFilePlan{
+			Path:          undoDisplayPath(baseDir, snap.Path),
+			ExistedBefore: snap.Existed,
+			BeforeHash:    snap.BeforeHash,
+			AfterHash:     snap.AfterHash,
+			CurrentHash:   hashUndoFile(snap.Path),
+			snap:          snap,
+		}
+		switch {
+		case snap.TooLarge:
+			plan.Action = undoActionUnavailable
+			plan.Reason = "file exceeded the snapshot size limit"
+		case plan.CurrentHash == snap.BeforeHash:
+			plan.Action = undoActionUnchanged
+		case plan.CurrentHash != snap.AfterHash:
+			plan.Action = undoActionConflict
+			plan.Reason = "file changed after the turn completed"
+		case snap.Existed:
+			plan.Action = undoActionRestore
+		default:
+			plan.Action = undoActionDelete
+		}
+		plans = append(plans, plan)
+	}
+	return plans, nil
+}
+
+func undoDisplayPath(baseDir, path string) string {
+	if baseDir == "" {
+		return path
+	}
+	if rel, err := filepath.Rel(baseDir, path); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+// threadUndoPreviewTyped 预览撤销会还原的文件 (JSON-RPC: thread/undo/preview)。
+func (s *Server) threadUndoPreviewTyped(_ context.Context, p threadUndoParams) (any, error) {
+	const op = "Server.threadUndoPreview"
+	threadID := strings.TrimSpace(p.ThreadID)
+	if threadID == "" {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "threadId is required")
+	}
+	resp := threadUndoPreviewResponse{ThreadID: threadID, Files: []undoFilePlan{}, Turns: []undoTurnSummary{}}
+
+	s.turnUndo.mu.Lock()
+	defer s.turnUndo.mu.Unlock()
+	baseDir := s.getAgentWorkDir(threadID)
+	stack := s.turnUndo.stacks[threadID]
+	for i := len(stack) - 1; i >= 0; i-- {
+		entry := stack[i]
+		if !entry.sealed {
+			continue
+		}
+		summary := undoTurnSummary{TurnID: entry.TurnID, Status: entry.Status, CompletedAt: entry.CompletedAt, Files: make([]string, 0, len(entry.Files))}
+		for _, f := range entry.Files {
+			summary.Files = append(summary.Files, undoDisplayPath(baseDir, f.Path))
+		}
+		resp.Turns = append(resp.Turns, summary)
+	}
+	entry := s.turnUndo.targetLocked(threadID, strings.TrimSpace(p.TurnID))
+	if entry == nil {
+		if p.TurnID != "" {
+			return nil, apperrors.NewCodef(op, errcode.NotFound, "turn %s has no undoable file changes", p.TurnID)
+		}
+		return resp, nil
+	}
+	plans, err := s.planUndoLocked(threadID, entry, p.Files)
+	if err != nil {
+		return nil, err
+	}
+	resp.TurnID = entry.TurnID
+	resp.Files = plans
+	return resp, nil
+}
+
+// threadUndoTyped 按快照还原 turn 改动的文件, 可限定部分文件 (JSON-RPC: thread/undo)。
+func (s *Server) threadUndoTyped(_ context.Context, p threadUndoParams) (any, error) {
+	const op = "Server.threadUndo"
+	if err := s.frozenError(op); err != nil {
+		return nil, err
+	}
+	threadID := strings.TrimSpace(p.ThreadID)
+	if threadID == "" {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "threadId is required")
+	}
+	if s.hasActiveTrackedTurn(threadID) {
+		return nil, apperrors.NewCodef(op, errcode.InvalidInput, "thread %s has a turn in progress", threadID)
+	}
+
+	s.turnUndo.mu.Lock()
+	defer s.turnUndo.mu.Unlock()
+	entry := s.turnUndo.targetLocked(threadID, strings.TrimSpace(p.TurnID))
+	if entry == nil {
+		if p.TurnID != "" {
+			return nil, apperrors.NewCodef(op, errcode.NotFound, "turn %s has no undoable file changes", p.TurnID)
+		}
+		return nil, apperrors.NewCodef(op, errcode.NotFound, "thread %s has no undoable file changes", threadID)
+	}
+	plans, err := s.planUndoLocked(threadID, entry, p.Files)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := threadUndoResponse{ThreadID: threadID, TurnID: entry.TurnID, Reverted: []undoFilePlan{}, Skipped: []undoFilePlan{}}
+	done := make(map[string]bool, len(plans))
+	for _, plan := range plans {
+		action := plan.Action
+		if action == undoActionConflict && p.Force {
+			action = undoActionRestore
+			if !plan.snap.Existed {
+				action = undoActionDelete
+			}
+		}
+		switch action {
+		case undoActionUnchanged:
+			done[plan.snap.Path] = true
+			resp.Reverted = append(resp.Reverted, plan)
+			continue
+		case undoActionRestore, undoActionDelete:
+		default:
+			resp.Skipped = append(resp.Skipped, plan)
+			continue
+		}
+		if err := applyUndoSnapshot(plan.snap); err != nil {
+			logger.Warn("thread/undo: revert file failed",
+				logger.FieldThreadID, threadID,
+				logger.FieldTurnID, entry.TurnID,
+				logger.FieldPath, plan.snap.Path,
+				logger.FieldError, err,
+			)
+			plan.Reason = err.Error()
+			resp.Skipped = append(resp.Skipped, plan)
+			continue
+		}
+		plan.Action = action
+		plan.CurrentHash = plan.snap.BeforeHash
+		done[plan.snap.Path] = true
+		resp.Reverted = append(resp.Reverted, plan)
+	}
+	resp.RemainingFiles = s.turnUndo.removeFilesLocked(threadID, entry, done)
+
+	logger.Info("thread/undo: files reverted",
+		logger.FieldThreadID, threadID,
+		logger.FieldTurnID, entry.TurnID,
+		"reverted", len(resp.Reverted),
+		"skipped", len(resp.Skipped),
+		"remaining", resp.RemainingFiles,
+	)
+	return resp, nil
+}
+
+// applyUndoSnapshot 写回 turn 前内容; turn 前不存在的文件直接删除。
+func applyUndoSnapshot(snap *undoFileSnapshot) error {
+	if !snap.Existed {
+		if err := os.Remove(snap.Path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(snap.Path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(snap.Path, snap.Before, snap.Mode)
+}
@@ -0,0 +1,134 @@
+package apiserver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+func TestThreadUndoPreviewAndPartialUndo(t *testing.T) {
+	dir := t.TempDir()
+	srv := &Server{activeTurns: make(map[string]*trackedTurn), turnWatchdogTimeout: time.Second}
+	srv.setAgentWorkDir("thread-1", dir)
+	ctx := context.Background()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(name string) string {
+		data, _ := os.ReadFile(filepath.Join(dir, name))
+		return string(data)
+	}
+	runTurn := func(turnID string, files map[string]string) {
+		t.Helper()
+		_ = srv.beginTrackedTurn("thread-1", turnID)
+		for name := range files {
+			srv.captureTurnUndoSnapshots("thread-1", []string{name})
+		}
+		for name, content := range files {
+			write(name, content)
+		}
+		if _, ok := srv.completeTrackedTurnByID("thread-1", turnID, "completed", ""); !ok {
+			t.Fatalf("complete %s failed", turnID)
+		}
+	}
+	plansByPath := func(plans []undoFilePlan) map[string]string {
+		out := map[string]string{}
+		for _, p := range plans {
+			out[p.Path] = p.Action
+		}
+		return out
+	}
+
+	write("a.txt", "v0")
+	runTurn("turn-1", map[string]string{"a.txt": "v1", "new.txt": "created"})
+	runTurn("turn-2", map[string]string{"a.txt": "v2"})
+
+	raw, err := srv.threadUndoPreviewTyped(ctx, threadUndoParams{ThreadID: "thread-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	preview := raw.(threadUndoPreviewResponse)
+	if preview.TurnID != "turn-2" || len(preview.Turns) != 2 || preview.Turns[0].TurnID != "turn-2" {
+		t.Fatalf("preview = %+v", preview)
+	}
+	if got := plansByPath(preview.Files); got["a.txt"] != undoActionRestore || len(got) != 1 {
+		t.Fatalf("latest plan = %+v", got)
+	}
+	raw, err = srv.threadUndoPreviewTyped(ctx, threadUndoParams{ThreadID: "thread-1", TurnID: "turn-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := plansByPath(raw.(threadUndoPreviewResponse).Files)
+	if got["a.txt"] != undoActionConflict || got["new.txt"] != undoActionDelete {
+		t.Fatalf("turn-1 plan = %+v, want a.txt conflict (changed by turn-2) and new.txt delete", got)
+	}
+
+	// 部分撤销: 只删除 turn-1 新建的文件。
+	raw, err = srv.threadUndoTyped(ctx, threadUndoParams{ThreadID: "thread-1", TurnID: "turn-1", Files: []string{"new.txt"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	undo := raw.(threadUndoResponse)
+	if len(undo.Reverted) != 1 || undo.RemainingFiles != 1 {
+		t.Fatalf("partial undo = %+v", undo)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "new.txt")); !os.IsNotExist(err) {
+		t.Fatalf("new.txt should be deleted, stat err = %v", err)
+	}
+
+	// 冲突文件默认跳过。
+	raw, err = srv.threadUndoTyped(ctx, threadUndoParams{ThreadID: "thread-1", TurnID: "turn-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if undo := raw.(threadUndoResponse); len(undo.Skipped) != 1 || len(undo.Reverted) != 0 || read("a.txt") != "v2" {
+		t.Fatalf("conflict undo = %+v, a.txt = %q", undo, read("a.txt"))
+	}
+
+	// 先撤销 turn-2, turn-1 的 a.txt 不再冲突。
+	if _, err := srv.threadUndoTyped(ctx, threadUndoParams{ThreadID: "thread-1"}); err != nil || read("a.txt") != "v1" {
+		t.Fatalf("undo turn-2: err=%v a.txt=%q", err, read("a.txt"))
+	}
+	if _, err := srv.threadUndoTyped(ctx, threadUndoParams{ThreadID: "thread-1"}); err != nil || read("a.txt") != "v0" {
+		t.Fatalf("undo turn-1: err=%v a.txt=%q", err, read("a.txt"))
+	}
+	raw, _ = srv.threadUndoPreviewTyped(ctx, threadUndoParams{ThreadID: "thread-1"})
+	if preview := raw.(threadUndoPreviewResponse); len(preview.Turns) != 0 || len(preview.Files) != 0 {
+		t.Fatalf("stack should be empty, got %+v", preview)
+	}
+	_, err = srv.threadUndoTyped(ctx, threadUndoParams{ThreadID: "thread-1"})
+	if apperrors.CodeOf(err) != errcode.NotFound {
+		t.Fatalf("undo on empty stack err = %v", err)
+	}
+}
+
+func TestThreadUndoRejectsUnknownFilesAndActiveTurn(t *testing.T) {
+	dir := t.TempDir()
+	srv := &Server{activeTurns: make(map[string]*trackedTurn), turnWatchdogTimeout: time.Second}
+	srv.setAgentWorkDir("thread-1", dir)
+	ctx := context.Background()
+
+	_ = srv.beginTrackedTurn("thread-1", "turn-1")
+	srv.captureTurnUndoSnapshots("thread-1", []string{"a.txt"})
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.threadUndoTyped(ctx, threadUndoParams{ThreadID: "thread-1"}); apperrors.CodeOf(err) != errcode.InvalidInput {
+		t.Fatalf("undo during active turn err = %v", err)
+	}
+	if _, ok := srv.completeTrackedTurnByID("thread-1", "turn-1", "completed", ""); !ok {
+		t.Fatal("complete failed")
+	}
+	_, err := srv.threadUndoPreviewTyped(ctx, threadUndoParams{ThreadID: "thread-1", Files: []string{"other.txt"}})
+	if apperrors.CodeOf(err) != errcode.InvalidInput {
+		t.Fatalf("unknown file err = %v", err)
+	}
+}
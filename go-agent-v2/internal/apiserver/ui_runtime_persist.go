@@ -0,0 +1,187 @@
+// ui_runtime_persist.go — UI 运行时状态跨重启持久化。
+//
+// UI_STATE_PERSIST_SEC > 0 时, New 中恢复上次保存的线程状态、状态栏、token 用量与 workspace runs
+// (uistate.PersistedRuntimeState), 使 UI 重启后立即显示有意义的状态而非全部空闲;
+// 之后每 UI_STATE_PERSIST_SEC 秒保存一次 (内容未变则跳过), 关闭时 (cleanupRuntimeResources) 再保存一次。
+// 有数据库时存 ui_runtime_state 表, 否则存本地文件 (UI_STATE_FILE, 默认 ~/.multi-agent/ui-runtime-state.json)。
+// 时间线与 diff 不持久化, 按需从 rollout 历史重建。
+package apiserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/store"
+	"github.com/multi-agent/go-agent-v2/internal/uistate"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+	"github.com/multi-agent/go-agent-v2/pkg/util"
+)
+
+const (
+	uiRuntimeStateInstance  = "default"
+	uiRuntimeStateIOTimeout = 5 * time.Second
+)
+
+// uiRuntimePersister 保存 / 读取 UI 运行时状态 (store 为 nil 时用本地文件)。
+type uiRuntimePersister struct {
+	mu        sync.Mutex
+	store     *store.UIRuntimeStateStore
+	path      string
+	lastSaved []byte // 上次保存的内容 (SavedAt 置零), 用于跳过未变的保存
+}
+
+// resolveUIStateFile 本地状态文件路径 (配置优先, 默认 ~/.multi-agent/ui-runtime-state.json)。
+func resolveUIStateFile(path string) (string, error) {
+	if path = strings.TrimSpace(path); path != "" {
+		return path, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", apperrors.Wrap(err, "resolveUIStateFile", "resolve user home")
+	}
+	return filepath.Join(homeDir, ".multi-agent", "ui-runtime-state.json"), nil
+}
+
+func (p *uiRuntimePersister) load(ctx context.Context) (*uistate.PersistedRuntimeState, error) {
+	const op = "uiRuntimePersister.load"
+	var data []byte
+	if p.store != nil {
+		raw, err := p.store.Load(ctx, uiRuntimeStateInstance)
+		if err != nil {
+			return nil, err
+		}
+		data = raw
+	} else {
+		raw, err := os.ReadFile(p.path)
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, apperrors.Wrap(err, op, "read state file")
+		}
+		data = raw
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var state uistate.PersistedRuntimeState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, apperrors.Wrap(err, op, "unmarshal state")
+	}
+	return &state, nil
+}
+
+// save 保存状态, 内容与上次相同时跳过 (返回 false)。
+func (p *uiRuntimePersister) save(ctx context.Context, state uistate.PersistedRuntimeState) (bool, error) {
+	const op = "uiRuntimePersister.save"
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	savedAt := state.SavedAt
+	state.SavedAt = time.Time{}
+	content, err := json.Marshal(state)
+	if err != nil {
+		return false, apperrors.Wrap(err, op, "marshal state")
+	}
+	if bytes.Equal(content, p.lastSaved) {
+		return false, nil
+	}
+	state.SavedAt = savedAt
+	data, err := json.Marshal(state)
+	if err != nil {
+		return false, apperrors.Wrap(err, op, "marshal state")
+	}
+
+	if p.store != nil {
+		if err := p.store.Save(ctx, uiRuntimeStateInstance, data); err != nil {
+			return false, err
+		}
+	} else {
+		if err := os.MkdirAll(filepath.Dir(p.path), 0o755); err != nil {
+			return false, apperrors.Wrap(err, op, "ensure state dir")
+		}
+		tmp := p.path + ".tmp"
+		if err := os.WriteFile(tmp, data, 0o600); err != nil {
+			return false, apperrors.Wrap(err, op, "write state file")
+		}
+		if err := os.Rename(tmp, p.path); err != nil {
+			_ = os.Remove(tmp)
+			return false, apperrors.Wrap(err, op, "replace state file")
+		}
+	}
+	p.lastSaved = content
+	return true, nil
+}
+
+// initUIRuntimePersist 配置持久化并恢复上次保存的状态 (UI_STATE_PERSIST_SEC = 0 时关闭)。
+func (s *Server) initUIRuntimePersist(stateStore *store.UIRuntimeStateStore) {
+	if s.uiRuntime == nil || s.cfg == nil || s.cfg.UIStatePersistSec <= 0 {
+		return
+	}
+	p := &uiRuntimePersister{store: stateStore}
+	if stateStore == nil {
+		path, err := resolveUIStateFile(s.cfg.UIStateFile)
+		if err != nil {
+			logger.Warn("ui state: persistence unavailable", logger.FieldError, err)
+			return
+		}
+		p.path = path
+	}
+	s.uiPersist = p
+
+	ctx, cancel := context.WithTimeout(context.Background(), uiRuntimeStateIOTimeout)
+	defer cancel()
+	state, err := p.load(ctx)
+	if err != nil {
+		logger.Warn("ui state: restore failed", logger.FieldError, err, logger.FieldPath, p.path)
+		return
+	}
+	if state == nil {
+		return
+	}
+	if n := s.uiRuntime.RestorePersistentState(*state); n > 0 {
+		logger.Info("ui state: restored from previous run",
+			logger.FieldCount, n,
+			"saved_at", state.SavedAt.Format(time.RFC3339),
+		)
+	}
+}
+
+// startUIRuntimePersistLoop 周期保存 UI 运行时状态。
+func (s *Server) startUIRuntimePersistLoop(ctx context.Context) {
+	if s.uiPersist == nil {
+		return
+	}
+	interval := time.Duration(s.cfg.UIStatePersistSec) * time.Second
+	util.SafeGo(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.saveUIRuntimeState()
+			}
+		}
+	})
+}
+
+// saveUIRuntimeState 立即保存 UI 运行时状态 (未开启持久化时为空操作)。
+func (s *Server) saveUIRuntimeState() {
+	if s.uiPersist == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), uiRuntimeStateIOTimeout)
+	defer cancel()
+	if _, err := s.uiPersist.save(ctx, s.uiRuntime.PersistentState()); err != nil {
+		logger.Warn("ui state: save failed", logger.FieldError, err, logger.FieldPath, s.uiPersist.path)
+	}
+}
@@ -0,0 +1,40 @@
+package apiserver
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/multi-agent/go-agent-v2/internal/config"
+	"github.com/multi-agent/go-agent-v2/internal/uistate"
+)
+
+func TestUIRuntimePersist_FileRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "ui-runtime-state.json")
+	cfg := &config.Config{UIStatePersistSec: 30, UIStateFile: path}
+
+	first := New(Deps{Config: cfg})
+	first.uiRuntime.ReplaceThreads([]uistate.ThreadSnapshot{{ID: "t1", Name: "worker", State: "error"}})
+	first.cleanupRuntimeResources() // 关闭时保存
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("state file: %v", err)
+	}
+	if saved, err := first.uiPersist.save(t.Context(), first.uiRuntime.PersistentState()); err != nil || saved {
+		t.Fatalf("unchanged save = %v, %v", saved, err)
+	}
+	if after, _ := os.Stat(path); !after.ModTime().Equal(info.ModTime()) {
+		t.Fatal("unchanged state should not rewrite the file")
+	}
+
+	second := New(Deps{Config: cfg})
+	snap := second.uiRuntime.Snapshot()
+	if snap.Statuses["t1"] != "error" || len(snap.Threads) != 1 || snap.Threads[0].Name != "worker" {
+		t.Fatalf("restored = %+v", snap)
+	}
+
+	disabled := New(Deps{Config: &config.Config{UIStateFile: path}})
+	if disabled.uiPersist != nil || len(disabled.uiRuntime.Snapshot().Threads) != 0 {
+		t.Fatal("UI_STATE_PERSIST_SEC=0 should neither restore nor save")
+	}
+}
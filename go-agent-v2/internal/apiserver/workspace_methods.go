@@ -6,6 +6,7 @@ import (
 
 	"github.com/multi-agent/go-agent-v2/internal/service"
 	pkgerr "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
 )
 
 func asMap(value any) map[string]any {
@@ -127,6 +128,9 @@ func (s *Server) workspaceRunList(ctx context.Context, params json.RawMessage) (
 }
 
 func (s *Server) workspaceRunMerge(ctx context.Context, params json.RawMessage) (any, error) {
+	if err := s.frozenError("WorkspaceRun.Merge"); err != nil {
+		return nil, err
+	}
 	if s.workspaceMgr == nil {
 		if s.uiRuntime != nil {
 			s.uiRuntime.SetWorkspaceUnavailable("workspace manager not initialized")
@@ -138,6 +142,8 @@ func (s *Server) workspaceRunMerge(ctx context.Context, params json.RawMessage)
 		UpdatedBy     string `json:"updatedBy"`
 		DryRun        bool   `json:"dryRun"`
 		DeleteRemoved bool   `json:"deleteRemoved"`
+		// 合并成功后推送分支并创建 PR (省略 = 不创建)
+		PullRequest *workspacePullRequestOptions `json:"pullRequest"`
 	}
 	if err := json.Unmarshal(params, &p); err != nil {
 		return nil, pkgerr.Wrap(err, "WorkspaceRun.Merge", "invalid params")
@@ -161,7 +167,16 @@ func (s *Server) workspaceRunMerge(ctx context.Context, params json.RawMessage)
 		"runKey": p.RunKey,
 		"result": result,
 	})
-	return map[string]any{"result": result}, nil
+	resp := map[string]any{"result": result}
+	if p.PullRequest != nil {
+		if pr, prErr := s.openRunPullRequest(ctx, p.RunKey, result, *p.PullRequest); prErr != nil {
+			logger.Warn("workspace/run/merge: pull request failed", logger.FieldRunKey, p.RunKey, logger.FieldError, prErr)
+			resp["pullRequestError"] = prErr.Error()
+		} else {
+			resp["pullRequest"] = pr
+		}
+	}
+	return resp, nil
 }
 
 func (s *Server) workspaceRunAbort(ctx context.Context, params json.RawMessage) (any, error) {
@@ -0,0 +1,212 @@
+// workspace_pull_request.go — workspace run 合并后自动推送分支并创建 GitHub / GitLab PR。
+//
+// workspace/run/merge 携带 pullRequest 选项, 或合并后单独调用 workspace/run/pullRequest:
+// 将本次合并写入源目录的文件 (merged / deleted) 提交到新分支 (默认 agent/<runKey>) 并推送,
+// 标题 / 正文取自创建该 run 的线程最近一次 turn 摘要。提交不切换源仓库当前分支, 工作区保持合并后的状态。
+// PR 创建失败不回滚合并, 错误随响应返回 (pullRequestError)。
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/service"
+	"github.com/multi-agent/go-agent-v2/internal/vcs"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+const (
+	workspacePRTimeout       = 2 * time.Minute
+	workspacePRTitleMaxRunes = 72
+)
+
+// workspacePullRequestOptions PR 选项 (均可省略)。
+type workspacePullRequestOptions struct {
+	Remote   string `json:"remote,omitempty"`   // 空 = VCS_PR_REMOTE (默认 origin)
+	Base     string `json:"base,omitempty"`     // 目标分支, 空 = 源仓库当前分支
+	Branch   string `json:"branch,omitempty"`   // 源分支, 空 = <VCS_PR_BRANCH_PREFIX><runKey>
+	Title    string `json:"title,omitempty"`    // 空 = turn 摘要首行
+	Body     string `json:"body,omitempty"`     // 空 = turn 摘要 + 变更文件清单
+	ThreadID string `json:"threadId,omitempty"` // 摘要来源线程, 空 = run 的 createdBy
+	Draft    bool   `json:"draft,omitempty"`
+}
+
+// workspacePullRequestResult PR 创建结果。
+type workspacePullRequestResult struct {
+	vcs.PullRequestResult
+	Branch string `json:"branch"`
+	Base   string `json:"base"`
+	Commit string `json:"commit"`
+	Files  int    `json:"files"`
+}
+
+func (s *Server) vcsClient() *vcs.Client {
+	if s.cfg == nil {
+		return &vcs.Client{}
+	}
+	return &vcs.Client{
+		GitHubToken:  s.cfg.VCSGitHubToken,
+		GitHubAPIURL: s.cfg.VCSGitHubAPIURL,
+		GitLabToken:  s.cfg.VCSGitLabToken,
+		GitLabAPIURL: s.cfg.VCSGitLabAPIURL,
+	}
+}
+
+// mergedRunFiles 本次合并实际写入 / 删除的文件。
+func mergedRunFiles(result *service.WorkspaceMergeResult) []string {
+	var files []string
+	for _, file := range result.Files {
+		if file.Action == "merged" || file.Action == "deleted" {
+			files = append(files, file.Path)
+		}
+	}
+	return files
+}
+
+// buildPullRequestText 生成 PR 标题与正文。
+func buildPullRequestText(runKey, summary string, files []string) (string, string) {
+	summary = strings.TrimSpace(summary)
+	title := ""
+	if summary != "" {
+		first, _, _ := strings.Cut(summary, "\n")
+		title = truncateOrchestrationSummary(strings.TrimLeft(first, "#*- "), workspacePRTitleMaxRunes)
+	}
+	if title == "" {
+		title = "Agent workspace run " + runKey
+	}
+	var body strings.Builder
+	if summary != "" {
+		body.WriteString(summary)
+		body.WriteString("\n\n")
+	}
+	fmt.Fprintf(&body, "### Changed files (%d)\n\n", len(files))
+	for _, file := range files {
+		fmt.Fprintf(&body, "- `%s`\n", filepath.ToSlash(file))
+	}
+	fmt.Fprintf(&body, "\n---\nWorkspace run: `%s`\n", runKey)
+	return title, body.String()
+}
+
+// openRunPullRequest 提交合并文件到新分支、推送并创建 PR。
+func (s *Server) openRunPullRequest(ctx context.Context, runKey string, result *service.WorkspaceMergeResult, opts workspacePullRequestOptions) (*workspacePullRequestResult, error) {
+	if result.DryRun {
+		return nil, apperrors.New("Server.workspacePullRequest", "dry-run merge cannot open a pull request")
+	}
+	if result.Status != service.WorkspaceRunStatusMerged {
+		return nil, apperrors.Newf("Server.workspacePullRequest", "run %s status is %s, want merged", runKey, result.Status)
+	}
+	files := mergedRunFiles(result)
+	if len(files) == 0 {
+		return nil, apperrors.Newf("Server.workspacePullRequest", "run %s merged no files", runKey)
+	}
+	ctx, cancel := context.WithTimeout(ctx, workspacePRTimeout)
+	defer cancel()
+
+	remoteName := strings.TrimSpace(opts.Remote)
+	branchPrefix := "agent/"
+	if s.cfg != nil {
+		if remoteName == "" {
+			remoteName = s.cfg.VCSPRRemote
+		}
+		branchPrefix = s.cfg.VCSPRBranchPrefix
+	}
+	if remoteName == "" {
+		remoteName = "origin"
+	}
+	repo := vcs.Repo{Dir: result.SourceRoot}
+	rawURL, err := repo.RemoteURL(ctx, remoteName)
+	if err != nil {
+		return nil, apperrors.Wrapf(err, "Server.workspacePullRequest", "resolve remote %s", remoteName)
+	}
+	remote, err := vcs.ParseRemoteURL(rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	base := strings.TrimSpace(opts.Base)
+	if base == "" {
+		if base, err = repo.CurrentBranch(ctx); err != nil {
+			return nil, apperrors.Wrap(err, "Server.workspacePullRequest", "resolve base branch")
+		}
+	}
+	branch := strings.TrimSpace(opts.Branch)
+	if branch == "" {
+		branch = branchPrefix + runKey
+	}
+
+	threadID := strings.TrimSpace(opts.ThreadID)
+	if threadID == "" && s.workspaceMgr != nil {
+		if run, getErr := s.workspaceMgr.GetRun(ctx, runKey); getErr == nil && run != nil {
+			threadID = run.CreatedBy
+		}
+	}
+	title, body := buildPullRequestText(runKey, s.lookupTrackedTurnSummary(threadID, ""), files)
+	if strings.TrimSpace(opts.Title) != "" {
+		title = strings.TrimSpace(opts.Title)
+	}
+	if strings.TrimSpace(opts.Body) != "" {
+		body = opts.Body
+	}
+
+	client := s.vcsClient()
+	sha, err := repo.CommitFiles(ctx, files, title+"\n\n"+body, vcs.Author{})
+	if err != nil {
+		return nil, apperrors.Wrap(err, "Server.workspacePullRequest", "commit merged files")
+	}
+	tokenUser, token := client.Token(remote.Provider)
+	if err := repo.Push(ctx, remoteName, sha, branch, tokenUser, token, true); err != nil {
+		return nil, apperrors.Wrapf(err, "Server.workspacePullRequest", "push branch %s", branch)
+	}
+	pr, err := client.CreatePullRequest(ctx, vcs.PullRequest{
+		Remote: remote, Head: branch, Base: base, Title: title, Body: body, Draft: opts.Draft,
+	})
+	if err != nil {
+		return nil, apperrors.Wrap(err, "Server.workspacePullRequest", "create pull request")
+	}
+	logger.Info("workspace/run: pull request opened",
+		logger.FieldRunKey, runKey,
+		logger.FieldURL, pr.URL,
+		"branch", branch,
+		"base", base,
+		"commit", sha,
+		"existing", pr.Existing,
+	)
+	out := &workspacePullRequestResult{PullRequestResult: *pr, Branch: branch, Base: base, Commit: sha, Files: len(files)}
+	s.Notify("workspace/run/pullRequest", map[string]any{"runKey": runKey, "pullRequest": out})
+	return out, nil
+}
+
+// workspaceRunPullRequest 对已合并的 run 重新发起 PR (首次失败后重试)。
+func (s *Server) workspaceRunPullRequest(ctx context.Context, params json.RawMessage) (any, error) {
+	if s.workspaceMgr == nil {
+		return nil, apperrors.New("WorkspaceRun", "workspace manager not initialized")
+	}
+	var p struct {
+		RunKey string `json:"runKey"`
+		workspacePullRequestOptions
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, apperrors.Wrap(err, "WorkspaceRun.PullRequest", "invalid params")
+	}
+	if p.RunKey == "" {
+		return nil, apperrors.New("WorkspaceRun", "runKey is required")
+	}
+	run, err := s.workspaceMgr.GetRun(ctx, p.RunKey)
+	if err != nil {
+		return nil, apperrors.Wrap(err, "WorkspaceRun.PullRequest", "get run")
+	}
+	files, err := s.workspaceMgr.ListMergedFiles(ctx, p.RunKey)
+	if err != nil {
+		return nil, apperrors.Wrap(err, "WorkspaceRun.PullRequest", "list merged files")
+	}
+	result := &service.WorkspaceMergeResult{RunKey: run.RunKey, Status: run.Status, SourceRoot: run.SourceRoot, Files: files}
+	pr, err := s.openRunPullRequest(ctx, p.RunKey, result, p.workspacePullRequestOptions)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"pullRequest": pr}, nil
+}
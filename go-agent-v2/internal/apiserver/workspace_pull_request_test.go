@@ -0,0 +1,37 @@
+package apiserver
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/multi-agent/go-agent-v2/internal/service"
+)
+
+func TestBuildPullRequestText(t *testing.T) {
+	title, body := buildPullRequestText("run-1", "## Fix flaky retry loop\nDetails here", []string{"a/b.go", "c.go"})
+	if title != "Fix flaky retry loop" {
+		t.Fatalf("title = %q", title)
+	}
+	if !strings.HasPrefix(body, "## Fix flaky retry loop\nDetails here") || !strings.Contains(body, "### Changed files (2)") || !strings.Contains(body, "- `a/b.go`") || !strings.Contains(body, "`run-1`") {
+		t.Fatalf("body = %q", body)
+	}
+	if title, _ := buildPullRequestText("run-2", "", nil); title != "Agent workspace run run-2" {
+		t.Fatalf("fallback title = %q", title)
+	}
+}
+
+func TestOpenRunPullRequestRejectsUnmergedRuns(t *testing.T) {
+	srv := &Server{}
+	ctx := context.Background()
+	if _, err := srv.openRunPullRequest(ctx, "r", &service.WorkspaceMergeResult{DryRun: true}, workspacePullRequestOptions{}); err == nil {
+		t.Fatal("dry-run should be rejected")
+	}
+	if _, err := srv.openRunPullRequest(ctx, "r", &service.WorkspaceMergeResult{Status: service.WorkspaceRunStatusFailed}, workspacePullRequestOptions{}); err == nil {
+		t.Fatal("failed merge should be rejected")
+	}
+	result := &service.WorkspaceMergeResult{Status: service.WorkspaceRunStatusMerged, Files: []service.WorkspaceMergeFileResult{{Path: "x", Action: "unchanged"}}}
+	if _, err := srv.openRunPullRequest(ctx, "r", result, workspacePullRequestOptions{}); err == nil {
+		t.Fatal("merge without written files should be rejected")
+	}
+}
@@ -0,0 +1,234 @@
+// workspace_roots.go — 多根工作区 (monorepo / 多仓库任务)。
+//
+// SetupLSP 的目录为主根, workspace/root/add 追加其他根:
+//   - LSP: 追加的根作为 workspaceFolders 通知语言服务器 (已运行的服务器增量通知, 不重启);
+//   - 诊断缓存按根分组 (根 → 文件 URI → 诊断), lsp/diagnostics/query 可按根过滤;
+//   - fuzzyFileSearch 未指定 roots 时在全部已登记根内搜索。
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/multi-agent/go-agent-v2/internal/lsp"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+const maxWorkspaceRoots = 32
+
+// workspaceRootRegistry 已登记的工作区根 (绝对路径, 主根在前; 零值可用)。
+type workspaceRootRegistry struct {
+	mu    sync.RWMutex
+	roots []string
+}
+
+// add 登记根目录, 已存在返回 false。
+func (r *workspaceRootRegistry) add(root string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if slices.Contains(r.roots, root) {
+		return false, nil
+	}
+	if len(r.roots) >= maxWorkspaceRoots {
+		return false, apperrors.NewCodef("workspaceRootRegistry.add", errcode.InvalidInput, "too many workspace roots (max %d)", maxWorkspaceRoots)
+	}
+	r.roots = append(r.roots, root)
+	return true, nil
+}
+
+// remove 移除根目录 (主根不可移除), 不存在返回 false。
+func (r *workspaceRootRegistry) remove(root string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	idx := slices.Index(r.roots, root)
+	if idx <= 0 {
+		return false
+	}
+	r.roots = slices.Delete(r.roots, idx, idx+1)
+	return true
+}
+
+func (r *workspaceRootRegistry) list() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]string(nil), r.roots...)
+}
+
+// topLevel 不被其他已登记根包含的根 (避免嵌套根重复遍历)。
+func (r *workspaceRootRegistry) topLevel() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]string, 0, len(r.roots))
+	for _, root := range r.roots {
+		nested := false
+		for _, other := range r.roots {
+			if other == root {
+				continue
+			}
+			if within, err := pathWithinRoot(other, root); err == nil && within {
+				nested = true
+				break
+			}
+		}
+		if !nested {
+			out = append(out, root)
+		}
+	}
+	return out
+}
+
+// match 返回包含 path 的最深根目录 (不在任何根内返回空串)。
+func (r *workspaceRootRegistry) match(path string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	best := ""
+	for _, root := range r.roots {
+		if len(root) <= len(best) {
+			continue
+		}
+		if within, err := pathWithinRoot(root, path); err == nil && within {
+			best = root
+		}
+	}
+	return best
+}
+
+// ========================================
+// 诊断缓存
+// ========================================
+
+// lspDiagnosticCache LSP 诊断缓存: 工作区根 → 文件 URI → 诊断 (根外文件归入空串; 调用方持有 diagMu)。
+type lspDiagnosticCache map[string]map[string][]lsp.Diagnostic
+
+// set 写入文件诊断 (空列表即清除); 文件改归其他根时从旧根移除。
+func (c lspDiagnosticCache) set(root, uri string, diags []lsp.Diagnostic) {
+	for r, files := range c {
+		if r == root {
+			continue
+		}
+		delete(files, uri)
+		if len(files) == 0 {
+			delete(c, r)
+		}
+	}
+	if len(diags) == 0 {
+		if files, ok := c[root]; ok {
+			delete(files, uri)
+			if len(files) == 0 {
+				delete(c, root)
+			}
+		}
+		return
+	}
+	files, ok := c[root]
+	if !ok {
+		files = make(map[string][]lsp.Diagnostic)
+		c[root] = files
+	}
+	files[uri] = diags
+}
+
+// get 按文件 URI 查询诊断。
+func (c lspDiagnosticCache) get(uri string) []lsp.Diagnostic {
+	for _, files := range c {
+		if diags, ok := files[uri]; ok {
+			return diags
+		}
+	}
+	return nil
+}
+
+// each 遍历全部诊断。
+func (c lspDiagnosticCache) each(fn func(root, uri string, diags []lsp.Diagnostic)) {
+	for root, files := range c {
+		for uri, diags := range files {
+			fn(root, uri, diags)
+		}
+	}
+}
+
+// setDiagnostics LSP publishDiagnostics 回调: 按所属工作区根写入缓存。
+func (s *Server) setDiagnostics(uri string, diagnostics []lsp.Diagnostic) {
+	root := s.workspaceRoots.match(diagnosticURIPath(uri))
+	s.diagMu.Lock()
+	s.diagCache.set(root, uri, diagnostics)
+	s.diagMu.Unlock()
+}
+
+// ========================================
+// JSON-RPC
+// ========================================
+
+type workspaceRootParams struct {
+	Path string `json:"path"`
+}
+
+// resolveWorkspaceRoot 校验并规整根目录 (须为存在的目录)。
+func resolveWorkspaceRoot(op, path string) (string, error) {
+	root := normalizeAgentWorkDir(path)
+	if root == "" {
+		return "", apperrors.NewCode(op, errcode.InvalidInput, "path is required")
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return "", apperrors.WrapCode(err, op, errcode.InvalidInput, "stat root")
+	}
+	if !info.IsDir() {
+		return "", apperrors.NewCodef(op, errcode.InvalidInput, "%s is not a directory", root)
+	}
+	return root, nil
+}
+
+func (s *Server) workspaceRootAddTyped(_ context.Context, p workspaceRootParams) (any, error) {
+	const op = "Server.workspaceRootAdd"
+	root, err := resolveWorkspaceRoot(op, p.Path)
+	if err != nil {
+		return nil, err
+	}
+	added, err := s.workspaceRoots.add(root)
+	if err != nil {
+		return nil, err
+	}
+	if added {
+		if s.lsp != nil {
+			s.lsp.AddWorkspaceFolder(root)
+		}
+		logger.Info("workspace/root/add: registered", logger.FieldRoot, root)
+		s.Notify("workspace/roots/changed", map[string]any{"roots": s.workspaceRoots.list()})
+	}
+	return map[string]any{"root": root, "added": added, "roots": s.workspaceRoots.list()}, nil
+}
+
+func (s *Server) workspaceRootRemoveTyped(_ context.Context, p workspaceRootParams) (any, error) {
+	const op = "Server.workspaceRootRemove"
+	root := normalizeAgentWorkDir(p.Path)
+	if root == "" {
+		return nil, apperrors.NewCode(op, errcode.InvalidInput, "path is required")
+	}
+	removed := s.workspaceRoots.remove(root)
+	if removed {
+		if s.lsp != nil {
+			s.lsp.RemoveWorkspaceFolder(root)
+		}
+		// 该根下的诊断改归其余根 (或根外)
+		s.diagMu.Lock()
+		files := s.diagCache[root]
+		delete(s.diagCache, root)
+		for uri, diags := range files {
+			s.diagCache.set(s.workspaceRoots.match(diagnosticURIPath(uri)), uri, diags)
+		}
+		s.diagMu.Unlock()
+		logger.Info("workspace/root/remove: removed", logger.FieldRoot, root)
+		s.Notify("workspace/roots/changed", map[string]any{"roots": s.workspaceRoots.list()})
+	}
+	return map[string]any{"root": root, "removed": removed, "roots": s.workspaceRoots.list()}, nil
+}
+
+func (s *Server) workspaceRootList(_ context.Context, _ json.RawMessage) (any, error) {
+	return map[string]any{"roots": s.workspaceRoots.list()}, nil
+}
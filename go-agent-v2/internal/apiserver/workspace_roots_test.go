@@ -0,0 +1,104 @@
+package apiserver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/multi-agent/go-agent-v2/internal/lsp"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+func TestWorkspaceRoots_RegistryAndFuzzySearch(t *testing.T) {
+	srv := &Server{diagCache: lspDiagnosticCache{}}
+	ctx := context.Background()
+	mono := t.TempDir()
+	other := t.TempDir()
+	nested := filepath.Join(mono, "services", "api")
+	for _, file := range []string{filepath.Join(nested, "handler.go"), filepath.Join(other, "main_handler.go")} {
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte("package x\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	srv.SetupLSP(mono) // lsp 未配置时仍登记主根
+	for _, root := range []string{other, nested, other} {
+		if _, err := srv.workspaceRootAddTyped(ctx, workspaceRootParams{Path: root}); err != nil {
+			t.Fatalf("add %s: %v", root, err)
+		}
+	}
+	if _, err := srv.workspaceRootAddTyped(ctx, workspaceRootParams{Path: filepath.Join(other, "main_handler.go")}); apperrors.CodeOf(err) != errcode.InvalidInput {
+		t.Fatalf("file root err = %v", err)
+	}
+	if roots := srv.workspaceRoots.list(); len(roots) != 3 || roots[0] != mono {
+		t.Fatalf("roots = %v", roots)
+	}
+	if got := srv.workspaceRoots.match(filepath.Join(nested, "handler.go")); got != nested {
+		t.Fatalf("match nested = %q", got)
+	}
+
+	res, err := srv.fuzzyFileSearchTyped(ctx, fuzzySearchParams{Query: "handler"})
+	if err != nil {
+		t.Fatalf("fuzzy search: %v", err)
+	}
+	files := res.(map[string]any)["files"].([]map[string]any)
+	if len(files) != 2 {
+		t.Fatalf("files = %v, want one per top-level root", files)
+	}
+
+	if _, err := srv.workspaceRootRemoveTyped(ctx, workspaceRootParams{Path: mono}); err != nil {
+		t.Fatal(err)
+	}
+	if roots := srv.workspaceRoots.list(); len(roots) != 3 {
+		t.Fatalf("primary root should not be removable: %v", roots)
+	}
+}
+
+func TestWorkspaceRoots_DiagnosticsKeyedByRoot(t *testing.T) {
+	srv := &Server{diagCache: lspDiagnosticCache{}, lsp: lsp.NewManager(nil)}
+	ctx := context.Background()
+	mono := t.TempDir()
+	other := t.TempDir()
+	if _, err := srv.workspaceRoots.add(mono); err != nil {
+		t.Fatal(err)
+	}
+	diag := []lsp.Diagnostic{{Severity: lsp.SeverityError, Message: "boom"}}
+	otherFile := codePathToURI(filepath.Join(other, "b.go"))
+
+	srv.setDiagnostics(codePathToURI(filepath.Join(mono, "a.go")), diag)
+	srv.setDiagnostics(otherFile, diag)
+	if len(srv.diagCache[mono]) != 1 || len(srv.diagCache[""]) != 1 {
+		t.Fatalf("cache = %v", srv.diagCache)
+	}
+
+	if _, err := srv.workspaceRootAddTyped(ctx, workspaceRootParams{Path: other}); err != nil {
+		t.Fatal(err)
+	}
+	srv.setDiagnostics(otherFile, diag) // 根登记后的发布归入新根
+	if len(srv.diagCache[other]) != 1 || len(srv.diagCache[""]) != 0 {
+		t.Fatalf("cache after add = %v", srv.diagCache)
+	}
+	res, err := srv.lspDiagnosticsQueryTyped(ctx, lspDiagnosticsQueryParams{Root: other})
+	if err != nil {
+		t.Fatalf("diagnostics query: %v", err)
+	}
+	if got := res.(map[string]any); len(got) != 1 || got[otherFile] == nil {
+		t.Fatalf("diagnostics for root = %v", got)
+	}
+
+	if _, err := srv.workspaceRootRemoveTyped(ctx, workspaceRootParams{Path: other}); err != nil {
+		t.Fatal(err)
+	}
+	if len(srv.diagCache[other]) != 0 || len(srv.diagCache[""]) != 1 {
+		t.Fatalf("cache after remove = %v", srv.diagCache)
+	}
+	srv.setDiagnostics(otherFile, nil)
+	if diags := srv.diagCache.get(otherFile); diags != nil {
+		t.Fatalf("cleared diagnostics = %v", diags)
+	}
+}
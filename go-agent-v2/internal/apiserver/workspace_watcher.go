@@ -0,0 +1,493 @@
+// workspace_watcher.go — 线程工作目录文件监听, 推送 workspace/fileChanged 通知。
+//
+// 每个工作目录共享一个 fsnotify watcher (多个线程 cwd 相同时复用), 递归监听子目录:
+//   - 事件按路径去抖合并后推送 (path / change / diff hunk 预览)
+//   - 忽略 .git / node_modules 等目录, 目录数超过上限后不再追加监听
+//   - 预览基于上次观测到的文件内容, 首次变更 (无基线) 时仅对新建文件给出内容预览
+package apiserver
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+	"github.com/multi-agent/go-agent-v2/pkg/util"
+)
+
+const (
+	defaultFileWatchDebounce   = 200 * time.Millisecond
+	defaultFileWatchMaxDirs    = 4096
+	fileWatchMaxPreviewBytes   = 256 << 10
+	fileWatchMaxPreviewLines   = 20
+	fileWatchMaxCachedContents = 256
+
+	fileChangeCreated  = "created"
+	fileChangeModified = "modified"
+	fileChangeDeleted  = "deleted"
+	fileChangeRenamed  = "renamed"
+)
+
+// fileWatchIgnoredDirs 不监听的目录名 (体量大且与编辑无关)。
+var fileWatchIgnoredDirs = map[string]bool{
+	".git":         true,
+	".hg":          true,
+	".svn":         true,
+	"node_modules": true,
+	"vendor":       true,
+	"dist":         true,
+	"build":        true,
+	"target":       true,
+	".idea":        true,
+	".cache":       true,
+	"__pycache__":  true,
+	".venv":        true,
+}
+
+// fileChangedEvent workspace/fileChanged 通知载荷。
+type fileChangedEvent struct {
+	ThreadIDs []string `json:"threadIds"`
+	Root      string   `json:"root"`
+	Path      string   `json:"path"` // 相对 root, 以 / 分隔
+	AbsPath   string   `json:"absPath"`
+	Change    string   `json:"change"` // created / modified / deleted / renamed
+	Preview   string   `json:"preview,omitempty"`
+}
+
+// fileWatchHub 管理 root → watcher 与 thread → root 映射 (nil 接收者安全, 等价于禁用)。
+type fileWatchHub struct {
+	mu         sync.Mutex
+	roots      map[string]*rootFileWatcher
+	threadRoot map[string]string
+	emit       func(fileChangedEvent)
+	debounce   time.Duration
+	maxDirs    int
+}
+
+func newFileWatchHub(emit func(fileChangedEvent), debounce time.Duration, maxDirs int) *fileWatchHub {
+	if debounce <= 0 {
+		debounce = defaultFileWatchDebounce
+	}
+	if maxDirs <= 0 {
+		maxDirs = defaultFileWatchMaxDirs
+	}
+	return &fileWatchHub{
+		roots:      make(map[string]*rootFileWatcher),
+		threadRoot: make(map[string]string),
+		emit:       emit,
+		debounce:   debounce,
+		maxDirs:    maxDirs,
+	}
+}
+
+// attach 为线程监听 root (已监听其他目录时先解除)。
+func (h *fileWatchHub) attach(threadID, root string) error {
+	if h == nil || strings.TrimSpace(threadID) == "" {
+		return nil
+	}
+	root = normalizeAgentWorkDir(root)
+	if !fileWatchRootAllowed(root) {
+		logger.Debug("file watcher: root not watchable, skipped", logger.FieldThreadID, threadID, logger.FieldPath, root)
+		h.detach(threadID)
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.threadRoot[threadID] == root {
+		return nil
+	}
+	h.detachLocked(threadID)
+	watcher, ok := h.roots[root]
+	if !ok {
+		var err error
+		watcher, err = startRootFileWatcher(root, h.emit, h.debounce, h.maxDirs)
+		if err != nil {
+			return err
+		}
+		h.roots[root] = watcher
+	}
+	watcher.addThread(threadID)
+	h.threadRoot[threadID] = root
+	return nil
+}
+
+// detach 解除线程监听; root 无线程引用时关闭 watcher。
+func (h *fileWatchHub) detach(threadID string) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.detachLocked(threadID)
+}
+
+func (h *fileWatchHub) detachLocked(threadID string) {
+	root, ok := h.threadRoot[threadID]
+	if !ok {
+		return
+	}
+	delete(h.threadRoot, threadID)
+	watcher := h.roots[root]
+	if watcher == nil {
+		return
+	}
+	if watcher.removeThread(threadID) == 0 {
+		watcher.close()
+		delete(h.roots, root)
+	}
+}
+
+// closeAll 关闭全部 watcher (服务关闭时调用)。
+func (h *fileWatchHub) closeAll() {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for root, watcher := range h.roots {
+		watcher.close()
+		delete(h.roots, root)
+	}
+	clear(h.threadRoot)
+}
+
+// fileWatchRootAllowed 拒绝监听根目录 / 用户主目录等过大范围。
+func fileWatchRootAllowed(root string) bool {
+	if root == "" || root == string(filepath.Separator) || filepath.Dir(root) == root {
+		return false
+	}
+	if home, err := os.UserHomeDir(); err == nil && filepath.Clean(home) == root {
+		return false
+	}
+	info, err := os.Stat(root)
+	return err == nil && info.IsDir()
+}
+
+// rootFileWatcher 单个工作目录的递归监听。
+type rootFileWatcher struct {
+	root     string
+	watcher  *fsnotify.Watcher
+	emit     func(fileChangedEvent)
+	debounce time.Duration
+	maxDirs  int
+
+	mu       sync.Mutex
+	threads  map[string]struct{}
+	dirs     int
+	pending  map[string]string // abs path → change
+	timer    *time.Timer
+	contents map[string]string // abs path → 最近观测到的内容 (预览基线)
+	closed   bool
+	done     chan struct{}
+}
+
+func startRootFileWatcher(root string, emit func(fileChangedEvent), debounce time.Duration, maxDirs int) (*rootFileWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, apperrors.Wrap(err, "Server.fileWatchStart", "create watcher")
+	}
+	w := &rootFileWatcher{
+		root:     root,
+		watcher:  watcher,
+		emit:     emit,
+		debounce: debounce,
+		maxDirs:  maxDirs,
+		threads:  make(map[string]struct{}),
+		pending:  make(map[string]string),
+		contents: make(map[string]string),
+		done:     make(chan struct{}),
+	}
+	w.addTree(root)
+	util.SafeGo(w.loop)
+	logger.Info("file watcher: started", logger.FieldPath, root, "dirs", w.dirs)
+	return w, nil
+}
+
+func (w *rootFileWatcher) addThread(threadID string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.threads[threadID] = struct{}{}
+}
+
+func (w *rootFileWatcher) removeThread(threadID string) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.threads, threadID)
+	return len(w.threads)
+}
+
+func (w *rootFileWatcher) close() {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return
+	}
+	w.closed = true
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.mu.Unlock()
+	close(w.done)
+	_ = w.watcher.Close()
+	logger.Info("file watcher: stopped", logger.FieldPath, w.root)
+}
+
+// addTree 递归添加目录监听 (跳过忽略目录, 受 maxDirs 限制)。
+func (w *rootFileWatcher) addTree(dir string) {
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if path != w.root && fileWatchIgnoredDirs[d.Name()] {
+			return filepath.SkipDir
+		}
+		w.mu.Lock()
+		full := w.dirs >= w.maxDirs
+		if !full {
+			w.dirs++
+		}
+		w.mu.Unlock()
+		if full {
+			logger.Warn("file watcher: directory limit reached", logger.FieldPath, w.root, "max_dirs", w.maxDirs)
+			return filepath.SkipAll
+		}
+		if addErr := w.watcher.Add(path); addErr != nil {
+			logger.Debug("file watcher: add dir failed", logger.FieldPath, path, logger.FieldError, addErr)
+		}
+		return nil
+	})
+}
+
+func (w *rootFileWatcher) loop() {
+	for {
+		select {
+		case <-w.done:
+			return
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			w.handleEvent(event)
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			logger.Warn("file watcher: error", logger.FieldPath, w.root, logger.FieldError, err)
+		}
+	}
+}
+
+func (w *rootFileWatcher) handleEvent(event fsnotify.Event) {
+	if w.ignored(event.Name) {
+		return
+	}
+	var change string
+	switch {
+	case event.Has(fsnotify.Create):
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			w.addTree(event.Name)
+			return
+		}
+		change = fileChangeCreated
+	case event.Has(fsnotify.Write):
+		change = fileChangeModified
+	case event.Has(fsnotify.Remove):
+		change = fileChangeDeleted
+	case event.Has(fsnotify.Rename):
+		change = fileChangeRenamed
+	default:
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+	w.pending[event.Name] = mergeFileChange(w.pending[event.Name], change)
+	if w.timer == nil {
+		w.timer = time.AfterFunc(w.debounce, w.flush)
+	} else {
+		w.timer.Reset(w.debounce)
+	}
+}
+
+// mergeFileChange 合并去抖窗口内同一路径的多次变更。
+func mergeFileChange(prev, next string) string {
+	if prev == fileChangeCreated && next == fileChangeModified {
+		return fileChangeCreated
+	}
+	return next
+}
+
+func (w *rootFileWatcher) ignored(path string) bool {
+	rel, err := filepath.Rel(w.root, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return true
+	}
+	for _, part := range strings.Split(filepath.ToSlash(rel), "/") {
+		if fileWatchIgnoredDirs[part] {
+			return true
+		}
+	}
+	base := filepath.Base(path)
+	return strings.HasSuffix(base, "~") || strings.HasSuffix(base, ".swp") || strings.HasPrefix(base, ".#")
+}
+
+func (w *rootFileWatcher) flush() {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return
+	}
+	pending := w.pending
+	w.pending = make(map[string]string)
+	threadIDs := make([]string, 0, len(w.threads))
+	for id := range w.threads {
+		threadIDs = append(threadIDs, id)
+	}
+	w.mu.Unlock()
+	sort.Strings(threadIDs)
+
+	paths := make([]string, 0, len(pending))
+	for path := range pending {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		change := pending[path]
+		rel, _ := filepath.Rel(w.root, path)
+		w.emit(fileChangedEvent{
+			ThreadIDs: threadIDs,
+			Root:      w.root,
+			Path:      filepath.ToSlash(rel),
+			AbsPath:   path,
+			Change:    change,
+			Preview:   w.preview(path, change),
+		})
+	}
+}
+
+// preview 生成 diff hunk 预览并更新内容基线。
+func (w *rootFileWatcher) preview(path, change string) string {
+	w.mu.Lock()
+	previous, hasPrevious := w.contents[path]
+	w.mu.Unlock()
+
+	if change == fileChangeDeleted || change == fileChangeRenamed {
+		w.mu.Lock()
+		delete(w.contents, path)
+		w.mu.Unlock()
+		return ""
+	}
+	current, ok := readPreviewableFile(path)
+	if !ok {
+		return ""
+	}
+	w.mu.Lock()
+	if len(w.contents) >= fileWatchMaxCachedContents {
+		for key := range w.contents {
+			delete(w.contents, key)
+			break
+		}
+	}
+	w.contents[path] = current
+	w.mu.Unlock()
+
+	if !hasPrevious {
+		if change != fileChangeCreated {
+			return ""
+		}
+		previous = ""
+	}
+	return diffHunkPreview(previous, current, fileWatchMaxPreviewLines)
+}
+
+// readPreviewableFile 读取小型文本文件 (二进制 / 过大文件返回 false)。
+func readPreviewableFile(path string) (string, bool) {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() || info.Size() > fileWatchMaxPreviewBytes {
+		return "", false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	probe := data
+	if len(probe) > 8<<10 {
+		probe = probe[:8<<10]
+	}
+	if bytes.IndexByte(probe, 0) >= 0 {
+		return "", false
+	}
+	return string(data), true
+}
+
+// diffHunkPreview 以公共前后缀裁剪出单个变更 hunk (统一 diff 格式, 最多 maxLines 行)。
+func diffHunkPreview(previous, current string, maxLines int) string {
+	if previous == current {
+		return ""
+	}
+	oldLines := splitPreviewLines(previous)
+	newLines := splitPreviewLines(current)
+	prefix := 0
+	for prefix < len(oldLines) && prefix < len(newLines) && oldLines[prefix] == newLines[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(oldLines)-prefix && suffix < len(newLines)-prefix &&
+		oldLines[len(oldLines)-1-suffix] == newLines[len(newLines)-1-suffix] {
+		suffix++
+	}
+	removed := oldLines[prefix : len(oldLines)-suffix]
+	added := newLines[prefix : len(newLines)-suffix]
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", prefix+1, len(removed), prefix+1, len(added))
+	written := 0
+	for _, group := range []struct {
+		mark  string
+		lines []string
+	}{{"-", removed}, {"+", added}} {
+		for _, line := range group.lines {
+			if written >= maxLines {
+				fmt.Fprintf(&sb, "... (%d more lines)\n", len(removed)+len(added)-written)
+				return sb.String()
+			}
+			sb.WriteString(group.mark + line + "\n")
+			written++
+		}
+	}
+	return sb.String()
+}
+
+func splitPreviewLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// watchThreadWorkspace 为线程工作目录开启监听 (失败仅记录日志)。
+func (s *Server) watchThreadWorkspace(threadID, cwd string) {
+	if s.fileWatch == nil {
+		return
+	}
+	if err := s.fileWatch.attach(threadID, cwd); err != nil {
+		logger.Warn("file watcher: attach failed", logger.FieldThreadID, threadID, logger.FieldPath, cwd, logger.FieldError, err)
+	}
+}
+
+func (s *Server) notifyFileChanged(event fileChangedEvent) {
+	s.Notify("workspace/fileChanged", event)
+}
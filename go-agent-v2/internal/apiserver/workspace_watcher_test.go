@@ -0,0 +1,77 @@
+package apiserver
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiffHunkPreviewTrimsCommonLines(t *testing.T) {
+	got := diffHunkPreview("a\nb\nc\nd\n", "a\nB\nc\nd\n", 20)
+	want := "@@ -2,1 +2,1 @@\n-b\n+B\n"
+	if got != want {
+		t.Fatalf("preview = %q, want %q", got, want)
+	}
+	if diffHunkPreview("same\n", "same\n", 20) != "" {
+		t.Fatal("identical content should produce empty preview")
+	}
+	truncated := diffHunkPreview("", "1\n2\n3\n4\n", 2)
+	if !strings.Contains(truncated, "+1\n+2\n... (2 more lines)") {
+		t.Fatalf("truncated preview = %q", truncated)
+	}
+}
+
+func TestFileWatchHubEmitsFileChanges(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "node_modules"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	events := make(chan fileChangedEvent, 16)
+	hub := newFileWatchHub(func(ev fileChangedEvent) { events <- ev }, 20*time.Millisecond, 0)
+	defer hub.closeAll()
+	if err := hub.attach("thread-1", root); err != nil {
+		t.Fatalf("attach: %v", err)
+	}
+	if err := hub.attach("thread-2", root); err != nil {
+		t.Fatalf("attach second thread: %v", err)
+	}
+
+	next := func() fileChangedEvent {
+		t.Helper()
+		select {
+		case ev := <-events:
+			return ev
+		case <-time.After(3 * time.Second):
+			t.Fatal("timed out waiting for workspace/fileChanged")
+		}
+		return fileChangedEvent{}
+	}
+
+	path := filepath.Join(root, "main.go")
+	if err := os.WriteFile(filepath.Join(root, "node_modules", "ignored.js"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ev := next()
+	if ev.Path != "main.go" || ev.Change != fileChangeCreated || len(ev.ThreadIDs) != 2 {
+		t.Fatalf("create event = %+v", ev)
+	}
+
+	if err := os.WriteFile(path, []byte("package app\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ev = next()
+	if ev.Change != fileChangeModified || !strings.Contains(ev.Preview, "-package main\n+package app") {
+		t.Fatalf("modify event = %+v", ev)
+	}
+
+	hub.detach("thread-1")
+	hub.detach("thread-2")
+	if len(hub.roots) != 0 {
+		t.Fatal("root watcher should close after last thread detaches")
+	}
+}
@@ -0,0 +1,216 @@
+// ws_coalesce.go — 流式 delta 通知按连接合并 (stream/coalesce) 与 permessage-deflate。
+//
+// 开启合并的连接上, 同一线程同一条目的连续 delta 通知 (coalescedDeltaMethods) 先缓存,
+// 每 intervalMs 或累计 maxBytes 时合并为一条通知发出 (delta 字段拼接, 其余字段取首条);
+// 其他通知到达前先冲刷缓存, 因此同一连接上的通知顺序不变 (ui/state/changed 仅是刷新提示, 不触发冲刷);
+// 连接关闭后的冲刷由 enqueue 丢弃。
+// 默认值来自 WS_DELTA_COALESCE_MS / WS_DELTA_COALESCE_BYTES, 客户端可经 stream/coalesce 按连接调整
+// (intervalMs = 0 关闭)。permessage-deflate 由 WS_COMPRESSION 开启, 仅对请求该扩展的客户端生效。
+package apiserver
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+const (
+	streamCoalesceMethod         = "stream/coalesce"
+	defaultDeltaCoalesceMaxBytes = 4096
+	maxDeltaCoalesceInterval     = 1000 // ms
+	maxDeltaCoalesceBytes        = 1 << 20
+)
+
+// coalescedDeltaMethods 可合并的流式 delta 通知。
+var coalescedDeltaMethods = map[string]bool{
+	"item/agentMessage/delta":           true,
+	"item/reasoning/textDelta":          true,
+	"item/reasoning/summaryTextDelta":   true,
+	"item/plan/delta":                   true,
+	"item/commandExecution/outputDelta": true,
+}
+
+// streamCoalesceParams stream/coalesce 参数与响应。
+type streamCoalesceParams struct {
+	IntervalMs int `json:"intervalMs"`
+	MaxBytes   int `json:"maxBytes,omitempty"`
+}
+
+func normalizeStreamCoalesce(p streamCoalesceParams) (streamCoalesceParams, error) {
+	const op = "Server.streamCoalesce"
+	if p.IntervalMs < 0 || p.IntervalMs > maxDeltaCoalesceInterval {
+		return p, apperrors.NewCodef(op, errcode.InvalidInput, "intervalMs must be between 0 and %d", maxDeltaCoalesceInterval)
+	}
+	if p.MaxBytes < 0 || p.MaxBytes > maxDeltaCoalesceBytes {
+		return p, apperrors.NewCodef(op, errcode.InvalidInput, "maxBytes must be between 0 and %d", maxDeltaCoalesceBytes)
+	}
+	if p.MaxBytes == 0 {
+		p.MaxBytes = defaultDeltaCoalesceMaxBytes
+	}
+	return p, nil
+}
+
+// pendingDelta 缓存中的合并通知。
+type pendingDelta struct {
+	method  string
+	key     string
+	payload map[string]any
+	delta   strings.Builder
+}
+
+// deltaCoalescer 单个连接的 delta 合并器 (零值 = 不合并, 直接发送)。
+type deltaCoalescer struct {
+	mu       sync.Mutex
+	interval time.Duration
+	maxBytes int
+	pending  *pendingDelta
+	timer    *time.Timer
+	send     func(data []byte) bool
+}
+
+// configure 调整合并参数; interval 为 0 时先冲刷缓存再关闭合并。
+func (c *deltaCoalescer) configure(p streamCoalesceParams) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.interval = time.Duration(p.IntervalMs) * time.Millisecond
+	c.maxBytes = p.MaxBytes
+	if c.interval == 0 {
+		c.flushLocked()
+	}
+}
+
+// active 是否开启合并 (未绑定发送函数的连接不合并)。
+func (c *deltaCoalescer) active() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.interval > 0 && c.send != nil
+}
+
+func (c *deltaCoalescer) settings() streamCoalesceParams {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return streamCoalesceParams{IntervalMs: int(c.interval / time.Millisecond), MaxBytes: c.maxBytes}
+}
+
+// deltaKey 合并键 (线程 + 条目), 无字符串 delta 字段的通知不合并。
+func deltaKey(method string, params any) (map[string]any, string, string, bool) {
+	if !coalescedDeltaMethods[method] {
+		return nil, "", "", false
+	}
+	payload, ok := params.(map[string]any)
+	if !ok {
+		return nil, "", "", false
+	}
+	delta, ok := payload["delta"].(string)
+	if !ok {
+		return nil, "", "", false
+	}
+	threadID, _ := payload["threadId"].(string)
+	itemID := extractFirstString(payload, "itemId", "item_id", "callId", "call_id")
+	return payload, threadID + "\x00" + itemID, delta, true
+}
+
+// push 发送通知 (data 为已编码的原始通知); 返回 false 表示连接已过载。
+func (c *deltaCoalescer) push(method string, params any, data []byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.interval == 0 {
+		return c.send(data)
+	}
+	if method == "ui/state/changed" {
+		return c.send(data)
+	}
+	payload, key, delta, ok := deltaKey(method, params)
+	if !ok {
+		return c.flushLocked() && c.send(data)
+	}
+	if c.pending != nil && (c.pending.method != method || c.pending.key != key) {
+		if !c.flushLocked() {
+			return false
+		}
+	}
+	if c.pending == nil {
+		c.pending = &pendingDelta{method: method, key: key, payload: payload}
+		c.timer = time.AfterFunc(c.interval, c.flushTimer)
+	}
+	c.pending.delta.WriteString(delta)
+	if c.pending.delta.Len() >= c.maxBytes {
+		return c.flushLocked()
+	}
+	return true
+}
+
+func (c *deltaCoalescer) flushTimer() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.flushLocked()
+}
+
+// flushLocked 发出缓存的合并通知。
+func (c *deltaCoalescer) flushLocked() bool {
+	pending := c.pending
+	if pending == nil {
+		return true
+	}
+	c.pending = nil
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	payload := make(map[string]any, len(pending.payload))
+	for k, v := range pending.payload {
+		payload[k] = v
+	}
+	payload["delta"] = pending.delta.String()
+	data, err := json.Marshal(newNotification(pending.method, payload))
+	if err != nil {
+		logger.Error("app-server: marshal coalesced delta failed", logger.FieldMethod, pending.method, logger.FieldError, err)
+		return true
+	}
+	return c.send(data)
+}
+
+// defaultStreamCoalesce 新连接的合并默认值 (WS_DELTA_COALESCE_MS / WS_DELTA_COALESCE_BYTES)。
+func (s *Server) defaultStreamCoalesce() streamCoalesceParams {
+	if s.cfg == nil {
+		return streamCoalesceParams{MaxBytes: defaultDeltaCoalesceMaxBytes}
+	}
+	p, err := normalizeStreamCoalesce(streamCoalesceParams{IntervalMs: s.cfg.WSDeltaCoalesceMs, MaxBytes: s.cfg.WSDeltaCoalesceBytes})
+	if err != nil {
+		logger.Warn("app-server: invalid delta coalesce config, disabled", logger.FieldError, err)
+		return streamCoalesceParams{MaxBytes: defaultDeltaCoalesceMaxBytes}
+	}
+	return p
+}
+
+// handleStreamCoalesceMessage 处理连接上的 stream/coalesce (其他方法返回 false)。
+func handleStreamCoalesceMessage(c *deltaCoalescer, env rpcEnvelope) (*Response, bool) {
+	if env.Method != streamCoalesceMethod {
+		return nil, false
+	}
+	id := rawIDtoAny(env.ID)
+	var p streamCoalesceParams
+	var err error
+	if len(env.Params) > 0 {
+		if err = json.Unmarshal(env.Params, &p); err != nil {
+			err = apperrors.WrapCode(err, "Server.streamCoalesce", errcode.InvalidParams, "unmarshal params")
+		}
+	}
+	if err == nil {
+		if p, err = normalizeStreamCoalesce(p); err == nil {
+			c.configure(p)
+		}
+	}
+	if id == nil {
+		return nil, true
+	}
+	if err != nil {
+		return newHandlerError(id, err), true
+	}
+	return newResult(id, c.settings()), true
+}
@@ -0,0 +1,98 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestDeltaCoalescer_MergesAndPreservesOrder(t *testing.T) {
+	var mu sync.Mutex
+	var sent []map[string]any
+	c := &deltaCoalescer{send: func(data []byte) bool {
+		var msg map[string]any
+		_ = json.Unmarshal(data, &msg)
+		mu.Lock()
+		sent = append(sent, msg)
+		mu.Unlock()
+		return true
+	}}
+	c.configure(streamCoalesceParams{IntervalMs: 1000, MaxBytes: 8})
+	push := func(method string, params map[string]any) {
+		data, _ := json.Marshal(newNotification(method, params))
+		c.push(method, params, data)
+	}
+	delta := func(thread, text string) map[string]any {
+		return map[string]any{"threadId": thread, "itemId": "i1", "delta": text}
+	}
+
+	push("item/agentMessage/delta", delta("t1", "ab"))
+	push("item/agentMessage/delta", delta("t1", "cd"))
+	push("item/agentMessage/delta", delta("t2", "x")) // 其他线程: 冲刷 t1
+	push("turn/completed", map[string]any{"threadId": "t2"})
+	push("item/agentMessage/delta", delta("t1", "12345"))
+	push("item/agentMessage/delta", delta("t1", "6789")) // 达到 maxBytes: 立即发出
+
+	mu.Lock()
+	defer mu.Unlock()
+	var got []string
+	for _, msg := range sent {
+		params, _ := msg["params"].(map[string]any)
+		d, _ := params["delta"].(string)
+		got = append(got, msg["method"].(string)+":"+d)
+	}
+	want := "item/agentMessage/delta:abcd,item/agentMessage/delta:x,turn/completed:,item/agentMessage/delta:123456789"
+	if strings.Join(got, ",") != want {
+		t.Fatalf("sent = %v", got)
+	}
+}
+
+func TestStreamCoalesce_WebSocketWithCompression(t *testing.T) {
+	srv := New(Deps{})
+	ts := httptest.NewServer(http.HandlerFunc(srv.handleUpgrade))
+	t.Cleanup(ts.Close)
+	dialer := websocket.Dialer{EnableCompression: true}
+	ws, resp, err := dialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = ws.Close() })
+	if ext := resp.Header.Get("Sec-WebSocket-Extensions"); !strings.Contains(ext, "permessage-deflate") {
+		t.Fatalf("extensions = %q", ext)
+	}
+	read := func() map[string]any {
+		t.Helper()
+		_ = ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+		var msg map[string]any
+		if err := ws.ReadJSON(&msg); err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		return msg
+	}
+
+	if err := ws.WriteJSON(map[string]any{"jsonrpc": "2.0", "id": 1, "method": "stream/coalesce", "params": map[string]any{"intervalMs": 30}}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if got, _ := json.Marshal(read()["result"]); string(got) != `{"intervalMs":30,"maxBytes":4096}` {
+		t.Fatalf("stream/coalesce = %s", got)
+	}
+	for _, part := range []string{"hel", "lo ", "world"} {
+		srv.Notify("item/agentMessage/delta", map[string]any{"threadId": "t1", "delta": part})
+	}
+	for {
+		msg := read()
+		if msg["method"] == "ui/state/changed" {
+			continue
+		}
+		if params, _ := msg["params"].(map[string]any); msg["method"] != "item/agentMessage/delta" || params["delta"] != "hello world" {
+			t.Fatalf("coalesced = %#v", msg)
+		}
+		break
+	}
+}
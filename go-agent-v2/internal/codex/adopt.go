@@ -0,0 +1,91 @@
+// adopt.go — 接管上次 supervisor 进程遗留的 codex app-server (不重新 spawn)。
+//
+// 重启后子进程仍在监听原端口: 重新建立 WebSocket → initialize → thread/resume 订阅原线程,
+// turn 继续在原进程中执行。接管的进程不是本进程的子进程, Kill 按 PID 发信号, Running 以信号 0 探活。
+package codex
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"syscall"
+
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+// PIDProvider 可报告 codex 子进程 PID 的客户端 (可选能力, 用于记录可接管的进程)。
+type PIDProvider interface {
+	PID() int
+}
+
+// PID 实现 PIDProvider (未启动返回 0)。
+func (c *AppServerClient) PID() int {
+	if c.Cmd != nil && c.Cmd.Process != nil {
+		return c.Cmd.Process.Pid
+	}
+	return c.adoptedPID
+}
+
+// ProcessAlive 报告 pid 对应进程是否存活且可由本进程发送信号。
+func ProcessAlive(pid int) bool {
+	return pid > 0 && syscall.Kill(pid, 0) == nil
+}
+
+// AdoptAppServer 连接已在 port 上运行的 app-server 进程并恢复 threadID 的订阅。
+func AdoptAppServer(ctx context.Context, port int, agentID string, pid int, threadID string) (*AppServerClient, error) {
+	const op = "codex.AdoptAppServer"
+	threadID = strings.TrimSpace(threadID)
+	if threadID == "" {
+		return nil, apperrors.New(op, "thread id is required")
+	}
+	if !ProcessAlive(pid) {
+		return nil, apperrors.Newf(op, "process %d is not running", pid)
+	}
+	c := NewAppServerClient(port, agentID)
+	c.adoptedPID = pid
+	if err := ctx.Err(); err != nil {
+		return nil, apperrors.Wrap(err, op, "adopt cancelled")
+	}
+	if err := c.connectWS(); err != nil {
+		return nil, apperrors.Wrapf(err, op, "connect app-server on port %d", port)
+	}
+	if err := c.Initialize(); err != nil {
+		c.detach()
+		return nil, apperrors.Wrap(err, op, "initialize")
+	}
+	if err := c.ResumeThread(ResumeThreadRequest{ThreadID: threadID}); err != nil {
+		c.detach()
+		return nil, err
+	}
+	logger.Info("codex: adopted app-server",
+		logger.FieldAgentID, agentID,
+		logger.FieldPort, port,
+		logger.FieldPID, pid,
+		logger.FieldThreadID, c.ThreadID,
+	)
+	return c, nil
+}
+
+// detach 断开连接但保留进程 (接管失败时由调用方决定是否清理)。
+func (c *AppServerClient) detach() {
+	c.stopped.Store(true)
+	c.cancel()
+	c.wsMu.Lock()
+	if c.ws != nil {
+		_ = c.ws.Close()
+	}
+	c.wsMu.Unlock()
+}
+
+// killAdopted 终止接管的进程 (Spawn 时 Setpgid, pgid == pid)。
+func (c *AppServerClient) killAdopted() error {
+	pid := c.adoptedPID
+	if err := syscall.Kill(-pid, syscall.SIGKILL); err == nil {
+		return nil
+	}
+	if err := syscall.Kill(pid, syscall.SIGKILL); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return err
+	}
+	return nil
+}
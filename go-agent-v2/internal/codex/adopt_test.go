@@ -0,0 +1,88 @@
+package codex
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// fakeAppServer 应答 initialize / thread/resume 的最小 app-server。
+func fakeAppServer(t *testing.T) (port int, methods func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var seen []string
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			var req struct {
+				ID     *int64          `json:"id"`
+				Method string          `json:"method"`
+				Params json.RawMessage `json:"params"`
+			}
+			if err := conn.ReadJSON(&req); err != nil {
+				return
+			}
+			mu.Lock()
+			seen = append(seen, req.Method)
+			mu.Unlock()
+			if req.ID == nil {
+				continue
+			}
+			result := map[string]any{}
+			if req.Method == "thread/resume" {
+				var p struct {
+					ThreadID string `json:"threadId"`
+				}
+				_ = json.Unmarshal(req.Params, &p)
+				result["thread"] = map[string]any{"id": p.ThreadID}
+			}
+			_ = conn.WriteJSON(map[string]any{"jsonrpc": "2.0", "id": *req.ID, "result": result})
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv.Listener.Addr().(*net.TCPAddr).Port, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), seen...)
+	}
+}
+
+func TestAdoptAppServerReconnectsAndResumesThread(t *testing.T) {
+	port, methods := fakeAppServer(t)
+	pid := os.Getpid() // 存活进程即可; 测试不调用 Kill
+
+	c, err := AdoptAppServer(context.Background(), port, "agent-1", pid, "thread-abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.detach()
+	if c.ThreadID != "thread-abc" || c.PID() != pid || !c.Running() {
+		t.Fatalf("adopted client thread=%q pid=%d running=%v", c.ThreadID, c.PID(), c.Running())
+	}
+	got := methods()
+	if len(got) < 2 || got[0] != "initialize" || got[len(got)-1] != "thread/resume" {
+		t.Fatalf("methods = %v", got)
+	}
+}
+
+func TestAdoptAppServerRejectsDeadProcessOrMissingThread(t *testing.T) {
+	port, _ := fakeAppServer(t)
+	if _, err := AdoptAppServer(context.Background(), port, "agent-1", os.Getpid(), ""); err == nil {
+		t.Fatal("expected error without thread id")
+	}
+	if _, err := AdoptAppServer(context.Background(), port, "agent-1", 0, "thread-abc"); err == nil {
+		t.Fatal("expected error for dead process")
+	}
+}
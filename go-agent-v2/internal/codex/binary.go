@@ -0,0 +1,258 @@
+// binary.go — codex 可执行文件发现、版本约束校验与缺失时下载。
+//
+// 查找顺序: 配置的路径 (CODEX_BIN) → PATH 中的 codex → 下载目录中已下载的 codex →
+// 从 CODEX_DOWNLOAD_URL 下载 (裸二进制或含 codex 的 .tar.gz)。解析结果经 SetBinary 供 Spawn 使用。
+package codex
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultBinaryName     = "codex"
+	binaryVersionTimeout  = 10 * time.Second
+	binaryDownloadTimeout = 5 * time.Minute
+	maxBinaryDownloadSize = 512 << 20
+)
+
+// 可执行文件来源。
+const (
+	BinarySourceConfigured = "configured"
+	BinarySourcePath       = "path"
+	BinarySourceDownloaded = "downloaded"
+)
+
+var binaryPath atomic.Value // string
+
+// SetBinary 设置 Spawn 使用的 codex 可执行文件 (空 = PATH 中的 codex)。
+func SetBinary(path string) { binaryPath.Store(strings.TrimSpace(path)) }
+
+// Binary 返回 Spawn 使用的 codex 可执行文件。
+func Binary() string {
+	if path, _ := binaryPath.Load().(string); path != "" {
+		return path
+	}
+	return defaultBinaryName
+}
+
+// BinaryOptions codex 可执行文件发现与版本约束配置。
+type BinaryOptions struct {
+	Path        string // 显式路径, 空 = 自动发现
+	MinVersion  string // 含下限, 空 = 不限
+	MaxVersion  string // 含上限, 空 = 不限
+	DownloadURL string // 找不到时下载, 支持 {os} {arch} 占位; 空 = 不下载
+	DownloadDir string // 下载目录
+}
+
+// BinaryStatus 发现与校验结果。
+type BinaryStatus struct {
+	Path       string    `json:"path,omitempty"`
+	Source     string    `json:"source,omitempty"`
+	Version    string    `json:"version,omitempty"`
+	MinVersion string    `json:"minVersion,omitempty"`
+	MaxVersion string    `json:"maxVersion,omitempty"`
+	Compatible bool      `json:"compatible"`
+	Problem    string    `json:"problem,omitempty"` // 找不到 / 版本不满足 / 无法识别版本
+	CheckedAt  time.Time `json:"checkedAt"`
+}
+
+// ResolveBinary 发现 codex 可执行文件并校验版本; 返回 error 仅表示找不到且下载失败。
+func ResolveBinary(ctx context.Context, opts BinaryOptions) (BinaryStatus, error) {
+	status := BinaryStatus{MinVersion: opts.MinVersion, MaxVersion: opts.MaxVersion, CheckedAt: time.Now()}
+	path, source, err := locateBinary(ctx, opts)
+	if err != nil {
+		status.Problem = err.Error()
+		return status, err
+	}
+	status.Path, status.Source = path, source
+
+	out, err := binaryVersionOutput(ctx, path)
+	if err != nil {
+		status.Problem = fmt.Sprintf("run %s --version: %v", path, err)
+		return status, nil
+	}
+	status.Version = ParseCodexVersion(out)
+	if status.Version == "" {
+		status.Problem = fmt.Sprintf("unrecognized version output %q", strings.TrimSpace(out))
+		return status, nil
+	}
+	status.Problem = checkVersionConstraint(status.Version, opts.MinVersion, opts.MaxVersion)
+	status.Compatible = status.Problem == ""
+	return status, nil
+}
+
+func locateBinary(ctx context.Context, opts BinaryOptions) (string, string, error) {
+	if configured := strings.TrimSpace(opts.Path); configured != "" {
+		path, err := exec.LookPath(configured)
+		if err != nil {
+			return "", "", fmt.Errorf("configured codex binary %s: %w", configured, err)
+		}
+		return path, BinarySourceConfigured, nil
+	}
+	if path, err := exec.LookPath(defaultBinaryName); err == nil {
+		return path, BinarySourcePath, nil
+	}
+	if opts.DownloadDir == "" {
+		return "", "", errors.New("codex not found in PATH")
+	}
+	target := filepath.Join(opts.DownloadDir, binaryFileName())
+	if info, err := os.Stat(target); err == nil && !info.IsDir() {
+		return target, BinarySourceDownloaded, nil
+	}
+	if strings.TrimSpace(opts.DownloadURL) == "" {
+		return "", "", errors.New("codex not found in PATH and no download URL configured")
+	}
+	if err := downloadBinary(ctx, expandDownloadURL(opts.DownloadURL), target); err != nil {
+		return "", "", fmt.Errorf("codex not found in PATH; download failed: %w", err)
+	}
+	return target, BinarySourceDownloaded, nil
+}
+
+func binaryFileName() string {
+	if runtime.GOOS == "windows" {
+		return defaultBinaryName + ".exe"
+	}
+	return defaultBinaryName
+}
+
+// expandDownloadURL 替换 {os} / {arch} 占位 (Go 平台名, 如 linux / arm64)。
+func expandDownloadURL(raw string) string {
+	return strings.NewReplacer("{os}", runtime.GOOS, "{arch}", runtime.GOARCH).Replace(strings.TrimSpace(raw))
+}
+
+func binaryVersionOutput(ctx context.Context, path string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, binaryVersionTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, "--version").CombinedOutput()
+	return string(out), err
+}
+
+// downloadBinary 下载到临时文件后原子替换; .tar.gz / .tgz 取其中名为 codex* 的第一个文件。
+func downloadBinary(ctx context.Context, url, target string) error {
+	ctx, cancel := context.WithTimeout(ctx, binaryDownloadTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	body := io.Reader(io.LimitReader(resp.Body, maxBinaryDownloadSize))
+	if strings.HasSuffix(url, ".tar.gz") || strings.HasSuffix(url, ".tgz") {
+		if body, err = extractBinaryFromTarGz(body); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".codex-download-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, body); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o755); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), target)
+}
+
+func extractBinaryFromTarGz(r io.Reader) (io.Reader, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, errors.New("archive contains no codex binary")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag == tar.TypeReg && strings.HasPrefix(filepath.Base(hdr.Name), defaultBinaryName) {
+			return tr, nil
+		}
+	}
+}
+
+// checkVersionConstraint 返回版本不满足约束的说明, 满足时为空。
+func checkVersionConstraint(version, minVersion, maxVersion string) string {
+	if minVersion = strings.TrimSpace(minVersion); minVersion != "" && CompareVersions(version, minVersion) < 0 {
+		return fmt.Sprintf("codex %s is older than the minimum supported version %s", version, minVersion)
+	}
+	if maxVersion = strings.TrimSpace(maxVersion); maxVersion != "" && CompareVersions(version, maxVersion) > 0 {
+		return fmt.Sprintf("codex %s is newer than the maximum supported version %s", version, maxVersion)
+	}
+	return ""
+}
+
+// CompareVersions 比较语义化版本 (major.minor.patch[-prerelease]); 预发布版本低于同号正式版本。
+func CompareVersions(a, b string) int {
+	coreA, preA := splitVersion(a)
+	coreB, preB := splitVersion(b)
+	for i := 0; i < 3; i++ {
+		if coreA[i] != coreB[i] {
+			if coreA[i] < coreB[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case preA == preB:
+		return 0
+	case preA == "":
+		return 1
+	case preB == "":
+		return -1
+	case preA < preB:
+		return -1
+	}
+	return 1
+}
+
+func splitVersion(v string) ([3]int, string) {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if i := strings.IndexByte(v, '+'); i >= 0 {
+		v = v[:i]
+	}
+	pre := ""
+	if i := strings.IndexByte(v, '-'); i >= 0 {
+		v, pre = v[:i], v[i+1:]
+	}
+	var core [3]int
+	for i, part := range strings.SplitN(v, ".", 3) {
+		core[i], _ = strconv.Atoi(part)
+	}
+	return core, pre
+}
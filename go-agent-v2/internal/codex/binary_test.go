@@ -0,0 +1,125 @@
+package codex
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func writeFakeCodex(t *testing.T, dir, version string) string {
+	t.Helper()
+	path := filepath.Join(dir, "codex")
+	if err := os.WriteFile(path, []byte(fakeCodexScript(version)), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func fakeCodexScript(version string) string {
+	return "#!/bin/sh\necho 'codex-cli " + version + "'\n"
+}
+
+func TestCompareVersions(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"0.46.0", "0.46.0", 0},
+		{"0.46.0", "0.47.0", -1},
+		{"1.0.0", "0.99.9", 1},
+		{"v0.46.1", "0.46.0", 1},
+		{"0.47.0-alpha.1", "0.47.0", -1},
+		{"0.47.0-alpha.2", "0.47.0-alpha.1", 1},
+		{"0.47.0+build.5", "0.47.0", 0},
+	}
+	for _, tc := range cases {
+		if got := CompareVersions(tc.a, tc.b); got != tc.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestResolveBinaryChecksVersionConstraint(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script fake")
+	}
+	path := writeFakeCodex(t, t.TempDir(), "0.40.2")
+
+	status, err := ResolveBinary(context.Background(), BinaryOptions{Path: path, MinVersion: "0.40.0", MaxVersion: "0.45.0"})
+	if err != nil || !status.Compatible || status.Version != "0.40.2" || status.Source != BinarySourceConfigured {
+		t.Fatalf("status = %+v, err = %v", status, err)
+	}
+	status, err = ResolveBinary(context.Background(), BinaryOptions{Path: path, MinVersion: "0.41.0"})
+	if err != nil || status.Compatible || !strings.Contains(status.Problem, "older than the minimum") {
+		t.Fatalf("below min: status = %+v, err = %v", status, err)
+	}
+	status, _ = ResolveBinary(context.Background(), BinaryOptions{Path: path, MaxVersion: "0.40.1"})
+	if status.Compatible || !strings.Contains(status.Problem, "newer than the maximum") {
+		t.Fatalf("above max: status = %+v", status)
+	}
+	if _, err := ResolveBinary(context.Background(), BinaryOptions{Path: filepath.Join(t.TempDir(), "missing")}); err == nil {
+		t.Fatal("missing configured binary should fail")
+	}
+}
+
+func TestResolveBinaryDownloadsWhenMissing(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script fake")
+	}
+	t.Setenv("PATH", t.TempDir())
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	script := fakeCodexScript("0.50.0")
+	_ = tw.WriteHeader(&tar.Header{Name: "README.md", Mode: 0o644, Size: 2, Typeflag: tar.TypeReg})
+	_, _ = tw.Write([]byte("hi"))
+	_ = tw.WriteHeader(&tar.Header{Name: "dist/codex-" + runtime.GOARCH, Mode: 0o755, Size: int64(len(script)), Typeflag: tar.TypeReg})
+	_, _ = tw.Write([]byte(script))
+	_ = tw.Close()
+	_ = gz.Close()
+
+	var requested string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.Path
+		_, _ = w.Write(archive.Bytes())
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	opts := BinaryOptions{DownloadURL: srv.URL + "/codex-{os}-{arch}.tar.gz", DownloadDir: dir}
+	status, err := ResolveBinary(context.Background(), opts)
+	if err != nil || !status.Compatible || status.Source != BinarySourceDownloaded || status.Version != "0.50.0" {
+		t.Fatalf("status = %+v, err = %v", status, err)
+	}
+	if want := "/codex-" + runtime.GOOS + "-" + runtime.GOARCH + ".tar.gz"; requested != want {
+		t.Fatalf("requested %q, want %q", requested, want)
+	}
+	if status.Path != filepath.Join(dir, "codex") {
+		t.Fatalf("path = %q", status.Path)
+	}
+
+	// 已下载的副本直接复用, 不再请求。
+	requested = ""
+	if status, err = ResolveBinary(context.Background(), opts); err != nil || requested != "" || !status.Compatible {
+		t.Fatalf("reuse: status = %+v, err = %v, requested = %q", status, err, requested)
+	}
+}
+
+func TestBinaryDefaultsToCodex(t *testing.T) {
+	defer SetBinary("")
+	if got := Binary(); got != "codex" {
+		t.Fatalf("Binary() = %q", got)
+	}
+	SetBinary("/opt/codex/bin/codex")
+	if got := Binary(); got != "/opt/codex/bin/codex" {
+		t.Fatalf("Binary() = %q", got)
+	}
+}
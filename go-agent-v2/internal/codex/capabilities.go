@@ -0,0 +1,108 @@
+// capabilities.go — codex 版本与协议能力探测 (agent/capabilities)。
+//
+// initialize 响应的 userAgent (如 "codex_cli_rs/0.46.0 (Mac OS 15.1; arm64)") 给出 codex 版本;
+// 各实验性方法以空参数调用探测: 返回 method not found 视为不支持, 参数错误或成功均视为支持,
+// 其他错误 (超时 / 熔断) 记为未知。空参数对需要 threadId 的方法只会触发参数校验, 不产生副作用。
+package codex
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// capabilityProbeTimeout 单个方法探测的超时。
+const capabilityProbeTimeout = 5 * time.Second
+
+// CapabilityProbeMethods 探测的 app-server 方法 (UI 按方法名隐藏不支持的操作)。
+var CapabilityProbeMethods = []string{
+	"thread/fork",
+	"thread/rollback",
+	"thread/compact/start",
+	"thread/archive",
+	"turn/steer",
+	"review/start",
+	"model/list",
+	"skills/list",
+	"config/read",
+	"command/exec",
+}
+
+// Capabilities codex 进程的版本与协议能力快照。
+type Capabilities struct {
+	Transport string          `json:"transport"` // app-server / mock
+	Version   string          `json:"version,omitempty"`
+	UserAgent string          `json:"userAgent,omitempty"`
+	Features  map[string]bool `json:"features"`          // 方法名 → 是否支持
+	Unknown   []string        `json:"unknown,omitempty"` // 探测失败, 支持情况未知
+}
+
+// CapabilityProber 可探测版本与协议能力的客户端 (可选能力, REST 传输与测试替身可不实现)。
+type CapabilityProber interface {
+	ProbeCapabilities(ctx context.Context) Capabilities
+}
+
+var codexVersionPattern = regexp.MustCompile(`\d+\.\d+\.\d+(?:[-+][0-9A-Za-z.-]+)?`)
+
+// ParseCodexVersion 从 userAgent 或 `codex --version` 输出中提取语义化版本号。
+func ParseCodexVersion(text string) string {
+	return codexVersionPattern.FindString(text)
+}
+
+// probeRPCFeatures 逐个以空参数调用探测方法。
+func probeRPCFeatures(ctx context.Context, call func(method string, params any, timeout time.Duration) (json.RawMessage, error)) (map[string]bool, []string) {
+	features := make(map[string]bool, len(CapabilityProbeMethods))
+	var unknown []string
+	for _, method := range CapabilityProbeMethods {
+		if ctx.Err() != nil {
+			unknown = append(unknown, method)
+			continue
+		}
+		_, err := call(method, map[string]any{}, capabilityProbeTimeout)
+		switch {
+		case err == nil, isInvalidParamsRPCError(err), isInvalidRequestRPCError(err):
+			features[method] = true
+		case isMethodNotFoundRPCError(err):
+			features[method] = false
+		default:
+			unknown = append(unknown, method)
+		}
+	}
+	return features, unknown
+}
+
+// isInvalidRequestRPCError codex 对反序列化失败的请求返回 -32600 (Invalid request)。
+func isInvalidRequestRPCError(err error) bool {
+	if err == nil {
+		return false
+	}
+	text := strings.ToLower(err.Error())
+	return strings.Contains(text, "invalid request") || strings.Contains(text, "code -32600")
+}
+
+// ProbeCapabilities 实现 CapabilityProber。
+func (c *AppServerClient) ProbeCapabilities(ctx context.Context) Capabilities {
+	caps := Capabilities{Transport: "app-server"}
+	if raw, ok := c.initResult.Load().(json.RawMessage); ok && len(raw) > 0 {
+		var result struct {
+			UserAgent string `json:"userAgent"`
+		}
+		if json.Unmarshal(raw, &result) == nil {
+			caps.UserAgent = result.UserAgent
+			caps.Version = ParseCodexVersion(result.UserAgent)
+		}
+	}
+	caps.Features, caps.Unknown = probeRPCFeatures(ctx, c.call)
+	return caps
+}
+
+// ProbeCapabilities 实现 CapabilityProber (模拟后端支持全部探测方法)。
+func (c *MockClient) ProbeCapabilities(context.Context) Capabilities {
+	features := make(map[string]bool, len(CapabilityProbeMethods))
+	for _, method := range CapabilityProbeMethods {
+		features[method] = true
+	}
+	return Capabilities{Transport: "mock", Version: "0.0.0-mock", UserAgent: "codex-mock", Features: features}
+}
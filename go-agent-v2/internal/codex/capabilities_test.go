@@ -0,0 +1,49 @@
+package codex
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseCodexVersion(t *testing.T) {
+	cases := map[string]string{
+		"codex_cli_rs/0.46.0 (Mac OS 15.1.0; arm64) iTerm.app/3.5": "0.46.0",
+		"codex-cli 0.47.1-alpha.2":                                 "0.47.1-alpha.2",
+		"codex":                                                    "",
+	}
+	for in, want := range cases {
+		if got := ParseCodexVersion(in); got != want {
+			t.Errorf("ParseCodexVersion(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestProbeRPCFeaturesClassifiesErrors(t *testing.T) {
+	call := func(method string, _ any, _ time.Duration) (json.RawMessage, error) {
+		switch method {
+		case "thread/fork":
+			return nil, errors.New("rpc error: Invalid request: missing field `threadId` (code -32600)")
+		case "thread/rollback":
+			return nil, errors.New("rpc error: method not found (code -32601)")
+		case "review/start":
+			return nil, errors.New("thread/start timeout")
+		}
+		return json.RawMessage(`{}`), nil
+	}
+	features, unknown := probeRPCFeatures(context.Background(), call)
+	if !features["thread/fork"] || !features["model/list"] {
+		t.Fatalf("features = %v", features)
+	}
+	if supported, probed := features["thread/rollback"]; supported || !probed {
+		t.Fatalf("thread/rollback = (%v, %v), want probed unsupported", supported, probed)
+	}
+	if len(unknown) != 1 || unknown[0] != "review/start" {
+		t.Fatalf("unknown = %v", unknown)
+	}
+	if _, probed := features["review/start"]; probed {
+		t.Fatal("timed out probe should not be recorded as a feature")
+	}
+}
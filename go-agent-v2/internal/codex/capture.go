@@ -0,0 +1,232 @@
+// capture.go — codex 会话录制 (JSON-RPC 收发流量) 与回放解析。
+//
+// 设置 GO_AGENT_SESSION_CAPTURE_DIR 后, 每个 AppServerClient 把与 codex 进程之间的
+// 全部 JSON-RPC 消息逐行追加到 <dir>/<agentId>-<启动时间>.jsonl:
+//
+//	{"ts":"...","dir":"in","agentId":"thread-1","msg":{...}}
+//
+// dir=in 为 codex → apiserver, dir=out 为 apiserver → codex。文件在首条消息时创建,
+// 连接终止 (readLoop 退出) 或 Shutdown 时关闭。cmd/replay 经 ReadCapture + CaptureEvent
+// 把录制的事件重新送入 apiserver 事件路径, 用于回归调试。
+package codex
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+// 录制方向。
+const (
+	CaptureDirIn  = "in"  // codex → apiserver
+	CaptureDirOut = "out" // apiserver → codex
+)
+
+// maxCaptureLineBytes 回放时单行上限 (大文件 diff / 输出增量)。
+const maxCaptureLineBytes = 64 << 20
+
+var appServerCaptureDir = strings.TrimSpace(os.Getenv("GO_AGENT_SESSION_CAPTURE_DIR"))
+
+var captureFileNameUnsafe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// CaptureRecord 录制文件中的一行。
+type CaptureRecord struct {
+	TS      time.Time       `json:"ts"`
+	Dir     string          `json:"dir"`
+	AgentID string          `json:"agentId"`
+	Msg     json.RawMessage `json:"msg"`
+}
+
+// sessionRecorder 单会话录制器; nil 接收者表示未启用。
+type sessionRecorder struct {
+	mu      sync.Mutex
+	agentID string
+	path    string
+	file    *os.File
+	closed  bool
+	failed  bool // 打开/写入失败后停止录制, 只告警一次
+}
+
+// newSessionRecorder 按录制目录创建录制器; dir 为空时返回 nil。
+func newSessionRecorder(dir, agentID string) *sessionRecorder {
+	dir = strings.TrimSpace(dir)
+	if dir == "" {
+		return nil
+	}
+	name := captureFileNameUnsafe.ReplaceAllString(strings.TrimSpace(agentID), "_")
+	if name == "" {
+		name = "agent"
+	}
+	name += "-" + time.Now().UTC().Format("20060102T150405.000000000") + ".jsonl"
+	return &sessionRecorder{agentID: agentID, path: filepath.Join(dir, name)}
+}
+
+// Path 返回录制文件路径。
+func (r *sessionRecorder) Path() string {
+	if r == nil {
+		return ""
+	}
+	return r.path
+}
+
+// record 追加一条原始消息。
+func (r *sessionRecorder) record(dir string, msg []byte) {
+	if r == nil || len(msg) == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed || r.failed {
+		return
+	}
+	if r.file == nil {
+		if err := os.MkdirAll(filepath.Dir(r.path), 0o700); err != nil {
+			r.fail(err)
+			return
+		}
+		f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			r.fail(err)
+			return
+		}
+		r.file = f
+		logger.Info("codex: session capture started",
+			logger.FieldAgentID, r.agentID,
+			logger.FieldPath, r.path,
+		)
+	}
+	raw := json.RawMessage(msg)
+	if !json.Valid(raw) {
+		// 非 JSON 帧按字符串保存, 保证每行可解析。
+		quoted, _ := json.Marshal(string(msg))
+		raw = quoted
+	}
+	line, err := json.Marshal(CaptureRecord{TS: time.Now().UTC(), Dir: dir, AgentID: r.agentID, Msg: raw})
+	if err != nil {
+		r.fail(err)
+		return
+	}
+	if _, err := r.file.Write(append(line, '\n')); err != nil {
+		r.fail(err)
+	}
+}
+
+// recordValue 序列化后追加 (出站消息)。
+func (r *sessionRecorder) recordValue(dir string, v any) {
+	if r == nil {
+		return
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		logger.Warn("codex: session capture marshal failed",
+			logger.FieldAgentID, r.agentID,
+			logger.FieldError, err,
+		)
+		return
+	}
+	r.record(dir, data)
+}
+
+// fail 记录录制错误并停用 (调用方持有 mu)。
+func (r *sessionRecorder) fail(err error) {
+	r.failed = true
+	logger.Warn("codex: session capture disabled",
+		logger.FieldAgentID, r.agentID,
+		logger.FieldPath, r.path,
+		logger.FieldError, err,
+	)
+	if r.file != nil {
+		_ = r.file.Close()
+		r.file = nil
+	}
+}
+
+// close 关闭录制文件 (幂等)。
+func (r *sessionRecorder) close() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+	r.closed = true
+	if r.file != nil {
+		if err := r.file.Close(); err != nil {
+			logger.Warn("codex: session capture close failed",
+				logger.FieldAgentID, r.agentID,
+				logger.FieldPath, r.path,
+				logger.FieldError, err,
+			)
+		}
+		r.file = nil
+	}
+}
+
+// CapturePath 返回当前会话录制文件路径 (未启用录制时为空)。
+func (c *AppServerClient) CapturePath() string { return c.capture.Path() }
+
+// ReadCapture 逐行解析录制文件; 空行跳过, 坏行报错并带行号。
+func ReadCapture(r io.Reader) ([]CaptureRecord, error) {
+	const op = "codex.ReadCapture"
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxCaptureLineBytes)
+	var records []CaptureRecord
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var rec CaptureRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			return nil, apperrors.Wrapf(err, op, "line %d", lineNo)
+		}
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, apperrors.Wrap(err, op, "scan capture")
+	}
+	return records, nil
+}
+
+// CaptureEvent 把一条入站录制消息还原为 readLoop 派发给 EventHandler 的 Event。
+//
+// 出站消息、RPC 响应与 readLoop 会丢弃的 legacy mirror 通知返回 false。
+// 服务端请求保留 RequestID, RespondFunc / DenyFunc 为空操作, 回放不会回写 codex。
+func CaptureEvent(rec CaptureRecord) (Event, bool) {
+	if rec.Dir != CaptureDirIn {
+		return Event{}, false
+	}
+	var msg jsonRPCMessage
+	if err := json.Unmarshal(rec.Msg, &msg); err != nil {
+		return Event{}, false
+	}
+	if msg.Method == "" {
+		return Event{}, false
+	}
+	if dropped, _, _ := shouldDropLegacyMirrorNotification(msg); dropped {
+		return Event{}, false
+	}
+	event := (&AppServerClient{AgentID: rec.AgentID}).jsonRPCToEvent(msg)
+	if event.Type == "" {
+		return Event{}, false
+	}
+	event.DenyFunc = func() error { return nil }
+	if msg.ID != nil {
+		event.RequestID = msg.ID
+		event.RespondFunc = func(int, string) error { return nil }
+	}
+	return event, true
+}
@@ -0,0 +1,70 @@
+package codex
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSessionRecorderRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	rec := newSessionRecorder(dir, "thread/1")
+	if !strings.HasPrefix(filepath.Base(rec.Path()), "thread_1-") {
+		t.Fatalf("path = %q", rec.Path())
+	}
+	if _, err := os.Stat(rec.Path()); !os.IsNotExist(err) {
+		t.Fatalf("capture file should be created lazily, stat err = %v", err)
+	}
+
+	rec.recordValue(CaptureDirOut, map[string]any{"jsonrpc": "2.0", "id": 1, "method": "turn/start"})
+	rec.record(CaptureDirIn, []byte(`{"jsonrpc":"2.0","id":1,"result":{}}`))
+	rec.record(CaptureDirIn, []byte(`{"jsonrpc":"2.0","method":"turn/started","params":{"turn":{"id":"t1"}}}`))
+	rec.record(CaptureDirIn, []byte(`{"jsonrpc":"2.0","id":7,"method":"item/commandExecution/requestApproval","params":{"command":"ls"}}`))
+	rec.record(CaptureDirIn, []byte("not json"))
+	rec.close()
+	rec.record(CaptureDirIn, []byte(`{"method":"after/close"}`))
+
+	f, err := os.Open(rec.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records, err := ReadCapture(f)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if len(records) != 5 || records[0].Dir != CaptureDirOut || records[0].AgentID != "thread/1" {
+		t.Fatalf("records = %+v", records)
+	}
+	if string(records[4].Msg) != `"not json"` {
+		t.Fatalf("non-JSON frame = %s", records[4].Msg)
+	}
+
+	var events []Event
+	for _, r := range records {
+		if event, ok := CaptureEvent(r); ok {
+			events = append(events, event)
+		}
+	}
+	if len(events) != 2 || events[0].Type != EventTurnStarted || events[0].RequestID != nil {
+		t.Fatalf("events = %+v", events)
+	}
+	approval := events[1]
+	if approval.Type != "exec_approval_request" || approval.RequestID == nil || *approval.RequestID != 7 {
+		t.Fatalf("approval event = %+v", approval)
+	}
+	if err := approval.RespondFunc(0, "x"); err != nil {
+		t.Fatalf("replay RespondFunc should be a no-op: %v", err)
+	}
+}
+
+func TestReadCaptureReportsBadLine(t *testing.T) {
+	_, err := ReadCapture(strings.NewReader("{\"dir\":\"in\",\"msg\":{}}\n\n{broken\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Fatalf("err = %v", err)
+	}
+	if newSessionRecorder("", "a") != nil {
+		t.Fatal("empty dir should disable capture")
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -48,6 +49,7 @@ type Client struct {
 	ThreadID  string
 	AgentID   string        // 所属 Agent 标识, 用于日志关联
 	Transport TransportMode // 保留字段兼容, 不再使用
+	ExtraEnv  []string      // 追加到子进程的环境变量 (KEY=VALUE, 覆盖继承值; Spawn 前设置)
 
 	baseURL         string
 	handler         EventHandler
@@ -103,9 +105,9 @@ func (c *Client) Spawn(ctx context.Context) error {
 	}
 
 	portArg := strconv.Itoa(c.Port)
-	c.Cmd = exec.CommandContext(ctx, "codex", "http-api", "--p1", portArg)
+	c.Cmd = exec.CommandContext(ctx, Binary(), "http-api", "--p1", portArg)
 	c.Cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
-	c.Cmd.Env = os.Environ()
+	c.Cmd.Env = append(os.Environ(), c.ExtraEnv...)
 
 	// port 0: 捕获 stdout 以发现实际端口
 	var stdoutBuf bytes.Buffer
@@ -253,11 +255,14 @@ func parsePortFromOutput(output string) int {
 	return 0
 }
 
+// ErrPortInUse Spawn 时指定端口已被占用 (runner 据此换端口重试)。
+var ErrPortInUse = errors.New("port in use")
+
 // checkPortFree 检查端口是否空闲。
 func checkPortFree(port int) error {
 	l, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
 	if err != nil {
-		return err
+		return fmt.Errorf("%w: %v", ErrPortInUse, err)
 	}
 	_ = l.Close()
 	return nil
@@ -80,7 +80,8 @@ type AppServerClient struct {
 	Port     int
 	Cmd      *exec.Cmd
 	ThreadID string
-	AgentID  string // 所属 Agent 标识, 用于日志关联
+	AgentID  string   // 所属 Agent 标识, 用于日志关联
+	ExtraEnv []string // 追加到子进程的环境变量 (KEY=VALUE, 覆盖继承值; Spawn 前设置)
 
 	// ========================================
 	// 锁职责说明
@@ -106,6 +107,8 @@ type AppServerClient struct {
 
 	// 活跃 turn 跟踪: turn/started 存入, turn_complete/idle/error 清空。
 	activeTurnID atomic.Value // string
+	// 后续 turn/start 携带的工作目录 (thread/cwd/set 切换, 空 = 沿用线程当前目录)。
+	turnCwd atomic.Value // string
 
 	// listener 兜底标记: 仅在连接重连后需要在下次 turn/start 前执行 thread/resume 确保订阅。
 	listenerEnsureNeeded atomic.Bool
@@ -114,6 +117,24 @@ type AppServerClient struct {
 
 	// legacy mirror 丢弃计数: 用于采样日志输出。
 	legacyMirrorDropCount atomic.Int64
+
+	// 最近一次收到 codex 消息的时间 (UnixNano), 供健康检查计算心跳间隔。
+	lastActivity atomic.Int64
+
+	// 请求限速与连接熔断 (见 client_appserver_breaker.go)。
+	limiter *appServerRateLimiter
+	breaker *appServerCircuitBreaker
+
+	// 会话录制 (GO_AGENT_SESSION_CAPTURE_DIR, nil = 未启用, 见 capture.go)。
+	capture *sessionRecorder
+
+	// initialize 响应 (json.RawMessage), 能力探测据此读取 userAgent (见 capabilities.go)。
+	initResult atomic.Value
+
+	// 接管的遗留进程 PID (Cmd 为 nil, 见 adopt.go)。
+	adoptedPID int
+	// 子进程退出记录 (Spawn 后设置, 见 exit.go)。
+	exit *processExit
 }
 
 const (
@@ -176,6 +197,9 @@ func NewAppServerClient(port int, agentID string) *AppServerClient {
 		ctx:     ctx,
 		cancel:  cancel,
 		wsDone:  make(chan struct{}),
+		limiter: newAppServerRateLimiter(appServerRateLimitRPS, appServerRateLimitBurst),
+		breaker: newAppServerCircuitBreaker(),
+		capture: newSessionRecorder(appServerCaptureDir, agentID),
 	}
 }
 
@@ -0,0 +1,212 @@
+// client_appserver_breaker.go — 单 codex 客户端的请求限速与熔断。
+//
+// 限速: 令牌桶约束 call() 的请求速率 (GO_AGENT_APP_SERVER_RPS / _BURST, 0 = 不限速),
+// 可经 SetRateLimit 按 Agent 覆盖。
+// 熔断: 连接断开 (1006 等) 与重连拨号失败计入连续失败, 达到阈值后熔断打开,
+// 冷却期内 call() 快速失败、重连循环等待冷却; 冷却结束进入半开, 再次失败则冷却时间翻倍 (上限 maxCooldown)。
+// 失败计数在一段安静期 (stableWindow) 后清零, 因此退避跨越多次重连周期持续生效,
+// 抖动进程每个冷却周期只向事件总线发一条 stream error。
+package codex
+
+import (
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+const (
+	defaultAppServerBreakerThreshold = 3
+	defaultAppServerBreakerCooldown  = 5 * time.Second
+	appServerBreakerMaxCooldown      = 5 * time.Minute
+	appServerBreakerStableWindow     = 60 * time.Second
+)
+
+// 熔断状态。
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+var (
+	appServerRateLimitRPS     = appServerFloatFromEnv("GO_AGENT_APP_SERVER_RPS", 0)
+	appServerRateLimitBurst   = int(appServerFloatFromEnv("GO_AGENT_APP_SERVER_BURST", 0))
+	appServerBreakerThreshold = int(appServerFloatFromEnv("GO_AGENT_APP_SERVER_BREAKER_THRESHOLD", defaultAppServerBreakerThreshold))
+	appServerBreakerCooldown  = time.Duration(appServerFloatFromEnv("GO_AGENT_APP_SERVER_BREAKER_COOLDOWN_MS", float64(defaultAppServerBreakerCooldown.Milliseconds()))) * time.Millisecond
+)
+
+// appServerFloatFromEnv 读取非负数值环境变量, 非法值回退默认值。
+func appServerFloatFromEnv(name string, def float64) float64 {
+	raw := strings.TrimSpace(os.Getenv(name))
+	if raw == "" {
+		return def
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil || value < 0 || math.IsNaN(value) || math.IsInf(value, 0) {
+		logger.Warn("codex: invalid "+name+", using default",
+			"value", raw,
+			"default", def,
+		)
+		return def
+	}
+	return value
+}
+
+// ========================================
+// 令牌桶限速
+// ========================================
+
+// appServerRateLimiter 令牌桶; rate <= 0 表示不限速。
+type appServerRateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // 每秒令牌数
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newAppServerRateLimiter(rps float64, burst int) *appServerRateLimiter {
+	l := &appServerRateLimiter{}
+	l.configure(rps, burst)
+	return l
+}
+
+func (l *appServerRateLimiter) configure(rps float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate = rps
+	l.burst = float64(burst)
+	if l.burst < 1 {
+		l.burst = math.Max(1, math.Ceil(rps))
+	}
+	l.tokens = l.burst
+	l.last = time.Time{}
+}
+
+// reserve 取一个令牌, 返回需等待的时长; 等待超过 maxWait 时不取令牌并返回 ok=false。
+func (l *appServerRateLimiter) reserve(now time.Time, maxWait time.Duration) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rate <= 0 {
+		return 0, true
+	}
+	if !l.last.IsZero() {
+		l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+	l.last = now
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0, true
+	}
+	wait := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+	if wait > maxWait {
+		return wait, false
+	}
+	l.tokens--
+	return wait, true
+}
+
+// ========================================
+// 熔断器
+// ========================================
+
+// appServerCircuitBreaker 连接级熔断器。
+type appServerCircuitBreaker struct {
+	mu           sync.Mutex
+	threshold    int // <= 0 = 关闭熔断
+	baseCooldown time.Duration
+	maxCooldown  time.Duration
+	stableWindow time.Duration
+
+	failures    int
+	trips       int
+	lastFailure time.Time
+	openUntil   time.Time
+}
+
+func newAppServerCircuitBreaker() *appServerCircuitBreaker {
+	return &appServerCircuitBreaker{
+		threshold:    appServerBreakerThreshold,
+		baseCooldown: appServerBreakerCooldown,
+		maxCooldown:  appServerBreakerMaxCooldown,
+		stableWindow: appServerBreakerStableWindow,
+	}
+}
+
+// recordFailure 记录一次断开 / 拨号失败; 本次失败使熔断打开时返回 tripped=true 与冷却时长。
+func (b *appServerCircuitBreaker) recordFailure(now time.Time) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	// 自上次失败 (或冷却结束) 起安静超过 stableWindow: 视为已恢复, 重新计数。
+	quietSince := b.lastFailure
+	if b.openUntil.After(quietSince) {
+		quietSince = b.openUntil
+	}
+	if !quietSince.IsZero() && now.Sub(quietSince) >= b.stableWindow {
+		b.failures = 0
+		b.trips = 0
+	}
+	b.lastFailure = now
+	b.failures++
+	if b.threshold <= 0 || b.failures < b.threshold || now.Before(b.openUntil) {
+		return false, 0
+	}
+	cooldown := b.baseCooldown
+	for i := 0; i < b.trips && cooldown < b.maxCooldown; i++ {
+		cooldown *= 2
+	}
+	if cooldown > b.maxCooldown {
+		cooldown = b.maxCooldown
+	}
+	b.trips++
+	b.openUntil = now.Add(cooldown)
+	return true, cooldown
+}
+
+// remaining 熔断打开时剩余的冷却时长。
+func (b *appServerCircuitBreaker) remaining(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if now.Before(b.openUntil) {
+		return b.openUntil.Sub(now)
+	}
+	return 0
+}
+
+// status 当前熔断状态快照。
+func (b *appServerCircuitBreaker) status(now time.Time) CircuitBreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	st := CircuitBreakerStatus{State: CircuitClosed, Failures: b.failures, Trips: b.trips}
+	switch {
+	case now.Before(b.openUntil):
+		st.State = CircuitOpen
+		st.RetryAfter = b.openUntil.Sub(now)
+	case b.threshold > 0 && b.failures >= b.threshold:
+		st.State = CircuitHalfOpen
+	}
+	return st
+}
+
+// CircuitBreakerStatus 熔断器状态。
+type CircuitBreakerStatus struct {
+	State      string        `json:"state"`
+	Failures   int           `json:"failures"`
+	Trips      int           `json:"trips"`
+	RetryAfter time.Duration `json:"retryAfter,omitempty"`
+}
+
+// CircuitBreakerStatus 返回当前客户端的熔断状态。
+func (c *AppServerClient) CircuitBreakerStatus() CircuitBreakerStatus {
+	return c.breaker.status(time.Now())
+}
+
+// SetRateLimit 覆盖该客户端的请求速率 (rps <= 0 = 不限速; burst <= 0 = 取 rps 向上取整)。
+func (c *AppServerClient) SetRateLimit(rps float64, burst int) {
+	c.limiter.configure(rps, burst)
+}
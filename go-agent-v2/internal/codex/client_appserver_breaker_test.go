@@ -0,0 +1,97 @@
+package codex
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker_TripsAndBacksOffAcrossCycles(t *testing.T) {
+	b := &appServerCircuitBreaker{threshold: 3, baseCooldown: time.Second, maxCooldown: 3 * time.Second, stableWindow: time.Minute}
+	now := time.Unix(1000, 0)
+	for i := 0; i < 2; i++ {
+		if tripped, _ := b.recordFailure(now); tripped {
+			t.Fatalf("tripped after %d failures, want threshold 3", i+1)
+		}
+	}
+	tripped, cooldown := b.recordFailure(now)
+	if !tripped || cooldown != time.Second {
+		t.Fatalf("third failure: tripped=%v cooldown=%v, want true 1s", tripped, cooldown)
+	}
+	if got := b.status(now).State; got != CircuitOpen {
+		t.Fatalf("state = %q, want open", got)
+	}
+	// 冷却期内的失败不重复触发
+	if tripped, _ := b.recordFailure(now.Add(500 * time.Millisecond)); tripped {
+		t.Fatal("failure during cooldown should not re-trip")
+	}
+
+	now = now.Add(2 * time.Second)
+	if got := b.status(now).State; got != CircuitHalfOpen {
+		t.Fatalf("state after cooldown = %q, want half_open", got)
+	}
+	if _, cooldown = b.recordFailure(now); cooldown != 2*time.Second {
+		t.Fatalf("second trip cooldown = %v, want 2s", cooldown)
+	}
+	now = now.Add(3 * time.Second)
+	if _, cooldown = b.recordFailure(now); cooldown != 3*time.Second {
+		t.Fatalf("third trip cooldown = %v, want capped 3s", cooldown)
+	}
+
+	// 安静期过后重新计数
+	now = now.Add(3*time.Second + time.Minute)
+	if tripped, _ := b.recordFailure(now); tripped {
+		t.Fatal("failure after stable window should reset counters")
+	}
+	if st := b.status(now); st.State != CircuitClosed || st.Trips != 0 {
+		t.Fatalf("status = %+v, want closed with 0 trips", st)
+	}
+}
+
+func TestCircuitBreaker_DisabledThreshold(t *testing.T) {
+	b := &appServerCircuitBreaker{threshold: 0, baseCooldown: time.Second, maxCooldown: time.Second, stableWindow: time.Minute}
+	now := time.Unix(1000, 0)
+	for i := 0; i < 10; i++ {
+		if tripped, _ := b.recordFailure(now); tripped {
+			t.Fatal("disabled breaker should never trip")
+		}
+	}
+}
+
+func TestRateLimiter_Reserve(t *testing.T) {
+	l := newAppServerRateLimiter(2, 2)
+	now := time.Unix(1000, 0)
+	for i := 0; i < 2; i++ {
+		if wait, ok := l.reserve(now, time.Second); !ok || wait != 0 {
+			t.Fatalf("burst reserve %d: wait=%v ok=%v", i, wait, ok)
+		}
+	}
+	wait, ok := l.reserve(now, time.Second)
+	if !ok || wait != 500*time.Millisecond {
+		t.Fatalf("third reserve: wait=%v ok=%v, want 500ms true", wait, ok)
+	}
+	if _, ok := l.reserve(now, 100*time.Millisecond); ok {
+		t.Fatal("reserve exceeding maxWait should fail")
+	}
+
+	unlimited := newAppServerRateLimiter(0, 0)
+	for i := 0; i < 100; i++ {
+		if wait, ok := unlimited.reserve(now, 0); !ok || wait != 0 {
+			t.Fatal("unlimited limiter should never wait")
+		}
+	}
+}
+
+func TestCall_FailsFastWhenCircuitOpen(t *testing.T) {
+	client := NewAppServerClient(0, "agent-breaker")
+	client.breaker.threshold = 1
+	client.breaker.recordFailure(time.Now())
+
+	_, err := client.call("thread/start", nil, time.Second)
+	if err == nil || !strings.Contains(err.Error(), "circuit open") {
+		t.Fatalf("err = %v, want circuit open", err)
+	}
+	if st := client.CircuitBreakerStatus(); st.State != CircuitOpen || st.RetryAfter <= 0 {
+		t.Fatalf("status = %+v, want open with retryAfter", st)
+	}
+}
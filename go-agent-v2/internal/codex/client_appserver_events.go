@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net"
 	"os"
 	"os/exec"
@@ -25,6 +26,7 @@ func (c *AppServerClient) readLoop() {
 		}
 		c.wsMu.Unlock()
 		c.failPendingCalls(apperrors.New("AppServerClient.readLoop", "connection closed"))
+		c.capture.close()
 
 		select {
 		case <-c.wsDone:
@@ -50,6 +52,8 @@ func (c *AppServerClient) readLoop() {
 			// 注意: 必须用循环内的 conn 局部变量, 不能用 c.currentWSConn(),
 			// 因为 reconnect 后 c.ws 已指向新 conn。
 			_ = conn.SetReadDeadline(time.Now().Add(appServerReadIdleTimeout))
+			c.lastActivity.Store(time.Now().UnixNano())
+			c.capture.record(CaptureDirIn, message)
 		}
 		if err != nil {
 			readErr := apperrors.Wrap(err, "AppServerClient.readLoop", "read message")
@@ -60,12 +64,18 @@ func (c *AppServerClient) readLoop() {
 				if !willRetry {
 					reconnectingMessage = "Stream disconnected"
 				}
-				c.emitStreamError(readErr, "read", isIdleTimeoutError(err), willRetry, map[string]any{
+				details := map[string]any{
 					"message":     reconnectingMessage,
 					"attempt":     0,
 					"max_retries": appServerStreamMaxRetries,
 					"trigger":     "read_error",
-				})
+				}
+				if tripped, cooldown := c.breaker.recordFailure(time.Now()); tripped {
+					details["message"] = fmt.Sprintf("Connection unstable, cooling down %s", cooldown.Round(time.Second))
+					details["circuit"] = CircuitOpen
+					details["cooldown_ms"] = cooldown.Milliseconds()
+				}
+				c.emitStreamError(readErr, "read", isIdleTimeoutError(err), willRetry, details)
 			}
 			if c.stopped.Load() && isShutdownReadError(err) {
 				logger.Debug("codex: readLoop read failed (shutdown)",
@@ -838,6 +848,7 @@ func (c *AppServerClient) asWriteJSON(v any) error {
 		c.failPendingCalls(writeErr)
 		return writeErr
 	}
+	c.capture.recordValue(CaptureDirOut, v)
 	return nil
 }
 
@@ -1019,11 +1030,15 @@ func (c *AppServerClient) Shutdown() error {
 	if c.stderrCollector != nil {
 		_ = c.stderrCollector.Close()
 	}
+	c.capture.close()
 	return nil
 }
 
 // Kill 强制终止子进程。
 func (c *AppServerClient) Kill() error {
+	if c.Cmd == nil && c.adoptedPID > 0 {
+		return c.killAdopted()
+	}
 	if c.Cmd == nil || c.Cmd.Process == nil {
 		return nil
 	}
@@ -1037,6 +1052,17 @@ func (c *AppServerClient) Kill() error {
 	if killErr != nil && !errors.Is(killErr, os.ErrProcessDone) {
 		return killErr
 	}
+	if c.exit != nil {
+		select {
+		case <-c.exit.done:
+		case <-time.After(5 * time.Second):
+			logger.Warn("codex: Kill() process exit wait timed out after 5s, abandoning",
+				logger.FieldAgentID, c.AgentID,
+				logger.FieldPID, pid,
+			)
+		}
+		return nil
+	}
 	// Cmd.Wait 可能因 pipe-copying goroutine 未退出而阻塞, 加超时保护。
 	waitDone := make(chan error, 1)
 	go func() { waitDone <- c.Cmd.Wait() }()
@@ -1065,6 +1091,13 @@ func (c *AppServerClient) Kill() error {
 
 // Running 返回是否在运行。
 func (c *AppServerClient) Running() bool {
+	if c.Cmd == nil && c.adoptedPID > 0 {
+		return !c.stopped.Load() && ProcessAlive(c.adoptedPID)
+	}
+	if c.exit != nil {
+		_, exited := c.ExitStatus()
+		return !c.stopped.Load() && !exited
+	}
 	return !c.stopped.Load() && c.Cmd != nil && c.Cmd.ProcessState == nil
 }
 
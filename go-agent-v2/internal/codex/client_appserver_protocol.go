@@ -2,6 +2,7 @@
 package codex
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"path/filepath"
 	"strings"
@@ -31,6 +32,7 @@ func (c *AppServerClient) Initialize() error {
 		logger.Error("codex: Initialize() FAILED", logger.FieldAgentID, c.AgentID, logger.FieldPort, c.Port, logger.FieldError, err)
 		return err
 	}
+	c.initResult.Store(result)
 	logger.Info("codex: Initialize() OK",
 		logger.FieldAgentID, c.AgentID,
 		logger.FieldPort, c.Port,
@@ -259,6 +261,9 @@ func (c *AppServerClient) Submit(prompt string, images, files []string, outputSc
 	if len(outputSchema) > 0 {
 		params["outputSchema"] = json.RawMessage(outputSchema)
 	}
+	if cwd, _ := c.turnCwd.Load().(string); cwd != "" {
+		params["cwd"] = cwd
+	}
 
 	result, err := c.call("turn/start", params, 10*time.Second)
 	if err != nil {
@@ -489,6 +494,28 @@ func (c *AppServerClient) SendDynamicToolResult(callID, output string, requestID
 	return c.notify("dynamic_tool_result", params)
 }
 
+// WriteTerminalStdin 向后台终端 (unified exec 进程) 写入 stdin, closeStdin 为 true 时随后关闭输入。
+//
+// 对应 app-server command/exec/write: {"processId", "deltaBase64", "closeStdin"}。
+func (c *AppServerClient) WriteTerminalStdin(processID string, data []byte, closeStdin bool) error {
+	id := strings.TrimSpace(processID)
+	if id == "" {
+		return apperrors.New("AppServerClient.WriteTerminalStdin", "processId is required")
+	}
+	params := map[string]any{
+		"processId":   id,
+		"deltaBase64": base64.StdEncoding.EncodeToString(data),
+		"closeStdin":  closeStdin,
+	}
+	if threadID := strings.TrimSpace(c.ThreadID); threadID != "" {
+		params["threadId"] = threadID
+	}
+	if _, err := c.call("command/exec/write", params, appServerWriteTimeout); err != nil {
+		return apperrors.Wrapf(err, "AppServerClient.WriteTerminalStdin", "write stdin to process %s", id)
+	}
+	return nil
+}
+
 // ListThreads 返回线程列表 (app-server 模式下只有当前线程)。
 func (c *AppServerClient) ListThreads() ([]ThreadInfo, error) {
 	if c.ThreadID == "" {
@@ -592,6 +619,32 @@ func (c *AppServerClient) ForkThread(_ ForkThreadRequest) (*ForkThreadResponse,
 	return nil, apperrors.New("AppServerClient.ForkThread", "fork not supported in app-server mode")
 }
 
+// ========================================
+// 健康检查
+// ========================================
+
+// Ping 发送一次轻量 RPC 验证 codex 进程可响应。
+// codex 返回 JSON-RPC 错误 (如方法不支持) 同样说明往返正常, 视为存活; 仅超时 / 传输失败返回错误。
+func (c *AppServerClient) Ping(timeout time.Duration) error {
+	if !c.Running() {
+		return apperrors.New("AppServerClient.Ping", "process not running")
+	}
+	_, err := c.call("thread/loaded/list", map[string]any{}, timeout)
+	if err != nil && !strings.Contains(err.Error(), "rpc error:") {
+		return err
+	}
+	return nil
+}
+
+// LastActivity 最近一次收到 codex 消息的时间 (零值 = 尚未收到)。
+func (c *AppServerClient) LastActivity() time.Time {
+	ns := c.lastActivity.Load()
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
 // ========================================
 // readLoop — 读取 JSON-RPC 消息
 // ========================================
@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
 	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
 	"github.com/multi-agent/go-agent-v2/pkg/logger"
 	"github.com/multi-agent/go-agent-v2/pkg/util"
@@ -30,9 +31,9 @@ func (c *AppServerClient) Spawn(ctx context.Context) error {
 	// 注意: 使用 exec.Command 而非 exec.CommandContext —
 	// 子进程不应随 HTTP 请求或 WebSocket 连接断开而被终止。
 	// 生命周期由 AppServerClient.Shutdown()/Kill() 显式管理。
-	c.Cmd = exec.Command("codex", "app-server", "--listen", listenURL)
+	c.Cmd = exec.Command(Binary(), "app-server", "--listen", listenURL)
 	c.Cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
-	c.Cmd.Env = os.Environ()
+	c.Cmd.Env = append(os.Environ(), c.ExtraEnv...)
 	c.Cmd.Stdout = io.Discard
 	c.stderrCollector = logger.NewStderrCollector(fmt.Sprintf("codex-appserver-%d", c.Port))
 	c.Cmd.Stderr = c.stderrCollector
@@ -40,6 +41,7 @@ func (c *AppServerClient) Spawn(ctx context.Context) error {
 	if err := c.Cmd.Start(); err != nil {
 		return apperrors.Wrap(err, "AppServerClient.Spawn", "spawn app-server")
 	}
+	c.watchExit()
 
 	// 等待 WebSocket 可用 (默认最多 30 秒, 同时受 ctx 控制)
 	deadline := time.Now().Add(appServerStartupProbeTimeout)
@@ -194,6 +196,24 @@ func (c *AppServerClient) reconnectWS(trigger string, lastErr error) bool {
 			break
 		}
 		delay := appServerReconnectDelay(attempt)
+		if cooldown := c.breaker.remaining(time.Now()); cooldown > delay {
+			// 熔断打开: 等待冷却结束后再拨号, 避免反复冲击抖动中的进程。
+			c.emitBackgroundEvent("Cooling down...", "cooldown", true, false, map[string]any{
+				"phase":        "reconnect",
+				"trigger":      trigger,
+				"attempt":      attempt,
+				"max_retries":  maxRetries,
+				"circuit":      CircuitOpen,
+				"cooldown_ms":  cooldown.Milliseconds(),
+				"activeTurnId": activeTurnID,
+			})
+			logger.Warn("codex: circuit open, delaying reconnect",
+				logger.FieldAgentID, c.AgentID,
+				"trigger", trigger,
+				"cooldown", cooldown,
+			)
+			delay = cooldown
+		}
 		if !c.sleepWithContext(delay) {
 			return false
 		}
@@ -231,13 +251,18 @@ func (c *AppServerClient) attemptSingleReconnect(trigger, activeTurnID string, a
 		if !willRetry {
 			reconnectMessage = fmt.Sprintf("Reconnect failed %d/%d", attempt, maxRetries)
 		}
-		c.emitStreamError(retryErr, "reconnect", false, willRetry, map[string]any{
+		details := map[string]any{
 			"message":      reconnectMessage,
 			"attempt":      attempt,
 			"max_retries":  maxRetries,
 			"trigger":      trigger,
 			"activeTurnId": activeTurnID,
-		})
+		}
+		if tripped, cooldown := c.breaker.recordFailure(time.Now()); tripped {
+			details["circuit"] = CircuitOpen
+			details["cooldown_ms"] = cooldown.Milliseconds()
+		}
+		c.emitStreamError(retryErr, "reconnect", false, willRetry, details)
 		logger.Warn("codex: ws reconnect attempt failed",
 			logger.FieldAgentID, c.AgentID,
 			"trigger", trigger,
@@ -306,6 +331,18 @@ func (c *AppServerClient) handleReconnectExhausted(trigger, activeTurnID string,
 
 // call 发送 JSON-RPC 请求并等待响应。
 func (c *AppServerClient) call(method string, params any, timeout time.Duration) (json.RawMessage, error) {
+	if cooldown := c.breaker.remaining(time.Now()); cooldown > 0 {
+		return nil, apperrors.NewCodef("AppServerClient.call", errcode.CodexCircuitOpen, "%s rejected: circuit open, retry in %s", method, cooldown.Round(time.Millisecond))
+	}
+	if wait, ok := c.limiter.reserve(time.Now(), timeout); !ok {
+		return nil, apperrors.NewCodef("AppServerClient.call", errcode.CodexRateLimited, "%s rate limited, wait %s exceeds timeout", method, wait.Round(time.Millisecond))
+	} else if wait > 0 {
+		if !c.sleepWithContext(wait) {
+			return nil, c.ctx.Err()
+		}
+		timeout -= wait
+	}
+
 	id := c.nextID.Add(1)
 	req := jsonRPCRequest{
 		JSONRPC: "2.0",
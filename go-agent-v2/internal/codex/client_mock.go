@@ -0,0 +1,295 @@
+// client_mock.go — 进程内模拟 codex 客户端 (演示模式 / 集成测试 / 压测)。
+//
+// MockClient 实现 CodexClient, 不启动 codex 子进程也不需要 API Key: 每次 Submit 按脚本
+// 逐步向 EventHandler 派发事件 (思考 → 命令执行 → 文件修改 → 回复 → turn_complete),
+// 事件形状与 app-server 通知映射后的类型一致, 因此 apiserver / uistate / Wails 前端整条链路可直接演示。
+// 脚本只产生事件, 不会真正执行命令或修改文件。中断 (CmdInterrupt) 取消当前脚本并发出 turn_aborted。
+package codex
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+// DefaultMockStepDelay 演示模式默认步间隔 (可见流式效果)。
+const DefaultMockStepDelay = 60 * time.Millisecond
+
+// MockStep 脚本中的一步: 等待 Delay (0 = MockClient.StepDelay) 后派发 Type/Data 事件。
+type MockStep struct {
+	Type  string
+	Data  map[string]any
+	Delay time.Duration
+}
+
+// MockScript 为一次 turn 生成事件序列 (turnID 由 MockClient 分配, prompt 为 Submit 文本)。
+type MockScript func(turnID, prompt string) []MockStep
+
+// MockClient 按脚本回放事件的 CodexClient。
+type MockClient struct {
+	Port      int
+	AgentID   string
+	Script    MockScript    // nil = DefaultMockScript
+	StepDelay time.Duration // 步间隔, 0 = 不等待
+
+	mu         sync.Mutex
+	threadID   string
+	activeTurn string
+	turnCwd    string
+	cancelTurn context.CancelFunc
+	handler    EventHandler
+	running    atomic.Bool
+	turnSeq    atomic.Int64
+}
+
+// NewMockClient 创建使用默认演示脚本的模拟客户端。
+func NewMockClient(port int, agentID string) *MockClient {
+	return &MockClient{Port: port, AgentID: agentID, Script: DefaultMockScript, StepDelay: DefaultMockStepDelay}
+}
+
+// MockClientFactory 返回构造模拟客户端的工厂 (runner.AgentManager.SetClientFactory)。
+func MockClientFactory(script MockScript, stepDelay time.Duration) func(port int, agentID string) CodexClient {
+	return func(port int, agentID string) CodexClient {
+		c := NewMockClient(port, agentID)
+		if script != nil {
+			c.Script = script
+		}
+		c.StepDelay = stepDelay
+		return c
+	}
+}
+
+// GetPort 返回端口号 (仅占位, 不监听)。
+func (c *MockClient) GetPort() int { return c.Port }
+
+// GetThreadID 返回模拟 thread ID。
+func (c *MockClient) GetThreadID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.threadID
+}
+
+// GetActiveTurnID 返回当前脚本对应的 turn ID。
+func (c *MockClient) GetActiveTurnID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.activeTurn
+}
+
+// SetEventHandler 注册事件回调。
+func (c *MockClient) SetEventHandler(h EventHandler) {
+	c.mu.Lock()
+	c.handler = h
+	c.mu.Unlock()
+}
+
+// SpawnAndConnect 分配模拟 thread ID, 不启动任何进程。
+func (c *MockClient) SpawnAndConnect(_ context.Context, _, _, _, _ string, _ []DynamicTool) error {
+	c.mu.Lock()
+	if c.threadID == "" {
+		c.threadID = "mock-" + c.AgentID
+	}
+	c.mu.Unlock()
+	c.running.Store(true)
+	logger.Info("codex: mock client connected",
+		logger.FieldAgentID, c.AgentID,
+		logger.FieldThreadID, c.GetThreadID(),
+	)
+	return nil
+}
+
+// Submit 启动一次脚本 turn; 已有进行中的 turn 时返回错误。
+func (c *MockClient) Submit(prompt string, _, _ []string, _ json.RawMessage) error {
+	if !c.running.Load() {
+		return apperrors.New("MockClient.Submit", "mock client not running")
+	}
+	c.mu.Lock()
+	if c.activeTurn != "" {
+		active := c.activeTurn
+		c.mu.Unlock()
+		return apperrors.Newf("MockClient.Submit", "turn %s still running", active)
+	}
+	turnID := fmt.Sprintf("mock-turn-%d", c.turnSeq.Add(1))
+	ctx, cancel := context.WithCancel(context.Background())
+	c.activeTurn = turnID
+	c.cancelTurn = cancel
+	script := c.Script
+	c.mu.Unlock()
+	if script == nil {
+		script = DefaultMockScript
+	}
+	go c.play(ctx, turnID, script(turnID, prompt))
+	return nil
+}
+
+// play 逐步派发脚本事件; 被取消时发出 turn_aborted。
+func (c *MockClient) play(ctx context.Context, turnID string, steps []MockStep) {
+	defer c.finishTurn(turnID)
+	for _, step := range steps {
+		delay := step.Delay
+		if delay == 0 {
+			delay = c.StepDelay
+		}
+		if delay > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+			case <-timer.C:
+			}
+		}
+		if ctx.Err() != nil {
+			c.finishTurn(turnID)
+			c.emit("turn_aborted", map[string]any{
+				"turn":   map[string]any{"id": turnID, "status": "interrupted", "items": []any{}},
+				"reason": "interrupted",
+			})
+			return
+		}
+		if step.Type == EventTurnComplete {
+			// 与真实客户端一致: turn_complete 派发前活跃 turn 已清空。
+			c.finishTurn(turnID)
+		}
+		c.emit(step.Type, step.Data)
+	}
+}
+
+func (c *MockClient) finishTurn(turnID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.activeTurn != turnID {
+		return
+	}
+	c.activeTurn = ""
+	if c.cancelTurn != nil {
+		c.cancelTurn()
+		c.cancelTurn = nil
+	}
+}
+
+func (c *MockClient) emit(eventType string, data map[string]any) {
+	c.mu.Lock()
+	handler := c.handler
+	c.mu.Unlock()
+	if handler == nil || eventType == "" {
+		return
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		logger.Warn("codex: mock event marshal failed",
+			logger.FieldAgentID, c.AgentID,
+			logger.FieldEventType, eventType,
+			logger.FieldError, err,
+		)
+		return
+	}
+	handler(Event{Type: eventType, Data: raw})
+}
+
+// SendCommand 仅支持中断 (取消当前脚本), 其余命令忽略。
+func (c *MockClient) SendCommand(cmd, _ string) error {
+	if strings.TrimSpace(cmd) != CmdInterrupt {
+		return nil
+	}
+	c.mu.Lock()
+	cancel := c.cancelTurn
+	c.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+	return nil
+}
+
+// SetTurnCwd 实现 TurnCwdConfigurable (仅记录, 供测试断言)。
+func (c *MockClient) SetTurnCwd(cwd string) {
+	c.mu.Lock()
+	c.turnCwd = cwd
+	c.mu.Unlock()
+}
+
+// TurnCwd 返回最近一次 SetTurnCwd 的目录。
+func (c *MockClient) TurnCwd() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.turnCwd
+}
+
+// SendDynamicToolResult 模拟客户端不发起工具调用, 直接忽略。
+func (c *MockClient) SendDynamicToolResult(string, string, *int64) error { return nil }
+
+// RespondError 直接忽略。
+func (c *MockClient) RespondError(int64, int, string) error { return nil }
+
+// ListThreads 返回当前模拟线程。
+func (c *MockClient) ListThreads() ([]ThreadInfo, error) {
+	return []ThreadInfo{{ThreadID: c.GetThreadID()}}, nil
+}
+
+// ResumeThread 采用请求中的 thread ID。
+func (c *MockClient) ResumeThread(req ResumeThreadRequest) error {
+	c.mu.Lock()
+	if id := strings.TrimSpace(req.ThreadID); id != "" {
+		c.threadID = id
+	}
+	c.mu.Unlock()
+	c.running.Store(true)
+	return nil
+}
+
+// ForkThread 返回新的模拟 thread ID。
+func (c *MockClient) ForkThread(req ForkThreadRequest) (*ForkThreadResponse, error) {
+	source := strings.TrimSpace(req.SourceThreadID)
+	if source == "" {
+		source = c.GetThreadID()
+	}
+	return &ForkThreadResponse{ThreadID: fmt.Sprintf("%s-fork-%d", source, time.Now().UnixNano()), Port: c.Port}, nil
+}
+
+// Shutdown 取消进行中的脚本并停止。
+func (c *MockClient) Shutdown() error {
+	c.running.Store(false)
+	_ = c.SendCommand(CmdInterrupt, "")
+	return nil
+}
+
+// Kill 同 Shutdown。
+func (c *MockClient) Kill() error { return c.Shutdown() }
+
+// Running 返回是否运行中。
+func (c *MockClient) Running() bool { return c.running.Load() }
+
+// DefaultMockScript 演示脚本: 思考 → 执行测试命令 → 修改 README.md → 流式回复。
+func DefaultMockScript(turnID, prompt string) []MockStep {
+	execID := turnID + "-exec"
+	patchID := turnID + "-patch"
+	reply := fmt.Sprintf("Demo mode (mock codex): I received %q, ran the test suite and added a line to README.md. No real command was executed.",
+		truncStr(strings.TrimSpace(prompt), 80))
+	diff := "--- a/README.md\n+++ b/README.md\n@@ -1 +1,2 @@\n # Demo\n+Updated by the mock codex backend.\n"
+
+	steps := []MockStep{
+		{Type: EventTurnStarted, Data: map[string]any{"turn": map[string]any{"id": turnID}}},
+		{Type: EventAgentReasoningDelta, Data: map[string]any{"delta": "Reading the request. "}},
+		{Type: EventAgentReasoningDelta, Data: map[string]any{"delta": "Plan: run the tests, then update README.md."}},
+		{Type: EventExecCommandBegin, Data: map[string]any{"call_id": execID, "command": "go test ./..."}},
+		{Type: EventExecCommandOutputDelta, Data: map[string]any{"call_id": execID, "delta": "ok  \tdemo/internal/app\t0.012s\n"}},
+		{Type: EventExecCommandOutputDelta, Data: map[string]any{"call_id": execID, "delta": "ok  \tdemo/pkg/util\t0.004s\n"}},
+		{Type: EventExecCommandEnd, Data: map[string]any{"call_id": execID, "command": "go test ./...", "exit_code": 0}},
+		{Type: EventPatchApplyBegin, Data: map[string]any{"call_id": patchID, "file": "README.md", "auto_approved": true}},
+		{Type: EventPatchApplyEnd, Data: map[string]any{"call_id": patchID, "file": "README.md", "success": true}},
+		{Type: EventTurnDiff, Data: map[string]any{"unified_diff": diff}},
+	}
+	for _, word := range strings.SplitAfter(reply, " ") {
+		steps = append(steps, MockStep{Type: EventAgentMessageDelta, Data: map[string]any{"delta": word}})
+	}
+	return append(steps, MockStep{Type: EventTurnComplete, Data: map[string]any{
+		"turn":               map[string]any{"id": turnID, "status": "completed", "items": []any{}},
+		"last_agent_message": reply,
+	}})
+}
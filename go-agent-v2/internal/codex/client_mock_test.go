@@ -0,0 +1,100 @@
+package codex
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// collectMockEvents 注册回调并返回事件类型收集器。
+func collectMockEvents(c *MockClient) (func() []string, <-chan string) {
+	var mu sync.Mutex
+	var types []string
+	ends := make(chan string, 4)
+	c.SetEventHandler(func(event Event) {
+		mu.Lock()
+		types = append(types, event.Type)
+		mu.Unlock()
+		if event.Type == EventTurnComplete || event.Type == "turn_aborted" {
+			ends <- event.Type
+		}
+	})
+	return func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), types...)
+	}, ends
+}
+
+func TestMockClientPlaysDefaultScript(t *testing.T) {
+	c := NewMockClient(0, "thread-demo")
+	c.StepDelay = 0
+	got, ends := collectMockEvents(c)
+	if err := c.SpawnAndConnect(context.Background(), "", ".", "", "", nil); err != nil {
+		t.Fatal(err)
+	}
+	if c.GetThreadID() != "mock-thread-demo" || !c.Running() {
+		t.Fatalf("thread = %q running = %v", c.GetThreadID(), c.Running())
+	}
+	if err := c.Submit("fix the build", nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case end := <-ends:
+		if end != EventTurnComplete {
+			t.Fatalf("turn ended with %s", end)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("mock turn did not complete")
+	}
+	seen := map[string]bool{}
+	for _, typ := range got() {
+		seen[typ] = true
+	}
+	for _, want := range []string{EventTurnStarted, EventAgentReasoningDelta, EventExecCommandBegin, EventExecCommandEnd, EventPatchApplyBegin, EventPatchApplyEnd, EventAgentMessageDelta} {
+		if !seen[want] {
+			t.Fatalf("missing %s in %v", want, got())
+		}
+	}
+	if c.GetActiveTurnID() != "" {
+		t.Fatalf("active turn not cleared: %q", c.GetActiveTurnID())
+	}
+}
+
+func TestMockClientInterrupt(t *testing.T) {
+	c := NewMockClient(0, "thread-int")
+	c.Script = func(turnID, _ string) []MockStep {
+		return []MockStep{
+			{Type: EventTurnStarted, Data: map[string]any{"turn": map[string]any{"id": turnID}}},
+			{Type: EventAgentMessageDelta, Data: map[string]any{"delta": "slow"}, Delay: time.Hour},
+			{Type: EventTurnComplete, Data: map[string]any{}},
+		}
+	}
+	c.StepDelay = 0
+	_, ends := collectMockEvents(c)
+	if err := c.Submit("x", nil, nil, nil); err == nil {
+		t.Fatal("submit before connect should fail")
+	}
+	_ = c.SpawnAndConnect(context.Background(), "", ".", "", "", nil)
+	if err := c.Submit("x", nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Submit("y", nil, nil, nil); err == nil {
+		t.Fatal("concurrent submit should fail while a turn is active")
+	}
+	if err := c.SendCommand(CmdInterrupt, ""); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case end := <-ends:
+		if end != "turn_aborted" {
+			t.Fatalf("turn ended with %s", end)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("interrupt did not abort the turn")
+	}
+	if c.GetActiveTurnID() != "" {
+		t.Fatal("active turn not cleared after interrupt")
+	}
+}
@@ -0,0 +1,62 @@
+// exit.go — app-server 子进程退出监视。
+//
+// Spawn 后由后台 goroutine 负责唯一一次 Cmd.Wait: 进程退出即可被 Running 感知,
+// 并记录退出码供重启策略区分正常退出 (exit 0) 与崩溃。Kill 等待该 goroutine 而不再自行 Wait。
+package codex
+
+import (
+	"errors"
+	"os/exec"
+	"time"
+)
+
+// exitWaitDelay 进程退出后等待 stderr 转发结束的上限 (孙进程可能继承管道)。
+const exitWaitDelay = 2 * time.Second
+
+// ExitReporter 可报告子进程退出状态的客户端 (可选能力)。
+type ExitReporter interface {
+	// ExitStatus 返回退出码与是否已退出 (被信号终止时退出码为 -1)。
+	ExitStatus() (code int, exited bool)
+}
+
+// processExit 子进程退出记录。
+type processExit struct {
+	done chan struct{}
+	code int
+}
+
+// watchExit 启动退出监视 (Cmd.Start 成功后调用)。
+func (c *AppServerClient) watchExit() {
+	c.Cmd.WaitDelay = exitWaitDelay
+	exit := &processExit{done: make(chan struct{})}
+	c.exit = exit
+	cmd := c.Cmd
+	go func() {
+		err := cmd.Wait()
+		var exitErr *exec.ExitError
+		switch {
+		case err == nil:
+			exit.code = 0
+		case errors.As(err, &exitErr):
+			exit.code = exitErr.ExitCode()
+		case cmd.ProcessState != nil:
+			exit.code = cmd.ProcessState.ExitCode()
+		default:
+			exit.code = -1
+		}
+		close(exit.done)
+	}()
+}
+
+// ExitStatus 实现 ExitReporter (未启动或接管的进程返回未退出)。
+func (c *AppServerClient) ExitStatus() (int, bool) {
+	if c.exit == nil {
+		return 0, false
+	}
+	select {
+	case <-c.exit.done:
+		return c.exit.code, true
+	default:
+		return 0, false
+	}
+}
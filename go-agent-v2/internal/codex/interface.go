@@ -42,3 +42,22 @@ type CodexClient interface {
 	// Running 返回是否运行中。
 	Running() bool
 }
+
+// EnvConfigurable 支持在 Spawn 前追加子进程环境变量的客户端 (可选能力, 测试替身可不实现)。
+type EnvConfigurable interface {
+	SetExtraEnv(env []string)
+}
+
+// TurnCwdConfigurable 支持切换后续 turn 工作目录的客户端 (可选能力, thread/cwd/set 使用)。
+type TurnCwdConfigurable interface {
+	SetTurnCwd(cwd string)
+}
+
+// SetExtraEnv 实现 EnvConfigurable。
+func (c *AppServerClient) SetExtraEnv(env []string) { c.ExtraEnv = env }
+
+// SetExtraEnv 实现 EnvConfigurable。
+func (c *Client) SetExtraEnv(env []string) { c.ExtraEnv = env }
+
+// SetTurnCwd 实现 TurnCwdConfigurable: 之后每次 turn/start 携带 cwd (codex 将其设为线程新的工作目录)。
+func (c *AppServerClient) SetTurnCwd(cwd string) { c.turnCwd.Store(cwd) }
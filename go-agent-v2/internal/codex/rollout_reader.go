@@ -3,6 +3,7 @@ package codex
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -52,44 +53,141 @@ func ReadRolloutMessages(rolloutPath string) ([]RolloutMessage, error) {
 	scanner.Buffer(make([]byte, 0, 64*1024), 100*1024*1024) // 100 MB max — rollout 行可能含 base64 图片或大 diff
 
 	for scanner.Scan() {
-		var line rolloutLine
-		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
-			continue
+		if msg, ok := parseRolloutMessage(scanner.Bytes()); ok {
+			messages = append(messages, msg)
 		}
-		if line.Type != "response_item" {
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scan rollout file: %w", err)
+	}
+	return messages, nil
+}
+
+// parseRolloutMessage 解析单行 rollout, 仅接受可见的 user/assistant 消息 (过滤系统注入)。
+func parseRolloutMessage(raw []byte) (RolloutMessage, bool) {
+	var line rolloutLine
+	if err := json.Unmarshal(raw, &line); err != nil || line.Type != "response_item" {
+		return RolloutMessage{}, false
+	}
+	var payload rolloutPayload
+	if err := json.Unmarshal(line.Payload, &payload); err != nil || payload.Type != "message" {
+		return RolloutMessage{}, false
+	}
+	if payload.Role != "user" && payload.Role != "assistant" {
+		return RolloutMessage{}, false
+	}
+	text := extractRolloutText(payload.Content)
+	if text == "" {
+		return RolloutMessage{}, false
+	}
+	if payload.Role == "user" {
+		if isSystemNoise(text) {
+			return RolloutMessage{}, false
+		}
+		text = trimSkillInjection(text)
+		text = trimLSPInjection(text)
+		if strings.TrimSpace(text) == "" {
+			return RolloutMessage{}, false
+		}
+	}
+	return RolloutMessage{Role: payload.Role, Content: text, Timestamp: line.Timestamp}, true
+}
+
+// TruncateRolloutTurns 保留 rollout 中前 keep 个用户 turn (及其前的 session_meta 等行),
+// 返回截断后的内容与原 rollout 的用户 turn 总数; keep 不小于总数时原样返回。
+// 用户 turn 以可见的用户消息为起点, 紧邻其前的 turn_context 行属于该 turn, 一并截去。
+func TruncateRolloutTurns(data []byte, keep int) ([]byte, int) {
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	turns, cut := 0, -1
+	for i, raw := range lines {
+		msg, ok := parseRolloutMessage(bytes.TrimSpace(raw))
+		if !ok || msg.Role != "user" {
 			continue
 		}
+		turns++
+		if turns == keep+1 {
+			cut = i
+			for cut > 0 && rolloutLineType(lines[cut-1]) == "turn_context" {
+				cut--
+			}
+		}
+	}
+	if cut < 0 {
+		return data, turns
+	}
+	return bytes.Join(lines[:cut], nil), turns
+}
 
-		var payload rolloutPayload
-		if err := json.Unmarshal(line.Payload, &payload); err != nil {
+func rolloutLineType(raw []byte) string {
+	var line rolloutLine
+	if json.Unmarshal(bytes.TrimSpace(raw), &line) != nil {
+		return ""
+	}
+	return line.Type
+}
+
+// RolloutPatch 从 rollout 文件提取的 apply_patch 调用。
+type RolloutPatch struct {
+	CallID    string `json:"callId,omitempty"`
+	Patch     string `json:"patch"`     // apply_patch 原始补丁文本
+	Timestamp string `json:"timestamp"` // ISO8601
+}
+
+// rolloutToolCallPayload response_item 中工具调用的 payload。
+type rolloutToolCallPayload struct {
+	Type      string `json:"type"` // "custom_tool_call" / "function_call"
+	Name      string `json:"name"`
+	CallID    string `json:"call_id"`
+	Input     string `json:"input"`     // custom_tool_call
+	Arguments string `json:"arguments"` // function_call (JSON 字符串)
+}
+
+// ReadRolloutPatches 从 rollout JSONL 文件按时间顺序提取 apply_patch 补丁。
+func ReadRolloutPatches(rolloutPath string) ([]RolloutPatch, error) {
+	f, err := os.Open(rolloutPath)
+	if err != nil {
+		return nil, fmt.Errorf("open rollout file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	var patches []RolloutPatch
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 100*1024*1024)
+
+	for scanner.Scan() {
+		var line rolloutLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
 			continue
 		}
-		if payload.Type != "message" {
+		if line.Type != "response_item" {
 			continue
 		}
-		if payload.Role != "user" && payload.Role != "assistant" {
+		var payload rolloutToolCallPayload
+		if err := json.Unmarshal(line.Payload, &payload); err != nil {
 			continue
 		}
-
-		text := extractRolloutText(payload.Content)
-		if text == "" {
+		if payload.Name != "apply_patch" {
 			continue
 		}
-
-		if payload.Role == "user" {
-			if isSystemNoise(text) {
-				continue
+		patch := ""
+		switch payload.Type {
+		case "custom_tool_call":
+			patch = payload.Input
+		case "function_call":
+			var args struct {
+				Input string `json:"input"`
 			}
-			text = trimSkillInjection(text)
-			text = trimLSPInjection(text)
-			if strings.TrimSpace(text) == "" {
-				continue
+			if err := json.Unmarshal([]byte(payload.Arguments), &args); err == nil {
+				patch = args.Input
 			}
 		}
-
-		messages = append(messages, RolloutMessage{
-			Role:      payload.Role,
-			Content:   text,
+		if strings.TrimSpace(patch) == "" {
+			continue
+		}
+		patches = append(patches, RolloutPatch{
+			CallID:    payload.CallID,
+			Patch:     patch,
 			Timestamp: line.Timestamp,
 		})
 	}
@@ -97,7 +195,7 @@ func ReadRolloutMessages(rolloutPath string) ([]RolloutMessage, error) {
 	if err := scanner.Err(); err != nil {
 		return nil, fmt.Errorf("scan rollout file: %w", err)
 	}
-	return messages, nil
+	return patches, nil
 }
 
 // FindRolloutPath 根据 codexThreadID 查找 rollout 文件。
@@ -3,6 +3,7 @@ package codex
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -266,6 +267,29 @@ func TestReadRolloutMessages_UserOnlySkillInjection_SkippedAsEmpty(t *testing.T)
 
 // ── FindRolloutPath ─────────────────────────────────────────
 
+// ── ReadRolloutPatches ──────────────────────────────────────
+
+func TestReadRolloutPatches_CustomAndFunctionCalls(t *testing.T) {
+	content := `{"timestamp":"2026-02-20T01:00:00Z","type":"response_item","payload":{"type":"custom_tool_call","name":"apply_patch","call_id":"c1","input":"*** Begin Patch\n*** Add File: a.go\n+package a\n*** End Patch"}}
+{"timestamp":"2026-02-20T01:00:01Z","type":"response_item","payload":{"type":"function_call","name":"shell","call_id":"c2","arguments":"{\"command\":[\"ls\"]}"}}
+{"timestamp":"2026-02-20T01:00:02Z","type":"response_item","payload":{"type":"function_call","name":"apply_patch","call_id":"c3","arguments":"{\"input\":\"*** Begin Patch\\n*** Delete File: b.go\\n*** End Patch\"}"}}
+`
+	path := writeTemp(t, content)
+	patches, err := ReadRolloutPatches(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patches) != 2 {
+		t.Fatalf("got %d patches, want 2", len(patches))
+	}
+	if patches[0].CallID != "c1" || patches[1].CallID != "c3" {
+		t.Fatalf("patches = %+v, want c1/c3", patches)
+	}
+	if patches[1].Timestamp != "2026-02-20T01:00:02Z" {
+		t.Fatalf("patches[1].Timestamp = %q", patches[1].Timestamp)
+	}
+}
+
 func TestFindRolloutPath_EmptyThreadID(t *testing.T) {
 	_, err := FindRolloutPath("")
 	if err == nil {
@@ -273,6 +297,34 @@ func TestFindRolloutPath_EmptyThreadID(t *testing.T) {
 	}
 }
 
+// ── TruncateRolloutTurns ────────────────────────────────────
+
+func TestTruncateRolloutTurns(t *testing.T) {
+	rollout := `{"type":"session_meta","payload":{"id":"abc"}}
+{"type":"response_item","payload":{"type":"message","role":"user","content":[{"type":"input_text","text":"<environment_context>cwd</environment_context>"}]}}
+{"type":"turn_context","payload":{}}
+{"type":"response_item","payload":{"type":"message","role":"user","content":[{"type":"input_text","text":"first"}]}}
+{"type":"response_item","payload":{"type":"message","role":"assistant","content":[{"type":"output_text","text":"one"}]}}
+{"type":"turn_context","payload":{}}
+{"type":"response_item","payload":{"type":"message","role":"user","content":[{"type":"input_text","text":"second"}]}}
+{"type":"response_item","payload":{"type":"message","role":"assistant","content":[{"type":"output_text","text":"two"}]}}
+`
+	out, turns := TruncateRolloutTurns([]byte(rollout), 1)
+	if turns != 2 {
+		t.Fatalf("turns = %d, want 2", turns)
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) != 5 || !strings.Contains(lines[4], `"one"`) {
+		t.Fatalf("truncated = %q", out)
+	}
+	if out, _ := TruncateRolloutTurns([]byte(rollout), 2); string(out) != rollout {
+		t.Fatalf("keep all changed rollout: %q", out)
+	}
+	if out, _ := TruncateRolloutTurns([]byte(rollout), 0); strings.Contains(string(out), "first") || !strings.Contains(string(out), "session_meta") {
+		t.Fatalf("keep 0 = %q", out)
+	}
+}
+
 // ── writeTemp helper ────────────────────────────────────────
 
 func writeTemp(t *testing.T, content string) string {
@@ -5,10 +5,13 @@
 //	`env:"VAR_NAME" default:"value" min:"0"`
 //
 // Load() 使用反射自动填充，无需手动逐行赋值。
+//
+// 取值按层覆盖: 默认值 → 配置文件 (config.yaml / config.toml, 键同环境变量名) → 环境变量 → 命令行 --set。
+// 带 reload:"true" 的字段可经 config/reload 运行时热更新, 其余变更需重启生效。
 package config
 
 import (
-	"github.com/multi-agent/go-agent-v2/pkg/util"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
 )
 
 // Config 应用全局配置，字段名与 .env 变量一一对应。
@@ -43,6 +46,11 @@ type Config struct {
 	PostgresPoolMaxSize    int    `env:"POSTGRES_POOL_MAX_SIZE" default:"10" min:"1"`
 	PostgresPoolTimeoutSec int    `env:"POSTGRES_POOL_TIMEOUT_SEC" default:"10" min:"1"`
 
+	// 敏感列静态加密 (交互内容 / 共享文件 / 审计日志, AES-256-GCM): "keyID:base64密钥[,...]", 首个用于加密;
+	// 为空时从系统钥匙串 STORE_ENCRYPTION_KEYCHAIN_SERVICE 读取, 两者均为空 = 不加密。轮换: migrate rotate-keys
+	StoreEncryptionKeys            string `env:"STORE_ENCRYPTION_KEYS"`
+	StoreEncryptionKeychainService string `env:"STORE_ENCRYPTION_KEYCHAIN_SERVICE"`
+
 	// Dashboard
 	DashboardSSESyncSec int `env:"DASHBOARD_SSE_SYNC_SEC" default:"5" min:"1"`
 	AuditLogLimit       int `env:"AUDIT_LOG_LIMIT" default:"100" min:"1"`
@@ -57,7 +65,7 @@ type Config struct {
 	TopologyApprovalTTLSec  int  `env:"TOPOLOGY_APPROVAL_TTL_SEC" default:"120" min:"1"`
 
 	// 日志
-	LogLevel string `env:"LOG_LEVEL" default:"INFO"`
+	LogLevel string `env:"LOG_LEVEL" default:"INFO" reload:"true"` // DEBUG / INFO / WARN / ERROR
 
 	// HTTP 服务
 	GinMode        string `env:"GIN_MODE" default:"release"`          // release / debug / test
@@ -71,19 +79,191 @@ type Config struct {
 	DisableOffline52Methods bool `env:"DISABLE_OFFLINE_52_METHODS" default:"true"`
 
 	// Turn Tracker (stall 检测)
-	StallThresholdSec int `env:"STALL_THRESHOLD_SEC" default:"480" min:"30"` // 无事件多久(秒)触发 stall 自动中断
-	StallHeartbeatSec int `env:"STALL_HEARTBEAT_SEC" default:"300" min:"10"` // dynamic tool call / 审批等待时的保活心跳间隔(秒)
+	StallThresholdSec int `env:"STALL_THRESHOLD_SEC" default:"480" min:"30" reload:"true"` // 无事件多久(秒)触发 stall 自动中断
+	StallHeartbeatSec int `env:"STALL_HEARTBEAT_SEC" default:"300" min:"10" reload:"true"` // dynamic tool call / 审批等待时的保活心跳间隔(秒)
+	// stall 处置链: 逗号分隔, 按顺序执行 probe (/status 探测) / escalate (告警) / interrupt (中断) / restart (重启进程)
+	StallRemediation         string `env:"STALL_REMEDIATION" default:"escalate,interrupt" reload:"true"`
+	StallRemediationGraceSec int    `env:"STALL_REMEDIATION_GRACE_SEC" default:"30" min:"1" reload:"true"` // 处置步骤之间的等待时间(秒)
+
+	// 动态工具结果缓存 (只读工具, 文件变更时失效)
+	ToolResultCacheTTLSec     int `env:"TOOL_RESULT_CACHE_TTL_SEC" default:"300" min:"0"` // 0 = 禁用
+	ToolResultCacheMaxEntries int `env:"TOOL_RESULT_CACHE_MAX_ENTRIES" default:"512" min:"1"`
+
+	// Store 读缓存 (agent_status / agent_codex_binding, 本进程写入即失效)
+	StoreCacheTTLMS int `env:"STORE_CACHE_TTL_MS" default:"2000" min:"0" reload:"true"` // 0 = 禁用
+
+	// turn 优先级调度 (interactive > normal > background, 按项目配额公平出队)
+	TurnSchedulerMaxConcurrent int    `env:"TURN_SCHEDULER_MAX_CONCURRENT" default:"0" min:"0"` // 全局并发上限, 0 = 不限 (且无单模型上限时不启用调度)
+	TurnSchedulerProjectQuota  int    `env:"TURN_SCHEDULER_PROJECT_QUOTA" default:"0" min:"0"`  // 单项目并发上限, 0 = 不限
+	TurnSchedulerModelLimits   string `env:"TURN_SCHEDULER_MODEL_LIMITS"`                       // 单模型并发上限, 如 "gpt-5=4,o3*=2,default=8"
+
+	// 诊断门禁 (turn 变更文件存在新增 LSP 错误时拒绝 completed 并要求 agent 修复)
+	TurnQualityGateEnabled    bool `env:"TURN_QUALITY_GATE_ENABLED" default:"false"`          // 全局默认开关, turn/start 可用 qualityGate 覆盖
+	TurnQualityGateMaxRetries int  `env:"TURN_QUALITY_GATE_MAX_RETRIES" default:"2" min:"0"`  // 自动修复重试次数
+	TurnQualityGateSettleMS   int  `env:"TURN_QUALITY_GATE_SETTLE_MS" default:"1500" min:"0"` // 重新同步文件后等待诊断稳定的时间
+
+	// 投机双模型执行 (实验性, turn/speculate: 廉价模型与强模型并行, 先通过门禁者胜出)
+	TurnSpeculationEnabled bool `env:"TURN_SPECULATION_ENABLED" default:"false"`
+
+	// 线程工作目录文件监听 (workspace/fileChanged 通知, 供 UI 实时刷新)
+	WorkspaceWatchEnabled    bool `env:"WORKSPACE_WATCH_ENABLED" default:"true"`
+	WorkspaceWatchDebounceMS int  `env:"WORKSPACE_WATCH_DEBOUNCE_MS" default:"200" min:"10"`
+	WorkspaceWatchMaxDirs    int  `env:"WORKSPACE_WATCH_MAX_DIRS" default:"4096" min:"1"` // 单个工作目录最多监听的子目录数
+
+	// 技能目录热加载 (外部修改技能后失效缓存并推送 skills/changed)
+	SkillsWatchEnabled    bool `env:"SKILLS_WATCH_ENABLED" default:"true"`
+	SkillsWatchDebounceMS int  `env:"SKILLS_WATCH_DEBOUNCE_MS" default:"300" min:"10"`
+
+	// 编队对账 (fleet/apply 清单与运行态的漂移检测)
+	FleetReconcileIntervalSec int  `env:"FLEET_RECONCILE_INTERVAL_SEC" default:"30" min:"0"` // 0 = 关闭后台对账
+	FleetAutoHeal             bool `env:"FLEET_AUTO_HEAL" default:"false"`                   // 检测到可修复漂移时自动收敛
+
+	// agent 健康检查 (RPC 往返 + 事件心跳) 与自动重启策略
+	AgentHealthIntervalSec    int `env:"AGENT_HEALTH_INTERVAL_SEC" default:"30" min:"0"`     // 0 = 关闭健康检查
+	AgentHealthPingTimeoutSec int `env:"AGENT_HEALTH_PING_TIMEOUT_SEC" default:"5" min:"1"`  // 单次 RPC 往返超时
+	AgentHealthFailThreshold  int `env:"AGENT_HEALTH_FAIL_THRESHOLD" default:"3" min:"1"`    // 连续失败多少次判定 unhealthy
+	AgentHealthStaleEventSec  int `env:"AGENT_HEALTH_STALE_EVENT_SEC" default:"300" min:"0"` // turn 进行中无事件超过该时长判定 degraded, 0 = 不检查
+	AgentRestartMax           int `env:"AGENT_RESTART_MAX" default:"3" min:"0"`              // 窗口内最多自动重启次数, 0 = 仅上报不重启
+	AgentRestartBackoffSec    int `env:"AGENT_RESTART_BACKOFF_SEC" default:"5" min:"0"`      // 首次重启退避, 之后逐次翻倍
+	AgentRestartWindowSec     int `env:"AGENT_RESTART_WINDOW_SEC" default:"600" min:"1"`     // 重启次数统计窗口
+
+	// 外部通知渠道 (notify/channel/*)
+	NotifyApprovalPendingSec int `env:"NOTIFY_APPROVAL_PENDING_SEC" default:"300" min:"1"` // 审批等待超过该时长触发 approval.pending
+
+	// 审批推送中继 (ntfy / FCM): 审批未处理时推送到移动端, 经签名链接 POST /approval/respond 回复
+	ApprovalRelayKind       string `env:"APPROVAL_RELAY_KIND" default:"ntfy"` // ntfy / fcm
+	ApprovalRelayURL        string `env:"APPROVAL_RELAY_URL"`                 // ntfy 主题地址或 FCM v1 messages:send 地址, 空 = 关闭
+	ApprovalRelayToken      string `env:"APPROVAL_RELAY_TOKEN"`               // ntfy 访问令牌 / FCM OAuth access token
+	ApprovalRelayFCMTarget  string `env:"APPROVAL_RELAY_FCM_TARGET"`          // FCM 设备 token
+	ApprovalRelaySecret     string `env:"APPROVAL_RELAY_SECRET"`              // 回复链接 HMAC 签名密钥
+	ApprovalRelayPublicURL  string `env:"APPROVAL_RELAY_PUBLIC_URL"`          // 移动端可访问的 app-server 地址 (https://host)
+	ApprovalRelayDelaySec   int    `env:"APPROVAL_RELAY_DELAY_SEC" default:"60" min:"0"`
+	ApprovalRelayTimeoutSec int    `env:"APPROVAL_RELAY_TIMEOUT_SEC" default:"900" min:"30"` // 推送后等待回复的最长时间
+
+	// 只读观战连接 (/spectate)
+	SpectatorKeys string `env:"SPECTATOR_KEYS"` // 逗号分隔的观战密钥, 空 = 关闭
+
+	// UI 时间线内存上限 (超出后逐出冷线程时间线, 访问时从 rollout 重建; 见 apiserver/timeline_eviction.go)
+	UITimelineMaxMB int `env:"UI_TIMELINE_MAX_MB" default:"256" min:"0"` // 0 = 不逐出
+
+	// UI 运行时状态跨重启持久化 (有数据库存 ui_runtime_state 表, 否则存本地文件; 见 apiserver/ui_runtime_persist.go)
+	UIStatePersistSec int    `env:"UI_STATE_PERSIST_SEC" default:"30" min:"0"` // 周期保存间隔, 0 = 不持久化也不恢复
+	UIStateFile       string `env:"UI_STATE_FILE"`                             // 空 = ~/.multi-agent/ui-runtime-state.json
+
+	// codex 事件 schema (版本化映射表; 未声明事件计入 debug/events/unknown; 见 uistate/event_schema.go)
+	EventSchemaVersion string `env:"EVENT_SCHEMA_VERSION"`                // 空 = 最新注册版本
+	EventSchemaMode    string `env:"EVENT_SCHEMA_MODE" default:"lenient"` // lenient: 未知事件按 system 处理; strict: 归一化为 error
+
+	// WebSocket 流式通知带宽 (见 apiserver/ws_coalesce.go)
+	WSCompression        bool `env:"WS_COMPRESSION" default:"true"`                    // 协商 permessage-deflate (仅对请求该扩展的客户端生效)
+	WSDeltaCoalesceMs    int  `env:"WS_DELTA_COALESCE_MS" default:"0" min:"0"`         // 新连接的 delta 合并间隔, 0 = 不合并 (stream/coalesce 可按连接调整)
+	WSDeltaCoalesceBytes int  `env:"WS_DELTA_COALESCE_BYTES" default:"4096" min:"256"` // 缓存累计达到此大小立即发出
+
+	// command/exec 沙箱
+	CommandSandboxDefaultProfile   string `env:"COMMAND_SANDBOX_DEFAULT_PROFILE" default:"standard"` // 未配置线程的预设: strict / standard / dev
+	CommandSandboxContainerRuntime string `env:"COMMAND_SANDBOX_CONTAINER_RUNTIME" default:"docker"` // 容器执行使用的 CLI (docker / podman)
+
+	// 优雅排空 (server/drain, SIGUSR1)
+	DrainTimeoutSec int `env:"DRAIN_TIMEOUT_SEC" default:"300" min:"1"` // 等待进行中 turn 结束的上限, 超时后写检查点退出
+
+	// 紧急停止 (orchestrator/emergencyStop 状态快照与锁文件)
+	EmergencySnapshotDir string `env:"EMERGENCY_SNAPSHOT_DIR"` // 空 = ~/.multi-agent/emergency
+
+	// codex 可执行文件 (启动时发现并校验版本, 不满足约束时推送 codex/binary/status; 见 codex/binary.go)
+	CodexBin         string `env:"CODEX_BIN"`          // 空 = PATH 中的 codex, 其次 CODEX_DOWNLOAD_DIR
+	CodexMinVersion  string `env:"CODEX_MIN_VERSION"`  // 含下限, 空 = 不限
+	CodexMaxVersion  string `env:"CODEX_MAX_VERSION"`  // 含上限, 空 = 不限
+	CodexDownloadURL string `env:"CODEX_DOWNLOAD_URL"` // 找不到时下载 (裸二进制或 .tar.gz, 支持 {os} {arch} 占位), 空 = 不下载
+	CodexDownloadDir string `env:"CODEX_DOWNLOAD_DIR"` // 空 = ~/.multi-agent/bin
+
+	// agent 端口分配 (runner.PortAllocator; 预留记录供下次启动接管或清理残留进程)
+	AgentPortMin       int    `env:"AGENT_PORT_MIN" default:"19836" min:"1"`
+	AgentPortMax       int    `env:"AGENT_PORT_MAX" default:"20835" min:"1"`
+	AgentPortStateFile string `env:"AGENT_PORT_STATE_FILE"`              // 空 = ~/.multi-agent/agent-ports.json
+	AgentAdoptOrphans  bool   `env:"AGENT_ADOPT_ORPHANS" default:"true"` // 启动时接管上次遗留的 app-server (按 agent_codex_binding 恢复线程), 失败的再清理
+
+	// 离线持久化 WAL (Postgres / 网络不可用时暂存绑定、别名、任务追踪写入, 恢复后重放)
+	PersistWALPath      string `env:"PERSIST_WAL_PATH"`                            // 空 = ~/.multi-agent/persist-wal.jsonl
+	PersistWALReplaySec int    `env:"PERSIST_WAL_REPLAY_SEC" default:"15" min:"1"` // 有待重放写入时的重试间隔
+
+	// 动态工具注册表 (外部命令工具 / gRPC 插件 / WASM 插件, 格式见 internal/toolreg/config.go)
+	DynamicToolsFile string `env:"DYNAMIC_TOOLS_FILE"` // 空 = ~/.multi-agent/dynamic-tools.json (不存在则跳过)
+
+	// system_logs 保留策略 (超期日志归档为 gzip JSONL 后删除; log/retention/set 可覆盖天数与归档开关)
+	LogRetentionDays        int    `env:"LOG_RETENTION_DAYS" default:"30" min:"0"`         // 0 = 永久保留
+	LogRetentionArchive     bool   `env:"LOG_RETENTION_ARCHIVE" default:"true"`            // false = 直接删除
+	LogRetentionIntervalMin int    `env:"LOG_RETENTION_INTERVAL_MIN" default:"60" min:"0"` // 0 = 关闭后台清理
+	LogArchiveDir           string `env:"LOG_ARCHIVE_DIR"`                                 // 空 = ~/.multi-agent/log-archive
+
+	// 磁盘配额清理 (logs/ 与日志归档、codex rollout 目录、临时导入目录; 超配额时先预警再按修改时间从旧到新删除)
+	StorageJanitorIntervalMin int `env:"STORAGE_JANITOR_INTERVAL_MIN" default:"60" min:"0"` // 0 = 关闭后台清理
+	StorageQuotaLogsMB        int `env:"STORAGE_QUOTA_LOGS_MB" default:"1024" min:"0"`      // logs/ + LOG_ARCHIVE_DIR, 0 = 不限
+	StorageQuotaRolloutsMB    int `env:"STORAGE_QUOTA_ROLLOUTS_MB" default:"4096" min:"0"`  // ~/.codex/sessions + archived_sessions
+	StorageQuotaTempMB        int `env:"STORAGE_QUOTA_TEMP_MB" default:"1024" min:"0"`      // 输入暂存目录 + skill-registry-* 残留
+	StorageWarnPercent        int `env:"STORAGE_WARN_PERCENT" default:"90" min:"1"`         // 用量达到配额的百分比时预警
+	StorageMinAgeMin          int `env:"STORAGE_MIN_AGE_MIN" default:"60" min:"0"`          // 最近修改的文件不删除 (正在写入的日志 / rollout)
+
+	// agent 长期记忆 (pgvector; turn 摘要 / 工具输出向量化, turn 提交前自动检索)
+	MemoryEnabled            bool    `env:"MEMORY_ENABLED" default:"true"`
+	MemoryEmbeddingModel     string  `env:"MEMORY_EMBEDDING_MODEL"`                         // 空 = 本地特征哈希向量
+	MemoryEmbeddingBaseURL   string  `env:"MEMORY_EMBEDDING_BASE_URL"`                      // 空 = OPENAI_BASE_URL
+	MemoryEmbeddingAPIKey    string  `env:"MEMORY_EMBEDDING_API_KEY"`                       // 空 = OPENAI_API_KEY
+	MemoryAutoRetrieveTopK   int     `env:"MEMORY_AUTO_RETRIEVE_TOP_K" default:"3" min:"0"` // 0 = 关闭自动检索
+	MemoryMinScore           float64 `env:"MEMORY_MIN_SCORE" default:"0.25" min:"0"`
+	MemoryToolOutputMinChars int     `env:"MEMORY_TOOL_OUTPUT_MIN_CHARS" default:"200" min:"0"` // 短于此长度的工具输出不入库
+
+	// 外部知识库连接器 (索引到向量记忆, 通过 kb_search 工具检索)
+	KBConnectors string `env:"KB_CONNECTORS"`                       // JSON 数组, 见 service/kb_connectors.go
+	KBRefreshMin int    `env:"KB_REFRESH_MIN" default:"60" min:"1"` // 读穿检索时的来源刷新间隔 (分钟)
 
 	// 编排工作区 (双通道: 虚拟目录 + PG 状态)
 	OrchestrationWorkspaceRoot          string `env:"ORCHESTRATION_WORKSPACE_ROOT" default:".agent/workspaces"`
 	OrchestrationWorkspaceMaxFiles      int    `env:"ORCHESTRATION_WORKSPACE_MAX_FILES" default:"5000" min:"1"`
 	OrchestrationWorkspaceMaxFileBytes  int    `env:"ORCHESTRATION_WORKSPACE_MAX_FILE_BYTES" default:"8388608" min:"1024"`     // 8MB
 	OrchestrationWorkspaceMaxTotalBytes int    `env:"ORCHESTRATION_WORKSPACE_MAX_TOTAL_BYTES" default:"268435456" min:"10240"` // 256MB
+
+	// 拖放文件 / 粘贴图片输入暂存 (input/prepareFiles, input/stageImage)
+	InputStagingDir     string `env:"INPUT_STAGING_DIR"`                                   // 空 = <系统临时目录>/multi-agent-inputs
+	InputInlineMaxBytes int    `env:"INPUT_INLINE_MAX_BYTES" default:"65536" min:"0"`      // 不超过此大小的文本文件内联为 fileContent
+	InputMaxFileBytes   int    `env:"INPUT_MAX_FILE_BYTES" default:"104857600" min:"1024"` // 100MB, 超过则拒绝
+	InputImageMaxBytes  int    `env:"INPUT_IMAGE_MAX_BYTES" default:"20971520" min:"1024"` // 20MB, 粘贴图片 (data URL 解码后) 上限
+
+	// 合并后自动创建 PR (workspace/run/merge pullRequest 选项)
+	VCSGitHubToken    string `env:"VCS_GITHUB_TOKEN"`
+	VCSGitHubAPIURL   string `env:"VCS_GITHUB_API_URL"` // 空 = https://api.github.com (Enterprise: https://host/api/v3)
+	VCSGitLabToken    string `env:"VCS_GITLAB_TOKEN"`
+	VCSGitLabAPIURL   string `env:"VCS_GITLAB_API_URL"` // 空 = https://<remote host>/api/v4
+	VCSPRRemote       string `env:"VCS_PR_REMOTE" default:"origin"`
+	VCSPRBranchPrefix string `env:"VCS_PR_BRANCH_PREFIX" default:"agent/"`
+
+	// 技能注册表 (skills/registry/sync)
+	SkillRegistryURL       string `env:"SKILL_REGISTRY_URL"`        // https://.../index.json 或 git+https://...
+	SkillRegistryPublicKey string `env:"SKILL_REGISTRY_PUBLIC_KEY"` // base64 ed25519 公钥, 安装时校验签名
+
+	// MCP 服务器 (cmd/mcp-server): 子代理编排工具经 app-server JSON-RPC 执行
+	MCPAppServerURL  string `env:"MCP_APP_SERVER_URL" default:"ws://127.0.0.1:4500"` // 空 = 不提供编排工具
+	MCPTransport     string `env:"MCP_TRANSPORT" default:"stdio"`                    // stdio / http (Streamable HTTP + SSE)
+	MCPListen        string `env:"MCP_LISTEN" default:"127.0.0.1:4600"`
+	MCPAuthToken     string `env:"MCP_AUTH_TOKEN"` // http 传输的 Bearer 令牌, 非回环地址必填
+	MCPSessionTTLMin int    `env:"MCP_SESSION_TTL_MIN" default:"30" min:"1"`
+
+	// 加载来源 (LoadWithOptions 填充, config/reload 按相同来源重新加载)
+	ConfigFile      string            // 实际读取的配置文件, 空 = 未使用
+	ConfigOverrides map[string]string // 命令行 --set 覆盖
 }
 
-// Load 从环境变量加载配置 (通过反射读取 struct tag)。
+// Load 分层加载配置 (配置文件 + 环境变量, 通过反射读取 struct tag)。
+//
+// 配置文件存在校验问题时记录日志并跳过出错的键; 需要严格失败的入口使用 LoadWithOptions。
 func Load() *Config {
-	var cfg Config
-	util.LoadFromEnv(&cfg)
-	return &cfg
+	cfg, _, err := LoadWithOptions(LoadOptions{})
+	if cfg == nil {
+		logger.Error("config: load config file failed, falling back to env", logger.FieldError, err)
+		cfg, _, _ = LoadWithOptions(LoadOptions{SkipFile: true})
+		return cfg
+	}
+	if err != nil {
+		logger.Warn("config: invalid config entries ignored", logger.FieldError, err)
+	}
+	return cfg
 }
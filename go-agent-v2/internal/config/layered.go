@@ -0,0 +1,567 @@
+// layered.go — 分层配置加载 (默认值 → config.yaml/toml → 环境变量 → 命令行覆盖) 与 schema 校验。
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/pelletier/go-toml/v2"
+
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+// 配置来源 (优先级由低到高)。
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// ConfigFileEnv 指定配置文件路径的环境变量 (未设置时按 DefaultConfigFiles 顺序查找)。
+const ConfigFileEnv = "CONFIG_FILE"
+
+// DefaultConfigFiles 未指定配置文件时在工作目录中依次查找的文件名。
+var DefaultConfigFiles = []string{"config.yaml", "config.yml", "config.toml"}
+
+// LoadOptions 分层加载选项: 默认值 → 配置文件 → 环境变量 → 命令行覆盖。
+type LoadOptions struct {
+	File      string            // 配置文件路径; 空 = $CONFIG_FILE 或 DefaultConfigFiles
+	Overrides map[string]string // 命令行覆盖 (--set KEY=VALUE), 键为环境变量名
+	SkipFile  bool              // 不读取配置文件 (仅默认值 + 环境变量 + 覆盖)
+}
+
+// Issue 单条配置校验问题。
+type Issue struct {
+	Key     string `json:"key"`
+	Source  string `json:"source"`
+	Message string `json:"message"`
+}
+
+// LoadReport 分层加载结果: 实际使用的文件、每个键的最终来源与校验问题。
+type LoadReport struct {
+	File    string            `json:"file,omitempty"`
+	Sources map[string]string `json:"sources"`
+	Issues  []Issue           `json:"issues"`
+}
+
+// ValidationError 配置文件或命令行覆盖未通过 schema 校验。
+type ValidationError struct {
+	Issues []Issue
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Issues))
+	for _, issue := range e.Issues {
+		parts = append(parts, fmt.Sprintf("%s (%s): %s", issue.Key, issue.Source, issue.Message))
+	}
+	return "invalid config: " + strings.Join(parts, "; ")
+}
+
+// fieldSpec Config 字段的 schema (由 struct tag 反射得到)。
+type fieldSpec struct {
+	Key        string
+	Index      int
+	Kind       reflect.Kind
+	Default    string
+	Min        string
+	Reloadable bool
+}
+
+func configFields() []fieldSpec {
+	t := reflect.TypeOf(Config{})
+	specs := make([]fieldSpec, 0, t.NumField())
+	for i := range t.NumField() {
+		field := t.Field(i)
+		key := field.Tag.Get("env")
+		if key == "" {
+			continue
+		}
+		specs = append(specs, fieldSpec{
+			Key:        key,
+			Index:      i,
+			Kind:       field.Type.Kind(),
+			Default:    field.Tag.Get("default"),
+			Min:        field.Tag.Get("min"),
+			Reloadable: field.Tag.Get("reload") == "true",
+		})
+	}
+	return specs
+}
+
+// ReloadableKeys 可运行时热更新的配置键 (struct tag reload:"true")。
+func ReloadableKeys() []string {
+	var keys []string
+	for _, spec := range configFields() {
+		if spec.Reloadable {
+			keys = append(keys, spec.Key)
+		}
+	}
+	return keys
+}
+
+// LoadWithOptions 分层加载配置。
+//
+// 配置文件与命令行覆盖按 schema 严格校验 (未知键、类型错误、低于 min 均报错,
+// 出错的键保留下层取值); 环境变量沿用 Load 的宽松语义 (非法值忽略, 低于 min 截断)。
+// 返回的 *Config 总是可用, 有校验问题时 error 为 *ValidationError。
+func LoadWithOptions(opts LoadOptions) (*Config, *LoadReport, error) {
+	report := &LoadReport{Sources: map[string]string{}, Issues: []Issue{}}
+	path, explicit := resolveConfigFile(opts.File)
+	var fileValues map[string]any
+	if path != "" && !opts.SkipFile {
+		values, err := ReadConfigFile(path)
+		switch {
+		case err == nil:
+			fileValues = values
+			report.File = path
+		case explicit || !errors.Is(err, fs.ErrNotExist):
+			return nil, report, err
+		}
+	}
+
+	var cfg Config
+	v := reflect.ValueOf(&cfg).Elem()
+	specs := configFields()
+	known := make(map[string]fieldSpec, len(specs))
+	for _, spec := range specs {
+		known[spec.Key] = spec
+		fv := v.Field(spec.Index)
+		_ = setFieldString(fv, spec.Kind, spec.Default)
+		report.Sources[spec.Key] = SourceDefault
+
+		if raw, ok := fileValues[spec.Key]; ok {
+			if msg := applyFileValue(fv, spec, raw); msg != "" {
+				report.Issues = append(report.Issues, Issue{Key: spec.Key, Source: SourceFile, Message: msg})
+			} else {
+				report.Sources[spec.Key] = SourceFile
+			}
+		}
+		if raw := os.Getenv(spec.Key); raw != "" && applyEnvValue(fv, spec, raw) {
+			report.Sources[spec.Key] = SourceEnv
+		}
+		if raw, ok := opts.Overrides[spec.Key]; ok {
+			if msg := applyStrictString(fv, spec, raw); msg != "" {
+				report.Issues = append(report.Issues, Issue{Key: spec.Key, Source: SourceFlag, Message: msg})
+			} else {
+				report.Sources[spec.Key] = SourceFlag
+			}
+		}
+	}
+	for key := range fileValues {
+		if _, ok := known[key]; !ok {
+			report.Issues = append(report.Issues, Issue{Key: key, Source: SourceFile, Message: "unknown key"})
+		}
+	}
+	for key := range opts.Overrides {
+		if _, ok := known[key]; !ok {
+			report.Issues = append(report.Issues, Issue{Key: key, Source: SourceFlag, Message: "unknown key"})
+		}
+	}
+	sort.SliceStable(report.Issues, func(i, j int) bool { return report.Issues[i].Key < report.Issues[j].Key })
+
+	cfg.ConfigFile = report.File
+	cfg.ConfigOverrides = opts.Overrides
+	if len(report.Issues) > 0 {
+		return &cfg, report, &ValidationError{Issues: report.Issues}
+	}
+	return &cfg, report, nil
+}
+
+// ValidateFile 按 schema 校验配置文件内容 (不读取环境变量)。
+func ValidateFile(path string) ([]Issue, error) {
+	values, err := ReadConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return validateValues(values), nil
+}
+
+// ValidateContent 按 schema 校验配置文本; format 为 yaml 或 toml。
+func ValidateContent(content []byte, format string) ([]Issue, error) {
+	values, err := decodeConfig(content, format)
+	if err != nil {
+		return nil, err
+	}
+	return validateValues(values), nil
+}
+
+func validateValues(values map[string]any) []Issue {
+	issues := []Issue{}
+	specs := configFields()
+	known := make(map[string]struct{}, len(specs))
+	var scratch Config
+	v := reflect.ValueOf(&scratch).Elem()
+	for _, spec := range specs {
+		known[spec.Key] = struct{}{}
+		raw, ok := values[spec.Key]
+		if !ok {
+			continue
+		}
+		if msg := applyFileValue(v.Field(spec.Index), spec, raw); msg != "" {
+			issues = append(issues, Issue{Key: spec.Key, Source: SourceFile, Message: msg})
+		}
+	}
+	for key := range values {
+		if _, ok := known[key]; !ok {
+			issues = append(issues, Issue{Key: key, Source: SourceFile, Message: "unknown key"})
+		}
+	}
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].Key < issues[j].Key })
+	return issues
+}
+
+// resolveConfigFile 确定配置文件路径; explicit 表示由调用方或 $CONFIG_FILE 指定 (缺失即报错)。
+func resolveConfigFile(file string) (path string, explicit bool) {
+	if file = strings.TrimSpace(file); file != "" {
+		return file, true
+	}
+	if env := strings.TrimSpace(os.Getenv(ConfigFileEnv)); env != "" {
+		return env, true
+	}
+	for _, name := range DefaultConfigFiles {
+		if _, err := os.Stat(name); err == nil {
+			return name, false
+		}
+	}
+	return "", false
+}
+
+// ReadConfigFile 读取 YAML / TOML 配置文件并展平为 {ENV_KEY: value}。
+//
+// 键不区分大小写, 嵌套表以下划线拼接: stall: {threshold_sec: 600} 等价于 STALL_THRESHOLD_SEC: 600。
+func ReadConfigFile(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, apperrors.Wrap(err, "Config.ReadFile", "read config file")
+	}
+	format := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	return decodeConfig(data, format)
+}
+
+func decodeConfig(data []byte, format string) (map[string]any, error) {
+	raw := map[string]any{}
+	switch format {
+	case "yaml", "yml":
+		if len(bytes.TrimSpace(data)) > 0 {
+			if err := yaml.Unmarshal(data, &raw); err != nil {
+				return nil, apperrors.Wrap(err, "Config.Decode", "parse yaml")
+			}
+		}
+	case "toml":
+		if err := toml.Unmarshal(data, &raw); err != nil {
+			return nil, apperrors.Wrap(err, "Config.Decode", "parse toml")
+		}
+	default:
+		return nil, apperrors.Newf("Config.Decode", "unsupported config format %q (want yaml or toml)", format)
+	}
+	flat := map[string]any{}
+	flattenConfig("", raw, flat)
+	return flat, nil
+}
+
+func flattenConfig(prefix string, in map[string]any, out map[string]any) {
+	for key, value := range in {
+		name := strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(key), "-", "_"))
+		if prefix != "" {
+			name = prefix + "_" + name
+		}
+		if nested, ok := value.(map[string]any); ok {
+			flattenConfig(name, nested, out)
+			continue
+		}
+		out[name] = value
+	}
+}
+
+// applyFileValue 严格写入配置文件取值, 返回问题描述 (空 = 成功)。
+func applyFileValue(fv reflect.Value, spec fieldSpec, raw any) string {
+	switch val := raw.(type) {
+	case nil:
+		return "value is null"
+	case string:
+		return applyStrictString(fv, spec, val)
+	case bool:
+		if spec.Kind == reflect.Bool || spec.Kind == reflect.String {
+			return applyStrictString(fv, spec, strconv.FormatBool(val))
+		}
+		return fmt.Sprintf("expected %s, got bool", spec.Kind)
+	case int, int64, uint64, float64:
+		var text string
+		switch n := val.(type) {
+		case float64:
+			if spec.Kind == reflect.Int && n != math.Trunc(n) {
+				return fmt.Sprintf("expected integer, got %v", n)
+			}
+			text = strconv.FormatFloat(n, 'f', -1, 64)
+		default:
+			text = fmt.Sprint(n)
+		}
+		if spec.Kind == reflect.Bool {
+			return fmt.Sprintf("expected bool, got number %s", text)
+		}
+		return applyStrictString(fv, spec, text)
+	default:
+		return fmt.Sprintf("expected %s, got %T", spec.Kind, raw)
+	}
+}
+
+// applyStrictString 解析字符串并校验 min, 返回问题描述 (空 = 成功)。
+func applyStrictString(fv reflect.Value, spec fieldSpec, raw string) string {
+	raw = strings.TrimSpace(raw)
+	switch spec.Kind {
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Sprintf("expected integer, got %q", raw)
+		}
+		if minVal, err := strconv.Atoi(spec.Min); err == nil && n < minVal {
+			return fmt.Sprintf("must be >= %d, got %d", minVal, n)
+		}
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Sprintf("expected number, got %q", raw)
+		}
+		if minVal, err := strconv.ParseFloat(spec.Min, 64); err == nil && f < minVal {
+			return fmt.Sprintf("must be >= %v, got %v", minVal, f)
+		}
+	case reflect.Bool:
+		if _, ok := parseBool(raw); !ok {
+			return fmt.Sprintf("expected bool, got %q", raw)
+		}
+	}
+	if err := setFieldString(fv, spec.Kind, raw); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// applyEnvValue 宽松写入环境变量 (与 util.LoadFromEnv 一致: 非法值忽略, 低于 min 截断)。
+func applyEnvValue(fv reflect.Value, spec fieldSpec, raw string) bool {
+	switch spec.Kind {
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return false
+		}
+		if minVal, err := strconv.Atoi(spec.Min); err == nil && n < minVal {
+			n = minVal
+		}
+		fv.SetInt(int64(n))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return false
+		}
+		if minVal, err := strconv.ParseFloat(spec.Min, 64); err == nil && f < minVal {
+			f = minVal
+		}
+		fv.SetFloat(f)
+	case reflect.Bool:
+		b, ok := parseBool(raw)
+		if !ok {
+			return false
+		}
+		fv.SetBool(b)
+	case reflect.String:
+		fv.SetString(raw)
+	default:
+		return false
+	}
+	return true
+}
+
+func setFieldString(fv reflect.Value, kind reflect.Kind, raw string) error {
+	switch kind {
+	case reflect.String:
+		fv.SetString(raw)
+	case reflect.Int:
+		n, _ := strconv.Atoi(raw)
+		fv.SetInt(int64(n))
+	case reflect.Float64:
+		f, _ := strconv.ParseFloat(raw, 64)
+		fv.SetFloat(f)
+	case reflect.Bool:
+		b, _ := parseBool(raw)
+		fv.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported field kind %s", kind)
+	}
+	return nil
+}
+
+func parseBool(raw string) (bool, bool) {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "1", "true", "yes", "on":
+		return true, true
+	case "0", "false", "no", "off":
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+// Change 两份配置间单个键的差异。
+type Change struct {
+	Key        string `json:"key"`
+	Old        any    `json:"old"`
+	New        any    `json:"new"`
+	Reloadable bool   `json:"reloadable"`
+}
+
+// Diff 比较两份配置, 返回取值不同的键 (按键名排序)。
+func Diff(prev, next *Config) []Change {
+	pv := reflect.ValueOf(prev).Elem()
+	nv := reflect.ValueOf(next).Elem()
+	var changes []Change
+	for _, spec := range configFields() {
+		a, b := pv.Field(spec.Index).Interface(), nv.Field(spec.Index).Interface()
+		if a == b {
+			continue
+		}
+		changes = append(changes, Change{Key: spec.Key, Old: a, New: b, Reloadable: spec.Reloadable})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
+
+// ApplyReloadable 将 next 中可热更新的键写入 dst, 返回实际应用的键。
+func ApplyReloadable(dst, next *Config) []string {
+	dv := reflect.ValueOf(dst).Elem()
+	nv := reflect.ValueOf(next).Elem()
+	var applied []string
+	for _, spec := range configFields() {
+		if !spec.Reloadable {
+			continue
+		}
+		if dv.Field(spec.Index).Interface() == nv.Field(spec.Index).Interface() {
+			continue
+		}
+		dv.Field(spec.Index).Set(nv.Field(spec.Index))
+		applied = append(applied, spec.Key)
+	}
+	return applied
+}
+
+// 漂移类型 (Drift.Kind)。
+const (
+	DriftOverridden = "overridden" // 配置文件声明了该键, 运行值不同 (被环境变量 / 命令行 / 运行时写入覆盖)
+	DriftUndeclared = "undeclared" // 配置文件未声明, 运行值偏离默认值
+	DriftInvalid    = "invalid"    // 配置文件取值未通过校验, 运行时被忽略
+	DriftPending    = "pending"    // 进程环境变量已改写 (config/value/write), 尚未 reload 或需重启生效
+)
+
+// Drift 运行配置与声明配置间单个键的差异。
+type Drift struct {
+	Key        string `json:"key"`
+	Kind       string `json:"kind"`
+	Declared   any    `json:"declared,omitempty"` // 配置文件中的取值 (未声明时省略)
+	Running    any    `json:"running"`
+	Default    any    `json:"default"`
+	Source     string `json:"source"` // 运行值来源: default / file / env / flag
+	Reloadable bool   `json:"reloadable"`
+	Message    string `json:"message,omitempty"`
+}
+
+// CompareWithFile 比较运行配置与配置文件声明 (values 为 ReadConfigFile 结果), 按键名排序。
+//
+// includeUndeclared 时同时报告文件未声明、但运行值偏离默认值的键。
+func CompareWithFile(running *Config, values map[string]any, includeUndeclared bool) []Drift {
+	rv := reflect.ValueOf(running).Elem()
+	var declared, defaults Config
+	dv := reflect.ValueOf(&declared).Elem()
+	defv := reflect.ValueOf(&defaults).Elem()
+	var drifts []Drift
+	for _, spec := range configFields() {
+		_ = setFieldString(defv.Field(spec.Index), spec.Kind, spec.Default)
+		run, def := rv.Field(spec.Index).Interface(), defv.Field(spec.Index).Interface()
+		d := Drift{
+			Key:        spec.Key,
+			Running:    run,
+			Default:    def,
+			Source:     runningSource(running, spec.Key, values),
+			Reloadable: spec.Reloadable,
+		}
+		raw, isDeclared := values[spec.Key]
+		switch {
+		case isDeclared:
+			d.Declared = raw
+			if msg := applyFileValue(dv.Field(spec.Index), spec, raw); msg != "" {
+				d.Kind, d.Message = DriftInvalid, msg
+			} else if want := dv.Field(spec.Index).Interface(); want != run {
+				d.Kind, d.Declared = DriftOverridden, want
+			} else {
+				continue
+			}
+		case includeUndeclared && run != def:
+			d.Kind = DriftUndeclared
+		default:
+			continue
+		}
+		drifts = append(drifts, d)
+	}
+	return drifts
+}
+
+// PendingEnv 返回进程环境变量当前取值与运行配置不一致的键 (环境变量在加载后被改写)。
+//
+// 命令行覆盖的键优先级高于环境变量, 不计入。
+func PendingEnv(running *Config) []Drift {
+	rv := reflect.ValueOf(running).Elem()
+	var scratch Config
+	sv := reflect.ValueOf(&scratch).Elem()
+	var drifts []Drift
+	for _, spec := range configFields() {
+		raw := os.Getenv(spec.Key)
+		if raw == "" {
+			continue
+		}
+		if _, ok := running.ConfigOverrides[spec.Key]; ok {
+			continue
+		}
+		fv := sv.Field(spec.Index)
+		if !applyEnvValue(fv, spec, raw) {
+			continue
+		}
+		if env, run := fv.Interface(), rv.Field(spec.Index).Interface(); env != run {
+			var def Config
+			defField := reflect.ValueOf(&def).Elem().Field(spec.Index)
+			_ = setFieldString(defField, spec.Kind, spec.Default)
+			drifts = append(drifts, Drift{
+				Key:        spec.Key,
+				Kind:       DriftPending,
+				Declared:   env,
+				Running:    run,
+				Default:    defField.Interface(),
+				Source:     SourceEnv,
+				Reloadable: spec.Reloadable,
+			})
+		}
+	}
+	return drifts
+}
+
+// runningSource 推断运行值来源 (与 LoadWithOptions 的优先级一致)。
+func runningSource(running *Config, key string, values map[string]any) string {
+	if _, ok := running.ConfigOverrides[key]; ok {
+		return SourceFlag
+	}
+	if os.Getenv(key) != "" {
+		return SourceEnv
+	}
+	if _, ok := values[key]; ok {
+		return SourceFile
+	}
+	return SourceDefault
+}
@@ -0,0 +1,180 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	return path
+}
+
+func TestLoadWithOptionsLayerPrecedence(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+log_level: DEBUG
+stall:
+  threshold_sec: 600
+  heartbeat_sec: 60
+LLM_MODEL: from-file
+`)
+	t.Setenv("STALL_HEARTBEAT_SEC", "90")
+	t.Setenv("LLM_MODEL", "from-env")
+
+	cfg, report, err := LoadWithOptions(LoadOptions{File: path, Overrides: map[string]string{"LLM_MODEL": "from-flag"}})
+	if err != nil {
+		t.Fatalf("LoadWithOptions: %v", err)
+	}
+	if cfg.LogLevel != "DEBUG" || cfg.StallThresholdSec != 600 || cfg.StallHeartbeatSec != 90 || cfg.LLMModel != "from-flag" {
+		t.Fatalf("cfg = log=%s stall=%d hb=%d model=%s", cfg.LogLevel, cfg.StallThresholdSec, cfg.StallHeartbeatSec, cfg.LLMModel)
+	}
+	if cfg.GatewayTimeout != 240 {
+		t.Fatalf("default GatewayTimeout = %d, want 240", cfg.GatewayTimeout)
+	}
+	want := map[string]string{
+		"LOG_LEVEL":           SourceFile,
+		"STALL_THRESHOLD_SEC": SourceFile,
+		"STALL_HEARTBEAT_SEC": SourceEnv,
+		"LLM_MODEL":           SourceFlag,
+		"GATEWAY_TIMEOUT":     SourceDefault,
+	}
+	for key, source := range want {
+		if got := report.Sources[key]; got != source {
+			t.Errorf("source[%s] = %s, want %s", key, got, source)
+		}
+	}
+	if cfg.ConfigFile != path {
+		t.Fatalf("ConfigFile = %q, want %q", cfg.ConfigFile, path)
+	}
+}
+
+func TestLoadWithOptionsTOML(t *testing.T) {
+	path := writeConfigFile(t, "config.toml", `
+LOG_LEVEL = "WARN"
+
+[workspace_watch]
+enabled = false
+debounce_ms = 500
+`)
+	cfg, _, err := LoadWithOptions(LoadOptions{File: path})
+	if err != nil {
+		t.Fatalf("LoadWithOptions: %v", err)
+	}
+	if cfg.LogLevel != "WARN" || cfg.WorkspaceWatchEnabled || cfg.WorkspaceWatchDebounceMS != 500 {
+		t.Fatalf("cfg = log=%s watch=%v debounce=%d", cfg.LogLevel, cfg.WorkspaceWatchEnabled, cfg.WorkspaceWatchDebounceMS)
+	}
+}
+
+func TestLoadWithOptionsSchemaValidation(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+STALL_THRESHOLD_SEC: 5
+GATEWAY_TIMEOUT: "soon"
+MEMORY_ENABLED: 3
+NO_SUCH_KEY: 1
+`)
+	cfg, report, err := LoadWithOptions(LoadOptions{File: path, Overrides: map[string]string{"LLM_TIMEOUT": "x"}})
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("err = %v, want ValidationError", err)
+	}
+	got := map[string]string{}
+	for _, issue := range report.Issues {
+		got[issue.Key] = issue.Source
+	}
+	for _, key := range []string{"STALL_THRESHOLD_SEC", "GATEWAY_TIMEOUT", "MEMORY_ENABLED", "NO_SUCH_KEY", "LLM_TIMEOUT"} {
+		if _, ok := got[key]; !ok {
+			t.Errorf("missing issue for %s (issues=%+v)", key, report.Issues)
+		}
+	}
+	// 出错的键保留默认值。
+	if cfg.StallThresholdSec != 480 || cfg.GatewayTimeout != 240 || cfg.LLMTimeout != 120 {
+		t.Fatalf("invalid keys must keep defaults: stall=%d gateway=%d llm=%d", cfg.StallThresholdSec, cfg.GatewayTimeout, cfg.LLMTimeout)
+	}
+}
+
+func TestLoadWithOptionsMissingExplicitFile(t *testing.T) {
+	if _, _, err := LoadWithOptions(LoadOptions{File: filepath.Join(t.TempDir(), "missing.yaml")}); err == nil {
+		t.Fatal("explicit missing config file must fail")
+	}
+}
+
+func TestDiffAndApplyReloadable(t *testing.T) {
+	prev, _, _ := LoadWithOptions(LoadOptions{SkipFile: true})
+	next, _, _ := LoadWithOptions(LoadOptions{SkipFile: true, Overrides: map[string]string{
+		"LOG_LEVEL":           "DEBUG",
+		"STALL_THRESHOLD_SEC": "900",
+		"LLM_MODEL":           "o3",
+	}})
+	changes := Diff(prev, next)
+	if len(changes) != 3 {
+		t.Fatalf("changes = %+v", changes)
+	}
+	applied := ApplyReloadable(prev, next)
+	if len(applied) != 2 || prev.LogLevel != "DEBUG" || prev.StallThresholdSec != 900 {
+		t.Fatalf("applied = %v, prev log=%s stall=%d", applied, prev.LogLevel, prev.StallThresholdSec)
+	}
+	if prev.LLMModel == "o3" {
+		t.Fatal("non-reloadable LLM_MODEL must not be applied")
+	}
+}
+
+func TestCompareWithFileReportsDrift(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+log_level: DEBUG
+stall:
+  threshold_sec: 600
+LLM_MODEL: from-file
+GATEWAY_TIMEOUT: 0
+`)
+	t.Setenv("LLM_MODEL", "from-env")
+	t.Setenv("STALL_HEARTBEAT_SEC", "90")
+	// 运行实例未加载该文件 (例如另一台机器上的声明配置), 仅日志级别在运行时被写成一致。
+	cfg, _, err := LoadWithOptions(LoadOptions{Overrides: map[string]string{"STALL_THRESHOLD_SEC": "700"}})
+	if err != nil {
+		t.Fatalf("LoadWithOptions: %v", err)
+	}
+	cfg.LogLevel = "DEBUG"
+	values, err := ReadConfigFile(path)
+	if err != nil {
+		t.Fatalf("ReadConfigFile: %v", err)
+	}
+
+	got := map[string]Drift{}
+	for _, d := range CompareWithFile(cfg, values, true) {
+		got[d.Key] = d
+	}
+	if _, ok := got["LOG_LEVEL"]; ok {
+		t.Error("LOG_LEVEL matches the file and should not drift")
+	}
+	if d := got["LLM_MODEL"]; d.Kind != DriftOverridden || d.Source != SourceEnv || d.Declared != "from-file" || d.Running != "from-env" {
+		t.Errorf("LLM_MODEL drift = %+v", d)
+	}
+	if d := got["STALL_THRESHOLD_SEC"]; d.Kind != DriftOverridden || d.Source != SourceFlag || d.Declared != 600 || d.Running != 700 || !d.Reloadable {
+		t.Errorf("STALL_THRESHOLD_SEC drift = %+v", d)
+	}
+	if d := got["GATEWAY_TIMEOUT"]; d.Kind != DriftInvalid || d.Message == "" {
+		t.Errorf("GATEWAY_TIMEOUT drift = %+v", d)
+	}
+	if d := got["STALL_HEARTBEAT_SEC"]; d.Kind != DriftUndeclared || d.Source != SourceEnv {
+		t.Errorf("STALL_HEARTBEAT_SEC drift = %+v", d)
+	}
+	if len(CompareWithFile(cfg, values, false)) != 3 {
+		t.Errorf("undeclared keys should be omitted by default")
+	}
+
+	if pending := PendingEnv(cfg); len(pending) != 0 {
+		t.Fatalf("PendingEnv = %+v, want none", pending)
+	}
+	t.Setenv("LLM_MODEL", "rewritten")
+	t.Setenv("STALL_THRESHOLD_SEC", "999") // 命令行覆盖优先, 不算待生效
+	pending := PendingEnv(cfg)
+	if len(pending) != 1 || pending[0].Key != "LLM_MODEL" || pending[0].Declared != "rewritten" {
+		t.Fatalf("PendingEnv = %+v", pending)
+	}
+}
@@ -2,100 +2,396 @@ package database
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
 	"github.com/multi-agent/go-agent-v2/pkg/logger"
 )
 
-// Migrate 执行 migrations 目录下的 SQL 迁移脚本 (按文件名排序)。
-// 使用 schema_version 表追踪已执行版本。
+// 迁移文件命名:
+//
+//	NNNN_name.sql 或 NNNN_name.up.sql   — 升级脚本 (二者不可同时存在)
+//	NNNN_name.down.sql                  — 回滚脚本 (可选, 缺失时该版本不可回滚)
+//
+// 版本号为去掉后缀的文件名 (如 0017_agent_memories), 按字典序执行。
+// 已执行版本记录在 schema_migrations (含 up 脚本 sha256), 同时同步旧表 schema_version
+// 以兼容旧版本二进制; 首次运行时自动从 schema_version 导入历史记录。
+const (
+	migrationUpSuffix   = ".up.sql"
+	migrationDownSuffix = ".down.sql"
+	migrationSuffix     = ".sql"
+
+	// migrationLockKey 迁移事务级 advisory lock, 多实例同时启动时串行执行。
+	migrationLockKey int64 = 0x6d6967726174
+)
+
+// 迁移状态 (MigrationStatus.State)。
+const (
+	MigrationApplied  = "applied"
+	MigrationPending  = "pending"
+	MigrationModified = "modified" // 已执行, 但文件内容与记录的校验和不一致
+	MigrationMissing  = "missing"  // 已执行, 但迁移文件已不存在
+)
+
+// Migration 单个迁移版本。
+type Migration struct {
+	Version  string `json:"version"`
+	UpFile   string `json:"upFile"`
+	DownFile string `json:"downFile,omitempty"`
+	Checksum string `json:"checksum"`
+
+	upSQL   string
+	downSQL string
+}
+
+// MigrationStatus 单个版本的执行状态。
+type MigrationStatus struct {
+	Version         string     `json:"version"`
+	State           string     `json:"state"`
+	AppliedAt       *time.Time `json:"appliedAt,omitempty"`
+	Checksum        string     `json:"checksum,omitempty"`
+	AppliedChecksum string     `json:"appliedChecksum,omitempty"`
+	Reversible      bool       `json:"reversible"`
+}
+
+// MigrateOptions 迁移选项。
+type MigrateOptions struct {
+	DryRun bool // 只计算计划, 不执行
+	Strict bool // 已执行迁移被修改时报错 (否则仅告警)
+	Steps  int  // MigrateDown 回滚的版本数 (≤ 0 按 1 处理)
+}
+
+// MigrateResult 迁移结果 (DryRun 时为计划执行的版本)。
+type MigrateResult struct {
+	Applied  []string `json:"applied"`
+	Reverted []string `json:"reverted"`
+	Modified []string `json:"modified"`
+	DryRun   bool     `json:"dryRun"`
+}
+
+type appliedMigration struct {
+	Version   string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+// Migrate 执行 migrations 目录下未应用的升级脚本 (启动时调用, 校验和不一致仅告警)。
 // 对应 Python db/migrator.py。
 func Migrate(ctx context.Context, pool *pgxpool.Pool, migrationsDir string) error {
-	if pool == nil {
-		return apperrors.New("Migrate", "pool is required")
-	}
+	_, err := MigrateUp(ctx, pool, migrationsDir, MigrateOptions{})
+	return err
+}
 
-	// 确保 schema_version 表存在
-	_, err := pool.Exec(ctx, `
-		CREATE TABLE IF NOT EXISTS schema_version (
-			version TEXT PRIMARY KEY,
-			applied_at TIMESTAMPTZ DEFAULT NOW()
-		)
-	`)
+// LoadMigrations 读取迁移目录, 按版本排序; 目录不存在时返回空列表。
+func LoadMigrations(migrationsDir string) ([]Migration, error) {
+	entries, err := os.ReadDir(migrationsDir)
 	if err != nil {
-		logger.Error("migrate: create schema_version table failed", logger.FieldError, err)
-		return apperrors.Wrap(err, "Migrate", "create schema_version table")
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, apperrors.Wrap(err, "Migrate", "read migrations dir")
+	}
+	byVersion := map[string]*Migration{}
+	get := func(version string) *Migration {
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version}
+			byVersion[version] = m
+		}
+		return m
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, migrationSuffix) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(migrationsDir, name))
+		if err != nil {
+			return nil, apperrors.Wrapf(err, "Migrate", "read migration %s", name)
+		}
+		switch {
+		case strings.HasSuffix(name, migrationDownSuffix):
+			m := get(strings.TrimSuffix(name, migrationDownSuffix))
+			m.DownFile, m.downSQL = name, string(data)
+		default:
+			version := strings.TrimSuffix(strings.TrimSuffix(name, migrationUpSuffix), migrationSuffix)
+			m := get(version)
+			if m.UpFile != "" {
+				return nil, apperrors.Newf("Migrate", "duplicate up migration for %s: %s and %s", version, m.UpFile, name)
+			}
+			m.UpFile, m.upSQL = name, string(data)
+			m.Checksum = migrationChecksum(data)
+		}
 	}
+	out := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.UpFile == "" {
+			return nil, apperrors.Newf("Migrate", "down migration %s has no matching up migration", m.DownFile)
+		}
+		out = append(out, *m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
 
-	// 读取迁移文件
-	entries, err := os.ReadDir(migrationsDir)
+func migrationChecksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// migrationVersion 兼容旧 schema_version 记录 (完整文件名)。
+func migrationVersion(recorded string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(recorded, migrationUpSuffix), migrationSuffix)
+}
+
+// planUp 计算待执行版本与已被修改的版本。空校验和 (从旧表导入) 视为一致。
+func planUp(migrations []Migration, applied map[string]appliedMigration) (pending []Migration, modified []string) {
+	for _, m := range migrations {
+		rec, ok := applied[m.Version]
+		if !ok {
+			pending = append(pending, m)
+			continue
+		}
+		if rec.Checksum != "" && rec.Checksum != m.Checksum {
+			modified = append(modified, m.Version)
+		}
+	}
+	return pending, modified
+}
+
+// planDown 按执行顺序倒序选出最近 steps 个已执行版本; 缺少回滚脚本时报错。
+func planDown(migrations []Migration, applied map[string]appliedMigration, steps int) ([]Migration, error) {
+	if steps <= 0 {
+		steps = 1
+	}
+	byVersion := make(map[string]Migration, len(migrations))
+	for _, m := range migrations {
+		byVersion[m.Version] = m
+	}
+	versions := make([]string, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(versions)))
+
+	var plan []Migration
+	for _, version := range versions {
+		if len(plan) == steps {
+			break
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			return nil, apperrors.Newf("Migrate", "cannot revert %s: migration file missing", version)
+		}
+		if m.DownFile == "" {
+			return nil, apperrors.Newf("Migrate", "cannot revert %s: no %s file", version, version+migrationDownSuffix)
+		}
+		plan = append(plan, m)
+	}
+	return plan, nil
+}
+
+// MigrateUp 执行未应用的升级脚本。
+func MigrateUp(ctx context.Context, pool *pgxpool.Pool, migrationsDir string, opts MigrateOptions) (MigrateResult, error) {
+	result := MigrateResult{Applied: []string{}, Reverted: []string{}, Modified: []string{}, DryRun: opts.DryRun}
+	if pool == nil {
+		return result, apperrors.New("Migrate", "pool is required")
+	}
+	migrations, err := LoadMigrations(migrationsDir)
 	if err != nil {
-		if os.IsNotExist(err) {
-			logger.Info("no migrations directory found, skipping")
-			return nil
+		return result, err
+	}
+	if migrations == nil {
+		logger.Info("no migrations directory found, skipping")
+		return result, nil
+	}
+	if err := ensureMigrationTables(ctx, pool); err != nil {
+		return result, err
+	}
+	applied, err := loadAppliedVersions(ctx, pool)
+	if err != nil {
+		return result, err
+	}
+	pending, modified := planUp(migrations, applied)
+	result.Modified = append(result.Modified, modified...)
+	if len(modified) > 0 {
+		if opts.Strict {
+			return result, apperrors.Newf("Migrate", "applied migrations modified: %s", strings.Join(modified, ", "))
 		}
-		return apperrors.Wrap(err, "Migrate", "read migrations dir")
+		logger.Warn("migrate: applied migrations modified since execution", "versions", modified)
+	}
+	if err := backfillChecksums(ctx, pool, migrations, applied); err != nil {
+		return result, err
 	}
 
-	// 过滤并排序 .sql 文件
-	var sqlFiles []string
-	for _, e := range entries {
-		if !e.IsDir() && strings.HasSuffix(e.Name(), ".sql") {
-			sqlFiles = append(sqlFiles, e.Name())
+	if len(pending) > 0 {
+		logger.Info("migrate: applying pending migrations", logger.FieldCount, len(pending), "dry_run", opts.DryRun)
+	}
+	for _, m := range pending {
+		if !opts.DryRun {
+			if err := applyMigration(ctx, pool, m); err != nil {
+				return result, err
+			}
+			logger.Info("migrate: migration applied", logger.FieldVersion, m.Version)
 		}
+		result.Applied = append(result.Applied, m.Version)
 	}
-	sort.Strings(sqlFiles)
+	return result, nil
+}
 
+// MigrateDown 回滚最近 opts.Steps 个已执行版本 (倒序执行 .down.sql)。
+func MigrateDown(ctx context.Context, pool *pgxpool.Pool, migrationsDir string, opts MigrateOptions) (MigrateResult, error) {
+	result := MigrateResult{Applied: []string{}, Reverted: []string{}, Modified: []string{}, DryRun: opts.DryRun}
+	if pool == nil {
+		return result, apperrors.New("Migrate", "pool is required")
+	}
+	migrations, err := LoadMigrations(migrationsDir)
+	if err != nil {
+		return result, err
+	}
+	if err := ensureMigrationTables(ctx, pool); err != nil {
+		return result, err
+	}
 	applied, err := loadAppliedVersions(ctx, pool)
 	if err != nil {
-		return err
+		return result, err
+	}
+	plan, err := planDown(migrations, applied, opts.Steps)
+	if err != nil {
+		return result, err
+	}
+	for _, m := range plan {
+		if !opts.DryRun {
+			if err := revertMigration(ctx, pool, m); err != nil {
+				return result, err
+			}
+			logger.Warn("migrate: migration reverted", logger.FieldVersion, m.Version)
+		}
+		result.Reverted = append(result.Reverted, m.Version)
+	}
+	return result, nil
+}
+
+// MigrationStatuses 返回迁移文件与执行记录的对照 (含文件已删除的已执行版本)。
+func MigrationStatuses(ctx context.Context, pool *pgxpool.Pool, migrationsDir string) ([]MigrationStatus, error) {
+	if pool == nil {
+		return nil, apperrors.New("Migrate", "pool is required")
 	}
+	migrations, err := LoadMigrations(migrationsDir)
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureMigrationTables(ctx, pool); err != nil {
+		return nil, err
+	}
+	applied, err := loadAppliedVersions(ctx, pool)
+	if err != nil {
+		return nil, err
+	}
+	return buildMigrationStatuses(migrations, applied), nil
+}
 
-	// 执行未应用的迁移
-	pending := countPendingMigrations(sqlFiles, applied)
-	if pending > 0 {
-		logger.Info("migrate: applying pending migrations", logger.FieldCount, pending)
+func buildMigrationStatuses(migrations []Migration, applied map[string]appliedMigration) []MigrationStatus {
+	out := make([]MigrationStatus, 0, len(migrations))
+	seen := make(map[string]bool, len(migrations))
+	for _, m := range migrations {
+		seen[m.Version] = true
+		st := MigrationStatus{Version: m.Version, State: MigrationPending, Checksum: m.Checksum, Reversible: m.DownFile != ""}
+		if rec, ok := applied[m.Version]; ok {
+			at := rec.AppliedAt
+			st.AppliedAt = &at
+			st.AppliedChecksum = rec.Checksum
+			st.State = MigrationApplied
+			if rec.Checksum != "" && rec.Checksum != m.Checksum {
+				st.State = MigrationModified
+			}
+		}
+		out = append(out, st)
 	}
-	for _, name := range sqlFiles {
-		if applied[name] {
+	for version, rec := range applied {
+		if seen[version] {
 			continue
 		}
-		if err := applyOneMigration(ctx, pool, migrationsDir, name); err != nil {
-			return err
-		}
-		logger.Info("migrate: migration applied", logger.FieldVersion, name)
+		at := rec.AppliedAt
+		out = append(out, MigrationStatus{Version: version, State: MigrationMissing, AppliedAt: &at, AppliedChecksum: rec.Checksum})
 	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out
+}
 
+// ensureMigrationTables 创建 schema_migrations 并从旧表 schema_version 导入历史记录。
+func ensureMigrationTables(ctx context.Context, pool *pgxpool.Pool) error {
+	if pool == nil {
+		return apperrors.New("Migrate", "pool is required")
+	}
+	_, err := pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_version (
+			version TEXT PRIMARY KEY,
+			applied_at TIMESTAMPTZ DEFAULT NOW()
+		);
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version TEXT PRIMARY KEY,
+			checksum TEXT NOT NULL DEFAULT '',
+			execution_ms BIGINT NOT NULL DEFAULT 0,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+		INSERT INTO schema_migrations (version, applied_at)
+		SELECT regexp_replace(regexp_replace(version, '\.sql$', ''), '\.up$', ''), COALESCE(applied_at, NOW())
+		FROM schema_version
+		ON CONFLICT (version) DO NOTHING
+	`)
+	if err != nil {
+		logger.Error("migrate: create schema_migrations table failed", logger.FieldError, err)
+		return apperrors.Wrap(err, "Migrate", "create schema_migrations table")
+	}
 	return nil
 }
 
-func loadAppliedVersions(ctx context.Context, pool *pgxpool.Pool) (map[string]bool, error) {
+func loadAppliedVersions(ctx context.Context, pool *pgxpool.Pool) (map[string]appliedMigration, error) {
 	if pool == nil {
 		return nil, apperrors.New("Migrate", "pool is required")
 	}
-	rows, err := pool.Query(ctx, `SELECT version FROM schema_version`)
+	rows, err := pool.Query(ctx, `SELECT version, checksum, applied_at FROM schema_migrations`)
 	if err != nil {
-		return nil, apperrors.Wrap(err, "Migrate", "query schema_version")
+		return nil, apperrors.Wrap(err, "Migrate", "query schema_migrations")
 	}
 	defer rows.Close()
 
-	applied := make(map[string]bool)
+	applied := make(map[string]appliedMigration)
 	for rows.Next() {
-		var version string
-		if err := rows.Scan(&version); err != nil {
-			return nil, apperrors.Wrap(err, "Migrate", "scan schema_version")
+		var rec appliedMigration
+		if err := rows.Scan(&rec.Version, &rec.Checksum, &rec.AppliedAt); err != nil {
+			return nil, apperrors.Wrap(err, "Migrate", "scan schema_migrations")
 		}
-		applied[version] = true
+		applied[rec.Version] = rec
 	}
-	return applied, nil
+	return applied, rows.Err()
 }
 
+// backfillChecksums 为从旧表导入的记录补写校验和。
+func backfillChecksums(ctx context.Context, pool *pgxpool.Pool, migrations []Migration, applied map[string]appliedMigration) error {
+	for _, m := range migrations {
+		rec, ok := applied[m.Version]
+		if !ok || rec.Checksum != "" {
+			continue
+		}
+		if _, err := pool.Exec(ctx, `UPDATE schema_migrations SET checksum = $2 WHERE version = $1 AND checksum = ''`, m.Version, m.Checksum); err != nil {
+			return apperrors.Wrapf(err, "Migrate", "backfill checksum %s", m.Version)
+		}
+	}
+	return nil
+}
+
+// applyOneMigration 读取并执行单个迁移文件 (name 为 up 文件名)。
 func applyOneMigration(ctx context.Context, pool *pgxpool.Pool, migrationsDir, name string) error {
 	if pool == nil {
 		return apperrors.New("Migrate", "pool is required")
@@ -104,31 +400,71 @@ func applyOneMigration(ctx context.Context, pool *pgxpool.Pool, migrationsDir, n
 	if err != nil {
 		return apperrors.Wrapf(err, "Migrate", "read migration %s", name)
 	}
+	return applyMigration(ctx, pool, Migration{
+		Version:  migrationVersion(name),
+		UpFile:   name,
+		Checksum: migrationChecksum(sqlBytes),
+		upSQL:    string(sqlBytes),
+	})
+}
+
+// applyMigration 在事务中执行升级脚本并记录版本 (advisory lock 下二次确认未被其他实例执行)。
+func applyMigration(ctx context.Context, pool *pgxpool.Pool, m Migration) error {
+	return inMigrationTx(ctx, pool, m.Version, func(tx pgx.Tx) error {
+		var exists bool
+		if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, m.Version).Scan(&exists); err != nil {
+			return apperrors.Wrapf(err, "Migrate", "check migration %s", m.Version)
+		}
+		if exists {
+			return nil
+		}
+		start := time.Now()
+		if _, err := tx.Exec(ctx, m.upSQL); err != nil {
+			return apperrors.Wrapf(err, "Migrate", "exec migration %s", m.UpFile)
+		}
+		if _, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version, checksum, execution_ms) VALUES ($1, $2, $3)`,
+			m.Version, m.Checksum, time.Since(start).Milliseconds()); err != nil {
+			return apperrors.Wrapf(err, "Migrate", "record migration %s", m.Version)
+		}
+		if _, err := tx.Exec(ctx, `INSERT INTO schema_version (version) VALUES ($1) ON CONFLICT (version) DO NOTHING`, m.UpFile); err != nil {
+			return apperrors.Wrapf(err, "Migrate", "record legacy version %s", m.Version)
+		}
+		return nil
+	})
+}
 
+// revertMigration 在事务中执行回滚脚本并删除版本记录。
+func revertMigration(ctx context.Context, pool *pgxpool.Pool, m Migration) error {
+	return inMigrationTx(ctx, pool, m.Version, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, m.downSQL); err != nil {
+			return apperrors.Wrapf(err, "Migrate", "exec down migration %s", m.DownFile)
+		}
+		if _, err := tx.Exec(ctx, `DELETE FROM schema_migrations WHERE version = $1`, m.Version); err != nil {
+			return apperrors.Wrapf(err, "Migrate", "delete migration record %s", m.Version)
+		}
+		if _, err := tx.Exec(ctx, `DELETE FROM schema_version WHERE version IN ($1, $2, $3)`,
+			m.Version+migrationSuffix, m.Version+migrationUpSuffix, m.Version); err != nil {
+			return apperrors.Wrapf(err, "Migrate", "delete legacy version %s", m.Version)
+		}
+		return nil
+	})
+}
+
+func inMigrationTx(ctx context.Context, pool *pgxpool.Pool, version string, fn func(pgx.Tx) error) error {
 	tx, err := pool.Begin(ctx)
 	if err != nil {
-		return apperrors.Wrapf(err, "Migrate", "begin tx for %s", name)
+		return apperrors.Wrapf(err, "Migrate", "begin tx for %s", version)
 	}
-	if _, err := tx.Exec(ctx, string(sqlBytes)); err != nil {
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationLockKey); err != nil {
 		_ = tx.Rollback(ctx)
-		return apperrors.Wrapf(err, "Migrate", "exec migration %s", name)
+		return apperrors.Wrapf(err, "Migrate", "lock for %s", version)
 	}
-	if _, err := tx.Exec(ctx, `INSERT INTO schema_version (version) VALUES ($1)`, name); err != nil {
+	if err := fn(tx); err != nil {
 		_ = tx.Rollback(ctx)
-		return apperrors.Wrapf(err, "Migrate", "record migration %s", name)
+		return err
 	}
 	if err := tx.Commit(ctx); err != nil {
-		return apperrors.Wrapf(err, "Migrate", "commit migration %s", name)
+		return apperrors.Wrapf(err, "Migrate", "commit migration %s", version)
 	}
 	return nil
 }
-
-func countPendingMigrations(sqlFiles []string, applied map[string]bool) int {
-	pending := 0
-	for _, name := range sqlFiles {
-		if !applied[name] {
-			pending++
-		}
-	}
-	return pending
-}
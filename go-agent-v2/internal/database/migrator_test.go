@@ -2,6 +2,8 @@ package database
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -18,3 +20,99 @@ func TestApplyOneMigration_NilPool(t *testing.T) {
 		t.Fatal("expected error for nil pool")
 	}
 }
+
+func writeMigrations(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+func TestLoadMigrations(t *testing.T) {
+	dir := writeMigrations(t, map[string]string{
+		"0002_b.up.sql":   "CREATE TABLE b();",
+		"0002_b.down.sql": "DROP TABLE b;",
+		"0001_a.sql":      "CREATE TABLE a();",
+		"README.md":       "ignored",
+	})
+	migrations, err := LoadMigrations(dir)
+	if err != nil {
+		t.Fatalf("LoadMigrations: %v", err)
+	}
+	if len(migrations) != 2 || migrations[0].Version != "0001_a" || migrations[1].Version != "0002_b" {
+		t.Fatalf("migrations = %+v", migrations)
+	}
+	if migrations[0].DownFile != "" || migrations[1].DownFile != "0002_b.down.sql" {
+		t.Fatalf("down files = %q, %q", migrations[0].DownFile, migrations[1].DownFile)
+	}
+	if migrations[0].Checksum == "" || migrations[0].Checksum == migrations[1].Checksum {
+		t.Fatalf("checksums = %q, %q", migrations[0].Checksum, migrations[1].Checksum)
+	}
+}
+
+func TestLoadMigrations_Invalid(t *testing.T) {
+	for name, files := range map[string]map[string]string{
+		"duplicate up": {"0001_a.sql": "x", "0001_a.up.sql": "y"},
+		"orphan down":  {"0001_a.down.sql": "x"},
+	} {
+		if _, err := LoadMigrations(writeMigrations(t, files)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	if m, err := LoadMigrations(filepath.Join(t.TempDir(), "missing")); err != nil || m != nil {
+		t.Fatalf("missing dir = %v, %v", m, err)
+	}
+}
+
+func TestPlanUpAndDown(t *testing.T) {
+	migrations := []Migration{
+		{Version: "0001_a", Checksum: "c1"},
+		{Version: "0002_b", Checksum: "c2", DownFile: "0002_b.down.sql"},
+		{Version: "0003_c", Checksum: "c3", DownFile: "0003_c.down.sql"},
+	}
+	applied := map[string]appliedMigration{
+		"0001_a": {Version: "0001_a", Checksum: ""}, // 旧表导入, 无校验和
+		"0002_b": {Version: "0002_b", Checksum: "changed"},
+	}
+	pending, modified := planUp(migrations, applied)
+	if len(pending) != 1 || pending[0].Version != "0003_c" {
+		t.Fatalf("pending = %+v", pending)
+	}
+	if len(modified) != 1 || modified[0] != "0002_b" {
+		t.Fatalf("modified = %v", modified)
+	}
+
+	plan, err := planDown(migrations, applied, 0)
+	if err != nil || len(plan) != 1 || plan[0].Version != "0002_b" {
+		t.Fatalf("planDown(1) = %+v, %v", plan, err)
+	}
+	if _, err := planDown(migrations, applied, 2); err == nil {
+		t.Fatal("reverting 0001_a without down file must fail")
+	}
+
+	statuses := buildMigrationStatuses(migrations[1:], applied)
+	states := map[string]string{}
+	for _, st := range statuses {
+		states[st.Version] = st.State
+	}
+	want := map[string]string{"0001_a": MigrationMissing, "0002_b": MigrationModified, "0003_c": MigrationPending}
+	for version, state := range want {
+		if states[version] != state {
+			t.Errorf("state[%s] = %s, want %s", version, states[version], state)
+		}
+	}
+}
+
+func TestLoadMigrations_RepoDirectory(t *testing.T) {
+	migrations, err := LoadMigrations(filepath.Join("..", "..", "migrations"))
+	if err != nil {
+		t.Fatalf("repo migrations: %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("expected repo migrations")
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/multi-agent/go-agent-v2/internal/config"
+	"github.com/multi-agent/go-agent-v2/internal/store"
 	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
 	"github.com/multi-agent/go-agent-v2/pkg/logger"
 )
@@ -32,6 +33,9 @@ func NewPool(ctx context.Context, cfg *config.Config) (*pgxpool.Pool, error) {
 	poolCfg.MinConns = safeInt32(cfg.PostgresPoolMinSize, "PostgresPoolMinSize")
 	poolCfg.MaxConns = safeInt32(cfg.PostgresPoolMaxSize, "PostgresPoolMaxSize")
 
+	// 写操作记入请求 correlation 因果链 (见 tracer.go)
+	poolCfg.ConnConfig.Tracer = writeTracer{}
+
 	// AfterConnect: 设置 search_path (使用 quote_ident 防止 SQL 注入)
 	schema := cfg.PostgresSchema
 	if schema != "" && schema != "public" {
@@ -41,6 +45,11 @@ func NewPool(ctx context.Context, cfg *config.Config) (*pgxpool.Pool, error) {
 		}
 	}
 
+	// 敏感列加密对所有 store 生效, 在建立连接前配置, 密钥无效时拒绝启动 (避免写入明文)。
+	if _, err := store.ConfigureFieldEncryption(cfg.StoreEncryptionKeys, cfg.StoreEncryptionKeychainService); err != nil {
+		return nil, apperrors.Wrap(err, "NewPool", "configure field encryption")
+	}
+
 	pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
 	if err != nil {
 		return nil, apperrors.Wrap(err, "NewPool", "create pool")
@@ -0,0 +1,98 @@
+// tracer.go — pgx 查询追踪: 把请求 context 上的写操作记入 correlation 因果链。
+package database
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/multi-agent/go-agent-v2/pkg/correlation"
+)
+
+// writeTracer 仅记录带 correlation.Trail 的 context 上的写操作 (INSERT / UPDATE / DELETE / COPY)。
+type writeTracer struct{}
+
+type traceStartKey struct{}
+
+type traceStart struct {
+	at   time.Time
+	name string
+}
+
+func (writeTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	if correlation.FromContext(ctx) == nil {
+		return ctx
+	}
+	name, ok := sqlWriteTarget(data.SQL)
+	if !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, traceStartKey{}, traceStart{at: time.Now(), name: name})
+}
+
+func (writeTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	recordWrite(ctx, data.CommandTag.RowsAffected(), data.Err)
+}
+
+func (writeTracer) TraceCopyFromStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromStartData) context.Context {
+	if correlation.FromContext(ctx) == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, traceStartKey{}, traceStart{at: time.Now(), name: "COPY " + strings.Join(data.TableName, ".")})
+}
+
+func (writeTracer) TraceCopyFromEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromEndData) {
+	recordWrite(ctx, data.CommandTag.RowsAffected(), data.Err)
+}
+
+func recordWrite(ctx context.Context, rows int64, err error) {
+	start, ok := ctx.Value(traceStartKey{}).(traceStart)
+	if !ok {
+		return
+	}
+	ev := correlation.Event{
+		Kind:       correlation.KindStore,
+		Name:       start.name,
+		DurationMS: time.Since(start.at).Milliseconds(),
+		Status:     "ok",
+	}
+	if err != nil {
+		ev.Status, ev.Error = "failed", err.Error()
+	} else if rows > 0 {
+		ev.Detail = "rows=" + strconv.FormatInt(rows, 10)
+	}
+	correlation.Record(ctx, ev)
+}
+
+// sqlWriteTarget 解析写语句的动作与表名 (如 "INSERT task_traces"), 非写语句返回 false。
+func sqlWriteTarget(sql string) (string, bool) {
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return "", false
+	}
+	verb := strings.ToUpper(fields[0])
+	var table string
+	switch verb {
+	case "INSERT", "DELETE":
+		if len(fields) >= 3 && (strings.EqualFold(fields[1], "INTO") || strings.EqualFold(fields[1], "FROM")) {
+			table = fields[2]
+		}
+	case "UPDATE":
+		if len(fields) >= 2 {
+			table = fields[1]
+		}
+	default:
+		return "", false
+	}
+	if i := strings.IndexByte(table, '('); i >= 0 {
+		table = table[:i]
+	}
+	table = strings.Trim(table, `"`)
+	if table == "" {
+		return verb, true
+	}
+	return verb + " " + table, true
+}
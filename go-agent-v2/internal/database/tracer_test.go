@@ -0,0 +1,20 @@
+package database
+
+import "testing"
+
+func TestSQLWriteTarget(t *testing.T) {
+	cases := map[string]string{
+		"INSERT INTO task_traces (trace_id, span_id) VALUES ($1, $2)": "INSERT task_traces",
+		"insert into \"agent_status\"(agent_id) values ($1)":          "INSERT agent_status",
+		"UPDATE workspace_runs SET status = $1":                       "UPDATE workspace_runs",
+		"DELETE FROM thread_aliases WHERE thread_id = $1":             "DELETE thread_aliases",
+	}
+	for sql, want := range cases {
+		if got, ok := sqlWriteTarget(sql); !ok || got != want {
+			t.Errorf("%q = %q, %v; want %q", sql, got, ok, want)
+		}
+	}
+	if _, ok := sqlWriteTarget("SELECT 1"); ok {
+		t.Error("SELECT should not be a write")
+	}
+}
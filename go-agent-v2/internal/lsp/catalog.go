@@ -0,0 +1,284 @@
+// catalog.go — LSP 服务器目录: 安装命令、初始化选项与项目级覆盖 (.agent/lsp.json)。
+//
+// 项目级覆盖示例 (.agent/lsp.json):
+//
+//	{
+//	  "servers": {
+//	    "python": {"command": "pyright-langserver", "args": ["--stdio"],
+//	               "install": ["npm", "install", "-g", "pyright"]},
+//	    "go": {"initializationOptions": {"staticcheck": true}}
+//	  }
+//	}
+//
+// 覆盖按语言合并: 只替换显式给出的字段; 未知语言视为新增 (需提供 command 与 extensions)。
+package lsp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+// ProjectCatalogFile 项目级 LSP 配置文件 (相对项目根目录)。
+const ProjectCatalogFile = ".agent/lsp.json"
+
+const (
+	defaultInstallTimeout = 10 * time.Minute
+	maxInstallOutputBytes = 16 << 10
+)
+
+// serverOverride .agent/lsp.json 中单个语言的覆盖项 (指针字段区分"未设置")。
+type serverOverride struct {
+	Command               *string        `json:"command,omitempty"`
+	Args                  []string       `json:"args,omitempty"`
+	Extensions            []string       `json:"extensions,omitempty"`
+	Install               []string       `json:"install,omitempty"`
+	InitializationOptions map[string]any `json:"initializationOptions,omitempty"`
+}
+
+type projectCatalog struct {
+	Servers map[string]serverOverride `json:"servers"`
+}
+
+// CloneServerConfigs 深拷贝配置列表 (避免修改 DefaultServers)。
+func CloneServerConfigs(configs []ServerConfig) []ServerConfig {
+	out := make([]ServerConfig, 0, len(configs))
+	for _, cfg := range configs {
+		copied := cfg
+		copied.Args = append([]string(nil), cfg.Args...)
+		copied.Extensions = append([]string(nil), cfg.Extensions...)
+		copied.InstallCommand = append([]string(nil), cfg.InstallCommand...)
+		if cfg.InitializationOptions != nil {
+			copied.InitializationOptions = make(map[string]any, len(cfg.InitializationOptions))
+			for key, value := range cfg.InitializationOptions {
+				copied.InitializationOptions[key] = value
+			}
+		}
+		out = append(out, copied)
+	}
+	return out
+}
+
+// MergeProjectCatalog 将 .agent/lsp.json 的内容合并到 base 上。
+func MergeProjectCatalog(base []ServerConfig, data []byte) ([]ServerConfig, error) {
+	var catalog projectCatalog
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		return nil, apperrors.Wrap(err, "LSP.MergeProjectCatalog", "parse lsp catalog")
+	}
+	merged := CloneServerConfigs(base)
+	index := make(map[string]int, len(merged))
+	for i, cfg := range merged {
+		index[normalizeLanguage(cfg.Language)] = i
+	}
+
+	languages := make([]string, 0, len(catalog.Servers))
+	for language := range catalog.Servers {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	for _, language := range languages {
+		override := catalog.Servers[language]
+		key := normalizeLanguage(language)
+		if key == "" {
+			continue
+		}
+		idx, exists := index[key]
+		if !exists {
+			if override.Command == nil || strings.TrimSpace(*override.Command) == "" || len(override.Extensions) == 0 {
+				return nil, apperrors.Newf("LSP.MergeProjectCatalog", "new language %q requires command and extensions", language)
+			}
+			merged = append(merged, ServerConfig{Language: key})
+			idx = len(merged) - 1
+			index[key] = idx
+		}
+		cfg := &merged[idx]
+		if override.Command != nil {
+			cfg.Command = strings.TrimSpace(*override.Command)
+		}
+		if override.Args != nil {
+			cfg.Args = append([]string(nil), override.Args...)
+		}
+		if override.Extensions != nil {
+			cfg.Extensions = normalizeExtensions(override.Extensions)
+		}
+		if override.Install != nil {
+			cfg.InstallCommand = append([]string(nil), override.Install...)
+		}
+		if override.InitializationOptions != nil {
+			cfg.InitializationOptions = override.InitializationOptions
+		}
+	}
+	return merged, nil
+}
+
+// LoadProjectCatalog 读取 rootDir 下的 .agent/lsp.json 并合并到默认目录; 文件不存在时返回默认目录。
+func LoadProjectCatalog(rootDir string) ([]ServerConfig, bool, error) {
+	base := CloneServerConfigs(DefaultServers)
+	if strings.TrimSpace(rootDir) == "" {
+		return base, false, nil
+	}
+	data, err := os.ReadFile(filepath.Join(rootDir, filepath.FromSlash(ProjectCatalogFile)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return base, false, nil
+		}
+		return base, false, apperrors.Wrap(err, "LSP.LoadProjectCatalog", "read lsp catalog")
+	}
+	merged, err := MergeProjectCatalog(base, data)
+	if err != nil {
+		return base, false, err
+	}
+	return merged, true, nil
+}
+
+func normalizeExtensions(raw []string) []string {
+	out := make([]string, 0, len(raw))
+	for _, ext := range raw {
+		trimmed := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(ext)), ".")
+		if trimmed != "" {
+			out = append(out, trimmed)
+		}
+	}
+	return out
+}
+
+// ========================================
+// Manager 集成
+// ========================================
+
+// ApplyConfigs 替换语言服务器目录, 运行中的客户端停止后按新配置延迟重启。
+func (m *Manager) ApplyConfigs(configs []ServerConfig) {
+	cloned := CloneServerConfigs(configs)
+	m.mu.Lock()
+	m.configs = make(map[string]*ServerConfig, len(cloned)*3)
+	m.languages = make(map[string]*ServerConfig, len(cloned))
+	for i := range cloned {
+		cfg := &cloned[i]
+		m.languages[normalizeLanguage(cfg.Language)] = cfg
+		for _, ext := range cfg.Extensions {
+			m.configs[ext] = cfg
+		}
+	}
+	m.mu.Unlock()
+	m.Reload()
+}
+
+// Configs 返回当前语言服务器目录 (按语言排序的副本)。
+func (m *Manager) Configs() []ServerConfig {
+	m.mu.RLock()
+	configs := make([]ServerConfig, 0, len(m.languages))
+	for _, cfg := range m.languages {
+		configs = append(configs, *cfg)
+	}
+	m.mu.RUnlock()
+	sort.Slice(configs, func(i, j int) bool { return configs[i].Language < configs[j].Language })
+	return CloneServerConfigs(configs)
+}
+
+// SupportsExtension 判断文件后缀 (不含点号) 是否有对应语言服务器。
+func (m *Manager) SupportsExtension(ext string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.configs[strings.ToLower(strings.TrimPrefix(ext, "."))]
+	return ok
+}
+
+// InstallResult 单个语言服务器的安装结果。
+type InstallResult struct {
+	Language   string   `json:"language"`
+	Command    string   `json:"command"`
+	Install    []string `json:"install,omitempty"`
+	Status     string   `json:"status"` // already_installed / installed / failed / unsupported
+	Output     string   `json:"output,omitempty"`
+	Error      string   `json:"error,omitempty"`
+	DurationMS int64    `json:"durationMs"`
+}
+
+// Install 为指定语言执行安装命令 (已在 PATH 上且 force=false 时跳过)。
+func (m *Manager) Install(ctx context.Context, language string, force bool) InstallResult {
+	start := time.Now()
+	key := normalizeLanguage(language)
+	m.mu.RLock()
+	cfg, ok := m.languages[key]
+	var snapshot ServerConfig
+	if ok {
+		snapshot = CloneServerConfigs([]ServerConfig{*cfg})[0]
+	}
+	m.mu.RUnlock()
+
+	result := InstallResult{Language: key}
+	if !ok {
+		result.Status = "failed"
+		result.Error = "unknown language: " + language
+		return result
+	}
+	result.Command = snapshot.Command
+	result.Install = snapshot.InstallCommand
+	if _, err := exec.LookPath(snapshot.Command); err == nil && !force {
+		result.Status = "already_installed"
+		return result
+	}
+	if len(snapshot.InstallCommand) == 0 {
+		result.Status = "unsupported"
+		result.Error = "no install command configured for " + key
+		return result
+	}
+
+	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultInstallTimeout)
+		defer cancel()
+	}
+	cmd := exec.CommandContext(ctx, snapshot.InstallCommand[0], snapshot.InstallCommand[1:]...)
+	cmd.Env = os.Environ()
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	logger.Info("lsp: installing language server",
+		logger.FieldLanguage, key,
+		logger.FieldCommand, strings.Join(snapshot.InstallCommand, " "),
+	)
+	runErr := cmd.Run()
+	result.DurationMS = time.Since(start).Milliseconds()
+	result.Output = tailBytes(output.Bytes(), maxInstallOutputBytes)
+	if runErr != nil {
+		result.Status = "failed"
+		result.Error = runErr.Error()
+		logger.Warn("lsp: install failed", logger.FieldLanguage, key, logger.FieldError, runErr)
+		return result
+	}
+	if _, err := exec.LookPath(snapshot.Command); err != nil {
+		result.Status = "failed"
+		result.Error = snapshot.Command + " still not found in PATH after install"
+		return result
+	}
+	result.Status = "installed"
+	logger.Info("lsp: language server installed",
+		logger.FieldLanguage, key,
+		logger.FieldDurationMS, result.DurationMS,
+	)
+
+	m.mu.RLock()
+	handler := m.onStatus
+	m.mu.RUnlock()
+	if handler != nil {
+		handler(m.Statuses())
+	}
+	return result
+}
+
+func tailBytes(data []byte, limit int) string {
+	if len(data) <= limit {
+		return string(data)
+	}
+	return "..." + string(data[len(data)-limit:])
+}
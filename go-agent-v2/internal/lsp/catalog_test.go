@@ -0,0 +1,85 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMergeProjectCatalogOverridesAndAdds(t *testing.T) {
+	data := []byte(`{"servers":{
+		"py":{"command":"pyright-langserver","args":["--stdio"],"install":["npm","install","-g","pyright"]},
+		"go":{"initializationOptions":{"staticcheck":true}},
+		"zig":{"command":"zls","extensions":[".zig"]}
+	}}`)
+	merged, err := MergeProjectCatalog(DefaultServers, data)
+	if err != nil {
+		t.Fatalf("MergeProjectCatalog: %v", err)
+	}
+	byLang := map[string]ServerConfig{}
+	for _, cfg := range merged {
+		byLang[cfg.Language] = cfg
+	}
+	if py := byLang["python"]; py.Command != "pyright-langserver" || len(py.Args) != 1 || py.InstallCommand[2] != "-g" || py.Extensions[0] != "py" {
+		t.Fatalf("python override = %+v", py)
+	}
+	if goCfg := byLang["go"]; goCfg.Command != "gopls" || goCfg.InitializationOptions["staticcheck"] != true {
+		t.Fatalf("go override = %+v", goCfg)
+	}
+	if zig := byLang["zig"]; zig.Command != "zls" || len(zig.Extensions) != 1 || zig.Extensions[0] != "zig" {
+		t.Fatalf("zig addition = %+v", zig)
+	}
+	if DefaultServers[3].Command != "pylsp" {
+		t.Fatal("DefaultServers must not be mutated")
+	}
+
+	if _, err := MergeProjectCatalog(DefaultServers, []byte(`{"servers":{"zig":{"command":"zls"}}}`)); err == nil {
+		t.Fatal("new language without extensions should fail")
+	}
+}
+
+func TestLoadProjectCatalogFromRoot(t *testing.T) {
+	root := t.TempDir()
+	if _, overridden, err := LoadProjectCatalog(root); err != nil || overridden {
+		t.Fatalf("missing catalog: overridden=%v err=%v", overridden, err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, ".agent"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, ".agent", "lsp.json"), []byte(`{"servers":{"c":{"command":"clangd-18"}}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	configs, overridden, err := LoadProjectCatalog(root)
+	if err != nil || !overridden {
+		t.Fatalf("LoadProjectCatalog: overridden=%v err=%v", overridden, err)
+	}
+	m := NewManager(nil)
+	m.ApplyConfigs(configs)
+	for _, cfg := range m.Configs() {
+		if cfg.Language == "c" && cfg.Command != "clangd-18" {
+			t.Fatalf("c command = %q, want clangd-18", cfg.Command)
+		}
+	}
+}
+
+func TestManagerInstallStatuses(t *testing.T) {
+	m := NewManager([]ServerConfig{
+		{Language: "shell", Command: "sh", Extensions: []string{"sh"}},
+		{Language: "ghost", Command: "__definitely_missing_lsp__", Extensions: []string{"ghost"}},
+		{Language: "noop", Command: "__definitely_missing_lsp__", Extensions: []string{"noop"}, InstallCommand: []string{"true"}},
+	})
+	ctx := context.Background()
+	if got := m.Install(ctx, "shell", false); got.Status != "already_installed" {
+		t.Fatalf("shell install = %+v", got)
+	}
+	if got := m.Install(ctx, "ghost", false); got.Status != "unsupported" {
+		t.Fatalf("ghost install = %+v", got)
+	}
+	if got := m.Install(ctx, "noop", false); got.Status != "failed" || got.Error == "" {
+		t.Fatalf("noop install should fail when binary still missing: %+v", got)
+	}
+	if got := m.Install(ctx, "cobol", false); got.Status != "failed" {
+		t.Fatalf("unknown language install = %+v", got)
+	}
+}
@@ -44,6 +44,8 @@ type Client struct {
 	onDiag   DiagnosticHandler
 	stopped  atomic.Bool
 	language string
+	initOpts map[string]any    // initialize 请求的 initializationOptions
+	folders  []WorkspaceFolder // initialize 请求的 workspaceFolders
 
 	capsMu               sync.RWMutex
 	initializeResult     InitializeResult
@@ -68,6 +70,33 @@ func (c *Client) SetDiagnosticHandler(h DiagnosticHandler) {
 	c.onDiag = h
 }
 
+// SetInitializationOptions 设置 initialize 请求携带的 initializationOptions (需在 Start 前调用)。
+func (c *Client) SetInitializationOptions(opts map[string]any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.initOpts = opts
+}
+
+// SetWorkspaceFolders 设置 initialize 请求携带的工作区根目录 (需在 Start 前调用)。
+func (c *Client) SetWorkspaceFolders(folders []WorkspaceFolder) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.folders = append([]WorkspaceFolder(nil), folders...)
+}
+
+// DidChangeWorkspaceFolders 通知语言服务器工作区根目录增减。
+func (c *Client) DidChangeWorkspaceFolders(added, removed []WorkspaceFolder) error {
+	if added == nil {
+		added = []WorkspaceFolder{}
+	}
+	if removed == nil {
+		removed = []WorkspaceFolder{}
+	}
+	return c.notify("workspace/didChangeWorkspaceFolders", DidChangeWorkspaceFoldersParams{
+		Event: WorkspaceFoldersChangeEvent{Added: added, Removed: removed},
+	})
+}
+
 // Start 启动语言服务器进程并完成 initialize 握手。
 func (c *Client) Start(ctx context.Context, command string, args []string, rootURI string) error {
 	c.cmd = exec.CommandContext(ctx, command, args...)
@@ -108,10 +137,17 @@ func (c *Client) Start(ctx context.Context, command string, args []string, rootU
 	}
 
 	// initialize 握手
+	c.mu.Lock()
+	initOpts := c.initOpts
+	folders := c.folders
+	c.mu.Unlock()
 	initParams := InitializeParams{
-		ProcessID: os.Getpid(),
-		RootURI:   rootURI,
+		ProcessID:             os.Getpid(),
+		RootURI:               rootURI,
+		WorkspaceFolders:      folders,
+		InitializationOptions: initOpts,
 		Capabilities: ClientCapabilities{
+			Workspace: &WorkspaceClientCapabilities{WorkspaceFolders: true},
 			TextDocument: &TextDocumentClientCapabilities{
 				PublishDiagnostics: &PublishDiagnosticsCapability{
 					RelatedInformation: true,
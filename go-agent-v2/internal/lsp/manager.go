@@ -25,37 +25,43 @@ import (
 
 // ServerConfig 语言服务器配置。
 type ServerConfig struct {
-	Language   string   // 语言标识 ("go", "rust", "typescript")
-	Command    string   // 可执行文件名
-	Args       []string // 命令参数
-	Extensions []string // 关联的文件后缀 (不含点号)
+	Language              string         // 语言标识 ("go", "rust", "typescript")
+	Command               string         // 可执行文件名
+	Args                  []string       // 命令参数
+	Extensions            []string       // 关联的文件后缀 (不含点号)
+	InstallCommand        []string       // 缺失时的安装命令 (lsp/install), 为空表示不支持自动安装
+	InitializationOptions map[string]any // initialize 请求的 initializationOptions
 }
 
 // DefaultServers 默认支持的五个语言服务器。
 var DefaultServers = []ServerConfig{
 	{
-		Language:   "go",
-		Command:    "gopls",
-		Args:       nil,
-		Extensions: []string{"go"},
+		Language:       "go",
+		Command:        "gopls",
+		Args:           nil,
+		Extensions:     []string{"go"},
+		InstallCommand: []string{"go", "install", "golang.org/x/tools/gopls@latest"},
 	},
 	{
-		Language:   "rust",
-		Command:    "rust-analyzer",
-		Args:       nil,
-		Extensions: []string{"rs"},
+		Language:       "rust",
+		Command:        "rust-analyzer",
+		Args:           nil,
+		Extensions:     []string{"rs"},
+		InstallCommand: []string{"rustup", "component", "add", "rust-analyzer"},
 	},
 	{
-		Language:   "typescript",
-		Command:    "typescript-language-server",
-		Args:       []string{"--stdio"},
-		Extensions: []string{"ts", "tsx", "js", "jsx"},
+		Language:       "typescript",
+		Command:        "typescript-language-server",
+		Args:           []string{"--stdio"},
+		Extensions:     []string{"ts", "tsx", "js", "jsx"},
+		InstallCommand: []string{"npm", "install", "-g", "typescript-language-server", "typescript"},
 	},
 	{
-		Language:   "python",
-		Command:    "pylsp",
-		Args:       nil,
-		Extensions: []string{"py"},
+		Language:       "python",
+		Command:        "pylsp",
+		Args:           nil,
+		Extensions:     []string{"py"},
+		InstallCommand: []string{"python3", "-m", "pip", "install", "--user", "python-lsp-server"},
 	},
 	{
 		Language:   "c",
@@ -89,6 +95,7 @@ type Manager struct {
 	languages   map[string]*ServerConfig // language(normalized) → config
 	clients     map[string]*Client       // language → client
 	rootURI     string
+	folders     []string // 追加的工作区根 (file:// URI, 见 manager_workspace_folders.go)
 	workspaceID string
 	ctx         context.Context
 	cancel      context.CancelFunc
@@ -445,9 +452,12 @@ func (m *Manager) ensureClient(cfg *ServerConfig) (*Client, error) {
 	if m.rootURI == "" && rootURI != "" {
 		m.rootURI = rootURI
 	}
+	folders := m.workspaceFoldersLocked(rootURI)
 	m.mu.Unlock()
 
 	// Start 可能阻塞 (等待 initialize 响应)，不持锁
+	client.SetInitializationOptions(cfg.InitializationOptions)
+	client.SetWorkspaceFolders(folders)
 	if err := client.Start(m.ctx, cmdPath, cfg.Args, rootURI); err != nil {
 		m.mu.Lock()
 		delete(m.clients, cfg.Language)
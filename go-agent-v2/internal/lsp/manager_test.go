@@ -139,3 +139,29 @@ func TestSetRootURI_SameValueKeepsClients(t *testing.T) {
 		t.Fatalf("expected clients to stay unchanged when rootURI is same, got %d", len(m.clients))
 	}
 }
+
+func TestWorkspaceFolders_AddRemoveKeepsRootFirst(t *testing.T) {
+	m := NewManager(nil)
+	m.SetRootURI("file:///tmp/mono")
+
+	if m.AddWorkspaceFolder("file:///tmp/mono") {
+		t.Fatal("primary root should not be added twice")
+	}
+	if !m.AddWorkspaceFolder("file:///tmp/other-repo") || m.AddWorkspaceFolder("file:///tmp/other-repo") {
+		t.Fatal("extra root should be added exactly once")
+	}
+	folders := m.WorkspaceFolders()
+	if len(folders) != 2 || folders[0].URI != "file:///tmp/mono" || folders[1].Name != "other-repo" {
+		t.Fatalf("folders = %+v", folders)
+	}
+
+	if m.RemoveWorkspaceFolder("file:///tmp/mono") {
+		t.Fatal("primary root should not be removable")
+	}
+	if !m.RemoveWorkspaceFolder("file:///tmp/other-repo") {
+		t.Fatal("extra root should be removable")
+	}
+	if folders := m.WorkspaceFolders(); len(folders) != 1 {
+		t.Fatalf("folders after remove = %+v", folders)
+	}
+}
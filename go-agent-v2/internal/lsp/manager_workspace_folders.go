@@ -0,0 +1,97 @@
+// manager_workspace_folders.go — 多根工作区 (workspaceFolders)。
+//
+// rootURI 为主根, AddWorkspaceFolder 追加的根随 initialize 发送给之后启动的语言服务器,
+// 已运行的语言服务器通过 workspace/didChangeWorkspaceFolders 增量通知, 无需重启。
+package lsp
+
+import (
+	"net/url"
+	"path"
+	"slices"
+
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+// workspaceFolderFromURI 以目录名作为 folder name。
+func workspaceFolderFromURI(uri string) WorkspaceFolder {
+	name := uri
+	if parsed, err := url.Parse(uri); err == nil && parsed.Path != "" {
+		name = path.Base(parsed.Path)
+	}
+	return WorkspaceFolder{URI: uri, Name: name}
+}
+
+// workspaceFoldersLocked 主根 + 追加的根 (调用方持有 m.mu)。
+func (m *Manager) workspaceFoldersLocked(rootURI string) []WorkspaceFolder {
+	folders := make([]WorkspaceFolder, 0, len(m.folders)+1)
+	if rootURI != "" {
+		folders = append(folders, workspaceFolderFromURI(rootURI))
+	}
+	for _, uri := range m.folders {
+		if uri != rootURI {
+			folders = append(folders, workspaceFolderFromURI(uri))
+		}
+	}
+	return folders
+}
+
+// WorkspaceFolders 返回当前全部工作区根 (主根在前)。
+func (m *Manager) WorkspaceFolders() []WorkspaceFolder {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.workspaceFoldersLocked(effectiveRootURI(m.rootURI, m.workspaceID))
+}
+
+// AddWorkspaceFolder 追加工作区根, 已存在时返回 false。
+func (m *Manager) AddWorkspaceFolder(dir string) bool {
+	uri := pathToURI(dir)
+	m.mu.Lock()
+	if uri == effectiveRootURI(m.rootURI, m.workspaceID) || slices.Contains(m.folders, uri) {
+		m.mu.Unlock()
+		return false
+	}
+	m.folders = append(m.folders, uri)
+	clients := m.runningClientsLocked()
+	m.mu.Unlock()
+
+	m.notifyWorkspaceFolders(clients, []WorkspaceFolder{workspaceFolderFromURI(uri)}, nil)
+	return true
+}
+
+// RemoveWorkspaceFolder 移除追加的工作区根 (主根不可移除), 不存在时返回 false。
+func (m *Manager) RemoveWorkspaceFolder(dir string) bool {
+	uri := pathToURI(dir)
+	m.mu.Lock()
+	idx := slices.Index(m.folders, uri)
+	if idx < 0 {
+		m.mu.Unlock()
+		return false
+	}
+	m.folders = slices.Delete(m.folders, idx, idx+1)
+	clients := m.runningClientsLocked()
+	m.mu.Unlock()
+
+	m.notifyWorkspaceFolders(clients, nil, []WorkspaceFolder{workspaceFolderFromURI(uri)})
+	return true
+}
+
+func (m *Manager) runningClientsLocked() []*Client {
+	clients := make([]*Client, 0, len(m.clients))
+	for _, client := range m.clients {
+		if client != nil && client.Running() {
+			clients = append(clients, client)
+		}
+	}
+	return clients
+}
+
+func (m *Manager) notifyWorkspaceFolders(clients []*Client, added, removed []WorkspaceFolder) {
+	for _, client := range clients {
+		if err := client.DidChangeWorkspaceFolders(added, removed); err != nil {
+			logger.Warn("lsp: didChangeWorkspaceFolders failed",
+				logger.FieldLanguage, client.Language(),
+				logger.FieldError, err,
+			)
+		}
+	}
+}
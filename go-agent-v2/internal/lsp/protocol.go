@@ -94,16 +94,41 @@ type TextDocumentPositionParams struct {
 
 // InitializeParams initialize 请求参数。
 type InitializeParams struct {
-	ProcessID    int                `json:"processId"`
-	RootURI      string             `json:"rootUri"`
-	Capabilities ClientCapabilities `json:"capabilities"`
+	ProcessID             int                `json:"processId"`
+	RootURI               string             `json:"rootUri"`
+	WorkspaceFolders      []WorkspaceFolder  `json:"workspaceFolders,omitempty"`
+	Capabilities          ClientCapabilities `json:"capabilities"`
+	InitializationOptions map[string]any     `json:"initializationOptions,omitempty"`
+}
+
+// WorkspaceFolder 工作区根目录 (多根工作区)。
+type WorkspaceFolder struct {
+	URI  string `json:"uri"`
+	Name string `json:"name"`
+}
+
+// DidChangeWorkspaceFoldersParams workspace/didChangeWorkspaceFolders 通知参数。
+type DidChangeWorkspaceFoldersParams struct {
+	Event WorkspaceFoldersChangeEvent `json:"event"`
+}
+
+// WorkspaceFoldersChangeEvent 新增 / 移除的工作区根目录。
+type WorkspaceFoldersChangeEvent struct {
+	Added   []WorkspaceFolder `json:"added"`
+	Removed []WorkspaceFolder `json:"removed"`
 }
 
 // ClientCapabilities 客户端能力声明。
 type ClientCapabilities struct {
+	Workspace    *WorkspaceClientCapabilities    `json:"workspace,omitempty"`
 	TextDocument *TextDocumentClientCapabilities `json:"textDocument,omitempty"`
 }
 
+// WorkspaceClientCapabilities 工作区级能力。
+type WorkspaceClientCapabilities struct {
+	WorkspaceFolders bool `json:"workspaceFolders,omitempty"`
+}
+
 // TextDocumentClientCapabilities 文档级能力。
 type TextDocumentClientCapabilities struct {
 	PublishDiagnostics *PublishDiagnosticsCapability `json:"publishDiagnostics,omitempty"`
@@ -0,0 +1,443 @@
+// http.go — MCP 网络传输: Streamable HTTP (/mcp) 与旧版 HTTP+SSE (/sse + /message)。
+//
+//   - Streamable HTTP (2025-03-26): POST /mcp 发送 JSON-RPC, initialize 响应头返回 Mcp-Session-Id,
+//     后续请求须携带该头; GET /mcp 打开会话的 SSE 推送流; DELETE /mcp 结束会话。
+//   - HTTP+SSE (2024-11-05): GET /sse 建立会话并推送 endpoint 事件, 客户端向
+//     POST /message?sessionId=... 发送请求, 响应经 SSE 流以 message 事件返回。
+//
+// 配置了令牌时所有端点要求 Authorization: Bearer <token>; 空闲超过 SessionTTL 的会话被回收。
+// 浏览器来源 (Origin) 必须为回环地址; 未配置令牌时 Host 也必须为回环地址, 防御跨站 POST 与 DNS rebinding。
+package mcp
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+	"github.com/multi-agent/go-agent-v2/pkg/util"
+)
+
+const (
+	sessionHeader          = "Mcp-Session-Id"
+	defaultSessionTTL      = 30 * time.Minute
+	sessionOutboxSize      = 128
+	sseKeepAliveInterval   = 25 * time.Second
+	maxHTTPMessageBytes    = 8 << 20
+	httpShutdownTimeout    = 5 * time.Second
+	transportStreamable    = "streamable-http"
+	transportSSE           = "sse"
+	sessionSweepInterval   = time.Minute
+	httpReadHeaderTimeout  = 10 * time.Second
+	legacySSEEndpointEvent = "endpoint"
+)
+
+// HTTPOptions 网络传输选项。
+type HTTPOptions struct {
+	Token      string        // 非空时要求 Bearer 令牌
+	SessionTTL time.Duration // 会话空闲回收时间, <=0 使用默认 30 分钟
+}
+
+// httpSession 一个 MCP 客户端会话。outbox 承载服务端推送 (旧版 SSE 下也承载响应)。
+type httpSession struct {
+	id        string
+	transport string
+	ctx       context.Context
+	cancel    context.CancelFunc
+
+	mu       sync.Mutex
+	lastSeen time.Time
+	streams  int // 当前连接的 SSE 流数
+	outbox   chan []byte
+}
+
+func (ss *httpSession) touch() {
+	ss.mu.Lock()
+	ss.lastSeen = time.Now()
+	ss.mu.Unlock()
+}
+
+func (ss *httpSession) connID() string { return ss.id }
+
+func (ss *httpSession) notify(msg []byte) bool { return ss.send(msg) }
+
+// send 非阻塞投递到 outbox, 已满时丢弃并返回 false。
+func (ss *httpSession) send(msg []byte) bool {
+	select {
+	case ss.outbox <- msg:
+		return true
+	default:
+		logger.Warn("mcp: session outbox full, message dropped", logger.FieldSessionID, ss.id)
+		return false
+	}
+}
+
+// httpTransport 会话表与 HTTP 处理器。
+type httpTransport struct {
+	srv  *Server
+	opts HTTPOptions
+
+	mu       sync.Mutex
+	sessions map[string]*httpSession
+}
+
+// NewHTTPHandler 创建 MCP 网络传输处理器 (不含会话回收循环, 见 ServeHTTP)。
+func (s *Server) NewHTTPHandler(opts HTTPOptions) http.Handler {
+	return s.newHTTPTransport(opts).routes()
+}
+
+func (s *Server) newHTTPTransport(opts HTTPOptions) *httpTransport {
+	if opts.SessionTTL <= 0 {
+		opts.SessionTTL = defaultSessionTTL
+	}
+	return &httpTransport{srv: s, opts: opts, sessions: make(map[string]*httpSession)}
+}
+
+func (t *httpTransport) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/mcp", t.handleStreamable)
+	mux.HandleFunc("/sse", t.handleLegacySSE)
+	mux.HandleFunc("/message", t.handleLegacyMessage)
+	return t.authMiddleware(mux)
+}
+
+// ServeHTTP 在 addr 上监听 MCP 网络传输, 直到 ctx 取消。
+// 非回环地址必须配置令牌, 避免把编排与数据库工具暴露给网络上的任意客户端。
+func (s *Server) ServeHTTP(ctx context.Context, addr string, opts HTTPOptions) error {
+	const op = "MCP.ServeHTTP"
+	if strings.TrimSpace(opts.Token) == "" && !isLoopbackAddr(addr) {
+		return apperrors.Newf(op, "MCP_AUTH_TOKEN is required when listening on non-loopback address %s", addr)
+	}
+	t := s.newHTTPTransport(opts)
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return apperrors.Wrapf(err, op, "listen %s", addr)
+	}
+	httpSrv := &http.Server{Handler: t.routes(), ReadHeaderTimeout: httpReadHeaderTimeout}
+	util.SafeGo(func() { t.sweepLoop(ctx) })
+	s.startResourceWatcher(ctx)
+	util.SafeGo(func() {
+		<-ctx.Done()
+		t.closeAll()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+		defer cancel()
+		_ = httpSrv.Shutdown(shutdownCtx)
+	})
+	logger.Info("MCP server listening (http)", logger.FieldAddr, ln.Addr().String(), "auth", opts.Token != "")
+	if err := httpSrv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return apperrors.Wrap(err, op, "serve")
+	}
+	return nil
+}
+
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	return isLoopbackHost(host)
+}
+
+// isLocalOrigin Origin 为空 (非浏览器客户端) 或主机为回环地址。
+func isLocalOrigin(origin string) bool {
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	return isLoopbackHost(u.Hostname())
+}
+
+func isLoopbackHost(host string) bool {
+	host = strings.Trim(host, "[]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (t *httpTransport) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 浏览器跨站请求 (含 DNS rebinding 后的页面) 带非本地 Origin, 一律拒绝。
+		if origin := r.Header.Get("Origin"); !isLocalOrigin(origin) {
+			logger.Warn("mcp: rejected non-local origin", logger.FieldOrigin, origin)
+			http.Error(w, "forbidden origin", http.StatusForbidden)
+			return
+		}
+		if t.opts.Token == "" {
+			// 无令牌仅限本机访问: rebinding 域名解析到 127.0.0.1 时 Host 仍为攻击者域名。
+			host, _, err := net.SplitHostPort(r.Host)
+			if err != nil {
+				host = r.Host
+			}
+			if !isLoopbackHost(host) {
+				logger.Warn("mcp: rejected non-loopback host without token", "host", r.Host)
+				http.Error(w, "forbidden host", http.StatusForbidden)
+				return
+			}
+		}
+		if t.opts.Token != "" {
+			got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(strings.TrimSpace(got)), []byte(t.opts.Token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="mcp"`)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (t *httpTransport) newSession(transport string) *httpSession {
+	ctx, cancel := context.WithCancel(context.Background())
+	now := time.Now()
+	ss := &httpSession{
+		id:        rand.Text(),
+		transport: transport,
+		lastSeen:  now,
+		ctx:       ctx,
+		cancel:    cancel,
+		outbox:    make(chan []byte, sessionOutboxSize),
+	}
+	t.mu.Lock()
+	t.sessions[ss.id] = ss
+	t.mu.Unlock()
+	logger.Info("mcp: session opened", logger.FieldSessionID, ss.id, "transport", transport)
+	return ss
+}
+
+func (t *httpTransport) session(id string) *httpSession {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ss := t.sessions[strings.TrimSpace(id)]
+	if ss != nil {
+		ss.touch()
+	}
+	return ss
+}
+
+func (t *httpTransport) closeSession(id string) bool {
+	t.mu.Lock()
+	ss, ok := t.sessions[id]
+	delete(t.sessions, id)
+	t.mu.Unlock()
+	if ok {
+		ss.cancel()
+		t.srv.subs.dropConn(id)
+		logger.Info("mcp: session closed", logger.FieldSessionID, id)
+	}
+	return ok
+}
+
+func (t *httpTransport) closeAll() {
+	t.mu.Lock()
+	ids := make([]string, 0, len(t.sessions))
+	for id := range t.sessions {
+		ids = append(ids, id)
+	}
+	t.mu.Unlock()
+	for _, id := range ids {
+		t.closeSession(id)
+	}
+}
+
+// sweepExpired 回收空闲超时且无 SSE 连接的会话, 返回回收数量。
+func (t *httpTransport) sweepExpired(now time.Time) int {
+	t.mu.Lock()
+	var expired []string
+	for id, ss := range t.sessions {
+		ss.mu.Lock()
+		idle := ss.streams == 0 && now.Sub(ss.lastSeen) > t.opts.SessionTTL
+		ss.mu.Unlock()
+		if idle {
+			expired = append(expired, id)
+		}
+	}
+	t.mu.Unlock()
+	for _, id := range expired {
+		t.closeSession(id)
+	}
+	return len(expired)
+}
+
+func (t *httpTransport) sweepLoop(ctx context.Context) {
+	ticker := time.NewTicker(sessionSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if n := t.sweepExpired(now); n > 0 {
+				logger.Info("mcp: expired sessions removed", logger.FieldCount, n)
+			}
+		}
+	}
+}
+
+// handleStreamable Streamable HTTP 端点。
+func (t *httpTransport) handleStreamable(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		t.handleStreamablePost(w, r)
+	case http.MethodGet:
+		ss := t.session(r.Header.Get(sessionHeader))
+		if ss == nil {
+			http.Error(w, "unknown or missing session", http.StatusNotFound)
+			return
+		}
+		t.streamSSE(w, r, ss, nil)
+	case http.MethodDelete:
+		if !t.closeSession(r.Header.Get(sessionHeader)) {
+			http.Error(w, "unknown session", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (t *httpTransport) handleStreamablePost(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxHTTPMessageBytes+1))
+	if err != nil || len(body) > maxHTTPMessageBytes {
+		http.Error(w, "invalid or oversized body", http.StatusBadRequest)
+		return
+	}
+	var ss *httpSession
+	if isInitializeMessage(body) {
+		ss = t.newSession(transportStreamable)
+	} else if ss = t.session(r.Header.Get(sessionHeader)); ss == nil {
+		// 规范要求: 未知/过期会话返回 404, 客户端据此重新 initialize。
+		http.Error(w, "unknown or missing session", http.StatusNotFound)
+		return
+	}
+	w.Header().Set(sessionHeader, ss.id)
+	resp := t.srv.HandleMessage(withClientConn(r.Context(), ss), body)
+	if resp == nil { // 仅含通知/响应
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(resp)
+}
+
+// handleLegacySSE 旧版 HTTP+SSE: 建立会话并保持推送流。
+func (t *httpTransport) handleLegacySSE(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ss := t.newSession(transportSSE)
+	defer t.closeSession(ss.id) // 旧版会话生命周期与 SSE 连接一致
+	endpoint := "/message?sessionId=" + ss.id
+	t.streamSSE(w, r, ss, &endpoint)
+}
+
+// handleLegacyMessage 旧版 HTTP+SSE 的请求入口, 响应经会话 SSE 流返回。
+func (t *httpTransport) handleLegacyMessage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ss := t.session(r.URL.Query().Get("sessionId"))
+	if ss == nil {
+		http.Error(w, "unknown session", http.StatusNotFound)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxHTTPMessageBytes+1))
+	if err != nil || len(body) > maxHTTPMessageBytes {
+		http.Error(w, "invalid or oversized body", http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	util.SafeGo(func() {
+		if resp := t.srv.HandleMessage(withClientConn(ss.ctx, ss), body); resp != nil {
+			ss.send(resp)
+		}
+	})
+}
+
+// streamSSE 将会话 outbox 写为 SSE 事件, 直到客户端断开或会话关闭。
+func (t *httpTransport) streamSSE(w http.ResponseWriter, r *http.Request, ss *httpSession, endpoint *string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "SSE not supported", http.StatusInternalServerError)
+		return
+	}
+	ss.mu.Lock()
+	ss.streams++
+	ss.mu.Unlock()
+	defer func() {
+		ss.mu.Lock()
+		ss.streams--
+		ss.lastSeen = time.Now()
+		ss.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set(sessionHeader, ss.id)
+	w.WriteHeader(http.StatusOK)
+	if endpoint != nil {
+		_, _ = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", legacySSEEndpointEvent, *endpoint)
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case msg := <-ss.outbox:
+			_, _ = fmt.Fprintf(w, "event: message\ndata: %s\n\n", msg)
+			flusher.Flush()
+		case <-keepAlive.C:
+			_, _ = io.WriteString(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		case <-ss.ctx.Done():
+			return
+		}
+	}
+}
+
+// isInitializeMessage 判断请求体是否为 initialize 请求 (单条或批量中包含)。
+func isInitializeMessage(body []byte) bool {
+	var single struct {
+		Method string `json:"method"`
+	}
+	if err := json.Unmarshal(body, &single); err == nil {
+		return single.Method == "initialize"
+	}
+	var batch []struct {
+		Method string `json:"method"`
+	}
+	if err := json.Unmarshal(body, &batch); err != nil {
+		return false
+	}
+	for _, msg := range batch {
+		if msg.Method == "initialize" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,168 @@
+package mcp
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func postMCP(t *testing.T, url, token, session, body string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if session != "" {
+		req.Header.Set(sessionHeader, session)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST %s: %v", url, err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestStreamableHTTPSessionLifecycle(t *testing.T) {
+	s, _ := newOrchestratedServer()
+	ts := httptest.NewServer(s.NewHTTPHandler(HTTPOptions{Token: "secret"}))
+	defer ts.Close()
+
+	if resp := postMCP(t, ts.URL+"/mcp", "wrong", "", `{"jsonrpc":"2.0","id":1,"method":"ping"}`); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("bad token status = %d", resp.StatusCode)
+	}
+	if resp := postMCP(t, ts.URL+"/mcp", "secret", "", `{"jsonrpc":"2.0","id":1,"method":"ping"}`); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("request without session status = %d", resp.StatusCode)
+	}
+
+	resp := postMCP(t, ts.URL+"/mcp", "secret", "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26"}}`)
+	session := resp.Header.Get(sessionHeader)
+	if resp.StatusCode != http.StatusOK || session == "" {
+		t.Fatalf("initialize status = %d session = %q", resp.StatusCode, session)
+	}
+	if resp := postMCP(t, ts.URL+"/mcp", "secret", session, `{"jsonrpc":"2.0","method":"notifications/initialized"}`); resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("notification status = %d", resp.StatusCode)
+	}
+	resp = postMCP(t, ts.URL+"/mcp", "secret", session, `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("tools/list status = %d type = %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL+"/mcp", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set(sessionHeader, session)
+	delResp, err := http.DefaultClient.Do(req)
+	if err != nil || delResp.StatusCode != http.StatusNoContent {
+		t.Fatalf("DELETE status = %v err = %v", delResp, err)
+	}
+	delResp.Body.Close()
+	if resp := postMCP(t, ts.URL+"/mcp", "secret", session, `{"jsonrpc":"2.0","id":3,"method":"ping"}`); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("closed session status = %d", resp.StatusCode)
+	}
+}
+
+func TestLegacySSETransport(t *testing.T) {
+	s, _ := newOrchestratedServer()
+	ts := httptest.NewServer(s.NewHTTPHandler(HTTPOptions{}))
+	defer ts.Close()
+
+	stream, err := http.Get(ts.URL + "/sse")
+	if err != nil {
+		t.Fatalf("GET /sse: %v", err)
+	}
+	defer stream.Body.Close()
+	reader := bufio.NewReader(stream.Body)
+	readData := func() string {
+		t.Helper()
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatalf("read sse: %v", err)
+			}
+			if data, ok := strings.CutPrefix(line, "data: "); ok {
+				return strings.TrimSpace(data)
+			}
+		}
+	}
+	endpoint := readData()
+	if !strings.HasPrefix(endpoint, "/message?sessionId=") {
+		t.Fatalf("endpoint = %q", endpoint)
+	}
+	if resp := postMCP(t, ts.URL+endpoint, "", "", `{"jsonrpc":"2.0","id":7,"method":"ping"}`); resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("POST message status = %d", resp.StatusCode)
+	}
+	if msg := readData(); !strings.Contains(msg, `"id":7`) {
+		t.Fatalf("sse message = %q", msg)
+	}
+}
+
+func TestSweepExpiredSessions(t *testing.T) {
+	s, _ := newOrchestratedServer()
+	tr := s.newHTTPTransport(HTTPOptions{SessionTTL: time.Minute})
+	idle := tr.newSession(transportStreamable)
+	streaming := tr.newSession(transportStreamable)
+	streaming.streams = 1
+	if n := tr.sweepExpired(time.Now().Add(2 * time.Minute)); n != 1 {
+		t.Fatalf("swept = %d, want 1", n)
+	}
+	if tr.session(idle.id) != nil || tr.session(streaming.id) == nil {
+		t.Fatal("only the idle session without streams must be removed")
+	}
+	if idle.ctx.Err() == nil {
+		t.Fatal("expired session context must be cancelled")
+	}
+}
+
+func TestServeHTTPRequiresTokenOffLoopback(t *testing.T) {
+	s, _ := newOrchestratedServer()
+	if err := s.ServeHTTP(t.Context(), "0.0.0.0:0", HTTPOptions{}); err == nil {
+		t.Fatal("non-loopback listener without token must be refused")
+	}
+	if !isLoopbackAddr("127.0.0.1:4600") || !isLoopbackAddr("localhost:1") || isLoopbackAddr(":4600") {
+		t.Fatal("isLoopbackAddr mismatch")
+	}
+}
+
+func TestHTTPRejectsNonLocalOriginAndHost(t *testing.T) {
+	s, _ := newOrchestratedServer()
+	ts := httptest.NewServer(s.NewHTTPHandler(HTTPOptions{}))
+	defer ts.Close()
+	initialize := `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26"}}`
+	do := func(origin, host string) int {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/mcp", strings.NewReader(initialize))
+		req.Header.Set("Content-Type", "application/json")
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if host != "" {
+			req.Host = host
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("POST: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := do("", ""); code != http.StatusOK {
+		t.Fatalf("non-browser client status = %d", code)
+	}
+	if code := do("http://localhost:5173", ""); code != http.StatusOK {
+		t.Fatalf("local origin status = %d", code)
+	}
+	for _, origin := range []string{"https://evil.example", "http://localhost.evil.example", "null"} {
+		if code := do(origin, ""); code != http.StatusForbidden {
+			t.Fatalf("origin %q status = %d", origin, code)
+		}
+	}
+	// DNS rebinding: 攻击者域名解析到 127.0.0.1, Host 头仍为该域名。
+	if code := do("", "rebind.evil.example:4600"); code != http.StatusForbidden {
+		t.Fatalf("rebinding host status = %d", code)
+	}
+}
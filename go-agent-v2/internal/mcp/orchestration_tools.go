@@ -0,0 +1,255 @@
+// orchestration_tools.go — 子代理编排工具: spawn_agent / send_task / await_result / agent_status。
+//
+// MCP 客户端 (如 Claude Desktop) 通过这些工具直接驱动 app-server: 创建 codex 子代理线程、
+// 下发任务 (turn/start)、等待结果 (turn/await)。agent_id 即 app-server 的 threadId。
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+const (
+	orchestrationCallTimeout = 60 * time.Second
+	defaultAwaitTimeoutSec   = 60
+	maxAwaitTimeoutSec       = 600
+)
+
+type orchestrationParams struct {
+	AgentID      string `json:"agent_id"`
+	Name         string `json:"name"`
+	Prompt       string `json:"prompt"`
+	Model        string `json:"model"`
+	Cwd          string `json:"cwd"`
+	Instructions string `json:"instructions"`
+	TemplateID   string `json:"template_id"`
+	Priority     string `json:"priority"`
+	TurnID       string `json:"turn_id"`
+	TimeoutSec   *int   `json:"timeout_sec"`
+}
+
+type turnStartResult struct {
+	Turn struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+	} `json:"turn"`
+	Queue   json.RawMessage `json:"queue,omitempty"`
+	DedupOf json.RawMessage `json:"dedupOf,omitempty"`
+}
+
+// orchestrationTools 编排工具定义 (仅在配置了 Orchestrator 时注册)。
+func orchestrationTools() []Tool {
+	return []Tool{
+		{
+			Name:        "spawn_agent",
+			Description: "创建一个新的 codex 子代理 (线程), 可选立即下发首个任务; 返回 agent_id",
+			InputSchema: objectSchema(map[string]any{
+				"name":         stringProp("子代理显示名称"),
+				"prompt":       stringProp("首个任务 (为空则只创建不下发)"),
+				"model":        stringProp("模型, 为空使用默认"),
+				"cwd":          stringProp("工作目录"),
+				"instructions": stringProp("附加的开发者指令"),
+				"template_id":  stringProp("角色模板 ID (agentTemplate/list)"),
+			}),
+		},
+		{
+			Name:        "send_task",
+			Description: "向子代理下发一个任务 (新 turn); 子代理忙时按调度器排队; 返回 turn_id",
+			InputSchema: objectSchema(map[string]any{
+				"agent_id": stringProp("spawn_agent 返回的 agent_id"),
+				"prompt":   stringProp("任务内容"),
+				"priority": map[string]any{"type": "string", "enum": []string{"interactive", "normal", "background"}},
+			}, "agent_id", "prompt"),
+		},
+		{
+			Name:        "await_result",
+			Description: "等待子代理当前 (或指定) turn 结束并返回状态与最终回复摘要; 超时返回 status=running, 可再次等待",
+			InputSchema: objectSchema(map[string]any{
+				"agent_id":    stringProp("子代理 agent_id"),
+				"turn_id":     stringProp("指定 turn, 为空等待当前/最近一次 turn"),
+				"timeout_sec": map[string]any{"type": "integer", "minimum": 0, "maximum": maxAwaitTimeoutSec, "default": defaultAwaitTimeoutSec},
+			}, "agent_id"),
+		},
+	}
+}
+
+func (s *Server) orchestrationHandler(name string) (func(context.Context, orchestrationParams) (any, error), bool) {
+	if s.orch == nil {
+		return nil, false
+	}
+	switch name {
+	case "spawn_agent":
+		return s.spawnAgent, true
+	case "send_task":
+		return s.sendTask, true
+	case "await_result":
+		return s.awaitResult, true
+	}
+	return nil, false
+}
+
+func (s *Server) call(ctx context.Context, method string, params any, out any) error {
+	ctx, cancel := context.WithTimeout(ctx, orchestrationCallTimeout)
+	defer cancel()
+	return s.callRaw(ctx, method, params, out)
+}
+
+func (s *Server) callRaw(ctx context.Context, method string, params any, out any) error {
+	raw, err := s.orch.Call(ctx, method, params)
+	if err != nil {
+		return err
+	}
+	if out == nil || len(raw) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return apperrors.Wrapf(err, "MCP.call", "%s: decode result", method)
+	}
+	return nil
+}
+
+func (s *Server) spawnAgent(ctx context.Context, p orchestrationParams) (any, error) {
+	const op = "MCP.spawnAgent"
+	params := map[string]any{}
+	setIfNotEmpty(params, "model", p.Model)
+	setIfNotEmpty(params, "cwd", p.Cwd)
+	setIfNotEmpty(params, "developerInstructions", p.Instructions)
+	setIfNotEmpty(params, "templateId", p.TemplateID)
+	var started struct {
+		Thread struct {
+			ID string `json:"id"`
+		} `json:"thread"`
+		Model string `json:"model"`
+		Cwd   string `json:"cwd"`
+	}
+	if err := s.call(ctx, "thread/start", params, &started); err != nil {
+		return nil, err
+	}
+	agentID := strings.TrimSpace(started.Thread.ID)
+	if agentID == "" {
+		return nil, apperrors.New(op, "thread/start returned no thread id")
+	}
+	result := map[string]any{"agent_id": agentID, "model": started.Model, "cwd": started.Cwd}
+	if name := strings.TrimSpace(p.Name); name != "" {
+		if err := s.call(ctx, "thread/name/set", map[string]any{"threadId": agentID, "name": name}, nil); err != nil {
+			return nil, apperrors.Wrapf(err, op, "agent %s created but naming failed", agentID)
+		}
+		result["name"] = name
+	}
+	if strings.TrimSpace(p.Prompt) == "" {
+		result["status"] = "idle"
+		return result, nil
+	}
+	p.AgentID = agentID
+	turn, err := s.startTask(ctx, p)
+	if err != nil {
+		return nil, apperrors.Wrapf(err, op, "agent %s created but task submission failed", agentID)
+	}
+	for key, value := range turn {
+		result[key] = value
+	}
+	return result, nil
+}
+
+func (s *Server) sendTask(ctx context.Context, p orchestrationParams) (any, error) {
+	const op = "MCP.sendTask"
+	if strings.TrimSpace(p.AgentID) == "" {
+		return nil, apperrors.New(op, "agent_id is required")
+	}
+	if strings.TrimSpace(p.Prompt) == "" {
+		return nil, apperrors.New(op, "prompt is required")
+	}
+	return s.startTask(ctx, p)
+}
+
+func (s *Server) startTask(ctx context.Context, p orchestrationParams) (map[string]any, error) {
+	agentID := strings.TrimSpace(p.AgentID)
+	params := map[string]any{
+		"threadId": agentID,
+		"input":    []map[string]any{{"type": "text", "text": p.Prompt}},
+	}
+	setIfNotEmpty(params, "priority", p.Priority)
+	var started turnStartResult
+	if err := s.call(ctx, "turn/start", params, &started); err != nil {
+		return nil, err
+	}
+	result := map[string]any{"agent_id": agentID, "turn_id": started.Turn.ID, "status": started.Turn.Status}
+	if len(started.Queue) > 0 && string(started.Queue) != "null" {
+		result["queue"] = started.Queue
+	}
+	if len(started.DedupOf) > 0 && string(started.DedupOf) != "null" {
+		result["dedup_of"] = started.DedupOf
+	}
+	return result, nil
+}
+
+func (s *Server) awaitResult(ctx context.Context, p orchestrationParams) (any, error) {
+	const op = "MCP.awaitResult"
+	agentID := strings.TrimSpace(p.AgentID)
+	if agentID == "" {
+		return nil, apperrors.New(op, "agent_id is required")
+	}
+	timeoutSec := defaultAwaitTimeoutSec
+	if p.TimeoutSec != nil {
+		timeoutSec = min(max(*p.TimeoutSec, 0), maxAwaitTimeoutSec)
+	}
+	// 请求超时 = 等待时间 + 余量, 由 app-server 端负责按 timeoutSec 返回。
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeoutSec)*time.Second+orchestrationCallTimeout)
+	defer cancel()
+	return s.awaitTurn(ctx, agentID, strings.TrimSpace(p.TurnID), timeoutSec)
+}
+
+func (s *Server) awaitTurn(ctx context.Context, agentID, turnID string, timeoutSec int) (map[string]any, error) {
+	params := map[string]any{"threadId": agentID, "timeoutSec": timeoutSec}
+	setIfNotEmpty(params, "turnId", turnID)
+	var awaited struct {
+		TurnID      string `json:"turnId"`
+		Status      string `json:"status"`
+		Summary     string `json:"summary"`
+		Reason      string `json:"reason"`
+		ElapsedMs   int64  `json:"elapsedMs"`
+		CompletedAt int64  `json:"completedAt"`
+	}
+	if err := s.callRaw(ctx, "turn/await", params, &awaited); err != nil {
+		return nil, err
+	}
+	result := map[string]any{"agent_id": agentID, "turn_id": awaited.TurnID, "status": awaited.Status}
+	setIfNotEmpty(result, "result", awaited.Summary)
+	setIfNotEmpty(result, "reason", awaited.Reason)
+	if awaited.ElapsedMs > 0 {
+		result["elapsed_ms"] = awaited.ElapsedMs
+	}
+	if awaited.CompletedAt > 0 {
+		result["completed_at"] = time.UnixMilli(awaited.CompletedAt).UTC().Format(time.RFC3339)
+	}
+	return result, nil
+}
+
+// liveAgentStatus agent_status 指定 agent_id 时的实时状态 (不等待)。
+func (s *Server) liveAgentStatus(ctx context.Context, agentID string) (any, error) {
+	ctx, cancel := context.WithTimeout(ctx, orchestrationCallTimeout)
+	defer cancel()
+	return s.awaitTurn(ctx, agentID, "", 0)
+}
+
+func setIfNotEmpty(m map[string]any, key, value string) {
+	if value = strings.TrimSpace(value); value != "" {
+		m[key] = value
+	}
+}
+
+func stringProp(description string) map[string]any {
+	return map[string]any{"type": "string", "description": description}
+}
+
+func objectSchema(properties map[string]any, required ...string) map[string]any {
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
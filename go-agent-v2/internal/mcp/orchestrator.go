@@ -0,0 +1,88 @@
+// orchestrator.go — 子代理编排后端: 通过 app-server JSON-RPC (/rpc) 驱动线程与 turn。
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+// Orchestrator 编排工具依赖的 JSON-RPC 调用接口 (测试中可替换为内存实现)。
+type Orchestrator interface {
+	Call(ctx context.Context, method string, params any) (json.RawMessage, error)
+}
+
+// HTTPOrchestrator 调用 app-server 的 HTTP JSON-RPC 端点。
+type HTTPOrchestrator struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPOrchestrator 创建编排后端; addr 可为 ws://host:port (app-server --listen) 或 http(s) 地址。
+// 超时由调用方 ctx 控制 (await_result 可能阻塞数分钟)。
+func NewHTTPOrchestrator(addr string) *HTTPOrchestrator {
+	return &HTTPOrchestrator{url: appServerRPCURL(addr), client: &http.Client{}}
+}
+
+// URL 实际请求的 /rpc 地址。
+func (o *HTTPOrchestrator) URL() string { return o.url }
+
+// appServerRPCURL 将监听地址转换为 HTTP /rpc 地址 (已带 /rpc 时保持不变)。
+func appServerRPCURL(addr string) string {
+	url := strings.TrimSpace(addr)
+	switch {
+	case strings.HasPrefix(url, "ws://"):
+		url = "http://" + strings.TrimPrefix(url, "ws://")
+	case strings.HasPrefix(url, "wss://"):
+		url = "https://" + strings.TrimPrefix(url, "wss://")
+	case !strings.Contains(url, "://"):
+		url = "http://" + url
+	}
+	url = strings.TrimRight(url, "/")
+	if strings.HasSuffix(url, "/rpc") {
+		return url
+	}
+	return url + "/rpc"
+}
+
+// Call 发送单个 JSON-RPC 请求并返回 result。
+func (o *HTTPOrchestrator) Call(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	const op = "MCP.Orchestrator.Call"
+	body, err := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+	if err != nil {
+		return nil, apperrors.Wrap(err, op, "marshal request")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, apperrors.Wrap(err, op, "build request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, apperrors.Wrapf(err, op, "%s: app-server unreachable", method)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return nil, apperrors.Wrap(err, op, "read response")
+	}
+	var out struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil, apperrors.Wrapf(err, op, "%s: decode response (HTTP %d)", method, resp.StatusCode)
+	}
+	if out.Error != nil {
+		return nil, apperrors.Newf(op, "%s failed: %s (code %d)", method, out.Error.Message, out.Error.Code)
+	}
+	return out.Result, nil
+}
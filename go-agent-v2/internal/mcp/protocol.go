@@ -0,0 +1,269 @@
+// protocol.go — MCP JSON-RPC 消息分发 (initialize / ping / tools/list / tools/call)。
+//
+// 与传输层无关: HandleMessage 接收一条 (或一批) JSON-RPC 消息, 返回应写回的响应字节,
+// 通知类消息返回 nil。
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+const (
+	mcpServerName   = "multi-agent-orchestration"
+	mcpServerVer    = "2.0.0"
+	latestProtocol  = "2025-03-26"
+	maxToolTextSize = 256 << 10
+)
+
+var supportedProtocols = []string{"2025-03-26", "2024-11-05"}
+
+// JSON-RPC 错误码。
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+)
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// HandleMessage 处理一条 JSON-RPC 消息或批量数组, 返回响应 (无需响应时为 nil)。
+func (s *Server) HandleMessage(ctx context.Context, raw []byte) []byte {
+	trimmed := strings.TrimSpace(string(raw))
+	if trimmed == "" {
+		return nil
+	}
+	if strings.HasPrefix(trimmed, "[") {
+		var batch []json.RawMessage
+		if err := json.Unmarshal([]byte(trimmed), &batch); err != nil || len(batch) == 0 {
+			return marshalResponse(errorResponse(nil, rpcInvalidRequest, "invalid batch"))
+		}
+		var responses []rpcResponse
+		for _, item := range batch {
+			if resp := s.dispatch(ctx, item); resp != nil {
+				responses = append(responses, *resp)
+			}
+		}
+		if len(responses) == 0 {
+			return nil
+		}
+		out, _ := json.Marshal(responses)
+		return out
+	}
+	if resp := s.dispatch(ctx, []byte(trimmed)); resp != nil {
+		return marshalResponse(*resp)
+	}
+	return nil
+}
+
+func (s *Server) dispatch(ctx context.Context, raw []byte) *rpcResponse {
+	var req rpcRequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		resp := errorResponse(nil, rpcParseError, "parse error")
+		return &resp
+	}
+	isNotification := len(req.ID) == 0 || string(req.ID) == "null"
+	if req.Method == "" {
+		if isNotification { // 客户端对服务端请求的响应, 目前无服务端发起的请求
+			return nil
+		}
+		resp := errorResponse(req.ID, rpcInvalidRequest, "method is required")
+		return &resp
+	}
+	result, rpcErr := s.handleMethod(ctx, req)
+	if isNotification {
+		return nil
+	}
+	if rpcErr != nil {
+		resp := errorResponse(req.ID, rpcErr.Code, rpcErr.Message)
+		return &resp
+	}
+	return &rpcResponse{JSONRPC: "2.0", ID: req.ID, Result: result}
+}
+
+func (s *Server) handleMethod(ctx context.Context, req rpcRequest) (any, *rpcError) {
+	switch req.Method {
+	case "initialize":
+		var p struct {
+			ProtocolVersion string `json:"protocolVersion"`
+			ClientInfo      struct {
+				Name    string `json:"name"`
+				Version string `json:"version"`
+			} `json:"clientInfo"`
+		}
+		_ = json.Unmarshal(req.Params, &p)
+		version := latestProtocol
+		if slices.Contains(supportedProtocols, p.ProtocolVersion) {
+			version = p.ProtocolVersion
+		}
+		logger.Info("mcp: client initialized",
+			"client", p.ClientInfo.Name,
+			"client_version", p.ClientInfo.Version,
+			"protocol", version,
+		)
+		capabilities := map[string]any{"tools": map[string]any{"listChanged": false}}
+		if s.hasResources() {
+			capabilities["resources"] = map[string]any{"subscribe": true, "listChanged": false}
+		}
+		return map[string]any{
+			"protocolVersion": version,
+			"capabilities":    capabilities,
+			"serverInfo":      map[string]any{"name": mcpServerName, "version": mcpServerVer},
+		}, nil
+	case "ping":
+		return map[string]any{}, nil
+	case "notifications/initialized", "notifications/cancelled":
+		return nil, nil
+	case "tools/list":
+		tools := s.listTools()
+		out := make([]map[string]any, 0, len(tools))
+		for _, tool := range tools {
+			schema := tool.InputSchema
+			if schema == nil {
+				schema = objectSchema(map[string]any{})
+			}
+			out = append(out, map[string]any{
+				"name":        tool.Name,
+				"description": tool.Description,
+				"inputSchema": schema,
+			})
+		}
+		return map[string]any{"tools": out}, nil
+	case "tools/call":
+		var p struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(req.Params, &p); err != nil || strings.TrimSpace(p.Name) == "" {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: "tools/call requires name"}
+		}
+		if !s.hasTool(p.Name) {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: "unknown tool: " + p.Name}
+		}
+		return s.callTool(ctx, p.Name, p.Arguments), nil
+	case "resources/list", "resources/templates/list", "resources/read", "resources/subscribe", "resources/unsubscribe":
+		if s.hasResources() {
+			return s.handleResourceMethod(ctx, req)
+		}
+	}
+	return nil, &rpcError{Code: rpcMethodNotFound, Message: "method not found: " + req.Method}
+}
+
+func (s *Server) handleResourceMethod(ctx context.Context, req rpcRequest) (any, *rpcError) {
+	var p struct {
+		URI string `json:"uri"`
+	}
+	_ = json.Unmarshal(req.Params, &p)
+	switch req.Method {
+	case "resources/list":
+		resources, err := s.listResources(ctx)
+		if err != nil {
+			logger.Warn("mcp: list resources failed", logger.FieldError, err)
+			return nil, &rpcError{Code: rpcInternalError, Message: err.Error()}
+		}
+		if resources == nil {
+			resources = []map[string]any{}
+		}
+		return map[string]any{"resources": resources}, nil
+	case "resources/templates/list":
+		return map[string]any{"resourceTemplates": resourceTemplates()}, nil
+	}
+	if _, _, err := parseResourceURI(p.URI); err != nil {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+	}
+	switch req.Method {
+	case "resources/read":
+		content, err := s.readResource(ctx, p.URI)
+		if err != nil {
+			return nil, &rpcError{Code: rpcInternalError, Message: err.Error()}
+		}
+		if content == nil {
+			return nil, &rpcError{Code: rpcResourceNotFound, Message: "resource not found: " + p.URI}
+		}
+		return map[string]any{"contents": []map[string]any{content}}, nil
+	case "resources/subscribe":
+		if err := s.subscribeResource(ctx, p.URI); err != nil {
+			return nil, &rpcError{Code: rpcInternalError, Message: err.Error()}
+		}
+		return map[string]any{}, nil
+	default: // resources/unsubscribe
+		if conn := clientConnFrom(ctx); conn != nil {
+			s.subs.unsubscribe(p.URI, conn.connID())
+		}
+		return map[string]any{}, nil
+	}
+}
+
+func (s *Server) hasTool(name string) bool {
+	for _, tool := range s.listTools() {
+		if tool.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// callTool 执行工具并封装为 MCP CallToolResult; 工具错误以 isError 返回给模型而非协议错误。
+func (s *Server) callTool(ctx context.Context, name string, args json.RawMessage) map[string]any {
+	result, err := s.HandleTool(ctx, name, args)
+	if err != nil {
+		logger.Warn("mcp: tool call failed", logger.FieldToolName, name, logger.FieldError, err)
+		return map[string]any{
+			"content": []map[string]any{{"type": "text", "text": err.Error()}},
+			"isError": true,
+		}
+	}
+	text, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return map[string]any{
+			"content": []map[string]any{{"type": "text", "text": "encode result: " + err.Error()}},
+			"isError": true,
+		}
+	}
+	out := string(text)
+	if len(out) > maxToolTextSize {
+		out = strings.ToValidUTF8(out[:maxToolTextSize], "") + "\n... (truncated)"
+	}
+	return map[string]any{
+		"content": []map[string]any{{"type": "text", "text": out}},
+		"isError": false,
+	}
+}
+
+func errorResponse(id json.RawMessage, code int, message string) rpcResponse {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	return rpcResponse{JSONRPC: "2.0", ID: id, Error: &rpcError{Code: code, Message: message}}
+}
+
+func marshalResponse(resp rpcResponse) []byte {
+	out, err := json.Marshal(resp)
+	if err != nil {
+		out, _ = json.Marshal(errorResponse(resp.ID, rpcInvalidRequest, "encode response: "+err.Error()))
+	}
+	return out
+}
@@ -0,0 +1,171 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type fakeOrchestrator struct {
+	mu      sync.Mutex
+	calls   []string
+	params  []map[string]any
+	results map[string]string
+}
+
+func (f *fakeOrchestrator) Call(_ context.Context, method string, params any) (json.RawMessage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	raw, _ := json.Marshal(params)
+	var decoded map[string]any
+	_ = json.Unmarshal(raw, &decoded)
+	f.calls = append(f.calls, method)
+	f.params = append(f.params, decoded)
+	return json.RawMessage(f.results[method]), nil
+}
+
+func newOrchestratedServer() (*Server, *fakeOrchestrator) {
+	orch := &fakeOrchestrator{results: map[string]string{
+		"thread/start":    `{"thread":{"id":"thread-1"},"model":"o3","cwd":"/repo"}`,
+		"thread/name/set": `{}`,
+		"turn/start":      `{"turn":{"id":"turn-1","status":"inProgress"}}`,
+		"turn/await":      `{"threadId":"thread-1","turnId":"turn-1","status":"completed","summary":"done","completedAt":1700000000000}`,
+	}}
+	s := NewServer(nil)
+	s.SetOrchestrator(orch)
+	return s, orch
+}
+
+func decodeResponse(t *testing.T, raw []byte) map[string]any {
+	t.Helper()
+	var out map[string]any
+	if err := json.Unmarshal(raw, &out); err != nil {
+		t.Fatalf("decode response %q: %v", raw, err)
+	}
+	return out
+}
+
+func TestHandleMessageInitializeAndNotifications(t *testing.T) {
+	s, _ := newOrchestratedServer()
+	resp := decodeResponse(t, s.HandleMessage(context.Background(),
+		[]byte(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05","clientInfo":{"name":"test"}}}`)))
+	result := resp["result"].(map[string]any)
+	if result["protocolVersion"] != "2024-11-05" {
+		t.Fatalf("protocolVersion = %v", result["protocolVersion"])
+	}
+	if out := s.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)); out != nil {
+		t.Fatalf("notification must not be answered, got %s", out)
+	}
+	resp = decodeResponse(t, s.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":2,"method":"nope"}`)))
+	if code := resp["error"].(map[string]any)["code"].(float64); code != rpcMethodNotFound {
+		t.Fatalf("error code = %v", code)
+	}
+}
+
+func TestToolsListDependsOnBackends(t *testing.T) {
+	s, _ := newOrchestratedServer()
+	names := map[string]bool{}
+	for _, tool := range s.listTools() {
+		names[tool.Name] = true
+	}
+	for _, name := range []string{"spawn_agent", "send_task", "await_result", "agent_status"} {
+		if !names[name] {
+			t.Errorf("missing tool %s in %v", name, names)
+		}
+	}
+	if names["db_query"] {
+		t.Fatal("database tools must be hidden without stores")
+	}
+
+	withStores := NewServer(&Stores{})
+	for _, tool := range withStores.listTools() {
+		if tool.Name == "spawn_agent" || tool.Name == "config_manage" {
+			t.Fatalf("unexpected tool %s", tool.Name)
+		}
+	}
+}
+
+func TestToolsCallSpawnAgentWithPrompt(t *testing.T) {
+	s, orch := newOrchestratedServer()
+	resp := decodeResponse(t, s.HandleMessage(context.Background(), []byte(
+		`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"spawn_agent","arguments":{"name":"worker","prompt":"fix tests","model":"o3"}}}`)))
+	result := resp["result"].(map[string]any)
+	if result["isError"] != false {
+		t.Fatalf("spawn_agent failed: %v", result)
+	}
+	text := result["content"].([]any)[0].(map[string]any)["text"].(string)
+	var payload map[string]any
+	if err := json.Unmarshal([]byte(text), &payload); err != nil {
+		t.Fatalf("decode tool text: %v", err)
+	}
+	if payload["agent_id"] != "thread-1" || payload["turn_id"] != "turn-1" || payload["name"] != "worker" {
+		t.Fatalf("payload = %v", payload)
+	}
+	if got := strings.Join(orch.calls, ","); got != "thread/start,thread/name/set,turn/start" {
+		t.Fatalf("calls = %s", got)
+	}
+	input := orch.params[2]["input"].([]any)[0].(map[string]any)
+	if orch.params[2]["threadId"] != "thread-1" || input["text"] != "fix tests" {
+		t.Fatalf("turn/start params = %v", orch.params[2])
+	}
+}
+
+func TestToolsCallValidationAndAwait(t *testing.T) {
+	s, orch := newOrchestratedServer()
+	ctx := context.Background()
+	if _, err := s.HandleTool(ctx, "send_task", json.RawMessage(`{"agent_id":"thread-1"}`)); err == nil {
+		t.Fatal("send_task without prompt must fail")
+	}
+	out, err := s.HandleTool(ctx, "await_result", json.RawMessage(`{"agent_id":"thread-1","timeout_sec":9999}`))
+	if err != nil {
+		t.Fatalf("await_result: %v", err)
+	}
+	result := out.(map[string]any)
+	if result["status"] != "completed" || result["result"] != "done" {
+		t.Fatalf("await_result = %v", result)
+	}
+	if timeout := orch.params[len(orch.params)-1]["timeoutSec"].(float64); timeout != maxAwaitTimeoutSec {
+		t.Fatalf("timeoutSec = %v, want clamp to %d", timeout, maxAwaitTimeoutSec)
+	}
+	if _, err := s.HandleTool(ctx, "agent_status", json.RawMessage(`{"agent_id":"thread-1"}`)); err != nil {
+		t.Fatalf("live agent_status: %v", err)
+	}
+	if timeout := orch.params[len(orch.params)-1]["timeoutSec"].(float64); timeout != 0 {
+		t.Fatalf("agent_status must not wait, timeoutSec = %v", timeout)
+	}
+	if _, err := s.HandleTool(ctx, "agent_status", nil); err == nil {
+		t.Fatal("agent_status list without database must fail")
+	}
+}
+
+func TestServeStdio(t *testing.T) {
+	s, _ := newOrchestratedServer()
+	in := strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"ping"}` + "\n" +
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}` + "\n" +
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}` + "\n")
+	var out bytes.Buffer
+	if err := s.ServeStdio(context.Background(), in, &out); err != nil {
+		t.Fatalf("ServeStdio: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("responses = %q", out.String())
+	}
+}
+
+func TestAppServerRPCURL(t *testing.T) {
+	cases := map[string]string{
+		"ws://127.0.0.1:4500":       "http://127.0.0.1:4500/rpc",
+		"wss://host:443/":           "https://host:443/rpc",
+		"127.0.0.1:4500":            "http://127.0.0.1:4500/rpc",
+		"http://127.0.0.1:4500/rpc": "http://127.0.0.1:4500/rpc",
+	}
+	for in, want := range cases {
+		if got := appServerRPCURL(in); got != want {
+			t.Errorf("appServerRPCURL(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,370 @@
+// resources.go — MCP 资源: 共享文件与任务追踪 (resources/list / read / templates/list / subscribe)。
+//
+// URI 约定:
+//   - mcp://shared/{path}     共享文件 (shared_files, path 按段 URL 转义)
+//   - mcp://traces/{trace_id} 一条任务追踪的全部 span (task_traces, JSON)
+//
+// 订阅: 客户端 resources/subscribe 后, 后台按 resourcePollInterval 比对资源指纹, 变化时向
+// 订阅连接推送 notifications/resources/updated; 经 shared_file 工具写入时立即推送。
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"mime"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/store"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+	"github.com/multi-agent/go-agent-v2/pkg/util"
+)
+
+const (
+	sharedURIPrefix      = "mcp://shared/"
+	traceURIPrefix       = "mcp://traces/"
+	resourcePollInterval = 5 * time.Second
+	resourceListLimit    = 200
+	rpcResourceNotFound  = -32002
+)
+
+// sharedFileSource 共享文件读取 (*store.SharedFileStore)。
+type sharedFileSource interface {
+	Read(ctx context.Context, path string) (*store.SharedFile, error)
+	List(ctx context.Context, prefix string, limit int) ([]store.SharedFile, error)
+}
+
+// traceSource 任务追踪读取 (*store.TaskTraceStore)。
+type traceSource interface {
+	ListByTraceID(ctx context.Context, traceID string) ([]store.TaskTrace, error)
+	List(ctx context.Context, agentID, keyword string, since *time.Time, limit int) ([]store.TaskTrace, error)
+}
+
+// clientConn 可接收服务端通知的客户端连接 (stdio 进程或 HTTP 会话)。
+type clientConn interface {
+	connID() string
+	notify(msg []byte) bool
+}
+
+type clientConnKey struct{}
+
+func withClientConn(ctx context.Context, conn clientConn) context.Context {
+	return context.WithValue(ctx, clientConnKey{}, conn)
+}
+
+func clientConnFrom(ctx context.Context) clientConn {
+	conn, _ := ctx.Value(clientConnKey{}).(clientConn)
+	return conn
+}
+
+// resourceSubscriptions URI → 订阅连接 (零值可用)。
+type resourceSubscriptions struct {
+	mu    sync.Mutex
+	byURI map[string]*resourceSub
+}
+
+type resourceSub struct {
+	conns       map[string]clientConn
+	fingerprint string
+}
+
+func (rs *resourceSubscriptions) subscribe(uri string, conn clientConn, fingerprint string) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if rs.byURI == nil {
+		rs.byURI = make(map[string]*resourceSub)
+	}
+	sub := rs.byURI[uri]
+	if sub == nil {
+		sub = &resourceSub{conns: make(map[string]clientConn), fingerprint: fingerprint}
+		rs.byURI[uri] = sub
+	}
+	sub.conns[conn.connID()] = conn
+}
+
+func (rs *resourceSubscriptions) unsubscribe(uri, connID string) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if sub := rs.byURI[uri]; sub != nil {
+		delete(sub.conns, connID)
+		if len(sub.conns) == 0 {
+			delete(rs.byURI, uri)
+		}
+	}
+}
+
+// dropConn 连接断开时移除其全部订阅。
+func (rs *resourceSubscriptions) dropConn(connID string) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	for uri, sub := range rs.byURI {
+		delete(sub.conns, connID)
+		if len(sub.conns) == 0 {
+			delete(rs.byURI, uri)
+		}
+	}
+}
+
+func (rs *resourceSubscriptions) uris() []string {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	out := make([]string, 0, len(rs.byURI))
+	for uri := range rs.byURI {
+		out = append(out, uri)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// update 记录新指纹; 变化时返回需通知的连接。
+func (rs *resourceSubscriptions) update(uri, fingerprint string) []clientConn {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	sub := rs.byURI[uri]
+	if sub == nil || sub.fingerprint == fingerprint {
+		return nil
+	}
+	sub.fingerprint = fingerprint
+	conns := make([]clientConn, 0, len(sub.conns))
+	for _, conn := range sub.conns {
+		conns = append(conns, conn)
+	}
+	return conns
+}
+
+func (s *Server) hasResources() bool {
+	return s.sharedFiles != nil || s.traces != nil
+}
+
+func sharedFileURI(filePath string) string {
+	segments := strings.Split(strings.TrimPrefix(filePath, "/"), "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+	return sharedURIPrefix + strings.Join(segments, "/")
+}
+
+func traceURI(traceID string) string {
+	return traceURIPrefix + url.PathEscape(traceID)
+}
+
+// parseResourceURI 解析资源 URI, 返回类型 (shared/trace) 与标识。
+func parseResourceURI(uri string) (kind, id string, err error) {
+	const op = "MCP.parseResourceURI"
+	var raw string
+	switch {
+	case strings.HasPrefix(uri, sharedURIPrefix):
+		kind, raw = "shared", strings.TrimPrefix(uri, sharedURIPrefix)
+	case strings.HasPrefix(uri, traceURIPrefix):
+		kind, raw = "trace", strings.TrimPrefix(uri, traceURIPrefix)
+	default:
+		return "", "", apperrors.Newf(op, "unsupported resource uri: %s", uri)
+	}
+	id, err = url.PathUnescape(raw)
+	if err != nil || strings.TrimSpace(id) == "" {
+		return "", "", apperrors.Newf(op, "invalid resource uri: %s", uri)
+	}
+	if kind == "trace" && strings.Contains(id, "/") {
+		return "", "", apperrors.Newf(op, "invalid trace id in uri: %s", uri)
+	}
+	return kind, id, nil
+}
+
+func sharedFileMIME(filePath string) string {
+	if ext := path.Ext(filePath); ext != "" {
+		if typ := mime.TypeByExtension(ext); typ != "" {
+			return typ
+		}
+	}
+	return "text/plain"
+}
+
+func resourceTemplates() []map[string]any {
+	return []map[string]any{
+		{
+			"uriTemplate": sharedURIPrefix + "{+path}",
+			"name":        "shared-file",
+			"description": "编排共享文件 (shared_file 工具写入的产物)",
+		},
+		{
+			"uriTemplate": traceURIPrefix + "{trace_id}",
+			"name":        "task-trace",
+			"description": "任务追踪的全部 span (JSON)",
+			"mimeType":    "application/json",
+		},
+	}
+}
+
+// listResources 列出最近的共享文件与任务追踪。
+func (s *Server) listResources(ctx context.Context) ([]map[string]any, error) {
+	var out []map[string]any
+	if s.sharedFiles != nil {
+		files, err := s.sharedFiles.List(ctx, "", resourceListLimit)
+		if err != nil {
+			return nil, apperrors.Wrap(err, "MCP.listResources", "list shared files")
+		}
+		for _, f := range files {
+			out = append(out, map[string]any{
+				"uri":      sharedFileURI(f.Path),
+				"name":     f.Path,
+				"mimeType": sharedFileMIME(f.Path),
+				"size":     len(f.Content),
+			})
+		}
+	}
+	if s.traces != nil {
+		spans, err := s.traces.List(ctx, "", "", nil, resourceListLimit)
+		if err != nil {
+			return nil, apperrors.Wrap(err, "MCP.listResources", "list task traces")
+		}
+		seen := map[string]bool{}
+		for _, span := range spans {
+			if span.TraceID == "" || seen[span.TraceID] {
+				continue
+			}
+			seen[span.TraceID] = true
+			out = append(out, map[string]any{
+				"uri":         traceURI(span.TraceID),
+				"name":        "trace " + span.TraceID,
+				"description": strings.TrimSpace(span.Component + " " + span.SpanName),
+				"mimeType":    "application/json",
+			})
+		}
+	}
+	return out, nil
+}
+
+// readResource 读取资源内容; 不存在时返回 (nil, nil)。
+func (s *Server) readResource(ctx context.Context, uri string) (map[string]any, error) {
+	kind, id, err := parseResourceURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	switch kind {
+	case "shared":
+		if s.sharedFiles == nil {
+			return nil, nil
+		}
+		f, err := s.sharedFiles.Read(ctx, id)
+		if err != nil || f == nil {
+			return nil, err
+		}
+		return map[string]any{"uri": uri, "mimeType": sharedFileMIME(f.Path), "text": f.Content}, nil
+	default:
+		if s.traces == nil {
+			return nil, nil
+		}
+		spans, err := s.traces.ListByTraceID(ctx, id)
+		if err != nil || len(spans) == 0 {
+			return nil, err
+		}
+		text, err := json.MarshalIndent(spans, "", "  ")
+		if err != nil {
+			return nil, apperrors.Wrap(err, "MCP.readResource", "encode spans")
+		}
+		return map[string]any{"uri": uri, "mimeType": "application/json", "text": string(text)}, nil
+	}
+}
+
+// resourceFingerprint 资源内容指纹, 用于订阅变更检测 ("absent" = 不存在)。
+func (s *Server) resourceFingerprint(ctx context.Context, uri string) (string, error) {
+	kind, id, err := parseResourceURI(uri)
+	if err != nil {
+		return "", err
+	}
+	if kind == "shared" {
+		if s.sharedFiles == nil {
+			return "absent", nil
+		}
+		f, err := s.sharedFiles.Read(ctx, id)
+		if err != nil {
+			return "", err
+		}
+		if f == nil {
+			return "absent", nil
+		}
+		return fmt.Sprintf("%d:%d", f.UpdatedAt.UnixNano(), len(f.Content)), nil
+	}
+	if s.traces == nil {
+		return "absent", nil
+	}
+	spans, err := s.traces.ListByTraceID(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if len(spans) == 0 {
+		return "absent", nil
+	}
+	h := fnv.New64a()
+	for _, span := range spans {
+		finished := int64(0)
+		if span.FinishedAt != nil {
+			finished = span.FinishedAt.UnixNano()
+		}
+		_, _ = fmt.Fprintf(h, "%s|%s|%d|%d;", span.SpanID, span.Status, span.DurationMS, finished)
+	}
+	return fmt.Sprintf("%d:%x", len(spans), h.Sum64()), nil
+}
+
+func (s *Server) subscribeResource(ctx context.Context, uri string) error {
+	conn := clientConnFrom(ctx)
+	if conn == nil {
+		return apperrors.New("MCP.subscribeResource", "transport does not support notifications")
+	}
+	fingerprint, err := s.resourceFingerprint(ctx, uri)
+	if err != nil {
+		return err
+	}
+	s.subs.subscribe(uri, conn, fingerprint)
+	logger.Info("mcp: resource subscribed", logger.FieldSessionID, conn.connID(), logger.FieldURL, uri)
+	return nil
+}
+
+// refreshResource 重新计算指纹, 变化时通知订阅者。
+func (s *Server) refreshResource(ctx context.Context, uri string) {
+	fingerprint, err := s.resourceFingerprint(ctx, uri)
+	if err != nil {
+		logger.Debug("mcp: resource fingerprint failed", logger.FieldURL, uri, logger.FieldError, err)
+		return
+	}
+	conns := s.subs.update(uri, fingerprint)
+	if len(conns) == 0 {
+		return
+	}
+	msg, _ := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"method":  "notifications/resources/updated",
+		"params":  map[string]any{"uri": uri},
+	})
+	for _, conn := range conns {
+		conn.notify(msg)
+	}
+}
+
+// startResourceWatcher 后台轮询已订阅资源, 直到 ctx 取消。
+func (s *Server) startResourceWatcher(ctx context.Context) {
+	if !s.hasResources() {
+		return
+	}
+	util.SafeGo(func() {
+		ticker := time.NewTicker(resourcePollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				for _, uri := range s.subs.uris() {
+					s.refreshResource(ctx, uri)
+				}
+			}
+		}
+	})
+}
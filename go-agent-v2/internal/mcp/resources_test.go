@@ -0,0 +1,171 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/store"
+)
+
+type fakeSharedFiles struct {
+	mu    sync.Mutex
+	files map[string]store.SharedFile
+}
+
+func (f *fakeSharedFiles) Read(_ context.Context, path string) (*store.SharedFile, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, ok := f.files[path]
+	if !ok {
+		return nil, nil
+	}
+	return &file, nil
+}
+
+func (f *fakeSharedFiles) List(_ context.Context, _ string, _ int) ([]store.SharedFile, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []store.SharedFile
+	for _, file := range f.files {
+		out = append(out, file)
+	}
+	return out, nil
+}
+
+func (f *fakeSharedFiles) put(path, content string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.files[path] = store.SharedFile{Path: path, Content: content, UpdatedAt: time.Now()}
+}
+
+type fakeTraces struct{ spans []store.TaskTrace }
+
+func (f *fakeTraces) ListByTraceID(_ context.Context, traceID string) ([]store.TaskTrace, error) {
+	var out []store.TaskTrace
+	for _, span := range f.spans {
+		if span.TraceID == traceID {
+			out = append(out, span)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeTraces) List(context.Context, string, string, *time.Time, int) ([]store.TaskTrace, error) {
+	return f.spans, nil
+}
+
+type recordingConn struct {
+	mu   sync.Mutex
+	msgs []string
+}
+
+func (c *recordingConn) connID() string { return "conn-1" }
+
+func (c *recordingConn) notify(msg []byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.msgs = append(c.msgs, string(msg))
+	return true
+}
+
+func newResourceServer() (*Server, *fakeSharedFiles) {
+	shared := &fakeSharedFiles{files: map[string]store.SharedFile{}}
+	shared.put("docs/plan v1.md", "# plan")
+	s := NewServer(nil)
+	s.sharedFiles = shared
+	s.traces = &fakeTraces{spans: []store.TaskTrace{
+		{TraceID: "tr-1", SpanID: "s1", SpanName: "turn", Status: "running"},
+		{TraceID: "tr-1", SpanID: "s2", SpanName: "tool", Status: "ok"},
+	}}
+	return s, shared
+}
+
+func TestParseResourceURI(t *testing.T) {
+	kind, id, err := parseResourceURI(sharedFileURI("docs/plan v1.md"))
+	if err != nil || kind != "shared" || id != "docs/plan v1.md" {
+		t.Fatalf("shared uri = %s %q %v", kind, id, err)
+	}
+	if kind, id, err := parseResourceURI("mcp://traces/tr-1"); err != nil || kind != "trace" || id != "tr-1" {
+		t.Fatalf("trace uri = %s %q %v", kind, id, err)
+	}
+	for _, bad := range []string{"file:///etc/passwd", "mcp://shared/", "mcp://traces/a/b"} {
+		if _, _, err := parseResourceURI(bad); err == nil {
+			t.Errorf("parseResourceURI(%q) must fail", bad)
+		}
+	}
+}
+
+func TestResourcesListAndRead(t *testing.T) {
+	s, _ := newResourceServer()
+	ctx := context.Background()
+	resp := decodeResponse(t, s.HandleMessage(ctx, []byte(`{"jsonrpc":"2.0","id":1,"method":"resources/list"}`)))
+	resources := resp["result"].(map[string]any)["resources"].([]any)
+	if len(resources) != 2 {
+		t.Fatalf("resources = %v", resources)
+	}
+
+	uri := sharedFileURI("docs/plan v1.md")
+	resp = decodeResponse(t, s.HandleMessage(ctx, []byte(`{"jsonrpc":"2.0","id":2,"method":"resources/read","params":{"uri":"`+uri+`"}}`)))
+	content := resp["result"].(map[string]any)["contents"].([]any)[0].(map[string]any)
+	if content["text"] != "# plan" || !strings.HasPrefix(content["mimeType"].(string), "text/") {
+		t.Fatalf("shared content = %v", content)
+	}
+
+	resp = decodeResponse(t, s.HandleMessage(ctx, []byte(`{"jsonrpc":"2.0","id":3,"method":"resources/read","params":{"uri":"mcp://traces/tr-1"}}`)))
+	text := resp["result"].(map[string]any)["contents"].([]any)[0].(map[string]any)["text"].(string)
+	var spans []store.TaskTrace
+	if err := json.Unmarshal([]byte(text), &spans); err != nil || len(spans) != 2 {
+		t.Fatalf("trace spans = %v err = %v", spans, err)
+	}
+
+	resp = decodeResponse(t, s.HandleMessage(ctx, []byte(`{"jsonrpc":"2.0","id":4,"method":"resources/read","params":{"uri":"mcp://shared/missing.txt"}}`)))
+	if code := resp["error"].(map[string]any)["code"].(float64); code != rpcResourceNotFound {
+		t.Fatalf("missing resource code = %v", code)
+	}
+}
+
+func TestResourceSubscriptionNotifiesOnChange(t *testing.T) {
+	s, shared := newResourceServer()
+	conn := &recordingConn{}
+	ctx := withClientConn(context.Background(), conn)
+	uri := sharedFileURI("docs/plan v1.md")
+
+	resp := decodeResponse(t, s.HandleMessage(ctx, []byte(`{"jsonrpc":"2.0","id":1,"method":"resources/subscribe","params":{"uri":"`+uri+`"}}`)))
+	if resp["error"] != nil {
+		t.Fatalf("subscribe: %v", resp["error"])
+	}
+	s.refreshResource(ctx, uri)
+	if len(conn.msgs) != 0 {
+		t.Fatalf("unchanged resource must not notify: %v", conn.msgs)
+	}
+	time.Sleep(time.Millisecond)
+	shared.put("docs/plan v1.md", "# plan v2")
+	s.refreshResource(ctx, uri)
+	if len(conn.msgs) != 1 || !strings.Contains(conn.msgs[0], "notifications/resources/updated") {
+		t.Fatalf("notifications = %v", conn.msgs)
+	}
+
+	s.HandleMessage(ctx, []byte(`{"jsonrpc":"2.0","id":2,"method":"resources/unsubscribe","params":{"uri":"`+uri+`"}}`))
+	if uris := s.subs.uris(); len(uris) != 0 {
+		t.Fatalf("uris after unsubscribe = %v", uris)
+	}
+}
+
+func TestResourceSubscribeRequiresConnection(t *testing.T) {
+	s, _ := newResourceServer()
+	resp := decodeResponse(t, s.HandleMessage(context.Background(),
+		[]byte(`{"jsonrpc":"2.0","id":1,"method":"resources/subscribe","params":{"uri":"mcp://traces/tr-1"}}`)))
+	if resp["error"] == nil {
+		t.Fatal("subscribe without a notification channel must fail")
+	}
+	withoutResources, _ := newOrchestratedServer()
+	resp = decodeResponse(t, withoutResources.HandleMessage(context.Background(),
+		[]byte(`{"jsonrpc":"2.0","id":2,"method":"resources/list"}`)))
+	if code := resp["error"].(map[string]any)["code"].(float64); code != rpcMethodNotFound {
+		t.Fatalf("resources/list without sources code = %v", code)
+	}
+}
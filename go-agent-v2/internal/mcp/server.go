@@ -4,6 +4,7 @@ package mcp
 import (
 	"context"
 	"encoding/json"
+	"os"
 
 	"github.com/multi-agent/go-agent-v2/internal/store"
 	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
@@ -13,6 +14,12 @@ import (
 // Server MCP 服务器。
 type Server struct {
 	stores *Stores
+	orch   Orchestrator // 可选: 子代理编排工具后端
+
+	// MCP 资源 (共享文件 / 任务追踪) 与订阅
+	sharedFiles sharedFileSource
+	traces      traceSource
+	subs        resourceSubscriptions
 }
 
 // Stores MCP 工具依赖。
@@ -28,28 +35,35 @@ type Stores struct {
 	DBQuery          *store.DBQueryStore
 }
 
-// NewServer 创建 MCP 服务器。
+// NewServer 创建 MCP 服务器。stores 为 nil 时仅提供编排工具 (需 SetOrchestrator)。
 func NewServer(stores *Stores) *Server {
-	return &Server{stores: stores}
+	s := &Server{stores: stores}
+	if stores != nil && stores.SharedFile != nil {
+		s.sharedFiles = stores.SharedFile
+	}
+	if stores != nil && stores.TaskTrace != nil {
+		s.traces = stores.TaskTrace
+	}
+	return s
 }
 
-// Start 启动 MCP 服务器 (stdio transport)。
-// 待集成 mcp-go SDK — 目前使用简易 JSON-RPC over stdin/stdout。
+// SetOrchestrator 注入子代理编排后端, 启用 spawn_agent / send_task / await_result。
+func (s *Server) SetOrchestrator(orch Orchestrator) {
+	s.orch = orch
+}
+
+// Start 启动 MCP 服务器 (stdio transport, 换行分隔的 JSON-RPC)。
 func (s *Server) Start(ctx context.Context) error {
-	logger.Info("MCP server starting (stdio)")
-	// TODO: 集成 github.com/mark3labs/mcp-go
-	// 以下为工具注册占位
-	tools := s.toolRegistry()
-	logger.Info("MCP tools registered", logger.FieldCount, len(tools))
-	<-ctx.Done()
-	return nil
+	logger.Info("MCP server starting (stdio)", logger.FieldCount, len(s.listTools()))
+	s.startResourceWatcher(ctx)
+	return s.ServeStdio(ctx, os.Stdin, os.Stdout)
 }
 
 // Tool MCP 工具定义。
 type Tool struct {
 	Name        string
 	Description string
-	Handler     func(ctx context.Context, args json.RawMessage) (any, error)
+	InputSchema map[string]any // JSON Schema (tools/list 的 inputSchema)
 }
 
 type toolParams struct {
@@ -69,24 +83,90 @@ type toolParams struct {
 
 // toolRegistry 注册 10 个 MCP 工具 (对应 Python @mcp.tool)。
 func (s *Server) toolRegistry() []Tool {
+	limit := map[string]any{"type": "integer", "minimum": 1, "maximum": 500, "default": 100}
+	keyword := stringProp("关键字过滤")
 	return []Tool{
-		{Name: "interaction", Description: "交互记录 CRUD"},
-		{Name: "task_trace", Description: "任务追踪查询"},
-		{Name: "prompt_template", Description: "提示词模板管理"},
-		{Name: "command_card", Description: "命令卡管理"},
-		{Name: "shared_file", Description: "共享文件读写"},
-		{Name: "audit_log", Description: "审计日志查询"},
-		{Name: "agent_status", Description: "Agent 状态查询"},
-		{Name: "topology_approval", Description: "拓扑审批管理"},
-		{Name: "db_query", Description: "通用数据库查询"},
+		{Name: "interaction", Description: "交互记录 CRUD", InputSchema: objectSchema(map[string]any{
+			"thread_id": stringProp("线程 ID"), "keyword": keyword, "limit": limit,
+		})},
+		{Name: "task_trace", Description: "任务追踪查询", InputSchema: objectSchema(map[string]any{
+			"agent_id": stringProp("Agent ID"), "keyword": keyword, "limit": limit,
+		})},
+		{Name: "prompt_template", Description: "提示词模板管理", InputSchema: objectSchema(map[string]any{
+			"keyword": keyword, "limit": limit,
+		})},
+		{Name: "command_card", Description: "命令卡管理", InputSchema: objectSchema(map[string]any{
+			"keyword": keyword, "limit": limit,
+		})},
+		{Name: "shared_file", Description: "共享文件读写", InputSchema: objectSchema(map[string]any{
+			"prefix": stringProp("列出时的路径前缀"), "path": stringProp("写入路径"),
+			"content": stringProp("写入内容 (与 path 同时提供时写入)"), "actor": stringProp("写入者"), "limit": limit,
+		})},
+		{Name: "audit_log", Description: "审计日志查询", InputSchema: objectSchema(map[string]any{
+			"event_type": stringProp("事件类型"), "action": stringProp("动作"), "actor": stringProp("操作者"),
+			"keyword": keyword, "limit": limit,
+		})},
+		{Name: "agent_status", Description: "Agent 状态查询 (指定 agent_id 且启用编排时返回子代理实时 turn 状态)", InputSchema: objectSchema(map[string]any{
+			"status": stringProp("按状态过滤"), "agent_id": stringProp("子代理 agent_id"),
+		})},
+		{Name: "topology_approval", Description: "拓扑审批管理", InputSchema: objectSchema(map[string]any{})},
+		{Name: "db_query", Description: "通用数据库查询", InputSchema: objectSchema(map[string]any{
+			"sql": stringProp("只读 SQL"), "limit": limit,
+		}, "sql")},
 		{Name: "config_manage", Description: "配置管理"},
 	}
 }
 
-// HandleTool 处理工具调用 (对应 Python all_in_one.py 10 个 @mcp.tool)。
+// listTools 当前可调用的工具 (数据库工具需 stores, 编排工具需 Orchestrator)。
+func (s *Server) listTools() []Tool {
+	var tools []Tool
+	if s.stores != nil {
+		for _, tool := range s.toolRegistry() {
+			if _, ok := s.storeHandlers(toolParams{})[tool.Name]; ok {
+				tools = append(tools, tool)
+			}
+		}
+	} else if s.orch != nil {
+		for _, tool := range s.toolRegistry() {
+			if tool.Name == "agent_status" {
+				tools = append(tools, tool)
+			}
+		}
+	}
+	if s.orch != nil {
+		tools = append(tools, orchestrationTools()...)
+	}
+	return tools
+}
+
+// HandleTool 处理工具调用 (对应 Python all_in_one.py 10 个 @mcp.tool, 以及子代理编排工具)。
 func (s *Server) HandleTool(ctx context.Context, name string, args json.RawMessage) (any, error) {
+	if handler, ok := s.orchestrationHandler(name); ok {
+		var p orchestrationParams
+		if len(args) > 0 {
+			if err := json.Unmarshal(args, &p); err != nil {
+				return nil, apperrors.Wrapf(err, "MCP.HandleTool", "%s: invalid arguments", name)
+			}
+		}
+		return handler(ctx, p)
+	}
 	p := parseToolParams(args)
-	handlers := map[string]func(context.Context) (any, error){
+	if name == "agent_status" && s.orch != nil && p.AgentID != "" {
+		return s.liveAgentStatus(ctx, p.AgentID)
+	}
+	handler, ok := s.storeHandlers(p)[name]
+	if !ok {
+		return nil, apperrors.Newf("MCP.HandleTool", "unknown tool: %s", name)
+	}
+	if s.stores == nil {
+		return nil, apperrors.Newf("MCP.HandleTool", "%s: database not configured", name)
+	}
+	return handler(ctx)
+}
+
+// storeHandlers 数据库工具处理函数。
+func (s *Server) storeHandlers(p toolParams) map[string]func(context.Context) (any, error) {
+	return map[string]func(context.Context) (any, error){
 		"interaction": func(ctx context.Context) (any, error) {
 			return s.stores.Interaction.List(ctx, p.ThreadID, p.Keyword, p.Limit)
 		},
@@ -101,7 +181,11 @@ func (s *Server) HandleTool(ctx context.Context, name string, args json.RawMessa
 		},
 		"shared_file": func(ctx context.Context) (any, error) {
 			if p.Path != "" && p.Content != "" {
-				return s.stores.SharedFile.Write(ctx, p.Path, p.Content, p.Actor)
+				f, err := s.stores.SharedFile.Write(ctx, p.Path, p.Content, p.Actor)
+				if err == nil && f != nil {
+					s.refreshResource(ctx, sharedFileURI(f.Path))
+				}
+				return f, err
 			}
 			return s.stores.SharedFile.List(ctx, p.Prefix, p.Limit)
 		},
@@ -121,11 +205,6 @@ func (s *Server) HandleTool(ctx context.Context, name string, args json.RawMessa
 			return s.stores.DBQuery.Query(ctx, p.SQL, p.Limit)
 		},
 	}
-	handler, ok := handlers[name]
-	if !ok {
-		return nil, apperrors.Newf("MCP.HandleTool", "unknown tool: %s", name)
-	}
-	return handler(ctx)
 }
 
 func parseToolParams(args json.RawMessage) toolParams {
@@ -0,0 +1,96 @@
+// stdio.go — MCP stdio 传输: 每行一条 JSON-RPC 消息。
+//
+// 请求并发处理 (await_result 可能长时间阻塞, 不能挡住 ping 等后续请求), 响应写入串行化。
+// stdout 专用于协议输出, 日志需写到 stderr (见 logger.InitStderr)。
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"io"
+	"sync"
+
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+	"github.com/multi-agent/go-agent-v2/pkg/util"
+)
+
+const maxStdioMessageBytes = 8 << 20
+
+// stdioConn stdio 客户端连接, 通知与响应共用同一输出流。
+type stdioConn struct {
+	id    string
+	write func(msg []byte)
+}
+
+func (c *stdioConn) connID() string { return c.id }
+
+func (c *stdioConn) notify(msg []byte) bool {
+	c.write(msg)
+	return true
+}
+
+// ServeStdio 从 r 读取消息并将响应写入 w, 直到 r 结束或 ctx 取消。
+func (s *Server) ServeStdio(ctx context.Context, r io.Reader, w io.Writer) error {
+	var (
+		writeMu sync.Mutex
+		wg      sync.WaitGroup
+	)
+	ctx, cancel := context.WithCancel(ctx)
+	defer wg.Wait() // 先取消再等待进行中的请求 (客户端已断开时不再阻塞)
+	defer cancel()
+	write := func(msg []byte) {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		if _, err := w.Write(append(msg, '\n')); err != nil {
+			logger.Warn("mcp: write response failed", logger.FieldError, err)
+		}
+	}
+
+	conn := &stdioConn{id: "stdio-" + rand.Text(), write: write}
+	ctx = withClientConn(ctx, conn)
+	defer s.subs.dropConn(conn.id)
+
+	lines := make(chan []byte)
+	readErr := make(chan error, 1)
+	util.SafeGo(func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64<<10), maxStdioMessageBytes)
+		for scanner.Scan() {
+			line := append([]byte(nil), scanner.Bytes()...)
+			select {
+			case lines <- line:
+			case <-ctx.Done():
+				return
+			}
+		}
+		readErr <- scanner.Err()
+	})
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case line, ok := <-lines:
+			if !ok {
+				select {
+				case err := <-readErr:
+					if err != nil {
+						return apperrors.Wrap(err, "MCP.ServeStdio", "read stdin")
+					}
+				default:
+				}
+				return nil
+			}
+			wg.Add(1)
+			util.SafeGo(func() {
+				defer wg.Done()
+				if resp := s.HandleMessage(ctx, line); resp != nil {
+					write(resp)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,264 @@
+// client.go — app-server WebSocket JSON-RPC 客户端 (终端 UI / 脚本 / 一次性 CLI 共用)。
+//
+// 单连接, 并发安全: Call 可在多个 goroutine 中调用, 响应按 id 路由。
+// 服务端推送 (通知 + Server→Client 请求, 如审批) 经 Notifications 按到达顺序投递,
+// 内部无界排队, 调用方处理慢不会阻塞响应读取。
+package rpcclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+// DefaultAddr app-server 默认 WebSocket 地址。
+const DefaultAddr = "ws://127.0.0.1:4500"
+
+// Error 服务端返回的 JSON-RPC 错误。
+type Error struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+// AppCode data.code 中的业务错误码 (pkg/errcode, 如 THREAD_NOT_FOUND); 无则为空。
+func (e *Error) AppCode() string {
+	var data struct {
+		Code string `json:"code"`
+	}
+	_ = json.Unmarshal(e.Data, &data)
+	return data.Code
+}
+
+// CodeOf 提取 err 链上 RPC 错误的业务错误码。
+func CodeOf(err error) string {
+	var rpcErr *Error
+	if errors.As(err, &rpcErr) {
+		return rpcErr.AppCode()
+	}
+	return ""
+}
+
+// Notification 服务端推送。ID 非空表示 Server→Client 请求, 需要 Respond 回复。
+type Notification struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+	ID     json.RawMessage `json:"id,omitempty"`
+}
+
+// IsRequest 是否为需要回复的 Server→Client 请求。
+func (n Notification) IsRequest() bool {
+	return len(n.ID) > 0 && string(n.ID) != "null"
+}
+
+// message 线上帧 (请求 / 响应 / 通知共用)。
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Client app-server 连接。
+type Client struct {
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+	nextID  atomic.Int64
+
+	mu      sync.Mutex
+	pending map[int64]chan message
+	queue   []Notification
+	wake    chan struct{}
+
+	notes  chan Notification
+	done   chan struct{}
+	err    error
+	closed atomic.Bool
+}
+
+// Dial 连接 app-server (addr 形如 ws://127.0.0.1:4500)。
+func Dial(ctx context.Context, addr string) (*Client, error) {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, addr, nil)
+	if err != nil {
+		return nil, apperrors.Wrapf(err, "rpcclient.Dial", "dial %s", addr)
+	}
+	c := &Client{
+		conn:    conn,
+		pending: map[int64]chan message{},
+		wake:    make(chan struct{}, 1),
+		notes:   make(chan Notification),
+		done:    make(chan struct{}),
+	}
+	go c.readLoop()
+	go c.pump()
+	return c, nil
+}
+
+// Notifications 服务端推送流; 连接断开后关闭。
+func (c *Client) Notifications() <-chan Notification { return c.notes }
+
+// Done 连接断开时关闭。
+func (c *Client) Done() <-chan struct{} { return c.done }
+
+// Err 连接断开原因 (Done 关闭后有效; 主动 Close 时为 nil)。
+func (c *Client) Err() error {
+	<-c.done
+	return c.err
+}
+
+// Close 正常关闭连接。
+func (c *Client) Close() error {
+	if !c.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+	c.writeMu.Lock()
+	_ = c.conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, "bye"), time.Now().Add(time.Second))
+	c.writeMu.Unlock()
+	return c.conn.Close()
+}
+
+// Call 发送请求并等待响应, 返回原始 result。RPC 错误以 *Error 返回 (可 errors.As)。
+func (c *Client) Call(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	id := c.nextID.Add(1)
+	ch := make(chan message, 1)
+	c.mu.Lock()
+	c.pending[id] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	req := map[string]any{"jsonrpc": "2.0", "id": id, "method": method}
+	if params != nil {
+		req["params"] = params
+	}
+	if err := c.write(req); err != nil {
+		return nil, apperrors.Wrapf(err, "rpcclient.Call", "%s: write", method)
+	}
+	select {
+	case msg := <-ch:
+		if msg.Error != nil {
+			return nil, msg.Error
+		}
+		return msg.Result, nil
+	case <-c.done:
+		return nil, apperrors.Wrapf(c.disconnectErr(), "rpcclient.Call", "%s: connection closed", method)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// CallInto Call 并将 result 解码到 out。
+func (c *Client) CallInto(ctx context.Context, method string, params, out any) error {
+	raw, err := c.Call(ctx, method, params)
+	if err != nil || out == nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return apperrors.Wrapf(err, "rpcclient.CallInto", "%s: decode result", method)
+	}
+	return nil
+}
+
+// Respond 回复 Server→Client 请求 (如审批: {"approved": true})。
+func (c *Client) Respond(id json.RawMessage, result any) error {
+	if err := c.write(map[string]any{"jsonrpc": "2.0", "id": id, "result": result}); err != nil {
+		return apperrors.Wrap(err, "rpcclient.Respond", "write")
+	}
+	return nil
+}
+
+func (c *Client) write(v any) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.conn.WriteJSON(v)
+}
+
+func (c *Client) disconnectErr() error {
+	if c.err != nil {
+		return c.err
+	}
+	return apperrors.New("rpcclient", "closed")
+}
+
+func (c *Client) readLoop() {
+	defer close(c.done)
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			if !c.closed.Load() {
+				c.err = err
+			}
+			return
+		}
+		var msg message
+		if json.Unmarshal(data, &msg) != nil {
+			continue
+		}
+		if msg.Method == "" {
+			var id int64
+			if json.Unmarshal(msg.ID, &id) != nil {
+				continue
+			}
+			c.mu.Lock()
+			ch := c.pending[id]
+			c.mu.Unlock()
+			if ch != nil {
+				ch <- msg
+			}
+			continue
+		}
+		c.mu.Lock()
+		c.queue = append(c.queue, Notification{Method: msg.Method, Params: msg.Params, ID: msg.ID})
+		c.mu.Unlock()
+		select {
+		case c.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// pump 将排队的推送按序投递到 notes; 连接断开且队列清空后关闭 notes。
+func (c *Client) pump() {
+	defer close(c.notes)
+	for {
+		c.mu.Lock()
+		batch := c.queue
+		c.queue = nil
+		c.mu.Unlock()
+		for _, n := range batch {
+			c.notes <- n
+		}
+		if len(batch) > 0 {
+			continue
+		}
+		select {
+		case <-c.wake:
+		case <-c.done:
+			c.mu.Lock()
+			rest := c.queue
+			c.queue = nil
+			c.mu.Unlock()
+			for _, n := range rest {
+				c.notes <- n
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package rpcclient
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/multi-agent/go-agent-v2/internal/apiserver"
+	"github.com/multi-agent/go-agent-v2/internal/codex"
+	"github.com/multi-agent/go-agent-v2/internal/runner"
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+)
+
+// startServer 启动注入模拟 codex 的 apiserver, 返回 ws 地址。
+func startServer(t *testing.T) string {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := "ws://" + ln.Addr().String()
+	_ = ln.Close()
+
+	mgr := runner.NewAgentManager()
+	mgr.SetClientFactory(codex.MockClientFactory(codex.DefaultMockScript, 5*time.Millisecond))
+	srv := apiserver.New(apiserver.Deps{Manager: mgr})
+	mgr.SetOnEvent(func(agentID string, event codex.Event) {
+		srv.AgentEventHandler(agentID)(event)
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() { _ = srv.ListenAndServe(ctx, addr) }()
+	return addr
+}
+
+func dialRetry(t *testing.T, addr string) *Client {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		c, err := Dial(context.Background(), addr)
+		if err == nil {
+			t.Cleanup(func() { _ = c.Close() })
+			return c
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("dial: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestClient_TurnStreamsNotifications(t *testing.T) {
+	c := dialRetry(t, startServer(t))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var started struct {
+		Thread struct {
+			ID string `json:"id"`
+		} `json:"thread"`
+	}
+	if err := c.CallInto(ctx, "thread/start", map[string]any{"cwd": "."}, &started); err != nil || started.Thread.ID == "" {
+		t.Fatalf("thread/start: %+v, %v", started, err)
+	}
+	if _, err := c.Call(ctx, "turn/start", map[string]any{
+		"threadId": started.Thread.ID,
+		"input":    []map[string]any{{"type": "text", "text": "hello"}},
+	}); err != nil {
+		t.Fatalf("turn/start: %v", err)
+	}
+
+	var deltas strings.Builder
+	for {
+		select {
+		case n := <-c.Notifications():
+			var p struct {
+				ThreadID string `json:"threadId"`
+				Delta    string `json:"delta"`
+			}
+			_ = json.Unmarshal(n.Params, &p)
+			if p.ThreadID != started.Thread.ID {
+				continue
+			}
+			if n.Method == "item/agentMessage/delta" {
+				deltas.WriteString(p.Delta)
+			}
+			if n.Method == "turn/completed" {
+				if deltas.Len() == 0 {
+					t.Fatal("no agent deltas before turn/completed")
+				}
+				return
+			}
+		case <-ctx.Done():
+			t.Fatalf("no turn/completed (deltas %q)", deltas.String())
+		}
+	}
+}
+
+func TestClient_ErrorCodeAndServerRequest(t *testing.T) {
+	c := dialRetry(t, startServer(t))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := c.Call(ctx, "thread/stop/graceful", map[string]any{"threadId": "missing"})
+	if CodeOf(err) != errcode.ThreadNotFound {
+		t.Fatalf("err = %v (code %q)", err, CodeOf(err))
+	}
+
+	// Server→Client 请求: 经 Notifications 投递, Respond 回写同一 id。
+	replies := make(chan map[string]any, 1)
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_ = conn.WriteJSON(map[string]any{"jsonrpc": "2.0", "id": 7, "method": "item/commandExecution/requestApproval", "params": map[string]any{"command": "ls"}})
+		var reply map[string]any
+		if conn.ReadJSON(&reply) == nil {
+			replies <- reply
+		}
+	}))
+	defer hs.Close()
+	fake := dialRetry(t, "ws"+strings.TrimPrefix(hs.URL, "http"))
+	req := <-fake.Notifications()
+	if !req.IsRequest() || req.Method != "item/commandExecution/requestApproval" {
+		t.Fatalf("request = %+v", req)
+	}
+	if err := fake.Respond(req.ID, map[string]any{"approved": true}); err != nil {
+		t.Fatal(err)
+	}
+	reply := <-replies
+	if reply["id"] != float64(7) || reply["result"].(map[string]any)["approved"] != true {
+		t.Fatalf("reply = %v", reply)
+	}
+}
@@ -0,0 +1,124 @@
+// adopt.go — 启动时接管上次运行遗留的 codex app-server 进程。
+//
+// supervisor 重启 (升级 / 崩溃) 不应销毁正在运行的 turn: 端口预留记录中带 PID 的 app-server
+// 若仍存活且确为对应端口的 codex 进程, 按 agent_codex_binding 找回 codex 线程 ID,
+// 重新连接并注册到 AgentManager; 接管失败的进程留给 CleanOrphanedProcesses 清理。
+package runner
+
+import (
+	"context"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/codex"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+// adoptTimeout 单个进程接管 (连接 + initialize + thread/resume) 的超时。
+const adoptTimeout = 45 * time.Second
+
+// ThreadBindingResolver 返回 agent 绑定的 codex 线程 ID (空 = 无绑定)。
+type ThreadBindingResolver func(ctx context.Context, agentID string) (string, error)
+
+type adoptFactory func(ctx context.Context, alloc PortAllocation, threadID string) (codex.CodexClient, error)
+
+// defaultAdoptFactory 校验进程命令行后接管真实 app-server。
+func defaultAdoptFactory(ctx context.Context, alloc PortAllocation, threadID string) (codex.CodexClient, error) {
+	if err := verifyOrphanCommand(alloc); err != nil {
+		return nil, err
+	}
+	return codex.AdoptAppServer(ctx, alloc.Port, alloc.AgentID, alloc.PID, threadID)
+}
+
+// verifyOrphanCommand 确认 PID 仍是监听该端口的 codex app-server (防止 PID 复用)。
+func verifyOrphanCommand(alloc PortAllocation) error {
+	out, err := exec.Command("ps", "-o", "command=", "-p", strconv.Itoa(alloc.PID)).Output()
+	if err != nil {
+		return apperrors.Wrapf(err, "runner.verifyOrphanCommand", "process %d not found", alloc.PID)
+	}
+	command := strings.TrimSpace(string(out))
+	listen := "--listen ws://127.0.0.1:" + strconv.Itoa(alloc.Port)
+	if !strings.Contains(command, "app-server") || !strings.HasSuffix(command, listen) {
+		return apperrors.Newf("runner.verifyOrphanCommand", "process %d is not an app-server on port %d: %q", alloc.PID, alloc.Port, command)
+	}
+	return nil
+}
+
+// AdoptOrphans 接管上次运行遗留的 app-server, 返回成功接管的 PID (供 CleanOrphanedProcesses 跳过)。
+//
+// resolve 为 nil (无绑定存储) 时不接管。接管的 agent 以 idle 注册, 进行中的 turn 事件随后照常推送。
+func (m *AgentManager) AdoptOrphans(ctx context.Context, allocs []PortAllocation, resolve ThreadBindingResolver) map[int]bool {
+	adopted := make(map[int]bool)
+	if resolve == nil {
+		return adopted
+	}
+	for _, alloc := range allocs {
+		if alloc.PID <= 0 || strings.TrimSpace(alloc.AgentID) == "" || m.Get(alloc.AgentID) != nil {
+			continue
+		}
+		if err := m.adoptOne(ctx, alloc, resolve); err != nil {
+			logger.Warn("runner: orphan adoption failed",
+				logger.FieldAgentID, alloc.AgentID,
+				logger.FieldPort, alloc.Port,
+				logger.FieldPID, alloc.PID,
+				logger.FieldError, err,
+			)
+			continue
+		}
+		adopted[alloc.PID] = true
+	}
+	if len(adopted) > 0 {
+		logger.Info("runner: adopted orphaned codex app-servers", logger.FieldCount, len(adopted), "total_found", len(allocs))
+	}
+	return adopted
+}
+
+func (m *AgentManager) adoptOne(ctx context.Context, alloc PortAllocation, resolve ThreadBindingResolver) error {
+	const op = "AgentManager.adoptOne"
+	ctx, cancel := context.WithTimeout(ctx, adoptTimeout)
+	defer cancel()
+	threadID, err := resolve(ctx, alloc.AgentID)
+	if err != nil {
+		return apperrors.Wrap(err, op, "resolve thread binding")
+	}
+	if strings.TrimSpace(threadID) == "" {
+		return apperrors.Newf(op, "agent %s has no codex thread binding", alloc.AgentID)
+	}
+
+	m.mu.RLock()
+	factory := m.adoptFactory
+	m.mu.RUnlock()
+	client, err := factory(ctx, alloc, threadID)
+	if err != nil {
+		return err
+	}
+
+	name := alloc.Name
+	if name == "" {
+		name = alloc.AgentID
+	}
+	proc := &AgentProcess{
+		ID:     alloc.AgentID,
+		Name:   name,
+		Client: client,
+		State:  StateIdle,
+		port:   alloc.Port,
+	}
+	m.mu.Lock()
+	if _, exists := m.agents[alloc.AgentID]; exists {
+		m.mu.Unlock()
+		return apperrors.Newf(op, "agent %s already exists", alloc.AgentID)
+	}
+	m.agents[alloc.AgentID] = proc
+	m.mu.Unlock()
+	client.SetEventHandler(func(event codex.Event) {
+		m.handleEvent(proc, event)
+	})
+	alloc.Name = name
+	m.ports.claim(alloc)
+	m.notifyLaunched(proc)
+	return nil
+}
@@ -0,0 +1,70 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/multi-agent/go-agent-v2/internal/codex"
+)
+
+func TestAdoptOrphans_RegistersAdoptedAgents(t *testing.T) {
+	mgr := NewAgentManager()
+	var adoptedThreads []string
+	mgr.adoptFactory = func(_ context.Context, alloc PortAllocation, threadID string) (codex.CodexClient, error) {
+		if alloc.AgentID == "agent-broken" {
+			return nil, errors.New("connect refused")
+		}
+		adoptedThreads = append(adoptedThreads, threadID)
+		return &stubClient{port: alloc.Port, threadID: threadID}, nil
+	}
+	var launched []string
+	mgr.SetOnLaunched(func(proc *AgentProcess) { launched = append(launched, proc.ID) })
+	bindings := map[string]string{"agent-ok": "codex-thread-1", "agent-broken": "codex-thread-2"}
+	resolve := func(_ context.Context, agentID string) (string, error) { return bindings[agentID], nil }
+
+	allocs := []PortAllocation{
+		{Port: 30301, AgentID: "agent-ok", Name: "Worker", PID: 4242},
+		{Port: 30302, AgentID: "agent-broken", PID: 4243},
+		{Port: 30303, AgentID: "agent-unbound", PID: 4244},
+		{Port: 30304, AgentID: "agent-rest"}, // 无 PID: 不可接管
+	}
+	adopted := mgr.AdoptOrphans(context.Background(), allocs, resolve)
+
+	if len(adopted) != 1 || !adopted[4242] {
+		t.Fatalf("adopted = %v, want only pid 4242", adopted)
+	}
+	proc := mgr.Get("agent-ok")
+	if proc == nil || proc.Name != "Worker" || proc.State != StateIdle || proc.Client.GetThreadID() != "codex-thread-1" {
+		t.Fatalf("adopted proc = %+v", proc)
+	}
+	if len(adoptedThreads) != 1 || len(launched) != 1 || launched[0] != "agent-ok" {
+		t.Fatalf("threads = %v, launched = %v", adoptedThreads, launched)
+	}
+	if allocs := mgr.PortAllocations(); len(allocs) != 1 || allocs[0].Port != 30301 || allocs[0].PID != 4242 {
+		t.Fatalf("allocations = %+v", allocs)
+	}
+	for _, id := range []string{"agent-broken", "agent-unbound", "agent-rest"} {
+		if mgr.Get(id) != nil {
+			t.Fatalf("%s should not be registered", id)
+		}
+	}
+
+	if err := mgr.Stop("agent-ok"); err != nil {
+		t.Fatal(err)
+	}
+	if allocs := mgr.PortAllocations(); len(allocs) != 0 {
+		t.Fatalf("allocations after stop = %+v", allocs)
+	}
+}
+
+func TestAdoptOrphans_NoResolverAdoptsNothing(t *testing.T) {
+	mgr := NewAgentManager()
+	mgr.adoptFactory = func(context.Context, PortAllocation, string) (codex.CodexClient, error) {
+		t.Fatal("factory must not be called without a binding resolver")
+		return nil, nil
+	}
+	if adopted := mgr.AdoptOrphans(context.Background(), []PortAllocation{{Port: 1, AgentID: "a", PID: 1}}, nil); len(adopted) != 0 {
+		t.Fatalf("adopted = %v", adopted)
+	}
+}
@@ -11,14 +11,12 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"fmt"
-	"net"
+	"errors"
 	"os/exec"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
-	"sync/atomic"
 	"syscall"
 
 	"github.com/multi-agent/go-agent-v2/internal/codex"
@@ -27,9 +25,6 @@ import (
 	"github.com/multi-agent/go-agent-v2/pkg/logger"
 )
 
-// basePort 自动分配端口的起始值。
-const basePort = 19836
-
 // AgentState Agent 运行状态。
 type AgentState string
 
@@ -55,6 +50,7 @@ type AgentProcess struct {
 	LastReport  string            // 最近一次 turn 完成时的 agent 报告 (对应 Rust TurnCompleteEvent.last_agent_message)
 	SessionLost bool              // 重启后 codex session 丢失, 下次 turn 需注入 DB 历史上下文
 	mu          sync.Mutex        // 保护 State / LastReport / SessionLost 字段读写
+	port        int               // PortAllocator 预留的端口 (停止时释放)
 }
 
 // MarkSessionLost 标记 session 丢失 (线程安全)。
@@ -86,6 +82,9 @@ type AgentInfo struct {
 	ThreadID   string     `json:"thread_id"`
 	State      AgentState `json:"state"`
 	LastReport string     `json:"last_report,omitempty"` // 最近一次任务报告
+
+	RestartPolicy *RestartPolicy `json:"restart_policy,omitempty"` // 显式配置的重启策略 (nil = 全局默认)
+	Restarts      int            `json:"restarts,omitempty"`       // 累计自动重启次数
 }
 
 // AgentEvent 封装 Agent 事件 (用于 UI 展示)。
@@ -107,6 +106,12 @@ type EventHandler func(agentID string, event codex.Event)
 
 type clientFactory func(port int, agentID string) codex.CodexClient
 
+// LaunchHook agent 启动成功后的回调 (含 REST 回退)。
+type LaunchHook func(proc *AgentProcess)
+
+// LaunchEnvResolver 返回 agent 启动时追加的环境变量 (KEY=VALUE, 覆盖继承值), 无覆盖时返回 nil。
+type LaunchEnvResolver func(agentID string) []string
+
 // AgentManager 管理多个 Codex Agent 子进程。
 type AgentManager struct {
 	// ========================================
@@ -119,25 +124,44 @@ type AgentManager struct {
 
 	mu       sync.RWMutex
 	agents   map[string]*AgentProcess
-	nextPort atomic.Int32
+	ports    *PortAllocator
+	restarts map[string]*agentRestartState // 重启策略与计数 (跨 Stop/Launch 保留)
 	onEvent  EventHandler
 
+	// 线程级环境变量覆盖 (每次 Launch 时解析, 恢复会话同样生效)
+	launchEnv LaunchEnvResolver
+	// 启动成功回调 (能力探测等)
+	onLaunched LaunchHook
+
 	// 传输构造器 (便于测试注入 + fallback)
 	appServerFactory clientFactory
 	restFactory      clientFactory
+	adoptFactory     adoptFactory
 }
 
 // NewAgentManager 创建管理器。
 func NewAgentManager() *AgentManager {
 	m := &AgentManager{
 		agents:           make(map[string]*AgentProcess),
+		restarts:         make(map[string]*agentRestartState),
+		ports:            NewPortAllocator(),
 		appServerFactory: func(port int, agentID string) codex.CodexClient { return codex.NewAppServerClient(port, agentID) },
 		restFactory:      func(port int, agentID string) codex.CodexClient { return codex.NewClient(port, agentID) },
+		adoptFactory:     defaultAdoptFactory,
 	}
-	m.nextPort.Store(int32(basePort))
 	return m
 }
 
+// ConfigurePorts 设置端口分配区间与预留记录文件 (应在首次 Launch 前调用)。
+func (m *AgentManager) ConfigurePorts(opts PortOptions) error {
+	return m.ports.Configure(opts)
+}
+
+// PortAllocations 返回当前端口预留快照。
+func (m *AgentManager) PortAllocations() []PortAllocation {
+	return m.ports.Allocations()
+}
+
 // SetOnEvent 设置事件回调 (线程安全)。
 func (m *AgentManager) SetOnEvent(fn EventHandler) {
 	m.mu.Lock()
@@ -145,6 +169,61 @@ func (m *AgentManager) SetOnEvent(fn EventHandler) {
 	m.onEvent = fn
 }
 
+// SetLaunchEnvResolver 设置启动环境变量解析器 (线程安全)。
+func (m *AgentManager) SetLaunchEnvResolver(fn LaunchEnvResolver) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.launchEnv = fn
+}
+
+// SetOnLaunched 设置启动成功回调 (线程安全, 在 Launch 返回前同步调用)。
+func (m *AgentManager) SetOnLaunched(fn LaunchHook) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onLaunched = fn
+}
+
+// notifyLaunched 在锁外调用启动回调。
+func (m *AgentManager) notifyLaunched(proc *AgentProcess) {
+	m.mu.RLock()
+	fn := m.onLaunched
+	m.mu.RUnlock()
+	if fn != nil {
+		fn(proc)
+	}
+}
+
+// SetClientFactory 替换传输客户端构造器 (压测 / 演示模式注入进程内后端, 线程安全)。
+//
+// 注入的客户端启动失败时不再回退 REST 传输。
+func (m *AgentManager) SetClientFactory(fn func(port int, agentID string) codex.CodexClient) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.appServerFactory = fn
+	m.restFactory = func(int, string) codex.CodexClient { return nil }
+}
+
+// resolveLaunchEnv 在锁外调用解析器 (解析器可能访问数据库)。
+func (m *AgentManager) resolveLaunchEnv(id string) []string {
+	m.mu.RLock()
+	fn := m.launchEnv
+	m.mu.RUnlock()
+	if fn == nil {
+		return nil
+	}
+	return fn(id)
+}
+
+// applyLaunchEnv 将环境变量覆盖注入支持的传输客户端。
+func applyLaunchEnv(client codex.CodexClient, env []string) {
+	if len(env) == 0 {
+		return
+	}
+	if configurable, ok := client.(codex.EnvConfigurable); ok {
+		configurable.SetExtraEnv(env)
+	}
+}
+
 // SetOnOutput 设置输出回调 (兼容旧 API, 将 agent_message_delta 转为 []byte)。
 func (m *AgentManager) SetOnOutput(fn func(agentID string, data []byte)) {
 	m.SetOnEvent(func(agentID string, event codex.Event) {
@@ -154,49 +233,57 @@ func (m *AgentManager) SetOnOutput(fn func(agentID string, data []byte)) {
 	})
 }
 
-// maxPortRetries 最多尝试的连续端口数 (防止耗尽)。
-const maxPortRetries = 20
-
-// findFreePort 从 nextPort 开始探测, 跳过被占用端口, 返回可用端口。
-//
-// 每次探测: net.Listen → Close。最多尝试 maxPortRetries 个端口。
-func (m *AgentManager) findFreePort() (int, error) {
-	for i := 0; i < maxPortRetries; i++ {
-		port := int(m.nextPort.Add(1) - 1)
-		ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
-		if err != nil {
-			continue // 端口被占用，跳到下一个
-		}
-		_ = ln.Close()
-		return port, nil
-	}
-
-	// 回退策略: 使用内核分配的随机可用端口 (127.0.0.1:0)。
-	ln, err := net.Listen("tcp", "127.0.0.1:0")
-	if err == nil {
-		port := ln.Addr().(*net.TCPAddr).Port
-		_ = ln.Close()
-		if port > 0 {
-			m.nextPort.Store(int32(port + 1))
-			return port, nil
-		}
-	}
-
-	return 0, apperrors.Newf("AgentManager.findFreePort", "no free port found after %d attempts from %d, and fallback random port failed",
-		maxPortRetries, int(m.nextPort.Load())-maxPortRetries)
-}
-
 // Launch 启动一个 Codex Agent。
 //
-// 流程: 探测空闲端口 → spawn codex app-server → JSON-RPC initialize → thread/start。
+// 流程: 预留端口 → spawn codex app-server → JSON-RPC initialize → thread/start。
 // ctx 控制 spawn 超时和子进程生命周期。
 // dynamicTools 为 nil 时不注入自定义工具。
 func (m *AgentManager) Launch(ctx context.Context, id, name, prompt, cwd string, instructions string, dynamicTools []codex.DynamicTool) error {
+	return m.LaunchWithModel(ctx, id, name, prompt, cwd, "", instructions, dynamicTools)
+}
+
+// LaunchWithModel 同 Launch, 并在 thread/start 时指定模型 (空 = codex 默认模型)。
+func (m *AgentManager) LaunchWithModel(ctx context.Context, id, name, prompt, cwd, model, instructions string, dynamicTools []codex.DynamicTool) error {
+	return m.LaunchWithOptions(ctx, LaunchOptions{
+		ID:           id,
+		Name:         name,
+		Prompt:       prompt,
+		Cwd:          cwd,
+		Model:        model,
+		Instructions: instructions,
+		DynamicTools: dynamicTools,
+	})
+}
+
+// LaunchOptions LaunchWithOptions 参数。
+type LaunchOptions struct {
+	ID           string
+	Name         string
+	Prompt       string
+	Cwd          string
+	Model        string // 空 = codex 默认模型
+	Instructions string
+	DynamicTools []codex.DynamicTool
+	// RestartPolicy 非 nil 时覆盖该 agent 的重启策略 (nil = 保留已有策略, 健康检查重启时即如此)。
+	RestartPolicy *RestartPolicy
+}
+
+// LaunchWithOptions 按选项启动 agent。
+func (m *AgentManager) LaunchWithOptions(ctx context.Context, opts LaunchOptions) error {
+	id, name, prompt, cwd, model := opts.ID, opts.Name, opts.Prompt, opts.Cwd, opts.Model
+	instructions, dynamicTools := opts.Instructions, opts.DynamicTools
+	if opts.RestartPolicy != nil {
+		if err := m.SetRestartPolicy(id, opts.RestartPolicy); err != nil {
+			return err
+		}
+	}
 	logger.Info("runner: launching agent",
 		logger.FieldAgentID, id,
 		logger.FieldName, name,
 		logger.FieldCwd, cwd,
+		"model", model,
 	)
+	extraEnv := m.resolveLaunchEnv(id)
 
 	m.mu.Lock()
 	if _, exists := m.agents[id]; exists {
@@ -204,7 +291,7 @@ func (m *AgentManager) Launch(ctx context.Context, id, name, prompt, cwd string,
 		return apperrors.Newf("AgentManager.Launch", "agent %s already exists", id)
 	}
 
-	port, err := m.findFreePort()
+	port, err := m.ports.Reserve(id)
 	if err != nil {
 		m.mu.Unlock()
 		logger.Error("runner: no free port", logger.FieldAgentID, id, logger.FieldError, err)
@@ -214,15 +301,18 @@ func (m *AgentManager) Launch(ctx context.Context, id, name, prompt, cwd string,
 	// 优先使用 AppServerClient (JSON-RPC, 支持实时事件 + dynamicTools)。
 	client := m.appServerFactory(port, id)
 	if client == nil {
+		m.ports.Release(port, id)
 		m.mu.Unlock()
 		return apperrors.New("AgentManager.Launch", "app-server client factory returned nil")
 	}
+	applyLaunchEnv(client, extraEnv)
 
 	proc := &AgentProcess{
 		ID:     id,
 		Name:   name,
 		Client: client,
 		State:  StateRunning,
+		port:   port,
 	}
 	m.agents[id] = proc
 	m.mu.Unlock()
@@ -233,23 +323,55 @@ func (m *AgentManager) Launch(ctx context.Context, id, name, prompt, cwd string,
 	})
 
 	// SpawnAndConnect: 启动 app-server → WS 连接 → initialize → thread/start (with dynamicTools)
-	if err := client.SpawnAndConnect(ctx, prompt, cwd, "", instructions, dynamicTools); err != nil {
+	err = client.SpawnAndConnect(ctx, prompt, cwd, model, instructions, dynamicTools)
+	// 预留后端口被外部进程抢占: 换端口重试。
+	for attempt := 1; errors.Is(err, codex.ErrPortInUse) && attempt < maxPortBindAttempts; attempt++ {
+		_ = client.Kill()
+		next, reserveErr := m.ports.Reserve(id)
+		if reserveErr != nil {
+			err = apperrors.Wrapf(reserveErr, "AgentManager.Launch", "re-reserve port after bind failure: %v", err)
+			break
+		}
+		m.ports.Release(port, id)
+		logger.Warn("runner: port taken before spawn, retrying",
+			logger.FieldAgentID, id,
+			logger.FieldPort, port,
+			"next_port", next,
+		)
+		port = next
+		if client = m.appServerFactory(port, id); client == nil {
+			err = apperrors.New("AgentManager.Launch", "app-server client factory returned nil")
+			break
+		}
+		applyLaunchEnv(client, extraEnv)
+		proc.mu.Lock()
+		proc.Client, proc.port = client, port
+		proc.mu.Unlock()
+		client.SetEventHandler(func(event codex.Event) {
+			m.handleEvent(proc, event)
+		})
+		err = client.SpawnAndConnect(ctx, prompt, cwd, model, instructions, dynamicTools)
+	}
+	if err != nil {
 		logger.Warn("runner: app-server launch failed, attempting REST fallback",
 			logger.FieldAgentID, id,
 			logger.FieldPort, port,
 			logger.FieldError, err,
 		)
-		_ = client.Kill()
+		if client != nil {
+			_ = client.Kill()
+		}
 
 		fallback := m.restFactory(port, id)
 		if fallback != nil {
+			applyLaunchEnv(fallback, extraEnv)
 			proc.mu.Lock()
 			proc.Client = fallback
 			proc.mu.Unlock()
 			fallback.SetEventHandler(func(event codex.Event) {
 				m.handleEvent(proc, event)
 			})
-			if fallbackErr := fallback.SpawnAndConnect(ctx, prompt, cwd, "", instructions, dynamicTools); fallbackErr == nil {
+			if fallbackErr := fallback.SpawnAndConnect(ctx, prompt, cwd, model, instructions, dynamicTools); fallbackErr == nil {
 				payload, err := json.Marshal(map[string]any{
 					"message": "App-server unavailable; using HTTP fallback",
 					"status":  "degraded",
@@ -268,6 +390,7 @@ func (m *AgentManager) Launch(ctx context.Context, id, name, prompt, cwd string,
 					logger.FieldAgentID, id,
 					logger.FieldPort, port,
 				)
+				m.notifyLaunched(proc)
 				return nil
 			} else {
 				logger.Error("runner: REST fallback launch failed",
@@ -291,11 +414,16 @@ func (m *AgentManager) Launch(ctx context.Context, id, name, prompt, cwd string,
 			delete(m.agents, id)
 		}
 		m.mu.Unlock()
+		m.ports.Release(port, id)
 		logger.Error("runner: launch failed", logger.FieldAgentID, id, logger.FieldPort, port, logger.FieldError, err, logger.FieldDecision, "removed_from_agents_map")
 		return apperrors.Wrapf(err, "AgentManager.Launch", "launch %s", id)
 	}
 
+	if pp, ok := client.(codex.PIDProvider); ok {
+		m.ports.annotate(port, id, name, pp.PID())
+	}
 	logger.Info("runner: agent launched", logger.FieldAgentID, id, logger.FieldPort, port)
+	m.notifyLaunched(proc)
 	return nil
 }
 
@@ -424,6 +552,7 @@ func (m *AgentManager) Stop(id string) error {
 	}
 	delete(m.agents, id)
 	m.mu.Unlock()
+	defer m.releasePort(proc)
 
 	if err := proc.Client.Shutdown(); err != nil {
 		logger.Warn("runner: shutdown error", logger.FieldAgentID, id, logger.FieldError, err)
@@ -484,35 +613,56 @@ func (m *AgentManager) KillAll() {
 		if err := proc.Client.Kill(); err != nil {
 			logger.Warn("runner: KillAll: kill failed", logger.FieldAgentID, proc.ID, logger.FieldError, err)
 		}
+		m.releasePort(proc)
 	}
 }
 
-// CleanOrphanedProcesses 清理上次异常退出残留的 codex app-server 子进程。
+// releasePort 释放已移出 agents 的进程的端口预留。
+func (m *AgentManager) releasePort(proc *AgentProcess) {
+	proc.mu.Lock()
+	port := proc.port
+	proc.mu.Unlock()
+	m.ports.Release(port, proc.ID)
+}
+
+// CleanOrphanedProcesses 清理上次异常退出残留的 codex 子进程 (keep 中的 PID 已被接管, 跳过)。
 //
-// 通过 pgrep 查找 "codex.*app-server.*--listen" 进程, 逐个 SIGKILL。
-// 仅在应用启动时调用一次。
-func CleanOrphanedProcesses() {
-	out, err := exec.Command("pgrep", "-f", "codex app-server --listen").Output()
-	if err != nil {
-		// pgrep exit 1 = 没找到匹配进程 (正常)
-		return
+// 先按上次运行的端口预留记录定位 (含自定义 CODEX_BIN 路径与 http-api 传输),
+// 再通过 pgrep 兜底查找 "codex app-server --listen" 进程, 逐个 SIGKILL。
+// 仅在应用启动时 (首次 Launch 之前, AdoptOrphans 之后) 调用一次。
+func CleanOrphanedProcesses(allocs []PortAllocation, keep map[int]bool) {
+	patterns := []string{"codex app-server --listen"}
+	for _, alloc := range allocs {
+		patterns = append(patterns,
+			"app-server --listen ws://127.0.0.1:"+strconv.Itoa(alloc.Port)+"$",
+			"http-api --p1 "+strconv.Itoa(alloc.Port)+"$",
+		)
 	}
-	lines := bytes.Split(bytes.TrimSpace(out), []byte("\n"))
+
+	seen := make(map[int]bool)
 	killed := 0
-	for _, line := range lines {
-		pidStr := strings.TrimSpace(string(line))
-		pid, parseErr := strconv.Atoi(pidStr)
-		if parseErr != nil || pid <= 0 {
+	for _, pattern := range patterns {
+		out, err := exec.Command("pgrep", "-f", pattern).Output()
+		if err != nil {
+			// pgrep exit 1 = 没找到匹配进程 (正常)
 			continue
 		}
-		if killErr := syscall.Kill(pid, syscall.SIGKILL); killErr == nil {
-			killed++
+		for _, line := range bytes.Split(bytes.TrimSpace(out), []byte("\n")) {
+			pid, parseErr := strconv.Atoi(strings.TrimSpace(string(line)))
+			if parseErr != nil || pid <= 0 || seen[pid] || keep[pid] {
+				continue
+			}
+			seen[pid] = true
+			if killErr := syscall.Kill(pid, syscall.SIGKILL); killErr == nil {
+				killed++
+			}
 		}
 	}
 	if killed > 0 {
-		logger.Warn("runner: cleaned orphaned codex app-server processes",
+		logger.Warn("runner: cleaned orphaned codex processes",
 			logger.FieldCount, killed,
-			"total_found", len(lines),
+			"total_found", len(seen),
+			"adopted", len(keep),
 		)
 	}
 }
@@ -534,6 +684,8 @@ func (m *AgentManager) List() []AgentInfo {
 
 	infos := make([]AgentInfo, 0, len(snapshot))
 	for _, proc := range snapshot {
+		policy, hasPolicy := m.RestartPolicyFor(proc.ID)
+		restarts := m.restartCount(proc.ID)
 		proc.mu.Lock()
 		info := AgentInfo{
 			ID:         proc.ID,
@@ -542,8 +694,12 @@ func (m *AgentManager) List() []AgentInfo {
 			ThreadID:   proc.Client.GetThreadID(),
 			State:      proc.State,
 			LastReport: proc.LastReport,
+			Restarts:   restarts,
 		}
 		proc.mu.Unlock()
+		if hasPolicy {
+			info.RestartPolicy = &policy
+		}
 		infos = append(infos, info)
 	}
 	sort.SliceStable(infos, func(i, j int) bool {
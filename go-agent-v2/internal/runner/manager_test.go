@@ -44,8 +44,11 @@ type fakeLaunchClient struct {
 	threadID   string
 	spawnErr   error
 	spawnCalls atomic.Int32
+	extraEnv   []string
 }
 
+func (f *fakeLaunchClient) SetExtraEnv(env []string) { f.extraEnv = env }
+
 func (f *fakeLaunchClient) GetPort() int                         { return f.port }
 func (f *fakeLaunchClient) GetThreadID() string                  { return f.threadID }
 func (f *fakeLaunchClient) SetEventHandler(_ codex.EventHandler) {}
@@ -367,6 +370,106 @@ func TestLaunch_FallbackFailureRemovesAgent(t *testing.T) {
 	}
 }
 
+func TestLaunch_AppliesLaunchEnvToBothTransports(t *testing.T) {
+	mgr := NewAgentManager()
+	appClient := &fakeLaunchClient{spawnErr: errors.New("ws connect failed")}
+	restClient := &fakeLaunchClient{}
+	mgr.appServerFactory = func(int, string) codex.CodexClient { return appClient }
+	mgr.restFactory = func(int, string) codex.CodexClient { return restClient }
+	mgr.SetLaunchEnvResolver(func(agentID string) []string {
+		if agentID != "agent-env" {
+			return nil
+		}
+		return []string{"HTTPS_PROXY=http://proxy:3128"}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := mgr.Launch(ctx, "agent-env", "Agent Env", "", ".", "", nil); err != nil {
+		t.Fatalf("Launch returned error: %v", err)
+	}
+	for name, client := range map[string]*fakeLaunchClient{"app-server": appClient, "rest": restClient} {
+		if len(client.extraEnv) != 1 || client.extraEnv[0] != "HTTPS_PROXY=http://proxy:3128" {
+			t.Fatalf("%s extraEnv = %v", name, client.extraEnv)
+		}
+	}
+}
+
+func TestSetClientFactory_InjectsClientWithoutRESTFallback(t *testing.T) {
+	mgr := NewAgentManager()
+	injected := &fakeLaunchClient{spawnErr: errors.New("fake backend down")}
+	mgr.SetClientFactory(func(int, string) codex.CodexClient { return injected })
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := mgr.Launch(ctx, "agent-injected", "Agent Injected", "", ".", "", nil); err == nil {
+		t.Fatal("expected launch error without REST fallback")
+	}
+	if injected.spawnCalls.Load() != 1 {
+		t.Fatalf("injected spawn calls = %d, want 1", injected.spawnCalls.Load())
+	}
+	if mgr.Get("agent-injected") != nil {
+		t.Fatal("expected failed launch agent to be removed from manager")
+	}
+}
+
+func TestLaunch_NotifiesOnLaunchedOnlyOnSuccess(t *testing.T) {
+	mgr := NewAgentManager()
+	client := &fakeLaunchClient{}
+	mgr.SetClientFactory(func(int, string) codex.CodexClient { return client })
+	var launched []string
+	mgr.SetOnLaunched(func(proc *AgentProcess) { launched = append(launched, proc.ID) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := mgr.Launch(ctx, "agent-ok", "Agent OK", "", ".", "", nil); err != nil {
+		t.Fatalf("Launch returned error: %v", err)
+	}
+	client.spawnErr = errors.New("backend down")
+	if err := mgr.Launch(ctx, "agent-fail", "Agent Fail", "", ".", "", nil); err == nil {
+		t.Fatal("expected launch error")
+	}
+	if len(launched) != 1 || launched[0] != "agent-ok" {
+		t.Fatalf("launched = %v, want [agent-ok]", launched)
+	}
+}
+
+func TestLaunch_RetriesOnPortTakenAndReleasesOnStop(t *testing.T) {
+	mgr := NewAgentManager()
+	mgr.ports.probe = func(int) bool { return true }
+	var ports []int
+	mgr.SetClientFactory(func(port int, _ string) codex.CodexClient {
+		ports = append(ports, port)
+		client := &fakeLaunchClient{port: port}
+		if len(ports) == 1 {
+			client.spawnErr = fmt.Errorf("spawn: %w", codex.ErrPortInUse)
+		}
+		return client
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := mgr.Launch(ctx, "agent-port", "Agent Port", "", ".", "", nil); err != nil {
+		t.Fatalf("Launch returned error: %v", err)
+	}
+	if len(ports) != 2 || ports[0] == ports[1] {
+		t.Fatalf("ports tried = %v, want two distinct ports", ports)
+	}
+	if got := mgr.Get("agent-port").Client.GetPort(); got != ports[1] {
+		t.Fatalf("active client port = %d, want %d", got, ports[1])
+	}
+	allocs := mgr.PortAllocations()
+	if len(allocs) != 1 || allocs[0].Port != ports[1] || allocs[0].AgentID != "agent-port" {
+		t.Fatalf("allocations = %+v", allocs)
+	}
+	if err := mgr.Stop("agent-port"); err != nil {
+		t.Fatal(err)
+	}
+	if allocs := mgr.PortAllocations(); len(allocs) != 0 {
+		t.Fatalf("allocations after stop = %+v", allocs)
+	}
+}
+
 // ========================================
 // 任务报告提取测试
 // ========================================
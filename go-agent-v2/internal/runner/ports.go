@@ -0,0 +1,223 @@
+// ports.go — agent 端口集中分配。
+//
+// 原先 findFreePort 探测后立即释放, 到 codex 真正 bind 之间存在窗口: 并发 Launch 可能拿到同一端口。
+// PortAllocator 在锁内从配置区间挑选未预留且可 bind 的端口并记录预留, 直到 agent 停止才释放;
+// 预留表 (含启动成功后的进程 PID) 写入状态文件, 下次启动时据此接管 (AdoptOrphans) 或清理
+// (CleanOrphanedProcesses) 上次残留的 codex 进程。
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+// 默认端口区间 (含两端)。
+const (
+	DefaultPortMin = 19836
+	DefaultPortMax = 20835
+)
+
+// maxPortBindAttempts 端口在预留后被外部进程抢占时, Launch 换端口重试的总次数。
+const maxPortBindAttempts = 3
+
+// PortAllocation 一条端口预留记录。
+type PortAllocation struct {
+	Port       int       `json:"port"`
+	AgentID    string    `json:"agentId"`
+	Name       string    `json:"name,omitempty"`
+	PID        int       `json:"pid,omitempty"` // app-server 启动成功后记录, 0 = 不可接管
+	ReservedAt time.Time `json:"reservedAt"`
+}
+
+// PortOptions 端口分配配置。
+type PortOptions struct {
+	Min       int    // 区间下限, 0 = DefaultPortMin
+	Max       int    // 区间上限, 0 = DefaultPortMax
+	StateFile string // 预留记录文件, 空 = 不持久化
+}
+
+// PortAllocator 从固定区间原子预留端口。
+type PortAllocator struct {
+	mu        sync.Mutex
+	min, max  int
+	next      int
+	byPort    map[int]PortAllocation
+	stateFile string
+	probe     func(port int) bool // 端口当前能否 bind (测试可替换)
+}
+
+// NewPortAllocator 创建默认区间、不持久化的分配器。
+func NewPortAllocator() *PortAllocator {
+	return &PortAllocator{
+		min:    DefaultPortMin,
+		max:    DefaultPortMax,
+		next:   DefaultPortMin,
+		byPort: make(map[int]PortAllocation),
+		probe:  portBindable,
+	}
+}
+
+// Configure 设置区间与状态文件并立即写入当前预留 (覆盖上次运行的记录, 应在读取旧记录之后调用);
+// 已有预留保留 (区间外的预留在释放前仍有效)。
+func (a *PortAllocator) Configure(opts PortOptions) error {
+	minPort, maxPort := opts.Min, opts.Max
+	if minPort == 0 {
+		minPort = DefaultPortMin
+	}
+	if maxPort == 0 {
+		maxPort = DefaultPortMax
+	}
+	if minPort < 1 || maxPort > 65535 || minPort > maxPort {
+		return apperrors.Newf("PortAllocator.Configure", "invalid port range %d-%d", minPort, maxPort)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.min, a.max = minPort, maxPort
+	if a.next < minPort || a.next > maxPort {
+		a.next = minPort
+	}
+	a.stateFile = strings.TrimSpace(opts.StateFile)
+	a.persistLocked()
+	return nil
+}
+
+// Reserve 为 agent 预留一个端口: 从上次位置起轮询区间, 跳过已预留与当前无法 bind 的端口。
+func (a *PortAllocator) Reserve(agentID string) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	size := a.max - a.min + 1
+	for i := 0; i < size; i++ {
+		port := a.next
+		a.next++
+		if a.next > a.max {
+			a.next = a.min
+		}
+		if _, taken := a.byPort[port]; taken || !a.probe(port) {
+			continue
+		}
+		a.byPort[port] = PortAllocation{Port: port, AgentID: agentID, ReservedAt: time.Now()}
+		a.persistLocked()
+		return port, nil
+	}
+	return 0, apperrors.Newf("PortAllocator.Reserve", "no free port in range %d-%d (%d reserved)", a.min, a.max, len(a.byPort))
+}
+
+// claim 登记接管进程已占用的端口 (不探测 bind)。
+func (a *PortAllocator) claim(alloc PortAllocation) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.byPort[alloc.Port] = alloc
+	a.persistLocked()
+}
+
+// annotate 为 agent 的预留补充进程信息。
+func (a *PortAllocator) annotate(port int, agentID, name string, pid int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	alloc, ok := a.byPort[port]
+	if !ok || alloc.AgentID != agentID {
+		return
+	}
+	alloc.Name, alloc.PID = name, pid
+	a.byPort[port] = alloc
+	a.persistLocked()
+}
+
+// Release 释放 agent 的端口预留 (预留已转给其他 agent 时忽略)。
+func (a *PortAllocator) Release(port int, agentID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if alloc, ok := a.byPort[port]; ok && alloc.AgentID == agentID {
+		delete(a.byPort, port)
+		a.persistLocked()
+	}
+}
+
+// Allocations 返回当前预留快照 (按端口排序)。
+func (a *PortAllocator) Allocations() []PortAllocation {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	out := make([]PortAllocation, 0, len(a.byPort))
+	for _, alloc := range a.byPort {
+		out = append(out, alloc)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Port < out[j].Port })
+	return out
+}
+
+// persistLocked 覆盖写状态文件 (失败仅记日志, 不影响分配)。
+func (a *PortAllocator) persistLocked() {
+	if a.stateFile == "" {
+		return
+	}
+	out := make([]PortAllocation, 0, len(a.byPort))
+	for _, alloc := range a.byPort {
+		out = append(out, alloc)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Port < out[j].Port })
+	if err := writePortAllocations(a.stateFile, out); err != nil {
+		logger.Warn("runner: persist port allocations failed", logger.FieldPath, a.stateFile, logger.FieldError, err)
+	}
+}
+
+func writePortAllocations(path string, allocs []PortAllocation) error {
+	data, err := json.MarshalIndent(allocs, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// ReadPortAllocations 读取状态文件中的预留记录 (文件不存在返回 nil)。
+func ReadPortAllocations(path string) ([]PortAllocation, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var allocs []PortAllocation
+	if err := json.Unmarshal(data, &allocs); err != nil {
+		return nil, apperrors.Wrap(err, "runner.ReadPortAllocations", "decode port allocations")
+	}
+	return allocs, nil
+}
+
+// ResolvePortStateFile 返回状态文件路径 (空 = ~/.multi-agent/agent-ports.json)。
+func ResolvePortStateFile(path string) string {
+	if path = strings.TrimSpace(path); path != "" {
+		return path
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(homeDir, ".multi-agent", "agent-ports.json")
+}
+
+func portBindable(port int) bool {
+	ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return false
+	}
+	_ = ln.Close()
+	return true
+}
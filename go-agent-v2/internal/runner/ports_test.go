@@ -0,0 +1,97 @@
+package runner
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func newTestAllocator(t *testing.T, minPort, maxPort int, busy map[int]bool) *PortAllocator {
+	t.Helper()
+	a := NewPortAllocator()
+	a.probe = func(port int) bool { return !busy[port] }
+	if err := a.Configure(PortOptions{Min: minPort, Max: maxPort}); err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestPortAllocator_ConcurrentReservationsAreUnique(t *testing.T) {
+	a := newTestAllocator(t, 30000, 30063, nil)
+	var mu sync.Mutex
+	seen := make(map[int]bool)
+	var wg sync.WaitGroup
+	for i := 0; i < 64; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			port, err := a.Reserve("agent")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if seen[port] {
+				t.Errorf("port %d reserved twice", port)
+			}
+			seen[port] = true
+		}()
+	}
+	wg.Wait()
+	if _, err := a.Reserve("overflow"); err == nil {
+		t.Fatal("expected exhausted range error")
+	}
+}
+
+func TestPortAllocator_SkipsBusyPortsAndReusesReleased(t *testing.T) {
+	a := newTestAllocator(t, 30100, 30102, map[int]bool{30100: true})
+	first, err := a.Reserve("a")
+	if err != nil || first != 30101 {
+		t.Fatalf("first = %d, %v; want 30101", first, err)
+	}
+	second, _ := a.Reserve("b")
+	if second != 30102 {
+		t.Fatalf("second = %d, want 30102", second)
+	}
+	a.Release(first, "someone-else")
+	if _, err := a.Reserve("c"); err == nil {
+		t.Fatal("release by another agent must not free the port")
+	}
+	a.Release(first, "a")
+	if third, err := a.Reserve("c"); err != nil || third != first {
+		t.Fatalf("third = %d, %v; want %d", third, err, first)
+	}
+}
+
+func TestPortAllocator_PersistsAllocations(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "ports.json")
+	a := newTestAllocator(t, 30200, 30210, nil)
+	if err := a.Configure(PortOptions{Min: 30200, Max: 30210, StateFile: stateFile}); err != nil {
+		t.Fatal(err)
+	}
+	port, _ := a.Reserve("agent-1")
+	other, _ := a.Reserve("agent-2")
+	a.Release(other, "agent-2")
+
+	allocs, err := ReadPortAllocations(stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(allocs) != 1 || allocs[0].Port != port || allocs[0].AgentID != "agent-1" {
+		t.Fatalf("persisted = %+v", allocs)
+	}
+	if allocs, err := ReadPortAllocations(filepath.Join(t.TempDir(), "missing.json")); err != nil || allocs != nil {
+		t.Fatalf("missing file = %+v, %v", allocs, err)
+	}
+}
+
+func TestPortAllocator_RejectsInvalidRange(t *testing.T) {
+	a := NewPortAllocator()
+	if err := a.Configure(PortOptions{Min: 5000, Max: 4000}); err == nil {
+		t.Fatal("expected error for inverted range")
+	}
+	if err := a.Configure(PortOptions{Min: 1, Max: 70000}); err == nil {
+		t.Fatal("expected error for out-of-range max")
+	}
+}
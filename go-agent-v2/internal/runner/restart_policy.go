@@ -0,0 +1,122 @@
+// restart_policy.go — agent 级重启策略 (类似 supervisord autorestart)。
+//
+// 策略在 Launch 时配置 (LaunchOptions.RestartPolicy), 按 agent ID 保存: 健康检查重启 agent 时
+// 会 Stop 后重新 Launch, 策略与重启计数不随进程丢失。
+// runner 只保存与展示策略, 崩溃判定与退避调度由 apiserver 健康检查执行。
+package runner
+
+import (
+	"strings"
+
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+// RestartMode 重启模式。
+type RestartMode string
+
+const (
+	// RestartNever 从不自动重启 (仅上报异常)。
+	RestartNever RestartMode = "never"
+	// RestartOnFailure 崩溃 / 失去响应时重启, 进程正常退出 (exit 0) 不重启。
+	RestartOnFailure RestartMode = "on-failure"
+	// RestartAlways 进程退出 (无论退出码) 或失去响应时都重启。
+	RestartAlways RestartMode = "always"
+)
+
+// 重启策略上限。
+const (
+	maxRestartPolicyRestarts  = 100
+	maxRestartPolicyBackoffMs = 5 * 60 * 1000
+)
+
+// RestartPolicy agent 重启策略。
+type RestartPolicy struct {
+	Mode        RestartMode `json:"mode"`
+	MaxRestarts int         `json:"maxRestarts,omitempty"` // 统计窗口内最多重启次数, 0 = 使用全局默认
+	BackoffMs   int64       `json:"backoffMs,omitempty"`   // 首次重启等待, 之后逐次翻倍; 0 = 使用全局默认
+}
+
+// Normalize 校验并规范化策略 (模式大小写不敏感, 接受 on_failure / onFailure 写法)。
+func (p RestartPolicy) Normalize() (RestartPolicy, error) {
+	const op = "RestartPolicy.Normalize"
+	mode := strings.ToLower(strings.TrimSpace(string(p.Mode)))
+	mode = strings.NewReplacer("_", "-", "onfailure", "on-failure").Replace(mode)
+	switch RestartMode(mode) {
+	case RestartNever, RestartOnFailure, RestartAlways:
+		p.Mode = RestartMode(mode)
+	case "":
+		return p, apperrors.NewCode(op, errcode.InvalidInput, "restart policy mode is required (never / on-failure / always)")
+	default:
+		return p, apperrors.NewCodef(op, errcode.InvalidInput, "unknown restart policy mode %q (never / on-failure / always)", p.Mode)
+	}
+	if p.MaxRestarts < 0 || p.MaxRestarts > maxRestartPolicyRestarts {
+		return p, apperrors.NewCodef(op, errcode.InvalidInput, "maxRestarts must be between 0 and %d", maxRestartPolicyRestarts)
+	}
+	if p.BackoffMs < 0 || p.BackoffMs > maxRestartPolicyBackoffMs {
+		return p, apperrors.NewCodef(op, errcode.InvalidInput, "backoffMs must be between 0 and %d", maxRestartPolicyBackoffMs)
+	}
+	if p.Mode == RestartNever {
+		p.MaxRestarts, p.BackoffMs = 0, 0
+	}
+	return p, nil
+}
+
+// agentRestartState 按 agent ID 保存的策略与累计重启次数。
+type agentRestartState struct {
+	policy   *RestartPolicy
+	restarts int
+}
+
+// SetRestartPolicy 设置 agent 的重启策略 (nil = 回退全局默认)。
+func (m *AgentManager) SetRestartPolicy(id string, policy *RestartPolicy) error {
+	if policy != nil {
+		normalized, err := policy.Normalize()
+		if err != nil {
+			return err
+		}
+		policy = &normalized
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	state := m.restartState(id)
+	state.policy = policy
+	return nil
+}
+
+// RestartPolicyFor 返回 agent 显式配置的重启策略。
+func (m *AgentManager) RestartPolicyFor(id string) (RestartPolicy, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	state, ok := m.restarts[id]
+	if !ok || state.policy == nil {
+		return RestartPolicy{}, false
+	}
+	return *state.policy, true
+}
+
+// NoteRestart 累计一次自动重启 (在 AgentInfo.Restarts 展示)。
+func (m *AgentManager) NoteRestart(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.restartState(id).restarts++
+}
+
+func (m *AgentManager) restartCount(id string) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if state, ok := m.restarts[id]; ok {
+		return state.restarts
+	}
+	return 0
+}
+
+// restartState 调用方需持有 mu 写锁。
+func (m *AgentManager) restartState(id string) *agentRestartState {
+	state, ok := m.restarts[id]
+	if !ok {
+		state = &agentRestartState{}
+		m.restarts[id] = state
+	}
+	return state
+}
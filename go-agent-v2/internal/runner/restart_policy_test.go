@@ -0,0 +1,77 @@
+package runner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/internal/codex"
+)
+
+func TestRestartPolicy_Normalize(t *testing.T) {
+	cases := []struct {
+		in      RestartPolicy
+		want    RestartPolicy
+		wantErr bool
+	}{
+		{in: RestartPolicy{Mode: "Always", MaxRestarts: 5, BackoffMs: 1000}, want: RestartPolicy{Mode: RestartAlways, MaxRestarts: 5, BackoffMs: 1000}},
+		{in: RestartPolicy{Mode: "on_failure"}, want: RestartPolicy{Mode: RestartOnFailure}},
+		{in: RestartPolicy{Mode: "onFailure"}, want: RestartPolicy{Mode: RestartOnFailure}},
+		{in: RestartPolicy{Mode: "never", MaxRestarts: 3, BackoffMs: 500}, want: RestartPolicy{Mode: RestartNever}},
+		{in: RestartPolicy{}, wantErr: true},
+		{in: RestartPolicy{Mode: "sometimes"}, wantErr: true},
+		{in: RestartPolicy{Mode: "always", MaxRestarts: -1}, wantErr: true},
+		{in: RestartPolicy{Mode: "always", BackoffMs: maxRestartPolicyBackoffMs + 1}, wantErr: true},
+	}
+	for _, tc := range cases {
+		got, err := tc.in.Normalize()
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("Normalize(%+v) expected error", tc.in)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("Normalize(%+v) = %+v, %v; want %+v", tc.in, got, err, tc.want)
+		}
+	}
+}
+
+func TestLaunchWithOptions_PolicySurvivesRelaunchAndAppearsInList(t *testing.T) {
+	mgr := NewAgentManager()
+	mgr.SetClientFactory(func(int, string) codex.CodexClient { return &fakeLaunchClient{} })
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	err := mgr.LaunchWithOptions(ctx, LaunchOptions{
+		ID: "agent-1", Name: "Agent 1", Cwd: ".",
+		RestartPolicy: &RestartPolicy{Mode: "on-failure", MaxRestarts: 2},
+	})
+	if err != nil {
+		t.Fatalf("LaunchWithOptions: %v", err)
+	}
+	infos := mgr.List()
+	if len(infos) != 1 || infos[0].RestartPolicy == nil || infos[0].RestartPolicy.Mode != RestartOnFailure {
+		t.Fatalf("List() restart policy = %+v", infos)
+	}
+
+	// 健康检查重启: Stop 后不带策略重新 Launch, 策略与计数保留。
+	mgr.NoteRestart("agent-1")
+	if err := mgr.Stop("agent-1"); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if err := mgr.Launch(ctx, "agent-1", "Agent 1", "", ".", "", nil); err != nil {
+		t.Fatalf("Launch: %v", err)
+	}
+	infos = mgr.List()
+	if len(infos) != 1 || infos[0].RestartPolicy == nil || infos[0].RestartPolicy.MaxRestarts != 2 || infos[0].Restarts != 1 {
+		t.Fatalf("after relaunch List() = %+v", infos)
+	}
+
+	if err := mgr.LaunchWithOptions(ctx, LaunchOptions{ID: "agent-2", RestartPolicy: &RestartPolicy{Mode: "bogus"}}); err == nil {
+		t.Fatal("expected invalid policy error")
+	}
+	if mgr.Get("agent-2") != nil {
+		t.Fatal("agent with invalid policy should not launch")
+	}
+}
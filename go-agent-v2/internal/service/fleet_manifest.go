@@ -0,0 +1,199 @@
+// fleet_manifest.go — 声明式 agent 编队清单 (fleet manifest) 解析与校验。
+//
+// 清单示例 (YAML):
+//
+//	version: 1
+//	profiles:
+//	  reviewer:
+//	    instructions: "You review Go changes."
+//	    model: gpt-5
+//	agents:
+//	  - name: go-reviewer
+//	    profile: reviewer
+//	    cwd: ./services/api
+//	    skills: [go-review]
+//	    schedule: "@every 30m"
+//	    prompt: "Review commits merged since your last run."
+//
+// cwd 相对清单所在目录解析; schedule 支持 Go duration ("30m")、"@every <duration>"、
+// "@hourly"、"@daily", 设置 schedule 时必须提供 prompt。
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+const (
+	fleetManifestVersion  = 1
+	maxFleetAgents        = 20
+	minFleetScheduleEvery = time.Minute
+)
+
+var fleetAgentNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// FleetProfile 可复用的 agent 配置 (系统指令 / 模型)。
+type FleetProfile struct {
+	Instructions string `yaml:"instructions" json:"instructions,omitempty"`
+	Model        string `yaml:"model" json:"model,omitempty"`
+}
+
+// FleetAgentSpec 清单中单个 agent 的期望状态。
+type FleetAgentSpec struct {
+	Name     string   `yaml:"name" json:"name"`
+	Profile  string   `yaml:"profile" json:"profile,omitempty"`
+	Cwd      string   `yaml:"cwd" json:"cwd"`
+	Skills   []string `yaml:"skills" json:"skills,omitempty"`
+	Schedule string   `yaml:"schedule" json:"schedule,omitempty"`
+	Prompt   string   `yaml:"prompt" json:"prompt,omitempty"`
+}
+
+// FleetManifest 编队清单。
+type FleetManifest struct {
+	Version  int                     `yaml:"version" json:"version"`
+	Profiles map[string]FleetProfile `yaml:"profiles" json:"profiles,omitempty"`
+	Agents   []FleetAgentSpec        `yaml:"agents" json:"agents"`
+}
+
+// ParseFleetManifest 解析 YAML (或 JSON) 清单并校验; 相对 cwd 基于 baseDir 解析为绝对路径。
+func ParseFleetManifest(data []byte, baseDir string) (*FleetManifest, error) {
+	var manifest FleetManifest
+	if err := yaml.Unmarshal(data, &manifest); err != nil {
+		return nil, apperrors.Wrap(err, "ParseFleetManifest", "parse manifest")
+	}
+	if manifest.Version == 0 {
+		manifest.Version = fleetManifestVersion
+	}
+	if manifest.Version != fleetManifestVersion {
+		return nil, apperrors.Newf("ParseFleetManifest", "unsupported manifest version %d", manifest.Version)
+	}
+	if len(manifest.Agents) > maxFleetAgents {
+		return nil, apperrors.Newf("ParseFleetManifest", "too many agents: %d (limit %d)", len(manifest.Agents), maxFleetAgents)
+	}
+
+	seen := make(map[string]struct{}, len(manifest.Agents))
+	for i := range manifest.Agents {
+		spec := &manifest.Agents[i]
+		spec.Name = strings.TrimSpace(spec.Name)
+		if !fleetAgentNameRe.MatchString(spec.Name) {
+			return nil, apperrors.Newf("ParseFleetManifest", "agents[%d]: invalid name %q", i, spec.Name)
+		}
+		if _, dup := seen[spec.Name]; dup {
+			return nil, apperrors.Newf("ParseFleetManifest", "duplicate agent name %q", spec.Name)
+		}
+		seen[spec.Name] = struct{}{}
+
+		spec.Profile = strings.TrimSpace(spec.Profile)
+		if spec.Profile != "" {
+			if _, ok := manifest.Profiles[spec.Profile]; !ok {
+				return nil, apperrors.Newf("ParseFleetManifest", "agent %q: unknown profile %q", spec.Name, spec.Profile)
+			}
+		}
+
+		spec.Cwd = strings.TrimSpace(spec.Cwd)
+		if spec.Cwd == "" {
+			spec.Cwd = "."
+		}
+		if !filepath.IsAbs(spec.Cwd) && strings.TrimSpace(baseDir) != "" {
+			spec.Cwd = filepath.Join(baseDir, spec.Cwd)
+		}
+		if abs, err := filepath.Abs(spec.Cwd); err == nil {
+			spec.Cwd = abs
+		}
+
+		spec.Skills = normalizeFleetSkills(spec.Skills)
+		spec.Schedule = strings.TrimSpace(spec.Schedule)
+		spec.Prompt = strings.TrimSpace(spec.Prompt)
+		if spec.Schedule != "" {
+			if _, err := ParseFleetSchedule(spec.Schedule); err != nil {
+				return nil, apperrors.Wrapf(err, "ParseFleetManifest", "agent %q", spec.Name)
+			}
+			if spec.Prompt == "" {
+				return nil, apperrors.Newf("ParseFleetManifest", "agent %q: schedule requires prompt", spec.Name)
+			}
+		}
+	}
+	return &manifest, nil
+}
+
+// ParseFleetSchedule 解析调度间隔。
+func ParseFleetSchedule(raw string) (time.Duration, error) {
+	value := strings.TrimSpace(raw)
+	switch value {
+	case "@hourly":
+		return time.Hour, nil
+	case "@daily":
+		return 24 * time.Hour, nil
+	}
+	value = strings.TrimSpace(strings.TrimPrefix(value, "@every"))
+	every, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, apperrors.Newf("ParseFleetSchedule", "invalid schedule %q (want 30m, @every 1h, @hourly or @daily)", raw)
+	}
+	if every < minFleetScheduleEvery {
+		return 0, apperrors.Newf("ParseFleetSchedule", "schedule %q shorter than %s", raw, minFleetScheduleEvery)
+	}
+	return every, nil
+}
+
+// ProfileFor 返回 agent 引用的 profile (未引用时为零值)。
+func (m *FleetManifest) ProfileFor(spec FleetAgentSpec) FleetProfile {
+	if m == nil || spec.Profile == "" {
+		return FleetProfile{}
+	}
+	return m.Profiles[spec.Profile]
+}
+
+// FleetLaunchHash 影响进程启动参数的字段指纹 (变化时需重建 agent)。
+func FleetLaunchHash(spec FleetAgentSpec, profile FleetProfile) string {
+	return fleetHash(map[string]any{
+		"cwd":          spec.Cwd,
+		"instructions": profile.Instructions,
+	})
+}
+
+// FleetSpecHash 完整期望状态指纹 (变化时需原地更新)。
+func FleetSpecHash(spec FleetAgentSpec, profile FleetProfile) string {
+	return fleetHash(map[string]any{
+		"spec":    spec,
+		"profile": profile,
+	})
+}
+
+func fleetHash(value any) string {
+	data, _ := json.Marshal(value)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+func normalizeFleetSkills(raw []string) []string {
+	if len(raw) == 0 {
+		return nil
+	}
+	seen := make(map[string]struct{}, len(raw))
+	out := make([]string, 0, len(raw))
+	for _, skill := range raw {
+		name := strings.TrimSpace(skill)
+		key := strings.ToLower(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseFleetManifestResolvesAndValidates(t *testing.T) {
+	base := t.TempDir()
+	manifest, err := ParseFleetManifest([]byte(`
+profiles:
+  reviewer:
+    instructions: review go code
+    model: gpt-5
+agents:
+  - name: go-reviewer
+    profile: reviewer
+    cwd: ./api
+    skills: [lint, go-review, lint]
+    schedule: "@every 30m"
+    prompt: review new commits
+  - name: scratch
+`), base)
+	if err != nil {
+		t.Fatalf("ParseFleetManifest: %v", err)
+	}
+	reviewer := manifest.Agents[0]
+	if reviewer.Cwd != filepath.Join(base, "api") {
+		t.Fatalf("cwd = %q", reviewer.Cwd)
+	}
+	if strings.Join(reviewer.Skills, ",") != "go-review,lint" {
+		t.Fatalf("skills = %v", reviewer.Skills)
+	}
+	if manifest.ProfileFor(reviewer).Model != "gpt-5" || manifest.ProfileFor(manifest.Agents[1]) != (FleetProfile{}) {
+		t.Fatal("unexpected profile resolution")
+	}
+
+	invalid := map[string]string{
+		"unknown profile":    "agents: [{name: a, profile: missing}]",
+		"duplicate name":     "agents: [{name: a}, {name: a}]",
+		"bad name":           "agents: [{name: '-a'}]",
+		"schedule no prompt": "agents: [{name: a, schedule: 1h}]",
+		"short schedule":     "agents: [{name: a, schedule: 10s, prompt: x}]",
+		"version":            "version: 2\nagents: []",
+	}
+	for name, doc := range invalid {
+		if _, err := ParseFleetManifest([]byte(doc), base); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestParseFleetSchedule(t *testing.T) {
+	cases := map[string]time.Duration{"30m": 30 * time.Minute, "@every 2h": 2 * time.Hour, "@hourly": time.Hour, "@daily": 24 * time.Hour}
+	for raw, want := range cases {
+		if got, err := ParseFleetSchedule(raw); err != nil || got != want {
+			t.Errorf("ParseFleetSchedule(%q) = (%v, %v), want %v", raw, got, err, want)
+		}
+	}
+	if _, err := ParseFleetSchedule("weekly"); err == nil {
+		t.Error("expected error for unsupported schedule")
+	}
+}
+
+func TestFleetHashesSeparateLaunchAndSpecChanges(t *testing.T) {
+	spec := FleetAgentSpec{Name: "a", Cwd: "/repo", Skills: []string{"lint"}}
+	profile := FleetProfile{Instructions: "x"}
+	launch, full := FleetLaunchHash(spec, profile), FleetSpecHash(spec, profile)
+
+	spec.Skills = []string{"lint", "test"}
+	if FleetLaunchHash(spec, profile) != launch || FleetSpecHash(spec, profile) == full {
+		t.Fatal("skill change should only alter spec hash")
+	}
+	profile.Instructions = "y"
+	if FleetLaunchHash(spec, profile) == launch {
+		t.Fatal("instruction change should alter launch hash")
+	}
+}
@@ -0,0 +1,325 @@
+// input_ingest.go — 拖放文件输入预处理: stat → 分类 (image/text/binary/directory) → 暂存。
+//
+// 小文本文件直接内联; 图片、大文本与二进制文件按内容哈希复制到暂存目录
+// (<staging>/<sha256 前 16 位><ext>), 源文件随后被移动/删除也不影响已提交的 turn,
+// 相同内容重复拖入只保留一份。粘贴的图片 (base64 data URL) 经 StageImageDataURL 写入同一暂存目录。
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+// 文件分类。
+const (
+	IngestKindImage     = "image"
+	IngestKindText      = "text"
+	IngestKindBinary    = "binary"
+	IngestKindDirectory = "directory"
+)
+
+const (
+	ingestSniffBytes       = 8 << 10
+	ingestStagedNameDigits = 16
+	defaultIngestDirName   = "multi-agent-inputs"
+)
+
+// imageExts 可作为 localImage 提交的图片扩展名。
+var imageExts = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".webp": true, ".bmp": true,
+}
+
+// IngestedFile 单个拖入路径的预处理结果 (Error 非空时其余字段可能不完整)。
+type IngestedFile struct {
+	Path       string `json:"path"` // 绝对源路径
+	Name       string `json:"name"`
+	Kind       string `json:"kind,omitempty"`
+	MimeType   string `json:"mimeType,omitempty"`
+	Size       int64  `json:"size"`
+	Content    string `json:"-"`                    // Kind=text 且内联时的文本
+	Inline     bool   `json:"inline,omitempty"`     // 文本已内联
+	StagedPath string `json:"stagedPath,omitempty"` // 暂存副本路径
+	Error      string `json:"error,omitempty"`
+}
+
+// FileIngestor 拖放文件预处理器。
+type FileIngestor struct {
+	stagingDir     string
+	inlineMaxBytes int64
+	maxFileBytes   int64
+}
+
+// NewFileIngestor 创建预处理器并确保暂存目录存在 (stagingDir 为空 = 系统临时目录下的 multi-agent-inputs)。
+func NewFileIngestor(stagingDir string, inlineMaxBytes, maxFileBytes int64) (*FileIngestor, error) {
+	stagingDir = strings.TrimSpace(stagingDir)
+	if stagingDir == "" {
+		stagingDir = DefaultInputStagingDir()
+	}
+	abs, err := filepath.Abs(stagingDir)
+	if err != nil {
+		return nil, apperrors.Wrap(err, "NewFileIngestor", "resolve staging dir")
+	}
+	if err := os.MkdirAll(abs, 0o700); err != nil {
+		return nil, apperrors.Wrap(err, "NewFileIngestor", "create staging dir")
+	}
+	return &FileIngestor{stagingDir: abs, inlineMaxBytes: max(inlineMaxBytes, 0), maxFileBytes: maxFileBytes}, nil
+}
+
+// DefaultInputStagingDir 未配置 INPUT_STAGING_DIR 时的暂存目录。
+func DefaultInputStagingDir() string { return filepath.Join(os.TempDir(), defaultIngestDirName) }
+
+// StagingDir 返回暂存目录。
+func (f *FileIngestor) StagingDir() string { return f.stagingDir }
+
+// Prepare 逐个处理路径 (相对路径基于 cwd); 单个文件失败记录在 Error 中, 不影响其他文件。
+func (f *FileIngestor) Prepare(ctx context.Context, cwd string, paths []string) []IngestedFile {
+	out := make([]IngestedFile, 0, len(paths))
+	for _, raw := range paths {
+		if ctx.Err() != nil {
+			out = append(out, IngestedFile{Path: raw, Name: filepath.Base(raw), Error: ctx.Err().Error()})
+			continue
+		}
+		file, err := f.prepareOne(cwd, raw)
+		if err != nil {
+			file.Error = err.Error()
+			logger.Warn("input: prepare file failed", logger.FieldPath, file.Path, logger.FieldError, err)
+		}
+		out = append(out, file)
+	}
+	return out
+}
+
+func (f *FileIngestor) prepareOne(cwd, raw string) (IngestedFile, error) {
+	const op = "FileIngestor.Prepare"
+	path := strings.TrimSpace(raw)
+	file := IngestedFile{Path: path, Name: filepath.Base(path)}
+	if path == "" {
+		return file, apperrors.New(op, "empty path")
+	}
+	if !filepath.IsAbs(path) && strings.TrimSpace(cwd) != "" {
+		path = filepath.Join(cwd, path)
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return file, apperrors.Wrap(err, op, "resolve path")
+	}
+	file.Path = path
+	info, err := os.Stat(path)
+	if err != nil {
+		return file, apperrors.Wrap(err, op, "stat")
+	}
+	if info.IsDir() {
+		file.Kind = IngestKindDirectory
+		return file, nil
+	}
+	if !info.Mode().IsRegular() {
+		return file, apperrors.Newf(op, "%s is not a regular file", path)
+	}
+	file.Size = info.Size()
+	if f.maxFileBytes > 0 && file.Size > f.maxFileBytes {
+		return file, apperrors.Newf(op, "%s is %d bytes, exceeds limit %d", file.Name, file.Size, f.maxFileBytes)
+	}
+
+	head, err := readHead(path, ingestSniffBytes)
+	if err != nil {
+		return file, err
+	}
+	file.Kind, file.MimeType = classifyFile(path, head)
+
+	if file.Kind == IngestKindText && file.Size <= f.inlineMaxBytes {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return file, apperrors.Wrap(err, op, "read text")
+		}
+		if utf8.Valid(data) {
+			file.Content = string(data)
+			file.Inline = true
+			return file, nil
+		}
+		file.Kind = IngestKindBinary
+	}
+	staged, err := f.stageFile(path)
+	if err != nil {
+		return file, err
+	}
+	file.StagedPath = staged
+	return file, nil
+}
+
+// classifyFile 按扩展名与内容嗅探分类。
+func classifyFile(path string, head []byte) (kind, mimeType string) {
+	ext := strings.ToLower(filepath.Ext(path))
+	sniffed := http.DetectContentType(head)
+	if imageExts[ext] && strings.HasPrefix(sniffed, "image/") {
+		return IngestKindImage, sniffed
+	}
+	if byExt := mime.TypeByExtension(ext); byExt != "" {
+		mimeType = byExt
+	} else {
+		mimeType = sniffed
+	}
+	if looksLikeText(head) {
+		return IngestKindText, mimeType
+	}
+	return IngestKindBinary, mimeType
+}
+
+// looksLikeText 无 NUL 且 (允许截断处残缺字符) 为合法 UTF-8 视为文本。
+func looksLikeText(head []byte) bool {
+	for _, b := range head {
+		if b == 0 {
+			return false
+		}
+	}
+	for i := 0; i < utf8.UTFMax && len(head) > 0; i++ {
+		if utf8.Valid(head) {
+			return true
+		}
+		head = head[:len(head)-1]
+	}
+	return len(head) == 0
+}
+
+func readHead(path string, n int) ([]byte, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, apperrors.Wrap(err, "FileIngestor.readHead", "open")
+	}
+	defer func() { _ = fh.Close() }()
+	buf := make([]byte, n)
+	read, err := io.ReadFull(fh, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, apperrors.Wrap(err, "FileIngestor.readHead", "read")
+	}
+	return buf[:read], nil
+}
+
+// stageFile 流式计算哈希并复制到暂存目录 (已存在同内容副本时直接复用)。
+func (f *FileIngestor) stageFile(path string) (string, error) {
+	const op = "FileIngestor.stageFile"
+	src, err := os.Open(path)
+	if err != nil {
+		return "", apperrors.Wrap(err, op, "open source")
+	}
+	defer func() { _ = src.Close() }()
+
+	tmp, err := os.CreateTemp(f.stagingDir, ".staging-*")
+	if err != nil {
+		return "", apperrors.Wrap(err, op, "create temp")
+	}
+	tmpName := tmp.Name()
+	defer func() { _ = os.Remove(tmpName) }()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hasher), src); err != nil {
+		_ = tmp.Close()
+		return "", apperrors.Wrap(err, op, "copy")
+	}
+	if err := tmp.Close(); err != nil {
+		return "", apperrors.Wrap(err, op, "close temp")
+	}
+	return f.commitStaged(tmpName, hex.EncodeToString(hasher.Sum(nil)), filepath.Ext(path))
+}
+
+// StagedImage 暂存的粘贴图片。
+type StagedImage struct {
+	Path     string `json:"path"`
+	MimeType string `json:"mimeType"`
+	Size     int64  `json:"size"`
+	SHA256   string `json:"sha256"`
+}
+
+// stagedImageExts 允许暂存的图片 MIME (以内容嗅探为准) 及其扩展名。
+var stagedImageExts = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+	"image/bmp":  ".bmp",
+}
+
+// StageImageDataURL 解码 base64 data URL (data:image/...;base64,...) 并按内容哈希写入暂存目录。
+//
+// 声明的 MIME 仅作格式校验, 实际类型与扩展名以内容嗅探为准; 超过 maxBytes (>0) 时在解码前拒绝。
+func (f *FileIngestor) StageImageDataURL(dataURL string, maxBytes int64) (StagedImage, error) {
+	const op = "FileIngestor.StageImageDataURL"
+	header, payload, ok := strings.Cut(strings.TrimSpace(dataURL), ",")
+	if !ok || !strings.HasPrefix(strings.ToLower(header), "data:") {
+		return StagedImage{}, apperrors.New(op, "not a data URL")
+	}
+	meta := strings.Split(strings.TrimPrefix(strings.ToLower(header), "data:"), ";")
+	if !strings.HasPrefix(meta[0], "image/") || meta[len(meta)-1] != "base64" {
+		return StagedImage{}, apperrors.Newf(op, "unsupported data URL header %q (want data:image/<type>;base64)", header)
+	}
+	payload = strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\n' || r == '\r' || r == '\t' {
+			return -1
+		}
+		return r
+	}, payload)
+	if maxBytes > 0 && int64(base64.StdEncoding.DecodedLen(len(payload))) > maxBytes+2 {
+		return StagedImage{}, apperrors.Newf(op, "image exceeds %d bytes", maxBytes)
+	}
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		if data, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(payload, "=")); err != nil {
+			return StagedImage{}, apperrors.Wrap(err, op, "decode base64")
+		}
+	}
+	if len(data) == 0 {
+		return StagedImage{}, apperrors.New(op, "empty image")
+	}
+	if maxBytes > 0 && int64(len(data)) > maxBytes {
+		return StagedImage{}, apperrors.Newf(op, "image exceeds %d bytes", maxBytes)
+	}
+	mimeType := http.DetectContentType(data)
+	ext, ok := stagedImageExts[mimeType]
+	if !ok {
+		return StagedImage{}, apperrors.Newf(op, "content is %s, not a supported image", mimeType)
+	}
+
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+	tmp, err := os.CreateTemp(f.stagingDir, ".staging-*")
+	if err != nil {
+		return StagedImage{}, apperrors.Wrap(err, op, "create temp")
+	}
+	tmpName := tmp.Name()
+	defer func() { _ = os.Remove(tmpName) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return StagedImage{}, apperrors.Wrap(err, op, "write temp")
+	}
+	if err := tmp.Close(); err != nil {
+		return StagedImage{}, apperrors.Wrap(err, op, "close temp")
+	}
+	path, err := f.commitStaged(tmpName, digest, ext)
+	if err != nil {
+		return StagedImage{}, err
+	}
+	return StagedImage{Path: path, MimeType: mimeType, Size: int64(len(data)), SHA256: digest}, nil
+}
+
+// commitStaged 把临时文件按 "<摘要前缀><ext>" 落位; 目标已存在时保留已有副本。
+func (f *FileIngestor) commitStaged(tmpName, digest, ext string) (string, error) {
+	target := filepath.Join(f.stagingDir, digest[:ingestStagedNameDigits]+strings.ToLower(ext))
+	if _, err := os.Stat(target); err == nil {
+		return target, nil
+	}
+	if err := os.Rename(tmpName, target); err != nil {
+		return "", apperrors.Wrap(err, "FileIngestor.commitStaged", "rename staged file")
+	}
+	return target, nil
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// pngHeader 最小 PNG 签名 + IHDR 头, 足以被 http.DetectContentType 识别。
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00")
+
+func TestFileIngestor_ClassifiesAndStages(t *testing.T) {
+	src := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(src, name)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	small := write("notes.md", []byte("# hello\n"))
+	large := write("big.log", bytes.Repeat([]byte("line\n"), 100))
+	image := write("shot.png", pngHeader)
+	binary := write("blob.bin", []byte{0x00, 0x01, 0x02, 0xff})
+	fakeImage := write("fake.png", []byte("not really an image"))
+
+	ingestor, err := NewFileIngestor(t.TempDir(), 64, 1<<20)
+	if err != nil {
+		t.Fatalf("NewFileIngestor: %v", err)
+	}
+	files := ingestor.Prepare(context.Background(), src, []string{"notes.md", large, image, binary, fakeImage, filepath.Join(src, "missing.txt"), src})
+	if len(files) != 7 {
+		t.Fatalf("len(files) = %d", len(files))
+	}
+
+	if f := files[0]; f.Kind != IngestKindText || !f.Inline || f.Content != "# hello\n" || f.Path != small || f.StagedPath != "" {
+		t.Fatalf("small text = %#v", f)
+	}
+	if f := files[1]; f.Kind != IngestKindText || f.Inline || !strings.HasPrefix(f.StagedPath, ingestor.StagingDir()) {
+		t.Fatalf("large text = %#v", f)
+	}
+	if f := files[2]; f.Kind != IngestKindImage || f.MimeType != "image/png" || filepath.Ext(f.StagedPath) != ".png" {
+		t.Fatalf("image = %#v", f)
+	}
+	if f := files[3]; f.Kind != IngestKindBinary || f.StagedPath == "" {
+		t.Fatalf("binary = %#v", f)
+	}
+	if f := files[4]; f.Kind != IngestKindText {
+		t.Fatalf("png extension with text content = %#v", f)
+	}
+	if f := files[5]; f.Error == "" {
+		t.Fatalf("missing file should carry error: %#v", f)
+	}
+	if f := files[6]; f.Kind != IngestKindDirectory || f.Error != "" {
+		t.Fatalf("directory = %#v", f)
+	}
+
+	staged, err := os.ReadFile(files[1].StagedPath)
+	if err != nil || !bytes.Equal(staged, bytes.Repeat([]byte("line\n"), 100)) {
+		t.Fatalf("staged copy mismatch: %v", err)
+	}
+
+	// 相同内容再次拖入复用同一暂存副本。
+	again := ingestor.Prepare(context.Background(), "", []string{large})
+	if again[0].StagedPath != files[1].StagedPath {
+		t.Fatalf("staged path changed: %q vs %q", again[0].StagedPath, files[1].StagedPath)
+	}
+	entries, _ := os.ReadDir(ingestor.StagingDir())
+	if len(entries) != 3 {
+		t.Fatalf("staging dir has %d entries, want 3", len(entries))
+	}
+}
+
+func TestFileIngestor_RejectsOversizedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "huge.txt")
+	if err := os.WriteFile(path, bytes.Repeat([]byte("x"), 2048), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ingestor, err := NewFileIngestor(t.TempDir(), 64, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := ingestor.Prepare(context.Background(), "", []string{path})
+	if !strings.Contains(files[0].Error, "exceeds limit") {
+		t.Fatalf("error = %q, want size limit", files[0].Error)
+	}
+}
+
+func TestFileIngestor_StageImageDataURL(t *testing.T) {
+	ingestor, err := NewFileIngestor(t.TempDir(), 64, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataURL := "data:image/png;base64," + base64.StdEncoding.EncodeToString(pngHeader)
+
+	first, err := ingestor.StageImageDataURL(dataURL, 1024)
+	if err != nil {
+		t.Fatalf("StageImageDataURL: %v", err)
+	}
+	if first.MimeType != "image/png" || filepath.Ext(first.Path) != ".png" || first.Size != int64(len(pngHeader)) {
+		t.Fatalf("staged = %#v", first)
+	}
+	if data, _ := os.ReadFile(first.Path); !bytes.Equal(data, pngHeader) {
+		t.Fatal("staged image content mismatch")
+	}
+	// 声明类型不同但内容相同: 以嗅探为准并复用同一文件。
+	second, err := ingestor.StageImageDataURL("data:image/jpeg;base64,"+base64.RawStdEncoding.EncodeToString(pngHeader), 1024)
+	if err != nil || second.Path != first.Path {
+		t.Fatalf("second = %#v, %v; want reuse of %s", second, err, first.Path)
+	}
+
+	for name, bad := range map[string]string{
+		"not data url": base64.StdEncoding.EncodeToString(pngHeader),
+		"not image":    "data:text/plain;base64," + base64.StdEncoding.EncodeToString([]byte("hi")),
+		"not base64":   "data:image/png,rawbytes",
+		"text content": "data:image/png;base64," + base64.StdEncoding.EncodeToString([]byte("hello world")),
+		"too large":    "data:image/png;base64," + base64.StdEncoding.EncodeToString(append(pngHeader, make([]byte, 2048)...)),
+	} {
+		if _, err := ingestor.StageImageDataURL(bad, 1024); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
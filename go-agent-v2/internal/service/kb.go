@@ -0,0 +1,341 @@
+// kb.go — 知识库索引: 连接器文档分块后写入向量记忆 (独立命名空间), 检索时附带来源引用。
+//
+// 读穿 (read-through): 检索前若某连接器距上次同步超过 refresh 间隔则先增量同步;
+// 同步按文档内容指纹 (docHash) 跳过未变化的文档, 删除源端已不存在的文档。
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/multi-agent/go-agent-v2/internal/store"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+// KBMemoryAgentID 知识库分块在 agent_memories 中使用的命名空间。
+const KBMemoryAgentID = "__kb__"
+
+// MemoryKindKBChunk 知识库分块记忆类型。
+const MemoryKindKBChunk = "kb_chunk"
+
+const (
+	kbChunkRunes       = 1200
+	maxKBChunksPerDoc  = 200
+	defaultKBSearchK   = 5
+	kbSearchOverfetch  = 4 // 按来源过滤时的超额拉取倍数
+	defaultKBRefreshIn = time.Hour
+)
+
+// KBRepository 知识库文档级维护 (由 store.AgentMemoryStore 实现)。
+type KBRepository interface {
+	DocHashes(ctx context.Context, agentID string) (map[string]string, error)
+	DeleteDoc(ctx context.Context, agentID, docID string) error
+}
+
+// KBSyncResult 单个连接器的同步结果。
+type KBSyncResult struct {
+	Source    string    `json:"source"`
+	Type      string    `json:"type"`
+	Documents int       `json:"documents"`
+	Indexed   int       `json:"indexed"`
+	Unchanged int       `json:"unchanged"`
+	Removed   int       `json:"removed"`
+	Chunks    int       `json:"chunks"`
+	SyncedAt  time.Time `json:"syncedAt"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// KBCitation 检索结果的来源引用。
+type KBCitation struct {
+	Source string `json:"source"`
+	Title  string `json:"title"`
+	URL    string `json:"url,omitempty"`
+	Path   string `json:"path,omitempty"`
+	Chunk  int    `json:"chunk"`
+}
+
+// KBSearchResult 检索结果。
+type KBSearchResult struct {
+	Content  string     `json:"content"`
+	Score    float64    `json:"score"`
+	Citation KBCitation `json:"citation"`
+}
+
+// KnowledgeBase 知识库索引与检索。
+type KnowledgeBase struct {
+	memory     *AgentMemoryService
+	repo       KBRepository
+	connectors []KBConnector
+	refresh    time.Duration
+
+	syncMu   sync.Mutex // 串行化同步
+	mu       sync.Mutex
+	lastSync map[string]KBSyncResult
+}
+
+// NewKnowledgeBase 创建知识库 (refresh ≤ 0 时使用默认 1 小时)。
+func NewKnowledgeBase(memory *AgentMemoryService, repo KBRepository, connectors []KBConnector, refresh time.Duration) *KnowledgeBase {
+	if refresh <= 0 {
+		refresh = defaultKBRefreshIn
+	}
+	return &KnowledgeBase{
+		memory:     memory,
+		repo:       repo,
+		connectors: connectors,
+		refresh:    refresh,
+		lastSync:   make(map[string]KBSyncResult),
+	}
+}
+
+// Sources 返回连接器名称列表。
+func (kb *KnowledgeBase) Sources() []string {
+	out := make([]string, 0, len(kb.connectors))
+	for _, c := range kb.connectors {
+		out = append(out, c.Name())
+	}
+	return out
+}
+
+// Status 返回各连接器最近一次同步结果 (未同步过的只含名称与类型)。
+func (kb *KnowledgeBase) Status() []KBSyncResult {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+	out := make([]KBSyncResult, 0, len(kb.connectors))
+	for _, c := range kb.connectors {
+		result, ok := kb.lastSync[c.Name()]
+		if !ok {
+			result = KBSyncResult{Source: c.Name(), Type: c.Type()}
+		}
+		out = append(out, result)
+	}
+	return out
+}
+
+// Sync 同步指定来源 (source 为空时同步全部); force=false 时跳过未到刷新间隔的来源。
+func (kb *KnowledgeBase) Sync(ctx context.Context, source string, force bool) ([]KBSyncResult, error) {
+	if !kb.memory.Enabled(ctx) {
+		return nil, apperrors.New("KnowledgeBase.Sync", "memory store unavailable")
+	}
+	kb.syncMu.Lock()
+	defer kb.syncMu.Unlock()
+
+	var results []KBSyncResult
+	matched := false
+	for _, connector := range kb.connectors {
+		if source != "" && connector.Name() != source {
+			continue
+		}
+		matched = true
+		if !force && !kb.stale(connector.Name()) {
+			continue
+		}
+		result := kb.syncConnector(ctx, connector)
+		kb.mu.Lock()
+		kb.lastSync[connector.Name()] = result
+		kb.mu.Unlock()
+		results = append(results, result)
+	}
+	if source != "" && !matched {
+		return nil, apperrors.Newf("KnowledgeBase.Sync", "unknown source %q", source)
+	}
+	return results, nil
+}
+
+func (kb *KnowledgeBase) stale(source string) bool {
+	kb.mu.Lock()
+	defer kb.mu.Unlock()
+	last, ok := kb.lastSync[source]
+	return !ok || time.Since(last.SyncedAt) >= kb.refresh
+}
+
+func (kb *KnowledgeBase) syncConnector(ctx context.Context, connector KBConnector) KBSyncResult {
+	result := KBSyncResult{Source: connector.Name(), Type: connector.Type(), SyncedAt: time.Now()}
+	docs, err := connector.Fetch(ctx)
+	if err != nil {
+		result.Error = err.Error()
+		logger.Warn("kb: fetch failed", logger.FieldSource, connector.Name(), logger.FieldError, err)
+		return result
+	}
+	existing, err := kb.repo.DocHashes(ctx, KBMemoryAgentID)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Documents = len(docs)
+	seen := make(map[string]struct{}, len(docs))
+	for _, doc := range docs {
+		docID := kbDocID(connector.Name(), doc.ID)
+		seen[docID] = struct{}{}
+		hash := kbDocHash(doc)
+		if existing[docID] == hash {
+			result.Unchanged++
+			continue
+		}
+		if err := kb.repo.DeleteDoc(ctx, KBMemoryAgentID, docID); err != nil {
+			result.Error = err.Error()
+			return result
+		}
+		chunks := ChunkKBDocument(doc.Content, kbChunkRunes)
+		for i, chunk := range chunks {
+			_, err := kb.memory.Remember(ctx, MemoryRecord{
+				AgentID: KBMemoryAgentID,
+				Kind:    MemoryKindKBChunk,
+				Content: doc.Title + "\n" + chunk,
+				Metadata: map[string]any{
+					"source":  connector.Name(),
+					"docId":   docID,
+					"docHash": hash,
+					"title":   doc.Title,
+					"url":     doc.URL,
+					"path":    doc.Path,
+					"chunk":   i,
+				},
+			})
+			if err != nil {
+				result.Error = err.Error()
+				return result
+			}
+		}
+		result.Indexed++
+		result.Chunks += len(chunks)
+	}
+	prefix := connector.Name() + ":"
+	for docID := range existing {
+		if _, ok := seen[docID]; ok || !strings.HasPrefix(docID, prefix) {
+			continue
+		}
+		if err := kb.repo.DeleteDoc(ctx, KBMemoryAgentID, docID); err != nil {
+			result.Error = err.Error()
+			return result
+		}
+		result.Removed++
+	}
+	logger.Info("kb: synced", logger.FieldSource, connector.Name(),
+		"documents", result.Documents, "indexed", result.Indexed, "removed", result.Removed, "chunks", result.Chunks)
+	return result
+}
+
+// Search 读穿检索: 先刷新过期来源 (失败时使用旧索引), 再按相似度返回带引用的分块。
+func (kb *KnowledgeBase) Search(ctx context.Context, query, source string, k int) ([]KBSearchResult, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, apperrors.New("KnowledgeBase.Search", "query is required")
+	}
+	if _, err := kb.Sync(ctx, source, false); err != nil {
+		return nil, err
+	}
+	if k <= 0 {
+		k = defaultKBSearchK
+	}
+	fetch := k
+	if source != "" {
+		fetch = k * kbSearchOverfetch
+	}
+	hits, err := kb.memory.Search(ctx, MemoryQuery{
+		AgentID: KBMemoryAgentID,
+		Query:   query,
+		TopK:    fetch,
+		Kinds:   []string{MemoryKindKBChunk},
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]KBSearchResult, 0, k)
+	for _, hit := range hits {
+		citation := kbCitationFromHit(hit)
+		if source != "" && citation.Source != source {
+			continue
+		}
+		out = append(out, KBSearchResult{Content: hit.Content, Score: hit.Score, Citation: citation})
+		if len(out) >= k {
+			break
+		}
+	}
+	return out, nil
+}
+
+func kbCitationFromHit(hit store.AgentMemoryHit) KBCitation {
+	str := func(key string) string {
+		value, _ := hit.Metadata[key].(string)
+		return value
+	}
+	chunk := 0
+	switch v := hit.Metadata["chunk"].(type) {
+	case float64:
+		chunk = int(v)
+	case int:
+		chunk = v
+	}
+	return KBCitation{Source: str("source"), Title: str("title"), URL: str("url"), Path: str("path"), Chunk: chunk}
+}
+
+func kbDocID(source, id string) string { return source + ":" + id }
+
+func kbDocHash(doc KBDocument) string {
+	sum := sha256.Sum256([]byte(doc.Title + "\x00" + doc.URL + "\x00" + doc.Content))
+	return hex.EncodeToString(sum[:8])
+}
+
+// ChunkKBDocument 按段落 (空行 / 标题) 聚合为不超过 limit 个字符的分块; 超长段落硬切。
+func ChunkKBDocument(content string, limit int) []string {
+	var chunks []string
+	var current strings.Builder
+	flush := func() {
+		if text := strings.TrimSpace(current.String()); text != "" {
+			chunks = append(chunks, text)
+		}
+		current.Reset()
+	}
+	for _, para := range splitKBParagraphs(content) {
+		for utf8.RuneCountInString(para) > limit {
+			flush()
+			runes := []rune(para)
+			chunks = append(chunks, string(runes[:limit]))
+			para = string(runes[limit:])
+		}
+		if current.Len() > 0 && utf8.RuneCountInString(current.String())+utf8.RuneCountInString(para) > limit {
+			flush()
+		}
+		if current.Len() > 0 {
+			current.WriteString("\n\n")
+		}
+		current.WriteString(para)
+		if len(chunks) >= maxKBChunksPerDoc {
+			break
+		}
+	}
+	flush()
+	if len(chunks) > maxKBChunksPerDoc {
+		chunks = chunks[:maxKBChunksPerDoc]
+	}
+	return chunks
+}
+
+func splitKBParagraphs(content string) []string {
+	var paras []string
+	var current []string
+	flush := func() {
+		if text := strings.TrimSpace(strings.Join(current, "\n")); text != "" {
+			paras = append(paras, text)
+		}
+		current = current[:0]
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			flush()
+			continue
+		}
+		if strings.HasPrefix(trimmed, "#") {
+			flush()
+		}
+		current = append(current, line)
+	}
+	flush()
+	return paras
+}
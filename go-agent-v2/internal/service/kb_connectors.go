@@ -0,0 +1,466 @@
+// kb_connectors.go — 外部知识库连接器: 本地 markdown 目录 / Confluence / Notion。
+//
+// 连接器只负责拉取文档全文 (KBDocument), 分块与向量化由 KnowledgeBase 统一处理。
+// 配置来自 KB_CONNECTORS (JSON 数组), 例如:
+//
+//	[{"name":"handbook","type":"markdown","root":"./docs"},
+//	 {"name":"wiki","type":"confluence","baseUrl":"https://acme.atlassian.net/wiki","space":"ENG","email":"bot@acme.com","tokenEnv":"CONFLUENCE_TOKEN"},
+//	 {"name":"notes","type":"notion","tokenEnv":"NOTION_TOKEN"}]
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"html"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+const (
+	maxKBDocuments     = 2000
+	maxKBDocumentBytes = 2 << 20
+	kbHTTPTimeout      = 30 * time.Second
+	notionAPIVersion   = "2022-06-28"
+	defaultNotionAPI   = "https://api.notion.com/v1"
+)
+
+var kbConnectorNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// KBDocument 连接器拉取的单篇文档。
+type KBDocument struct {
+	ID        string
+	Title     string
+	URL       string
+	Path      string
+	Content   string
+	UpdatedAt time.Time
+}
+
+// KBConnector 知识库数据源。
+type KBConnector interface {
+	Name() string
+	Type() string
+	Fetch(ctx context.Context) ([]KBDocument, error)
+}
+
+// KBConnectorConfig 单个连接器配置。
+type KBConnectorConfig struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"` // markdown | confluence | notion
+	Root     string `json:"root,omitempty"`
+	BaseURL  string `json:"baseUrl,omitempty"`
+	Space    string `json:"space,omitempty"`
+	Email    string `json:"email,omitempty"`
+	Token    string `json:"token,omitempty"`
+	TokenEnv string `json:"tokenEnv,omitempty"` // 优先从环境变量读取 token, 避免明文写入配置
+}
+
+// ParseKBConnectors 解析 KB_CONNECTORS JSON 配置 (空串返回 nil)。
+func ParseKBConnectors(raw string) ([]KBConnector, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	var configs []KBConnectorConfig
+	if err := json.Unmarshal([]byte(raw), &configs); err != nil {
+		return nil, apperrors.Wrap(err, "ParseKBConnectors", "decode KB_CONNECTORS")
+	}
+	seen := make(map[string]struct{}, len(configs))
+	out := make([]KBConnector, 0, len(configs))
+	for i, cfg := range configs {
+		name := strings.TrimSpace(cfg.Name)
+		if !kbConnectorNameRe.MatchString(name) {
+			return nil, apperrors.Newf("ParseKBConnectors", "connectors[%d]: invalid name %q", i, cfg.Name)
+		}
+		if _, dup := seen[name]; dup {
+			return nil, apperrors.Newf("ParseKBConnectors", "duplicate connector name %q", name)
+		}
+		seen[name] = struct{}{}
+		token := strings.TrimSpace(cfg.Token)
+		if env := strings.TrimSpace(cfg.TokenEnv); env != "" {
+			token = strings.TrimSpace(os.Getenv(env))
+		}
+		client := &http.Client{Timeout: kbHTTPTimeout}
+		switch strings.ToLower(strings.TrimSpace(cfg.Type)) {
+		case "markdown", "md":
+			if strings.TrimSpace(cfg.Root) == "" {
+				return nil, apperrors.Newf("ParseKBConnectors", "connector %q: root is required", name)
+			}
+			out = append(out, &MarkdownConnector{ConnectorName: name, Root: cfg.Root})
+		case "confluence":
+			if strings.TrimSpace(cfg.BaseURL) == "" || strings.TrimSpace(cfg.Space) == "" {
+				return nil, apperrors.Newf("ParseKBConnectors", "connector %q: baseUrl and space are required", name)
+			}
+			out = append(out, &ConfluenceConnector{ConnectorName: name, BaseURL: cfg.BaseURL, Space: cfg.Space, Email: cfg.Email, Token: token, Client: client})
+		case "notion":
+			if token == "" {
+				return nil, apperrors.Newf("ParseKBConnectors", "connector %q: token is required", name)
+			}
+			out = append(out, &NotionConnector{ConnectorName: name, BaseURL: cfg.BaseURL, Token: token, Client: client})
+		default:
+			return nil, apperrors.Newf("ParseKBConnectors", "connector %q: unsupported type %q", name, cfg.Type)
+		}
+	}
+	return out, nil
+}
+
+// ========================================
+// MarkdownConnector — 本地 markdown 目录 (Obsidian vault 等)
+// ========================================
+
+// MarkdownConnector 递归读取目录下的 .md / .markdown 文件。
+type MarkdownConnector struct {
+	ConnectorName string
+	Root          string
+}
+
+// Name 连接器名称。
+func (c *MarkdownConnector) Name() string { return c.ConnectorName }
+
+// Type 连接器类型。
+func (c *MarkdownConnector) Type() string { return "markdown" }
+
+// Fetch 读取全部 markdown 文档 (跳过隐藏目录与超大文件)。
+func (c *MarkdownConnector) Fetch(ctx context.Context) ([]KBDocument, error) {
+	root, err := filepath.Abs(c.Root)
+	if err != nil {
+		return nil, apperrors.Wrap(err, "MarkdownConnector.Fetch", "resolve root")
+	}
+	var docs []KBDocument
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if d.IsDir() {
+			if path != root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		ext := strings.ToLower(filepath.Ext(d.Name()))
+		if ext != ".md" && ext != ".markdown" {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || info.Size() > maxKBDocumentBytes {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, path)
+		rel = filepath.ToSlash(rel)
+		content := string(data)
+		docs = append(docs, KBDocument{
+			ID:        rel,
+			Title:     markdownTitle(content, strings.TrimSuffix(filepath.Base(rel), filepath.Ext(rel))),
+			Path:      path,
+			Content:   content,
+			UpdatedAt: info.ModTime(),
+		})
+		if len(docs) >= maxKBDocuments {
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, apperrors.Wrap(err, "MarkdownConnector.Fetch", "walk root")
+	}
+	return docs, nil
+}
+
+// markdownTitle 取首个一级标题, 否则使用 fallback (文件名)。
+func markdownTitle(content, fallback string) string {
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "# ") {
+			return strings.TrimSpace(strings.TrimPrefix(trimmed, "# "))
+		}
+	}
+	return fallback
+}
+
+// ========================================
+// ConfluenceConnector — Confluence REST API (按空间拉取页面)
+// ========================================
+
+// ConfluenceConnector 拉取指定空间的全部页面 (storage 格式转纯文本)。
+type ConfluenceConnector struct {
+	ConnectorName string
+	BaseURL       string // 如 https://acme.atlassian.net/wiki
+	Space         string
+	Email         string // 设置时使用 Basic (Cloud API token), 否则 Bearer (Server PAT)
+	Token         string
+	Client        *http.Client
+}
+
+// Name 连接器名称。
+func (c *ConfluenceConnector) Name() string { return c.ConnectorName }
+
+// Type 连接器类型。
+func (c *ConfluenceConnector) Type() string { return "confluence" }
+
+// Fetch 分页拉取空间页面。
+func (c *ConfluenceConnector) Fetch(ctx context.Context) ([]KBDocument, error) {
+	base := strings.TrimRight(strings.TrimSpace(c.BaseURL), "/")
+	var docs []KBDocument
+	for start := 0; len(docs) < maxKBDocuments; {
+		query := url.Values{
+			"spaceKey": {c.Space},
+			"type":     {"page"},
+			"expand":   {"body.storage,version"},
+			"limit":    {"50"},
+			"start":    {strconv.Itoa(start)},
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/rest/api/content?"+query.Encode(), nil)
+		if err != nil {
+			return nil, apperrors.Wrap(err, "ConfluenceConnector.Fetch", "build request")
+		}
+		req.Header.Set("Accept", "application/json")
+		if c.Email != "" {
+			req.SetBasicAuth(c.Email, c.Token)
+		} else if c.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.Token)
+		}
+		var page struct {
+			Results []struct {
+				ID    string `json:"id"`
+				Title string `json:"title"`
+				Body  struct {
+					Storage struct {
+						Value string `json:"value"`
+					} `json:"storage"`
+				} `json:"body"`
+				Version struct {
+					When time.Time `json:"when"`
+				} `json:"version"`
+				Links struct {
+					WebUI string `json:"webui"`
+				} `json:"_links"`
+			} `json:"results"`
+			Size int `json:"size"`
+		}
+		if err := doKBJSON(c.Client, req, &page); err != nil {
+			return nil, apperrors.Wrap(err, "ConfluenceConnector.Fetch", "list pages")
+		}
+		for _, item := range page.Results {
+			docs = append(docs, KBDocument{
+				ID:        item.ID,
+				Title:     item.Title,
+				URL:       base + item.Links.WebUI,
+				Content:   htmlToText(item.Body.Storage.Value),
+				UpdatedAt: item.Version.When,
+			})
+		}
+		if len(page.Results) < 50 {
+			break
+		}
+		start += len(page.Results)
+	}
+	return docs, nil
+}
+
+var (
+	htmlBlockTagRe = regexp.MustCompile(`(?i)</?(p|div|br|h[1-6]|li|tr|table|ul|ol|pre|blockquote)[^>]*>`)
+	htmlTagRe      = regexp.MustCompile(`<[^>]+>`)
+	blankLinesRe   = regexp.MustCompile(`\n{3,}`)
+)
+
+// htmlToText 粗粒度 HTML → 纯文本 (块级标签换行, 其余标签移除)。
+func htmlToText(value string) string {
+	text := htmlBlockTagRe.ReplaceAllString(value, "\n")
+	text = htmlTagRe.ReplaceAllString(text, "")
+	text = html.UnescapeString(text)
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.TrimSpace(blankLinesRe.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
+
+// ========================================
+// NotionConnector — Notion API (集成可见的全部页面)
+// ========================================
+
+// NotionConnector 通过 search 枚举页面, 再读取顶层块文本。
+type NotionConnector struct {
+	ConnectorName string
+	BaseURL       string // 默认 https://api.notion.com/v1
+	Token         string
+	Client        *http.Client
+}
+
+// Name 连接器名称。
+func (c *NotionConnector) Name() string { return c.ConnectorName }
+
+// Type 连接器类型。
+func (c *NotionConnector) Type() string { return "notion" }
+
+type notionRichText []struct {
+	PlainText string `json:"plain_text"`
+}
+
+func (r notionRichText) text() string {
+	var b strings.Builder
+	for _, part := range r {
+		b.WriteString(part.PlainText)
+	}
+	return b.String()
+}
+
+// Fetch 分页枚举页面并读取正文。
+func (c *NotionConnector) Fetch(ctx context.Context) ([]KBDocument, error) {
+	base := strings.TrimRight(strings.TrimSpace(c.BaseURL), "/")
+	if base == "" {
+		base = defaultNotionAPI
+	}
+	var docs []KBDocument
+	cursor := ""
+	for len(docs) < maxKBDocuments {
+		body := map[string]any{"filter": map[string]any{"property": "object", "value": "page"}, "page_size": 100}
+		if cursor != "" {
+			body["start_cursor"] = cursor
+		}
+		var page struct {
+			Results []struct {
+				ID             string                     `json:"id"`
+				URL            string                     `json:"url"`
+				LastEditedTime time.Time                  `json:"last_edited_time"`
+				Properties     map[string]json.RawMessage `json:"properties"`
+			} `json:"results"`
+			HasMore    bool   `json:"has_more"`
+			NextCursor string `json:"next_cursor"`
+		}
+		if err := c.call(ctx, http.MethodPost, base+"/search", body, &page); err != nil {
+			return nil, apperrors.Wrap(err, "NotionConnector.Fetch", "search pages")
+		}
+		for _, item := range page.Results {
+			content, err := c.pageText(ctx, base, item.ID)
+			if err != nil {
+				return nil, apperrors.Wrapf(err, "NotionConnector.Fetch", "read page %s", item.ID)
+			}
+			docs = append(docs, KBDocument{
+				ID:        item.ID,
+				Title:     notionPageTitle(item.Properties),
+				URL:       item.URL,
+				Content:   content,
+				UpdatedAt: item.LastEditedTime,
+			})
+		}
+		if !page.HasMore || page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	return docs, nil
+}
+
+// pageText 读取页面顶层块中的文本 (段落 / 标题 / 列表 / 代码 / 引用 / 待办)。
+func (c *NotionConnector) pageText(ctx context.Context, base, pageID string) (string, error) {
+	var lines []string
+	cursor := ""
+	for {
+		endpoint := base + "/blocks/" + url.PathEscape(pageID) + "/children?page_size=100"
+		if cursor != "" {
+			endpoint += "&start_cursor=" + url.QueryEscape(cursor)
+		}
+		var page struct {
+			Results    []map[string]json.RawMessage `json:"results"`
+			HasMore    bool                         `json:"has_more"`
+			NextCursor string                       `json:"next_cursor"`
+		}
+		if err := c.call(ctx, http.MethodGet, endpoint, nil, &page); err != nil {
+			return "", err
+		}
+		for _, block := range page.Results {
+			var blockType string
+			_ = json.Unmarshal(block["type"], &blockType)
+			var payload struct {
+				RichText notionRichText `json:"rich_text"`
+			}
+			if raw, ok := block[blockType]; ok && json.Unmarshal(raw, &payload) == nil {
+				if text := strings.TrimSpace(payload.RichText.text()); text != "" {
+					if strings.HasPrefix(blockType, "heading_") {
+						level, _ := strconv.Atoi(strings.TrimPrefix(blockType, "heading_"))
+						text = strings.Repeat("#", max(level, 1)) + " " + text
+					}
+					lines = append(lines, text)
+				}
+			}
+		}
+		if !page.HasMore || page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	return strings.Join(lines, "\n\n"), nil
+}
+
+func (c *NotionConnector) call(ctx context.Context, method, endpoint string, body any, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = strings.NewReader(string(data))
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	req.Header.Set("Notion-Version", notionAPIVersion)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return doKBJSON(c.Client, req, out)
+}
+
+// notionPageTitle 从 properties 中找到 title 类型属性。
+func notionPageTitle(properties map[string]json.RawMessage) string {
+	for _, raw := range properties {
+		var prop struct {
+			Type  string         `json:"type"`
+			Title notionRichText `json:"title"`
+		}
+		if json.Unmarshal(raw, &prop) == nil && prop.Type == "title" {
+			if title := strings.TrimSpace(prop.Title.text()); title != "" {
+				return title
+			}
+		}
+	}
+	return "Untitled"
+}
+
+func doKBJSON(client *http.Client, req *http.Request, out any) error {
+	if client == nil {
+		client = &http.Client{Timeout: kbHTTPTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 32<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return apperrors.Newf("doKBJSON", "%s %s: status %d: %s", req.Method, req.URL.Path, resp.StatusCode, truncateRunes(string(data), 200))
+	}
+	return json.Unmarshal(data, out)
+}
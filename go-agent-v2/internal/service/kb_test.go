@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKnowledgeBaseSyncAndSearchWithCitations(t *testing.T) {
+	root := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("deploy.md", "# Deploy Guide\n\nRun ops/deploy.sh with the production kubeconfig to release.")
+	write("team/oncall.md", "# Oncall\n\nPage the oncall engineer through the incident channel.")
+	write(".obsidian/cache.md", "# Hidden\n\nshould be skipped")
+
+	repo := &fakeMemoryRepo{available: true}
+	connectors, err := ParseKBConnectors(`[{"name":"handbook","type":"markdown","root":` + jsonString(root) + `}]`)
+	if err != nil {
+		t.Fatalf("ParseKBConnectors: %v", err)
+	}
+	kb := NewKnowledgeBase(NewAgentMemoryService(repo, nil), repo, connectors, time.Hour)
+
+	results, err := kb.Search(context.Background(), "how do I deploy to production", "", 1)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 1 || results[0].Citation.Title != "Deploy Guide" || results[0].Citation.Source != "handbook" ||
+		!strings.HasSuffix(results[0].Citation.Path, "deploy.md") {
+		t.Fatalf("results = %+v", results)
+	}
+	status := kb.Status()
+	if len(status) != 1 || status[0].Documents != 2 || status[0].Indexed != 2 {
+		t.Fatalf("status = %+v", status)
+	}
+
+	// 未变化文档跳过, 删除的文档从索引移除
+	if err := os.Remove(filepath.Join(root, "team", "oncall.md")); err != nil {
+		t.Fatal(err)
+	}
+	synced, err := kb.Sync(context.Background(), "handbook", true)
+	if err != nil || len(synced) != 1 {
+		t.Fatalf("Sync = (%+v, %v)", synced, err)
+	}
+	if synced[0].Unchanged != 1 || synced[0].Removed != 1 || synced[0].Indexed != 0 {
+		t.Fatalf("resync = %+v", synced[0])
+	}
+	if _, err := kb.Sync(context.Background(), "missing", true); err == nil {
+		t.Fatal("expected unknown source error")
+	}
+}
+
+func TestChunkKBDocument(t *testing.T) {
+	content := "# A\n\n" + strings.Repeat("alpha ", 30) + "\n\n# B\n\n" + strings.Repeat("x", 250)
+	chunks := ChunkKBDocument(content, 100)
+	if len(chunks) < 4 {
+		t.Fatalf("chunks = %d, want long paragraphs split", len(chunks))
+	}
+	for _, chunk := range chunks {
+		if len([]rune(chunk)) > 100 {
+			t.Fatalf("chunk exceeds limit: %d", len([]rune(chunk)))
+		}
+	}
+}
+
+func TestParseKBConnectorsValidation(t *testing.T) {
+	cases := []string{
+		`[{"name":"a","type":"markdown"}]`,
+		`[{"name":"a","type":"confluence","baseUrl":"https://x"}]`,
+		`[{"name":"a","type":"notion"}]`,
+		`[{"name":"a","type":"gdrive"}]`,
+		`[{"name":"a","type":"markdown","root":"."},{"name":"a","type":"markdown","root":"."}]`,
+	}
+	for _, raw := range cases {
+		if _, err := ParseKBConnectors(raw); err == nil {
+			t.Errorf("ParseKBConnectors(%s): expected error", raw)
+		}
+	}
+	t.Setenv("TEST_NOTION_TOKEN", "secret")
+	connectors, err := ParseKBConnectors(`[{"name":"notes","type":"notion","tokenEnv":"TEST_NOTION_TOKEN"}]`)
+	if err != nil || connectors[0].(*NotionConnector).Token != "secret" {
+		t.Fatalf("tokenEnv not resolved: %v", err)
+	}
+}
+
+func TestConfluenceConnectorFetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "bot@acme.com" || pass != "token" || r.URL.Query().Get("spaceKey") != "ENG" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"results":[{"id":"42","title":"Runbook","body":{"storage":{"value":"<h1>Runbook</h1><p>Restart &amp; verify</p>"}},"_links":{"webui":"/spaces/ENG/pages/42"}}]}`))
+	}))
+	defer srv.Close()
+
+	docs, err := (&ConfluenceConnector{ConnectorName: "wiki", BaseURL: srv.URL, Space: "ENG", Email: "bot@acme.com", Token: "token"}).Fetch(context.Background())
+	if err != nil || len(docs) != 1 {
+		t.Fatalf("Fetch = (%+v, %v)", docs, err)
+	}
+	if docs[0].Content != "Runbook\n\nRestart & verify" || docs[0].URL != srv.URL+"/spaces/ENG/pages/42" {
+		t.Fatalf("doc = %+v", docs[0])
+	}
+}
+
+func TestNotionConnectorFetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Notion-Version") == "" || r.Header.Get("Authorization") != "Bearer tok" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.URL.Path == "/search":
+			_, _ = w.Write([]byte(`{"results":[{"id":"p1","url":"https://notion.so/p1","properties":{"Name":{"type":"title","title":[{"plain_text":"Design Doc"}]}}}],"has_more":false}`))
+		case r.URL.Path == "/blocks/p1/children":
+			_, _ = w.Write([]byte(`{"results":[{"type":"heading_2","heading_2":{"rich_text":[{"plain_text":"Goals"}]}},{"type":"paragraph","paragraph":{"rich_text":[{"plain_text":"Ship v2"}]}}],"has_more":false}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	docs, err := (&NotionConnector{ConnectorName: "notes", BaseURL: srv.URL, Token: "tok"}).Fetch(context.Background())
+	if err != nil || len(docs) != 1 {
+		t.Fatalf("Fetch = (%+v, %v)", docs, err)
+	}
+	if docs[0].Title != "Design Doc" || docs[0].Content != "## Goals\n\nShip v2" {
+		t.Fatalf("doc = %+v", docs[0])
+	}
+}
+
+func jsonString(value string) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
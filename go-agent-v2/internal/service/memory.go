@@ -0,0 +1,372 @@
+// memory.go — agent 长期记忆: 文本向量化 + 相似度检索 (pgvector 存储)。
+//
+// 写入: 完成的 turn 摘要 / 动态工具输出 / 手动注入的笔记, 按 agent 隔离。
+// 检索: turn 提交前按 prompt 检索 top-k 相关记忆, 渲染为上下文块前置到 prompt。
+//
+// 向量化默认使用本地特征哈希 (无外部依赖, 确定性); 配置 embedding 模型后改用
+// OpenAI 兼容 /embeddings 接口。两者输出维度固定为 MemoryEmbeddingDim。
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/multi-agent/go-agent-v2/internal/store"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+// MemoryEmbeddingDim 向量维度 (与 migrations/0017_agent_memories.sql 中 vector(256) 一致)。
+const MemoryEmbeddingDim = 256
+
+// 记忆类型。
+const (
+	MemoryKindTurnSummary = "turn_summary"
+	MemoryKindToolOutput  = "tool_output"
+	MemoryKindNote        = "note"
+)
+
+const (
+	defaultMemoryTopK         = 3
+	maxMemoryTopK             = 20
+	maxMemoryContentRunes     = 4000
+	maxMemoryContextRunes     = 600 // 注入 prompt 时单条记忆的截断长度
+	memoryEmbedRequestTimeout = 10 * time.Second
+	memoryProbeInterval       = 5 * time.Minute
+)
+
+// Embedder 文本向量化接口。
+type Embedder interface {
+	Name() string
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// ========================================
+// HashEmbedder — 本地特征哈希
+// ========================================
+
+// HashEmbedder 基于词 (拉丁文) 与双字 (CJK) 的有符号特征哈希, L2 归一化。
+type HashEmbedder struct{}
+
+// Name 返回 embedder 名称。
+func (HashEmbedder) Name() string { return "hash" }
+
+// Embed 批量向量化。
+func (HashEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		out[i] = hashEmbed(text)
+	}
+	return out, nil
+}
+
+func hashEmbed(text string) []float32 {
+	vec := make([]float32, MemoryEmbeddingDim)
+	for _, token := range memoryTokens(text) {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(token))
+		sum := h.Sum32()
+		sign := float32(1)
+		if sum&(1<<31) != 0 {
+			sign = -1
+		}
+		vec[sum%MemoryEmbeddingDim] += sign
+	}
+	normalizeVector(vec)
+	return vec
+}
+
+// memoryTokens 拉丁文按词切分 (小写, ≥2 字符), CJK 按相邻双字切分。
+func memoryTokens(text string) []string {
+	var tokens []string
+	var word strings.Builder
+	var prevCJK rune
+	flush := func() {
+		if utf8.RuneCountInString(word.String()) >= 2 {
+			tokens = append(tokens, word.String())
+		}
+		word.Reset()
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r):
+			flush()
+			if prevCJK != 0 {
+				tokens = append(tokens, string([]rune{prevCJK, r}))
+			} else {
+				tokens = append(tokens, string(r))
+			}
+			prevCJK = r
+			continue
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			word.WriteRune(r)
+		default:
+			flush()
+		}
+		prevCJK = 0
+	}
+	flush()
+	return tokens
+}
+
+func normalizeVector(vec []float32) {
+	var norm float64
+	for _, v := range vec {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return
+	}
+	scale := float32(1 / math.Sqrt(norm))
+	for i := range vec {
+		vec[i] *= scale
+	}
+}
+
+// ========================================
+// OpenAIEmbedder — OpenAI 兼容 /embeddings
+// ========================================
+
+// OpenAIEmbedder 调用 OpenAI 兼容 embeddings 接口 (请求 dimensions=MemoryEmbeddingDim)。
+type OpenAIEmbedder struct {
+	BaseURL string
+	APIKey  string
+	Model   string
+	Client  *http.Client
+}
+
+// Name 返回 embedder 名称。
+func (e *OpenAIEmbedder) Name() string { return "openai:" + e.Model }
+
+// Embed 批量向量化。
+func (e *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	body, err := json.Marshal(map[string]any{
+		"model":      e.Model,
+		"input":      texts,
+		"dimensions": MemoryEmbeddingDim,
+	})
+	if err != nil {
+		return nil, apperrors.Wrap(err, "OpenAIEmbedder.Embed", "marshal request")
+	}
+	baseURL := strings.TrimRight(strings.TrimSpace(e.BaseURL), "/")
+	if baseURL == "" {
+		baseURL = "https://api.openai.com/v1"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+"/embeddings", bytes.NewReader(body))
+	if err != nil {
+		return nil, apperrors.Wrap(err, "OpenAIEmbedder.Embed", "build request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.APIKey)
+	}
+	client := e.Client
+	if client == nil {
+		client = &http.Client{Timeout: memoryEmbedRequestTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, apperrors.Wrap(err, "OpenAIEmbedder.Embed", "request")
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 32<<20))
+	if err != nil {
+		return nil, apperrors.Wrap(err, "OpenAIEmbedder.Embed", "read response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, apperrors.Newf("OpenAIEmbedder.Embed", "status %d: %s", resp.StatusCode, truncateRunes(string(data), 200))
+	}
+	var decoded struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, apperrors.Wrap(err, "OpenAIEmbedder.Embed", "decode response")
+	}
+	out := make([][]float32, len(texts))
+	for _, item := range decoded.Data {
+		if item.Index < 0 || item.Index >= len(out) {
+			continue
+		}
+		if len(item.Embedding) != MemoryEmbeddingDim {
+			return nil, apperrors.Newf("OpenAIEmbedder.Embed", "embedding dim %d, want %d", len(item.Embedding), MemoryEmbeddingDim)
+		}
+		out[item.Index] = item.Embedding
+	}
+	for i, vec := range out {
+		if vec == nil {
+			return nil, apperrors.Newf("OpenAIEmbedder.Embed", "missing embedding for input %d", i)
+		}
+	}
+	return out, nil
+}
+
+// ========================================
+// AgentMemoryService
+// ========================================
+
+// MemoryRepository 记忆持久化 (由 store.AgentMemoryStore 实现)。
+type MemoryRepository interface {
+	Available(ctx context.Context) (bool, error)
+	Insert(ctx context.Context, m *store.AgentMemory) error
+	Search(ctx context.Context, q store.AgentMemoryQuery) ([]store.AgentMemoryHit, error)
+}
+
+// AgentMemoryService 记忆写入与检索。
+type AgentMemoryService struct {
+	repo     MemoryRepository
+	embedder Embedder
+
+	availMu     sync.Mutex
+	available   bool
+	availProbed time.Time
+}
+
+// NewAgentMemoryService 创建记忆服务 (embedder 为 nil 时使用 HashEmbedder)。
+func NewAgentMemoryService(repo MemoryRepository, embedder Embedder) *AgentMemoryService {
+	if embedder == nil {
+		embedder = HashEmbedder{}
+	}
+	return &AgentMemoryService{repo: repo, embedder: embedder}
+}
+
+// Enabled 存储表可用时返回 true (缺少 pgvector 时关闭, 每 memoryProbeInterval 重新探测)。
+func (m *AgentMemoryService) Enabled(ctx context.Context) bool {
+	if m == nil || m.repo == nil {
+		return false
+	}
+	m.availMu.Lock()
+	defer m.availMu.Unlock()
+	if m.available || (!m.availProbed.IsZero() && time.Since(m.availProbed) < memoryProbeInterval) {
+		return m.available
+	}
+	m.availProbed = time.Now()
+	ok, err := m.repo.Available(ctx)
+	if err != nil || !ok {
+		logger.Warn("memory: agent_memories unavailable (pgvector missing?), memory disabled", logger.FieldError, err)
+		return false
+	}
+	m.available = true
+	logger.Info("memory: enabled", "embedder", m.embedder.Name())
+	return true
+}
+
+// MemoryRecord 待写入的记忆。
+type MemoryRecord struct {
+	AgentID  string
+	ThreadID string
+	TurnID   string
+	Kind     string
+	Content  string
+	Metadata map[string]any
+}
+
+// Remember 向量化并写入一条记忆, 返回记录 ID。
+func (m *AgentMemoryService) Remember(ctx context.Context, rec MemoryRecord) (int64, error) {
+	agentID := strings.TrimSpace(rec.AgentID)
+	content := strings.TrimSpace(rec.Content)
+	if agentID == "" || content == "" {
+		return 0, apperrors.New("AgentMemoryService.Remember", "agentId and content are required")
+	}
+	if !m.Enabled(ctx) {
+		return 0, apperrors.New("AgentMemoryService.Remember", "memory store unavailable")
+	}
+	content = truncateRunes(content, maxMemoryContentRunes)
+	vectors, err := m.embedder.Embed(ctx, []string{content})
+	if err != nil {
+		return 0, apperrors.Wrap(err, "AgentMemoryService.Remember", "embed content")
+	}
+	kind := strings.TrimSpace(rec.Kind)
+	if kind == "" {
+		kind = MemoryKindNote
+	}
+	row := &store.AgentMemory{
+		AgentID:   agentID,
+		ThreadID:  strings.TrimSpace(rec.ThreadID),
+		TurnID:    strings.TrimSpace(rec.TurnID),
+		Kind:      kind,
+		Content:   content,
+		Metadata:  rec.Metadata,
+		Embedding: vectors[0],
+	}
+	if err := m.repo.Insert(ctx, row); err != nil {
+		return 0, apperrors.Wrap(err, "AgentMemoryService.Remember", "insert memory")
+	}
+	return row.ID, nil
+}
+
+// MemoryQuery 检索参数。
+type MemoryQuery struct {
+	AgentID  string
+	Query    string
+	TopK     int
+	Kinds    []string
+	MinScore float64
+}
+
+// Search 按语义相似度检索 agent 的记忆 (score 为余弦相似度, 降序)。
+func (m *AgentMemoryService) Search(ctx context.Context, q MemoryQuery) ([]store.AgentMemoryHit, error) {
+	agentID := strings.TrimSpace(q.AgentID)
+	query := strings.TrimSpace(q.Query)
+	if agentID == "" || query == "" {
+		return nil, apperrors.New("AgentMemoryService.Search", "agentId and query are required")
+	}
+	if !m.Enabled(ctx) {
+		return nil, nil
+	}
+	topK := q.TopK
+	if topK <= 0 {
+		topK = defaultMemoryTopK
+	}
+	if topK > maxMemoryTopK {
+		topK = maxMemoryTopK
+	}
+	vectors, err := m.embedder.Embed(ctx, []string{truncateRunes(query, maxMemoryContentRunes)})
+	if err != nil {
+		return nil, apperrors.Wrap(err, "AgentMemoryService.Search", "embed query")
+	}
+	hits, err := m.repo.Search(ctx, store.AgentMemoryQuery{
+		AgentID:   agentID,
+		Embedding: vectors[0],
+		Limit:     topK,
+		Kinds:     q.Kinds,
+	})
+	if err != nil {
+		return nil, apperrors.Wrap(err, "AgentMemoryService.Search", "search memories")
+	}
+	out := hits[:0]
+	for _, hit := range hits {
+		if hit.Score >= q.MinScore {
+			out = append(out, hit)
+		}
+	}
+	return out, nil
+}
+
+// RenderMemoryContext 将检索结果渲染为 prompt 前置上下文块 (无结果时返回空串)。
+func RenderMemoryContext(hits []store.AgentMemoryHit) string {
+	if len(hits) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("[相关历史记忆] 以下为与本轮任务相关的过往记录, 仅供参考, 若与当前事实冲突以当前为准:\n")
+	for i, hit := range hits {
+		fmt.Fprintf(&b, "%d. (%s, %s) %s\n", i+1, hit.Kind,
+			time.Unix(hit.CreatedAt, 0).Format("2006-01-02"),
+			strings.ReplaceAll(truncateRunes(hit.Content, maxMemoryContextRunes), "\n", " "))
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
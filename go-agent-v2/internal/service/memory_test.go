@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/multi-agent/go-agent-v2/internal/store"
+)
+
+// fakeMemoryRepo 内存实现, 按余弦相似度排序。
+type fakeMemoryRepo struct {
+	available bool
+	rows      []store.AgentMemory
+}
+
+func (r *fakeMemoryRepo) Available(context.Context) (bool, error) { return r.available, nil }
+
+func (r *fakeMemoryRepo) Insert(_ context.Context, m *store.AgentMemory) error {
+	m.ID = int64(len(r.rows) + 1)
+	r.rows = append(r.rows, *m)
+	return nil
+}
+
+func (r *fakeMemoryRepo) Search(_ context.Context, q store.AgentMemoryQuery) ([]store.AgentMemoryHit, error) {
+	var hits []store.AgentMemoryHit
+	for _, row := range r.rows {
+		if row.AgentID != q.AgentID {
+			continue
+		}
+		var dot float64
+		for i := range row.Embedding {
+			dot += float64(row.Embedding[i]) * float64(q.Embedding[i])
+		}
+		hits = append(hits, store.AgentMemoryHit{ID: row.ID, AgentID: row.AgentID, Kind: row.Kind, Content: row.Content, Metadata: row.Metadata, Score: dot})
+	}
+	sort.Slice(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+	if len(hits) > q.Limit {
+		hits = hits[:q.Limit]
+	}
+	return hits, nil
+}
+
+func (r *fakeMemoryRepo) DocHashes(_ context.Context, agentID string) (map[string]string, error) {
+	out := map[string]string{}
+	for _, row := range r.rows {
+		if docID, ok := row.Metadata["docId"].(string); ok && row.AgentID == agentID {
+			out[docID], _ = row.Metadata["docHash"].(string)
+		}
+	}
+	return out, nil
+}
+
+func (r *fakeMemoryRepo) DeleteDoc(_ context.Context, agentID, docID string) error {
+	kept := r.rows[:0]
+	for _, row := range r.rows {
+		if row.AgentID != agentID || row.Metadata["docId"] != docID {
+			kept = append(kept, row)
+		}
+	}
+	r.rows = kept
+	return nil
+}
+
+func TestAgentMemoryServiceRanksRelevantMemories(t *testing.T) {
+	repo := &fakeMemoryRepo{available: true}
+	svc := NewAgentMemoryService(repo, nil)
+	ctx := context.Background()
+	for _, rec := range []MemoryRecord{
+		{AgentID: "a", Kind: MemoryKindTurnSummary, Content: "Fixed the postgres connection pool leak in store package"},
+		{AgentID: "a", Kind: MemoryKindTurnSummary, Content: "Updated frontend button colors and css theme"},
+		{AgentID: "b", Content: "postgres connection pool tuning for another agent"},
+	} {
+		if _, err := svc.Remember(ctx, rec); err != nil {
+			t.Fatalf("Remember: %v", err)
+		}
+	}
+
+	hits, err := svc.Search(ctx, MemoryQuery{AgentID: "a", Query: "postgres pool leak again", TopK: 5, MinScore: 0.1})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(hits) != 1 || !strings.Contains(hits[0].Content, "postgres") {
+		t.Fatalf("hits = %+v, want only the postgres memory of agent a", hits)
+	}
+	if repo.rows[2].Kind != MemoryKindNote {
+		t.Fatalf("default kind = %q", repo.rows[2].Kind)
+	}
+
+	rendered := RenderMemoryContext(hits)
+	if !strings.HasPrefix(rendered, "[相关历史记忆]") || !strings.Contains(rendered, "1. (turn_summary") {
+		t.Fatalf("rendered = %q", rendered)
+	}
+	if RenderMemoryContext(nil) != "" {
+		t.Fatal("empty hits should render nothing")
+	}
+}
+
+func TestAgentMemoryServiceDisabledWithoutTable(t *testing.T) {
+	svc := NewAgentMemoryService(&fakeMemoryRepo{}, nil)
+	if svc.Enabled(context.Background()) {
+		t.Fatal("expected disabled when table is missing")
+	}
+	if _, err := svc.Remember(context.Background(), MemoryRecord{AgentID: "a", Content: "x"}); err == nil {
+		t.Fatal("Remember should fail when unavailable")
+	}
+	var nilSvc *AgentMemoryService
+	if nilSvc.Enabled(context.Background()) {
+		t.Fatal("nil service should be disabled")
+	}
+}
+
+func TestHashEmbedderTokenizesCJK(t *testing.T) {
+	vecs, _ := HashEmbedder{}.Embed(context.Background(), []string{"修复数据库连接泄漏", "数据库连接", "前端样式"})
+	cos := func(a, b []float32) float64 {
+		var dot float64
+		for i := range a {
+			dot += float64(a[i]) * float64(b[i])
+		}
+		return dot
+	}
+	if cos(vecs[0], vecs[1]) <= cos(vecs[0], vecs[2]) {
+		t.Fatal("related CJK texts should be closer than unrelated ones")
+	}
+}
+
+func TestOpenAIEmbedderRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/embeddings" || r.Header.Get("Authorization") != "Bearer key" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		var req struct {
+			Input      []string `json:"input"`
+			Dimensions int      `json:"dimensions"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		vec := make([]float32, req.Dimensions)
+		vec[0] = 1
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": []map[string]any{{"index": 0, "embedding": vec}}})
+	}))
+	defer srv.Close()
+
+	embedder := &OpenAIEmbedder{BaseURL: srv.URL + "/v1/", APIKey: "key", Model: "text-embedding-3-small"}
+	vecs, err := embedder.Embed(context.Background(), []string{"hello"})
+	if err != nil || len(vecs) != 1 || len(vecs[0]) != MemoryEmbeddingDim {
+		t.Fatalf("Embed = (%d vectors, %v)", len(vecs), err)
+	}
+	embedder.APIKey = "wrong"
+	if _, err := embedder.Embed(context.Background(), []string{"hello"}); err == nil {
+		t.Fatal("expected error on non-200 response")
+	}
+}
@@ -9,8 +9,10 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 	"unicode/utf8"
 
@@ -65,8 +67,15 @@ type SkillImportResult struct {
 }
 
 // SkillService 统一管理技能存储。
+//
+// 扫描结果 (SKILL.md 元数据) 缓存在内存中, 经本服务写入时自动失效;
+// 目录被外部修改时由调用方 (技能目录监听) 调用 Invalidate。
 type SkillService struct {
 	dir string
+
+	cacheMu sync.RWMutex
+	cached  []skillRecord
+	valid   bool
 }
 
 type skillRecord struct {
@@ -91,6 +100,14 @@ func NewSkillService(dir string) *SkillService {
 	return &SkillService{dir: dir}
 }
 
+// Invalidate 丢弃扫描缓存, 下次读取时重新扫描磁盘。
+func (s *SkillService) Invalidate() {
+	s.cacheMu.Lock()
+	s.cached = nil
+	s.valid = false
+	s.cacheMu.Unlock()
+}
+
 func (s *SkillService) byIDRoot() string {
 	return filepath.Join(s.dir, skillStoreByIDDir)
 }
@@ -142,7 +159,28 @@ func (s *SkillService) writeSkillIndex(dirPath, name string) error {
 	return os.WriteFile(filepath.Join(dirPath, skillIndexFile), data, 0o644)
 }
 
+// scanSkillRecords 返回技能记录 (优先使用缓存)。
 func (s *SkillService) scanSkillRecords() ([]skillRecord, error) {
+	s.cacheMu.RLock()
+	if s.valid {
+		records := slices.Clone(s.cached)
+		s.cacheMu.RUnlock()
+		return records, nil
+	}
+	s.cacheMu.RUnlock()
+
+	records, err := s.scanSkillRecordsFromDisk()
+	if err != nil {
+		return nil, err
+	}
+	s.cacheMu.Lock()
+	s.cached = records
+	s.valid = true
+	s.cacheMu.Unlock()
+	return slices.Clone(records), nil
+}
+
+func (s *SkillService) scanSkillRecordsFromDisk() ([]skillRecord, error) {
 	entries, err := os.ReadDir(s.byIDRoot())
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -287,6 +325,7 @@ func (s *SkillService) ReadSkillDigest(name string) (SkillDigest, error) {
 
 // WriteSkillContent 覆盖写入技能内容并更新索引。
 func (s *SkillService) WriteSkillContent(name, content string) (string, error) {
+	defer s.Invalidate()
 	storedName := strings.TrimSpace(name)
 	if storedName == "" {
 		return "", apperrors.New("SkillService.WriteSkillContent", "skill name is required")
@@ -318,6 +357,7 @@ func (s *SkillService) WriteSkillContent(name, content string) (string, error) {
 
 // UpdateSkillSummary 更新技能 frontmatter summary 字段。
 func (s *SkillService) UpdateSkillSummary(name, summary string) (skillPath string, resolvedName string, err error) {
+	defer s.Invalidate()
 	record, err := s.resolveSkillRecord(name)
 	if err != nil {
 		return "", "", err
@@ -340,6 +380,7 @@ func (s *SkillService) UpdateSkillSummary(name, summary string) (skillPath strin
 
 // DeleteSkill 删除技能目录。
 func (s *SkillService) DeleteSkill(name string) (resolvedName string, dir string, err error) {
+	defer s.Invalidate()
 	record, err := s.resolveSkillRecord(name)
 	if err != nil {
 		return "", "", err
@@ -356,6 +397,7 @@ func (s *SkillService) DeleteSkill(name string) (resolvedName string, dir string
 
 // ImportSkillDirectory 导入技能目录到 by-id 存储。
 func (s *SkillService) ImportSkillDirectory(sourceDir, name string) (SkillImportResult, error) {
+	defer s.Invalidate()
 	info, err := os.Stat(sourceDir)
 	if err != nil {
 		return SkillImportResult{}, apperrors.Wrap(err, "SkillService.ImportSkillDirectory", "stat source dir")
@@ -0,0 +1,298 @@
+// skills_registry.go — 远程技能注册表 (marketplace) 同步与安装。
+//
+// 注册表来源:
+//   - HTTPS JSON 清单: https://example.com/skills/index.json
+//   - Git 仓库:        git+https://example.com/skills.git (仓库根目录需包含 index.json)
+//
+// 安装流程: 拉取内容 → sha256 校验 → ed25519 签名校验 → 写入 SkillService 存储。
+package service
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+const (
+	skillRegistryIndexFile     = "index.json"
+	skillRegistryGitPrefix     = "git+"
+	maxSkillRegistryIndexBytes = 2 << 20 // 2MB
+	maxSkillRegistrySkillBytes = maxSkillImportSingleFileSize
+	skillRegistryFetchTimeout  = 20 * time.Second
+	skillRegistryCloneTimeout  = 60 * time.Second
+)
+
+// SkillRegistryEntry 注册表中的单个远程技能。
+type SkillRegistryEntry struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version,omitempty"`
+	URL         string `json:"url,omitempty"`  // HTTPS 清单: SKILL.md 下载地址
+	Path        string `json:"path,omitempty"` // Git 清单: 仓库内 SKILL.md 相对路径
+	SHA256      string `json:"sha256"`
+	Signature   string `json:"signature,omitempty"` // base64(ed25519(SKILL.md 原始内容))
+}
+
+// SkillRegistryIndex 注册表清单。
+type SkillRegistryIndex struct {
+	Source string               `json:"source"`
+	Skills []SkillRegistryEntry `json:"skills"`
+}
+
+// SkillRegistryInstallResult 单个技能安装结果。
+type SkillRegistryInstallResult struct {
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	SHA256   string `json:"sha256"`
+	Verified bool   `json:"verified"`
+}
+
+// SkillRegistry 远程注册表会话 (Git 来源会持有临时克隆目录, 使用后需 Close)。
+type SkillRegistry struct {
+	index    SkillRegistryIndex
+	client   *http.Client
+	cloneDir string
+}
+
+// OpenSkillRegistry 拉取并解析注册表清单。
+func OpenSkillRegistry(ctx context.Context, source string) (*SkillRegistry, error) {
+	source = strings.TrimSpace(source)
+	if source == "" {
+		return nil, apperrors.New("OpenSkillRegistry", "registry source is required")
+	}
+	reg := &SkillRegistry{client: &http.Client{Timeout: skillRegistryFetchTimeout}}
+
+	var data []byte
+	if strings.HasPrefix(source, skillRegistryGitPrefix) {
+		dir, err := cloneSkillRegistry(ctx, strings.TrimPrefix(source, skillRegistryGitPrefix))
+		if err != nil {
+			return nil, err
+		}
+		reg.cloneDir = dir
+		data, err = readLimitedFile(filepath.Join(dir, skillRegistryIndexFile), maxSkillRegistryIndexBytes)
+		if err != nil {
+			reg.Close()
+			return nil, apperrors.Wrap(err, "OpenSkillRegistry", "read git registry index")
+		}
+	} else {
+		if !strings.HasPrefix(strings.ToLower(source), "https://") {
+			return nil, apperrors.Newf("OpenSkillRegistry", "registry source must be https:// or git+https://: %s", source)
+		}
+		var err error
+		data, err = reg.fetch(ctx, source, maxSkillRegistryIndexBytes)
+		if err != nil {
+			return nil, apperrors.Wrap(err, "OpenSkillRegistry", "fetch registry index")
+		}
+	}
+
+	index, err := ParseSkillRegistryIndex(data)
+	if err != nil {
+		reg.Close()
+		return nil, err
+	}
+	index.Source = source
+	reg.index = index
+	return reg, nil
+}
+
+// ParseSkillRegistryIndex 解析并校验注册表清单 JSON。
+func ParseSkillRegistryIndex(data []byte) (SkillRegistryIndex, error) {
+	var index SkillRegistryIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return SkillRegistryIndex{}, apperrors.Wrap(err, "ParseSkillRegistryIndex", "invalid registry json")
+	}
+	seen := make(map[string]struct{}, len(index.Skills))
+	entries := make([]SkillRegistryEntry, 0, len(index.Skills))
+	for _, entry := range index.Skills {
+		entry.Name = strings.TrimSpace(entry.Name)
+		entry.SHA256 = strings.ToLower(strings.TrimSpace(entry.SHA256))
+		if entry.Name == "" {
+			return SkillRegistryIndex{}, apperrors.New("ParseSkillRegistryIndex", "registry entry missing name")
+		}
+		if _, err := hex.DecodeString(entry.SHA256); err != nil || len(entry.SHA256) != sha256.Size*2 {
+			return SkillRegistryIndex{}, apperrors.Newf("ParseSkillRegistryIndex", "registry entry %s has invalid sha256", entry.Name)
+		}
+		if strings.TrimSpace(entry.URL) == "" && strings.TrimSpace(entry.Path) == "" {
+			return SkillRegistryIndex{}, apperrors.Newf("ParseSkillRegistryIndex", "registry entry %s missing url or path", entry.Name)
+		}
+		key := strings.ToLower(entry.Name)
+		if _, ok := seen[key]; ok {
+			return SkillRegistryIndex{}, apperrors.Newf("ParseSkillRegistryIndex", "duplicate registry entry %s", entry.Name)
+		}
+		seen[key] = struct{}{}
+		entries = append(entries, entry)
+	}
+	index.Skills = entries
+	return index, nil
+}
+
+// Index 返回注册表清单。
+func (r *SkillRegistry) Index() SkillRegistryIndex {
+	return r.index
+}
+
+// Close 清理 Git 临时克隆目录。
+func (r *SkillRegistry) Close() {
+	if r == nil || r.cloneDir == "" {
+		return
+	}
+	_ = os.RemoveAll(r.cloneDir)
+	r.cloneDir = ""
+}
+
+// Install 下载、校验并安装指定技能。
+//
+// publicKey 为空时拒绝安装 (注册表技能必须签名校验)。
+func (r *SkillRegistry) Install(ctx context.Context, svc *SkillService, name string, publicKey ed25519.PublicKey) (SkillRegistryInstallResult, error) {
+	if svc == nil {
+		return SkillRegistryInstallResult{}, apperrors.New("SkillRegistry.Install", "skill service unavailable")
+	}
+	if len(publicKey) != ed25519.PublicKeySize {
+		return SkillRegistryInstallResult{}, apperrors.New("SkillRegistry.Install", "registry public key not configured")
+	}
+	entry, ok := r.lookup(name)
+	if !ok {
+		return SkillRegistryInstallResult{}, apperrors.Newf("SkillRegistry.Install", "skill %s not found in registry", name)
+	}
+	content, err := r.loadContent(ctx, entry)
+	if err != nil {
+		return SkillRegistryInstallResult{}, apperrors.Wrapf(err, "SkillRegistry.Install", "load skill %s", entry.Name)
+	}
+	if err := VerifySkillRegistryContent(entry, content, publicKey); err != nil {
+		return SkillRegistryInstallResult{}, err
+	}
+	path, err := svc.WriteSkillContent(entry.Name, string(content))
+	if err != nil {
+		return SkillRegistryInstallResult{}, apperrors.Wrapf(err, "SkillRegistry.Install", "write skill %s", entry.Name)
+	}
+	return SkillRegistryInstallResult{
+		Name:     entry.Name,
+		Path:     path,
+		SHA256:   entry.SHA256,
+		Verified: true,
+	}, nil
+}
+
+// VerifySkillRegistryContent 校验技能内容的 sha256 与 ed25519 签名。
+func VerifySkillRegistryContent(entry SkillRegistryEntry, content []byte, publicKey ed25519.PublicKey) error {
+	sum := sha256.Sum256(content)
+	if got := hex.EncodeToString(sum[:]); got != strings.ToLower(strings.TrimSpace(entry.SHA256)) {
+		return apperrors.Newf("VerifySkillRegistryContent", "checksum mismatch for %s: got %s", entry.Name, got)
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(entry.Signature))
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return apperrors.Newf("VerifySkillRegistryContent", "invalid signature for %s", entry.Name)
+	}
+	if !ed25519.Verify(publicKey, content, sig) {
+		return apperrors.Newf("VerifySkillRegistryContent", "signature verification failed for %s", entry.Name)
+	}
+	return nil
+}
+
+// ParseSkillRegistryPublicKey 解析 base64 编码的 ed25519 公钥 (空字符串返回 nil)。
+func ParseSkillRegistryPublicKey(raw string) (ed25519.PublicKey, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, apperrors.Wrap(err, "ParseSkillRegistryPublicKey", "decode base64")
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return nil, apperrors.Newf("ParseSkillRegistryPublicKey", "invalid key size %d", len(key))
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+func (r *SkillRegistry) lookup(name string) (SkillRegistryEntry, bool) {
+	for _, entry := range r.index.Skills {
+		if matchSkillName(entry.Name, name) {
+			return entry, true
+		}
+	}
+	return SkillRegistryEntry{}, false
+}
+
+func (r *SkillRegistry) loadContent(ctx context.Context, entry SkillRegistryEntry) ([]byte, error) {
+	if rel := strings.TrimSpace(entry.Path); rel != "" && r.cloneDir != "" {
+		clean := filepath.Clean(filepath.FromSlash(rel))
+		if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+			return nil, apperrors.Newf("SkillRegistry.loadContent", "path escapes registry: %s", rel)
+		}
+		return readLimitedFile(filepath.Join(r.cloneDir, clean), maxSkillRegistrySkillBytes)
+	}
+	url := strings.TrimSpace(entry.URL)
+	if !strings.HasPrefix(strings.ToLower(url), "https://") {
+		return nil, apperrors.Newf("SkillRegistry.loadContent", "skill url must be https://: %s", url)
+	}
+	return r.fetch(ctx, url, maxSkillRegistrySkillBytes)
+}
+
+func (r *SkillRegistry) fetch(ctx context.Context, url string, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, apperrors.Newf("SkillRegistry.fetch", "unexpected status %d from %s", resp.StatusCode, url)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, apperrors.Newf("SkillRegistry.fetch", "response too large from %s (limit %d bytes)", url, limit)
+	}
+	return data, nil
+}
+
+func cloneSkillRegistry(ctx context.Context, repoURL string) (string, error) {
+	if !strings.HasPrefix(strings.ToLower(repoURL), "https://") {
+		return "", apperrors.Newf("cloneSkillRegistry", "git registry must use https: %s", repoURL)
+	}
+	dir, err := os.MkdirTemp("", "skill-registry-*")
+	if err != nil {
+		return "", apperrors.Wrap(err, "cloneSkillRegistry", "create temp dir")
+	}
+	cloneCtx, cancel := context.WithTimeout(ctx, skillRegistryCloneTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(cloneCtx, "git", "clone", "--depth", "1", "--quiet", repoURL, dir)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", apperrors.Wrapf(err, "cloneSkillRegistry", "git clone failed: %s", strings.TrimSpace(string(out)))
+	}
+	return dir, nil
+}
+
+func readLimitedFile(path string, limit int64) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, apperrors.Newf("readLimitedFile", "path is directory: %s", path)
+	}
+	if info.Size() > limit {
+		return nil, apperrors.Newf("readLimitedFile", "file too large: %s (%d bytes, limit %d bytes)", path, info.Size(), limit)
+	}
+	return os.ReadFile(path)
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func signedRegistryEntry(t *testing.T, priv ed25519.PrivateKey, name, url, content string) SkillRegistryEntry {
+	t.Helper()
+	sum := sha256.Sum256([]byte(content))
+	return SkillRegistryEntry{
+		Name:      name,
+		URL:       url,
+		SHA256:    hex.EncodeToString(sum[:]),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(priv, []byte(content))),
+	}
+}
+
+func TestParseSkillRegistryIndexRejectsInvalidEntries(t *testing.T) {
+	cases := map[string]string{
+		"missing name":   `{"skills":[{"url":"https://x/a","sha256":"` + strings.Repeat("a", 64) + `"}]}`,
+		"bad checksum":   `{"skills":[{"name":"a","url":"https://x/a","sha256":"zz"}]}`,
+		"missing source": `{"skills":[{"name":"a","sha256":"` + strings.Repeat("a", 64) + `"}]}`,
+		"duplicate": `{"skills":[{"name":"a","url":"https://x/a","sha256":"` + strings.Repeat("a", 64) + `"},` +
+			`{"name":"A","url":"https://x/b","sha256":"` + strings.Repeat("b", 64) + `"}]}`,
+	}
+	for label, raw := range cases {
+		if _, err := ParseSkillRegistryIndex([]byte(raw)); err == nil {
+			t.Fatalf("%s: expected error", label)
+		}
+	}
+}
+
+func TestSkillRegistryInstallVerifiesSignature(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	const content = "---\nname: remote-go\ndescription: remote skill\n---\n# Remote"
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(content))
+	}))
+	defer srv.Close()
+
+	good := signedRegistryEntry(t, priv, "remote-go", srv.URL+"/good.md", content)
+	tampered := good
+	tampered.Name = "tampered"
+	tampered.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(priv, []byte("other")))
+
+	raw, _ := json.Marshal(SkillRegistryIndex{Skills: []SkillRegistryEntry{good, tampered}})
+	index, err := ParseSkillRegistryIndex(raw)
+	if err != nil {
+		t.Fatalf("ParseSkillRegistryIndex: %v", err)
+	}
+	reg := &SkillRegistry{index: index, client: srv.Client()}
+	svc := NewSkillService(t.TempDir())
+
+	if _, err := reg.Install(context.Background(), svc, "remote-go", nil); err == nil {
+		t.Fatal("expected install without public key to fail")
+	}
+	result, err := reg.Install(context.Background(), svc, "remote-go", pub)
+	if err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	if !result.Verified {
+		t.Fatalf("result=%+v, want verified", result)
+	}
+	got, err := svc.ReadSkillContent("remote-go")
+	if err != nil || got != content {
+		t.Fatalf("ReadSkillContent=%q err=%v", got, err)
+	}
+	if _, err := reg.Install(context.Background(), svc, "tampered", pub); err == nil {
+		t.Fatal("expected tampered signature to fail")
+	}
+}
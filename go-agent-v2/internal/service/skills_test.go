@@ -306,3 +306,36 @@ description: "%s"
 		t.Fatalf("description should keep full text, got=%q", meta.Description)
 	}
 }
+
+func TestListSkillsCacheInvalidation(t *testing.T) {
+	tmp := t.TempDir()
+	svc := NewSkillService(tmp)
+	writeSkillFixture(t, svc, "go-review", "---\ndescription: \"v1\"\n---\n# review")
+
+	list, err := svc.ListSkills()
+	if err != nil || len(list) != 1 || list[0].Description != "v1" {
+		t.Fatalf("ListSkills = %+v, %v", list, err)
+	}
+
+	// 经服务写入: 缓存自动失效。
+	writeSkillFixture(t, svc, "go-review", "---\ndescription: \"v2\"\n---\n# review")
+	if list, _ = svc.ListSkills(); list[0].Description != "v2" {
+		t.Fatalf("description after WriteSkillContent = %q, want v2", list[0].Description)
+	}
+
+	// 外部直接修改文件: Invalidate 前仍返回缓存。
+	record, err := svc.resolveSkillRecord("go-review")
+	if err != nil {
+		t.Fatalf("resolveSkillRecord: %v", err)
+	}
+	if err := os.WriteFile(record.SkillPath, []byte("---\ndescription: \"v3\"\n---\n# review"), 0o644); err != nil {
+		t.Fatalf("write SKILL.md: %v", err)
+	}
+	if list, _ = svc.ListSkills(); list[0].Description != "v2" {
+		t.Fatalf("description before Invalidate = %q, want cached v2", list[0].Description)
+	}
+	svc.Invalidate()
+	if list, _ = svc.ListSkills(); list[0].Description != "v3" {
+		t.Fatalf("description after Invalidate = %q, want v3", list[0].Description)
+	}
+}
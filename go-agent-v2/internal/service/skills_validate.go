@@ -0,0 +1,206 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/goccy/go-yaml"
+)
+
+const (
+	maxSkillContentBytes   = 256 << 10 // 256KB: 超出后注入/摘要代价过高
+	maxSkillNameRunes      = 128
+	maxSkillTriggerRunes   = 200
+	SkillIssueSeverityErr  = "error"
+	SkillIssueSeverityWarn = "warning"
+)
+
+var (
+	skillTriggerKeys = []string{"trigger_words", "triggerwords", "trigger_words_list", "triggers", "aliases", "alias", "tags", "tag", "keywords", "keyword"}
+	skillForceKeys   = []string{"force_words", "forcewords", "mandatory_words", "must_words"}
+)
+
+// SkillValidationIssue 单条校验问题。
+type SkillValidationIssue struct {
+	Severity string `json:"severity"` // error / warning
+	Field    string `json:"field,omitempty"`
+	Message  string `json:"message"`
+}
+
+// SkillValidationResult 单个技能的校验结果。
+type SkillValidationResult struct {
+	Name   string                 `json:"name"`
+	Path   string                 `json:"path,omitempty"`
+	Bytes  int                    `json:"bytes"`
+	Valid  bool                   `json:"valid"`
+	Issues []SkillValidationIssue `json:"issues"`
+}
+
+func (r *SkillValidationResult) addIssue(severity, field, message string) {
+	r.Issues = append(r.Issues, SkillValidationIssue{Severity: severity, Field: field, Message: message})
+	if severity == SkillIssueSeverityErr {
+		r.Valid = false
+	}
+}
+
+// ParseSkillTriggerPattern 解析正则触发词 (形如 /pattern/, 大小写不敏感)。
+//
+// 非 /.../ 形式返回 isPattern=false, 调用方按普通子串匹配处理。
+func ParseSkillTriggerPattern(word string) (re *regexp.Regexp, isPattern bool, err error) {
+	trimmed := strings.TrimSpace(word)
+	if len(trimmed) < 3 || !strings.HasPrefix(trimmed, "/") || !strings.HasSuffix(trimmed, "/") {
+		return nil, false, nil
+	}
+	re, err = regexp.Compile("(?i)" + trimmed[1:len(trimmed)-1])
+	return re, true, err
+}
+
+// ValidateSkillContent 解析 SKILL.md YAML frontmatter 并校验结构。
+//
+// 检查项: frontmatter 存在且为合法 YAML、name 必填、触发词类型与正则合法性、内容大小。
+func ValidateSkillContent(name, content string) SkillValidationResult {
+	result := SkillValidationResult{
+		Name:   strings.TrimSpace(name),
+		Bytes:  len(content),
+		Valid:  true,
+		Issues: []SkillValidationIssue{},
+	}
+	if len(content) > maxSkillContentBytes {
+		result.addIssue(SkillIssueSeverityErr, "content", fmt.Sprintf("content too large: %d bytes (limit %d)", len(content), maxSkillContentBytes))
+	}
+	if !utf8.ValidString(content) {
+		result.addIssue(SkillIssueSeverityErr, "content", "content is not valid UTF-8")
+	}
+
+	frontmatter, ok := extractFrontmatter(content)
+	if !ok {
+		result.addIssue(SkillIssueSeverityErr, "frontmatter", "missing YAML frontmatter (--- ... ---)")
+		return result
+	}
+	fields := map[string]any{}
+	if err := yaml.Unmarshal([]byte(frontmatter), &fields); err != nil {
+		result.addIssue(SkillIssueSeverityErr, "frontmatter", "invalid YAML: "+strings.TrimSpace(err.Error()))
+		return result
+	}
+	normalized := make(map[string]any, len(fields))
+	for key, value := range fields {
+		normalized[strings.ToLower(strings.TrimSpace(key))] = value
+	}
+
+	declared, ok := normalized["name"].(string)
+	switch {
+	case normalized["name"] == nil:
+		result.addIssue(SkillIssueSeverityErr, "name", "name is required")
+	case !ok:
+		result.addIssue(SkillIssueSeverityErr, "name", "name must be a string")
+	case strings.TrimSpace(declared) == "":
+		result.addIssue(SkillIssueSeverityErr, "name", "name is required")
+	case utf8.RuneCountInString(declared) > maxSkillNameRunes:
+		result.addIssue(SkillIssueSeverityErr, "name", "name too long")
+	default:
+		if result.Name == "" {
+			result.Name = strings.TrimSpace(declared)
+		} else if !matchSkillName(result.Name, declared) {
+			result.addIssue(SkillIssueSeverityWarn, "name", "frontmatter name "+quoteYAMLScalar(declared)+" differs from skill name "+quoteYAMLScalar(result.Name))
+		}
+	}
+
+	if description, exists := normalized["description"]; !exists || description == nil {
+		result.addIssue(SkillIssueSeverityWarn, "description", "description is recommended for skill listing")
+	} else if _, ok := description.(string); !ok {
+		result.addIssue(SkillIssueSeverityErr, "description", "description must be a string")
+	}
+
+	for _, key := range append(append([]string(nil), skillTriggerKeys...), skillForceKeys...) {
+		value, exists := normalized[key]
+		if !exists || value == nil {
+			continue
+		}
+		words, ok := skillWordList(value)
+		if !ok {
+			result.addIssue(SkillIssueSeverityErr, key, "must be a string or a list of strings")
+			continue
+		}
+		for _, word := range words {
+			if utf8.RuneCountInString(word) > maxSkillTriggerRunes {
+				result.addIssue(SkillIssueSeverityErr, key, "trigger too long: "+quoteYAMLScalar(truncateRunes(word, 40)))
+				continue
+			}
+			if _, isPattern, err := ParseSkillTriggerPattern(word); isPattern && err != nil {
+				result.addIssue(SkillIssueSeverityErr, key, "invalid trigger regex "+quoteYAMLScalar(word)+": "+err.Error())
+			}
+		}
+	}
+	return result
+}
+
+// ValidateSkills 校验已安装技能; names 为空时校验全部。
+func (s *SkillService) ValidateSkills(names []string) ([]SkillValidationResult, error) {
+	if len(names) == 0 {
+		records, err := s.scanSkillRecords()
+		if err != nil {
+			return nil, err
+		}
+		results := make([]SkillValidationResult, 0, len(records))
+		for _, record := range records {
+			results = append(results, validateSkillRecord(record))
+		}
+		return results, nil
+	}
+
+	results := make([]SkillValidationResult, 0, len(names))
+	for _, name := range names {
+		record, err := s.resolveSkillRecord(name)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				return nil, err
+			}
+			missing := SkillValidationResult{Name: strings.TrimSpace(name), Valid: true, Issues: []SkillValidationIssue{}}
+			missing.addIssue(SkillIssueSeverityErr, "name", "skill not found")
+			results = append(results, missing)
+			continue
+		}
+		results = append(results, validateSkillRecord(record))
+	}
+	return results, nil
+}
+
+func validateSkillRecord(record skillRecord) SkillValidationResult {
+	name := strings.TrimSpace(record.StoredName)
+	if name == "" {
+		name = skillDisplayName(record.StoredName, record.Meta, record.ID)
+	}
+	data, err := os.ReadFile(record.SkillPath)
+	if err != nil {
+		failed := SkillValidationResult{Name: name, Path: record.SkillPath, Valid: true, Issues: []SkillValidationIssue{}}
+		failed.addIssue(SkillIssueSeverityErr, "content", "read SKILL.md failed: "+err.Error())
+		return failed
+	}
+	result := ValidateSkillContent(name, string(data))
+	result.Path = record.SkillPath
+	return result
+}
+
+func skillWordList(value any) ([]string, bool) {
+	switch typed := value.(type) {
+	case string:
+		return parseWordsFromValue(typed), true
+	case []any:
+		words := make([]string, 0, len(typed))
+		for _, item := range typed {
+			word, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			if trimmed := strings.TrimSpace(word); trimmed != "" {
+				words = append(words, trimmed)
+			}
+		}
+		return words, true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateSkillContentReportsSchemaErrors(t *testing.T) {
+	cases := map[string]struct {
+		content string
+		field   string
+	}{
+		"missing frontmatter": {content: "# no frontmatter", field: "frontmatter"},
+		"invalid yaml":        {content: "---\nname: [a\n---\n", field: "frontmatter"},
+		"missing name":        {content: "---\ndescription: d\n---\nbody", field: "name"},
+		"bad regex":           {content: "---\nname: a\ntrigger_words: [\"/(unclosed/\"]\n---\n", field: "trigger_words"},
+		"bad trigger type":    {content: "---\nname: a\nforce_words:\n  nested: true\n---\n", field: "force_words"},
+		"oversized":           {content: "---\nname: a\n---\n" + strings.Repeat("x", maxSkillContentBytes), field: "content"},
+	}
+	for label, tc := range cases {
+		result := ValidateSkillContent("", tc.content)
+		if result.Valid {
+			t.Fatalf("%s: expected invalid, got %+v", label, result)
+		}
+		found := false
+		for _, issue := range result.Issues {
+			if issue.Severity == SkillIssueSeverityErr && issue.Field == tc.field {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("%s: missing %s error in %+v", label, tc.field, result.Issues)
+		}
+	}
+}
+
+func TestValidateSkillContentAcceptsRegexTriggers(t *testing.T) {
+	content := "---\nname: go-test\ndescription: run go tests\ntrigger_words:\n  - go test\n  - /go\\s+vet/\n---\n# Go"
+	result := ValidateSkillContent("go-test", content)
+	if !result.Valid || len(result.Issues) != 0 {
+		t.Fatalf("result=%+v, want valid without issues", result)
+	}
+
+	re, isPattern, err := ParseSkillTriggerPattern("/go\\s+vet/")
+	if err != nil || !isPattern || !re.MatchString("please run GO  vet") {
+		t.Fatalf("pattern=(%v, %v, %v), want case-insensitive match", re, isPattern, err)
+	}
+	if _, isPattern, _ := ParseSkillTriggerPattern("go test"); isPattern {
+		t.Fatal("plain trigger should not be treated as pattern")
+	}
+}
+
+func TestSkillServiceValidateSkills(t *testing.T) {
+	svc := NewSkillService(t.TempDir())
+	if _, err := svc.WriteSkillContent("good", "---\nname: good\ndescription: ok\n---\n# Good"); err != nil {
+		t.Fatalf("WriteSkillContent: %v", err)
+	}
+	if _, err := svc.WriteSkillContent("broken", "# no frontmatter"); err != nil {
+		t.Fatalf("WriteSkillContent: %v", err)
+	}
+
+	results, err := svc.ValidateSkills(nil)
+	if err != nil {
+		t.Fatalf("ValidateSkills: %v", err)
+	}
+	valid := map[string]bool{}
+	for _, result := range results {
+		valid[result.Name] = result.Valid
+	}
+	if len(results) != 2 || !valid["good"] || valid["broken"] {
+		t.Fatalf("results=%+v, want good valid and broken invalid", results)
+	}
+
+	results, err = svc.ValidateSkills([]string{"missing"})
+	if err != nil || len(results) != 1 || results[0].Valid {
+		t.Fatalf("missing skill results=%+v err=%v", results, err)
+	}
+}
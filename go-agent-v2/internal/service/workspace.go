@@ -216,6 +216,23 @@ func (m *WorkspaceManager) GetRun(ctx context.Context, runKey string) (*store.Wo
 	return m.runs.GetRun(ctx, strings.TrimSpace(runKey))
 }
 
+// ListMergedFiles 返回 run 已合并到源目录的文件 (SourceSHA256After 为空表示删除)。
+func (m *WorkspaceManager) ListMergedFiles(ctx context.Context, runKey string) ([]WorkspaceMergeFileResult, error) {
+	rows, err := m.runs.ListFiles(ctx, strings.TrimSpace(runKey), WorkspaceFileStateMerged, m.maxFiles*4)
+	if err != nil {
+		return nil, apperrors.Wrap(err, "WorkspaceManager.ListMergedFiles", "list merged files")
+	}
+	files := make([]WorkspaceMergeFileResult, 0, len(rows))
+	for _, row := range rows {
+		action := "merged"
+		if row.SourceSHA256After == "" {
+			action = "deleted"
+		}
+		files = append(files, WorkspaceMergeFileResult{Path: row.RelativePath, Action: action})
+	}
+	return files, nil
+}
+
 func (m *WorkspaceManager) ListRuns(ctx context.Context, status, dagKey string, limit int) ([]store.WorkspaceRun, error) {
 	return m.runs.ListRuns(ctx, strings.TrimSpace(status), strings.TrimSpace(dagKey), limit)
 }
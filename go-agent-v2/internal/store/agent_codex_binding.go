@@ -31,11 +31,37 @@ type AgentCodexBinding struct {
 }
 
 // AgentCodexBindingStore agent_codex_binding 表操作。
-type AgentCodexBindingStore struct{ BaseStore }
+//
+// FindByAgentID / ListAll 走进程内读缓存 (SetCacheTTL 启用), Bind / Unbind 写入后立即失效。
+type AgentCodexBindingStore struct {
+	BaseStore
+	byAgent *readCache[*AgentCodexBinding]
+	list    *readCache[[]AgentCodexBinding]
+}
 
 // NewAgentCodexBindingStore 创建。
 func NewAgentCodexBindingStore(pool *pgxpool.Pool) *AgentCodexBindingStore {
-	return &AgentCodexBindingStore{NewBaseStore(pool)}
+	return &AgentCodexBindingStore{
+		BaseStore: NewBaseStore(pool),
+		byAgent:   newReadCache("agent_codex_binding", clonePtr[AgentCodexBinding]),
+		list:      newReadCache("agent_codex_binding.list", cloneSlice[AgentCodexBinding]),
+	}
+}
+
+// SetCacheTTL 设置读缓存 TTL (≤ 0 禁用), 同时清空已缓存数据。
+func (s *AgentCodexBindingStore) SetCacheTTL(ttl time.Duration) {
+	s.byAgent.setTTL(ttl)
+	s.list.setTTL(ttl)
+}
+
+// CacheStats 返回读缓存统计。
+func (s *AgentCodexBindingStore) CacheStats() []CacheStats {
+	return []CacheStats{s.byAgent.stats(), s.list.stats()}
+}
+
+func (s *AgentCodexBindingStore) invalidate(agentID string) {
+	s.byAgent.invalidate(agentID)
+	s.list.invalidate()
 }
 
 const acbCols = "agent_id, codex_thread_id, rollout_path, created_at, updated_at"
@@ -54,10 +80,12 @@ func (s *AgentCodexBindingStore) Bind(ctx context.Context, agentID, codexThreadI
 		return fmt.Errorf("bind requires non-empty agent_id and codex_thread_id")
 	}
 
-	existing, err := s.FindByAgentID(ctx, agentID)
+	// 不变性校验直接查库, 不读缓存。
+	existing, err := s.findByAgentID(ctx, agentID)
 	if err != nil {
 		return err
 	}
+	defer s.invalidate(agentID)
 	now := time.Now().Unix()
 	if existing != nil {
 		if strings.TrimSpace(existing.CodexThreadID) != codexThreadID {
@@ -85,6 +113,7 @@ func (s *AgentCodexBindingStore) Bind(ctx context.Context, agentID, codexThreadI
 
 // Unbind 删除绑定 (共生共灭: agent 删除时调用)。
 func (s *AgentCodexBindingStore) Unbind(ctx context.Context, agentID string) error {
+	defer s.invalidate(agentID)
 	_, err := s.pool.Exec(ctx,
 		"DELETE FROM agent_codex_binding WHERE agent_id = $1", agentID)
 	return err
@@ -94,6 +123,12 @@ func (s *AgentCodexBindingStore) Unbind(ctx context.Context, agentID string) err
 //
 // 返回 nil 表示该 agent 尚未绑定 (首次启动)。
 func (s *AgentCodexBindingStore) FindByAgentID(ctx context.Context, agentID string) (*AgentCodexBinding, error) {
+	return s.byAgent.load(ctx, agentID, func(ctx context.Context) (*AgentCodexBinding, error) {
+		return s.findByAgentID(ctx, agentID)
+	})
+}
+
+func (s *AgentCodexBindingStore) findByAgentID(ctx context.Context, agentID string) (*AgentCodexBinding, error) {
 	rows, err := s.pool.Query(ctx,
 		"SELECT "+acbCols+" FROM agent_codex_binding WHERE agent_id = $1", agentID)
 	if err != nil {
@@ -114,10 +149,21 @@ func (s *AgentCodexBindingStore) FindByCodexThreadID(ctx context.Context, codexT
 
 // ListAll 返回所有绑定 (调试/运维用)。
 func (s *AgentCodexBindingStore) ListAll(ctx context.Context) ([]AgentCodexBinding, error) {
-	rows, err := s.pool.Query(ctx,
-		"SELECT "+acbCols+" FROM agent_codex_binding ORDER BY created_at DESC")
-	if err != nil {
-		return nil, err
+	return s.list.load(ctx, "", func(ctx context.Context) ([]AgentCodexBinding, error) {
+		rows, err := s.pool.Query(ctx,
+			"SELECT "+acbCols+" FROM agent_codex_binding ORDER BY created_at DESC")
+		if err != nil {
+			return nil, err
+		}
+		return collectRows[AgentCodexBinding](rows)
+	})
+}
+
+// CodexThreadID 返回 agent 绑定的 codex_thread_id (未绑定返回空串; 供 runner.AdoptOrphans 恢复线程)。
+func (s *AgentCodexBindingStore) CodexThreadID(ctx context.Context, agentID string) (string, error) {
+	binding, err := s.FindByAgentID(ctx, agentID)
+	if err != nil || binding == nil {
+		return "", err
 	}
-	return collectRows[AgentCodexBinding](rows)
+	return strings.TrimSpace(binding.CodexThreadID), nil
 }
@@ -0,0 +1,78 @@
+// agent_export.go — 按 agent 导出其全部持久化数据 (data/export)。
+//
+// 与各 store 的 List 不同, 导出不受 QueryBuilder 的 2000 行上限约束, 逐行回调以便边查询边写入压缩包。
+package store
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AgentExportStore agent 数据导出查询。
+type AgentExportStore struct{ BaseStore }
+
+// NewAgentExportStore 创建导出查询。
+func NewAgentExportStore(pool *pgxpool.Pool) *AgentExportStore {
+	return &AgentExportStore{NewBaseStore(pool)}
+}
+
+// EachInteraction 逐条回调 agent 作为线程、发送方或接收方的交互记录 (按时间升序)。
+func (s *AgentExportStore) EachInteraction(ctx context.Context, agentID string, fn func(Interaction) error) error {
+	rows, err := s.pool.Query(ctx,
+		"SELECT "+interactionCols+` FROM agent_interactions
+		 WHERE thread_id = $1 OR sender = $1 OR receiver = $1
+		 ORDER BY created_at, id`, agentID)
+	if err != nil {
+		return err
+	}
+	return eachRow(rows, func(row Interaction) error {
+		if err := row.decryptFields(); err != nil {
+			return err
+		}
+		return fn(row)
+	})
+}
+
+// EachTaskTrace 逐条回调 agent 的任务追踪 span (component = agentID, 按开始时间升序)。
+func (s *AgentExportStore) EachTaskTrace(ctx context.Context, agentID string, fn func(TaskTrace) error) error {
+	rows, err := s.pool.Query(ctx,
+		"SELECT "+taskTraceCols+" FROM task_traces WHERE component = $1 ORDER BY started_at, id", agentID)
+	if err != nil {
+		return err
+	}
+	return eachRow(rows, fn)
+}
+
+// EachAuditEvent 逐条回调 agent 作为操作者或目标的审计事件 (按时间升序)。
+func (s *AgentExportStore) EachAuditEvent(ctx context.Context, agentID string, fn func(AuditEvent) error) error {
+	rows, err := s.pool.Query(ctx,
+		`SELECT ts, event_type, action, result, actor, target, detail, level, extra FROM audit_events
+		 WHERE actor = $1 OR target = $1
+		 ORDER BY ts, id`, agentID)
+	if err != nil {
+		return err
+	}
+	return eachRow(rows, func(row AuditEvent) error {
+		if err := row.decryptFields(); err != nil {
+			return err
+		}
+		return fn(row)
+	})
+}
+
+// eachRow 逐行扫描并回调, 回调出错时停止。
+func eachRow[T any](rows pgx.Rows, fn func(T) error) error {
+	defer rows.Close()
+	for rows.Next() {
+		item, err := pgx.RowToStructByNameLax[T](rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
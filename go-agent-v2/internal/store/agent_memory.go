@@ -0,0 +1,140 @@
+// agent_memory.go — agent_memories 表 (pgvector) 读写: agent 长期记忆。
+package store
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AgentMemory 一条 agent 记忆。
+type AgentMemory struct {
+	ID        int64          `db:"id" json:"id"`
+	AgentID   string         `db:"agent_id" json:"agent_id"`
+	ThreadID  string         `db:"thread_id" json:"thread_id,omitempty"`
+	TurnID    string         `db:"turn_id" json:"turn_id,omitempty"`
+	Kind      string         `db:"kind" json:"kind"`
+	Content   string         `db:"content" json:"content"`
+	Metadata  map[string]any `db:"metadata" json:"metadata,omitempty"`
+	CreatedAt int64          `db:"created_at" json:"created_at"`
+	Embedding []float32      `db:"-" json:"-"`
+}
+
+// AgentMemoryHit 检索命中 (score = 余弦相似度)。
+type AgentMemoryHit struct {
+	ID        int64          `db:"id" json:"id"`
+	AgentID   string         `db:"agent_id" json:"agent_id"`
+	ThreadID  string         `db:"thread_id" json:"thread_id,omitempty"`
+	TurnID    string         `db:"turn_id" json:"turn_id,omitempty"`
+	Kind      string         `db:"kind" json:"kind"`
+	Content   string         `db:"content" json:"content"`
+	Metadata  map[string]any `db:"metadata" json:"metadata,omitempty"`
+	CreatedAt int64          `db:"created_at" json:"created_at"`
+	Score     float64        `db:"score" json:"score"`
+}
+
+// AgentMemoryQuery 向量检索条件。
+type AgentMemoryQuery struct {
+	AgentID   string
+	Embedding []float32
+	Limit     int
+	Kinds     []string
+}
+
+// AgentMemoryStore agent_memories 存储。
+type AgentMemoryStore struct{ BaseStore }
+
+// NewAgentMemoryStore 创建。
+func NewAgentMemoryStore(pool *pgxpool.Pool) *AgentMemoryStore {
+	return &AgentMemoryStore{NewBaseStore(pool)}
+}
+
+// Available agent_memories 表是否存在 (数据库未安装 pgvector 时迁移会跳过建表)。
+func (s *AgentMemoryStore) Available(ctx context.Context) (bool, error) {
+	var exists bool
+	err := s.pool.QueryRow(ctx, "SELECT to_regclass('agent_memories') IS NOT NULL").Scan(&exists)
+	return exists, err
+}
+
+// Insert 写入一条记忆并回填 ID / CreatedAt。
+func (s *AgentMemoryStore) Insert(ctx context.Context, m *AgentMemory) error {
+	m.CreatedAt = time.Now().Unix()
+	return s.pool.QueryRow(ctx,
+		`INSERT INTO agent_memories (agent_id, thread_id, turn_id, kind, content, metadata, embedding, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6::jsonb, $7::vector, $8)
+		 RETURNING id`,
+		m.AgentID, m.ThreadID, m.TurnID, m.Kind, m.Content, string(mustMarshalJSON(m.Metadata)), formatVector(m.Embedding), m.CreatedAt,
+	).Scan(&m.ID)
+}
+
+// Search 按余弦距离检索指定 agent 的记忆。
+func (s *AgentMemoryStore) Search(ctx context.Context, q AgentMemoryQuery) ([]AgentMemoryHit, error) {
+	sql := `SELECT id, agent_id, thread_id, turn_id, kind, content, metadata, created_at,
+	               1 - (embedding <=> $2::vector) AS score
+	        FROM agent_memories WHERE agent_id = $1`
+	params := []any{q.AgentID, formatVector(q.Embedding)}
+	if len(q.Kinds) > 0 {
+		params = append(params, q.Kinds)
+		sql += " AND kind = ANY($" + strconv.Itoa(len(params)) + ")"
+	}
+	params = append(params, q.Limit)
+	sql += " ORDER BY embedding <=> $2::vector LIMIT $" + strconv.Itoa(len(params))
+	rows, err := s.pool.Query(ctx, sql, params...)
+	if err != nil {
+		return nil, err
+	}
+	return collectRows[AgentMemoryHit](rows)
+}
+
+// DeleteByAgent 删除 agent 的全部记忆, 返回删除条数。
+func (s *AgentMemoryStore) DeleteByAgent(ctx context.Context, agentID string) (int64, error) {
+	tag, err := s.pool.Exec(ctx, "DELETE FROM agent_memories WHERE agent_id = $1", agentID)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// DocHashes 返回 agent 命名空间下各文档 (metadata.docId) 的内容指纹 (metadata.docHash)。
+func (s *AgentMemoryStore) DocHashes(ctx context.Context, agentID string) (map[string]string, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT DISTINCT metadata->>'docId', COALESCE(metadata->>'docHash', '')
+		 FROM agent_memories WHERE agent_id = $1 AND metadata ? 'docId'`, agentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make(map[string]string)
+	for rows.Next() {
+		var docID, hash string
+		if err := rows.Scan(&docID, &hash); err != nil {
+			return nil, err
+		}
+		out[docID] = hash
+	}
+	return out, rows.Err()
+}
+
+// DeleteDoc 删除 agent 命名空间下指定文档的全部分块。
+func (s *AgentMemoryStore) DeleteDoc(ctx context.Context, agentID, docID string) error {
+	_, err := s.pool.Exec(ctx, "DELETE FROM agent_memories WHERE agent_id = $1 AND metadata->>'docId' = $2", agentID, docID)
+	return err
+}
+
+// formatVector 转为 pgvector 文本字面量 "[x,y,...]"。
+func formatVector(vec []float32) string {
+	var b strings.Builder
+	b.Grow(len(vec) * 10)
+	b.WriteByte('[')
+	for i, v := range vec {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatFloat(float64(v), 'g', 7, 32))
+	}
+	b.WriteByte(']')
+	return b.String()
+}
@@ -5,6 +5,7 @@ package store
 import (
 	"context"
 	"regexp"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 
@@ -12,11 +13,32 @@ import (
 )
 
 // AgentStatusStore Agent 状态存储。
-type AgentStatusStore struct{ BaseStore }
+//
+// Get / List 走进程内读缓存 (SetCacheTTL 启用), Upsert 写入后立即失效。
+type AgentStatusStore struct {
+	BaseStore
+	byAgent *readCache[*AgentStatus]
+	list    *readCache[[]AgentStatus]
+}
 
 // NewAgentStatusStore 创建。
 func NewAgentStatusStore(pool *pgxpool.Pool) *AgentStatusStore {
-	return &AgentStatusStore{NewBaseStore(pool)}
+	return &AgentStatusStore{
+		BaseStore: NewBaseStore(pool),
+		byAgent:   newReadCache("agent_status", clonePtr[AgentStatus]),
+		list:      newReadCache("agent_status.list", cloneSlice[AgentStatus]),
+	}
+}
+
+// SetCacheTTL 设置读缓存 TTL (≤ 0 禁用), 同时清空已缓存数据。
+func (s *AgentStatusStore) SetCacheTTL(ttl time.Duration) {
+	s.byAgent.setTTL(ttl)
+	s.list.setTTL(ttl)
+}
+
+// CacheStats 返回读缓存统计。
+func (s *AgentStatusStore) CacheStats() []CacheStats {
+	return []CacheStats{s.byAgent.stats(), s.list.stats()}
 }
 
 var agentIDRe = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
@@ -64,6 +86,10 @@ func (s *AgentStatusStore) Upsert(ctx context.Context, a *AgentStatus) (*AgentSt
 	a.OutputTail = normalizeOutputTail(a.OutputTail)
 
 	outputJSON := mustMarshalJSON(a.OutputTail)
+	defer func() {
+		s.byAgent.invalidate(a.AgentID)
+		s.list.invalidate()
+	}()
 	rows, err := s.pool.Query(ctx,
 		`INSERT INTO agent_status (agent_id, agent_name, session_id, status, stagnant_sec, error, output_tail, created_at, updated_at)
 		 VALUES ($1, $2, $3, $4, $5, $6, $7::jsonb, NOW(), NOW())
@@ -80,21 +106,25 @@ func (s *AgentStatusStore) Upsert(ctx context.Context, a *AgentStatus) (*AgentSt
 
 // Get 按 agent_id 查询。
 func (s *AgentStatusStore) Get(ctx context.Context, agentID string) (*AgentStatus, error) {
-	rows, err := s.pool.Query(ctx,
-		"SELECT "+asCols+" FROM agent_status WHERE agent_id = $1", agentID)
-	if err != nil {
-		return nil, err
-	}
-	return collectOne[AgentStatus](rows)
+	return s.byAgent.load(ctx, agentID, func(ctx context.Context) (*AgentStatus, error) {
+		rows, err := s.pool.Query(ctx,
+			"SELECT "+asCols+" FROM agent_status WHERE agent_id = $1", agentID)
+		if err != nil {
+			return nil, err
+		}
+		return collectOne[AgentStatus](rows)
+	})
 }
 
 // List 查询 Agent 状态 (支持 status 过滤, 对应 Python query_agent_status)。
 func (s *AgentStatusStore) List(ctx context.Context, status string) ([]AgentStatus, error) {
-	q := NewQueryBuilder().Eq("status", status)
-	sql, params := q.Build("SELECT "+asCols+" FROM agent_status", "updated_at DESC", 500)
-	rows, err := s.pool.Query(ctx, sql, params...)
-	if err != nil {
-		return nil, err
-	}
-	return collectRows[AgentStatus](rows)
+	return s.list.load(ctx, status, func(ctx context.Context) ([]AgentStatus, error) {
+		q := NewQueryBuilder().Eq("status", status)
+		sql, params := q.Build("SELECT "+asCols+" FROM agent_status", "updated_at DESC", 500)
+		rows, err := s.pool.Query(ctx, sql, params...)
+		if err != nil {
+			return nil, err
+		}
+		return collectRows[AgentStatus](rows)
+	})
 }
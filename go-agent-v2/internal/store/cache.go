@@ -0,0 +1,146 @@
+// cache.go — Store 层进程内读缓存 (热路径查询减少 Postgres 往返)。
+//
+// 语义:
+//   - TTL ≤ 0 时禁用 (默认), 所有读取直接查库;
+//   - 本进程内的写操作立即失效对应键, 其他进程的写入最多延迟 TTL 可见;
+//   - 失效时递增代数, 与失效并发的在途查询结果不会回填, 避免写后读到旧值;
+//   - 返回值经 clone 复制, 调用方修改不影响缓存。
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// CacheStats 单个缓存的命中统计 (cache/stats 调试 RPC)。
+type CacheStats struct {
+	Name          string `json:"name"`
+	TTLMS         int64  `json:"ttlMs"`
+	Entries       int    `json:"entries"`
+	Hits          uint64 `json:"hits"`
+	Misses        uint64 `json:"misses"`
+	Invalidations uint64 `json:"invalidations"`
+}
+
+type cacheEntry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+// readCache 带 TTL 的键值读缓存 (nil 接收者安全, 等价于禁用)。
+type readCache[V any] struct {
+	name  string
+	clone func(V) V
+
+	mu            sync.Mutex
+	ttl           time.Duration
+	gen           uint64
+	entries       map[string]cacheEntry[V]
+	hits          uint64
+	misses        uint64
+	invalidations uint64
+}
+
+func newReadCache[V any](name string, clone func(V) V) *readCache[V] {
+	return &readCache[V]{name: name, clone: clone, entries: make(map[string]cacheEntry[V])}
+}
+
+func (c *readCache[V]) setTTL(ttl time.Duration) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttl = ttl
+	c.gen++
+	clear(c.entries)
+}
+
+// load 命中时返回缓存副本, 否则调用 fetch 并在代数未变时回填。
+func (c *readCache[V]) load(ctx context.Context, key string, fetch func(context.Context) (V, error)) (V, error) {
+	if c == nil {
+		return fetch(ctx)
+	}
+	c.mu.Lock()
+	if c.ttl <= 0 {
+		c.mu.Unlock()
+		return fetch(ctx)
+	}
+	now := time.Now()
+	if e, ok := c.entries[key]; ok && now.Before(e.expiresAt) {
+		c.hits++
+		c.mu.Unlock()
+		return c.clone(e.value), nil
+	}
+	c.misses++
+	gen := c.gen
+	c.mu.Unlock()
+
+	value, err := fetch(ctx)
+	if err != nil {
+		return value, err
+	}
+	c.mu.Lock()
+	if c.gen == gen && c.ttl > 0 {
+		c.entries[key] = cacheEntry[V]{value: c.clone(value), expiresAt: time.Now().Add(c.ttl)}
+	}
+	c.mu.Unlock()
+	return value, nil
+}
+
+// invalidate 失效指定键; 不传键时清空全部。
+func (c *readCache[V]) invalidate(keys ...string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	c.invalidations++
+	if len(keys) == 0 {
+		clear(c.entries)
+		return
+	}
+	for _, key := range keys {
+		delete(c.entries, key)
+	}
+}
+
+func (c *readCache[V]) stats() CacheStats {
+	if c == nil {
+		return CacheStats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	live := 0
+	for _, e := range c.entries {
+		if now.Before(e.expiresAt) {
+			live++
+		}
+	}
+	return CacheStats{
+		Name:          c.name,
+		TTLMS:         c.ttl.Milliseconds(),
+		Entries:       live,
+		Hits:          c.hits,
+		Misses:        c.misses,
+		Invalidations: c.invalidations,
+	}
+}
+
+func clonePtr[T any](v *T) *T {
+	if v == nil {
+		return nil
+	}
+	cp := *v
+	return &cp
+}
+
+func cloneSlice[T any](v []T) []T {
+	if v == nil {
+		return nil
+	}
+	return append([]T(nil), v...)
+}
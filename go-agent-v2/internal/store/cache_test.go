@@ -0,0 +1,80 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReadCacheHitAndInvalidate(t *testing.T) {
+	c := newReadCache("test", clonePtr[AgentStatus])
+	c.setTTL(time.Minute)
+	calls := 0
+	fetch := func(context.Context) (*AgentStatus, error) {
+		calls++
+		return &AgentStatus{AgentID: "a1", Status: "idle"}, nil
+	}
+	ctx := context.Background()
+	first, _ := c.load(ctx, "a1", fetch)
+	second, _ := c.load(ctx, "a1", fetch)
+	if calls != 1 || second.Status != "idle" {
+		t.Fatalf("calls = %d, second = %+v", calls, second)
+	}
+	second.Status = "mutated"
+	if third, _ := c.load(ctx, "a1", fetch); third.Status != "idle" || first.Status != "idle" {
+		t.Fatal("cached value must be isolated from callers")
+	}
+
+	c.invalidate("a1")
+	_, _ = c.load(ctx, "a1", fetch)
+	if calls != 2 {
+		t.Fatalf("calls after invalidate = %d, want 2", calls)
+	}
+	st := c.stats()
+	if st.Hits != 2 || st.Misses != 2 || st.Invalidations != 1 || st.Entries != 1 || st.TTLMS != 60000 {
+		t.Fatalf("stats = %+v", st)
+	}
+}
+
+func TestReadCacheDisabledAndErrors(t *testing.T) {
+	c := newReadCache("test", cloneSlice[AgentStatus])
+	calls := 0
+	fetch := func(context.Context) ([]AgentStatus, error) {
+		calls++
+		return nil, errors.New("db down")
+	}
+	ctx := context.Background()
+	_, _ = c.load(ctx, "", fetch)
+	_, _ = c.load(ctx, "", fetch)
+	if calls != 2 {
+		t.Fatalf("disabled cache must not memoize, calls = %d", calls)
+	}
+	c.setTTL(time.Minute)
+	_, _ = c.load(ctx, "", fetch)
+	_, _ = c.load(ctx, "", fetch)
+	if calls != 4 {
+		t.Fatalf("errors must not be cached, calls = %d", calls)
+	}
+
+	var nilCache *readCache[int]
+	if v, err := nilCache.load(ctx, "k", func(context.Context) (int, error) { return 7, nil }); v != 7 || err != nil {
+		t.Fatalf("nil cache load = %d, %v", v, err)
+	}
+}
+
+func TestReadCacheSkipsFillRacingInvalidate(t *testing.T) {
+	c := newReadCache("test", clonePtr[AgentCodexBinding])
+	c.setTTL(time.Minute)
+	ctx := context.Background()
+	_, _ = c.load(ctx, "a1", func(context.Context) (*AgentCodexBinding, error) {
+		c.invalidate("a1") // 查询期间发生写入
+		return &AgentCodexBinding{AgentID: "a1", CodexThreadID: "stale"}, nil
+	})
+	got, _ := c.load(ctx, "a1", func(context.Context) (*AgentCodexBinding, error) {
+		return &AgentCodexBinding{AgentID: "a1", CodexThreadID: "fresh"}, nil
+	})
+	if got.CodexThreadID != "fresh" {
+		t.Fatalf("got %q, stale fill must be dropped", got.CodexThreadID)
+	}
+}
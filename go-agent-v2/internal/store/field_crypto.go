@@ -0,0 +1,320 @@
+// field_crypto.go — 敏感列静态加密 (AES-256-GCM, 对调用方透明)。
+//
+// 启用后以下列写入前加密、读取后解密:
+//   - agent_interactions.payload  (jsonb)
+//   - shared_files.content        (text)
+//   - audit_events.detail / extra (text / jsonb)
+//
+// text 列存 "enc:v1:<keyID>:<base64(nonce‖密文)>"; jsonb 列存 {"$enc": "<同上>"} 以保持合法 JSON。
+// 附加认证数据为 "表.列", 密文不能被挪到其他列。密钥带 ID, 多把密钥并存时第一把用于加密,
+// 其余仅用于解密旧数据; 轮换时把新密钥放在首位, 再执行 migrate rotate-keys 重写旧密文 (见 field_rotation.go)。
+// 未加密的历史数据照常读取。加密列无法再按内容模糊搜索 (审计日志 keyword 只命中明文列)。
+package store
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+const (
+	fieldCipherPrefix  = "enc:v1:"
+	fieldEnvelopeKey   = "$enc"
+	fieldKeySize       = 32
+	fieldKeyIDMaxBytes = 32
+)
+
+// 加密列 (附加认证数据)。
+const (
+	columnInteractionPayload = "agent_interactions.payload"
+	columnSharedFileContent  = "shared_files.content"
+	columnAuditDetail        = "audit_events.detail"
+	columnAuditExtra         = "audit_events.extra"
+)
+
+// ErrFieldKeyUnavailable 读取到加密数据但未配置对应密钥。
+var ErrFieldKeyUnavailable = errors.New("store: encrypted field but decryption key is not configured")
+
+var fieldKeyIDRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// FieldKey 一把列加密密钥。
+type FieldKey struct {
+	ID  string
+	Key []byte // 32 字节 (AES-256)
+}
+
+// ParseFieldKeys 解析 "keyID:base64密钥[,keyID:base64密钥...]" (第一把为当前加密密钥)。
+func ParseFieldKeys(spec string) ([]FieldKey, error) {
+	const op = "store.ParseFieldKeys"
+	var keys []FieldKey
+	seen := make(map[string]bool)
+	for _, part := range strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == '\n' }) {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(part, ":")
+		id = strings.TrimSpace(id)
+		if !ok || id == "" || len(id) > fieldKeyIDMaxBytes || !fieldKeyIDRe.MatchString(id) {
+			return nil, apperrors.Newf(op, "invalid key entry %q (want keyID:base64key, keyID [A-Za-z0-9_-]{1,%d})", redactKeyEntry(part), fieldKeyIDMaxBytes)
+		}
+		if seen[id] {
+			return nil, apperrors.Newf(op, "duplicate key id %q", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, apperrors.Wrapf(err, op, "decode key %q", id)
+		}
+		if len(key) != fieldKeySize {
+			return nil, apperrors.Newf(op, "key %q must be %d bytes, got %d", id, fieldKeySize, len(key))
+		}
+		seen[id] = true
+		keys = append(keys, FieldKey{ID: id, Key: key})
+	}
+	return keys, nil
+}
+
+// redactKeyEntry 错误信息中只保留密钥 ID。
+func redactKeyEntry(entry string) string {
+	if id, _, ok := strings.Cut(entry, ":"); ok {
+		return id + ":***"
+	}
+	return "***"
+}
+
+// FieldCipher 多密钥 AES-GCM 列加密器。
+type FieldCipher struct {
+	active string
+	aeads  map[string]cipher.AEAD
+}
+
+// NewFieldCipher 创建加密器, keys[0] 用于加密。
+func NewFieldCipher(keys []FieldKey) (*FieldCipher, error) {
+	const op = "store.NewFieldCipher"
+	if len(keys) == 0 {
+		return nil, apperrors.New(op, "at least one key is required")
+	}
+	c := &FieldCipher{active: keys[0].ID, aeads: make(map[string]cipher.AEAD, len(keys))}
+	for _, key := range keys {
+		block, err := aes.NewCipher(key.Key)
+		if err != nil {
+			return nil, apperrors.Wrapf(err, op, "key %q", key.ID)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, apperrors.Wrapf(err, op, "key %q", key.ID)
+		}
+		c.aeads[key.ID] = aead
+	}
+	return c, nil
+}
+
+// ActiveKeyID 当前加密密钥 ID。
+func (c *FieldCipher) ActiveKeyID() string { return c.active }
+
+// Encrypt 用当前密钥加密。
+func (c *FieldCipher) Encrypt(column string, plaintext []byte) (string, error) {
+	aead := c.aeads[c.active]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", apperrors.Wrap(err, "FieldCipher.Encrypt", "generate nonce")
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, []byte(column))
+	return fieldCipherPrefix + c.active + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt 按密文中的密钥 ID 解密。
+func (c *FieldCipher) Decrypt(column, value string) ([]byte, error) {
+	const op = "FieldCipher.Decrypt"
+	keyID, encoded, ok := splitFieldCiphertext(value)
+	if !ok {
+		return nil, apperrors.New(op, "malformed ciphertext")
+	}
+	aead, ok := c.aeads[keyID]
+	if !ok {
+		return nil, apperrors.Wrapf(ErrFieldKeyUnavailable, op, "key %q", keyID)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return nil, apperrors.New(op, "malformed ciphertext")
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(column))
+	if err != nil {
+		return nil, apperrors.Wrapf(err, op, "authenticate %s", column)
+	}
+	return plaintext, nil
+}
+
+// splitFieldCiphertext 拆出密钥 ID 与 base64 部分。
+func splitFieldCiphertext(value string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(value, fieldCipherPrefix)
+	if !ok {
+		return "", "", false
+	}
+	return strings.Cut(rest, ":")
+}
+
+// ========================================
+// 进程级加密器 (所有 store 共用)
+// ========================================
+
+var fieldCipher atomic.Pointer[FieldCipher]
+
+// SetFieldCipher 设置进程级加密器 (nil = 关闭加密, 已加密数据仍需密钥才能读取)。
+func SetFieldCipher(c *FieldCipher) { fieldCipher.Store(c) }
+
+// FieldEncryptionEnabled 是否启用列加密。
+func FieldEncryptionEnabled() bool { return fieldCipher.Load() != nil }
+
+// sealText 加密 text 列 (未启用时原样返回)。
+func sealText(column, value string) (string, error) {
+	c := fieldCipher.Load()
+	if c == nil {
+		return value, nil
+	}
+	return c.Encrypt(column, []byte(value))
+}
+
+// openText 解密 text 列 (明文原样返回)。
+func openText(column, value string) (string, error) {
+	if !strings.HasPrefix(value, fieldCipherPrefix) {
+		return value, nil
+	}
+	c := fieldCipher.Load()
+	if c == nil {
+		return "", apperrors.Wrap(ErrFieldKeyUnavailable, "store.openText", column)
+	}
+	plaintext, err := c.Decrypt(column, value)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// sealJSON 序列化 jsonb 列 (启用加密时包装为 {"$enc": 密文})。
+func sealJSON(column string, value any) (string, error) {
+	data := mustMarshalJSON(value)
+	c := fieldCipher.Load()
+	if c == nil {
+		return string(data), nil
+	}
+	sealed, err := c.Encrypt(column, data)
+	if err != nil {
+		return "", err
+	}
+	return string(mustMarshalJSON(map[string]string{fieldEnvelopeKey: sealed})), nil
+}
+
+// jsonEnvelope 返回 jsonb 值中的密文 (非加密包装返回 false)。
+func jsonEnvelope(value any) (string, bool) {
+	m, ok := value.(map[string]any)
+	if !ok || len(m) != 1 {
+		return "", false
+	}
+	sealed, ok := m[fieldEnvelopeKey].(string)
+	return sealed, ok && strings.HasPrefix(sealed, fieldCipherPrefix)
+}
+
+// openJSON 解密 pgx 解码后的 jsonb 值 (非加密包装原样返回)。
+func openJSON(column string, value any) (any, error) {
+	sealed, ok := jsonEnvelope(value)
+	if !ok {
+		return value, nil
+	}
+	c := fieldCipher.Load()
+	if c == nil {
+		return nil, apperrors.Wrap(ErrFieldKeyUnavailable, "store.openJSON", column)
+	}
+	data, err := c.Decrypt(column, sealed)
+	if err != nil {
+		return nil, err
+	}
+	var out any
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, apperrors.Wrapf(err, "store.openJSON", "decode %s", column)
+	}
+	return out, nil
+}
+
+// ========================================
+// 行解密
+// ========================================
+
+func (i *Interaction) decryptFields() error {
+	payload, err := openJSON(columnInteractionPayload, i.Payload)
+	if err != nil {
+		return err
+	}
+	i.Payload = payload
+	return nil
+}
+
+func (f *SharedFile) decryptFields() error {
+	content, err := openText(columnSharedFileContent, f.Content)
+	if err != nil {
+		return err
+	}
+	f.Content = content
+	return nil
+}
+
+func (e *AuditEvent) decryptFields() error {
+	detail, err := openText(columnAuditDetail, e.Detail)
+	if err != nil {
+		return err
+	}
+	extra, err := openJSON(columnAuditExtra, e.Extra)
+	if err != nil {
+		return err
+	}
+	e.Detail, e.Extra = detail, extra
+	return nil
+}
+
+// decryptAll 逐行解密; 无法解密的行 (密钥已移除、密文损坏) 跳过并告警, 不让单行拖垮整个列表。
+func decryptAll[T any, P interface {
+	*T
+	decryptFields() error
+}](items []T, err error) ([]T, error) {
+	if err != nil {
+		return nil, err
+	}
+	out := items[:0]
+	var firstErr error
+	for i := range items {
+		if err := P(&items[i]).decryptFields(); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		out = append(out, items[i])
+	}
+	if skipped := len(items) - len(out); skipped > 0 {
+		logger.Warn("store: skipped rows that cannot be decrypted", logger.FieldCount, skipped, logger.FieldError, firstErr)
+	}
+	return out, nil
+}
+
+// decryptOne 解密单行 (nil 原样返回)。
+func decryptOne[T any, P interface {
+	*T
+	decryptFields() error
+}](item *T, err error) (*T, error) {
+	if err != nil || item == nil {
+		return item, err
+	}
+	if err := P(item).decryptFields(); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
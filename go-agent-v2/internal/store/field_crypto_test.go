@@ -0,0 +1,168 @@
+package store
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testFieldKeySpec(ids ...string) string {
+	parts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		// 同一 ID 始终得到同一密钥, 便于模拟轮换前后的密钥集合。
+		parts = append(parts, id+":"+base64.StdEncoding.EncodeToString(bytes.Repeat([]byte(id[len(id)-1:]), fieldKeySize)))
+	}
+	return strings.Join(parts, ",")
+}
+
+func mustFieldCipher(t *testing.T, spec string) *FieldCipher {
+	t.Helper()
+	keys, err := ParseFieldKeys(spec)
+	if err != nil {
+		t.Fatalf("ParseFieldKeys: %v", err)
+	}
+	c, err := NewFieldCipher(keys)
+	if err != nil {
+		t.Fatalf("NewFieldCipher: %v", err)
+	}
+	return c
+}
+
+func TestParseFieldKeys_Validation(t *testing.T) {
+	keys, err := ParseFieldKeys(testFieldKeySpec("k2", "k1"))
+	if err != nil || len(keys) != 2 || keys[0].ID != "k2" {
+		t.Fatalf("keys = %+v, err = %v", keys, err)
+	}
+	for _, spec := range []string{
+		"nokey",
+		"k1:" + base64.StdEncoding.EncodeToString([]byte("short")),
+		"bad id:" + base64.StdEncoding.EncodeToString(make([]byte, fieldKeySize)),
+		testFieldKeySpec("k1") + "," + testFieldKeySpec("k1"),
+	} {
+		if _, err := ParseFieldKeys(spec); err == nil {
+			t.Errorf("ParseFieldKeys(%q) expected error", spec)
+		} else if strings.Contains(err.Error(), base64.StdEncoding.EncodeToString(make([]byte, fieldKeySize))) {
+			t.Errorf("error leaks key material: %v", err)
+		}
+	}
+}
+
+func TestFieldCipher_TransparentRoundTrip(t *testing.T) {
+	SetFieldCipher(mustFieldCipher(t, testFieldKeySpec("k1")))
+	t.Cleanup(func() { SetFieldCipher(nil) })
+
+	sealed, err := sealText(columnSharedFileContent, "secret notes")
+	if err != nil || !strings.HasPrefix(sealed, "enc:v1:k1:") {
+		t.Fatalf("sealText = %q, %v", sealed, err)
+	}
+	file := SharedFile{Content: sealed}
+	if err := file.decryptFields(); err != nil || file.Content != "secret notes" {
+		t.Fatalf("decrypt shared file = %q, %v", file.Content, err)
+	}
+
+	// 密文绑定列: 挪到其他列无法解密。
+	if _, err := openText(columnAuditDetail, sealed); err == nil {
+		t.Fatal("ciphertext must not decrypt under another column")
+	}
+
+	raw, err := sealJSON(columnAuditExtra, map[string]any{"token": "abc"})
+	if err != nil {
+		t.Fatalf("sealJSON: %v", err)
+	}
+	var stored any
+	if err := json.Unmarshal([]byte(raw), &stored); err != nil {
+		t.Fatalf("sealed json must stay valid JSON: %v", err)
+	}
+	detail, _ := sealText(columnAuditDetail, "rm -rf")
+	event := AuditEvent{Detail: detail, Extra: stored}
+	if err := event.decryptFields(); err != nil {
+		t.Fatalf("decrypt audit event: %v", err)
+	}
+	if event.Detail != "rm -rf" || event.Extra.(map[string]any)["token"] != "abc" {
+		t.Fatalf("audit event = %+v", event)
+	}
+
+	// 未加密的历史数据照常读取。
+	legacy := Interaction{Payload: map[string]any{"text": "hello"}}
+	if err := legacy.decryptFields(); err != nil || legacy.Payload.(map[string]any)["text"] != "hello" {
+		t.Fatalf("legacy payload = %+v, %v", legacy.Payload, err)
+	}
+
+	SetFieldCipher(nil)
+	if err := (&SharedFile{Content: sealed}).decryptFields(); !errors.Is(err, ErrFieldKeyUnavailable) {
+		t.Fatalf("missing key err = %v, want ErrFieldKeyUnavailable", err)
+	}
+}
+
+// 列表中个别行的密钥已移除时跳过该行, 其余行照常返回。
+func TestDecryptAll_SkipsUndecryptableRows(t *testing.T) {
+	retired := mustFieldCipher(t, testFieldKeySpec("old"))
+	stale := mustEncrypt(t, retired, columnSharedFileContent, "stale")
+	current := mustFieldCipher(t, testFieldKeySpec("new"))
+	SetFieldCipher(current)
+	t.Cleanup(func() { SetFieldCipher(nil) })
+	fresh := mustEncrypt(t, current, columnSharedFileContent, "fresh")
+
+	files, err := decryptAll([]SharedFile{
+		{Path: "a", Content: "plain"},
+		{Path: "b", Content: stale},
+		{Path: "c", Content: fresh},
+	}, nil)
+	if err != nil {
+		t.Fatalf("decryptAll: %v", err)
+	}
+	if len(files) != 2 || files[0].Content != "plain" || files[1].Path != "c" || files[1].Content != "fresh" {
+		t.Fatalf("decryptAll = %+v", files)
+	}
+	if _, err := decryptOne(&SharedFile{Path: "b", Content: stale}, nil); !errors.Is(err, ErrFieldKeyUnavailable) {
+		t.Fatalf("decryptOne stale err = %v, want ErrFieldKeyUnavailable", err)
+	}
+}
+
+func TestReseal_RotatesToActiveKey(t *testing.T) {
+	oldCipher := mustFieldCipher(t, testFieldKeySpec("k1"))
+	rotated := mustFieldCipher(t, testFieldKeySpec("k2", "k1"))
+
+	oldText, _ := oldCipher.Encrypt(columnSharedFileContent, []byte("v1 content"))
+	text, changed, err := resealText(rotated, columnSharedFileContent, oldText)
+	if err != nil || !changed || !strings.HasPrefix(text, "enc:v1:k2:") {
+		t.Fatalf("resealText = %q changed=%v err=%v", text, changed, err)
+	}
+	if _, changed, _ := resealText(rotated, columnSharedFileContent, text); changed {
+		t.Fatal("already rotated value should be left alone")
+	}
+	plain, err := rotated.Decrypt(columnSharedFileContent, text)
+	if err != nil || string(plain) != "v1 content" {
+		t.Fatalf("decrypt rotated = %q, %v", plain, err)
+	}
+
+	// 明文 jsonb 与旧密钥包装都重写为新密钥包装。
+	for _, raw := range []string{`{"a":1}`, string(mustMarshalJSON(map[string]string{fieldEnvelopeKey: mustEncrypt(t, oldCipher, columnInteractionPayload, `{"a":1}`)}))} {
+		out, changed, err := resealJSON(rotated, columnInteractionPayload, raw)
+		if err != nil || !changed {
+			t.Fatalf("resealJSON(%s) changed=%v err=%v", raw, changed, err)
+		}
+		var value any
+		_ = json.Unmarshal([]byte(out), &value)
+		sealed, ok := jsonEnvelope(value)
+		if !ok || !strings.HasPrefix(sealed, "enc:v1:k2:") {
+			t.Fatalf("resealJSON output = %s", out)
+		}
+		data, err := rotated.Decrypt(columnInteractionPayload, sealed)
+		if err != nil || string(data) != `{"a":1}` {
+			t.Fatalf("decrypt resealed json = %s, %v", data, err)
+		}
+	}
+}
+
+func mustEncrypt(t *testing.T, c *FieldCipher, column, plaintext string) string {
+	t.Helper()
+	sealed, err := c.Encrypt(column, []byte(plaintext))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sealed
+}
@@ -0,0 +1,346 @@
+// field_rotation.go — 列加密密钥来源与轮换 (migrate rotate-keys)。
+//
+// 密钥来源优先级: STORE_ENCRYPTION_KEYS 环境变量 > 系统钥匙串 (STORE_ENCRYPTION_KEYCHAIN_SERVICE,
+// macOS security / Linux secret-tool, 条目内容与环境变量格式相同)。
+//
+// 轮换: 把新密钥放在首位、旧密钥保留在后面重启服务 (新写入即用新密钥), 再执行 RotateFieldEncryption
+// 分批把明文与旧密钥密文重写为新密钥密文; 完成后即可移除旧密钥。重写不修改 updated_at。
+// 可在服务运行时执行: 每行以读取时的旧值做比较后写入 (compare-and-set), 期间被服务改写的行
+// 计入 skipped 不覆盖; skipped 非零时再执行一次即可补齐。
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+// defaultRotationBatch 轮换每批处理行数。
+const defaultRotationBatch = 500
+
+// ConfigureFieldEncryption 按配置启用进程级列加密 (两者均为空时关闭), 返回密钥来源 ("" / env / keychain)。
+func ConfigureFieldEncryption(keySpec, keychainService string) (string, error) {
+	const op = "store.ConfigureFieldEncryption"
+	source := ""
+	spec := strings.TrimSpace(keySpec)
+	switch {
+	case spec != "":
+		source = "env"
+	case strings.TrimSpace(keychainService) != "":
+		secret, err := readKeychainSecret(strings.TrimSpace(keychainService))
+		if err != nil {
+			return "", apperrors.Wrap(err, op, "read keychain")
+		}
+		spec, source = secret, "keychain"
+	default:
+		SetFieldCipher(nil)
+		return "", nil
+	}
+	keys, err := ParseFieldKeys(spec)
+	if err != nil {
+		return "", err
+	}
+	c, err := NewFieldCipher(keys)
+	if err != nil {
+		return "", err
+	}
+	SetFieldCipher(c)
+	logger.Info("store: field encryption enabled", logger.FieldSource, source, "active_key", c.ActiveKeyID(), logger.FieldCount, len(keys))
+	return source, nil
+}
+
+// readKeychainSecret 从系统钥匙串读取 service 对应的密钥串。
+func readKeychainSecret(service string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		cmd = exec.Command("security", "find-generic-password", "-s", service, "-w")
+	} else {
+		cmd = exec.Command("secret-tool", "lookup", "service", service)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return "", apperrors.Wrapf(err, "store.readKeychainSecret", "%s lookup %q", cmd.Path, service)
+	}
+	secret := strings.TrimSpace(string(out))
+	if secret == "" {
+		return "", apperrors.Newf("store.readKeychainSecret", "keychain entry %q is empty", service)
+	}
+	return secret, nil
+}
+
+// FieldRotationOptions 轮换选项。
+type FieldRotationOptions struct {
+	BatchSize int  // ≤ 0 = 500
+	DryRun    bool // 只统计需要重写的行
+}
+
+// FieldRotationResult 单表轮换结果。
+type FieldRotationResult struct {
+	Table     string `json:"table"`
+	Scanned   int    `json:"scanned"`
+	Rewritten int    `json:"rewritten"` // DryRun 时为需要重写的行数
+	Skipped   int    `json:"skipped"`   // 读取后被并发修改而未重写的行 (再次执行时处理)
+}
+
+// countRotationWrite 按 compare-and-set UPDATE 的影响行数计入 rewritten / skipped。
+func (r *FieldRotationResult) countRotationWrite(tag pgconn.CommandTag) {
+	if tag.RowsAffected() == 0 {
+		r.Skipped++
+		return
+	}
+	r.Rewritten++
+}
+
+// RotateFieldEncryption 把加密列全部重写为当前密钥密文 (需已启用列加密)。
+func RotateFieldEncryption(ctx context.Context, pool *pgxpool.Pool, opts FieldRotationOptions) ([]FieldRotationResult, error) {
+	const op = "store.RotateFieldEncryption"
+	c := fieldCipher.Load()
+	if c == nil {
+		return nil, apperrors.New(op, "field encryption is not configured (STORE_ENCRYPTION_KEYS)")
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultRotationBatch
+	}
+	rotators := []func(context.Context, *pgxpool.Pool, *FieldCipher, FieldRotationOptions) (FieldRotationResult, error){
+		rotateInteractions,
+		rotateSharedFiles,
+		rotateAuditEvents,
+	}
+	results := make([]FieldRotationResult, 0, len(rotators))
+	for _, rotate := range rotators {
+		res, err := rotate(ctx, pool, c, opts)
+		results = append(results, res)
+		if err != nil {
+			return results, apperrors.Wrapf(err, op, "rotate %s", res.Table)
+		}
+		logger.Info("store: field encryption rotated", "table", res.Table, "scanned", res.Scanned, "rewritten", res.Rewritten, "skipped", res.Skipped, "dry_run", opts.DryRun)
+	}
+	return results, nil
+}
+
+func rotateInteractions(ctx context.Context, pool *pgxpool.Pool, c *FieldCipher, opts FieldRotationOptions) (FieldRotationResult, error) {
+	res := FieldRotationResult{Table: "agent_interactions"}
+	lastID := 0
+	for {
+		rows, err := pool.Query(ctx,
+			"SELECT id, payload::text FROM agent_interactions WHERE id > $1 ORDER BY id LIMIT $2", lastID, opts.BatchSize)
+		if err != nil {
+			return res, err
+		}
+		type row struct {
+			id      int
+			payload *string
+		}
+		var batch []row
+		for rows.Next() {
+			var r row
+			if err := rows.Scan(&r.id, &r.payload); err != nil {
+				rows.Close()
+				return res, err
+			}
+			batch = append(batch, r)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return res, err
+		}
+		for _, r := range batch {
+			lastID = r.id
+			res.Scanned++
+			if r.payload == nil {
+				continue
+			}
+			sealed, changed, err := resealJSON(c, columnInteractionPayload, *r.payload)
+			if err != nil {
+				return res, apperrors.Wrapf(err, "store.rotateInteractions", "row %d", r.id)
+			}
+			if !changed {
+				continue
+			}
+			if opts.DryRun {
+				res.Rewritten++
+				continue
+			}
+			tag, err := pool.Exec(ctx,
+				"UPDATE agent_interactions SET payload = $1::jsonb WHERE id = $2 AND payload = $3::jsonb", sealed, r.id, *r.payload)
+			if err != nil {
+				return res, err
+			}
+			res.countRotationWrite(tag)
+		}
+		if len(batch) < opts.BatchSize {
+			return res, nil
+		}
+	}
+}
+
+func rotateSharedFiles(ctx context.Context, pool *pgxpool.Pool, c *FieldCipher, opts FieldRotationOptions) (FieldRotationResult, error) {
+	res := FieldRotationResult{Table: "shared_files"}
+	lastPath := ""
+	for {
+		rows, err := pool.Query(ctx,
+			"SELECT path, content FROM shared_files WHERE path > $1 ORDER BY path LIMIT $2", lastPath, opts.BatchSize)
+		if err != nil {
+			return res, err
+		}
+		type row struct {
+			path    string
+			content *string
+		}
+		var batch []row
+		for rows.Next() {
+			var r row
+			if err := rows.Scan(&r.path, &r.content); err != nil {
+				rows.Close()
+				return res, err
+			}
+			batch = append(batch, r)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return res, err
+		}
+		for _, r := range batch {
+			lastPath = r.path
+			res.Scanned++
+			if r.content == nil {
+				continue
+			}
+			sealed, changed, err := resealText(c, columnSharedFileContent, *r.content)
+			if err != nil {
+				return res, apperrors.Wrapf(err, "store.rotateSharedFiles", "path %s", r.path)
+			}
+			if !changed {
+				continue
+			}
+			if opts.DryRun {
+				res.Rewritten++
+				continue
+			}
+			tag, err := pool.Exec(ctx,
+				"UPDATE shared_files SET content = $1 WHERE path = $2 AND content = $3", sealed, r.path, *r.content)
+			if err != nil {
+				return res, err
+			}
+			res.countRotationWrite(tag)
+		}
+		if len(batch) < opts.BatchSize {
+			return res, nil
+		}
+	}
+}
+
+func rotateAuditEvents(ctx context.Context, pool *pgxpool.Pool, c *FieldCipher, opts FieldRotationOptions) (FieldRotationResult, error) {
+	res := FieldRotationResult{Table: "audit_events"}
+	var lastID int64
+	for {
+		rows, err := pool.Query(ctx,
+			"SELECT id, detail, extra::text FROM audit_events WHERE id > $1 ORDER BY id LIMIT $2", lastID, opts.BatchSize)
+		if err != nil {
+			return res, err
+		}
+		type row struct {
+			id     int64
+			detail *string
+			extra  *string
+		}
+		var batch []row
+		for rows.Next() {
+			var r row
+			if err := rows.Scan(&r.id, &r.detail, &r.extra); err != nil {
+				rows.Close()
+				return res, err
+			}
+			batch = append(batch, r)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return res, err
+		}
+		for _, r := range batch {
+			lastID = r.id
+			res.Scanned++
+			detail, extra := r.detail, r.extra
+			var detailChanged, extraChanged bool
+			if detail != nil {
+				sealed, changed, err := resealText(c, columnAuditDetail, *detail)
+				if err != nil {
+					return res, apperrors.Wrapf(err, "store.rotateAuditEvents", "row %d detail", r.id)
+				}
+				detail, detailChanged = &sealed, changed
+			}
+			if extra != nil {
+				sealed, changed, err := resealJSON(c, columnAuditExtra, *extra)
+				if err != nil {
+					return res, apperrors.Wrapf(err, "store.rotateAuditEvents", "row %d extra", r.id)
+				}
+				extra, extraChanged = &sealed, changed
+			}
+			if !detailChanged && !extraChanged {
+				continue
+			}
+			if opts.DryRun {
+				res.Rewritten++
+				continue
+			}
+			tag, err := pool.Exec(ctx,
+				`UPDATE audit_events SET detail = $1, extra = $2::jsonb
+				 WHERE id = $3 AND detail IS NOT DISTINCT FROM $4 AND extra IS NOT DISTINCT FROM $5::jsonb`,
+				detail, extra, r.id, r.detail, r.extra)
+			if err != nil {
+				return res, err
+			}
+			res.countRotationWrite(tag)
+		}
+		if len(batch) < opts.BatchSize {
+			return res, nil
+		}
+	}
+}
+
+// resealText 把 text 列值重写为 c 当前密钥密文 (已是当前密钥时 changed = false)。
+func resealText(c *FieldCipher, column, value string) (string, bool, error) {
+	if strings.HasPrefix(value, fieldCipherPrefix+c.active+":") {
+		return value, false, nil
+	}
+	plaintext := []byte(value)
+	if strings.HasPrefix(value, fieldCipherPrefix) {
+		var err error
+		if plaintext, err = c.Decrypt(column, value); err != nil {
+			return "", false, err
+		}
+	}
+	sealed, err := c.Encrypt(column, plaintext)
+	return sealed, err == nil, err
+}
+
+// resealJSON 把 jsonb 列值 (JSON 文本) 重写为 c 当前密钥的加密包装。
+func resealJSON(c *FieldCipher, column, raw string) (string, bool, error) {
+	var value any
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		return "", false, err
+	}
+	plaintext := []byte(raw)
+	if sealed, ok := jsonEnvelope(value); ok {
+		if strings.HasPrefix(sealed, fieldCipherPrefix+c.active+":") {
+			return raw, false, nil
+		}
+		var err error
+		if plaintext, err = c.Decrypt(column, sealed); err != nil {
+			return "", false, err
+		}
+	}
+	sealed, err := c.Encrypt(column, plaintext)
+	if err != nil {
+		return "", false, err
+	}
+	return string(mustMarshalJSON(map[string]string{fieldEnvelopeKey: sealed})), true, nil
+}
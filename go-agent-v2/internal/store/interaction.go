@@ -25,18 +25,21 @@ const defaultInteractionStatus = "pending"
 
 // Create 创建交互记录 (对应 Python create_interaction)。
 func (s *InteractionStore) Create(ctx context.Context, i *Interaction) (*Interaction, error) {
-	payloadJSON := mustMarshalJSON(i.Payload)
+	payloadJSON, err := sealJSON(columnInteractionPayload, i.Payload)
+	if err != nil {
+		return nil, err
+	}
 	rows, err := s.pool.Query(ctx,
 		`INSERT INTO agent_interactions (thread_id, parent_id, sender, receiver, msg_type, status,
 		   requires_review, payload, updated_at)
 		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8::jsonb, NOW())
 		 RETURNING `+interactionCols,
 		i.ThreadID, i.ParentID, i.Sender, i.Receiver, i.MsgType,
-		defaultStr(i.Status, defaultInteractionStatus), i.RequiresReview, string(payloadJSON))
+		defaultStr(i.Status, defaultInteractionStatus), i.RequiresReview, payloadJSON)
 	if err != nil {
 		return nil, err
 	}
-	return collectOne[Interaction](rows)
+	return decryptOne(collectOne[Interaction](rows))
 }
 
 // Get 按 ID 查询。
@@ -46,7 +49,7 @@ func (s *InteractionStore) Get(ctx context.Context, id int) (*Interaction, error
 	if err != nil {
 		return nil, err
 	}
-	return collectOne[Interaction](rows)
+	return decryptOne(collectOne[Interaction](rows))
 }
 
 // List 列表查询 (支持 thread_id / sender / receiver / msg_type / status / keyword)。
@@ -61,7 +64,7 @@ func (s *InteractionStore) List(ctx context.Context, threadID, keyword string, l
 	if err != nil {
 		return nil, err
 	}
-	return collectRows[Interaction](rows)
+	return decryptAll(collectRows[Interaction](rows))
 }
 
 // Review 审批交互记录 (对应 Python review_interaction)。
@@ -75,7 +78,7 @@ func (s *InteractionStore) Review(ctx context.Context, id int, status, reviewer,
 	if err != nil {
 		return nil, err
 	}
-	return collectOne[Interaction](rows)
+	return decryptOne(collectOne[Interaction](rows))
 }
 
 // defaultStr 空字符串返回默认值。
@@ -0,0 +1,476 @@
+// log_query.go — 系统日志查询语言: 解析为语法树后编译为参数化 WHERE 子句 (log/query)。
+//
+// 语法 (关键字不区分大小写):
+//
+//	query   := or
+//	or      := and { OR and }
+//	and     := unary { [AND] unary }          相邻条件默认 AND
+//	unary   := NOT unary | "(" or ")" | field op value | value
+//	op      := = | != | ~ | !~ | > | >= | < | <=
+//	value   := 裸词 | "双引号" | '单引号'       引号内支持 \" \' \\ 转义
+//
+// 示例:
+//
+//	level>=warn AND (source=codex OR tool=shell) ts>=-1h message~"timeout|refused"
+//
+// 字段规则:
+//   - 文本字段 (logger/source/agent_id/...) 支持 = != ~ !~; ~ 为 PostgreSQL 正则 (先用 RE2 校验语法)。
+//   - level 支持全部比较符, 按 TRACE < DEBUG < INFO < WARN < ERROR < FATAL 排序 (WARNING/CRITICAL/PANIC 为别名)。
+//   - ts 支持 > >= < <=, 值为 RFC3339 / 2006-01-02[T15:04[:05]] / now / 相对时长 (-1h, 30m, 2d = 距今)。
+//   - duration_ms 与 id 支持数值比较; 单独的值按 message 子串 (不区分大小写) 匹配。
+//
+// 所有值均以 $N 参数传入, 列名只来自白名单, 不会拼接用户输入。
+package store
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/util"
+)
+
+const (
+	// MaxLogQueryLen 查询文本最大长度 (字节)。
+	MaxLogQueryLen   = 2000
+	maxLogQueryTerms = 64
+	maxLogQueryDepth = 16
+)
+
+// LogQuery 编译后的查询: Where 使用 $1..$N 占位符, 与 Args 一一对应。
+type LogQuery struct {
+	Where string
+	Args  []any
+}
+
+type logFieldKind int
+
+const (
+	logFieldText logFieldKind = iota
+	logFieldLevel
+	logFieldTime
+	logFieldInt
+)
+
+// logQueryFields 可查询字段 → (列名, 类型)。
+var logQueryFields = map[string]struct {
+	col  string
+	kind logFieldKind
+}{
+	"id":          {"id", logFieldInt},
+	"ts":          {"ts", logFieldTime},
+	"level":       {"level", logFieldLevel},
+	"logger":      {"logger", logFieldText},
+	"message":     {"message", logFieldText},
+	"raw":         {"raw", logFieldText},
+	"source":      {"source", logFieldText},
+	"component":   {"component", logFieldText},
+	"agent_id":    {"agent_id", logFieldText},
+	"thread_id":   {"thread_id", logFieldText},
+	"trace_id":    {"trace_id", logFieldText},
+	"event_type":  {"event_type", logFieldText},
+	"tool_name":   {"tool_name", logFieldText},
+	"duration_ms": {"duration_ms", logFieldInt},
+}
+
+// logQueryAliases 字段简写。
+var logQueryAliases = map[string]string{
+	"time": "ts", "msg": "message", "agent": "agent_id", "thread": "thread_id",
+	"trace": "trace_id", "event": "event_type", "tool": "tool_name", "duration": "duration_ms",
+}
+
+// logLevelRanks 日志级别排序 (别名与主名同级)。
+var logLevelRanks = map[string]int{
+	"TRACE": 0, "DEBUG": 1, "INFO": 2, "WARN": 3, "WARNING": 3,
+	"ERROR": 4, "FATAL": 5, "CRITICAL": 5, "PANIC": 5,
+}
+
+// ========================================
+// 词法
+// ========================================
+
+type logTokenKind int
+
+const (
+	logTokEOF logTokenKind = iota
+	logTokWord
+	logTokString
+	logTokOp
+	logTokLParen
+	logTokRParen
+)
+
+type logToken struct {
+	kind logTokenKind
+	text string
+	pos  int // 字节偏移, 用于报错
+}
+
+func isLogQueryOpChar(r rune) bool {
+	return r == '=' || r == '!' || r == '~' || r == '<' || r == '>'
+}
+
+func lexLogQuery(src string) ([]logToken, error) {
+	const op = "store.lexLogQuery"
+	var toks []logToken
+	runes := []rune(src)
+	offset := func(i int) int { return len(string(runes[:i])) }
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			toks = append(toks, logToken{logTokLParen, "(", offset(i)})
+			i++
+		case r == ')':
+			toks = append(toks, logToken{logTokRParen, ")", offset(i)})
+			i++
+		case r == '"' || r == '\'':
+			start := i
+			var b strings.Builder
+			i++
+			closed := false
+			for i < len(runes) {
+				c := runes[i]
+				if c == '\\' && i+1 < len(runes) {
+					b.WriteRune(runes[i+1])
+					i += 2
+					continue
+				}
+				i++
+				if c == r {
+					closed = true
+					break
+				}
+				b.WriteRune(c)
+			}
+			if !closed {
+				return nil, apperrors.Newf(op, "position %d: unterminated string", offset(start))
+			}
+			toks = append(toks, logToken{logTokString, b.String(), offset(start)})
+		case isLogQueryOpChar(r):
+			start := i
+			for i < len(runes) && isLogQueryOpChar(runes[i]) {
+				i++
+			}
+			text := string(runes[start:i])
+			switch text {
+			case "=", "!=", "~", "!~", ">", ">=", "<", "<=":
+			default:
+				return nil, apperrors.Newf(op, "position %d: unknown operator %q", offset(start), text)
+			}
+			toks = append(toks, logToken{logTokOp, text, offset(start)})
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && runes[i] != '(' && runes[i] != ')' &&
+				runes[i] != '"' && runes[i] != '\'' && !isLogQueryOpChar(runes[i]) {
+				i++
+			}
+			toks = append(toks, logToken{logTokWord, string(runes[start:i]), offset(start)})
+		}
+	}
+	return append(toks, logToken{logTokEOF, "", len(src)}), nil
+}
+
+// ========================================
+// 语法
+// ========================================
+
+// logQueryNode 语法树节点: op 为 AND/OR/NOT 时使用 children, 否则为比较 (field op value) 或裸值 (field 为空)。
+type logQueryNode struct {
+	op       string
+	children []*logQueryNode
+	field    string
+	value    string
+	pos      int
+}
+
+type logQueryParser struct {
+	toks  []logToken
+	i     int
+	terms int
+	depth int
+}
+
+func (p *logQueryParser) peek() logToken { return p.toks[p.i] }
+
+func (p *logQueryParser) next() logToken {
+	t := p.toks[p.i]
+	if t.kind != logTokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *logQueryParser) keyword(t logToken, kw string) bool {
+	return t.kind == logTokWord && strings.EqualFold(t.text, kw)
+}
+
+func (p *logQueryParser) parseOr() (*logQueryNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword(p.peek(), "OR") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logQueryNode{op: "OR", children: []*logQueryNode{left, right}}
+	}
+	return left, nil
+}
+
+func (p *logQueryParser) parseAnd() (*logQueryNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind == logTokEOF || t.kind == logTokRParen || p.keyword(t, "OR") {
+			return left, nil
+		}
+		if p.keyword(t, "AND") {
+			p.next()
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &logQueryNode{op: "AND", children: []*logQueryNode{left, right}}
+	}
+}
+
+func (p *logQueryParser) parseUnary() (*logQueryNode, error) {
+	const op = "store.parseLogQuery"
+	t := p.next()
+	switch {
+	case p.keyword(t, "NOT"):
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &logQueryNode{op: "NOT", children: []*logQueryNode{child}}, nil
+	case t.kind == logTokLParen:
+		p.depth++
+		if p.depth > maxLogQueryDepth {
+			return nil, apperrors.Newf(op, "position %d: nesting deeper than %d", t.pos, maxLogQueryDepth)
+		}
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != logTokRParen {
+			return nil, apperrors.Newf(op, "position %d: expected \")\"", closing.pos)
+		}
+		p.depth--
+		return inner, nil
+	case t.kind == logTokWord || t.kind == logTokString:
+		p.terms++
+		if p.terms > maxLogQueryTerms {
+			return nil, apperrors.Newf(op, "more than %d conditions", maxLogQueryTerms)
+		}
+		if t.kind == logTokWord && p.peek().kind == logTokOp {
+			opTok := p.next()
+			val := p.next()
+			if val.kind != logTokWord && val.kind != logTokString {
+				return nil, apperrors.Newf(op, "position %d: expected value after %s%s", val.pos, t.text, opTok.text)
+			}
+			return &logQueryNode{op: opTok.text, field: t.text, value: val.text, pos: t.pos}, nil
+		}
+		return &logQueryNode{value: t.text, pos: t.pos}, nil
+	case t.kind == logTokEOF:
+		return nil, apperrors.Newf(op, "position %d: unexpected end of query", t.pos)
+	default:
+		return nil, apperrors.Newf(op, "position %d: unexpected %q", t.pos, t.text)
+	}
+}
+
+// ========================================
+// 编译
+// ========================================
+
+type logQueryCompiler struct {
+	now  time.Time
+	args []any
+}
+
+func (c *logQueryCompiler) arg(v any) string {
+	c.args = append(c.args, v)
+	return fmt.Sprintf("$%d", len(c.args))
+}
+
+func (c *logQueryCompiler) compile(n *logQueryNode) (string, error) {
+	switch n.op {
+	case "AND", "OR":
+		parts := make([]string, 0, len(n.children))
+		for _, child := range n.children {
+			sql, err := c.compile(child)
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, sql)
+		}
+		return "(" + strings.Join(parts, " "+n.op+" ") + ")", nil
+	case "NOT":
+		sql, err := c.compile(n.children[0])
+		if err != nil {
+			return "", err
+		}
+		return "NOT " + sql, nil
+	case "":
+		return fmt.Sprintf("LOWER(message) LIKE %s ESCAPE E'\\\\'", c.arg("%"+util.EscapeLike(strings.ToLower(n.value))+"%")), nil
+	}
+	return c.compileComparison(n)
+}
+
+func (c *logQueryCompiler) compileComparison(n *logQueryNode) (string, error) {
+	const op = "store.compileLogQuery"
+	name := strings.ToLower(n.field)
+	if alias, ok := logQueryAliases[name]; ok {
+		name = alias
+	}
+	field, ok := logQueryFields[name]
+	if !ok {
+		return "", apperrors.Newf(op, "position %d: unknown field %q", n.pos, n.field)
+	}
+	unsupported := func() error {
+		return apperrors.Newf(op, "position %d: operator %s not supported for %s", n.pos, n.op, name)
+	}
+	switch field.kind {
+	case logFieldText:
+		switch n.op {
+		case "=", "!=":
+			return fmt.Sprintf("%s %s %s", field.col, sqlCompareOp(n.op), c.arg(n.value)), nil
+		case "~", "!~":
+			if _, err := regexp.Compile(n.value); err != nil {
+				return "", apperrors.Wrapf(err, op, "position %d: invalid regex", n.pos)
+			}
+			return fmt.Sprintf("%s %s %s", field.col, n.op, c.arg(n.value)), nil
+		}
+		return "", unsupported()
+	case logFieldLevel:
+		if n.op == "~" || n.op == "!~" {
+			return "", unsupported()
+		}
+		rank, ok := logLevelRanks[strings.ToUpper(n.value)]
+		if !ok {
+			return "", apperrors.Newf(op, "position %d: unknown level %q", n.pos, n.value)
+		}
+		var levels []string
+		for level, r := range logLevelRanks {
+			if rankMatches(r, n.op, rank) {
+				levels = append(levels, level)
+			}
+		}
+		if len(levels) == 0 {
+			return "FALSE", nil
+		}
+		slices.Sort(levels)
+		return fmt.Sprintf("UPPER(level) = ANY(%s)", c.arg(levels)), nil
+	case logFieldTime:
+		if n.op == "=" || n.op == "!=" || n.op == "~" || n.op == "!~" {
+			return "", unsupported()
+		}
+		ts, err := parseLogQueryTime(n.value, c.now)
+		if err != nil {
+			return "", apperrors.Wrapf(err, op, "position %d", n.pos)
+		}
+		return fmt.Sprintf("%s %s %s", field.col, n.op, c.arg(ts)), nil
+	default: // logFieldInt
+		if n.op == "~" || n.op == "!~" {
+			return "", unsupported()
+		}
+		v, err := strconv.ParseInt(n.value, 10, 64)
+		if err != nil {
+			return "", apperrors.Newf(op, "position %d: %s expects an integer, got %q", n.pos, name, n.value)
+		}
+		return fmt.Sprintf("%s %s %s", field.col, sqlCompareOp(n.op), c.arg(v)), nil
+	}
+}
+
+func sqlCompareOp(op string) string {
+	if op == "!=" {
+		return "<>"
+	}
+	return op
+}
+
+func rankMatches(r int, op string, want int) bool {
+	switch op {
+	case "=":
+		return r == want
+	case "!=":
+		return r != want
+	case ">":
+		return r > want
+	case ">=":
+		return r >= want
+	case "<":
+		return r < want
+	default: // "<="
+		return r <= want
+	}
+}
+
+var logQueryTimeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04:05", "2006-01-02"}
+
+// parseLogQueryTime 解析时间值: now、相对时长 (-1h / 30m / 2d, 均表示距今) 或绝对时间 (无时区按本地时间)。
+func parseLogQueryTime(v string, now time.Time) (time.Time, error) {
+	const op = "store.parseLogQueryTime"
+	if strings.EqualFold(v, "now") {
+		return now, nil
+	}
+	rel := strings.TrimPrefix(v, "-")
+	if days, ok := strings.CutSuffix(rel, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(rel); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	for _, layout := range logQueryTimeLayouts {
+		if t, err := time.ParseInLocation(layout, v, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, apperrors.Newf(op, "invalid time %q (want RFC3339, 2006-01-02, now or a relative duration like -1h)", v)
+}
+
+// CompileLogQuery 解析并编译日志查询; 空查询匹配全部日志。now 作为相对时间基准。
+func CompileLogQuery(src string, now time.Time) (LogQuery, error) {
+	const op = "store.CompileLogQuery"
+	if len(src) > MaxLogQueryLen {
+		return LogQuery{}, apperrors.Newf(op, "query longer than %d bytes", MaxLogQueryLen)
+	}
+	toks, err := lexLogQuery(src)
+	if err != nil {
+		return LogQuery{}, err
+	}
+	if toks[0].kind == logTokEOF {
+		return LogQuery{Where: "TRUE"}, nil
+	}
+	p := &logQueryParser{toks: toks}
+	root, err := p.parseOr()
+	if err != nil {
+		return LogQuery{}, err
+	}
+	if t := p.peek(); t.kind != logTokEOF {
+		return LogQuery{}, apperrors.Newf(op, "position %d: unexpected %q", t.pos, t.text)
+	}
+	c := &logQueryCompiler{now: now}
+	where, err := c.compile(root)
+	if err != nil {
+		return LogQuery{}, err
+	}
+	return LogQuery{Where: where, Args: c.args}, nil
+}
@@ -0,0 +1,69 @@
+package store
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompileLogQuery(t *testing.T) {
+	now := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		query string
+		where string
+		args  []any
+	}{
+		{"empty", "  ", "TRUE", nil},
+		{"bare word", "timeout", "LOWER(message) LIKE $1 ESCAPE E'\\\\'", []any{"%timeout%"}},
+		{"eq and alias", `tool="shell" AND agent!=a-1`, "(tool_name = $1 AND agent_id <> $2)", []any{"shell", "a-1"}},
+		{"implicit and with or precedence", "source=codex OR source=mcp level=error",
+			"(source = $1 OR (source = $2 AND UPPER(level) = ANY($3)))", []any{"codex", "mcp", []string{"ERROR"}}},
+		{"parens and not", "NOT (logger=a or logger=b)", "NOT (logger = $1 OR logger = $2)", []any{"a", "b"}},
+		{"level ordering", "level>=warn", "UPPER(level) = ANY($1)",
+			[]any{[]string{"CRITICAL", "ERROR", "FATAL", "PANIC", "WARN", "WARNING"}}},
+		{"relative time", "ts>=-90m", "ts >= $1", []any{now.Add(-90 * time.Minute)}},
+		{"days and absolute", "time>2d ts<2026-01-01", "(ts > $1 AND ts < $2)",
+			[]any{now.AddDate(0, 0, -2), time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}},
+		{"regex", `msg~"refused|reset \"x\""`, "message ~ $1", []any{`refused|reset "x"`}},
+		{"duration", "duration_ms>=1500", "duration_ms >= $1", []any{int64(1500)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := CompileLogQuery(tt.query, now)
+			if err != nil {
+				t.Fatalf("CompileLogQuery(%q): %v", tt.query, err)
+			}
+			if q.Where != tt.where {
+				t.Errorf("where = %s, want %s", q.Where, tt.where)
+			}
+			if !reflect.DeepEqual(q.Args, tt.args) {
+				t.Errorf("args = %#v, want %#v", q.Args, tt.args)
+			}
+		})
+	}
+}
+
+func TestCompileLogQuery_Errors(t *testing.T) {
+	for query, want := range map[string]string{
+		"level>=verbose":         "unknown level",
+		"color=red":              "unknown field",
+		"ts=now":                 "not supported for ts",
+		"level~warn":             "not supported for level",
+		"message~\"(\"":          "invalid regex",
+		"duration_ms>fast":       "expects an integer",
+		"ts>yesterday":           "invalid time",
+		"(level=error":           `expected ")"`,
+		"level=error)":           "unexpected",
+		"source=":                "expected value",
+		"source=codex AND":       "unexpected end",
+		"message=\"open":         "unterminated string",
+		"level=>error":           "unknown operator",
+		strings.Repeat("a ", 65): "more than 64 conditions",
+	} {
+		if _, err := CompileLogQuery(query, time.Now()); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("CompileLogQuery(%q) err = %v, want %q", query, err, want)
+		}
+	}
+}
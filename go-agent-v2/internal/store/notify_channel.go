@@ -0,0 +1,93 @@
+// notify_channel.go — 外部通知渠道 CRUD (表 notify_channels)。
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// NotifyChannel 外部通知渠道 (Slack / 邮件 / 通用 webhook)。
+type NotifyChannel struct {
+	ID        int64          `db:"id" json:"id"`
+	Name      string         `db:"name" json:"name"`
+	Kind      string         `db:"kind" json:"kind"`
+	Config    map[string]any `db:"config" json:"config"`
+	Events    []string       `db:"events" json:"events"`
+	AgentIDs  []string       `db:"agent_ids" json:"agentIds"`
+	Enabled   bool           `db:"enabled" json:"enabled"`
+	CreatedAt time.Time      `db:"created_at" json:"createdAt"`
+	UpdatedAt time.Time      `db:"updated_at" json:"updatedAt"`
+}
+
+// NotifyChannelStore 通知渠道存储。
+type NotifyChannelStore struct{ BaseStore }
+
+// NewNotifyChannelStore 创建。
+func NewNotifyChannelStore(pool *pgxpool.Pool) *NotifyChannelStore {
+	return &NotifyChannelStore{NewBaseStore(pool)}
+}
+
+const notifyChannelCols = `id, name, kind, config, events, agent_ids, enabled, created_at, updated_at`
+
+// Create 新建渠道 (name 唯一)。
+func (s *NotifyChannelStore) Create(ctx context.Context, c *NotifyChannel) (*NotifyChannel, error) {
+	rows, err := s.pool.Query(ctx,
+		`INSERT INTO notify_channels (name, kind, config, events, agent_ids, enabled)
+		 VALUES ($1, $2, $3::jsonb, $4, $5, $6)
+		 RETURNING `+notifyChannelCols,
+		c.Name, c.Kind, string(mustMarshalJSON(c.Config)), nonNilStrings(c.Events), nonNilStrings(c.AgentIDs), c.Enabled)
+	if err != nil {
+		return nil, err
+	}
+	return collectOne[NotifyChannel](rows)
+}
+
+// Update 覆盖渠道配置 (按 id, 不存在返回 nil)。
+func (s *NotifyChannelStore) Update(ctx context.Context, c *NotifyChannel) (*NotifyChannel, error) {
+	rows, err := s.pool.Query(ctx,
+		`UPDATE notify_channels
+		 SET name=$2, kind=$3, config=$4::jsonb, events=$5, agent_ids=$6, enabled=$7, updated_at=NOW()
+		 WHERE id=$1
+		 RETURNING `+notifyChannelCols,
+		c.ID, c.Name, c.Kind, string(mustMarshalJSON(c.Config)), nonNilStrings(c.Events), nonNilStrings(c.AgentIDs), c.Enabled)
+	if err != nil {
+		return nil, err
+	}
+	return collectOne[NotifyChannel](rows)
+}
+
+// Get 按 id 查询, 不存在返回 nil。
+func (s *NotifyChannelStore) Get(ctx context.Context, id int64) (*NotifyChannel, error) {
+	rows, err := s.pool.Query(ctx, "SELECT "+notifyChannelCols+" FROM notify_channels WHERE id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+	return collectOne[NotifyChannel](rows)
+}
+
+// List 全部渠道 (按 id 升序)。
+func (s *NotifyChannelStore) List(ctx context.Context) ([]NotifyChannel, error) {
+	rows, err := s.pool.Query(ctx, "SELECT "+notifyChannelCols+" FROM notify_channels ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	return collectRows[NotifyChannel](rows)
+}
+
+// Delete 删除渠道, 返回是否存在。
+func (s *NotifyChannelStore) Delete(ctx context.Context, id int64) (bool, error) {
+	tag, err := s.pool.Exec(ctx, "DELETE FROM notify_channels WHERE id = $1", id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func nonNilStrings(v []string) []string {
+	if v == nil {
+		return []string{}
+	}
+	return v
+}
@@ -0,0 +1,96 @@
+// personality.go — agent 人格目录 (表 personalities) 与线程当前人格 (表 thread_personalities)。
+package store
+
+import (
+	"context"
+	stderrors "errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+// Personality 命名系统提示词预设。
+type Personality struct {
+	Name         string    `db:"name" json:"name"`
+	Description  string    `db:"description" json:"description,omitempty"`
+	Base         string    `db:"base" json:"base,omitempty"` // codex 内置人格 (none / friendly / pragmatic), 空 = 不切换
+	Instructions string    `db:"instructions" json:"instructions,omitempty"`
+	CreatedAt    time.Time `db:"created_at" json:"createdAt,omitempty"`
+	UpdatedAt    time.Time `db:"updated_at" json:"updatedAt,omitempty"`
+}
+
+// PersonalityStore 人格目录存储。
+type PersonalityStore struct{ BaseStore }
+
+// NewPersonalityStore 创建。
+func NewPersonalityStore(pool *pgxpool.Pool) *PersonalityStore {
+	return &PersonalityStore{NewBaseStore(pool)}
+}
+
+const personalityCols = `name, description, base, instructions, created_at, updated_at`
+
+// Save 创建或更新预设 (UPSERT)。
+func (s *PersonalityStore) Save(ctx context.Context, p Personality) (*Personality, error) {
+	rows, err := s.pool.Query(ctx, `
+		INSERT INTO personalities (name, description, base, instructions, updated_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (name) DO UPDATE SET
+			description = EXCLUDED.description,
+			base = EXCLUDED.base,
+			instructions = EXCLUDED.instructions,
+			updated_at = NOW()
+		RETURNING `+personalityCols,
+		p.Name, p.Description, p.Base, p.Instructions)
+	if err != nil {
+		return nil, apperrors.Wrap(err, "PersonalityStore.Save", "upsert personality")
+	}
+	return collectOne[Personality](rows)
+}
+
+// List 全部自定义预设 (按名称排序)。
+func (s *PersonalityStore) List(ctx context.Context) ([]Personality, error) {
+	rows, err := s.pool.Query(ctx, "SELECT "+personalityCols+" FROM personalities ORDER BY name")
+	if err != nil {
+		return nil, apperrors.Wrap(err, "PersonalityStore.List", "query personalities")
+	}
+	return collectRows[Personality](rows)
+}
+
+// Count 自定义预设数量。
+func (s *PersonalityStore) Count(ctx context.Context) (int, error) {
+	var n int
+	if err := s.pool.QueryRow(ctx, "SELECT COUNT(*) FROM personalities").Scan(&n); err != nil {
+		return 0, apperrors.Wrap(err, "PersonalityStore.Count", "count personalities")
+	}
+	return n, nil
+}
+
+// SetThread 记录线程当前人格。
+func (s *PersonalityStore) SetThread(ctx context.Context, threadID, name string) error {
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO thread_personalities (thread_id, personality, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (thread_id) DO UPDATE SET
+			personality = EXCLUDED.personality,
+			updated_at = NOW()
+	`, threadID, name)
+	if err != nil {
+		return apperrors.Wrap(err, "PersonalityStore.SetThread", "upsert thread personality")
+	}
+	return nil
+}
+
+// GetThread 读取线程当前人格, 未设置返回空串。
+func (s *PersonalityStore) GetThread(ctx context.Context, threadID string) (string, error) {
+	var name string
+	err := s.pool.QueryRow(ctx, "SELECT personality FROM thread_personalities WHERE thread_id = $1", threadID).Scan(&name)
+	if err != nil {
+		if stderrors.Is(err, pgx.ErrNoRows) {
+			return "", nil
+		}
+		return "", apperrors.Wrap(err, "PersonalityStore.GetThread", "query thread personality")
+	}
+	return name, nil
+}
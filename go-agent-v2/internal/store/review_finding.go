@@ -0,0 +1,112 @@
+// review_finding.go — 代码审查结构化发现 (表 review_findings, 供 review/findings/*)。
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// 审查发现处理状态。
+const (
+	ReviewFindingOpen      = "open"
+	ReviewFindingAccepted  = "accepted"
+	ReviewFindingDismissed = "dismissed"
+)
+
+// ReviewFinding 一条审查发现。
+type ReviewFinding struct {
+	ID         int64     `db:"id" json:"id"`
+	ReviewID   string    `db:"review_id" json:"reviewId"`
+	ThreadID   string    `db:"thread_id" json:"threadId"`
+	TurnID     string    `db:"turn_id" json:"turnId,omitempty"`
+	Scope      string    `db:"scope" json:"scope,omitempty"`
+	File       string    `db:"file" json:"file"`
+	Line       int       `db:"line" json:"line,omitempty"`
+	Severity   string    `db:"severity" json:"severity"`
+	Title      string    `db:"title" json:"title"`
+	Suggestion string    `db:"suggestion" json:"suggestion,omitempty"`
+	Status     string    `db:"status" json:"status"`
+	Note       string    `db:"note" json:"note,omitempty"`
+	CreatedAt  time.Time `db:"created_at" json:"createdAt"`
+	UpdatedAt  time.Time `db:"updated_at" json:"updatedAt"`
+}
+
+// ReviewFindingQuery 列表过滤条件 (空值不过滤)。
+type ReviewFindingQuery struct {
+	ThreadID string
+	ReviewID string
+	Status   string
+	Limit    int
+}
+
+// ReviewFindingStore 审查发现存储。
+type ReviewFindingStore struct{ BaseStore }
+
+// NewReviewFindingStore 创建。
+func NewReviewFindingStore(pool *pgxpool.Pool) *ReviewFindingStore {
+	return &ReviewFindingStore{NewBaseStore(pool)}
+}
+
+const reviewFindingCols = `id, review_id, thread_id, turn_id, scope, file, line, severity, title, suggestion, status, note, created_at, updated_at`
+
+// InsertBatch 批量写入一次审查的发现, 返回带 id 的记录 (顺序与入参一致)。
+func (s *ReviewFindingStore) InsertBatch(ctx context.Context, findings []ReviewFinding) ([]ReviewFinding, error) {
+	if len(findings) == 0 {
+		return nil, nil
+	}
+	batch := &pgx.Batch{}
+	for _, f := range findings {
+		batch.Queue(
+			`INSERT INTO review_findings (review_id, thread_id, turn_id, scope, file, line, severity, title, suggestion, status)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			 RETURNING `+reviewFindingCols,
+			f.ReviewID, f.ThreadID, f.TurnID, f.Scope, f.File, f.Line, f.Severity, f.Title, f.Suggestion, f.Status)
+	}
+	results := s.pool.SendBatch(ctx, batch)
+	defer results.Close()
+	out := make([]ReviewFinding, 0, len(findings))
+	for range findings {
+		rows, err := results.Query()
+		if err != nil {
+			return nil, err
+		}
+		row, err := collectOne[ReviewFinding](rows)
+		if err != nil {
+			return nil, err
+		}
+		if row != nil {
+			out = append(out, *row)
+		}
+	}
+	return out, nil
+}
+
+// List 按条件查询 (id 倒序, 最新审查在前)。
+func (s *ReviewFindingStore) List(ctx context.Context, q ReviewFindingQuery) ([]ReviewFinding, error) {
+	sql, params := NewQueryBuilder().
+		Eq("thread_id", q.ThreadID).
+		Eq("review_id", q.ReviewID).
+		Eq("status", q.Status).
+		Build("SELECT "+reviewFindingCols+" FROM review_findings", "id DESC", q.Limit)
+	rows, err := s.pool.Query(ctx, sql, params...)
+	if err != nil {
+		return nil, err
+	}
+	return collectRows[ReviewFinding](rows)
+}
+
+// SetStatus 更新处理状态与备注, 不存在返回 nil。
+func (s *ReviewFindingStore) SetStatus(ctx context.Context, id int64, status, note string) (*ReviewFinding, error) {
+	rows, err := s.pool.Query(ctx,
+		`UPDATE review_findings SET status=$2, note=$3, updated_at=NOW()
+		 WHERE id=$1
+		 RETURNING `+reviewFindingCols,
+		id, status, note)
+	if err != nil {
+		return nil, err
+	}
+	return collectOne[ReviewFinding](rows)
+}
@@ -28,16 +28,20 @@ func (s *SharedFileStore) Write(ctx context.Context, path, content, actor string
 	if p == "" {
 		return nil, ErrInvalidPath
 	}
+	sealed, err := sealText(columnSharedFileContent, content)
+	if err != nil {
+		return nil, err
+	}
 	rows, err := s.pool.Query(ctx,
 		`INSERT INTO shared_files (path, content, updated_by, created_at, updated_at)
 		 VALUES ($1, $2, $3, NOW(), NOW())
 		 ON CONFLICT (path) DO UPDATE SET content=EXCLUDED.content, updated_by=EXCLUDED.updated_by, updated_at=NOW()
 		 RETURNING path, content, updated_by, created_at, updated_at`,
-		p, content, actor)
+		p, sealed, actor)
 	if err != nil {
 		return nil, err
 	}
-	return collectOne[SharedFile](rows)
+	return decryptOne(collectOne[SharedFile](rows))
 }
 
 // Read 读取文件。
@@ -48,7 +52,7 @@ func (s *SharedFileStore) Read(ctx context.Context, path string) (*SharedFile, e
 	if err != nil {
 		return nil, err
 	}
-	return collectOne[SharedFile](rows)
+	return decryptOne(collectOne[SharedFile](rows))
 }
 
 // List 列表查询文件。
@@ -65,7 +69,7 @@ func (s *SharedFileStore) List(ctx context.Context, prefix string, limit int) ([
 	if err != nil {
 		return nil, err
 	}
-	return collectRows[SharedFile](rows)
+	return decryptAll(collectRows[SharedFile](rows))
 }
 
 // Delete 删除文件。
@@ -3,8 +3,14 @@ package store
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/multi-agent/go-agent-v2/pkg/util"
 )
 
 // SystemLogStore 系统日志存储。
@@ -27,6 +33,72 @@ func (s *SystemLogStore) Append(ctx context.Context, level, loggerName, message,
 	return err
 }
 
+var sysLogCopyCols = []string{
+	"ts", "level", "logger", "message", "raw",
+	"source", "component", "agent_id", "thread_id", "trace_id",
+	"event_type", "tool_name", "duration_ms", "extra",
+}
+
+// AppendBatch 批量写入日志 (COPY 协议单次往返, 全部成功或全部失败; ID 字段忽略, Ts 为零值时取当前时间)。
+func (s *SystemLogStore) AppendBatch(ctx context.Context, entries []SystemLog) (int64, error) {
+	if len(entries) == 0 {
+		return 0, nil
+	}
+	now := time.Now()
+	rows := make([][]any, 0, len(entries))
+	for _, e := range entries {
+		ts := e.Ts
+		if ts.IsZero() {
+			ts = now
+		}
+		var extra any
+		if e.Extra != nil {
+			extra = json.RawMessage(mustMarshalJSON(e.Extra))
+		}
+		rows = append(rows, []any{
+			ts, e.Level, e.Logger, e.Message, e.Raw,
+			e.Source, e.Component, e.AgentID, e.ThreadID, e.TraceID,
+			e.EventType, e.ToolName, e.DurationMS, extra,
+		})
+	}
+	return s.pool.CopyFrom(ctx, pgx.Identifier{"system_logs"}, sysLogCopyCols, pgx.CopyFromRows(rows))
+}
+
+// ListBefore 按 id 升序返回 ts < cutoff 且 id > afterID 的日志 (保留策略归档分批读取)。
+func (s *SystemLogStore) ListBefore(ctx context.Context, cutoff time.Time, afterID, limit int) ([]SystemLog, error) {
+	rows, err := s.pool.Query(ctx,
+		"SELECT "+sysLogCols+" FROM system_logs WHERE ts < $1 AND id > $2 ORDER BY id LIMIT $3",
+		cutoff, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return collectRows[SystemLog](rows)
+}
+
+// DeleteIDs 删除指定 id 的日志 (归档写盘成功后调用)。
+func (s *SystemLogStore) DeleteIDs(ctx context.Context, ids []int) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	tag, err := s.pool.Exec(ctx, "DELETE FROM system_logs WHERE id = ANY($1)", ids)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// PruneBefore 删除最多 limit 条 ts < cutoff 的日志 (分批删除, 避免长事务)。
+func (s *SystemLogStore) PruneBefore(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	tag, err := s.pool.Exec(ctx,
+		`DELETE FROM system_logs WHERE id IN (
+			SELECT id FROM system_logs WHERE ts < $1 ORDER BY id LIMIT $2)`,
+		cutoff, limit)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
 // ListParams 统一日志查询参数。
 type ListParams struct {
 	Level     string
@@ -35,6 +107,7 @@ type ListParams struct {
 	Component string
 	AgentID   string
 	ThreadID  string
+	TraceID   string
 	EventType string
 	ToolName  string
 	Keyword   string
@@ -60,6 +133,7 @@ func (s *SystemLogStore) ListV2(ctx context.Context, p ListParams) ([]SystemLog,
 		Eq("component", p.Component).
 		Eq("agent_id", p.AgentID).
 		Eq("thread_id", p.ThreadID).
+		Eq("trace_id", p.TraceID).
 		Eq("event_type", p.EventType).
 		Eq("tool_name", p.ToolName).
 		KeywordLike(p.Keyword, "level", "logger", "message", "raw", "source", "component")
@@ -71,6 +145,19 @@ func (s *SystemLogStore) ListV2(ctx context.Context, p ListParams) ([]SystemLog,
 	return collectRows[SystemLog](rows)
 }
 
+// Query 按已编译的查询语言条件检索日志 (log/query), 按时间倒序。
+func (s *SystemLogStore) Query(ctx context.Context, q LogQuery, limit int) ([]SystemLog, error) {
+	limit = util.ClampInt(limit, 1, 2000)
+	args := append(append([]any(nil), q.Args...), limit)
+	rows, err := s.pool.Query(ctx,
+		fmt.Sprintf("SELECT %s FROM system_logs WHERE %s ORDER BY ts DESC, id DESC LIMIT $%d", sysLogCols, q.Where, len(args)),
+		args...)
+	if err != nil {
+		return nil, err
+	}
+	return collectRows[SystemLog](rows)
+}
+
 // ListFilterValues 返回去重筛选值。
 func (s *SystemLogStore) ListFilterValues(ctx context.Context) (map[string][]string, error) {
 	return DistinctMap(ctx, s.pool, "system_logs", "level", "logger", "source", "component", "event_type", "tool_name")
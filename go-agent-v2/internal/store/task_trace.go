@@ -55,25 +55,29 @@ func (s *TaskTraceStore) FinishSpan(ctx context.Context, traceID, spanID, status
 	return collectOne[TaskTrace](rows)
 }
 
-// Create 直接创建完整记录。
+// Create 直接创建完整记录 (StartedAt 为零值时使用当前时间, 离线重放时保留原始时间)。
 func (s *TaskTraceStore) Create(ctx context.Context, t *TaskTrace) (*TaskTrace, error) {
 	inJSON := mustMarshalJSON(t.Input)
 	outJSON := mustMarshalJSON(t.Output)
 	metaJSON := mustMarshalJSON(t.Metadata)
+	var startedAt *time.Time
+	if !t.StartedAt.IsZero() {
+		startedAt = &t.StartedAt
+	}
 	rows, err := s.pool.Query(ctx,
 		`INSERT INTO task_traces (trace_id, span_id, parent_span_id, span_name, component,
 		   input_payload, output_payload, status, error_text, duration_ms, metadata, started_at, finished_at)
-		 VALUES ($1, $2, $3, $4, $5, $6::jsonb, $7::jsonb, $8, $9, $10, $11::jsonb, NOW(), NULL)
+		 VALUES ($1, $2, $3, $4, $5, $6::jsonb, $7::jsonb, $8, $9, $10, $11::jsonb, COALESCE($12, NOW()), $13)
 		 RETURNING `+taskTraceCols,
 		t.TraceID, t.SpanID, t.ParentSpanID, t.SpanName, t.Component,
-		string(inJSON), string(outJSON), t.Status, t.ErrorText, t.DurationMS, string(metaJSON))
+		string(inJSON), string(outJSON), t.Status, t.ErrorText, t.DurationMS, string(metaJSON), startedAt, t.FinishedAt)
 	if err != nil {
 		return nil, err
 	}
 	return collectOne[TaskTrace](rows)
 }
 
-// Deprecated: ListByTraceID 无外部调用者。
+// ListByTraceID 按 trace_id 查询全部 span (MCP 资源 mcp://traces/{trace_id})。
 func (s *TaskTraceStore) ListByTraceID(ctx context.Context, traceID string) ([]TaskTrace, error) {
 	rows, err := s.pool.Query(ctx,
 		"SELECT "+taskTraceCols+" FROM task_traces WHERE trace_id = $1 ORDER BY started_at", traceID)
@@ -0,0 +1,84 @@
+// thread_handoff.go — 优雅停止交接摘要 (表 thread_handoffs)。
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+// ThreadHandoff agent 停止前生成的交接摘要。
+type ThreadHandoff struct {
+	ID               int64      `db:"id" json:"id"`
+	ThreadID         string     `db:"thread_id" json:"threadId"`
+	TurnID           string     `db:"turn_id" json:"turnId,omitempty"`
+	Status           string     `db:"status" json:"status"` // captured / unstructured / timeout
+	Reason           string     `db:"reason" json:"reason,omitempty"`
+	Summary          string     `db:"summary" json:"summary,omitempty"`
+	OutstandingSteps []string   `db:"outstanding_steps" json:"outstandingSteps"`
+	ModifiedFiles    []string   `db:"modified_files" json:"modifiedFiles"`
+	Raw              string     `db:"raw" json:"raw,omitempty"`
+	CreatedAt        time.Time  `db:"created_at" json:"createdAt"`
+	ConsumedAt       *time.Time `db:"consumed_at" json:"consumedAt,omitempty"`
+}
+
+// ThreadHandoffStore 交接摘要存储。
+type ThreadHandoffStore struct{ BaseStore }
+
+// NewThreadHandoffStore 创建。
+func NewThreadHandoffStore(pool *pgxpool.Pool) *ThreadHandoffStore {
+	return &ThreadHandoffStore{NewBaseStore(pool)}
+}
+
+const threadHandoffCols = `id, thread_id, turn_id, status, reason, summary, outstanding_steps, modified_files, raw, created_at, consumed_at`
+
+// Save 写入一条交接摘要。
+func (s *ThreadHandoffStore) Save(ctx context.Context, h ThreadHandoff) (*ThreadHandoff, error) {
+	if h.OutstandingSteps == nil {
+		h.OutstandingSteps = []string{}
+	}
+	if h.ModifiedFiles == nil {
+		h.ModifiedFiles = []string{}
+	}
+	rows, err := s.pool.Query(ctx, `
+		INSERT INTO thread_handoffs (thread_id, turn_id, status, reason, summary, outstanding_steps, modified_files, raw)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING `+threadHandoffCols,
+		h.ThreadID, h.TurnID, h.Status, h.Reason, h.Summary, h.OutstandingSteps, h.ModifiedFiles, h.Raw)
+	if err != nil {
+		return nil, apperrors.Wrap(err, "ThreadHandoffStore.Save", "insert handoff")
+	}
+	return collectOne[ThreadHandoff](rows)
+}
+
+// LatestPending 线程最新一条未消费的交接摘要, 没有返回 nil。
+func (s *ThreadHandoffStore) LatestPending(ctx context.Context, threadID string) (*ThreadHandoff, error) {
+	rows, err := s.pool.Query(ctx, "SELECT "+threadHandoffCols+` FROM thread_handoffs
+		WHERE thread_id = $1 AND consumed_at IS NULL
+		ORDER BY created_at DESC, id DESC LIMIT 1`, threadID)
+	if err != nil {
+		return nil, apperrors.Wrap(err, "ThreadHandoffStore.LatestPending", "query handoff")
+	}
+	return collectOne[ThreadHandoff](rows)
+}
+
+// ListByThread 线程的交接摘要 (新的在前)。
+func (s *ThreadHandoffStore) ListByThread(ctx context.Context, threadID string, limit int) ([]ThreadHandoff, error) {
+	rows, err := s.pool.Query(ctx, "SELECT "+threadHandoffCols+` FROM thread_handoffs
+		WHERE thread_id = $1 ORDER BY created_at DESC, id DESC LIMIT $2`, threadID, limit)
+	if err != nil {
+		return nil, apperrors.Wrap(err, "ThreadHandoffStore.ListByThread", "query handoffs")
+	}
+	return collectRows[ThreadHandoff](rows)
+}
+
+// MarkConsumed 标记线程全部未消费的交接摘要为已注入 (较旧的记录已被最新一条取代)。
+func (s *ThreadHandoffStore) MarkConsumed(ctx context.Context, threadID string) error {
+	_, err := s.pool.Exec(ctx, "UPDATE thread_handoffs SET consumed_at = NOW() WHERE thread_id = $1 AND consumed_at IS NULL", threadID)
+	if err != nil {
+		return apperrors.Wrap(err, "ThreadHandoffStore.MarkConsumed", "update handoffs")
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+// thread_lineage.go — 线程分叉关系 (表 thread_lineage)。
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+// ThreadLineage 子线程与其父线程的分叉关系。
+type ThreadLineage struct {
+	ChildThreadID  string    `db:"child_thread_id" json:"threadId"`
+	ParentThreadID string    `db:"parent_thread_id" json:"parentThreadId"`
+	TurnIndex      *int      `db:"turn_index" json:"turnIndex,omitempty"` // nil = 整段分叉
+	CodexThreadID  string    `db:"codex_thread_id" json:"codexThreadId,omitempty"`
+	CreatedAt      time.Time `db:"created_at" json:"createdAt"`
+}
+
+// ThreadLineageStore 分叉关系存储。
+type ThreadLineageStore struct{ BaseStore }
+
+// NewThreadLineageStore 创建。
+func NewThreadLineageStore(pool *pgxpool.Pool) *ThreadLineageStore {
+	return &ThreadLineageStore{NewBaseStore(pool)}
+}
+
+const threadLineageCols = `child_thread_id, parent_thread_id, turn_index, codex_thread_id, created_at`
+
+// Save 写入 (或覆盖) 子线程的父线程关系。
+func (s *ThreadLineageStore) Save(ctx context.Context, l ThreadLineage) (*ThreadLineage, error) {
+	rows, err := s.pool.Query(ctx, `
+		INSERT INTO thread_lineage (child_thread_id, parent_thread_id, turn_index, codex_thread_id)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (child_thread_id) DO UPDATE SET
+			parent_thread_id = EXCLUDED.parent_thread_id,
+			turn_index = EXCLUDED.turn_index,
+			codex_thread_id = EXCLUDED.codex_thread_id
+		RETURNING `+threadLineageCols,
+		l.ChildThreadID, l.ParentThreadID, l.TurnIndex, l.CodexThreadID)
+	if err != nil {
+		return nil, apperrors.Wrap(err, "ThreadLineageStore.Save", "upsert lineage")
+	}
+	return collectOne[ThreadLineage](rows)
+}
+
+// Parent 子线程的分叉来源, 不是分叉线程时返回 nil。
+func (s *ThreadLineageStore) Parent(ctx context.Context, threadID string) (*ThreadLineage, error) {
+	rows, err := s.pool.Query(ctx, "SELECT "+threadLineageCols+" FROM thread_lineage WHERE child_thread_id = $1", threadID)
+	if err != nil {
+		return nil, apperrors.Wrap(err, "ThreadLineageStore.Parent", "query lineage")
+	}
+	return collectOne[ThreadLineage](rows)
+}
+
+// Children 由该线程分叉出的子线程 (按创建时间)。
+func (s *ThreadLineageStore) Children(ctx context.Context, threadID string) ([]ThreadLineage, error) {
+	rows, err := s.pool.Query(ctx, "SELECT "+threadLineageCols+` FROM thread_lineage
+		WHERE parent_thread_id = $1 ORDER BY created_at, child_thread_id`, threadID)
+	if err != nil {
+		return nil, apperrors.Wrap(err, "ThreadLineageStore.Children", "query lineage")
+	}
+	return collectRows[ThreadLineage](rows)
+}
@@ -0,0 +1,92 @@
+// thread_search.go — 线程时间线全文检索文档 (thread_search_docs, 供 thread/search)。
+package store
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/multi-agent/go-agent-v2/pkg/util"
+)
+
+// ThreadSearchDoc 一条可检索的时间线条目。
+type ThreadSearchDoc struct {
+	ThreadID string    `db:"thread_id" json:"threadId"`
+	Kind     string    `db:"kind" json:"kind"`
+	DocHash  string    `db:"doc_hash" json:"-"`
+	ItemID   string    `db:"item_id" json:"itemId"`
+	Content  string    `db:"content" json:"content"`
+	Ts       time.Time `db:"ts" json:"ts"`
+}
+
+// ThreadSearchHit 检索结果 (Score 为 ts_rank, ILIKE 兜底命中时为 0)。
+type ThreadSearchHit struct {
+	ThreadSearchDoc
+	Score float64 `db:"score" json:"score"`
+}
+
+// ThreadSearchQuery 检索条件。
+type ThreadSearchQuery struct {
+	Query    string
+	ThreadID string   // 为空表示全部线程
+	Kinds    []string // 为空表示全部类型
+	Limit    int
+}
+
+// ThreadSearchStore thread_search_docs 表操作。
+type ThreadSearchStore struct{ BaseStore }
+
+// NewThreadSearchStore 创建。
+func NewThreadSearchStore(pool *pgxpool.Pool) *ThreadSearchStore {
+	return &ThreadSearchStore{NewBaseStore(pool)}
+}
+
+// maxSearchContentChars 检索结果返回的内容上限 (摘要在 Go 侧生成)。
+const maxSearchContentChars = 20000
+
+// UpsertDocs 批量写入检索文档 (同一 thread/kind/doc_hash 已存在时更新 item_id)。
+func (s *ThreadSearchStore) UpsertDocs(ctx context.Context, docs []ThreadSearchDoc) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	batch := &pgx.Batch{}
+	for _, d := range docs {
+		batch.Queue(
+			`INSERT INTO thread_search_docs (thread_id, kind, doc_hash, item_id, content, ts)
+			 VALUES ($1, $2, $3, $4, $5, $6)
+			 ON CONFLICT (thread_id, kind, doc_hash) DO UPDATE SET item_id = EXCLUDED.item_id`,
+			d.ThreadID, d.Kind, d.DocHash, d.ItemID, d.Content, d.Ts)
+	}
+	return s.pool.SendBatch(ctx, batch).Close()
+}
+
+// Search 全文检索: tsvector 匹配按 ts_rank 排序, 未命中分词时以 ILIKE 子串匹配兜底。
+func (s *ThreadSearchStore) Search(ctx context.Context, q ThreadSearchQuery) ([]ThreadSearchHit, error) {
+	query := strings.TrimSpace(q.Query)
+	if query == "" {
+		return []ThreadSearchHit{}, nil
+	}
+	kinds := q.Kinds
+	if kinds == nil {
+		kinds = []string{}
+	}
+	rows, err := s.pool.Query(ctx,
+		`SELECT thread_id, kind, doc_hash, item_id, LEFT(content, $5) AS content, ts,
+		        ts_rank(tsv, plainto_tsquery('simple', $1))::float8 AS score
+		 FROM thread_search_docs
+		 WHERE ($2 = '' OR thread_id = $2)
+		   AND (cardinality($3::text[]) = 0 OR kind = ANY($3))
+		   AND (tsv @@ plainto_tsquery('simple', $1) OR LOWER(content) LIKE $4 ESCAPE E'\\')
+		 ORDER BY score DESC, ts DESC
+		 LIMIT $6`,
+		query, strings.TrimSpace(q.ThreadID), kinds,
+		"%"+util.EscapeLike(strings.ToLower(query))+"%",
+		maxSearchContentChars, util.ClampInt(q.Limit, 1, 500))
+	if err != nil {
+		return nil, err
+	}
+	return collectRows[ThreadSearchHit](rows)
+}
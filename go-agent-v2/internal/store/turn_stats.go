@@ -0,0 +1,110 @@
+// turn_stats.go — turn 结果统计 (task_traces span_name='turn' 聚合, 供 dashboard/turnStats)。
+package store
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// TurnSpanName turn 结束记录在 task_traces 中的 span_name。
+const TurnSpanName = "turn"
+
+// TurnStatsQuery turn 统计查询条件。
+type TurnStatsQuery struct {
+	AgentID  string    // 为空表示全部 agent
+	Since    time.Time // started_at 下限
+	TimeZone string    // 按天分组的时区 (IANA 名称, 空 = UTC)
+}
+
+// TurnStatsRow 某 agent 某天 (或汇总) 的 turn 状态分布与耗时分位。
+//
+// AgentID / Day 为 nil 表示该维度的汇总行 (GROUPING SETS)。
+type TurnStatsRow struct {
+	AgentID       *string `db:"agent_id" json:"agentId"`
+	Day           *string `db:"day" json:"day"`
+	Total         int64   `db:"total" json:"total"`
+	Completed     int64   `db:"completed" json:"completed"`
+	Interrupted   int64   `db:"interrupted" json:"interrupted"`
+	Failed        int64   `db:"failed" json:"failed"`
+	AvgDurationMS int64   `db:"avg_duration_ms" json:"avgDurationMs"`
+	P50DurationMS int64   `db:"p50_duration_ms" json:"p50DurationMs"`
+	P90DurationMS int64   `db:"p90_duration_ms" json:"p90DurationMs"`
+	P99DurationMS int64   `db:"p99_duration_ms" json:"p99DurationMs"`
+	MaxDurationMS int64   `db:"max_duration_ms" json:"maxDurationMs"`
+}
+
+// TurnReasonRow 某 agent 某天按原因统计的中断 / 失败次数。
+type TurnReasonRow struct {
+	AgentID string `db:"agent_id" json:"agentId"`
+	Day     string `db:"day" json:"day"`
+	Status  string `db:"status" json:"status"`
+	Reason  string `db:"reason" json:"reason"`
+	Count   int64  `db:"count" json:"count"`
+}
+
+// turnStatsWhere 构造 turn 统计公共 WHERE 子句, 参数从 $2 开始 ($1 为时区)。
+func turnStatsWhere(q TurnStatsQuery) (string, []any) {
+	where := "span_name = $2 AND started_at >= $3"
+	params := []any{TurnSpanName, q.Since}
+	if q.AgentID != "" {
+		params = append(params, q.AgentID)
+		where += " AND component = $" + strconv.Itoa(len(params)+1)
+	}
+	return where, params
+}
+
+func turnStatsTimeZone(tz string) string {
+	if tz == "" {
+		return "UTC"
+	}
+	return tz
+}
+
+// TurnStats 按 agent / 天聚合 turn 状态分布与耗时分位 (含 agent 汇总与全局汇总行)。
+func (s *TaskTraceStore) TurnStats(ctx context.Context, q TurnStatsQuery) ([]TurnStatsRow, error) {
+	where, params := turnStatsWhere(q)
+	sql := `SELECT component AS agent_id,
+		       to_char((started_at AT TIME ZONE $1)::date, 'YYYY-MM-DD') AS day,
+		       COUNT(*) AS total,
+		       COUNT(*) FILTER (WHERE status = 'completed') AS completed,
+		       COUNT(*) FILTER (WHERE status = 'interrupted') AS interrupted,
+		       COUNT(*) FILTER (WHERE status = 'failed') AS failed,
+		       COALESCE(AVG(duration_ms), 0)::BIGINT AS avg_duration_ms,
+		       COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY duration_ms), 0)::BIGINT AS p50_duration_ms,
+		       COALESCE(percentile_cont(0.9) WITHIN GROUP (ORDER BY duration_ms), 0)::BIGINT AS p90_duration_ms,
+		       COALESCE(percentile_cont(0.99) WITHIN GROUP (ORDER BY duration_ms), 0)::BIGINT AS p99_duration_ms,
+		       COALESCE(MAX(duration_ms), 0)::BIGINT AS max_duration_ms
+		FROM task_traces
+		WHERE ` + where + `
+		GROUP BY GROUPING SETS ((1, 2), (1), ())
+		ORDER BY day DESC NULLS FIRST, agent_id NULLS FIRST`
+	rows, err := s.pool.Query(ctx, sql, append([]any{turnStatsTimeZone(q.TimeZone)}, params...)...)
+	if err != nil {
+		return nil, err
+	}
+	return collectRows[TurnStatsRow](rows)
+}
+
+// TurnReasons 按 agent / 天 / 状态统计中断与失败原因 (metadata.reason, 空原因归为 unknown)。
+func (s *TaskTraceStore) TurnReasons(ctx context.Context, q TurnStatsQuery, limit int) ([]TurnReasonRow, error) {
+	if limit <= 0 {
+		limit = 500
+	}
+	where, params := turnStatsWhere(q)
+	sql := `SELECT component AS agent_id,
+		       to_char((started_at AT TIME ZONE $1)::date, 'YYYY-MM-DD') AS day,
+		       status,
+		       COALESCE(NULLIF(metadata->>'reason', ''), 'unknown') AS reason,
+		       COUNT(*) AS count
+		FROM task_traces
+		WHERE ` + where + ` AND status IN ('interrupted', 'failed')
+		GROUP BY 1, 2, 3, 4
+		ORDER BY day DESC, count DESC
+		LIMIT ` + strconv.Itoa(limit)
+	rows, err := s.pool.Query(ctx, sql, append([]any{turnStatsTimeZone(q.TimeZone)}, params...)...)
+	if err != nil {
+		return nil, err
+	}
+	return collectRows[TurnReasonRow](rows)
+}
@@ -0,0 +1,48 @@
+// ui_runtime_state.go — UI 运行时状态快照 (表 ui_runtime_state, 跨重启恢复)。
+package store
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+// UIRuntimeStateStore UI 运行时状态存储 (每实例一行 JSON)。
+type UIRuntimeStateStore struct{ BaseStore }
+
+// NewUIRuntimeStateStore 创建。
+func NewUIRuntimeStateStore(pool *pgxpool.Pool) *UIRuntimeStateStore {
+	return &UIRuntimeStateStore{NewBaseStore(pool)}
+}
+
+// Load 读取实例的状态快照, 不存在返回 nil。
+func (s *UIRuntimeStateStore) Load(ctx context.Context, instanceID string) (json.RawMessage, error) {
+	var state json.RawMessage
+	err := s.pool.QueryRow(ctx, "SELECT state FROM ui_runtime_state WHERE instance_id = $1", instanceID).Scan(&state)
+	if err != nil {
+		if stderrors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, apperrors.Wrap(err, "UIRuntimeStateStore.Load", "query state")
+	}
+	return state, nil
+}
+
+// Save 覆盖写入实例的状态快照。
+func (s *UIRuntimeStateStore) Save(ctx context.Context, instanceID string, state json.RawMessage) error {
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO ui_runtime_state (instance_id, state, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (instance_id) DO UPDATE SET
+			state = EXCLUDED.state,
+			updated_at = NOW()
+	`, instanceID, state)
+	if err != nil {
+		return apperrors.Wrap(err, "UIRuntimeStateStore.Save", "upsert state")
+	}
+	return nil
+}
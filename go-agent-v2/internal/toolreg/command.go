@@ -0,0 +1,109 @@
+// command.go — 外部命令工具: 参数 JSON 写入 stdin, stdout 作为结果, 非零退出视为失败。
+//
+// 环境变量: 继承当前进程, 叠加配置 env, 并注入 TOOL_NAME / TOOL_AGENT_ID / TOOL_CALL_ID。
+package toolreg
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+const (
+	defaultCommandTimeout = 60 * time.Second
+	maxCommandOutputBytes = 256 << 10
+	maxCommandStderrBytes = 4 << 10
+)
+
+// CommandSpec 配置文件中的外部命令工具。
+type CommandSpec struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	InputSchema map[string]any    `json:"inputSchema,omitempty"`
+	Command     []string          `json:"command"` // argv, 不经 shell
+	Cwd         string            `json:"cwd,omitempty"`
+	Env         map[string]string `json:"env,omitempty"`
+	TimeoutSec  int               `json:"timeoutSec,omitempty"`
+}
+
+// limitedBuffer 超出上限后丢弃写入并标记截断。
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.buf.Len(); room < len(p) {
+		if room > 0 {
+			b.buf.Write(p[:room])
+		}
+		b.truncated = true
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+// Tool 构造注册表工具。
+func (spec CommandSpec) Tool(origin string) (Tool, error) {
+	if len(spec.Command) == 0 || strings.TrimSpace(spec.Command[0]) == "" {
+		return Tool{}, apperrors.Newf("toolreg.CommandSpec", "tool %q: command is required", spec.Name)
+	}
+	schema := spec.InputSchema
+	if schema == nil {
+		schema = map[string]any{"type": "object", "properties": map[string]any{}}
+	}
+	tool := Tool{
+		Name:        spec.Name,
+		Description: spec.Description,
+		InputSchema: schema,
+		Source:      SourceCommand,
+		Origin:      origin,
+		Handler:     spec.run,
+	}
+	return tool, tool.Validate()
+}
+
+func (spec CommandSpec) run(ctx context.Context, call Call) (string, error) {
+	const op = "toolreg.runCommand"
+	timeout := defaultCommandTimeout
+	if spec.TimeoutSec > 0 {
+		timeout = time.Duration(spec.TimeoutSec) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, spec.Command[0], spec.Command[1:]...)
+	cmd.Dir = spec.Cwd
+	cmd.Env = os.Environ()
+	for key, value := range spec.Env {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+	cmd.Env = append(cmd.Env, "TOOL_NAME="+spec.Name, "TOOL_AGENT_ID="+call.AgentID, "TOOL_CALL_ID="+call.CallID)
+	args := call.Arguments
+	if len(bytes.TrimSpace(args)) == 0 {
+		args = []byte("{}")
+	}
+	cmd.Stdin = bytes.NewReader(args)
+	stdout := &limitedBuffer{limit: maxCommandOutputBytes}
+	stderr := &limitedBuffer{limit: maxCommandStderrBytes}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", apperrors.Newf(op, "tool %s timed out after %s", spec.Name, timeout)
+		}
+		return "", apperrors.Wrapf(err, op, "tool %s failed: %s", spec.Name, strings.TrimSpace(stderr.buf.String()))
+	}
+	out := stdout.buf.String()
+	if stdout.truncated {
+		out += "\n[output truncated]"
+	}
+	return out, nil
+}
@@ -0,0 +1,52 @@
+// config.go — 动态工具配置文件 (DYNAMIC_TOOLS_FILE, JSON)。
+//
+//	{
+//	  "tools":   [{"name": "jira_search", "description": "...", "inputSchema": {...},
+//	               "command": ["python3", "jira.py"], "cwd": "...", "env": {...}, "timeoutSec": 60}],
+//	  "plugins": [{"name": "corp", "grpc": "127.0.0.1:7001", "timeoutSec": 30}],
+//	  "wasm":    [{"name": "lint", "path": "lint.wasm", "readRoots": ["/repo"], "httpAllow": ["*.example.com"]}]
+//	}
+package toolreg
+
+import (
+	"encoding/json"
+	"os"
+
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+// Config 配置文件内容。
+type Config struct {
+	Tools   []CommandSpec `json:"tools,omitempty"`
+	Plugins []PluginSpec  `json:"plugins,omitempty"`
+	WASM    []WASMSpec    `json:"wasm,omitempty"`
+}
+
+// LoadConfig 读取配置文件; 文件不存在时返回 (nil, nil)。
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, apperrors.Wrap(err, "toolreg.LoadConfig", "read config")
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, apperrors.Wrap(err, "toolreg.LoadConfig", "decode config")
+	}
+	return &cfg, nil
+}
+
+// CommandTools 构造配置中的命令工具 (任一无效即返回错误)。
+func (c *Config) CommandTools(origin string) ([]Tool, error) {
+	tools := make([]Tool, 0, len(c.Tools))
+	for _, spec := range c.Tools {
+		tool, err := spec.Tool(origin)
+		if err != nil {
+			return nil, err
+		}
+		tools = append(tools, tool)
+	}
+	return tools, nil
+}
@@ -0,0 +1,264 @@
+// grpc.go — gRPC 插件: 经 toolreg.v1.ToolPlugin 服务 (plugin.proto) 提供工具。
+//
+// 只使用一元调用, 报文以 protowire 手工编解码 (无 protoc 生成代码); 传输为 HTTP/2:
+// "host:port" / "http://host:port" 走明文 h2c, "https://host:port" 走 TLS。
+package toolreg
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+const (
+	grpcServicePath       = "/toolreg.v1.ToolPlugin/"
+	defaultPluginTimeout  = 30 * time.Second
+	maxGRPCResponseBytes  = 4 << 20
+	grpcFrameHeaderLength = 5
+)
+
+// PluginSpec 配置文件中的 gRPC 插件。
+type PluginSpec struct {
+	Name       string `json:"name"`
+	GRPC       string `json:"grpc"` // 插件地址
+	TimeoutSec int    `json:"timeoutSec,omitempty"`
+}
+
+func (spec PluginSpec) timeout() time.Duration {
+	if spec.TimeoutSec > 0 {
+		return time.Duration(spec.TimeoutSec) * time.Second
+	}
+	return defaultPluginTimeout
+}
+
+// grpcClient 最小化的一元 gRPC 客户端。
+type grpcClient struct {
+	base string
+	http *http.Client
+}
+
+func newGRPCClient(addr string) *grpcClient {
+	addr = strings.TrimRight(strings.TrimSpace(addr), "/")
+	protocols := new(http.Protocols)
+	base := addr
+	if strings.HasPrefix(addr, "https://") {
+		protocols.SetHTTP2(true)
+	} else {
+		protocols.SetUnencryptedHTTP2(true)
+		base = "http://" + strings.TrimPrefix(addr, "http://")
+	}
+	return &grpcClient{base: base, http: &http.Client{Transport: &http.Transport{Protocols: protocols}}}
+}
+
+// unary 发送一元调用, 返回响应报文。
+func (c *grpcClient) unary(ctx context.Context, method string, msg []byte) ([]byte, error) {
+	const op = "toolreg.grpcUnary"
+	frame := make([]byte, grpcFrameHeaderLength, grpcFrameHeaderLength+len(msg))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	frame = append(frame, msg...)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.base+grpcServicePath+method, bytes.NewReader(frame))
+	if err != nil {
+		return nil, apperrors.Wrap(err, op, "build request")
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, apperrors.Wrapf(err, op, "call %s", method)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, apperrors.Newf(op, "call %s: HTTP %d", method, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxGRPCResponseBytes+grpcFrameHeaderLength))
+	if err != nil {
+		return nil, apperrors.Wrapf(err, op, "read %s response", method)
+	}
+	status := resp.Trailer.Get("Grpc-Status")
+	message := resp.Trailer.Get("Grpc-Message")
+	if status == "" { // trailers-only 响应
+		status, message = resp.Header.Get("Grpc-Status"), resp.Header.Get("Grpc-Message")
+	}
+	if status != "0" {
+		if decoded, err := url.PathUnescape(message); err == nil {
+			message = decoded
+		}
+		return nil, apperrors.Newf(op, "call %s: grpc status %s: %s", method, status, message)
+	}
+	if len(body) < grpcFrameHeaderLength {
+		return nil, apperrors.Newf(op, "call %s: empty response", method)
+	}
+	if body[0] != 0 {
+		return nil, apperrors.Newf(op, "call %s: compressed responses are not supported", method)
+	}
+	size := binary.BigEndian.Uint32(body[1:grpcFrameHeaderLength])
+	if int(size) > len(body)-grpcFrameHeaderLength {
+		return nil, apperrors.Newf(op, "call %s: truncated response frame", method)
+	}
+	return body[grpcFrameHeaderLength : grpcFrameHeaderLength+int(size)], nil
+}
+
+// ========================================
+// 报文编解码 (字段号见 plugin.proto)
+// ========================================
+
+func appendStringField(b []byte, num protowire.Number, value string) []byte {
+	if value == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, value)
+}
+
+// decodeFields 遍历报文, 对 bytes 类型字段回调 (其余类型跳过)。
+func decodeFields(msg []byte, fn func(num protowire.Number, value []byte) error) error {
+	for len(msg) > 0 {
+		num, typ, n := protowire.ConsumeTag(msg)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		msg = msg[n:]
+		if typ != protowire.BytesType {
+			n = protowire.ConsumeFieldValue(num, typ, msg)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			msg = msg[n:]
+			continue
+		}
+		value, n := protowire.ConsumeBytes(msg)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		msg = msg[n:]
+		if err := fn(num, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type pluginToolSpec struct {
+	Name            string
+	Description     string
+	InputSchemaJSON string
+}
+
+func decodeListToolsResponse(msg []byte) ([]pluginToolSpec, error) {
+	var out []pluginToolSpec
+	err := decodeFields(msg, func(num protowire.Number, value []byte) error {
+		if num != 1 {
+			return nil
+		}
+		var spec pluginToolSpec
+		if err := decodeFields(value, func(num protowire.Number, value []byte) error {
+			switch num {
+			case 1:
+				spec.Name = string(value)
+			case 2:
+				spec.Description = string(value)
+			case 3:
+				spec.InputSchemaJSON = string(value)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		out = append(out, spec)
+		return nil
+	})
+	return out, err
+}
+
+func encodeCallToolRequest(name string, call Call) []byte {
+	var b []byte
+	b = appendStringField(b, 1, name)
+	b = appendStringField(b, 2, string(call.Arguments))
+	b = appendStringField(b, 3, call.AgentID)
+	b = appendStringField(b, 4, call.CallID)
+	return b
+}
+
+func decodeCallToolResponse(msg []byte) (result, toolErr string, err error) {
+	err = decodeFields(msg, func(num protowire.Number, value []byte) error {
+		switch num {
+		case 1:
+			result = string(value)
+		case 2:
+			toolErr = string(value)
+		}
+		return nil
+	})
+	return result, toolErr, err
+}
+
+// ========================================
+// 插件加载
+// ========================================
+
+// LoadPlugin 调用 ListTools 拉取插件工具, 工具调用经 CallTool 转发。
+func LoadPlugin(ctx context.Context, spec PluginSpec) ([]Tool, error) {
+	const op = "toolreg.LoadPlugin"
+	if strings.TrimSpace(spec.GRPC) == "" {
+		return nil, apperrors.Newf(op, "plugin %q: grpc address is required", spec.Name)
+	}
+	client := newGRPCClient(spec.GRPC)
+	listCtx, cancel := context.WithTimeout(ctx, spec.timeout())
+	defer cancel()
+	msg, err := client.unary(listCtx, "ListTools", nil)
+	if err != nil {
+		return nil, apperrors.Wrapf(err, op, "plugin %s", spec.Name)
+	}
+	specs, err := decodeListToolsResponse(msg)
+	if err != nil {
+		return nil, apperrors.Wrapf(err, op, "plugin %s: decode ListTools", spec.Name)
+	}
+	tools := make([]Tool, 0, len(specs))
+	for _, ts := range specs {
+		schema := map[string]any{"type": "object", "properties": map[string]any{}}
+		if strings.TrimSpace(ts.InputSchemaJSON) != "" {
+			if err := json.Unmarshal([]byte(ts.InputSchemaJSON), &schema); err != nil {
+				return nil, apperrors.Wrapf(err, op, "plugin %s: tool %s input schema", spec.Name, ts.Name)
+			}
+		}
+		name := ts.Name
+		tool := Tool{
+			Name:        name,
+			Description: ts.Description,
+			InputSchema: schema,
+			Source:      SourceGRPC,
+			Origin:      spec.Name,
+			Handler: func(ctx context.Context, call Call) (string, error) {
+				callCtx, cancel := context.WithTimeout(ctx, spec.timeout())
+				defer cancel()
+				resp, err := client.unary(callCtx, "CallTool", encodeCallToolRequest(name, call))
+				if err != nil {
+					return "", err
+				}
+				result, toolErr, err := decodeCallToolResponse(resp)
+				if err != nil {
+					return "", apperrors.Wrapf(err, "toolreg.CallTool", "decode %s response", name)
+				}
+				if toolErr != "" {
+					return "", apperrors.Newf("toolreg.CallTool", "tool %s: %s", name, toolErr)
+				}
+				return result, nil
+			},
+		}
+		if err := tool.Validate(); err != nil {
+			return nil, apperrors.Wrapf(err, op, "plugin %s", spec.Name)
+		}
+		tools = append(tools, tool)
+	}
+	return tools, nil
+}
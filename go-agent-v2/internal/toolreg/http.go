@@ -0,0 +1,232 @@
+// http.go — HTTP 工具: 项目 .agent/tools.yaml 声明的 HTTP 端点, 无需编写代码即可接入。
+//
+//	tools:
+//	  - name: jira_search
+//	    description: Search Jira issues
+//	    url: https://jira.example.com/rest/api/2/search
+//	    method: GET                 # GET/DELETE 参数转为查询串, 其余以 JSON 请求体发送
+//	    inputSchema: {type: object, properties: {jql: {type: string}}}
+//	    auth: {header: Authorization, value: "Bearer ${AGENT_TOOL_JIRA_TOKEN}"}
+//	    headers: {X-Team: platform}
+//	    timeoutSec: 30
+//
+// url / auth.value / headers 中的 ${VAR} 在调用时以 app-server 进程环境变量展开, 密钥无需写入仓库;
+// 仅展开 AGENT_TOOL_ 前缀的变量, 防止不可信仓库把其他密钥发往任意端点。
+package toolreg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+// ProjectToolsFile 项目级 HTTP 工具声明文件 (相对项目根目录)。
+const ProjectToolsFile = ".agent/tools.yaml"
+
+const (
+	httpToolEnvPrefix      = "AGENT_TOOL_"
+	defaultHTTPToolTimeout = 30 * time.Second
+	maxHTTPResponseBytes   = 256 << 10
+	maxHTTPErrorBodyBytes  = 1 << 10
+)
+
+// HTTPAuth 认证头 (value 支持 ${VAR} 展开)。
+type HTTPAuth struct {
+	Header string `yaml:"header" json:"header"`
+	Value  string `yaml:"value" json:"value"`
+}
+
+// HTTPSpec .agent/tools.yaml 中的单个 HTTP 工具。
+type HTTPSpec struct {
+	Name        string            `yaml:"name" json:"name"`
+	Description string            `yaml:"description" json:"description"`
+	URL         string            `yaml:"url" json:"url"`
+	Method      string            `yaml:"method" json:"method,omitempty"` // 默认 POST
+	InputSchema map[string]any    `yaml:"inputSchema" json:"inputSchema,omitempty"`
+	Auth        *HTTPAuth         `yaml:"auth" json:"auth,omitempty"`
+	Headers     map[string]string `yaml:"headers" json:"headers,omitempty"`
+	TimeoutSec  int               `yaml:"timeoutSec" json:"timeoutSec,omitempty"`
+}
+
+// Tool 构造注册表工具。
+func (spec HTTPSpec) Tool(origin string) (Tool, error) {
+	const op = "toolreg.HTTPSpec"
+	if strings.TrimSpace(spec.URL) == "" {
+		return Tool{}, apperrors.Newf(op, "tool %q: url is required", spec.Name)
+	}
+	switch spec.method() {
+	case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+	default:
+		return Tool{}, apperrors.Newf(op, "tool %q: unsupported method %s", spec.Name, spec.Method)
+	}
+	if spec.Auth != nil && strings.TrimSpace(spec.Auth.Header) == "" {
+		return Tool{}, apperrors.Newf(op, "tool %q: auth.header is required", spec.Name)
+	}
+	schema := spec.InputSchema
+	if schema == nil {
+		schema = map[string]any{"type": "object", "properties": map[string]any{}}
+	}
+	tool := Tool{
+		Name:        spec.Name,
+		Description: spec.Description,
+		InputSchema: schema,
+		Source:      SourceHTTP,
+		Origin:      origin,
+		Handler:     spec.call,
+	}
+	return tool, tool.Validate()
+}
+
+// expandToolEnv 展开 ${VAR}: 非 AGENT_TOOL_ 前缀的变量替换为空串。
+func expandToolEnv(value string) string {
+	return os.Expand(value, func(key string) string {
+		if !strings.HasPrefix(key, httpToolEnvPrefix) {
+			return ""
+		}
+		return os.Getenv(key)
+	})
+}
+
+func (spec HTTPSpec) method() string {
+	if m := strings.ToUpper(strings.TrimSpace(spec.Method)); m != "" {
+		return m
+	}
+	return http.MethodPost
+}
+
+// buildRequest 按方法组装请求: GET/DELETE 参数转查询串, 其余为 JSON 请求体。
+func (spec HTTPSpec) buildRequest(ctx context.Context, args json.RawMessage) (*http.Request, error) {
+	const op = "toolreg.httpRequest"
+	if len(bytes.TrimSpace(args)) == 0 {
+		args = []byte("{}")
+	}
+	target, err := url.Parse(expandToolEnv(spec.URL))
+	if err != nil {
+		return nil, apperrors.Wrapf(err, op, "tool %s: parse url", spec.Name)
+	}
+	method := spec.method()
+	var body io.Reader
+	if method == http.MethodGet || method == http.MethodDelete {
+		var params map[string]any
+		if err := json.Unmarshal(args, &params); err != nil {
+			return nil, apperrors.Wrapf(err, op, "tool %s: arguments must be an object", spec.Name)
+		}
+		query := target.Query()
+		for key, value := range params {
+			switch v := value.(type) {
+			case nil:
+			case string:
+				query.Set(key, v)
+			case []any:
+				for _, item := range v {
+					query.Add(key, fmt.Sprint(item))
+				}
+			case map[string]any:
+				raw, _ := json.Marshal(v)
+				query.Set(key, string(raw))
+			default:
+				query.Set(key, fmt.Sprint(v))
+			}
+		}
+		target.RawQuery = query.Encode()
+	} else {
+		body = bytes.NewReader(args)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), body)
+	if err != nil {
+		return nil, apperrors.Wrapf(err, op, "tool %s: build request", spec.Name)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for key, value := range spec.Headers {
+		req.Header.Set(key, expandToolEnv(value))
+	}
+	if spec.Auth != nil {
+		req.Header.Set(spec.Auth.Header, expandToolEnv(spec.Auth.Value))
+	}
+	return req, nil
+}
+
+func (spec HTTPSpec) call(ctx context.Context, call Call) (string, error) {
+	const op = "toolreg.callHTTP"
+	timeout := defaultHTTPToolTimeout
+	if spec.TimeoutSec > 0 {
+		timeout = time.Duration(spec.TimeoutSec) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := spec.buildRequest(ctx, call.Arguments)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Tool-Agent-Id", call.AgentID)
+	req.Header.Set("X-Tool-Call-Id", call.CallID)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", apperrors.Wrapf(err, op, "tool %s", spec.Name)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPResponseBytes+1))
+	if err != nil {
+		return "", apperrors.Wrapf(err, op, "tool %s: read response", spec.Name)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		snippet := data
+		if len(snippet) > maxHTTPErrorBodyBytes {
+			snippet = snippet[:maxHTTPErrorBodyBytes]
+		}
+		return "", apperrors.Newf(op, "tool %s: HTTP %d: %s", spec.Name, resp.StatusCode, strings.TrimSpace(string(snippet)))
+	}
+	if len(data) > maxHTTPResponseBytes {
+		return "", apperrors.Newf(op, "tool %s: response exceeds %d bytes", spec.Name, maxHTTPResponseBytes)
+	}
+	if json.Valid(data) {
+		return string(data), nil
+	}
+	// 非 JSON 响应包装为 {"result": "..."}
+	wrapped, _ := json.Marshal(map[string]string{"result": string(data)})
+	return string(wrapped), nil
+}
+
+// LoadProjectTools 读取 rootDir 下的 .agent/tools.yaml; 文件不存在时返回 (nil, nil)。
+func LoadProjectTools(rootDir string) ([]Tool, error) {
+	const op = "toolreg.LoadProjectTools"
+	path := filepath.Join(rootDir, ProjectToolsFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, apperrors.Wrap(err, op, "read tools file")
+	}
+	var file struct {
+		Tools []HTTPSpec `yaml:"tools"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, apperrors.Wrapf(err, op, "decode %s", path)
+	}
+	tools := make([]Tool, 0, len(file.Tools))
+	for _, spec := range file.Tools {
+		tool, err := spec.Tool(path)
+		if err != nil {
+			return nil, err
+		}
+		tools = append(tools, tool)
+	}
+	return tools, nil
+}
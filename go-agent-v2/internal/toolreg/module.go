@@ -0,0 +1,34 @@
+// module.go — 构建标签模块: 编译期编入的 Go 工具。
+//
+// 模块文件以 //go:build toolmod_<name> 约束, 在 init 中调用 RegisterModule;
+// 构建时 go build -tags toolmod_<name> 即可启用 (示例见 module_echo.go)。
+package toolreg
+
+import "sync"
+
+var (
+	modulesMu sync.Mutex
+	modules   = map[string][]Tool{}
+)
+
+// RegisterModule 注册模块贡献的工具 (通常在 init 中调用)。
+func RegisterModule(name string, tools ...Tool) {
+	modulesMu.Lock()
+	defer modulesMu.Unlock()
+	for i := range tools {
+		tools[i].Source = SourceModule
+		tools[i].Origin = name
+	}
+	modules[name] = append(modules[name], tools...)
+}
+
+// ModuleTools 按模块名返回已编入的工具。
+func ModuleTools() map[string][]Tool {
+	modulesMu.Lock()
+	defer modulesMu.Unlock()
+	out := make(map[string][]Tool, len(modules))
+	for name, tools := range modules {
+		out[name] = append([]Tool(nil), tools...)
+	}
+	return out
+}
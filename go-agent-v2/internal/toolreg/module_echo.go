@@ -0,0 +1,20 @@
+//go:build toolmod_echo
+
+// module_echo.go — 示例模块: echo 工具原样返回参数 (go build -tags toolmod_echo)。
+package toolreg
+
+import "context"
+
+func init() {
+	RegisterModule("echo", Tool{
+		Name:        "echo",
+		Description: "Echo the given arguments back as JSON. Useful to verify dynamic tool wiring.",
+		InputSchema: map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"text": map[string]any{"type": "string", "description": "Text to echo"}},
+		},
+		Handler: func(_ context.Context, call Call) (string, error) {
+			return string(call.Arguments), nil
+		},
+	})
+}
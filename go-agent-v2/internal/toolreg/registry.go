@@ -0,0 +1,125 @@
+// Package toolreg 动态工具注册表: 汇集外部贡献的 codex 动态工具。
+//
+// 工具来源:
+//   - command: 配置文件声明的外部命令 (参数 JSON 经 stdin 传入, stdout 作为结果)
+//   - grpc:    gRPC 插件 (toolreg.v1.ToolPlugin, 见 plugin.proto), 启动时拉取工具列表
+//   - module:  以构建标签编入的 Go 模块 (init 中调用 RegisterModule)
+//   - wasm:    wazero 沙箱中执行的 .wasm 模块, 宿主能力按插件配置收窄 (见 wasm.go)
+//   - http:    项目 .agent/tools.yaml 声明的 HTTP 端点 (按线程工作目录加载, 不进入全局注册表)
+//
+// 注册表按分组 (来源 + 出处) 整体替换, 便于配置重载; 同名工具先注册者生效。
+package toolreg
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"sync"
+
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+// 工具来源。
+const (
+	SourceBuiltin = "builtin"
+	SourceCommand = "command"
+	SourceGRPC    = "grpc"
+	SourceModule  = "module"
+	SourceHTTP    = "http"
+	SourceWASM    = "wasm"
+)
+
+var toolNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// Call 一次工具调用。
+type Call struct {
+	AgentID   string
+	CallID    string
+	Arguments json.RawMessage
+}
+
+// Handler 执行工具调用, 返回文本结果。
+type Handler func(ctx context.Context, call Call) (string, error)
+
+// Tool 注册表中的一个动态工具。
+type Tool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+	Source      string         `json:"source"`
+	Origin      string         `json:"origin,omitempty"` // 配置文件 / 插件名 / 模块名
+	Handler     Handler        `json:"-"`
+}
+
+// Validate 校验名称与处理函数。
+func (t Tool) Validate() error {
+	if !toolNamePattern.MatchString(t.Name) {
+		return apperrors.Newf("toolreg.Validate", "invalid tool name %q", t.Name)
+	}
+	if t.Handler == nil {
+		return apperrors.Newf("toolreg.Validate", "tool %q has no handler", t.Name)
+	}
+	return nil
+}
+
+// Registry 动态工具注册表 (零值可用, 并发安全)。
+type Registry struct {
+	mu     sync.RWMutex
+	groups map[string][]Tool
+}
+
+// SetGroup 整体替换一个分组的工具 (tools 为空时删除分组)。
+func (r *Registry) SetGroup(group string, tools []Tool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(tools) == 0 {
+		delete(r.groups, group)
+		return
+	}
+	if r.groups == nil {
+		r.groups = make(map[string][]Tool)
+	}
+	r.groups[group] = append([]Tool(nil), tools...)
+}
+
+// Groups 已注册的分组名 (排序)。
+func (r *Registry) Groups() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]string, 0, len(r.groups))
+	for group := range r.groups {
+		out = append(out, group)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Tools 全部工具 (按分组名、组内声明顺序; 同名工具只保留第一个)。
+func (r *Registry) Tools() []Tool {
+	groups := r.Groups()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	seen := map[string]bool{}
+	var out []Tool
+	for _, group := range groups {
+		for _, tool := range r.groups[group] {
+			if seen[tool.Name] {
+				continue
+			}
+			seen[tool.Name] = true
+			out = append(out, tool)
+		}
+	}
+	return out
+}
+
+// Lookup 按名称查找工具。
+func (r *Registry) Lookup(name string) (Tool, bool) {
+	for _, tool := range r.Tools() {
+		if tool.Name == name {
+			return tool, true
+		}
+	}
+	return Tool{}, false
+}
@@ -0,0 +1,87 @@
+//go:build wasip1
+
+// main.go — 测试用 WASM 插件 (GOOS=wasip1 GOARCH=wasm go build -buildmode=c-shared)。
+//
+// 工具: wasm_echo 回显参数; wasm_read 经 read_file 读取 {"path"}; wasm_fetch 经 http_fetch 请求 {"url"}; wasm_spin 死循环。
+package main
+
+import (
+	"encoding/json"
+	"unsafe"
+)
+
+// keep 防止交给宿主的缓冲区被回收。
+var keep = map[uint32][]byte{}
+
+func ptrOf(buf []byte) uint32 {
+	ptr := uint32(uintptr(unsafe.Pointer(unsafe.SliceData(buf))))
+	keep[ptr] = buf
+	return ptr
+}
+
+func pack(buf []byte) uint64 {
+	if len(buf) == 0 {
+		return 0
+	}
+	return uint64(ptrOf(buf))<<32 | uint64(len(buf))
+}
+
+func view(ptr, size uint32) []byte {
+	return unsafe.Slice((*byte)(unsafe.Pointer(uintptr(ptr))), size)
+}
+
+//go:wasmimport agent read_file
+func hostReadFile(ptr, size uint32) uint32
+
+//go:wasmimport agent http_fetch
+func hostHTTPFetch(ptr, size uint32) uint32
+
+//go:wasmimport agent host_result
+func hostResult(ptr, size uint32) uint32
+
+func takeResult(size uint32) []byte {
+	buf := make([]byte, size+1)
+	n := hostResult(ptrOf(buf), size)
+	return buf[:n]
+}
+
+//go:wasmexport alloc
+func alloc(size uint32) uint32 {
+	return ptrOf(make([]byte, size+1))
+}
+
+//go:wasmexport tool_manifest
+func toolManifest() uint64 {
+	manifest, _ := json.Marshal([]map[string]any{
+		{"name": "wasm_echo", "description": "echo arguments"},
+		{"name": "wasm_read", "description": "read a file"},
+		{"name": "wasm_fetch", "description": "fetch a url"},
+		{"name": "wasm_spin", "description": "never returns"},
+	})
+	return pack(manifest)
+}
+
+//go:wasmexport tool_call
+func toolCall(namePtr, nameLen, argsPtr, argsLen uint32) uint64 {
+	name := string(view(namePtr, nameLen))
+	args := append([]byte(nil), view(argsPtr, argsLen)...)
+	var p struct {
+		Path string `json:"path"`
+		URL  string `json:"url"`
+	}
+	_ = json.Unmarshal(args, &p)
+	switch name {
+	case "wasm_read":
+		path := []byte(p.Path)
+		return pack(takeResult(hostReadFile(ptrOf(path), uint32(len(path)))))
+	case "wasm_fetch":
+		req, _ := json.Marshal(map[string]string{"url": p.URL})
+		return pack(takeResult(hostHTTPFetch(ptrOf(req), uint32(len(req)))))
+	case "wasm_spin":
+		for {
+		}
+	}
+	return pack(args)
+}
+
+func main() {}
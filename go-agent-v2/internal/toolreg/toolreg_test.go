@@ -0,0 +1,217 @@
+package toolreg
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func stubTool(name, origin string) Tool {
+	return Tool{Name: name, Origin: origin, Handler: func(context.Context, Call) (string, error) { return origin, nil }}
+}
+
+func TestRegistryGroupsFirstNameWins(t *testing.T) {
+	var reg Registry
+	reg.SetGroup("b", []Tool{stubTool("search", "b"), stubTool("fetch", "b")})
+	reg.SetGroup("a", []Tool{stubTool("search", "a")})
+
+	tools := reg.Tools()
+	if len(tools) != 2 || tools[0].Origin != "a" || tools[1].Name != "fetch" {
+		t.Fatalf("tools = %+v", tools)
+	}
+	if tool, ok := reg.Lookup("search"); !ok || tool.Origin != "a" {
+		t.Fatalf("lookup search = %+v, %v", tool, ok)
+	}
+
+	reg.SetGroup("a", nil)
+	if groups := reg.Groups(); len(groups) != 1 || groups[0] != "b" {
+		t.Fatalf("groups = %v", groups)
+	}
+	if tool, _ := reg.Lookup("search"); tool.Origin != "b" {
+		t.Fatalf("search origin after removal = %q", tool.Origin)
+	}
+}
+
+func TestToolValidate(t *testing.T) {
+	if err := stubTool("bad name", "x").Validate(); err == nil {
+		t.Fatal("expected invalid name error")
+	}
+	if err := (Tool{Name: "ok"}).Validate(); err == nil {
+		t.Fatal("expected missing handler error")
+	}
+}
+
+func TestCommandToolRun(t *testing.T) {
+	tool, err := CommandSpec{
+		Name:    "whoami",
+		Command: []string{"sh", "-c", `printf '%s/%s:' "$TOOL_NAME" "$TOOL_AGENT_ID"; cat`},
+	}.Tool("test")
+	if err != nil {
+		t.Fatalf("tool: %v", err)
+	}
+	got, err := tool.Handler(context.Background(), Call{AgentID: "agent-1", Arguments: []byte(`{"q":1}`)})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if got != `whoami/agent-1:{"q":1}` {
+		t.Fatalf("output = %q", got)
+	}
+
+	failing, _ := CommandSpec{Name: "fail", Command: []string{"sh", "-c", "echo boom >&2; exit 3"}}.Tool("test")
+	if _, err := failing.Handler(context.Background(), Call{}); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("err = %v, want stderr in error", err)
+	}
+	if _, err := (CommandSpec{Name: "empty"}).Tool("test"); err == nil {
+		t.Fatal("expected missing command error")
+	}
+}
+
+// fakePlugin 以 h2c 提供 ToolPlugin 服务: ListTools 返回 reverse, CallTool 回显参数。
+func fakePlugin(t *testing.T) string {
+	t.Helper()
+	writeFrame := func(w http.ResponseWriter, msg []byte) {
+		frame := make([]byte, 5, 5+len(msg))
+		binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status")
+		_, _ = w.Write(append(frame, msg...))
+		w.Header().Set("Grpc-Status", "0")
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(grpcServicePath+"ListTools", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		var spec []byte
+		spec = appendStringField(spec, 1, "reverse")
+		spec = appendStringField(spec, 2, "reverse text")
+		var msg []byte
+		msg = protowire.AppendTag(msg, 1, protowire.BytesType)
+		msg = protowire.AppendBytes(msg, spec)
+		writeFrame(w, msg)
+	})
+	mux.HandleFunc(grpcServicePath+"CallTool", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var name, args, agent string
+		_ = decodeFields(body[5:], func(num protowire.Number, value []byte) error {
+			switch num {
+			case 1:
+				name = string(value)
+			case 2:
+				args = string(value)
+			case 3:
+				agent = string(value)
+			}
+			return nil
+		})
+		writeFrame(w, appendStringField(nil, 1, name+"|"+agent+"|"+args))
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	srv := &http.Server{Handler: mux, Protocols: protocols}
+	go func() { _ = srv.Serve(ln) }()
+	t.Cleanup(func() { _ = srv.Close() })
+	return ln.Addr().String()
+}
+
+func TestLoadPluginOverGRPC(t *testing.T) {
+	addr := fakePlugin(t)
+	tools, err := LoadPlugin(context.Background(), PluginSpec{Name: "fake", GRPC: addr})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(tools) != 1 || tools[0].Name != "reverse" || tools[0].Source != SourceGRPC || tools[0].Origin != "fake" {
+		t.Fatalf("tools = %+v", tools)
+	}
+	got, err := tools[0].Handler(context.Background(), Call{AgentID: "agent-1", Arguments: []byte(`{"text":"abc"}`)})
+	if err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	if got != `reverse|agent-1|{"text":"abc"}` {
+		t.Fatalf("result = %q", got)
+	}
+}
+
+func TestLoadProjectToolsHTTP(t *testing.T) {
+	t.Setenv("AGENT_TOOL_TOKEN", "s3cret")
+	t.Setenv("OTHER_SECRET", "leak")
+	var gotAuth, gotTeam, gotQuery, gotBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth, gotTeam, gotQuery = r.Header.Get("Authorization"), r.Header.Get("X-Team"), r.URL.RawQuery
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		switch r.URL.Path {
+		case "/fail":
+			http.Error(w, "nope", http.StatusForbidden)
+		case "/text":
+			_, _ = io.WriteString(w, "plain")
+		default:
+			_, _ = io.WriteString(w, `{"ok":true}`)
+		}
+	}))
+	defer srv.Close()
+
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, ".agent"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	yamlDoc := `tools:
+  - name: search
+    description: search issues
+    url: ` + srv.URL + `/search
+    method: get
+    inputSchema: {type: object, properties: {q: {type: string}}}
+    auth: {header: Authorization, value: "Bearer ${AGENT_TOOL_TOKEN}"}
+    headers: {X-Team: "${OTHER_SECRET}"}
+  - name: create
+    url: ` + srv.URL + `/create
+  - name: text
+    url: ` + srv.URL + `/text
+  - name: fail
+    url: ` + srv.URL + `/fail
+`
+	if err := os.WriteFile(filepath.Join(root, ProjectToolsFile), []byte(yamlDoc), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tools, err := LoadProjectTools(root)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(tools) != 4 || tools[0].Source != SourceHTTP || tools[0].InputSchema["type"] != "object" {
+		t.Fatalf("tools = %+v", tools)
+	}
+	ctx := context.Background()
+
+	got, err := tools[0].Handler(ctx, Call{Arguments: []byte(`{"q":"bug","n":3}`)})
+	if err != nil || got != `{"ok":true}` {
+		t.Fatalf("search = %q, %v", got, err)
+	}
+	if gotAuth != "Bearer s3cret" || gotTeam != "" || gotQuery != "n=3&q=bug" {
+		t.Fatalf("auth=%q team=%q query=%q", gotAuth, gotTeam, gotQuery)
+	}
+	if _, err := tools[1].Handler(ctx, Call{Arguments: []byte(`{"title":"x"}`)}); err != nil || gotBody != `{"title":"x"}` {
+		t.Fatalf("create body = %q, %v", gotBody, err)
+	}
+	if got, _ := tools[2].Handler(ctx, Call{}); got != `{"result":"plain"}` {
+		t.Fatalf("text = %q", got)
+	}
+	if _, err := tools[3].Handler(ctx, Call{}); err == nil || !strings.Contains(err.Error(), "HTTP 403") {
+		t.Fatalf("fail err = %v", err)
+	}
+
+	if tools, err := LoadProjectTools(t.TempDir()); err != nil || tools != nil {
+		t.Fatalf("missing file = %v, %v", tools, err)
+	}
+}
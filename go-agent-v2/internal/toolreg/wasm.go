@@ -0,0 +1,478 @@
+// wasm.go — WASM 插件: 第三方工具以 .wasm 模块分发, 在 wazero 沙箱中执行 (无原生代码)。
+//
+// 模块 ABI (导出):
+//
+//	memory
+//	alloc(size u32) -> u32                              宿主写入参数前分配内存
+//	tool_manifest() -> u64                              (ptr<<32 | len) 指向 JSON [{name, description, inputSchema}]
+//	tool_call(namePtr, nameLen, argsPtr, argsLen u32) -> u64   (ptr<<32 | len) 指向结果文本
+//
+// 宿主函数 (模块 "agent", 能力按插件配置收窄):
+//
+//	read_file(pathPtr, pathLen u32) -> u32    读取 readRoots 内的文件, 返回结果长度
+//	http_fetch(reqPtr, reqLen u32) -> u32     请求 JSON {method,url,headers,body}, 仅限 httpAllow 主机
+//	host_result(outPtr, outCap u32) -> u32    取回上一次宿主调用的结果 JSON (两步取回, 宿主不回调模块)
+//	log(ptr, len u32)
+//
+// read_file 结果为 {"data": "..."}, http_fetch 结果为 {"status": 200, "body": "..."}, 失败时为 {"error": "..."}。
+// 每次调用实例化新模块 (无跨调用状态), 提供无文件系统 / 无环境变量的 WASI。
+package toolreg
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+	"github.com/multi-agent/go-agent-v2/pkg/logger"
+)
+
+const (
+	wasmHostModule          = "agent"
+	defaultWASMTimeout      = 30 * time.Second
+	defaultWASMMemoryMB     = 128
+	maxWASMReadFileBytes    = 1 << 20
+	maxWASMFetchBytes       = 1 << 20
+	maxWASMResultBytes      = 256 << 10
+	maxWASMStderrBytes      = 4 << 10
+	wasmPagesPerMB          = 16
+	wasmRequiredAllocExport = "alloc"
+)
+
+// WASMSpec 配置文件中的 WASM 插件。
+type WASMSpec struct {
+	Name       string   `json:"name"`
+	Path       string   `json:"path"`                // .wasm 文件
+	ReadRoots  []string `json:"readRoots,omitempty"` // read_file 允许的目录 (空 = 禁用)
+	HTTPAllow  []string `json:"httpAllow,omitempty"` // http_fetch 允许的主机 (支持 *.example.com, 空 = 禁用)
+	TimeoutSec int      `json:"timeoutSec,omitempty"`
+	MemoryMB   int      `json:"memoryMB,omitempty"` // 线性内存上限, 默认 128
+}
+
+func (spec WASMSpec) timeout() time.Duration {
+	if spec.TimeoutSec > 0 {
+		return time.Duration(spec.TimeoutSec) * time.Second
+	}
+	return defaultWASMTimeout
+}
+
+// WASMPlugin 已编译的 WASM 插件 (一个 wazero 运行时)。
+type WASMPlugin struct {
+	spec     WASMSpec
+	roots    []string
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+	tools    []Tool
+	http     *http.Client
+}
+
+// wasmCall 单次调用的宿主状态 (经 context 传给宿主函数)。
+type wasmCall struct {
+	plugin  *WASMPlugin
+	pending []byte
+}
+
+type wasmCallKey struct{}
+
+// LoadWASM 编译模块并读取工具清单。
+func LoadWASM(ctx context.Context, spec WASMSpec) (*WASMPlugin, error) {
+	const op = "toolreg.LoadWASM"
+	data, err := os.ReadFile(spec.Path)
+	if err != nil {
+		return nil, apperrors.Wrapf(err, op, "plugin %s: read module", spec.Name)
+	}
+	memoryMB := spec.MemoryMB
+	if memoryMB <= 0 {
+		memoryMB = defaultWASMMemoryMB
+	}
+	p := &WASMPlugin{spec: spec}
+	for _, root := range spec.ReadRoots {
+		resolved, err := filepath.Abs(root)
+		if err == nil {
+			if real, err := filepath.EvalSymlinks(resolved); err == nil {
+				resolved = real
+			}
+			p.roots = append(p.roots, resolved)
+		}
+	}
+	p.http = &http.Client{
+		Timeout: spec.timeout(),
+		CheckRedirect: func(req *http.Request, _ []*http.Request) error {
+			if !p.hostAllowed(req.URL) {
+				return apperrors.Newf("toolreg.wasmFetch", "redirect to %s not allowed", req.URL.Host)
+			}
+			return nil
+		},
+	}
+
+	p.runtime = wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithCloseOnContextDone(true).
+		WithMemoryLimitPages(uint32(memoryMB*wasmPagesPerMB)))
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, p.runtime); err != nil {
+		p.Close(ctx)
+		return nil, apperrors.Wrapf(err, op, "plugin %s: instantiate wasi", spec.Name)
+	}
+	if _, err := p.runtime.NewHostModuleBuilder(wasmHostModule).
+		NewFunctionBuilder().WithFunc(hostReadFile).Export("read_file").
+		NewFunctionBuilder().WithFunc(hostHTTPFetch).Export("http_fetch").
+		NewFunctionBuilder().WithFunc(hostResult).Export("host_result").
+		NewFunctionBuilder().WithFunc(hostLog).Export("log").
+		Instantiate(ctx); err != nil {
+		p.Close(ctx)
+		return nil, apperrors.Wrapf(err, op, "plugin %s: instantiate host module", spec.Name)
+	}
+	p.compiled, err = p.runtime.CompileModule(ctx, data)
+	if err != nil {
+		p.Close(ctx)
+		return nil, apperrors.Wrapf(err, op, "plugin %s: compile module", spec.Name)
+	}
+	exports := p.compiled.ExportedFunctions()
+	for _, name := range []string{wasmRequiredAllocExport, "tool_manifest", "tool_call"} {
+		if _, ok := exports[name]; !ok {
+			p.Close(ctx)
+			return nil, apperrors.Newf(op, "plugin %s: missing export %s", spec.Name, name)
+		}
+	}
+
+	manifest, err := p.invoke(ctx, func(ctx context.Context, mod api.Module) ([]byte, error) {
+		return callPacked(ctx, mod, "tool_manifest")
+	})
+	if err != nil {
+		p.Close(ctx)
+		return nil, apperrors.Wrapf(err, op, "plugin %s: read manifest", spec.Name)
+	}
+	var specs []struct {
+		Name        string         `json:"name"`
+		Description string         `json:"description"`
+		InputSchema map[string]any `json:"inputSchema"`
+	}
+	if err := json.Unmarshal(manifest, &specs); err != nil {
+		p.Close(ctx)
+		return nil, apperrors.Wrapf(err, op, "plugin %s: decode manifest", spec.Name)
+	}
+	for _, ts := range specs {
+		schema := ts.InputSchema
+		if schema == nil {
+			schema = map[string]any{"type": "object", "properties": map[string]any{}}
+		}
+		name := ts.Name
+		tool := Tool{
+			Name:        name,
+			Description: ts.Description,
+			InputSchema: schema,
+			Source:      SourceWASM,
+			Origin:      spec.Name,
+			Handler: func(ctx context.Context, call Call) (string, error) {
+				return p.call(ctx, name, call)
+			},
+		}
+		if err := tool.Validate(); err != nil {
+			p.Close(ctx)
+			return nil, apperrors.Wrapf(err, op, "plugin %s", spec.Name)
+		}
+		p.tools = append(p.tools, tool)
+	}
+	return p, nil
+}
+
+// Tools 插件提供的工具。
+func (p *WASMPlugin) Tools() []Tool {
+	return append([]Tool(nil), p.tools...)
+}
+
+// Close 释放运行时 (进行中的调用随之失败)。
+func (p *WASMPlugin) Close(ctx context.Context) {
+	if p.runtime != nil {
+		_ = p.runtime.Close(ctx)
+	}
+}
+
+func (p *WASMPlugin) call(ctx context.Context, name string, call Call) (string, error) {
+	args := call.Arguments
+	if len(bytes.TrimSpace(args)) == 0 {
+		args = []byte("{}")
+	}
+	out, err := p.invoke(ctx, func(ctx context.Context, mod api.Module) ([]byte, error) {
+		namePtr, err := writeGuest(ctx, mod, []byte(name))
+		if err != nil {
+			return nil, err
+		}
+		argsPtr, err := writeGuest(ctx, mod, args)
+		if err != nil {
+			return nil, err
+		}
+		return callPacked(ctx, mod, "tool_call", uint64(namePtr), uint64(len(name)), uint64(argsPtr), uint64(len(args)))
+	})
+	if err != nil {
+		return "", apperrors.Wrapf(err, "toolreg.CallWASM", "tool %s", name)
+	}
+	return string(out), nil
+}
+
+// invoke 实例化一个新模块执行 fn, 超时后中断。
+func (p *WASMPlugin) invoke(ctx context.Context, fn func(context.Context, api.Module) ([]byte, error)) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.spec.timeout())
+	defer cancel()
+	ctx = context.WithValue(ctx, wasmCallKey{}, &wasmCall{plugin: p})
+	stderr := &limitedBuffer{limit: maxWASMStderrBytes}
+	mod, err := p.runtime.InstantiateModule(ctx, p.compiled, wazero.NewModuleConfig().
+		WithName("").
+		WithStartFunctions("_initialize").
+		WithStderr(stderr).
+		WithRandSource(rand.Reader).
+		WithSysWalltime().
+		WithSysNanotime())
+	if err != nil {
+		return nil, wasmError(ctx, err, stderr)
+	}
+	defer mod.Close(context.Background())
+	out, err := fn(ctx, mod)
+	if err != nil {
+		return nil, wasmError(ctx, err, stderr)
+	}
+	return out, nil
+}
+
+func wasmError(ctx context.Context, err error, stderr *limitedBuffer) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return apperrors.New("toolreg.wasmInvoke", "timed out")
+	}
+	if msg := strings.TrimSpace(stderr.buf.String()); msg != "" {
+		return apperrors.Wrapf(err, "toolreg.wasmInvoke", "stderr: %s", msg)
+	}
+	return err
+}
+
+// writeGuest 经模块 alloc 分配内存并写入数据。
+func writeGuest(ctx context.Context, mod api.Module, data []byte) (uint32, error) {
+	res, err := mod.ExportedFunction(wasmRequiredAllocExport).Call(ctx, uint64(len(data)))
+	if err != nil {
+		return 0, err
+	}
+	ptr := uint32(res[0])
+	if !mod.Memory().Write(ptr, data) {
+		return 0, apperrors.Newf("toolreg.writeGuest", "alloc returned out-of-range pointer %d", ptr)
+	}
+	return ptr, nil
+}
+
+// callPacked 调用返回 (ptr<<32 | len) 的导出函数并复制结果。
+func callPacked(ctx context.Context, mod api.Module, name string, params ...uint64) ([]byte, error) {
+	res, err := mod.ExportedFunction(name).Call(ctx, params...)
+	if err != nil {
+		return nil, err
+	}
+	if len(res) != 1 {
+		return nil, apperrors.Newf("toolreg.callPacked", "%s must return one i64", name)
+	}
+	ptr, size := uint32(res[0]>>32), uint32(res[0])
+	if size > maxWASMResultBytes {
+		return nil, apperrors.Newf("toolreg.callPacked", "%s result exceeds %d bytes", name, maxWASMResultBytes)
+	}
+	view, ok := mod.Memory().Read(ptr, size)
+	if !ok {
+		return nil, apperrors.Newf("toolreg.callPacked", "%s returned out-of-range result", name)
+	}
+	return bytes.Clone(view), nil
+}
+
+// ========================================
+// 宿主函数
+// ========================================
+
+func callState(ctx context.Context) *wasmCall {
+	state, _ := ctx.Value(wasmCallKey{}).(*wasmCall)
+	return state
+}
+
+// setPending 保存宿主结果供 host_result 取回, 返回长度。
+func (c *wasmCall) setPending(v any) uint32 {
+	data, err := json.Marshal(v)
+	if err != nil {
+		data = []byte(`{"error":"encode result"}`)
+	}
+	c.pending = data
+	return uint32(len(data))
+}
+
+func readGuest(mod api.Module, ptr, size uint32) ([]byte, bool) {
+	view, ok := mod.Memory().Read(ptr, size)
+	if !ok {
+		return nil, false
+	}
+	return bytes.Clone(view), true
+}
+
+func hostReadFile(ctx context.Context, mod api.Module, pathPtr, pathLen uint32) uint32 {
+	state := callState(ctx)
+	if state == nil {
+		return 0
+	}
+	raw, ok := readGuest(mod, pathPtr, pathLen)
+	if !ok {
+		return state.setPending(map[string]string{"error": "path out of range"})
+	}
+	data, err := state.plugin.readFile(string(raw))
+	if err != nil {
+		return state.setPending(map[string]string{"error": err.Error()})
+	}
+	return state.setPending(map[string]string{"data": string(data)})
+}
+
+func hostHTTPFetch(ctx context.Context, mod api.Module, reqPtr, reqLen uint32) uint32 {
+	state := callState(ctx)
+	if state == nil {
+		return 0
+	}
+	raw, ok := readGuest(mod, reqPtr, reqLen)
+	if !ok {
+		return state.setPending(map[string]string{"error": "request out of range"})
+	}
+	status, body, err := state.plugin.fetch(ctx, raw)
+	if err != nil {
+		return state.setPending(map[string]string{"error": err.Error()})
+	}
+	return state.setPending(map[string]any{"status": status, "body": body})
+}
+
+func hostResult(ctx context.Context, mod api.Module, outPtr, outCap uint32) uint32 {
+	state := callState(ctx)
+	if state == nil {
+		return 0
+	}
+	data := state.pending
+	if uint32(len(data)) > outCap {
+		data = data[:outCap]
+	}
+	if !mod.Memory().Write(outPtr, data) {
+		return 0
+	}
+	state.pending = nil
+	return uint32(len(data))
+}
+
+func hostLog(ctx context.Context, mod api.Module, ptr, size uint32) {
+	state := callState(ctx)
+	if state == nil {
+		return
+	}
+	if raw, ok := readGuest(mod, ptr, min(size, maxWASMStderrBytes)); ok {
+		logger.Info("toolreg: wasm plugin log", logger.FieldName, state.plugin.spec.Name, "message", string(raw))
+	}
+}
+
+// readFile 读取 readRoots 内的文件 (解析符号链接后校验, 相对路径基于第一个根目录)。
+func (p *WASMPlugin) readFile(path string) ([]byte, error) {
+	const op = "toolreg.wasmReadFile"
+	if len(p.roots) == 0 {
+		return nil, apperrors.New(op, "read_file capability not granted")
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(p.roots[0], path)
+	}
+	resolved, err := filepath.EvalSymlinks(filepath.Clean(path))
+	if err != nil {
+		return nil, apperrors.Wrap(err, op, "resolve path")
+	}
+	allowed := false
+	for _, root := range p.roots {
+		if rel, err := filepath.Rel(root, resolved); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return nil, apperrors.Newf(op, "path %s is outside readRoots", path)
+	}
+	f, err := os.Open(resolved)
+	if err != nil {
+		return nil, apperrors.Wrap(err, op, "open file")
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxWASMReadFileBytes+1))
+	if err != nil {
+		return nil, apperrors.Wrap(err, op, "read file")
+	}
+	if len(data) > maxWASMReadFileBytes {
+		return nil, apperrors.Newf(op, "file exceeds %d bytes", maxWASMReadFileBytes)
+	}
+	return data, nil
+}
+
+// hostAllowed 主机是否在 httpAllow 内 (精确匹配或 *.suffix)。
+func (p *WASMPlugin) hostAllowed(u *url.URL) bool {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, pattern := range p.spec.HTTPAllow {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == host {
+			return true
+		}
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok && strings.HasSuffix(host, "."+suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *WASMPlugin) fetch(ctx context.Context, raw []byte) (int, string, error) {
+	const op = "toolreg.wasmFetch"
+	var req struct {
+		Method  string            `json:"method"`
+		URL     string            `json:"url"`
+		Headers map[string]string `json:"headers"`
+		Body    string            `json:"body"`
+	}
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return 0, "", apperrors.Wrap(err, op, "decode request")
+	}
+	target, err := url.Parse(req.URL)
+	if err != nil {
+		return 0, "", apperrors.Wrap(err, op, "parse url")
+	}
+	if !p.hostAllowed(target) {
+		return 0, "", apperrors.Newf(op, "host %q not in httpAllow", target.Hostname())
+	}
+	method := strings.ToUpper(strings.TrimSpace(req.Method))
+	if method == "" {
+		method = http.MethodGet
+	}
+	var body io.Reader
+	if req.Body != "" {
+		body = strings.NewReader(req.Body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, method, target.String(), body)
+	if err != nil {
+		return 0, "", apperrors.Wrap(err, op, "build request")
+	}
+	for key, value := range req.Headers {
+		httpReq.Header.Set(key, value)
+	}
+	resp, err := p.http.Do(httpReq)
+	if err != nil {
+		return 0, "", apperrors.Wrap(err, op, "do request")
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxWASMFetchBytes+1))
+	if err != nil {
+		return 0, "", apperrors.Wrap(err, op, "read response")
+	}
+	if len(data) > maxWASMFetchBytes {
+		return 0, "", apperrors.Newf(op, "response exceeds %d bytes", maxWASMFetchBytes)
+	}
+	return resp.StatusCode, string(data), nil
+}
@@ -0,0 +1,91 @@
+package toolreg
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// buildTestWASMPlugin 编译 testdata/wasmplugin (需要 Go 工具链支持 wasip1)。
+func buildTestWASMPlugin(t *testing.T) string {
+	t.Helper()
+	out := filepath.Join(t.TempDir(), "plugin.wasm")
+	cmd := exec.Command("go", "build", "-buildmode=c-shared", "-o", out, "./testdata/wasmplugin/main.go")
+	cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm")
+	if msg, err := cmd.CombinedOutput(); err != nil {
+		t.Skipf("build wasm plugin: %v\n%s", err, msg)
+	}
+	return out
+}
+
+func TestWASMPluginCapabilities(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles a wasm module")
+	}
+	path := buildTestWASMPlugin(t)
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "notes.txt"), []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	secret := filepath.Join(t.TempDir(), "secret.txt")
+	if err := os.WriteFile(secret, []byte("nope"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(secret, filepath.Join(root, "escape.txt")); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, "pong")
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	plugin, err := LoadWASM(ctx, WASMSpec{Name: "test", Path: path, ReadRoots: []string{root}, HTTPAllow: []string{"127.0.0.1"}, TimeoutSec: 5})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	defer plugin.Close(ctx)
+	tools := map[string]Tool{}
+	for _, tool := range plugin.Tools() {
+		tools[tool.Name] = tool
+	}
+	if len(tools) != 4 || tools["wasm_echo"].Source != SourceWASM || tools["wasm_echo"].Origin != "test" {
+		t.Fatalf("tools = %+v", plugin.Tools())
+	}
+	call := func(name, args string) (string, error) {
+		return tools[name].Handler(ctx, Call{Arguments: []byte(args)})
+	}
+
+	if got, err := call("wasm_echo", `{"x":1}`); err != nil || got != `{"x":1}` {
+		t.Fatalf("echo = %q, %v", got, err)
+	}
+	if got, _ := call("wasm_read", `{"path":"notes.txt"}`); got != `{"data":"hello"}` {
+		t.Fatalf("read = %q", got)
+	}
+	for _, denied := range []string{secret, "escape.txt", "../" + filepath.Base(filepath.Dir(secret)) + "/secret.txt"} {
+		if got, _ := call("wasm_read", `{"path":"`+denied+`"}`); !strings.Contains(got, `"error"`) {
+			t.Fatalf("read %s = %q, want error", denied, got)
+		}
+	}
+	if got, _ := call("wasm_fetch", `{"url":"`+srv.URL+`"}`); got != `{"body":"pong","status":200}` {
+		t.Fatalf("fetch = %q", got)
+	}
+	if got, _ := call("wasm_fetch", `{"url":"http://localhost:1/"}`); !strings.Contains(got, "not in httpAllow") {
+		t.Fatalf("fetch denied = %q", got)
+	}
+
+	start := time.Now()
+	if _, err := call("wasm_spin", `{}`); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("spin err = %v, want timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 15*time.Second {
+		t.Fatalf("spin took %s", elapsed)
+	}
+}
@@ -2,124 +2,10 @@ package uistate
 
 import (
 	"encoding/json"
+	"fmt"
 	"strings"
 )
 
-// ── classifyEvent: map 查表替代 switch ──
-
-type classifyResult struct {
-	uiType UIType
-}
-
-var classifyMap = map[string]classifyResult{
-	// Assistant Messages
-	"agent_message_delta":         {UITypeAssistantDelta},
-	"agent_message_content_delta": {UITypeAssistantDelta},
-	"agent_message_completed":     {UITypeAssistantDone},
-	"agent_message":               {UITypeAssistantDone},
-
-	// Reasoning
-	"agent_reasoning":               {UITypeReasoningDelta},
-	"agent_reasoning_delta":         {UITypeReasoningDelta},
-	"agent_reasoning_raw":           {UITypeReasoningDelta},
-	"agent_reasoning_raw_delta":     {UITypeReasoningDelta},
-	"agent_reasoning_section_break": {UITypeReasoningDelta},
-
-	// Command Execution
-	"exec_command_begin":        {UITypeCommandStart},
-	"exec_output_delta":         {UITypeCommandOutput},
-	"exec_command_output_delta": {UITypeCommandOutput},
-	"exec_command_end":          {UITypeCommandDone},
-	"exec_terminal_interaction": {UITypeSystem},
-
-	// File Editing
-	"patch_apply_begin": {UITypeFileEditStart},
-	"file_read":         {UITypeFileEditStart},
-	"patch_apply":       {UITypeCommandOutput},
-	"patch_apply_delta": {UITypeCommandOutput},
-	"patch_apply_end":   {UITypeFileEditDone},
-	"file_updated":      {UITypeFileEditDone},
-
-	// Tool Calls
-	"mcp_tool_call_begin": {UITypeToolCall},
-	"mcp_tool_call":       {UITypeToolCall},
-	"dynamic_tool_call":   {UITypeSystem},
-	"mcp_tool_call_end":   {UITypeToolCall},
-
-	// Approval
-	"exec_approval_request":        {UITypeApprovalRequest},
-	"file_change_approval_request": {UITypeApprovalRequest},
-
-	// Turn Lifecycle
-	"turn_started":              {UITypeTurnStarted},
-	"task_started":              {UITypeTurnStarted},
-	"codex/event/task_started":  {UITypeTurnStarted},
-	"agent/event/task_started":  {UITypeTurnStarted},
-	"turn_complete":             {UITypeTurnComplete},
-	"task_complete":             {UITypeTurnComplete},
-	"codex/event/task_complete": {UITypeTurnComplete},
-	"agent/event/task_complete": {UITypeTurnComplete},
-	"turn/completed":            {UITypeTurnComplete},
-	"turn_aborted":              {UITypeTurnComplete},
-	"idle":                      {UITypeTurnComplete},
-
-	// Plan / Diff
-	"plan_delta":             {UITypePlanDelta},
-	"plan_update":            {UITypePlanDelta},
-	"turn_plan":              {UITypePlanDelta},
-	"item/plan/delta":        {UITypePlanDelta},
-	"codex/event/plan_delta": {UITypePlanDelta},
-	"turn_diff":              {UITypeDiffUpdate},
-
-	// User Message
-	"user_message": {UITypeUserMessage},
-
-	// Errors
-	"error":        {UITypeError},
-	"stream_error": {UITypeError},
-
-	// Warnings
-	"warning": {UITypeSystem},
-
-	// System / Lifecycle
-	"shutdown_complete":       {UITypeSystem},
-	"session_configured":      {UITypeSystem},
-	"mcp_startup_update":      {UITypeSystem},
-	"mcp_startup_complete":    {UITypeSystem},
-	"mcp_list_tools_response": {UITypeSystem},
-	"list_skills_response":    {UITypeSystem},
-	"token_count":             {UITypeSystem},
-	"context_compacted":       {UITypeSystem},
-	"thread_name_updated":     {UITypeSystem},
-	"thread_rolled_back":      {UITypeSystem},
-	"undo_started":            {UITypeSystem},
-	"undo_completed":          {UITypeSystem},
-	"entered_review_mode":     {UITypeSystem},
-	"exited_review_mode":      {UITypeSystem},
-	"background_event":        {UITypeSystem},
-
-	// Collab Agents
-	"collab_agent_spawn_begin":       {UITypeSystem},
-	"collab_agent_interaction_begin": {UITypeSystem},
-	"collab_waiting_begin":           {UITypeSystem},
-	"collab_agent_spawn_end":         {UITypeSystem},
-	"collab_agent_interaction_end":   {UITypeSystem},
-	"collab_waiting_end":             {UITypeSystem},
-}
-
-var classifyMethodMap = map[string]classifyResult{
-	"turn/started":                              {UITypeTurnStarted},
-	"turn/completed":                            {UITypeTurnComplete},
-	"turn/plan/updated":                         {UITypePlanDelta},
-	"item/plan/delta":                           {UITypePlanDelta},
-	"codex/event/plan_delta":                    {UITypePlanDelta},
-	"codex/event/task_started":                  {UITypeTurnStarted},
-	"codex/event/task_complete":                 {UITypeTurnComplete},
-	"item/commandExecution/terminalInteraction": {UITypeSystem},
-	"codex/event/mcp_startup_update":            {UITypeSystem},
-	"codex/event/background_event":              {UITypeSystem},
-}
-
 func normalizeLifecycleItemKind(raw string) string {
 	value := strings.ToLower(strings.TrimSpace(raw))
 	if value == "" {
@@ -209,20 +95,38 @@ func classifyItemLifecycleEvent(codexType, method string, payload map[string]any
 	return "", false
 }
 
-// classifyEventWithMethodAndPayload 按 codex 原始事件类型 + method + payload 分类。
+// classifyEventWithMethodAndPayload 按当前事件 schema 对 codex 原始事件类型 + method + payload 分类。
 func classifyEventWithMethodAndPayload(codexType, method string, payload map[string]any) UIType {
-	if r, ok := classifyMap[codexType]; ok {
-		return r.uiType
+	uiType, _ := classifyEventWithSchema(activeEventSchema.Load(), codexType, method, payload)
+	return uiType
+}
+
+// classifyEventWithSchema 分类并报告事件是否在 schema 中声明。
+// 未声明的事件计入 unknown 统计: lenient 模式按 system 处理, strict 模式返回 error (见 event_schema.go)。
+func classifyEventWithSchema(schema *compiledEventSchema, codexType, method string, payload map[string]any) (UIType, bool) {
+	if uiType, ok := schema.types[codexType]; ok {
+		return uiType, true
 	}
-	if key := strings.TrimSpace(method); key != "" {
-		if r, ok := classifyMethodMap[key]; ok {
-			return r.uiType
+	key := strings.TrimSpace(method)
+	if key != "" {
+		if uiType, ok := schema.methods[key]; ok {
+			return uiType, true
 		}
 	}
 	if uiType, ok := classifyItemLifecycleEvent(codexType, method, payload); ok {
-		return uiType
+		return uiType, true
+	}
+	if schema.informational[codexType] || schema.informational[key] {
+		return UITypeSystem, true
 	}
-	return UITypeSystem
+	if codexType == "" && key == "" {
+		return UITypeSystem, true // 无类型的记录 (如历史消息) 不视为协议漂移
+	}
+	recordUnknownEvent(schema.version, unknownEventKey(codexType, key))
+	if schema.mode == EventParseStrict {
+		return UITypeError, false
+	}
+	return UITypeSystem, false
 }
 
 // classifyEventWithMethod 按 codex 原始事件类型 + method 分类 (map 查表, O(1))。
@@ -359,13 +263,17 @@ func NormalizeEventFromPayload(codexType, method string, payload map[string]any)
 	if payload == nil {
 		payload = map[string]any{}
 	}
-	uiType := classifyEventWithMethodAndPayload(codexType, method, payload)
+	schema := activeEventSchema.Load()
+	uiType, known := classifyEventWithSchema(schema, codexType, method, payload)
 
 	result := NormalizedEvent{
 		UIType:  uiType,
 		RawType: codexType,
 		Method:  method,
 	}
+	if !known && schema.mode == EventParseStrict {
+		result.Error = fmt.Sprintf("unknown codex event %q (event schema %s)", unknownEventKey(codexType, method), schema.version)
+	}
 
 	result.Text = extractText(payload)
 	result.Command = extractNormalizedCommand(payload)
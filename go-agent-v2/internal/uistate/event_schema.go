@@ -0,0 +1,372 @@
+// event_schema.go — 版本化事件 schema 注册表 (codex 事件类型 / JSON-RPC 方法 → UIType)。
+//
+// 每个 schema 版本是一组声明式映射表, 新版本可经 Extends 继承旧版本、只声明差异:
+//   - Types / Methods: 事件类型 / 方法 → UIType;
+//   - Informational: 已知但不渲染的事件 (按 system 处理, 不计为未知)。
+//
+// item/started、item/completed 另按 payload 中的条目类型分类 (classifyItemLifecycleEvent)。
+// 未在当前 schema 中声明的事件计入 unknown 统计 (debug/events/unknown), 以便及早发现 codex 协议漂移:
+//   - lenient (默认): 未知事件按 system 处理;
+//   - strict: 未知事件归一化为 error 并附带说明, 在 UI 中显式暴露。
+//
+// 当前 schema 由 UseEventSchema 切换 (EVENT_SCHEMA_VERSION / EVENT_SCHEMA_MODE), 热路径无锁读取。
+package uistate
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+// EventParseMode controls how events missing from the active schema are normalized.
+type EventParseMode string
+
+const (
+	// EventParseLenient normalizes unknown events as system events.
+	EventParseLenient EventParseMode = "lenient"
+	// EventParseStrict normalizes unknown events as errors so protocol drift is visible in the UI.
+	EventParseStrict EventParseMode = "strict"
+)
+
+// EventSchemaV1 is the built-in schema version covering the codex protocol this tree was written against.
+const EventSchemaV1 = "v1"
+
+// maxUnknownEventKeys bounds distinct unknown event names tracked (the rest only count toward the total).
+const maxUnknownEventKeys = 256
+
+// EventSchema is a declarative event mapping table for one protocol version.
+type EventSchema struct {
+	Version       string
+	Extends       string            // base version whose mappings are inherited ("" = none)
+	Types         map[string]UIType // codex event type → UIType
+	Methods       map[string]UIType // JSON-RPC method → UIType
+	Informational []string          // known events that are not rendered (system)
+}
+
+// compiledEventSchema is an EventSchema with inherited mappings flattened.
+type compiledEventSchema struct {
+	version       string
+	mode          EventParseMode
+	types         map[string]UIType
+	methods       map[string]UIType
+	informational map[string]bool
+}
+
+// UnknownEventStat counts one unknown event name.
+type UnknownEventStat struct {
+	Event     string `json:"event"`
+	Count     int64  `json:"count"`
+	Schema    string `json:"schema"`
+	FirstSeen string `json:"firstSeen"`
+	LastSeen  string `json:"lastSeen"`
+}
+
+// UnknownEventReport is the debug/events/unknown payload.
+type UnknownEventReport struct {
+	Schema    string             `json:"schema"`
+	Mode      EventParseMode     `json:"mode"`
+	Versions  []string           `json:"versions"`
+	Total     int64              `json:"total"`
+	Untracked int64              `json:"untracked"` // 超出 maxUnknownEventKeys 后未单独统计的次数
+	Events    []UnknownEventStat `json:"events"`
+}
+
+var (
+	eventSchemaMu     sync.Mutex
+	eventSchemas      = map[string]EventSchema{}
+	eventSchemaOrder  []string
+	activeEventSchema atomic.Pointer[compiledEventSchema]
+
+	unknownEventsMu  sync.Mutex
+	unknownEvents    = map[string]*UnknownEventStat{}
+	unknownTotal     int64
+	unknownUntracked int64
+)
+
+func init() {
+	if err := RegisterEventSchema(EventSchema{
+		Version:       EventSchemaV1,
+		Types:         eventSchemaV1Types,
+		Methods:       eventSchemaV1Methods,
+		Informational: eventSchemaV1Informational,
+	}); err != nil {
+		panic(err)
+	}
+	if err := UseEventSchema(EventSchemaV1, EventParseLenient); err != nil {
+		panic(err)
+	}
+}
+
+// RegisterEventSchema registers a schema version. Extends must name an already registered version.
+func RegisterEventSchema(schema EventSchema) error {
+	const op = "uistate.RegisterEventSchema"
+	if schema.Version == "" {
+		return apperrors.NewCode(op, errcode.InvalidInput, "schema version is required")
+	}
+	eventSchemaMu.Lock()
+	defer eventSchemaMu.Unlock()
+	if _, exists := eventSchemas[schema.Version]; exists {
+		return apperrors.NewCodef(op, errcode.InvalidInput, "event schema %q already registered", schema.Version)
+	}
+	if schema.Extends != "" {
+		if _, ok := eventSchemas[schema.Extends]; !ok {
+			return apperrors.NewCodef(op, errcode.InvalidInput, "event schema %q extends unknown version %q", schema.Version, schema.Extends)
+		}
+	}
+	eventSchemas[schema.Version] = schema
+	eventSchemaOrder = append(eventSchemaOrder, schema.Version)
+	return nil
+}
+
+// UseEventSchema activates a registered schema version ("" = latest registered) and parse mode.
+func UseEventSchema(version string, mode EventParseMode) error {
+	const op = "uistate.UseEventSchema"
+	switch mode {
+	case "":
+		mode = EventParseLenient
+	case EventParseLenient, EventParseStrict:
+	default:
+		return apperrors.NewCodef(op, errcode.InvalidInput, "invalid event parse mode %q (lenient / strict)", mode)
+	}
+	eventSchemaMu.Lock()
+	defer eventSchemaMu.Unlock()
+	if version == "" {
+		version = eventSchemaOrder[len(eventSchemaOrder)-1]
+	}
+	if _, ok := eventSchemas[version]; !ok {
+		return apperrors.NewCodef(op, errcode.InvalidInput, "unknown event schema version %q", version)
+	}
+	compiled := &compiledEventSchema{
+		version:       version,
+		mode:          mode,
+		types:         map[string]UIType{},
+		methods:       map[string]UIType{},
+		informational: map[string]bool{},
+	}
+	compileEventSchemaLocked(version, compiled)
+	activeEventSchema.Store(compiled)
+	return nil
+}
+
+// compileEventSchemaLocked 先合并基础版本, 再以本版本的声明覆盖。
+func compileEventSchemaLocked(version string, out *compiledEventSchema) {
+	schema := eventSchemas[version]
+	if schema.Extends != "" {
+		compileEventSchemaLocked(schema.Extends, out)
+	}
+	for key, uiType := range schema.Types {
+		out.types[key] = uiType
+	}
+	for key, uiType := range schema.Methods {
+		out.methods[key] = uiType
+	}
+	for _, key := range schema.Informational {
+		out.informational[key] = true
+	}
+}
+
+func unknownEventKey(codexType, method string) string {
+	if codexType != "" {
+		return codexType
+	}
+	return method
+}
+
+func recordUnknownEvent(schema, event string) {
+	now := time.Now().UTC().Format(time.RFC3339)
+	unknownEventsMu.Lock()
+	defer unknownEventsMu.Unlock()
+	unknownTotal++
+	stat, ok := unknownEvents[event]
+	if !ok {
+		if len(unknownEvents) >= maxUnknownEventKeys {
+			unknownUntracked++
+			return
+		}
+		stat = &UnknownEventStat{Event: event, Schema: schema, FirstSeen: now}
+		unknownEvents[event] = stat
+	}
+	stat.Count++
+	stat.Schema = schema
+	stat.LastSeen = now
+}
+
+// UnknownEvents reports events missing from the active schema (most frequent first).
+// reset clears the counters after reading.
+func UnknownEvents(reset bool) UnknownEventReport {
+	schema := activeEventSchema.Load()
+	eventSchemaMu.Lock()
+	versions := append([]string(nil), eventSchemaOrder...)
+	eventSchemaMu.Unlock()
+
+	unknownEventsMu.Lock()
+	defer unknownEventsMu.Unlock()
+	report := UnknownEventReport{
+		Schema:    schema.version,
+		Mode:      schema.mode,
+		Versions:  versions,
+		Total:     unknownTotal,
+		Untracked: unknownUntracked,
+		Events:    make([]UnknownEventStat, 0, len(unknownEvents)),
+	}
+	for _, stat := range unknownEvents {
+		report.Events = append(report.Events, *stat)
+	}
+	sort.Slice(report.Events, func(i, j int) bool {
+		if report.Events[i].Count != report.Events[j].Count {
+			return report.Events[i].Count > report.Events[j].Count
+		}
+		return report.Events[i].Event < report.Events[j].Event
+	})
+	if reset {
+		unknownEvents = map[string]*UnknownEventStat{}
+		unknownTotal, unknownUntracked = 0, 0
+	}
+	return report
+}
+
+// ── v1 映射表 ──
+
+// eventSchemaV1Types codex 事件类型 → UIType。
+var eventSchemaV1Types = map[string]UIType{
+	// Assistant Messages
+	"agent_message_delta":         UITypeAssistantDelta,
+	"agent_message_content_delta": UITypeAssistantDelta,
+	"agent_message_completed":     UITypeAssistantDone,
+	"agent_message":               UITypeAssistantDone,
+
+	// Reasoning
+	"agent_reasoning":               UITypeReasoningDelta,
+	"agent_reasoning_delta":         UITypeReasoningDelta,
+	"agent_reasoning_raw":           UITypeReasoningDelta,
+	"agent_reasoning_raw_delta":     UITypeReasoningDelta,
+	"agent_reasoning_section_break": UITypeReasoningDelta,
+
+	// Command Execution
+	"exec_command_begin":        UITypeCommandStart,
+	"exec_output_delta":         UITypeCommandOutput,
+	"exec_command_output_delta": UITypeCommandOutput,
+	"exec_command_end":          UITypeCommandDone,
+	"exec_terminal_interaction": UITypeSystem,
+
+	// File Editing
+	"patch_apply_begin": UITypeFileEditStart,
+	"file_read":         UITypeFileEditStart,
+	"patch_apply":       UITypeCommandOutput,
+	"patch_apply_delta": UITypeCommandOutput,
+	"patch_apply_end":   UITypeFileEditDone,
+	"file_updated":      UITypeFileEditDone,
+
+	// Tool Calls
+	"mcp_tool_call_begin": UITypeToolCall,
+	"mcp_tool_call":       UITypeToolCall,
+	"dynamic_tool_call":   UITypeSystem,
+	"mcp_tool_call_end":   UITypeToolCall,
+
+	// Approval
+	"exec_approval_request":        UITypeApprovalRequest,
+	"file_change_approval_request": UITypeApprovalRequest,
+
+	// Turn Lifecycle
+	"turn_started":              UITypeTurnStarted,
+	"task_started":              UITypeTurnStarted,
+	"codex/event/task_started":  UITypeTurnStarted,
+	"agent/event/task_started":  UITypeTurnStarted,
+	"turn_complete":             UITypeTurnComplete,
+	"task_complete":             UITypeTurnComplete,
+	"codex/event/task_complete": UITypeTurnComplete,
+	"agent/event/task_complete": UITypeTurnComplete,
+	"turn/completed":            UITypeTurnComplete,
+	"turn_aborted":              UITypeTurnComplete,
+	"idle":                      UITypeTurnComplete,
+
+	// Plan / Diff
+	"plan_delta":             UITypePlanDelta,
+	"plan_update":            UITypePlanDelta,
+	"turn_plan":              UITypePlanDelta,
+	"item/plan/delta":        UITypePlanDelta,
+	"codex/event/plan_delta": UITypePlanDelta,
+	"turn_diff":              UITypeDiffUpdate,
+
+	// User Message
+	"user_message": UITypeUserMessage,
+
+	// Errors
+	"error":        UITypeError,
+	"stream_error": UITypeError,
+
+	// Warnings
+	"warning": UITypeSystem,
+
+	// System / Lifecycle
+	"shutdown_complete":       UITypeSystem,
+	"session_configured":      UITypeSystem,
+	"mcp_startup_update":      UITypeSystem,
+	"mcp_startup_complete":    UITypeSystem,
+	"mcp_list_tools_response": UITypeSystem,
+	"list_skills_response":    UITypeSystem,
+	"token_count":             UITypeSystem,
+	"context_compacted":       UITypeSystem,
+	"thread_name_updated":     UITypeSystem,
+	"thread_rolled_back":      UITypeSystem,
+	"undo_started":            UITypeSystem,
+	"undo_completed":          UITypeSystem,
+	"entered_review_mode":     UITypeSystem,
+	"exited_review_mode":      UITypeSystem,
+	"background_event":        UITypeSystem,
+
+	// Collab Agents
+	"collab_agent_spawn_begin":       UITypeSystem,
+	"collab_agent_interaction_begin": UITypeSystem,
+	"collab_waiting_begin":           UITypeSystem,
+	"collab_agent_spawn_end":         UITypeSystem,
+	"collab_agent_interaction_end":   UITypeSystem,
+	"collab_waiting_end":             UITypeSystem,
+}
+
+// eventSchemaV1Methods JSON-RPC 方法 → UIType (事件类型未命中时按 method 分类)。
+var eventSchemaV1Methods = map[string]UIType{
+	"turn/started":                              UITypeTurnStarted,
+	"turn/completed":                            UITypeTurnComplete,
+	"turn/plan/updated":                         UITypePlanDelta,
+	"item/plan/delta":                           UITypePlanDelta,
+	"codex/event/plan_delta":                    UITypePlanDelta,
+	"codex/event/task_started":                  UITypeTurnStarted,
+	"codex/event/task_complete":                 UITypeTurnComplete,
+	"item/commandExecution/terminalInteraction": UITypeSystem,
+	"codex/event/mcp_startup_update":            UITypeSystem,
+	"codex/event/background_event":              UITypeSystem,
+}
+
+// eventSchemaV1Informational 已知但不渲染的事件 (codex app-server 透传的方法名等)。
+var eventSchemaV1Informational = []string{
+	"item/started",
+	"item/completed",
+	"rawResponseItem/completed",
+	"item/fileChange/outputDelta",
+	"item/fileChange/requestApproval",
+	"item/mcpToolCall/progress",
+	"item/tool/requestUserInput",
+	"item/commandExecution/terminalInteraction",
+	"turn/aborted",
+	"agent/event/mcp_startup_update",
+	"codex/event/mcp_startup_update",
+	"codex/event/background_event",
+	"codex/event/stream_error",
+	"mcpServer/oauthLogin/completed",
+	"account/updated",
+	"account/rateLimits/updated",
+	"account/login/completed",
+	"account/chatgptAuthTokens/refresh",
+	"app/list/updated",
+	"fuzzyFileSearch/sessionUpdated",
+	"fuzzyFileSearch/sessionCompleted",
+	"deprecationNotice",
+	"authStatusChange",
+	"loginChatGptComplete",
+	"applyPatchApproval",
+}
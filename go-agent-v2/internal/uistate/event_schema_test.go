@@ -0,0 +1,91 @@
+package uistate
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/multi-agent/go-agent-v2/pkg/errcode"
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+var registerTestSchemaOnce sync.Once
+
+func useTestEventSchema(t *testing.T, mode EventParseMode) {
+	t.Helper()
+	registerTestSchemaOnce.Do(func() {
+		if err := RegisterEventSchema(EventSchema{
+			Version:       "test-v2",
+			Extends:       EventSchemaV1,
+			Types:         map[string]UIType{"agent_message_chunk": UITypeAssistantDelta},
+			Informational: []string{"thread/archived"},
+		}); err != nil {
+			t.Fatalf("register: %v", err)
+		}
+	})
+	if err := UseEventSchema("test-v2", mode); err != nil {
+		t.Fatalf("use: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = UseEventSchema(EventSchemaV1, EventParseLenient)
+		UnknownEvents(true)
+	})
+	UnknownEvents(true)
+}
+
+func TestEventSchema_ExtendsBaseAndCountsUnknown(t *testing.T) {
+	useTestEventSchema(t, EventParseLenient)
+
+	if got := NormalizeEventFromPayload("agent_message_chunk", "", nil).UIType; got != UITypeAssistantDelta {
+		t.Fatalf("new type = %q", got)
+	}
+	if got := NormalizeEventFromPayload("exec_command_begin", "", nil).UIType; got != UITypeCommandStart {
+		t.Fatalf("inherited type = %q", got)
+	}
+	NormalizeEventFromPayload("thread/archived", "", nil)
+	NormalizeEventFromPayload("", "", nil)
+	for i := 0; i < 2; i++ {
+		if ev := NormalizeEventFromPayload("agent_message_v3", "", nil); ev.UIType != UITypeSystem || ev.Error != "" {
+			t.Fatalf("lenient unknown = %+v", ev)
+		}
+	}
+	NormalizeEventFromPayload("", "item/newThing/updated", nil)
+
+	report := UnknownEvents(false)
+	if report.Schema != "test-v2" || report.Mode != EventParseLenient || report.Total != 3 || len(report.Events) != 2 {
+		t.Fatalf("report = %+v", report)
+	}
+	if top := report.Events[0]; top.Event != "agent_message_v3" || top.Count != 2 || top.Schema != "test-v2" {
+		t.Fatalf("top = %+v", top)
+	}
+}
+
+func TestEventSchema_StrictModeSurfacesUnknownAsError(t *testing.T) {
+	useTestEventSchema(t, EventParseStrict)
+
+	ev := NormalizeEventFromPayload("agent_message_v3", "", map[string]any{"delta": "x"})
+	if ev.UIType != UITypeError || ev.Error == "" || ev.Text != "x" {
+		t.Fatalf("strict unknown = %+v", ev)
+	}
+	if ev := NormalizeEventFromPayload("turn_started", "", nil); ev.UIType != UITypeTurnStarted || ev.Error != "" {
+		t.Fatalf("strict known = %+v", ev)
+	}
+}
+
+func TestEventSchema_RejectsInvalidRegistration(t *testing.T) {
+	cases := []EventSchema{
+		{},
+		{Version: EventSchemaV1},
+		{Version: "orphan", Extends: "v0"},
+	}
+	for _, schema := range cases {
+		if err := RegisterEventSchema(schema); apperrors.CodeOf(err) != errcode.InvalidInput {
+			t.Errorf("RegisterEventSchema(%+v) err = %v", schema, err)
+		}
+	}
+	if err := UseEventSchema("v0", EventParseLenient); apperrors.CodeOf(err) != errcode.InvalidInput {
+		t.Errorf("unknown version err = %v", err)
+	}
+	if err := UseEventSchema("", "loose"); apperrors.CodeOf(err) != errcode.InvalidInput {
+		t.Errorf("invalid mode err = %v", err)
+	}
+}
@@ -3,18 +3,10 @@ package uistate
 
 import "strings"
 
-func cloneSnapshot(src RuntimeSnapshot) RuntimeSnapshot {
-	return cloneBaseSnapshot(src, true)
-}
-
-// cloneSnapshotLight is like cloneSnapshot but skips timelines and diffs (the heaviest fields).
+// cloneSnapshotLight creates a deep copy of RuntimeSnapshot except timelines and diffs
+// (the heaviest fields): those maps are left empty and filled by the caller when needed
+// (Snapshot attaches immutable timeline views, see runtime_timeline_store.go).
 func cloneSnapshotLight(src RuntimeSnapshot) RuntimeSnapshot {
-	return cloneBaseSnapshot(src, false)
-}
-
-// cloneBaseSnapshot creates a deep copy of RuntimeSnapshot. When includeTimeline is false,
-// Timeline and DiffText maps are left empty (the caller uses separate accessors).
-func cloneBaseSnapshot(src RuntimeSnapshot, includeTimeline bool) RuntimeSnapshot {
 	out := RuntimeSnapshot{
 		Threads:               make([]ThreadSnapshot, 0, len(src.Threads)),
 		Statuses:              make(map[string]string, len(src.Statuses)),
@@ -25,14 +17,8 @@ func cloneBaseSnapshot(src RuntimeSnapshot, includeTimeline bool) RuntimeSnapsho
 		WorkspaceRunsByKey:    make(map[string]map[string]any, len(src.WorkspaceRunsByKey)),
 		WorkspaceLastError:    src.WorkspaceLastError,
 		AgentMetaByID:         make(map[string]AgentMeta, len(src.AgentMetaByID)),
-	}
-
-	if includeTimeline {
-		out.TimelinesByThread = make(map[string][]TimelineItem, len(src.TimelinesByThread))
-		out.DiffTextByThread = make(map[string]string, len(src.DiffTextByThread))
-	} else {
-		out.TimelinesByThread = map[string][]TimelineItem{}
-		out.DiffTextByThread = map[string]string{}
+		TimelinesByThread:     map[string][]TimelineItem{},
+		DiffTextByThread:      map[string]string{},
 	}
 
 	out.Threads = append(out.Threads, src.Threads...)
@@ -58,13 +44,6 @@ func cloneBaseSnapshot(src RuntimeSnapshot, includeTimeline bool) RuntimeSnapsho
 		out.StatusDetailsByThread[key] = value
 	}
 
-	if includeTimeline {
-		cloneTimelineItems(src.TimelinesByThread, out.TimelinesByThread)
-		for key, value := range src.DiffTextByThread {
-			out.DiffTextByThread[key] = value
-		}
-	}
-
 	for key, value := range src.TokenUsageByThread {
 		out.TokenUsageByThread[key] = value
 	}
@@ -85,30 +64,6 @@ func cloneBaseSnapshot(src RuntimeSnapshot, includeTimeline bool) RuntimeSnapsho
 	return out
 }
 
-// cloneTimelineItems deep-copies timeline items including pointer fields.
-func cloneTimelineItems(src, dst map[string][]TimelineItem) {
-	for key, list := range src {
-		copied := make([]TimelineItem, len(list))
-		copy(copied, list)
-		for i := range copied {
-			if len(copied[i].Attachments) > 0 {
-				attachments := make([]TimelineAttachment, len(copied[i].Attachments))
-				copy(attachments, copied[i].Attachments)
-				copied[i].Attachments = attachments
-			}
-			if copied[i].ExitCode != nil {
-				v := *copied[i].ExitCode
-				copied[i].ExitCode = &v
-			}
-			if copied[i].ElapsedMS != nil {
-				v := *copied[i].ElapsedMS
-				copied[i].ElapsedMS = &v
-			}
-		}
-		dst[key] = copied
-	}
-}
-
 // cloneActivityStatsMap deep-copies activity stats including ToolCalls map.
 func cloneActivityStatsMap(src map[string]ActivityStats) map[string]ActivityStats {
 	out := make(map[string]ActivityStats, len(src))
@@ -0,0 +1,172 @@
+package uistate
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// 估算单条时间线条目的固定开销 (结构体 + map/slice 头部)。
+	timelineItemOverheadBytes = 256
+	// 最近访问过的线程不逐出, 避免正在查看的线程反复重建。
+	timelineEvictionMinIdle = 30 * time.Second
+)
+
+// TimelineLoader loads persisted history records for an evicted thread timeline.
+type TimelineLoader func(threadID string) ([]HistoryRecord, error)
+
+// timelineEviction tracks LRU access and eviction state for thread timelines.
+// Lock order: RuntimeManager.mu → timelineEviction.mu.
+type timelineEviction struct {
+	mu           sync.Mutex
+	maxBytes     int64
+	loader       TimelineLoader
+	lastAccess   map[string]time.Time
+	evicted      map[string]bool
+	evictions    int64
+	rehydrations int64
+}
+
+func (e *timelineEviction) touch(threadID string, now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.maxBytes <= 0 {
+		return
+	}
+	if e.lastAccess == nil {
+		e.lastAccess = map[string]time.Time{}
+	}
+	e.lastAccess[threadID] = now
+}
+
+func (e *timelineEviction) clearEvicted(threadID string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.evicted, threadID)
+}
+
+// SetTimelineEviction enables LRU eviction of idle thread timelines once the estimated
+// timeline memory exceeds maxBytes (0 disables). Evicted timelines are rebuilt through
+// loader the next time ThreadTimeline is requested.
+func (m *RuntimeManager) SetTimelineEviction(maxBytes int64, loader TimelineLoader) {
+	m.eviction.mu.Lock()
+	defer m.eviction.mu.Unlock()
+	m.eviction.maxBytes = maxBytes
+	m.eviction.loader = loader
+}
+
+// estimateTimelineBytes approximates the memory held by a timeline.
+func estimateTimelineBytes(items []TimelineItem) int64 {
+	var total int64
+	for i := range items {
+		item := &items[i]
+		total += timelineItemOverheadBytes + int64(len(item.ID)+len(item.Ts)+len(item.Kind)+len(item.Text)+
+			len(item.Command)+len(item.Output)+len(item.Status)+len(item.File)+len(item.Tool)+
+			len(item.Preview)+len(item.Severity)+len(item.Ref))
+		for _, att := range item.Attachments {
+			total += timelineItemOverheadBytes/2 + int64(len(att.Kind)+len(att.Name)+len(att.Path)+len(att.PreviewURL))
+		}
+	}
+	return total
+}
+
+// timelineMemoryLocked returns per-thread and total estimated timeline memory (timeline + diff).
+func (m *RuntimeManager) timelineMemoryLocked() (map[string]int64, int64) {
+	sizes := make(map[string]int64, len(m.snapshot.TimelinesByThread))
+	var total int64
+	for id, items := range m.snapshot.TimelinesByThread {
+		size := estimateTimelineBytes(items) + int64(len(m.snapshot.DiffTextByThread[id]))
+		sizes[id] = size
+		total += size
+	}
+	return sizes, total
+}
+
+// EvictColdTimelines drops the least recently used idle timelines until the estimated
+// memory falls below the low watermark (3/4 of the limit). Returns the evicted thread count.
+func (m *RuntimeManager) EvictColdTimelines(now time.Time) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e := &m.eviction
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.maxBytes <= 0 || e.loader == nil {
+		return 0
+	}
+	sizes, total := m.timelineMemoryLocked()
+	if total <= e.maxBytes {
+		return 0
+	}
+	low := e.maxBytes / 4 * 3
+
+	candidates := make([]string, 0, len(sizes))
+	for id, size := range sizes {
+		if size == 0 || m.snapshot.Statuses[id] != "idle" || now.Sub(e.lastAccess[id]) < timelineEvictionMinIdle {
+			continue
+		}
+		candidates = append(candidates, id)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return e.lastAccess[candidates[i]].Before(e.lastAccess[candidates[j]])
+	})
+
+	if e.evicted == nil {
+		e.evicted = map[string]bool{}
+	}
+	evicted := 0
+	for _, id := range candidates {
+		if total <= low {
+			break
+		}
+		m.setTimelineLocked(id, []TimelineItem{})
+		m.snapshot.DiffTextByThread[id] = ""
+		m.runtime[id] = newThreadRuntime()
+		e.evicted[id] = true
+		total -= sizes[id]
+		evicted++
+	}
+	e.evictions += int64(evicted)
+	return evicted
+}
+
+// rehydrateIfEvicted rebuilds an evicted timeline from persisted history.
+// The loader runs without holding the runtime lock.
+func (m *RuntimeManager) rehydrateIfEvicted(threadID string) {
+	m.eviction.mu.Lock()
+	loader := m.eviction.loader
+	evicted := m.eviction.evicted[threadID]
+	m.eviction.mu.Unlock()
+	if !evicted || loader == nil {
+		return
+	}
+	records, err := loader(threadID)
+	if err != nil {
+		return // 保留逐出标记, 下次访问重试
+	}
+	if m.HydrateHistory(threadID, records) {
+		m.eviction.mu.Lock()
+		m.eviction.rehydrations++
+		m.eviction.mu.Unlock()
+	}
+}
+
+// timelineEvictionStatsLocked reports watermarks and eviction counters for debug/runtime.
+func (m *RuntimeManager) timelineEvictionStatsLocked(estimatedBytes int64) map[string]any {
+	e := &m.eviction
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	evictedThreads := make([]string, 0, len(e.evicted))
+	for id := range e.evicted {
+		evictedThreads = append(evictedThreads, id)
+	}
+	sort.Strings(evictedThreads)
+	return map[string]any{
+		"estimatedBytes":     estimatedBytes,
+		"highWatermarkBytes": e.maxBytes,
+		"lowWatermarkBytes":  e.maxBytes / 4 * 3,
+		"evictedThreads":     evictedThreads,
+		"evictions":          e.evictions,
+		"rehydrations":       e.rehydrations,
+	}
+}
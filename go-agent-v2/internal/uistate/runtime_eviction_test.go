@@ -0,0 +1,49 @@
+package uistate
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEvictColdTimelines_EvictsIdleLRUAndRehydrates(t *testing.T) {
+	mgr := NewRuntimeManager()
+	loads := 0
+	mgr.SetTimelineEviction(6400, func(threadID string) ([]HistoryRecord, error) {
+		loads++
+		return []HistoryRecord{{ID: 1, Role: "user", Content: "restored " + threadID}}, nil
+	})
+	big := strings.Repeat("x", 2000)
+	mgr.AppendUserMessage("cold", big, nil)
+	mgr.AppendUserMessage("warm", big, nil)
+	mgr.AppendUserMessage("hot", big, nil)
+	now := time.Now().Add(time.Hour)
+	mgr.eviction.touch("warm", now.Add(-2*time.Minute))
+	mgr.eviction.touch("hot", now) // 刚访问, 不逐出
+
+	if n := mgr.EvictColdTimelines(now); n != 1 {
+		t.Fatalf("evicted = %d", n)
+	}
+	snap := mgr.Snapshot()
+	if len(snap.TimelinesByThread["cold"]) != 0 || len(snap.TimelinesByThread["warm"]) != 1 || len(snap.TimelinesByThread["hot"]) != 1 {
+		t.Fatalf("timelines = %v", snap.TimelinesByThread)
+	}
+
+	timeline := mgr.ThreadTimeline("cold")
+	if loads != 1 || len(timeline) != 1 || timeline[0].Text != "restored cold" {
+		t.Fatalf("rehydrated = %#v (loads %d)", timeline, loads)
+	}
+	mgr.ThreadTimeline("cold")
+	memory := mgr.TimelineStats()["memory"].(map[string]any)
+	if loads != 1 || memory["evictions"] != int64(1) || memory["rehydrations"] != int64(1) || memory["highWatermarkBytes"] != int64(6400) {
+		t.Fatalf("memory = %#v (loads %d)", memory, loads)
+	}
+}
+
+func TestEvictColdTimelines_DisabledByDefault(t *testing.T) {
+	mgr := NewRuntimeManager()
+	mgr.AppendUserMessage("t1", strings.Repeat("x", 1<<20), nil)
+	if n := mgr.EvictColdTimelines(time.Now().Add(time.Hour)); n != 0 {
+		t.Fatalf("evicted = %d", n)
+	}
+}
@@ -283,3 +283,20 @@ func BenchmarkThreadTimeline_SingleThread(b *testing.B) {
 		_ = mgr.ThreadTimeline(threadID)
 	}
 }
+
+// ── Benchmark: 长时间线上的追加 ──────────────────────────────
+
+func BenchmarkAppendTimelineItem_LongTimeline(b *testing.B) {
+	mgr := NewRuntimeManager()
+	threadID := "thread-bench"
+	records := make([]HistoryRecord, 5000)
+	for i := range records {
+		records[i] = HistoryRecord{ID: int64(i + 1), Role: "user", Content: "message"}
+	}
+	mgr.HydrateHistory(threadID, records)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		mgr.AppendReviewFinding(threadID, TimelineItem{Ref: "finding", Status: "open"})
+	}
+}
@@ -0,0 +1,120 @@
+// runtime_persist.go — 运行时状态跨重启持久化 (线程状态、状态栏、token 用量、workspace runs)。
+//
+// 时间线与 diff 不在其中: 它们按需从 rollout 历史重建 (HydrateHistory)。
+// 恢复时进行中的状态 (thinking / running 等) 降为 idle: 重启后这些 turn 已不存在,
+// 真实状态由之后的 ReplaceThreads / agent 事件覆盖。
+package uistate
+
+import (
+	"strings"
+	"time"
+)
+
+// PersistedRuntimeStateVersion is the current PersistedRuntimeState format version.
+const PersistedRuntimeStateVersion = 1
+
+// PersistedRuntimeState is the restart-surviving subset of RuntimeSnapshot.
+type PersistedRuntimeState struct {
+	Version                 int                           `json:"version"`
+	SavedAt                 time.Time                     `json:"savedAt"`
+	Threads                 []ThreadSnapshot              `json:"threads"`
+	Statuses                map[string]string             `json:"statuses"`
+	StatusHeadersByThread   map[string]string             `json:"statusHeadersByThread"`
+	StatusDetailsByThread   map[string]string             `json:"statusDetailsByThread"`
+	TokenUsageByThread      map[string]TokenUsageSnapshot `json:"tokenUsageByThread"`
+	WorkspaceRunsByKey      map[string]map[string]any     `json:"workspaceRunsByKey"`
+	WorkspaceFeatureEnabled *bool                         `json:"workspaceFeatureEnabled,omitempty"`
+	WorkspaceLastError      string                        `json:"workspaceLastError,omitempty"`
+	AgentMetaByID           map[string]AgentMeta          `json:"agentMetaById"`
+	ActivityStatsByThread   map[string]ActivityStats      `json:"activityStatsByThread"`
+}
+
+// PersistentState returns a deep copy of the state persisted across restarts.
+func (m *RuntimeManager) PersistentState() PersistedRuntimeState {
+	m.mu.RLock()
+	snap := cloneSnapshotLight(m.snapshot)
+	m.mu.RUnlock()
+	return PersistedRuntimeState{
+		Version:                 PersistedRuntimeStateVersion,
+		SavedAt:                 time.Now().UTC(),
+		Threads:                 snap.Threads,
+		Statuses:                snap.Statuses,
+		StatusHeadersByThread:   snap.StatusHeadersByThread,
+		StatusDetailsByThread:   snap.StatusDetailsByThread,
+		TokenUsageByThread:      snap.TokenUsageByThread,
+		WorkspaceRunsByKey:      snap.WorkspaceRunsByKey,
+		WorkspaceFeatureEnabled: snap.WorkspaceFeatureEnabled,
+		WorkspaceLastError:      snap.WorkspaceLastError,
+		AgentMetaByID:           snap.AgentMetaByID,
+		ActivityStatsByThread:   snap.ActivityStatsByThread,
+	}
+}
+
+// RestorePersistentState seeds the manager with state saved by a previous run.
+// Intended right after NewRuntimeManager, before live events arrive; threads already
+// known to the manager keep their current state. The manager takes ownership of state's
+// maps. Returns the number of restored threads.
+func (m *RuntimeManager) RestorePersistentState(state PersistedRuntimeState) int {
+	if state.Version != PersistedRuntimeStateVersion {
+		return 0
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	known := make(map[string]bool, len(m.snapshot.Threads))
+	for _, thread := range m.snapshot.Threads {
+		known[thread.ID] = true
+	}
+	restored := 0
+	for _, thread := range state.Threads {
+		id := strings.TrimSpace(thread.ID)
+		if id == "" || known[id] {
+			continue
+		}
+		known[id] = true
+		m.ensureThreadLocked(id)
+
+		status := normalizeThreadState(state.Statuses[id])
+		header := state.StatusHeadersByThread[id]
+		detail := state.StatusDetailsByThread[id]
+		if isInterruptibleThreadState(status) {
+			// 进行中的 turn 不会跨重启存活
+			status, header, detail = "idle", "", ""
+		}
+		if strings.TrimSpace(header) == "" {
+			header = defaultStatusHeaderForState(status)
+		}
+		m.snapshot.Statuses[id] = status
+		m.snapshot.StatusHeadersByThread[id] = header
+		m.snapshot.StatusDetailsByThread[id] = detail
+		if usage, ok := state.TokenUsageByThread[id]; ok {
+			m.snapshot.TokenUsageByThread[id] = usage
+		}
+		if meta, ok := state.AgentMetaByID[id]; ok {
+			m.snapshot.AgentMetaByID[id] = meta
+		}
+		if stats, ok := state.ActivityStatsByThread[id]; ok {
+			m.snapshot.ActivityStatsByThread[id] = stats
+		}
+		name := strings.TrimSpace(thread.Name)
+		if name == "" {
+			name = id
+		}
+		m.snapshot.Threads = append(m.snapshot.Threads, ThreadSnapshot{ID: id, Name: name, State: status})
+		restored++
+	}
+
+	for key, run := range state.WorkspaceRunsByKey {
+		if _, ok := m.snapshot.WorkspaceRunsByKey[key]; !ok {
+			m.snapshot.WorkspaceRunsByKey[key] = run
+		}
+	}
+	if m.snapshot.WorkspaceFeatureEnabled == nil && state.WorkspaceFeatureEnabled != nil {
+		v := *state.WorkspaceFeatureEnabled
+		m.snapshot.WorkspaceFeatureEnabled = &v
+	}
+	if m.snapshot.WorkspaceLastError == "" {
+		m.snapshot.WorkspaceLastError = state.WorkspaceLastError
+	}
+	return restored
+}
@@ -0,0 +1,51 @@
+package uistate
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestPersistentState_RoundTripDemotesActiveStates(t *testing.T) {
+	src := NewRuntimeManager()
+	src.ReplaceThreads([]ThreadSnapshot{
+		{ID: "t-error", Name: "broken", State: "error"},
+		{ID: "t-busy", Name: "busy", State: "thinking"},
+		{ID: "t-usage", Name: "usage", State: "idle"},
+	})
+	src.ApplyAgentEvent("t-usage", NormalizeEvent("token_count", "thread/tokenUsage/updated", mustRawJSON(`{"input":1200,"output":300}`)), map[string]any{
+		"input":  1200,
+		"output": 300,
+	})
+	src.SetThreadName("t-error", "renamed")
+
+	data, err := json.Marshal(src.PersistentState())
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var state PersistedRuntimeState
+	if err := json.Unmarshal(data, &state); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	dst := NewRuntimeManager()
+	dst.ReplaceThreads([]ThreadSnapshot{{ID: "t-busy", State: "running"}}) // 已知线程保持当前状态
+	if n := dst.RestorePersistentState(state); n != 2 {
+		t.Fatalf("restored = %d", n)
+	}
+	snap := dst.Snapshot()
+	if snap.Statuses["t-error"] != "error" || snap.TokenUsageByThread["t-usage"].UsedTokens != 1500 || snap.AgentMetaByID["t-error"].Alias != "renamed" {
+		t.Fatalf("restored snapshot = %+v", snap)
+	}
+	if snap.Statuses["t-busy"] != "running" || len(snap.Threads) != 3 {
+		t.Fatalf("threads = %+v statuses = %v", snap.Threads, snap.Statuses)
+	}
+
+	fresh := NewRuntimeManager()
+	fresh.RestorePersistentState(state)
+	if got := fresh.Snapshot().Statuses["t-busy"]; got != "idle" {
+		t.Fatalf("active state after restart = %q, want idle", got)
+	}
+	if n := NewRuntimeManager().RestorePersistentState(PersistedRuntimeState{Version: 99, Threads: state.Threads}); n != 0 {
+		t.Fatalf("unknown version restored %d threads", n)
+	}
+}
@@ -17,6 +17,9 @@ type RuntimeManager struct {
 	snapshot RuntimeSnapshot
 	runtime  map[string]*threadRuntime
 	seq      uint64
+
+	eviction       timelineEviction // 冷线程时间线 LRU 逐出 (见 runtime_eviction.go)
+	timelineShares timelineShares   // 已交给读者的时间线前缀 (见 runtime_timeline_store.go)
 }
 
 // NewRuntimeManager creates an empty runtime manager.
@@ -40,11 +43,19 @@ func NewRuntimeManager() *RuntimeManager {
 	}
 }
 
-// Snapshot returns a deep-copied runtime snapshot for JSON-RPC responses.
+// Snapshot returns a runtime snapshot for JSON-RPC responses.
+// Timelines are immutable views shared with the manager; callers must NOT mutate them.
 func (m *RuntimeManager) Snapshot() RuntimeSnapshot {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	return cloneSnapshot(m.snapshot)
+	out := cloneSnapshotLight(m.snapshot)
+	for id := range m.snapshot.TimelinesByThread {
+		out.TimelinesByThread[id] = m.timelineViewLocked(id)
+	}
+	for id, diff := range m.snapshot.DiffTextByThread {
+		out.DiffTextByThread[id] = diff
+	}
+	return out
 }
 
 // SnapshotLight returns a snapshot without timelines and diffs (the heaviest fields).
@@ -56,21 +67,33 @@ func (m *RuntimeManager) SnapshotLight() RuntimeSnapshot {
 }
 
 // ThreadTimeline returns a single thread's timeline items (read-only reference).
+// Evicted timelines are transparently rebuilt from persisted history first.
 // Callers must NOT mutate the returned slice.
 func (m *RuntimeManager) ThreadTimeline(threadID string) []TimelineItem {
 	id := strings.TrimSpace(threadID)
 	if id == "" {
 		return nil
 	}
+	m.rehydrateIfEvicted(id)
+	m.eviction.touch(id, time.Now())
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	src := m.snapshot.TimelinesByThread[id]
+	src := m.timelineViewLocked(id)
 	if len(src) == 0 {
 		return []TimelineItem{}
 	}
 	return src
 }
 
+// ThreadTimelineLen returns the number of in-memory timeline items for a thread.
+// Unlike ThreadTimeline it neither rehydrates evicted timelines nor marks the
+// backing array as shared, so later in-place updates do not have to copy it.
+func (m *RuntimeManager) ThreadTimelineLen(threadID string) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.snapshot.TimelinesByThread[strings.TrimSpace(threadID)])
+}
+
 // ThreadDiff returns a single thread's diff text.
 func (m *RuntimeManager) ThreadDiff(threadID string) string {
 	id := strings.TrimSpace(threadID)
@@ -82,14 +105,21 @@ func (m *RuntimeManager) ThreadDiff(threadID string) string {
 	return m.snapshot.DiffTextByThread[id]
 }
 
+// ThreadTokenUsage returns a single thread's latest token usage.
+func (m *RuntimeManager) ThreadTokenUsage(threadID string) TokenUsageSnapshot {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.snapshot.TokenUsageByThread[strings.TrimSpace(threadID)]
+}
+
 // AllTimelinesAndDiffs returns all hydrated timelines and diff texts.
 // Used by ui/state/get to avoid race conditions when switching threads.
 func (m *RuntimeManager) AllTimelinesAndDiffs() (map[string][]TimelineItem, map[string]string) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	timelines := make(map[string][]TimelineItem, len(m.snapshot.TimelinesByThread))
-	for k, v := range m.snapshot.TimelinesByThread {
-		timelines[k] = v
+	for k := range m.snapshot.TimelinesByThread {
+		timelines[k] = m.timelineViewLocked(k)
 	}
 	diffs := make(map[string]string, len(m.snapshot.DiffTextByThread))
 	for k, v := range m.snapshot.DiffTextByThread {
@@ -193,9 +223,58 @@ func (m *RuntimeManager) AppendUserMessage(threadID, text string, attachments []
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.ensureThreadLocked(id)
+	m.eviction.touch(id, time.Now())
 	m.appendUserLocked(id, text, attachments, time.Now())
 }
 
+// AppendReviewFinding appends a structured review finding (kind=review) into timeline.
+// item.Ref identifies the persisted finding for later status updates.
+func (m *RuntimeManager) AppendReviewFinding(threadID string, item TimelineItem) {
+	id := strings.TrimSpace(threadID)
+	if id == "" {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ensureThreadLocked(id)
+	item.Kind = "review"
+	m.pushTimelineItemLocked(id, item, time.Now())
+}
+
+// AppendTurnAttempt appends a retry annotation (kind=attempt) into timeline.
+// item.Ref is the turn that produced the final result.
+func (m *RuntimeManager) AppendTurnAttempt(threadID string, item TimelineItem) {
+	id := strings.TrimSpace(threadID)
+	if id == "" {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ensureThreadLocked(id)
+	item.Kind = "attempt"
+	m.pushTimelineItemLocked(id, item, time.Now())
+}
+
+// SetReviewFindingStatus updates the status of the review finding item matching ref.
+func (m *RuntimeManager) SetReviewFindingStatus(threadID, ref, status string) bool {
+	id := strings.TrimSpace(threadID)
+	if id == "" || ref == "" {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := m.timelineLocked(id)
+	for i := len(list) - 1; i >= 0; i-- {
+		if list[i].Kind == "review" && list[i].Ref == ref {
+			m.patchTimelineItemLocked(id, i, func(item *TimelineItem) {
+				item.Status = status
+			})
+			return true
+		}
+	}
+	return false
+}
+
 // ClearThreadTimeline clears a single thread timeline and diff.
 func (m *RuntimeManager) ClearThreadTimeline(threadID string) {
 	id := strings.TrimSpace(threadID)
@@ -205,9 +284,10 @@ func (m *RuntimeManager) ClearThreadTimeline(threadID string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.ensureThreadLocked(id)
-	m.snapshot.TimelinesByThread[id] = []TimelineItem{}
+	m.setTimelineLocked(id, []TimelineItem{})
 	m.snapshot.DiffTextByThread[id] = ""
 	m.runtime[id] = newThreadRuntime()
+	m.eviction.clearEvicted(id)
 }
 
 // ApplyAgentEvent mutates runtime state by normalized backend events.
@@ -224,6 +304,7 @@ func (m *RuntimeManager) ApplyAgentEvent(threadID string, normalized NormalizedE
 	defer m.mu.Unlock()
 
 	m.ensureThreadLocked(id)
+	m.eviction.touch(id, time.Now())
 	m.applyAgentEventLocked(id, normalized, payload, time.Now())
 }
 
@@ -244,11 +325,13 @@ func (m *RuntimeManager) TimelineStats() map[string]any {
 		diffBytes += len(d)
 	}
 
+	_, estimatedBytes := m.timelineMemoryLocked()
 	return map[string]any{
 		"threadCount":   len(m.snapshot.TimelinesByThread),
 		"totalItems":    totalItems,
 		"diffByteTotal": diffBytes,
 		"perThread":     perThread,
+		"memory":        m.timelineEvictionStatsLocked(estimatedBytes),
 	}
 }
 
@@ -261,6 +344,38 @@ func hasAccumulatedText(timeline []TimelineItem, index int) bool {
 	return timeline[index].Text != ""
 }
 
+// RestoreThread installs a thread with a prebuilt timeline and diff (e.g. an imported bundle).
+// The thread is appended to the thread list when not already present.
+func (m *RuntimeManager) RestoreThread(threadID, name string, timeline []TimelineItem, diff string) {
+	id := strings.TrimSpace(threadID)
+	if id == "" {
+		return
+	}
+	display := strings.TrimSpace(name)
+	if display == "" {
+		display = id
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.ensureThreadLocked(id)
+	m.setTimelineLocked(id, append([]TimelineItem{}, timeline...))
+	m.snapshot.DiffTextByThread[id] = diff
+	m.runtime[id] = newThreadRuntime()
+	m.eviction.clearEvicted(id)
+	for _, item := range m.snapshot.Threads {
+		if item.ID == id {
+			return
+		}
+	}
+	m.snapshot.Threads = append(m.snapshot.Threads, ThreadSnapshot{
+		ID:    id,
+		Name:  display,
+		State: m.snapshot.Statuses[id],
+	})
+}
+
 // HydrateHistory rebuilds thread timeline from stored messages.
 // Returns false if skipped (e.g. thread is actively streaming).
 func (m *RuntimeManager) HydrateHistory(threadID string, records []HistoryRecord) bool {
@@ -285,9 +400,11 @@ func (m *RuntimeManager) HydrateHistory(threadID string, records []HistoryRecord
 	}
 
 	m.ensureThreadLocked(id)
-	m.snapshot.TimelinesByThread[id] = []TimelineItem{}
+	m.setTimelineLocked(id, []TimelineItem{})
 	m.snapshot.DiffTextByThread[id] = ""
 	m.runtime[id] = newThreadRuntime()
+	m.eviction.clearEvicted(id)
+	m.eviction.touch(id, time.Now())
 
 	ordered := make([]HistoryRecord, 0, len(records))
 	ordered = append(ordered, records...)
@@ -3,6 +3,7 @@ package uistate
 import (
 	"math"
 	"testing"
+	"time"
 )
 
 // ── extractContextWindow ─────────────────────────────────────
@@ -247,39 +248,57 @@ func TestApplyOverlays_MCPStartupClear(t *testing.T) {
 	}
 }
 
-// ── cloneTimelineItems ───────────────────────────────────────
+// ── 时间线写时复制 ───────────────────────────────────────────
 
-func TestCloneTimelineItems_DeepCopiesPointerFields(t *testing.T) {
-	exitCode := 42
-	elapsedMS := 1500
-	src := map[string][]TimelineItem{
-		"thread-1": {
-			{
-				Kind:      "command",
-				Text:      "echo hi",
-				ExitCode:  &exitCode,
-				ElapsedMS: &elapsedMS,
-				Attachments: []TimelineAttachment{
-					{Kind: "file", Name: "a.go"},
-				},
-			},
-		},
+func TestTimelineViews_ImmutableAfterAppendAndPatch(t *testing.T) {
+	mgr := NewRuntimeManager()
+	threadID := "thread-cow"
+	delta := func(text string) {
+		payload := map[string]any{"delta": text}
+		mgr.ApplyAgentEvent(threadID, NormalizeEventFromPayload("agent_message_delta", "", payload), payload)
 	}
-	dst := make(map[string][]TimelineItem)
-	cloneTimelineItems(src, dst)
+	delta("hel")
+
+	view := mgr.ThreadTimeline(threadID)
+	snap := mgr.Snapshot().TimelinesByThread[threadID]
+	delta("lo")
+	mgr.AppendReviewFinding(threadID, TimelineItem{Ref: "f1", Status: "open"})
 
-	// Verify deep copy
-	if dst["thread-1"][0].ExitCode == src["thread-1"][0].ExitCode {
-		t.Fatal("ExitCode pointer should not be shared")
+	if len(view) != 1 || view[0].Text != "hel" || len(snap) != 1 || snap[0].Text != "hel" {
+		t.Fatalf("views mutated: view=%+v snap=%+v", view, snap)
 	}
-	if *dst["thread-1"][0].ExitCode != 42 {
-		t.Fatalf("ExitCode = %d, want 42", *dst["thread-1"][0].ExitCode)
+	latest := mgr.ThreadTimeline(threadID)
+	if len(latest) != 2 || latest[0].Text != "hello" || latest[1].Kind != "review" {
+		t.Fatalf("latest = %+v", latest)
 	}
+	if !mgr.SetReviewFindingStatus(threadID, "f1", "resolved") || latest[1].Status != "open" {
+		t.Fatalf("patch leaked into view: %+v", latest[1])
+	}
+}
 
-	// Mutate source, verify dst unchanged
-	*src["thread-1"][0].ExitCode = 999
-	if *dst["thread-1"][0].ExitCode != 42 {
-		t.Fatal("dst ExitCode should not be affected by src mutation")
+func TestTimelineStore_CopiesOnlyWhenShared(t *testing.T) {
+	mgr := NewRuntimeManager()
+	threadID := "thread-cow"
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+	mgr.ensureThreadLocked(threadID)
+	index := mgr.pushTimelineItemLocked(threadID, TimelineItem{Kind: "assistant"}, time.Time{})
+	appendText := func(delta string) *TimelineItem {
+		mgr.patchTimelineItemLocked(threadID, index, func(item *TimelineItem) { item.Text += delta })
+		return &mgr.snapshot.TimelinesByThread[threadID][index]
+	}
+
+	first := appendText("a")
+	if appendText("b") != first {
+		t.Fatal("unshared timeline should be patched in place")
+	}
+	view := mgr.timelineViewLocked(threadID)
+	copied := appendText("c")
+	if copied == first || view[index].Text != "ab" {
+		t.Fatalf("shared timeline should be copied before patching (view=%q)", view[index].Text)
+	}
+	if appendText("d") != copied {
+		t.Fatal("copy should be patched in place until shared again")
 	}
 }
 
@@ -217,6 +217,28 @@ func TestExtractUserAttachmentsFromPayload(t *testing.T) {
 	}
 }
 
+func TestThreadTimelineLen_DoesNotShareTimeline(t *testing.T) {
+	mgr := NewRuntimeManager()
+	mgr.HydrateHistory("thread-1", []HistoryRecord{
+		{ID: 1, Role: "user", Content: "a"},
+		{ID: 2, Role: "assistant", Content: "b"},
+	})
+
+	if got := mgr.ThreadTimelineLen(" thread-1 "); got != 2 {
+		t.Fatalf("ThreadTimelineLen = %d, want 2", got)
+	}
+	if got := mgr.ThreadTimelineLen("missing"); got != 0 {
+		t.Fatalf("ThreadTimelineLen(missing) = %d, want 0", got)
+	}
+	if shared := mgr.timelineShares.sharedLen("thread-1"); shared != 0 {
+		t.Fatalf("ThreadTimelineLen marked %d items as shared", shared)
+	}
+	mgr.ThreadTimeline("thread-1")
+	if shared := mgr.timelineShares.sharedLen("thread-1"); shared != 2 {
+		t.Fatalf("ThreadTimeline shared len = %d, want 2", shared)
+	}
+}
+
 func TestHydrateHistory_UserAttachmentsFromMetadata(t *testing.T) {
 	mgr := NewRuntimeManager()
 	mgr.HydrateHistory("thread-1", []HistoryRecord{
@@ -41,26 +41,23 @@ func (m *RuntimeManager) nextItemIDLocked(kind string) string {
 }
 
 func (m *RuntimeManager) pushTimelineItemLocked(threadID string, item TimelineItem, ts time.Time) int {
-	list := append([]TimelineItem{}, m.snapshot.TimelinesByThread[threadID]...)
 	item.ID = m.nextItemIDLocked(item.Kind)
 	if ts.IsZero() {
 		ts = time.Now()
 	}
 	item.Ts = ts.UTC().Format(time.RFC3339)
-	list = append(list, item)
+	// 原地追加: 已交出的视图容量截止于各自长度, 看不到新条目 (见 runtime_timeline_store.go)。
+	list := append(m.snapshot.TimelinesByThread[threadID], item)
 	m.snapshot.TimelinesByThread[threadID] = list
 	return len(list) - 1
 }
 
 func (m *RuntimeManager) patchTimelineItemLocked(threadID string, index int, patch func(*TimelineItem)) {
-	list := append([]TimelineItem{}, m.snapshot.TimelinesByThread[threadID]...)
-	if index < 0 || index >= len(list) {
+	if index < 0 || index >= len(m.snapshot.TimelinesByThread[threadID]) {
 		return
 	}
-	item := list[index]
-	patch(&item)
-	list[index] = item
-	m.snapshot.TimelinesByThread[threadID] = list
+	list := m.writableTimelineLocked(threadID, index)
+	patch(&list[index])
 }
 
 func (m *RuntimeManager) timelineLocked(threadID string) []TimelineItem {
@@ -116,15 +113,16 @@ func (m *RuntimeManager) finishThinkingLocked(threadID string) {
 		return
 	}
 
-	list := append([]TimelineItem{}, m.timelineLocked(threadID)...)
+	list := m.timelineLocked(threadID)
 	if index >= len(list) {
 		rt.thinkingIndex = -1
 		return
 	}
 	item := list[index]
 	if strings.TrimSpace(item.Text) == "" {
-		list = append(list[:index], list[index+1:]...)
-		m.snapshot.TimelinesByThread[threadID] = list
+		trimmed := make([]TimelineItem, 0, cap(list))
+		trimmed = append(append(trimmed, list[:index]...), list[index+1:]...)
+		m.setTimelineLocked(threadID, trimmed)
 		m.shiftRuntimeIndicesAfterRemoveLocked(rt, index)
 		rt.thinkingIndex = -1
 		return
@@ -248,7 +246,7 @@ func (m *RuntimeManager) fileEditingLocked(threadID, file string, ts time.Time)
 }
 
 func (m *RuntimeManager) fileSavedLocked(threadID, file string, ts time.Time) {
-	list := append([]TimelineItem{}, m.timelineLocked(threadID)...)
+	list := m.timelineLocked(threadID)
 	for i := len(list) - 1; i >= 0; i-- {
 		item := list[i]
 		if item.Kind == "file" && item.Status == "editing" && (item.File == file || file == "") {
@@ -0,0 +1,74 @@
+// runtime_timeline_store.go — 时间线写时复制存储。
+//
+// 每个线程的时间线是只追加的切片 (append 预留容量), 交给读者的是截断容量的视图 list[:n:n]:
+//   - 追加写入视图容量之外的位置, 读者不可见, 无需复制 (均摊 O(1));
+//   - 修改已交出前缀内的条目前先整体复制一次, 此后的修改原地进行, 直到再次被读取;
+//   - 删除 / 整体替换总是换用新切片。
+//
+// 条目的指针字段 (ExitCode / ElapsedMS) 与 Attachments 只会整体替换、从不原地修改,
+// 因此视图 (ThreadTimeline / AllTimelinesAndDiffs / Snapshot) 可浅共享条目。
+package uistate
+
+import "sync"
+
+// timelineShares records, per thread, the timeline prefix length handed out to readers.
+// Read paths only hold RuntimeManager.mu for reading, so the map has its own lock.
+// Lock order: RuntimeManager.mu → timelineShares.mu.
+type timelineShares struct {
+	mu     sync.Mutex
+	shared map[string]int
+}
+
+func (s *timelineShares) mark(threadID string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.shared == nil {
+		s.shared = map[string]int{}
+	}
+	if n > s.shared[threadID] {
+		s.shared[threadID] = n
+	}
+}
+
+func (s *timelineShares) sharedLen(threadID string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.shared[threadID]
+}
+
+func (s *timelineShares) reset(threadID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.shared, threadID)
+}
+
+// timelineViewLocked returns an immutable view of a thread timeline that may outlive the lock.
+// Caller must hold m.mu (read or write).
+func (m *RuntimeManager) timelineViewLocked(threadID string) []TimelineItem {
+	list := m.snapshot.TimelinesByThread[threadID]
+	n := len(list)
+	if n == 0 {
+		return list
+	}
+	m.timelineShares.mark(threadID, n)
+	return list[:n:n]
+}
+
+// setTimelineLocked replaces a thread timeline with a slice owned by the manager.
+func (m *RuntimeManager) setTimelineLocked(threadID string, list []TimelineItem) {
+	m.snapshot.TimelinesByThread[threadID] = list
+	m.timelineShares.reset(threadID)
+}
+
+// writableTimelineLocked returns the timeline with index safe to modify in place,
+// copying the backing array once when that index is visible to readers.
+func (m *RuntimeManager) writableTimelineLocked(threadID string, index int) []TimelineItem {
+	list := m.snapshot.TimelinesByThread[threadID]
+	if index < m.timelineShares.sharedLen(threadID) {
+		copied := make([]TimelineItem, len(list), cap(list))
+		copy(copied, list)
+		m.setTimelineLocked(threadID, copied)
+		list = copied
+	}
+	return list
+}
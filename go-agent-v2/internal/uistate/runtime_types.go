@@ -36,6 +36,9 @@ type TimelineItem struct {
 	Tool        string               `json:"tool,omitempty"`
 	Preview     string               `json:"preview,omitempty"`
 	ElapsedMS   *int                 `json:"elapsedMs,omitempty"`
+	Line        int                  `json:"line,omitempty"`
+	Severity    string               `json:"severity,omitempty"`
+	Ref         string               `json:"ref,omitempty"`
 }
 
 // AgentMeta tracks runtime meta for thread cards.
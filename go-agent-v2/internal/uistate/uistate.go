@@ -57,7 +57,8 @@ type NormalizedEvent struct {
 // 当 store 为 nil 时，降级为内存存储。
 type PreferenceManager struct {
 	store    *store.UIPreferenceStore
-	fallback sync.Map // nil-store 时的内存降级
+	fallback sync.Map   // nil-store 时的内存降级
+	layoutMu sync.Mutex // windows.layout 读-改-写串行化
 }
 
 // NewPreferenceManager 创建偏好管理器。
@@ -0,0 +1,102 @@
+// window_layout.go — 桌面窗口几何偏好 (多显示器布局持久化)。
+//
+// 所有窗口的几何状态保存在单个偏好键 windows.layout 下: map[窗口键]WindowGeometry。
+// 窗口键由桌面端决定 (如 "main" / "group:<name>"), 与会话内分配的窗口 ID 无关, 重启后可复用。
+package uistate
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+// PrefWindowLayout 窗口布局偏好键。
+const PrefWindowLayout = "windows.layout"
+
+// WindowGeometry 单个窗口的几何状态。
+//
+// X/Y/Width/Height 为最近一次非最大化时的边界 (绝对桌面坐标),
+// Maximized 为真时恢复后再最大化, 取消最大化可回到原边界。
+type WindowGeometry struct {
+	X           int    `json:"x"`
+	Y           int    `json:"y"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Maximized   bool   `json:"maximized,omitempty"`
+	Monitor     string `json:"monitor,omitempty"`     // 所在屏幕 ID
+	MonitorName string `json:"monitorName,omitempty"` // 所在屏幕名称 (ID 变化时的备用匹配)
+	UpdatedAt   string `json:"updatedAt,omitempty"`   // RFC3339
+}
+
+// Valid 判断几何状态是否可用于恢复。
+func (g WindowGeometry) Valid() bool { return g.Width > 0 && g.Height > 0 }
+
+// WindowLayouts 返回全部窗口几何状态 (无记录时返回空 map)。
+func (m *PreferenceManager) WindowLayouts(ctx context.Context) (map[string]WindowGeometry, error) {
+	raw, err := m.Get(ctx, PrefWindowLayout)
+	if err != nil {
+		return nil, err
+	}
+	return decodeWindowLayouts(raw)
+}
+
+// SaveWindowGeometry 保存单个窗口的几何状态。
+func (m *PreferenceManager) SaveWindowGeometry(ctx context.Context, window string, g WindowGeometry) error {
+	const op = "PreferenceManager.SaveWindowGeometry"
+	window = strings.TrimSpace(window)
+	if window == "" {
+		return apperrors.New(op, "window key is required")
+	}
+	if !g.Valid() {
+		return apperrors.Newf(op, "invalid window size %dx%d", g.Width, g.Height)
+	}
+	m.layoutMu.Lock()
+	defer m.layoutMu.Unlock()
+	layouts, err := m.WindowLayouts(ctx)
+	if err != nil {
+		return err
+	}
+	layouts[window] = g
+	return m.Set(ctx, PrefWindowLayout, layouts)
+}
+
+// ResetWindowLayout 清除指定窗口 (window 为空时清除全部) 的几何状态, 返回清除条数。
+func (m *PreferenceManager) ResetWindowLayout(ctx context.Context, window string) (int, error) {
+	window = strings.TrimSpace(window)
+	m.layoutMu.Lock()
+	defer m.layoutMu.Unlock()
+	layouts, err := m.WindowLayouts(ctx)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	if window == "" {
+		removed = len(layouts)
+		layouts = map[string]WindowGeometry{}
+	} else if _, ok := layouts[window]; ok {
+		delete(layouts, window)
+		removed = 1
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, m.Set(ctx, PrefWindowLayout, layouts)
+}
+
+// decodeWindowLayouts 兼容 DB (JSON 解码后的 map[string]any) 与内存降级 (原始类型) 两种取值。
+func decodeWindowLayouts(raw any) (map[string]WindowGeometry, error) {
+	layouts := map[string]WindowGeometry{}
+	if raw == nil {
+		return layouts, nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, apperrors.Wrap(err, "decodeWindowLayouts", "marshal preference")
+	}
+	if err := json.Unmarshal(data, &layouts); err != nil {
+		return nil, apperrors.Wrap(err, "decodeWindowLayouts", "unmarshal preference")
+	}
+	return layouts, nil
+}
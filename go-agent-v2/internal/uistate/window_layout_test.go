@@ -0,0 +1,57 @@
+package uistate
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWindowLayout_SaveAndReset(t *testing.T) {
+	ctx := context.Background()
+	m := NewPreferenceManager(nil)
+
+	main := WindowGeometry{X: 1920, Y: 40, Width: 1440, Height: 900, Maximized: true, Monitor: "2", MonitorName: "DELL U2720Q"}
+	if err := m.SaveWindowGeometry(ctx, "main", main); err != nil {
+		t.Fatalf("save main: %v", err)
+	}
+	if err := m.SaveWindowGeometry(ctx, "group:alpha", WindowGeometry{Width: 800, Height: 600}); err != nil {
+		t.Fatalf("save group: %v", err)
+	}
+	if err := m.SaveWindowGeometry(ctx, "main", WindowGeometry{}); err == nil {
+		t.Fatal("expected error for zero size")
+	}
+
+	layouts, err := m.WindowLayouts(ctx)
+	if err != nil {
+		t.Fatalf("layouts: %v", err)
+	}
+	if len(layouts) != 2 || layouts["main"] != main {
+		t.Fatalf("layouts = %#v", layouts)
+	}
+
+	if n, err := m.ResetWindowLayout(ctx, "main"); err != nil || n != 1 {
+		t.Fatalf("reset main = %d, %v", n, err)
+	}
+	if n, err := m.ResetWindowLayout(ctx, "main"); err != nil || n != 0 {
+		t.Fatalf("reset main again = %d, %v", n, err)
+	}
+	if n, err := m.ResetWindowLayout(ctx, ""); err != nil || n != 1 {
+		t.Fatalf("reset all = %d, %v", n, err)
+	}
+	if layouts, _ := m.WindowLayouts(ctx); len(layouts) != 0 {
+		t.Fatalf("layouts after reset = %#v", layouts)
+	}
+}
+
+func TestDecodeWindowLayouts_FromJSONMap(t *testing.T) {
+	raw := map[string]any{
+		"main": map[string]any{"x": float64(-1280), "y": float64(0), "width": float64(1280), "height": float64(720), "monitor": "1"},
+	}
+	layouts, err := decodeWindowLayouts(raw)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	got := layouts["main"]
+	if got.X != -1280 || got.Width != 1280 || got.Monitor != "1" || !got.Valid() {
+		t.Fatalf("decoded = %#v", got)
+	}
+}
@@ -0,0 +1,149 @@
+// git.go — 不触碰工作区的分支提交与推送。
+//
+// 提交通过临时索引 (GIT_INDEX_FILE) 构造: read-tree HEAD → add 指定文件 → write-tree → commit-tree,
+// 再将提交直接推送到远端分支; 当前检出分支、暂存区与工作区均保持不变。
+// 推送凭据优先使用仓库已有的 git 凭据; 配置 token 时经 GIT_CONFIG_* 环境变量注入 http.extraHeader,
+// token 不出现在命令行参数中。
+package vcs
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+// Author 提交作者 (为空时使用仓库 git 配置)。
+type Author struct {
+	Name  string
+	Email string
+}
+
+// Repo 本地 git 仓库。
+type Repo struct {
+	Dir string
+}
+
+// run 在仓库目录执行 git 命令, 返回去除首尾空白的 stdout。
+func (r Repo) run(ctx context.Context, env []string, stdin string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = r.Dir
+	cmd.Env = append(os.Environ(), env...)
+	if stdin != "" {
+		cmd.Stdin = strings.NewReader(stdin)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", apperrors.Wrapf(err, "vcs.git", "git %s: %s", args[0], strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// CurrentBranch 当前检出分支 (detached HEAD 时返回错误)。
+func (r Repo) CurrentBranch(ctx context.Context) (string, error) {
+	branch, err := r.run(ctx, nil, "", "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	if branch == "HEAD" {
+		return "", apperrors.New("Repo.CurrentBranch", "repository is in detached HEAD state")
+	}
+	return branch, nil
+}
+
+// RemoteURL 远端地址。
+func (r Repo) RemoteURL(ctx context.Context, remote string) (string, error) {
+	return r.run(ctx, nil, "", "remote", "get-url", remote)
+}
+
+// Diff 统一 diff 文本: staged 时比较暂存区, 否则比较工作区; base 为空时以 HEAD 为基准,
+// paths 非空时只包含这些路径。
+func (r Repo) Diff(ctx context.Context, base string, staged bool, paths []string) (string, error) {
+	args := []string{"diff", "--no-color", "--no-ext-diff"}
+	if staged {
+		args = append(args, "--cached")
+	}
+	if base == "" {
+		base = "HEAD"
+	}
+	args = append(args, base, "--")
+	args = append(args, paths...)
+	return r.run(ctx, nil, "", args...)
+}
+
+// CommitFiles 以 HEAD 为父提交, 将 files (相对仓库根目录, 已删除的文件记为删除) 的工作区内容
+// 写成一个新提交并返回其 SHA; 不修改当前分支、暂存区与工作区。
+func (r Repo) CommitFiles(ctx context.Context, files []string, message string, author Author) (string, error) {
+	if len(files) == 0 {
+		return "", apperrors.New("Repo.CommitFiles", "no files to commit")
+	}
+	gitDir, err := r.run(ctx, nil, "", "rev-parse", "--absolute-git-dir")
+	if err != nil {
+		return "", err
+	}
+	index, err := os.CreateTemp(gitDir, "vcs-index-*")
+	if err != nil {
+		return "", apperrors.Wrap(err, "Repo.CommitFiles", "create temp index")
+	}
+	indexPath := index.Name()
+	_ = index.Close()
+	_ = os.Remove(indexPath) // git 要求索引文件不存在或为合法索引
+	defer os.Remove(indexPath)
+
+	env := []string{"GIT_INDEX_FILE=" + indexPath}
+	if author.Name != "" {
+		env = append(env, "GIT_AUTHOR_NAME="+author.Name, "GIT_COMMITTER_NAME="+author.Name)
+	}
+	if author.Email != "" {
+		env = append(env, "GIT_AUTHOR_EMAIL="+author.Email, "GIT_COMMITTER_EMAIL="+author.Email)
+	}
+	if _, err := r.run(ctx, env, "", "read-tree", "HEAD"); err != nil {
+		return "", err
+	}
+	args := []string{"add", "--all", "--"}
+	for _, file := range files {
+		args = append(args, filepath.ToSlash(file))
+	}
+	if _, err := r.run(ctx, env, "", args...); err != nil {
+		return "", err
+	}
+	tree, err := r.run(ctx, env, "", "write-tree")
+	if err != nil {
+		return "", err
+	}
+	headTree, err := r.run(ctx, nil, "", "rev-parse", "HEAD^{tree}")
+	if err != nil {
+		return "", err
+	}
+	if tree == headTree {
+		return "", apperrors.New("Repo.CommitFiles", "no changes relative to HEAD")
+	}
+	return r.run(ctx, env, message, "commit-tree", tree, "-p", "HEAD", "-F", "-")
+}
+
+// Push 将提交推送到远端分支 (force 覆盖同名分支)。token 非空时以 HTTP Basic 头认证。
+func (r Repo) Push(ctx context.Context, remote, sha, branch, tokenUser, token string, force bool) error {
+	var env []string
+	if token != "" {
+		credential := base64.StdEncoding.EncodeToString([]byte(tokenUser + ":" + token))
+		env = append(env,
+			"GIT_CONFIG_COUNT=1",
+			"GIT_CONFIG_KEY_0=http.extraHeader",
+			"GIT_CONFIG_VALUE_0=Authorization: Basic "+credential,
+			"GIT_TERMINAL_PROMPT=0",
+		)
+	}
+	refspec := sha + ":refs/heads/" + branch
+	if force {
+		refspec = "+" + refspec
+	}
+	_, err := r.run(ctx, env, "", "push", remote, refspec)
+	return err
+}
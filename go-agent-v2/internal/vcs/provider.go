@@ -0,0 +1,237 @@
+// provider.go — GitHub / GitLab 拉取请求 (合并请求) 创建。
+//
+// 按远端地址识别托管平台与仓库路径 (支持 https 与 scp 风格 ssh 地址、自建实例),
+// 以 REST API 创建 PR/MR; 同分支 PR 已存在时返回已有 PR。
+package vcs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+const (
+	ProviderGitHub = "github"
+	ProviderGitLab = "gitlab"
+
+	defaultGitHubAPI = "https://api.github.com"
+	apiTimeout       = 30 * time.Second
+)
+
+// Remote 解析后的远端仓库。
+type Remote struct {
+	Provider string // github / gitlab
+	Host     string
+	Path     string // owner/repo (GitLab 可含子组)
+}
+
+// ParseRemoteURL 解析 https://host/owner/repo(.git)、ssh://git@host/owner/repo 与 git@host:owner/repo 形式。
+// 主机名含 gitlab 的识别为 GitLab, 其余识别为 GitHub; hostProviders 可显式指定自建实例 (host → provider)。
+func ParseRemoteURL(raw string, hostProviders map[string]string) (Remote, error) {
+	value := strings.TrimSpace(raw)
+	var host, path string
+	if strings.Contains(value, "://") {
+		u, err := url.Parse(value)
+		if err != nil {
+			return Remote{}, apperrors.Wrapf(err, "vcs.ParseRemoteURL", "parse %q", raw)
+		}
+		host, path = u.Hostname(), u.Path
+	} else if at := strings.Index(value, "@"); at >= 0 && strings.Contains(value[at:], ":") {
+		rest := value[at+1:]
+		colon := strings.Index(rest, ":")
+		host, path = rest[:colon], rest[colon+1:]
+	} else {
+		return Remote{}, apperrors.Newf("vcs.ParseRemoteURL", "unsupported remote url %q", raw)
+	}
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	if host == "" || strings.Count(path, "/") < 1 {
+		return Remote{}, apperrors.Newf("vcs.ParseRemoteURL", "remote url %q has no owner/repo path", raw)
+	}
+	provider := hostProviders[strings.ToLower(host)]
+	if provider == "" {
+		provider = ProviderGitHub
+		if strings.Contains(strings.ToLower(host), "gitlab") {
+			provider = ProviderGitLab
+		}
+	}
+	return Remote{Provider: provider, Host: host, Path: path}, nil
+}
+
+// PullRequest PR 创建请求。
+type PullRequest struct {
+	Remote Remote
+	Head   string // 源分支
+	Base   string // 目标分支
+	Title  string
+	Body   string
+	Draft  bool
+}
+
+// PullRequestResult 创建结果。
+type PullRequestResult struct {
+	Provider string `json:"provider"`
+	Number   int    `json:"number"`
+	URL      string `json:"url"`
+	Existing bool   `json:"existing,omitempty"` // 同分支 PR 已存在
+}
+
+// Client PR API 客户端。
+type Client struct {
+	GitHubToken  string
+	GitHubAPIURL string // 空 = https://api.github.com (GitHub Enterprise 填 https://host/api/v3)
+	GitLabToken  string
+	GitLabAPIURL string // 空 = https://<host>/api/v4
+	HTTP         *http.Client
+}
+
+// Token 返回平台 token 与推送时的 Basic 认证用户名。
+func (c *Client) Token(provider string) (user, token string) {
+	if provider == ProviderGitLab {
+		return "oauth2", c.GitLabToken
+	}
+	return "x-access-token", c.GitHubToken
+}
+
+// CreatePullRequest 创建 PR (GitHub) 或 MR (GitLab)。
+func (c *Client) CreatePullRequest(ctx context.Context, pr PullRequest) (*PullRequestResult, error) {
+	switch pr.Remote.Provider {
+	case ProviderGitHub:
+		return c.createGitHub(ctx, pr)
+	case ProviderGitLab:
+		return c.createGitLab(ctx, pr)
+	default:
+		return nil, apperrors.Newf("vcs.CreatePullRequest", "unsupported provider %q", pr.Remote.Provider)
+	}
+}
+
+func (c *Client) createGitHub(ctx context.Context, pr PullRequest) (*PullRequestResult, error) {
+	if c.GitHubToken == "" {
+		return nil, apperrors.New("vcs.CreatePullRequest", "github token not configured (VCS_GITHUB_TOKEN)")
+	}
+	base := strings.TrimRight(c.GitHubAPIURL, "/")
+	if base == "" {
+		base = defaultGitHubAPI
+		if !strings.EqualFold(pr.Remote.Host, "github.com") {
+			base = "https://" + pr.Remote.Host + "/api/v3"
+		}
+	}
+	auth := func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer "+c.GitHubToken)
+		req.Header.Set("Accept", "application/vnd.github+json")
+	}
+	endpoint := base + "/repos/" + pr.Remote.Path + "/pulls"
+	var created struct {
+		Number  int    `json:"number"`
+		HTMLURL string `json:"html_url"`
+	}
+	status, err := c.doJSON(ctx, http.MethodPost, endpoint, auth, map[string]any{
+		"title": pr.Title, "body": pr.Body, "head": pr.Head, "base": pr.Base, "draft": pr.Draft,
+	}, &created)
+	if err == nil {
+		return &PullRequestResult{Provider: ProviderGitHub, Number: created.Number, URL: created.HTMLURL}, nil
+	}
+	if status != http.StatusUnprocessableEntity {
+		return nil, err
+	}
+	// 422: 同分支 PR 已存在时查询并返回
+	owner, _, _ := strings.Cut(pr.Remote.Path, "/")
+	query := url.Values{"head": {owner + ":" + pr.Head}, "base": {pr.Base}, "state": {"open"}}
+	var existing []struct {
+		Number  int    `json:"number"`
+		HTMLURL string `json:"html_url"`
+	}
+	if _, listErr := c.doJSON(ctx, http.MethodGet, endpoint+"?"+query.Encode(), auth, nil, &existing); listErr != nil || len(existing) == 0 {
+		return nil, err
+	}
+	return &PullRequestResult{Provider: ProviderGitHub, Number: existing[0].Number, URL: existing[0].HTMLURL, Existing: true}, nil
+}
+
+func (c *Client) createGitLab(ctx context.Context, pr PullRequest) (*PullRequestResult, error) {
+	if c.GitLabToken == "" {
+		return nil, apperrors.New("vcs.CreatePullRequest", "gitlab token not configured (VCS_GITLAB_TOKEN)")
+	}
+	base := strings.TrimRight(c.GitLabAPIURL, "/")
+	if base == "" {
+		base = "https://" + pr.Remote.Host + "/api/v4"
+	}
+	auth := func(req *http.Request) { req.Header.Set("PRIVATE-TOKEN", c.GitLabToken) }
+	endpoint := base + "/projects/" + url.PathEscape(pr.Remote.Path) + "/merge_requests"
+	title := pr.Title
+	if pr.Draft {
+		title = "Draft: " + title
+	}
+	var created struct {
+		IID    int    `json:"iid"`
+		WebURL string `json:"web_url"`
+	}
+	status, err := c.doJSON(ctx, http.MethodPost, endpoint, auth, map[string]any{
+		"title": title, "description": pr.Body, "source_branch": pr.Head, "target_branch": pr.Base, "remove_source_branch": true,
+	}, &created)
+	if err == nil {
+		return &PullRequestResult{Provider: ProviderGitLab, Number: created.IID, URL: created.WebURL}, nil
+	}
+	if status != http.StatusConflict {
+		return nil, err
+	}
+	query := url.Values{"source_branch": {pr.Head}, "target_branch": {pr.Base}, "state": {"opened"}}
+	var existing []struct {
+		IID    int    `json:"iid"`
+		WebURL string `json:"web_url"`
+	}
+	if _, listErr := c.doJSON(ctx, http.MethodGet, endpoint+"?"+query.Encode(), auth, nil, &existing); listErr != nil || len(existing) == 0 {
+		return nil, err
+	}
+	return &PullRequestResult{Provider: ProviderGitLab, Number: existing[0].IID, URL: existing[0].WebURL, Existing: true}, nil
+}
+
+// doJSON 发送 JSON 请求并解码响应, 返回 HTTP 状态码 (网络错误时为 0)。
+func (c *Client) doJSON(ctx context.Context, method, endpoint string, auth func(*http.Request), body, out any) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, apperrors.Wrap(err, "vcs.doJSON", "marshal body")
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return 0, apperrors.Wrap(err, "vcs.doJSON", "build request")
+	}
+	auth(req)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	client := c.HTTP
+	if client == nil {
+		client = &http.Client{Timeout: apiTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, apperrors.Wrapf(err, "vcs.doJSON", "%s %s", method, req.URL.Path)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return resp.StatusCode, apperrors.Wrap(err, "vcs.doJSON", "read response")
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg := string(data)
+		if len(msg) > 300 {
+			msg = msg[:300]
+		}
+		return resp.StatusCode, apperrors.Newf("vcs.doJSON", "%s %s: status %d: %s", method, req.URL.Path, resp.StatusCode, strings.TrimSpace(msg))
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return resp.StatusCode, apperrors.Wrap(err, "vcs.doJSON", fmt.Sprintf("decode %s response", req.URL.Path))
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,142 @@
+package vcs
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseRemoteURL(t *testing.T) {
+	cases := []struct {
+		raw  string
+		want Remote
+	}{
+		{"https://github.com/acme/widgets.git", Remote{ProviderGitHub, "github.com", "acme/widgets"}},
+		{"git@github.com:acme/widgets.git", Remote{ProviderGitHub, "github.com", "acme/widgets"}},
+		{"ssh://git@gitlab.example.com:2222/group/sub/proj", Remote{ProviderGitLab, "gitlab.example.com", "group/sub/proj"}},
+	}
+	for _, tc := range cases {
+		got, err := ParseRemoteURL(tc.raw, nil)
+		if err != nil || got != tc.want {
+			t.Errorf("ParseRemoteURL(%q) = %+v, %v; want %+v", tc.raw, got, err, tc.want)
+		}
+	}
+	if got, _ := ParseRemoteURL("https://code.corp/team/app", map[string]string{"code.corp": ProviderGitLab}); got.Provider != ProviderGitLab {
+		t.Fatalf("host override ignored: %+v", got)
+	}
+	if _, err := ParseRemoteURL("/local/path", nil); err == nil {
+		t.Fatal("local path should be rejected")
+	}
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestCommitFilesAndPushLeaveWorktreeUntouched(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	ctx := context.Background()
+	remote := t.TempDir()
+	runGit(t, remote, "init", "--bare", "-q")
+	dir := t.TempDir()
+	runGit(t, dir, "init", "-q", "-b", "main")
+	runGit(t, dir, "config", "user.name", "tester")
+	runGit(t, dir, "config", "user.email", "tester@example.com")
+	for name, body := range map[string]string{"keep.txt": "keep\n", "gone.txt": "bye\n", "edit.txt": "v1\n"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "-qm", "init")
+	runGit(t, dir, "remote", "add", "origin", remote)
+
+	_ = os.WriteFile(filepath.Join(dir, "edit.txt"), []byte("v2\n"), 0o644)
+	_ = os.Remove(filepath.Join(dir, "gone.txt"))
+	_ = os.WriteFile(filepath.Join(dir, "unrelated.txt"), []byte("local only\n"), 0o644)
+
+	repo := Repo{Dir: dir}
+	diff, err := repo.Diff(ctx, "", false, []string{"edit.txt"})
+	if err != nil || !strings.Contains(diff, "+v2") || strings.Contains(diff, "gone.txt") {
+		t.Fatalf("Diff = %q err = %v", diff, err)
+	}
+	sha, err := repo.CommitFiles(ctx, []string{"edit.txt", "gone.txt"}, "agent change", Author{Name: "agent", Email: "agent@example.com"})
+	if err != nil {
+		t.Fatalf("CommitFiles: %v", err)
+	}
+	if err := repo.Push(ctx, "origin", sha, "agent/run-1", "", "", true); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	if branch, _ := repo.CurrentBranch(ctx); branch != "main" {
+		t.Fatalf("current branch = %q", branch)
+	}
+	if status := runGit(t, dir, "status", "--porcelain"); !strings.Contains(status, "M edit.txt") || !strings.Contains(status, "?? unrelated.txt") {
+		t.Fatalf("worktree changed: %q", status)
+	}
+	files := runGit(t, remote, "ls-tree", "--name-only", "agent/run-1")
+	if files != "edit.txt\nkeep.txt" {
+		t.Fatalf("pushed tree = %q", files)
+	}
+	if author := runGit(t, remote, "log", "-1", "--format=%an", "agent/run-1"); author != "agent" {
+		t.Fatalf("author = %q", author)
+	}
+	if _, err := repo.CommitFiles(ctx, []string{"keep.txt"}, "noop", Author{}); err == nil {
+		t.Fatal("commit without changes should fail")
+	}
+}
+
+func TestCreatePullRequestGitHubReturnsExisting(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer gh-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.Method {
+		case http.MethodPost:
+			var body map[string]any
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if body["head"] == "agent/dup" {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				_, _ = w.Write([]byte(`{"message":"A pull request already exists"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"number":7,"html_url":"https://github.com/acme/widgets/pull/7"}`))
+		case http.MethodGet:
+			if r.URL.Query().Get("head") != "acme:agent/dup" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_, _ = w.Write([]byte(`[{"number":3,"html_url":"https://github.com/acme/widgets/pull/3"}]`))
+		}
+	}))
+	defer srv.Close()
+
+	client := &Client{GitHubToken: "gh-token", GitHubAPIURL: srv.URL}
+	remote := Remote{Provider: ProviderGitHub, Host: "github.com", Path: "acme/widgets"}
+	created, err := client.CreatePullRequest(context.Background(), PullRequest{Remote: remote, Head: "agent/new", Base: "main", Title: "t"})
+	if err != nil || created.Number != 7 || created.Existing {
+		t.Fatalf("created = %+v, %v", created, err)
+	}
+	existing, err := client.CreatePullRequest(context.Background(), PullRequest{Remote: remote, Head: "agent/dup", Base: "main", Title: "t"})
+	if err != nil || existing.Number != 3 || !existing.Existing {
+		t.Fatalf("existing = %+v, %v", existing, err)
+	}
+	if _, err := (&Client{}).CreatePullRequest(context.Background(), PullRequest{Remote: remote}); err == nil {
+		t.Fatal("missing token should fail")
+	}
+}
@@ -0,0 +1,2 @@
+-- 0017_agent_memories.down.sql — 回滚 0017: 删除 agent 长期记忆表 (保留 vector 扩展)。
+DROP TABLE IF EXISTS agent_memories;
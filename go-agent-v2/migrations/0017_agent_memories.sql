@@ -0,0 +1,38 @@
+-- 0017_agent_memories.sql — agent 长期记忆 (pgvector)。
+--
+-- 用途: 存储 turn 摘要 / 工具输出 / 手动笔记的向量, 供 memory/search 与 turn 提交前的自动检索。
+-- Go 代码: internal/store/agent_memory.go, internal/service/memory.go
+--
+-- 说明:
+-- - 向量维度固定 256 (service.MemoryEmbeddingDim)。
+-- - 数据库未安装 pgvector 扩展时跳过建表, 服务端探测到表不存在会自动关闭记忆功能;
+--   安装扩展后需手动执行本文件中的建表语句。
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_available_extensions WHERE name = 'vector') THEN
+        RAISE NOTICE 'pgvector extension not available, agent_memories skipped';
+        RETURN;
+    END IF;
+
+    CREATE EXTENSION IF NOT EXISTS vector;
+
+    CREATE TABLE IF NOT EXISTS agent_memories (
+        id          BIGSERIAL   PRIMARY KEY,
+        agent_id    TEXT        NOT NULL,
+        thread_id   TEXT        NOT NULL DEFAULT '',
+        turn_id     TEXT        NOT NULL DEFAULT '',
+        kind        TEXT        NOT NULL DEFAULT 'note',
+        content     TEXT        NOT NULL,
+        metadata    JSONB       NOT NULL DEFAULT '{}'::jsonb,
+        embedding   vector(256) NOT NULL,
+        created_at  BIGINT      NOT NULL DEFAULT 0
+    );
+
+    CREATE INDEX IF NOT EXISTS idx_agent_memories_agent_created
+        ON agent_memories (agent_id, created_at DESC);
+
+    CREATE INDEX IF NOT EXISTS idx_agent_memories_embedding
+        ON agent_memories USING hnsw (embedding vector_cosine_ops);
+END
+$$;
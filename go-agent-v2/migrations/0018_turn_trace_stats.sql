@@ -0,0 +1,17 @@
+-- 0018_turn_trace_stats.sql — turn 结果统计 (dashboard/turnStats)。
+--
+-- 用途: turn 结束时以 span_name='turn' 写入 task_traces (component = threadID),
+--       dashboard/turnStats 按 agent / 天聚合状态分布、耗时分位与中断原因。
+-- Go 代码: internal/apiserver/turn_tracker.go (recordTurnTrace), internal/store/turn_stats.go
+--
+-- 说明:
+-- - turn 状态为 completed / interrupted / failed, 原 chk_task_traces_status 只允许 span 状态,
+--   导致 turn 记录写入失败; 此处放宽约束。
+-- - 统计查询按 span_name + started_at 范围扫描, 补充复合索引。
+
+ALTER TABLE task_traces DROP CONSTRAINT IF EXISTS chk_task_traces_status;
+ALTER TABLE task_traces ADD CONSTRAINT chk_task_traces_status
+    CHECK (status IN ('running', 'ok', 'error', 'cancelled', 'completed', 'interrupted', 'failed'));
+
+CREATE INDEX IF NOT EXISTS idx_task_traces_span_started
+    ON task_traces (span_name, started_at DESC);
@@ -0,0 +1,2 @@
+-- 0019_notify_channels.down.sql — 回滚 0019: 删除外部通知渠道表。
+DROP TABLE IF EXISTS notify_channels;
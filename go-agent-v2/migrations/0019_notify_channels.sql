@@ -0,0 +1,23 @@
+-- 0019_notify_channels.sql — 外部通知渠道 (Slack / 邮件 / 通用 webhook)。
+--
+-- 用途: agent 卡住 / 审批长时间未处理 / turn 失败等事件按渠道过滤条件投递到外部系统。
+-- Go 代码: internal/store/notify_channel.go, internal/apiserver/notify_sinks.go
+--
+-- 说明:
+-- - config 按 kind 区分: slack {webhookUrl}, webhook {url, headers}, email {host, port, from, to, username, passwordEnv}。
+-- - events 为空表示订阅全部事件; agent_ids 为空表示不限 agent。
+-- - 邮件密码不落库, 经 passwordEnv 指定的环境变量读取。
+
+CREATE TABLE IF NOT EXISTS notify_channels (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    kind TEXT NOT NULL,
+    config JSONB NOT NULL DEFAULT '{}'::jsonb,
+    events TEXT[] NOT NULL DEFAULT '{}',
+    agent_ids TEXT[] NOT NULL DEFAULT '{}',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT chk_notify_channels_kind
+        CHECK (kind IN ('slack', 'email', 'webhook'))
+);
@@ -0,0 +1,2 @@
+-- 0020_thread_search.down.sql — 回滚 0020: 删除线程全文检索表。
+DROP TABLE IF EXISTS thread_search_docs;
@@ -0,0 +1,20 @@
+-- 0020_thread_search.sql — 线程时间线全文检索 (thread/search)。
+--
+-- 用途: assistant 消息 / 命令 / 文件名按条目写入, tsvector (simple 词典, 兼容中英文混排)
+--       + GIN 索引检索; 中文等无空格分词的查询由 content ILIKE 兜底。
+--       同一线程内 (kind, doc_hash) 唯一, 历史重新加载生成新 item_id 时只更新 item_id。
+-- Go 代码: internal/store/thread_search.go, internal/apiserver/thread_search.go
+
+CREATE TABLE IF NOT EXISTS thread_search_docs (
+    thread_id  TEXT        NOT NULL,
+    kind       TEXT        NOT NULL,
+    doc_hash   TEXT        NOT NULL,
+    item_id    TEXT        NOT NULL,
+    content    TEXT        NOT NULL,
+    ts         TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    tsv        TSVECTOR    GENERATED ALWAYS AS (to_tsvector('simple', content)) STORED,
+    PRIMARY KEY (thread_id, kind, doc_hash)
+);
+
+CREATE INDEX IF NOT EXISTS idx_thread_search_docs_tsv ON thread_search_docs USING GIN (tsv);
+CREATE INDEX IF NOT EXISTS idx_thread_search_docs_thread_ts ON thread_search_docs (thread_id, ts DESC);
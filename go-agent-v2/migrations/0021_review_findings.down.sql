@@ -0,0 +1,2 @@
+-- 0021_review_findings.down.sql — 回滚 0021: 删除代码审查发现表。
+DROP TABLE IF EXISTS review_findings;
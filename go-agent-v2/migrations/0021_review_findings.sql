@@ -0,0 +1,33 @@
+-- 0021_review_findings.sql — 代码审查结构化发现 (review/start → review/findings/*)。
+--
+-- 用途: review/start 对 diff 范围执行审查, agent 输出的发现按条解析入库;
+--       前端经 review/findings/list 拉取, accept / dismiss 更新处理状态。
+-- Go 代码: internal/store/review_finding.go, internal/apiserver/review_pipeline.go
+--
+-- 说明:
+-- - review_id 标识一次审查 (同一次审查的发现共享), scope 记录 diff 范围 (base / staged / paths)。
+-- - line 为 0 表示发现不对应具体行。
+
+CREATE TABLE IF NOT EXISTS review_findings (
+    id BIGSERIAL PRIMARY KEY,
+    review_id TEXT NOT NULL,
+    thread_id TEXT NOT NULL,
+    turn_id TEXT NOT NULL DEFAULT '',
+    scope TEXT NOT NULL DEFAULT '',
+    file TEXT NOT NULL DEFAULT '',
+    line INTEGER NOT NULL DEFAULT 0,
+    severity TEXT NOT NULL,
+    title TEXT NOT NULL,
+    suggestion TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'open',
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT chk_review_findings_severity
+        CHECK (severity IN ('critical', 'major', 'minor', 'info')),
+    CONSTRAINT chk_review_findings_status
+        CHECK (status IN ('open', 'accepted', 'dismissed'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_review_findings_thread ON review_findings (thread_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_review_findings_review ON review_findings (review_id);
@@ -0,0 +1,2 @@
+-- 0022_ui_runtime_state.down.sql — 回滚 0022: 删除 UI 运行时状态表。
+DROP TABLE IF EXISTS ui_runtime_state;
@@ -0,0 +1,15 @@
+-- 0022_ui_runtime_state.sql — UI 运行时状态跨重启持久化。
+--
+-- 用途: app-server 周期性及关闭时保存线程状态、状态栏、token 用量、workspace runs,
+--       启动时恢复, 使 UI 重启后立即显示有意义的状态而非全部空闲。
+-- Go 代码: internal/store/ui_runtime_state.go, internal/apiserver/ui_runtime_persist.go
+--
+-- 说明:
+-- - 每个 app-server 实例一行 (instance_id, 默认 'default'), state 为 uistate.PersistedRuntimeState。
+-- - 未连接数据库时改存本地文件 (UI_STATE_FILE)。
+
+CREATE TABLE IF NOT EXISTS ui_runtime_state (
+    instance_id TEXT PRIMARY KEY,
+    state JSONB NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
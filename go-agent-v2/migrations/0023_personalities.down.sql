@@ -0,0 +1,3 @@
+-- 0023_personalities.down.sql — 回滚 0023: 删除人格目录与线程人格表。
+DROP TABLE IF EXISTS thread_personalities;
+DROP TABLE IF EXISTS personalities;
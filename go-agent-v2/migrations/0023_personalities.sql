@@ -0,0 +1,26 @@
+-- 0023_personalities.sql — agent 人格目录 (命名系统提示词预设) 与线程当前人格。
+--
+-- 用途: personality/create 保存预设, personality/apply 套用到线程 (codex /personality + 指令注入);
+--       线程当前人格持久化, thread/resume 时重新套用。
+-- Go 代码: internal/store/personality.go, internal/apiserver/personality.go
+--
+-- 说明:
+-- - base 为 codex 内置人格 (none / friendly / pragmatic), 空表示不切换。
+-- - 内置预设不入库, 同名自定义预设覆盖内置。
+
+CREATE TABLE IF NOT EXISTS personalities (
+    name TEXT PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT '',
+    base TEXT NOT NULL DEFAULT '',
+    instructions TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT chk_personalities_base
+        CHECK (base IN ('', 'none', 'friendly', 'pragmatic'))
+);
+
+CREATE TABLE IF NOT EXISTS thread_personalities (
+    thread_id TEXT PRIMARY KEY,
+    personality TEXT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
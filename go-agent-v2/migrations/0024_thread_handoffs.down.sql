@@ -0,0 +1,2 @@
+-- 0024_thread_handoffs.down.sql — 回滚 0024: 删除交接摘要表。
+DROP TABLE IF EXISTS thread_handoffs;
@@ -0,0 +1,28 @@
+-- 0024_thread_handoffs.sql — 优雅停止时 agent 生成的交接摘要。
+--
+-- 用途: thread/stop/graceful 中断当前 turn 后让 agent 输出机器可读的交接摘要 (待办步骤 / 已改文件),
+--       持久化后在该线程下一次 turn 提交时注入提示词, 注入后标记 consumed_at。
+-- Go 代码: internal/store/thread_handoff.go, internal/apiserver/thread_handoff.go
+--
+-- 说明:
+-- - status: captured (摘要解析成功) / unstructured (非 JSON 回复, 原文保存在 raw) / timeout (未在时限内回复)。
+-- - 同一线程可有多条记录, 只注入最新一条未消费的记录。
+
+CREATE TABLE IF NOT EXISTS thread_handoffs (
+    id BIGSERIAL PRIMARY KEY,
+    thread_id TEXT NOT NULL,
+    turn_id TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    summary TEXT NOT NULL DEFAULT '',
+    outstanding_steps TEXT[] NOT NULL DEFAULT '{}',
+    modified_files TEXT[] NOT NULL DEFAULT '{}',
+    raw TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    consumed_at TIMESTAMPTZ,
+    CONSTRAINT chk_thread_handoffs_status
+        CHECK (status IN ('captured', 'unstructured', 'timeout'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_thread_handoffs_thread_created
+    ON thread_handoffs (thread_id, created_at DESC);
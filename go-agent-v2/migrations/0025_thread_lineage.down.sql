@@ -0,0 +1,2 @@
+-- 0025_thread_lineage.down.sql — 回滚 0025: 删除线程分叉关系表。
+DROP TABLE IF EXISTS thread_lineage;
@@ -0,0 +1,22 @@
+-- 0025_thread_lineage.sql — 线程分叉关系 (父线程 → 子线程)。
+--
+-- 用途: thread/fork 记录子线程来自哪个父线程、在第几个 turn 处分叉, 供 thread/lineage 查询
+--       祖先链与子线程 (会话分支 UI)。
+-- Go 代码: internal/store/thread_lineage.go, internal/apiserver/thread_lineage.go
+--
+-- 说明:
+-- - 每个子线程只有一个父线程, child_thread_id 为主键。
+-- - turn_index 为 NULL 表示整段分叉 (codex fork), 否则为保留的最后一个 turn 的下标 (从 0 开始)。
+
+CREATE TABLE IF NOT EXISTS thread_lineage (
+    child_thread_id TEXT PRIMARY KEY,
+    parent_thread_id TEXT NOT NULL,
+    turn_index INTEGER,
+    codex_thread_id TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT chk_thread_lineage_turn_index
+        CHECK (turn_index IS NULL OR turn_index >= 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_thread_lineage_parent
+    ON thread_lineage (parent_thread_id, created_at);
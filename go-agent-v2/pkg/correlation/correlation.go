@@ -0,0 +1,147 @@
+// Package correlation 请求关联 ID 与因果链记录。
+//
+// apiserver 为每个 JSON-RPC 调用创建一个 Trail (ID 形如 req-<毫秒>-<序号>), 经 context 传递;
+// 沿途的 codex 调用、存储写入、通知与 turn 结束以 Record 追加为事件, 无 Trail 的 context 上为空操作。
+// 连续相同的通知合并计数 (如流式 delta), 单个 Trail 最多保留 MaxEvents 条事件。
+package correlation
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MaxEvents 单个 Trail 保留的事件上限 (超出部分只计入 Dropped)。
+const MaxEvents = 256
+
+// 事件类型。
+const (
+	KindRequest      = "request"
+	KindResponse     = "response"
+	KindCodex        = "codex"
+	KindStore        = "store"
+	KindNotification = "notification"
+	KindTurn         = "turn"
+)
+
+// Event 因果链上的一个事件。
+type Event struct {
+	Seq        int       `json:"seq"`
+	Ts         time.Time `json:"ts"`
+	Kind       string    `json:"kind"`
+	Name       string    `json:"name"` // 方法名 / codex 操作 / SQL 动作与表名 / 通知方法
+	ThreadID   string    `json:"threadId,omitempty"`
+	TurnID     string    `json:"turnId,omitempty"`
+	Status     string    `json:"status,omitempty"`
+	Detail     string    `json:"detail,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMS int64     `json:"durationMs,omitempty"`
+	Count      int       `json:"count,omitempty"` // >1 = 连续同类事件已合并
+}
+
+// Trail 单个请求的事件链 (并发安全)。
+type Trail struct {
+	ID        string
+	Method    string
+	StartedAt time.Time
+
+	mu      sync.Mutex
+	events  []Event
+	dropped int
+}
+
+var seq atomic.Int64
+
+// NewID 生成关联 ID。
+func NewID() string {
+	return fmt.Sprintf("req-%d-%d", time.Now().UnixMilli(), seq.Add(1))
+}
+
+// New 为方法调用创建 Trail。
+func New(method string) *Trail {
+	return &Trail{ID: NewID(), Method: method, StartedAt: time.Now()}
+}
+
+// Add 追加事件; 与上一条相同 (方法/线程/turn 一致) 的通知合并计数。
+func (t *Trail) Add(ev Event) {
+	if t == nil {
+		return
+	}
+	if ev.Ts.IsZero() {
+		ev.Ts = time.Now()
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if n := len(t.events); n > 0 && ev.Kind == KindNotification {
+		last := &t.events[n-1]
+		if last.Kind == ev.Kind && last.Name == ev.Name && last.ThreadID == ev.ThreadID && last.TurnID == ev.TurnID {
+			if last.Count == 0 {
+				last.Count = 1
+			}
+			last.Count++
+			return
+		}
+	}
+	if len(t.events) >= MaxEvents {
+		t.dropped++
+		return
+	}
+	ev.Seq = len(t.events) + 1
+	t.events = append(t.events, ev)
+}
+
+// Events 返回事件副本与被丢弃的数量。
+func (t *Trail) Events() ([]Event, int) {
+	if t == nil {
+		return nil, 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Event(nil), t.events...), t.dropped
+}
+
+// HasSideEffects 是否记录了请求/响应以外的事件 (codex 调用 / 存储写入 / 通知 / turn)。
+func (t *Trail) HasSideEffects() bool {
+	if t == nil {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, ev := range t.events {
+		if ev.Kind != KindRequest && ev.Kind != KindResponse {
+			return true
+		}
+	}
+	return false
+}
+
+type ctxKey struct{}
+
+// WithTrail 把 Trail 绑定到 context。
+func WithTrail(ctx context.Context, t *Trail) context.Context {
+	return context.WithValue(ctx, ctxKey{}, t)
+}
+
+// FromContext 取 context 上的 Trail (不存在时为 nil)。
+func FromContext(ctx context.Context) *Trail {
+	if ctx == nil {
+		return nil
+	}
+	t, _ := ctx.Value(ctxKey{}).(*Trail)
+	return t
+}
+
+// ID 取 context 上的关联 ID (不存在时为空串)。
+func ID(ctx context.Context) string {
+	if t := FromContext(ctx); t != nil {
+		return t.ID
+	}
+	return ""
+}
+
+// Record 向 context 上的 Trail 追加事件。
+func Record(ctx context.Context, ev Event) {
+	FromContext(ctx).Add(ev)
+}
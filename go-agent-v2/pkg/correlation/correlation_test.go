@@ -0,0 +1,42 @@
+package correlation
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTrail_RecordViaContext(t *testing.T) {
+	Record(context.Background(), Event{Kind: KindCodex, Name: "noop"}) // 无 Trail 时为空操作
+
+	trail := New("turn/start")
+	ctx := WithTrail(context.Background(), trail)
+	if ID(ctx) != trail.ID || trail.ID == "" {
+		t.Fatalf("ID = %q, trail = %q", ID(ctx), trail.ID)
+	}
+	Record(ctx, Event{Kind: KindRequest, Name: "turn/start"})
+	if trail.HasSideEffects() {
+		t.Fatal("request event alone should not count as side effect")
+	}
+	Record(ctx, Event{Kind: KindNotification, Name: "item/agentMessage/delta", ThreadID: "t1"})
+	Record(ctx, Event{Kind: KindNotification, Name: "item/agentMessage/delta", ThreadID: "t1"})
+	Record(ctx, Event{Kind: KindStore, Name: "INSERT task_traces"})
+
+	events, dropped := trail.Events()
+	if len(events) != 3 || dropped != 0 {
+		t.Fatalf("events = %#v dropped = %d", events, dropped)
+	}
+	if events[1].Count != 2 || events[2].Seq != 3 || !trail.HasSideEffects() {
+		t.Fatalf("events = %#v", events)
+	}
+}
+
+func TestTrail_CapsEvents(t *testing.T) {
+	trail := New("x")
+	for i := 0; i < MaxEvents+5; i++ {
+		trail.Add(Event{Kind: KindStore, Name: "INSERT t"})
+	}
+	events, dropped := trail.Events()
+	if len(events) != MaxEvents || dropped != 5 {
+		t.Fatalf("len = %d dropped = %d", len(events), dropped)
+	}
+}
@@ -0,0 +1,95 @@
+// Package errcode 错误码注册表: JSON-RPC 错误响应 error.data.code 的全部取值。
+//
+// 错误码为稳定的大写蛇形字符串, 客户端据此分支处理 (重试 / 提示 / 重新加载线程),
+// 不依赖错误消息文本。服务端以 errors.NewCode / errors.WrapCode 附加错误码,
+// apiserver 分发层经 errors.CodeOf 读取并写入响应; 未附加错误码的错误回退为 INTERNAL
+// (或按哨兵错误映射为 NOT_FOUND / INVALID_INPUT / TIMEOUT)。
+//
+// 新增错误码须同时登记到 registry, 以便 errors/codes 方法对外列出。
+package errcode
+
+import "sort"
+
+// 通用错误码。
+const (
+	Internal      = "INTERNAL"
+	InvalidParams = "INVALID_PARAMS"
+	InvalidInput  = "INVALID_INPUT"
+	NotFound      = "NOT_FOUND"
+	Timeout       = "TIMEOUT"
+	Canceled      = "CANCELED"
+)
+
+// 线程 / codex 进程相关错误码。
+const (
+	ThreadNotFound   = "THREAD_NOT_FOUND"
+	ResumeExhausted  = "RESUME_EXHAUSTED"
+	CodexCrashed     = "CODEX_CRASHED"
+	CodexCircuitOpen = "CODEX_CIRCUIT_OPEN"
+	CodexRateLimited = "CODEX_RATE_LIMITED"
+)
+
+// 策略 / 安全相关错误码。
+const (
+	BlockedCommand      = "BLOCKED_COMMAND"
+	EmergencyLocked     = "EMERGENCY_LOCKED"
+	ToolNotAllowed      = "TOOL_NOT_ALLOWED"
+	IdempotencyConflict = "IDEMPOTENCY_CONFLICT"
+	SandboxViolation    = "SANDBOX_VIOLATION"
+	ServerDraining      = "SERVER_DRAINING"
+	ReadOnly            = "READ_ONLY"
+)
+
+// JSON-RPC 2.0 错误码 (与 apiserver 协议常量一致)。
+const (
+	rpcInvalidParams = -32602
+	rpcInternalError = -32603
+	rpcOverloaded    = -32001
+)
+
+// Descriptor 错误码说明。
+type Descriptor struct {
+	Code        string `json:"code"`
+	RPCCode     int    `json:"rpcCode"`   // JSON-RPC error.code
+	Retryable   bool   `json:"retryable"` // 原样重试可能成功
+	Description string `json:"description"`
+}
+
+var registry = map[string]Descriptor{
+	Internal:      {Code: Internal, RPCCode: rpcInternalError, Description: "未分类的服务端错误"},
+	InvalidParams: {Code: InvalidParams, RPCCode: rpcInvalidParams, Description: "请求参数无法解析"},
+	InvalidInput:  {Code: InvalidInput, RPCCode: rpcInvalidParams, Description: "请求参数校验失败"},
+	NotFound:      {Code: NotFound, RPCCode: rpcInternalError, Description: "请求的资源不存在"},
+	Timeout:       {Code: Timeout, RPCCode: rpcInternalError, Retryable: true, Description: "操作超时"},
+	Canceled:      {Code: Canceled, RPCCode: rpcInternalError, Retryable: true, Description: "请求被取消 (连接断开或服务关闭)"},
+
+	ThreadNotFound:   {Code: ThreadNotFound, RPCCode: rpcInternalError, Description: "线程不存在且无历史记录"},
+	ResumeExhausted:  {Code: ResumeExhausted, RPCCode: rpcInternalError, Description: "历史线程恢复失败, 所有 rollout 候选均不可用"},
+	CodexCrashed:     {Code: CodexCrashed, RPCCode: rpcInternalError, Retryable: true, Description: "codex 进程崩溃或连接异常断开 (1006)"},
+	CodexCircuitOpen: {Code: CodexCircuitOpen, RPCCode: rpcOverloaded, Retryable: true, Description: "codex 连接反复断开, 熔断冷却中"},
+	CodexRateLimited: {Code: CodexRateLimited, RPCCode: rpcOverloaded, Retryable: true, Description: "超出单个 codex 客户端的请求速率限制"},
+
+	BlockedCommand:      {Code: BlockedCommand, RPCCode: rpcInternalError, Description: "命令被安全策略拦截"},
+	EmergencyLocked:     {Code: EmergencyLocked, RPCCode: rpcInternalError, Description: "紧急停止生效中, 需 orchestrator/unlock 解除"},
+	ToolNotAllowed:      {Code: ToolNotAllowed, RPCCode: rpcInternalError, Description: "工具不在线程角色模板的允许列表内"},
+	IdempotencyConflict: {Code: IdempotencyConflict, RPCCode: rpcInvalidParams, Description: "同一 idempotencyKey 携带了不同参数"},
+	SandboxViolation:    {Code: SandboxViolation, RPCCode: rpcInternalError, Description: "命令违反线程沙箱配置 (写入路径 / 网络)"},
+	ServerDraining:      {Code: ServerDraining, RPCCode: rpcOverloaded, Retryable: true, Description: "服务排空中 (滚动重启), 不再接受新线程与 turn"},
+	ReadOnly:            {Code: ReadOnly, RPCCode: rpcInternalError, Description: "只读观战连接不允许调用该方法或访问未选择的线程"},
+}
+
+// Lookup 查找错误码说明。
+func Lookup(code string) (Descriptor, bool) {
+	d, ok := registry[code]
+	return d, ok
+}
+
+// All 返回全部已登记错误码 (按 Code 排序)。
+func All() []Descriptor {
+	out := make([]Descriptor, 0, len(registry))
+	for _, d := range registry {
+		out = append(out, d)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Code < out[j].Code })
+	return out
+}
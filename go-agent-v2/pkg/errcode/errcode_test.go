@@ -0,0 +1,40 @@
+package errcode
+
+import (
+	"fmt"
+	"testing"
+
+	apperrors "github.com/multi-agent/go-agent-v2/pkg/errors"
+)
+
+func TestRegistry_AllCodesDescribed(t *testing.T) {
+	seen := map[string]bool{}
+	for _, d := range All() {
+		if d.Code == "" || d.Description == "" || d.RPCCode == 0 {
+			t.Fatalf("incomplete descriptor: %+v", d)
+		}
+		if seen[d.Code] {
+			t.Fatalf("duplicate code %s", d.Code)
+		}
+		seen[d.Code] = true
+	}
+	for _, code := range []string{ThreadNotFound, ResumeExhausted, CodexCrashed, BlockedCommand, Internal} {
+		if _, ok := Lookup(code); !ok {
+			t.Fatalf("code %s not registered", code)
+		}
+	}
+}
+
+func TestCodeOf_OutermostCodeWins(t *testing.T) {
+	inner := apperrors.NewCode("Inner", CodexCrashed, "ws closed")
+	if got := apperrors.CodeOf(apperrors.Wrap(inner, "Outer", "resume")); got != CodexCrashed {
+		t.Fatalf("CodeOf(uncoded wrap) = %q, want %q", got, CodexCrashed)
+	}
+	outer := apperrors.WrapCode(inner, "Outer", ResumeExhausted, "resume")
+	if got := apperrors.CodeOf(fmt.Errorf("ctx: %w", outer)); got != ResumeExhausted {
+		t.Fatalf("CodeOf(coded wrap) = %q, want %q", got, ResumeExhausted)
+	}
+	if got := apperrors.CodeOf(fmt.Errorf("plain")); got != "" {
+		t.Fatalf("CodeOf(plain) = %q, want empty", got)
+	}
+}
@@ -86,3 +86,42 @@ func Wrap(err error, op string, message string) error {
 func Wrapf(err error, op, format string, args ...any) error {
 	return &AppError{Op: op, Message: fmt.Sprintf(format, args...), Err: err}
 }
+
+// ========================================
+// 错误码 (取值见 pkg/errcode)
+// ========================================
+
+// NewCode 创建带错误码的应用错误。
+func NewCode(op, code, message string) error {
+	return &AppError{Op: op, Code: code, Message: message}
+}
+
+// NewCodef 创建带错误码与格式化消息的应用错误。
+func NewCodef(op, code, format string, args ...any) error {
+	return &AppError{Op: op, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// WrapCode 包装错误并附加错误码。
+func WrapCode(err error, op, code, message string) error {
+	return &AppError{Op: op, Code: code, Message: message, Err: err}
+}
+
+// WrapCodef 用格式化消息包装错误并附加错误码。
+func WrapCodef(err error, op, code, format string, args ...any) error {
+	return &AppError{Op: op, Code: code, Message: fmt.Sprintf(format, args...), Err: err}
+}
+
+// CodeOf 返回错误链中最外层非空的错误码 (无则返回空串)。
+func CodeOf(err error) string {
+	for err != nil {
+		var appErr *AppError
+		if !errors.As(err, &appErr) {
+			return ""
+		}
+		if appErr.Code != "" {
+			return appErr.Code
+		}
+		err = appErr.Err
+	}
+	return ""
+}
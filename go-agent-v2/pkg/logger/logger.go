@@ -14,6 +14,7 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -33,6 +34,9 @@ var (
 
 	// exitFunc 可替换的退出函数, 仅供测试拦截 os.Exit。
 	exitFunc = os.Exit
+
+	// levelVar 全局日志级别, 所有 handler 共享, SetLevel 可运行时调整。
+	levelVar slog.LevelVar
 )
 
 func init() { defaultLogger.Store(newLogger(false)) }
@@ -58,7 +62,7 @@ func replaceTimeAttr(_ []string, a slog.Attr) slog.Attr {
 
 func newLogger(development bool) *slog.Logger {
 	opts := &slog.HandlerOptions{
-		Level:       slog.LevelInfo,
+		Level:       &levelVar,
 		AddSource:   development,
 		ReplaceAttr: replaceTimeAttr,
 	}
@@ -71,12 +75,46 @@ func newLogger(development bool) *slog.Logger {
 	return slog.New(handler)
 }
 
-// Init 初始化日志配置。env: "development"/"dev" 或 "production" (默认)。
+// Init 初始化日志配置。env: "development"/"dev" 或 "production" (默认);
+// 传入日志级别名 (DEBUG/INFO/WARN/ERROR) 时同时设置级别。
 func Init(env string) {
 	dev := env == "development" || env == "dev"
 	storeLogger(newLogger(dev))
+	SetLevel(env)
+}
+
+// InitStderr 初始化日志并全部输出到 stderr (JSON 格式), 用于 stdout 被协议占用的进程 (如 MCP stdio)。
+func InitStderr(level string) {
+	opts := &slog.HandlerOptions{Level: &levelVar, ReplaceAttr: replaceTimeAttr}
+	storeLogger(slog.New(slog.NewJSONHandler(os.Stderr, opts)))
+	SetLevel(level)
 }
 
+// ParseLevel 解析日志级别名 (不区分大小写, 支持 WARNING 别名)。
+func ParseLevel(name string) (slog.Level, bool) {
+	var level slog.Level
+	normalized := strings.ToUpper(strings.TrimSpace(name))
+	if normalized == "WARNING" {
+		normalized = "WARN"
+	}
+	if normalized == "" || level.UnmarshalText([]byte(normalized)) != nil {
+		return slog.LevelInfo, false
+	}
+	return level, true
+}
+
+// SetLevel 运行时调整全局日志级别, 无法识别的级别名返回 false 且不生效。
+func SetLevel(name string) bool {
+	level, ok := ParseLevel(name)
+	if ok {
+		levelVar.Set(level)
+	}
+	return ok
+}
+
+// Level 当前全局日志级别。
+func Level() slog.Level { return levelVar.Level() }
+
 // InitWithFile 初始化日志, 同时输出到 stdout 和日志文件。
 //
 // 日志文件: {logDir}/agent-terminal-{date}.log (JSON 格式)。
@@ -103,7 +141,7 @@ func InitWithFile(logDir string) error {
 
 	// MultiWriter: stdout + file
 	multi := io.MultiWriter(os.Stdout, f)
-	opts := &slog.HandlerOptions{Level: slog.LevelInfo, ReplaceAttr: replaceTimeAttr}
+	opts := &slog.HandlerOptions{Level: &levelVar, ReplaceAttr: replaceTimeAttr}
 	handler := slog.NewJSONHandler(multi, opts)
 	storeLogger(slog.New(handler))
 
@@ -268,4 +306,5 @@ const (
 	FieldDecision   = "decision"
 	FieldPID        = "pid"
 	FieldState      = "state"
+	FieldSessionID  = "session_id"
 )